@@ -0,0 +1,68 @@
+package iacgen
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+)
+
+// promptClarifier asks clarification questions on a terminal
+type promptClarifier struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// newPromptClarifier creates a clarifier reading answers from in and writing prompts to out
+func newPromptClarifier(in io.Reader, out io.Writer) *promptClarifier {
+	return &promptClarifier{
+		in:  bufio.NewReader(in),
+		out: out,
+	}
+}
+
+// Ask implements nlp.Clarifier. Invalid answers are asked again; an empty answer or the
+// end of input selects the default.
+func (c *promptClarifier) Ask(ctx context.Context, clarification nlp.Clarification) (string, error) {
+	for {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		fmt.Fprintf(c.out, "%s [%s] (default %s): ", clarification.Question, strings.Join(clarification.Options, "/"), clarification.Default)
+		line, err := c.in.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("failed to read answer: %w", err)
+		}
+
+		answer := strings.ToLower(strings.TrimSpace(line))
+		if answer == "" {
+			if err == io.EOF {
+				fmt.Fprintln(c.out)
+			}
+			return clarification.Default, nil
+		}
+		for _, option := range clarification.Options {
+			if answer == option {
+				return answer, nil
+			}
+		}
+		if err == io.EOF {
+			return "", fmt.Errorf("invalid answer %q", answer)
+		}
+		fmt.Fprintf(c.out, "Please answer one of: %s\n", strings.Join(clarification.Options, ", "))
+	}
+}
+
+// isInteractiveTerminal reports whether stdin is attached to a terminal
+func isInteractiveTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
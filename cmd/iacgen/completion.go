@@ -0,0 +1,117 @@
+package iacgen
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/config"
+	"github.com/riptano/iac_generator_cli/internal/plugin"
+	"github.com/spf13/cobra"
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish|powershell",
+	Short: "Generate a shell completion script",
+	Long: `Generate the completion script of iacgen for a shell.
+
+Besides commands and flags, the script completes the values of --output, --region,
+--dr-region and --profile, reading the profile names from the config files.
+
+To load completions in the current shell:
+
+  Bash:        source <(iacgen completion bash)
+  Zsh:         source <(iacgen completion zsh)
+  Fish:        iacgen completion fish | source
+  PowerShell:  iacgen completion powershell | Out-String | Invoke-Expression
+
+To load them in every new shell, write the script to the shell's completion directory,
+for example:
+
+  Bash:  iacgen completion bash > /etc/bash_completion.d/iacgen
+  Zsh:   iacgen completion zsh > "${fpath[1]}/_iacgen"
+  Fish:  iacgen completion fish > ~/.config/fish/completions/iacgen.fish`,
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := cmd.OutOrStdout()
+		switch args[0] {
+		case "bash":
+			return cmd.Root().GenBashCompletionV2(out, true)
+		case "zsh":
+			return cmd.Root().GenZshCompletion(out)
+		case "fish":
+			return cmd.Root().GenFishCompletion(out, true)
+		case "powershell":
+			return cmd.Root().GenPowerShellCompletionWithDesc(out)
+		}
+		return fmt.Errorf("unsupported shell: %s", args[0])
+	},
+}
+
+// completeOutputFormats completes the values of --output, with the formats of the generator
+// plugins, and the next format of a comma-separated list
+func completeOutputFormats(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	formats := []string{
+		"terraform\tTerraform HCL",
+		"crossplane\tCrossplane manifests",
+	}
+	// Completions are requested without running the commands' hooks, which load the plugins
+	if plugins == nil {
+		loadPlugins(cmd)
+	}
+	for _, p := range plugins {
+		if p.Kind == plugin.KindGenerator {
+			formats = append(formats, fmt.Sprintf("%s\tPlugin %s", p.Format, p.Name))
+		}
+	}
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		for j := range formats {
+			formats[j] = toComplete[:i+1] + formats[j]
+		}
+	}
+	return formats, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeRegions completes AWS regions
+func completeRegions(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return knownRegions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProfiles completes the names of the profiles the config files define
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	files := config.DefaultConfigFiles()
+	if config.CfgFile != "" {
+		files = []string{config.CfgFile}
+	}
+
+	return config.ProfileNames(files), cobra.ShellCompDirectiveNoFileComp
+}
+
+// registerFlagCompletion registers the completion of a flag's values. Flags are registered
+// when the program starts, so a failure is a programming error.
+func registerFlagCompletion(cmd *cobra.Command, flag string, complete func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective)) {
+	if err := cmd.RegisterFlagCompletionFunc(flag, complete); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// registerCompletions registers the completion of flag values. It runs after every
+// command's flags are added.
+func registerCompletions() {
+	// The completion command replaces cobra's default one, to document the dynamic completions
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+
+	registerFlagCompletion(rootCmd, "output", completeOutputFormats)
+	registerFlagCompletion(rootCmd, "region", completeRegions)
+	registerFlagCompletion(rootCmd, "profile", completeProfiles)
+	registerFlagCompletion(generateCmd, "progress", cobra.FixedCompletions([]string{
+		"text\tProgress as text",
+		"json\tJSON lines events on stdout",
+	}, cobra.ShellCompDirectiveNoFileComp))
+	for _, cmd := range []*cobra.Command{generateCmd, diffCmd} {
+		registerFlagCompletion(cmd, "dr-region", completeRegions)
+	}
+}
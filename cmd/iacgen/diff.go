@@ -0,0 +1,87 @@
+package iacgen
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Diff command flags
+	diffNoColor  bool
+	diffExitCode bool
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [description]",
+	Short: "Show what generating a description would change in an output directory",
+	Long: `Parse a description, generate it into a temporary directory, and show a per-file
+unified diff against the output directory, without writing anything to it.
+
+Use it to review what a change to a description would do to committed IaC before
+running generate. It takes the same description, spec and generation flags as
+generate. Files that would be added or modified are shown as diffs; files in the
+output directory that the description does not generate are listed, since generate
+leaves them in place.
+
+The diff is colored when stdout is a terminal, unless --no-color or NO_COLOR is set.
+Progress and the assumptions report are written to stderr, so the diff can be piped
+or saved as a patch.`,
+	Example: `  # Preview the change to committed Terraform
+  iacgen diff "Create a VPC with an EKS cluster with 5 nodes" --output-dir ./infra
+
+  # Preview a description kept in a file, failing CI when the directory is out of date
+  iacgen diff --file infra.txt --output-dir ./infra --non-interactive --exit-code
+
+  # Save the changes as a patch
+  iacgen diff --file infra.txt --output-dir ./infra --no-color > infra.patch`,
+	Args: cobra.MaximumNArgs(1),
+	// The diff explains the exit status, and Execute prints errors once
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateDescriptionFlags(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		params := descriptionParams(args)
+		params.OutputDir = outputDir
+		params.ProgressWriter = os.Stderr
+
+		diff, err := pipeline.DiffDescription(params)
+		if errors.Is(err, nlp.ErrReviewAborted) {
+			return fmt.Errorf("aborted during review; nothing was compared")
+		}
+		if err != nil {
+			return err
+		}
+
+		color := !diffNoColor && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+		fmt.Fprint(cmd.OutOrStdout(), diff.Text(color))
+
+		if diffExitCode && diff.HasChanges() {
+			os.Exit(1)
+		}
+		return nil
+	},
+}
+
+// isTerminal reports whether a file is attached to a terminal
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func init() {
+	addDescriptionFlags(diffCmd)
+	addGenerationFlags(diffCmd)
+
+	diffCmd.Flags().BoolVar(&diffNoColor, "no-color", false, "Print the diff without colors")
+	diffCmd.Flags().BoolVar(&diffExitCode, "exit-code", false, "Exit with status 1 when generating would change the output directory")
+}
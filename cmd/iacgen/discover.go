@@ -0,0 +1,69 @@
+package iacgen
+
+import (
+	"fmt"
+
+	"github.com/riptano/iac_generator_cli/internal/discover"
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Discover command flags
+	discoverVPCID     string
+	discoverProfile   string
+	discoverSaveModel string
+)
+
+var discoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "Discover an existing VPC and its EKS clusters into an infrastructure model",
+	Long: `Build an infrastructure model of a VPC that already exists in AWS, saved as JSON like the
+models of --save-model, without generating anything. Only Describe and List calls are made,
+so nothing in the account is changed.
+
+The model has the VPC, its subnets, internet and NAT gateways, and the EKS clusters in the
+VPC with their node groups, add-ons and Fargate profiles. Generate it with --from-model as
+Terraform or Crossplane. The resources are named after their Name tag, or their name or ID
+in AWS, and refer to each other by these names. Subnets routing to an internet gateway are
+public. Tags every resource has become the tags of the model.
+
+The VPC is looked up in the region of --region, with the credentials of the AWS SDK: the
+environment, a profile of the shared configuration (--profile) or the role of the instance.`,
+	Example: `  # Discover a VPC and generate it as Crossplane manifests
+  iacgen discover --vpc-id vpc-0123456789abcdef0 --region us-west-2 --save-model model.json
+  iacgen generate --from-model model.json --output crossplane --output-dir ./crossplane
+
+  # Use a profile of the shared AWS configuration
+  iacgen discover --vpc-id vpc-0123456789abcdef0 --profile production`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if discoverVPCID == "" {
+			return fmt.Errorf("specify the VPC to discover with --vpc-id")
+		}
+
+		ctx := cmd.Context()
+		discoverer, err := discover.NewAWSDiscoverer(ctx, awsRegion, discoverProfile)
+		if err != nil {
+			return err
+		}
+		model, err := discoverer.Discover(ctx, discoverVPCID)
+		if err != nil {
+			return err
+		}
+		if err := pipeline.SaveModelFile(discoverSaveModel, model); err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "Discovered %d resources of %s into %s\n", len(model.Resources), discoverVPCID, discoverSaveModel)
+		fmt.Fprintf(out, "\nTo generate the model, run:\n  iacgen generate --from-model %s --output crossplane\n", discoverSaveModel)
+		return nil
+	},
+}
+
+func init() {
+	discoverCmd.Flags().StringVar(&discoverVPCID, "vpc-id", "", "ID of the VPC to discover")
+	discoverCmd.Flags().StringVar(&discoverProfile, "profile", "", "Profile of the shared AWS configuration (default: the SDK's default chain)")
+	discoverCmd.Flags().StringVar(&discoverSaveModel, "save-model", "model.json", "JSON file the discovered model is saved to, for generate --from-model")
+}
@@ -0,0 +1,67 @@
+package iacgen
+
+import (
+	"fmt"
+
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Estimate command flags
+	estimateJSON      bool
+	estimateEstimator string
+)
+
+var estimateCmd = &cobra.Command{
+	Use:   "estimate [directory]",
+	Short: "Estimate the monthly cost of a directory of generated Terraform",
+	Long: `Estimate the monthly cost of the Terraform root modules of an output directory,
+and print it per module, then per resource.
+
+The cost is estimated with infracost breakdown when infracost is in PATH, which needs
+an Infracost API key. Otherwise, or with --estimator builtin, it is estimated with
+built-in us-east-1 on-demand prices of the common resources: EC2 instances, auto
+scaling groups, EKS clusters and node groups, NAT gateways, Elastic IPs, load
+balancers and RDS instances. Their counts, for_each and attributes are read with the
+values of terraform.tfvars and the variable defaults. The cost of resources billed by
+usage, such as S3 buckets and Lambda functions, is not included.`,
+	Example: `  # Estimate the current output directory
+  iacgen estimate
+
+  # Estimate with the built-in prices, without infracost
+  iacgen estimate ./infra --estimator builtin
+
+  # Print the estimate as JSON
+  iacgen estimate ./infra --json`,
+	Args: cobra.MaximumNArgs(1),
+	// Execute prints the error once
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := outputDir
+		if len(args) > 0 {
+			dir = args[0]
+		}
+
+		estimate, err := report.EstimateCosts(dir, report.EstimateOptions{Estimator: estimateEstimator})
+		if err != nil {
+			return err
+		}
+		if estimateJSON {
+			content, err := estimate.JSON()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), content)
+			return nil
+		}
+		fmt.Fprint(cmd.OutOrStdout(), estimate.Text())
+		return nil
+	},
+}
+
+func init() {
+	estimateCmd.Flags().BoolVar(&estimateJSON, "json", false, "Print the estimate as JSON")
+	estimateCmd.Flags().StringVar(&estimateEstimator, "estimator", "", "Cost estimator: infracost or builtin (default infracost when it is in PATH, builtin otherwise)")
+}
@@ -0,0 +1,84 @@
+package iacgen
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/spf13/cobra"
+)
+
+// Explain command flags
+var explainFormat string
+
+var explainCmd = &cobra.Command{
+	Use:   "explain [description]",
+	Short: "Print the infrastructure model parsed from a description",
+	Long: `Parse a description, or load a spec, and print the infrastructure model it produces,
+without generating anything.
+
+The model is printed as a tree of resources with their properties and dependencies,
+followed by the defaults that were assumed because the description did not state a
+value. Use it to check what the parser understood before generating, or paste the
+Markdown version into a pull request description.
+
+It takes the same description and parsing flags as generate. A description covering
+several environments is explained one environment at a time, chosen with --environment.`,
+	Example: `  # Explain a description
+  iacgen explain "Create a VPC with 2 public subnets and an EKS cluster with 3 nodes"
+
+  # Summarize the infrastructure of a pull request
+  iacgen explain --file infra.txt --format markdown --non-interactive
+
+  # Inspect what the LLM backend extracted, as JSON
+  iacgen explain --file infra.txt --nlp llm --format json`,
+	Args: cobra.MaximumNArgs(1),
+	// Execute prints errors once
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if !isValidExplainFormat(explainFormat) {
+			return fmt.Errorf("invalid format: %s (supported formats: text, json, markdown)", explainFormat)
+		}
+		return validateDescriptionFlags(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		explanation, err := pipeline.ExplainDescription(descriptionParams(args))
+		if errors.Is(err, nlp.ErrReviewAborted) {
+			return fmt.Errorf("aborted during review; nothing was explained")
+		}
+		if err != nil {
+			return err
+		}
+
+		switch explainFormat {
+		case "json":
+			content, err := explanation.JSON()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), content)
+		case "markdown":
+			fmt.Fprint(cmd.OutOrStdout(), explanation.Markdown())
+		default:
+			fmt.Fprint(cmd.OutOrStdout(), explanation.Text())
+		}
+		return nil
+	},
+}
+
+// isValidExplainFormat checks if the explanation format is supported
+func isValidExplainFormat(format string) bool {
+	switch format {
+	case "text", "json", "markdown":
+		return true
+	}
+	return false
+}
+
+func init() {
+	addDescriptionFlags(explainCmd)
+
+	explainCmd.Flags().StringVar(&explainFormat, "format", "text", "Output format of the explanation (text, json or markdown)")
+}
@@ -0,0 +1,149 @@
+package iacgen
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// defaultFeedbackRepo is the GitHub repository feedback issues are filed against
+const defaultFeedbackRepo = "piyushjajoo1991/iac_generator_cli"
+
+// feedbackLabel is the label of feedback issues, whether gh files them or the user does
+const feedbackLabel = "parser-feedback"
+
+var (
+	// Feedback command flags
+	feedbackFile    string
+	feedbackMessage string
+	feedbackOutput  string
+	feedbackJSON    bool
+	feedbackOpen    bool
+	feedbackRepo    string
+)
+
+var feedbackCmd = &cobra.Command{
+	Use:   "feedback [description]",
+	Short: "Package a description the parser got wrong into an issue-ready report",
+	Long: `Package an infrastructure description into an issue-ready feedback report.
+
+The report contains the description with credentials, account IDs, email addresses
+and public IP addresses redacted, an explanation of what the parser recognized,
+and the tool version. Review it, then paste it into a GitHub issue or let iacgen
+open the issue for you with --open-issue (requires the GitHub CLI, gh).`,
+	Example: `  # Print a feedback report for a description
+  iacgen feedback "Create a VPC with 3 private subnets and a bastion host"
+
+  # Explain what went wrong and save the report
+  iacgen feedback -f infra.txt -m "The bastion host was ignored" --out feedback.md
+
+  # Open a GitHub issue with the report
+  iacgen feedback -f infra.txt -m "The bastion host was ignored" --open-issue`,
+	Args: cobra.MaximumNArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && feedbackFile == "" {
+			return fmt.Errorf("either provide a description as an argument or specify an input file with --file")
+		}
+		if len(args) > 0 && feedbackFile != "" {
+			return fmt.Errorf("provide either a description argument or --file, not both")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := utils.GetLogger()
+
+		description := ""
+		if len(args) > 0 {
+			description = args[0]
+		} else {
+			content, err := utils.ReadFromFile(feedbackFile)
+			if err != nil {
+				return fmt.Errorf("failed to read input file: %w", err)
+			}
+			description = content
+		}
+
+		feedback := report.GenerateFeedbackReport(description, feedbackMessage)
+
+		content := feedback.Markdown()
+		if feedbackJSON {
+			jsonContent, err := feedback.JSON()
+			if err != nil {
+				return err
+			}
+			content = jsonContent
+		}
+
+		if feedbackOutput != "" {
+			if err := utils.WriteToFile(feedbackOutput, content); err != nil {
+				return fmt.Errorf("failed to write feedback report: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Feedback report written to %s\n", feedbackOutput)
+		} else if !feedbackOpen {
+			fmt.Fprintln(cmd.OutOrStdout(), content)
+		}
+
+		if feedbackOpen {
+			issueURL, err := openFeedbackIssue(feedbackRepo, feedback)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Feedback issue: %s\n", issueURL)
+		}
+
+		logger.Debugw("Feedback report generated",
+			"redactions", len(feedback.Redactions),
+			"parse_error", feedback.ParseError,
+		)
+		return nil
+	},
+}
+
+// openFeedbackIssue files the report as a GitHub issue with the gh CLI. Without gh, it
+// returns a pre-filled new-issue URL for the user to open in a browser instead.
+func openFeedbackIssue(repo string, feedback *report.FeedbackReport) (string, error) {
+	body := feedback.Markdown()
+
+	if _, err := exec.LookPath("gh"); err != nil {
+		query := url.Values{}
+		query.Set("title", feedback.Title())
+		query.Set("body", body)
+		query.Set("labels", feedbackLabel)
+		return fmt.Sprintf("https://github.com/%s/issues/new?%s", repo, query.Encode()), nil
+	}
+
+	ghCmd := exec.Command("gh", "issue", "create",
+		"--repo", repo,
+		"--title", feedback.Title(),
+		"--label", feedbackLabel,
+		"--body-file", "-",
+	)
+	ghCmd.Stdin = strings.NewReader(body)
+	ghCmd.Stderr = os.Stderr
+	output, err := ghCmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to create GitHub issue: %w", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+func init() {
+	// Input options
+	feedbackCmd.Flags().StringVarP(&feedbackFile, "file", "f", "", "Input file containing the infrastructure description")
+	feedbackCmd.Flags().StringVarP(&feedbackMessage, "message", "m", "", "What the generator got wrong or missed")
+
+	// Output options
+	feedbackCmd.Flags().StringVar(&feedbackOutput, "out", "", "Write the report to a file instead of stdout")
+	feedbackCmd.Flags().BoolVar(&feedbackJSON, "json", false, "Render the report as JSON instead of Markdown")
+
+	// Issue options
+	feedbackCmd.Flags().BoolVar(&feedbackOpen, "open-issue", false, "Open a GitHub issue with the report (uses the gh CLI when installed)")
+	feedbackCmd.Flags().StringVar(&feedbackRepo, "repo", defaultFeedbackRepo, "GitHub repository to open the feedback issue in")
+}
@@ -1,14 +1,26 @@
 package iacgen
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/riptano/iac_generator_cli/internal/adapter/crossplane"
+	"github.com/riptano/iac_generator_cli/internal/adapter/terraform"
+	"github.com/riptano/iac_generator_cli/internal/config"
+	"github.com/riptano/iac_generator_cli/internal/infra"
 	"github.com/riptano/iac_generator_cli/internal/nlp"
 	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/riptano/iac_generator_cli/internal/policy"
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/riptano/iac_generator_cli/internal/retry"
 	"github.com/riptano/iac_generator_cli/internal/utils"
 	"github.com/riptano/iac_generator_cli/pkg/models"
 	"github.com/spf13/cobra"
@@ -17,8 +29,57 @@ import (
 
 var (
 	// Generate command flags
-	inputFile    string
+	inputFiles   []string
+	specFile     string
+	modelFile    string
+	saveModel    string
 	outputFile   string
+	complianceReport bool
+	lint         bool
+	lintIAM      bool
+	scan         bool
+	scanFailOn   string
+	checkov      bool
+	estimate     bool
+	estimator    string
+	checkQuotas  bool
+	drRegion     string
+	nlpBackend   string
+	llmModel     string
+	llmEndpoint  string
+	llmTimeout   time.Duration
+	nonInteractive bool
+	review       bool
+	synonymsFile string
+	environment  string
+	generateStdout bool
+	generateTar  bool
+	dryRun       bool
+	resume       bool
+	progressFormat string
+	keepPartial  bool
+	showMetrics  bool
+	metricsFile  string
+	backendSettings []string
+	tagSettings     []string
+	workspaceSettings []string
+	importMapFile   string
+	crossplaneImportMapFile string
+	preventDestroyTypes []string
+	ignoreChangeSettings []string
+	addonVersionSettings []string
+
+	// importMap holds the imports of the import map file
+	importMap map[string]string
+
+	// crossplaneImportMap holds the imports of the Crossplane import map file
+	crossplaneImportMap map[string]string
+
+	// stdinDescription holds the description read from stdin when the description argument is "-"
+	stdinDescription string
+
+	// synonyms holds the built-in synonyms and those of the synonyms file
+	synonyms *nlp.SynonymRegistry
 )
 
 var generateCmd = &cobra.Command{
@@ -27,58 +88,239 @@ var generateCmd = &cobra.Command{
 	Long: `Generate Infrastructure as Code (IaC) manifests from a natural language description.
 The description should detail the AWS infrastructure you want to provision.
 
-You can provide the description directly as an argument or specify a file containing
-the description using the --file flag. The generated IaC manifest will be printed
-to stdout by default, or written to the specified output directory.`,
+You can provide the description directly as an argument, pass - to read it from stdin,
+or specify a file containing the description using the --file flag. For fully specified, repeatable input, pass a
+YAML or JSON spec with --spec instead; it skips natural language parsing entirely. The generated IaC manifest will be printed
+to stdout by default, or written to the specified output directory. With --stdout, the
+generated files are written to stdout as one document, or as a tar stream with --tar,
+instead of to the output directory, so generate can be used in pipes.
+
+With --dry-run, nothing is written: generate prints the tree of files it would write,
+each marked as created, modified or unchanged, with a unified diff of every modified
+file. Files generation adds to, such as Crossplane kustomizations, are shown with the
+entries that would be added.
+
+With --resume, the parsed and built models are saved under .iacgen/cache, keyed by a hash
+of the input, and a rerun with the same input resumes from them instead of parsing the
+description again. Set cache_dir in the config file to keep them elsewhere.
+
+With --progress json, progress is written to stdout as JSON lines events instead of text:
+one event when the run and each stage starts, completes or fails, each file is written
+and a warning is emitted, for CI systems and other programs following the run. The text
+progress is written to stderr instead.
+
+The files are generated into a staging directory in the output directory and moved into
+it once generation succeeded, so a failed or interrupted run leaves the output directory
+as it was. With --keep-partial, they are written to the output directory directly, and
+the files of a failed run are left in place.`,
 	Example: `  # Generate from command-line description
   iacgen generate "Create an EC2 instance with t2.micro size"
 
   # Generate from a file
   iacgen generate --file ./infra-description.txt
 
+  # Save the parsed model for review, then generate it again later without parsing
+  iacgen generate --file ./infra-description.txt --save-model model.json
+  iacgen generate --from-model model.json --output crossplane
+
   # Generate with specific output format and directory
   iacgen generate "Create an S3 bucket for static website hosting" --output crossplane --output-dir ./manifests
 
+  # Generate Terraform and Crossplane side by side, into ./infra/terraform and ./infra/crossplane
+  iacgen generate "Create a VPC with an EKS cluster" --output terraform,crossplane --output-dir ./infra
+
   # Generate with a specific region
   iacgen generate "Create a new VPC with 3 subnets" --region us-west-2
   
   # Generate using the template system
-  iacgen generate "Create an EKS cluster with 2 nodes" --use-templates`,
+  iacgen generate "Create an EKS cluster with 2 nodes" --use-templates
+
+  # Generate with a compliance matrix for auditors
+  iacgen generate "Create a VPC with an EKS cluster" --output-dir ./infra --compliance-report
+
+  # Lint the generated Terraform with tflint and print the findings
+  iacgen generate "Create a VPC with an EKS cluster" --output-dir ./infra --lint
+
+  # Lint the generated IAM roles and policies for wildcards and broad managed policies
+  iacgen generate "Create an EKS cluster with the AWS load balancer controller" --output-dir ./infra --lint-iam
+
+  # Scan the generated files with trivy or tfsec, failing on findings of high severity
+  iacgen generate "Create a VPC with an EKS cluster" --output-dir ./infra --scan --scan-fail-on high
+
+  # Check the generated files against the checkov policies
+  iacgen generate "Create a VPC with an EKS cluster" --output-dir ./infra --checkov
+
+  # Print the monthly cost of the generated Terraform per module
+  iacgen generate "Create a VPC with an EKS cluster" --output-dir ./infra --estimate
+
+  # Fail before generating when the model violates the organization's Rego policies
+  iacgen generate "Create a VPC with an EKS cluster" --environment prod --policy ./policies
+
+  # Warn before generating when the account's service quotas are too low for the infrastructure
+  iacgen generate "Create a VPC with 3 NAT gateways" --region us-west-2 --check-quotas
+
+  # Generate the staging environment, with terraform.tfvars.staging for the staging workspace
+  iacgen generate "Create a VPC with an EKS cluster" --output-dir ./infra --environment staging
+
+  # Tag every resource with its owner, and fail when no cost center is set
+  iacgen generate "Create a VPC with an EKS cluster" --output-dir ./infra --tag Owner=platform \
+    --require-tag CostCenter,Owner
+
+  # Name the resources after the environment and the application, such as prod-orders-vpc
+  iacgen generate "Create a VPC with an EKS cluster" --output-dir ./infra --environment prod \
+    --name-pattern "{env}-{app}-{resource}" --app-name orders
+
+  # Review and edit the parsed values before anything is generated
+  iacgen generate "Create a VPC with an EKS cluster with 3 nodes" --output-dir ./infra --review
+
+  # Keep the state in S3, locked with DynamoDB, with the configuration creating both
+  iacgen generate "Create a VPC with an EKS cluster" --output-dir ./infra --backend s3 \
+    --backend-config bucket=my-state --backend-config key=infra/terraform.tfstate \
+    --backend-config dynamodb_table=terraform-locks --backend-bootstrap
+
+  # Use the terraform-aws-modules VPC and EKS modules instead of generating local ones
+  iacgen generate "Create a VPC with an EKS cluster" --output-dir ./infra --module-source registry
+
+  # Generate a single directory without modules
+  iacgen generate "Create a VPC with an EKS cluster" --output-dir ./infra --module-layout flat
+
+  # Write the Crossplane resources for the Upbound AWS provider family
+  iacgen generate "Create a VPC with an EKS cluster" --output crossplane --output-dir ./infra \
+    --crossplane-provider upbound
+
+  # Let the providers assume an IAM role through their service account instead of reading a Secret
+  iacgen generate "Create a VPC with an EKS cluster" --output crossplane --output-dir ./infra \
+    --crossplane-credentials irsa --crossplane-role-arn arn:aws:iam::123456789012:role/crossplane
+
+  # Also write Compositions of the network and the cluster, with example claims for teams
+  iacgen generate "Create a VPC with an EKS cluster" --output crossplane --output-dir ./infra --compositions
+
+  # Place the claims in a namespace of the team owning them, with a service account allowed to manage them
+  iacgen generate "Create a VPC with an EKS cluster" --output crossplane --output-dir ./infra --compositions --team payments
+
+  # Publish the cluster's kubeconfig, and aggregate it into a secret of each claim
+  iacgen generate "Create a VPC with an EKS cluster" --output crossplane --output-dir ./infra \
+    --compositions --connection-secrets composite
+
+  # Adopt an existing VPC and cluster, observing them until their management policy is widened
+  iacgen generate "Create a VPC with an EKS cluster" --output crossplane --output-dir ./infra \
+    --crossplane-import-map crossplane-imports.yaml
+
+  # Observe existing AWS resources without changing them, and keep them if the manifests are deleted
+  iacgen generate "Create a VPC with an EKS cluster" --output crossplane --output-dir ./infra \
+    --management-policy observe --deletion-policy orphan
+
+  # Write an Argo CD Application deploying the Crossplane output from a Git repository
+  iacgen generate "Create a VPC with an EKS cluster" --output crossplane --output-dir ./infra \
+    --gitops argocd --gitops-repo https://github.com/acme/platform.git --gitops-sync auto
+
+  # Or a Flux GitRepository and Kustomizations reconciling it every 5 minutes
+  iacgen generate "Create a VPC with an EKS cluster" --output crossplane --output-dir ./infra \
+    --gitops flux --gitops-repo https://github.com/acme/platform.git --gitops-interval 5m
+
+  # Lock the providers for Linux and macOS, so terraform init installs the same ones everywhere
+  iacgen generate "Create a VPC with an EKS cluster" --output-dir ./infra --lock-providers \
+    --lock-platforms linux_amd64,darwin_arm64
+
+  # Pin Terraform and the AWS provider to newer versions
+  iacgen generate "Create a VPC with an EKS cluster" --output-dir ./infra --terraform-version 1.6.0 \
+    --aws-provider-version "~> 5.61"
+
+  # Generate variables for dev, staging and prod workspaces, with a larger prod node group
+  iacgen generate "Create a VPC with an EKS cluster" --output-dir ./infra --workspaces dev,staging,prod \
+    --workspace-set prod.node_instance_type=m5.xlarge
+
+  # Protect the cluster from being destroyed, and leave node group sizes to the cluster autoscaler
+  iacgen generate "Create a VPC with an EKS cluster" --output-dir ./infra --prevent-destroy aws_eks_cluster \
+    --ignore-changes aws_eks_node_group=scaling_config[0].desired_size
+
+  # Pin the CoreDNS add-on instead of taking its version from the compatibility table
+  iacgen generate "Create a VPC with an EKS cluster with coredns and kube-proxy" --output-dir ./infra \
+    --addon-version coredns=v1.11.4-eksbuild.2
+
+  # Adopt an existing VPC and bucket instead of creating them
+  iacgen generate "Create a VPC with an EKS cluster" --output-dir ./infra --import-map imports.yaml
+
+  # Generate a disaster-recovery variant in a secondary region
+  iacgen generate "Create a VPC with an EKS cluster" --output-dir ./infra --dr-region eu-west-1
+
+  # Parse the description with an LLM (requires OPENAI_API_KEY), falling back to the regex parser
+  iacgen generate "A highly available network for a small web app with a kubernetes cluster" --nlp llm
+
+  # Parse the description with a local model served by Ollama
+  iacgen generate "A highly available network with a kubernetes cluster" --nlp ollama --llm-model qwen2.5:7b
+
+  # Read the description from stdin and write the manifests to stdout
+  echo "Create a VPC with an EKS cluster" | iacgen generate - --output crossplane --stdout > manifests.yaml
+
+  # Show which files would be created or modified, without writing anything
+  iacgen generate "Create a VPC with an EKS cluster with 5 nodes" --output-dir ./infra --dry-run
+
+  # Stream progress as JSON lines events, such as in CI
+  iacgen generate "Create a VPC with an EKS cluster" --output-dir ./infra --progress json --non-interactive
+
+  # Parse with an LLM once, and resume from the saved model if a later stage fails
+  iacgen generate --file infra.txt --nlp llm --output-dir ./infra --resume
+
+  # Stream every generated file as a tar archive
+  echo "Create a VPC with an EKS cluster" | iacgen generate - --stdout --tar | tar -x -C ./infra`,
 	Args: cobra.MaximumNArgs(1),
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		logger := utils.GetLogger()
 		
-		// Validate input - either direct description or file must be provided
-		if len(args) == 0 && inputFile == "" {
-			return fmt.Errorf("either provide a description as an argument or specify an input file with --file")
+		if err := validateDescriptionFlags(cmd, args); err != nil {
+			return err
 		}
 		
-		// Validate output format
-		if !isValidOutputFormat(toolFormat) {
-			return fmt.Errorf("invalid output format: %s (supported formats: terraform, crossplane)", toolFormat)
+		if dryRun && generateStdout {
+			return fmt.Errorf("--dry-run writes nothing; do not combine it with --stdout")
+		}
+		if lint && (dryRun || generateStdout) {
+			return fmt.Errorf("--lint lints the files written to the output directory; do not combine it with --dry-run or --stdout")
+		}
+		if lintIAM && (dryRun || generateStdout) {
+			return fmt.Errorf("--lint-iam lints the files written to the output directory; do not combine it with --dry-run or --stdout")
+		}
+		if (scan || scanFailOn != "") && (dryRun || generateStdout) {
+			return fmt.Errorf("--scan scans the files written to the output directory; do not combine it with --dry-run or --stdout")
+		}
+		if checkov && (dryRun || generateStdout) {
+			return fmt.Errorf("--checkov checks the files written to the output directory; do not combine it with --dry-run or --stdout")
+		}
+		if (estimate || estimator != "") && (dryRun || generateStdout) {
+			return fmt.Errorf("--estimate estimates the files written to the output directory; do not combine it with --dry-run or --stdout")
+		}
+		if scanFailOn != "" && !report.ValidScanSeverity(scanFailOn) {
+			return fmt.Errorf("invalid --scan-fail-on severity: %s (use %s)", scanFailOn, strings.ToLower(strings.Join(report.ScanSeverities, ", ")))
 		}
 		
-		// If input file is specified, check if it exists and is readable
-		if inputFile != "" {
-			if !utils.FileExists(inputFile) {
-				return fmt.Errorf("input file does not exist: %s", inputFile)
+		switch progressFormat {
+		case "text":
+		case "json":
+			if generateStdout || dryRun {
+				return fmt.Errorf("--progress json writes events to stdout; do not combine it with --stdout or --dry-run")
 			}
-			
-			// Check if file is readable
-			if _, err := utils.ReadFromFile(inputFile); err != nil {
-				return fmt.Errorf("cannot read input file: %s (%w)", inputFile, err)
+		default:
+			return fmt.Errorf("invalid progress format: %s (use text or json)", progressFormat)
+		}
+		
+		// Nothing is written to the output directory in pipe mode
+		if generateTar && !generateStdout {
+			return fmt.Errorf("--tar requires --stdout")
+		}
+		if generateStdout {
+			if cmd.Flags().Changed("output-dir") || outputFile != "" {
+				return fmt.Errorf("--stdout writes the generated files to stdout; do not combine it with --output-dir or --output-file")
 			}
-			
-			logger.Debug("Input file validated", "file", inputFile)
+			return nil
 		}
 		
-		// Validate region format (basic check for now)
-		if !isValidRegionFormat(awsRegion) {
-			logger.Warn("AWS region format may be invalid", "region", awsRegion)
+		// Nothing is written in a dry run, so the output directory is not created
+		if dryRun {
+			return nil
 		}
 		
-		// Create output directory if it doesn't exist
-		outputDir, _ := cmd.Flags().GetString("output-dir")
+		// Create output directory if it doesn't exist; it may come from the config file
 		if outputDir != "." {
 			// Check if we have write permission by creating the directory
 			if err := utils.EnsureDirectoryExists(outputDir); err != nil {
@@ -124,57 +366,749 @@ to stdout by default, or written to the specified output directory.`,
 			"output_format", outputFormat,
 			"region", region,
 			"output_dir", outDir,
-			"input_file", inputFile,
+			"input_files", inputFiles,
 			"use_templates", useTemplates)
 			
-		var description string
-		
-		// Get description from argument 
-		if len(args) > 0 {
-			description = args[0]
-			logger.Debug("Using description from argument")
+		params := descriptionParams(args)
+		if resume {
+			params.CacheDir = viper.GetString("cache_dir")
 		}
-		
-		// Create pipeline parameters
-		params := &pipeline.ProcessingParams{
-			Description:    description,
-			InputFile:      inputFile,
-			OutputFormat:   outputFormat,
-			OutputDir:      outDir,
-			OutputFile:     outputFile,
-			Region:         region,
-			UseTemplates:   useTemplates,
-			Debug:          debugMode,
-			ProgressWriter: os.Stdout,
+		if showMetrics || metricsFile != "" {
+			params.Metrics = pipeline.NewMetrics()
 		}
+		if generateStdout {
+			err := writeGeneratedFiles(cmd.OutOrStdout(), params)
+			reportMetrics(os.Stderr, params.Metrics)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		params.OutputDir = outDir
+		params.OutputFile = outputFile
+		params.KeepPartial = keepPartial
+		if dryRun {
+			err := printDryRun(cmd.OutOrStdout(), params)
+			reportMetrics(os.Stderr, params.Metrics)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		// With JSON progress, stdout is left to the events
+		var progress io.Writer = os.Stdout
+		if progressFormat == "json" {
+			params.Events = pipeline.NewJSONLinesSink(cmd.OutOrStdout())
+			progress = os.Stderr
+		}
+		params.ProgressWriter = progress
 		
 		// Process through the pipeline
-		result, err := pipeline.RunWithProgressFeedback(params, os.Stdout)
+		result, err := pipeline.RunWithProgressFeedback(params, progress)
+		reportMetrics(progress, params.Metrics)
+		if errors.Is(err, nlp.ErrReviewAborted) {
+			fmt.Fprintln(os.Stderr, "Aborted during review; nothing was generated")
+			os.Exit(1)
+		}
 		if err != nil {
 			logger.Error("Failed to generate IaC manifest", "error", err.Error())
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 		
-		// Print the result
-		fmt.Println(result)
+		// Print the result; the pipeline_completed event carries it with JSON progress
+		if params.Events == nil {
+			fmt.Println(result)
+		}
 		
 		logger.Info("Successfully generated IaC manifest")
 	},
 }
 
+// reportMetrics prints the metrics of a run as a table to w with --metrics, and writes them
+// to the --metrics-file report. The metrics of failed runs are reported too.
+func reportMetrics(w io.Writer, metrics *pipeline.Metrics) {
+	if metrics == nil {
+		return
+	}
+	if showMetrics {
+		fmt.Fprintf(w, "\nStage metrics:\n%s", metrics.Text())
+	}
+	if metricsFile != "" {
+		report, err := metrics.JSON()
+		if err == nil {
+			err = utils.WriteToFile(metricsFile, report+"\n")
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to write metrics report: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// writeGeneratedFiles generates the files of the parameters and writes them to out as one
+// document, or as a tar stream with --tar. The assumptions report is written to stderr.
+func writeGeneratedFiles(out io.Writer, params *pipeline.ProcessingParams) error {
+	params.ProgressWriter = os.Stderr
+	files, err := pipeline.GenerateFiles(context.Background(), params)
+	if errors.Is(err, nlp.ErrReviewAborted) {
+		return fmt.Errorf("aborted during review; nothing was generated")
+	}
+	if err != nil {
+		return err
+	}
+	utils.GetLogger().Infow("Writing generated files to stdout", "files", len(files), "tar", generateTar)
+
+	if generateTar {
+		return pipeline.WriteArchive(out, files, false)
+	}
+	_, err = io.WriteString(out, pipeline.ConcatenateFiles(files))
+	return err
+}
+
+// printDryRun generates the files of the parameters without writing to the output directory,
+// and prints which of them would be created, modified or left unchanged
+func printDryRun(out io.Writer, params *pipeline.ProcessingParams) error {
+	params.ProgressWriter = os.Stderr
+	diff, err := pipeline.DiffDescription(params)
+	if errors.Is(err, nlp.ErrReviewAborted) {
+		return fmt.Errorf("aborted during review; nothing was generated")
+	}
+	if err != nil {
+		return err
+	}
+	color := os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+	_, err = io.WriteString(out, diff.Plan(color))
+	return err
+}
+
+// validateDescriptionFlags validates the flags shared by the commands that generate from a
+// description or spec, and loads the synonyms file
+func validateDescriptionFlags(cmd *cobra.Command, args []string) error {
+	logger := utils.GetLogger()
+	bindDescriptionFlags(cmd)
+
+	// Validate input - either direct description, file or spec must be provided
+	if len(args) == 0 && len(inputFiles) == 0 && specFile == "" && modelFile == "" {
+		return fmt.Errorf("either provide a description as an argument, specify an input file with --file, a spec with --spec or a saved model with --from-model")
+	}
+	
+	// A description of "-" is read from stdin, which the review cannot share
+	if len(args) > 0 && args[0] == "-" {
+		if review {
+			return fmt.Errorf("--review reads its answers from stdin, so it cannot be combined with a description read from stdin")
+		}
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read the description from stdin: %w", err)
+		}
+		stdinDescription = strings.TrimSpace(string(content))
+		if stdinDescription == "" {
+			return fmt.Errorf("the description read from stdin is empty")
+		}
+	}
+	if specFile != "" {
+		if len(args) > 0 || len(inputFiles) > 0 {
+			return fmt.Errorf("--spec replaces the description; do not combine it with a description argument or --file")
+		}
+		if !utils.FileExists(specFile) {
+			return fmt.Errorf("spec file does not exist: %s", specFile)
+		}
+		if review {
+			return fmt.Errorf("--review shows what was parsed from a description; a spec has nothing to review")
+		}
+	}
+	if modelFile != "" {
+		if len(args) > 0 || len(inputFiles) > 0 || specFile != "" {
+			return fmt.Errorf("--from-model replaces the description; do not combine it with a description argument, --file or --spec")
+		}
+		if !utils.FileExists(modelFile) {
+			return fmt.Errorf("model file does not exist: %s", modelFile)
+		}
+		if review {
+			return fmt.Errorf("--review shows what was parsed from a description; a saved model has nothing to review")
+		}
+	}
+	if review && viper.GetBool("non_interactive") {
+		return fmt.Errorf("--review asks for confirmation, so it cannot be combined with --non-interactive")
+	}
+	if workers := viper.GetInt("workers"); workers < 0 {
+		return fmt.Errorf("invalid worker count: %d (use 0 for one per CPU)", workers)
+	}
+	if err := retryPolicy().Validate(); err != nil {
+		return fmt.Errorf("invalid retry settings: %w", err)
+	}
+	if source := viper.GetString("module_source"); source != "" {
+		if err := terraform.ValidateModuleSource(source); err != nil {
+			return err
+		}
+	}
+	if layout := viper.GetString("module_layout"); layout != "" {
+		if err := terraform.ValidateModuleLayout(layout); err != nil {
+			return err
+		}
+	}
+	if err := terraform.ValidateModuleNames(viper.GetStringMapString("module_names")); err != nil {
+		return err
+	}
+	if provider := viper.GetString("crossplane_provider"); provider != "" {
+		if err := crossplane.ValidateProvider(provider); err != nil {
+			return err
+		}
+	}
+	if source := viper.GetString("crossplane_credentials"); source != "" {
+		if err := crossplane.ValidateCredentials(source); err != nil {
+			return err
+		}
+	}
+	if team := viper.GetString("team"); team != "" {
+		if err := crossplane.ValidateTeam(team); err != nil {
+			return err
+		}
+	}
+	if mode := viper.GetString("connection_secrets"); mode != "" {
+		if err := crossplane.ValidateConnectionSecrets(mode); err != nil {
+			return err
+		}
+	}
+	if policy := viper.GetString("deletion_policy"); policy != "" {
+		if err := crossplane.ValidateDeletionPolicy(policy); err != nil {
+			return err
+		}
+	}
+	if policy := viper.GetString("management_policy"); policy != "" {
+		if err := crossplane.ValidateManagementPolicy(policy); err != nil {
+			return err
+		}
+	}
+	if tool := viper.GetString("gitops.type"); tool != "" {
+		if err := crossplane.ValidateGitOps(tool, gitOpsConfig()); err != nil {
+			return err
+		}
+	}
+	if version := viper.GetString("terraform_version"); version != "" {
+		if err := terraform.ValidateTerraformVersion(version); err != nil {
+			return err
+		}
+	}
+	if constraint := viper.GetString("aws_provider_version"); constraint != "" {
+		if err := terraform.ValidateProviderConstraint(constraint); err != nil {
+			return err
+		}
+	}
+	if err := terraform.ValidateLockPlatforms(lockPlatforms()); err != nil {
+		return err
+	}
+	if viper.IsSet("backend.type") || viper.GetBool("backend.bootstrap") || len(backendSettings) > 0 {
+		settings, err := backendConfig()
+		if err != nil {
+			return err
+		}
+		if err := terraform.ValidateBackend(viper.GetString("backend.type"), settings, viper.GetBool("backend.bootstrap")); err != nil {
+			return err
+		}
+	}
+	if _, err := workspaces(); err != nil {
+		return err
+	}
+	if _, err := lifecycleSettings(); err != nil {
+		return err
+	}
+	if _, err := checkovSuppressions(); err != nil {
+		return err
+	}
+	if _, err := addonVersions(); err != nil {
+		return err
+	}
+	importMap = nil
+	if importMapFile != "" {
+		imports, err := terraform.LoadImportMap(importMapFile)
+		if err != nil {
+			return err
+		}
+		importMap = imports
+	}
+	crossplaneImportMap = nil
+	if crossplaneImportMapFile != "" {
+		imports, err := crossplane.LoadImportMap(crossplaneImportMapFile)
+		if err != nil {
+			return err
+		}
+		crossplaneImportMap = imports
+	}
+	
+	// Validate output format
+	if !isValidOutputFormats(toolFormat) {
+		return fmt.Errorf("invalid output format: %s (supported formats: terraform, crossplane)", toolFormat)
+	}
+	
+	// If input files are specified, check if they exist and are readable
+	if len(inputFiles) > 1 && len(args) > 0 {
+		return fmt.Errorf("the descriptions of several --file flags are merged; do not combine them with a description argument")
+	}
+	for _, inputFile := range inputFiles {
+		if !utils.FileExists(inputFile) {
+			return fmt.Errorf("input file does not exist: %s", inputFile)
+		}
+		
+		// Check if file is readable
+		if _, err := utils.ReadFromFile(inputFile); err != nil {
+			return fmt.Errorf("cannot read input file: %s (%w)", inputFile, err)
+		}
+		
+		logger.Debug("Input file validated", "file", inputFile)
+	}
+	
+	// Validate region format (basic check for now)
+	if !isValidRegionFormat(awsRegion) {
+		logger.Warn("AWS region format may be invalid", "region", awsRegion)
+	}
+	
+	// Validate the DR region
+	if drRegion != "" {
+		if !isValidRegionFormat(drRegion) {
+			return fmt.Errorf("invalid DR region: %s", drRegion)
+		}
+		if drRegion == awsRegion {
+			return fmt.Errorf("DR region must differ from the primary region: %s", drRegion)
+		}
+	}
+	
+	// Validate the environment
+	if environment != "" && !infra.ValidEnvironment(environment) {
+		return fmt.Errorf("invalid environment: %s (use up to 16 lower-case letters, digits and hyphens, starting with a letter)", environment)
+	}
+	
+	// Validate the tags given for this run
+	if _, err := tagOverrides(); err != nil {
+		return err
+	}
+	
+	// Validate the naming policy, which may come from flags or the config file
+	if err := namingPolicy().Validate(); err != nil {
+		return err
+	}
+	
+	return validateParsingSettings()
+}
+
+// validateParsingSettings validates the settings of the commands that parse descriptions: the
+// NLP backend and the tags of the config file. It also loads the synonyms file. The backend
+// and synonyms file may come from flags or the config file.
+func validateParsingSettings() error {
+	// Validate the NLP backend
+	nlpBackend = viper.GetString("nlp_backend")
+	if !isValidNLPBackend(nlpBackend) {
+		return fmt.Errorf("invalid NLP backend: %s (supported backends: %s)", nlpBackend, strings.Join(nlp.SupportedBackends(), ", "))
+	}
+	
+	// Validate the tags of the config file, and those every model must have
+	for key, value := range config.AppConfig.Tags {
+		if err := infra.CheckTag(key, value); err != nil {
+			return fmt.Errorf("invalid tag in config file: %w", err)
+		}
+	}
+	for _, key := range viper.GetStringSlice("required_tags") {
+		if err := infra.CheckRequiredTag(key); err != nil {
+			return fmt.Errorf("invalid required tag: %w", err)
+		}
+	}
+
+	// Compile the policies, so a policy that does not compile fails before parsing
+	if paths := viper.GetStringSlice("policies"); len(paths) > 0 {
+		if _, err := policy.Load(context.Background(), paths); err != nil {
+			return err
+		}
+	}
+	
+	// Load the synonyms file
+	synonyms = nlp.DefaultSynonyms()
+	if path := viper.GetString("synonyms_file"); path != "" {
+		if err := synonyms.LoadFile(path); err != nil {
+			return err
+		}
+		utils.GetLogger().Debug("Synonyms loaded", "file", path)
+	}
+	
+	return nil
+}
+
+// descriptionParams creates the pipeline parameters for the description or spec given by
+// the arguments and flags shared by the commands that generate
+func descriptionParams(args []string) *pipeline.ProcessingParams {
+	var description string
+	
+	// Get description from argument, or from stdin for "-"
+	if len(args) > 0 {
+		description = args[0]
+		if description == "-" {
+			description = stdinDescription
+		}
+		utils.GetLogger().Debug("Using description from argument")
+	}
+	
+	// Follow-up questions and the review share stdin, so neither buffers the other's answers
+	stdin := bufio.NewReader(os.Stdin)
+	
+	// The backend settings and workspaces were validated with the flags
+	settings, _ := backendConfig()
+	workspaceList, _ := workspaces()
+	lifecycle, _ := lifecycleSettings()
+	addons, _ := addonVersions()
+	suppressions, _ := checkovSuppressions()
+	overrides, _ := tagOverrides()
+	
+	// The partial descriptions of several files are merged into one model
+	var inputFile string
+	var mergedFiles []string
+	if len(inputFiles) == 1 {
+		inputFile = inputFiles[0]
+	} else {
+		mergedFiles = inputFiles
+	}
+	
+	return &pipeline.ProcessingParams{
+		Description:    description,
+		InputFile:      inputFile,
+		InputFiles:     mergedFiles,
+		SpecFile:       specFile,
+		ModelFile:      modelFile,
+		SaveModel:      saveModel,
+		OutputFormat:   toolFormat,
+		Region:         awsRegion,
+		UseTemplates:   useTemplates,
+		Debug:          debugMode,
+		DRRegion:       drRegion,
+		Environment:    environment,
+		ComplianceReport: complianceReport,
+		Lint:           lint,
+		LintIAM:        lintIAM,
+		Scan:           scan || scanFailOn != "",
+		ScanFailOn:     strings.ToUpper(scanFailOn),
+		Checkov:        checkov,
+		CheckovSuppressions: suppressions,
+		Estimate:       estimate || estimator != "",
+		CheckQuotas:    checkQuotas,
+		Estimator:      estimator,
+		Workers:        viper.GetInt("workers"),
+		Backend:        viper.GetString("backend.type"),
+		BackendConfig:  settings,
+		BackendBootstrap: viper.GetBool("backend.bootstrap"),
+		ModuleSource:   viper.GetString("module_source"),
+		ModuleLayout:   viper.GetString("module_layout"),
+		ModuleNames:    viper.GetStringMapString("module_names"),
+		CrossplaneProvider: viper.GetString("crossplane_provider"),
+		CrossplaneCredentials: viper.GetString("crossplane_credentials"),
+		CrossplaneRoleARN: viper.GetString("crossplane_role_arn"),
+		Compositions:   viper.GetBool("compositions"),
+		Team:           viper.GetString("team"),
+		ConnectionSecrets: viper.GetString("connection_secrets"),
+		ConnectionSecretNamespace: viper.GetString("connection_secret_namespace"),
+		DeletionPolicy: viper.GetString("deletion_policy"),
+		ManagementPolicy: viper.GetString("management_policy"),
+		GitOps:         viper.GetString("gitops.type"),
+		GitOpsConfig:   gitOpsConfig(),
+		TerraformVersion: viper.GetString("terraform_version"),
+		ProviderVersion: viper.GetString("aws_provider_version"),
+		Workspaces:     workspaceList,
+		Imports:        importMap,
+		CrossplaneImports: crossplaneImportMap,
+		Lifecycle:      lifecycle,
+		FormatOutput:   viper.GetBool("format_output"),
+		LockPlatforms:  lockPlatforms(),
+		Retry:          retryPolicy(),
+		Tags:           config.AppConfig.Tags,
+		TagOverrides:   overrides,
+		RequiredTags:   viper.GetStringSlice("required_tags"),
+		Policies:       viper.GetStringSlice("policies"),
+		Naming:         namingPolicy(),
+		SkipModelValidation: viper.GetBool("skip_model_validation"),
+		AddonVersions:  addons,
+		NLPBackend:     nlpBackend,
+		LLMConfig:      llmConfig(nlpBackend),
+		Clarifier:      clarifier(stdin),
+		Reviewer:       reviewer(stdin),
+		Plugins:        plugins,
+		Hooks:          configuredHooks,
+	}
+}
+
+// tagOverrides returns the tags given with --tag, which override those of the config file and
+// the description
+func tagOverrides() (map[string]string, error) {
+	if len(tagSettings) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string, len(tagSettings))
+	for _, setting := range tagSettings {
+		key, value, ok := strings.Cut(setting, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid tag: %q (use key=value)", setting)
+		}
+		if err := infra.CheckTag(key, value); err != nil {
+			return nil, fmt.Errorf("invalid tag: %w", err)
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
+
+// namingPolicy returns the naming policy of the config file's naming section, overridden by
+// the --name-pattern, --name-case and --app-name flags
+func namingPolicy() infra.NamingPolicy {
+	return infra.NamingPolicy{
+		Pattern: viper.GetString("naming.pattern"),
+		App:     viper.GetString("naming.app"),
+		Case:    viper.GetString("naming.case"),
+	}
+}
+
+// backendConfig returns the settings of the state backend: those of the config file's backend
+// section, overridden by the --backend-config flags
+func backendConfig() (map[string]string, error) {
+	settings := viper.GetStringMapString("backend.config")
+	for _, setting := range backendSettings {
+		key, value, ok := strings.Cut(setting, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid backend setting: %q (use key=value)", setting)
+		}
+		settings[strings.TrimSpace(key)] = value
+	}
+	return settings, nil
+}
+
+// gitOpsConfig returns the settings of the GitOps manifests: those of the config file's gitops
+// section and the --gitops flags, or the defaults of the tool. The output directory is the path of the
+// output in the repository unless another is set, or it is absolute.
+func gitOpsConfig() crossplane.GitOpsConfig {
+	config := crossplane.DefaultGitOpsConfig(viper.GetString("gitops.type"))
+	settings := map[string]*string{
+		"gitops.repo":                  &config.RepoURL,
+		"gitops.revision":              &config.Revision,
+		"gitops.path":                  &config.Path,
+		"gitops.namespace":             &config.Namespace,
+		"gitops.destination_namespace": &config.DestinationNamespace,
+		"gitops.sync":                  &config.SyncPolicy,
+		"gitops.interval":              &config.Interval,
+	}
+	for key, setting := range settings {
+		if value := viper.GetString(key); value != "" {
+			*setting = value
+		}
+	}
+	if config.Path == "" {
+		config.Path = "."
+		if !filepath.IsAbs(outputDir) {
+			config.Path = filepath.ToSlash(filepath.Clean(outputDir))
+		}
+	}
+	return config
+}
+
+// lockPlatforms returns the platforms of the dependency lock file: none unless the providers
+// are locked, and the default platforms unless others are set
+func lockPlatforms() []string {
+	if !viper.GetBool("lock_providers") {
+		return nil
+	}
+	if platforms := viper.GetStringSlice("lock_platforms"); len(platforms) > 0 {
+		return platforms
+	}
+	return terraform.DefaultLockPlatforms
+}
+
+// workspaces returns the Terraform workspaces of the --workspaces flag or the config file's
+// workspaces list, each with the sizes of its environment overridden by the --workspace-set
+// flags
+func workspaces() ([]terraform.Workspace, error) {
+	var list []terraform.Workspace
+	for _, name := range viper.GetStringSlice("workspaces") {
+		if name = strings.TrimSpace(name); name != "" {
+			list = append(list, terraform.DefaultWorkspace(name))
+		}
+	}
+	if err := terraform.ValidateWorkspaces(list); err != nil {
+		return nil, err
+	}
+	for _, setting := range workspaceSettings {
+		key, value, ok := strings.Cut(setting, "=")
+		name, option, hasOption := strings.Cut(key, ".")
+		if !ok || !hasOption {
+			return nil, fmt.Errorf("invalid workspace setting: %q (use workspace.setting=value)", setting)
+		}
+		found := false
+		for i := range list {
+			if list[i].Name == name {
+				if err := list[i].Set(option, value); err != nil {
+					return nil, err
+				}
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("workspace setting %q is for %s, which is not one of the workspaces", setting, name)
+		}
+	}
+	return list, nil
+}
+
+// checkovSuppressions returns the checkov checks the config file's checkov section
+// suppresses
+func checkovSuppressions() ([]report.CheckovSuppression, error) {
+	var suppressions []report.CheckovSuppression
+	if err := viper.UnmarshalKey("checkov.suppressions", &suppressions); err != nil {
+		return nil, fmt.Errorf("invalid checkov suppressions in config file: %w", err)
+	}
+	if err := report.ValidateCheckovSuppressions(suppressions); err != nil {
+		return nil, err
+	}
+	return suppressions, nil
+}
+
+// lifecycleSettings returns the lifecycle settings of the config file's lifecycle section,
+// keyed by Terraform resource type, with those of the --prevent-destroy and --ignore-changes
+// flags added
+func lifecycleSettings() (map[string]terraform.Lifecycle, error) {
+	settings := make(map[string]terraform.Lifecycle)
+	if err := viper.UnmarshalKey("lifecycle", &settings); err != nil {
+		return nil, fmt.Errorf("invalid lifecycle settings in config file: %w", err)
+	}
+	for _, resourceType := range preventDestroyTypes {
+		lifecycle := settings[resourceType]
+		lifecycle.PreventDestroy = true
+		settings[resourceType] = lifecycle
+	}
+	for _, setting := range ignoreChangeSettings {
+		resourceType, attributes, ok := strings.Cut(setting, "=")
+		if !ok || resourceType == "" || attributes == "" {
+			return nil, fmt.Errorf("invalid ignore-changes setting: %q (use type=attribute, such as aws_eks_node_group=scaling_config[0].desired_size)", setting)
+		}
+		lifecycle := settings[resourceType]
+		for _, attribute := range strings.Split(attributes, ",") {
+			lifecycle.IgnoreChanges = append(lifecycle.IgnoreChanges, strings.TrimSpace(attribute))
+		}
+		settings[resourceType] = lifecycle
+	}
+	if err := terraform.ValidateLifecycle(settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// addonVersions returns the versions of the EKS add-ons overriding the compatibility table:
+// those of the config file's addon_versions section, overridden by the --addon-version flags
+func addonVersions() (map[string]string, error) {
+	versions := viper.GetStringMapString("addon_versions")
+	for _, setting := range addonVersionSettings {
+		name, version, ok := strings.Cut(setting, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid add-on version: %q (use addon=version, such as coredns=v1.11.4-eksbuild.2)", setting)
+		}
+		versions[strings.TrimSpace(name)] = strings.TrimSpace(version)
+	}
+	if err := infra.ValidateEKSAddonVersions(versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// llmConfig builds the LLM backend settings from flags, the config file and the environment.
+// Each backend reads its own config section (llm, ollama or anthropic) so all can be configured at once.
+func llmConfig(backend string) nlp.BackendConfig {
+	section := "llm"
+	switch strings.ToLower(backend) {
+	case nlp.BackendOllama, nlp.BackendAnthropic:
+		section = strings.ToLower(backend)
+	}
+
+	config := nlp.DefaultBackendConfig(backend)
+	config.Synonyms = synonyms
+	config.Model = viper.GetString(section + ".model")
+	config.Endpoint = viper.GetString(section + ".endpoint")
+	if timeout := viper.GetDuration(section + ".timeout"); timeout > 0 {
+		config.Timeout = timeout
+	}
+
+	// Flags win over the config file
+	if llmModel != "" {
+		config.Model = llmModel
+	}
+	if llmEndpoint != "" {
+		config.Endpoint = llmEndpoint
+	}
+	if llmTimeout > 0 {
+		config.Timeout = llmTimeout
+	}
+	config.Retry = retryPolicy()
+	return config
+}
+
+// retryPolicy builds the retry policy of LLM requests and plugins from the retry section of
+// the config file and --retry-attempts, over the default delays
+func retryPolicy() retry.Policy {
+	policy := retry.DefaultPolicy()
+	policy.MaxAttempts = viper.GetInt("retry.max_attempts")
+	if viper.IsSet("retry.initial_delay") {
+		policy.InitialDelay = viper.GetDuration("retry.initial_delay")
+	}
+	if viper.IsSet("retry.max_delay") {
+		policy.MaxDelay = viper.GetDuration("retry.max_delay")
+	}
+	if viper.IsSet("retry.multiplier") {
+		policy.Multiplier = viper.GetFloat64("retry.multiplier")
+	}
+	if viper.IsSet("retry.jitter") {
+		policy.Jitter = viper.GetFloat64("retry.jitter")
+	}
+	return policy
+}
+
+// clarifier returns the clarifier used for ambiguous descriptions, or nil to apply defaults.
+// Questions are only asked when stdin is a terminal, so scripts and CI keep working unchanged.
+func clarifier(in io.Reader) nlp.Clarifier {
+	if viper.GetBool("non_interactive") || !isInteractiveTerminal() {
+		return nil
+	}
+	return newPromptClarifier(in, os.Stderr)
+}
+
+// reviewer returns the reviewer shown the parsed entities before generation, or nil when
+// --review is not set. The review reads stdin even when it is not a terminal, so the edits
+// can be piped in.
+func reviewer(in io.Reader) nlp.Reviewer {
+	if !review {
+		return nil
+	}
+	return newPromptReviewer(in, os.Stderr)
+}
+
+// isValidNLPBackend checks if the NLP backend is supported
+func isValidNLPBackend(backend string) bool {
+	for _, name := range nlp.SupportedBackends() {
+		if strings.ToLower(backend) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// knownRegions are the AWS regions accepted without a format check, and offered by shell
+// completion
+var knownRegions = []string{
+	"us-east-1", "us-east-2", "us-west-1", "us-west-2",
+	"eu-west-1", "eu-west-2", "eu-west-3", "eu-central-1",
+	"ap-northeast-1", "ap-northeast-2", "ap-southeast-1", "ap-southeast-2",
+	"sa-east-1", "ca-central-1", "ap-south-1", "ap-east-1",
+	"eu-north-1", "eu-south-1", "af-south-1", "me-south-1",
+}
+
 // isValidRegionFormat checks if the AWS region format is valid
 func isValidRegionFormat(region string) bool {
 	// Basic format checking for AWS regions like us-east-1, us-west-2, etc.
-	validRegions := []string{
-		"us-east-1", "us-east-2", "us-west-1", "us-west-2",
-		"eu-west-1", "eu-west-2", "eu-west-3", "eu-central-1",
-		"ap-northeast-1", "ap-northeast-2", "ap-southeast-1", "ap-southeast-2",
-		"sa-east-1", "ca-central-1", "ap-south-1", "ap-east-1",
-		"eu-north-1", "eu-south-1", "af-south-1", "me-south-1",
-	}
-
-	for _, validRegion := range validRegions {
+	for _, validRegion := range knownRegions {
 		if validRegion == region {
 			return true
 		}
@@ -227,13 +1161,205 @@ func processDescription(description, region string) (*models.InfrastructureModel
 }
 
 func init() {
-	// Input options
-	generateCmd.Flags().StringVarP(&inputFile, "file", "f", "", "Input file containing infrastructure description")
+	addDescriptionFlags(generateCmd)
+	addGenerationFlags(generateCmd)
 	
 	// Output options
 	generateCmd.Flags().StringVarP(&outputFile, "output-file", "", "", "Output filename (default: based on input file or 'main.tf'/'resources.yaml')")
+	generateCmd.Flags().BoolVar(&generateStdout, "stdout", false, "Write the generated files to stdout as one document instead of to the output directory")
+	generateCmd.Flags().BoolVar(&generateTar, "tar", false, "With --stdout, write the generated files as a tar stream")
+	generateCmd.Flags().BoolVar(&resume, "resume", false, "Checkpoint the parsed and built models under the cache directory, and resume from them when the same input is generated again")
+	generateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the files that would be created, modified or left unchanged, with diffs, without writing anything")
+	generateCmd.Flags().StringVar(&progressFormat, "progress", "text", "Progress output: text, or json to write JSON lines events to stdout")
+	generateCmd.Flags().BoolVar(&keepPartial, "keep-partial", false, "Write directly into the output directory, leaving the files of a failed or interrupted run in place")
+	generateCmd.Flags().BoolVar(&showMetrics, "metrics", false, "Print a table of the time each stage took and the files it wrote after generation")
+	generateCmd.Flags().StringVar(&metricsFile, "metrics-file", "", "Write the time each stage took and the files it wrote to a JSON report")
+	generateCmd.Flags().BoolVar(&scan, "scan", false, "Scan the generated files for security misconfigurations with trivy config, or tfsec, and print the HIGH and CRITICAL findings")
+	generateCmd.Flags().StringVar(&scanFailOn, "scan-fail-on", "", "Fail generation, leaving the output directory as it was, on scan findings of this severity or above: low, medium, high or critical (implies --scan)")
+	generateCmd.Flags().BoolVar(&checkov, "checkov", false, "Check the generated Terraform and manifests with checkov and print the failed checks, leaving out those suppressed in the checkov section of the config file")
+	generateCmd.Flags().BoolVar(&lint, "lint", false, "Lint the generated Terraform with tflint, using the .tflint.hcl of the output directory or a default ruleset, and print the findings")
+	generateCmd.Flags().BoolVar(&lintIAM, "lint-iam", false, "Lint the IAM policies of the generated files for wildcards, unconditioned web identity trust and broad managed policies, and print the findings with scoped alternatives")
+	generateCmd.Flags().BoolVar(&estimate, "estimate", false, "Estimate the monthly cost of the generated Terraform, with infracost when it is in PATH or the built-in prices, and print it per module")
+	generateCmd.Flags().StringVar(&estimator, "estimator", "", "Cost estimator of --estimate: infracost or builtin (implies --estimate)")
+	generateCmd.Flags().BoolVar(&checkQuotas, "check-quotas", false, "Before generating, warn when the infrastructure would exceed the AWS service quotas of its regions, such as VPCs per region or NAT gateways per AZ; needs AWS credentials")
 	
 	// Bind viper for persistent configuration
-	viper.BindPFlag("input_file", generateCmd.Flags().Lookup("file"))
 	viper.BindPFlag("output_file", generateCmd.Flags().Lookup("output-file"))
+}
+
+// addDescriptionFlags adds the flags of the commands that generate from a description or spec
+func addDescriptionFlags(cmd *cobra.Command) {
+	// Input options
+	cmd.Flags().StringArrayVarP(&inputFiles, "file", "f", nil, "Input file containing infrastructure description; repeat it to merge the partial descriptions of several files, such as of the network and the cluster, into one model")
+	cmd.Flags().StringVar(&specFile, "spec", "", "YAML or JSON spec with the infrastructure entities or model, used instead of a description (see 'iacgen schema')")
+	cmd.Flags().StringVar(&modelFile, "from-model", "", "Model saved with --save-model, generated as it is instead of parsing a description")
+	cmd.Flags().StringVar(&saveModel, "save-model", "", "Save the infrastructure model to this JSON file before generating, to review and version it or generate it again with --from-model")
+	
+	// Environment options
+	cmd.Flags().StringVar(&environment, "environment", "", "Environment to generate, such as staging; overrides the environment the description names, suffixes resource names and writes terraform.tfvars.<environment>")
+	
+	// Tagging options
+	cmd.Flags().StringArrayVar(&tagSettings, "tag", nil, "Tag applied to every resource as key=value, such as Owner=platform, overriding the tags of the config file and the description (repeatable)")
+	cmd.Flags().StringSlice("require-tag", nil, "Tags every resource must have, such as CostCenter,Owner; generation fails when the config file, --tag and the description leave one unset")
+	
+	// Naming options
+	cmd.Flags().String("name-pattern", "", "Pattern of the resource names, with the placeholders {env}, {app}, {region} and {resource}, such as {env}-{app}-{resource} (default the built-in names, such as main-vpc)")
+	cmd.Flags().String("name-case", "", "Case of the values filling the name pattern: kebab (order-service) or lower (orderservice) (default kebab)")
+	cmd.Flags().String("app-name", "", "Application name filling the {app} placeholder of the name pattern")
+	
+	// Validation options
+	cmd.Flags().StringArray("policy", nil, "Rego policy file, or directory of them, the model must satisfy before any file is generated, such as no public EKS endpoints in prod (repeatable)")
+	cmd.Flags().Bool("skip-model-validation", false, "Generate the model without checking the relationships between its resources, such as overlapping subnets or an EKS cluster in a single availability zone")
+	
+	// NLP options
+	addNLPFlags(cmd)
+	
+	// Interaction options
+	cmd.Flags().BoolVar(&nonInteractive, "non-interactive", false, "Apply defaults instead of asking follow-up questions when the description is ambiguous")
+	cmd.Flags().BoolVar(&review, "review", false, "Show the parsed values and planned resources, and let you edit, accept or abort them before generating")
+}
+
+// addNLPFlags adds the flags that select and configure the entity extraction backend
+func addNLPFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&nlpBackend, "nlp", "regex", "Entity extraction backend (regex, llm, ollama or anthropic)")
+	cmd.Flags().StringVar(&llmModel, "llm-model", "", "Model used by the LLM backend (default gpt-4o-mini for llm, llama3.1 for ollama, claude-sonnet-4-5 for anthropic)")
+	cmd.Flags().StringVar(&llmEndpoint, "llm-endpoint", "", "API endpoint of the LLM backend (default https://api.openai.com/v1 for llm, http://localhost:11434 for ollama, https://api.anthropic.com for anthropic)")
+	cmd.Flags().DurationVar(&llmTimeout, "llm-timeout", 0, "Timeout for each LLM request (default 60s)")
+	cmd.Flags().StringVar(&synonymsFile, "synonyms", "", "YAML file mapping phrases to resource types or to wording the parser understands")
+	cmd.Flags().Int("retry-attempts", 1, "Attempts at most for LLM requests and plugins failing transiently, such as on network errors (1 does not retry)")
+}
+
+// addGenerationFlags adds the flags of the commands that generate files from the model
+func addGenerationFlags(cmd *cobra.Command) {
+	// Disaster recovery options
+	cmd.Flags().StringVar(&drRegion, "dr-region", "", "Secondary AWS region for a disaster-recovery variant of the stack (written to <output-dir>/dr)")
+	
+	// Report options
+	cmd.Flags().BoolVar(&complianceReport, "compliance-report", false, "Write a CIS AWS / SOC 2 compliance matrix (compliance-report.md) to the output directory")
+	
+	// State backend options
+	cmd.Flags().String("backend", "local", "State backend of the Terraform configuration (local or s3)")
+	cmd.Flags().StringArrayVar(&backendSettings, "backend-config", nil, "Setting of the state backend as key=value, such as bucket=my-state (repeatable)")
+	cmd.Flags().Bool("backend-bootstrap", false, "Write the configuration creating the S3 bucket and DynamoDB table of the s3 backend to <output-dir>/backend-bootstrap")
+	
+	// Module options
+	cmd.Flags().String("module-source", "local", "Source of the Terraform VPC and EKS modules: local generates them, registry uses terraform-aws-modules")
+	cmd.Flags().String("module-layout", "modules", "Layout of the Terraform modules: modules (vpc and eks), by-type (network, compute and data) or flat (no modules)")
+	
+	// Crossplane options
+	cmd.Flags().String("crossplane-provider", "contrib", "Provider the Crossplane managed resources are written for: contrib (crossplane-contrib provider-aws) or upbound (Upbound AWS provider family)")
+	cmd.Flags().String("crossplane-credentials", "secret", "Source of the Crossplane providers' AWS credentials: secret (the aws-credentials Secret) or irsa (an IAM role for their service account, on EKS)")
+	cmd.Flags().String("crossplane-role-arn", "", "IAM role the Crossplane providers assume with --crossplane-credentials irsa (default a placeholder)")
+	cmd.Flags().Bool("compositions", false, "Also write Crossplane Compositions of the network and the EKS cluster to <output-dir>/compositions, with example claims in <output-dir>/claims")
+	cmd.Flags().String("team", "", "Team owning the Crossplane claims of --compositions: writes its namespace, service account and RBAC to <output-dir>/team and places the claims in the namespace")
+	cmd.Flags().String("connection-secrets", "none", "Connection secrets of the Crossplane EKS clusters and RDS instances: none, resources (a secret per resource) or composite (also one per claim, aggregated by the Compositions)")
+	cmd.Flags().String("connection-secret-namespace", "", "Namespace of the connection secrets (default crossplane-system)")
+	cmd.Flags().StringVar(&crossplaneImportMapFile, "crossplane-import-map", "", "YAML file mapping the kinds and names of Crossplane managed resources to the IDs of existing AWS resources, adopted through their external names")
+	cmd.Flags().String("deletion-policy", "orphan", "Deletion policy of the Crossplane managed resources: orphan (keep the AWS resources when a manifest is deleted) or delete")
+	cmd.Flags().String("management-policy", "full", "Management policy of the Crossplane managed resources: full or observe (only observe existing AWS resources; needs Crossplane's management policies feature)")
+	
+	// GitOps options
+	cmd.Flags().String("gitops", "", "Write manifests deploying the Crossplane output with a GitOps tool: argocd or flux")
+	cmd.Flags().String("gitops-repo", "", "Git repository the output is pushed to, which the GitOps manifests deploy from (default a placeholder)")
+	cmd.Flags().String("gitops-revision", "", "Branch, tag or commit of the repository to deploy (default HEAD for Argo CD, main for Flux)")
+	cmd.Flags().String("gitops-path", "", "Path of the output directory in the repository (default the output directory)")
+	cmd.Flags().String("gitops-namespace", "", "Namespace of the GitOps tool (default argocd or flux-system)")
+	cmd.Flags().String("gitops-destination-namespace", "", "Namespace of the deployed namespaced resources that name none, with Argo CD (default crossplane-system)")
+	cmd.Flags().String("gitops-sync", "", "Sync policy: manual, auto (sync changes and revert drift) or auto-prune (also delete removed resources) (default manual for Argo CD, auto-prune for Flux)")
+	cmd.Flags().String("gitops-interval", "", "How often Flux reconciles the output (default 10m)")
+	
+	// Version options
+	cmd.Flags().String("terraform-version", "", "Minimum Terraform version of the generated configuration, such as 1.5.7 (default 1.0.0)")
+	cmd.Flags().String("aws-provider-version", "", "Version constraint of the AWS provider, such as \"~> 5.40\" (default \"~> 5.0\")")
+	
+	// Formatting options
+	cmd.Flags().Bool("format-output", true, "Format the generated Terraform files as terraform fmt does")
+	
+	// Dependency lock options
+	cmd.Flags().Bool("lock-providers", false, "Write .terraform.lock.hcl with terraform providers lock (requires terraform in PATH)")
+	cmd.Flags().StringSlice("lock-platforms", nil, "Platforms the lock file has provider checksums for (default linux_amd64,linux_arm64,darwin_amd64,darwin_arm64,windows_amd64)")
+	
+	// Lifecycle options
+	cmd.Flags().StringSliceVar(&preventDestroyTypes, "prevent-destroy", nil, "Terraform resource types whose resources Terraform refuses to destroy, such as aws_eks_cluster,aws_db_instance")
+	cmd.Flags().StringArrayVar(&ignoreChangeSettings, "ignore-changes", nil, "Attributes of a Terraform resource type whose changes are ignored, as type=attribute[,attribute], such as aws_eks_node_group=scaling_config[0].desired_size (repeatable)")
+	
+	// Add-on options
+	cmd.Flags().StringArrayVar(&addonVersionSettings, "addon-version", nil, "Version of an EKS add-on as addon=version, such as coredns=v1.11.4-eksbuild.2, instead of the one compatible with the cluster's Kubernetes version (repeatable)")
+	
+	// Import options
+	cmd.Flags().StringVar(&importMapFile, "import-map", "", "YAML file mapping resource addresses to the IDs of existing AWS resources, adopted with import blocks in imports.tf")
+	
+	// Workspace options
+	cmd.Flags().StringSlice("workspaces", nil, "Terraform workspaces to write a variables file for under <output-dir>/env, with a Makefile selecting them, such as dev,staging,prod")
+	cmd.Flags().StringArrayVar(&workspaceSettings, "workspace-set", nil, "Size of a workspace as workspace.setting=value, such as prod.nat_gateways=3; settings are nat_gateways, node_count and node_instance_type (repeatable)")
+	
+	// Concurrency options
+	cmd.Flags().Int("workers", 0, "Number of environments and stages generated at once (default one per CPU)")
+}
+
+// bindDescriptionFlags binds the running command's flags to the settings the config file can
+// also set. Each command has its own flags, so they are bound when it runs.
+func bindDescriptionFlags(cmd *cobra.Command) {
+	viper.BindPFlag("input_file", cmd.Flags().Lookup("file"))
+	viper.BindPFlag("non_interactive", cmd.Flags().Lookup("non-interactive"))
+	viper.BindPFlag("required_tags", cmd.Flags().Lookup("require-tag"))
+	viper.BindPFlag("naming.pattern", cmd.Flags().Lookup("name-pattern"))
+	viper.BindPFlag("naming.case", cmd.Flags().Lookup("name-case"))
+	viper.BindPFlag("naming.app", cmd.Flags().Lookup("app-name"))
+	viper.BindPFlag("skip_model_validation", cmd.Flags().Lookup("skip-model-validation"))
+	viper.BindPFlag("policies", cmd.Flags().Lookup("policy"))
+	if flag := cmd.Flags().Lookup("workers"); flag != nil {
+		viper.BindPFlag("workers", flag)
+	}
+	if flag := cmd.Flags().Lookup("module-source"); flag != nil {
+		viper.BindPFlag("module_source", flag)
+		viper.BindPFlag("module_layout", cmd.Flags().Lookup("module-layout"))
+	}
+	if flag := cmd.Flags().Lookup("crossplane-provider"); flag != nil {
+		viper.BindPFlag("crossplane_provider", flag)
+		viper.BindPFlag("crossplane_credentials", cmd.Flags().Lookup("crossplane-credentials"))
+		viper.BindPFlag("crossplane_role_arn", cmd.Flags().Lookup("crossplane-role-arn"))
+		viper.BindPFlag("compositions", cmd.Flags().Lookup("compositions"))
+		viper.BindPFlag("team", cmd.Flags().Lookup("team"))
+		viper.BindPFlag("connection_secrets", cmd.Flags().Lookup("connection-secrets"))
+		viper.BindPFlag("connection_secret_namespace", cmd.Flags().Lookup("connection-secret-namespace"))
+		viper.BindPFlag("deletion_policy", cmd.Flags().Lookup("deletion-policy"))
+		viper.BindPFlag("management_policy", cmd.Flags().Lookup("management-policy"))
+	}
+	if flag := cmd.Flags().Lookup("gitops"); flag != nil {
+		viper.BindPFlag("gitops.type", flag)
+		viper.BindPFlag("gitops.repo", cmd.Flags().Lookup("gitops-repo"))
+		viper.BindPFlag("gitops.revision", cmd.Flags().Lookup("gitops-revision"))
+		viper.BindPFlag("gitops.path", cmd.Flags().Lookup("gitops-path"))
+		viper.BindPFlag("gitops.namespace", cmd.Flags().Lookup("gitops-namespace"))
+		viper.BindPFlag("gitops.destination_namespace", cmd.Flags().Lookup("gitops-destination-namespace"))
+		viper.BindPFlag("gitops.sync", cmd.Flags().Lookup("gitops-sync"))
+		viper.BindPFlag("gitops.interval", cmd.Flags().Lookup("gitops-interval"))
+	}
+	if flag := cmd.Flags().Lookup("terraform-version"); flag != nil {
+		viper.BindPFlag("terraform_version", flag)
+		viper.BindPFlag("aws_provider_version", cmd.Flags().Lookup("aws-provider-version"))
+	}
+	if flag := cmd.Flags().Lookup("format-output"); flag != nil {
+		viper.BindPFlag("format_output", flag)
+	}
+	if flag := cmd.Flags().Lookup("lock-providers"); flag != nil {
+		viper.BindPFlag("lock_providers", flag)
+		viper.BindPFlag("lock_platforms", cmd.Flags().Lookup("lock-platforms"))
+	}
+	if flag := cmd.Flags().Lookup("workspaces"); flag != nil {
+		viper.BindPFlag("workspaces", flag)
+	}
+	if flag := cmd.Flags().Lookup("backend"); flag != nil {
+		viper.BindPFlag("backend.type", flag)
+		viper.BindPFlag("backend.bootstrap", cmd.Flags().Lookup("backend-bootstrap"))
+	}
+	bindNLPFlags(cmd)
+}
+
+// bindNLPFlags binds the running command's NLP flags to the settings the config file can also set
+func bindNLPFlags(cmd *cobra.Command) {
+	viper.BindPFlag("nlp_backend", cmd.Flags().Lookup("nlp"))
+	viper.BindPFlag("synonyms_file", cmd.Flags().Lookup("synonyms"))
+	viper.BindPFlag("retry.max_attempts", cmd.Flags().Lookup("retry-attempts"))
 }
\ No newline at end of file
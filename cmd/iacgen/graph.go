@@ -0,0 +1,60 @@
+package iacgen
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+// Graph command flags
+var graphFormat string
+
+var graphCmd = &cobra.Command{
+	Use:   "graph [description]",
+	Short: "Print the dependency graph of the infrastructure model of a description",
+	Long: `Parse a description, or load a spec, and print the dependencies between the resources
+of the infrastructure model it produces as a graph, without generating anything.
+
+Each resource is a node labeled with its type and name, with an edge to each resource it
+depends on, either explicitly or by referring to it in a property, such as the vpc_id of a
+subnet. A dependency on a resource the model does not have is drawn as a dashed node.
+
+The graph is printed in the Graphviz DOT language, or as a Mermaid flowchart, which GitHub
+renders in Markdown. It takes the same description and parsing flags as generate.`,
+	Example: `  # Render the graph of a description with Graphviz
+  iacgen graph "Create a VPC with 2 public subnets and an EKS cluster with 3 nodes" | dot -Tsvg > infra.svg
+
+  # Paste the graph of a spec into a pull request description
+  iacgen graph --spec infra.yaml --format mermaid`,
+	Args: cobra.MaximumNArgs(1),
+	// Execute prints errors once
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := models.NewGraphExporter(models.GraphFormat(graphFormat)); err != nil {
+			return err
+		}
+		return validateDescriptionFlags(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		graph, err := pipeline.GraphDescription(descriptionParams(args), models.GraphFormat(graphFormat))
+		if errors.Is(err, nlp.ErrReviewAborted) {
+			return fmt.Errorf("aborted during review; nothing was graphed")
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(cmd.OutOrStdout(), graph)
+		return nil
+	},
+}
+
+func init() {
+	addDescriptionFlags(graphCmd)
+
+	graphCmd.Flags().StringVar(&graphFormat, "format", string(models.GraphDOT), "Format of the graph (dot or mermaid)")
+}
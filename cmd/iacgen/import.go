@@ -0,0 +1,73 @@
+package iacgen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/riptano/iac_generator_cli/internal/tfstate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Import command flags
+	importState     string
+	importSaveModel string
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import a Terraform state file into an infrastructure model",
+	Long: `Convert the resources of a Terraform state file into an infrastructure model, saved as
+JSON like the models of --save-model, without generating anything.
+
+Generate the saved model with --from-model to convert infrastructure managed with Terraform
+to Crossplane, or to generate its Terraform again with a cleaner layout. The resources are
+named after their name in AWS, their Name tag or their address in the state, and refer to
+each other by these names. Tags every resource has become the tags of the model.
+
+The state must be in the format Terraform 0.12 and later write, such as the output of
+terraform state pull, which --state - reads from stdin. Data sources are left out, and
+resources of unsupported types are listed as skipped. Supported types:
+  ` + strings.Join(tfstate.SupportedTypes(), "\n  "),
+	Example: `  # Import a state and generate it as Crossplane manifests
+  iacgen import --state terraform.tfstate --save-model model.json
+  iacgen generate --from-model model.json --output crossplane --output-dir ./crossplane
+
+  # Import the state of a remote backend
+  terraform state pull | iacgen import --state - --save-model model.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if importState == "" {
+			return fmt.Errorf("specify the state file to import with --state")
+		}
+
+		result, err := tfstate.LoadFile(importState)
+		if err != nil {
+			return err
+		}
+		// A state whose resources name no region gets the configured one
+		if result.Model.Region == "" {
+			result.Model.Region = awsRegion
+		}
+		if err := pipeline.SaveModelFile(importSaveModel, result.Model); err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "Imported %d resources into %s\n", len(result.Model.Resources), importSaveModel)
+		if len(result.Skipped) > 0 {
+			fmt.Fprintf(out, "Skipped %d resources of unsupported types:\n", len(result.Skipped))
+			for _, address := range result.Skipped {
+				fmt.Fprintf(out, "  %s\n", address)
+			}
+		}
+		fmt.Fprintf(out, "\nTo generate the model, run:\n  iacgen generate --from-model %s --output crossplane\n", importSaveModel)
+		return nil
+	},
+}
+
+func init() {
+	importCmd.Flags().StringVar(&importState, "state", "", "Terraform state file to import, or - to read it from stdin")
+	importCmd.Flags().StringVar(&importSaveModel, "save-model", "model.json", "JSON file the imported model is saved to, for generate --from-model")
+}
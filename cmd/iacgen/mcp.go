@@ -0,0 +1,76 @@
+package iacgen
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/riptano/iac_generator_cli/internal/config"
+	"github.com/riptano/iac_generator_cli/internal/server"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve the generator to AI assistants over the Model Context Protocol",
+	Long: `Run a Model Context Protocol (MCP) server over stdio, so coding assistants can drive
+the generator directly. It provides these tools:
+
+  parse_description    Parse a description and return the model and assumed defaults
+  generate_terraform   Generate Terraform for a description
+  generate_crossplane  Generate Crossplane manifests for a description
+  validate_output      Validate a directory of generated files
+
+The generate tools write the files to output_dir when it is given, and return them
+otherwise. Descriptions never trigger follow-up questions: ambiguous ones get defaults,
+and parse_description reports them. The --region, --output and --use-templates flags
+and the config file set the defaults of tool calls; the NLP backend and the config
+file's tags apply to every call.
+
+The assistant starts the server itself; add it to the assistant's MCP configuration
+with iacgen as the command and mcp as its argument. Logs are written to stderr, as
+stdout carries the protocol.`,
+	Example: `  # Serve over stdio, as an assistant's MCP configuration runs it
+  iacgen mcp
+
+  # Parse descriptions with an LLM (requires OPENAI_API_KEY)
+  iacgen mcp --nlp llm`,
+	Args: cobra.NoArgs,
+	// Execute prints errors once
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		bindNLPFlags(cmd)
+		if !isValidOutputFormat(toolFormat) {
+			return fmt.Errorf("invalid output format: %s (supported formats: terraform, crossplane)", toolFormat)
+		}
+		return validateParsingSettings()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		srv := server.NewServer(server.Options{
+			Region:       awsRegion,
+			OutputFormat: toolFormat,
+			UseTemplates: useTemplates,
+			Tags:         config.AppConfig.Tags,
+			RequiredTags: viper.GetStringSlice("required_tags"),
+			Policies:     viper.GetStringSlice("policies"),
+			NLPBackend:   nlpBackend,
+			LLMConfig:    llmConfig(nlpBackend),
+		})
+
+		// Stdout carries the protocol, so anything else printed goes to stderr
+		protocolOut := os.Stdout
+		os.Stdout = os.Stderr
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return srv.ServeMCP(ctx, os.Stdin, protocolOut)
+	},
+}
+
+func init() {
+	addNLPFlags(mcpCmd)
+}
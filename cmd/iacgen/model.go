@@ -0,0 +1,106 @@
+package iacgen
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	// Model diff command flags
+	modelDiffFormat   string
+	modelDiffNoColor  bool
+	modelDiffExitCode bool
+)
+
+var modelCmd = &cobra.Command{
+	Use:   "model",
+	Short: "Inspect the infrastructure models of descriptions",
+	Long: `Inspect the infrastructure models that descriptions and specs produce, without
+generating anything.`,
+}
+
+var modelDiffCmd = &cobra.Command{
+	Use:   "diff <before> <after>",
+	Short: "Show how the infrastructure models of two descriptions or specs differ",
+	Long: `Build the infrastructure models of two descriptions or specs and show the resources
+and properties that were added, removed or changed from the first to the second, without
+generating anything.
+
+Use it to preview the impact on the infrastructure of editing a description, before
+diffing the generated files. Each input is a description, a file holding one, or a spec
+file, recognized by its .json, .yaml or .yml extension, such as a model exported as JSON.
+Resources are matched by type and name. The values of secrets are not printed.
+
+The diff is colored when stdout is a terminal, unless --no-color or NO_COLOR is set.
+Progress is written to stderr.`,
+	Example: `  # Preview the impact of editing a description
+  iacgen model diff infra.txt infra-new.txt --non-interactive
+
+  # Compare a description with a spec
+  iacgen model diff "Create a VPC with an EKS cluster with 3 nodes" model.json --non-interactive
+
+  # Fail CI when the models differ
+  iacgen model diff infra.txt infra-new.txt --non-interactive --exit-code --format json`,
+	Args: cobra.ExactArgs(2),
+	// The diff explains the exit status, and Execute prints errors once
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if modelDiffFormat != "text" && modelDiffFormat != "json" {
+			return fmt.Errorf("invalid format: %s (supported formats: text, json)", modelDiffFormat)
+		}
+		viper.BindPFlag("non_interactive", cmd.Flags().Lookup("non-interactive"))
+		bindNLPFlags(cmd)
+		if environment != "" && !infra.ValidEnvironment(environment) {
+			return fmt.Errorf("invalid environment: %s (use up to 16 lower-case letters, digits and hyphens, starting with a letter)", environment)
+		}
+		return validateParsingSettings()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		params := descriptionParams(nil)
+		params.ProgressWriter = os.Stderr
+
+		diff, err := pipeline.DiffModelInputs(params, args[0], args[1])
+		if errors.Is(err, nlp.ErrReviewAborted) {
+			return fmt.Errorf("aborted during review; nothing was compared")
+		}
+		if err != nil {
+			return err
+		}
+
+		if modelDiffFormat == "json" {
+			content, err := diff.JSON()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), content)
+		} else {
+			color := !modelDiffNoColor && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+			fmt.Fprint(cmd.OutOrStdout(), diff.Text(color))
+		}
+
+		if modelDiffExitCode && diff.HasChanges() {
+			os.Exit(1)
+		}
+		return nil
+	},
+}
+
+func init() {
+	modelCmd.AddCommand(modelDiffCmd)
+
+	modelDiffCmd.Flags().StringVar(&environment, "environment", "", "Environment to compare when a description covers several, such as staging")
+	addNLPFlags(modelDiffCmd)
+	modelDiffCmd.Flags().BoolVar(&nonInteractive, "non-interactive", false, "Apply defaults instead of asking follow-up questions when a description is ambiguous")
+
+	modelDiffCmd.Flags().StringVar(&modelDiffFormat, "format", "text", "Output format of the diff (text or json)")
+	modelDiffCmd.Flags().BoolVar(&modelDiffNoColor, "no-color", false, "Print the diff without colors")
+	modelDiffCmd.Flags().BoolVar(&modelDiffExitCode, "exit-code", false, "Exit with status 1 when the models differ")
+}
@@ -0,0 +1,41 @@
+package iacgen
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "List the plugins discovered in the plugin directory",
+	Long: `List the plugins discovered in the plugin directory, set with --plugin-dir or
+plugin_dir in the config file, or ~/.iacgen/plugins by default.
+
+A plugin is an executable that speaks iacgen's JSON plugin protocol. Generator plugins
+add output formats, selected with --output like the built-in ones, and stage plugins
+run on the infrastructure model before it is generated, for example to check it
+against a policy.`,
+	Example: `  # List the plugins of a project
+  iacgen plugins --plugin-dir ./plugins`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := cmd.OutOrStdout()
+		if len(plugins) == 0 {
+			fmt.Fprintln(out, "No plugins found")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tKIND\tFORMAT\tDESCRIPTION\tPATH")
+		for _, p := range plugins {
+			format := p.Format
+			if format == "" {
+				format = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.Name, p.Kind, format, p.Description, p.Path)
+		}
+		return w.Flush()
+	},
+}
@@ -0,0 +1,63 @@
+package iacgen
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+)
+
+// promptReviewer shows the parsed entities on a terminal and reads edits, accept or abort
+type promptReviewer struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// newPromptReviewer creates a reviewer reading commands from in and writing the review to out
+func newPromptReviewer(in io.Reader, out io.Writer) *promptReviewer {
+	return &promptReviewer{
+		in:  bufio.NewReader(in),
+		out: out,
+	}
+}
+
+// Review implements nlp.Reviewer. An empty line or the end of input accepts the review;
+// "field=value" edits a value and "abort" stops without generating anything.
+func (r *promptReviewer) Review(ctx context.Context, review nlp.Review) (nlp.ReviewDecision, error) {
+	fmt.Fprintln(r.out)
+	fmt.Fprint(r.out, review.Text())
+	for {
+		if ctx.Err() != nil {
+			return nlp.ReviewDecision{}, ctx.Err()
+		}
+
+		fmt.Fprint(r.out, "\nPress Enter to generate, type field=value to edit, or abort: ")
+		line, err := r.in.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nlp.ReviewDecision{}, fmt.Errorf("failed to read review command: %w", err)
+		}
+
+		command := strings.TrimSpace(line)
+		if path, value, ok := strings.Cut(command, "="); ok {
+			return nlp.ReviewDecision{Action: nlp.ReviewEdit, Path: strings.TrimSpace(path), Value: value}, nil
+		}
+		switch strings.ToLower(command) {
+		case "":
+			if err == io.EOF {
+				fmt.Fprintln(r.out)
+			}
+			return nlp.ReviewDecision{Action: nlp.ReviewAccept}, nil
+		case "y", "yes", "accept":
+			return nlp.ReviewDecision{Action: nlp.ReviewAccept}, nil
+		case "n", "no", "q", "quit", "abort":
+			return nlp.ReviewDecision{Action: nlp.ReviewAbort}, nil
+		}
+		if err == io.EOF {
+			return nlp.ReviewDecision{}, fmt.Errorf("invalid review command %q", command)
+		}
+		fmt.Fprintln(r.out, "Please enter nothing to generate, field=value such as eks.node_count=3, or abort")
+	}
+}
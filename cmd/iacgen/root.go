@@ -2,10 +2,15 @@ package iacgen
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/riptano/iac_generator_cli/internal/config"
+	"github.com/riptano/iac_generator_cli/internal/hooks"
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/riptano/iac_generator_cli/internal/plugin"
+	"github.com/riptano/iac_generator_cli/internal/template"
 	"github.com/riptano/iac_generator_cli/internal/utils"
 	"github.com/riptano/iac_generator_cli/internal/version"
 	"github.com/spf13/cobra"
@@ -14,12 +19,24 @@ import (
 
 var (
 	// Global flags
-	awsRegion      string
-	debugMode      bool
-	outputDir      string
-	toolFormat     string
-	useTemplates   bool
-	versionFlag    bool
+	awsRegion        string
+	debugMode        bool
+	outputDir        string
+	toolFormat       string
+	useTemplates     bool
+	versionFlag      bool
+	pluginDir        string
+	templateDir      string
+	noTemplateCache  bool
+	templateCacheDir string
+	valueFiles       []string
+	valueSettings    []string
+
+	// plugins are the plugins discovered in the plugin directory
+	plugins []*plugin.Plugin
+
+	// configuredHooks are the commands the config files run before and after generation
+	configuredHooks hooks.Hooks
 )
 
 var rootCmd = &cobra.Command{
@@ -63,24 +80,124 @@ the text to identify resources, their configurations, and relationships.`,
 			logLevel = "debug"
 		}
 		viper.Set("log_level", logLevel)
+		if debugMode {
+			// The config was read before the flags were parsed
+			config.AppConfig.LogLevel = logLevel
+		}
+		
+		// Commands whose output is meant for other programs log to stderr
+		if writesToStdout(cmd) {
+			utils.SetLogOutput(os.Stderr)
+		}
+		
+		// The wizard's questions and previews would be lost among the pipeline's logs
+		if cmd == wizardCmd && !debugMode {
+			utils.SetLogOutput(io.Discard)
+		}
 		
 		// Get logger
 		logger := utils.GetLogger()
 		logger.Debug("Debug mode enabled")
+		
+		// The config file and profile set the defaults of the global flags, and flags set on
+		// the command line override them
+		toolFormat = viper.GetString("default_type")
+		awsRegion = viper.GetString("aws_region")
+		outputDir = viper.GetString("output_dir")
+		useTemplates = viper.GetBool("use_templates")
+		
+		// Templates in the template directory replace the built-in ones of the same name
+		if dir := viper.GetString("template_dir"); dir != "" {
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				fmt.Fprintf(os.Stderr, "Error: template directory does not exist: %s\n", dir)
+				os.Exit(1)
+			}
+			template.GetDefaultManager().SetOverrideDir(dir)
+			logger.Debugw("Using template overrides", "dir", dir)
+		}
+		if noTemplateCache {
+			template.GetDefaultManager().SetCacheEnabled(false)
+		}
+		template.GetDefaultManager().SetDiskCacheDir(viper.GetString("template_cache_dir"))
+
+		// Templates read the values of the values files and --set as .Values
+		values, err := template.LoadValues(valueFiles, valueSettings)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		template.GetDefaultManager().SetValues(values)
+
+		// The template mappings of the config files render more resource types with templates
+		if err := registerTemplateMappings(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		
+		// The hooks of the config files run before and after generation
+		if configuredHooks, err = hooks.Parse(viper.Get("hooks")); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid hooks: %v\n", err)
+			os.Exit(1)
+		}
+		
+		// Plugins in the plugin directory add output formats and pipeline stages
+		if err := loadPlugins(cmd); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 		logger.Info("Using AWS region", "region", awsRegion)
 		
 		// Validate output format
-		if !isValidOutputFormat(toolFormat) {
+		if !isValidOutputFormats(toolFormat) {
 			logger.Error("Invalid output format", "format", toolFormat)
-			fmt.Printf("Error: Invalid output format: %s. Supported formats are: terraform, crossplane\n", toolFormat)
+			fmt.Printf("Error: Invalid output format: %s. Supported formats are: %s\n", toolFormat, strings.Join(outputFormats(), ", "))
 			os.Exit(1)
 		}
 	},
 }
 
+// writesToStdout reports whether a command's output is meant for other programs, such as a
+// diff, a completion script or the completions a shell asks for
+func writesToStdout(cmd *cobra.Command) bool {
+	switch cmd {
+	case diffCmd, explainCmd, completionCmd, mcpCmd:
+		return true
+	case generateCmd:
+		return generateStdout || dryRun || progressFormat == "json"
+	}
+	return cmd.Name() == cobra.ShellCompRequestCmd || cmd.Name() == cobra.ShellCompNoDescRequestCmd
+}
+
+// loadPlugins discovers the plugins in the configured plugin directory, which must exist,
+// or in ~/.iacgen/plugins if it exists
+func loadPlugins(cmd *cobra.Command) error {
+	dir := viper.GetString("plugin_dir")
+	if dir == "" {
+		dir = config.DefaultPluginDir()
+		if info, err := os.Stat(dir); dir == "" || err != nil || !info.IsDir() {
+			return nil
+		}
+	} else if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("plugin directory does not exist: %s", dir)
+	}
+
+	discovered, err := pipeline.LoadPlugins(cmd.Context(), dir)
+	if err != nil {
+		return err
+	}
+	plugins = discovered
+	utils.GetLogger().Debugw("Loaded plugins", "dir", dir, "count", len(plugins))
+	return nil
+}
+
+// outputFormats returns the built-in output formats and those of the generator plugins
+func outputFormats() []string {
+	return append(pipeline.GetAvailableGenerators(), plugin.Formats(plugins)...)
+}
+
 // isValidOutputFormat checks if the provided output format is supported
 func isValidOutputFormat(format string) bool {
-	validFormats := []string{"terraform", "crossplane"}
+	validFormats := outputFormats()
 	format = strings.ToLower(format)
 	
 	for _, v := range validFormats {
@@ -91,6 +208,12 @@ func isValidOutputFormat(format string) bool {
 	return false
 }
 
+// isValidOutputFormats checks each format of a comma-separated list of output formats, such
+// as terraform,crossplane
+func isValidOutputFormats(formats string) bool {
+	return pipeline.ValidOutputFormats(formats, outputFormats())
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -102,10 +225,11 @@ func init() {
 	cobra.OnInitialize(config.InitConfig)
 
 	// Configuration file
-	rootCmd.PersistentFlags().StringVar(&config.CfgFile, "config", "", "config file (default is $HOME/.iacgen.yaml)")
+	rootCmd.PersistentFlags().StringVar(&config.CfgFile, "config", "", "config file (default is $HOME/.iacgen.yaml, then ./iacgen.yaml)")
+	rootCmd.PersistentFlags().StringVar(&config.Profile, "profile", "", "Config file profile whose settings override the file's defaults")
 	
 	// Tool selection
-	rootCmd.PersistentFlags().StringVarP(&toolFormat, "output", "o", "terraform", "Output format (terraform or crossplane)")
+	rootCmd.PersistentFlags().StringVarP(&toolFormat, "output", "o", "terraform", "Output format (terraform or crossplane), or a comma-separated list such as terraform,crossplane to generate each into its own subdirectory")
 	viper.BindPFlag("default_type", rootCmd.PersistentFlags().Lookup("output"))
 
 	// Output directory
@@ -120,6 +244,18 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&useTemplates, "use-templates", false, "Use the template system for generating IaC code")
 	viper.BindPFlag("use_templates", rootCmd.PersistentFlags().Lookup("use-templates"))
 
+	rootCmd.PersistentFlags().StringVar(&templateDir, "template-dir", "", "Directory of templates replacing the built-in ones of the same format and name, such as <dir>/terraform/vpc.tmpl")
+	viper.BindPFlag("template_dir", rootCmd.PersistentFlags().Lookup("template-dir"))
+	rootCmd.PersistentFlags().BoolVar(&noTemplateCache, "no-template-cache", false, "Read and parse each template every time it is used, to see changes to templates being edited")
+	rootCmd.PersistentFlags().StringVar(&templateCacheDir, "template-cache-dir", "", "Directory keeping template check results and rendered partials between runs, keyed by version and template hash")
+	viper.BindPFlag("template_cache_dir", rootCmd.PersistentFlags().Lookup("template-cache-dir"))
+	rootCmd.PersistentFlags().StringArrayVar(&valueFiles, "values", nil, "YAML file of values templates read as .Values, such as artifact buckets or SSO role ARNs; later files override earlier ones (repeatable)")
+	rootCmd.PersistentFlags().StringArrayVar(&valueSettings, "set", nil, "Value templates read as .Values, as key=value with dotted keys such as sso.role_arn=arn:..., overriding the values files (repeatable)")
+
+	// Plugins
+	rootCmd.PersistentFlags().StringVar(&pluginDir, "plugin-dir", "", "Directory of plugins adding output formats and pipeline stages (default is $HOME/.iacgen/plugins)")
+	viper.BindPFlag("plugin_dir", rootCmd.PersistentFlags().Lookup("plugin-dir"))
+
 	// Logging options
 	rootCmd.PersistentFlags().BoolVarP(&debugMode, "debug", "v", false, "Enable debug output")
 	
@@ -128,4 +264,22 @@ func init() {
 	
 	// Add commands
 	rootCmd.AddCommand(generateCmd)
+	rootCmd.AddCommand(feedbackCmd)
+	rootCmd.AddCommand(schemaCmd)
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(estimateCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(explainCmd)
+	rootCmd.AddCommand(graphCmd)
+	rootCmd.AddCommand(modelCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(discoverCmd)
+	rootCmd.AddCommand(completionCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(mcpCmd)
+	rootCmd.AddCommand(wizardCmd)
+	rootCmd.AddCommand(pluginsCmd)
+	rootCmd.AddCommand(templatesCmd)
+	
+	registerCompletions()
 }
\ No newline at end of file
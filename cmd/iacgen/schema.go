@@ -0,0 +1,22 @@
+package iacgen
+
+import (
+	"fmt"
+
+	"github.com/riptano/iac_generator_cli/internal/spec"
+	"github.com/spf13/cobra"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema for --spec files",
+	Long: `Print the JSON Schema describing the structured spec files accepted by
+iacgen generate --spec. Point your editor or CI validator at it to check specs
+before generating.`,
+	Example: `  # Save the schema for editor validation
+  iacgen schema > iacgen-spec.schema.json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Fprint(cmd.OutOrStdout(), string(spec.Schema))
+	},
+}
@@ -0,0 +1,116 @@
+package iacgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/riptano/iac_generator_cli/internal/config"
+	"github.com/riptano/iac_generator_cli/internal/server"
+	"github.com/riptano/iac_generator_cli/internal/template"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Serve command flags
+var serveAddr string
+
+// shutdownTimeout is how long requests in progress are given to finish when the server stops
+const shutdownTimeout = 30 * time.Second
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the generator over HTTP",
+	Long: `Run a long-running HTTP server, so platforms can call the generator without shelling
+out to the CLI. It serves:
+
+  POST /generate  Generate the manifests of a description. The JSON body holds the
+                  description and options (output, region, environment, tags, dr_region,
+                  compliance_report, use_templates); the response maps each generated file
+                  to its content, or is a gzipped tarball when the request sends
+                  "Accept: application/gzip".
+  GET  /model     Parse the description query parameter, with the optional region and
+                  environment parameters, and return the parsed model and the defaults
+                  that were assumed, without generating anything.
+  GET  /healthz   Report that the server is up.
+
+Requests never ask follow-up questions: ambiguous descriptions get defaults. The --region,
+--output and --use-templates flags and the config file set the defaults of requests that
+do not set them; the NLP backend and the config file's tags apply to every request.
+Templates in --template-dir are watched, and a template that changes is rendered by the
+next request.`,
+	Example: `  # Serve on port 8080 of every interface
+  iacgen serve
+
+  # Serve on localhost, parsing descriptions with an LLM (requires OPENAI_API_KEY)
+  iacgen serve --addr localhost:9000 --nlp llm
+
+  # Generate Terraform through the server
+  curl -X POST localhost:8080/generate -d '{"description": "Create a VPC with an EKS cluster with 3 nodes"}'`,
+	Args: cobra.NoArgs,
+	// Execute prints errors once
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		bindNLPFlags(cmd)
+		if !isValidOutputFormats(toolFormat) {
+			return fmt.Errorf("invalid output format: %s (supported formats: terraform, crossplane)", toolFormat)
+		}
+		return validateParsingSettings()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := utils.GetLogger()
+
+		srv := server.NewServer(server.Options{
+			Region:       awsRegion,
+			OutputFormat: toolFormat,
+			UseTemplates: useTemplates,
+			Tags:         config.AppConfig.Tags,
+			RequiredTags: viper.GetStringSlice("required_tags"),
+			Policies:     viper.GetStringSlice("policies"),
+			NLPBackend:   nlpBackend,
+			LLMConfig:    llmConfig(nlpBackend),
+		})
+		httpServer := &http.Server{
+			Addr:              serveAddr,
+			Handler:           srv.Handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		// Stop accepting requests on an interrupt, and let those in progress finish
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := httpServer.Shutdown(shutdownCtx); err != nil {
+				logger.Warnw("Server shutdown failed", "error", err)
+			}
+		}()
+
+		// Templates edited in the template directory are rendered by the next request
+		if err := template.GetDefaultManager().WatchOverrideDir(ctx); err != nil {
+			return err
+		}
+
+		logger.Infow("Serving the generator", "addr", serveAddr, "nlp_backend", nlpBackend)
+		if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		logger.Info("Server stopped")
+		return nil
+	},
+}
+
+func init() {
+	addNLPFlags(serveCmd)
+
+	serveCmd.Flags().StringVar(&serveAddr, "addr", ":8080", "Address to listen on")
+}
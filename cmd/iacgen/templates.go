@@ -0,0 +1,361 @@
+package iacgen
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/riptano/iac_generator_cli/internal/config"
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/riptano/iac_generator_cli/internal/spec"
+	"github.com/riptano/iac_generator_cli/internal/template"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	scaffoldType    string
+	scaffoldFormats string
+	scaffoldForce   bool
+)
+
+// defaultScaffoldDir is the directory templates are scaffolded in when no template directory
+// is configured
+const defaultScaffoldDir = "iac-templates"
+
+var templatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "List, lint, test and scaffold the templates of --use-templates",
+	Long: `Inspect the templates the template system (--use-templates) renders resources with:
+the built-in templates, and those of --template-dir or template_dir in the config file
+that replace them or add new ones.
+
+Every format's templates are used, unless --output selects one.`,
+}
+
+var templatesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the templates of each format",
+	Long: `List the templates of each format, with the resource types rendered with them and
+where each is read from: the template directory, or the built-in templates.`,
+	Example: `  # List the templates, with the overrides of a template directory
+  iacgen templates list --template-dir ./iac-templates`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		templates, err := selectedTemplates(cmd, nil)
+		if err != nil {
+			return err
+		}
+
+		manager := template.GetDefaultManager()
+		selector := template.GetDefaultSelector()
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FORMAT\tTEMPLATE\tRESOURCE TYPES\tSOURCE")
+		for _, t := range templates {
+			var resourceTypes []string
+			for _, resourceType := range selector.ResourceTypes(t.format, t.name) {
+				resourceTypes = append(resourceTypes, string(resourceType))
+			}
+			if len(resourceTypes) == 0 {
+				resourceTypes = []string{"-"}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.format, t.name, strings.Join(resourceTypes, ","), manager.TemplateSource(t.format, t.name))
+		}
+		return w.Flush()
+	},
+}
+
+var templatesLintCmd = &cobra.Command{
+	Use:   "lint [template...]",
+	Short: "Check templates for errors without rendering them",
+	Long: `Check templates without rendering them: that they parse with the template functions,
+so that they call no undefined function, that no action delimiter is left unparsed in
+their text, and that the resource properties they read are ones the model sets on the
+resource types rendered with them.
+
+Templates are named like terraform/vpc.tmpl, or vpc.tmpl for that template of every
+format; all of them are linted by default. The command exits with a non-zero status
+when any problem is an error; unknown properties are warnings.`,
+	Example: `  # Lint every template, with the overrides of a template directory
+  iacgen templates lint --template-dir ./iac-templates
+
+  # Lint one template
+  iacgen templates lint terraform/vpc.tmpl --template-dir ./iac-templates`,
+	// The issues explain a failed lint, and Execute prints the error once
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return checkTemplates(cmd, args, "Linted", template.GetDefaultManager().LintTemplate)
+	},
+}
+
+var templatesTestCmd = &cobra.Command{
+	Use:   "test [template...]",
+	Short: "Render templates against fixture resources to catch runtime errors",
+	Long: `Render templates against fixture resources: a resource of each type the templates
+render, with every property the model may set on it. A template fails when it cannot be
+rendered, such as when a function is called with a value of the wrong type, when it
+renders <no value> for a field the data does not have, or when its output is not valid
+HCL or YAML. Header and footer templates are rendered without a resource.
+
+Templates are named like terraform/vpc.tmpl, or vpc.tmpl for that template of every
+format; all of them are tested by default. The command exits with a non-zero status
+when any template fails.`,
+	Example: `  # Test every template, with the overrides of a template directory
+  iacgen templates test --template-dir ./iac-templates
+
+  # Test the Crossplane templates only
+  iacgen templates test --output crossplane --template-dir ./iac-templates`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return checkTemplates(cmd, args, "Tested", template.GetDefaultManager().TestTemplate)
+	},
+}
+
+var templatesScaffoldCmd = &cobra.Command{
+	Use:   "scaffold",
+	Short: "Create starter templates for a new resource type",
+	Long: `Create a starter template of each format for a resource type the built-in templates do
+not render, in the template directory (--template-dir or template_dir in the config
+file, or ./iac-templates), and map the resource type to them in the config file: the
+file of --config, or ~/.iacgen.yaml. The templates render the resource with the standard
+context: its name, each of its properties, its tags and the tags of the model, and the
+provider of a resource outside the primary region.
+
+The resource type is a Terraform AWS resource type, such as aws_elasticache_cluster,
+whose resources in the model are of the type without the aws_ prefix. Existing
+templates are kept unless --force is set.`,
+	Example: `  # Scaffold Terraform and Crossplane templates for ElastiCache clusters
+  iacgen templates scaffold --type aws_elasticache_cluster --format terraform,crossplane`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resourceType, err := template.ScaffoldResourceType(scaffoldType)
+		if err != nil {
+			return err
+		}
+		var formats []template.TemplateFormat
+		for _, format := range strings.Split(scaffoldFormats, ",") {
+			format = strings.TrimSpace(format)
+			if format != string(template.FormatTerraform) && format != string(template.FormatCrossplane) {
+				return fmt.Errorf("format %s has no templates (template formats: terraform, crossplane)", format)
+			}
+			formats = append(formats, template.TemplateFormat(format))
+		}
+
+		configFile := config.CfgFile
+		if configFile == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return fmt.Errorf("failed to find the user config file: %w", err)
+			}
+			configFile = filepath.Join(home, config.UserConfigFile)
+		}
+		dir := viper.GetString("template_dir")
+		if dir == "" {
+			// The config file may be read from any directory, so it records where the templates are
+			if dir, err = filepath.Abs(defaultScaffoldDir); err != nil {
+				return err
+			}
+			if err := config.SetFileValue(configFile, []string{"template_dir"}, dir); err != nil {
+				return err
+			}
+		}
+
+		name := template.ScaffoldTemplateName(scaffoldType)
+		out := cmd.OutOrStdout()
+		for _, format := range formats {
+			content, err := template.ScaffoldTemplate(format, scaffoldType)
+			if err != nil {
+				return err
+			}
+			path := filepath.Join(dir, string(format), name)
+			if _, err := os.Stat(path); err == nil && !scaffoldForce {
+				fmt.Fprintf(out, "Kept %s, which exists; set --force to replace it\n", path)
+			} else {
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					return err
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					return fmt.Errorf("failed to write %s: %w", path, err)
+				}
+				fmt.Fprintf(out, "Created %s\n", path)
+			}
+			if err := config.SetFileValue(configFile, []string{"template_mappings", string(format), string(resourceType)}, name); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(out, "Mapped %s resources to %s in %s\n", resourceType, name, configFile)
+		return nil
+	},
+}
+
+// templatePattern maps the resource types a regular expression matches to a template, such as
+// those matching ^elasticache_ to elasticache.tmpl
+type templatePattern struct {
+	Pattern  string `mapstructure:"pattern"`
+	Template string `mapstructure:"template"`
+}
+
+// registerTemplateMappings registers the template mappings of the config files with the
+// template selector, such as template_mappings.terraform.elasticache_cluster set to
+// elasticache_cluster.tmpl, and accepts their resource types in saved models and specs. The
+// patterns of template_patterns select templates for the types without a mapping, before the
+// built-in patterns, in the order the file lists them, and accept the types they match.
+func registerTemplateMappings() error {
+	var mappings map[string]map[string]string
+	if err := viper.UnmarshalKey("template_mappings", &mappings); err != nil {
+		return fmt.Errorf("invalid template_mappings: %w", err)
+	}
+	for format, names := range mappings {
+		if format != string(template.FormatTerraform) && format != string(template.FormatCrossplane) {
+			return fmt.Errorf("invalid template_mappings: format %s has no templates (template formats: terraform, crossplane)", format)
+		}
+		for resourceType, name := range names {
+			template.GetDefaultSelector().RegisterTemplate(template.TemplateFormat(format), models.ResourceType(resourceType), name)
+			spec.RegisterResourceType(models.ResourceType(resourceType))
+			warnMissingTemplate(template.TemplateFormat(format), name)
+		}
+	}
+
+	var patterns map[string][]templatePattern
+	if err := viper.UnmarshalKey("template_patterns", &patterns); err != nil {
+		return fmt.Errorf("invalid template_patterns: %w", err)
+	}
+	for format, list := range patterns {
+		if format != string(template.FormatTerraform) && format != string(template.FormatCrossplane) {
+			return fmt.Errorf("invalid template_patterns: format %s has no templates (template formats: terraform, crossplane)", format)
+		}
+		// Patterns registered later are tried first, so the first one of the file is registered last
+		for i := len(list) - 1; i >= 0; i-- {
+			pattern := list[i]
+			if pattern.Pattern == "" || pattern.Template == "" {
+				return fmt.Errorf("invalid template_patterns: each pattern of %s needs a pattern and a template", format)
+			}
+			compiled, err := regexp.Compile(pattern.Pattern)
+			if err != nil {
+				return fmt.Errorf("invalid template_patterns: pattern %q of %s: %w", pattern.Pattern, format, err)
+			}
+			template.GetDefaultSelector().RegisterPatternTemplate(template.TemplateFormat(format), pattern.Pattern, pattern.Template)
+			spec.RegisterResourceTypePattern(compiled)
+			warnMissingTemplate(template.TemplateFormat(format), pattern.Template)
+		}
+	}
+	return nil
+}
+
+// warnMissingTemplate warns that a template of the config file's mappings is neither a
+// built-in template nor one of the template directory, so resources mapped to it fail to
+// render. It is not an error, so that templates scaffold can create it again.
+func warnMissingTemplate(format template.TemplateFormat, name string) {
+	list, err := template.GetDefaultManager().ListTemplates(format)
+	if err != nil {
+		return
+	}
+	for _, existing := range list {
+		if existing == name {
+			return
+		}
+	}
+	utils.GetLogger().Warnw("Template of the template mappings does not exist; add it to the template directory", "template", fmt.Sprintf("%s/%s", format, name))
+}
+
+// namedTemplate is a template of a format
+type namedTemplate struct {
+	format template.TemplateFormat
+	name   string
+}
+
+// selectedTemplates returns the templates of the formats --output selects, or of every
+// format, limited to those named like terraform/vpc.tmpl or vpc.tmpl when names are given
+func selectedTemplates(cmd *cobra.Command, names []string) ([]namedTemplate, error) {
+	formats := []template.TemplateFormat{template.FormatTerraform, template.FormatCrossplane}
+	if cmd.Flags().Changed("output") {
+		formats = nil
+		for _, format := range pipeline.OutputFormats(toolFormat) {
+			if format != string(template.FormatTerraform) && format != string(template.FormatCrossplane) {
+				return nil, fmt.Errorf("format %s has no templates (template formats: terraform, crossplane)", format)
+			}
+			formats = append(formats, template.TemplateFormat(format))
+		}
+	}
+
+	var templates []namedTemplate
+	matched := make(map[string]bool)
+	for _, format := range formats {
+		list, err := template.GetDefaultManager().ListTemplates(format)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range list {
+			if len(names) == 0 {
+				templates = append(templates, namedTemplate{format, name})
+				continue
+			}
+			for _, want := range names {
+				if want == name || want == string(format)+"/"+name {
+					templates = append(templates, namedTemplate{format, name})
+					matched[want] = true
+				}
+			}
+		}
+	}
+	for _, want := range names {
+		if !matched[want] {
+			return nil, fmt.Errorf("no template %s; list them with iacgen templates list", want)
+		}
+	}
+	return templates, nil
+}
+
+// checkTemplates prints the issues check finds in each selected template, and fails when any
+// of them is an error
+func checkTemplates(cmd *cobra.Command, names []string, verb string, check func(template.TemplateFormat, string) []template.TemplateIssue) error {
+	templates, err := selectedTemplates(cmd, names)
+	if err != nil {
+		return err
+	}
+
+	var issues []template.TemplateIssue
+	for _, t := range templates {
+		issues = append(issues, check(t.format, t.name)...)
+	}
+	errors := printTemplateIssues(cmd.OutOrStdout(), issues)
+	fmt.Fprintf(cmd.OutOrStdout(), "%s %d templates: %d errors, %d warnings\n", verb, len(templates), errors, len(issues)-errors)
+	if errors > 0 {
+		return fmt.Errorf("templates %s failed with %d errors", cmd.Name(), errors)
+	}
+	return nil
+}
+
+// printTemplateIssues prints each issue on a line, and returns the number of errors
+func printTemplateIssues(out io.Writer, issues []template.TemplateIssue) int {
+	errors := 0
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, issue := range issues {
+		if issue.Severity == template.TemplateIssueError {
+			errors++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", issue.Severity, issue.Location(), issue.Message)
+	}
+	w.Flush()
+	return errors
+}
+
+func init() {
+	templatesCmd.AddCommand(templatesListCmd)
+	templatesCmd.AddCommand(templatesLintCmd)
+	templatesCmd.AddCommand(templatesTestCmd)
+	templatesCmd.AddCommand(templatesScaffoldCmd)
+
+	templatesScaffoldCmd.Flags().StringVar(&scaffoldType, "type", "", "Terraform AWS resource type to scaffold templates for, such as aws_elasticache_cluster")
+	templatesScaffoldCmd.Flags().StringVar(&scaffoldFormats, "format", "terraform,crossplane", "Comma-separated formats to scaffold templates of (terraform, crossplane)")
+	templatesScaffoldCmd.Flags().BoolVar(&scaffoldForce, "force", false, "Replace templates that exist")
+	templatesScaffoldCmd.MarkFlagRequired("type")
+}
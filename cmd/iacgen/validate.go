@@ -0,0 +1,151 @@
+package iacgen
+
+import (
+	"fmt"
+
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Validate command flags
+	validateJSON            bool
+	validateSkipTerraform   bool
+	validateSchemas         bool
+	validateSchemaLocations []string
+	validateLint            bool
+	validateCheckov         bool
+	validateIAM             bool
+	validateDocker          bool
+	validateTerraformImage  string
+	validatePluginCacheDir  string
+	validatePluginMirror    string
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [directory]",
+	Short: "Validate a directory of generated Terraform or Crossplane files",
+	Long: `Validate an existing output directory without regenerating it.
+
+Terraform files are parsed as HCL, local module sources are checked, and each root
+module is checked with terraform init -backend=false and terraform validate when
+terraform is in PATH. Crossplane manifests are parsed as YAML and checked for the
+apiVersion, kind, metadata.name and spec.forProvider fields, and kustomizations for
+resources that do not exist. With --schemas, the manifests are also checked against
+the schemas of their kinds with kubeconform, which rejects misspelled fields; the
+schemas are fetched from the Kubernetes and CRDs catalogs unless --schema-location
+points at schemas of your own. With --lint, each root module is also linted with
+tflint, using its .tflint.hcl or else a default ruleset of the recommended rules of
+tflint's bundled Terraform plugin. With --checkov, the files are also checked with
+checkov, and the failed checks the checkov section of the config file does not
+suppress are errors. With --iam, the IAM policies and managed policy attachments of
+the Terraform and the manifests are linted: wildcard actions and principals, web
+identity trust without a condition on the service account, and managed policies
+such as AdministratorAccess are errors; write actions on every resource, service
+wildcards and other FullAccess policies are warnings, each with a scoped alternative.
+
+With --docker, terraform runs in a pinned docker image instead of the terraform in
+PATH, so that CI validates with the same terraform everywhere; --terraform-image pins
+another image. --plugin-cache-dir keeps the providers terraform init downloads
+between runs, and --plugin-mirror installs them only from a mirror written by
+terraform providers mirror, in a container without network access.
+
+Every file type found is validated, unless --output selects one format. The findings
+are printed as a report, and the command exits with a non-zero status when any of
+them is an error, so CI can gate on it.`,
+	Example: `  # Validate the current output directory
+  iacgen validate
+
+  # Validate generated Terraform in CI, with a machine-readable report
+  iacgen validate ./infra --output terraform --json
+
+  # Validate with a pinned terraform in docker, with the providers of a mirror only
+  iacgen validate ./infra --docker --plugin-mirror ./providers
+
+  # Check the HCL syntax only, without running terraform
+  iacgen validate ./infra --skip-terraform
+
+  # Lint the generated Terraform with tflint as well
+  iacgen validate ./infra --output terraform --lint
+
+  # Check the Terraform and the manifests against the checkov policies
+  iacgen validate ./infra --checkov
+
+  # Lint the IAM roles and policies for wildcards and broad managed policies
+  iacgen validate ./infra --iam
+
+  # Check the Crossplane manifests against the provider CRD schemas
+  iacgen validate ./infra --output crossplane --schemas
+
+  # Use schemas converted from the installed CRDs instead of fetching them
+  iacgen validate ./infra --schemas --schema-location default \
+    --schema-location './schemas/{{.ResourceKind}}_{{.ResourceAPIVersion}}.json'`,
+	Args: cobra.MaximumNArgs(1),
+	// The findings explain a failed validation, and Execute prints the error once
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := outputDir
+		if len(args) > 0 {
+			dir = args[0]
+		}
+
+		suppressions, err := checkovSuppressions()
+		if err != nil {
+			return err
+		}
+		options := report.ValidationOptions{
+			SkipTerraform:       validateSkipTerraform,
+			Schemas:             validateSchemas,
+			SchemaLocations:     validateSchemaLocations,
+			Lint:                validateLint,
+			Checkov:             validateCheckov,
+			CheckovSuppressions: suppressions,
+			IAM:                 validateIAM,
+			PluginCacheDir:      validatePluginCacheDir,
+			PluginMirrorDir:     validatePluginMirror,
+		}
+		if validateDocker || validateTerraformImage != "" {
+			options.TerraformImage = report.DefaultTerraformImage
+			if validateTerraformImage != "" {
+				options.TerraformImage = validateTerraformImage
+			}
+		}
+		if cmd.Flags().Changed("output") {
+			options.Format = toolFormat
+		}
+		validation, err := report.ValidateDirectory(dir, options)
+		if err != nil {
+			return err
+		}
+
+		if validateJSON {
+			content, err := validation.JSON()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), content)
+		} else {
+			fmt.Fprint(cmd.OutOrStdout(), validation.Text())
+		}
+
+		if errors, _ := validation.Counts(); errors > 0 {
+			return fmt.Errorf("validation failed with %d errors", errors)
+		}
+		return nil
+	},
+}
+
+func init() {
+	validateCmd.Flags().BoolVar(&validateJSON, "json", false, "Print the findings as JSON")
+	validateCmd.Flags().BoolVar(&validateSkipTerraform, "skip-terraform", false, "Only check the HCL syntax, without running terraform init and validate")
+	validateCmd.Flags().BoolVar(&validateSchemas, "schemas", false, "Also check the Crossplane manifests against the schemas of their kinds with kubeconform")
+	validateCmd.Flags().StringArrayVar(&validateSchemaLocations, "schema-location", nil, "Schema location for --schemas, as kubeconform takes it; may be repeated (default the Kubernetes schemas and the CRDs catalog)")
+	validateCmd.Flags().BoolVar(&validateCheckov, "checkov", false, "Also check the files with checkov, leaving out the checks suppressed in the checkov section of the config file")
+	validateCmd.Flags().BoolVar(&validateIAM, "iam", false, "Also lint the IAM policies and managed policy attachments for wildcards, unconditioned web identity trust and broad managed policies")
+	validateCmd.Flags().BoolVar(&validateDocker, "docker", false, "Run terraform init and validate in the pinned "+report.DefaultTerraformImage+" docker image instead of the terraform in PATH")
+	validateCmd.Flags().StringVar(&validateTerraformImage, "terraform-image", "", "Run terraform init and validate in this docker image, pinned by tag or digest (implies --docker)")
+	validateCmd.Flags().StringVar(&validatePluginCacheDir, "plugin-cache-dir", "", "Keep the providers terraform init downloads in this directory between runs")
+	validateCmd.Flags().StringVar(&validatePluginMirror, "plugin-mirror", "", "Install the providers only from this filesystem mirror, written by terraform providers mirror, without network access")
+	validateCmd.Flags().BoolVar(&validateLint, "lint", false, "Also lint the Terraform root modules with tflint, using their .tflint.hcl or the default ruleset")
+}
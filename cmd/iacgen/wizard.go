@@ -0,0 +1,412 @@
+package iacgen
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/config"
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// errWizardInputEnded is returned when the input ends before the wizard is finished
+var errWizardInputEnded = errors.New("input ended before the wizard was finished; nothing was written")
+
+var (
+	// instanceTypePattern matches EC2 instance types, such as t3.medium
+	instanceTypePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*\.[a-z0-9]+$`)
+	// kubernetesVersionPattern matches EKS Kubernetes versions, such as 1.29
+	kubernetesVersionPattern = regexp.MustCompile(`^1\.\d{2}$`)
+)
+
+var wizardCmd = &cobra.Command{
+	Use:   "wizard",
+	Short: "Build a description step by step and generate it",
+	Long: `Walk through the choices of a VPC, its subnets and gateways, and an EKS cluster, one
+question at a time, instead of writing a description.
+
+After each part, the wizard shows the description it built from the answers, in the
+phrasing the parser understands, and the tree of files it would generate. The files are
+written to the output directory once you confirm. Press Enter to keep the default of a
+question.
+
+The wizard prints the equivalent generate command at the end, so the description can be
+kept in a file or changed by hand later.`,
+	Example: `  # Build a description interactively
+  iacgen wizard
+
+  # Default to Crossplane in eu-west-1
+  iacgen wizard --output crossplane --region eu-west-1`,
+	Args: cobra.NoArgs,
+	// Execute prints errors once
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defaultDir := outputDir
+		if defaultDir == "." {
+			defaultDir = "infra"
+		}
+		wizard := &wizard{
+			prompter: &wizardPrompter{in: bufio.NewReader(cmd.InOrStdin()), out: cmd.OutOrStdout()},
+			out:      cmd.OutOrStdout(),
+			choices: wizardChoices{
+				Region:            awsRegion,
+				CIDR:              "10.0.0.0/16",
+				PublicSubnets:     2,
+				PrivateSubnets:    2,
+				InternetGateway:   true,
+				NATGateway:        true,
+				EKS:               true,
+				KubernetesVersion: "1.27",
+				NodeCount:         2,
+				InstanceType:      "t3.medium",
+				OutputFormat:      toolFormat,
+				OutputDir:         defaultDir,
+			},
+		}
+		return wizard.run(cmd.Context())
+	},
+}
+
+// wizardChoices are the answers given to the wizard
+type wizardChoices struct {
+	Region            string
+	CIDR              string
+	PublicSubnets     int
+	PrivateSubnets    int
+	InternetGateway   bool
+	NATGateway        bool
+	EKS               bool
+	KubernetesVersion string
+	NodeCount         int
+	InstanceType      string
+	OutputFormat      string
+	OutputDir         string
+}
+
+// Description phrases the choices as a description the parser understands
+func (c wizardChoices) Description() string {
+	var description strings.Builder
+	fmt.Fprintf(&description, "Create a VPC with CIDR %s in %s", c.CIDR, c.Region)
+
+	switch {
+	case c.PublicSubnets > 0 && c.PrivateSubnets > 0:
+		fmt.Fprintf(&description, " with %d public %s and %d private %s", c.PublicSubnets, plural(c.PublicSubnets, "subnet"), c.PrivateSubnets, plural(c.PrivateSubnets, "subnet"))
+	case c.PublicSubnets > 0:
+		fmt.Fprintf(&description, " with %d public %s", c.PublicSubnets, plural(c.PublicSubnets, "subnet"))
+	case c.PrivateSubnets > 0:
+		fmt.Fprintf(&description, " with %d private %s", c.PrivateSubnets, plural(c.PrivateSubnets, "subnet"))
+	}
+
+	// Gateways are stated either way, so the parser does not assume them. They need public
+	// subnets.
+	if c.InternetGateway && c.PublicSubnets > 0 {
+		description.WriteString(", an internet gateway")
+	} else {
+		description.WriteString(", without an internet gateway")
+	}
+	if c.NATGateway && c.InternetGateway && c.PublicSubnets > 0 && c.PrivateSubnets > 0 {
+		description.WriteString(" and a NAT gateway")
+	} else {
+		description.WriteString(" and without a NAT gateway")
+	}
+
+	if c.EKS {
+		fmt.Fprintf(&description, ", and an EKS cluster version %s with %d %s of instance type %s", c.KubernetesVersion, c.NodeCount, plural(c.NodeCount, "node"), c.InstanceType)
+	}
+	return description.String()
+}
+
+// plural returns the plural of a noun for counts other than one
+func plural(count int, noun string) string {
+	if count == 1 {
+		return noun
+	}
+	return noun + "s"
+}
+
+// wizard asks for the choices of a description, previews what they generate and writes it
+type wizard struct {
+	prompter *wizardPrompter
+	out      io.Writer
+	choices  wizardChoices
+	// files are the files generated by the last preview
+	files map[string]string
+}
+
+// run asks the questions part by part, previewing the generated files after each part, and
+// writes the files when they are confirmed
+func (w *wizard) run(ctx context.Context) error {
+	fmt.Fprintln(w.out, "This wizard builds an infrastructure description from your answers. Press Enter to keep a default.")
+
+	parts := []struct {
+		title string
+		ask   func() error
+	}{
+		{"Network", w.askNetwork},
+		{"Gateways", w.askGateways},
+		{"EKS cluster", w.askCluster},
+		{"Output", w.askOutput},
+	}
+	for _, part := range parts {
+		fmt.Fprintf(w.out, "\n== %s ==\n", part.title)
+		if err := part.ask(); err != nil {
+			return err
+		}
+		if err := w.preview(ctx); err != nil {
+			return err
+		}
+	}
+
+	write, err := w.prompter.askYesNo(fmt.Sprintf("\nWrite %d files to %s?", len(w.files), w.choices.OutputDir), true)
+	if err != nil {
+		return err
+	}
+	if !write {
+		fmt.Fprintln(w.out, "Nothing was written.")
+		return nil
+	}
+
+	paths := sortedPaths(w.files)
+	for _, path := range paths {
+		if err := utils.WriteToFile(filepath.Join(w.choices.OutputDir, filepath.FromSlash(path)), w.files[path]); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(w.out, "Wrote %d %s files to %s\n", len(paths), w.choices.OutputFormat, w.choices.OutputDir)
+	fmt.Fprintf(w.out, "\nTo generate them again, or from a changed description, run:\n  iacgen generate %q --output %s --output-dir %s\n",
+		w.choices.Description(), w.choices.OutputFormat, w.choices.OutputDir)
+	return nil
+}
+
+// askNetwork asks for the region, CIDR block and subnets of the VPC
+func (w *wizard) askNetwork() error {
+	var err error
+	c := &w.choices
+	if c.Region, err = w.prompter.ask("AWS region", c.Region, func(answer string) error {
+		if !isValidRegionFormat(answer) {
+			return fmt.Errorf("%s is not an AWS region, such as us-east-1", answer)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if c.CIDR, err = w.prompter.ask("VPC CIDR block", c.CIDR, func(answer string) error {
+		ip, network, err := net.ParseCIDR(answer)
+		if err != nil || ip.To4() == nil || !ip.Equal(network.IP) {
+			return fmt.Errorf("%s is not an IPv4 network, such as 10.0.0.0/16", answer)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if c.PublicSubnets, err = w.prompter.askInt("Public subnets, one per availability zone", c.PublicSubnets, 0, 6); err != nil {
+		return err
+	}
+	if c.PrivateSubnets, err = w.prompter.askInt("Private subnets, one per availability zone", c.PrivateSubnets, 0, 6); err != nil {
+		return err
+	}
+	// The EKS cluster is placed in the private subnets, which must span two availability zones.
+	// Without private subnets, the cluster gets its own.
+	if !eksSubnetsAllowed(c.PrivateSubnets) {
+		c.EKS = false
+	}
+	return nil
+}
+
+// askGateways asks for the internet and NAT gateways, which need public subnets
+func (w *wizard) askGateways() error {
+	var err error
+	c := &w.choices
+	if c.PublicSubnets == 0 {
+		fmt.Fprintln(w.out, "Without public subnets, the VPC has no internet or NAT gateway.")
+		c.InternetGateway, c.NATGateway = false, false
+		return nil
+	}
+	if c.InternetGateway, err = w.prompter.askYesNo("Internet gateway for the public subnets?", c.InternetGateway); err != nil {
+		return err
+	}
+	if c.PrivateSubnets == 0 || !c.InternetGateway {
+		c.NATGateway = false
+		return nil
+	}
+	c.NATGateway, err = w.prompter.askYesNo("NAT gateway for outbound traffic from the private subnets?", c.NATGateway)
+	return err
+}
+
+// eksSubnetsAllowed reports whether an EKS cluster can be placed in a number of private
+// subnets: none, which the description leaves for the parser to add in two availability
+// zones, or one in each of at least two
+func eksSubnetsAllowed(privateSubnets int) bool {
+	return privateSubnets == 0 || privateSubnets >= infra.MinEKSAvailabilityZones
+}
+
+// askCluster asks for the EKS cluster and its nodes
+func (w *wizard) askCluster() error {
+	var err error
+	c := &w.choices
+	if !eksSubnetsAllowed(c.PrivateSubnets) {
+		fmt.Fprintf(w.out, "With private subnets in fewer than %d availability zones, the VPC has no EKS cluster.\n", infra.MinEKSAvailabilityZones)
+		return nil
+	}
+	if c.EKS, err = w.prompter.askYesNo("EKS cluster?", c.EKS); err != nil || !c.EKS {
+		return err
+	}
+	if c.KubernetesVersion, err = w.prompter.ask("Kubernetes version", c.KubernetesVersion, func(answer string) error {
+		if !kubernetesVersionPattern.MatchString(answer) {
+			return fmt.Errorf("%s is not a Kubernetes version, such as 1.29", answer)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if c.NodeCount, err = w.prompter.askInt("Worker nodes", c.NodeCount, 1, 100); err != nil {
+		return err
+	}
+	c.InstanceType, err = w.prompter.ask("Node instance type", c.InstanceType, func(answer string) error {
+		if !instanceTypePattern.MatchString(answer) {
+			return fmt.Errorf("%s is not an EC2 instance type, such as t3.medium", answer)
+		}
+		return nil
+	})
+	return err
+}
+
+// askOutput asks for the output format and directory
+func (w *wizard) askOutput() error {
+	var err error
+	c := &w.choices
+	if c.OutputFormat, err = w.prompter.ask("Output format (terraform or crossplane)", c.OutputFormat, func(answer string) error {
+		if !isValidOutputFormat(answer) {
+			return fmt.Errorf("%s is not an output format; use terraform or crossplane", answer)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	c.OutputFormat = strings.ToLower(c.OutputFormat)
+	c.OutputDir, err = w.prompter.ask("Output directory", c.OutputDir, nil)
+	return err
+}
+
+// preview generates the current choices in memory, and shows their description and the tree
+// of files they generate, unless the files are those of the previous preview
+func (w *wizard) preview(ctx context.Context) error {
+	description := w.choices.Description()
+	files, err := pipeline.GenerateFiles(ctx, &pipeline.ProcessingParams{
+		Description:  description,
+		OutputFormat: w.choices.OutputFormat,
+		Region:       w.choices.Region,
+		UseTemplates: useTemplates,
+		Tags:         config.AppConfig.Tags,
+		RequiredTags: viper.GetStringSlice("required_tags"),
+		Policies:     viper.GetStringSlice("policies"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to preview the description %q: %w", description, err)
+	}
+	previous := strings.Join(sortedPaths(w.files), "\n")
+	w.files = files
+
+	fmt.Fprintf(w.out, "\nDescription: %s\n", description)
+	paths := sortedPaths(files)
+	if strings.Join(paths, "\n") == previous {
+		fmt.Fprintf(w.out, "Files: the same %d files as before\n", len(paths))
+		return nil
+	}
+	fmt.Fprintf(w.out, "%s/\n%s", strings.TrimSuffix(w.choices.OutputDir, "/"), report.FileTree(paths, nil))
+	return nil
+}
+
+// sortedPaths returns the paths of generated files in order
+func sortedPaths(files map[string]string) []string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// wizardPrompter asks the wizard's questions and reads the answers
+type wizardPrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask asks a question until the answer passes the check. An empty answer keeps the default.
+func (p *wizardPrompter) ask(question, defaultValue string, check func(string) error) (string, error) {
+	for {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, defaultValue)
+		line, err := p.in.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("failed to read answer: %w", err)
+		}
+		answer := strings.TrimSpace(line)
+		if err == io.EOF && answer == "" {
+			fmt.Fprintln(p.out)
+			return "", errWizardInputEnded
+		}
+		if answer == "" {
+			answer = defaultValue
+		}
+		if check == nil {
+			return answer, nil
+		}
+		checkErr := check(answer)
+		if checkErr == nil {
+			return answer, nil
+		}
+		if err == io.EOF {
+			return "", checkErr
+		}
+		fmt.Fprintln(p.out, checkErr)
+	}
+}
+
+// askInt asks for a number between min and max
+func (p *wizardPrompter) askInt(question string, defaultValue, min, max int) (int, error) {
+	answer, err := p.ask(question, strconv.Itoa(defaultValue), func(answer string) error {
+		value, err := strconv.Atoi(answer)
+		if err != nil || value < min || value > max {
+			return fmt.Errorf("please enter a number from %d to %d", min, max)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(answer)
+}
+
+// askYesNo asks a yes or no question
+func (p *wizardPrompter) askYesNo(question string, defaultValue bool) (bool, error) {
+	defaultAnswer := "n"
+	if defaultValue {
+		defaultAnswer = "y"
+	}
+	answer, err := p.ask(question+" (y/n)", defaultAnswer, func(answer string) error {
+		switch strings.ToLower(answer) {
+		case "y", "yes", "n", "no":
+			return nil
+		}
+		return errors.New("please answer y or n")
+	})
+	if err != nil {
+		return false, err
+	}
+	return strings.HasPrefix(strings.ToLower(answer), "y"), nil
+}
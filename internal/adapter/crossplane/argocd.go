@@ -0,0 +1,98 @@
+package crossplane
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// ArgoCDDirectory is the directory of the Argo CD manifests, beside the tree they deploy
+const ArgoCDDirectory = "argocd"
+
+// applicationSpec returns the spec of an application deploying a path of the repository
+func applicationSpec(repoPath string, config GitOpsConfig) map[string]interface{} {
+	syncPolicy := map[string]interface{}{
+		// The claims and managed resources are only known once Crossplane and its providers
+		// have installed their CRDs
+		"syncOptions": []string{"CreateNamespace=true", "SkipDryRunOnMissingResource=true"},
+	}
+	if config.SyncPolicy != SyncManual {
+		syncPolicy["automated"] = map[string]bool{
+			"prune":    config.SyncPolicy == SyncAutoPrune,
+			"selfHeal": true,
+		}
+	}
+	return map[string]interface{}{
+		"project": "default",
+		"source": map[string]string{
+			"repoURL":        config.RepoURL,
+			"targetRevision": config.Revision,
+			"path":           repoPath,
+		},
+		"destination": map[string]string{
+			"server":    "https://kubernetes.default.svc",
+			"namespace": config.DestinationNamespace,
+		},
+		"syncPolicy": syncPolicy,
+	}
+}
+
+// ArgoCDApplication returns the Argo CD Application deploying the kustomize tree at a path of
+// the repository
+func ArgoCDApplication(repoPath string, config GitOpsConfig) K8sObject {
+	app := NewK8sObject("argoproj.io/v1alpha1", "Application", gitOpsName(repoPath))
+	app.SetNamespace(config.Namespace)
+	app.Spec = applicationSpec(repoPath, config)
+	return app
+}
+
+// ArgoCDApplicationSet returns the Argo CD ApplicationSet deploying the kustomize tree of each
+// environment, at the environment's directory under config.Path followed by subdir
+func ArgoCDApplicationSet(environments []string, subdir string, config GitOpsConfig) K8sObject {
+	name := gitOpsName(config.Path)
+	appSet := NewK8sObject("argoproj.io/v1alpha1", "ApplicationSet", name)
+	appSet.SetNamespace(config.Namespace)
+
+	elements := make([]map[string]string, 0, len(environments))
+	for _, environment := range environments {
+		elements = append(elements, map[string]string{"environment": environment})
+	}
+	appSet.SetSpecField("generators", []map[string]interface{}{{
+		"list": map[string]interface{}{"elements": elements},
+	}})
+	appSet.SetSpecField("template", map[string]interface{}{
+		"metadata": map[string]string{"name": name + "-{{environment}}"},
+		"spec":     applicationSpec(path.Join(config.Path, "{{environment}}", subdir), config),
+	})
+	return appSet
+}
+
+// WriteArgoCDApplication writes the Application deploying the Crossplane output in dir, at
+// repoPath in the repository, to dir/argocd/application.yaml. The root kustomization leaves
+// the directory out, so the application does not deploy itself.
+func WriteArgoCDApplication(dir, repoPath string, config GitOpsConfig) error {
+	argoDir := filepath.Join(dir, ArgoCDDirectory)
+	if err := utils.EnsureDirectoryExists(argoDir); err != nil {
+		return fmt.Errorf("failed to create Argo CD directory: %w", err)
+	}
+	if err := WriteYAML(ArgoCDApplication(repoPath, config), filepath.Join(argoDir, "application.yaml")); err != nil {
+		return fmt.Errorf("failed to write Argo CD application: %w", err)
+	}
+	return nil
+}
+
+// WriteArgoCDApplicationSet writes the ApplicationSet deploying the Crossplane output of the
+// environments, each in its directory of dir followed by subdir, to
+// dir/argocd/applicationset.yaml
+func WriteArgoCDApplicationSet(dir string, environments []string, subdir string, config GitOpsConfig) error {
+	argoDir := filepath.Join(dir, ArgoCDDirectory)
+	if err := utils.EnsureDirectoryExists(argoDir); err != nil {
+		return fmt.Errorf("failed to create Argo CD directory: %w", err)
+	}
+	if err := WriteYAML(ArgoCDApplicationSet(environments, subdir, config), filepath.Join(argoDir, "applicationset.yaml")); err != nil {
+		return fmt.Errorf("failed to write Argo CD application set: %w", err)
+	}
+	return nil
+}
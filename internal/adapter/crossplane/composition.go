@@ -0,0 +1,447 @@
+package crossplane
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// compositionGroup is the API group of the composite resources and their claims
+const compositionGroup = "platform.iacgen.io"
+
+// compositionVersion is the version of the composite resources' API
+const compositionVersion = "v1alpha1"
+
+// defaultClaimNamespace is the namespace of the example claims without a team owning them
+const defaultClaimNamespace = "default"
+
+// patchAndTransformPackage is the composition function the Compositions run
+const patchAndTransformPackage = "xpkg.upbound.io/crossplane-contrib/function-patch-and-transform:v0.7.0"
+
+// Labels of the composed resources: the name of a resource in its Composition, which the
+// selectors of the others in the composite match, and the claim name of the network of a
+// subnet, which the selectors of a cluster match
+const (
+	composedResourceLabel = compositionGroup + "/resource"
+	networkLabel          = compositionGroup + "/network"
+)
+
+// WithCompositions sets whether CompositeResourceDefinitions and Compositions for the network
+// and the EKS cluster are written to compositions/, with example claims in claims/
+func (g *CrossplaneGenerator) WithCompositions(enabled bool) *CrossplaneGenerator {
+	g.compositions = enabled
+	return g
+}
+
+// compositeDefinition returns the CompositeResourceDefinition of a composite resource and
+// its namespaced claim, whose spec.parameters have the given properties
+func compositeDefinition(kind, plural, claimKind, claimPlural string, properties map[string]interface{}, required []string) K8sObject {
+	xrd := NewK8sObject("apiextensions.crossplane.io/v1", "CompositeResourceDefinition", plural+"."+compositionGroup)
+	xrd.SetSpecField("group", compositionGroup)
+	xrd.SetSpecField("names", map[string]string{"kind": kind, "plural": plural})
+	xrd.SetSpecField("claimNames", map[string]string{"kind": claimKind, "plural": claimPlural})
+	xrd.SetSpecField("versions", []map[string]interface{}{{
+		"name":          compositionVersion,
+		"served":        true,
+		"referenceable": true,
+		"schema": map[string]interface{}{
+			"openAPIV3Schema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"parameters": map[string]interface{}{
+								"type":       "object",
+								"properties": properties,
+								"required":   required,
+							},
+						},
+						"required": []string{"parameters"},
+					},
+				},
+			},
+		},
+	}})
+	return xrd
+}
+
+// stringList is the schema of a list of two strings, one per availability zone
+func stringList(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "array",
+		"description": description,
+		"items":       map[string]string{"type": "string"},
+		"minItems":    2,
+		"maxItems":    2,
+	}
+}
+
+// schemaField is the schema of a scalar parameter
+func schemaField(fieldType, description string) map[string]interface{} {
+	return map[string]interface{}{"type": fieldType, "description": description}
+}
+
+// selector returns a selector of the composite's resource with the given name in its
+// Composition
+func selector(resource string) map[string]interface{} {
+	return map[string]interface{}{
+		"matchControllerRef": true,
+		"matchLabels":        map[string]string{composedResourceLabel: resource},
+	}
+}
+
+// fromComposite is a patch copying a field of the composite resource to a composed resource
+func fromComposite(from, to string, transforms ...map[string]interface{}) map[string]interface{} {
+	patch := map[string]interface{}{
+		"type":          "FromCompositeFieldPath",
+		"fromFieldPath": from,
+		"toFieldPath":   to,
+	}
+	if len(transforms) > 0 {
+		patch["transforms"] = transforms
+	}
+	return patch
+}
+
+// composedResource returns the entry of a composed resource in a Composition's
+// patch-and-transform input. The object is labeled with its name, which the selectors of
+// the others match, and loses the name and references of a standalone object.
+func composedResource(name string, obj K8sObject, patches ...map[string]interface{}) map[string]interface{} {
+	obj.AddLabel(composedResourceLabel, name)
+	delete(obj.Metadata.Annotations, "crossplane.io/external-name")
+
+	metadata := map[string]interface{}{"labels": obj.Metadata.Labels}
+	if len(obj.Metadata.Annotations) > 0 {
+		metadata["annotations"] = obj.Metadata.Annotations
+	}
+	entry := map[string]interface{}{
+		"name": name,
+		"base": map[string]interface{}{
+			"apiVersion": obj.APIVersion,
+			"kind":       obj.Kind,
+			"metadata":   metadata,
+			"spec":       obj.Spec,
+		},
+	}
+	if len(patches) > 0 {
+		entry["patches"] = patches
+	}
+	return entry
+}
+
+// composition returns the Composition of a composite resource, running the
+// patch-and-transform function over its resources
+func composition(name, compositeKind string, resources []map[string]interface{}) K8sObject {
+	comp := NewK8sObject("apiextensions.crossplane.io/v1", "Composition", name)
+	comp.AddLabel(compositionGroup+"/provider", "aws")
+	comp.AddNestedSpecField([]string{"compositeTypeRef", "apiVersion"}, compositionGroup+"/"+compositionVersion)
+	comp.AddNestedSpecField([]string{"compositeTypeRef", "kind"}, compositeKind)
+	comp.SetSpecField("mode", "Pipeline")
+	comp.SetSpecField("pipeline", []map[string]interface{}{{
+		"step":        "patch-and-transform",
+		"functionRef": map[string]string{"name": "function-patch-and-transform"},
+		"input": map[string]interface{}{
+			"apiVersion": "pt.fn.crossplane.io/v1beta1",
+			"kind":       "Resources",
+			"resources":  resources,
+		},
+	}})
+	return comp
+}
+
+// baseObject returns a standalone object written for the generator's provider, with its
+// policy, as the base of a composed resource. The composed resources of each claim are new,
+// so they adopt no existing resources.
+func (g *CrossplaneGenerator) baseObject(obj K8sObject, region string) K8sObject {
+	policy := ResourcePolicy{Deletion: g.policy.Deletion, Management: g.policy.Management}
+	return policy.apply(providerObjects(g.provider, []K8sObject{obj}, region))[0]
+}
+
+// networkComposition returns the Composition of XNetwork: a VPC with an internet gateway and
+// a public subnet in each of two availability zones, routed to the gateway. The subnets are
+// labeled with the claim's name, so the clusters of the network find them.
+func (g *CrossplaneGenerator) networkComposition(region string) K8sObject {
+	regionPatch := fromComposite("spec.parameters.region", "spec.forProvider.region")
+
+	vpc := g.vpcGenerator.GenerateVPC("vpc", "10.0.0.0/16", true, true)
+	igw := g.vpcGenerator.GenerateInternetGateway("internet-gateway", "")
+	rt := g.vpcGenerator.GenerateRouteTable("route-table", "", true)
+	route := g.vpcGenerator.GenerateRoute("route", "", "0.0.0.0/0", "", "")
+	for _, obj := range []*K8sObject{&igw, &rt} {
+		delete(obj.forProvider(), "vpcIdRef")
+		obj.forProvider()["vpcIdSelector"] = selector("vpc")
+	}
+	delete(route.forProvider(), "routeTableIdRef")
+	route.forProvider()["routeTableIdSelector"] = selector("route-table")
+	route.forProvider()["gatewayIdSelector"] = selector("internet-gateway")
+
+	resources := []map[string]interface{}{
+		composedResource("vpc", g.baseObject(vpc, region), regionPatch,
+			fromComposite("spec.parameters.cidrBlock", "spec.forProvider.cidrBlock")),
+		composedResource("internet-gateway", g.baseObject(igw, region), regionPatch),
+		composedResource("route-table", g.baseObject(rt, region), regionPatch),
+		composedResource("route", g.baseObject(route, region), regionPatch),
+	}
+	for i, zone := range []string{"a", "b"} {
+		subnetName, associationName := "subnet-"+zone, "route-table-association-"+zone
+		subnet := g.vpcGenerator.GenerateSubnet(subnetName, "", "", "", true)
+		delete(subnet.forProvider(), "vpcIdRef")
+		subnet.forProvider()["vpcIdSelector"] = selector("vpc")
+		association := g.vpcGenerator.GenerateSubnetRouteTableAssociation(associationName, "", "")
+		delete(association.forProvider(), "subnetIdRef")
+		delete(association.forProvider(), "routeTableIdRef")
+		association.forProvider()["subnetIdSelector"] = selector(subnetName)
+		association.forProvider()["routeTableIdSelector"] = selector("route-table")
+
+		resources = append(resources,
+			composedResource(subnetName, g.baseObject(subnet, region), regionPatch,
+				fromComposite(fmt.Sprintf("spec.parameters.subnetCidrBlocks[%d]", i), "spec.forProvider.cidrBlock"),
+				fromComposite(fmt.Sprintf("spec.parameters.availabilityZones[%d]", i), "spec.forProvider.availabilityZone"),
+				fromComposite("spec.claimRef.name", "metadata.labels["+networkLabel+"]")),
+			composedResource(associationName, g.baseObject(association, region), regionPatch))
+	}
+	return composition("xnetworks.aws."+compositionGroup, "XNetwork", resources)
+}
+
+// kubernetesClusterComposition returns the Composition of XKubernetesCluster: an EKS cluster
+// with a managed node group and their IAM roles, in the subnets of the network the claim
+// names
+func (g *CrossplaneGenerator) kubernetesClusterComposition(region string) K8sObject {
+	regionPatch := fromComposite("spec.parameters.region", "spec.forProvider.region")
+	vpcConfigPath, scalingPath := "spec.forProvider.resourcesVpcConfig", "spec.forProvider.scalingConfig"
+	if g.provider == ProviderUpbound {
+		vpcConfigPath, scalingPath = "spec.forProvider.vpcConfig[0]", "spec.forProvider.scalingConfig[0]"
+	}
+	networkSelector := map[string]interface{}{"matchLabels": map[string]string{networkLabel: ""}}
+
+	// The composed roles have the trust and managed policies of the standalone ones
+	clusterRole, nodeRole := g.eksGenerator.generateClusterRoles("cluster-role", "node-role")
+
+	cluster := g.eksGenerator.GenerateEKSCluster("cluster", "1.27", "", nil, true, false, nil, nil)
+	delete(cluster.forProvider(), "roleArnRef")
+	cluster.forProvider()["roleArnSelector"] = selector("cluster-role")
+	vpcConfig := cluster.forProvider()["resourcesVpcConfig"].(map[string]interface{})
+	delete(vpcConfig, "subnetIdRefs")
+	vpcConfig["subnetIdSelector"] = networkSelector
+
+	nodeGroup := g.eksGenerator.GenerateEKSNodeGroup("node-group", "", "", nil, []string{"t3.medium"}, 2, 2, 4, 20, "AL2_x86_64", nil, nil)
+	delete(nodeGroup.forProvider(), "clusterNameRef")
+	delete(nodeGroup.forProvider(), "subnetIdRefs")
+	delete(nodeGroup.forProvider(), "nodeRoleRef")
+	nodeGroup.forProvider()["clusterNameSelector"] = map[string]interface{}{"matchControllerRef": true}
+	nodeGroup.forProvider()["subnetIdSelector"] = networkSelector
+	nodeGroup.forProvider()["nodeRoleSelector"] = selector("node-role")
+
+	subnetLabel := "subnetIdSelector.matchLabels[" + networkLabel + "]"
+	clusterEntry := composedResource("cluster", g.baseObject(cluster, region), regionPatch,
+		fromComposite("spec.parameters.version", "spec.forProvider.version"),
+		fromComposite("spec.parameters.networkName", vpcConfigPath+"."+subnetLabel))
+	resources := []map[string]interface{}{
+		composedResource("cluster-role", g.baseObject(clusterRole, region)),
+		composedResource("node-role", g.baseObject(nodeRole, region)),
+		clusterEntry,
+		composedResource("node-group", g.baseObject(nodeGroup, region), regionPatch,
+			fromComposite("spec.parameters.networkName", "spec.forProvider."+subnetLabel),
+			fromComposite("spec.parameters.instanceType", "spec.forProvider.instanceTypes[0]"),
+			fromComposite("spec.parameters.nodeCount", scalingPath+".desiredSize"),
+			fromComposite("spec.parameters.nodeCount", scalingPath+".minSize"),
+			// The node group scales to twice the nodes it starts with
+			fromComposite("spec.parameters.nodeCount", scalingPath+".maxSize", map[string]interface{}{
+				"type": "math",
+				"math": map[string]interface{}{"type": "Multiply", "multiply": 2},
+			})),
+	}
+
+	// The composite aggregates the cluster's connection details into the claim's secret
+	namespace := g.secretNamespace()
+	if g.connectionSecrets == ConnectionSecretsComposite {
+		keys := clusterConnectionKeys(g.provider)
+		if g.provider == ProviderUpbound {
+			auth := g.eksGenerator.GenerateClusterAuth("cluster-auth", region)
+			delete(auth.forProvider(), "clusterNameRef")
+			auth.forProvider()["clusterNameSelector"] = selector("cluster")
+			authEntry := composedResource("cluster-auth", g.baseObject(auth, region), regionPatch)
+			composedConnectionSecret(authEntry, namespace, "cluster-auth", keys)
+			resources = append(resources, authEntry)
+		} else {
+			composedConnectionSecret(clusterEntry, namespace, "cluster", keys)
+		}
+	}
+	comp := composition("xkubernetesclusters.aws."+compositionGroup, "XKubernetesCluster", resources)
+	if g.connectionSecrets == ConnectionSecretsComposite {
+		comp.SetSpecField("writeConnectionSecretsToNamespace", namespace)
+	}
+	return comp
+}
+
+// networkClaim returns the example Network claim of the model's VPC, with its CIDR blocks
+// and a subnet in each of the region's first two availability zones
+func networkClaim(model *models.InfrastructureModel, region string) (K8sObject, bool) {
+	name, cidrBlock := "", "10.0.0.0/16"
+	subnetCidrBlocks := []string{"10.0.0.0/24", "10.0.1.0/24"}
+	var cidrs []string
+	for i := range model.Resources {
+		resource := &model.Resources[i]
+		if resourceRegion, ok := resource.GetProperty("region"); ok && resourceRegion != region {
+			continue
+		}
+		switch resource.Type {
+		case models.ResourceVPC:
+			if name == "" {
+				name = resource.Name
+				if value, ok := resource.GetProperty("cidr_block"); ok {
+					if cidr, ok := value.(string); ok && cidr != "" {
+						cidrBlock = cidr
+					}
+				}
+			}
+		case models.ResourceSubnet:
+			if value, ok := resource.GetProperty("cidr_block"); ok {
+				if cidr, ok := value.(string); ok && cidr != "" {
+					cidrs = append(cidrs, cidr)
+				}
+			}
+		}
+	}
+	if name == "" {
+		return K8sObject{}, false
+	}
+	if len(cidrs) >= 2 {
+		subnetCidrBlocks = cidrs[:2]
+	}
+
+	claim := NewK8sObject(compositionGroup+"/"+compositionVersion, "Network", name)
+	claim.SetSpecField("parameters", map[string]interface{}{
+		"region":            region,
+		"cidrBlock":         cidrBlock,
+		"availabilityZones": []string{region + "a", region + "b"},
+		"subnetCidrBlocks":  subnetCidrBlocks,
+	})
+	return claim, true
+}
+
+// kubernetesClusterClaim returns the example KubernetesCluster claim of the model's EKS
+// cluster, sized as its first node group, in the network of the given claim
+func kubernetesClusterClaim(model *models.InfrastructureModel, region, networkName string) (K8sObject, bool) {
+	name, version := "", "1.27"
+	instanceType, nodeCount := "t3.medium", 2
+	for i := range model.Resources {
+		resource := &model.Resources[i]
+		switch resource.Type {
+		case models.ResourceEKSCluster:
+			if name == "" {
+				name = resource.Name
+				if value, ok := resource.GetProperty("version"); ok {
+					if v, ok := value.(string); ok && v != "" {
+						version = v
+					}
+				}
+			}
+		case models.ResourceNodeGroup:
+			if value, ok := resource.GetProperty("instance_types"); ok {
+				if types, ok := value.([]string); ok && len(types) > 0 {
+					instanceType = types[0]
+				}
+			}
+			if value, ok := resource.GetProperty("scaling_config"); ok {
+				if scaling, ok := value.(map[string]interface{}); ok {
+					if size, ok := scaling["desired_size"].(int); ok && size > 0 {
+						nodeCount = size
+					}
+				}
+			}
+		}
+	}
+	if name == "" {
+		return K8sObject{}, false
+	}
+
+	claim := NewK8sObject(compositionGroup+"/"+compositionVersion, "KubernetesCluster", name)
+	claim.SetSpecField("parameters", map[string]interface{}{
+		"region":       region,
+		"networkName":  networkName,
+		"version":      version,
+		"instanceType": instanceType,
+		"nodeCount":    nodeCount,
+	})
+	return claim, true
+}
+
+// generateCompositions writes the CompositeResourceDefinitions and Compositions of XNetwork and
+// XKubernetesCluster, and the function they run, to compositions/, and example claims of the
+// model's network and cluster to claims/. The compositions are applied first, so the claims'
+// APIs exist when the claims are.
+func (g *CrossplaneGenerator) generateCompositions(model *models.InfrastructureModel, region string) error {
+	compositionsDir := filepath.Join(g.baseDir, "compositions")
+	claimsDir := filepath.Join(g.baseDir, "claims")
+	for _, dir := range []string{compositionsDir, claimsDir} {
+		if err := utils.EnsureDirectoryExists(dir); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+
+	function := NewK8sObject("pkg.crossplane.io/v1beta1", "Function", "function-patch-and-transform")
+	function.SetSpecField("package", patchAndTransformPackage)
+
+	definitions := []K8sObject{
+		compositeDefinition("XNetwork", "xnetworks", "Network", "networks", map[string]interface{}{
+			"region":            schemaField("string", "AWS region of the network"),
+			"cidrBlock":         schemaField("string", "CIDR block of the VPC"),
+			"availabilityZones": stringList("Availability zones of the two subnets"),
+			"subnetCidrBlocks":  stringList("CIDR blocks of the two subnets"),
+		}, []string{"region", "cidrBlock", "availabilityZones", "subnetCidrBlocks"}),
+		compositeDefinition("XKubernetesCluster", "xkubernetesclusters", "KubernetesCluster", "kubernetesclusters", map[string]interface{}{
+			"region":       schemaField("string", "AWS region of the cluster"),
+			"networkName":  schemaField("string", "Name of the Network claim whose subnets the cluster runs in"),
+			"version":      schemaField("string", "Kubernetes version of the cluster"),
+			"instanceType": schemaField("string", "EC2 instance type of the nodes"),
+			"nodeCount":    map[string]interface{}{"type": "integer", "description": "Number of nodes the node group starts with", "minimum": 1},
+		}, []string{"region", "networkName", "version", "instanceType", "nodeCount"}),
+	}
+
+	if g.connectionSecrets == ConnectionSecretsComposite {
+		definitions[1].SetSpecField("connectionSecretKeys", clusterConnectionKeys(g.provider))
+	}
+
+	files := map[string][]K8sObject{
+		"functions.yaml":          {function},
+		"definitions.yaml":        definitions,
+		"network.yaml":            {g.networkComposition(region)},
+		"kubernetes-cluster.yaml": {g.kubernetesClusterComposition(region)},
+	}
+	for _, file := range []string{"functions.yaml", "definitions.yaml", "network.yaml", "kubernetes-cluster.yaml"} {
+		if err := WriteMultiYAML(files[file], filepath.Join(compositionsDir, file)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file, err)
+		}
+		if err := AddKustomizationResource(compositionsDir, file); err != nil {
+			return err
+		}
+	}
+
+	// A cluster is claimed in the claimed network
+	var claims []K8sObject
+	if network, ok := networkClaim(model, region); ok {
+		claims = append(claims, network)
+		if cluster, ok := kubernetesClusterClaim(model, region, network.Metadata.Name); ok {
+			if g.connectionSecrets == ConnectionSecretsComposite {
+				cluster.SetSpecField("writeConnectionSecretToRef", map[string]string{"name": connectionSecretName(cluster.Metadata.Name)})
+			}
+			claims = append(claims, cluster)
+		}
+	}
+	claimFiles := map[string]string{"Network": "network.yaml", "KubernetesCluster": "kubernetes-cluster.yaml"}
+	for _, claim := range claims {
+		claim.SetNamespace(g.claimNamespace())
+		file := claimFiles[claim.Kind]
+		if err := WriteYAML(claim, filepath.Join(claimsDir, file)); err != nil {
+			return fmt.Errorf("failed to write claim %s: %w", file, err)
+		}
+		if err := AddKustomizationResource(claimsDir, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
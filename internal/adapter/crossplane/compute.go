@@ -0,0 +1,387 @@
+package crossplane
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// ComputeGenerator generates Crossplane YAML for launch templates, Auto Scaling Groups and EC2 instances
+type ComputeGenerator struct {
+	baseDir string
+	ec2Dir  string
+	// provider is the Crossplane provider the resources are written for, and region the
+	// region of those naming none
+	provider string
+	region   string
+	// policy is the deletion and management policy of the managed resources
+	policy ResourcePolicy
+}
+
+// NewComputeGenerator creates a new Compute Generator
+func NewComputeGenerator(baseDir string) *ComputeGenerator {
+	return &ComputeGenerator{
+		baseDir: baseDir,
+		ec2Dir:  filepath.Join(baseDir, "ec2"),
+	}
+}
+
+// GenerateLaunchTemplate generates a Crossplane LaunchTemplate resource
+func (g *ComputeGenerator) GenerateLaunchTemplate(name, imageId, instanceType, userData string) K8sObject {
+	lt := NewK8sObject("ec2.aws.crossplane.io/v1alpha1", "LaunchTemplate", name)
+
+	lt.AddNestedSpecField([]string{"forProvider", "launchTemplateName"}, name)
+
+	// Add launch template data
+	launchTemplateData := map[string]interface{}{
+		"imageId":      imageId,
+		"instanceType": instanceType,
+	}
+	if userData != "" {
+		launchTemplateData["userData"] = utils.Base64Encode(userData)
+	}
+	lt.AddNestedSpecField([]string{"forProvider", "launchTemplateData"}, launchTemplateData)
+
+	// Add provider config reference
+	lt.AddNestedSpecField([]string{"providerConfigRef", "name"}, "aws-provider")
+
+	// Add common labels
+	lt.AddLabel("app.kubernetes.io/part-of", "compute")
+	lt.AddLabel("app.kubernetes.io/component", "launch-template")
+
+	return lt
+}
+
+// GenerateAutoScalingGroup generates a Crossplane AutoScalingGroup resource
+func (g *ComputeGenerator) GenerateAutoScalingGroup(
+	name string,
+	launchTemplateName string,
+	subnetNames []string,
+	desiredCapacity int,
+	minSize int,
+	maxSize int,
+	healthCheckType string,
+	instanceRefresh map[string]interface{},
+) K8sObject {
+	asg := NewK8sObject("autoscaling.aws.crossplane.io/v1beta1", "AutoScalingGroup", name)
+
+	// Add sizing
+	asg.AddNestedSpecField([]string{"forProvider", "desiredCapacity"}, desiredCapacity)
+	asg.AddNestedSpecField([]string{"forProvider", "minSize"}, minSize)
+	asg.AddNestedSpecField([]string{"forProvider", "maxSize"}, maxSize)
+	asg.AddNestedSpecField([]string{"forProvider", "healthCheckType"}, healthCheckType)
+
+	// Reference the launch template
+	asg.AddNestedSpecField([]string{"forProvider", "launchTemplate"}, map[string]interface{}{
+		"launchTemplateName": launchTemplateName,
+		"version":            "$Latest",
+	})
+
+	// Add subnet references
+	subnetRefs := make([]map[string]string, 0, len(subnetNames))
+	for _, subnetName := range subnetNames {
+		subnetRefs = append(subnetRefs, map[string]string{"name": subnetName})
+	}
+	asg.AddNestedSpecField([]string{"forProvider", "vpcZoneIdentifierRefs"}, subnetRefs)
+
+	// Instance refresh is started by the provider when the launch template changes
+	if instanceRefresh != nil {
+		asg.AddAnnotation("iacgen.io/instance-refresh-strategy", fmt.Sprintf("%v", instanceRefresh["strategy"]))
+		asg.AddAnnotation("iacgen.io/instance-refresh-min-healthy-percentage", fmt.Sprintf("%v", instanceRefresh["min_healthy_percentage"]))
+	}
+
+	asg.AddNestedSpecField([]string{"forProvider", "tags"}, []map[string]interface{}{
+		{"key": "Name", "value": name, "propagateAtLaunch": true},
+	})
+
+	// Add provider config reference
+	asg.AddNestedSpecField([]string{"providerConfigRef", "name"}, "aws-provider")
+
+	// Add common labels
+	asg.AddLabel("app.kubernetes.io/part-of", "compute")
+	asg.AddLabel("app.kubernetes.io/component", "autoscaling-group")
+
+	return asg
+}
+
+// GenerateInstance generates a Crossplane EC2 Instance resource.
+// imageId may be an AMI ID or a "resolve:ssm:" reference to the latest AMI parameter.
+func (g *ComputeGenerator) GenerateInstance(
+	name string,
+	imageId string,
+	instanceType string,
+	subnetName string,
+	securityGroupNames []string,
+	keyName string,
+	httpTokens string,
+) K8sObject {
+	instance := NewK8sObject("ec2.aws.crossplane.io/v1alpha1", "Instance", name)
+
+	instance.AddNestedSpecField([]string{"forProvider", "imageId"}, imageId)
+	instance.AddNestedSpecField([]string{"forProvider", "instanceType"}, instanceType)
+
+	// Reference the subnet and security groups by their Crossplane names
+	if subnetName != "" {
+		instance.AddNestedSpecField([]string{"forProvider", "subnetIdRef", "name"}, subnetName)
+	}
+	if len(securityGroupNames) > 0 {
+		refs := make([]map[string]interface{}, 0, len(securityGroupNames))
+		for _, sg := range securityGroupNames {
+			refs = append(refs, map[string]interface{}{"name": sg})
+		}
+		instance.AddNestedSpecField([]string{"forProvider", "securityGroupRefs"}, refs)
+	}
+
+	if keyName != "" {
+		instance.AddNestedSpecField([]string{"forProvider", "keyName"}, keyName)
+	}
+
+	if httpTokens != "" {
+		instance.AddNestedSpecField([]string{"forProvider", "metadataOptions"}, map[string]interface{}{
+			"httpEndpoint": "enabled",
+			"httpTokens":   httpTokens,
+		})
+	}
+
+	instance.AddNestedSpecField([]string{"forProvider", "tagSpecifications"}, []map[string]interface{}{
+		{
+			"resourceType": "instance",
+			"tags": []map[string]interface{}{
+				{"key": "Name", "value": name},
+			},
+		},
+	})
+
+	// Add provider config reference
+	instance.AddNestedSpecField([]string{"providerConfigRef", "name"}, "aws-provider")
+
+	// Add common labels
+	instance.AddLabel("app.kubernetes.io/part-of", "compute")
+	instance.AddLabel("app.kubernetes.io/component", "instance")
+
+	return instance
+}
+
+// GenerateSecurityGroup generates a Crossplane SecurityGroup resource from a model security group
+func (g *ComputeGenerator) GenerateSecurityGroup(resource models.Resource) K8sObject {
+	sg := NewK8sObject("ec2.aws.crossplane.io/v1beta1", "SecurityGroup", resource.Name)
+
+	sg.AddNestedSpecField([]string{"forProvider", "groupName"}, resource.Name)
+
+	description := "Managed by iacgen"
+	if val, ok := resource.GetProperty("description"); ok {
+		if str, ok := val.(string); ok {
+			description = str
+		}
+	}
+	sg.AddNestedSpecField([]string{"forProvider", "description"}, description)
+
+	if val, ok := resource.GetProperty("vpc_id"); ok {
+		if vpcName, ok := val.(string); ok {
+			sg.AddNestedSpecField([]string{"forProvider", "vpcIdRef", "name"}, vpcName)
+		}
+	}
+
+	for _, ruleType := range []string{"ingress", "egress"} {
+		val, ok := resource.GetProperty(ruleType)
+		if !ok {
+			continue
+		}
+		rules, ok := val.([]map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		permissions := make([]map[string]interface{}, 0, len(rules))
+		for _, rule := range rules {
+			var ipRanges []map[string]interface{}
+			if cidrs, ok := rule["cidr_blocks"].([]string); ok {
+				for _, cidr := range cidrs {
+					ipRanges = append(ipRanges, map[string]interface{}{"cidrIp": cidr})
+				}
+			}
+			permission := map[string]interface{}{
+				"ipProtocol": rule["protocol"],
+				"ipRanges":   ipRanges,
+			}
+			// All-traffic rules carry no port range
+			if rule["protocol"] != "-1" {
+				permission["fromPort"] = rule["from_port"]
+				permission["toPort"] = rule["to_port"]
+			}
+			permissions = append(permissions, permission)
+		}
+		sg.AddNestedSpecField([]string{"forProvider", ruleType}, permissions)
+	}
+
+	// Add provider config reference
+	sg.AddNestedSpecField([]string{"providerConfigRef", "name"}, "aws-provider")
+
+	// Add common labels
+	sg.AddLabel("app.kubernetes.io/part-of", "compute")
+	sg.AddLabel("app.kubernetes.io/component", "security-group")
+
+	return sg
+}
+
+// GenerateComputeResources generates launch template, Auto Scaling Group and EC2 instance resources from an infrastructure model
+func (g *ComputeGenerator) GenerateComputeResources(model *models.InfrastructureModel) error {
+	var objects []K8sObject
+
+	// Security groups are generated for the instances that use them
+	instanceGroups := make(map[string]bool)
+	for _, resource := range model.Resources {
+		if resource.Type != models.ResourceEC2Instance {
+			continue
+		}
+		if val, ok := resource.GetProperty("vpc_security_group_ids"); ok {
+			if names, ok := val.([]string); ok {
+				for _, name := range names {
+					instanceGroups[name] = true
+				}
+			}
+		}
+	}
+
+	for _, resource := range model.Resources {
+		switch resource.Type {
+		case models.ResourceSecurityGroup:
+			if instanceGroups[resource.Name] {
+				objects = append(objects, g.GenerateSecurityGroup(resource))
+			}
+		case models.ResourceEC2Instance:
+			imageId, instanceType, subnetName, keyName, httpTokens, region := "", "t3.micro", "", "", "", ""
+			var securityGroupNames []string
+
+			for _, prop := range resource.Properties {
+				switch prop.Name {
+				case "ami":
+					if val, ok := prop.Value.(string); ok {
+						imageId = val
+					}
+				case "instance_type":
+					if val, ok := prop.Value.(string); ok {
+						instanceType = val
+					}
+				case "subnet_id":
+					if val, ok := prop.Value.(string); ok {
+						subnetName = val
+					}
+				case "vpc_security_group_ids":
+					if val, ok := prop.Value.([]string); ok {
+						securityGroupNames = val
+					}
+				case "key_name":
+					if val, ok := prop.Value.(string); ok {
+						keyName = val
+					}
+				case "metadata_options":
+					if val, ok := prop.Value.(map[string]interface{}); ok {
+						httpTokens, _ = val["http_tokens"].(string)
+					}
+				case "region":
+					if val, ok := prop.Value.(string); ok {
+						region = val
+					}
+				}
+			}
+
+			instance := g.GenerateInstance(
+				resource.Name,
+				imageId,
+				instanceType,
+				subnetName,
+				securityGroupNames,
+				keyName,
+				httpTokens,
+			)
+			if region != "" {
+				instance.AddNestedSpecField([]string{"forProvider", "region"}, region)
+			}
+			objects = append(objects, instance)
+		case models.ResourceLaunchTemplate:
+			imageId, instanceType, userData := "", "t3.micro", ""
+			if val, ok := resource.GetProperty("image_id"); ok {
+				imageId, _ = val.(string)
+			}
+			if val, ok := resource.GetProperty("instance_type"); ok {
+				instanceType, _ = val.(string)
+			}
+			if val, ok := resource.GetProperty("user_data"); ok {
+				userData, _ = val.(string)
+			}
+			objects = append(objects, g.GenerateLaunchTemplate(resource.Name, imageId, instanceType, userData))
+		case models.ResourceAutoScalingGroup:
+			launchTemplateName := ""
+			var subnetNames []string
+			desiredCapacity, minSize, maxSize := 2, 1, 4
+			healthCheckType := "EC2"
+			var instanceRefresh map[string]interface{}
+
+			for _, prop := range resource.Properties {
+				switch prop.Name {
+				case "launch_template":
+					if val, ok := prop.Value.(string); ok {
+						launchTemplateName = val
+					}
+				case "vpc_zone_identifier":
+					if val, ok := prop.Value.([]string); ok {
+						subnetNames = val
+					}
+				case "desired_capacity":
+					if val, ok := prop.Value.(int); ok {
+						desiredCapacity = val
+					}
+				case "min_size":
+					if val, ok := prop.Value.(int); ok {
+						minSize = val
+					}
+				case "max_size":
+					if val, ok := prop.Value.(int); ok {
+						maxSize = val
+					}
+				case "health_check_type":
+					if val, ok := prop.Value.(string); ok {
+						healthCheckType = val
+					}
+				case "instance_refresh":
+					if val, ok := prop.Value.(map[string]interface{}); ok {
+						instanceRefresh = val
+					}
+				}
+			}
+
+			objects = append(objects, g.GenerateAutoScalingGroup(
+				resource.Name,
+				launchTemplateName,
+				subnetNames,
+				desiredCapacity,
+				minSize,
+				maxSize,
+				healthCheckType,
+				instanceRefresh,
+			))
+		}
+	}
+
+	if len(objects) == 0 {
+		return nil
+	}
+
+	// Apply the tags set for every resource
+	addTags(objects, model.Tags)
+
+	// Write compute YAML
+	if err := WriteMultiYAML(g.policy.apply(providerObjects(g.provider, objects, g.region)), filepath.Join(g.ec2Dir, "compute.yaml")); err != nil {
+		return fmt.Errorf("failed to write compute YAML: %w", err)
+	}
+
+	// Register the compute resources with kustomize
+	if err := AddKustomizationResource(g.ec2Dir, "compute.yaml"); err != nil {
+		return err
+	}
+
+	return AddKustomizationResource(g.baseDir, "ec2")
+}
@@ -0,0 +1,118 @@
+package crossplane
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Modes of the connection secrets the resources write their connection details to
+const (
+	// ConnectionSecretsNone writes no connection secrets
+	ConnectionSecretsNone = "none"
+	// ConnectionSecretsResources has each EKS cluster and RDS instance write a secret of its own
+	ConnectionSecretsResources = "resources"
+	// ConnectionSecretsComposite also has the Compositions aggregate the connection details of
+	// their composed resources into a single secret of each claim
+	ConnectionSecretsComposite = "composite"
+)
+
+// ConnectionSecretModes are the modes of the connection secrets
+var ConnectionSecretModes = []string{ConnectionSecretsNone, ConnectionSecretsResources, ConnectionSecretsComposite}
+
+// DefaultConnectionSecretNamespace is the namespace of the connection secrets unless another
+// is set
+const DefaultConnectionSecretNamespace = "crossplane-system"
+
+// ValidateConnectionSecrets checks the mode of the connection secrets
+func ValidateConnectionSecrets(mode string) error {
+	for _, known := range ConnectionSecretModes {
+		if mode == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid connection secrets: %s (supported modes: %s)", mode, strings.Join(ConnectionSecretModes, ", "))
+}
+
+// WithConnectionSecrets sets the mode of the connection secrets, and the namespace the
+// resources write them to, or DefaultConnectionSecretNamespace when it is empty
+func (g *CrossplaneGenerator) WithConnectionSecrets(mode, namespace string) *CrossplaneGenerator {
+	g.connectionSecrets, g.connectionSecretNamespace = mode, namespace
+	return g
+}
+
+// secretNamespace returns the namespace the resources write their connection secrets to, or
+// none without connection secrets
+func (g *CrossplaneGenerator) secretNamespace() string {
+	if g.connectionSecrets == "" || g.connectionSecrets == ConnectionSecretsNone {
+		return ""
+	}
+	if g.connectionSecretNamespace == "" {
+		return DefaultConnectionSecretNamespace
+	}
+	return g.connectionSecretNamespace
+}
+
+// connectionSecretName returns the name of the connection secret of a resource
+func connectionSecretName(resource string) string {
+	return resource + "-connection"
+}
+
+// writeConnectionSecret has an object write its connection details to a secret of its own in
+// namespace, unless namespace is empty
+func (obj *K8sObject) writeConnectionSecret(namespace string) {
+	if namespace == "" {
+		return
+	}
+	obj.SetSpecField("writeConnectionSecretToRef", map[string]string{
+		"namespace": namespace,
+		"name":      connectionSecretName(obj.Metadata.Name),
+	})
+}
+
+// clusterConnectionKeys returns the connection details of an EKS cluster, which the
+// Upbound provider family publishes from the cluster's ClusterAuth
+func clusterConnectionKeys(provider string) []string {
+	if provider == ProviderUpbound {
+		return []string{"kubeconfig", "clusterCA"}
+	}
+	return []string{"kubeconfig", "endpoint", "clusterCA"}
+}
+
+// GenerateClusterAuth generates the ClusterAuth of an EKS cluster of the Upbound provider
+// family, which publishes the cluster's kubeconfig the Cluster does not
+func (g *EKSGenerator) GenerateClusterAuth(clusterName, region string) K8sObject {
+	auth := NewK8sObject("eks.aws.upbound.io/v1beta1", "ClusterAuth", clusterName)
+	auth.AddNestedSpecField([]string{"forProvider", "region"}, region)
+	auth.AddNestedSpecField([]string{"forProvider", "clusterNameRef", "name"}, clusterName)
+	auth.AddNestedSpecField([]string{"providerConfigRef", "name"}, "aws-provider")
+	auth.AddLabel("app.kubernetes.io/part-of", "eks")
+	auth.AddLabel("app.kubernetes.io/component", "cluster-auth")
+	return auth
+}
+
+// connectionDetails returns the connection details a composed resource passes on to its
+// composite, each under the key of the resource's connection secret
+func connectionDetails(keys []string) []map[string]string {
+	details := make([]map[string]string, 0, len(keys))
+	for _, key := range keys {
+		details = append(details, map[string]string{
+			"name":                    key,
+			"type":                    "FromConnectionSecretKey",
+			"fromConnectionSecretKey": key,
+		})
+	}
+	return details
+}
+
+// composedConnectionSecret has a composed resource write its connection details to a secret
+// named after the composite's UID, and pass them on to the composite
+func composedConnectionSecret(entry map[string]interface{}, namespace, suffix string, keys []string) {
+	base := entry["base"].(map[string]interface{})
+	base["spec"].(map[string]interface{})["writeConnectionSecretToRef"] = map[string]string{"namespace": namespace}
+	patches, _ := entry["patches"].([]map[string]interface{})
+	entry["patches"] = append(patches, fromComposite("metadata.uid", "spec.writeConnectionSecretToRef.name", map[string]interface{}{
+		"type":   "string",
+		"string": map[string]interface{}{"type": "Format", "fmt": "%s-" + suffix},
+	}))
+	entry["connectionDetails"] = connectionDetails(keys)
+}
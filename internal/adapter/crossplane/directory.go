@@ -3,6 +3,7 @@ package crossplane
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/riptano/iac_generator_cli/internal/utils"
 )
@@ -191,6 +192,75 @@ func (d *DirectoryStructure) CreateKustomizationFiles() error {
 	return nil
 }
 
+// AddKustomizationResource adds a resource entry to the kustomization.yaml in dir,
+// creating the file if it does not exist yet
+func AddKustomizationResource(dir string, resource string) error {
+	kustomizationPath := filepath.Join(dir, "kustomization.yaml")
+	
+	content := "apiVersion: kustomize.config.k8s.io/v1beta1\n" +
+		"kind: Kustomization\n\n" +
+		"resources:\n"
+	if utils.FileExists(kustomizationPath) {
+		existing, err := utils.ReadFromFile(kustomizationPath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", kustomizationPath, err)
+		}
+		
+		// Nothing to do if the resource is already listed
+		for _, line := range strings.Split(existing, "\n") {
+			if strings.TrimSpace(line) == "- "+resource {
+				return nil
+			}
+		}
+		content = existing
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+	}
+	
+	content += "- " + resource + "\n"
+	if err := utils.WriteToFile(kustomizationPath, content); err != nil {
+		return fmt.Errorf("failed to update %s: %w", kustomizationPath, err)
+	}
+	
+	return nil
+}
+
+// SetKustomizationNamespace sets the namespace the kustomization.yaml in dir transforms its
+// resources into, replacing the one it sets if any
+func SetKustomizationNamespace(dir string, namespace string) error {
+	kustomizationPath := filepath.Join(dir, "kustomization.yaml")
+	
+	existing, err := utils.ReadFromFile(kustomizationPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", kustomizationPath, err)
+	}
+	
+	// The namespace goes before the resources
+	var lines []string
+	replaced := false
+	for _, line := range strings.Split(existing, "\n") {
+		if strings.HasPrefix(line, "namespace:") {
+			replaced = true
+			continue
+		}
+		if replaced && line == "" {
+			replaced = false
+			continue
+		}
+		replaced = false
+		if line == "resources:" {
+			lines = append(lines, "namespace: "+namespace, "")
+		}
+		lines = append(lines, line)
+	}
+	if err := utils.WriteToFile(kustomizationPath, strings.Join(lines, "\n")); err != nil {
+		return fmt.Errorf("failed to update %s: %w", kustomizationPath, err)
+	}
+	
+	return nil
+}
+
 // CreateREADME creates a README.md file with documentation
 func (d *DirectoryStructure) CreateREADME() error {
 	readmeContent := "# Crossplane Infrastructure\n\n" +
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/riptano/iac_generator_cli/internal/infra"
 	"github.com/riptano/iac_generator_cli/pkg/models"
 )
 
@@ -12,6 +13,15 @@ import (
 type EKSGenerator struct {
 	baseDir string
 	eksDir  string
+	// provider is the Crossplane provider the resources are written for, and region the
+	// region of those naming none
+	provider string
+	region   string
+	// policy is the deletion and management policy of the managed resources
+	policy   ResourcePolicy
+	// connectionSecretNamespace is the namespace the cluster writes its connection details
+	// to; empty writes none
+	connectionSecretNamespace string
 }
 
 // NewEKSGenerator creates a new EKS Generator
@@ -103,9 +113,6 @@ func (g *EKSGenerator) GenerateEKSCluster(
 	cluster.AddLabel("app.kubernetes.io/part-of", "eks")
 	cluster.AddLabel("app.kubernetes.io/component", "cluster")
 	
-	// Add deletion policy to avoid accidental deletion
-	cluster.AddNestedSpecField([]string{"deletionPolicy"}, "Orphan")
-	
 	return cluster
 }
 
@@ -188,40 +195,109 @@ func (g *EKSGenerator) GenerateEKSNodeGroup(
 	return nodeGroup
 }
 
-// GenerateEKSResources generates all EKS related resources from an infrastructure model
-func (g *EKSGenerator) GenerateEKSResources(model *models.InfrastructureModel) error {
-	var (
-		eksCluster   K8sObject
-		nodeGroups   []K8sObject
-		roles        []K8sObject
-		clusterFound bool
-	)
+// GenerateEKSAddon generates a Crossplane EKS Addon resource. An empty version installs the
+// default version for the cluster, and an empty roleName leaves the add-on on the node role.
+func (g *EKSGenerator) GenerateEKSAddon(name, clusterName, addonName, version, region, roleName string) K8sObject {
+	addon := NewK8sObject("eks.aws.crossplane.io/v1alpha1", "Addon", name)
+	
+	addon.AddNestedSpecField([]string{"forProvider", "addonName"}, addonName)
+	if version != "" {
+		addon.AddNestedSpecField([]string{"forProvider", "addonVersion"}, version)
+	}
+	addon.AddNestedSpecField([]string{"forProvider", "clusterNameRef", "name"}, clusterName)
+	if region != "" {
+		addon.AddNestedSpecField([]string{"forProvider", "region"}, region)
+	}
+	addon.AddNestedSpecField([]string{"forProvider", "resolveConflicts"}, "OVERWRITE")
+	if roleName != "" {
+		addon.AddNestedSpecField([]string{"forProvider", "serviceAccountRoleARNRef", "name"}, roleName)
+	}
 	
-	// Find subnet references for EKS cluster and node groups
-	var subnetIds []string
-	for _, resource := range model.Resources {
-		if resource.Type == models.ResourceSubnet {
-			// Check if it's a private subnet - EKS nodes should be in private subnets
-			isPrivate := true
-			for _, prop := range resource.Properties {
-				if prop.Name == "map_public_ip_on_launch" {
-					if val, ok := prop.Value.(bool); ok && val {
-						isPrivate = false
-					}
-				}
-			}
-			
-			if isPrivate {
-				subnetIds = append(subnetIds, resource.Name)
-			}
-		}
+	// Add provider config reference
+	addon.AddNestedSpecField([]string{"providerConfigRef", "name"}, "aws-provider")
+	
+	// Add common labels
+	addon.AddLabel("app.kubernetes.io/part-of", "eks")
+	addon.AddLabel("app.kubernetes.io/component", "addon")
+	
+	return addon
+}
+
+// GenerateFargateProfile generates a Crossplane EKS Fargate Profile resource that runs the pods
+// of the given namespaces on Fargate
+func (g *EKSGenerator) GenerateFargateProfile(name, clusterName, podExecutionRoleName, region string, subnetIds, namespaces []string) K8sObject {
+	profile := NewK8sObject("eks.aws.crossplane.io/v1alpha1", "FargateProfile", name)
+	
+	profile.AddNestedSpecField([]string{"forProvider", "clusterNameRef", "name"}, clusterName)
+	profile.AddNestedSpecField([]string{"forProvider", "podExecutionRoleArnRef", "name"}, podExecutionRoleName)
+	if region != "" {
+		profile.AddNestedSpecField([]string{"forProvider", "region"}, region)
 	}
 	
-	// Create IAM policies and roles
+	// Add subnet references
+	subnetRefs := make([]map[string]string, 0, len(subnetIds))
+	for _, subnetId := range subnetIds {
+		subnetRefs = append(subnetRefs, map[string]string{"name": subnetId})
+	}
+	profile.AddNestedSpecField([]string{"forProvider", "subnetRefs"}, subnetRefs)
+	
+	// Select the pods of each namespace
+	selectors := make([]map[string]string, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		selectors = append(selectors, map[string]string{"namespace": namespace})
+	}
+	profile.AddNestedSpecField([]string{"forProvider", "selectors"}, selectors)
 	
+	// Add provider config reference
+	profile.AddNestedSpecField([]string{"providerConfigRef", "name"}, "aws-provider")
+	
+	// Add common labels
+	profile.AddLabel("app.kubernetes.io/part-of", "eks")
+	profile.AddLabel("app.kubernetes.io/component", "fargate-profile")
+	
+	return profile
+}
+
+// GenerateIRSARole generates the IAM role a service account assumes through the cluster's
+// OIDC provider. The provider is only known once the cluster exists, so the trust policy
+// names it with the ACCOUNT_ID and OIDC_ID placeholders, and REGION when no region is given.
+func (g *EKSGenerator) GenerateIRSARole(name, region, serviceAccount string, managedPolicyArns []string) K8sObject {
+	if region == "" {
+		region = "REGION"
+	}
+	issuer := fmt.Sprintf("oidc.eks.%s.amazonaws.com/id/OIDC_ID", region)
+	role := g.GenerateIAMRole(
+		name,
+		fmt.Sprintf(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": {
+        "Federated": "arn:aws:iam::ACCOUNT_ID:oidc-provider/%s"
+      },
+      "Action": "sts:AssumeRoleWithWebIdentity",
+      "Condition": {
+        "StringEquals": {
+          "%s:sub": "system:serviceaccount:%s",
+          "%s:aud": "sts.amazonaws.com"
+        }
+      }
+    }
+  ]
+}`, issuer, issuer, strings.Replace(serviceAccount, "/", ":", 1), issuer),
+		managedPolicyArns,
+	)
+	role.AddLabel("app.kubernetes.io/component", "irsa-role")
+	
+	return role
+}
+
+// generateClusterRoles generates the IAM roles of an EKS cluster and its node groups, with
+// the managed policies they need
+func (g *EKSGenerator) generateClusterRoles(clusterRoleName, nodeRoleName string) (clusterRole, nodeRole K8sObject) {
 	// Cluster role
-	clusterRoleName := "eks-cluster-role"
-	clusterRole := g.GenerateIAMRole(
+	clusterRole = g.GenerateIAMRole(
 		clusterRoleName,
 		`{
   "Version": "2012-10-17",
@@ -239,11 +315,9 @@ func (g *EKSGenerator) GenerateEKSResources(model *models.InfrastructureModel) e
 			"arn:aws:iam::aws:policy/AmazonEKSClusterPolicy",
 		},
 	)
-	roles = append(roles, clusterRole)
 	
 	// Node group role
-	nodeRoleName := "eks-node-role"
-	nodeRole := g.GenerateIAMRole(
+	nodeRole = g.GenerateIAMRole(
 		nodeRoleName,
 		`{
   "Version": "2012-10-17",
@@ -263,7 +337,47 @@ func (g *EKSGenerator) GenerateEKSResources(model *models.InfrastructureModel) e
 			"arn:aws:iam::aws:policy/AmazonEC2ContainerRegistryReadOnly",
 		},
 	)
-	roles = append(roles, nodeRole)
+	
+	return clusterRole, nodeRole
+}
+
+// GenerateEKSResources generates all EKS related resources from an infrastructure model
+func (g *EKSGenerator) GenerateEKSResources(model *models.InfrastructureModel) error {
+	var (
+		eksCluster   K8sObject
+		nodeGroups   []K8sObject
+		addons       []K8sObject
+		profiles     []K8sObject
+		roles        []K8sObject
+		clusterFound bool
+		// clusterVersion picks the default AMI type of the node groups
+		clusterVersion = "1.27"
+	)
+	
+	// Find subnet references for EKS cluster and node groups
+	var subnetIds []string
+	for _, resource := range model.Resources {
+		if resource.Type == models.ResourceSubnet {
+			// Check if it's a private subnet - EKS nodes should be in private subnets
+			isPrivate := true
+			for _, prop := range resource.Properties {
+				if prop.Name == "map_public_ip_on_launch" {
+					if val, ok := prop.Value.(bool); ok && val {
+						isPrivate = false
+					}
+				}
+			}
+			
+			if isPrivate {
+				subnetIds = append(subnetIds, resource.Name)
+			}
+		}
+	}
+	
+	// Create IAM policies and roles
+	clusterRoleName, nodeRoleName := "eks-cluster-role", "eks-node-role"
+	clusterRole, nodeRole := g.generateClusterRoles(clusterRoleName, nodeRoleName)
+	roles = append(roles, clusterRole, nodeRole)
 	
 	// Find the EKS cluster
 	for _, resource := range model.Resources {
@@ -275,12 +389,14 @@ func (g *EKSGenerator) GenerateEKSResources(model *models.InfrastructureModel) e
 			version := "1.27"
 			endpointPublicAccess := true
 			endpointPrivateAccess := false
+			serviceCIDR := infra.ServiceCIDR(resource)
 			
 			for _, prop := range resource.Properties {
 				switch prop.Name {
 				case "version":
 					if val, ok := prop.Value.(string); ok {
 						version = val
+						clusterVersion = val
 					}
 				case "endpoint_public_access":
 					if val, ok := prop.Value.(bool); ok {
@@ -308,6 +424,9 @@ func (g *EKSGenerator) GenerateEKSResources(model *models.InfrastructureModel) e
 					"ManagedBy":   "crossplane",
 				},
 			)
+			if serviceCIDR != "" {
+				eksCluster.AddNestedSpecField([]string{"forProvider", "kubernetesNetworkConfig", "serviceIpv4Cidr"}, serviceCIDR)
+			}
 		}
 	}
 	
@@ -340,10 +459,27 @@ func (g *EKSGenerator) GenerateEKSResources(model *models.InfrastructureModel) e
 			minSize := 2
 			maxSize := 4
 			diskSize := 20
-			amiType := "AL2_x86_64"
+			amiType := ""
+			capacityType := ""
 			
 			for _, prop := range resource.Properties {
 				switch prop.Name {
+				case "scaling_config":
+					if val, ok := prop.Value.(map[string]interface{}); ok {
+						if size, ok := val["desired_size"].(int); ok {
+							desiredSize = size
+						}
+						if size, ok := val["min_size"].(int); ok {
+							minSize = size
+						}
+						if size, ok := val["max_size"].(int); ok {
+							maxSize = size
+						}
+					}
+				case "capacity_type":
+					if val, ok := prop.Value.(string); ok {
+						capacityType = val
+					}
 				case "instance_types":
 					if val, ok := prop.Value.([]string); ok {
 						instanceTypes = val
@@ -371,6 +507,10 @@ func (g *EKSGenerator) GenerateEKSResources(model *models.InfrastructureModel) e
 				}
 			}
 			
+			if amiType == "" {
+				amiType = infra.DefaultEKSAMIType(clusterVersion, instanceTypes)
+			}
+			
 			// Create node group
 			nodeGroup := g.GenerateEKSNodeGroup(
 				name,
@@ -391,6 +531,9 @@ func (g *EKSGenerator) GenerateEKSResources(model *models.InfrastructureModel) e
 					"ManagedBy": "crossplane",
 				},
 			)
+			if capacityType != "" {
+				nodeGroup.AddNestedSpecField([]string{"forProvider", "capacityType"}, capacityType)
+			}
 			
 			nodeGroups = append(nodeGroups, nodeGroup)
 		}
@@ -421,28 +564,166 @@ func (g *EKSGenerator) GenerateEKSResources(model *models.InfrastructureModel) e
 		nodeGroups = append(nodeGroups, nodeGroup)
 	}
 	
+	// Find add-ons, with an IRSA role for each add-on that calls AWS APIs
+	for i, resource := range model.Resources {
+		if resource.Type != models.ResourceEKSAddon || eksCluster.APIVersion == "" {
+			continue
+		}
+		
+		var addonName, version, serviceAccount, policyArn string
+		for _, prop := range resource.Properties {
+			if val, ok := prop.Value.(string); ok {
+				switch prop.Name {
+				case "addon_name":
+					addonName = val
+				case "addon_version":
+					version = val
+				case "service_account":
+					serviceAccount = val
+				case "policy_arn":
+					policyArn = val
+				}
+			}
+		}
+		
+		region := model.ResourceRegion(&model.Resources[i])
+		roleName := ""
+		if serviceAccount != "" {
+			roleName = resource.Name + "-irsa"
+			roles = append(roles, g.GenerateIRSARole(roleName, region, serviceAccount, []string{policyArn}))
+		}
+		
+		addons = append(addons, g.GenerateEKSAddon(resource.Name, eksCluster.Metadata.Name, addonName, version, region, roleName))
+	}
+	
+	// Find Fargate profiles, which share one pod execution role
+	podExecutionRoleName := "eks-fargate-pod-execution-role"
+	for i, resource := range model.Resources {
+		if resource.Type != models.ResourceFargateProfile || eksCluster.APIVersion == "" {
+			continue
+		}
+		
+		var namespaces []string
+		if value, ok := resource.GetProperty("namespaces"); ok {
+			namespaces, _ = value.([]string)
+		}
+		
+		// Fargate only places pods in private subnets, which the profile names itself
+		profileSubnetIds := subnetIds
+		if value, ok := resource.GetProperty("subnet_ids"); ok {
+			if ids, ok := value.([]string); ok && len(ids) > 0 {
+				profileSubnetIds = ids
+			}
+		}
+		
+		if len(profiles) == 0 {
+			roles = append(roles, g.GenerateIAMRole(
+				podExecutionRoleName,
+				`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": {
+        "Service": "eks-fargate-pods.amazonaws.com"
+      },
+      "Action": "sts:AssumeRole"
+    }
+  ]
+}`,
+				[]string{
+					"arn:aws:iam::aws:policy/AmazonEKSFargatePodExecutionRolePolicy",
+				},
+			))
+		}
+		
+		region := model.ResourceRegion(&model.Resources[i])
+		profiles = append(profiles, g.GenerateFargateProfile(resource.Name, eksCluster.Metadata.Name, podExecutionRoleName, region, profileSubnetIds, namespaces))
+	}
+	
+	// Apply the tags set for every resource
+	addTags(roles, model.Tags)
+	eksCluster.AddTags(model.Tags)
+	addTags(nodeGroups, model.Tags)
+	addTags(addons, model.Tags)
+	addTags(profiles, model.Tags)
+	
 	// Write IAM YAML
 	if len(roles) > 0 {
 		iamFilePath := filepath.Join(g.eksDir, "iam.yaml")
-		if err := WriteMultiYAML(roles, iamFilePath); err != nil {
+		if err := WriteMultiYAML(g.policy.apply(providerObjects(g.provider, roles, g.region)), iamFilePath); err != nil {
 			return fmt.Errorf("failed to write IAM YAML: %w", err)
 		}
+		if err := AddKustomizationResource(g.eksDir, "iam.yaml"); err != nil {
+			return err
+		}
+	}
+	
+	// The cluster publishes its kubeconfig, or its ClusterAuth does with the Upbound provider
+	// family
+	var clusterAuth []K8sObject
+	if eksCluster.APIVersion != "" && g.connectionSecretNamespace != "" {
+		if g.provider == ProviderUpbound {
+			auth := g.GenerateClusterAuth(eksCluster.Metadata.Name, g.region)
+			auth.writeConnectionSecret(g.connectionSecretNamespace)
+			clusterAuth = append(clusterAuth, auth)
+		} else {
+			eksCluster.writeConnectionSecret(g.connectionSecretNamespace)
+		}
 	}
 	
 	// Write EKS Cluster YAML
 	if eksCluster.APIVersion != "" {
 		clusterFilePath := filepath.Join(g.eksDir, "cluster.yaml")
-		if err := WriteYAML(eksCluster, clusterFilePath); err != nil {
+		if err := WriteYAML(g.policy.apply(providerObjects(g.provider, []K8sObject{eksCluster}, g.region))[0], clusterFilePath); err != nil {
 			return fmt.Errorf("failed to write EKS Cluster YAML: %w", err)
 		}
+		if err := AddKustomizationResource(g.eksDir, "cluster.yaml"); err != nil {
+			return err
+		}
+	}
+	
+	// Write Cluster Auth YAML
+	if len(clusterAuth) > 0 {
+		if err := WriteMultiYAML(g.policy.apply(clusterAuth), filepath.Join(g.eksDir, "cluster-auth.yaml")); err != nil {
+			return fmt.Errorf("failed to write Cluster Auth YAML: %w", err)
+		}
+		if err := AddKustomizationResource(g.eksDir, "cluster-auth.yaml"); err != nil {
+			return err
+		}
 	}
 	
 	// Write Node Group YAML
 	if len(nodeGroups) > 0 {
 		nodeGroupFilePath := filepath.Join(g.eksDir, "nodegroup.yaml")
-		if err := WriteMultiYAML(nodeGroups, nodeGroupFilePath); err != nil {
+		if err := WriteMultiYAML(g.policy.apply(providerObjects(g.provider, nodeGroups, g.region)), nodeGroupFilePath); err != nil {
 			return fmt.Errorf("failed to write Node Group YAML: %w", err)
 		}
+		if err := AddKustomizationResource(g.eksDir, "nodegroup.yaml"); err != nil {
+			return err
+		}
+	}
+	
+	// Write Add-on YAML
+	if len(addons) > 0 {
+		addonFilePath := filepath.Join(g.eksDir, "addons.yaml")
+		if err := WriteMultiYAML(g.policy.apply(providerObjects(g.provider, addons, g.region)), addonFilePath); err != nil {
+			return fmt.Errorf("failed to write Add-on YAML: %w", err)
+		}
+		if err := AddKustomizationResource(g.eksDir, "addons.yaml"); err != nil {
+			return err
+		}
+	}
+	
+	// Write Fargate Profile YAML
+	if len(profiles) > 0 {
+		profileFilePath := filepath.Join(g.eksDir, "fargate.yaml")
+		if err := WriteMultiYAML(g.policy.apply(providerObjects(g.provider, profiles, g.region)), profileFilePath); err != nil {
+			return fmt.Errorf("failed to write Fargate Profile YAML: %w", err)
+		}
+		if err := AddKustomizationResource(g.eksDir, "fargate.yaml"); err != nil {
+			return err
+		}
 	}
 	
 	return nil
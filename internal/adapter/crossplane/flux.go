@@ -0,0 +1,115 @@
+package crossplane
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// FluxDirectory is the directory of the Flux manifests, beside the tree they deploy
+const FluxDirectory = "flux"
+
+// commitSHA matches a full Git commit SHA
+var commitSHA = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// fluxRef returns the reference of a GitRepository to a revision: a commit SHA, a full
+// reference such as refs/tags/v1.0.0, or else a branch
+func fluxRef(revision string) map[string]string {
+	switch {
+	case commitSHA.MatchString(revision):
+		return map[string]string{"commit": revision}
+	case strings.HasPrefix(revision, "refs/"):
+		return map[string]string{"name": revision}
+	}
+	return map[string]string{"branch": revision}
+}
+
+// fluxPath returns a path of the repository as Flux takes it, relative to its root
+func fluxPath(repoPath string) string {
+	if repoPath = path.Clean(repoPath); repoPath == "." {
+		return "./"
+	}
+	return "./" + repoPath
+}
+
+// deletePatch returns a patch of a Flux Kustomization leaving the objects matching target out
+// of the ones it applies
+func deletePatch(apiVersion, kind string, target map[string]string) map[string]interface{} {
+	return map[string]interface{}{
+		"patch":  fmt.Sprintf("$patch: delete\napiVersion: %s\nkind: %s\nmetadata:\n  name: unused\n", apiVersion, kind),
+		"target": target,
+	}
+}
+
+// fluxKustomization returns a Flux Kustomization applying a path of the repository from the
+// GitRepository source, waiting for the objects it applies to be ready
+func fluxKustomization(name, source, repoPath, timeout string, config GitOpsConfig) K8sObject {
+	kustomization := NewK8sObject("kustomize.toolkit.fluxcd.io/v1", "Kustomization", name)
+	kustomization.SetNamespace(config.Namespace)
+	kustomization.SetSpecField("interval", config.Interval)
+	kustomization.SetSpecField("retryInterval", "1m")
+	kustomization.SetSpecField("timeout", timeout)
+	kustomization.SetSpecField("sourceRef", map[string]string{"kind": "GitRepository", "name": source})
+	kustomization.SetSpecField("path", fluxPath(repoPath))
+	kustomization.SetSpecField("prune", config.SyncPolicy == SyncAutoPrune)
+	kustomization.SetSpecField("wait", true)
+	// Flux has no manual sync: a suspended Kustomization is applied with flux resume
+	if config.SyncPolicy == SyncManual {
+		kustomization.SetSpecField("suspend", true)
+	}
+	return kustomization
+}
+
+// FluxManifests returns the Flux objects deploying the kustomize tree at a path of the
+// repository: a GitRepository, a Kustomization installing the Crossplane providers and waiting
+// for them to be healthy, and one applying the rest of the tree once the providers' APIs exist
+func FluxManifests(repoPath string, config GitOpsConfig) []K8sObject {
+	name := gitOpsName(repoPath)
+
+	source := NewK8sObject("source.toolkit.fluxcd.io/v1", "GitRepository", name)
+	source.SetNamespace(config.Namespace)
+	source.SetSpecField("interval", "1m")
+	source.SetSpecField("url", config.RepoURL)
+	source.SetSpecField("ref", fluxRef(config.Revision))
+
+	// The ProviderConfigs are objects of the providers' APIs, so they wait for them
+	providers := fluxKustomization(name+"-providers", name, path.Join(repoPath, "base"), "10m", config)
+	providers.SetSpecField("patches", []map[string]interface{}{
+		deletePatch("aws.crossplane.io/v1beta1", "ProviderConfig", map[string]string{"kind": "ProviderConfig"}),
+	})
+	providers.SetSpecField("healthCheckExprs", []map[string]string{{
+		"apiVersion": "pkg.crossplane.io/v1",
+		"kind":       "Provider",
+		"current":    "status.conditions.exists(e, e.type == 'Healthy' && e.status == 'True')",
+	}})
+
+	// The managed resources are ready once AWS has created them, which takes a while for an
+	// EKS cluster
+	resources := fluxKustomization(name, name, repoPath, "30m", config)
+	resources.SetSpecField("dependsOn", []map[string]string{{"name": name + "-providers"}})
+	// The packages, their runtime configuration and credentials belong to the first Kustomization
+	resources.SetSpecField("patches", []map[string]interface{}{
+		deletePatch("pkg.crossplane.io/v1", "Provider", map[string]string{"group": "pkg.crossplane.io"}),
+		deletePatch("v1", "Secret", map[string]string{"kind": "Secret", "name": "aws-credentials"}),
+	})
+
+	return []K8sObject{source, providers, resources}
+}
+
+// WriteFluxManifests writes the Flux objects deploying the Crossplane output in dir, at
+// repoPath in the repository, to dir/flux/sync.yaml. The root kustomization leaves the
+// directory out, so Flux does not apply its own objects.
+func WriteFluxManifests(dir, repoPath string, config GitOpsConfig) error {
+	fluxDir := filepath.Join(dir, FluxDirectory)
+	if err := utils.EnsureDirectoryExists(fluxDir); err != nil {
+		return fmt.Errorf("failed to create Flux directory: %w", err)
+	}
+	if err := WriteMultiYAML(FluxManifests(repoPath, config), filepath.Join(fluxDir, "sync.yaml")); err != nil {
+		return fmt.Errorf("failed to write Flux manifests: %w", err)
+	}
+	return nil
+}
@@ -17,7 +17,25 @@ type CrossplaneGenerator struct {
 	dirStructure *DirectoryStructure
 	vpcGenerator *VPCGenerator
 	eksGenerator *EKSGenerator
+	computeGenerator *ComputeGenerator
+	rdsGenerator *RDSGenerator
 	provGenerator *ProviderGenerator
+	// provider is the Crossplane provider the managed resources are written for
+	provider string
+	// credentials is the source of the providers' AWS credentials, and roleARN the IAM role
+	// they assume with IRSA
+	credentials string
+	roleARN     string
+	// connectionSecrets is the mode of the connection secrets, and connectionSecretNamespace
+	// the namespace they are written to
+	connectionSecrets         string
+	connectionSecretNamespace string
+	// policy is the deletion and management policy of the managed resources
+	policy ResourcePolicy
+	// compositions writes Compositions of the network and the EKS cluster, with example claims
+	compositions bool
+	// team is the team owning the claims, whose namespace and RBAC are written to team/
+	team string
 }
 
 // NewCrossplaneGenerator creates a new CrossplaneGenerator
@@ -25,6 +43,20 @@ func NewCrossplaneGenerator() *CrossplaneGenerator {
 	return &CrossplaneGenerator{}
 }
 
+// WithProvider sets the Crossplane provider the managed resources are written for:
+// ProviderContrib (the default) or ProviderUpbound
+func (g *CrossplaneGenerator) WithProvider(provider string) *CrossplaneGenerator {
+	g.provider = provider
+	return g
+}
+
+// WithCredentials sets the source of the providers' AWS credentials: CredentialsSecret (the
+// default) or CredentialsIRSA, assuming roleARN, or a placeholder when it is empty
+func (g *CrossplaneGenerator) WithCredentials(source, roleARN string) *CrossplaneGenerator {
+	g.credentials, g.roleARN = source, roleARN
+	return g
+}
+
 // Init initializes the generator with a base directory
 func (g *CrossplaneGenerator) Init(baseDir string) error {
 	return g.SetOutputDir(baseDir)
@@ -36,6 +68,8 @@ func (g *CrossplaneGenerator) SetOutputDir(baseDir string) error {
 	g.dirStructure = NewDirectoryStructure(baseDir)
 	g.vpcGenerator = NewVPCGenerator(baseDir)
 	g.eksGenerator = NewEKSGenerator(baseDir)
+	g.computeGenerator = NewComputeGenerator(baseDir)
+	g.rdsGenerator = NewRDSGenerator(baseDir)
 	g.provGenerator = NewProviderGenerator(baseDir)
 	
 	// Create the directory structure
@@ -63,6 +97,12 @@ func (g *CrossplaneGenerator) SetOutputDir(baseDir string) error {
 
 // Generate generates Crossplane YAML from an infrastructure model
 func (g *CrossplaneGenerator) Generate(model *models.InfrastructureModel) (string, error) {
+	sorted, sortErr := model.Sorted()
+	if sortErr != nil {
+		return "", sortErr
+	}
+	model = sorted
+	
 	// If baseDir is not set, use a temporary directory
 	if g.baseDir == "" {
 		tempDir, err := os.MkdirTemp("", "crossplane-")
@@ -74,23 +114,42 @@ func (g *CrossplaneGenerator) Generate(model *models.InfrastructureModel) (strin
 		}
 	}
 	
-	// Extract region from the model
-	region := "us-east-1" // Default region
-	for _, resource := range model.Resources {
-		for _, prop := range resource.Properties {
-			if strings.Contains(strings.ToLower(prop.Name), "region") {
-				if regionStr, ok := prop.Value.(string); ok {
-					region = regionStr
+	// Extract region from the model. Models built by hand may only name it in resource
+	// properties.
+	region := model.Region
+	if region == "" {
+		region = "us-east-1" // Default region
+		for _, resource := range model.Resources {
+			for _, prop := range resource.Properties {
+				if strings.Contains(strings.ToLower(prop.Name), "region") {
+					if regionStr, ok := prop.Value.(string); ok {
+						region = regionStr
+					}
 				}
 			}
 		}
 	}
 	
+	// Write the managed resources for the selected provider
+	g.provGenerator.provider = g.provider
+	g.provGenerator.credentials, g.provGenerator.roleARN = g.credentials, g.roleARN
+	g.vpcGenerator.provider, g.vpcGenerator.region = g.provider, region
+	g.eksGenerator.provider, g.eksGenerator.region = g.provider, region
+	g.computeGenerator.provider, g.computeGenerator.region = g.provider, region
+	g.rdsGenerator.provider, g.rdsGenerator.region = g.provider, region
+	g.vpcGenerator.policy, g.eksGenerator.policy = g.policy, g.policy
+	g.computeGenerator.policy, g.rdsGenerator.policy = g.policy, g.policy
+	g.eksGenerator.connectionSecretNamespace = g.secretNamespace()
+	g.rdsGenerator.connectionSecretNamespace = g.secretNamespace()
+	
 	// Generate the provider configuration
 	// Use empty strings for access and secret keys - they would be provided by the user in a real scenario
 	if err := g.provGenerator.GenerateCommonResources(region, "", ""); err != nil {
 		return "", fmt.Errorf("failed to generate provider configuration: %w", err)
 	}
+	if err := g.provGenerator.GenerateRegionalProviderFiles(region, model.SecondaryRegions()); err != nil {
+		return "", fmt.Errorf("failed to generate regional provider configuration: %w", err)
+	}
 	
 	// Generate VPC resources
 	if err := g.vpcGenerator.GenerateNetworkResources(model); err != nil {
@@ -102,6 +161,30 @@ func (g *CrossplaneGenerator) Generate(model *models.InfrastructureModel) (strin
 		return "", fmt.Errorf("failed to generate EKS resources: %w", err)
 	}
 	
+	// Generate launch template and Auto Scaling Group resources
+	if err := g.computeGenerator.GenerateComputeResources(model); err != nil {
+		return "", fmt.Errorf("failed to generate compute resources: %w", err)
+	}
+	
+	// Generate RDS instances
+	if err := g.rdsGenerator.GenerateRDSResources(model); err != nil {
+		return "", fmt.Errorf("failed to generate RDS resources: %w", err)
+	}
+	
+	// Generate the Compositions and their example claims
+	if g.compositions {
+		if err := g.generateCompositions(model, region); err != nil {
+			return "", fmt.Errorf("failed to generate compositions: %w", err)
+		}
+		if g.team != "" {
+			if err := g.generateTeam(); err != nil {
+				return "", fmt.Errorf("failed to generate team namespace: %w", err)
+			}
+		}
+	}
+	
+	warnUnmatchedImports(g.baseDir, g.policy.Imports)
+	
 	// Return a summary of the generated resources
 	summary, err := g.generateSummary()
 	if err != nil {
@@ -181,6 +264,28 @@ func (g *CrossplaneGenerator) generateSummary() (string, error) {
 	}
 	summary.WriteString("\n")
 	
+	// List compute resources
+	computePath := filepath.Join(g.baseDir, "ec2", "compute.yaml")
+	if utils.FileExists(computePath) {
+		content, err := utils.ReadFromFile(computePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read compute.yaml: %w", err)
+		}
+		summary.WriteString("## Compute Resources\n\n")
+		summary.WriteString(fmt.Sprintf("- compute: %d resources\n\n", strings.Count(content, "kind:")))
+	}
+	
+	// List RDS resources
+	rdsPath := filepath.Join(g.baseDir, "rds", "instances.yaml")
+	if utils.FileExists(rdsPath) {
+		content, err := utils.ReadFromFile(rdsPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read instances.yaml: %w", err)
+		}
+		summary.WriteString("## RDS Resources\n\n")
+		summary.WriteString(fmt.Sprintf("- instances: %d resources\n\n", strings.Count(content, "kind:")))
+	}
+	
 	// Add usage instructions
 	summary.WriteString("## Usage Instructions\n\n")
 	summary.WriteString("To apply these resources to your Kubernetes cluster with Crossplane installed:\n\n")
@@ -194,5 +299,18 @@ func (g *CrossplaneGenerator) generateSummary() (string, error) {
 	summary.WriteString(fmt.Sprintf("kubectl apply -k %s/eks   # EKS resources\n", g.baseDir))
 	summary.WriteString("```\n")
 	
+	// The claims need the APIs the compositions define
+	if g.compositions {
+		summary.WriteString("\n## Compositions\n\n")
+		summary.WriteString("To let teams request the network and the cluster with claims:\n\n")
+		summary.WriteString("```bash\n")
+		summary.WriteString(fmt.Sprintf("kubectl apply -k %s/compositions  # Definitions and Compositions\n", g.baseDir))
+		if g.team != "" {
+			summary.WriteString(fmt.Sprintf("kubectl apply -k %s/team          # Namespace and RBAC of the team\n", g.baseDir))
+		}
+		summary.WriteString(fmt.Sprintf("kubectl apply -k %s/claims        # Example claims\n", g.baseDir))
+		summary.WriteString("```\n")
+	}
+	
 	return summary.String(), nil
 }
\ No newline at end of file
@@ -47,6 +47,12 @@ func (g *TemplateCrossplaneGenerator) Init(baseDir string) error {
 
 // Generate generates Crossplane YAML from an infrastructure model
 func (g *TemplateCrossplaneGenerator) Generate(model *models.InfrastructureModel) (string, error) {
+	sorted, sortErr := model.Sorted()
+	if sortErr != nil {
+		return "", sortErr
+	}
+	model = sorted
+	
 	// If baseDir is not set, use a temporary directory
 	if g.baseDir == "" {
 		tempDir, err := os.MkdirTemp("", "crossplane-")
@@ -58,20 +64,25 @@ func (g *TemplateCrossplaneGenerator) Generate(model *models.InfrastructureModel
 		}
 	}
 
-	// Extract region from the model
-	awsRegion := "us-east-1" // Default region
-	for _, resource := range model.Resources {
-		for _, prop := range resource.Properties {
-			if strings.Contains(strings.ToLower(prop.Name), "region") {
-				if regionStr, ok := prop.Value.(string); ok {
-					awsRegion = regionStr
+	// Extract region from the model. Models built by hand may only name it in resource
+	// properties.
+	awsRegion := model.Region
+	if awsRegion == "" {
+		awsRegion = "us-east-1" // Default region
+		for _, resource := range model.Resources {
+			for _, prop := range resource.Properties {
+				if strings.Contains(strings.ToLower(prop.Name), "region") {
+					if regionStr, ok := prop.Value.(string); ok {
+						awsRegion = regionStr
+					}
 				}
 			}
 		}
 	}
 	
-	// Set the region in the template context
+	// Set the region and the tags for every resource in the template context
 	g.renderer.SetGlobalContext("region", awsRegion)
+	g.renderer.SetGlobalContext("tags", model.Tags)
 
 	// Group resources by type for organization
 	vpcResources := []models.Resource{}
@@ -82,7 +93,7 @@ func (g *TemplateCrossplaneGenerator) Generate(model *models.InfrastructureModel
 		switch resource.Type {
 		case models.ResourceVPC, models.ResourceSubnet, models.ResourceIGW, models.ResourceNATGateway:
 			vpcResources = append(vpcResources, resource)
-		case models.ResourceEKSCluster, models.ResourceNodeGroup:
+		case models.ResourceEKSCluster, models.ResourceNodeGroup, models.ResourceEKSAddon, models.ResourceFargateProfile:
 			eksResources = append(eksResources, resource)
 		default:
 			otherResources = append(otherResources, resource)
@@ -203,6 +214,23 @@ spec:
       name: aws-creds
       key: credentials
 `
+	// Resources outside the primary region use a ProviderConfig of their own
+	for _, region := range model.SecondaryRegions() {
+		providerContent += `---
+apiVersion: aws.crossplane.io/v1beta1
+kind: ProviderConfig
+metadata:
+  name: ` + regionalProviderConfigName(region) + `
+spec:
+  credentials:
+    source: Secret
+    secretRef:
+      namespace: crossplane-system
+      name: aws-creds
+      key: credentials
+  region: ` + region + `
+`
+	}
 	if err := utils.WriteToFile(filepath.Join(baseDir, "aws-provider.yaml"), providerContent); err != nil {
 		return "", fmt.Errorf("failed to write aws-provider.yaml: %w", err)
 	}
@@ -0,0 +1,109 @@
+package crossplane
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// GitOps tools the Crossplane output can be deployed with
+const (
+	// GitOpsArgoCD deploys the output with Argo CD Applications
+	GitOpsArgoCD = "argocd"
+	// GitOpsFlux deploys the output with a Flux GitRepository and Kustomizations
+	GitOpsFlux = "flux"
+)
+
+// GitOpsTools are the GitOps tools the Crossplane output can be deployed with
+var GitOpsTools = []string{GitOpsArgoCD, GitOpsFlux}
+
+// Sync policies of the GitOps manifests
+const (
+	// SyncManual syncs the output when asked to
+	SyncManual = "manual"
+	// SyncAuto syncs the output on each change of the repository, and reverts changes made to
+	// its resources in the cluster
+	SyncAuto = "auto"
+	// SyncAutoPrune also deletes the resources removed from the repository
+	SyncAutoPrune = "auto-prune"
+)
+
+// SyncPolicies are the sync policies of the GitOps manifests
+var SyncPolicies = []string{SyncManual, SyncAuto, SyncAutoPrune}
+
+// GitOpsConfig configures the GitOps manifests deploying the Crossplane output
+type GitOpsConfig struct {
+	// RepoURL is the Git repository holding the output, and Revision the branch, tag or
+	// commit of it to deploy
+	RepoURL  string
+	Revision string
+	// Path is the path of the output directory in the repository
+	Path string
+	// Namespace is the namespace of the GitOps tool, and DestinationNamespace the namespace of
+	// the output's namespaced resources that name none (Argo CD only)
+	Namespace            string
+	DestinationNamespace string
+	// SyncPolicy is manual, auto or auto-prune
+	SyncPolicy string
+	// Interval is how often Flux reconciles the output, such as 10m
+	Interval string
+}
+
+// DefaultGitOpsConfig returns the default settings of the manifests of a GitOps tool. The
+// repository is a placeholder to replace with the one the output is pushed to. Argo CD syncs
+// when asked to, and Flux, which has no manual sync, prunes.
+func DefaultGitOpsConfig(tool string) GitOpsConfig {
+	config := GitOpsConfig{
+		RepoURL:              "https://github.com/ORG/REPO.git",
+		Revision:             "HEAD",
+		Namespace:            "argocd",
+		DestinationNamespace: "crossplane-system",
+		SyncPolicy:           SyncManual,
+		Interval:             "10m",
+	}
+	if strings.EqualFold(tool, GitOpsFlux) {
+		config.Revision = "main"
+		config.Namespace = "flux-system"
+		config.SyncPolicy = SyncAutoPrune
+	}
+	return config
+}
+
+// ValidateGitOps checks a GitOps tool and the settings of its manifests
+func ValidateGitOps(tool string, config GitOpsConfig) error {
+	if tool != GitOpsArgoCD && tool != GitOpsFlux {
+		return fmt.Errorf("invalid GitOps tool: %s (supported tools: %s)", tool, strings.Join(GitOpsTools, ", "))
+	}
+	if interval, err := time.ParseDuration(config.Interval); err != nil || interval <= 0 {
+		return fmt.Errorf("invalid GitOps interval: %s (use a duration such as 10m)", config.Interval)
+	}
+	for _, policy := range SyncPolicies {
+		if config.SyncPolicy == policy {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid GitOps sync policy: %s (supported policies: %s)", config.SyncPolicy, strings.Join(SyncPolicies, ", "))
+}
+
+// WriteGitOps writes the manifests of a GitOps tool deploying the Crossplane output in dir, at
+// repoPath in the repository
+func WriteGitOps(tool, dir, repoPath string, config GitOpsConfig) error {
+	if strings.EqualFold(tool, GitOpsFlux) {
+		return WriteFluxManifests(dir, repoPath, config)
+	}
+	return WriteArgoCDApplication(dir, repoPath, config)
+}
+
+// invalidNameCharacters matches the runs of characters an object name cannot have
+var invalidNameCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+
+// gitOpsName returns the name of the objects deploying a path of the repository, such as
+// infra-crossplane for infra/crossplane
+func gitOpsName(repoPath string) string {
+	name := strings.Trim(invalidNameCharacters.ReplaceAllString(strings.ToLower(repoPath), "-"), "-")
+	if name == "" {
+		return "infrastructure"
+	}
+	return name
+}
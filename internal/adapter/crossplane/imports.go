@@ -0,0 +1,128 @@
+package crossplane
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// externalNameAnnotation is the annotation naming the external resource of a managed resource
+const externalNameAnnotation = "crossplane.io/external-name"
+
+// importKeyPattern matches the key of an import, the kind and name of a managed resource as
+// written, such as VPC/main-vpc
+var importKeyPattern = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*/[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+
+// LoadImportMap reads a YAML file mapping the kinds and names of generated managed resources
+// to the IDs of the existing AWS resources they adopt:
+//
+//	imports:
+//	  VPC/main-vpc: vpc-0123456789abcdef0
+//	  Cluster/main-eks: main-eks
+func LoadImportMap(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Crossplane import map: %w", err)
+	}
+
+	var file struct {
+		Imports map[string]string `yaml:"imports"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse Crossplane import map %s: %w", path, err)
+	}
+	if len(file.Imports) == 0 {
+		return nil, fmt.Errorf("no imports in %s", path)
+	}
+	if err := ValidateImports(file.Imports); err != nil {
+		return nil, fmt.Errorf("invalid Crossplane import map %s: %w", path, err)
+	}
+	return file.Imports, nil
+}
+
+// ValidateImports checks the resource keys and IDs of an import map
+func ValidateImports(imports map[string]string) error {
+	for _, key := range utils.SortedKeys(imports) {
+		if !importKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid managed resource: %q (use its kind and name, such as VPC/main-vpc)", key)
+		}
+		if strings.TrimSpace(imports[key]) == "" {
+			return fmt.Errorf("the import of %s has no ID", key)
+		}
+	}
+	return nil
+}
+
+// WithImports sets the existing AWS resources the managed resources adopt, by the kind and
+// name of the managed resource as written. Each is given the ID as its external name and only
+// observes the resource, until its management policy is widened.
+func (g *CrossplaneGenerator) WithImports(imports map[string]string) *CrossplaneGenerator {
+	g.policy.Imports = imports
+	return g
+}
+
+// importKey returns the key of an object in an import map
+func importKey(obj K8sObject) string {
+	return obj.Kind + "/" + obj.Metadata.Name
+}
+
+// adopt has an object adopt the existing resource of an import map, if it lists one
+func (p ResourcePolicy) adopt(obj *K8sObject) {
+	id, ok := p.Imports[importKey(*obj)]
+	if !ok {
+		return
+	}
+	obj.AddAnnotation(externalNameAnnotation, id)
+	obj.SetSpecField("managementPolicies", []string{"Observe"})
+}
+
+// warnUnmatchedImports logs the imports of resources the output in dir does not write, which
+// would otherwise be silently ignored
+func warnUnmatchedImports(dir string, imports map[string]string) {
+	if len(imports) == 0 {
+		return
+	}
+	written := writtenObjects(dir)
+	for _, key := range utils.SortedKeys(imports) {
+		if !written[key] {
+			utils.GetLogger().Warnw("Imported resource is not written by the generated manifests", "resource", key)
+		}
+	}
+}
+
+// writtenObjects returns the keys of the objects of the YAML files under dir
+func writtenObjects(dir string) map[string]bool {
+	written := make(map[string]bool)
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".yaml" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		for {
+			var obj K8sObject
+			if err := decoder.Decode(&obj); err != nil {
+				if !errors.Is(err, io.EOF) {
+					utils.GetLogger().Debugw("Failed to parse generated manifest", "file", path, "error", err)
+				}
+				break
+			}
+			written[importKey(obj)] = true
+		}
+		return nil
+	})
+	return written
+}
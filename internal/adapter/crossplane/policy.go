@@ -0,0 +1,90 @@
+package crossplane
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Deletion policies of the managed resources, deciding what becomes of the external resource
+// when its managed resource is deleted
+const (
+	// DeletionOrphan keeps the external resource
+	DeletionOrphan = "orphan"
+	// DeletionDelete deletes the external resource with its managed resource
+	DeletionDelete = "delete"
+)
+
+// DeletionPolicies are the deletion policies of the managed resources
+var DeletionPolicies = []string{DeletionOrphan, DeletionDelete}
+
+// Management policies of the managed resources, deciding which actions Crossplane takes on
+// the external resource
+const (
+	// ManagementFull has Crossplane create, update and delete the external resource
+	ManagementFull = "full"
+	// ManagementObserve has Crossplane only observe an existing external resource, reporting
+	// its state without changing it
+	ManagementObserve = "observe"
+)
+
+// ManagementPolicies are the management policies of the managed resources
+var ManagementPolicies = []string{ManagementFull, ManagementObserve}
+
+// ValidateDeletionPolicy checks the deletion policy of the managed resources
+func ValidateDeletionPolicy(policy string) error {
+	for _, known := range DeletionPolicies {
+		if policy == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid deletion policy: %s (supported policies: %s)", policy, strings.Join(DeletionPolicies, ", "))
+}
+
+// ValidateManagementPolicy checks the management policy of the managed resources
+func ValidateManagementPolicy(policy string) error {
+	for _, known := range ManagementPolicies {
+		if policy == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid management policy: %s (supported policies: %s)", policy, strings.Join(ManagementPolicies, ", "))
+}
+
+// ResourcePolicy is the deletion and management policy of every managed resource written,
+// and the existing resources some of them adopt. The zero value orphans the external
+// resources and manages them fully.
+type ResourcePolicy struct {
+	Deletion   string
+	Management string
+	// Imports maps the kinds and names of managed resources to the IDs of the existing AWS
+	// resources they adopt
+	Imports map[string]string
+}
+
+// WithResourcePolicy sets the deletion policy of the managed resources, DeletionOrphan (the
+// default) or DeletionDelete, and their management policy, ManagementFull (the default) or
+// ManagementObserve
+func (g *CrossplaneGenerator) WithResourcePolicy(deletion, management string) *CrossplaneGenerator {
+	g.policy.Deletion, g.policy.Management = deletion, management
+	return g
+}
+
+// apply sets the policy on the managed resources of the objects, those with parameters for
+// their provider, and returns them. Provider configurations and secrets are kept as they are.
+func (p ResourcePolicy) apply(objects []K8sObject) []K8sObject {
+	deletionPolicy := "Orphan"
+	if p.Deletion == DeletionDelete {
+		deletionPolicy = "Delete"
+	}
+	for i := range objects {
+		if _, ok := objects[i].Spec["forProvider"]; !ok {
+			continue
+		}
+		objects[i].SetSpecField("deletionPolicy", deletionPolicy)
+		if p.Management == ManagementObserve {
+			objects[i].SetSpecField("managementPolicies", []string{"Observe"})
+		}
+		p.adopt(&objects[i])
+	}
+	return objects
+}
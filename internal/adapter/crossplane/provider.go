@@ -3,14 +3,46 @@ package crossplane
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 
+	"github.com/riptano/iac_generator_cli/internal/template"
 	"github.com/riptano/iac_generator_cli/internal/utils"
 )
 
+// Sources of the AWS credentials of the providers
+const (
+	// CredentialsSecret reads the credentials from the aws-credentials Secret
+	CredentialsSecret = "secret"
+	// CredentialsIRSA assumes an IAM role through the providers' service account, on EKS
+	CredentialsIRSA = "irsa"
+)
+
+// CredentialSources are the sources the providers can take their AWS credentials from
+var CredentialSources = []string{CredentialsSecret, CredentialsIRSA}
+
+// ValidateCredentials checks the source of the providers' AWS credentials
+func ValidateCredentials(source string) error {
+	for _, known := range CredentialSources {
+		if source == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid Crossplane credentials: %s (supported sources: %s)", source, strings.Join(CredentialSources, ", "))
+}
+
+// defaultProviderRoleARN is a placeholder for the IAM role the providers assume with IRSA
+const defaultProviderRoleARN = "arn:aws:iam::ACCOUNT_ID:role/crossplane-provider-aws"
+
 // ProviderGenerator generates Crossplane AWS Provider configuration
 type ProviderGenerator struct {
 	baseDir  string
 	commonDir string
+	// provider is the Crossplane provider the configuration is for
+	provider string
+	// credentials is the source of the providers' AWS credentials, and roleARN the IAM role
+	// they assume with IRSA
+	credentials string
+	roleARN     string
 }
 
 // NewProviderGenerator creates a new Provider Generator
@@ -23,7 +55,8 @@ func NewProviderGenerator(baseDir string) *ProviderGenerator {
 
 // GenerateProviderPackage generates a Crossplane Provider Package
 func (g *ProviderGenerator) GenerateProviderPackage(name, version string) K8sObject {
-	provider := NewK8sObject("pkg.crossplane.io/v1", "Provider", name)
+	// Package names such as crossplane/provider-aws are not valid object names
+	provider := NewK8sObject("pkg.crossplane.io/v1", "Provider", strings.ReplaceAll(name, "/", "-"))
 	
 	// Set provider package details
 	provider.AddNestedSpecField([]string{"package"}, fmt.Sprintf("%s:%s", name, version))
@@ -32,21 +65,53 @@ func (g *ProviderGenerator) GenerateProviderPackage(name, version string) K8sObj
 	return provider
 }
 
-// GenerateProviderConfig generates a Crossplane ProviderConfig for AWS
+// GenerateUpboundProviderPackages generates the Providers of the Upbound AWS provider family
+// serving the generated resources, each running with the aws-config runtime configuration
+func (g *ProviderGenerator) GenerateUpboundProviderPackages() []K8sObject {
+	providers := make([]K8sObject, 0, len(upboundProviderPackages))
+	for _, name := range upboundProviderPackages {
+		provider := NewK8sObject("pkg.crossplane.io/v1", "Provider", "upbound-"+name)
+		provider.AddNestedSpecField([]string{"package"}, fmt.Sprintf("xpkg.upbound.io/upbound/%s:%s", name, upboundProviderVersion))
+		provider.AddNestedSpecField([]string{"runtimeConfigRef", "name"}, "aws-config")
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
+// GenerateProviderConfig generates a Crossplane ProviderConfig for AWS. The resources of the
+// Upbound provider family name their region themselves, so its ProviderConfig has none. With
+// IRSA, the providers take the credentials of the role their service account assumes.
 func (g *ProviderGenerator) GenerateProviderConfig(region string) K8sObject {
 	if region == "" {
 		region = "us-east-1"
 	}
 	
+	if g.provider == ProviderUpbound {
+		config := NewK8sObject("aws.upbound.io/v1beta1", "ProviderConfig", "aws-provider")
+		if g.credentials == CredentialsIRSA {
+			config.AddNestedSpecField([]string{"credentials", "source"}, "IRSA")
+			return config
+		}
+		config.AddNestedSpecField([]string{"credentials", "source"}, "Secret")
+		config.AddNestedSpecField([]string{"credentials", "secretRef", "namespace"}, "crossplane-system")
+		config.AddNestedSpecField([]string{"credentials", "secretRef", "name"}, "aws-credentials")
+		config.AddNestedSpecField([]string{"credentials", "secretRef", "key"}, "creds")
+		return config
+	}
+	
 	config := NewK8sObject("aws.crossplane.io/v1beta1", "ProviderConfig", "aws-provider")
 	
 	// Set credentials source
-	config.AddNestedSpecField([]string{"credentials", "source"}, "Secret")
-	
-	// Set secret reference
-	config.AddNestedSpecField([]string{"credentials", "secretRef", "namespace"}, "crossplane-system")
-	config.AddNestedSpecField([]string{"credentials", "secretRef", "name"}, "aws-credentials")
-	config.AddNestedSpecField([]string{"credentials", "secretRef", "key"}, "creds")
+	if g.credentials == CredentialsIRSA {
+		config.AddNestedSpecField([]string{"credentials", "source"}, "InjectedIdentity")
+	} else {
+		config.AddNestedSpecField([]string{"credentials", "source"}, "Secret")
+		
+		// Set secret reference
+		config.AddNestedSpecField([]string{"credentials", "secretRef", "namespace"}, "crossplane-system")
+		config.AddNestedSpecField([]string{"credentials", "secretRef", "name"}, "aws-credentials")
+		config.AddNestedSpecField([]string{"credentials", "secretRef", "key"}, "creds")
+	}
 	
 	// Set region
 	config.AddNestedSpecField([]string{"region"}, region)
@@ -54,6 +119,40 @@ func (g *ProviderGenerator) GenerateProviderConfig(region string) K8sObject {
 	return config
 }
 
+// regionalProviderConfigName returns the name of the ProviderConfig used by resources in a
+// region other than the primary one
+func regionalProviderConfigName(region string) string {
+	return template.ProviderConfigNameFunc("aws-provider", region)
+}
+
+// GenerateRegionalProviderConfig generates the ProviderConfig for a secondary region
+func (g *ProviderGenerator) GenerateRegionalProviderConfig(region string) K8sObject {
+	config := g.GenerateProviderConfig(region)
+	config.Metadata.Name = regionalProviderConfigName(region)
+	return config
+}
+
+// GenerateRegionalProviderFiles writes the ProviderConfig of each secondary region to
+// providerconfig.yaml, after the primary region's. Nothing is written for a single region,
+// nor for the Upbound provider family, whose resources name their region.
+func (g *ProviderGenerator) GenerateRegionalProviderFiles(region string, secondaryRegions []string) error {
+	if len(secondaryRegions) == 0 || g.provider == ProviderUpbound {
+		return nil
+	}
+	
+	configs := []K8sObject{g.GenerateProviderConfig(region)}
+	for _, secondary := range secondaryRegions {
+		configs = append(configs, g.GenerateRegionalProviderConfig(secondary))
+	}
+	
+	configPath := filepath.Join(g.commonDir, "providerconfig.yaml")
+	if err := WriteMultiYAML(configs, configPath); err != nil {
+		return fmt.Errorf("failed to write provider config file: %w", err)
+	}
+	
+	return nil
+}
+
 // GenerateCommonResources generates common Crossplane resources
 func (g *ProviderGenerator) GenerateCommonResources(region, accessKey, secretKey string) error {
 	return g.GenerateAllProviderFiles(region, accessKey, secretKey)
@@ -102,53 +201,95 @@ func (g *ProviderGenerator) GenerateAwsSecret(accessKey, secretKey string) K8sOb
 	return secret
 }
 
-// GenerateControllerConfig generates a ControllerConfig for AWS provider
+// providerRoleARN returns the IAM role the providers assume with IRSA
+func (g *ProviderGenerator) providerRoleARN() string {
+	if g.roleARN == "" {
+		return defaultProviderRoleARN
+	}
+	return g.roleARN
+}
+
+// GenerateControllerConfig generates a ControllerConfig for AWS provider. With IRSA, its
+// annotations are set on the provider's service account, which assumes the role they name.
 func (g *ProviderGenerator) GenerateControllerConfig() K8sObject {
 	config := NewK8sObject("pkg.crossplane.io/v1alpha1", "ControllerConfig", "aws-config")
 	
 	// Set metadata
-	config.AddMetadataAnnotation("eks.amazonaws.com/role-arn", 
-		"arn:aws:iam::ACCOUNT_ID:role/crossplane-provider-aws")
+	if g.credentials == CredentialsIRSA {
+		config.AddMetadataAnnotation("eks.amazonaws.com/role-arn", g.providerRoleARN())
+	}
+	
+	// Set spec, so the provider can read the token of the service account
+	config.AddNestedSpecField([]string{"podSecurityContext", "fsGroup"}, 2000)
+	
+	return config
+}
+
+// GenerateRuntimeConfig generates the DeploymentRuntimeConfig the providers of the Upbound
+// provider family run with, which replaces the deprecated ControllerConfig
+func (g *ProviderGenerator) GenerateRuntimeConfig() K8sObject {
+	config := NewK8sObject("pkg.crossplane.io/v1beta1", "DeploymentRuntimeConfig", "aws-config")
 	
-	// Set spec
-	config.AddNestedSpecField([]string{"podSecurityContext", "fsGroup"}, "2000")
+	annotations := map[string]string{}
+	if g.credentials == CredentialsIRSA {
+		annotations["eks.amazonaws.com/role-arn"] = g.providerRoleARN()
+	}
+	config.AddNestedSpecField([]string{"serviceAccountTemplate", "metadata", "annotations"}, annotations)
 	
 	return config
 }
 
-// GenerateProviderFiles generates all necessary provider files
+// writeBaseFile writes objects to a file of base/ and lists it in the base kustomization, so
+// kubectl apply -k installs it
+func (g *ProviderGenerator) writeBaseFile(file string, objects ...K8sObject) error {
+	if err := WriteMultiYAML(objects, filepath.Join(g.commonDir, file)); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	return AddKustomizationResource(g.commonDir, file)
+}
+
+// GenerateProviderFiles generates all necessary provider files: the Provider package, its
+// ControllerConfig and ProviderConfig, and with secret credentials the aws-credentials Secret
 func (g *ProviderGenerator) GenerateAllProviderFiles(region, accessKey, secretKey string) error {
 	// Create common directory if it doesn't exist
 	if err := utils.EnsureDirectoryExists(g.commonDir); err != nil {
 		return fmt.Errorf("failed to create provider directory: %w", err)
 	}
 	
-	// Generate provider objects
-	provider := g.GenerateProviderPackage("crossplane/provider-aws", "v0.36.0")
-	providerConfig := g.GenerateProviderConfig(region)
-	secret := g.GenerateAwsSecret(accessKey, secretKey)
-	controllerConfig := g.GenerateControllerConfig()
+	if g.provider == ProviderUpbound {
+		return g.generateUpboundProviderFiles(region, accessKey, secretKey)
+	}
 	
 	// Write provider files
-	providerPath := filepath.Join(g.commonDir, "provider.yaml")
-	if err := utils.WriteToFile(providerPath, provider.YAML()); err != nil {
-		return fmt.Errorf("failed to write provider file: %w", err)
+	if err := g.writeBaseFile("provider.yaml", g.GenerateProviderPackage("crossplane/provider-aws", "v0.36.0")); err != nil {
+		return err
 	}
-	
-	configPath := filepath.Join(g.commonDir, "providerconfig.yaml")
-	if err := utils.WriteToFile(configPath, providerConfig.YAML()); err != nil {
-		return fmt.Errorf("failed to write provider config file: %w", err)
+	if err := g.writeBaseFile("controller-config.yaml", g.GenerateControllerConfig()); err != nil {
+		return err
 	}
-	
-	secretPath := filepath.Join(g.commonDir, "aws-secret.yaml")
-	if err := utils.WriteToFile(secretPath, secret.YAML()); err != nil {
-		return fmt.Errorf("failed to write secret file: %w", err)
+	if err := g.writeBaseFile("providerconfig.yaml", g.GenerateProviderConfig(region)); err != nil {
+		return err
 	}
-	
-	controllerPath := filepath.Join(g.commonDir, "controller-config.yaml")
-	if err := utils.WriteToFile(controllerPath, controllerConfig.YAML()); err != nil {
-		return fmt.Errorf("failed to write controller config file: %w", err)
+	if g.credentials == CredentialsIRSA {
+		return nil
 	}
-	
-	return nil
-}
\ No newline at end of file
+	return g.writeBaseFile("aws-secret.yaml", g.GenerateAwsSecret(accessKey, secretKey))
+}
+
+// generateUpboundProviderFiles writes the providers of the Upbound AWS provider family, their
+// runtime configuration, ProviderConfig and credentials
+func (g *ProviderGenerator) generateUpboundProviderFiles(region, accessKey, secretKey string) error {
+	if err := g.writeBaseFile("provider.yaml", g.GenerateUpboundProviderPackages()...); err != nil {
+		return err
+	}
+	if err := g.writeBaseFile("runtime-config.yaml", g.GenerateRuntimeConfig()); err != nil {
+		return err
+	}
+	if err := g.writeBaseFile("providerconfig.yaml", g.GenerateProviderConfig(region)); err != nil {
+		return err
+	}
+	if g.credentials == CredentialsIRSA {
+		return nil
+	}
+	return g.writeBaseFile("aws-secret.yaml", g.GenerateAwsSecret(accessKey, secretKey))
+}
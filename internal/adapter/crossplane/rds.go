@@ -0,0 +1,122 @@
+package crossplane
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// RDSGenerator generates Crossplane YAML for RDS database instances
+type RDSGenerator struct {
+	baseDir string
+	rdsDir  string
+	// provider is the Crossplane provider the resources are written for, and region the
+	// region of those naming none
+	provider string
+	region   string
+	// policy is the deletion and management policy of the managed resources
+	policy ResourcePolicy
+	// connectionSecretNamespace is the namespace the instances write their connection
+	// details to; empty writes none
+	connectionSecretNamespace string
+}
+
+// NewRDSGenerator creates a new RDS Generator
+func NewRDSGenerator(baseDir string) *RDSGenerator {
+	return &RDSGenerator{
+		baseDir: baseDir,
+		rdsDir:  filepath.Join(baseDir, "rds"),
+	}
+}
+
+// GenerateRDSInstance generates a Crossplane RDSInstance. The provider generates the master
+// password, which the instance's connection secret holds.
+func (g *RDSGenerator) GenerateRDSInstance(name, region, engine, engineVersion, instanceClass string, allocatedStorage int, username string) K8sObject {
+	instance := NewK8sObject("database.aws.crossplane.io/v1beta1", "RDSInstance", name)
+
+	instance.AddNestedSpecField([]string{"forProvider", "region"}, region)
+	instance.AddNestedSpecField([]string{"forProvider", "engine"}, engine)
+	if engineVersion != "" {
+		instance.AddNestedSpecField([]string{"forProvider", "engineVersion"}, engineVersion)
+	}
+	instance.AddNestedSpecField([]string{"forProvider", "dbInstanceClass"}, instanceClass)
+	instance.AddNestedSpecField([]string{"forProvider", "allocatedStorage"}, allocatedStorage)
+	instance.AddNestedSpecField([]string{"forProvider", "masterUsername"}, username)
+	instance.AddNestedSpecField([]string{"forProvider", "publiclyAccessible"}, false)
+	instance.AddNestedSpecField([]string{"forProvider", "skipFinalSnapshotBeforeDeletion"}, true)
+
+	// Add provider config reference
+	instance.AddNestedSpecField([]string{"providerConfigRef", "name"}, "aws-provider")
+
+	// Add common labels
+	instance.AddLabel("app.kubernetes.io/part-of", "rds")
+	instance.AddLabel("app.kubernetes.io/component", "database")
+
+	instance.writeConnectionSecret(g.connectionSecretNamespace)
+
+	return instance
+}
+
+// GenerateRDSResources generates the RDS instances of an infrastructure model to
+// rds/instances.yaml
+func (g *RDSGenerator) GenerateRDSResources(model *models.InfrastructureModel) error {
+	var instances []K8sObject
+	for i, resource := range model.Resources {
+		if resource.Type != models.ResourceRDSInstance {
+			continue
+		}
+
+		// Take the properties of the Terraform resource, or defaults
+		engine, engineVersion, instanceClass, username := "postgres", "", "db.t3.micro", "dbadmin"
+		allocatedStorage := 20
+		for _, prop := range resource.Properties {
+			switch prop.Name {
+			case "engine":
+				if val, ok := prop.Value.(string); ok {
+					engine = val
+				}
+			case "engine_version":
+				if val, ok := prop.Value.(string); ok {
+					engineVersion = val
+				}
+			case "instance_class":
+				if val, ok := prop.Value.(string); ok {
+					instanceClass = val
+				}
+			case "username":
+				if val, ok := prop.Value.(string); ok {
+					username = val
+				}
+			case "allocated_storage":
+				if val, ok := prop.Value.(int); ok {
+					allocatedStorage = val
+				}
+			}
+		}
+
+		region := model.ResourceRegion(&model.Resources[i])
+		if region == "" {
+			region = g.region
+		}
+		instances = append(instances, g.GenerateRDSInstance(resource.Name, region, engine, engineVersion, instanceClass, allocatedStorage, username))
+	}
+
+	if len(instances) == 0 {
+		return nil
+	}
+
+	// Apply the tags set for every resource
+	addTags(instances, model.Tags)
+
+	if err := WriteMultiYAML(g.policy.apply(providerObjects(g.provider, instances, g.region)), filepath.Join(g.rdsDir, "instances.yaml")); err != nil {
+		return fmt.Errorf("failed to write RDS YAML: %w", err)
+	}
+
+	// Register the instances with kustomize
+	if err := AddKustomizationResource(g.rdsDir, "instances.yaml"); err != nil {
+		return err
+	}
+
+	return AddKustomizationResource(g.baseDir, "rds")
+}
@@ -0,0 +1,110 @@
+package crossplane
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// TeamDirectory is the directory of the namespace and RBAC of the team owning the claims
+const TeamDirectory = "team"
+
+// teamNamePattern matches the names a team can take, those of a Kubernetes namespace
+var teamNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// ValidateTeam checks the name of the team owning the claims, which names its namespace
+func ValidateTeam(team string) error {
+	if len(team) > 63 || !teamNamePattern.MatchString(team) {
+		return fmt.Errorf("invalid team: %s (a team names its namespace, so it takes lowercase letters, digits and dashes, up to 63 characters)", team)
+	}
+	return nil
+}
+
+// WithTeam sets the team owning the claims. The claims are placed in a namespace of the team,
+// written to team/ with a ServiceAccount allowed to manage them; empty keeps them in default.
+func (g *CrossplaneGenerator) WithTeam(team string) *CrossplaneGenerator {
+	g.team = team
+	return g
+}
+
+// claimNamespace returns the namespace of the example claims
+func (g *CrossplaneGenerator) claimNamespace() string {
+	if g.team == "" {
+		return defaultClaimNamespace
+	}
+	return g.team
+}
+
+// teamObjects returns the namespace of the team, and the ServiceAccount its tooling deploys
+// the claims with, bound to a Role limited to the claims and, with the composite connection
+// secrets, to reading the secrets they write
+func (g *CrossplaneGenerator) teamObjects() []K8sObject {
+	namespace := NewK8sObject("v1", "Namespace", g.team)
+	namespace.AddLabel("app.kubernetes.io/managed-by", "iacgen")
+
+	serviceAccount := NewK8sObject("v1", "ServiceAccount", g.team)
+	serviceAccount.SetNamespace(g.team)
+
+	rules := []map[string]interface{}{{
+		"apiGroups": []string{compositionGroup},
+		"resources": []string{"networks", "kubernetesclusters"},
+		"verbs":     []string{"get", "list", "watch", "create", "update", "patch", "delete"},
+	}}
+	if g.connectionSecrets == ConnectionSecretsComposite {
+		rules = append(rules, map[string]interface{}{
+			"apiGroups": []string{""},
+			"resources": []string{"secrets"},
+			"verbs":     []string{"get", "list", "watch"},
+		})
+	}
+	role := NewK8sObject("rbac.authorization.k8s.io/v1", "Role", g.team+"-claims")
+	role.SetNamespace(g.team)
+	role.AddField("rules", rules)
+
+	binding := NewK8sObject("rbac.authorization.k8s.io/v1", "RoleBinding", g.team+"-claims")
+	binding.SetNamespace(g.team)
+	binding.AddField("roleRef", map[string]string{
+		"apiGroup": "rbac.authorization.k8s.io",
+		"kind":     "Role",
+		"name":     role.Metadata.Name,
+	})
+	binding.AddField("subjects", []map[string]string{{
+		"kind":      "ServiceAccount",
+		"name":      serviceAccount.Metadata.Name,
+		"namespace": g.team,
+	}})
+
+	return []K8sObject{namespace, serviceAccount, role, binding}
+}
+
+// generateTeam writes the namespace and RBAC of the team to team/, whose kustomization, like
+// that of the claims, sets the team's namespace, so renaming it there moves them together
+func (g *CrossplaneGenerator) generateTeam() error {
+	teamDir := filepath.Join(g.baseDir, TeamDirectory)
+	if err := utils.EnsureDirectoryExists(teamDir); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", teamDir, err)
+	}
+
+	objects := g.teamObjects()
+	files := map[string][]K8sObject{
+		"namespace.yaml": objects[:1],
+		"rbac.yaml":      objects[1:],
+	}
+	for _, file := range []string{"namespace.yaml", "rbac.yaml"} {
+		if err := WriteMultiYAML(files[file], filepath.Join(teamDir, file)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file, err)
+		}
+		if err := AddKustomizationResource(teamDir, file); err != nil {
+			return err
+		}
+	}
+
+	for _, dir := range []string{teamDir, filepath.Join(g.baseDir, "claims")} {
+		if err := SetKustomizationNamespace(dir, g.team); err != nil {
+			return err
+		}
+	}
+	return nil
+}
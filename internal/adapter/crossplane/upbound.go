@@ -0,0 +1,255 @@
+package crossplane
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Providers whose APIs the Crossplane managed resources are written for
+const (
+	// ProviderContrib targets the crossplane-contrib provider-aws, with APIs such as
+	// ec2.aws.crossplane.io and eks.aws.crossplane.io
+	ProviderContrib = "contrib"
+	// ProviderUpbound targets the Upbound AWS provider family, with APIs such as
+	// ec2.aws.upbound.io and eks.aws.upbound.io
+	ProviderUpbound = "upbound"
+)
+
+// Providers are the providers the managed resources can be written for
+var Providers = []string{ProviderContrib, ProviderUpbound}
+
+// ValidateProvider checks the provider the managed resources are written for
+func ValidateProvider(provider string) error {
+	for _, known := range Providers {
+		if provider == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid Crossplane provider: %s (supported providers: %s)", provider, strings.Join(Providers, ", "))
+}
+
+// upboundProviderVersion is the version of the packages of the Upbound AWS provider family
+const upboundProviderVersion = "v1.14.0"
+
+// upboundProviderPackages are the providers of the family serving the generated resources;
+// each installs the family's provider-family-aws, which owns the ProviderConfig
+var upboundProviderPackages = []string{"provider-aws-ec2", "provider-aws-eks", "provider-aws-iam", "provider-aws-autoscaling"}
+
+// upboundKinds map the API versions and kinds of the contrib provider to those of the
+// Upbound provider family
+var upboundKinds = map[string][2]string{
+	"ec2.aws.crossplane.io/v1beta1/VPC":                      {"ec2.aws.upbound.io/v1beta1", "VPC"},
+	"ec2.aws.crossplane.io/v1beta1/Subnet":                   {"ec2.aws.upbound.io/v1beta1", "Subnet"},
+	"ec2.aws.crossplane.io/v1beta1/InternetGateway":          {"ec2.aws.upbound.io/v1beta1", "InternetGateway"},
+	"ec2.aws.crossplane.io/v1beta1/NATGateway":               {"ec2.aws.upbound.io/v1beta1", "NATGateway"},
+	"ec2.aws.crossplane.io/v1beta1/ElasticIP":                {"ec2.aws.upbound.io/v1beta1", "EIP"},
+	"ec2.aws.crossplane.io/v1beta1/RouteTable":               {"ec2.aws.upbound.io/v1beta1", "RouteTable"},
+	"ec2.aws.crossplane.io/v1beta1/Route":                    {"ec2.aws.upbound.io/v1beta1", "Route"},
+	"ec2.aws.crossplane.io/v1beta1/RouteTableAssociation":    {"ec2.aws.upbound.io/v1beta1", "RouteTableAssociation"},
+	"ec2.aws.crossplane.io/v1beta1/SecurityGroup":            {"ec2.aws.upbound.io/v1beta1", "SecurityGroup"},
+	"ec2.aws.crossplane.io/v1alpha1/Instance":                {"ec2.aws.upbound.io/v1beta1", "Instance"},
+	"ec2.aws.crossplane.io/v1alpha1/LaunchTemplate":          {"ec2.aws.upbound.io/v1beta1", "LaunchTemplate"},
+	"autoscaling.aws.crossplane.io/v1beta1/AutoScalingGroup": {"autoscaling.aws.upbound.io/v1beta1", "AutoscalingGroup"},
+	"iam.aws.crossplane.io/v1beta1/Role":                     {"iam.aws.upbound.io/v1beta1", "Role"},
+	"eks.aws.crossplane.io/v1beta1/Cluster":                  {"eks.aws.upbound.io/v1beta1", "Cluster"},
+	"eks.aws.crossplane.io/v1beta1/NodeGroup":                {"eks.aws.upbound.io/v1beta1", "NodeGroup"},
+	"eks.aws.crossplane.io/v1alpha1/Addon":                   {"eks.aws.upbound.io/v1beta1", "Addon"},
+	"eks.aws.crossplane.io/v1alpha1/FargateProfile":          {"eks.aws.upbound.io/v1beta1", "FargateProfile"},
+	"database.aws.crossplane.io/v1beta1/RDSInstance":         {"rds.aws.upbound.io/v1beta1", "Instance"},
+}
+
+// providerObjects returns objects written for the contrib provider as the given provider
+// takes them, in the given region
+func providerObjects(provider string, objects []K8sObject, region string) []K8sObject {
+	if provider != ProviderUpbound {
+		return objects
+	}
+	return toUpbound(objects, region)
+}
+
+// forProvider returns the forProvider parameters of an object
+func (obj *K8sObject) forProvider() map[string]interface{} {
+	parameters, ok := obj.Spec["forProvider"].(map[string]interface{})
+	if !ok {
+		parameters = make(map[string]interface{})
+		obj.Spec["forProvider"] = parameters
+	}
+	return parameters
+}
+
+// renameField moves a field of the parameters to another name, if it is set
+func renameField(parameters map[string]interface{}, from, to string) {
+	if value, ok := parameters[from]; ok {
+		delete(parameters, from)
+		parameters[to] = value
+	}
+}
+
+// blockField wraps a field of the parameters in a list, as the Upbound providers take the
+// nested blocks of the Terraform resources they are generated from
+func blockField(parameters map[string]interface{}, name string) {
+	if value, ok := parameters[name]; ok {
+		parameters[name] = []interface{}{value}
+	}
+}
+
+// tagMap returns the tags of the contrib provider, a map or a list of key/value pairs, as a map
+func tagMap(tags interface{}) map[string]string {
+	switch tags := tags.(type) {
+	case map[string]string:
+		return tags
+	case []map[string]interface{}:
+		merged := make(map[string]string, len(tags))
+		for _, entry := range tags {
+			key, _ := entry["key"].(string)
+			merged[key] = fmt.Sprint(entry["value"])
+		}
+		return merged
+	}
+	return nil
+}
+
+// toUpbound converts objects written for the contrib provider to the Upbound provider family.
+// Its resources are given their region, defaulting to region, and the rules of a security
+// group become SecurityGroupRule objects of their own. Objects of other APIs are kept.
+func toUpbound(objects []K8sObject, region string) []K8sObject {
+	converted := make([]K8sObject, 0, len(objects))
+	for _, obj := range objects {
+		target, ok := upboundKinds[obj.APIVersion+"/"+obj.Kind]
+		if !ok {
+			converted = append(converted, obj)
+			continue
+		}
+		obj.APIVersion, obj.Kind = target[0], target[1]
+		parameters := obj.forProvider()
+
+		// The Upbound providers take the external name as the ID AWS assigns the resource
+		delete(obj.Metadata.Annotations, "crossplane.io/external-name")
+
+		var rules []K8sObject
+		switch obj.Kind {
+		case "Role":
+			renameField(parameters, "assumeRolePolicyDocument", "assumeRolePolicy")
+		case "Cluster":
+			renameField(parameters, "resourcesVpcConfig", "vpcConfig")
+			blockField(parameters, "vpcConfig")
+			blockField(parameters, "kubernetesNetworkConfig")
+		case "NodeGroup":
+			renameField(parameters, "nodeRole", "nodeRoleArn")
+			renameField(parameters, "nodeRoleRef", "nodeRoleArnRef")
+			renameField(parameters, "nodeRoleSelector", "nodeRoleArnSelector")
+			blockField(parameters, "scalingConfig")
+		case "Addon":
+			if resolve, ok := parameters["resolveConflicts"]; ok {
+				delete(parameters, "resolveConflicts")
+				parameters["resolveConflictsOnCreate"] = resolve
+				parameters["resolveConflictsOnUpdate"] = resolve
+			}
+			renameField(parameters, "serviceAccountRoleARNRef", "serviceAccountRoleArnRef")
+		case "FargateProfile":
+			renameField(parameters, "subnetRefs", "subnetIdRefs")
+			renameField(parameters, "selectors", "selector")
+		case "SecurityGroup":
+			renameField(parameters, "groupName", "name")
+			rules = securityGroupRules(obj, region)
+		case "Instance":
+			if obj.APIVersion == "rds.aws.upbound.io/v1beta1" {
+				renameField(parameters, "dbInstanceClass", "instanceClass")
+				renameField(parameters, "masterUsername", "username")
+				renameField(parameters, "skipFinalSnapshotBeforeDeletion", "skipFinalSnapshot")
+				// The provider only generates the password into a secret of its own
+				parameters["autoGeneratePassword"] = true
+				parameters["passwordSecretRef"] = map[string]string{
+					"namespace": "crossplane-system",
+					"name":      obj.Metadata.Name + "-password",
+					"key":       "password",
+				}
+				break
+			}
+			renameField(parameters, "imageId", "ami")
+			renameField(parameters, "securityGroupRefs", "vpcSecurityGroupIdRefs")
+			blockField(parameters, "metadataOptions")
+			if specs, ok := parameters["tagSpecifications"].([]map[string]interface{}); ok {
+				delete(parameters, "tagSpecifications")
+				for _, spec := range specs {
+					if spec["resourceType"] == "instance" {
+						parameters["tags"] = spec["tags"]
+					}
+				}
+			}
+		case "LaunchTemplate":
+			renameField(parameters, "launchTemplateName", "name")
+			if data, ok := parameters["launchTemplateData"].(map[string]interface{}); ok {
+				delete(parameters, "launchTemplateData")
+				for key, value := range data {
+					parameters[key] = value
+				}
+			}
+		case "AutoscalingGroup":
+			if template, ok := parameters["launchTemplate"].(map[string]interface{}); ok {
+				renameField(template, "launchTemplateName", "name")
+			}
+			blockField(parameters, "launchTemplate")
+			// Auto Scaling groups keep their tags as a list, to propagate them to instances
+			renameField(parameters, "tags", "tag")
+		}
+
+		// Every resource but the global IAM ones takes its region, and tags as a map
+		if obj.Kind != "Role" {
+			if _, ok := parameters["region"]; !ok {
+				parameters["region"] = region
+			}
+		}
+		if tags := tagMap(parameters["tags"]); tags != nil {
+			parameters["tags"] = tags
+		}
+
+		converted = append(converted, obj)
+		converted = append(converted, rules...)
+	}
+	return converted
+}
+
+// securityGroupRules removes the ingress and egress rules of a security group and returns
+// them as SecurityGroupRule objects, one per rule and CIDR list, referring to the group
+func securityGroupRules(sg K8sObject, region string) []K8sObject {
+	parameters := sg.forProvider()
+	if groupRegion, ok := parameters["region"].(string); ok {
+		region = groupRegion
+	}
+
+	var rules []K8sObject
+	for _, ruleType := range []string{"ingress", "egress"} {
+		permissions, _ := parameters[ruleType].([]map[string]interface{})
+		delete(parameters, ruleType)
+		for i, permission := range permissions {
+			rule := NewK8sObject("ec2.aws.upbound.io/v1beta1", "SecurityGroupRule", fmt.Sprintf("%s-%s-%d", sg.Metadata.Name, ruleType, i+1))
+			rule.AddNestedSpecField([]string{"forProvider", "region"}, region)
+			rule.AddNestedSpecField([]string{"forProvider", "type"}, ruleType)
+			rule.AddNestedSpecField([]string{"forProvider", "protocol"}, permission["ipProtocol"])
+			// All-traffic rules take the port range 0 to 0
+			fromPort, toPort := permission["fromPort"], permission["toPort"]
+			if fromPort == nil {
+				fromPort, toPort = 0, 0
+			}
+			rule.AddNestedSpecField([]string{"forProvider", "fromPort"}, fromPort)
+			rule.AddNestedSpecField([]string{"forProvider", "toPort"}, toPort)
+			var cidrBlocks []string
+			ranges, _ := permission["ipRanges"].([]map[string]interface{})
+			for _, ipRange := range ranges {
+				if cidr, ok := ipRange["cidrIp"].(string); ok {
+					cidrBlocks = append(cidrBlocks, cidr)
+				}
+			}
+			rule.AddNestedSpecField([]string{"forProvider", "cidrBlocks"}, cidrBlocks)
+			rule.AddNestedSpecField([]string{"forProvider", "securityGroupIdRef", "name"}, sg.Metadata.Name)
+			rule.Spec["providerConfigRef"] = sg.Spec["providerConfigRef"]
+			for key, value := range sg.Metadata.Labels {
+				rule.AddLabel(key, value)
+			}
+			rule.AddLabel("app.kubernetes.io/component", "security-group-rule")
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
@@ -13,6 +13,12 @@ import (
 type VPCGenerator struct {
 	baseDir string
 	vpcDir  string
+	// provider is the Crossplane provider the resources are written for, and region the
+	// region of the primary network
+	provider string
+	region   string
+	// policy is the deletion and management policy of the managed resources
+	policy   ResourcePolicy
 }
 
 // NewVPCGenerator creates a new VPC Generator
@@ -198,24 +204,101 @@ func (g *VPCGenerator) GenerateSubnetRouteTableAssociation(name, subnetName, rou
 	return assoc
 }
 
-// GenerateNetworkResources generates all Crossplane VPC networking resources from an infrastructure model
+// network holds the Crossplane objects of a VPC with its subnets, gateways and routing
+type network struct {
+	vpc            K8sObject
+	publicSubnets  []K8sObject
+	privateSubnets []K8sObject
+	igw            K8sObject
+	natGateways    []K8sObject
+	eips           []K8sObject
+	publicRT       K8sObject
+	privateRTs     []K8sObject
+	routes         []K8sObject
+	associations   []K8sObject
+}
+
+// subnets returns the public subnets followed by the private subnets
+func (n *network) subnets() []K8sObject {
+	var subnets []K8sObject
+	subnets = append(subnets, n.publicSubnets...)
+	subnets = append(subnets, n.privateSubnets...)
+	return subnets
+}
+
+// gateways returns the Internet Gateway, Elastic IPs and NAT Gateways
+func (n *network) gateways() []K8sObject {
+	var gateways []K8sObject
+	if n.igw.APIVersion != "" {
+		gateways = append(gateways, n.igw)
+	}
+	gateways = append(gateways, n.eips...)
+	gateways = append(gateways, n.natGateways...)
+	return gateways
+}
+
+// routing returns the route tables, routes and route table associations
+func (n *network) routing() []K8sObject {
+	var routing []K8sObject
+	if n.publicRT.APIVersion != "" {
+		routing = append(routing, n.publicRT)
+	}
+	routing = append(routing, n.privateRTs...)
+	routing = append(routing, n.routes...)
+	routing = append(routing, n.associations...)
+	return routing
+}
+
+// addTags applies the tags set for every resource
+func (n *network) addTags(tags map[string]string) {
+	n.vpc.AddTags(tags)
+	n.igw.AddTags(tags)
+	n.publicRT.AddTags(tags)
+	for _, objects := range [][]K8sObject{n.publicSubnets, n.privateSubnets, n.eips, n.natGateways, n.privateRTs} {
+		addTags(objects, tags)
+	}
+}
+
+// GenerateNetworkResources generates all Crossplane VPC networking resources from an infrastructure model.
+// Resources in a secondary region are written to vpc/<region>.yaml and use that region's ProviderConfig.
 func (g *VPCGenerator) GenerateNetworkResources(model *models.InfrastructureModel) error {
+	var primary []models.Resource
+	regional := make(map[string][]models.Resource)
+	for i, resource := range model.Resources {
+		region := model.ResourceRegion(&model.Resources[i])
+		if model.Region == "" || region == model.Region {
+			primary = append(primary, resource)
+		} else {
+			regional[region] = append(regional[region], resource)
+		}
+	}
+	
+	n := g.buildNetwork(primary, "")
+	n.addTags(model.Tags)
+	if err := g.writeNetwork(n); err != nil {
+		return err
+	}
+	
+	for _, region := range model.SecondaryRegions() {
+		if err := g.writeRegionalNetwork(regional[region], region, model.Tags); err != nil {
+			return err
+		}
+	}
+	
+	return nil
+}
+
+// buildNetwork generates the network objects for the resources of one region. The suffix is
+// appended to the names of objects the model does not name, such as route tables, so the
+// networks of different regions do not collide.
+func (g *VPCGenerator) buildNetwork(resources []models.Resource, suffix string) network {
 	var (
-		vpc          K8sObject
-		vpcName      string
-		publicSubnets  []K8sObject
-		privateSubnets []K8sObject
-		igw          K8sObject
-		natGateways  []K8sObject
-		eips         []K8sObject
-		publicRT     K8sObject
-		privateRTs   []K8sObject
-		routes       []K8sObject
-		associations []K8sObject
+		n       network
+		vpcName string
 	)
 	
 	// Find the VPC
-	for _, resource := range model.Resources {
+	for _, resource := range resources {
 		if resource.Type == models.ResourceVPC {
 			vpcName = resource.Name
 			
@@ -241,19 +324,19 @@ func (g *VPCGenerator) GenerateNetworkResources(model *models.InfrastructureMode
 				}
 			}
 			
-			vpc = g.GenerateVPC(vpcName, cidrBlock, enableDnsSupport, enableDnsHostnames)
+			n.vpc = g.GenerateVPC(vpcName, cidrBlock, enableDnsSupport, enableDnsHostnames)
 			break
 		}
 	}
 	
 	// If no VPC found, create one with default values
 	if vpcName == "" {
-		vpcName = "main-vpc"
-		vpc = g.GenerateVPC(vpcName, "10.0.0.0/16", true, true)
+		vpcName = "main-vpc" + suffix
+		n.vpc = g.GenerateVPC(vpcName, "10.0.0.0/16", true, true)
 	}
 	
 	// Find subnets
-	for _, resource := range model.Resources {
+	for _, resource := range resources {
 		if resource.Type == models.ResourceSubnet {
 			// Extract subnet properties
 			name := resource.Name
@@ -281,68 +364,68 @@ func (g *VPCGenerator) GenerateNetworkResources(model *models.InfrastructureMode
 			subnet := g.GenerateSubnet(name, vpcName, cidrBlock, az, isPublic)
 			
 			if isPublic {
-				publicSubnets = append(publicSubnets, subnet)
+				n.publicSubnets = append(n.publicSubnets, subnet)
 			} else {
-				privateSubnets = append(privateSubnets, subnet)
+				n.privateSubnets = append(n.privateSubnets, subnet)
 			}
 		}
 	}
 	
 	// Find Internet Gateway
-	for _, resource := range model.Resources {
+	for _, resource := range resources {
 		if resource.Type == models.ResourceIGW {
-			igw = g.GenerateInternetGateway(resource.Name, vpcName)
+			n.igw = g.GenerateInternetGateway(resource.Name, vpcName)
 			break
 		}
 	}
 	
 	// If no IGW found, create one if we have public subnets
-	if igw.APIVersion == "" && len(publicSubnets) > 0 {
-		igw = g.GenerateInternetGateway("main-igw", vpcName)
+	if n.igw.APIVersion == "" && len(n.publicSubnets) > 0 {
+		n.igw = g.GenerateInternetGateway("main-igw"+suffix, vpcName)
 	}
 	
 	// Create public route table if needed
-	if len(publicSubnets) > 0 && igw.APIVersion != "" {
-		publicRT = g.GenerateRouteTable("public-rt", vpcName, true)
+	if len(n.publicSubnets) > 0 && n.igw.APIVersion != "" {
+		n.publicRT = g.GenerateRouteTable("public-rt"+suffix, vpcName, true)
 		
 		// Create route to the internet
 		internetRoute := g.GenerateRoute(
-			"public-internet-route",
-			publicRT.Metadata.Name,
+			"public-internet-route"+suffix,
+			n.publicRT.Metadata.Name,
 			"0.0.0.0/0",
 			"igw",
-			igw.Metadata.Name,
+			n.igw.Metadata.Name,
 		)
 		
-		routes = append(routes, internetRoute)
+		n.routes = append(n.routes, internetRoute)
 		
 		// Create route table associations for public subnets
-		for i, subnet := range publicSubnets {
+		for i, subnet := range n.publicSubnets {
 			assoc := g.GenerateSubnetRouteTableAssociation(
-				fmt.Sprintf("public-rt-assoc-%d", i+1),
+				fmt.Sprintf("public-rt-assoc-%d%s", i+1, suffix),
 				subnet.Metadata.Name,
-				publicRT.Metadata.Name,
+				n.publicRT.Metadata.Name,
 			)
-			associations = append(associations, assoc)
+			n.associations = append(n.associations, assoc)
 		}
 	}
 	
 	// Find NAT Gateways or create them if we have private subnets
-	if len(privateSubnets) > 0 {
+	if len(n.privateSubnets) > 0 {
 		natCount := 0
 		
 		// Find existing NAT gateways
-		for _, resource := range model.Resources {
+		for _, resource := range resources {
 			if resource.Type == models.ResourceNATGateway {
 				natCount++
 			}
 		}
 		
 		// Create NAT gateway for each AZ or at least one
-		if natCount == 0 && len(publicSubnets) > 0 {
+		if natCount == 0 && len(n.publicSubnets) > 0 {
 			// Determine how many NAT gateways we need (one per AZ is best practice)
 			azs := make(map[string]bool)
-			for _, subnet := range privateSubnets {
+			for _, subnet := range n.privateSubnets {
 				for _, field := range subnet.Spec["forProvider"].(map[string]interface{}) {
 					if az, ok := field.(string); ok && strings.HasPrefix(az, "us-") {
 						azs[az] = true
@@ -357,89 +440,79 @@ func (g *VPCGenerator) GenerateNetworkResources(model *models.InfrastructureMode
 			}
 			
 			// Create NAT gateways (and EIPs)
-			for i := 0; i < natCount && i < len(publicSubnets); i++ {
+			for i := 0; i < natCount && i < len(n.publicSubnets); i++ {
 				// Create EIP for NAT gateway
-				eipName := fmt.Sprintf("nat-eip-%d", i+1)
+				eipName := fmt.Sprintf("nat-eip-%d%s", i+1, suffix)
 				eip := g.GenerateElasticIP(eipName)
-				eips = append(eips, eip)
+				n.eips = append(n.eips, eip)
 				
 				// Create NAT gateway
-				natName := fmt.Sprintf("nat-gateway-%d", i+1)
-				nat := g.GenerateNATGateway(natName, publicSubnets[i].Metadata.Name, eipName)
-				natGateways = append(natGateways, nat)
+				natName := fmt.Sprintf("nat-gateway-%d%s", i+1, suffix)
+				nat := g.GenerateNATGateway(natName, n.publicSubnets[i].Metadata.Name, eipName)
+				n.natGateways = append(n.natGateways, nat)
 				
 				// Create private route table for this NAT gateway
-				privateRTName := fmt.Sprintf("private-rt-%d", i+1)
+				privateRTName := fmt.Sprintf("private-rt-%d%s", i+1, suffix)
 				privateRT := g.GenerateRouteTable(privateRTName, vpcName, false)
-				privateRTs = append(privateRTs, privateRT)
+				n.privateRTs = append(n.privateRTs, privateRT)
 				
 				// Create route to the internet via NAT
 				natRoute := g.GenerateRoute(
-					fmt.Sprintf("private-internet-route-%d", i+1),
+					fmt.Sprintf("private-internet-route-%d%s", i+1, suffix),
 					privateRTName,
 					"0.0.0.0/0",
 					"nat",
 					natName,
 				)
-				routes = append(routes, natRoute)
+				n.routes = append(n.routes, natRoute)
 				
 				// Distribute private subnets across NAT gateways
-				for j, subnet := range privateSubnets {
+				for j, subnet := range n.privateSubnets {
 					if j % natCount == i {
 						assoc := g.GenerateSubnetRouteTableAssociation(
-							fmt.Sprintf("private-rt-assoc-%d-%d", i+1, j+1),
+							fmt.Sprintf("private-rt-assoc-%d-%d%s", i+1, j+1, suffix),
 							subnet.Metadata.Name,
 							privateRTName,
 						)
-						associations = append(associations, assoc)
+						n.associations = append(n.associations, assoc)
 					}
 				}
 			}
 		}
 	}
 	
+	return n
+}
+
+// writeNetwork writes the network of the primary region to the files of the VPC directory
+func (g *VPCGenerator) writeNetwork(n network) error {
 	// Write VPC YAML
 	vpcFilePath := filepath.Join(g.vpcDir, "vpc.yaml")
-	if err := WriteYAML(vpc, vpcFilePath); err != nil {
+	if err := WriteYAML(g.policy.apply(providerObjects(g.provider, []K8sObject{n.vpc}, g.region))[0], vpcFilePath); err != nil {
 		return fmt.Errorf("failed to write VPC YAML: %w", err)
 	}
 	
 	// Write Subnets YAML
-	var allSubnets []K8sObject
-	allSubnets = append(allSubnets, publicSubnets...)
-	allSubnets = append(allSubnets, privateSubnets...)
-	if len(allSubnets) > 0 {
+	if allSubnets := n.subnets(); len(allSubnets) > 0 {
 		subnetsFilePath := filepath.Join(g.vpcDir, "subnets.yaml")
-		if err := WriteMultiYAML(allSubnets, subnetsFilePath); err != nil {
+		if err := WriteMultiYAML(g.policy.apply(providerObjects(g.provider, allSubnets, g.region)), subnetsFilePath); err != nil {
 			return fmt.Errorf("failed to write Subnets YAML: %w", err)
 		}
 	}
 	
 	// Write Gateways YAML (IGW, NAT, EIP)
-	var gateways []K8sObject
-	if igw.APIVersion != "" {
-		gateways = append(gateways, igw)
-	}
-	gateways = append(gateways, eips...)
-	gateways = append(gateways, natGateways...)
-	if len(gateways) > 0 {
+	if gateways := n.gateways(); len(gateways) > 0 {
 		gatewaysFilePath := filepath.Join(g.vpcDir, "gateways.yaml")
-		if err := WriteMultiYAML(gateways, gatewaysFilePath); err != nil {
+		if err := WriteMultiYAML(g.policy.apply(providerObjects(g.provider, gateways, g.region)), gatewaysFilePath); err != nil {
 			return fmt.Errorf("failed to write Gateways YAML: %w", err)
 		}
 	}
 	
 	// Write Routing YAML (Route tables, routes, associations)
-	var routing []K8sObject
-	if publicRT.APIVersion != "" {
-		routing = append(routing, publicRT)
-	}
-	routing = append(routing, privateRTs...)
-	routing = append(routing, routes...)
-	routing = append(routing, associations...)
+	routing := n.routing()
 	if len(routing) > 0 {
 		routingFilePath := filepath.Join(g.vpcDir, "routing.yaml")
-		if err := WriteMultiYAML(routing, routingFilePath); err != nil {
+		if err := WriteMultiYAML(g.policy.apply(providerObjects(g.provider, routing, g.region)), routingFilePath); err != nil {
 			return fmt.Errorf("failed to write Routing YAML: %w", err)
 		}
 	}
@@ -461,4 +534,32 @@ func (g *VPCGenerator) GenerateNetworkResources(model *models.InfrastructureMode
 	}
 	
 	return nil
-}
\ No newline at end of file
+}
+
+// writeRegionalNetwork writes the network of a secondary region to vpc/<region>.yaml and
+// adds the file to the VPC kustomization. Every object uses the region's ProviderConfig, or
+// names the region itself with the Upbound provider family.
+func (g *VPCGenerator) writeRegionalNetwork(resources []models.Resource, region string, tags map[string]string) error {
+	n := g.buildNetwork(resources, "-"+region)
+	n.addTags(tags)
+	
+	var objects []K8sObject
+	objects = append(objects, n.vpc)
+	objects = append(objects, n.subnets()...)
+	objects = append(objects, n.gateways()...)
+	objects = append(objects, n.routing()...)
+	if g.provider == ProviderUpbound {
+		objects = toUpbound(objects, region)
+	} else {
+		for i := range objects {
+			objects[i].AddNestedSpecField([]string{"providerConfigRef", "name"}, regionalProviderConfigName(region))
+		}
+	}
+	
+	fileName := region + ".yaml"
+	if err := WriteMultiYAML(g.policy.apply(objects), filepath.Join(g.vpcDir, fileName)); err != nil {
+		return fmt.Errorf("failed to write %s network YAML: %w", region, err)
+	}
+	
+	return AddKustomizationResource(g.vpcDir, fileName)
+}
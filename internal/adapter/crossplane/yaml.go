@@ -2,6 +2,8 @@ package crossplane
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/riptano/iac_generator_cli/internal/utils"
 	"github.com/riptano/iac_generator_cli/pkg/models"
@@ -13,7 +15,10 @@ type K8sObject struct {
 	APIVersion string                 `yaml:"apiVersion"`
 	Kind       string                 `yaml:"kind"`
 	Metadata   Metadata               `yaml:"metadata"`
-	Spec       map[string]interface{} `yaml:"spec"`
+	// Fields are the top-level fields of objects other than the spec, such as the data of a
+	// Secret or the rules of a Role
+	Fields map[string]interface{} `yaml:",inline"`
+	Spec   map[string]interface{} `yaml:"spec,omitempty"`
 }
 
 // Metadata represents Kubernetes resource metadata
@@ -78,10 +83,10 @@ func (obj *K8sObject) AddField(key string, value interface{}) {
 	default:
 		// For custom fields, add directly
 		// This is for non-standard fields like 'data' in Secret objects
-		if obj.Spec == nil {
-			obj.Spec = make(map[string]interface{})
+		if obj.Fields == nil {
+			obj.Fields = make(map[string]interface{})
 		}
-		obj.Spec[key] = value
+		obj.Fields[key] = value
 	}
 }
 
@@ -187,6 +192,90 @@ func (obj *K8sObject) AddNestedSpecField(path []string, value interface{}) {
 	}
 }
 
+// untaggedKinds are the kinds whose AWS resources cannot be tagged
+var untaggedKinds = map[string]bool{
+	"Route":                 true,
+	"RouteTableAssociation": true,
+}
+
+// AddTags merges tags into the AWS tags of a Kubernetes object, replacing tags with the same
+// key. EKS objects take tags as a map and EC2 objects as a list of key/value pairs, and
+// instances carry them in their tag specifications.
+func (obj *K8sObject) AddTags(tags map[string]string) {
+	if len(tags) == 0 || untaggedKinds[obj.Kind] {
+		return
+	}
+	forProvider, ok := obj.Spec["forProvider"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	if specs, ok := forProvider["tagSpecifications"].([]map[string]interface{}); ok {
+		for _, spec := range specs {
+			spec["tags"] = mergeTagList(spec["tags"], tags, false)
+		}
+		return
+	}
+
+	switch existing := forProvider["tags"].(type) {
+	case map[string]string:
+		for key, value := range tags {
+			existing[key] = value
+		}
+	case nil:
+		if strings.HasPrefix(obj.APIVersion, "eks.") {
+			merged := make(map[string]string, len(tags))
+			for key, value := range tags {
+				merged[key] = value
+			}
+			forProvider["tags"] = merged
+		} else {
+			forProvider["tags"] = mergeTagList(nil, tags, false)
+		}
+	default:
+		forProvider["tags"] = mergeTagList(existing, tags, obj.Kind == "AutoScalingGroup")
+	}
+}
+
+// mergeTagList merges tags into a list of key/value pairs. Existing entries keep their place
+// and new ones are added in key order. Auto Scaling Group tags also propagate to instances.
+func mergeTagList(existing interface{}, tags map[string]string, propagateAtLaunch bool) []map[string]interface{} {
+	list, _ := existing.([]map[string]interface{})
+	merged := make([]map[string]interface{}, 0, len(list)+len(tags))
+	seen := make(map[string]bool, len(list))
+	for _, entry := range list {
+		key, _ := entry["key"].(string)
+		if value, ok := tags[key]; ok {
+			entry["value"] = value
+		}
+		seen[key] = true
+		merged = append(merged, entry)
+	}
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		entry := map[string]interface{}{"key": key, "value": tags[key]}
+		if propagateAtLaunch {
+			entry["propagateAtLaunch"] = true
+		}
+		merged = append(merged, entry)
+	}
+	return merged
+}
+
+// addTags applies tags to each of the objects
+func addTags(objects []K8sObject, tags map[string]string) {
+	for i := range objects {
+		objects[i].AddTags(tags)
+	}
+}
+
 // ConvertResourceToK8sObject converts an internal resource model to a Crossplane K8s object
 func ConvertResourceToK8sObject(resource models.Resource) (K8sObject, error) {
 	apiVersion, kind, err := mapResourceTypeToK8s(resource.Type)
@@ -273,9 +362,25 @@ func mapResourceTypeToK8s(resourceType models.ResourceType) (string, string, err
 			Kind:       "Bucket",
 		},
 		models.ResourceEC2Instance: {
-			APIVersion: "ec2.aws.crossplane.io/v1beta1",
+			APIVersion: "ec2.aws.crossplane.io/v1alpha1",
 			Kind:       "Instance",
 		},
+		models.ResourceLaunchTemplate: {
+			APIVersion: "ec2.aws.crossplane.io/v1alpha1",
+			Kind:       "LaunchTemplate",
+		},
+		models.ResourceAutoScalingGroup: {
+			APIVersion: "autoscaling.aws.crossplane.io/v1beta1",
+			Kind:       "AutoScalingGroup",
+		},
+		models.ResourceEKSAddon: {
+			APIVersion: "eks.aws.crossplane.io/v1alpha1",
+			Kind:       "Addon",
+		},
+		models.ResourceFargateProfile: {
+			APIVersion: "eks.aws.crossplane.io/v1alpha1",
+			Kind:       "FargateProfile",
+		},
 	}
 
 	if mapping, ok := mapping[resourceType]; ok {
@@ -303,6 +408,12 @@ func mapPropertyName(propName string) string {
 		"role_arn":             "roleArn",
 		"endpoint_public_access": "endpointPublicAccess",
 		"endpoint_private_access": "endpointPrivateAccess",
+		"desired_capacity":     "desiredCapacity",
+		"min_size":             "minSize",
+		"max_size":             "maxSize",
+		"health_check_type":    "healthCheckType",
+		"image_id":             "imageId",
+		"user_data":            "userData",
 	}
 
 	if mapped, ok := mapping[propName]; ok {
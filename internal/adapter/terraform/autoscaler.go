@@ -0,0 +1,88 @@
+package terraform
+
+import (
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// clusterAutoscaler reports whether the Cluster Autoscaler scales the EKS cluster's node
+// groups. Its IAM role is one of the controllers'.
+func (g *TerraformGenerator) clusterAutoscaler() bool {
+	if g.Model == nil {
+		return false
+	}
+	for _, resource := range g.Model.Resources {
+		if resource.Type != models.ResourceEKSCluster {
+			continue
+		}
+		if value, ok := resource.GetProperty("autoscaling"); ok && value == infra.AutoscalingClusterAutoscaler {
+			return true
+		}
+	}
+	return false
+}
+
+// clusterAutoscalerTags are the tags the Cluster Autoscaler discovers the node groups by, in
+// the syntax of a map of the EKS module. EKS tags the node groups' Auto Scaling groups the
+// same way.
+const clusterAutoscalerTags = `{
+      "k8s.io/cluster-autoscaler/enabled"             = "true"
+      "k8s.io/cluster-autoscaler/${var.cluster_name}" = "owned"
+    }`
+
+// clusterAutoscalerNodeGroup returns the tags and lifecycle of the EKS module's node groups
+// that the Cluster Autoscaler scales, which sets their desired sizes; none without it
+func (g *TerraformGenerator) clusterAutoscalerNodeGroup() (tags, lifecycle string) {
+	if !g.clusterAutoscaler() {
+		return "", ""
+	}
+	return `,
+    # Tags the Cluster Autoscaler discovers the node group by
+    ` + clusterAutoscalerTags, `
+
+  # The Cluster Autoscaler sets the desired size
+  lifecycle {
+    ignore_changes = [scaling_config[0].desired_size]
+  }`
+}
+
+// registryNodeGroupTags returns the tags of the registry EKS module's node groups: their
+// additional tags, and those the Cluster Autoscaler discovers them by when it scales them
+func (g *TerraformGenerator) registryNodeGroupTags() string {
+	if !g.clusterAutoscaler() {
+		return "group.additional_tags"
+	}
+	return "merge(group.additional_tags, " + strings.ReplaceAll(clusterAutoscalerTags, "\n", "\n    ") + ")"
+}
+
+// clusterAutoscalerOutputs returns the root output with the values of the Cluster
+// Autoscaler's Helm chart, discovering the node groups by their tags and running under the
+// IAM role of its service account
+func (g *TerraformGenerator) clusterAutoscalerOutputs() string {
+	return `# Cluster Autoscaler Outputs
+output "cluster_autoscaler_helm_values" {
+  description = "Values of the Cluster Autoscaler Helm chart, for helm install -f"
+  value = yamlencode({
+    autoDiscovery = {
+      clusterName = ` + g.moduleOutput("eks", "cluster_id") + `
+    }
+    awsRegion = var.aws_region
+    rbac = {
+      serviceAccount = {
+        name = "cluster-autoscaler"
+        annotations = {
+          "eks.amazonaws.com/role-arn" = ` + g.moduleOutput("eks", "controller_role_arns") + `["cluster-autoscaler"]
+        }
+      }
+    }
+    extraArgs = {
+      balance-similar-node-groups = true
+      skip-nodes-with-system-pods = false
+    }
+  })
+}
+
+`
+}
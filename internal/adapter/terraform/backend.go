@@ -0,0 +1,164 @@
+package terraform
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// BackendTypes are the backends the generated configuration can keep its state in
+var BackendTypes = []string{"local", "s3"}
+
+// BackendBootstrapDir is the directory, under the output directory, of the configuration
+// creating the S3 bucket and DynamoDB table of an s3 backend
+const BackendBootstrapDir = "backend-bootstrap"
+
+// ValidateBackend checks a backend type and its settings. The bootstrap configuration
+// creates the bucket of an s3 backend, so it needs the bucket's name.
+func ValidateBackend(backendType string, settings map[string]string, bootstrap bool) error {
+	if !contains(BackendTypes, backendType) {
+		return fmt.Errorf("invalid backend: %s (supported backends: %s)", backendType, strings.Join(BackendTypes, ", "))
+	}
+	for key := range settings {
+		if !hclIdentifierPattern.MatchString(key) {
+			return fmt.Errorf("invalid backend setting: %q", key)
+		}
+	}
+	if bootstrap {
+		if backendType != "s3" {
+			return fmt.Errorf("a backend bootstrap configuration can only be generated for the s3 backend")
+		}
+		if settings["bucket"] == "" {
+			return fmt.Errorf("the s3 backend bootstrap configuration needs the bucket setting")
+		}
+	}
+	return nil
+}
+
+// GenerateBackendBootstrap writes the configuration creating the S3 bucket, and the DynamoDB
+// lock table when one is set, of an s3 backend to dir. It keeps its own state locally, so it
+// can be applied before the backend exists.
+func GenerateBackendBootstrap(dir string, config *TerraformConfig, model *models.InfrastructureModel) error {
+	bucket := config.BackendConfig["bucket"]
+	region := config.BackendConfig["region"]
+	if region == "" {
+		region = config.AwsRegion
+	}
+	encryption := "AES256"
+	kmsKey := ""
+	if key := config.BackendConfig["kms_key_id"]; key != "" {
+		encryption = "aws:kms"
+		kmsKey = fmt.Sprintf("\n      kms_master_key_id = %q", key)
+	}
+
+	var main strings.Builder
+	fmt.Fprintf(&main, `# Creates the S3 bucket keeping the Terraform state of the generated configuration and the
+# DynamoDB table locking it. Apply this configuration once, before terraform init there.
+terraform {
+  required_version = ">= %s"
+
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "%s"
+    }
+  }
+}
+
+provider "aws" {
+  region = %q
+
+  default_tags {
+    tags = %s
+  }
+}
+
+resource "aws_s3_bucket" "state" {
+  bucket = %q
+
+  lifecycle {
+    prevent_destroy = true
+  }
+}
+
+resource "aws_s3_bucket_versioning" "state" {
+  bucket = aws_s3_bucket.state.id
+
+  versioning_configuration {
+    status = "Enabled"
+  }
+}
+
+resource "aws_s3_bucket_server_side_encryption_configuration" "state" {
+  bucket = aws_s3_bucket.state.id
+
+  rule {
+    apply_server_side_encryption_by_default {
+      sse_algorithm = %q%s
+    }
+  }
+}
+
+resource "aws_s3_bucket_public_access_block" "state" {
+  bucket = aws_s3_bucket.state.id
+
+  block_public_acls       = true
+  block_public_policy     = true
+  ignore_public_acls      = true
+  restrict_public_buckets = true
+}
+`, config.TerraformVersion, config.ProviderConstraint, region, formatTagMap(defaultTags(config, model), "    "), bucket, encryption, kmsKey)
+
+	outputs := `output "state_bucket" {
+  description = "The name of the S3 bucket keeping the Terraform state"
+  value       = aws_s3_bucket.state.id
+}
+`
+	if table := config.BackendConfig["dynamodb_table"]; table != "" {
+		fmt.Fprintf(&main, `
+resource "aws_dynamodb_table" "lock" {
+  name         = %q
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "LockID"
+
+  attribute {
+    name = "LockID"
+    type = "S"
+  }
+
+  point_in_time_recovery {
+    enabled = true
+  }
+}
+`, table)
+		outputs += `
+output "lock_table" {
+  description = "The name of the DynamoDB table locking the Terraform state"
+  value       = aws_dynamodb_table.lock.name
+}
+`
+	}
+
+	if err := utils.EnsureDirectoryExists(dir); err != nil {
+		return err
+	}
+	if err := utils.WriteToFile(filepath.Join(dir, "main.tf"), main.String()); err != nil {
+		return fmt.Errorf("failed to write the backend bootstrap configuration: %w", err)
+	}
+	if err := utils.WriteToFile(filepath.Join(dir, "outputs.tf"), outputs); err != nil {
+		return fmt.Errorf("failed to write the backend bootstrap outputs: %w", err)
+	}
+	if config.FormatOutput {
+		if err := formatDirectory(dir, time.Time{}); err != nil {
+			return err
+		}
+	}
+	if err := lockProviders(dir, config); err != nil {
+		return fmt.Errorf("failed to write the backend bootstrap %s: %w", LockFile, err)
+	}
+	return nil
+}
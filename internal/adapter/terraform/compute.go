@@ -0,0 +1,413 @@
+package terraform
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// generateComputeFile generates compute.tf with launch templates, Auto Scaling Groups and EC2 instances
+func (g *TerraformGenerator) generateComputeFile() (string, error) {
+	if g.Model == nil {
+		return "", nil
+	}
+
+	var launchTemplates, autoScalingGroups, instances []models.Resource
+	for _, resource := range g.Model.Resources {
+		switch resource.Type {
+		case models.ResourceLaunchTemplate:
+			launchTemplates = append(launchTemplates, resource)
+		case models.ResourceAutoScalingGroup:
+			autoScalingGroups = append(autoScalingGroups, resource)
+		case models.ResourceEC2Instance:
+			instances = append(instances, resource)
+		}
+	}
+
+	if len(launchTemplates) == 0 && len(autoScalingGroups) == 0 && len(instances) == 0 {
+		return "", nil
+	}
+
+	var content strings.Builder
+	needsSubnetVar := false
+	needsTargetGroupVar := false
+
+	// Launch templates
+	for _, lt := range launchTemplates {
+		content.WriteString(fmt.Sprintf("resource \"aws_launch_template\" %q {\n", terraformName(lt.Name)))
+		content.WriteString(g.providerArgument(&lt))
+		content.WriteString(fmt.Sprintf("  name_prefix   = %q\n", stringProperty(lt, "name_prefix", lt.Name+"-")))
+		content.WriteString(fmt.Sprintf("  image_id      = %q\n", stringProperty(lt, "image_id", "")))
+		content.WriteString(fmt.Sprintf("  instance_type = %q\n", stringProperty(lt, "instance_type", "t3.micro")))
+
+		if userData := stringProperty(lt, "user_data", ""); userData != "" {
+			// Escape template interpolation so the script is passed through verbatim
+			userData = strings.ReplaceAll(userData, "${", "$${")
+			content.WriteString("  user_data     = base64encode(<<-EOT\n")
+			for _, line := range strings.Split(strings.TrimRight(userData, "\n"), "\n") {
+				content.WriteString("    " + line + "\n")
+			}
+			content.WriteString("  EOT\n  )\n")
+		}
+
+		content.WriteString(`
+  tag_specifications {
+    resource_type = "instance"
+
+    tags = {
+      Name = "` + lt.Name + `"
+    }
+  }
+
+  lifecycle {
+    create_before_destroy = true
+  }
+}
+
+`)
+	}
+
+	// Auto Scaling Groups
+	for _, asg := range autoScalingGroups {
+		content.WriteString(fmt.Sprintf("resource \"aws_autoscaling_group\" %q {\n", terraformName(asg.Name)))
+		content.WriteString(g.providerArgument(&asg))
+		content.WriteString(fmt.Sprintf("  name                = %q\n", stringProperty(asg, "name", asg.Name)))
+		content.WriteString(fmt.Sprintf("  desired_capacity    = %d\n", intProperty(asg, "desired_capacity", 2)))
+		content.WriteString(fmt.Sprintf("  min_size            = %d\n", intProperty(asg, "min_size", 1)))
+		content.WriteString(fmt.Sprintf("  max_size            = %d\n", intProperty(asg, "max_size", 4)))
+		content.WriteString(fmt.Sprintf("  health_check_type   = %q\n", stringProperty(asg, "health_check_type", "EC2")))
+
+		// Subnets come from the VPC module of the group's region when it is generated
+		subnetRef := "var.asg_subnet_ids"
+		if g.hasVPCModuleFor(&asg) {
+			subnetRef = g.moduleOutput(g.vpcModuleLabel(&asg), "private_subnet_ids")
+			if subnets, ok := asg.GetProperty("vpc_zone_identifier"); ok {
+				if ids, ok := subnets.([]string); ok && len(ids) > 0 && strings.HasPrefix(ids[0], "public-") {
+					subnetRef = g.moduleOutput(g.vpcModuleLabel(&asg), "public_subnet_ids")
+				}
+			}
+		} else {
+			needsSubnetVar = true
+		}
+		content.WriteString(fmt.Sprintf("  vpc_zone_identifier = %s\n", subnetRef))
+
+		if attach, ok := asg.GetProperty("attach_to_alb"); ok && attach == true {
+			content.WriteString("  target_group_arns   = var.asg_target_group_arns\n")
+			needsTargetGroupVar = true
+		}
+
+		ltRef := terraformName(stringProperty(asg, "launch_template", ""))
+		content.WriteString(fmt.Sprintf(`
+  launch_template {
+    id      = aws_launch_template.%s.id
+    version = aws_launch_template.%s.latest_version
+  }
+`, ltRef, ltRef))
+
+		if refresh, ok := asg.GetProperty("instance_refresh"); ok {
+			if settings, ok := refresh.(map[string]interface{}); ok {
+				content.WriteString(fmt.Sprintf(`
+  instance_refresh {
+    strategy = %q
+
+    preferences {
+      min_healthy_percentage = %v
+    }
+  }
+`, settings["strategy"], settings["min_healthy_percentage"]))
+			}
+		}
+
+		content.WriteString(fmt.Sprintf(`
+  tag {
+    key                 = "Name"
+    value               = %q
+    propagate_at_launch = true
+  }
+}
+
+`, asg.Name))
+	}
+
+	if len(instances) > 0 {
+		content.WriteString(g.generateInstanceBlocks(instances))
+	}
+
+	if needsSubnetVar {
+		content.WriteString(`variable "asg_subnet_ids" {
+  description = "List of subnet IDs for the Auto Scaling Groups"
+  type        = list(string)
+}
+
+`)
+	}
+
+	if needsTargetGroupVar {
+		content.WriteString(`variable "asg_target_group_arns" {
+  description = "ALB target group ARNs the Auto Scaling Groups register with"
+  type        = list(string)
+  default     = []
+}
+
+`)
+	}
+
+	return content.String(), nil
+}
+
+// generateInstanceBlocks generates AMI data sources, security groups, key pairs and
+// aws_instance resources for standalone EC2 instances
+func (g *TerraformGenerator) generateInstanceBlocks(instances []models.Resource) string {
+	var content strings.Builder
+
+	// One data source per distinct AMI lookup in each region, since AMI IDs differ between regions
+	lookups := make(map[string]map[string]interface{})
+	lookupProviders := make(map[string]string)
+	for i, instance := range instances {
+		if lookup := mapPropertyValue(instance, "ami_lookup"); lookup != nil {
+			label := g.regionalLabel(fmt.Sprint(lookup["data_source"]), &instances[i])
+			lookups[label] = lookup
+			lookupProviders[label] = g.providerArgument(&instances[i])
+		}
+	}
+	lookupNames := make([]string, 0, len(lookups))
+	for name := range lookups {
+		lookupNames = append(lookupNames, name)
+	}
+	sort.Strings(lookupNames)
+
+	for _, name := range lookupNames {
+		lookup := lookups[name]
+		content.WriteString(fmt.Sprintf(`data "aws_ami" %q {
+%s  most_recent = true
+  owners      = [%q]
+
+  filter {
+    name   = "name"
+    values = [%q]
+  }
+
+  filter {
+    name   = "architecture"
+    values = [%q]
+  }
+
+  filter {
+    name   = "virtualization-type"
+    values = ["hvm"]
+  }
+}
+
+`, name, lookupProviders[name], lookup["owner"], lookup["name_filter"], lookup["architecture"]))
+	}
+
+	// Security groups attached to the instances
+	securityGroups := make(map[string]bool)
+	for _, instance := range instances {
+		for _, sg := range stringSliceProperty(instance, "vpc_security_group_ids") {
+			securityGroups[sg] = true
+		}
+	}
+	for _, resource := range g.Model.Resources {
+		if resource.Type != models.ResourceSecurityGroup || !securityGroups[resource.Name] || !g.hasVPCModuleFor(&resource) {
+			continue
+		}
+		content.WriteString(g.generateSecurityGroupBlock(resource))
+	}
+
+	// Key pairs are created from a public key supplied at apply time, in each region using them
+	keyNames := make(map[string]bool)
+	for i, instance := range instances {
+		keyName := stringProperty(instance, "key_name", "")
+		label := g.regionalLabel(terraformName(keyName), &instances[i])
+		if keyName != "" && !keyNames[label] {
+			keyNames[label] = true
+			content.WriteString(fmt.Sprintf(`resource "aws_key_pair" %q {
+%s  key_name   = %q
+  public_key = var.key_pair_public_key
+}
+
+`, label, g.providerArgument(&instances[i]), keyName))
+		}
+	}
+
+	for i, instance := range instances {
+		hasVPC := g.hasVPCModuleFor(&instances[i])
+		content.WriteString(fmt.Sprintf("resource \"aws_instance\" %q {\n", terraformName(instance.Name)))
+		content.WriteString(g.providerArgument(&instances[i]))
+
+		if lookup := mapPropertyValue(instance, "ami_lookup"); lookup != nil {
+			content.WriteString(fmt.Sprintf("  ami                    = data.aws_ami.%s.id\n", g.regionalLabel(fmt.Sprint(lookup["data_source"]), &instances[i])))
+		} else {
+			content.WriteString(fmt.Sprintf("  ami                    = %q\n", stringProperty(instance, "ami", "")))
+		}
+		content.WriteString(fmt.Sprintf("  instance_type          = %q\n", stringProperty(instance, "instance_type", "t3.micro")))
+
+		if subnetID := stringProperty(instance, "subnet_id", ""); subnetID != "" && hasVPC {
+			// Subnets copied into a secondary region are named with the region as a suffix
+			if g.regionAlias(&instances[i]) != "" {
+				subnetID = strings.TrimSuffix(subnetID, infra.RegionSuffix(g.Model.ResourceRegion(&instances[i])))
+			}
+			content.WriteString(fmt.Sprintf("  subnet_id              = %s\n", g.moduleSubnetReference(g.vpcModuleLabel(&instances[i]), subnetID)))
+		}
+
+		var sgRefs []string
+		for _, sg := range stringSliceProperty(instance, "vpc_security_group_ids") {
+			if hasVPC {
+				sgRefs = append(sgRefs, fmt.Sprintf("aws_security_group.%s.id", terraformName(sg)))
+			}
+		}
+		if len(sgRefs) > 0 {
+			content.WriteString(fmt.Sprintf("  vpc_security_group_ids = [%s]\n", strings.Join(sgRefs, ", ")))
+		}
+
+		if keyName := stringProperty(instance, "key_name", ""); keyName != "" {
+			content.WriteString(fmt.Sprintf("  key_name               = aws_key_pair.%s.key_name\n", g.regionalLabel(terraformName(keyName), &instances[i])))
+		}
+
+		if public, ok := instance.GetProperty("associate_public_ip_address"); ok && public == true {
+			content.WriteString("  associate_public_ip_address = true\n")
+		}
+
+		if options := mapPropertyValue(instance, "metadata_options"); options != nil {
+			content.WriteString(fmt.Sprintf(`
+  metadata_options {
+    http_endpoint = "enabled"
+    http_tokens   = %q
+  }
+`, options["http_tokens"]))
+		}
+
+		content.WriteString(fmt.Sprintf(`
+  tags = {
+    Name = %q
+  }
+}
+
+`, instance.Name))
+	}
+
+	if len(keyNames) > 0 {
+		content.WriteString(`variable "key_pair_public_key" {
+  description = "Public key material for the EC2 key pair"
+  type        = string
+}
+
+`)
+	}
+
+	return content.String()
+}
+
+// generateSecurityGroupBlock generates an aws_security_group resource in the VPC of the VPC
+// module in its region
+func (g *TerraformGenerator) generateSecurityGroupBlock(sg models.Resource) string {
+	var content strings.Builder
+	content.WriteString(fmt.Sprintf("resource \"aws_security_group\" %q {\n", terraformName(sg.Name)))
+	content.WriteString(g.providerArgument(&sg))
+	content.WriteString(fmt.Sprintf("  name        = %q\n", sg.Name+g.Config.NameSuffix))
+	content.WriteString(fmt.Sprintf("  description = %q\n", stringProperty(sg, "description", "Managed by iacgen")))
+	content.WriteString(fmt.Sprintf("  vpc_id      = %s\n", g.moduleOutput(g.vpcModuleLabel(&sg), "vpc_id")))
+
+	for _, ruleType := range []string{"ingress", "egress"} {
+		value, ok := sg.GetProperty(ruleType)
+		if !ok {
+			continue
+		}
+		rules, ok := value.([]map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, rule := range rules {
+			cidrs := make([]string, 0)
+			if blocks, ok := rule["cidr_blocks"].([]string); ok {
+				for _, block := range blocks {
+					cidrs = append(cidrs, strconv.Quote(block))
+				}
+			}
+			content.WriteString(fmt.Sprintf(`
+  %s {
+    from_port   = %v
+    to_port     = %v
+    protocol    = %q
+    cidr_blocks = [%s]
+  }
+`, ruleType, rule["from_port"], rule["to_port"], rule["protocol"], strings.Join(cidrs, ", ")))
+		}
+	}
+
+	content.WriteString(fmt.Sprintf(`
+  tags = {
+    Name = %q
+  }
+}
+
+`, sg.Name))
+	return content.String()
+}
+
+// moduleSubnetReference converts a model subnet name such as private-subnet-2 into an output
+// reference of a VPC module call
+func (g *TerraformGenerator) moduleSubnetReference(module, subnetName string) string {
+	output := "private_subnet_ids"
+	if strings.HasPrefix(subnetName, "public-") {
+		output = "public_subnet_ids"
+	}
+
+	index := 0
+	if pos := strings.LastIndex(subnetName, "-"); pos >= 0 {
+		if n, err := strconv.Atoi(subnetName[pos+1:]); err == nil && n > 0 {
+			index = n - 1
+		}
+	}
+	return fmt.Sprintf("%s[%d]", g.moduleOutput(module, output), index)
+}
+
+// terraformName converts a resource name into a valid Terraform identifier
+func terraformName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "-", "_")
+}
+
+// stringProperty returns a string property of a resource or the default value
+func stringProperty(resource models.Resource, name string, defaultValue string) string {
+	if value, ok := resource.GetProperty(name); ok {
+		if str, ok := value.(string); ok {
+			return str
+		}
+	}
+	return defaultValue
+}
+
+// intProperty returns an integer property of a resource or the default value
+func intProperty(resource models.Resource, name string, defaultValue int) int {
+	if value, ok := resource.GetProperty(name); ok {
+		if i, ok := value.(int); ok {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+// stringSliceProperty returns a string slice property of a resource
+func stringSliceProperty(resource models.Resource, name string) []string {
+	if value, ok := resource.GetProperty(name); ok {
+		if values, ok := value.([]string); ok {
+			return values
+		}
+	}
+	return nil
+}
+
+// mapPropertyValue returns a map property of a resource or nil
+func mapPropertyValue(resource models.Resource, name string) map[string]interface{} {
+	if value, ok := resource.GetProperty(name); ok {
+		if m, ok := value.(map[string]interface{}); ok {
+			return m
+		}
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package terraform
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// controllerPolicies are the statements of the IAM policies of the controllers, in the
+// jsonencode syntax of the module's controllers.tf. Those scaling the node groups are limited
+// to the Auto Scaling groups EKS tags as the cluster's.
+var controllerPolicies = map[string]string{
+	"aws-load-balancer-controller": `{
+            Effect   = "Allow"
+            Action   = "iam:CreateServiceLinkedRole"
+            Resource = "*"
+            Condition = {
+              StringEquals = {
+                "iam:AWSServiceName" = "elasticloadbalancing.amazonaws.com"
+              }
+            }
+          },
+          {
+            Effect = "Allow"
+            Action = [
+              "ec2:DescribeAccountAttributes",
+              "ec2:DescribeAddresses",
+              "ec2:DescribeAvailabilityZones",
+              "ec2:DescribeInternetGateways",
+              "ec2:DescribeVpcs",
+              "ec2:DescribeVpcPeeringConnections",
+              "ec2:DescribeSubnets",
+              "ec2:DescribeSecurityGroups",
+              "ec2:DescribeInstances",
+              "ec2:DescribeNetworkInterfaces",
+              "ec2:DescribeTags",
+              "ec2:GetCoipPoolUsage",
+              "ec2:DescribeCoipPools",
+              "elasticloadbalancing:Describe*",
+              "acm:ListCertificates",
+              "acm:DescribeCertificate",
+              "iam:ListServerCertificates",
+              "iam:GetServerCertificate",
+              "cognito-idp:DescribeUserPoolClient",
+              "waf-regional:GetWebACL",
+              "waf-regional:GetWebACLForResource",
+              "waf-regional:AssociateWebACL",
+              "waf-regional:DisassociateWebACL",
+              "wafv2:GetWebACL",
+              "wafv2:GetWebACLForResource",
+              "wafv2:AssociateWebACL",
+              "wafv2:DisassociateWebACL",
+              "shield:GetSubscriptionState",
+              "shield:DescribeProtection",
+              "shield:CreateProtection",
+              "shield:DeleteProtection",
+            ]
+            Resource = "*"
+          },
+          {
+            Effect = "Allow"
+            Action = [
+              "ec2:AuthorizeSecurityGroupIngress",
+              "ec2:RevokeSecurityGroupIngress",
+              "ec2:CreateSecurityGroup",
+              "ec2:DeleteSecurityGroup",
+              "ec2:CreateTags",
+              "ec2:DeleteTags",
+            ]
+            Resource = "*"
+          },
+          {
+            Effect = "Allow"
+            Action = [
+              "elasticloadbalancing:CreateLoadBalancer",
+              "elasticloadbalancing:CreateTargetGroup",
+              "elasticloadbalancing:CreateListener",
+              "elasticloadbalancing:CreateRule",
+              "elasticloadbalancing:DeleteLoadBalancer",
+              "elasticloadbalancing:DeleteTargetGroup",
+              "elasticloadbalancing:DeleteListener",
+              "elasticloadbalancing:DeleteRule",
+              "elasticloadbalancing:ModifyLoadBalancerAttributes",
+              "elasticloadbalancing:ModifyTargetGroup",
+              "elasticloadbalancing:ModifyTargetGroupAttributes",
+              "elasticloadbalancing:ModifyListener",
+              "elasticloadbalancing:ModifyRule",
+              "elasticloadbalancing:AddTags",
+              "elasticloadbalancing:RemoveTags",
+              "elasticloadbalancing:RegisterTargets",
+              "elasticloadbalancing:DeregisterTargets",
+              "elasticloadbalancing:SetIpAddressType",
+              "elasticloadbalancing:SetSecurityGroups",
+              "elasticloadbalancing:SetSubnets",
+              "elasticloadbalancing:SetWebAcl",
+              "elasticloadbalancing:AddListenerCertificates",
+              "elasticloadbalancing:RemoveListenerCertificates",
+            ]
+            Resource = "*"
+          },`,
+	"external-dns": `{
+            Effect   = "Allow"
+            Action   = "route53:ChangeResourceRecordSets"
+            Resource = "arn:aws:route53:::hostedzone/*"
+          },
+          {
+            Effect = "Allow"
+            Action = [
+              "route53:ListHostedZones",
+              "route53:ListResourceRecordSets",
+              "route53:ListTagsForResource",
+            ]
+            Resource = "*"
+          },`,
+	"cluster-autoscaler": `{
+            Effect = "Allow"
+            Action = [
+              "autoscaling:DescribeAutoScalingGroups",
+              "autoscaling:DescribeAutoScalingInstances",
+              "autoscaling:DescribeLaunchConfigurations",
+              "autoscaling:DescribeScalingActivities",
+              "autoscaling:DescribeTags",
+              "ec2:DescribeImages",
+              "ec2:DescribeInstanceTypes",
+              "ec2:DescribeLaunchTemplateVersions",
+              "ec2:GetInstanceTypesFromInstanceRequirements",
+              "eks:DescribeNodegroup",
+            ]
+            Resource = "*"
+          },
+          {
+            Effect = "Allow"
+            Action = [
+              "autoscaling:SetDesiredCapacity",
+              "autoscaling:TerminateInstanceInAutoScalingGroup",
+            ]
+            Resource = "*"
+            Condition = {
+              StringEquals = {
+                "aws:ResourceTag/k8s.io/cluster-autoscaler/${var.cluster_name}" = "owned"
+              }
+            }
+          },`,
+}
+
+// eksControllers returns the controllers of the EKS cluster that get an IAM role for their
+// service account, in the order they were described
+func (g *TerraformGenerator) eksControllers() []infra.EKSController {
+	if g.Model == nil {
+		return nil
+	}
+	var controllers []infra.EKSController
+	for _, resource := range g.Model.Resources {
+		if resource.Type != models.ResourceEKSCluster {
+			continue
+		}
+		names, _ := resource.GetProperty("controllers")
+		list, _ := names.([]string)
+		for _, name := range list {
+			if controller, ok := infra.LookupEKSController(name); ok {
+				controllers = append(controllers, controller)
+			}
+		}
+	}
+	return controllers
+}
+
+// generateEksModuleControllersFile generates the EKS module controllers.tf: an IAM role for
+// the service account of each controller, trusted through the cluster's OIDC provider, with
+// the policy the controller needs
+func (g *TerraformGenerator) generateEksModuleControllersFile() (string, error) {
+	var controllers strings.Builder
+	for _, controller := range g.eksControllers() {
+		controllers.WriteString(`    ` + strconv.Quote(controller.Name) + ` = {
+      service_account = ` + strconv.Quote(controller.ServiceAccount) + `
+      policy = jsonencode({
+        Version = "2012-10-17"
+        Statement = [
+          ` + controllerPolicies[controller.Name] + `
+        ]
+      })
+    }
+`)
+	}
+
+	return `# IAM roles for the service accounts of controllers that call AWS APIs (IRSA)
+locals {
+  irsa_controllers = {
+` + controllers.String() + `  }
+}
+
+data "aws_iam_policy_document" "controller_assume_role_policy" {
+  for_each = local.irsa_controllers
+
+  statement {
+    actions = ["sts:AssumeRoleWithWebIdentity"]
+    effect  = "Allow"
+
+    condition {
+      test     = "StringEquals"
+      variable = "${replace(aws_iam_openid_connect_provider.this.url, "https://", "")}:sub"
+      values   = ["system:serviceaccount:${replace(each.value.service_account, "/", ":")}"]
+    }
+
+    condition {
+      test     = "StringEquals"
+      variable = "${replace(aws_iam_openid_connect_provider.this.url, "https://", "")}:aud"
+      values   = ["sts.amazonaws.com"]
+    }
+
+    principals {
+      identifiers = [aws_iam_openid_connect_provider.this.arn]
+      type        = "Federated"
+    }
+  }
+}
+
+resource "aws_iam_role" "controller" {
+  for_each = local.irsa_controllers
+
+  name               = "${var.cluster_name}-${each.key}"
+  assume_role_policy = data.aws_iam_policy_document.controller_assume_role_policy[each.key].json
+
+  tags = merge(var.tags, {
+    Name = "${var.cluster_name}-${each.key}"
+  })
+}
+
+resource "aws_iam_role_policy" "controller" {
+  for_each = local.irsa_controllers
+
+  name   = "${var.cluster_name}-${each.key}"
+  role   = aws_iam_role.controller[each.key].id
+  policy = each.value.policy
+}
+`, nil
+}
+
+// controllerModuleOutputs is the output of the EKS module for the controllers, appended to
+// its outputs.tf when the cluster has any
+const controllerModuleOutputs = `
+output "controller_role_arns" {
+  description = "Map of controller names to the IAM role ARNs of their service accounts"
+  value       = { for name, role in aws_iam_role.controller : name => role.arn }
+}
+`
+
+// registryControllerModule returns the block of the registry module creating the IAM roles
+// of the controllers' service accounts, with the module's policy for each controller
+func (g *TerraformGenerator) registryControllerModule() string {
+	irsa := registryModules["irsa"]
+	var serviceAccounts strings.Builder
+	for _, controller := range g.eksControllers() {
+		serviceAccounts.WriteString("    " + strconv.Quote(controller.Name) + " = " + strconv.Quote(strings.ReplaceAll(controller.ServiceAccount, "/", ":")) + "\n")
+	}
+
+	return `# IAM roles for the service accounts of controllers that call AWS APIs (IRSA)
+module "controller_irsa" {
+  source  = "` + irsa.Source + `"
+  version = "` + irsa.Version + `"
+
+  # Each controller's service account, as namespace:name
+  for_each = {
+` + serviceAccounts.String() + `  }
+
+  role_name = "${var.cluster_name}-${each.key}"
+
+  attach_load_balancer_controller_policy = each.key == "aws-load-balancer-controller"
+  attach_external_dns_policy             = each.key == "external-dns"
+  attach_cluster_autoscaler_policy       = each.key == "cluster-autoscaler"
+  cluster_autoscaler_cluster_names       = [var.cluster_name]
+
+  oidc_providers = {
+    main = {
+      provider_arn               = ` + g.moduleOutput("eks", "oidc_provider_arn") + `
+      namespace_service_accounts = [each.value]
+    }
+  }
+
+  tags = var.eks_tags
+}
+
+`
+}
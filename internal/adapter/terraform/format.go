@@ -0,0 +1,67 @@
+package terraform
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// formatDirectory formats the Terraform and variables files written under dir since a time
+// the way terraform fmt does, skipping hidden directories such as .terraform. Files that were
+// there before, such as the user's own, are left alone, and so is a file that does not parse,
+// which is logged.
+func formatDirectory(dir string, since time.Time) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != dir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); ext != ".tf" && ext != ".tfvars" && !strings.HasPrefix(filepath.Base(path), "terraform.tfvars.") {
+			return nil
+		}
+
+		if info, err := entry.Info(); err != nil || info.ModTime().Before(since) {
+			return err
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if _, diags := hclwrite.ParseConfig(content, path, hcl.Pos{Line: 1, Column: 1}); diags.HasErrors() {
+			utils.GetLogger().Warnw("Not formatting a file that does not parse", "file", path, "error", diags.Error())
+			return nil
+		}
+		formatted := hclwrite.Format(content)
+		if bytes.Equal(formatted, content) {
+			return nil
+		}
+		return rewriteFile(path, string(formatted))
+	})
+}
+
+// rewriteFile replaces the content of a generated file. The file was reported when it was
+// written, so it is not reported again.
+func rewriteFile(path, content string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(content), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"fmt"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/riptano/iac_generator_cli/internal/infra"
 	"github.com/riptano/iac_generator_cli/internal/utils"
@@ -17,6 +21,11 @@ type TerraformGenerator struct {
 	OutputDir string
 	Model     *models.InfrastructureModel
 	Config    *TerraformConfig
+
+	// workspace is the workspace whose variables file is being generated
+	workspace *Workspace
+	// dataModule are the resources of the data module of the by-type layout
+	dataModule []models.Resource
 }
 
 // TerraformConfig holds Terraform-specific configuration
@@ -28,6 +37,39 @@ type TerraformConfig struct {
 	BackendConfig      map[string]string
 	TerraformVersion   string
 	ProviderConstraint string
+	// NameSuffix is appended to the VPC and cluster names, e.g. to keep a DR stack's IAM roles unique
+	NameSuffix         string
+	// Environment is the value of the Environment tag, e.g. "prod" for one of several environments
+	Environment        string
+	// BackendBootstrap writes the configuration creating the bucket and lock table of an s3
+	// backend to backend-bootstrap/
+	BackendBootstrap   bool
+	// ModuleSource is where the VPC and EKS modules come from: local generates them under
+	// modules/, registry uses the terraform-aws-modules ones
+	ModuleSource       string
+	// ModuleLayout is how the resources are split into modules: modules generates the vpc
+	// and eks modules, by-type the network, compute and data modules, and flat declares
+	// everything in the root module
+	ModuleLayout       string
+	// CustomModuleNames maps the modules (vpc, eks and data) to the names of their
+	// directories and calls, such as vpc to network
+	CustomModuleNames  map[string]string
+	// Workspaces get a variables file each under env/, with a Makefile selecting the
+	// workspace and its variables file
+	Workspaces         []Workspace
+	// Imports maps the addresses of generated resources to the IDs of the existing resources
+	// they adopt, written as import blocks to imports.tf
+	Imports            map[string]string
+	// Lifecycle maps Terraform resource types, such as aws_eks_cluster, to the lifecycle
+	// settings added to their resources
+	Lifecycle          map[string]Lifecycle
+	// FormatOutput formats the generated files as terraform fmt does
+	FormatOutput       bool
+	// LockPlatforms are the platforms whose provider checksums the dependency lock file
+	// records; none writes no lock file
+	LockPlatforms      []string
+	// TerraformPath is the terraform binary writing the lock file (default: terraform from PATH)
+	TerraformPath      string
 }
 
 // DefaultTerraformConfig returns a default configuration
@@ -40,7 +82,120 @@ func DefaultTerraformConfig() *TerraformConfig {
 		BackendConfig:      map[string]string{},
 		TerraformVersion:   "1.0.0",
 		ProviderConstraint: "~> 5.0",
+		Environment:        "dev",
+		ModuleSource:       ModuleSourceLocal,
+		ModuleLayout:       ModuleLayoutModules,
+	}
+}
+
+// environmentName returns the Environment tag value, defaulting to "dev"
+func (c *TerraformConfig) environmentName() string {
+	if c.Environment == "" {
+		return "dev"
+	}
+	return c.Environment
+}
+
+// workspaceDir is where the backend keeps the state of each environment's workspace
+const workspaceDir = "environments"
+
+// tfvarsFileName returns the name of the variables file. A model made for one environment
+// gets terraform.tfvars.<environment>, passed with -var-file in that environment's workspace,
+// so its values are never applied to another workspace by accident.
+func tfvarsFileName(model *models.InfrastructureModel) string {
+	if model != nil && model.Environment != "" {
+		return "terraform.tfvars." + model.Environment
+	}
+	return "terraform.tfvars"
+}
+
+// backendBlock returns the backend block of versions.tf, or an empty string for the default
+// local backend. A model made for one environment, like each of the configured workspaces,
+// keeps its state in a workspace named after the environment, so the backend is told to keep
+// workspace states under environments/.
+func backendBlock(config *TerraformConfig, model *models.InfrastructureModel) string {
+	settings := make(map[string]string, len(config.BackendConfig)+1)
+	for key, value := range config.BackendConfig {
+		settings[key] = value
+	}
+	if (model != nil && model.Environment != "") || len(config.Workspaces) > 0 {
+		switch config.BackendType {
+		case "local":
+			settings["workspace_dir"] = workspaceDir
+		case "s3":
+			settings["workspace_key_prefix"] = workspaceDir
+		}
+	}
+	if config.BackendType == "local" && len(settings) == 0 {
+		return ""
+	}
+	// The state bucket is in the configured region unless another is set
+	if config.BackendType == "s3" && settings["region"] == "" && config.AwsRegion != "" {
+		settings["region"] = config.AwsRegion
+	}
+
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var block strings.Builder
+	fmt.Fprintf(&block, "\n  backend %q {\n", config.BackendType)
+	for _, key := range keys {
+		fmt.Fprintf(&block, "    %s = %q\n", key, settings[key])
+	}
+	block.WriteString("  }\n")
+	return block.String()
+}
+
+// hclIdentifierPattern matches map keys that can be written without quotes
+var hclIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// defaultTags returns the tags the provider applies to every resource: the generator's own
+// tags, overridden by the tags set on the model
+func defaultTags(config *TerraformConfig, model *models.InfrastructureModel) map[string]string {
+	tags := map[string]string{
+		"Environment": config.environmentName(),
+		"ManagedBy":   "terraform",
+		"Project":     "iac-generator",
+	}
+	if model != nil {
+		for key, value := range model.Tags {
+			tags[key] = value
+		}
+	}
+	return tags
+}
+
+// formatTagMap renders tags as an HCL map with aligned keys, sorted by key. The closing
+// brace is written at indent and the entries one level deeper.
+func formatTagMap(tags map[string]string, indent string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+
+	names := make([]string, len(keys))
+	width := 0
+	for i, key := range keys {
+		names[i] = key
+		if !hclIdentifierPattern.MatchString(key) {
+			names[i] = strconv.Quote(key)
+		}
+		if len(names[i]) > width {
+			width = len(names[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("{\n")
+	for i, key := range keys {
+		buf.WriteString(fmt.Sprintf("%s  %-*s = %s\n", indent, width, names[i], strconv.Quote(tags[key])))
+	}
+	buf.WriteString(indent + "}")
+	return buf.String()
 }
 
 // NewTerraformGenerator creates a new TerraformGenerator
@@ -65,7 +220,24 @@ func (g *TerraformGenerator) WithConfig(config *TerraformConfig) *TerraformGener
 
 // Generate generates Terraform HCL from an infrastructure model
 func (g *TerraformGenerator) Generate(model *models.InfrastructureModel) (string, error) {
+	sorted, sortErr := model.Sorted()
+	if sortErr != nil {
+		return "", sortErr
+	}
+	model = sorted
 	g.Model = model
+	// The provider and variables are for the model's primary region, as its resources are
+	if model.Region != "" {
+		g.Config.AwsRegion = model.Region
+	}
+	// Files written within the second before are formatted too, as modification times may
+	// only have a resolution of a second
+	started := time.Now().Truncate(time.Second)
+
+	g.dataModule = g.dataModuleResources()
+	if err := g.checkLayout(); err != nil {
+		return "", err
+	}
 
 	// Create directory structure
 	if err := g.createDirectoryStructure(); err != nil {
@@ -77,13 +249,64 @@ func (g *TerraformGenerator) Generate(model *models.InfrastructureModel) (string
 		return "", fmt.Errorf("failed to generate root module files: %w", err)
 	}
 
-	// Generate module files
-	if g.Config.CreateModules {
+	// Generate module files, unless the modules come from the registry or are flattened
+	if len(g.localModules()) > 0 {
 		if err := g.generateModuleFiles(); err != nil {
 			return "", fmt.Errorf("failed to generate module files: %w", err)
 		}
 	}
 
+	// Check that the provider version allows the features of the generated resources
+	if err := checkProviderVersion(g.OutputDir, g.Config.ProviderConstraint); err != nil {
+		return "", err
+	}
+
+	// Mark the outputs carrying secrets as sensitive
+	if err := markSensitiveOutputs(g.OutputDir, started); err != nil {
+		return "", err
+	}
+
+	// Add the configured lifecycle settings to the resources
+	if err := applyLifecycle(g.OutputDir, g.Config.Lifecycle); err != nil {
+		return "", err
+	}
+
+	// Generate the import blocks adopting existing resources
+	if err := writeImportsFile(g.OutputDir, g.Config.Imports); err != nil {
+		return "", err
+	}
+
+	// Record the generation, moving the resources renamed since the previous one
+	if err := recordGeneration(g.OutputDir, g.Model); err != nil {
+		return "", err
+	}
+
+	// Generate the variables files of the workspaces
+	if len(g.Config.Workspaces) > 0 {
+		if err := g.generateWorkspaceFiles(); err != nil {
+			return "", err
+		}
+	}
+
+	// Generate the configuration creating the state backend
+	if g.Config.BackendBootstrap {
+		if err := GenerateBackendBootstrap(filepath.Join(g.OutputDir, BackendBootstrapDir), g.Config, g.Model); err != nil {
+			return "", err
+		}
+	}
+
+	// Format the generated files
+	if g.Config.FormatOutput {
+		if err := formatDirectory(g.OutputDir, started); err != nil {
+			return "", fmt.Errorf("failed to format the generated files: %w", err)
+		}
+	}
+
+	// Lock the providers of the configuration
+	if err := lockProviders(g.OutputDir, g.Config); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", LockFile, err)
+	}
+
 	return fmt.Sprintf("Terraform files generated in %s directory", g.OutputDir), nil
 }
 
@@ -95,15 +318,15 @@ func (g *TerraformGenerator) createDirectoryStructure() error {
 	}
 
 	// Create modules directory if needed
-	if g.Config.CreateModules {
+	if modules := g.localModules(); len(modules) > 0 {
 		modulesDir := filepath.Join(g.OutputDir, "modules")
 		if err := utils.EnsureDirectoryExists(modulesDir); err != nil {
 			return err
 		}
 
-		// Create subdirectories for each module
-		for _, moduleName := range g.Config.ModuleNames {
-			moduleDir := filepath.Join(modulesDir, moduleName)
+		// Create subdirectories for each module the model has resources for
+		for _, kind := range modules {
+			moduleDir := filepath.Join(modulesDir, g.moduleName(kind))
 			if err := utils.EnsureDirectoryExists(moduleDir); err != nil {
 				return err
 			}
@@ -140,11 +363,48 @@ func (g *TerraformGenerator) generateRootModuleFiles() error {
 	if err != nil {
 		return err
 	}
+	// The flat layout declares the contents of the modules in files of their own instead
+	if g.flat() {
+		var flattened []moduleFile
+		mainTf, flattened, err = g.flattenModules(mainTf)
+		if err != nil {
+			return err
+		}
+		for _, file := range flattened {
+			if err := utils.WriteToFile(filepath.Join(g.OutputDir, file.Name), file.Content); err != nil {
+				return err
+			}
+		}
+	}
 	err = utils.WriteToFile(filepath.Join(g.OutputDir, "main.tf"), mainTf)
 	if err != nil {
 		return err
 	}
 
+	// Generate compute.tf when the model contains launch templates or Auto Scaling Groups
+	computeTf, err := g.generateComputeFile()
+	if err != nil {
+		return err
+	}
+	if computeTf != "" {
+		err = utils.WriteToFile(filepath.Join(g.OutputDir, "compute.tf"), computeTf)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Generate resources.tf when the model contains resources no module covers
+	resourcesTf, err := g.generateResourcesFile()
+	if err != nil {
+		return err
+	}
+	if resourcesTf != "" {
+		err = utils.WriteToFile(filepath.Join(g.OutputDir, "resources.tf"), resourcesTf)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Generate variables.tf
 	variablesTf, err := g.generateVariablesFile()
 	if err != nil {
@@ -170,7 +430,7 @@ func (g *TerraformGenerator) generateRootModuleFiles() error {
 	if err != nil {
 		return err
 	}
-	err = utils.WriteToFile(filepath.Join(g.OutputDir, "terraform.tfvars"), tfvars)
+	err = utils.WriteToFile(filepath.Join(g.OutputDir, tfvarsFileName(g.Model)), tfvars)
 	if err != nil {
 		return err
 	}
@@ -180,83 +440,16 @@ func (g *TerraformGenerator) generateRootModuleFiles() error {
 
 // generateModuleFiles generates files for each module
 func (g *TerraformGenerator) generateModuleFiles() error {
-	// Generate VPC module files
-	if contains(g.Config.ModuleNames, "vpc") {
-		vpcDir := filepath.Join(g.OutputDir, "modules", "vpc")
-		
-		// VPC main.tf
-		vpcMainTf, err := g.generateVpcModuleMainFile()
-		if err != nil {
-			return err
-		}
-		err = utils.WriteToFile(filepath.Join(vpcDir, "main.tf"), vpcMainTf)
+	for _, kind := range g.localModules() {
+		files, err := g.moduleFiles(kind)
 		if err != nil {
 			return err
 		}
-		
-		// VPC variables.tf
-		vpcVarsTf, err := g.generateVpcModuleVariablesFile()
-		if err != nil {
-			return err
-		}
-		err = utils.WriteToFile(filepath.Join(vpcDir, "variables.tf"), vpcVarsTf)
-		if err != nil {
-			return err
-		}
-		
-		// VPC outputs.tf
-		vpcOutputsTf, err := g.generateVpcModuleOutputsFile()
-		if err != nil {
-			return err
-		}
-		err = utils.WriteToFile(filepath.Join(vpcDir, "outputs.tf"), vpcOutputsTf)
-		if err != nil {
-			return err
-		}
-	}
-
-	// Generate EKS module files
-	if contains(g.Config.ModuleNames, "eks") {
-		eksDir := filepath.Join(g.OutputDir, "modules", "eks")
-		
-		// EKS main.tf
-		eksMainTf, err := g.generateEksModuleMainFile()
-		if err != nil {
-			return err
-		}
-		err = utils.WriteToFile(filepath.Join(eksDir, "main.tf"), eksMainTf)
-		if err != nil {
-			return err
-		}
-		
-		// EKS variables.tf
-		eksVarsTf, err := g.generateEksModuleVariablesFile()
-		if err != nil {
-			return err
-		}
-		err = utils.WriteToFile(filepath.Join(eksDir, "variables.tf"), eksVarsTf)
-		if err != nil {
-			return err
-		}
-		
-		// EKS outputs.tf
-		eksOutputsTf, err := g.generateEksModuleOutputsFile()
-		if err != nil {
-			return err
-		}
-		err = utils.WriteToFile(filepath.Join(eksDir, "outputs.tf"), eksOutputsTf)
-		if err != nil {
-			return err
-		}
-		
-		// EKS iam.tf
-		eksIamTf, err := g.generateEksModuleIamFile()
-		if err != nil {
-			return err
-		}
-		err = utils.WriteToFile(filepath.Join(eksDir, "iam.tf"), eksIamTf)
-		if err != nil {
-			return err
+		moduleDir := filepath.Join(g.OutputDir, "modules", g.moduleName(kind))
+		for _, file := range files {
+			if err := utils.WriteToFile(filepath.Join(moduleDir, file.Name), file.Content); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -274,23 +467,20 @@ func (g *TerraformGenerator) generateVersionsFile() (string, error) {
       version = "{{.ProviderConstraint}}"
     }
   }
-
-  {{if ne .BackendType "local"}}
-  backend "{{.BackendType}}" {
-    {{range $key, $value := .BackendConfig}}
-    {{$key}} = "{{$value}}"
-    {{end}}
-  }
-  {{end}}
-}
+{{.Backend}}}
 `
 	tmpl, err := template.New("versions").Parse(tmplStr)
 	if err != nil {
 		return "", err
 	}
 
+	data := map[string]interface{}{
+		"TerraformVersion":   requiredVersion(g.Config),
+		"ProviderConstraint": g.Config.ProviderConstraint,
+		"Backend":            backendBlock(g.Config, g.Model),
+	}
 	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, g.Config); err != nil {
+	if err := tmpl.Execute(&buf, data); err != nil {
 		return "", err
 	}
 
@@ -306,20 +496,45 @@ func (g *TerraformGenerator) generateProviderFile() (string, error) {
     tags = var.default_tags
   }
 }
-`
+` + aliasedProviders(g.secondaryRegions(), "var.default_tags")
 	return tmplStr, nil
 }
 
 // generateMainFile generates the main.tf file content
 func (g *TerraformGenerator) generateMainFile() (string, error) {
-	hasVPC := contains(g.Config.ModuleNames, "vpc")
-	hasEKS := contains(g.Config.ModuleNames, "eks")
+	hasVPC := g.hasModule("vpc")
+	hasEKS := g.hasModule("eks")
 
 	var mainFileContent bytes.Buffer
 
+	if g.usesRegistry() {
+		if hasVPC {
+			mainFileContent.WriteString(registryVpcModule(g.moduleCall("vpc"), "var.vpc_name", "var.availability_zones", ""))
+			for _, region := range g.vpcRegions() {
+				alias := providerAlias(region)
+				providers := "\n  providers = {\n    aws = aws." + alias + "\n  }\n"
+				mainFileContent.WriteString(registryVpcModule(g.moduleCall("vpc_"+alias), strconv.Quote(g.vpcName()+"-"+region), availabilityZones(region), providers))
+			}
+		}
+		if hasEKS {
+			mainFileContent.WriteString(g.registryEksModule(hasVPC))
+			if g.karpenter() {
+				mainFileContent.WriteString(g.registryKarpenterModule())
+			}
+			if len(g.eksControllers()) > 0 {
+				mainFileContent.WriteString(g.registryControllerModule())
+			}
+		}
+		if g.hasModule("data") {
+			mainFileContent.WriteString(g.dataModuleBlock())
+		}
+		return mainFileContent.String(), nil
+	}
+
 	if hasVPC {
-		vpcModule := `module "vpc" {
-  source = "./modules/vpc"
+		vpcName := g.moduleName("vpc")
+		vpcModule := `module "` + vpcName + `" {
+  source = "./modules/` + vpcName + `"
 
   vpc_name             = var.vpc_name
   vpc_cidr             = var.vpc_cidr
@@ -334,19 +549,47 @@ func (g *TerraformGenerator) generateMainFile() (string, error) {
 
 `
 		mainFileContent.WriteString(vpcModule)
+
+		// Each secondary region with a VPC gets a copy of the VPC through its aliased provider
+		for _, region := range g.vpcRegions() {
+			alias := providerAlias(region)
+			mainFileContent.WriteString(`module "` + g.moduleCall("vpc_"+alias) + `" {
+  source = "./modules/` + vpcName + `"
+
+  providers = {
+    aws = aws.` + alias + `
+  }
+
+  vpc_name             = "` + g.vpcName() + "-" + region + `"
+  vpc_cidr             = var.vpc_cidr
+  availability_zones   = ` + availabilityZones(region) + `
+  private_subnet_cidrs = var.private_subnet_cidrs
+  public_subnet_cidrs  = var.public_subnet_cidrs
+  enable_nat_gateway   = var.enable_nat_gateway
+  single_nat_gateway   = var.single_nat_gateway
+
+  tags = var.vpc_tags
+}
+
+`)
+		}
 	}
 
 	if hasEKS {
-		eksModule := `module "eks" {
-  source = "./modules/eks"
+		eksName := g.moduleName("eks")
+		eksModule := `module "` + eksName + `" {
+  source = "./modules/` + eksName + `"
   
   cluster_name    = var.cluster_name
   cluster_version = var.cluster_version
-  
-  vpc_id          = ${hasVPC ? "module.vpc.vpc_id" : "var.vpc_id"}
-  subnet_ids      = ${hasVPC ? "module.vpc.private_subnet_ids" : "var.subnet_ids"}
+` + g.serviceCIDRArgument("cluster_service_ipv4_cidr") + `  
+  vpc_id          = ${hasVPC ? "` + g.moduleOutput("vpc", "vpc_id") + `" : "var.vpc_id"}
+  subnet_ids      = ${hasVPC ? "` + g.moduleOutput("vpc", "private_subnet_ids") + `" : "var.subnet_ids"}
   
   node_groups = var.node_groups
+  addons      = var.addons
+
+  fargate_profiles = var.fargate_profiles
   
   tags = var.eks_tags
 }
@@ -362,13 +605,21 @@ func (g *TerraformGenerator) generateMainFile() (string, error) {
 		mainFileContent.WriteString(eksContent)
 	}
 
+	if g.hasModule("data") {
+		mainFileContent.WriteString(g.dataModuleBlock())
+	}
+
 	return mainFileContent.String(), nil
 }
 
-// generateVariablesFile generates the variables.tf file content
+// regionPattern matches the names of AWS regions, as the aws_region variable validates them
+const regionPattern = "^[a-z]{2}(-gov|-iso[a-z]?)?-(central|north|south|east|west|northeast|northwest|southeast|southwest)-[0-9]$"
+
+// generateVariablesFile generates the variables.tf file content. Validation blocks check the
+// CIDR blocks, region, Kubernetes version and node group sizes, so bad tfvars fail at plan.
 func (g *TerraformGenerator) generateVariablesFile() (string, error) {
-	hasVPC := contains(g.Config.ModuleNames, "vpc")
-	hasEKS := contains(g.Config.ModuleNames, "eks")
+	hasVPC := g.hasModule("vpc")
+	hasEKS := g.hasModule("eks")
 
 	var variablesContent bytes.Buffer
 
@@ -377,16 +628,16 @@ func (g *TerraformGenerator) generateVariablesFile() (string, error) {
   description = "AWS region to deploy resources into"
   type        = string
   default     = "` + g.Config.AwsRegion + `"
+  validation {
+    condition     = can(regex("` + regionPattern + `", var.aws_region))
+    error_message = "The aws_region must be an AWS region, such as us-east-1."
+  }
 }
 
 variable "default_tags" {
   description = "Default tags to apply to all resources"
   type        = map(string)
-  default     = {
-    Environment = "dev"
-    ManagedBy   = "terraform"
-    Project     = "iac-generator"
-  }
+  default     = ` + formatTagMap(defaultTags(g.Config, g.Model), "  ") + `
 }
 
 `
@@ -394,47 +645,60 @@ variable "default_tags" {
 
 	// VPC variables
 	if hasVPC {
+		publicSubnets, privateSubnets := g.subnetCIDRs()
 		vpcVars := `# VPC Variables
 variable "vpc_name" {
   description = "Name of the VPC"
   type        = string
-  default     = "main"
+  default     = "` + g.vpcName() + `"
 }
 
 variable "vpc_cidr" {
   description = "CIDR block for the VPC"
   type        = string
-  default     = "10.0.0.0/16"
+  default     = "` + g.vpcCIDR() + `"
+  validation {
+    condition     = can(cidrhost(var.vpc_cidr, 0))
+    error_message = "The vpc_cidr must be an IPv4 CIDR block, such as 10.0.0.0/16."
+  }
 }
 
 variable "availability_zones" {
   description = "List of availability zones"
   type        = list(string)
-  default     = ["us-east-1a", "us-east-1b", "us-east-1c"]
+  default     = ` + g.availabilityZonesList() + `
 }
 
 variable "private_subnet_cidrs" {
   description = "CIDR blocks for the private subnets"
   type        = list(string)
-  default     = ["10.0.1.0/24", "10.0.2.0/24", "10.0.3.0/24"]
+  default     = ` + hclList(privateSubnets) + `
+  validation {
+    condition     = alltrue([for cidr in var.private_subnet_cidrs : can(cidrhost(cidr, 0))])
+    error_message = "Each of the private_subnet_cidrs must be an IPv4 CIDR block, such as 10.0.1.0/24."
+  }
 }
 
 variable "public_subnet_cidrs" {
   description = "CIDR blocks for the public subnets"
   type        = list(string)
-  default     = ["10.0.101.0/24", "10.0.102.0/24", "10.0.103.0/24"]
+  default     = ` + hclList(publicSubnets) + `
+  validation {
+    condition     = alltrue([for cidr in var.public_subnet_cidrs : can(cidrhost(cidr, 0))])
+    error_message = "Each of the public_subnet_cidrs must be an IPv4 CIDR block, such as 10.0.0.0/24."
+  }
 }
 
 variable "enable_nat_gateway" {
   description = "Enable NAT Gateways for private subnets"
   type        = bool
-  default     = true
+  default     = ` + strconv.FormatBool(g.natGatewayEnabled()) + `
 }
 
 variable "single_nat_gateway" {
   description = "Use a single NAT Gateway for all private subnets"
   type        = bool
-  default     = true
+  default     = ` + strconv.FormatBool(g.singleNATGateway()) + `
 }
 
 variable "vpc_tags" {
@@ -467,13 +731,17 @@ variable "subnet_ids" {
 variable "cluster_name" {
   description = "Name of the EKS cluster"
   type        = string
-  default     = "main"
+  default     = "` + g.clusterName() + `"
 }
 
 variable "cluster_version" {
   description = "Kubernetes version to use for the EKS cluster"
   type        = string
-  default     = "1.28"
+  default     = "` + g.clusterVersion() + `"
+  validation {
+    condition     = can(regex("^1[.][0-9]+$", var.cluster_version))
+    error_message = "The cluster_version must be a Kubernetes minor version, such as 1.29."
+  }
 }
 
 variable "node_groups" {
@@ -498,6 +766,32 @@ variable "node_groups" {
       additional_tags      = {}
     }
   }
+  validation {
+    condition     = alltrue([for group in values(var.node_groups) : contains(["ON_DEMAND", "SPOT"], group.capacity_type)])
+    error_message = "The capacity_type of each node group must be ON_DEMAND or SPOT."
+  }
+  validation {
+    condition     = alltrue([for group in values(var.node_groups) : group.min_size >= 0 && group.min_size <= group.desired_size && group.desired_size <= group.max_size && group.max_size >= 1])
+    error_message = "Each node group needs 0 <= min_size <= desired_size <= max_size, with a max_size of at least 1."
+  }
+}
+
+variable "addons" {
+  description = "Map of EKS add-on names to their pinned version and IRSA service account and policy"
+  type        = map(object({
+    addon_version   = string
+    service_account = string
+    policy_arn      = string
+  }))
+  default     = {}
+}
+
+variable "fargate_profiles" {
+  description = "Map of Fargate profile names to the namespaces whose pods run on Fargate"
+  type        = map(object({
+    namespaces = list(string)
+  }))
+  default     = {}
 }
 
 variable "eks_tags" {
@@ -507,6 +801,19 @@ variable "eks_tags" {
 }
 
 `
+		if cidr := g.serviceCIDR(); cidr != "" {
+			eksVars += `variable "cluster_service_ipv4_cidr" {
+  description = "CIDR block Kubernetes service addresses are assigned from"
+  type        = string
+  default     = "` + cidr + `"
+  validation {
+    condition     = can(cidrhost(var.cluster_service_ipv4_cidr, 0))
+    error_message = "The cluster_service_ipv4_cidr must be an IPv4 CIDR block, such as 172.20.0.0/16."
+  }
+}
+
+`
+		}
 		variablesContent.WriteString(eksVars)
 	}
 
@@ -515,8 +822,8 @@ variable "eks_tags" {
 
 // generateOutputsFile generates the outputs.tf file content
 func (g *TerraformGenerator) generateOutputsFile() (string, error) {
-	hasVPC := contains(g.Config.ModuleNames, "vpc")
-	hasEKS := contains(g.Config.ModuleNames, "eks")
+	hasVPC := g.hasModule("vpc")
+	hasEKS := g.hasModule("eks")
 
 	var outputsContent bytes.Buffer
 
@@ -524,90 +831,137 @@ func (g *TerraformGenerator) generateOutputsFile() (string, error) {
 		vpcOutputs := `# VPC Outputs
 output "vpc_id" {
   description = "The ID of the VPC"
-  value       = module.vpc.vpc_id
+  value       = ` + g.moduleOutput("vpc", "vpc_id") + `
+}
+
+output "vpc_name" {
+  description = "The name of the VPC"
+  value       = var.vpc_name
 }
 
 output "private_subnet_ids" {
   description = "List of private subnet IDs"
-  value       = module.vpc.private_subnet_ids
+  value       = ` + g.moduleOutput("vpc", "private_subnet_ids") + `
 }
 
 output "public_subnet_ids" {
   description = "List of public subnet IDs"
-  value       = module.vpc.public_subnet_ids
+  value       = ` + g.moduleOutput("vpc", "public_subnet_ids") + `
 }
 
 `
 		outputsContent.WriteString(vpcOutputs)
+
+		for _, region := range g.vpcRegions() {
+			alias := providerAlias(region)
+			outputsContent.WriteString(`output "vpc_id_` + alias + `" {
+  description = "The ID of the VPC in ` + region + `"
+  value       = ` + g.moduleOutput("vpc_"+alias, "vpc_id") + `
+}
+
+`)
+		}
 	}
 
 	if hasEKS {
 		eksOutputs := `# EKS Outputs
 output "cluster_id" {
   description = "The name of the EKS cluster"
-  value       = module.eks.cluster_id
+  value       = ` + g.moduleOutput("eks", "cluster_id") + `
 }
 
 output "cluster_endpoint" {
   description = "Endpoint for the EKS cluster"
-  value       = module.eks.cluster_endpoint
+  value       = ` + g.moduleOutput("eks", "cluster_endpoint") + `
+}
+
+output "cluster_ca_certificate" {
+  description = "Base64 encoded certificate data required to communicate with the cluster"
+  value       = ` + g.moduleOutput("eks", "cluster_ca_certificate") + `
 }
 
 output "cluster_security_group_id" {
   description = "Security group ID attached to the EKS cluster"
-  value       = module.eks.cluster_security_group_id
+  value       = ` + g.moduleOutput("eks", "cluster_security_group_id") + `
 }
 
 output "cluster_iam_role_arn" {
   description = "IAM role ARN of the EKS cluster"
-  value       = module.eks.cluster_iam_role_arn
+  value       = ` + g.moduleOutput("eks", "cluster_iam_role_arn") + `
 }
 
 output "oidc_provider_arn" {
   description = "The ARN of the OIDC Provider"
-  value       = module.eks.oidc_provider_arn
+  value       = ` + g.moduleOutput("eks", "oidc_provider_arn") + `
 }
 
 output "node_security_group_id" {
   description = "Security group ID attached to the EKS nodes"
-  value       = module.eks.node_security_group_id
+  value       = ` + g.moduleOutput("eks", "node_security_group_id") + `
+}
+
+output "addon_versions" {
+  description = "Map of EKS add-on names to their installed versions"
+  value       = ` + g.moduleOutput("eks", "addon_versions") + `
 }
 
 `
 		outputsContent.WriteString(eksOutputs)
+
+		if len(g.eksControllers()) > 0 {
+			outputsContent.WriteString(`output "controller_role_arns" {
+  description = "Map of controller names to the IAM role ARNs of their service accounts"
+  value       = ` + g.moduleOutput("eks", "controller_role_arns") + `
+}
+
+`)
+		}
+
+		if g.clusterAutoscaler() {
+			outputsContent.WriteString(g.clusterAutoscalerOutputs())
+		}
+
+		if g.karpenter() {
+			subnetIDs := "var.subnet_ids"
+			if hasVPC {
+				subnetIDs = g.moduleOutput("vpc", "private_subnet_ids")
+			}
+			outputsContent.WriteString(g.karpenterOutputs(subnetIDs))
+		}
 	}
 
+	// Resources outside the modules
+	outputsContent.WriteString(resourceOutputs(g.unmodeledResources()))
+	outputsContent.WriteString(g.dataModuleOutputs())
+
 	return outputsContent.String(), nil
 }
 
 // generateTfvarsFile generates the terraform.tfvars file
 func (g *TerraformGenerator) generateTfvarsFile() (string, error) {
-	hasVPC := contains(g.Config.ModuleNames, "vpc")
-	hasEKS := contains(g.Config.ModuleNames, "eks")
+	hasVPC := g.hasModule("vpc")
+	hasEKS := g.hasModule("eks")
 
 	var content bytes.Buffer
 
 	content.WriteString(fmt.Sprintf(`aws_region = "%s"
 
-default_tags = {
-  Environment = "dev"
-  ManagedBy   = "terraform"
-  Project     = "iac-generator"
-}
+default_tags = %s
 
-`, g.Config.AwsRegion))
+`, g.Config.AwsRegion, formatTagMap(defaultTags(g.Config, g.Model), "")))
 
 	if hasVPC {
+		publicSubnets, privateSubnets := g.subnetCIDRs()
 		content.WriteString(`# VPC Configuration
-vpc_name = "main"
-vpc_cidr = "10.0.0.0/16"
-availability_zones = ["us-east-1a", "us-east-1b", "us-east-1c"]
-private_subnet_cidrs = ["10.0.1.0/24", "10.0.2.0/24", "10.0.3.0/24"]
-public_subnet_cidrs = ["10.0.101.0/24", "10.0.102.0/24", "10.0.103.0/24"]
-enable_nat_gateway = true
-single_nat_gateway = true
+vpc_name = "` + g.vpcName() + `"
+vpc_cidr = "` + g.vpcCIDR() + `"
+availability_zones = ` + g.availabilityZonesList() + `
+private_subnet_cidrs = ` + hclList(privateSubnets) + `
+public_subnet_cidrs = ` + hclList(publicSubnets) + `
+enable_nat_gateway = ` + strconv.FormatBool(g.natGatewayEnabled()) + `
+single_nat_gateway = ` + strconv.FormatBool(g.singleNATGateway()) + `
 vpc_tags = {
-  "kubernetes.io/cluster/main" = "shared"
+  "kubernetes.io/cluster/` + g.clusterName() + `" = "shared"
 }
 
 `)
@@ -615,34 +969,17 @@ vpc_tags = {
 
 	if hasEKS {
 		content.WriteString(`# EKS Configuration
-cluster_name = "main"
-cluster_version = "1.28"
-
-node_groups = {
-  default = {
-    instance_types = ["t3.medium"]
-    capacity_type = "ON_DEMAND"
-    desired_size = 2
-    min_size = 1
-    max_size = 4
-    disk_size = 20
-    additional_tags = {}
-  }
-  spot = {
-    instance_types = ["t3.medium", "t3.large"]
-    capacity_type = "SPOT"
-    desired_size = 1
-    min_size = 0
-    max_size = 5
-    disk_size = 20
-    additional_tags = {
-      "node-type" = "spot"
-    }
-  }
-}
+cluster_name = "` + g.clusterName() + `"
+cluster_version = "` + g.clusterVersion() + `"
+` + g.serviceCIDRTfvar() + `
+node_groups = ` + g.nodeGroupsTfvars() + `
+
+addons = ` + g.addonsTfvars() + `
+
+fargate_profiles = ` + g.fargateProfilesTfvars() + `
 
 eks_tags = {
-  "Environment" = "dev"
+  "Environment" = "` + g.environmentName() + `"
 }
 
 `)
@@ -896,6 +1233,7 @@ output "private_route_table_ids" {
 
 // generateEksModuleMainFile generates the EKS module main.tf
 func (g *TerraformGenerator) generateEksModuleMainFile() (string, error) {
+	autoscalerTags, autoscalerLifecycle := g.clusterAutoscalerNodeGroup()
 	tmplStr := `resource "aws_eks_cluster" "this" {
   name     = var.cluster_name
   role_arn = aws_iam_role.cluster.arn
@@ -960,8 +1298,8 @@ resource "aws_eks_node_group" "this" {
     each.value.additional_tags,
     {
       Name = "${var.cluster_name}-${each.key}"
-    }
-  )
+    }` + autoscalerTags + `
+  )` + autoscalerLifecycle + `
 }
 
 resource "aws_security_group" "cluster" {
@@ -1054,6 +1392,47 @@ resource "aws_iam_openid_connect_provider" "this" {
     Name = "${var.cluster_name}-oidc-provider"
   })
 }
+
+# EKS add-ons. An add-on without a pinned version gets the default version for the
+# cluster's Kubernetes version.
+resource "aws_eks_addon" "this" {
+  for_each = var.addons
+
+  cluster_name                = aws_eks_cluster.this.name
+  addon_name                  = each.key
+  addon_version               = each.value.addon_version
+  service_account_role_arn    = each.value.service_account != null ? aws_iam_role.addon[each.key].arn : null
+  resolve_conflicts_on_create = "OVERWRITE"
+  resolve_conflicts_on_update = "OVERWRITE"
+
+  depends_on = [aws_eks_node_group.this]
+
+  tags = var.tags
+}
+
+# Fargate profiles run the pods of their namespaces on Fargate instead of the node groups
+resource "aws_eks_fargate_profile" "this" {
+  for_each = var.fargate_profiles
+
+  cluster_name           = aws_eks_cluster.this.name
+  fargate_profile_name   = each.key
+  pod_execution_role_arn = aws_iam_role.fargate[0].arn
+  subnet_ids             = var.subnet_ids
+
+  dynamic "selector" {
+    for_each = each.value.namespaces
+
+    content {
+      namespace = selector.value
+    }
+  }
+
+  depends_on = [aws_iam_role_policy_attachment.fargate_AmazonEKSFargatePodExecutionRolePolicy]
+
+  tags = merge(var.tags, {
+    Name = "${var.cluster_name}-${each.key}"
+  })
+}
 `
 	return tmplStr, nil
 }
@@ -1138,6 +1517,24 @@ variable "node_groups" {
   }
 }
 
+variable "addons" {
+  description = "Map of EKS add-on names to their pinned version and, for add-ons that call AWS APIs, the namespace/name of their service account and the policy of its IAM role"
+  type        = map(object({
+    addon_version   = string
+    service_account = string
+    policy_arn      = string
+  }))
+  default     = {}
+}
+
+variable "fargate_profiles" {
+  description = "Map of Fargate profile names to the namespaces whose pods run on Fargate"
+  type        = map(object({
+    namespaces = list(string)
+  }))
+  default     = {}
+}
+
 variable "tags" {
   description = "Tags to apply to all resources"
   type        = map(string)
@@ -1194,7 +1591,23 @@ output "oidc_provider_arn" {
   description = "The ARN of the OIDC Provider"
   value       = aws_iam_openid_connect_provider.this.arn
 }
+
+output "addon_versions" {
+  description = "Map of EKS add-on names to their installed versions"
+  value       = { for name, addon in aws_eks_addon.this : name => addon.addon_version }
+}
+
+output "fargate_pod_execution_role_arn" {
+  description = "IAM role ARN of the pods run on Fargate"
+  value       = length(aws_iam_role.fargate) > 0 ? aws_iam_role.fargate[0].arn : null
+}
 `
+	if g.karpenter() {
+		tmplStr += karpenterModuleOutputs
+	}
+	if len(g.eksControllers()) > 0 {
+		tmplStr += controllerModuleOutputs
+	}
 	return tmplStr, nil
 }
 
@@ -1281,6 +1694,12 @@ data "aws_iam_policy_document" "oidc_assume_role_policy" {
       values   = ["system:serviceaccount:kube-system:aws-node"]
     }
 
+    condition {
+      test     = "StringEquals"
+      variable = "${replace(aws_iam_openid_connect_provider.this.url, "https://", "")}:aud"
+      values   = ["sts.amazonaws.com"]
+    }
+
     principals {
       identifiers = [aws_iam_openid_connect_provider.this.arn]
       type        = "Federated"
@@ -1297,10 +1716,332 @@ resource "aws_iam_role" "service_account" {
     Name = "${var.cluster_name}-service-account-role"
   })
 }
+
+# IAM roles for the service accounts of add-ons that call AWS APIs (IRSA)
+locals {
+  irsa_addons = { for name, addon in var.addons : name => addon if addon.service_account != null }
+}
+
+data "aws_iam_policy_document" "addon_assume_role_policy" {
+  for_each = local.irsa_addons
+
+  statement {
+    actions = ["sts:AssumeRoleWithWebIdentity"]
+    effect  = "Allow"
+
+    condition {
+      test     = "StringEquals"
+      variable = "${replace(aws_iam_openid_connect_provider.this.url, "https://", "")}:sub"
+      values   = ["system:serviceaccount:${replace(each.value.service_account, "/", ":")}"]
+    }
+
+    condition {
+      test     = "StringEquals"
+      variable = "${replace(aws_iam_openid_connect_provider.this.url, "https://", "")}:aud"
+      values   = ["sts.amazonaws.com"]
+    }
+
+    principals {
+      identifiers = [aws_iam_openid_connect_provider.this.arn]
+      type        = "Federated"
+    }
+  }
+}
+
+resource "aws_iam_role" "addon" {
+  for_each = local.irsa_addons
+
+  name               = "${var.cluster_name}-${each.key}"
+  assume_role_policy = data.aws_iam_policy_document.addon_assume_role_policy[each.key].json
+
+  tags = merge(var.tags, {
+    Name = "${var.cluster_name}-${each.key}"
+  })
+}
+
+resource "aws_iam_role_policy_attachment" "addon" {
+  for_each = local.irsa_addons
+
+  policy_arn = each.value.policy_arn
+  role       = aws_iam_role.addon[each.key].name
+}
+
+# Pod execution role for Fargate profiles
+resource "aws_iam_role" "fargate" {
+  count = length(var.fargate_profiles) > 0 ? 1 : 0
+
+  name = "${var.cluster_name}-fargate-pod-execution-role"
+
+  assume_role_policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Action = "sts:AssumeRole"
+        Effect = "Allow"
+        Principal = {
+          Service = "eks-fargate-pods.amazonaws.com"
+        }
+      }
+    ]
+  })
+
+  tags = merge(var.tags, {
+    Name = "${var.cluster_name}-fargate-pod-execution-role"
+  })
+}
+
+resource "aws_iam_role_policy_attachment" "fargate_AmazonEKSFargatePodExecutionRolePolicy" {
+  count = length(var.fargate_profiles) > 0 ? 1 : 0
+
+  policy_arn = "arn:aws:iam::aws:policy/AmazonEKSFargatePodExecutionRolePolicy"
+  role       = aws_iam_role.fargate[0].name
+}
 `
 	return tmplStr, nil
 }
 
+// nodeGroupTfvars is a node group of the node_groups value of terraform.tfvars
+type nodeGroupTfvars struct {
+	name          string
+	instanceTypes []string
+	capacityType  string
+	desiredSize   interface{}
+	minSize       interface{}
+	maxSize       interface{}
+	tags          string
+}
+
+// nodeGroupsTfvars returns the node_groups value of terraform.tfvars, with every node group
+// of the model under its name. Node groups without a capacity type are on-demand, and the
+// sizes and instance types they do not have are the EKS module's defaults. A workspace's node
+// count and instance type replace theirs.
+func (g *TerraformGenerator) nodeGroupsTfvars() string {
+	var groups []nodeGroupTfvars
+	if g.Model != nil {
+		for _, resource := range g.Model.Resources {
+			if resource.Type != models.ResourceNodeGroup {
+				continue
+			}
+			group := nodeGroupTfvars{
+				name:          strconv.Quote(resource.Name),
+				instanceTypes: []string{"t3.medium"},
+				capacityType:  "ON_DEMAND",
+				desiredSize:   2,
+				minSize:       1,
+				maxSize:       4,
+			}
+			if value, ok := resource.GetProperty("capacity_type"); ok {
+				group.capacityType = fmt.Sprint(value)
+			}
+			if instanceTypes := stringSliceProperty(resource, "instance_types"); len(instanceTypes) > 0 {
+				group.instanceTypes = instanceTypes
+			}
+			scaling := mapPropertyValue(resource, "scaling_config")
+			if size, ok := scaling["desired_size"]; ok {
+				group.desiredSize = size
+			}
+			if size, ok := scaling["min_size"]; ok {
+				group.minSize = size
+			}
+			if size, ok := scaling["max_size"]; ok {
+				group.maxSize = size
+			}
+			groups = append(groups, group)
+		}
+	}
+
+	var content strings.Builder
+	content.WriteString("{\n")
+	for _, group := range groups {
+		if g.workspace != nil {
+			group = g.workspace.sizeNodeGroup(group)
+		}
+		quoted := make([]string, len(group.instanceTypes))
+		for i, instanceType := range group.instanceTypes {
+			quoted[i] = strconv.Quote(instanceType)
+		}
+		tags := group.tags
+		if tags == "" {
+			tags = "{}"
+		}
+		fmt.Fprintf(&content, `  %s = {
+    instance_types = [%s]
+    capacity_type = %q
+    desired_size = %v
+    min_size = %v
+    max_size = %v
+    disk_size = 20
+    additional_tags = %s
+  }
+`, group.name, strings.Join(quoted, ", "), group.capacityType, group.desiredSize, group.minSize, group.maxSize, tags)
+	}
+	content.WriteString("}")
+	return content.String()
+}
+
+// addonsTfvars returns the addons value of terraform.tfvars, keyed by add-on name. Add-ons
+// without a pinned version or an IAM role get null for them.
+func (g *TerraformGenerator) addonsTfvars() string {
+	var addons []models.Resource
+	if g.Model != nil {
+		for _, resource := range g.Model.Resources {
+			if resource.Type == models.ResourceEKSAddon {
+				addons = append(addons, resource)
+			}
+		}
+	}
+	if len(addons) == 0 {
+		return "{}"
+	}
+
+	optional := func(addon models.Resource, name string) string {
+		if value, ok := addon.GetProperty(name); ok {
+			if str, ok := value.(string); ok && str != "" {
+				return strconv.Quote(str)
+			}
+		}
+		return "null"
+	}
+
+	var content strings.Builder
+	content.WriteString("{\n")
+	for _, addon := range addons {
+		addonName, _ := addon.GetProperty("addon_name")
+		fmt.Fprintf(&content, `  %q = {
+    addon_version = %s
+    service_account = %s
+    policy_arn = %s
+  }
+`, addonName, optional(addon, "addon_version"), optional(addon, "service_account"), optional(addon, "policy_arn"))
+	}
+	content.WriteString("}")
+	return content.String()
+}
+
+// fargateProfilesTfvars returns the fargate_profiles value of terraform.tfvars, keyed by
+// profile name
+func (g *TerraformGenerator) fargateProfilesTfvars() string {
+	var content strings.Builder
+	if g.Model != nil {
+		for _, resource := range g.Model.Resources {
+			if resource.Type != models.ResourceFargateProfile {
+				continue
+			}
+			var namespaces []string
+			if value, ok := resource.GetProperty("namespaces"); ok {
+				namespaces, _ = value.([]string)
+			}
+			quoted := make([]string, len(namespaces))
+			for i, namespace := range namespaces {
+				quoted[i] = strconv.Quote(namespace)
+			}
+			fmt.Fprintf(&content, `  %q = {
+    namespaces = [%s]
+  }
+`, resource.Name, strings.Join(quoted, ", "))
+		}
+	}
+	if content.Len() == 0 {
+		return "{}"
+	}
+	return "{\n" + content.String() + "}"
+}
+
+// vpcName returns the name of the VPC, as given in the model or "main" by default
+func (g *TerraformGenerator) vpcName() string {
+	return g.modelName(models.ResourceVPC, infra.DefaultVPCName)
+}
+
+// clusterName returns the name of the EKS cluster, as given in the model or "main" by default
+func (g *TerraformGenerator) clusterName() string {
+	return g.modelName(models.ResourceEKSCluster, infra.DefaultEKSClusterName)
+}
+
+// modelName returns the name of the first model resource of a type with the configured
+// suffix appended. Resources that kept the model builder's default name are called "main".
+func (g *TerraformGenerator) modelName(resourceType models.ResourceType, defaultName string) string {
+	name := ""
+	if g.Model != nil {
+		for _, resource := range g.Model.Resources {
+			if resource.Type == resourceType {
+				name = resource.Name
+				break
+			}
+		}
+	}
+	// Names in a DR variant model already carry the DR suffix
+	if strings.HasSuffix(g.Config.NameSuffix, infra.DRSuffix) {
+		name = strings.TrimSuffix(name, infra.DRSuffix)
+	}
+	// Names in a model made for one environment carry the environment's suffix, unless a
+	// naming policy placed the environment elsewhere
+	suffix := g.Config.NameSuffix
+	if g.Model != nil && g.Model.Environment != "" {
+		if trimmed := strings.TrimSuffix(name, "-"+g.Model.Environment); trimmed != name || name == "" {
+			name = trimmed
+			suffix = "-" + g.Model.Environment + suffix
+		}
+	}
+	if name == "" || name == defaultName {
+		name = "main"
+	}
+	return name + suffix
+}
+
+// environmentName returns the Environment tag value: the environment the model is for, or
+// the configured one
+func (g *TerraformGenerator) environmentName() string {
+	if g.Model != nil && g.Model.Environment != "" {
+		return g.Model.Environment
+	}
+	return g.Config.environmentName()
+}
+
+// singleNATGateway reports whether the private subnets share one NAT gateway, which is the
+// case unless the model, or the workspace, has a NAT gateway per availability zone of the
+// primary region
+func (g *TerraformGenerator) singleNATGateway() bool {
+	if g.workspace != nil && g.workspace.NATGateways != nil {
+		return *g.workspace.NATGateways <= 1
+	}
+	if g.Model == nil {
+		return true
+	}
+	natCount := 0
+	for i, resource := range g.Model.Resources {
+		if resource.Type == models.ResourceNATGateway && g.Model.ResourceRegion(&g.Model.Resources[i]) == g.Model.Region {
+			natCount++
+		}
+	}
+	return natCount <= 1
+}
+
+// secondaryRegions returns the regions of model resources outside the primary region
+func (g *TerraformGenerator) secondaryRegions() []string {
+	if g.Model == nil {
+		return nil
+	}
+	return g.Model.SecondaryRegions()
+}
+
+// availabilityZonesList returns the availability zones of the subnets in the model, or the
+// first three of the configured region, as an HCL list
+func (g *TerraformGenerator) availabilityZonesList() string {
+	if zones := g.subnetAvailabilityZones(); len(zones) > 0 {
+		return hclList(zones)
+	}
+	region := g.Config.AwsRegion
+	if region == "" {
+		region = "us-east-1"
+	}
+	return availabilityZones(region)
+}
+
+// availabilityZones returns the first three availability zones of a region as an HCL list
+func availabilityZones(region string) string {
+	return fmt.Sprintf("[%q, %q, %q]", region+"a", region+"b", region+"c")
+}
+
 // Helper functions
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
@@ -1317,28 +2058,35 @@ func replaceConditional(str string, start string, end string, useFirst ...bool)
 		takeFirst = useFirst[0]
 	}
 
-	startIdx := strings.Index(str, start)
-	endIdx := strings.Index(str, end)
-	
-	if startIdx == -1 || endIdx == -1 {
-		return str
-	}
-	
-	condStart := startIdx + len(start)
-	parts := strings.Split(str[condStart:endIdx], ":")
-	
-	if len(parts) < 2 {
-		return str
-	}
-	
-	choice := ""
-	if takeFirst {
-		choice = strings.TrimSpace(parts[0])
-	} else {
-		choice = strings.TrimSpace(parts[1])
+	// Replace every conditional, each with the unquoted expression it chooses
+	for offset := 0; ; {
+		startIdx := strings.Index(str[offset:], start)
+		if startIdx == -1 {
+			return str
+		}
+		startIdx += offset
+		condStart := startIdx + len(start)
+		endIdx := strings.Index(str[condStart:], end)
+		if endIdx == -1 {
+			return str
+		}
+		endIdx += condStart
+
+		parts := strings.Split(str[condStart:endIdx], ":")
+		if len(parts) < 2 {
+			return str
+		}
+
+		choice := ""
+		if takeFirst {
+			choice = strings.Trim(strings.TrimSpace(parts[0]), `"`)
+		} else {
+			choice = strings.Trim(strings.TrimSpace(parts[1]), `"`)
+		}
+
+		str = str[:startIdx] + choice + str[endIdx+len(end):]
+		offset = startIdx + len(choice)
 	}
-	
-	return str[:startIdx] + choice + str[endIdx+len(end):]
 }
 
 // mapResourceType maps our internal resource type to Terraform resource type
@@ -1358,6 +2106,10 @@ func mapResourceType(resourceType models.ResourceType) (string, error) {
 		models.ResourceNATGateway:     "aws_nat_gateway",
 		models.ResourceEKSCluster:     "aws_eks_cluster",
 		models.ResourceNodeGroup:      "aws_eks_node_group",
+		models.ResourceLaunchTemplate: "aws_launch_template",
+		models.ResourceAutoScalingGroup: "aws_autoscaling_group",
+		models.ResourceEKSAddon:         "aws_eks_addon",
+		models.ResourceFargateProfile:   "aws_eks_fargate_profile",
 	}
 
 	if terraformType, ok := mapping[resourceType]; ok {
@@ -1379,8 +2131,8 @@ func ModelToTerraformModel(model *infra.Infrastructure) (*models.InfrastructureM
 		vpcResource.AddProperty("enable_dns_support", vpc.EnableDNSSupport)
 		vpcResource.AddProperty("enable_dns_hostnames", vpc.EnableDNSHostname)
 		
-		for k, v := range vpc.Tags {
-			vpcResource.AddProperty(fmt.Sprintf("tag.%s", k), v)
+		for _, k := range utils.SortedKeys(vpc.Tags) {
+			vpcResource.AddProperty(fmt.Sprintf("tag.%s", k), vpc.Tags[k])
 		}
 		
 		tfModel.AddResource(vpcResource)
@@ -1393,8 +2145,8 @@ func ModelToTerraformModel(model *infra.Infrastructure) (*models.InfrastructureM
 			subnetResource.AddProperty("availability_zone", subnet.AvailabilityZone)
 			subnetResource.AddProperty("map_public_ip_on_launch", subnet.IsPublic)
 			
-			for k, v := range subnet.Tags {
-				subnetResource.AddProperty(fmt.Sprintf("tag.%s", k), v)
+			for _, k := range utils.SortedKeys(subnet.Tags) {
+				subnetResource.AddProperty(fmt.Sprintf("tag.%s", k), subnet.Tags[k])
 			}
 			
 			subnetResource.AddDependency(vpc.Name)
@@ -1406,8 +2158,8 @@ func ModelToTerraformModel(model *infra.Infrastructure) (*models.InfrastructureM
 			igwResource := models.NewResource(models.ResourceIGW, igw.Name)
 			igwResource.AddProperty("vpc_id", fmt.Sprintf("${aws_vpc.%s.id}", vpc.Name))
 			
-			for k, v := range igw.Tags {
-				igwResource.AddProperty(fmt.Sprintf("tag.%s", k), v)
+			for _, k := range utils.SortedKeys(igw.Tags) {
+				igwResource.AddProperty(fmt.Sprintf("tag.%s", k), igw.Tags[k])
 			}
 			
 			igwResource.AddDependency(vpc.Name)
@@ -1424,8 +2176,8 @@ func ModelToTerraformModel(model *infra.Infrastructure) (*models.InfrastructureM
 				natResource.AddProperty("allocation_id", natgw.AllocationID)
 			}
 			
-			for k, v := range natgw.Tags {
-				natResource.AddProperty(fmt.Sprintf("tag.%s", k), v)
+			for _, k := range utils.SortedKeys(natgw.Tags) {
+				natResource.AddProperty(fmt.Sprintf("tag.%s", k), natgw.Tags[k])
 			}
 			
 			natResource.AddDependency(natgw.Subnet)
@@ -3,6 +3,8 @@ package terraform
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/riptano/iac_generator_cli/internal/template"
 	"github.com/riptano/iac_generator_cli/internal/utils"
@@ -45,7 +47,19 @@ func (g *TemplateTerraformGenerator) WithConfig(config *TerraformConfig) *Templa
 
 // Generate generates Terraform HCL from an infrastructure model
 func (g *TemplateTerraformGenerator) Generate(model *models.InfrastructureModel) (string, error) {
+	sorted, sortErr := model.Sorted()
+	if sortErr != nil {
+		return "", sortErr
+	}
+	model = sorted
 	g.Model = model
+	// The provider and variables are for the model's primary region, as its resources are
+	if model.Region != "" {
+		g.Config.AwsRegion = model.Region
+	}
+	// Files written within the second before are formatted too, as modification times may
+	// only have a resolution of a second
+	started := time.Now().Truncate(time.Second)
 
 	// Create directory structure
 	if err := utils.EnsureDirectoryExists(g.OutputDir); err != nil {
@@ -55,12 +69,15 @@ func (g *TemplateTerraformGenerator) Generate(model *models.InfrastructureModel)
 	// Prepare configuration for templates
 	headerData := map[string]interface{}{
 		"Region":            g.Config.AwsRegion,
-		"TerraformVersion":  g.Config.TerraformVersion,
+		"TerraformVersion":  requiredVersion(g.Config),
 		"ProviderVersion":   g.Config.ProviderConstraint,
 		"BackendType":       g.Config.BackendType,
 		"BackendConfig":     g.Config.BackendConfig,
 	}
 
+	// Resources outside the primary region use the provider aliased for their region
+	g.renderer.SetGlobalContext("region", model.Region)
+
 	// Try to get a header template, if not found, we'll use the default templates from renderer
 	_, err := template.GetDefaultManager().GetTemplate(template.FormatTerraform, "header.tmpl")
 	if err != nil {
@@ -89,6 +106,50 @@ func (g *TemplateTerraformGenerator) Generate(model *models.InfrastructureModel)
 		return "", fmt.Errorf("failed to generate Terraform files: %w", err)
 	}
 
+	// Check that the provider version allows the features of the generated resources
+	if err := checkProviderVersion(g.OutputDir, g.Config.ProviderConstraint); err != nil {
+		return "", err
+	}
+
+	// Mark the outputs carrying secrets as sensitive
+	if err := markSensitiveOutputs(g.OutputDir, started); err != nil {
+		return "", err
+	}
+
+	// Add the configured lifecycle settings to the resources
+	if err := applyLifecycle(g.OutputDir, g.Config.Lifecycle); err != nil {
+		return "", err
+	}
+
+	// Generate the import blocks adopting existing resources
+	if err := writeImportsFile(g.OutputDir, g.Config.Imports); err != nil {
+		return "", err
+	}
+
+	// Record the generation, moving the resources renamed since the previous one
+	if err := recordGeneration(g.OutputDir, g.Model); err != nil {
+		return "", err
+	}
+
+	// Generate the configuration creating the state backend
+	if g.Config.BackendBootstrap {
+		if err := GenerateBackendBootstrap(filepath.Join(g.OutputDir, BackendBootstrapDir), g.Config, g.Model); err != nil {
+			return "", err
+		}
+	}
+
+	// Format the generated files
+	if g.Config.FormatOutput {
+		if err := formatDirectory(g.OutputDir, started); err != nil {
+			return "", fmt.Errorf("failed to format the generated files: %w", err)
+		}
+	}
+
+	// Lock the providers of the configuration
+	if err := lockProviders(g.OutputDir, g.Config); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", LockFile, err)
+	}
+
 	return fmt.Sprintf("Terraform files generated in %s directory", g.OutputDir), nil
 }
 
@@ -109,8 +170,8 @@ func (g *TemplateTerraformGenerator) generateTerraformFiles(mainContent string,
       version = "%s"
     }
   }
-}
-`, headerData["TerraformVersion"], headerData["ProviderVersion"])
+%s}
+`, headerData["TerraformVersion"], headerData["ProviderVersion"], backendBlock(g.Config, g.Model))
 	if err := utils.WriteToFile(filepath.Join(g.OutputDir, "versions.tf"), versionsTf); err != nil {
 		return fmt.Errorf("failed to write versions.tf: %w", err)
 	}
@@ -120,14 +181,11 @@ func (g *TemplateTerraformGenerator) generateTerraformFiles(mainContent string,
   region = "%s"
 
   default_tags {
-    tags = {
-      Environment = "dev"
-      ManagedBy   = "terraform"
-      Project     = "iac-generator"
-    }
+    tags = %s
   }
 }
-`, headerData["Region"])
+`, headerData["Region"], formatTagMap(defaultTags(g.Config, g.Model), "    ")) +
+		aliasedProviders(g.Model.SecondaryRegions(), formatTagMap(defaultTags(g.Config, g.Model), "    "))
 	if err := utils.WriteToFile(filepath.Join(g.OutputDir, "provider.tf"), providerTf); err != nil {
 		return fmt.Errorf("failed to write provider.tf: %w", err)
 	}
@@ -142,11 +200,7 @@ func (g *TemplateTerraformGenerator) generateTerraformFiles(mainContent string,
 variable "default_tags" {
   description = "Default tags to apply to all resources"
   type        = map(string)
-  default     = {
-    Environment = "dev"
-    ManagedBy   = "terraform"
-    Project     = "iac-generator"
-  }
+  default     = ` + formatTagMap(defaultTags(g.Config, g.Model), "  ") + `
 }
 `
 	if err := utils.WriteToFile(filepath.Join(g.OutputDir, "variables.tf"), variablesTf); err != nil {
@@ -159,7 +213,8 @@ output "aws_region" {
   description = "The AWS region used"
   value       = var.aws_region
 }
-`
+
+` + resourceOutputs(g.Model.Resources)
 	if err := utils.WriteToFile(filepath.Join(g.OutputDir, "outputs.tf"), outputsTf); err != nil {
 		return fmt.Errorf("failed to write outputs.tf: %w", err)
 	}
@@ -167,15 +222,35 @@ output "aws_region" {
 	// Generate and write terraform.tfvars (optional)
 	tfvars := fmt.Sprintf(`aws_region = "%s"
 
-default_tags = {
-  Environment = "dev"
-  ManagedBy   = "terraform"
-  Project     = "iac-generator"
-}
-`, headerData["Region"])
-	if err := utils.WriteToFile(filepath.Join(g.OutputDir, "terraform.tfvars"), tfvars); err != nil {
-		return fmt.Errorf("failed to write terraform.tfvars: %w", err)
+default_tags = %s
+`, headerData["Region"], formatTagMap(defaultTags(g.Config, g.Model), ""))
+	if err := utils.WriteToFile(filepath.Join(g.OutputDir, tfvarsFileName(g.Model)), tfvars); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tfvarsFileName(g.Model), err)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// providerAlias returns the alias of the aws provider for a region
+func providerAlias(region string) string {
+	return template.ProviderAliasFunc(region)
+}
+
+// aliasedProviders returns an aliased aws provider block for each region, through which
+// resources outside the primary region are created
+func aliasedProviders(regions []string, tags string) string {
+	var providers strings.Builder
+	for _, region := range regions {
+		fmt.Fprintf(&providers, `
+provider "aws" {
+  alias  = %q
+  region = %q
+
+  default_tags {
+    tags = %s
+  }
+}
+`, providerAlias(region), region, tags)
+	}
+	return providers.String()
+}
@@ -0,0 +1,164 @@
+package terraform
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// ImportVersion is the first Terraform version with import blocks
+const ImportVersion = "1.5.0"
+
+// resourceAddressPattern matches the address of a managed resource, in the root module or in
+// module calls, with optional instance keys, such as module.vpc.aws_vpc.this or
+// aws_s3_bucket.logs["eu"]
+var resourceAddressPattern = regexp.MustCompile(`^(module\.[A-Za-z_][A-Za-z0-9_-]*(\[("[^"]*"|[0-9]+)\])?\.)*[a-z][a-z0-9_]*\.[A-Za-z_][A-Za-z0-9_-]*(\[("[^"]*"|[0-9]+)\])?$`)
+
+// LoadImportMap reads a YAML file mapping the addresses of generated resources to the IDs
+// of the existing AWS resources they adopt:
+//
+//	imports:
+//	  module.vpc.aws_vpc.this: vpc-0123456789abcdef0
+//	  aws_s3_bucket.app_logs: my-app-logs
+func LoadImportMap(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import map: %w", err)
+	}
+
+	var file struct {
+		Imports map[string]string `yaml:"imports"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse import map %s: %w", path, err)
+	}
+	if len(file.Imports) == 0 {
+		return nil, fmt.Errorf("no imports in %s", path)
+	}
+	if err := ValidateImports(file.Imports); err != nil {
+		return nil, fmt.Errorf("invalid import map %s: %w", path, err)
+	}
+	return file.Imports, nil
+}
+
+// ValidateImports checks the resource addresses and IDs of an import map
+func ValidateImports(imports map[string]string) error {
+	for _, address := range utils.SortedKeys(imports) {
+		if !resourceAddressPattern.MatchString(address) {
+			return fmt.Errorf("invalid resource address: %q (use an address such as module.vpc.aws_vpc.this)", address)
+		}
+		if strings.TrimSpace(imports[address]) == "" {
+			return fmt.Errorf("the import of %s has no ID", address)
+		}
+	}
+	return nil
+}
+
+// requiredVersion returns the minimum Terraform version of versions.tf: the configured one,
+// raised to the version with import blocks when there are imports
+func requiredVersion(config *TerraformConfig) string {
+	if len(config.Imports) > 0 && compareVersions(config.TerraformVersion, ImportVersion) < 0 {
+		return ImportVersion
+	}
+	return config.TerraformVersion
+}
+
+// compareVersions compares two dotted versions numerically, returning -1, 0 or 1
+func compareVersions(a, b string) int {
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var x, y int
+		if i < len(aParts) {
+			x, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			y, _ = strconv.Atoi(bParts[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// writeImportsFile writes imports.tf, with an import block for each imported resource, to
+// dir. Imports of resources the configuration does not declare are logged, since Terraform
+// rejects them at plan.
+func writeImportsFile(dir string, imports map[string]string) error {
+	if len(imports) == 0 {
+		return nil
+	}
+
+	var content strings.Builder
+	content.WriteString("# Existing resources adopted into the state at the next apply (Terraform " + ImportVersion + " or later).\n# Review terraform plan before applying: it lists each import and any change to the resource.\n")
+	for _, address := range utils.SortedKeys(imports) {
+		fmt.Fprintf(&content, "\nimport {\n  to = %s\n  id = %q\n}\n", address, imports[address])
+	}
+	if err := utils.WriteToFile(filepath.Join(dir, "imports.tf"), content.String()); err != nil {
+		return fmt.Errorf("failed to write imports.tf: %w", err)
+	}
+
+	for _, address := range utils.SortedKeys(imports) {
+		if !importDeclared(dir, address) {
+			utils.GetLogger().Warnw("Imported resource is not declared by the generated configuration", "address", address)
+		}
+	}
+	return nil
+}
+
+// importDeclared reports whether the configuration in dir declares the resource an import
+// address adopts. A resource in a module call is looked up in the module when it is one of
+// the generated local modules; otherwise the module call has to be declared.
+func importDeclared(dir, address string) bool {
+	if !strings.HasPrefix(address, "module.") {
+		address, _, _ = strings.Cut(address, "[")
+		return declaredAddresses(dir)[address]
+	}
+	call, resource, _ := strings.Cut(strings.TrimPrefix(address, "module."), ".")
+	name, _, _ := strings.Cut(call, "[")
+	if !declaredAddresses(dir)["module."+name] {
+		return false
+	}
+	moduleDir := filepath.Join(dir, "modules", name)
+	if strings.HasPrefix(resource, "module.") || !utils.FileExists(moduleDir) {
+		return true
+	}
+	resource, _, _ = strings.Cut(resource, "[")
+	return declaredAddresses(moduleDir)[resource]
+}
+
+// declarationPattern matches the resource and module blocks of a configuration
+var declarationPattern = regexp.MustCompile(`(?m)^(resource|module)\s+"([^"]+)"(?:\s+"([^"]+)")?\s*\{`)
+
+// declaredAddresses returns the resources and module calls the root module in dir declares,
+// as aws_vpc.main or module.vpc
+func declaredAddresses(dir string) map[string]bool {
+	declared := make(map[string]bool)
+	files, _ := filepath.Glob(filepath.Join(dir, "*.tf"))
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		for _, match := range declarationPattern.FindAllStringSubmatch(string(content), -1) {
+			if match[1] == "module" {
+				declared["module."+match[2]] = true
+			} else if match[3] != "" {
+				declared[match[2]+"."+match[3]] = true
+			}
+		}
+	}
+	return declared
+}
@@ -0,0 +1,361 @@
+package terraform
+
+import (
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// karpenter reports whether Karpenter provisions nodes for the EKS cluster
+func (g *TerraformGenerator) karpenter() bool {
+	if g.Model == nil {
+		return false
+	}
+	for _, resource := range g.Model.Resources {
+		if resource.Type != models.ResourceEKSCluster {
+			continue
+		}
+		if value, ok := resource.GetProperty("karpenter"); ok && value == true {
+			return true
+		}
+	}
+	return false
+}
+
+// generateEksModuleKarpenterFile generates the EKS module karpenter.tf: the instance profile
+// of the nodes Karpenter launches, the IAM role of its controller's service account, and the
+// queue receiving the interruption events of the nodes. The nodes have the node groups' IAM
+// role, which EKS already maps in the aws-auth ConfigMap, so they join the cluster as the
+// node groups' nodes do.
+func (g *TerraformGenerator) generateEksModuleKarpenterFile() (string, error) {
+	tmplStr := `# Instance profile of the nodes Karpenter launches
+resource "aws_iam_instance_profile" "karpenter" {
+  name = "${var.cluster_name}-karpenter-node"
+  role = aws_iam_role.node.name
+
+  tags = merge(var.tags, {
+    Name = "${var.cluster_name}-karpenter-node"
+  })
+}
+
+# IAM role of the Karpenter controller's service account (IRSA)
+data "aws_iam_policy_document" "karpenter_assume_role_policy" {
+  statement {
+    actions = ["sts:AssumeRoleWithWebIdentity"]
+    effect  = "Allow"
+
+    condition {
+      test     = "StringEquals"
+      variable = "${replace(aws_iam_openid_connect_provider.this.url, "https://", "")}:sub"
+      values   = ["system:serviceaccount:kube-system:karpenter"]
+    }
+
+    condition {
+      test     = "StringEquals"
+      variable = "${replace(aws_iam_openid_connect_provider.this.url, "https://", "")}:aud"
+      values   = ["sts.amazonaws.com"]
+    }
+
+    principals {
+      identifiers = [aws_iam_openid_connect_provider.this.arn]
+      type        = "Federated"
+    }
+  }
+}
+
+resource "aws_iam_role" "karpenter_controller" {
+  name               = "${var.cluster_name}-karpenter-controller"
+  assume_role_policy = data.aws_iam_policy_document.karpenter_assume_role_policy.json
+
+  tags = merge(var.tags, {
+    Name = "${var.cluster_name}-karpenter-controller"
+  })
+}
+
+# The controller launches and terminates the nodes it tags, and reads the interruption queue
+resource "aws_iam_role_policy" "karpenter_controller" {
+  name = "${var.cluster_name}-karpenter-controller"
+  role = aws_iam_role.karpenter_controller.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Sid      = "Provisioning"
+        Effect   = "Allow"
+        Action   = ["ec2:CreateFleet", "ec2:RunInstances", "ec2:CreateLaunchTemplate", "ec2:CreateTags"]
+        Resource = "*"
+      },
+      {
+        Sid      = "Termination"
+        Effect   = "Allow"
+        Action   = ["ec2:TerminateInstances", "ec2:DeleteLaunchTemplate"]
+        Resource = "*"
+        Condition = {
+          StringLike = {
+            "aws:ResourceTag/karpenter.sh/nodepool" = "*"
+          }
+        }
+      },
+      {
+        Sid    = "Discovery"
+        Effect = "Allow"
+        Action = [
+          "ec2:DescribeAvailabilityZones",
+          "ec2:DescribeImages",
+          "ec2:DescribeInstances",
+          "ec2:DescribeInstanceTypeOfferings",
+          "ec2:DescribeInstanceTypes",
+          "ec2:DescribeLaunchTemplates",
+          "ec2:DescribeSecurityGroups",
+          "ec2:DescribeSpotPriceHistory",
+          "ec2:DescribeSubnets",
+          "pricing:GetProducts",
+        ]
+        Resource = "*"
+      },
+      {
+        Sid      = "AMIParameters"
+        Effect   = "Allow"
+        Action   = "ssm:GetParameter"
+        Resource = "arn:aws:ssm:*::parameter/aws/service/*"
+      },
+      {
+        Sid      = "PassNodeRole"
+        Effect   = "Allow"
+        Action   = "iam:PassRole"
+        Resource = aws_iam_role.node.arn
+      },
+      {
+        Sid      = "InstanceProfile"
+        Effect   = "Allow"
+        Action   = "iam:GetInstanceProfile"
+        Resource = aws_iam_instance_profile.karpenter.arn
+      },
+      {
+        Sid      = "Cluster"
+        Effect   = "Allow"
+        Action   = "eks:DescribeCluster"
+        Resource = aws_eks_cluster.this.arn
+      },
+      {
+        Sid      = "InterruptionQueue"
+        Effect   = "Allow"
+        Action   = ["sqs:DeleteMessage", "sqs:GetQueueUrl", "sqs:ReceiveMessage"]
+        Resource = aws_sqs_queue.karpenter.arn
+      },
+    ]
+  })
+}
+
+# Interruption queue: EventBridge sends it the events of the nodes about to be interrupted,
+# so Karpenter drains them first
+resource "aws_sqs_queue" "karpenter" {
+  name                      = "${var.cluster_name}-karpenter"
+  message_retention_seconds = 300
+  sqs_managed_sse_enabled   = true
+
+  tags = merge(var.tags, {
+    Name = "${var.cluster_name}-karpenter"
+  })
+}
+
+resource "aws_sqs_queue_policy" "karpenter" {
+  queue_url = aws_sqs_queue.karpenter.url
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Sid    = "EventBridgeWrite"
+        Effect = "Allow"
+        Principal = {
+          Service = ["events.amazonaws.com", "sqs.amazonaws.com"]
+        }
+        Action   = "sqs:SendMessage"
+        Resource = aws_sqs_queue.karpenter.arn
+      },
+      {
+        Sid       = "DenyHTTP"
+        Effect    = "Deny"
+        Principal = "*"
+        Action    = "sqs:*"
+        Resource  = aws_sqs_queue.karpenter.arn
+        Condition = {
+          Bool = {
+            "aws:SecureTransport" = "false"
+          }
+        }
+      },
+    ]
+  })
+}
+
+locals {
+  karpenter_events = {
+    health_event = {
+      source        = ["aws.health"]
+      "detail-type" = ["AWS Health Event"]
+    }
+    spot_interruption = {
+      source        = ["aws.ec2"]
+      "detail-type" = ["EC2 Spot Instance Interruption Warning"]
+    }
+    rebalance_recommendation = {
+      source        = ["aws.ec2"]
+      "detail-type" = ["EC2 Instance Rebalance Recommendation"]
+    }
+    instance_state_change = {
+      source        = ["aws.ec2"]
+      "detail-type" = ["EC2 Instance State-change Notification"]
+    }
+  }
+}
+
+resource "aws_cloudwatch_event_rule" "karpenter" {
+  for_each = local.karpenter_events
+
+  name          = "${var.cluster_name}-karpenter-${replace(each.key, "_", "-")}"
+  description   = "Sends the ${replace(each.key, "_", " ")} events of the nodes to Karpenter"
+  event_pattern = jsonencode(each.value)
+
+  tags = var.tags
+}
+
+resource "aws_cloudwatch_event_target" "karpenter" {
+  for_each = local.karpenter_events
+
+  rule      = aws_cloudwatch_event_rule.karpenter[each.key].name
+  target_id = "KarpenterInterruptionQueue"
+  arn       = aws_sqs_queue.karpenter.arn
+}
+`
+	return tmplStr, nil
+}
+
+// karpenterModuleOutputs are the outputs of the EKS module for Karpenter, appended to its
+// outputs.tf when Karpenter is enabled
+const karpenterModuleOutputs = `
+output "karpenter_controller_role_arn" {
+  description = "IAM role ARN of the Karpenter controller's service account"
+  value       = aws_iam_role.karpenter_controller.arn
+}
+
+output "karpenter_interruption_queue_name" {
+  description = "Name of the SQS queue receiving the interruption events of Karpenter's nodes"
+  value       = aws_sqs_queue.karpenter.name
+}
+
+output "karpenter_node_role_name" {
+  description = "Name of the IAM role of Karpenter's nodes"
+  value       = aws_iam_role.node.name
+}
+
+output "karpenter_instance_profile_name" {
+  description = "Name of the instance profile of Karpenter's nodes"
+  value       = aws_iam_instance_profile.karpenter.name
+}
+`
+
+// registryKarpenterModule returns the block of the registry Karpenter module, creating the
+// controller's IAM role for its service account, the nodes' IAM role, instance profile and
+// access entry, and the interruption queue with its EventBridge rules
+func (g *TerraformGenerator) registryKarpenterModule() string {
+	karpenter := registryModules["karpenter"]
+	return `# Karpenter provisions nodes for the cluster's pending pods
+module "karpenter" {
+  source  = "` + karpenter.Source + `"
+  version = "` + karpenter.Version + `"
+
+  cluster_name          = ` + g.moduleOutput("eks", "cluster_id") + `
+  enable_v1_permissions = true
+
+  enable_irsa                     = true
+  irsa_oidc_provider_arn          = ` + g.moduleOutput("eks", "oidc_provider_arn") + `
+  irsa_namespace_service_accounts = ["kube-system:karpenter"]
+  enable_pod_identity             = false
+
+  create_instance_profile = true
+
+  tags = var.eks_tags
+}
+
+`
+}
+
+// karpenterOutputs returns the root outputs for Karpenter: its IAM roles and queue, the values
+// of its Helm chart, and the manifests of a default EC2NodeClass and NodePool launching nodes
+// in the cluster's subnets with its node security group. subnetIDs is the expression of the
+// cluster's subnets.
+func (g *TerraformGenerator) karpenterOutputs(subnetIDs string) string {
+	return `# Karpenter Outputs
+output "karpenter_controller_role_arn" {
+  description = "IAM role ARN of the Karpenter controller's service account"
+  value       = ` + g.moduleOutput("eks", "karpenter_controller_role_arn") + `
+}
+
+output "karpenter_interruption_queue_name" {
+  description = "Name of the SQS queue receiving the interruption events of Karpenter's nodes"
+  value       = ` + g.moduleOutput("eks", "karpenter_interruption_queue_name") + `
+}
+
+output "karpenter_node_role_name" {
+  description = "Name of the IAM role of Karpenter's nodes"
+  value       = ` + g.moduleOutput("eks", "karpenter_node_role_name") + `
+}
+
+output "karpenter_instance_profile_name" {
+  description = "Name of the instance profile of Karpenter's nodes"
+  value       = ` + g.moduleOutput("eks", "karpenter_instance_profile_name") + `
+}
+
+output "karpenter_helm_values" {
+  description = "Values of the Karpenter Helm chart, for helm install -f"
+  value = yamlencode({
+    settings = {
+      clusterName       = ` + g.moduleOutput("eks", "cluster_id") + `
+      interruptionQueue = ` + g.moduleOutput("eks", "karpenter_interruption_queue_name") + `
+    }
+    serviceAccount = {
+      annotations = {
+        "eks.amazonaws.com/role-arn" = ` + g.moduleOutput("eks", "karpenter_controller_role_arn") + `
+      }
+    }
+  })
+}
+
+output "karpenter_manifests" {
+  description = "Default EC2NodeClass and NodePool of Karpenter, for kubectl apply -f"
+  value = join("---\n", [
+    yamlencode({
+      apiVersion = "karpenter.k8s.aws/v1"
+      kind       = "EC2NodeClass"
+      metadata   = { name = "default" }
+      spec = {
+        instanceProfile            = ` + g.moduleOutput("eks", "karpenter_instance_profile_name") + `
+        amiSelectorTerms           = [{ alias = "al2023@latest" }]
+        subnetSelectorTerms        = [for id in ` + subnetIDs + ` : { id = id }]
+        securityGroupSelectorTerms = [{ id = ` + g.moduleOutput("eks", "node_security_group_id") + ` }]
+      }
+    }),
+    yamlencode({
+      apiVersion = "karpenter.sh/v1"
+      kind       = "NodePool"
+      metadata   = { name = "default" }
+      spec = {
+        template = {
+          spec = {
+            nodeClassRef = { group = "karpenter.k8s.aws", kind = "EC2NodeClass", name = "default" }
+            requirements = [
+              { key = "kubernetes.io/arch", operator = "In", values = ["amd64"] },
+              { key = "karpenter.sh/capacity-type", operator = "In", values = ["on-demand", "spot"] },
+            ]
+          }
+        }
+        limits     = { cpu = 1000 }
+        disruption = { consolidationPolicy = "WhenEmptyOrUnderutilized", consolidateAfter = "1m" }
+      }
+    }),
+  ])
+}
+
+`
+}
@@ -0,0 +1,419 @@
+package terraform
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/riptano/iac_generator_cli/internal/template"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// Layouts of the modules of the generated configuration
+const (
+	// ModuleLayoutModules generates the vpc and eks modules under modules/
+	ModuleLayoutModules = "modules"
+	// ModuleLayoutByType groups the resources by tier into the network, compute and data
+	// modules under modules/
+	ModuleLayoutByType = "by-type"
+	// ModuleLayoutFlat declares all the resources in the root module, without modules/
+	ModuleLayoutFlat = "flat"
+)
+
+// ModuleLayouts are the layouts the modules can have
+var ModuleLayouts = []string{ModuleLayoutModules, ModuleLayoutByType, ModuleLayoutFlat}
+
+// moduleKinds are the modules whose names can be configured: the network, the EKS cluster,
+// and the data stores of the by-type layout
+var moduleKinds = []string{"vpc", "eks", "data"}
+
+// byTypeModuleNames are the names of the modules of the by-type layout
+var byTypeModuleNames = map[string]string{"vpc": "network", "eks": "compute", "data": "data"}
+
+// dataResourceTypes are the resource types of the data module of the by-type layout
+var dataResourceTypes = []models.ResourceType{models.ResourceS3Bucket, models.ResourceRDSInstance, models.ResourceDynamoDB}
+
+// moduleNamePattern matches the names of module directories and calls
+var moduleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// ValidateModuleLayout checks the layout of the modules
+func ValidateModuleLayout(layout string) error {
+	if !contains(ModuleLayouts, layout) {
+		return fmt.Errorf("invalid module layout: %s (supported layouts: %s)", layout, strings.Join(ModuleLayouts, ", "))
+	}
+	return nil
+}
+
+// ValidateModuleNames checks the custom names of the modules, which must be distinct
+func ValidateModuleNames(names map[string]string) error {
+	kinds := make([]string, 0, len(names))
+	for kind := range names {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	used := make(map[string]string)
+	for _, kind := range kinds {
+		name := names[kind]
+		if !contains(moduleKinds, kind) {
+			return fmt.Errorf("invalid module in module names: %s (modules: %s)", kind, strings.Join(moduleKinds, ", "))
+		}
+		if !moduleNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid name of the %s module: %q (use lowercase letters, digits, underscores and hyphens)", kind, name)
+		}
+		if other, ok := used[name]; ok {
+			return fmt.Errorf("the %s and %s modules are both named %s", other, kind, name)
+		}
+		used[name] = kind
+	}
+	return nil
+}
+
+// moduleLayout returns the layout of the modules, the modules layout by default
+func (g *TerraformGenerator) moduleLayout() string {
+	if g.Config.ModuleLayout == "" {
+		return ModuleLayoutModules
+	}
+	return g.Config.ModuleLayout
+}
+
+// flat reports whether the module contents are declared in the root module
+func (g *TerraformGenerator) flat() bool {
+	return g.moduleLayout() == ModuleLayoutFlat
+}
+
+// moduleName returns the name of a module's directory and call, such as network for the vpc
+// module of the by-type layout, unless it has a custom name
+func (g *TerraformGenerator) moduleName(kind string) string {
+	if name := g.Config.CustomModuleNames[kind]; name != "" {
+		return name
+	}
+	if g.moduleLayout() == ModuleLayoutByType {
+		return byTypeModuleNames[kind]
+	}
+	return kind
+}
+
+// moduleCall returns the name of a module call given as the module and the region suffix of
+// a copy in a secondary region, so vpc_us_west_2 is network_us_west_2 in the by-type layout
+func (g *TerraformGenerator) moduleCall(label string) string {
+	kind, suffix := label, ""
+	if i := strings.Index(label, "_"); i >= 0 {
+		kind, suffix = label[:i], label[i:]
+	}
+	return g.moduleName(kind) + suffix
+}
+
+// localModules returns the generated modules written under modules/: those not coming from
+// the registry, and none in the flat layout
+func (g *TerraformGenerator) localModules() []string {
+	if g.flat() {
+		return nil
+	}
+	var modules []string
+	for _, kind := range g.modules() {
+		if _, ok := registryModules[kind]; ok && g.usesRegistry() {
+			continue
+		}
+		modules = append(modules, kind)
+	}
+	return modules
+}
+
+// checkLayout checks that the model can be generated in the layout of the modules. The flat
+// layout cannot declare the copies of the VPC in secondary regions, whose resources would
+// have the names of the primary VPC's.
+func (g *TerraformGenerator) checkLayout() error {
+	if g.flat() && g.hasModule("vpc") {
+		if regions := g.vpcRegions(); len(regions) > 0 {
+			return fmt.Errorf("the flat module layout cannot generate the VPCs of secondary regions (%s); use the modules or by-type layout", strings.Join(regions, ", "))
+		}
+	}
+	return nil
+}
+
+// moduleFile is a file of a generated module
+type moduleFile struct {
+	Name    string
+	Content string
+}
+
+// moduleFiles returns the files of a generated module
+func (g *TerraformGenerator) moduleFiles(kind string) ([]moduleFile, error) {
+	var generators []func() (string, error)
+	var names []string
+	switch kind {
+	case "vpc":
+		names = []string{"main.tf", "variables.tf", "outputs.tf"}
+		generators = []func() (string, error){g.generateVpcModuleMainFile, g.generateVpcModuleVariablesFile, g.generateVpcModuleOutputsFile}
+	case "eks":
+		names = []string{"main.tf", "variables.tf", "outputs.tf", "iam.tf"}
+		generators = []func() (string, error){g.generateEksModuleMainFile, g.generateEksModuleVariablesFile, g.generateEksModuleOutputsFile, g.generateEksModuleIamFile}
+		if g.karpenter() {
+			names = append(names, "karpenter.tf")
+			generators = append(generators, g.generateEksModuleKarpenterFile)
+		}
+		if len(g.eksControllers()) > 0 {
+			names = append(names, "controllers.tf")
+			generators = append(generators, g.generateEksModuleControllersFile)
+		}
+	case "data":
+		names = []string{"main.tf", "outputs.tf"}
+		generators = []func() (string, error){g.generateDataModuleMainFile, g.generateDataModuleOutputsFile}
+	default:
+		return nil, fmt.Errorf("unknown module: %s", kind)
+	}
+
+	files := make([]moduleFile, len(names))
+	for i, generate := range generators {
+		content, err := generate()
+		if err != nil {
+			return nil, err
+		}
+		files[i] = moduleFile{Name: names[i], Content: content}
+	}
+	return files, nil
+}
+
+// dataModuleResources returns the resources of the data module of the by-type layout: the
+// data stores of the primary region, apart from those referring to resources outside the
+// module, such as a bucket replicating to a bucket of another region
+func (g *TerraformGenerator) dataModuleResources() []models.Resource {
+	if g.moduleLayout() != ModuleLayoutByType || !g.Config.CreateModules || g.Model == nil {
+		return nil
+	}
+
+	var resources []models.Resource
+	var blocks []string
+	for i, resource := range g.Model.Resources {
+		if !containsType(dataResourceTypes, resource.Type) || g.regionAlias(&g.Model.Resources[i]) != "" {
+			continue
+		}
+		block, err := g.renderResource(&g.Model.Resources[i])
+		if err != nil {
+			continue
+		}
+		resources = append(resources, resource)
+		blocks = append(blocks, block)
+	}
+
+	// A resource left out may leave out those referring to it in turn
+	for changed := true; changed; {
+		changed = false
+		declared := make(map[string]bool)
+		for _, block := range blocks {
+			for _, match := range declarationPattern.FindAllStringSubmatch(block, -1) {
+				if match[1] == "resource" && match[3] != "" {
+					declared[match[2]+"."+match[3]] = true
+				}
+			}
+		}
+		for i := 0; i < len(blocks); i++ {
+			if referencesOutside(blocks[i], declared) {
+				resources = append(resources[:i], resources[i+1:]...)
+				blocks = append(blocks[:i], blocks[i+1:]...)
+				changed = true
+				i--
+			}
+		}
+	}
+	return resources
+}
+
+// referencePattern matches the references of a block to resources, variables and locals
+var referencePattern = regexp.MustCompile(`\b(aws_[a-z0-9_]+\.[a-z0-9_]+|var\.[a-z0-9_]+|local\.[a-z0-9_]+)\b`)
+
+// referencesOutside reports whether a block refers to something but the declared resources
+func referencesOutside(block string, declared map[string]bool) bool {
+	for _, reference := range referencePattern.FindAllString(block, -1) {
+		if !declared[reference] {
+			return true
+		}
+	}
+	return false
+}
+
+// generateDataModuleMainFile generates the data module main.tf, with the data stores
+func (g *TerraformGenerator) generateDataModuleMainFile() (string, error) {
+	var content strings.Builder
+	for i := range g.dataModule {
+		block, err := g.renderResource(&g.dataModule[i])
+		if err != nil {
+			return "", err
+		}
+		content.WriteString(block)
+	}
+	return strings.TrimRight(template.FormatRenderedContent(template.FormatTerraform, content.String()), "\n") + "\n", nil
+}
+
+// generateDataModuleOutputsFile generates the data module outputs.tf, with the ID of each
+// data store
+func (g *TerraformGenerator) generateDataModuleOutputsFile() (string, error) {
+	return strings.TrimRight(resourceOutputs(g.dataModule), "\n") + "\n", nil
+}
+
+// dataModuleBlock returns the call of the data module in main.tf
+func (g *TerraformGenerator) dataModuleBlock() string {
+	name := g.moduleName("data")
+	return `module "` + name + `" {
+  source = "./modules/` + name + `"
+}
+
+`
+}
+
+// dataModuleOutputs returns the root outputs of the data stores of the data module, named
+// as those of the resources declared in the root module
+func (g *TerraformGenerator) dataModuleOutputs() string {
+	var content strings.Builder
+	for _, resource := range g.dataModule {
+		label := resourceLabel(resource)
+		fmt.Fprintf(&content, `output "%s_id" {
+  description = "The ID of the %s %s"
+  value       = %s
+}
+
+`, label, strings.ReplaceAll(string(resource.Type), "_", " "), resource.Name, g.moduleOutput("data", label+"_id"))
+	}
+	return content.String()
+}
+
+// flattenModules declares the contents of the generated modules in the root module for the
+// flat layout, returning main.tf without their module calls and the files declaring them,
+// such as vpc.tf and eks_iam.tf. The inputs of each module call and the outputs of the
+// module become locals prefixed with the module's name, such as local.vpc_vpc_cidr and
+// local.vpc_vpc_id, which the root files refer to instead of the module.
+func (g *TerraformGenerator) flattenModules(mainTf string) (string, []moduleFile, error) {
+	file, diags := hclwrite.ParseConfig([]byte(mainTf), "main.tf", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return "", nil, fmt.Errorf("failed to parse main.tf: %s", diags.Error())
+	}
+
+	var flattened []moduleFile
+	for _, kind := range g.modules() {
+		name := g.moduleName(kind)
+		inputs := make(map[string]string)
+		if block := file.Body().FirstMatchingBlock("module", []string{name}); block != nil {
+			for attribute, value := range block.Body().Attributes() {
+				if attribute != "source" && attribute != "providers" {
+					inputs[attribute] = expressionText(value.Expr())
+				}
+			}
+			file.Body().RemoveBlock(block)
+		}
+
+		files, err := g.moduleFiles(kind)
+		if err != nil {
+			return "", nil, err
+		}
+		moduleFlattened, err := flattenModule(name, files, inputs)
+		if err != nil {
+			return "", nil, err
+		}
+		flattened = append(flattened, moduleFlattened...)
+	}
+
+	remaining := strings.TrimSpace(string(file.Bytes()))
+	if remaining != "" {
+		remaining += "\n"
+	}
+	return remaining, flattened, nil
+}
+
+// flattenModule returns the files declaring a module's resources in the root module: main.tf
+// becomes <name>.tf, between the locals of the module's inputs and of its outputs, and its
+// other files are prefixed with its name, such as eks_iam.tf. A variable the module call
+// does not set gets its default.
+func flattenModule(name string, files []moduleFile, inputs map[string]string) ([]moduleFile, error) {
+	var variables, variableValues, outputs, outputValues []string
+	var declarations []moduleFile
+	for _, file := range files {
+		if file.Name != "variables.tf" && file.Name != "outputs.tf" {
+			declarations = append(declarations, file)
+			continue
+		}
+		parsed, diags := hclwrite.ParseConfig([]byte(file.Content), file.Name, hcl.Pos{Line: 1, Column: 1})
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("failed to parse the %s module's %s: %s", name, file.Name, diags.Error())
+		}
+		for _, block := range parsed.Body().Blocks() {
+			if len(block.Labels()) != 1 {
+				continue
+			}
+			switch block.Type() {
+			case "variable":
+				value := "null"
+				if input, ok := inputs[block.Labels()[0]]; ok {
+					value = input
+				} else if defaultValue := block.Body().GetAttribute("default"); defaultValue != nil {
+					value = expressionText(defaultValue.Expr())
+				}
+				variables = append(variables, block.Labels()[0])
+				variableValues = append(variableValues, value)
+			case "output":
+				if value := block.Body().GetAttribute("value"); value != nil {
+					outputs = append(outputs, block.Labels()[0])
+					outputValues = append(outputValues, expressionText(value.Expr()))
+				}
+			}
+		}
+	}
+
+	// The module's references to its variables refer to their locals instead
+	rename := func(content string) string { return content }
+	if len(variables) > 0 {
+		pattern := regexp.MustCompile(`\bvar\.(` + strings.Join(variables, "|") + `)\b`)
+		rename = func(content string) string {
+			return pattern.ReplaceAllString(content, "local."+name+"_$1")
+		}
+	}
+	for i := range outputValues {
+		outputValues[i] = rename(outputValues[i])
+	}
+
+	var flattened []moduleFile
+	for _, file := range declarations {
+		if file.Name != "main.tf" {
+			flattened = append(flattened, moduleFile{Name: name + "_" + file.Name, Content: rename(file.Content)})
+			continue
+		}
+		var content strings.Builder
+		if len(variables) > 0 {
+			content.WriteString("# Inputs of the " + name + " resources\n")
+			content.WriteString(localsBlock(name, variables, variableValues) + "\n")
+		}
+		content.WriteString(strings.TrimRight(rename(file.Content), "\n") + "\n")
+		if len(outputs) > 0 {
+			content.WriteString("\n# Outputs of the " + name + " resources\n")
+			content.WriteString(localsBlock(name, outputs, outputValues))
+		}
+		flattened = append([]moduleFile{{Name: name + ".tf", Content: content.String()}}, flattened...)
+	}
+	return flattened, nil
+}
+
+// localsBlock returns a locals block with a local for each name, prefixed with a module's
+// name, with aligned equals signs
+func localsBlock(prefix string, names, values []string) string {
+	width := 0
+	for _, name := range names {
+		if len(prefix+"_"+name) > width {
+			width = len(prefix + "_" + name)
+		}
+	}
+	var block strings.Builder
+	block.WriteString("locals {\n")
+	for i, name := range names {
+		fmt.Fprintf(&block, "  %-*s = %s\n", width, prefix+"_"+name, values[i])
+	}
+	block.WriteString("}\n")
+	return block.String()
+}
+
+// expressionText returns the source of an expression, without its surrounding spaces
+func expressionText(expression *hclwrite.Expression) string {
+	return strings.TrimSpace(string(expression.BuildTokens(nil).Bytes()))
+}
@@ -0,0 +1,218 @@
+package terraform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// Lifecycle holds the lifecycle settings added to the resources of a Terraform type
+type Lifecycle struct {
+	// PreventDestroy makes Terraform refuse plans destroying the resources
+	PreventDestroy bool `mapstructure:"prevent_destroy"`
+	// IgnoreChanges lists the attributes whose changes outside Terraform are not reverted,
+	// such as scaling_config[0].desired_size of a node group scaled by the cluster autoscaler
+	IgnoreChanges []string `mapstructure:"ignore_changes"`
+}
+
+// terraformTypePattern matches the Terraform types of AWS resources
+var terraformTypePattern = regexp.MustCompile(`^aws_[a-z0-9_]+$`)
+
+// attributePathPattern matches an attribute of ignore_changes, such as tags["Owner"] or
+// scaling_config[0].desired_size
+var attributePathPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*(\[[0-9]+\]|\["[^"]*"\]|\.[a-z_][a-z0-9_]*)*$`)
+
+// ValidateLifecycle checks the resource types and attributes of lifecycle settings
+func ValidateLifecycle(settings map[string]Lifecycle) error {
+	for _, resourceType := range lifecycleTypes(settings) {
+		if !terraformTypePattern.MatchString(resourceType) {
+			return fmt.Errorf("invalid resource type for lifecycle settings: %s (use a Terraform type, such as aws_eks_cluster)", resourceType)
+		}
+		for _, attribute := range settings[resourceType].IgnoreChanges {
+			if attribute != "all" && !attributePathPattern.MatchString(attribute) {
+				return fmt.Errorf("invalid ignore_changes attribute of %s: %q", resourceType, attribute)
+			}
+		}
+	}
+	return nil
+}
+
+// applyLifecycle adds the lifecycle settings to the resources of their types in the
+// configuration in dir and its local modules. A resource with a lifecycle block gets the
+// settings it does not have yet. Types matching no resource, such as those inside registry
+// modules, are logged.
+func applyLifecycle(dir string, settings map[string]Lifecycle) error {
+	if len(settings) == 0 {
+		return nil
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.tf"))
+	moduleFiles, _ := filepath.Glob(filepath.Join(dir, "modules", "*", "*.tf"))
+	files = append(files, moduleFiles...)
+
+	applied := make(map[string]bool)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		updated := string(content)
+		for _, resourceType := range lifecycleTypes(settings) {
+			var matched bool
+			updated, matched = addLifecycle(updated, resourceType, settings[resourceType])
+			applied[resourceType] = applied[resourceType] || matched
+		}
+		if updated != string(content) {
+			if err := rewriteFile(file, updated); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, resourceType := range lifecycleTypes(settings) {
+		if !applied[resourceType] {
+			utils.GetLogger().Warnw("Lifecycle settings match no generated resource", "type", resourceType)
+		}
+	}
+	return nil
+}
+
+// addLifecycle adds lifecycle settings to the resource blocks of a type in a file's content,
+// reporting whether it has any
+func addLifecycle(content, resourceType string, lifecycle Lifecycle) (string, bool) {
+	header := regexp.MustCompile(`(?m)^resource\s+"` + regexp.QuoteMeta(resourceType) + `"\s+"[^"]+"\s*\{`)
+	matches := header.FindAllStringIndex(content, -1)
+
+	// Blocks are edited from the last, so the positions of the others stay valid
+	for i := len(matches) - 1; i >= 0; i-- {
+		open := matches[i][1] - 1
+		end := blockEnd(content, open)
+		if end < 0 {
+			continue
+		}
+		content = content[:open] + lifecycleBlock(content[open:end], lifecycle) + content[end:]
+	}
+	return content, len(matches) > 0
+}
+
+// existingLifecyclePattern matches the lifecycle block of a resource
+var existingLifecyclePattern = regexp.MustCompile(`(?m)^([ \t]*)lifecycle\s*\{\n`)
+
+// lifecycleBlock returns a resource's body, from its opening brace up to its closing brace,
+// with the lifecycle settings it does not have yet
+func lifecycleBlock(body string, lifecycle Lifecycle) string {
+	var settings []string
+	if lifecycle.PreventDestroy && !strings.Contains(body, "prevent_destroy") {
+		settings = append(settings, "prevent_destroy = true")
+	}
+	if len(lifecycle.IgnoreChanges) > 0 && !strings.Contains(body, "ignore_changes") {
+		// Aligned with prevent_destroy, as terraform fmt aligns them
+		assignment := " = ["
+		if len(settings) > 0 {
+			assignment = "  = ["
+		}
+		settings = append(settings, "ignore_changes"+assignment+strings.Join(lifecycle.IgnoreChanges, ", ")+"]")
+	}
+	if len(settings) == 0 {
+		return body
+	}
+
+	if location := existingLifecyclePattern.FindStringSubmatchIndex(body); location != nil {
+		indent := body[location[2]:location[3]] + "  "
+		return body[:location[1]] + indent + strings.Join(settings, "\n"+indent) + "\n" + body[location[1]:]
+	}
+	if !strings.HasSuffix(body, "\n") {
+		body += "\n"
+	}
+	return body + "\n  lifecycle {\n    " + strings.Join(settings, "\n    ") + "\n  }\n"
+}
+
+// blockEnd returns the position of the brace closing the block opened at open, skipping
+// strings, heredocs and comments, or -1 when it is not closed
+func blockEnd(content string, open int) int {
+	depth := 0
+	for i := open; i < len(content); i++ {
+		switch c := content[i]; {
+		case c == '#' || strings.HasPrefix(content[i:], "//"):
+			if next := strings.IndexByte(content[i:], '\n'); next >= 0 {
+				i += next
+			} else {
+				return -1
+			}
+		case c == '"':
+			i = stringEnd(content, i)
+			if i < 0 {
+				return -1
+			}
+		case strings.HasPrefix(content[i:], "<<"):
+			i = heredocEnd(content, i)
+			if i < 0 {
+				return -1
+			}
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// stringEnd returns the position of the quote closing the string opened at open, skipping
+// the expressions interpolated into it, or -1
+func stringEnd(content string, open int) int {
+	for i := open + 1; i < len(content); i++ {
+		switch content[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		case '$', '%':
+			if i+1 < len(content) && content[i+1] == '{' {
+				i = blockEnd(content, i+1)
+				if i < 0 {
+					return -1
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// heredocMarkerPattern matches the start of a heredoc, such as <<EOF or <<-POLICY
+var heredocMarkerPattern = regexp.MustCompile(`^<<-?([A-Za-z_][A-Za-z0-9_]*)\n`)
+
+// heredocEnd returns the position of the end of the heredoc started at start, or -1
+func heredocEnd(content string, start int) int {
+	marker := heredocMarkerPattern.FindStringSubmatch(content[start:])
+	if marker == nil {
+		return start + 1
+	}
+	lines := strings.SplitAfter(content[start+len(marker[0]):], "\n")
+	position := start + len(marker[0])
+	for _, line := range lines {
+		position += len(line)
+		if strings.TrimSpace(line) == marker[1] {
+			return position - 1
+		}
+	}
+	return -1
+}
+
+// lifecycleTypes returns the resource types of lifecycle settings in order
+func lifecycleTypes(settings map[string]Lifecycle) []string {
+	types := make([]string, 0, len(settings))
+	for resourceType := range settings {
+		types = append(types, resourceType)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -0,0 +1,94 @@
+package terraform
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// LockFile is the dependency lock file recording the versions and checksums of the providers
+const LockFile = ".terraform.lock.hcl"
+
+// DefaultLockPlatforms are the platforms the lock file has checksums for by default, those
+// Terraform usually runs on in CI and on workstations
+var DefaultLockPlatforms = []string{"linux_amd64", "linux_arm64", "darwin_amd64", "darwin_arm64", "windows_amd64"}
+
+// platformPattern matches a platform of Terraform providers, such as linux_amd64
+var platformPattern = regexp.MustCompile(`^[a-z0-9]+_[a-z0-9]+$`)
+
+// ValidateLockPlatforms checks the platforms of the lock file
+func ValidateLockPlatforms(platforms []string) error {
+	for _, platform := range platforms {
+		if !platformPattern.MatchString(platform) {
+			return fmt.Errorf("invalid platform: %q (use an OS and architecture, such as linux_amd64)", platform)
+		}
+	}
+	return nil
+}
+
+// lockProviders writes the dependency lock file of the configuration in dir with
+// terraform providers lock, so that terraform init installs the same provider versions, with
+// the same checksums, on each platform. The configuration is initialized without its backend
+// first, with the provider cache and modules in a temporary directory, so the registry
+// modules' providers are locked too.
+func lockProviders(dir string, config *TerraformConfig) error {
+	if len(config.LockPlatforms) == 0 {
+		return nil
+	}
+
+	terraform := config.TerraformPath
+	if terraform == "" {
+		var err error
+		if terraform, err = exec.LookPath("terraform"); err != nil {
+			return fmt.Errorf("terraform was not found in PATH")
+		}
+	}
+
+	dataDir, err := os.MkdirTemp("", "iacgen-lock-")
+	if err != nil {
+		return fmt.Errorf("failed to create a data directory: %w", err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	run := func(args ...string) error {
+		command := args[0]
+		if command == "providers" {
+			command += " " + args[1]
+		}
+		cmd := exec.Command(terraform, args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "TF_DATA_DIR="+dataDir, "TF_IN_AUTOMATION=1")
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("terraform %s failed: %w\n%s", command, err, strings.TrimSpace(output.String()))
+		}
+		return nil
+	}
+
+	if err := run("init", "-backend=false", "-input=false", "-no-color"); err != nil {
+		return err
+	}
+	args := []string{"providers", "lock"}
+	for _, platform := range config.LockPlatforms {
+		args = append(args, "-platform="+platform)
+	}
+	if err := run(args...); err != nil {
+		return err
+	}
+
+	// The lock file is written again, so it is reported with the generated files
+	path := filepath.Join(dir, LockFile)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("terraform did not write %s: %w", LockFile, err)
+	}
+	return utils.WriteToFile(path, string(content))
+}
@@ -0,0 +1,312 @@
+package terraform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// ManifestFile is the file, in the output directory, recording the resources of the last
+// generation, against which the next one detects renamed resources
+const ManifestFile = ".iacgen-manifest.json"
+
+// GenerationManifest records the resources a generation declared in the root module
+type GenerationManifest struct {
+	Resources []ManifestResource `json:"resources"`
+}
+
+// ManifestResource is a model resource and the root module resources generated for it
+type ManifestResource struct {
+	Type models.ResourceType `json:"type"`
+	Name string              `json:"name"`
+	// Fingerprint identifies the resource by its type and properties, apart from its name
+	// and tags, so it survives a rename
+	Fingerprint string `json:"fingerprint"`
+	// Addresses are the resources generated for it, such as aws_s3_bucket.app_logs and
+	// aws_s3_bucket_versioning.app_logs_versioning
+	Addresses []string `json:"addresses"`
+}
+
+// move is a moved block, from the address of a resource in the state to its new address
+type move struct {
+	From string
+	To   string
+}
+
+// recordGeneration writes the manifest of the generation in dir, and moved.tf with a moved
+// block for each resource renamed since the previous generation, so that Terraform moves it
+// in the state instead of destroying and creating it. The moved blocks of earlier
+// generations are kept while they lead to a declared resource, as the state may not have
+// been applied since.
+func recordGeneration(dir string, model *models.InfrastructureModel) error {
+	declared := declaredAddresses(dir)
+	manifest := generationManifest(model, declared)
+
+	moves := previousMoves(dir)
+	if previous, err := loadManifest(dir); err != nil {
+		utils.GetLogger().Warnw("Ignoring the previous generation manifest", "error", err)
+	} else if previous != nil {
+		moves = append(moves, renames(previous, manifest, declared)...)
+	}
+	moves = collapseMoves(moves, declared)
+
+	// A moved.tf without moves is kept empty, as the files of a run are copied over the
+	// output directory
+	movedPath := filepath.Join(dir, "moved.tf")
+	if len(moves) > 0 || utils.FileExists(movedPath) {
+		var content strings.Builder
+		content.WriteString("# Resources renamed since an earlier generation, moved in the state instead of replaced.\n# Remove these blocks once the configuration has been applied everywhere.\n")
+		for _, m := range moves {
+			fmt.Fprintf(&content, "\nmoved {\n  from = %s\n  to   = %s\n}\n", m.From, m.To)
+		}
+		if err := utils.WriteToFile(movedPath, content.String()); err != nil {
+			return fmt.Errorf("failed to write moved.tf: %w", err)
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the generation manifest: %w", err)
+	}
+	if err := utils.WriteToFile(filepath.Join(dir, ManifestFile), string(data)+"\n"); err != nil {
+		return fmt.Errorf("failed to write the generation manifest: %w", err)
+	}
+	return nil
+}
+
+// generationManifest returns the manifest of the model resources declared in the root
+// module. Each declared resource belongs to the model resource with the longest label it
+// starts with, so aws_s3_bucket_versioning.app_logs_versioning belongs to app-logs.
+func generationManifest(model *models.InfrastructureModel, declared map[string]bool) *GenerationManifest {
+	manifest := &GenerationManifest{Resources: []ManifestResource{}}
+	if model == nil {
+		return manifest
+	}
+
+	names := make(map[string]bool, len(model.Resources))
+	for _, resource := range model.Resources {
+		names[resource.Name] = true
+	}
+
+	owners := make(map[string]int)
+	ownerLabels := make(map[string]string)
+	for i, resource := range model.Resources {
+		terraformType, err := mapResourceType(resource.Type)
+		if err != nil {
+			continue
+		}
+		for _, label := range resourceLabels(resource) {
+			if !declared[terraformType+"."+label] {
+				continue
+			}
+			for address := range declared {
+				_, addressLabel, _ := strings.Cut(address, ".")
+				if strings.HasPrefix(address, "module.") || (addressLabel != label && !strings.HasPrefix(addressLabel, label+"_")) {
+					continue
+				}
+				if len(label) > len(ownerLabels[address]) {
+					owners[address] = i
+					ownerLabels[address] = label
+				}
+			}
+		}
+	}
+
+	for i, resource := range model.Resources {
+		var addresses []string
+		for address, owner := range owners {
+			if owner == i {
+				addresses = append(addresses, address)
+			}
+		}
+		if len(addresses) == 0 {
+			continue
+		}
+		sort.Strings(addresses)
+		manifest.Resources = append(manifest.Resources, ManifestResource{
+			Type:        resource.Type,
+			Name:        resource.Name,
+			Fingerprint: fingerprint(resource, names),
+			Addresses:   addresses,
+		})
+	}
+	return manifest
+}
+
+// resourceLabels returns the labels a resource's blocks may have, as the templates and
+// compute.tf name them
+func resourceLabels(resource models.Resource) []string {
+	labels := []string{resourceLabel(resource)}
+	if label := terraformName(resource.Name); label != labels[0] {
+		labels = append(labels, label)
+	}
+	return labels
+}
+
+// fingerprint hashes a resource's type and properties, leaving out its name, tags, the
+// properties derived from its name and the references to other resources by name, which
+// change when those are renamed
+func fingerprint(resource models.Resource, names map[string]bool) string {
+	var properties []string
+	for _, property := range resource.Properties {
+		value := fmt.Sprint(property.Value)
+		if property.Name == "name" || property.Name == "tags" || strings.Contains(value, resource.Name) || names[value] {
+			continue
+		}
+		properties = append(properties, property.Name+"="+value)
+	}
+	sort.Strings(properties)
+	sum := sha256.Sum256([]byte(string(resource.Type) + "\n" + strings.Join(properties, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// loadManifest reads the manifest of the previous generation in dir, or returns nil when
+// there is none
+func loadManifest(dir string) (*GenerationManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifest GenerationManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ManifestFile, err)
+	}
+	return &manifest, nil
+}
+
+// renames returns the moves of the resources of the previous generation that are no longer
+// declared, to the resources of the same type and fingerprint that were not declared before.
+// Resources that cannot be paired with exactly one other are left to be replaced.
+func renames(previous, current *GenerationManifest, declared map[string]bool) []move {
+	wasDeclared := make(map[string]bool)
+	for _, resource := range previous.Resources {
+		for _, address := range resource.Addresses {
+			wasDeclared[address] = true
+		}
+	}
+
+	key := func(resource ManifestResource) string {
+		return string(resource.Type) + "/" + resource.Fingerprint
+	}
+	gone := make(map[string][]ManifestResource)
+	for _, resource := range previous.Resources {
+		if len(resource.Addresses) > 0 && !declared[resource.Addresses[0]] {
+			gone[key(resource)] = append(gone[key(resource)], resource)
+		}
+	}
+	added := make(map[string][]ManifestResource)
+	for _, resource := range current.Resources {
+		if !wasDeclared[resource.Addresses[0]] {
+			added[key(resource)] = append(added[key(resource)], resource)
+		}
+	}
+
+	var moves []move
+	for _, resource := range current.Resources {
+		from, to := gone[key(resource)], added[key(resource)]
+		if len(to) == 0 || to[0].Name != resource.Name || len(from) == 0 {
+			continue
+		}
+		if len(from) != 1 || len(to) != 1 {
+			utils.GetLogger().Warnw("Cannot tell which resource was renamed; it will be replaced", "type", resource.Type, "name", resource.Name)
+			continue
+		}
+		moves = append(moves, addressMoves(from[0], resource)...)
+	}
+	return moves
+}
+
+// addressMoves pairs the addresses of a renamed resource: each old address moves to the new
+// address of the same type whose label has the same suffix after the resource's label
+func addressMoves(from, to ManifestResource) []move {
+	var moves []move
+	for _, oldAddress := range from.Addresses {
+		oldType, oldLabel, _ := strings.Cut(oldAddress, ".")
+		for _, newAddress := range to.Addresses {
+			newType, newLabel, _ := strings.Cut(newAddress, ".")
+			if oldType != newType {
+				continue
+			}
+			for _, fromLabel := range resourceLabels(models.Resource{Name: from.Name}) {
+				suffix := strings.TrimPrefix(oldLabel, fromLabel)
+				if suffix == oldLabel && oldLabel != fromLabel {
+					continue
+				}
+				matched := false
+				for _, toLabel := range resourceLabels(models.Resource{Name: to.Name}) {
+					if newLabel == toLabel+suffix {
+						moves = append(moves, move{From: oldAddress, To: newAddress})
+						matched = true
+						break
+					}
+				}
+				if matched {
+					break
+				}
+			}
+		}
+	}
+	return moves
+}
+
+// movedPattern matches the moved blocks of moved.tf
+var movedPattern = regexp.MustCompile(`moved\s*\{\s*from\s*=\s*(\S+)\s*to\s*=\s*(\S+)\s*\}`)
+
+// previousMoves returns the moved blocks of the moved.tf in dir
+func previousMoves(dir string) []move {
+	content, err := os.ReadFile(filepath.Join(dir, "moved.tf"))
+	if err != nil {
+		return nil
+	}
+	var moves []move
+	for _, match := range movedPattern.FindAllStringSubmatch(string(content), -1) {
+		moves = append(moves, move{From: match[1], To: match[2]})
+	}
+	return moves
+}
+
+// collapseMoves follows each chain of moves to the declared resource it ends at, returning a
+// move from each address no longer declared straight to that resource. Chains that end
+// elsewhere, such as at a resource since removed, are dropped, and so are moves from an
+// address declared again, which a rename back would otherwise turn into a cycle.
+func collapseMoves(moves []move, declared map[string]bool) []move {
+	next := make(map[string]string)
+	var sources []string
+	for _, m := range moves {
+		if _, ok := next[m.From]; !ok {
+			sources = append(sources, m.From)
+		}
+		next[m.From] = m.To
+	}
+
+	var collapsed []move
+	for _, from := range sources {
+		if declared[from] {
+			continue
+		}
+		to := next[from]
+		for i := 0; i < len(moves) && !declared[to]; i++ {
+			following, ok := next[to]
+			if !ok {
+				break
+			}
+			to = following
+		}
+		if declared[to] {
+			collapsed = append(collapsed, move{From: from, To: to})
+		}
+	}
+	return collapsed
+}
@@ -0,0 +1,74 @@
+package terraform
+
+import (
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// regionAlias returns the provider alias of a resource outside the primary region, or an
+// empty string for a resource in it
+func (g *TerraformGenerator) regionAlias(resource *models.Resource) string {
+	if g.Model == nil || g.Model.Region == "" {
+		return ""
+	}
+	region := g.Model.ResourceRegion(resource)
+	if region == g.Model.Region {
+		return ""
+	}
+	return providerAlias(region)
+}
+
+// providerArgument returns the provider argument of a resource outside the primary region,
+// creating it through that region's aliased provider, or an empty string
+func (g *TerraformGenerator) providerArgument(resource *models.Resource) string {
+	if alias := g.regionAlias(resource); alias != "" {
+		return "  provider = aws." + alias + "\n"
+	}
+	return ""
+}
+
+// regionalLabel suffixes a label with the provider alias of a resource outside the primary
+// region, so the data sources and shared resources of each region get their own blocks
+func (g *TerraformGenerator) regionalLabel(label string, resource *models.Resource) string {
+	if alias := g.regionAlias(resource); alias != "" {
+		return label + "_" + alias
+	}
+	return label
+}
+
+// vpcModuleLabel returns the label of the VPC module call in a resource's region: vpc, or
+// the copy of a secondary region such as vpc_us_west_2
+func (g *TerraformGenerator) vpcModuleLabel(resource *models.Resource) string {
+	return g.regionalLabel("vpc", resource)
+}
+
+// vpcRegions returns the secondary regions the model has a VPC in, each of which gets a copy
+// of the VPC module
+func (g *TerraformGenerator) vpcRegions() []string {
+	var regions []string
+	for _, region := range g.secondaryRegions() {
+		for i, resource := range g.Model.Resources {
+			if resource.Type == models.ResourceVPC && g.Model.ResourceRegion(&g.Model.Resources[i]) == region {
+				regions = append(regions, region)
+				break
+			}
+		}
+	}
+	return regions
+}
+
+// inModuleRegion reports whether a resource is in a region the modules generate: the
+// primary region, or for the network a secondary region with a copy of the VPC module
+func (g *TerraformGenerator) inModuleRegion(resource *models.Resource) bool {
+	if g.regionAlias(resource) == "" {
+		return true
+	}
+	return containsType(moduleResourceTypes["vpc"], resource.Type) && contains(g.vpcRegions(), g.Model.ResourceRegion(resource))
+}
+
+// hasVPCModuleFor reports whether the VPC module is generated in a resource's region
+func (g *TerraformGenerator) hasVPCModuleFor(resource *models.Resource) bool {
+	if !g.hasModule("vpc") {
+		return false
+	}
+	return g.regionAlias(resource) == "" || contains(g.vpcRegions(), g.Model.ResourceRegion(resource))
+}
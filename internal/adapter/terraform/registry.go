@@ -0,0 +1,200 @@
+package terraform
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Sources of the VPC and EKS modules of the generated configuration
+const (
+	// ModuleSourceLocal generates the modules under modules/
+	ModuleSourceLocal = "local"
+	// ModuleSourceRegistry uses the community modules of the Terraform registry
+	ModuleSourceRegistry = "registry"
+)
+
+// ModuleSources are the sources the modules can come from
+var ModuleSources = []string{ModuleSourceLocal, ModuleSourceRegistry}
+
+// registryModule is a module of the Terraform registry, pinned to a version
+type registryModule struct {
+	Source  string
+	Version string
+	// ProviderVersion is the earliest AWS provider version the module supports
+	ProviderVersion string
+	// SensitiveOutputs are the outputs carrying secrets, which the module does not mark
+	// sensitive itself
+	SensitiveOutputs []string
+}
+
+// registryModules are the registry modules replacing the generated ones, the module creating
+// the IAM roles of add-on service accounts, and the Karpenter module
+var registryModules = map[string]registryModule{
+	"vpc":       {Source: "terraform-aws-modules/vpc/aws", Version: "5.13.0", ProviderVersion: "5.46.0"},
+	"eks":       {Source: "terraform-aws-modules/eks/aws", Version: "20.24.0", ProviderVersion: "5.61.0", SensitiveOutputs: []string{"cluster_certificate_authority_data"}},
+	"irsa":      {Source: "terraform-aws-modules/iam/aws//modules/iam-role-for-service-accounts-eks", Version: "5.44.0", ProviderVersion: "4.0.0"},
+	"karpenter": {Source: "terraform-aws-modules/eks/aws//modules/karpenter", Version: "20.24.0", ProviderVersion: "5.61.0"},
+}
+
+// registryOutputs maps the outputs of the generated modules to the expressions giving the
+// same values from the registry modules, where they differ
+var registryOutputs = map[string]string{
+	"vpc.private_subnet_ids":     "module.vpc.private_subnets",
+	"vpc.public_subnet_ids":      "module.vpc.public_subnets",
+	"eks.cluster_id":             "module.eks.cluster_name",
+	"eks.cluster_ca_certificate": "module.eks.cluster_certificate_authority_data",
+	"eks.addon_versions":         "{ for name, addon in module.eks.cluster_addons : name => addon.addon_version }",
+	"eks.controller_role_arns":   "{ for name, role in module.controller_irsa : name => role.iam_role_arn }",
+	// Karpenter has a module of its own in the registry
+	"eks.karpenter_controller_role_arn":     "module.karpenter.iam_role_arn",
+	"eks.karpenter_interruption_queue_name": "module.karpenter.queue_name",
+	"eks.karpenter_node_role_name":          "module.karpenter.node_iam_role_name",
+	"eks.karpenter_instance_profile_name":   "module.karpenter.instance_profile_name",
+}
+
+// ValidateModuleSource checks the source of the modules
+func ValidateModuleSource(source string) error {
+	if !contains(ModuleSources, source) {
+		return fmt.Errorf("invalid module source: %s (supported sources: %s)", source, strings.Join(ModuleSources, ", "))
+	}
+	return nil
+}
+
+// usesRegistry reports whether the modules come from the Terraform registry
+func (g *TerraformGenerator) usesRegistry() bool {
+	return g.Config.ModuleSource == ModuleSourceRegistry
+}
+
+// moduleOutput returns the expression of an output of a module call, such as
+// moduleOutput("vpc", "vpc_id"), named as the generated module names it. The copies of a
+// module in secondary regions, such as vpc_us_west_2, have the outputs of the module. The
+// module is given by its kind, and the call has the module's configured name.
+func (g *TerraformGenerator) moduleOutput(module, output string) string {
+	call := g.moduleCall(module)
+	// The flat layout has the outputs of the modules as locals
+	if g.flat() {
+		return "local." + call + "_" + output
+	}
+	if g.usesRegistry() {
+		kind, _, _ := strings.Cut(module, "_")
+		if expression, ok := registryOutputs[kind+"."+output]; ok {
+			return strings.ReplaceAll(expression, "module."+kind+".", "module."+call+".")
+		}
+	}
+	return "module." + call + "." + output
+}
+
+// registryVpcModule returns a block of the registry VPC module. The root variables are those
+// of the generated module; name, azs and providers differ for a copy in a secondary region.
+func registryVpcModule(label, name, azs, providers string) string {
+	module := registryModules["vpc"]
+	return `module "` + label + `" {
+  source  = "` + module.Source + `"
+  version = "` + module.Version + `"
+` + providers + `
+  name = ` + name + `
+  cidr = var.vpc_cidr
+  azs  = ` + azs + `
+
+  private_subnets    = var.private_subnet_cidrs
+  public_subnets     = var.public_subnet_cidrs
+  enable_nat_gateway = var.enable_nat_gateway
+  single_nat_gateway = var.single_nat_gateway
+
+  enable_dns_hostnames = true
+  enable_dns_support   = true
+
+  public_subnet_tags = {
+    "kubernetes.io/role/elb" = "1"
+  }
+  private_subnet_tags = {
+    "kubernetes.io/role/internal-elb" = "1"
+  }
+
+  tags = var.vpc_tags
+}
+
+`
+}
+
+// registryEksModule returns the block of the registry EKS module, with the node groups,
+// add-ons and Fargate profiles of the root variables mapped to its inputs, and the module
+// creating the IAM roles of add-ons with a service account
+func (g *TerraformGenerator) registryEksModule(hasVPC bool) string {
+	eks := registryModules["eks"]
+	irsa := registryModules["irsa"]
+	vpcID, subnetIDs := "var.vpc_id", "var.subnet_ids"
+	if hasVPC {
+		vpcID, subnetIDs = g.moduleOutput("vpc", "vpc_id"), g.moduleOutput("vpc", "private_subnet_ids")
+	}
+
+	return `module "` + g.moduleName("eks") + `" {
+  source  = "` + eks.Source + `"
+  version = "` + eks.Version + `"
+
+  cluster_name    = var.cluster_name
+  cluster_version = var.cluster_version
+` + g.serviceCIDRArgument("cluster_service_ipv4_cidr") + `
+  vpc_id     = ` + vpcID + `
+  subnet_ids = ` + subnetIDs + `
+
+  cluster_endpoint_public_access           = true
+  enable_cluster_creator_admin_permissions = true
+
+  # The node groups keep the EKS default launch template, so that disk_size applies
+  eks_managed_node_groups = {
+    for name, group in var.node_groups : name => {
+      instance_types             = group.instance_types
+      capacity_type              = group.capacity_type
+      desired_size               = group.desired_size
+      min_size                   = group.min_size
+      max_size                   = group.max_size
+      disk_size                  = group.disk_size
+      use_custom_launch_template = false
+      tags                       = ` + g.registryNodeGroupTags() + `
+    }
+  }
+
+  # An add-on without a pinned version gets the latest version for the cluster
+  cluster_addons = {
+    for name, addon in var.addons : name => {
+      addon_version               = addon.addon_version
+      most_recent                 = addon.addon_version == null
+      service_account_role_arn    = addon.service_account != null ? module.addon_irsa[name].iam_role_arn : null
+      resolve_conflicts_on_create = "OVERWRITE"
+      resolve_conflicts_on_update = "OVERWRITE"
+    }
+  }
+
+  fargate_profiles = {
+    for name, profile in var.fargate_profiles : name => {
+      name      = name
+      selectors = [for namespace in profile.namespaces : { namespace = namespace }]
+    }
+  }
+
+  tags = var.eks_tags
+}
+
+# IAM roles for the service accounts of add-ons that call AWS APIs (IRSA)
+module "addon_irsa" {
+  source  = "` + irsa.Source + `"
+  version = "` + irsa.Version + `"
+
+  for_each = { for name, addon in var.addons : name => addon if addon.service_account != null }
+
+  role_name        = "${var.cluster_name}-${each.key}"
+  role_policy_arns = each.value.policy_arn != null ? { policy = each.value.policy_arn } : {}
+
+  oidc_providers = {
+    main = {
+      provider_arn               = ` + g.moduleOutput("eks", "oidc_provider_arn") + `
+      namespace_service_accounts = [replace(each.value.service_account, "/", ":")]
+    }
+  }
+
+  tags = var.eks_tags
+}
+
+`
+}
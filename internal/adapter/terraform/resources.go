@@ -0,0 +1,308 @@
+package terraform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/template"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// moduleResourceTypes are the resource types each module generates
+var moduleResourceTypes = map[string][]models.ResourceType{
+	"vpc": {models.ResourceVPC, models.ResourceSubnet, models.ResourceIGW, models.ResourceNATGateway},
+	"eks": {models.ResourceEKSCluster, models.ResourceNodeGroup, models.ResourceEKSAddon, models.ResourceFargateProfile},
+}
+
+// computeResourceTypes are the resource types compute.tf generates
+var computeResourceTypes = []models.ResourceType{
+	models.ResourceLaunchTemplate, models.ResourceAutoScalingGroup, models.ResourceEC2Instance,
+}
+
+// modules returns the configured modules the model has resources for in the regions the
+// modules generate, and the data module of the by-type layout when it has data stores
+func (g *TerraformGenerator) modules() []string {
+	if !g.Config.CreateModules || g.Model == nil {
+		return nil
+	}
+	var modules []string
+	for _, name := range g.Config.ModuleNames {
+		for i, resource := range g.Model.Resources {
+			if containsType(moduleResourceTypes[name], resource.Type) && g.inModuleRegion(&g.Model.Resources[i]) {
+				modules = append(modules, name)
+				break
+			}
+		}
+	}
+	if len(g.dataModule) > 0 {
+		modules = append(modules, "data")
+	}
+	return modules
+}
+
+// hasModule reports whether a module is generated
+func (g *TerraformGenerator) hasModule(name string) bool {
+	return contains(g.modules(), name)
+}
+
+// primaryResources returns the model resources of a type in the primary region
+func (g *TerraformGenerator) primaryResources(resourceType models.ResourceType) []models.Resource {
+	var resources []models.Resource
+	if g.Model == nil {
+		return nil
+	}
+	for i, resource := range g.Model.Resources {
+		if resource.Type == resourceType && g.Model.ResourceRegion(&g.Model.Resources[i]) == g.Model.Region {
+			resources = append(resources, resource)
+		}
+	}
+	return resources
+}
+
+// vpcCIDR returns the CIDR block of the VPC in the model, or 10.0.0.0/16
+func (g *TerraformGenerator) vpcCIDR() string {
+	for _, vpc := range g.primaryResources(models.ResourceVPC) {
+		if cidr := stringProperty(vpc, "cidr_block", ""); cidr != "" {
+			return cidr
+		}
+	}
+	return "10.0.0.0/16"
+}
+
+// subnetCIDRs returns the CIDR blocks of the public and private subnets in the model, as
+// infra.IsPublicSubnet tells them apart
+func (g *TerraformGenerator) subnetCIDRs() (public []string, private []string) {
+	for _, subnet := range g.primaryResources(models.ResourceSubnet) {
+		cidr := stringProperty(subnet, "cidr_block", "")
+		if cidr == "" {
+			continue
+		}
+		if infra.IsPublicSubnet(subnet) {
+			public = append(public, cidr)
+		} else {
+			private = append(private, cidr)
+		}
+	}
+	return public, private
+}
+
+// natGatewayEnabled reports whether the model has a NAT gateway in the primary region, or
+// the workspace sets a number of them
+func (g *TerraformGenerator) natGatewayEnabled() bool {
+	if g.workspace != nil && g.workspace.NATGateways != nil {
+		return *g.workspace.NATGateways > 0
+	}
+	return len(g.primaryResources(models.ResourceNATGateway)) > 0
+}
+
+// clusterVersion returns the Kubernetes version of the EKS cluster in the model, or 1.28
+func (g *TerraformGenerator) clusterVersion() string {
+	for _, cluster := range g.primaryResources(models.ResourceEKSCluster) {
+		if version, ok := cluster.GetProperty("version"); ok && fmt.Sprint(version) != "" {
+			return fmt.Sprint(version)
+		}
+	}
+	return "1.28"
+}
+
+// serviceCIDR returns the service CIDR of the EKS cluster in the model, or "" when AWS picks it
+func (g *TerraformGenerator) serviceCIDR() string {
+	for _, cluster := range g.primaryResources(models.ResourceEKSCluster) {
+		if cidr := infra.ServiceCIDR(cluster); cidr != "" {
+			return cidr
+		}
+	}
+	return ""
+}
+
+// serviceCIDRArgument returns the argument setting the service CIDR of the cluster to the
+// root variable, in a group of its own, or "" when the model has no service CIDR
+func (g *TerraformGenerator) serviceCIDRArgument(name string) string {
+	if g.serviceCIDR() == "" {
+		return ""
+	}
+	return "\n  " + name + " = var.cluster_service_ipv4_cidr\n"
+}
+
+// serviceCIDRTfvar returns the tfvars line of the service CIDR of the cluster, or "" when the
+// model has no service CIDR
+func (g *TerraformGenerator) serviceCIDRTfvar() string {
+	if cidr := g.serviceCIDR(); cidr != "" {
+		return `cluster_service_ipv4_cidr = "` + cidr + `"
+`
+	}
+	return ""
+}
+
+// subnetAvailabilityZones returns the availability zones of the subnets in the model, in the
+// order they first appear
+func (g *TerraformGenerator) subnetAvailabilityZones() []string {
+	var zones []string
+	for _, subnet := range g.primaryResources(models.ResourceSubnet) {
+		if zone := stringProperty(subnet, "availability_zone", ""); zone != "" && !contains(zones, zone) {
+			zones = append(zones, zone)
+		}
+	}
+	return zones
+}
+
+// unmodeledResources returns the model resources that neither a generated module nor
+// compute.tf covers, in model order
+func (g *TerraformGenerator) unmodeledResources() []models.Resource {
+	if g.Model == nil {
+		return nil
+	}
+	var covered []models.ResourceType
+	for _, module := range g.modules() {
+		covered = append(covered, moduleResourceTypes[module]...)
+	}
+
+	// compute.tf generates the security groups of the instances when the VPC module is generated
+	attached := make(map[string]bool)
+	if g.hasModule("vpc") {
+		for _, resource := range g.Model.Resources {
+			if resource.Type == models.ResourceEC2Instance {
+				for _, sg := range stringSliceProperty(resource, "vpc_security_group_ids") {
+					attached[sg] = true
+				}
+			}
+		}
+	}
+
+	inDataModule := make(map[string]bool, len(g.dataModule))
+	for _, resource := range g.dataModule {
+		inDataModule[string(resource.Type)+"."+resource.Name] = true
+	}
+
+	var resources []models.Resource
+	for _, resource := range g.Model.Resources {
+		if containsType(computeResourceTypes, resource.Type) || (containsType(covered, resource.Type) && g.inModuleRegion(&resource)) ||
+			(resource.Type == models.ResourceSecurityGroup && attached[resource.Name] && g.hasVPCModuleFor(&resource)) ||
+			inDataModule[string(resource.Type)+"."+resource.Name] {
+			continue
+		}
+		resources = append(resources, resource)
+	}
+	return resources
+}
+
+// generateResourcesFile generates resources.tf with the model resources no module or
+// compute.tf covers
+func (g *TerraformGenerator) generateResourcesFile() (string, error) {
+	resources := g.unmodeledResources()
+	if len(resources) == 0 {
+		return "", nil
+	}
+
+	var content strings.Builder
+	for i := range resources {
+		block, err := g.renderResource(&resources[i])
+		if err != nil {
+			return "", err
+		}
+		content.WriteString(block)
+	}
+
+	return template.FormatRenderedContent(template.FormatTerraform, content.String()), nil
+}
+
+// renderResource renders a resource no module covers with the template the template
+// selector picks for it, or from its properties when there is no such template
+func (g *TerraformGenerator) renderResource(resource *models.Resource) (string, error) {
+	// Security groups in the VPC module's VPC reference it through the module
+	if resource.Type == models.ResourceSecurityGroup && g.hasVPCModuleFor(resource) {
+		return g.generateSecurityGroupBlock(*resource), nil
+	}
+
+	renderer := template.GetDefaultRenderer()
+	renderer.SetGlobalContext("region", g.Model.Region)
+	selector := template.GetDefaultSelector()
+
+	templateName, err := selector.SelectTemplate(template.FormatTerraform, resource)
+	if err == nil {
+		_, err = template.GetDefaultManager().GetTemplate(template.FormatTerraform, templateName)
+	}
+	if err != nil {
+		utils.GetLogger().Debugw("No template for resource, writing its properties", "type", resource.Type, "name", resource.Name)
+		block, err := resourceHCL(*resource, g.providerArgument(resource))
+		if err != nil {
+			return "", err
+		}
+		return block + "\n", nil
+	}
+
+	rendered, err := renderer.RenderResource(template.FormatTerraform, resource)
+	if err != nil {
+		return "", fmt.Errorf("failed to render %s %s: %w", resource.Type, resource.Name, err)
+	}
+	return strings.TrimSpace(rendered) + "\n\n", nil
+}
+
+// resourceHCL writes a resource without a template as a block of its properties, with the
+// provider argument of a resource outside the primary region
+func resourceHCL(resource models.Resource, provider string) (string, error) {
+	terraformType, err := mapResourceType(resource.Type)
+	if err != nil {
+		return "", err
+	}
+	block := NewHCLBlock("resource", terraformType, resourceLabel(resource))
+	for _, property := range resource.Properties {
+		if property.Name != "region" {
+			block.AddAttribute(property.Name, property.Value)
+		}
+	}
+	writer := NewHCLWriter()
+	writer.WriteBlock(block)
+	if provider != "" {
+		header, body, _ := strings.Cut(writer.String(), "\n")
+		return header + "\n" + provider + body, nil
+	}
+	return writer.String(), nil
+}
+
+// resourceLabel returns the Terraform label of a resource, as the templates name it
+func resourceLabel(resource models.Resource) string {
+	return template.SnakeCaseFunc(resource.Name)
+}
+
+// resourceOutputs returns an output with the ID of each resource, for the resources a
+// configuration declares directly
+func resourceOutputs(resources []models.Resource) string {
+	var content strings.Builder
+	for _, resource := range resources {
+		terraformType, err := mapResourceType(resource.Type)
+		if err != nil {
+			continue
+		}
+		label := resourceLabel(resource)
+		fmt.Fprintf(&content, `output "%s_id" {
+  description = "The ID of the %s %s"
+  value       = %s.%s.id
+}
+
+`, label, strings.ReplaceAll(string(resource.Type), "_", " "), resource.Name, terraformType, label)
+	}
+	return content.String()
+}
+
+// hclList returns strings as an HCL list
+func hclList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = strconv.Quote(value)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// containsType reports whether a resource type is in a list
+func containsType(types []models.ResourceType, resourceType models.ResourceType) bool {
+	for _, t := range types {
+		if t == resourceType {
+			return true
+		}
+	}
+	return false
+}
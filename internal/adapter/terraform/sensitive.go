@@ -0,0 +1,215 @@
+package terraform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/riptano/iac_generator_cli/internal/sensitive"
+)
+
+// markSensitiveOutputs marks the outputs carrying secrets as sensitive in the configuration
+// in dir and its local modules, so Terraform hides their values in plans and logs, and
+// accepts them at all when they refer to sensitive values. An output carries a secret when
+// its value refers to a secret attribute of a resource or data source, to a sensitive
+// variable, to a local carrying a secret, or to a sensitive output of a local module, whose
+// outputs are marked first. Only
+// the files written since a time are changed, so the user's own files are left alone.
+func markSensitiveOutputs(dir string, since time.Time) error {
+	modules, _ := filepath.Glob(filepath.Join(dir, "modules", "*"))
+	moduleOutputs := make(map[string]map[string]bool)
+	for _, module := range modules {
+		outputs, err := markModuleOutputs(module, nil, since)
+		if err != nil {
+			return err
+		}
+		moduleOutputs[filepath.Base(module)] = outputs
+	}
+	_, err := markModuleOutputs(dir, moduleOutputs, since)
+	return err
+}
+
+// markModuleOutputs marks the outputs carrying secrets in the files of one module, and
+// returns the names of its sensitive outputs
+func markModuleOutputs(dir string, moduleOutputs map[string]map[string]bool, since time.Time) (map[string]bool, error) {
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.tf"))
+	files := make(map[string]*hclwrite.File, len(paths))
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		file, diags := hclwrite.ParseConfig(content, path, hcl.Pos{Line: 1, Column: 1})
+		if diags.HasErrors() {
+			continue
+		}
+		files[path] = file
+	}
+
+	variables := make(map[string]bool)
+	outputs := make(map[string]bool)
+	// The module calls of a local module, such as vpc_us_west_2 of ./modules/vpc, have its
+	// sensitive outputs
+	callOutputs := make(map[string]map[string]bool)
+	localValues := make(map[string]*hclwrite.Expression)
+	for _, file := range files {
+		for _, block := range file.Body().Blocks() {
+			if block.Type() == "locals" {
+				for name, attribute := range block.Body().Attributes() {
+					localValues[name] = attribute.Expr()
+				}
+				continue
+			}
+			if len(block.Labels()) != 1 {
+				continue
+			}
+			if block.Type() == "module" {
+				if source := block.Body().GetAttribute("source"); source != nil {
+					path := strings.Trim(strings.TrimSpace(string(source.Expr().BuildTokens(nil).Bytes())), `"`)
+					if module, ok := strings.CutPrefix(path, "./modules/"); ok {
+						callOutputs[block.Labels()[0]] = moduleOutputs[module]
+					}
+					for _, registry := range registryModules {
+						if path == registry.Source {
+							callOutputs[block.Labels()[0]] = make(map[string]bool)
+							for _, output := range registry.SensitiveOutputs {
+								callOutputs[block.Labels()[0]][output] = true
+							}
+						}
+					}
+				}
+			}
+			if isSensitive(block.Body()) {
+				switch block.Type() {
+				case "variable":
+					variables[block.Labels()[0]] = true
+				case "output":
+					outputs[block.Labels()[0]] = true
+				}
+			}
+		}
+	}
+
+	// A local carries a secret when its value refers to one, which may be another local
+	locals := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for name, value := range localValues {
+			if locals[name] {
+				continue
+			}
+			for _, traversal := range value.Variables() {
+				if carriesSecret(traversalNames(traversal), variables, locals, callOutputs) {
+					locals[name] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	for _, path := range paths {
+		file, ok := files[path]
+		if !ok {
+			continue
+		}
+		content := string(file.Bytes())
+		marked := content
+		for _, block := range file.Body().Blocks() {
+			value := block.Body().GetAttribute("value")
+			if block.Type() != "output" || len(block.Labels()) != 1 || value == nil || isSensitive(block.Body()) {
+				continue
+			}
+			for _, traversal := range value.Expr().Variables() {
+				if carriesSecret(traversalNames(traversal), variables, locals, callOutputs) {
+					marked = markOutput(marked, block.Labels()[0])
+					outputs[block.Labels()[0]] = true
+					break
+				}
+			}
+		}
+		if info, err := os.Stat(path); err == nil && marked != content && !info.ModTime().Before(since) {
+			if err := rewriteFile(path, marked); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return outputs, nil
+}
+
+// valueAssignmentPattern matches the value argument of an output, with its indent and the
+// spaces aligning its equals sign
+var valueAssignmentPattern = regexp.MustCompile(`(?m)^([ \t]*)value([ \t]*)=`)
+
+// markOutput adds sensitive = true to an output block in a file's content, aligned with the
+// value argument as terraform fmt aligns them
+func markOutput(content, name string) string {
+	header := regexp.MustCompile(`(?m)^output\s+"` + regexp.QuoteMeta(name) + `"\s*\{`)
+	location := header.FindStringIndex(content)
+	if location == nil {
+		return content
+	}
+	open := location[1] - 1
+	end := blockEnd(content, open)
+	if end < 0 {
+		return content
+	}
+
+	indent, padding := "  ", " "
+	if value := valueAssignmentPattern.FindStringSubmatch(content[open:end]); value != nil {
+		indent = value[1]
+		if width := len("value") + len(value[2]); width > len("sensitive")+1 {
+			padding = strings.Repeat(" ", width-len("sensitive"))
+		}
+	}
+	line := indent + "sensitive" + padding + "= true\n"
+	if !strings.HasSuffix(content[:end], "\n") {
+		line = "\n" + line
+	}
+	return content[:end] + line + content[end:]
+}
+
+// isSensitive reports whether a variable or output block sets sensitive = true
+func isSensitive(body *hclwrite.Body) bool {
+	attribute := body.GetAttribute("sensitive")
+	return attribute != nil && strings.TrimSpace(string(attribute.Expr().BuildTokens(nil).Bytes())) == "true"
+}
+
+// traversalNames returns the names of a reference's steps, leaving out its indexes, so
+// aws_eks_cluster.this.certificate_authority[0].data gives aws_eks_cluster, this,
+// certificate_authority and data
+func traversalNames(traversal *hclwrite.Traversal) []string {
+	var steps []string
+	for _, token := range traversal.BuildTokens(nil) {
+		if token.Type == hclsyntax.TokenIdent {
+			steps = append(steps, string(token.Bytes))
+		}
+	}
+	return steps
+}
+
+// carriesSecret reports whether a reference, given as the names of its steps, leads to a
+// secret: a secret attribute of a resource or data source, a sensitive variable, a local
+// carrying a secret, or a sensitive output of a module call
+func carriesSecret(steps []string, variables, locals map[string]bool, callOutputs map[string]map[string]bool) bool {
+	if len(steps) < 2 {
+		return false
+	}
+	switch steps[0] {
+	case "var":
+		return variables[steps[1]]
+	case "local":
+		return locals[steps[1]]
+	case "module":
+		return len(steps) > 2 && callOutputs[steps[1]][steps[2]]
+	case "data":
+		return len(steps) > 3 && sensitive.Attribute(steps[1], steps[3])
+	}
+	return len(steps) > 2 && sensitive.Attribute(steps[0], steps[2])
+}
@@ -0,0 +1,149 @@
+package terraform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// versionPattern matches a version of Terraform or of a provider, such as 1.5 or 5.61.0
+var versionPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+){0,2}$`)
+
+// constraintPattern matches a clause of a version constraint, such as ~> 5.0 or < 6.0.0
+var constraintPattern = regexp.MustCompile(`^(=|!=|>=|<=|>|<|~>)?\s*([0-9]+(?:\.[0-9]+){0,2})$`)
+
+// ValidateTerraformVersion checks the minimum Terraform version of the configuration
+func ValidateTerraformVersion(version string) error {
+	if !versionPattern.MatchString(version) {
+		return fmt.Errorf("invalid Terraform version: %q (use a version such as 1.5.7)", version)
+	}
+	return nil
+}
+
+// ValidateProviderConstraint checks the version constraint of the AWS provider
+func ValidateProviderConstraint(constraint string) error {
+	if _, err := parseConstraint(constraint); err != nil {
+		return err
+	}
+	return nil
+}
+
+// constraintClause is a clause of a version constraint
+type constraintClause struct {
+	Operator string
+	Version  string
+}
+
+// parseConstraint splits a version constraint, such as ">= 5.40, < 6.0", into its clauses.
+// A version without an operator requires that exact version, as in Terraform.
+func parseConstraint(constraint string) ([]constraintClause, error) {
+	var clauses []constraintClause
+	for _, part := range strings.Split(constraint, ",") {
+		match := constraintPattern.FindStringSubmatch(strings.TrimSpace(part))
+		if match == nil {
+			return nil, fmt.Errorf("invalid AWS provider version constraint: %q (use a constraint such as \"~> 5.0\" or \">= 5.40, < 6.0\")", constraint)
+		}
+		operator := match[1]
+		if operator == "" {
+			operator = "="
+		}
+		clauses = append(clauses, constraintClause{Operator: operator, Version: match[2]})
+	}
+	return clauses, nil
+}
+
+// allowsVersionFrom reports whether a version constraint allows a version at least as recent
+// as version. Only the clauses bounding the versions from above can rule them all out.
+func allowsVersionFrom(clauses []constraintClause, version string) bool {
+	for _, clause := range clauses {
+		switch clause.Operator {
+		case "<":
+			if compareVersions(version, clause.Version) >= 0 {
+				return false
+			}
+		case "<=", "=":
+			if compareVersions(version, clause.Version) > 0 {
+				return false
+			}
+		case "~>":
+			if compareVersions(version, pessimisticBound(clause.Version)) >= 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// pessimisticBound returns the first version ~> version excludes: ~> 5.0 allows the 5.x
+// versions, and ~> 5.40.1 the 5.40.x ones
+func pessimisticBound(version string) string {
+	parts := strings.Split(version, ".")
+	if len(parts) > 1 {
+		parts = parts[:len(parts)-1]
+	}
+	last, _ := strconv.Atoi(parts[len(parts)-1])
+	parts[len(parts)-1] = strconv.Itoa(last + 1)
+	return strings.Join(parts, ".")
+}
+
+// providerFeature is a feature of the AWS provider the generated configuration may use
+type providerFeature struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Version is the AWS provider version that introduced the feature
+	Version string
+}
+
+// providerFeatures are the features of the AWS provider the generated EKS resources and
+// registry modules depend on
+func providerFeatures() []providerFeature {
+	features := []providerFeature{
+		{Name: "EKS add-on conflict resolution on create and update", Pattern: regexp.MustCompile(`resolve_conflicts_on_(create|update)\s*=`), Version: "5.0.0"},
+		{Name: "EKS node group update configuration", Pattern: regexp.MustCompile(`(?m)^\s*update_config\s*\{`), Version: "3.56.0"},
+	}
+	names := make([]string, 0, len(registryModules))
+	for name := range registryModules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		module := registryModules[name]
+		features = append(features, providerFeature{
+			Name:    fmt.Sprintf("the %s module %s", module.Source, module.Version),
+			Pattern: regexp.MustCompile(`source\s*=\s*"` + regexp.QuoteMeta(module.Source) + `"`),
+			Version: module.ProviderVersion,
+		})
+	}
+	return features
+}
+
+// checkProviderVersion checks that the AWS provider version constraint allows a version
+// with each feature the configuration in dir and its local modules uses
+func checkProviderVersion(dir, constraint string) error {
+	clauses, err := parseConstraint(constraint)
+	if err != nil {
+		return err
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.tf"))
+	moduleFiles, _ := filepath.Glob(filepath.Join(dir, "modules", "*", "*.tf"))
+	var content strings.Builder
+	for _, file := range append(files, moduleFiles...) {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		content.Write(data)
+	}
+
+	for _, feature := range providerFeatures() {
+		if feature.Pattern.MatchString(content.String()) && !allowsVersionFrom(clauses, feature.Version) {
+			return fmt.Errorf("the AWS provider version constraint %q rules out %s, which needs version %s or later", constraint, feature.Name, feature.Version)
+		}
+	}
+	return nil
+}
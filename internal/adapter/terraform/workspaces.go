@@ -0,0 +1,165 @@
+package terraform
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// WorkspaceDir is the directory, under the output directory, of the variables files of the
+// workspaces
+const WorkspaceDir = "env"
+
+// Workspace is a Terraform workspace the configuration is applied in, such as staging. Its
+// variables file, env/<name>.tfvars, sets the described values with the sizes it overrides.
+type Workspace struct {
+	Name string
+	// NATGateways is the number of NAT gateways: 0 for none, 1 for one shared by the private
+	// subnets, and more for one in each availability zone. Nil keeps the described ones.
+	NATGateways *int
+	// NodeCount is the desired size of each node group; nil keeps the described sizes
+	NodeCount *int
+	// NodeInstanceType replaces the instance types of the node groups when set
+	NodeInstanceType string
+}
+
+// WorkspaceSettings are the settings a workspace can override
+var WorkspaceSettings = []string{"nat_gateways", "node_count", "node_instance_type"}
+
+// DefaultWorkspace returns a workspace with the sizes of its environment: dev and test
+// workspaces share a NAT gateway and run one node of each group, staging shares a NAT
+// gateway, and prod gets a NAT gateway in each availability zone. Other workspaces keep
+// the described sizes.
+func DefaultWorkspace(name string) Workspace {
+	workspace := Workspace{Name: name}
+	one, perZone := 1, 3
+	switch name {
+	case "dev", "development", "test", "qa":
+		workspace.NATGateways = &one
+		workspace.NodeCount = &one
+	case "staging", "stage", "uat":
+		workspace.NATGateways = &one
+	case "prod", "production":
+		workspace.NATGateways = &perZone
+	}
+	return workspace
+}
+
+// Set overrides a setting of the workspace, one of WorkspaceSettings
+func (w *Workspace) Set(setting, value string) error {
+	switch setting {
+	case "nat_gateways", "node_count":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid %s of workspace %s: %q (use a number of 0 or more)", setting, w.Name, value)
+		}
+		if setting == "nat_gateways" {
+			w.NATGateways = &n
+		} else {
+			w.NodeCount = &n
+		}
+	case "node_instance_type":
+		if value == "" {
+			return fmt.Errorf("invalid node_instance_type of workspace %s: the instance type is empty", w.Name)
+		}
+		w.NodeInstanceType = value
+	default:
+		return fmt.Errorf("unknown workspace setting: %s (supported settings: %s)", setting, strings.Join(WorkspaceSettings, ", "))
+	}
+	return nil
+}
+
+// ValidateWorkspaces checks the names of workspaces
+func ValidateWorkspaces(workspaces []Workspace) error {
+	seen := make(map[string]bool, len(workspaces))
+	for _, workspace := range workspaces {
+		if !infra.ValidEnvironment(workspace.Name) {
+			return fmt.Errorf("invalid workspace: %s (use up to 16 lower-case letters, digits and hyphens, starting with a letter)", workspace.Name)
+		}
+		if seen[workspace.Name] {
+			return fmt.Errorf("workspace %s is listed twice", workspace.Name)
+		}
+		seen[workspace.Name] = true
+	}
+	return nil
+}
+
+// generateWorkspaceFiles writes the variables file of each workspace to env/ and a Makefile
+// selecting the workspace and its variables file together. Each workspace's resources are
+// named and tagged after it, so the workspaces can share an AWS account.
+func (g *TerraformGenerator) generateWorkspaceFiles() error {
+	if g.Model != nil && g.Model.Environment != "" {
+		return fmt.Errorf("the model is made for the %s environment, so it cannot be shared by workspaces", g.Model.Environment)
+	}
+
+	names := make([]string, len(g.Config.Workspaces))
+	for i, workspace := range g.Config.Workspaces {
+		names[i] = workspace.Name
+
+		config := *g.Config
+		config.Environment = workspace.Name
+		config.NameSuffix = "-" + workspace.Name + g.Config.NameSuffix
+		workspaceGenerator := &TerraformGenerator{OutputDir: g.OutputDir, Model: g.Model, Config: &config, workspace: &g.Config.Workspaces[i]}
+		tfvars, err := workspaceGenerator.generateTfvarsFile()
+		if err != nil {
+			return err
+		}
+		header := fmt.Sprintf("# Variables of the %s workspace: terraform workspace select %s\n# terraform apply -var-file=%s/%s.tfvars\n\n", workspace.Name, workspace.Name, WorkspaceDir, workspace.Name)
+		if err := utils.WriteToFile(filepath.Join(g.OutputDir, WorkspaceDir, workspace.Name+".tfvars"), header+tfvars); err != nil {
+			return fmt.Errorf("failed to write the variables of workspace %s: %w", workspace.Name, err)
+		}
+	}
+
+	makefile := `# Runs Terraform in a workspace with its variables file, such as: make plan ENV=staging
+ENV ?= ` + names[0] + `
+WORKSPACES := ` + strings.Join(names, " ") + `
+
+ifeq ($(filter $(ENV),$(WORKSPACES)),)
+$(error ENV must be one of: $(WORKSPACES))
+endif
+
+.PHONY: init workspace plan apply destroy
+
+init:
+	terraform init
+
+workspace: init
+	terraform workspace select $(ENV) || terraform workspace new $(ENV)
+
+plan: workspace
+	terraform plan -var-file=` + WorkspaceDir + `/$(ENV).tfvars
+
+apply: workspace
+	terraform apply -var-file=` + WorkspaceDir + `/$(ENV).tfvars
+
+destroy: workspace
+	terraform destroy -var-file=` + WorkspaceDir + `/$(ENV).tfvars
+`
+	if err := utils.WriteToFile(filepath.Join(g.OutputDir, "Makefile"), makefile); err != nil {
+		return fmt.Errorf("failed to write Makefile: %w", err)
+	}
+	return nil
+}
+
+// sizeNodeGroup applies the workspace's node count and instance type to a node group. The
+// node count is the desired size, within the minimum and maximum sizes widened to include it.
+func (w *Workspace) sizeNodeGroup(group nodeGroupTfvars) nodeGroupTfvars {
+	if w.NodeCount != nil {
+		n := *w.NodeCount
+		group.desiredSize = n
+		if size, err := strconv.Atoi(fmt.Sprint(group.minSize)); err != nil || size > n {
+			group.minSize = n
+		}
+		if size, err := strconv.Atoi(fmt.Sprint(group.maxSize)); err != nil || size < n {
+			group.maxSize = n
+		}
+	}
+	if w.NodeInstanceType != "" {
+		group.instanceTypes = []string{w.NodeInstanceType}
+	}
+	return group
+}
@@ -4,58 +4,214 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
 // CfgFile holds the config file path
 var CfgFile string
 
+// Profile holds the name of the profile selected with --profile
+var Profile string
+
+// Config file names: the user's defaults in the home directory, and the project's in the
+// current directory
+const (
+	UserConfigFile    = ".iacgen.yaml"
+	ProjectConfigFile = "iacgen.yaml"
+)
+
+// UserPluginDir is the directory in the home directory plugins are discovered in when
+// plugin_dir is not set
+const UserPluginDir = ".iacgen/plugins"
+
 // Config holds configuration values
 type Config struct {
 	LogLevel    string `mapstructure:"log_level"`
 	OutputDir   string `mapstructure:"output_dir"`
 	DefaultType string `mapstructure:"default_type"`
+	TemplateDir string `mapstructure:"template_dir"`
+	PluginDir   string `mapstructure:"plugin_dir"`
+	// Tags are applied to every resource, under the tags the description states. Viper
+	// lower-cases keys, so they are read from the files directly.
+	Tags map[string]string `mapstructure:"-"`
 }
 
 // AppConfig holds the application config
 var AppConfig Config
 
+// fileSettings are the settings of a config file whose keys keep their case, which viper
+// lower-cases
+type fileSettings struct {
+	Tags     map[string]string `yaml:"tags"`
+	Profiles map[string]struct {
+		Tags map[string]string `yaml:"tags"`
+	} `yaml:"profiles"`
+}
+
 // InitConfig reads in config file and ENV variables if set
 func InitConfig() {
-	if CfgFile != "" {
-		// Use config file from the flag
-		viper.SetConfigFile(CfgFile)
-	} else {
-		// Find home directory
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-
-		// Search config in home directory with name ".iacgen" (without extension)
-		viper.AddConfigPath(home)
-		viper.AddConfigPath(".")
-		viper.SetConfigName(".iacgen")
-	}
-
 	viper.AutomaticEnv() // read in environment variables that match
 
 	// Set defaults
 	viper.SetDefault("log_level", "info")
 	viper.SetDefault("output_dir", ".")
 	viper.SetDefault("default_type", "terraform")
+	viper.SetDefault("cache_dir", ".iacgen/cache")
+	viper.SetDefault("format_output", true)
 
-	// If a config file is found, read it in
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+	files := []string{CfgFile}
+	if CfgFile == "" {
+		files = DefaultConfigFiles()
+	} else if _, err := os.Stat(CfgFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: config file not found: %s\n", CfgFile)
+		os.Exit(1)
+	}
+
+	used, tags, err := ReadConfigFiles(files, Profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(used) > 0 {
+		fmt.Fprintln(os.Stderr, "Using config file:", strings.Join(used, ", "))
 	}
 
 	// Unmarshal config into AppConfig
 	if err := viper.Unmarshal(&AppConfig); err != nil {
 		fmt.Printf("Unable to decode config into struct: %v\n", err)
 	}
+	AppConfig.Tags = tags
+}
+
+// DefaultConfigFiles returns the config files read when --config is not set: the user's
+// ~/.iacgen.yaml, then the project's ./iacgen.yaml, whose settings override the user's
+func DefaultConfigFiles() []string {
+	var files []string
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, UserConfigFile))
+	}
+	return append(files, ProjectConfigFile)
+}
+
+// DefaultPluginDir returns the directory plugins are discovered in when plugin_dir is not
+// set: ~/.iacgen/plugins, if the home directory is known
+func DefaultPluginDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, UserPluginDir)
+}
+
+// ReadConfigFiles merges the config files that exist into viper, in order, and then the
+// settings of the named profile, which override those of the files. It returns the files
+// that were read and the tags they set.
+func ReadConfigFiles(files []string, profile string) ([]string, map[string]string, error) {
+	var used []string
+	tags := make(map[string]string)
+	profileTags := make(map[string]string)
+	profileFound := false
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read config file %s: %w", file, err)
+		}
+
+		viper.SetConfigFile(file)
+		if err := viper.MergeInConfig(); err != nil {
+			return nil, nil, fmt.Errorf("failed to read config file %s: %w", file, err)
+		}
+		used = append(used, file)
+
+		var settings fileSettings
+		if err := yaml.Unmarshal(content, &settings); err != nil {
+			return nil, nil, fmt.Errorf("invalid tags in config file %s: %w", file, err)
+		}
+		for key, value := range settings.Tags {
+			tags[key] = value
+		}
+		for name, settings := range settings.Profiles {
+			if strings.EqualFold(name, profile) {
+				profileFound = true
+				for key, value := range settings.Tags {
+					profileTags[key] = value
+				}
+			}
+		}
+	}
+
+	if profile != "" {
+		if !profileFound {
+			return nil, nil, fmt.Errorf("profile %s is not defined in the config files (%s)", profile, profileNames())
+		}
+		if err := applyProfile(profile); err != nil {
+			return nil, nil, err
+		}
+		for key, value := range profileTags {
+			tags[key] = value
+		}
+	}
+
+	if len(tags) == 0 {
+		tags = nil
+	}
+	return used, tags, nil
+}
+
+// applyProfile merges the settings of a profile over those of the config files
+func applyProfile(profile string) error {
+	settings, ok := viper.GetStringMap("profiles")[strings.ToLower(profile)].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("profile %s must be a map of settings", profile)
+	}
+	return viper.MergeConfigMap(settings)
+}
+
+// profileNames lists the profiles defined in the config files that were read
+func profileNames() string {
+	profiles := viper.GetStringMap("profiles")
+	if len(profiles) == 0 {
+		return "no profiles are defined"
+	}
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "profiles: " + strings.Join(names, ", ")
+}
+
+// ProfileNames returns the names of the profiles the config files define, sorted and with
+// their case kept. Files that are missing or invalid are skipped.
+func ProfileNames(files []string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var settings fileSettings
+		if err := yaml.Unmarshal(content, &settings); err != nil {
+			continue
+		}
+		for name := range settings.Profiles {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
 }
 
 // SaveConfig saves the current configuration to file
@@ -77,3 +233,58 @@ func SaveConfig() error {
 	// Write config
 	return viper.WriteConfig()
 }
+
+// SetFileValue sets a setting of a config file, such as template_mappings.terraform.vpc for
+// the keys template_mappings, terraform and vpc, keeping the other settings of the file and
+// its comments. The file is created when it does not exist.
+func SetFileValue(file string, keys []string, value string) error {
+	var doc yaml.Node
+	content, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read config file %s: %w", file, err)
+	}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", file, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	node := doc.Content[0]
+	for i, key := range keys {
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("cannot set %s in config file %s: %s is not a map", strings.Join(keys, "."), file, strings.Join(keys[:i], "."))
+		}
+		var child *yaml.Node
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == key {
+				child = node.Content[j+1]
+				break
+			}
+		}
+		if child == nil || (child.Kind == yaml.ScalarNode && child.Tag == "!!null") {
+			if child == nil {
+				child = &yaml.Node{}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, child)
+			}
+			*child = yaml.Node{Kind: yaml.MappingNode}
+		}
+		if i == len(keys)-1 {
+			*child = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+		}
+		node = child
+	}
+
+	var buf strings.Builder
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", file, err)
+	}
+	if dir := filepath.Dir(file); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(file, []byte(buf.String()), 0644)
+}
@@ -0,0 +1,510 @@
+package discover
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/riptano/iac_generator_cli/internal/spec"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// EC2API is the part of the EC2 API discovery calls: Describe calls only
+type EC2API interface {
+	DescribeVpcs(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error)
+	DescribeVpcAttribute(ctx context.Context, params *ec2.DescribeVpcAttributeInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcAttributeOutput, error)
+	DescribeSubnets(ctx context.Context, params *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error)
+	DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error)
+	DescribeInternetGateways(ctx context.Context, params *ec2.DescribeInternetGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInternetGatewaysOutput, error)
+	DescribeNatGateways(ctx context.Context, params *ec2.DescribeNatGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNatGatewaysOutput, error)
+}
+
+// EKSAPI is the part of the EKS API discovery calls: List and Describe calls only
+type EKSAPI interface {
+	ListClusters(ctx context.Context, params *eks.ListClustersInput, optFns ...func(*eks.Options)) (*eks.ListClustersOutput, error)
+	DescribeCluster(ctx context.Context, params *eks.DescribeClusterInput, optFns ...func(*eks.Options)) (*eks.DescribeClusterOutput, error)
+	ListNodegroups(ctx context.Context, params *eks.ListNodegroupsInput, optFns ...func(*eks.Options)) (*eks.ListNodegroupsOutput, error)
+	DescribeNodegroup(ctx context.Context, params *eks.DescribeNodegroupInput, optFns ...func(*eks.Options)) (*eks.DescribeNodegroupOutput, error)
+	ListAddons(ctx context.Context, params *eks.ListAddonsInput, optFns ...func(*eks.Options)) (*eks.ListAddonsOutput, error)
+	DescribeAddon(ctx context.Context, params *eks.DescribeAddonInput, optFns ...func(*eks.Options)) (*eks.DescribeAddonOutput, error)
+	ListFargateProfiles(ctx context.Context, params *eks.ListFargateProfilesInput, optFns ...func(*eks.Options)) (*eks.ListFargateProfilesOutput, error)
+	DescribeFargateProfile(ctx context.Context, params *eks.DescribeFargateProfileInput, optFns ...func(*eks.Options)) (*eks.DescribeFargateProfileOutput, error)
+}
+
+// Discoverer builds the model of an existing VPC and the EKS clusters in it from the AWS API,
+// without changing anything
+type Discoverer struct {
+	ec2    EC2API
+	eks    EKSAPI
+	region string
+}
+
+// NewDiscoverer creates a Discoverer calling the given clients, which are in a region
+func NewDiscoverer(ec2Client EC2API, eksClient EKSAPI, region string) *Discoverer {
+	return &Discoverer{ec2: ec2Client, eks: eksClient, region: region}
+}
+
+// NewAWSDiscoverer creates a Discoverer for a region, with the credentials of the default
+// chain of the AWS SDK: the environment, a profile of the shared configuration or the role of
+// the instance. An empty profile uses the default one.
+func NewAWSDiscoverer(ctx context.Context, region, profile string) (*Discoverer, error) {
+	options := []func(*config.LoadOptions) error{config.WithRegion(region)}
+	if profile != "" {
+		options = append(options, config.WithSharedConfigProfile(profile))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	return NewDiscoverer(ec2.NewFromConfig(cfg), eks.NewFromConfig(cfg), cfg.Region), nil
+}
+
+// Discover builds the model of a VPC: its subnets, internet and NAT gateways, and the EKS
+// clusters in it with their node groups, add-ons and Fargate profiles. The resources are
+// named after their Name tag, or their name or ID in AWS, and refer to each other by these
+// names. Subnets routing to an internet gateway are public. Tags every resource has become
+// the tags of the model.
+func (d *Discoverer) Discover(ctx context.Context, vpcID string) (*models.InfrastructureModel, error) {
+	vpcs, err := d.ec2.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{VpcIds: []string{vpcID}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe VPC %s: %w", vpcID, err)
+	}
+	if len(vpcs.Vpcs) == 0 {
+		return nil, fmt.Errorf("VPC %s not found in %s", vpcID, d.region)
+	}
+	vpc := vpcs.Vpcs[0]
+	inVPC := []ec2types.Filter{{Name: aws.String("vpc-id"), Values: []string{vpcID}}}
+
+	var subnets []ec2types.Subnet
+	subnetPages := ec2.NewDescribeSubnetsPaginator(d.ec2, &ec2.DescribeSubnetsInput{Filters: inVPC})
+	for subnetPages.HasMorePages() {
+		page, err := subnetPages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe the subnets of VPC %s: %w", vpcID, err)
+		}
+		subnets = append(subnets, page.Subnets...)
+	}
+	publicSubnets, err := d.publicSubnets(ctx, vpcID, inVPC, subnets)
+	if err != nil {
+		return nil, err
+	}
+
+	var gateways []ec2types.InternetGateway
+	gatewayPages := ec2.NewDescribeInternetGatewaysPaginator(d.ec2, &ec2.DescribeInternetGatewaysInput{
+		Filters: []ec2types.Filter{{Name: aws.String("attachment.vpc-id"), Values: []string{vpcID}}},
+	})
+	for gatewayPages.HasMorePages() {
+		page, err := gatewayPages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe the internet gateways of VPC %s: %w", vpcID, err)
+		}
+		gateways = append(gateways, page.InternetGateways...)
+	}
+
+	var natGateways []ec2types.NatGateway
+	natPages := ec2.NewDescribeNatGatewaysPaginator(d.ec2, &ec2.DescribeNatGatewaysInput{
+		Filter: append(inVPC, ec2types.Filter{Name: aws.String("state"), Values: []string{"pending", "available"}}),
+	})
+	for natPages.HasMorePages() {
+		page, err := natPages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe the NAT gateways of VPC %s: %w", vpcID, err)
+		}
+		natGateways = append(natGateways, page.NatGateways...)
+	}
+
+	clusters, err := d.clusters(ctx, vpcID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The resources are named first, so references to any of them can be resolved
+	n := &namer{names: make(map[string]bool), ids: make(map[string]string)}
+	n.add(aws.ToString(vpc.VpcId), ec2Tags(vpc.Tags)["Name"])
+	for _, subnet := range subnets {
+		n.add(aws.ToString(subnet.SubnetId), ec2Tags(subnet.Tags)["Name"])
+	}
+	for _, gateway := range gateways {
+		n.add(aws.ToString(gateway.InternetGatewayId), ec2Tags(gateway.Tags)["Name"])
+	}
+	for _, nat := range natGateways {
+		n.add(aws.ToString(nat.NatGatewayId), ec2Tags(nat.Tags)["Name"])
+	}
+	for _, cluster := range clusters {
+		n.add(cluster.name, cluster.name)
+	}
+
+	model := models.NewInfrastructureModel()
+	model.Region = d.region
+	var tagSets []map[string]string
+
+	resource := models.NewResource(models.ResourceVPC, n.reference(aws.ToString(vpc.VpcId)))
+	resource.AddProperty("cidr_block", aws.ToString(vpc.CidrBlock))
+	for _, attribute := range []ec2types.VpcAttributeName{ec2types.VpcAttributeNameEnableDnsSupport, ec2types.VpcAttributeNameEnableDnsHostnames} {
+		output, err := d.ec2.DescribeVpcAttribute(ctx, &ec2.DescribeVpcAttributeInput{VpcId: vpc.VpcId, Attribute: attribute})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe the %s attribute of VPC %s: %w", attribute, vpcID, err)
+		}
+		if attribute == ec2types.VpcAttributeNameEnableDnsSupport {
+			resource.AddProperty("enable_dns_support", output.EnableDnsSupport != nil && aws.ToBool(output.EnableDnsSupport.Value))
+		} else {
+			resource.AddProperty("enable_dns_hostnames", output.EnableDnsHostnames != nil && aws.ToBool(output.EnableDnsHostnames.Value))
+		}
+	}
+	model.AddResource(resource)
+	tagSets = append(tagSets, ec2Tags(vpc.Tags))
+
+	for _, subnet := range subnets {
+		resource := models.NewResource(models.ResourceSubnet, n.reference(aws.ToString(subnet.SubnetId)))
+		resource.AddProperty("vpc_id", n.reference(vpcID))
+		resource.AddProperty("cidr_block", aws.ToString(subnet.CidrBlock))
+		resource.AddProperty("availability_zone", aws.ToString(subnet.AvailabilityZone))
+		resource.AddProperty("map_public_ip_on_launch", aws.ToBool(subnet.MapPublicIpOnLaunch) || publicSubnets[aws.ToString(subnet.SubnetId)])
+		model.AddResource(resource)
+		tagSets = append(tagSets, ec2Tags(subnet.Tags))
+	}
+
+	for _, gateway := range gateways {
+		resource := models.NewResource(models.ResourceIGW, n.reference(aws.ToString(gateway.InternetGatewayId)))
+		resource.AddProperty("vpc_id", n.reference(vpcID))
+		model.AddResource(resource)
+		tagSets = append(tagSets, ec2Tags(gateway.Tags))
+	}
+
+	for _, nat := range natGateways {
+		resource := models.NewResource(models.ResourceNATGateway, n.reference(aws.ToString(nat.NatGatewayId)))
+		resource.AddProperty("subnet_id", n.reference(aws.ToString(nat.SubnetId)))
+		for _, address := range nat.NatGatewayAddresses {
+			if address.AllocationId != nil {
+				resource.AddProperty("allocation_id", aws.ToString(address.AllocationId))
+				break
+			}
+		}
+		connectivityType := string(nat.ConnectivityType)
+		if connectivityType == "" {
+			connectivityType = string(ec2types.ConnectivityTypePublic)
+		}
+		resource.AddProperty("connectivity_type", connectivityType)
+		model.AddResource(resource)
+		tagSets = append(tagSets, ec2Tags(nat.Tags))
+	}
+
+	for _, cluster := range clusters {
+		tagSets = append(tagSets, cluster.addTo(model, n)...)
+	}
+	model.Tags = commonTags(tagSets)
+
+	spec.NormalizeModel(model)
+	if err := spec.ValidateModel(model); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// publicSubnets returns the subnets of a VPC whose route table, their own or the main one,
+// routes to an internet gateway
+func (d *Discoverer) publicSubnets(ctx context.Context, vpcID string, inVPC []ec2types.Filter, subnets []ec2types.Subnet) (map[string]bool, error) {
+	var tables []ec2types.RouteTable
+	pages := ec2.NewDescribeRouteTablesPaginator(d.ec2, &ec2.DescribeRouteTablesInput{Filters: inVPC})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe the route tables of VPC %s: %w", vpcID, err)
+		}
+		tables = append(tables, page.RouteTables...)
+	}
+
+	public := make(map[string]bool)
+	mainPublic := false
+	for _, table := range tables {
+		routesToInternet := false
+		for _, route := range table.Routes {
+			if strings.HasPrefix(aws.ToString(route.GatewayId), "igw-") {
+				routesToInternet = true
+			}
+		}
+		for _, association := range table.Associations {
+			if aws.ToBool(association.Main) {
+				mainPublic = routesToInternet
+			} else if association.SubnetId != nil {
+				public[aws.ToString(association.SubnetId)] = routesToInternet
+			}
+		}
+	}
+
+	// Subnets without a route table of their own use the main one
+	for _, subnet := range subnets {
+		if _, ok := public[aws.ToString(subnet.SubnetId)]; !ok {
+			public[aws.ToString(subnet.SubnetId)] = mainPublic
+		}
+	}
+	return public, nil
+}
+
+// cluster is an EKS cluster of the VPC with its node groups, add-ons and Fargate profiles
+type cluster struct {
+	name     string
+	cluster  *eks.DescribeClusterOutput
+	groups   []*eks.DescribeNodegroupOutput
+	addons   []*eks.DescribeAddonOutput
+	profiles []*eks.DescribeFargateProfileOutput
+}
+
+// clusters returns the EKS clusters in a VPC, sorted by name
+func (d *Discoverer) clusters(ctx context.Context, vpcID string) ([]cluster, error) {
+	var names []string
+	pages := eks.NewListClustersPaginator(d.eks, &eks.ListClustersInput{})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the EKS clusters: %w", err)
+		}
+		names = append(names, page.Clusters...)
+	}
+	sort.Strings(names)
+
+	var clusters []cluster
+	for _, name := range names {
+		output, err := d.eks.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(name)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe EKS cluster %s: %w", name, err)
+		}
+		if output.Cluster == nil || output.Cluster.ResourcesVpcConfig == nil || aws.ToString(output.Cluster.ResourcesVpcConfig.VpcId) != vpcID {
+			continue
+		}
+		c := cluster{name: name, cluster: output}
+
+		groupPages := eks.NewListNodegroupsPaginator(d.eks, &eks.ListNodegroupsInput{ClusterName: aws.String(name)})
+		for groupPages.HasMorePages() {
+			page, err := groupPages.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list the node groups of EKS cluster %s: %w", name, err)
+			}
+			for _, group := range page.Nodegroups {
+				output, err := d.eks.DescribeNodegroup(ctx, &eks.DescribeNodegroupInput{ClusterName: aws.String(name), NodegroupName: aws.String(group)})
+				if err != nil {
+					return nil, fmt.Errorf("failed to describe node group %s of EKS cluster %s: %w", group, name, err)
+				}
+				c.groups = append(c.groups, output)
+			}
+		}
+
+		addonPages := eks.NewListAddonsPaginator(d.eks, &eks.ListAddonsInput{ClusterName: aws.String(name)})
+		for addonPages.HasMorePages() {
+			page, err := addonPages.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list the add-ons of EKS cluster %s: %w", name, err)
+			}
+			for _, addon := range page.Addons {
+				output, err := d.eks.DescribeAddon(ctx, &eks.DescribeAddonInput{ClusterName: aws.String(name), AddonName: aws.String(addon)})
+				if err != nil {
+					return nil, fmt.Errorf("failed to describe add-on %s of EKS cluster %s: %w", addon, name, err)
+				}
+				c.addons = append(c.addons, output)
+			}
+		}
+
+		profilePages := eks.NewListFargateProfilesPaginator(d.eks, &eks.ListFargateProfilesInput{ClusterName: aws.String(name)})
+		for profilePages.HasMorePages() {
+			page, err := profilePages.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list the Fargate profiles of EKS cluster %s: %w", name, err)
+			}
+			for _, profile := range page.FargateProfileNames {
+				output, err := d.eks.DescribeFargateProfile(ctx, &eks.DescribeFargateProfileInput{ClusterName: aws.String(name), FargateProfileName: aws.String(profile)})
+				if err != nil {
+					return nil, fmt.Errorf("failed to describe Fargate profile %s of EKS cluster %s: %w", profile, name, err)
+				}
+				c.profiles = append(c.profiles, output)
+			}
+		}
+		clusters = append(clusters, c)
+	}
+	return clusters, nil
+}
+
+// addTo adds the cluster, its node groups, add-ons and Fargate profiles to the model, and
+// returns their tags
+func (c cluster) addTo(model *models.InfrastructureModel, n *namer) []map[string]string {
+	var tagSets []map[string]string
+	name := n.reference(c.name)
+
+	info := c.cluster.Cluster
+	resource := models.NewResource(models.ResourceEKSCluster, name)
+	resource.AddProperty("name", name)
+	resource.AddProperty("role_arn", aws.ToString(info.RoleArn))
+	resource.AddProperty("version", aws.ToString(info.Version))
+	resource.AddProperty("vpc_config", map[string]interface{}{
+		"subnet_ids":              n.references(info.ResourcesVpcConfig.SubnetIds),
+		"endpoint_public_access":  info.ResourcesVpcConfig.EndpointPublicAccess,
+		"endpoint_private_access": info.ResourcesVpcConfig.EndpointPrivateAccess,
+	})
+	model.AddResource(resource)
+	tagSets = append(tagSets, awsTags(info.Tags))
+
+	for _, output := range c.groups {
+		group := output.Nodegroup
+		if group == nil {
+			continue
+		}
+		resource := models.NewResource(models.ResourceNodeGroup, n.name(aws.ToString(group.NodegroupName)))
+		resource.AddProperty("cluster_name", name)
+		resource.AddProperty("node_role_arn", aws.ToString(group.NodeRole))
+		resource.AddProperty("subnet_ids", n.references(group.Subnets))
+		if scaling := group.ScalingConfig; scaling != nil {
+			resource.AddProperty("scaling_config", map[string]interface{}{
+				"desired_size": int(aws.ToInt32(scaling.DesiredSize)),
+				"min_size":     int(aws.ToInt32(scaling.MinSize)),
+				"max_size":     int(aws.ToInt32(scaling.MaxSize)),
+			})
+		}
+		resource.AddProperty("instance_types", group.InstanceTypes)
+		model.AddResource(resource)
+		tagSets = append(tagSets, awsTags(group.Tags))
+	}
+
+	for _, output := range c.addons {
+		addon := output.Addon
+		if addon == nil {
+			continue
+		}
+		resource := models.NewResource(models.ResourceEKSAddon, n.name(aws.ToString(addon.AddonName)))
+		resource.AddProperty("cluster_name", name)
+		resource.AddProperty("addon_name", aws.ToString(addon.AddonName))
+		if addon.AddonVersion != nil {
+			resource.AddProperty("addon_version", aws.ToString(addon.AddonVersion))
+		}
+		resource.AddProperty("resolve_conflicts", "OVERWRITE")
+		resource.AddDependency(name)
+		model.AddResource(resource)
+		tagSets = append(tagSets, awsTags(addon.Tags))
+	}
+
+	for _, output := range c.profiles {
+		profile := output.FargateProfile
+		if profile == nil {
+			continue
+		}
+		profileName := n.name(aws.ToString(profile.FargateProfileName))
+		resource := models.NewResource(models.ResourceFargateProfile, profileName)
+		resource.AddProperty("cluster_name", name)
+		resource.AddProperty("fargate_profile_name", profileName)
+		resource.AddProperty("subnet_ids", n.references(profile.Subnets))
+		var namespaces []string
+		for _, selector := range profile.Selectors {
+			if selector.Namespace != nil {
+				namespaces = append(namespaces, aws.ToString(selector.Namespace))
+			}
+		}
+		resource.AddProperty("namespaces", namespaces)
+		resource.AddDependency(name)
+		model.AddResource(resource)
+		tagSets = append(tagSets, awsTags(profile.Tags))
+	}
+	return tagSets
+}
+
+// namer names the discovered resources and resolves the references between them
+type namer struct {
+	// names are the model names given so far
+	names map[string]bool
+	// ids maps the IDs and AWS names of the discovered resources to their model names
+	ids map[string]string
+}
+
+// namePattern matches the characters a model name is made of
+var namePattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// add names the resource with an ID after its Name tag, or its ID when it has none
+func (n *namer) add(id, nameTag string) {
+	n.ids[id] = n.name(nameTag, id)
+}
+
+// name returns the first candidate made into a model name, of lower-case letters, digits and
+// hyphens. A name already given gets a number.
+func (n *namer) name(candidates ...string) string {
+	name := "resource"
+	for _, candidate := range candidates {
+		candidate = strings.Trim(namePattern.ReplaceAllString(strings.ToLower(candidate), "-"), "-")
+		if candidate != "" && candidate[0] >= 'a' && candidate[0] <= 'z' {
+			name = candidate
+			break
+		}
+	}
+	unique := name
+	for i := 2; n.names[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+	n.names[unique] = true
+	return unique
+}
+
+// reference returns the model name of the resource an ID refers to, or the ID when it was
+// not discovered, such as a subnet of another VPC
+func (n *namer) reference(id string) string {
+	if name, ok := n.ids[id]; ok {
+		return name
+	}
+	return id
+}
+
+// references returns the model names of the resources IDs refer to
+func (n *namer) references(ids []string) []string {
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = n.reference(id)
+	}
+	return names
+}
+
+// ec2Tags returns the tags of an EC2 resource as a map
+func ec2Tags(tags []ec2types.Tag) map[string]string {
+	values := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		values[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return awsTags(values)
+}
+
+// awsTags returns the tags without those AWS sets itself, such as aws:cloudformation:stack-name,
+// which cannot be set on a resource
+func awsTags(tags map[string]string) map[string]string {
+	values := make(map[string]string, len(tags))
+	for key, value := range tags {
+		if !strings.HasPrefix(key, "aws:") {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// commonTags returns the tags, other than Name, with the same value in every set
+func commonTags(tagSets []map[string]string) map[string]string {
+	if len(tagSets) == 0 {
+		return nil
+	}
+	common := make(map[string]string)
+	for key, value := range tagSets[0] {
+		if key != "Name" {
+			common[key] = value
+		}
+	}
+	for _, tags := range tagSets[1:] {
+		for key, value := range common {
+			if tags[key] != value {
+				delete(common, key)
+			}
+		}
+	}
+	if len(common) == 0 {
+		return nil
+	}
+	return common
+}
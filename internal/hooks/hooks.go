@@ -0,0 +1,210 @@
+// Package hooks runs the shell commands configured to run before and after generation
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// Phases hooks run in
+const (
+	// Pre hooks run before anything is generated
+	Pre = "pre"
+	// Post hooks run once every file has been generated
+	Post = "post"
+)
+
+// Failure policies of a hook, applied when it exits with a non-zero status or times out
+const (
+	// FailureFail stops the run with an error
+	FailureFail = "fail"
+	// FailureWarn prints a warning and goes on
+	FailureWarn = "warn"
+	// FailureIgnore goes on silently
+	FailureIgnore = "ignore"
+)
+
+// DefaultTimeout is how long a hook may run when it sets no timeout
+const DefaultTimeout = 5 * time.Minute
+
+// Hook is a shell command run in the output directory
+type Hook struct {
+	// Command is run with sh -c, or cmd /C on Windows
+	Command string
+	// Timeout is how long the command may run before it is killed
+	Timeout time.Duration
+	// OnFailure is the failure policy: fail, warn or ignore
+	OnFailure string
+}
+
+// Hooks are the hooks of each phase, run in order
+type Hooks struct {
+	Pre  []Hook
+	Post []Hook
+}
+
+// Empty reports whether no hook is configured
+func (h Hooks) Empty() bool {
+	return len(h.Pre) == 0 && len(h.Post) == 0
+}
+
+// Phase returns the hooks of a phase
+func (h Hooks) Phase(phase string) []Hook {
+	if phase == Pre {
+		return h.Pre
+	}
+	return h.Post
+}
+
+// Parse reads the hooks setting of a config file: a map of phases to lists of hooks, each a
+// command, or a map with the command under run and an optional timeout and on_failure
+func Parse(raw interface{}) (Hooks, error) {
+	var hooks Hooks
+	if raw == nil {
+		return hooks, nil
+	}
+	phases, ok := raw.(map[string]interface{})
+	if !ok {
+		return hooks, fmt.Errorf("hooks must be a map of pre and post hooks")
+	}
+
+	for phase, list := range phases {
+		var parsed []Hook
+		items, ok := list.([]interface{})
+		if !ok && list != nil {
+			return hooks, fmt.Errorf("%s hooks must be a list", phase)
+		}
+		for i, item := range items {
+			hook, err := parseHook(item)
+			if err != nil {
+				return hooks, fmt.Errorf("%s hook %d: %w", phase, i+1, err)
+			}
+			parsed = append(parsed, hook)
+		}
+
+		switch phase {
+		case Pre:
+			hooks.Pre = parsed
+		case Post:
+			hooks.Post = parsed
+		default:
+			return hooks, fmt.Errorf("unknown hook phase %s (use %s or %s)", phase, Pre, Post)
+		}
+	}
+	return hooks, nil
+}
+
+// parseHook reads one hook, a command or a map of settings
+func parseHook(item interface{}) (Hook, error) {
+	hook := Hook{Timeout: DefaultTimeout, OnFailure: FailureFail}
+	switch v := item.(type) {
+	case string:
+		hook.Command = v
+	case map[string]interface{}:
+		for key, value := range v {
+			text, ok := value.(string)
+			if !ok {
+				return hook, fmt.Errorf("%s must be a string", key)
+			}
+			switch key {
+			case "run":
+				hook.Command = text
+			case "timeout":
+				timeout, err := time.ParseDuration(text)
+				if err != nil || timeout <= 0 {
+					return hook, fmt.Errorf("invalid timeout %q (use a duration such as 30s)", text)
+				}
+				hook.Timeout = timeout
+			case "on_failure":
+				switch text {
+				case FailureFail, FailureWarn, FailureIgnore:
+					hook.OnFailure = text
+				default:
+					return hook, fmt.Errorf("invalid on_failure %q (use %s, %s or %s)", text, FailureFail, FailureWarn, FailureIgnore)
+				}
+			default:
+				return hook, fmt.Errorf("unknown setting %s (use run, timeout and on_failure)", key)
+			}
+		}
+	default:
+		return hook, fmt.Errorf("a hook must be a command or a map with run")
+	}
+
+	if hook.Command == "" {
+		return hook, fmt.Errorf("a hook must have a command")
+	}
+	return hook, nil
+}
+
+// Run runs the hooks of a phase in order, in dir, with env added to the environment. Their
+// output and the command of each are written to out. A hook that fails with the fail
+// policy stops the run and returns its error; one with the warn policy is reported to warn,
+// or written to out as a warning when warn is nil.
+func Run(ctx context.Context, phase string, hooks []Hook, dir string, env []string, out io.Writer, warn func(err error)) error {
+	if out == nil {
+		out = io.Discard
+	}
+	logger := utils.GetLogger()
+
+	for _, hook := range hooks {
+		fmt.Fprintf(out, "Running %s-generation hook: %s\n", phase, hook.Command)
+		logger.Infow("Running hook", "phase", phase, "command", hook.Command, "dir", dir)
+
+		err := run(ctx, hook, dir, env, out)
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("%s-generation hook %q failed: %w", phase, hook.Command, err)
+		switch hook.OnFailure {
+		case FailureWarn:
+			if warn != nil {
+				warn(err)
+			} else {
+				fmt.Fprintf(out, "Warning: %v\n", err)
+			}
+			logger.Warnw("Hook failed", "phase", phase, "command", hook.Command, "error", err)
+		case FailureIgnore:
+			logger.Debugw("Ignoring failed hook", "phase", phase, "command", hook.Command, "error", err)
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// run runs one hook with its timeout
+func run(ctx context.Context, hook Hook, dir string, env []string, out io.Writer) error {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", hook.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", hook.Command)
+	}
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	// Processes the command started may keep its output open after it is killed
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
@@ -0,0 +1,170 @@
+package infra
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// EKSAddon describes an EKS add-on. Add-ons that call AWS APIs run under an IAM role for
+// service accounts (IRSA), assumed by the add-on's service account through the cluster's
+// OIDC provider.
+type EKSAddon struct {
+	Name string
+	// ServiceAccount is the namespace/name of the service account the IAM role is for;
+	// empty when the add-on needs no role
+	ServiceAccount string
+	// PolicyARN is the managed policy attached to the IAM role
+	PolicyARN string
+}
+
+// NeedsIRSA reports whether the add-on runs under an IAM role for service accounts
+func (a EKSAddon) NeedsIRSA() bool {
+	return a.ServiceAccount != ""
+}
+
+// eksAddons are the EKS add-ons the generators know how to install
+var eksAddons = []EKSAddon{
+	{Name: "vpc-cni"},
+	{Name: "coredns"},
+	{Name: "kube-proxy"},
+	{
+		Name:           "aws-ebs-csi-driver",
+		ServiceAccount: "kube-system/ebs-csi-controller-sa",
+		PolicyARN:      "arn:aws:iam::aws:policy/service-role/AmazonEBSCSIDriverPolicy",
+	},
+	{
+		Name:           "aws-efs-csi-driver",
+		ServiceAccount: "kube-system/efs-csi-controller-sa",
+		PolicyARN:      "arn:aws:iam::aws:policy/service-role/AmazonEFSCSIDriverPolicy",
+	},
+	{Name: "eks-pod-identity-agent"},
+}
+
+// LookupEKSAddon returns the add-on with the given EKS name, such as "aws-ebs-csi-driver"
+func LookupEKSAddon(name string) (EKSAddon, bool) {
+	for _, addon := range eksAddons {
+		if addon.Name == name {
+			return addon, true
+		}
+	}
+	return EKSAddon{}, false
+}
+
+// EKSAddonNames returns the names of the supported add-ons
+func EKSAddonNames() []string {
+	names := make([]string, len(eksAddons))
+	for i, addon := range eksAddons {
+		names[i] = addon.Name
+	}
+	return names
+}
+
+// eksAddonVersionPattern matches the add-on versions EKS publishes, such as v1.18.1-eksbuild.1
+var eksAddonVersionPattern = regexp.MustCompile(`^v\d+\.\d+\.\d+-eksbuild\.\d+$`)
+
+// ValidEKSAddonVersion reports whether a version can pin an add-on
+func ValidEKSAddonVersion(version string) bool {
+	return eksAddonVersionPattern.MatchString(version)
+}
+
+// CreateEKSAddon creates an EKS add-on resource. An empty version installs the default
+// version for the cluster's Kubernetes version.
+func CreateEKSAddon(name string, clusterName string, addon EKSAddon, version string) models.Resource {
+	resource := models.NewResource(models.ResourceEKSAddon, name)
+	resource.AddProperty("cluster_name", clusterName)
+	resource.AddProperty("addon_name", addon.Name)
+	if version != "" {
+		resource.AddProperty("addon_version", version)
+	}
+	resource.AddProperty("resolve_conflicts", "OVERWRITE")
+	if addon.NeedsIRSA() {
+		resource.AddProperty("service_account", addon.ServiceAccount)
+		resource.AddProperty("policy_arn", addon.PolicyARN)
+	}
+	resource.AddDependency(clusterName)
+	return resource
+}
+
+// addonVersionsTable is the bundled compatibility table of the add-on versions to install on
+// each Kubernetes version of EKS
+//
+//go:embed addon_versions.json
+var addonVersionsTable []byte
+
+// addonVersions maps Kubernetes versions, such as 1.29, to the add-on versions compatible with
+// them, by add-on name
+var addonVersions = func() map[string]map[string]string {
+	var table map[string]map[string]string
+	if err := json.Unmarshal(addonVersionsTable, &table); err != nil {
+		panic(fmt.Sprintf("invalid add-on versions table: %v", err))
+	}
+	return table
+}()
+
+// CompatibleEKSAddonVersion returns the version of an add-on the bundled compatibility table
+// lists for a Kubernetes version, and false when it lists none
+func CompatibleEKSAddonVersion(clusterVersion, addon string) (string, bool) {
+	version, ok := addonVersions[clusterVersion][addon]
+	return version, ok
+}
+
+// ValidateEKSAddonVersions checks the add-on versions overriding the compatibility table,
+// keyed by add-on name
+func ValidateEKSAddonVersions(versions map[string]string) error {
+	names := make([]string, 0, len(versions))
+	for name := range versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := LookupEKSAddon(name); !ok {
+			return fmt.Errorf("invalid add-on version override: %q is not a supported add-on (%s)", name, strings.Join(EKSAddonNames(), ", "))
+		}
+		if !ValidEKSAddonVersion(versions[name]) {
+			return fmt.Errorf("invalid add-on version override: %q is not an add-on version such as v1.18.1-eksbuild.1", versions[name])
+		}
+	}
+	return nil
+}
+
+// ResolveEKSAddonVersions sets the versions of the model's add-ons: an override given for the
+// add-on, else the version the description pinned, else the version the compatibility table
+// lists for the Kubernetes version of the add-on's cluster. An add-on the table does not list
+// for the version keeps installing the EKS default.
+func ResolveEKSAddonVersions(model *models.InfrastructureModel, overrides map[string]string) {
+	clusterVersions := make(map[string]string)
+	for _, resource := range model.Resources {
+		if resource.Type == models.ResourceEKSCluster {
+			if version, ok := resource.GetProperty("version"); ok {
+				clusterVersions[resource.Name], _ = version.(string)
+			}
+		}
+	}
+
+	for i := range model.Resources {
+		addon := &model.Resources[i]
+		if addon.Type != models.ResourceEKSAddon {
+			continue
+		}
+		nameValue, _ := addon.GetProperty("addon_name")
+		name, _ := nameValue.(string)
+		if version, ok := overrides[name]; ok {
+			addon.SetProperty("addon_version", version)
+			continue
+		}
+		if _, pinned := addon.GetProperty("addon_version"); pinned {
+			continue
+		}
+		clusterValue, _ := addon.GetProperty("cluster_name")
+		cluster, _ := clusterValue.(string)
+		if version, ok := CompatibleEKSAddonVersion(clusterVersions[cluster], name); ok {
+			addon.SetProperty("addon_version", version)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package infra
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Operating systems supported by the AMI lookup
+const (
+	OSAmazonLinux2023 = "amazon-linux-2023"
+	OSUbuntu          = "ubuntu"
+)
+
+// AMILookup describes how to resolve the latest AMI for an operating system at apply time
+// instead of hardcoding a region-specific image ID
+type AMILookup struct {
+	OS           string
+	Owner        string
+	NameFilter   string
+	Architecture string
+	SSMParameter string
+}
+
+// LookupAMI returns the AMI lookup for an operating system and instance type.
+// Graviton instance types (such as t4g.small) resolve to arm64 images.
+func LookupAMI(os string, instanceType string) AMILookup {
+	arch := "x86_64"
+	if isGravitonInstanceType(instanceType) {
+		arch = "arm64"
+	}
+
+	if strings.ToLower(os) == OSUbuntu {
+		ubuntuArch := "amd64"
+		if arch == "arm64" {
+			ubuntuArch = "arm64"
+		}
+		return AMILookup{
+			OS:           OSUbuntu,
+			Owner:        "099720109477", // Canonical
+			NameFilter:   fmt.Sprintf("ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-%s-server-*", ubuntuArch),
+			Architecture: arch,
+			SSMParameter: fmt.Sprintf("/aws/service/canonical/ubuntu/server/22.04/stable/current/%s/hvm/ebs-gp2/ami-id", ubuntuArch),
+		}
+	}
+
+	return AMILookup{
+		OS:           OSAmazonLinux2023,
+		Owner:        "amazon",
+		NameFilter:   fmt.Sprintf("al2023-ami-2023.*-kernel-*-%s", arch),
+		Architecture: arch,
+		SSMParameter: fmt.Sprintf("/aws/service/ami-amazon-linux-latest/al2023-ami-kernel-default-%s", arch),
+	}
+}
+
+// DataSourceName returns the Terraform data source name for the lookup, such as "amazon_linux_2023_x86_64"
+func (l AMILookup) DataSourceName() string {
+	return strings.ReplaceAll(l.OS, "-", "_") + "_" + l.Architecture
+}
+
+// Properties returns the lookup as a resource property value
+func (l AMILookup) Properties() map[string]interface{} {
+	return map[string]interface{}{
+		"os":            l.OS,
+		"owner":         l.Owner,
+		"name_filter":   l.NameFilter,
+		"architecture":  l.Architecture,
+		"ssm_parameter": l.SSMParameter,
+		"data_source":   l.DataSourceName(),
+	}
+}
+
+// isGravitonInstanceType reports whether an instance type uses an AWS Graviton (arm64) processor
+func isGravitonInstanceType(instanceType string) bool {
+	family := strings.SplitN(strings.ToLower(instanceType), ".", 2)[0]
+	// Graviton families carry a "g" processor suffix after the generation number, e.g. t4g, m7g, c6gn
+	for i, r := range family {
+		if r >= '0' && r <= '9' {
+			suffix := family[i+1:]
+			return strings.HasPrefix(suffix, "g")
+		}
+	}
+	return false
+}
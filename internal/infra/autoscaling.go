@@ -0,0 +1,37 @@
+package infra
+
+// Autoscaling strategies of an EKS cluster's nodes
+const (
+	// AutoscalingClusterAutoscaler scales the node groups' Auto Scaling groups with the
+	// Kubernetes Cluster Autoscaler
+	AutoscalingClusterAutoscaler = "cluster-autoscaler"
+	// AutoscalingKarpenter provisions nodes for the cluster's pending pods with Karpenter
+	AutoscalingKarpenter = "karpenter"
+)
+
+// EKSAutoscalingStrategies returns the supported autoscaling strategies
+func EKSAutoscalingStrategies() []string {
+	return []string{AutoscalingClusterAutoscaler, AutoscalingKarpenter}
+}
+
+// ValidEKSAutoscaling reports whether a strategy is a supported autoscaling strategy
+func ValidEKSAutoscaling(strategy string) bool {
+	for _, known := range EKSAutoscalingStrategies() {
+		if strategy == known {
+			return true
+		}
+	}
+	return false
+}
+
+// eksAutoscaling returns the autoscaling strategy of an extracted EKS entity: the one it
+// names, or Karpenter when it only enables Karpenter
+func eksAutoscaling(eksData map[string]interface{}) string {
+	if strategy, ok := eksData["autoscaling"].(string); ok && ValidEKSAutoscaling(strategy) {
+		return strategy
+	}
+	if karpenter, ok := eksData["karpenter"].(bool); ok && karpenter {
+		return AutoscalingKarpenter
+	}
+	return ""
+}
@@ -1,8 +1,9 @@
 package infra
 
 import (
+	"encoding/binary"
 	"fmt"
-	"strings"
+	"net"
 	
 	"github.com/riptano/iac_generator_cli/pkg/models"
 )
@@ -127,33 +128,135 @@ func CreateEKSCluster(name string, version string, roleArn string, subnetIDs []s
 
 // GenerateSubnetCIDRs generates CIDR blocks for subnets based on VPC CIDR
 func GenerateSubnetCIDRs(vpcCIDR string, publicCount int, privateCount int) ([]string, []string, error) {
-	// Parse VPC CIDR
-	parts := strings.Split(vpcCIDR, "/")
-	if len(parts) != 2 {
+	return GenerateSubnetCIDRsWithPlan(vpcCIDR, publicCount, privateCount, SubnetPlan{})
+}
+
+// Subnet sizes AWS accepts, and the layout used when no plan is given: /24 subnets with the
+// private subnets starting at the eleventh block, x.x.10.0/24 in a /16
+const (
+	minSubnetMask          = 16
+	maxSubnetMask          = 28
+	defaultSubnetMask      = 24
+	defaultPrivateSubnetAt = 10
+)
+
+// SubnetPlan holds the CIDR planning directives of a description, such as "/20 subnets" or
+// "leave room for 6 AZs"
+type SubnetPlan struct {
+	// Mask is the prefix length of every subnet; zero means /24
+	Mask int
+	// ReservedAZs is the number of subnets kept free in each tier, so subnets for more
+	// availability zones can be added later without renumbering
+	ReservedAZs int
+}
+
+// IsZero reports whether the plan has no directives
+func (p SubnetPlan) IsZero() bool {
+	return p == SubnetPlan{}
+}
+
+// SubnetPlanFromEntity reads the plan from the "subnet_mask" and "reserved_azs" fields of a
+// subnets entity
+func SubnetPlanFromEntity(subnets map[string]interface{}) SubnetPlan {
+	var plan SubnetPlan
+	plan.Mask, _ = subnets["subnet_mask"].(int)
+	plan.ReservedAZs, _ = subnets["reserved_azs"].(int)
+	return plan
+}
+
+// GenerateSubnetCIDRsWithPlan generates subnet CIDR blocks of the plan's size. Public subnets
+// are numbered from the start of the VPC and private subnets follow in a tier of the same
+// size, which is as large as the reserved availability zones. An error says why the plan
+// does not fit the VPC.
+func GenerateSubnetCIDRsWithPlan(vpcCIDR string, publicCount int, privateCount int, plan SubnetPlan) ([]string, []string, error) {
+	_, vpc, err := net.ParseCIDR(vpcCIDR)
+	if err != nil || vpc.IP.To4() == nil {
 		return nil, nil, fmt.Errorf("invalid VPC CIDR format: %s", vpcCIDR)
 	}
-	
-	ipParts := strings.Split(parts[0], ".")
-	if len(ipParts) != 4 {
-		return nil, nil, fmt.Errorf("invalid IP format in CIDR: %s", vpcCIDR)
+	vpcMask, _ := vpc.Mask.Size()
+
+	mask := plan.Mask
+	if mask == 0 {
+		mask = defaultSubnetMask
 	}
-	
+	if mask < minSubnetMask || mask > maxSubnetMask {
+		return nil, nil, fmt.Errorf("subnet size /%d is not supported; AWS subnets must be /%d to /%d", mask, minSubnetMask, maxSubnetMask)
+	}
+	if mask <= vpcMask {
+		return nil, nil, fmt.Errorf("/%d subnets do not fit in VPC %s; use subnets smaller than /%d", mask, vpc, vpcMask)
+	}
+
+	blocks := 1 << uint(mask-vpcMask)
+	tier := publicCount
+	if privateCount > tier {
+		tier = privateCount
+	}
+	if plan.ReservedAZs > tier {
+		tier = plan.ReservedAZs
+	}
+	needed := tier + privateCount
+	if plan.ReservedAZs > 0 {
+		needed = 2 * tier
+	}
+	if plan.IsZero() && tier <= defaultPrivateSubnetAt && defaultPrivateSubnetAt+privateCount <= blocks {
+		tier = defaultPrivateSubnetAt
+		needed = tier + privateCount
+	}
+	if needed > blocks {
+		reason := fmt.Sprintf("%d public and %d private", publicCount, privateCount)
+		if plan.ReservedAZs > 0 {
+			reason = fmt.Sprintf("%d per tier to leave room for %d availability zones", tier, plan.ReservedAZs)
+		}
+		return nil, nil, fmt.Errorf("VPC %s has room for %d /%d subnets, but %d are needed (%s); use a larger VPC CIDR or smaller subnets", vpc, blocks, mask, needed, reason)
+	}
+
+	base := binary.BigEndian.Uint32(vpc.IP.To4())
+	size := uint32(1) << uint(32-mask)
+	block := func(i int) string {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, base+uint32(i)*size)
+		return fmt.Sprintf("%s/%d", ip, mask)
+	}
+
 	publicCIDRs := make([]string, publicCount)
-	privateCIDRs := make([]string, privateCount)
-	
-	// Generate public subnets starting from x.x.0.0/24
-	for i := 0; i < publicCount; i++ {
-		publicCIDRs[i] = fmt.Sprintf("%s.%s.%d.0/24", ipParts[0], ipParts[1], i)
+	for i := range publicCIDRs {
+		publicCIDRs[i] = block(i)
 	}
-	
-	// Generate private subnets starting from x.x.10.0/24
-	for i := 0; i < privateCount; i++ {
-		privateCIDRs[i] = fmt.Sprintf("%s.%s.%d.0/24", ipParts[0], ipParts[1], i+10)
+	privateCIDRs := make([]string, privateCount)
+	for i := range privateCIDRs {
+		privateCIDRs[i] = block(tier + i)
 	}
-	
+
 	return publicCIDRs, privateCIDRs, nil
 }
 
+// overflowSubnetCIDRs lays out default-sized subnets one after the other from the start of a
+// VPC too small for the default layout, the public subnets first. The subnets past the end of
+// the VPC are left for the ModelValidator to report, by name. It returns false when the VPC
+// CIDR is not a valid IPv4 block.
+func overflowSubnetCIDRs(vpcCIDR string, publicCount int, privateCount int) ([]string, []string, bool) {
+	_, vpc, err := net.ParseCIDR(vpcCIDR)
+	if err != nil || vpc.IP.To4() == nil {
+		return nil, nil, false
+	}
+	base := binary.BigEndian.Uint32(vpc.IP.To4()) &^ (uint32(1)<<uint(32-defaultSubnetMask) - 1)
+	block := func(i int) string {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, base+uint32(i)<<uint(32-defaultSubnetMask))
+		return fmt.Sprintf("%s/%d", ip, defaultSubnetMask)
+	}
+
+	publicCIDRs := make([]string, publicCount)
+	for i := range publicCIDRs {
+		publicCIDRs[i] = block(i)
+	}
+	privateCIDRs := make([]string, privateCount)
+	for i := range privateCIDRs {
+		privateCIDRs[i] = block(publicCount + i)
+	}
+	return publicCIDRs, privateCIDRs, true
+}
+
 // CreateEKSNodeGroup creates an EKS Node Group resource
 func CreateEKSNodeGroup(name string, clusterName string, nodeRoleArn string, subnetIDs []string, instanceTypes []string, desiredSize int, minSize int, maxSize int) models.Resource {
 	resource := models.NewResource(models.ResourceNodeGroup, name)
@@ -173,4 +276,53 @@ func CreateEKSNodeGroup(name string, clusterName string, nodeRoleArn string, sub
 	resource.AddProperty("instance_types", instanceTypes)
 	
 	return resource
-}
\ No newline at end of file
+}
+
+// CreateLaunchTemplate creates a Launch Template resource
+func CreateLaunchTemplate(name string, instanceType string, ami string, userData string) models.Resource {
+	resource := models.NewResource(models.ResourceLaunchTemplate, name)
+	resource.AddProperty("name_prefix", name+"-")
+	resource.AddProperty("instance_type", instanceType)
+	resource.AddProperty("image_id", ami)
+	if userData != "" {
+		resource.AddProperty("user_data", userData)
+	}
+	return resource
+}
+
+// CreateAutoScalingGroup creates an Auto Scaling Group resource backed by a launch template
+func CreateAutoScalingGroup(name string, launchTemplateName string, subnetIDs []string, desiredCapacity int, minSize int, maxSize int) models.Resource {
+	resource := models.NewResource(models.ResourceAutoScalingGroup, name)
+	resource.AddProperty("name", name)
+	resource.AddProperty("launch_template", launchTemplateName)
+	resource.AddProperty("vpc_zone_identifier", subnetIDs)
+	resource.AddProperty("desired_capacity", desiredCapacity)
+	resource.AddProperty("min_size", minSize)
+	resource.AddProperty("max_size", maxSize)
+	resource.AddProperty("health_check_type", "EC2")
+	resource.AddDependency(launchTemplateName)
+	return resource
+}
+
+// SetInstanceRefresh configures the instance refresh settings of an Auto Scaling Group
+func SetInstanceRefresh(asg *models.Resource, strategy string, minHealthyPercentage int) {
+	asg.SetProperty("instance_refresh", map[string]interface{}{
+		"strategy":               strategy,
+		"min_healthy_percentage": minHealthyPercentage,
+	})
+}
+
+// MaxFargateSelectors is the number of namespace selectors EKS allows in one Fargate profile
+const MaxFargateSelectors = 5
+
+// CreateFargateProfile creates an EKS Fargate profile that runs the pods of the given
+// namespaces on Fargate. Fargate only places pods in private subnets.
+func CreateFargateProfile(name string, clusterName string, subnetIDs []string, namespaces []string) models.Resource {
+	resource := models.NewResource(models.ResourceFargateProfile, name)
+	resource.AddProperty("cluster_name", clusterName)
+	resource.AddProperty("fargate_profile_name", name)
+	resource.AddProperty("subnet_ids", subnetIDs)
+	resource.AddProperty("namespaces", namespaces)
+	resource.AddDependency(clusterName)
+	return resource
+}
@@ -0,0 +1,37 @@
+package infra
+
+// EKSController describes a Kubernetes controller commonly run on EKS outside the EKS
+// add-ons, such as the AWS Load Balancer Controller. Each runs under an IAM role for service
+// accounts (IRSA), assumed by its service account through the cluster's OIDC provider.
+type EKSController struct {
+	Name string
+	// ServiceAccount is the namespace/name of the service account the IAM role is for, as
+	// the controller's Helm chart names it by default
+	ServiceAccount string
+}
+
+// eksControllers are the controllers the generators create IAM roles for
+var eksControllers = []EKSController{
+	{Name: "aws-load-balancer-controller", ServiceAccount: "kube-system/aws-load-balancer-controller"},
+	{Name: "external-dns", ServiceAccount: "kube-system/external-dns"},
+	{Name: "cluster-autoscaler", ServiceAccount: "kube-system/cluster-autoscaler"},
+}
+
+// LookupEKSController returns the controller with the given name, such as "external-dns"
+func LookupEKSController(name string) (EKSController, bool) {
+	for _, controller := range eksControllers {
+		if controller.Name == name {
+			return controller, true
+		}
+	}
+	return EKSController{}, false
+}
+
+// EKSControllerNames returns the names of the supported controllers
+func EKSControllerNames() []string {
+	names := make([]string, len(eksControllers))
+	for i, controller := range eksControllers {
+		names[i] = controller.Name
+	}
+	return names
+}
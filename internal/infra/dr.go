@@ -0,0 +1,164 @@
+package infra
+
+import (
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// DRSuffix is appended to the names of resources in a disaster-recovery variant
+const DRSuffix = "-dr"
+
+// drReplicatedTypes are the resource types copied into the secondary region.
+// Compute such as Auto Scaling Groups is left out; the DR stack is a skeleton
+// that workloads are deployed onto during failover.
+var drReplicatedTypes = map[models.ResourceType]bool{
+	models.ResourceVPC:            true,
+	models.ResourceSubnet:         true,
+	models.ResourceIGW:            true,
+	models.ResourceNATGateway:     true,
+	models.ResourceSecurityGroup:  true,
+	models.ResourceIAMRole:        true,
+	models.ResourceEKSCluster:     true,
+	models.ResourceNodeGroup:      true,
+	models.ResourceEKSAddon:       true,
+	models.ResourceFargateProfile: true,
+}
+
+// BuildDRVariant builds a secondary-region variant of a model for disaster recovery.
+// The VPC and EKS skeleton is copied into drRegion with DRSuffix appended to every
+// resource name. Each S3 bucket in the primary model gets a replica bucket in the
+// secondary region, and the primary bucket is updated in place with versioning and
+// a replication property pointing at its replica.
+func BuildDRVariant(primary *models.InfrastructureModel, drRegion string) *models.InfrastructureModel {
+	variant := models.NewInfrastructureModel()
+	if primary == nil {
+		return variant
+	}
+	variant.Region = drRegion
+	variant.Tags = primary.Tags
+	variant.Environment = primary.Environment
+
+	// Collect the names of all replicated resources so references can be rewritten
+	renamed := make(map[string]string)
+	for _, resource := range primary.Resources {
+		if drReplicatedTypes[resource.Type] {
+			renamed[resource.Name] = resource.Name + DRSuffix
+		}
+	}
+
+	for i, resource := range primary.Resources {
+		switch {
+		case drReplicatedTypes[resource.Type]:
+			variant.AddResource(copyResourceToRegion(resource, renamed, drRegion))
+		case resource.Type == models.ResourceS3Bucket:
+			variant.AddResource(configureBucketReplication(&primary.Resources[i], drRegion))
+		}
+	}
+
+	return variant
+}
+
+// copyResourceToRegion copies a resource into another region, renaming it and its references
+func copyResourceToRegion(resource models.Resource, renamed map[string]string, region string) models.Resource {
+	replica := models.NewResource(resource.Type, renamed[resource.Name])
+
+	for _, property := range resource.Properties {
+		value := rewriteReferences(property.Value, renamed)
+
+		switch property.Name {
+		case "availability_zone":
+			if az, ok := value.(string); ok {
+				value = regionalAvailabilityZone(az, region)
+			}
+		case "region":
+			value = region
+		}
+
+		replica.AddProperty(property.Name, value)
+	}
+
+	if _, ok := replica.GetProperty("region"); !ok {
+		replica.AddProperty("region", region)
+	}
+
+	for _, dep := range resource.DependsOn {
+		if name, ok := renamed[dep]; ok {
+			dep = name
+		}
+		replica.AddDependency(dep)
+	}
+
+	return replica
+}
+
+// configureBucketReplication enables replication on a primary bucket and returns its replica
+func configureBucketReplication(bucket *models.Resource, region string) models.Resource {
+	replicaName := bucket.Name + DRSuffix
+
+	acl := "private"
+	if value, ok := bucket.GetProperty("acl"); ok {
+		if str, ok := value.(string); ok {
+			acl = str
+		}
+	}
+
+	// Cross-region replication requires versioning on both buckets
+	bucket.SetProperty("versioning", true)
+	bucket.SetProperty("replication", map[string]interface{}{
+		"destination_bucket": replicaName,
+		"destination_region": region,
+	})
+
+	replica := CreateS3Bucket(replicaName, acl, true)
+	replica.AddProperty("region", region)
+	replica.AddProperty("replica_of", bucket.Name)
+	return replica
+}
+
+// rewriteReferences replaces references to renamed resources inside a property value
+func rewriteReferences(value interface{}, renamed map[string]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		if name, ok := renamed[v]; ok {
+			return name
+		}
+		return v
+	case []string:
+		result := make([]string, len(v))
+		for i, item := range v {
+			result[i] = rewriteReferences(item, renamed).(string)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = rewriteReferences(item, renamed)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = rewriteReferences(item, renamed)
+		}
+		return result
+	case []map[string]interface{}:
+		result := make([]map[string]interface{}, len(v))
+		for i, item := range v {
+			result[i] = rewriteReferences(item, renamed).(map[string]interface{})
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+// regionalAvailabilityZone moves an availability zone such as us-east-1a into another region
+func regionalAvailabilityZone(az string, region string) string {
+	if len(az) == 0 {
+		return az
+	}
+	suffix := az[len(az)-1:]
+	if suffix < "a" || suffix > "z" {
+		return region + "a"
+	}
+	return region + suffix
+}
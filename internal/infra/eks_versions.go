@@ -0,0 +1,105 @@
+package infra
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// eksVersions are the Kubernetes versions EKS creates clusters with, in standard or extended
+// support, oldest first
+var eksVersions = []string{"1.25", "1.26", "1.27", "1.28", "1.29", "1.30", "1.31"}
+
+// SupportedEKSVersions returns the Kubernetes versions EKS creates clusters with, oldest first
+func SupportedEKSVersions() []string {
+	return append([]string(nil), eksVersions...)
+}
+
+// LatestEKSVersion returns the newest Kubernetes version EKS creates clusters with
+func LatestEKSVersion() string {
+	return eksVersions[len(eksVersions)-1]
+}
+
+// ValidateEKSVersion checks that EKS creates clusters with a Kubernetes version, such as 1.29
+func ValidateEKSVersion(version string) error {
+	for _, supported := range eksVersions {
+		if version == supported {
+			return nil
+		}
+	}
+	if _, ok := kubernetesMinor(version); !ok {
+		return fmt.Errorf("%q is not a Kubernetes version such as %s", version, LatestEKSVersion())
+	}
+	return fmt.Errorf("EKS does not support Kubernetes %s; use %s to %s", version, eksVersions[0], LatestEKSVersion())
+}
+
+// kubernetesMinor returns the minor version of a Kubernetes 1.x version, such as 29 for 1.29
+// or v1.29.10-eksbuild.3
+func kubernetesMinor(version string) (int, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 || parts[0] != "1" {
+		return 0, false
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil || minor < 0 {
+		return 0, false
+	}
+	return minor, true
+}
+
+// AMI types of EKS managed node groups by the architecture of their instances. GPU and
+// Neuron AMI types also need instances with those accelerators.
+var (
+	eksAMITypesARM64 = []string{"AL2_ARM_64", "AL2023_ARM_64_STANDARD", "BOTTLEROCKET_ARM_64", "BOTTLEROCKET_ARM_64_NVIDIA"}
+	eksAMITypesX8664 = []string{
+		"AL2_x86_64", "AL2_x86_64_GPU", "AL2023_x86_64_STANDARD", "AL2023_x86_64_NVIDIA", "AL2023_x86_64_NEURON",
+		"BOTTLEROCKET_x86_64", "BOTTLEROCKET_x86_64_NVIDIA",
+		"WINDOWS_CORE_2019_x86_64", "WINDOWS_FULL_2019_x86_64", "WINDOWS_CORE_2022_x86_64", "WINDOWS_FULL_2022_x86_64",
+	}
+)
+
+// DefaultEKSAMIType returns the AMI type for a node group of the instance types on a Kubernetes
+// version: Amazon Linux 2023 from 1.30, Amazon Linux 2 before, for the architecture of the
+// instances
+func DefaultEKSAMIType(version string, instanceTypes []string) string {
+	arm := len(instanceTypes) > 0 && isGravitonInstanceType(instanceTypes[0])
+	minor, ok := kubernetesMinor(version)
+	switch {
+	case ok && minor >= 30 && arm:
+		return "AL2023_ARM_64_STANDARD"
+	case ok && minor >= 30:
+		return "AL2023_x86_64_STANDARD"
+	case arm:
+		return "AL2_ARM_64"
+	}
+	return "AL2_x86_64"
+}
+
+// checkEKSAMIType returns why a node group of the instance types cannot run the AMI type, or
+// "" when it can. A CUSTOM AMI type comes from a launch template and is not checked.
+func checkEKSAMIType(amiType string, instanceTypes []string) string {
+	if amiType == "CUSTOM" {
+		return ""
+	}
+	arm := containsString(eksAMITypesARM64, amiType)
+	if !arm && !containsString(eksAMITypesX8664, amiType) {
+		return fmt.Sprintf("its AMI type %s is not an EKS AMI type", amiType)
+	}
+	for _, instanceType := range instanceTypes {
+		if isGravitonInstanceType(instanceType) != arm {
+			arch := "x86_64"
+			if arm {
+				arch = "arm64"
+			}
+			return fmt.Sprintf("its AMI type %s is for %s instances, but %s is not one", amiType, arch, instanceType)
+		}
+		family := strings.SplitN(strings.ToLower(instanceType), ".", 2)[0]
+		switch {
+		case (strings.HasSuffix(amiType, "_GPU") || strings.HasSuffix(amiType, "_NVIDIA")) && !strings.HasPrefix(family, "g") && !strings.HasPrefix(family, "p"):
+			return fmt.Sprintf("its AMI type %s is for NVIDIA GPU instances, but %s has no GPU", amiType, instanceType)
+		case strings.HasSuffix(amiType, "_NEURON") && !strings.HasPrefix(family, "inf") && !strings.HasPrefix(family, "trn"):
+			return fmt.Sprintf("its AMI type %s is for Inferentia and Trainium instances, but %s is not one", amiType, instanceType)
+		}
+	}
+	return ""
+}
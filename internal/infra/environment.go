@@ -0,0 +1,66 @@
+package infra
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// environmentPattern matches environment names. They become name suffixes and Terraform
+// workspace names, so they are kept short: lower-case letters, digits and hyphens, starting
+// with a letter.
+var environmentPattern = regexp.MustCompile(`^[a-z]([a-z0-9-]{0,14}[a-z0-9])?$`)
+
+// ValidEnvironment reports whether a name can be used as an environment name
+func ValidEnvironment(name string) bool {
+	return environmentPattern.MatchString(name)
+}
+
+// ApplyEnvironment makes a model the model of one environment, such as "staging". Every
+// resource name gets the environment as a suffix, such as main-vpc-staging, references to
+// the resources are renamed with them, and every resource is tagged with the environment. A
+// model made for another environment has that environment's suffix replaced.
+func ApplyEnvironment(model *models.InfrastructureModel, environment string) {
+	if model == nil || environment == "" || environment == model.Environment {
+		return
+	}
+
+	renamed := make(map[string]string, len(model.Resources))
+	for _, resource := range model.Resources {
+		name := resource.Name
+		if model.Environment != "" {
+			name = strings.TrimSuffix(name, "-"+model.Environment)
+		}
+		renamed[resource.Name] = name + "-" + environment
+	}
+
+	renameResources(model, renamed)
+
+	model.Environment = environment
+	tags := make(map[string]string, len(model.Tags)+1)
+	for key, value := range model.Tags {
+		tags[key] = value
+	}
+	tags["Environment"] = environment
+	model.Tags = tags
+}
+
+// renameResources renames the resources of a model, and the references to them in the
+// properties and dependencies of the others
+func renameResources(model *models.InfrastructureModel, renamed map[string]string) {
+	for i := range model.Resources {
+		resource := &model.Resources[i]
+		if name, ok := renamed[resource.Name]; ok {
+			resource.Name = name
+		}
+		for j := range resource.Properties {
+			resource.Properties[j].Value = rewriteReferences(resource.Properties[j].Value, renamed)
+		}
+		for j, dep := range resource.DependsOn {
+			if name, ok := renamed[dep]; ok {
+				resource.DependsOn[j] = name
+			}
+		}
+	}
+}
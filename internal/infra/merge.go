@@ -0,0 +1,203 @@
+package infra
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// PartialModel is one of the models MergeModels merges, with where it came from, such as the
+// file holding its description
+type PartialModel struct {
+	Source string
+	Model  *models.InfrastructureModel
+}
+
+// MergeConflicts are the conflicts MergeModels finds between partial models
+type MergeConflicts []ModelError
+
+// Error lists the conflicts, one per line
+func (e MergeConflicts) Error() string {
+	lines := []string{"the models cannot be merged:"}
+	for _, err := range e {
+		lines = append(lines, "  - "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// MergeModels merges partial models, such as those of descriptions of the network, the
+// cluster and the data of one infrastructure, into one model. Resources with the same name
+// must be the same resource in each model, such as the default VPC that several descriptions
+// imply, and are merged into one. The models must agree on their region, environment and
+// tags, and the VPCs and subnets they add must not overlap those of the other models. Any
+// conflict is returned as MergeConflicts.
+func MergeModels(partials []PartialModel) (*models.InfrastructureModel, error) {
+	merged := models.NewInfrastructureModel()
+	var conflicts MergeConflicts
+	// sources maps the name of each merged resource to the model that first had it
+	sources := make(map[string]string)
+	index := make(map[string]int)
+	var regionSource, environmentSource string
+	tagSources := make(map[string]string)
+
+	for _, partial := range partials {
+		model := partial.Model
+		if model.Region != "" {
+			if merged.Region == "" {
+				merged.Region, regionSource = model.Region, partial.Source
+			} else if model.Region != merged.Region {
+				conflicts = append(conflicts, ModelError{
+					Resource: "region",
+					Problem:  fmt.Sprintf("%s is in %s, but %s is in %s", partial.Source, model.Region, regionSource, merged.Region),
+					Fix:      "describe the same region in each",
+				})
+			}
+		}
+		if model.Environment != "" {
+			if merged.Environment == "" {
+				merged.Environment, environmentSource = model.Environment, partial.Source
+			} else if model.Environment != merged.Environment {
+				conflicts = append(conflicts, ModelError{
+					Resource: "environment",
+					Problem:  fmt.Sprintf("%s is for %s, but %s is for %s", partial.Source, model.Environment, environmentSource, merged.Environment),
+					Fix:      "describe the same environment in each",
+				})
+			}
+		}
+		for _, key := range utils.SortedKeys(model.Tags) {
+			value := model.Tags[key]
+			if existing, ok := merged.Tags[key]; ok {
+				if existing != value {
+					conflicts = append(conflicts, ModelError{
+						Resource: "tag " + key,
+						Problem:  fmt.Sprintf("%s sets it to %q, but %s sets it to %q", partial.Source, value, tagSources[key], existing),
+						Fix:      "set it the same way in each",
+					})
+				}
+				continue
+			}
+			if merged.Tags == nil {
+				merged.Tags = make(map[string]string)
+			}
+			merged.Tags[key], tagSources[key] = value, partial.Source
+		}
+
+		for _, resource := range model.Resources {
+			i, ok := index[resource.Name]
+			if !ok {
+				index[resource.Name] = len(merged.Resources)
+				sources[resource.Name] = partial.Source
+				resource.DependsOn = append([]string(nil), resource.DependsOn...)
+				merged.AddResource(resource)
+				continue
+			}
+			existing := &merged.Resources[i]
+			if differences := resourceDifferences(*existing, resource); len(differences) > 0 {
+				conflicts = append(conflicts, ModelError{
+					Resource: fmt.Sprintf("%s %s", resource.Type, resource.Name),
+					Problem:  fmt.Sprintf("%s and %s describe it differently: %s", sources[resource.Name], partial.Source, strings.Join(differences, ", ")),
+					Fix:      "describe it the same way in each, or give one of them another name",
+				})
+				continue
+			}
+			for _, dependency := range resource.DependsOn {
+				if !containsString(existing.DependsOn, dependency) {
+					existing.AddDependency(dependency)
+				}
+			}
+		}
+	}
+
+	conflicts = append(conflicts, overlappingNetworks(merged, sources)...)
+	if len(conflicts) > 0 {
+		return nil, conflicts
+	}
+	return merged, nil
+}
+
+// resourceDifferences returns how two resources with the same name differ, as their types or
+// the values of their properties
+func resourceDifferences(a, b models.Resource) []string {
+	if a.Type != b.Type {
+		return []string{fmt.Sprintf("a %s and a %s", a.Type, b.Type)}
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, properties := range [][]models.Property{a.Properties, b.Properties} {
+		for _, property := range properties {
+			if !seen[property.Name] {
+				seen[property.Name] = true
+				names = append(names, property.Name)
+			}
+		}
+	}
+	var differences []string
+	for _, name := range names {
+		valueA, okA := a.GetProperty(name)
+		valueB, okB := b.GetProperty(name)
+		if okA == okB && reflect.DeepEqual(valueA, valueB) {
+			continue
+		}
+		differences = append(differences, fmt.Sprintf("%s %s and %s", name, propertyValue(valueA, okA), propertyValue(valueB, okB)))
+	}
+	return differences
+}
+
+// propertyValue formats the value of a property for a conflict, or "unset" without one
+func propertyValue(value interface{}, ok bool) string {
+	if !ok {
+		return "unset"
+	}
+	return fmt.Sprint(value)
+}
+
+// overlappingNetworks returns the VPCs whose CIDR blocks overlap a VPC of another model, and
+// the subnets of a VPC whose CIDR blocks overlap a subnet of another model. Overlaps within one
+// model are left to the ModelValidator.
+func overlappingNetworks(model *models.InfrastructureModel, sources map[string]string) []ModelError {
+	type network struct {
+		resource models.Resource
+		cidr     string
+		ipNet    *net.IPNet
+	}
+	var conflicts []ModelError
+	seen := make(map[string][]network)
+	for _, resource := range model.Resources {
+		var group string
+		switch resource.Type {
+		case models.ResourceVPC:
+			group = "vpc/" + model.ResourceRegion(&resource)
+		case models.ResourceSubnet:
+			group = "subnet/" + stringProperty(resource, "vpc_id") + "/" + model.ResourceRegion(&resource)
+		default:
+			continue
+		}
+		cidr := stringProperty(resource, "cidr_block")
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		for _, other := range seen[group] {
+			if sources[other.resource.Name] == sources[resource.Name] {
+				continue
+			}
+			if ipNet.Contains(other.ipNet.IP) || other.ipNet.Contains(ipNet.IP) {
+				fix := "give each subnet of " + stringProperty(resource, "vpc_id") + " a CIDR block of its own"
+				if resource.Type == models.ResourceVPC {
+					fix = "name the same VPC in each description, or give the VPCs CIDR blocks of their own"
+				}
+				conflicts = append(conflicts, ModelError{
+					Resource: fmt.Sprintf("%s %s of %s", resource.Type, resource.Name, sources[resource.Name]),
+					Problem:  fmt.Sprintf("its CIDR block %s overlaps %s of %s %s of %s", cidr, other.cidr, other.resource.Type, other.resource.Name, sources[other.resource.Name]),
+					Fix:      fix,
+				})
+			}
+		}
+		seen[group] = append(seen[group], network{resource: resource, cidr: cidr, ipNet: ipNet})
+	}
+	return conflicts
+}
@@ -1,7 +1,9 @@
 package infra
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/riptano/iac_generator_cli/pkg/models"
 )
@@ -38,9 +40,16 @@ func (b *ModelBuilder) BuildFromParsedEntities(entities map[string]interface{})
 		region = regionStr
 	}
 
+	b.model.Region = region
+
+	// Global tags apply to every resource the generators emit
+	if tagData, ok := entities["tags"].(map[string]interface{}); ok {
+		b.model.Tags = entityTags(tagData)
+	}
+
 	// Create VPC if specified
 	if vpcData, ok := entities["vpc"].(map[string]interface{}); ok {
-		vpcName := "main-vpc"
+		vpcName := entityName(vpcData, "name", DefaultVPCName)
 		cidrBlock := "10.0.0.0/16"
 
 		if cidr, ok := vpcData["cidr_block"].(string); ok {
@@ -71,11 +80,19 @@ func (b *ModelBuilder) BuildFromParsedEntities(entities map[string]interface{})
 			if cidrs, ok := subnetData["public_cidrs"].([]string); ok && len(cidrs) > 0 {
 				publicCIDRs = cidrs
 			} else {
-				// Generate CIDRs if not provided
-				generatedPublic, generatedPrivate, err := GenerateSubnetCIDRs(cidrBlock, publicCount, privateCount)
+				// Generate CIDRs if not provided. A failed plan is an error, while the
+				// default layout packs the subnets from the start of a VPC too small for it,
+				// so that the model validation names the subnets that do not fit
+				plan := SubnetPlanFromEntity(subnetData)
+				generatedPublic, generatedPrivate, err := GenerateSubnetCIDRsWithPlan(cidrBlock, publicCount, privateCount, plan)
 				if err == nil {
 					publicCIDRs = generatedPublic
 					privateCIDRs = generatedPrivate
+				} else if !plan.IsZero() {
+					return fmt.Errorf("cannot plan subnet CIDRs: %w", err)
+				} else if packedPublic, packedPrivate, ok := overflowSubnetCIDRs(cidrBlock, publicCount, privateCount); ok {
+					publicCIDRs = packedPublic
+					privateCIDRs = packedPrivate
 				}
 			}
 
@@ -131,7 +148,10 @@ func (b *ModelBuilder) BuildFromParsedEntities(entities map[string]interface{})
 
 			// Create Internet Gateway (typically just one)
 			if igwCount > 0 {
-				igwName := "main-igw"
+				igwName := DefaultIGWName
+				if vpcName != DefaultVPCName {
+					igwName = vpcName + "-igw"
+				}
 				igw := CreateInternetGateway(igwName, resourceIDs["vpc"])
 				b.AddResource(igw)
 				resourceIDs["igw"] = igwName
@@ -153,7 +173,7 @@ func (b *ModelBuilder) BuildFromParsedEntities(entities map[string]interface{})
 
 		// Create EKS Cluster if specified
 		if eksData, ok := entities["eks"].(map[string]interface{}); ok {
-			eksName := "main-eks-cluster"
+			eksName := entityName(eksData, "name", DefaultEKSClusterName)
 			eksVersion := "1.27"
 
 			if version, ok := eksData["version"].(string); ok {
@@ -186,17 +206,59 @@ func (b *ModelBuilder) BuildFromParsedEntities(entities map[string]interface{})
 			}
 
 			eks := CreateEKSCluster(eksName, eksVersion, roleArn, subnetIDs, endpointPublicAccess, endpointPrivateAccess)
+			// Without a service CIDR, AWS picks 10.100.0.0/16 or 172.20.0.0/16
+			if cidr, ok := eksData["service_cidr"].(string); ok && cidr != "" {
+				eks.AddProperty("kubernetes_network_config", map[string]interface{}{"service_ipv4_cidr": cidr})
+			}
+			// The nodes scale with the Cluster Autoscaler or with Karpenter, which provisions
+			// nodes for the cluster's pending pods alongside its node groups
+			autoscaling := eksAutoscaling(eksData)
+			if autoscaling != "" {
+				eks.AddProperty("autoscaling", autoscaling)
+			}
+			if autoscaling == AutoscalingKarpenter {
+				eks.AddProperty("karpenter", true)
+			}
+			// The controllers' IAM roles are assumed through the cluster's OIDC provider. The
+			// Cluster Autoscaler gets one when it scales the nodes, and none when Karpenter
+			// does, so the two do not scale the same nodes.
+			var controllers []string
+			clusterAutoscaler := false
+			if names, ok := eksData["controllers"].([]string); ok {
+				for _, name := range names {
+					if _, ok := LookupEKSController(name); !ok || (name == AutoscalingClusterAutoscaler && autoscaling == AutoscalingKarpenter) {
+						continue
+					}
+					clusterAutoscaler = clusterAutoscaler || name == AutoscalingClusterAutoscaler
+					controllers = append(controllers, name)
+				}
+			}
+			if autoscaling == AutoscalingClusterAutoscaler && !clusterAutoscaler {
+				controllers = append(controllers, AutoscalingClusterAutoscaler)
+			}
+			if len(controllers) > 0 {
+				eks.AddProperty("controllers", controllers)
+				eks.AddProperty("oidc_provider", true)
+			}
 			b.AddResource(eks)
 			resourceIDs["eks"] = eksName
 
 			// Create Node Group if EKS exists
-			nodeGroupName := "main-node-group"
+			nodeGroupName := DefaultNodeGroupName
+			if eksName != DefaultEKSClusterName {
+				nodeGroupName = eksName + "-node-group"
+			}
+			nodeGroupName = entityName(eksData, "node_group_name", nodeGroupName)
 			instanceType := "t3.medium"
 			nodeCount := 2
 
 			if instance, ok := eksData["instance_type"].(string); ok {
 				instanceType = instance
 			}
+			instanceTypes := []string{instanceType}
+			if types, ok := eksData["instance_types"].([]string); ok && len(types) > 0 {
+				instanceTypes = types
+			}
 
 			if count, ok := eksData["node_count"].(int); ok {
 				nodeCount = count
@@ -206,40 +268,42 @@ func (b *ModelBuilder) BuildFromParsedEntities(entities map[string]interface{})
 			// For simplicity, we're assuming the role already exists
 			nodeRoleArn := "arn:aws:iam::123456789012:role/eks-node-group-role"
 
-			nodeGroup := CreateEKSNodeGroup(
-				nodeGroupName,
-				eksName,
-				nodeRoleArn,
-				subnetIDs,
-				[]string{instanceType},
-				nodeCount,   // desired
-				nodeCount,   // min
-				nodeCount*2, // max
-			)
-			b.AddResource(nodeGroup)
-		}
-	}
-
-	// Handle EC2 instance if specified
-	if instanceData, ok := entities["ec2_instance"].(map[string]interface{}); ok {
-		name := "example-instance"
-		instanceType := "t2.micro"
-		ami := "ami-123456789"
+			if nodeGroups, ok := eksData["node_groups"].([]interface{}); ok && len(nodeGroups) > 0 {
+				b.buildNodeGroups(nodeGroups, nodeGroupName, eksName, nodeRoleArn, subnetIDs)
+			} else {
+				nodeGroup := CreateEKSNodeGroup(
+					nodeGroupName,
+					eksName,
+					nodeRoleArn,
+					subnetIDs,
+					instanceTypes,
+					nodeCount,   // desired
+					nodeCount,   // min
+					nodeCount*2, // max
+				)
+				b.AddResource(nodeGroup)
+			}
 
-		if instName, ok := instanceData["name"].(string); ok {
-			name = instName
-		}
+			if addons, ok := eksData["addons"].([]interface{}); ok {
+				b.buildEKSAddons(addons, eksName)
+			}
 
-		if instType, ok := instanceData["instance_type"].(string); ok {
-			instanceType = instType
+			if namespaces, ok := eksData["fargate_namespaces"].([]string); ok {
+				b.buildFargateProfiles(namespaces, eksName, subnetIDs)
+			}
 		}
 
-		if instAMI, ok := instanceData["ami"].(string); ok {
-			ami = instAMI
+		// Create Auto Scaling Group and its launch template if specified
+		if asgData, ok := entities["asg"].(map[string]interface{}); ok {
+			if err := b.buildAutoScalingGroup(asgData, resourceIDs); err != nil {
+				return err
+			}
 		}
+	}
 
-		instance := CreateEC2Instance(name, instanceType, ami, region)
-		b.AddResource(instance)
+	// Handle EC2 instances if specified
+	if instanceData, ok := entities["ec2_instance"].(map[string]interface{}); ok {
+		b.buildEC2Instances(instanceData, resourceIDs, region)
 	}
 
 	// Handle S3 bucket if specified
@@ -264,5 +328,334 @@ func (b *ModelBuilder) BuildFromParsedEntities(entities map[string]interface{})
 		b.AddResource(bucket)
 	}
 
+	// Copy the network into each secondary region
+	if secondaryRegions, ok := entities["secondary_regions"].([]string); ok {
+		for _, secondary := range secondaryRegions {
+			AddSecondaryRegion(b.model, secondary)
+		}
+	}
+
+	// Name and tag the resources after the environment they are for
+	if environment, ok := entities["environment"].(string); ok && ValidEnvironment(environment) {
+		ApplyEnvironment(b.model, environment)
+	}
+
+	return nil
+}
+
+// buildNodeGroups adds a node group for each node group entity. Each group is named after its
+// capacity type, such as main-node-group-spot, and numbered when several share one.
+func (b *ModelBuilder) buildNodeGroups(nodeGroups []interface{}, baseName, clusterName, nodeRoleArn string, subnetIDs []string) {
+	seen := make(map[string]int)
+	for _, entry := range nodeGroups {
+		data, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		capacityType := "ON_DEMAND"
+		if value, ok := data["capacity_type"].(string); ok && value != "" {
+			capacityType = strings.ToUpper(value)
+		}
+		instanceTypes := []string{"t3.medium"}
+		if value, ok := data["instance_types"].([]string); ok && len(value) > 0 {
+			instanceTypes = value
+		} else if value, ok := data["instance_type"].(string); ok && value != "" {
+			instanceTypes = []string{value}
+		}
+		desiredSize, minSize, maxSize := 2, 2, 4
+		if value, ok := data["desired_size"].(int); ok {
+			desiredSize = value
+		}
+		if value, ok := data["min_size"].(int); ok {
+			minSize = value
+		}
+		if value, ok := data["max_size"].(int); ok {
+			maxSize = value
+		}
+
+		suffix := strings.ToLower(strings.ReplaceAll(capacityType, "_", "-"))
+		seen[suffix]++
+		if seen[suffix] > 1 {
+			suffix += "-" + strconv.Itoa(seen[suffix])
+		}
+
+		nodeGroup := CreateEKSNodeGroup(
+			baseName+"-"+suffix,
+			clusterName,
+			nodeRoleArn,
+			subnetIDs,
+			instanceTypes,
+			desiredSize,
+			minSize,
+			maxSize,
+		)
+		nodeGroup.AddProperty("capacity_type", capacityType)
+		b.AddResource(nodeGroup)
+	}
+}
+
+// buildEKSAddons adds an EKS add-on for each add-on entity, named after the cluster and the
+// add-on, such as main-eks-cluster-coredns. Add-ons the generators do not know are skipped.
+// A cluster with an add-on that runs under an IAM role for service accounts gets the
+// "oidc_provider" property, since the role is assumed through the cluster's OIDC provider.
+func (b *ModelBuilder) buildEKSAddons(addons []interface{}, clusterName string) {
+	irsa := false
+	for _, entry := range addons {
+		data, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := data["name"].(string)
+		addon, ok := LookupEKSAddon(name)
+		if !ok {
+			continue
+		}
+		version, _ := data["version"].(string)
+		b.AddResource(CreateEKSAddon(clusterName+"-"+addon.Name, clusterName, addon, version))
+		irsa = irsa || addon.NeedsIRSA()
+	}
+	if !irsa {
+		return
+	}
+
+	for i := range b.model.Resources {
+		cluster := &b.model.Resources[i]
+		if cluster.Type == models.ResourceEKSCluster && cluster.Name == clusterName {
+			cluster.SetProperty("oidc_provider", true)
+		}
+	}
+}
+
+// buildFargateProfiles adds the Fargate profiles that run the pods of the given namespaces,
+// such as main-eks-cluster-fargate. EKS limits the namespaces of a profile, so a long list is
+// split across numbered profiles.
+func (b *ModelBuilder) buildFargateProfiles(namespaces []string, clusterName string, subnetIDs []string) {
+	for i := 0; i < len(namespaces); i += MaxFargateSelectors {
+		end := i + MaxFargateSelectors
+		if end > len(namespaces) {
+			end = len(namespaces)
+		}
+		name := clusterName + "-fargate"
+		if i > 0 {
+			name += "-" + strconv.Itoa(i/MaxFargateSelectors+1)
+		}
+		b.AddResource(CreateFargateProfile(name, clusterName, subnetIDs, namespaces[i:end]))
+	}
+}
+
+// buildAutoScalingGroup adds a launch template and an Auto Scaling Group for non-EKS compute
+func (b *ModelBuilder) buildAutoScalingGroup(asgData map[string]interface{}, resourceIDs map[string]string) error {
+	asgName := entityName(asgData, "name", DefaultASGName)
+	launchTemplateName := DefaultLaunchTemplateName
+	if asgName != DefaultASGName {
+		launchTemplateName = asgName + "-launch-template"
+	}
+	instanceType := "t3.micro"
+	ami := "ami-123456789"
+	desiredCapacity := 2
+
+	if instType, ok := asgData["instance_type"].(string); ok {
+		instanceType = instType
+	}
+
+	if asgAMI, ok := asgData["ami"].(string); ok {
+		ami = asgAMI
+	}
+
+	if count, ok := asgData["desired_capacity"].(int); ok {
+		desiredCapacity = count
+	}
+
+	minSize := desiredCapacity
+	maxSize := desiredCapacity * 2
+
+	if size, ok := asgData["min_size"].(int); ok {
+		minSize = size
+	}
+
+	if size, ok := asgData["max_size"].(int); ok {
+		maxSize = size
+	}
+
+	// Render user data from the requested packages
+	var packages []string
+	if pkgs, ok := asgData["user_data_packages"].([]string); ok {
+		packages = pkgs
+	}
+	userData, err := RenderUserData(DefaultUserDataTemplate, UserDataParams{
+		Name:     asgName,
+		Packages: packages,
+	})
+	if err != nil {
+		return err
+	}
+
+	launchTemplate := CreateLaunchTemplate(launchTemplateName, instanceType, ami, userData)
+	b.AddResource(launchTemplate)
+
+	// Place instances in private subnets, falling back to public ones
+	var subnetIDs []string
+	for _, prefix := range []string{"private-subnet-", "public-subnet-"} {
+		for i := 0; ; i++ {
+			subnetID, ok := resourceIDs[prefix+strconv.Itoa(i)]
+			if !ok {
+				break
+			}
+			subnetIDs = append(subnetIDs, subnetID)
+		}
+		if len(subnetIDs) > 0 {
+			break
+		}
+	}
+
+	asg := CreateAutoScalingGroup(asgName, launchTemplateName, subnetIDs, desiredCapacity, minSize, maxSize)
+
+	// Register instances with the load balancer target group when requested
+	if behindALB, ok := asgData["behind_alb"].(bool); ok && behindALB {
+		asg.SetProperty("health_check_type", "ELB")
+		asg.SetProperty("attach_to_alb", true)
+	}
+
+	minHealthy := 90
+	if percent, ok := asgData["instance_refresh_min_healthy"].(int); ok {
+		minHealthy = percent
+	}
+	SetInstanceRefresh(&asg, "Rolling", minHealthy)
+
+	b.AddResource(asg)
+	resourceIDs["asg"] = asgName
+
 	return nil
 }
+
+// buildEC2Instances adds standalone EC2 instances with an AMI lookup, a security group
+// and an optional key pair. Instances are spread across the subnets in the model.
+func (b *ModelBuilder) buildEC2Instances(instanceData map[string]interface{}, resourceIDs map[string]string, region string) {
+	baseName := DefaultInstanceName
+	instanceType := "t3.micro"
+	operatingSystem := OSAmazonLinux2023
+	count := 1
+
+	if instName, ok := instanceData["name"].(string); ok {
+		baseName = instName
+	}
+
+	if instType, ok := instanceData["instance_type"].(string); ok {
+		instanceType = instType
+	}
+
+	if os, ok := instanceData["os"].(string); ok {
+		operatingSystem = os
+	}
+
+	if instCount, ok := instanceData["count"].(int); ok && instCount > 0 {
+		count = instCount
+	}
+
+	// An explicit AMI wins over the lookup
+	lookup := LookupAMI(operatingSystem, instanceType)
+	ami := "resolve:ssm:" + lookup.SSMParameter
+	explicitAMI := false
+	if instAMI, ok := instanceData["ami"].(string); ok {
+		ami = instAMI
+		explicitAMI = true
+	}
+
+	keyName, _ := instanceData["key_name"].(string)
+	web, _ := instanceData["web"].(bool)
+	public, _ := instanceData["public"].(bool)
+
+	// Instances go in private subnets unless they were asked to be public
+	var subnetIDs []string
+	prefixes := []string{"private-subnet-", "public-subnet-"}
+	if public {
+		prefixes = []string{"public-subnet-", "private-subnet-"}
+	}
+	for _, prefix := range prefixes {
+		for i := 0; ; i++ {
+			subnetID, ok := resourceIDs[prefix+strconv.Itoa(i)]
+			if !ok {
+				break
+			}
+			subnetIDs = append(subnetIDs, subnetID)
+		}
+		if len(subnetIDs) > 0 {
+			break
+		}
+	}
+
+	// The security group only exists when there is a VPC to put it in
+	securityGroupName := ""
+	if vpcName, ok := resourceIDs["vpc"]; ok {
+		securityGroupName = baseName + "-sg"
+		securityGroup := CreateSecurityGroup(securityGroupName, "Security group for "+baseName, vpcName)
+
+		if keyName != "" {
+			sshCIDR := "10.0.0.0/16"
+			if cidr, ok := instanceData["ssh_cidr"].(string); ok {
+				sshCIDR = cidr
+			} else {
+				// Default to SSH from inside the VPC
+				for _, resource := range b.model.Resources {
+					if resource.Type != models.ResourceVPC || resource.Name != vpcName {
+						continue
+					}
+					if cidr, ok := resource.GetProperty("cidr_block"); ok {
+						if str, ok := cidr.(string); ok {
+							sshCIDR = str
+						}
+					}
+				}
+			}
+			AddSecurityGroupRule(&securityGroup, "ingress", "tcp", 22, 22, []string{sshCIDR})
+		}
+
+		if web {
+			AddSecurityGroupRule(&securityGroup, "ingress", "tcp", 80, 80, []string{"0.0.0.0/0"})
+			AddSecurityGroupRule(&securityGroup, "ingress", "tcp", 443, 443, []string{"0.0.0.0/0"})
+		}
+
+		AddSecurityGroupRule(&securityGroup, "egress", "-1", 0, 0, []string{"0.0.0.0/0"})
+		b.AddResource(securityGroup)
+		resourceIDs["ec2-sg"] = securityGroupName
+	}
+
+	for i := 0; i < count; i++ {
+		name := baseName
+		if count > 1 {
+			name = baseName + "-" + strconv.Itoa(i+1)
+		}
+
+		instance := CreateEC2Instance(name, instanceType, ami, region)
+		if !explicitAMI {
+			instance.AddProperty("ami_lookup", lookup.Properties())
+		}
+
+		if len(subnetIDs) > 0 {
+			subnetID := subnetIDs[i%len(subnetIDs)]
+			instance.AddProperty("subnet_id", subnetID)
+			instance.AddDependency(subnetID)
+		}
+
+		if securityGroupName != "" {
+			instance.AddProperty("vpc_security_group_ids", []string{securityGroupName})
+			instance.AddDependency(securityGroupName)
+		}
+
+		if keyName != "" {
+			instance.AddProperty("key_name", keyName)
+		}
+
+		if public {
+			instance.AddProperty("associate_public_ip_address", true)
+		}
+
+		// Require IMDSv2 session tokens
+		instance.AddProperty("metadata_options", map[string]interface{}{
+			"http_tokens": "required",
+		})
+
+		b.AddResource(instance)
+	}
+}
@@ -0,0 +1,205 @@
+package infra
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// Default names for resources the description does not name
+const (
+	DefaultVPCName            = "main-vpc"
+	DefaultIGWName            = "main-igw"
+	DefaultEKSClusterName     = "main-eks-cluster"
+	DefaultNodeGroupName      = "main-node-group"
+	DefaultASGName            = "main-asg"
+	DefaultLaunchTemplateName = "main-launch-template"
+	DefaultInstanceName       = "main-instance"
+)
+
+// resourceNamePattern matches names that are valid for every supported resource type:
+// lower-case letters, digits and hyphens, starting with a letter and not ending in a hyphen.
+// EKS cluster names allow up to 100 characters, but IAM role names derived from them do not.
+var resourceNamePattern = regexp.MustCompile(`^[a-z]([a-z0-9-]{0,38}[a-z0-9])?$`)
+
+// ValidResourceName reports whether a user-given resource name can be used as is
+func ValidResourceName(name string) bool {
+	return resourceNamePattern.MatchString(name)
+}
+
+// namespacePattern matches Kubernetes namespace names: DNS labels of up to 63 characters
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// ValidNamespace reports whether a name is a valid Kubernetes namespace
+func ValidNamespace(name string) bool {
+	return namespacePattern.MatchString(name)
+}
+
+// entityName returns the user-given name of an entity, or the default
+func entityName(entity map[string]interface{}, field, defaultName string) string {
+	if name, ok := entity[field].(string); ok && name != "" {
+		return name
+	}
+	return defaultName
+}
+
+// defaultNamePrefix starts the default names, which a naming policy drops
+const defaultNamePrefix = "main-"
+
+// Cases of the values a naming pattern is filled with
+const (
+	// NamingCaseKebab joins the words of a value with hyphens, such as order-service
+	NamingCaseKebab = "kebab"
+	// NamingCaseLower joins the words of a value without separators, such as orderservice
+	NamingCaseLower = "lower"
+)
+
+// NamingCases lists the supported naming cases
+var NamingCases = []string{NamingCaseKebab, NamingCaseLower}
+
+// namingPlaceholders are the placeholders of a naming pattern
+var namingPlaceholders = []string{"{env}", "{app}", "{region}", "{resource}"}
+
+// namingPlaceholderPattern matches the placeholders of a naming pattern, and
+// namingLiteralPattern the text around them
+var (
+	namingPlaceholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+	namingLiteralPattern     = regexp.MustCompile(`^[a-z0-9-]*$`)
+)
+
+// NamingPolicy names the resources of a model after a pattern, such as
+// "{env}-{app}-{resource}". The placeholders are filled with the environment of the model, the
+// application name, the region, and the name the model builder gave the resource without its
+// main- prefix, such as vpc or public-subnet-1. Case is how the words of each value are
+// joined (kebab or lower; empty means kebab). The zero policy keeps the builder's names.
+type NamingPolicy struct {
+	Pattern string
+	App     string
+	Case    string
+}
+
+// IsZero reports whether the policy keeps the names the model builder gives
+func (p NamingPolicy) IsZero() bool {
+	return p.Pattern == ""
+}
+
+// Validate checks the pattern, application name and case of a naming policy
+func (p NamingPolicy) Validate() error {
+	if p.Case != "" && !containsString(NamingCases, strings.ToLower(p.Case)) {
+		return fmt.Errorf("invalid naming case: %s (supported cases: %s)", p.Case, strings.Join(NamingCases, ", "))
+	}
+	if p.IsZero() {
+		return nil
+	}
+
+	if strings.Count(p.Pattern, "{resource}") != 1 {
+		return fmt.Errorf("invalid naming pattern: %s (it must contain {resource} once, so each resource gets a name of its own)", p.Pattern)
+	}
+	for _, placeholder := range namingPlaceholderPattern.FindAllString(p.Pattern, -1) {
+		if !containsString(namingPlaceholders, placeholder) {
+			return fmt.Errorf("invalid naming pattern: %s (unknown placeholder %s; use %s)", p.Pattern, placeholder, strings.Join(namingPlaceholders, ", "))
+		}
+	}
+	if !namingLiteralPattern.MatchString(namingPlaceholderPattern.ReplaceAllString(p.Pattern, "")) {
+		return fmt.Errorf("invalid naming pattern: %s (use lower-case letters, digits and hyphens around the placeholders)", p.Pattern)
+	}
+	if strings.Contains(p.Pattern, "{app}") && len(namingWords(p.App)) == 0 {
+		return fmt.Errorf("the naming pattern %s uses {app}, but no application name is set", p.Pattern)
+	}
+	return nil
+}
+
+// affixes returns the text the policy places before and after the name of a resource
+func (p NamingPolicy) affixes(environment, region string) (prefix, suffix string) {
+	values := map[string]string{
+		"{env}":    environment,
+		"{app}":    p.App,
+		"{region}": region,
+	}
+	render := func(pattern string) string {
+		text := namingPlaceholderPattern.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+			return p.join(namingWords(values[placeholder]))
+		})
+		// Empty values leave their separators behind
+		for strings.Contains(text, "--") {
+			text = strings.ReplaceAll(text, "--", "-")
+		}
+		return text
+	}
+	before, after, _ := strings.Cut(p.Pattern, "{resource}")
+	return strings.TrimLeft(render(before), "-"), strings.TrimRight(render(after), "-")
+}
+
+// join joins the words of a value in the policy's case
+func (p NamingPolicy) join(words []string) string {
+	if strings.EqualFold(p.Case, NamingCaseLower) {
+		return strings.Join(words, "")
+	}
+	return strings.Join(words, "-")
+}
+
+// namingWords splits a value into lower-case words at separators and case changes, such as
+// OrderService or order_service into order and service
+func namingWords(value string) []string {
+	var buf strings.Builder
+	runes := []rune(value)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+			buf.WriteRune(' ')
+		}
+		buf.WriteRune(unicode.ToLower(r))
+	}
+	return strings.FieldsFunc(buf.String(), func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+	})
+}
+
+// ApplyNamingPolicy renames the resources of a model after a naming policy, and the
+// references to them. When the pattern places the environment, it replaces the environment
+// suffix the names were given. Names the policy already gave, such as those of a saved model,
+// are kept. It fails when two resources would get the same name.
+func ApplyNamingPolicy(model *models.InfrastructureModel, policy NamingPolicy) error {
+	if model == nil || policy.IsZero() {
+		return nil
+	}
+
+	prefix, suffix := policy.affixes(model.Environment, model.Region)
+	placesEnvironment := strings.Contains(policy.Pattern, "{env}")
+	renamed := make(map[string]string, len(model.Resources))
+	owners := make(map[string]string, len(model.Resources))
+	for _, resource := range model.Resources {
+		base := resource.Name
+		if len(base) > len(prefix)+len(suffix) && strings.HasPrefix(base, prefix) && strings.HasSuffix(base, suffix) {
+			base = strings.TrimSuffix(strings.TrimPrefix(base, prefix), suffix)
+		} else if placesEnvironment && model.Environment != "" {
+			base = strings.TrimSuffix(base, "-"+model.Environment)
+		}
+		base = strings.TrimPrefix(base, defaultNamePrefix)
+
+		name := strings.Trim(prefix+policy.join(namingWords(base))+suffix, "-")
+		if name == "" || !unicode.IsLetter(rune(name[0])) {
+			return fmt.Errorf("the naming pattern %s gives %s the name %q, which does not start with a letter", policy.Pattern, resource.Name, name)
+		}
+		if owner, ok := owners[name]; ok && owner != resource.Name {
+			return fmt.Errorf("the naming pattern %s gives %s and %s the same name: %s", policy.Pattern, owner, resource.Name, name)
+		}
+		owners[name] = resource.Name
+		renamed[resource.Name] = name
+	}
+
+	renameResources(model, renamed)
+	return nil
+}
+
+// containsString reports whether a list contains a value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
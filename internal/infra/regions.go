@@ -0,0 +1,78 @@
+package infra
+
+import (
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// networkTypes are the resource types copied into a secondary region. Clusters and compute
+// stay in the primary region; the secondary network is ready for them to be added.
+var networkTypes = map[models.ResourceType]bool{
+	models.ResourceVPC:        true,
+	models.ResourceSubnet:     true,
+	models.ResourceIGW:        true,
+	models.ResourceNATGateway: true,
+}
+
+// RegionSuffix returns the suffix appended to the names of resources copied into a region
+func RegionSuffix(region string) string {
+	return "-" + region
+}
+
+// AddSecondaryRegion copies the network of a model into another region, such as the
+// us-west-2 VPC of "a VPC in us-east-1 and a DR VPC in us-west-2". The copies are named
+// with RegionSuffix and carry the region in their "region" property. Resources already in
+// the model get the primary region, so every resource of a multi-region model names its
+// region.
+func AddSecondaryRegion(model *models.InfrastructureModel, region string) {
+	if region == "" || region == model.Region {
+		return
+	}
+
+	renamed := make(map[string]string)
+	var network []models.Resource
+	for i := range model.Resources {
+		resource := &model.Resources[i]
+		if _, ok := resource.GetProperty("region"); !ok && model.Region != "" {
+			resource.AddProperty("region", model.Region)
+		}
+		if networkTypes[resource.Type] && model.ResourceRegion(resource) == model.Region {
+			renamed[resource.Name] = resource.Name + RegionSuffix(region)
+			network = append(network, *resource)
+		}
+	}
+
+	for _, resource := range network {
+		model.AddResource(copyResourceToRegion(resource, renamed, region))
+	}
+}
+
+// MoveToRegion moves the resources of a model's primary region into another region, such as
+// the configured region of a description that names none. Their availability zones and
+// "region" properties follow them; the resources of secondary regions stay where they are.
+// A model is not moved into one of its secondary regions.
+func MoveToRegion(model *models.InfrastructureModel, region string) {
+	if region == "" || region == model.Region {
+		return
+	}
+	for _, secondary := range model.SecondaryRegions() {
+		if secondary == region {
+			return
+		}
+	}
+
+	for i := range model.Resources {
+		resource := &model.Resources[i]
+		if model.ResourceRegion(resource) != model.Region {
+			continue
+		}
+		if value, ok := resource.GetProperty("availability_zone"); ok {
+			if az, ok := value.(string); ok {
+				resource.SetProperty("availability_zone", regionalAvailabilityZone(az, region))
+			}
+		}
+		if _, ok := resource.GetProperty("region"); ok {
+			resource.SetProperty("region", region)
+		}
+	}
+	model.Region = region
+}
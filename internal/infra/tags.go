@@ -0,0 +1,94 @@
+package infra
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// Tag keys and values may use letters, digits, spaces and the characters _ . : / = + - @,
+// which is the set every AWS service accepts
+var (
+	tagKeyPattern   = regexp.MustCompile(`^[\p{L}\p{N}_.:/=+\-@ ]{1,128}$`)
+	tagValuePattern = regexp.MustCompile(`^[\p{L}\p{N}_.:/=+\-@ ]{0,256}$`)
+)
+
+// CheckTag returns an error when a global tag cannot be applied to every resource. The
+// "Name" tag is set per resource from the resource name, and "aws:" keys are reserved.
+func CheckTag(key, value string) error {
+	switch {
+	case !tagKeyPattern.MatchString(key):
+		return fmt.Errorf("tag key %q must be 1-128 letters, digits, spaces or _.:/=+-@", key)
+	case key == "Name":
+		return fmt.Errorf("tag key %q is set from each resource's name; name the resource instead", key)
+	case strings.HasPrefix(strings.ToLower(key), "aws:"):
+		return fmt.Errorf("tag key %q uses the reserved aws: prefix", key)
+	case !tagValuePattern.MatchString(value):
+		return fmt.Errorf("tag %s value %q must be up to 256 letters, digits, spaces or _.:/=+-@", key, value)
+	}
+	return nil
+}
+
+// entityTags converts a tags entity to the model's tag set, skipping invalid tags
+func entityTags(entity map[string]interface{}) map[string]string {
+	tags := make(map[string]string, len(entity))
+	for key, value := range entity {
+		text := fmt.Sprintf("%v", value)
+		if CheckTag(key, text) == nil {
+			tags[key] = text
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
+// CheckRequiredTag returns an error when a tag cannot be required of every model, such as a
+// reserved one
+func CheckRequiredTag(key string) error {
+	return CheckTag(key, "")
+}
+
+// MergeTags returns the tags of a model: defaults, such as those of the config file, under the
+// tags the model has, such as those the description states, under overrides, such as those
+// given for one run
+func MergeTags(defaults, tags, overrides map[string]string) map[string]string {
+	if len(defaults) == 0 && len(overrides) == 0 {
+		return tags
+	}
+	merged := make(map[string]string, len(defaults)+len(tags)+len(overrides))
+	for _, set := range []map[string]string{defaults, tags, overrides} {
+		for key, value := range set {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// MissingTags returns the required tags a tag set lacks or leaves empty, in the order they are
+// required
+func MissingTags(tags map[string]string, required []string) []string {
+	var missing []string
+	for _, key := range required {
+		if strings.TrimSpace(tags[key]) == "" {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+// CheckRequiredTags returns an error naming the required tags a model lacks. Both output
+// formats apply the model's tags to every resource, so a model with them is tagged with them
+// throughout.
+func CheckRequiredTags(model *models.InfrastructureModel, required []string) error {
+	if model == nil {
+		return nil
+	}
+	if missing := MissingTags(model.Tags, required); len(missing) > 0 {
+		return fmt.Errorf("missing required tags: %s (set them under tags in the config file, with --tag or in the description)", strings.Join(missing, ", "))
+	}
+	return nil
+}
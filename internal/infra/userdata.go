@@ -0,0 +1,45 @@
+package infra
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// DefaultUserDataTemplate is the cloud-init script used for launch templates
+const DefaultUserDataTemplate = `#!/bin/bash
+set -euo pipefail
+
+# Generated for {{ .Name }}
+{{- if .Packages }}
+dnf install -y {{ range $i, $pkg := .Packages }}{{ if $i }} {{ end }}{{ $pkg }}{{ end }}
+{{- range .Packages }}
+systemctl enable --now {{ . }} || true
+{{- end }}
+{{- end }}
+`
+
+// UserDataParams holds the values available to user data templates
+type UserDataParams struct {
+	Name     string
+	Packages []string
+}
+
+// RenderUserData renders a user data template with the given parameters
+func RenderUserData(tmplStr string, params UserDataParams) (string, error) {
+	if tmplStr == "" {
+		tmplStr = DefaultUserDataTemplate
+	}
+
+	tmpl, err := template.New("user_data").Parse(tmplStr)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse user data template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return "", fmt.Errorf("failed to render user data template: %w", err)
+	}
+
+	return buf.String(), nil
+}
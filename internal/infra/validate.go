@@ -0,0 +1,539 @@
+package infra
+
+import (
+	"fmt"
+	"math/bits"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// MinEKSAvailabilityZones is the number of availability zones the subnets of an EKS cluster
+// must span; AWS rejects clusters with fewer
+const MinEKSAvailabilityZones = 2
+
+// ModelError is a problem between the resources of a model, with how to fix it
+type ModelError struct {
+	Resource string
+	Problem  string
+	Fix      string
+}
+
+// Error returns the problem and its fix
+func (e ModelError) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", e.Resource, e.Problem, e.Fix)
+}
+
+// ModelErrors are all the problems a ModelValidator finds in a model
+type ModelErrors []ModelError
+
+// Error lists the problems, one per line
+func (e ModelErrors) Error() string {
+	lines := []string{"the model is not valid:"}
+	for _, err := range e {
+		lines = append(lines, "  - "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ModelValidator checks what the Validate methods of single resources cannot: that the
+// subnets of a VPC fit in it and do not overlap, that the service CIDR of an EKS cluster does
+// not collide with its network, that EKS clusters have subnets in two availability zones and
+// a Kubernetes version EKS supports, that node groups and add-ons are compatible with the
+// version and instance types they run on, that NAT gateways are in public subnets, and that
+// node groups, add-ons and Fargate profiles belong to a cluster of the model
+type ModelValidator struct{}
+
+// NewModelValidator creates a new ModelValidator
+func NewModelValidator() *ModelValidator {
+	return &ModelValidator{}
+}
+
+// Validate returns the problems of the model as ModelErrors, or nil when it has none
+func (v *ModelValidator) Validate(model *models.InfrastructureModel) error {
+	var errs ModelErrors
+	errs = append(errs, v.checkSubnetCIDRs(model)...)
+	errs = append(errs, v.checkSubnetCapacity(model)...)
+	errs = append(errs, v.checkServiceCIDRs(model)...)
+	errs = append(errs, v.checkEKSSubnets(model)...)
+	errs = append(errs, v.checkEKSVersions(model)...)
+	errs = append(errs, v.checkNodeGroups(model)...)
+	errs = append(errs, v.checkEKSAddons(model)...)
+	errs = append(errs, v.checkNATGateways(model)...)
+	errs = append(errs, v.checkClusterReferences(model)...)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// checkSubnetCIDRs reports the subnets of a VPC whose CIDR blocks overlap. The subnets of the
+// copies of a VPC in other regions are compared among themselves.
+func (v *ModelValidator) checkSubnetCIDRs(model *models.InfrastructureModel) []ModelError {
+	type subnetCIDR struct {
+		name    string
+		cidr    string
+		network *net.IPNet
+	}
+	var errs []ModelError
+	seen := make(map[string][]subnetCIDR)
+	for _, subnet := range resourcesOfType(model, models.ResourceSubnet) {
+		cidr := stringProperty(subnet, "cidr_block")
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		vpc := stringProperty(subnet, "vpc_id") + "/" + model.ResourceRegion(&subnet)
+		for _, other := range seen[vpc] {
+			if network.Contains(other.network.IP) || other.network.Contains(network.IP) {
+				errs = append(errs, ModelError{
+					Resource: "subnet " + subnet.Name,
+					Problem:  fmt.Sprintf("its CIDR block %s overlaps %s of subnet %s", cidr, other.cidr, other.name),
+					Fix:      fmt.Sprintf("give each subnet of %s a CIDR block of its own", stringProperty(subnet, "vpc_id")),
+				})
+			}
+		}
+		seen[vpc] = append(seen[vpc], subnetCIDR{name: subnet.Name, cidr: cidr, network: network})
+	}
+	return errs
+}
+
+// checkSubnetCapacity reports the subnets whose CIDR blocks do not fit in the CIDR block of
+// their VPC. When the subnets of the VPC need more addresses than it has, the fix gives the VPC
+// size they need. Subnets of VPCs the model does not have are not checked.
+func (v *ModelValidator) checkSubnetCapacity(model *models.InfrastructureModel) []ModelError {
+	vpcs := resourcesByName(model, models.ResourceVPC)
+	subnets := resourcesOfType(model, models.ResourceSubnet)
+
+	// The addresses the subnets of each VPC need, to size the VPC they would fit in
+	needed := make(map[string]uint64)
+	for _, subnet := range subnets {
+		if _, network, err := net.ParseCIDR(stringProperty(subnet, "cidr_block")); err == nil {
+			ones, size := network.Mask.Size()
+			needed[stringProperty(subnet, "vpc_id")] += uint64(1) << uint(size-ones)
+		}
+	}
+
+	var errs []ModelError
+	for _, subnet := range subnets {
+		vpcName := stringProperty(subnet, "vpc_id")
+		vpc, ok := vpcs[vpcName]
+		if !ok {
+			continue
+		}
+		vpcCIDR := stringProperty(vpc, "cidr_block")
+		_, vpcNetwork, err := net.ParseCIDR(vpcCIDR)
+		if err != nil {
+			continue
+		}
+		cidr := stringProperty(subnet, "cidr_block")
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil || cidrContains(vpcNetwork, network) {
+			continue
+		}
+
+		fix := fmt.Sprintf("give it a CIDR block within %s", vpcCIDR)
+		vpcOnes, size := vpcNetwork.Mask.Size()
+		if needed[vpcName] > uint64(1)<<uint(size-vpcOnes) {
+			fix = fmt.Sprintf("the subnets of %s need a /%d VPC or larger; use a larger VPC CIDR, or fewer or smaller subnets", vpcName, size-bits.Len64(needed[vpcName]-1))
+		}
+		errs = append(errs, ModelError{
+			Resource: "subnet " + subnet.Name,
+			Problem:  fmt.Sprintf("its CIDR block %s does not fit in %s of VPC %s", cidr, vpcCIDR, vpcName),
+			Fix:      fix,
+		})
+	}
+	return errs
+}
+
+// checkServiceCIDRs reports the EKS clusters whose service CIDR AWS would reject: one that is
+// not a private range of /12 to /24, or that overlaps the VPC or a subnet of the cluster, where
+// pods could not reach the nodes or services with clashing addresses
+func (v *ModelValidator) checkServiceCIDRs(model *models.InfrastructureModel) []ModelError {
+	vpcs := resourcesByName(model, models.ResourceVPC)
+	subnets := resourcesByName(model, models.ResourceSubnet)
+	var errs []ModelError
+	for _, cluster := range resourcesOfType(model, models.ResourceEKSCluster) {
+		cidr := ServiceCIDR(cluster)
+		if cidr == "" {
+			continue
+		}
+		resource := "EKS cluster " + cluster.Name
+		if err := ValidateServiceCIDR(cidr); err != nil {
+			errs = append(errs, ModelError{
+				Resource: resource,
+				Problem:  "its service CIDR " + err.Error(),
+				Fix:      "use a /12 to /24 block of 10.0.0.0/8, 172.16.0.0/12 or 192.168.0.0/16",
+			})
+			continue
+		}
+		_, service, _ := net.ParseCIDR(cidr)
+
+		// The cluster's network is the VPCs of its subnets and the subnets themselves, or
+		// every VPC of its region when it has no subnets of the model
+		var network []models.Resource
+		seen := make(map[string]bool)
+		var subnetIDs []string
+		if value, ok := cluster.GetProperty("vpc_config"); ok {
+			if vpcConfig, ok := value.(map[string]interface{}); ok {
+				subnetIDs = stringValues(vpcConfig["subnet_ids"])
+			}
+		}
+		for _, id := range subnetIDs {
+			subnet, ok := subnets[id]
+			if !ok {
+				continue
+			}
+			if vpc, ok := vpcs[stringProperty(subnet, "vpc_id")]; ok && !seen[vpc.Name] {
+				seen[vpc.Name] = true
+				network = append(network, vpc)
+			}
+			network = append(network, subnet)
+		}
+		if len(network) == 0 {
+			for _, vpc := range resourcesOfType(model, models.ResourceVPC) {
+				if model.ResourceRegion(&vpc) == model.ResourceRegion(&cluster) {
+					network = append(network, vpc)
+				}
+			}
+		}
+
+		for _, member := range network {
+			other := stringProperty(member, "cidr_block")
+			_, otherNetwork, err := net.ParseCIDR(other)
+			if err != nil || !(service.Contains(otherNetwork.IP) || otherNetwork.Contains(service.IP)) {
+				continue
+			}
+			kind := "VPC"
+			if member.Type == models.ResourceSubnet {
+				kind = "subnet"
+			}
+			errs = append(errs, ModelError{
+				Resource: resource,
+				Problem:  fmt.Sprintf("its service CIDR %s overlaps %s of %s %s", cidr, other, kind, member.Name),
+				Fix:      "give the cluster a service CIDR outside its VPC, such as " + suggestedServiceCIDR(otherNetwork),
+			})
+			break
+		}
+	}
+	return errs
+}
+
+// checkEKSSubnets reports the EKS clusters whose subnets do not span two availability zones.
+// Subnets the model does not have, such as existing ones referenced by ID, are assumed to be
+// in zones of their own.
+func (v *ModelValidator) checkEKSSubnets(model *models.InfrastructureModel) []ModelError {
+	subnets := resourcesByName(model, models.ResourceSubnet)
+	var errs []ModelError
+	for _, cluster := range resourcesOfType(model, models.ResourceEKSCluster) {
+		var subnetIDs []string
+		if value, ok := cluster.GetProperty("vpc_config"); ok {
+			if vpcConfig, ok := value.(map[string]interface{}); ok {
+				subnetIDs = stringValues(vpcConfig["subnet_ids"])
+			}
+		}
+
+		zones := make(map[string]bool)
+		for _, id := range subnetIDs {
+			zone := id
+			if subnet, ok := subnets[id]; ok {
+				zone = stringProperty(subnet, "availability_zone")
+			}
+			zones[zone] = true
+		}
+		if len(zones) >= MinEKSAvailabilityZones {
+			continue
+		}
+
+		problem := fmt.Sprintf("it has %d subnets, but EKS needs subnets in at least %d availability zones", len(subnetIDs), MinEKSAvailabilityZones)
+		if len(subnetIDs) == 1 {
+			problem = fmt.Sprintf("it has 1 subnet, but EKS needs subnets in at least %d availability zones", MinEKSAvailabilityZones)
+		} else if len(subnetIDs) > 1 {
+			problem = fmt.Sprintf("its subnets %s are all in one availability zone, but EKS needs at least %d", strings.Join(subnetIDs, ", "), MinEKSAvailabilityZones)
+		}
+		errs = append(errs, ModelError{
+			Resource: "EKS cluster " + cluster.Name,
+			Problem:  problem,
+			Fix:      "describe private subnets in two or more availability zones, such as \"2 private subnets across 2 AZs\"",
+		})
+	}
+	return errs
+}
+
+// checkEKSVersions reports the EKS clusters whose Kubernetes version EKS does not create
+// clusters with, such as 1.19
+func (v *ModelValidator) checkEKSVersions(model *models.InfrastructureModel) []ModelError {
+	var errs []ModelError
+	for _, cluster := range resourcesOfType(model, models.ResourceEKSCluster) {
+		version := stringProperty(cluster, "version")
+		if version == "" || ValidateEKSVersion(version) == nil {
+			continue
+		}
+		errs = append(errs, ModelError{
+			Resource: "EKS cluster " + cluster.Name,
+			Problem:  fmt.Sprintf("its Kubernetes version %s is not one EKS creates clusters with", version),
+			Fix:      fmt.Sprintf("use %s to %s, such as \"EKS cluster version %s\"", eksVersions[0], LatestEKSVersion(), LatestEKSVersion()),
+		})
+	}
+	return errs
+}
+
+// checkNodeGroups reports the node groups EKS would reject: ones whose instance types mix
+// architectures, whose AMI type does not run on their instance types, or whose Kubernetes
+// version is newer than their cluster's or more than three minor versions older
+func (v *ModelValidator) checkNodeGroups(model *models.InfrastructureModel) []ModelError {
+	clusters := resourcesByName(model, models.ResourceEKSCluster)
+	var errs []ModelError
+	for _, group := range resourcesOfType(model, models.ResourceNodeGroup) {
+		resource := "node group " + group.Name
+		value, _ := group.GetProperty("instance_types")
+		instanceTypes := stringValues(value)
+		clusterVersion := stringProperty(clusters[stringProperty(group, "cluster_name")], "version")
+
+		var arm, x86 []string
+		for _, instanceType := range instanceTypes {
+			if isGravitonInstanceType(instanceType) {
+				arm = append(arm, instanceType)
+			} else {
+				x86 = append(x86, instanceType)
+			}
+		}
+		if len(arm) > 0 && len(x86) > 0 {
+			errs = append(errs, ModelError{
+				Resource: resource,
+				Problem:  fmt.Sprintf("its instance types mix arm64 (%s) and x86_64 (%s), but a node group runs one AMI", strings.Join(arm, ", "), strings.Join(x86, ", ")),
+				Fix:      "use instance types of one architecture, or a node group for each",
+			})
+		} else if amiType := stringProperty(group, "ami_type"); amiType != "" {
+			if problem := checkEKSAMIType(amiType, instanceTypes); problem != "" {
+				errs = append(errs, ModelError{
+					Resource: resource,
+					Problem:  problem,
+					Fix:      fmt.Sprintf("use instance types the AMI type runs on, or an AMI type such as %s", DefaultEKSAMIType(clusterVersion, instanceTypes)),
+				})
+			}
+		}
+
+		version := stringProperty(group, "version")
+		groupMinor, ok := kubernetesMinor(version)
+		clusterMinor, clusterOK := kubernetesMinor(clusterVersion)
+		if !ok || !clusterOK {
+			continue
+		}
+		if groupMinor > clusterMinor || groupMinor < clusterMinor-3 {
+			errs = append(errs, ModelError{
+				Resource: resource,
+				Problem:  fmt.Sprintf("its Kubernetes version %s is not within three minor versions before %s of its cluster", version, clusterVersion),
+				Fix:      "use the version of its cluster, or leave it unset to follow the cluster",
+			})
+		}
+	}
+	return errs
+}
+
+// checkEKSAddons reports the pinned add-on versions that are not for the Kubernetes version of
+// their cluster. Only kube-proxy names the version it is for; the other add-ons support a range
+// of versions that EKS checks when it installs them.
+func (v *ModelValidator) checkEKSAddons(model *models.InfrastructureModel) []ModelError {
+	clusters := resourcesByName(model, models.ResourceEKSCluster)
+	var errs []ModelError
+	for _, addon := range resourcesOfType(model, models.ResourceEKSAddon) {
+		if stringProperty(addon, "addon_name") != "kube-proxy" {
+			continue
+		}
+		version := stringProperty(addon, "addon_version")
+		clusterName := stringProperty(addon, "cluster_name")
+		clusterVersion := stringProperty(clusters[clusterName], "version")
+		addonMinor, ok := kubernetesMinor(version)
+		clusterMinor, clusterOK := kubernetesMinor(clusterVersion)
+		if !ok || !clusterOK || addonMinor == clusterMinor {
+			continue
+		}
+		fix := "pin a kube-proxy version for Kubernetes " + clusterVersion + ", or leave it unpinned"
+		if compatible, ok := CompatibleEKSAddonVersion(clusterVersion, "kube-proxy"); ok {
+			fix = "pin it to " + compatible + ", or leave it unpinned"
+		}
+		errs = append(errs, ModelError{
+			Resource: "EKS add-on " + addon.Name,
+			Problem:  fmt.Sprintf("its version %s is for Kubernetes 1.%d, but its cluster %s runs %s", version, addonMinor, clusterName, clusterVersion),
+			Fix:      fix,
+		})
+	}
+	return errs
+}
+
+// checkNATGateways reports the public NAT gateways that are not in a public subnet of the model
+func (v *ModelValidator) checkNATGateways(model *models.InfrastructureModel) []ModelError {
+	subnets := resourcesByName(model, models.ResourceSubnet)
+	var errs []ModelError
+	for _, nat := range resourcesOfType(model, models.ResourceNATGateway) {
+		if stringProperty(nat, "connectivity_type") == "private" {
+			continue
+		}
+		subnetID := stringProperty(nat, "subnet_id")
+		subnet, ok := subnets[subnetID]
+		switch {
+		case subnetID == "":
+			errs = append(errs, ModelError{
+				Resource: "NAT gateway " + nat.Name,
+				Problem:  "it is in no subnet",
+				Fix:      "describe at least as many public subnets as NAT gateways",
+			})
+		case ok && !IsPublicSubnet(subnet):
+			errs = append(errs, ModelError{
+				Resource: "NAT gateway " + nat.Name,
+				Problem:  fmt.Sprintf("it is in the private subnet %s, where it cannot reach the internet", subnetID),
+				Fix:      "place it in a public subnet",
+			})
+		}
+	}
+	return errs
+}
+
+// checkClusterReferences reports the node groups, add-ons and Fargate profiles whose cluster
+// is not in the model
+func (v *ModelValidator) checkClusterReferences(model *models.InfrastructureModel) []ModelError {
+	clusters := resourcesByName(model, models.ResourceEKSCluster)
+	var names []string
+	for name := range clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fix := "describe the EKS cluster it belongs to"
+	if len(names) > 0 {
+		fix = "set its cluster to one of " + strings.Join(names, ", ")
+	}
+
+	kinds := map[models.ResourceType]string{
+		models.ResourceNodeGroup:      "node group",
+		models.ResourceEKSAddon:       "EKS add-on",
+		models.ResourceFargateProfile: "Fargate profile",
+	}
+	var errs []ModelError
+	for _, resource := range model.Resources {
+		kind, ok := kinds[resource.Type]
+		if !ok {
+			continue
+		}
+		cluster := stringProperty(resource, "cluster_name")
+		if _, ok := clusters[cluster]; ok {
+			continue
+		}
+		problem := fmt.Sprintf("its cluster %s is not in the model", cluster)
+		if cluster == "" {
+			problem = "it names no cluster"
+		}
+		errs = append(errs, ModelError{Resource: kind + " " + resource.Name, Problem: problem, Fix: fix})
+	}
+	return errs
+}
+
+// IsPublicSubnet reports whether a subnet is public: whether it maps public IPs on launch or,
+// without that setting, whether its name says so
+func IsPublicSubnet(subnet models.Resource) bool {
+	if value, ok := subnet.GetProperty("map_public_ip_on_launch"); ok {
+		if mapPublicIP, ok := value.(bool); ok {
+			return mapPublicIP
+		}
+	}
+	return strings.Contains(subnet.Name, "public")
+}
+
+// ServiceCIDR returns the CIDR block an EKS cluster assigns Kubernetes service addresses
+// from, or "" when AWS picks it
+func ServiceCIDR(cluster models.Resource) string {
+	value, ok := cluster.GetProperty("kubernetes_network_config")
+	if !ok {
+		return ""
+	}
+	config, _ := value.(map[string]interface{})
+	cidr, _ := config["service_ipv4_cidr"].(string)
+	return cidr
+}
+
+// serviceCIDRRanges are the private ranges an EKS service CIDR must be in
+var serviceCIDRRanges = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}
+
+// ValidateServiceCIDR checks that a CIDR block can be the service CIDR of an EKS cluster: an
+// IPv4 block of /12 to /24 within 10.0.0.0/8, 172.16.0.0/12 or 192.168.0.0/16
+func ValidateServiceCIDR(cidr string) error {
+	ip, network, err := net.ParseCIDR(cidr)
+	if err != nil || ip.To4() == nil {
+		return fmt.Errorf("%s is not an IPv4 CIDR block", cidr)
+	}
+	if !ip.Equal(network.IP) {
+		return fmt.Errorf("%s is not the start of a block; use %s", cidr, network)
+	}
+	if ones, _ := network.Mask.Size(); ones < 12 || ones > 24 {
+		return fmt.Errorf("%s is a /%d, but EKS needs /12 to /24", cidr, ones)
+	}
+	for _, private := range serviceCIDRRanges {
+		_, privateNetwork, _ := net.ParseCIDR(private)
+		if cidrContains(privateNetwork, network) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not in a private range", cidr)
+}
+
+// suggestedServiceCIDR returns the service CIDR EKS picks itself for a cluster in a network:
+// 172.20.0.0/16 when the network is in 10.0.0.0/8, and 10.100.0.0/16 otherwise
+func suggestedServiceCIDR(network *net.IPNet) string {
+	if network.IP.To4() != nil && network.IP.To4()[0] == 10 {
+		return "172.20.0.0/16"
+	}
+	return "10.100.0.0/16"
+}
+
+// cidrContains reports whether the network inner is entirely within the network outer
+func cidrContains(outer, inner *net.IPNet) bool {
+	outerOnes, outerBits := outer.Mask.Size()
+	innerOnes, innerBits := inner.Mask.Size()
+	return outerBits == innerBits && innerOnes >= outerOnes && outer.Contains(inner.IP)
+}
+
+// resourcesOfType returns the resources of a type in the model
+func resourcesOfType(model *models.InfrastructureModel, resourceType models.ResourceType) []models.Resource {
+	var resources []models.Resource
+	for _, resource := range model.Resources {
+		if resource.Type == resourceType {
+			resources = append(resources, resource)
+		}
+	}
+	return resources
+}
+
+// resourcesByName returns the resources of a type in the model, by name
+func resourcesByName(model *models.InfrastructureModel, resourceType models.ResourceType) map[string]models.Resource {
+	resources := make(map[string]models.Resource)
+	for _, resource := range resourcesOfType(model, resourceType) {
+		resources[resource.Name] = resource
+	}
+	return resources
+}
+
+// stringProperty returns a string property of a resource, or "" when it is not a string
+func stringProperty(resource models.Resource, name string) string {
+	value, _ := resource.GetProperty(name)
+	str, _ := value.(string)
+	return str
+}
+
+// stringValues returns a list of strings, as it is built or as it is read back from JSON
+func stringValues(value interface{}) []string {
+	switch values := value.(type) {
+	case []string:
+		return values
+	case []interface{}:
+		var strs []string
+		for _, v := range values {
+			if str, ok := v.(string); ok {
+				strs = append(strs, str)
+			}
+		}
+		return strs
+	}
+	return nil
+}
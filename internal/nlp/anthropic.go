@@ -0,0 +1,183 @@
+package nlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/retry"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// Defaults for the Anthropic backend
+const (
+	DefaultAnthropicEndpoint = "https://api.anthropic.com"
+	DefaultAnthropicModel    = "claude-sonnet-4-5"
+
+	// anthropicVersion is the Messages API version the backend is written against
+	anthropicVersion = "2023-06-01"
+
+	// entityToolName is the tool the model is forced to call with the extracted entities
+	entityToolName = "record_infrastructure_entities"
+)
+
+// AnthropicBackend extracts entities with the Anthropic Messages API. The model is forced
+// to call a tool whose input schema is EntitySchema, so its answer is always a JSON object
+// of the expected shape rather than free text.
+type AnthropicBackend struct {
+	apiKey   string
+	model    string
+	endpoint string
+	client   *http.Client
+	retry    retry.Policy
+}
+
+// anthropicTool is a tool definition in a Messages API request
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// anthropicRequest is the Messages API request body
+type anthropicRequest struct {
+	Model       string            `json:"model"`
+	MaxTokens   int               `json:"max_tokens"`
+	System      string            `json:"system"`
+	Messages    []chatMessage     `json:"messages"`
+	Tools       []anthropicTool   `json:"tools"`
+	ToolChoice  map[string]string `json:"tool_choice"`
+	Temperature float64           `json:"temperature"`
+}
+
+// anthropicResponse is the subset of the Messages API response the backend reads
+type anthropicResponse struct {
+	Content []struct {
+		Type  string                 `json:"type"`
+		Name  string                 `json:"name"`
+		Input map[string]interface{} `json:"input"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+	Error      *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewAnthropicBackend creates a new Anthropic backend
+func NewAnthropicBackend(config BackendConfig) (*AnthropicBackend, error) {
+	if config.APIKey == "" {
+		return nil, errors.New("the anthropic NLP backend requires an API key (set ANTHROPIC_API_KEY)")
+	}
+
+	backend := &AnthropicBackend{
+		apiKey:   config.APIKey,
+		model:    config.Model,
+		endpoint: strings.TrimRight(config.Endpoint, "/"),
+		client:   &http.Client{Timeout: config.Timeout},
+		retry:    config.Retry,
+	}
+	if backend.model == "" {
+		backend.model = DefaultAnthropicModel
+	}
+	if backend.endpoint == "" {
+		backend.endpoint = DefaultAnthropicEndpoint
+	}
+	return backend, nil
+}
+
+// Name implements Backend
+func (b *AnthropicBackend) Name() string {
+	return BackendAnthropic
+}
+
+// ExtractEntities implements Backend
+func (b *AnthropicBackend) ExtractEntities(ctx context.Context, description string) (map[string]interface{}, error) {
+	systemPrompt, err := EntityExtractionPrompt()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build prompt: %w", err)
+	}
+
+	body, err := json.Marshal(anthropicRequest{
+		Model:     b.model,
+		MaxTokens: 1024,
+		System:    systemPrompt,
+		Messages: []chatMessage{
+			{Role: "user", Content: description},
+		},
+		Tools: []anthropicTool{
+			{
+				Name:        entityToolName,
+				Description: "Record the AWS infrastructure entities extracted from the description.",
+				InputSchema: json.RawMessage(EntitySchema),
+			},
+		},
+		ToolChoice:  map[string]string{"type": "tool", "name": entityToolName},
+		Temperature: 0,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode Anthropic request: %w", err)
+	}
+
+	var resp *http.Response
+	var respBody []byte
+	err = retry.Do(ctx, b.retry, nil, logRetry(b.Name()), func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.endpoint+"/v1/messages", bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to create Anthropic request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", b.apiKey)
+		req.Header.Set("anthropic-version", anthropicVersion)
+
+		resp, err = b.client.Do(req)
+		if err != nil {
+			return retry.Transient(fmt.Errorf("Anthropic request failed: %w", err))
+		}
+		defer resp.Body.Close()
+
+		respBody, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return retry.Transient(fmt.Errorf("failed to read Anthropic response: %w", err))
+		}
+		return retryableStatus(resp.StatusCode)
+	})
+	if err != nil && !errors.Is(err, errRetryableStatus) {
+		return nil, err
+	}
+
+	var message anthropicResponse
+	if err := json.Unmarshal(respBody, &message); err != nil {
+		return nil, fmt.Errorf("failed to decode Anthropic response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		detail := http.StatusText(resp.StatusCode)
+		if message.Error != nil {
+			detail = message.Error.Message
+		}
+		return nil, fmt.Errorf("Anthropic request failed with status %d: %s", resp.StatusCode, detail)
+	}
+
+	var raw map[string]interface{}
+	for _, block := range message.Content {
+		if block.Type == "tool_use" && block.Name == entityToolName {
+			raw = block.Input
+			break
+		}
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("Anthropic response did not call %s (stop reason %s)", entityToolName, message.StopReason)
+	}
+
+	entities, warnings := NormalizeEntities(raw, description)
+	for _, warning := range warnings {
+		utils.GetLogger().Warnw("Corrected LLM entity", "backend", b.Name(), "detail", warning)
+	}
+
+	return entities, nil
+}
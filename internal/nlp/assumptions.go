@@ -0,0 +1,385 @@
+package nlp
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Confidence levels assigned to individual entity values
+const (
+	// ConfidenceStated is used for values stated in the description
+	ConfidenceStated = 1.0
+	// ConfidenceInferred is used for non-default values the description does not state,
+	// such as values inferred by an LLM or answered in a clarification
+	ConfidenceInferred = 0.8
+	// ConfidenceDefault is used for values the parser fell back to a default for
+	ConfidenceDefault = 0.5
+)
+
+// Patterns for phrases that state a value the regex patterns above only imply
+var (
+	vpcMentionPattern        = regexp.MustCompile(`(?i)\bvpc\b|virtual\s+private\s+cloud`)
+	publicCountPattern       = regexp.MustCompile(`(?i)\d+\s+public\b`)
+	privateCountPattern      = regexp.MustCompile(`(?i)\d+\s+private\s+(?:/\d{1,2}\s+)?subnets?|and\s+\d+\s+private\b`)
+	eksVersionMentionPattern = regexp.MustCompile(`(?i)version\s+\d+\.\d+`)
+	nodeCountPattern         = regexp.MustCompile(`(?i)\d+\s+nodes?\b`)
+	asgCapacityPattern       = regexp.MustCompile(`(?i)(?:asg|auto\s*scaling\s+group)\s+of\s+\d+`)
+	ec2CountPattern          = regexp.MustCompile(`(?i)\d+\s+(?:[tmcr]\d+[a-z]*\.[a-z0-9]+\s+)?ec2\s+instances?`)
+	explicitOSPattern        = regexp.MustCompile(`(?i)ubuntu|amazon\s+linux`)
+	explicitNATCountPattern  = regexp.MustCompile(`(?i)\d+\s*nat\s*gateways?`)
+)
+
+// Assumption is a default applied because the description did not state a value
+type Assumption struct {
+	// Entity is the entity key, such as "vpc"
+	Entity string `json:"entity"`
+	// Field is the entity field, empty when the whole entity was assumed
+	Field string `json:"field,omitempty"`
+	// Value is the value that was applied
+	Value interface{} `json:"value"`
+	// Reason explains the assumption in terms the user can act on
+	Reason string `json:"reason"`
+}
+
+// ExtractionReport describes how much of the extracted infrastructure came from the
+// description and how much was filled in with defaults
+type ExtractionReport struct {
+	// Confidence maps each entity key to a score between 0 and 1
+	Confidence map[string]float64 `json:"confidence"`
+	// Assumptions lists the defaults that were applied, in entity order
+	Assumptions []Assumption `json:"assumptions"`
+}
+
+// fieldCheck describes how to tell whether an entity field was stated in the description
+type fieldCheck struct {
+	field        string
+	defaultValue interface{}
+	stated       func(lowerDesc string) bool
+	reason       string
+	// defaultOf, when set, computes the default from the other entities in place of
+	// defaultValue, or returns nil when the check does not apply
+	defaultOf func(entities map[string]interface{}) interface{}
+}
+
+// entityChecks lists the checked fields of each entity, with the reason shown when the
+// default was applied
+var entityChecks = map[string][]fieldCheck{
+	"vpc": {
+		{
+			field:        "cidr_block",
+			defaultValue: "10.0.0.0/16",
+			stated:       CIDRPattern.MatchString,
+			reason:       "no CIDR block stated; make sure it does not overlap networks you peer with",
+		},
+	},
+	"subnets": {
+		{
+			field:        "public_count",
+			defaultValue: 1,
+			stated:       publicCountStated,
+			reason:       "no public subnet count stated, so the stack uses a single availability zone",
+		},
+		{
+			field:        "private_count",
+			defaultValue: 1,
+			stated:       privateCountStated,
+			reason:       "no private subnet count stated, so the stack uses a single availability zone",
+		},
+		{
+			field:        "public_count",
+			defaultValue: 2,
+			stated:       publicCountStated,
+			reason:       "no public subnet count stated, so the stack spans the two availability zones an EKS cluster needs",
+		},
+		{
+			field:        "private_count",
+			defaultValue: 2,
+			stated:       privateCountStated,
+			reason:       "no private subnet count stated, so the stack spans the two availability zones an EKS cluster needs",
+		},
+		{
+			field:     "private_count",
+			stated:    privateCountStated,
+			reason:    "no private subnet count stated, so there is a private subnet in each availability zone of the public subnets, as EKS nodes need",
+			defaultOf: eksDefaultPrivateCount,
+		},
+	},
+	"gateways": {
+		{
+			field:        "igw_count",
+			defaultValue: 1,
+			stated:       IGWPattern.MatchString,
+			reason:       "an internet gateway is added for the public subnets",
+		},
+		{
+			field:        "nat_count",
+			defaultValue: 0,
+			stated: func(lowerDesc string) bool {
+				return strings.Contains(lowerDesc, "nat")
+			},
+			reason: "no NAT gateway requested, so private subnets have no outbound internet access",
+		},
+		{
+			field:        "nat_count",
+			defaultValue: 1,
+			stated: func(lowerDesc string) bool {
+				return explicitNATCountPattern.MatchString(lowerDesc) || natPerAZPattern.MatchString(lowerDesc)
+			},
+			reason: "one NAT gateway is shared by all private subnets; it is a single point of failure",
+		},
+	},
+	"eks": {
+		{
+			field:        "version",
+			defaultValue: "1.27",
+			stated:       eksVersionMentionPattern.MatchString,
+			reason:       "no Kubernetes version stated",
+		},
+		{
+			field:        "node_count",
+			defaultValue: 2,
+			stated: func(lowerDesc string) bool {
+				return nodeCountPattern.MatchString(lowerDesc) || NodeGroupPattern.MatchString(lowerDesc)
+			},
+			reason: "no node count stated",
+		},
+		{
+			field:        "instance_type",
+			defaultValue: "t3.medium",
+			stated:       InstanceTypePattern.MatchString,
+			reason:       "no node instance type stated",
+		},
+		{
+			field:        "endpoint_public_access",
+			defaultValue: true,
+			stated:       apiAccessPattern.MatchString,
+			reason:       "no API access mode stated, so the cluster API endpoint is public",
+		},
+	},
+	"asg": {
+		{
+			field:        "desired_capacity",
+			defaultValue: 2,
+			stated: func(lowerDesc string) bool {
+				return asgCapacityPattern.MatchString(lowerDesc) || ASGRangePattern.MatchString(lowerDesc)
+			},
+			reason: "no group size stated",
+		},
+		{
+			field:        "instance_type",
+			defaultValue: "t3.micro",
+			stated:       InstanceTypePattern.MatchString,
+			reason:       "no instance type stated",
+		},
+	},
+	"ec2_instance": {
+		{
+			field:        "count",
+			defaultValue: 1,
+			stated:       ec2CountPattern.MatchString,
+			reason:       "no instance count stated",
+		},
+		{
+			field:        "instance_type",
+			defaultValue: "t3.micro",
+			stated: func(lowerDesc string) bool {
+				return EC2Pattern.FindStringSubmatch(lowerDesc) != nil && InstanceTypePattern.MatchString(lowerDesc)
+			},
+			reason: "no instance type stated",
+		},
+		{
+			field:        "os",
+			defaultValue: "amazon-linux-2023",
+			stated:       explicitOSPattern.MatchString,
+			reason:       "no operating system stated",
+		},
+	},
+}
+
+// publicCountStated reports whether a description states the number of public subnets,
+// directly or through the availability zones it spans
+func publicCountStated(lowerDesc string) bool {
+	return publicCountPattern.MatchString(lowerDesc) || azCountStated(lowerDesc)
+}
+
+// privateCountStated reports whether a description states the number of private subnets,
+// directly or through the availability zones it spans
+func privateCountStated(lowerDesc string) bool {
+	return privateCountPattern.MatchString(lowerDesc) || azCountStated(lowerDesc)
+}
+
+// azCountStated reports whether a description states the availability zones the stack
+// spans, other than those it reserves
+func azCountStated(lowerDesc string) bool {
+	return AZPattern.MatchString(ReservedAZPattern.ReplaceAllString(lowerDesc, ""))
+}
+
+// AssessEntities scores the entities extracted from a description and lists the defaults
+// that were applied. It works on the output of any backend, since it only compares the
+// entity values with the description and the regex parser's defaults.
+func AssessEntities(description string, entities map[string]interface{}) *ExtractionReport {
+	report := &ExtractionReport{
+		Confidence: make(map[string]float64),
+	}
+	lowerDesc := strings.ToLower(description)
+
+	keys := make([]string, 0, len(entities))
+	for key := range entities {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Region first, since it applies to everything else
+	if region, ok := entities["region"].(string); ok {
+		switch {
+		case RegionPattern.MatchString(lowerDesc):
+			report.Confidence["region"] = ConfidenceStated
+		case region != "us-east-1":
+			report.Confidence["region"] = ConfidenceInferred
+		default:
+			report.Confidence["region"] = ConfidenceDefault
+			report.Assumptions = append(report.Assumptions, Assumption{
+				Entity: "region",
+				Value:  region,
+				Reason: "no region stated; name one in the description to change it",
+			})
+		}
+	}
+
+	for _, key := range keys {
+		entity, ok := entities[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		var scores []float64
+		if key == "vpc" && !vpcMentionPattern.MatchString(lowerDesc) {
+			scores = append(scores, ConfidenceDefault)
+			report.Assumptions = append(report.Assumptions, Assumption{
+				Entity: key,
+				Value:  true,
+				Reason: "no VPC mentioned, so one is created to hold the other resources",
+			})
+		}
+
+		for _, field := range uniqueFields(entityChecks[key]) {
+			value, ok := entity[field]
+			if !ok {
+				continue
+			}
+			score, check := assessField(entityChecks[key], field, value, entities, lowerDesc)
+			scores = append(scores, score)
+			if check != nil {
+				report.Assumptions = append(report.Assumptions, Assumption{
+					Entity: key,
+					Field:  field,
+					Value:  value,
+					Reason: check.reason,
+				})
+			}
+		}
+
+		report.Confidence[key] = averageScore(scores)
+	}
+
+	return report
+}
+
+// eksDefaultPrivateCount returns the private subnet count the parser defaults to for the
+// stated public subnets of an EKS cluster, or nil without a cluster
+func eksDefaultPrivateCount(entities map[string]interface{}) interface{} {
+	subnets, ok := entities["subnets"].(map[string]interface{})
+	if _, hasEKS := entities["eks"]; !ok || !hasEKS {
+		return nil
+	}
+	return eksPrivateCount(subnets)
+}
+
+// assessField scores one field value and returns the check whose default was applied, if any
+func assessField(checks []fieldCheck, field string, value interface{}, entities map[string]interface{}, lowerDesc string) (float64, *fieldCheck) {
+	matchedDefault := false
+	for i := range checks {
+		check := &checks[i]
+		defaultValue := check.defaultValue
+		if check.defaultOf != nil {
+			defaultValue = check.defaultOf(entities)
+		}
+		if check.field != field || !reflect.DeepEqual(defaultValue, value) {
+			continue
+		}
+		matchedDefault = true
+		if !check.stated(lowerDesc) {
+			return ConfidenceDefault, check
+		}
+	}
+	if matchedDefault || statedInDescription(value, lowerDesc) {
+		return ConfidenceStated, nil
+	}
+	for _, check := range checks {
+		if check.field == field && check.stated(lowerDesc) {
+			return ConfidenceStated, nil
+		}
+	}
+	return ConfidenceInferred, nil
+}
+
+// uniqueFields returns the checked fields in declaration order without duplicates
+func uniqueFields(checks []fieldCheck) []string {
+	seen := make(map[string]bool)
+	var fields []string
+	for _, check := range checks {
+		if !seen[check.field] {
+			seen[check.field] = true
+			fields = append(fields, check.field)
+		}
+	}
+	return fields
+}
+
+// averageScore returns the mean of the scores, or full confidence when there are none
+func averageScore(scores []float64) float64 {
+	if len(scores) == 0 {
+		return ConfidenceStated
+	}
+	total := 0.0
+	for _, score := range scores {
+		total += score
+	}
+	return total / float64(len(scores))
+}
+
+// Text renders the report as the "Assumptions" section printed before generation
+func (r *ExtractionReport) Text() string {
+	var buf bytes.Buffer
+
+	buf.WriteString("Assumptions\n")
+	buf.WriteString("-----------\n")
+	if len(r.Assumptions) == 0 {
+		buf.WriteString("  None: every value was stated in the description.\n")
+	}
+	for _, assumption := range r.Assumptions {
+		name := assumption.Entity
+		if assumption.Field != "" {
+			name += "." + assumption.Field
+		}
+		buf.WriteString(fmt.Sprintf("  • %s = %v: %s\n", name, assumption.Value, assumption.Reason))
+	}
+
+	keys := make([]string, 0, len(r.Confidence))
+	for key := range r.Confidence {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	scores := make([]string, 0, len(keys))
+	for _, key := range keys {
+		scores = append(scores, fmt.Sprintf("%s %.0f%%", key, r.Confidence[key]*100))
+	}
+	buf.WriteString(fmt.Sprintf("  Confidence: %s\n", strings.Join(scores, ", ")))
+
+	return buf.String()
+}
@@ -0,0 +1,149 @@
+package nlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/riptano/iac_generator_cli/internal/retry"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// Backend names accepted by NewBackend
+const (
+	BackendRegex     = "regex"
+	BackendLLM       = "llm"
+	BackendOllama    = "ollama"
+	BackendAnthropic = "anthropic"
+)
+
+// Backend extracts infrastructure entities from a natural language description.
+// Every backend returns the entity map produced by the regex parser, so the
+// validator and model builder work the same way regardless of the backend.
+type Backend interface {
+	// Name returns the backend name for logging
+	Name() string
+
+	// ExtractEntities extracts infrastructure entities from the description
+	ExtractEntities(ctx context.Context, description string) (map[string]interface{}, error)
+}
+
+// BackendConfig holds the settings for LLM-backed parsing and the regex parser
+type BackendConfig struct {
+	// APIKey authenticates with the hosted LLM API (unused by Ollama)
+	APIKey string
+	// Model is the model name to request
+	Model string
+	// Endpoint overrides the API base URL, such as a remote Ollama host
+	Endpoint string
+	// Timeout bounds each LLM request
+	Timeout time.Duration
+	// Synonyms rewrite descriptions for the regex parser (nil uses the built-in synonyms)
+	Synonyms *SynonymRegistry
+	// Retry is how LLM requests failing with network errors, rate limits or server errors
+	// are retried before falling back to the regex parser (the zero value sends them once)
+	Retry retry.Policy
+}
+
+// DefaultBackendConfig returns the settings for the named backend from the environment
+func DefaultBackendConfig(backend string) BackendConfig {
+	config := BackendConfig{
+		Timeout: 60 * time.Second,
+	}
+	switch strings.ToLower(backend) {
+	case BackendLLM:
+		config.APIKey = os.Getenv("OPENAI_API_KEY")
+	case BackendAnthropic:
+		config.APIKey = os.Getenv("ANTHROPIC_API_KEY")
+	}
+	return config
+}
+
+// NewBackend creates the named backend. LLM backends are reconciled against the
+// regex parser and fall back to it when the LLM request fails.
+func NewBackend(name string, config BackendConfig) (Backend, error) {
+	regex := NewRegexBackendWithSynonyms(config.Synonyms)
+	switch strings.ToLower(name) {
+	case "", BackendRegex:
+		return regex, nil
+	case BackendLLM:
+		llm, err := NewOpenAIBackend(config)
+		if err != nil {
+			return nil, err
+		}
+		return NewReconcilingBackend(llm, regex), nil
+	case BackendOllama:
+		return NewReconcilingBackend(NewOllamaBackend(config), regex), nil
+	case BackendAnthropic:
+		llm, err := NewAnthropicBackend(config)
+		if err != nil {
+			return nil, err
+		}
+		return NewReconcilingBackend(llm, regex), nil
+	default:
+		return nil, fmt.Errorf("unsupported NLP backend: %s (supported backends: %s)", name, strings.Join(SupportedBackends(), ", "))
+	}
+}
+
+// SupportedBackends returns the names accepted by NewBackend
+func SupportedBackends() []string {
+	return []string{BackendRegex, BackendLLM, BackendOllama, BackendAnthropic}
+}
+
+// RegexBackend extracts entities with the pattern-based parser
+type RegexBackend struct {
+	parser *Parser
+}
+
+// NewRegexBackend creates a new regex backend
+func NewRegexBackend() *RegexBackend {
+	return NewRegexBackendWithSynonyms(nil)
+}
+
+// NewRegexBackendWithSynonyms creates a regex backend whose parser uses the given synonyms
+func NewRegexBackendWithSynonyms(synonyms *SynonymRegistry) *RegexBackend {
+	return &RegexBackend{
+		parser: NewParserWithSynonyms(synonyms),
+	}
+}
+
+// Name implements Backend
+func (b *RegexBackend) Name() string {
+	return BackendRegex
+}
+
+// ExtractEntities implements Backend
+func (b *RegexBackend) ExtractEntities(ctx context.Context, description string) (map[string]interface{}, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return b.parser.ExtractEntities(description)
+}
+
+// errRetryableStatus fails an LLM request answered with a status worth retrying. Once the
+// retries are used up, the last response is decoded as any other.
+var errRetryableStatus = errors.New("retryable response status")
+
+// retryableStatus returns a transient error for the statuses of rate limits and server errors
+func retryableStatus(status int) error {
+	if status == http.StatusTooManyRequests || status >= http.StatusInternalServerError {
+		return retry.Transient(errRetryableStatus)
+	}
+	return nil
+}
+
+// logRetry returns the function logging the retried requests of a backend
+func logRetry(backend string) func(attempt int, delay time.Duration, err error) {
+	return func(attempt int, delay time.Duration, err error) {
+		utils.GetLogger().Warnw("Retrying LLM request",
+			"backend", backend,
+			"attempt", attempt,
+			"delay", delay,
+			"error", err.Error(),
+		)
+	}
+}
@@ -0,0 +1,169 @@
+package nlp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// Patterns for phrases that settle an otherwise ambiguous choice
+var (
+	natPerAZPattern     = regexp.MustCompile(`(?i)nat\s*gateways?\s+(?:per|in\s+each|for\s+each)\s+(?:az|availability\s+zone)|(?:single|one|shared)\s+nat`)
+	apiAccessPattern    = regexp.MustCompile(`(?i)(?:public|private)[a-z\s]*(?:api|endpoint)`)
+	subnetLayoutPattern = regexp.MustCompile(`(?i)\d+\s*(?:public|private|azs?\b|availability\s+zones?)`)
+)
+
+// Clarification is a follow-up question about a part of the description the parser could
+// only fill in with a default
+type Clarification struct {
+	// ID identifies the question, such as "nat_gateways"
+	ID string
+	// Question is the text shown to the user
+	Question string
+	// Options are the accepted answers
+	Options []string
+	// Default is the answer that matches the non-interactive behavior
+	Default string
+
+	apply func(entities map[string]interface{}, answer string)
+}
+
+// Apply updates the entities with an answer. An empty answer selects the default.
+func (c Clarification) Apply(entities map[string]interface{}, answer string) error {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer == "" {
+		answer = c.Default
+	}
+	for _, option := range c.Options {
+		if answer == option {
+			c.apply(entities, answer)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid answer %q (expected one of: %s)", answer, strings.Join(c.Options, ", "))
+}
+
+// Clarifier answers clarification questions, usually by prompting the user
+type Clarifier interface {
+	// Ask returns the answer to a clarification question
+	Ask(ctx context.Context, clarification Clarification) (string, error)
+}
+
+// FindClarifications returns the questions worth asking about a description, given the
+// entities extracted from it. Only values the parser defaulted are questioned, so a
+// description that states them explicitly produces no questions. The questions are
+// returned in the order they should be asked, since later answers depend on earlier ones.
+func FindClarifications(description string, entities map[string]interface{}) []Clarification {
+	var clarifications []Clarification
+	lowerDesc := strings.ToLower(description)
+
+	if subnets, ok := entities["subnets"].(map[string]interface{}); ok {
+		// An EKS cluster needs subnets in two availability zones, so it is not offered one
+		defaultZones, options := 1, []string{"1", "2", "3"}
+		if _, ok := entities["eks"]; ok {
+			defaultZones, options = infra.MinEKSAvailabilityZones, []string{"2", "3"}
+		}
+		zones, _ := subnets["public_count"].(int)
+		if (zones == 1 || zones == defaultZones) && subnets["private_count"] == zones && !subnetLayoutPattern.MatchString(lowerDesc) {
+			clarifications = append(clarifications, Clarification{
+				ID:       "availability_zones",
+				Question: "How many availability zones should the VPC span (one public and one private subnet each)?",
+				Options:  options,
+				Default:  strconv.Itoa(defaultZones),
+				apply: func(entities map[string]interface{}, answer string) {
+					count, _ := strconv.Atoi(answer)
+					subnets := entities["subnets"].(map[string]interface{})
+					subnets["public_count"] = count
+					subnets["private_count"] = count
+					regenerateSubnetCIDRs(entities)
+				},
+			})
+		}
+	}
+
+	if gateways, ok := entities["gateways"].(map[string]interface{}); ok {
+		natMatch := NATPattern.FindStringSubmatch(lowerDesc)
+		if natMatch != nil && natMatch[1] == "" && gateways["nat_count"] == 1 && !natPerAZPattern.MatchString(lowerDesc) {
+			clarifications = append(clarifications, Clarification{
+				ID:       "nat_gateways",
+				Question: "Should private subnets share a single NAT gateway, or get one NAT gateway per availability zone?",
+				Options:  []string{"single", "per-az"},
+				Default:  "single",
+				apply: func(entities map[string]interface{}, answer string) {
+					gateways := entities["gateways"].(map[string]interface{})
+					gateways["nat_count"] = 1
+					if answer == "per-az" {
+						if subnets, ok := entities["subnets"].(map[string]interface{}); ok {
+							if count, ok := subnets["public_count"].(int); ok && count > 0 {
+								gateways["nat_count"] = count
+							}
+						}
+					}
+				},
+			})
+		}
+	}
+
+	if eks, ok := entities["eks"].(map[string]interface{}); ok && eks["exists"] == true {
+		if !apiAccessPattern.MatchString(lowerDesc) {
+			clarifications = append(clarifications, Clarification{
+				ID:       "eks_api_access",
+				Question: "Should the EKS API endpoint be reachable publicly, privately from the VPC, or both?",
+				Options:  []string{"public", "private", "both"},
+				Default:  "public",
+				apply: func(entities map[string]interface{}, answer string) {
+					eks := entities["eks"].(map[string]interface{})
+					eks["endpoint_public_access"] = answer != "private"
+					eks["endpoint_private_access"] = answer != "public"
+				},
+			})
+		}
+	}
+
+	return clarifications
+}
+
+// ClarifyingBackend asks follow-up questions about the entities extracted by another backend
+type ClarifyingBackend struct {
+	backend   Backend
+	clarifier Clarifier
+}
+
+// NewClarifyingBackend creates a backend that resolves ambiguities with a clarifier
+func NewClarifyingBackend(backend Backend, clarifier Clarifier) *ClarifyingBackend {
+	return &ClarifyingBackend{
+		backend:   backend,
+		clarifier: clarifier,
+	}
+}
+
+// Name implements Backend
+func (b *ClarifyingBackend) Name() string {
+	return b.backend.Name()
+}
+
+// ExtractEntities implements Backend
+func (b *ClarifyingBackend) ExtractEntities(ctx context.Context, description string) (map[string]interface{}, error) {
+	entities, err := b.backend.ExtractEntities(ctx, description)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, clarification := range FindClarifications(description, entities) {
+		answer, err := b.clarifier.Ask(ctx, clarification)
+		if err != nil {
+			return nil, fmt.Errorf("failed to clarify %s: %w", clarification.ID, err)
+		}
+		if err := clarification.Apply(entities, answer); err != nil {
+			return nil, err
+		}
+		utils.GetLogger().Debugw("Applied clarification", "question", clarification.ID, "answer", answer)
+	}
+
+	return entities, nil
+}
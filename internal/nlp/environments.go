@@ -0,0 +1,148 @@
+package nlp
+
+import (
+	"regexp"
+	"strings"
+)
+
+// environmentNames lists the words recognized as environment names
+const environmentNames = `dev|development|test|qa|uat|staging|stage|prod|production`
+
+// Patterns for descriptions that cover several environments
+var (
+	// environmentListPattern matches "dev and prod environments" or "dev, staging and prod envs"
+	environmentListPattern = regexp.MustCompile(`(?i)\b((?:` + environmentNames + `)(?:\s*,\s*(?:and\s+)?|\s+and\s+)(?:` + environmentNames + `)(?:(?:\s*,\s*(?:and\s+)?|\s+and\s+)(?:` + environmentNames + `))*)\s+(?:environments|envs)\b`)
+	environmentNamePattern = regexp.MustCompile(`(?i)\b(?:` + environmentNames + `)\b`)
+	// environmentClausePattern matches the start of a clause about one environment, such as "prod with"
+	environmentClausePattern = regexp.MustCompile(`(?i)\b(` + environmentNames + `)(?:\s+(?:with|gets|has|uses)\b|\s*:)`)
+	clauseTrailerPattern     = regexp.MustCompile(`(?i)(?:\s*,)?(?:\s+and)?\s*$`)
+	sentenceEndPattern       = regexp.MustCompile(`[.;](?:\s|$)`)
+	sentenceBreaksPattern    = regexp.MustCompile(`\s*[.;](?:\s*[.;])*(?:\s+|$)`)
+	bareCountPattern         = regexp.MustCompile(`^(\d+)$`)
+	countedNounPattern       = regexp.MustCompile(`^\d+\s+(.+)$`)
+)
+
+// EnvironmentPattern matches the one environment a description is for, such as "for
+// staging" or "a production environment"
+var EnvironmentPattern = regexp.MustCompile(`(?i)\b(?:for|in)\s+(?:the\s+|a\s+)?(` + environmentNames + `)\b(?:\s+(?:environment|env|account|stage)\b)?|\b(` + environmentNames + `)\s+(?:environment|env)\b`)
+
+// Environment is the part of a description that applies to one environment
+type Environment struct {
+	// Name is the environment name as written, in lower case, such as "prod"
+	Name string
+	// Description is the description of this environment on its own
+	Description string
+}
+
+// SplitEnvironments splits a description that asks for several environments, such as
+// "create dev and prod environments with an EKS cluster, prod with 3 NAT gateways and dev
+// with 1", into one description per environment. The text shared by all environments is
+// kept in each, and clauses naming one environment are only kept in that environment's
+// description. A clause that only gives a count, like "dev with 1", borrows the noun from
+// another environment's clause. Descriptions naming fewer than two environments return nil.
+func SplitEnvironments(description string) []Environment {
+	listMatch := environmentListPattern.FindStringSubmatchIndex(description)
+	if listMatch == nil {
+		return nil
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range environmentNamePattern.FindAllString(description[listMatch[2]:listMatch[3]], -1) {
+		name = strings.ToLower(name)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if len(names) < 2 {
+		return nil
+	}
+
+	// The environment list is replaced so the shared text reads as a single environment
+	shared := description[:listMatch[0]] + "an environment" + description[listMatch[1]:]
+
+	// Find the clauses about one environment. A clause runs until the next clause or the end
+	// of the sentence.
+	clauses := make(map[string][]string)
+	var spans [][2]int
+	starts := environmentClausePattern.FindAllStringSubmatchIndex(shared, -1)
+	for i, start := range starts {
+		name := strings.ToLower(shared[start[2]:start[3]])
+		if !seen[name] {
+			continue
+		}
+		end := len(shared)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+		if stop := sentenceEndPattern.FindStringIndex(shared[start[1]:end]); stop != nil {
+			end = start[1] + stop[0]
+		}
+		body := strings.TrimSpace(clauseTrailerPattern.ReplaceAllString(shared[start[1]:end], ""))
+		if body != "" {
+			clauses[name] = append(clauses[name], body)
+		}
+		spans = append(spans, [2]int{start[0], end})
+	}
+
+	// Remove the clauses, along with the separators that joined them to the shared text
+	var segments []string
+	last := 0
+	for _, span := range spans {
+		segments = append(segments, strings.TrimSpace(clauseTrailerPattern.ReplaceAllString(shared[last:span[0]], "")))
+		last = span[1]
+	}
+	segments = append(segments, strings.TrimSpace(shared[last:]))
+	commonText := strings.TrimSpace(sentenceBreaksPattern.ReplaceAllString(strings.Join(segments, " "), ". "))
+
+	environments := make([]Environment, 0, len(names))
+	for _, name := range names {
+		var parts []string
+		for _, body := range clauses[name] {
+			parts = append(parts, completeCount(body, name, names, clauses))
+		}
+		// The environment's own clauses come first, so they take precedence over the shared
+		// text for parsers that use the first match
+		parts = append(parts, commonText)
+		environments = append(environments, Environment{
+			Name:        name,
+			Description: strings.Join(parts, ". "),
+		})
+	}
+
+	return environments
+}
+
+// ExtractEnvironment extracts the environment a description is for, in lower case, or an
+// empty string when it names none. Descriptions covering several environments are split with
+// SplitEnvironments instead.
+func ExtractEnvironment(description string) string {
+	match := EnvironmentPattern.FindStringSubmatch(description)
+	if match == nil {
+		return ""
+	}
+	if match[1] != "" {
+		return strings.ToLower(match[1])
+	}
+	return strings.ToLower(match[2])
+}
+
+// completeCount adds the noun to a clause that only gives a count, taking it from a clause
+// of another environment, in the order the environments were listed
+func completeCount(body, name string, names []string, clauses map[string][]string) string {
+	if !bareCountPattern.MatchString(body) {
+		return body
+	}
+	for _, other := range names {
+		if other == name {
+			continue
+		}
+		for _, otherBody := range clauses[other] {
+			if match := countedNounPattern.FindStringSubmatch(otherBody); match != nil {
+				return body + " " + match[1]
+			}
+		}
+	}
+	return body
+}
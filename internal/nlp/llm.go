@@ -0,0 +1,664 @@
+package nlp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+)
+
+// EntitySchema is the JSON Schema for the entity map LLM backends must return
+const EntitySchema = `{
+  "type": "object",
+  "properties": {
+    "region": {"type": "string", "description": "AWS region such as us-east-1"},
+    "vpc": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string", "description": "Name given in the description, lower-case with hyphens"},
+        "cidr_block": {"type": "string"},
+        "enable_dns_support": {"type": "boolean"},
+        "enable_dns_hostnames": {"type": "boolean"}
+      }
+    },
+    "subnets": {
+      "type": "object",
+      "properties": {
+        "public_count": {"type": "integer", "minimum": 0},
+        "private_count": {"type": "integer", "minimum": 0},
+        "subnet_mask": {"type": "integer", "minimum": 16, "maximum": 28, "description": "Prefix length of every subnet, such as 20 for \"/20 subnets\"; leave out for /24"},
+        "reserved_azs": {"type": "integer", "minimum": 1, "description": "Availability zones to leave room for, such as 6 for \"leave room for 6 AZs\""}
+      }
+    },
+    "gateways": {
+      "type": "object",
+      "properties": {
+        "igw_count": {"type": "integer", "minimum": 0, "maximum": 1},
+        "nat_count": {"type": "integer", "minimum": 0}
+      }
+    },
+    "eks": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string", "description": "Name given in the description, lower-case with hyphens"},
+        "node_group_name": {"type": "string"},
+        "version": {"type": "string", "description": "Kubernetes version such as 1.28"},
+        "node_count": {"type": "integer", "minimum": 1},
+        "instance_type": {"type": "string"},
+        "endpoint_public_access": {"type": "boolean"},
+        "endpoint_private_access": {"type": "boolean"},
+        "service_cidr": {"type": "string", "description": "CIDR block of the Kubernetes service addresses, when the description gives one"},
+        "node_groups": {
+          "type": "array",
+          "description": "Node groups given with a capacity type, such as \"a spot node group of up to 10 t3.large\"; \"10 nodes, 80% spot\" is an on-demand group of 2 and a spot group of 8; leave out for a single default node group",
+          "items": {
+            "type": "object",
+            "properties": {
+              "capacity_type": {"type": "string", "enum": ["ON_DEMAND", "SPOT"]},
+              "instance_type": {"type": "string"},
+              "instance_types": {"type": "array", "description": "Instance types a mixed-instances policy allows, such as [\"m5.large\", \"m5a.large\"]", "items": {"type": "string"}},
+              "desired_size": {"type": "integer", "minimum": 1},
+              "min_size": {"type": "integer", "minimum": 1},
+              "max_size": {"type": "integer", "minimum": 1}
+            }
+          }
+        },
+        "controllers": {
+          "type": "array",
+          "description": "Controllers that get an IAM role for their service account, such as [\"aws-load-balancer-controller\", \"external-dns\"] for \"with ALB controller and external-dns\"",
+          "items": {"type": "string", "enum": ["aws-load-balancer-controller", "external-dns", "cluster-autoscaler"]}
+        },
+        "karpenter": {"type": "boolean", "description": "Whether Karpenter provisions nodes for the cluster, as in \"an EKS cluster with Karpenter\""},
+        "autoscaling": {"type": "string", "enum": ["cluster-autoscaler", "karpenter"], "description": "How the cluster's nodes scale, as in \"with cluster autoscaler\" or \"with karpenter\""},
+        "fargate_namespaces": {
+          "type": "array",
+          "description": "Kubernetes namespaces whose pods run on Fargate, such as [\"kube-system\", \"app\"] for \"run the kube-system and app namespaces on Fargate\"",
+          "items": {"type": "string"}
+        },
+        "addons": {
+          "type": "array",
+          "description": "EKS add-ons, such as [{\"name\": \"coredns\"}] for \"with coredns\"; \"the EBS CSI driver\" is aws-ebs-csi-driver",
+          "items": {
+            "type": "object",
+            "properties": {
+              "name": {"type": "string", "enum": ["vpc-cni", "coredns", "kube-proxy", "aws-ebs-csi-driver", "aws-efs-csi-driver", "eks-pod-identity-agent"]},
+              "version": {"type": "string", "description": "Pinned add-on version such as v1.18.1-eksbuild.1; leave out for the default version"}
+            },
+            "required": ["name"]
+          }
+        }
+      }
+    },
+    "asg": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string", "description": "Name given in the description, lower-case with hyphens"},
+        "desired_capacity": {"type": "integer", "minimum": 0},
+        "min_size": {"type": "integer", "minimum": 0},
+        "max_size": {"type": "integer", "minimum": 1},
+        "instance_type": {"type": "string"},
+        "behind_alb": {"type": "boolean"},
+        "user_data_packages": {"type": "array", "items": {"type": "string"}},
+        "instance_refresh_min_healthy": {"type": "integer", "minimum": 0, "maximum": 100}
+      }
+    },
+    "ec2_instance": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string", "description": "Name given in the description, lower-case with hyphens"},
+        "count": {"type": "integer", "minimum": 1},
+        "instance_type": {"type": "string"},
+        "os": {"type": "string", "enum": ["amazon-linux-2023", "ubuntu"]},
+        "key_name": {"type": "string"},
+        "ssh_cidr": {"type": "string"},
+        "web": {"type": "boolean"},
+        "public": {"type": "boolean"}
+      }
+    },
+    "secondary_regions": {
+      "type": "array",
+      "description": "Regions of additional VPCs, such as us-west-2 for \"a DR VPC in us-west-2\"",
+      "items": {"type": "string"}
+    },
+    "tags": {
+      "type": "object",
+      "description": "Tags the description asks to apply to every resource, such as {\"team\": \"platform\"}",
+      "additionalProperties": {"type": "string"}
+    },
+    "environment": {"type": "string", "description": "The one environment the description is for, such as staging for \"for staging\"; leave out when none is named"}
+  },
+  "required": ["region"]
+}`
+
+// entityExtractionPrompt is the system prompt shared by the LLM backends
+const entityExtractionPrompt = `You extract AWS infrastructure requirements from English descriptions.
+
+Respond with a single JSON object that matches this JSON Schema:
+
+{{.Schema}}
+
+Rules:
+- Only include a resource object when the description asks for that resource.
+- Omit properties the description does not mention; defaults are applied later.
+- When the description rules something out, such as "no NAT gateway" or "no public subnets", set its count to 0 or its flag to false.
+- Use integers for counts and booleans for flags.
+- Do not add commentary or Markdown, only the JSON object.`
+
+// Limits enforced on LLM output before it reaches the model builder
+const (
+	maxSubnetsPerType = 16
+	maxNodeCount      = 100
+	maxInstanceCount  = 50
+)
+
+var (
+	fullRegionPattern   = regexp.MustCompile(`^(us|eu|ap|sa|ca|me|af)-(north|south|east|west|central|northeast|southeast|southwest|northwest)-\d$`)
+	instanceTypePattern = regexp.MustCompile(`^[a-z][a-z0-9\-]*\d[a-z0-9\-]*\.[a-z0-9]+$`)
+	eksVersionPattern   = regexp.MustCompile(`^1\.\d{1,2}$`)
+	keyNamePattern      = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9\-_.]*$`)
+	codeFencePattern    = regexp.MustCompile("(?s)^```(?:json)?\\s*(.*?)\\s*```$")
+)
+
+// EntityExtractionPrompt returns the system prompt instructing an LLM to return the entity map
+func EntityExtractionPrompt() (string, error) {
+	return NewPromptTemplate(entityExtractionPrompt).GeneratePrompt(map[string]interface{}{
+		"Schema": EntitySchema,
+	})
+}
+
+// DecodeLLMEntities parses the JSON object returned by an LLM, tolerating Markdown code fences
+func DecodeLLMEntities(content string) (map[string]interface{}, error) {
+	content = strings.TrimSpace(content)
+	if matches := codeFencePattern.FindStringSubmatch(content); len(matches) > 1 {
+		content = matches[1]
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode LLM entities: %w", err)
+	}
+	return raw, nil
+}
+
+// NormalizeEntities deterministically validates raw LLM output and converts it into the
+// entity map produced by the regex parser. Values that are malformed or out of range are
+// replaced with the regex parser's defaults, and every correction is reported as a warning.
+func NormalizeEntities(raw map[string]interface{}, description string) (map[string]interface{}, []string) {
+	var warnings []string
+	warn := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	entities := make(map[string]interface{})
+	lowerDesc := strings.ToLower(description)
+
+	for key := range raw {
+		switch key {
+		case "region", "vpc", "subnets", "gateways", "eks", "asg", "ec2_instance", "secondary_regions", "tags", "environment":
+		default:
+			warn("ignored unsupported entity %q", key)
+		}
+	}
+
+	// Region
+	region, _ := raw["region"].(string)
+	region = strings.ToLower(strings.TrimSpace(region))
+	if !fullRegionPattern.MatchString(region) {
+		fallback := ExtractRegion(lowerDesc)
+		if region != "" {
+			warn("invalid region %q replaced with %s", region, fallback)
+		}
+		region = fallback
+	}
+	entities["region"] = region
+
+	// The regex parser always produces a VPC, subnets and gateways, so the LLM output does too
+	vpcRaw := objectValue(raw["vpc"])
+	vpc := map[string]interface{}{
+		"exists":               true,
+		"cidr_block":           "10.0.0.0/16",
+		"enable_dns_support":   boolValue(vpcRaw["enable_dns_support"], true),
+		"enable_dns_hostnames": boolValue(vpcRaw["enable_dns_hostnames"], true),
+	}
+	if cidr, ok := vpcRaw["cidr_block"].(string); ok {
+		if validCIDR(cidr, 16, 28) {
+			vpc["cidr_block"] = cidr
+		} else {
+			warn("invalid VPC CIDR %q replaced with 10.0.0.0/16", cidr)
+		}
+	}
+	setName(vpc, "name", vpcRaw["name"], "VPC name", warn)
+	entities["vpc"] = vpc
+
+	subnetsRaw := objectValue(raw["subnets"])
+	publicCount := boundedInt(subnetsRaw["public_count"], 1, 0, maxSubnetsPerType, "public subnet count", warn)
+	privateCount := boundedInt(subnetsRaw["private_count"], 1, 0, maxSubnetsPerType, "private subnet count", warn)
+	if publicCount == 0 && privateCount == 0 {
+		warn("no subnets requested; defaulting to 1 public and 1 private subnet")
+		publicCount, privateCount = 1, 1
+	}
+	subnets := map[string]interface{}{
+		"public_count":  publicCount,
+		"private_count": privateCount,
+	}
+	plan := infra.SubnetPlan{
+		Mask:        boundedInt(subnetsRaw["subnet_mask"], 0, 16, 28, "subnet mask", warn),
+		ReservedAZs: boundedInt(subnetsRaw["reserved_azs"], 0, 1, maxSubnetsPerType, "reserved availability zone count", warn),
+	}
+	if !plan.IsZero() {
+		if _, _, err := infra.GenerateSubnetCIDRsWithPlan(vpc["cidr_block"].(string), publicCount, privateCount, plan); err != nil {
+			warn("subnet plan ignored: %v", err)
+			plan = infra.SubnetPlan{}
+		}
+	}
+	if plan.Mask != 0 {
+		subnets["subnet_mask"] = plan.Mask
+	}
+	if plan.ReservedAZs != 0 {
+		subnets["reserved_azs"] = plan.ReservedAZs
+	}
+	if publicCIDRs, privateCIDRs, err := infra.GenerateSubnetCIDRsWithPlan(vpc["cidr_block"].(string), publicCount, privateCount, plan); err == nil {
+		subnets["public_cidrs"] = publicCIDRs
+		subnets["private_cidrs"] = privateCIDRs
+	}
+	entities["subnets"] = subnets
+
+	gatewaysRaw := objectValue(raw["gateways"])
+	entities["gateways"] = map[string]interface{}{
+		"igw_count": boundedInt(gatewaysRaw["igw_count"], 1, 0, 1, "internet gateway count", warn),
+		"nat_count": boundedInt(gatewaysRaw["nat_count"], 0, 0, maxSubnetsPerType, "NAT gateway count", warn),
+	}
+
+	if eksRaw, ok := raw["eks"].(map[string]interface{}); ok {
+		eks := map[string]interface{}{
+			"exists":                  true,
+			"version":                 "1.27",
+			"node_count":              boundedInt(eksRaw["node_count"], 2, 1, maxNodeCount, "EKS node count", warn),
+			"instance_type":           instanceTypeValue(eksRaw["instance_type"], "t3.medium", warn),
+			"endpoint_public_access":  boolValue(eksRaw["endpoint_public_access"], true),
+			"endpoint_private_access": boolValue(eksRaw["endpoint_private_access"], false),
+		}
+		if version := stringValue(eksRaw["version"]); version != "" {
+			if eksVersionPattern.MatchString(version) {
+				eks["version"] = version
+			} else {
+				warn("invalid EKS version %q replaced with 1.27", version)
+			}
+		}
+		if eks["endpoint_public_access"] == false && eks["endpoint_private_access"] == false {
+			warn("EKS endpoint cannot be both private and public disabled; enabling private access")
+			eks["endpoint_private_access"] = true
+		}
+		if cidr := stringValue(eksRaw["service_cidr"]); cidr != "" {
+			if err := infra.ValidateServiceCIDR(cidr); err == nil {
+				eks["service_cidr"] = cidr
+			} else {
+				warn("invalid EKS service CIDR ignored: %v", err)
+			}
+		}
+		setName(eks, "name", eksRaw["name"], "EKS cluster name", warn)
+		setName(eks, "node_group_name", eksRaw["node_group_name"], "node group name", warn)
+		if nodeGroups := nodeGroupsValue(eksRaw["node_groups"], warn); len(nodeGroups) > 0 {
+			eks["node_groups"] = nodeGroups
+		}
+		if addons := addonsValue(eksRaw["addons"], warn); len(addons) > 0 {
+			eks["addons"] = addons
+		}
+		var namespaces []string
+		seen := make(map[string]bool)
+		for _, namespace := range stringSliceValue(eksRaw["fargate_namespaces"]) {
+			namespace = strings.ToLower(namespace)
+			if !infra.ValidNamespace(namespace) {
+				warn("invalid Fargate namespace %q ignored", namespace)
+				continue
+			}
+			if !seen[namespace] {
+				seen[namespace] = true
+				namespaces = append(namespaces, namespace)
+			}
+		}
+		if len(namespaces) > 0 {
+			eks["fargate_namespaces"] = namespaces
+		}
+		if karpenter, ok := eksRaw["karpenter"].(bool); ok && karpenter {
+			eks["karpenter"] = true
+		}
+		if autoscaling, ok := eksRaw["autoscaling"].(string); ok && autoscaling != "" {
+			if infra.ValidEKSAutoscaling(autoscaling) {
+				eks["autoscaling"] = autoscaling
+			} else {
+				warn("unknown autoscaling strategy %q ignored", autoscaling)
+			}
+		}
+		var controllers []string
+		seenControllers := make(map[string]bool)
+		for _, name := range stringSliceValue(eksRaw["controllers"]) {
+			if _, ok := infra.LookupEKSController(name); !ok {
+				warn("unknown controller %q ignored", name)
+				continue
+			}
+			if !seenControllers[name] {
+				seenControllers[name] = true
+				controllers = append(controllers, name)
+			}
+		}
+		if len(controllers) > 0 {
+			eks["controllers"] = controllers
+		}
+		entities["eks"] = eks
+	}
+
+	if asgRaw, ok := raw["asg"].(map[string]interface{}); ok {
+		desired := boundedInt(asgRaw["desired_capacity"], 2, 0, maxInstanceCount, "ASG desired capacity", warn)
+		minSize := boundedInt(asgRaw["min_size"], desired, 0, maxInstanceCount, "ASG min size", warn)
+		maxSize := boundedInt(asgRaw["max_size"], desired*2, 1, maxInstanceCount*2, "ASG max size", warn)
+		if minSize > maxSize {
+			warn("ASG min size %d exceeds max size %d; swapping them", minSize, maxSize)
+			minSize, maxSize = maxSize, minSize
+		}
+		if desired < minSize || desired > maxSize {
+			warn("ASG desired capacity %d outside [%d, %d]; using min size", desired, minSize, maxSize)
+			desired = minSize
+		}
+		asg := map[string]interface{}{
+			"exists":                       true,
+			"desired_capacity":             desired,
+			"min_size":                     minSize,
+			"max_size":                     maxSize,
+			"instance_type":                instanceTypeValue(asgRaw["instance_type"], "t3.micro", warn),
+			"behind_alb":                   boolValue(asgRaw["behind_alb"], false),
+			"instance_refresh_min_healthy": boundedInt(asgRaw["instance_refresh_min_healthy"], 90, 0, 100, "instance refresh min healthy", warn),
+		}
+		if packages := stringSliceValue(asgRaw["user_data_packages"]); len(packages) > 0 {
+			asg["user_data_packages"] = packages
+		}
+		setName(asg, "name", asgRaw["name"], "ASG name", warn)
+		entities["asg"] = asg
+	}
+
+	if ec2Raw, ok := raw["ec2_instance"].(map[string]interface{}); ok {
+		ec2 := map[string]interface{}{
+			"exists":        true,
+			"count":         boundedInt(ec2Raw["count"], 1, 1, maxInstanceCount, "EC2 instance count", warn),
+			"instance_type": instanceTypeValue(ec2Raw["instance_type"], "t3.micro", warn),
+			"os":            "amazon-linux-2023",
+			"web":           boolValue(ec2Raw["web"], false),
+			"public":        boolValue(ec2Raw["public"], false),
+		}
+		if os := stringValue(ec2Raw["os"]); os != "" {
+			if os == "ubuntu" || os == "amazon-linux-2023" {
+				ec2["os"] = os
+			} else {
+				warn("unsupported EC2 operating system %q replaced with amazon-linux-2023", os)
+			}
+		}
+		if keyName := stringValue(ec2Raw["key_name"]); keyName != "" {
+			if keyNamePattern.MatchString(keyName) {
+				ec2["key_name"] = keyName
+			} else {
+				warn("invalid key pair name %q ignored", keyName)
+			}
+		}
+		if cidr := stringValue(ec2Raw["ssh_cidr"]); cidr != "" {
+			if validCIDR(cidr, 0, 32) {
+				ec2["ssh_cidr"] = cidr
+			} else {
+				warn("invalid SSH CIDR %q ignored", cidr)
+			}
+		}
+		setName(ec2, "name", ec2Raw["name"], "EC2 instance name", warn)
+		entities["ec2_instance"] = ec2
+	}
+
+	if regionsRaw, ok := raw["secondary_regions"].([]interface{}); ok {
+		var secondary []string
+		seen := map[string]bool{region: true}
+		for _, value := range regionsRaw {
+			secondaryRegion := strings.ToLower(stringValue(value))
+			if !fullRegionPattern.MatchString(secondaryRegion) {
+				warn("invalid secondary region %q ignored", secondaryRegion)
+				continue
+			}
+			if !seen[secondaryRegion] {
+				seen[secondaryRegion] = true
+				secondary = append(secondary, secondaryRegion)
+			}
+		}
+		if len(secondary) > 0 {
+			entities["secondary_regions"] = secondary
+		}
+	}
+
+	if tagsRaw, ok := raw["tags"].(map[string]interface{}); ok {
+		tags := make(map[string]interface{})
+		for key, value := range tagsRaw {
+			text := stringValue(value)
+			if err := infra.CheckTag(key, text); err != nil {
+				warn("%v; tag ignored", err)
+				continue
+			}
+			tags[key] = text
+		}
+		if len(tags) > 0 {
+			entities["tags"] = tags
+		}
+	}
+
+	if environment := strings.ToLower(stringValue(raw["environment"])); environment != "" {
+		if infra.ValidEnvironment(environment) {
+			entities["environment"] = environment
+		} else {
+			warn("invalid environment %q ignored", environment)
+		}
+	}
+
+	return entities, warnings
+}
+
+// objectValue returns a JSON object value or an empty map
+func objectValue(value interface{}) map[string]interface{} {
+	if m, ok := value.(map[string]interface{}); ok {
+		return m
+	}
+	return map[string]interface{}{}
+}
+
+// stringValue returns a trimmed JSON string value or an empty string
+func stringValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return strings.TrimSpace(s)
+	}
+	return ""
+}
+
+// setName copies a resource name into an entity when it is a valid resource name
+func setName(entity map[string]interface{}, field string, value interface{}, label string, warn func(string, ...interface{})) {
+	name := strings.ToLower(stringValue(value))
+	if name == "" {
+		return
+	}
+	if !infra.ValidResourceName(name) {
+		warn("invalid %s %q ignored", label, name)
+		return
+	}
+	entity[field] = name
+}
+
+// boolValue returns a JSON boolean value or the default
+func boolValue(value interface{}, defaultValue bool) bool {
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	return defaultValue
+}
+
+// intValue converts a JSON number (or numeric string) into an int
+func intValue(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case float64:
+		if v != float64(int(v)) {
+			return 0, false
+		}
+		return int(v), true
+	case json.Number:
+		n, err := strconv.Atoi(v.String())
+		return n, err == nil
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// boundedInt returns an integer value within [min, max], or the default when it is missing or invalid
+func boundedInt(value interface{}, defaultValue, min, max int, label string, warn func(string, ...interface{})) int {
+	if value == nil {
+		return defaultValue
+	}
+	n, ok := intValue(value)
+	if !ok {
+		warn("invalid %s %v replaced with %d", label, value, defaultValue)
+		return defaultValue
+	}
+	if n < min || n > max {
+		warn("%s %d outside [%d, %d] replaced with %d", label, n, min, max, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// nodeGroupsValue checks the node groups of an EKS entity. Sizes are bounded and ordered the
+// same way as Auto Scaling Group sizes; invalid entries are dropped.
+func nodeGroupsValue(value interface{}, warn func(string, ...interface{})) []interface{} {
+	entries, _ := value.([]interface{})
+	var nodeGroups []interface{}
+	for _, entry := range entries {
+		raw, ok := entry.(map[string]interface{})
+		if !ok {
+			warn("invalid node group %v ignored", entry)
+			continue
+		}
+
+		capacityType := strings.ToUpper(strings.ReplaceAll(stringValue(raw["capacity_type"]), "-", "_"))
+		if capacityType != "ON_DEMAND" && capacityType != "SPOT" {
+			if capacityType != "" {
+				warn("unsupported node group capacity type %q replaced with ON_DEMAND", capacityType)
+			}
+			capacityType = "ON_DEMAND"
+		}
+
+		desired := boundedInt(raw["desired_size"], 2, 1, maxNodeCount, "node group desired size", warn)
+		minSize := boundedInt(raw["min_size"], desired, 1, maxNodeCount, "node group min size", warn)
+		maxSize := boundedInt(raw["max_size"], desired*2, 1, maxNodeCount*2, "node group max size", warn)
+		if minSize > maxSize {
+			warn("node group min size %d exceeds max size %d; swapping them", minSize, maxSize)
+			minSize, maxSize = maxSize, minSize
+		}
+		if desired < minSize || desired > maxSize {
+			warn("node group desired size %d outside [%d, %d]; using min size", desired, minSize, maxSize)
+			desired = minSize
+		}
+
+		nodeGroup := map[string]interface{}{
+			"capacity_type": capacityType,
+			"instance_type": instanceTypeValue(raw["instance_type"], "t3.medium", warn),
+			"desired_size":  desired,
+			"min_size":      minSize,
+			"max_size":      maxSize,
+		}
+		var instanceTypes []string
+		for _, instanceType := range stringSliceValue(raw["instance_types"]) {
+			instanceType = strings.ToLower(instanceType)
+			if !instanceTypePattern.MatchString(instanceType) {
+				warn("invalid instance type %q ignored", instanceType)
+				continue
+			}
+			instanceTypes = append(instanceTypes, instanceType)
+		}
+		if len(instanceTypes) > 0 {
+			nodeGroup["instance_types"] = instanceTypes
+		}
+		nodeGroups = append(nodeGroups, nodeGroup)
+	}
+	return nodeGroups
+}
+
+// addonsValue checks the add-ons of an EKS entity. Unknown and repeated add-ons are dropped,
+// and a malformed version is dropped so the default version is installed.
+func addonsValue(value interface{}, warn func(string, ...interface{})) []interface{} {
+	entries, _ := value.([]interface{})
+	var addons []interface{}
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		raw, ok := entry.(map[string]interface{})
+		if !ok {
+			warn("invalid EKS add-on %v ignored", entry)
+			continue
+		}
+
+		name := strings.ToLower(stringValue(raw["name"]))
+		if _, ok := infra.LookupEKSAddon(name); !ok {
+			warn("unsupported EKS add-on %q ignored", name)
+			continue
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		addon := map[string]interface{}{"name": name}
+		if version := stringValue(raw["version"]); version != "" {
+			if infra.ValidEKSAddonVersion(version) {
+				addon["version"] = version
+			} else {
+				warn("invalid %s version %q ignored; the default version is installed", name, version)
+			}
+		}
+		addons = append(addons, addon)
+	}
+	return addons
+}
+
+// instanceTypeValue returns a well-formed instance type or the default
+func instanceTypeValue(value interface{}, defaultValue string, warn func(string, ...interface{})) string {
+	instanceType := strings.ToLower(stringValue(value))
+	if instanceType == "" {
+		return defaultValue
+	}
+	if !instanceTypePattern.MatchString(instanceType) {
+		warn("invalid instance type %q replaced with %s", instanceType, defaultValue)
+		return defaultValue
+	}
+	return instanceType
+}
+
+// stringSliceValue converts a JSON array of strings into a string slice
+func stringSliceValue(value interface{}) []string {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+	var result []string
+	for _, item := range items {
+		if s := stringValue(item); s != "" {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// validCIDR reports whether a CIDR block is a valid IPv4 network with a mask in [minMask, maxMask]
+func validCIDR(cidr string, minMask, maxMask int) bool {
+	ip, network, err := net.ParseCIDR(cidr)
+	if err != nil || ip.To4() == nil {
+		return false
+	}
+	mask, _ := network.Mask.Size()
+	return mask >= minMask && mask <= maxMask
+}
@@ -0,0 +1,85 @@
+package nlp
+
+import (
+	"regexp"
+)
+
+// negationPrefix matches the words that turn off what follows them: "no", "without",
+// "without any", "zero" or "0"
+const negationPrefix = `\b(?:no|without(?:\s+(?:a|an|any))?|zero|0)\s+`
+
+// negation is a phrase that removes a resource or disables a feature, and the change it
+// makes to the extracted entities
+type negation struct {
+	pattern *regexp.Regexp
+	apply   func(entities map[string]interface{})
+}
+
+// negations are checked in order after every entity has been extracted, so they win over
+// the defaults the extractors fill in
+var negations = []negation{
+	{
+		pattern: regexp.MustCompile(`(?i)` + negationPrefix + `public\s+subnets?\b|\bprivate[\s-]+only\s+(?:vpc|network)\b|\bonly\s+private\s+subnets\b|\bprivate\s+subnets\s+only\b`),
+		apply:   func(entities map[string]interface{}) { setEntityField(entities, "subnets", "public_count", 0) },
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)` + negationPrefix + `private\s+subnets?\b|\bpublic[\s-]+only\s+(?:vpc|network)\b|\bonly\s+public\s+subnets\b|\bpublic\s+subnets\s+only\b`),
+		apply:   func(entities map[string]interface{}) { setEntityField(entities, "subnets", "private_count", 0) },
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)` + negationPrefix + `(?:internet\s*gateways?|igws?)\b`),
+		apply:   func(entities map[string]interface{}) { setEntityField(entities, "gateways", "igw_count", 0) },
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)` + negationPrefix + `nat(?:\s*gateways?|s)?\b`),
+		apply:   func(entities map[string]interface{}) { setEntityField(entities, "gateways", "nat_count", 0) },
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)` + negationPrefix + `public\s+(?:api\s+)?(?:endpoint|api\s+access|access\s+to\s+the\s+api)\b`),
+		apply: func(entities map[string]interface{}) {
+			setEntityField(entities, "eks", "endpoint_public_access", false)
+			setEntityField(entities, "eks", "endpoint_private_access", true)
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)` + negationPrefix + `karpenter\b`),
+		apply:   func(entities map[string]interface{}) { setEntityField(entities, "eks", "karpenter", false) },
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)` + negationPrefix + `dns\s+hostnames\b`),
+		apply:   func(entities map[string]interface{}) { setEntityField(entities, "vpc", "enable_dns_hostnames", false) },
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)` + negationPrefix + `dns\s+(?:support|resolution)\b`),
+		apply:   func(entities map[string]interface{}) { setEntityField(entities, "vpc", "enable_dns_support", false) },
+	},
+}
+
+// applyNegations zeroes the counts and disables the features the description rules out,
+// such as "without a NAT gateway" or "a private-only VPC". A VPC without public subnets gets
+// no internet or NAT gateways either, because both need a public subnet to be of use.
+func applyNegations(entities map[string]interface{}, description string) {
+	negated := false
+	for _, n := range negations {
+		if n.pattern.MatchString(description) {
+			n.apply(entities)
+			negated = true
+		}
+	}
+	if !negated {
+		return
+	}
+
+	if subnets, ok := entities["subnets"].(map[string]interface{}); ok && subnets["public_count"] == 0 {
+		setEntityField(entities, "gateways", "igw_count", 0)
+		setEntityField(entities, "gateways", "nat_count", 0)
+	}
+	regenerateSubnetCIDRs(entities)
+}
+
+// setEntityField sets a field of an extracted entity, if the entity exists
+func setEntityField(entities map[string]interface{}, entity, field string, value interface{}) {
+	if fields, ok := entities[entity].(map[string]interface{}); ok {
+		fields[field] = value
+	}
+}
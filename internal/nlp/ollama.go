@@ -0,0 +1,141 @@
+package nlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/retry"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// Defaults for the Ollama backend
+const (
+	DefaultOllamaEndpoint = "http://localhost:11434"
+	DefaultOllamaModel    = "llama3.1"
+)
+
+// OllamaBackend extracts entities with a local model served by Ollama, so descriptions
+// can be parsed without sending them to a hosted API
+type OllamaBackend struct {
+	model    string
+	endpoint string
+	client   *http.Client
+	retry    retry.Policy
+}
+
+// ollamaRequest is the /api/chat request body. Format carries the JSON Schema that
+// constrains the model's output.
+type ollamaRequest struct {
+	Model    string                 `json:"model"`
+	Messages []chatMessage          `json:"messages"`
+	Stream   bool                   `json:"stream"`
+	Format   json.RawMessage        `json:"format"`
+	Options  map[string]interface{} `json:"options"`
+}
+
+// ollamaResponse is the subset of the /api/chat response the backend reads
+type ollamaResponse struct {
+	Message chatMessage `json:"message"`
+	Error   string      `json:"error"`
+}
+
+// NewOllamaBackend creates a new Ollama backend
+func NewOllamaBackend(config BackendConfig) *OllamaBackend {
+	backend := &OllamaBackend{
+		model:    config.Model,
+		endpoint: strings.TrimRight(config.Endpoint, "/"),
+		client:   &http.Client{Timeout: config.Timeout},
+		retry:    config.Retry,
+	}
+	if backend.model == "" {
+		backend.model = DefaultOllamaModel
+	}
+	if backend.endpoint == "" {
+		backend.endpoint = DefaultOllamaEndpoint
+	}
+	return backend
+}
+
+// Name implements Backend
+func (b *OllamaBackend) Name() string {
+	return BackendOllama
+}
+
+// ExtractEntities implements Backend
+func (b *OllamaBackend) ExtractEntities(ctx context.Context, description string) (map[string]interface{}, error) {
+	systemPrompt, err := EntityExtractionPrompt()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build prompt: %w", err)
+	}
+
+	body, err := json.Marshal(ollamaRequest{
+		Model: b.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: description},
+		},
+		Stream: false,
+		Format: json.RawMessage(EntitySchema),
+		Options: map[string]interface{}{
+			"temperature": 0,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode Ollama request: %w", err)
+	}
+
+	var resp *http.Response
+	var respBody []byte
+	err = retry.Do(ctx, b.retry, nil, logRetry(b.Name()), func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.endpoint+"/api/chat", bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to create Ollama request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err = b.client.Do(req)
+		if err != nil {
+			return retry.Transient(fmt.Errorf("Ollama request to %s failed: %w", b.endpoint, err))
+		}
+		defer resp.Body.Close()
+
+		respBody, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return retry.Transient(fmt.Errorf("failed to read Ollama response: %w", err))
+		}
+		return retryableStatus(resp.StatusCode)
+	})
+	if err != nil && !errors.Is(err, errRetryableStatus) {
+		return nil, err
+	}
+
+	var chat ollamaResponse
+	if err := json.Unmarshal(respBody, &chat); err != nil {
+		return nil, fmt.Errorf("failed to decode Ollama response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		message := http.StatusText(resp.StatusCode)
+		if chat.Error != "" {
+			message = chat.Error
+		}
+		return nil, fmt.Errorf("Ollama request failed with status %d: %s", resp.StatusCode, message)
+	}
+
+	raw, err := DecodeLLMEntities(chat.Message.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	entities, warnings := NormalizeEntities(raw, description)
+	for _, warning := range warnings {
+		utils.GetLogger().Warnw("Corrected LLM entity", "backend", b.Name(), "model", b.model, "detail", warning)
+	}
+
+	return entities, nil
+}
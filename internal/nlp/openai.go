@@ -0,0 +1,155 @@
+package nlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/retry"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// Defaults for the OpenAI backend
+const (
+	DefaultOpenAIEndpoint = "https://api.openai.com/v1"
+	DefaultOpenAIModel    = "gpt-4o-mini"
+)
+
+// OpenAIBackend extracts entities with the OpenAI chat completions API
+type OpenAIBackend struct {
+	apiKey   string
+	model    string
+	endpoint string
+	client   *http.Client
+	retry    retry.Policy
+}
+
+// chatMessage is a chat message in an OpenAI or Ollama request or response
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// openAIRequest is the chat completions request body
+type openAIRequest struct {
+	Model          string            `json:"model"`
+	Messages       []chatMessage     `json:"messages"`
+	Temperature    float64           `json:"temperature"`
+	ResponseFormat map[string]string `json:"response_format"`
+}
+
+// openAIResponse is the subset of the chat completions response the backend reads
+type openAIResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewOpenAIBackend creates a new OpenAI backend
+func NewOpenAIBackend(config BackendConfig) (*OpenAIBackend, error) {
+	if config.APIKey == "" {
+		return nil, errors.New("the llm NLP backend requires an OpenAI API key (set OPENAI_API_KEY)")
+	}
+
+	backend := &OpenAIBackend{
+		apiKey:   config.APIKey,
+		model:    config.Model,
+		endpoint: strings.TrimRight(config.Endpoint, "/"),
+		client:   &http.Client{Timeout: config.Timeout},
+		retry:    config.Retry,
+	}
+	if backend.model == "" {
+		backend.model = DefaultOpenAIModel
+	}
+	if backend.endpoint == "" {
+		backend.endpoint = DefaultOpenAIEndpoint
+	}
+	return backend, nil
+}
+
+// Name implements Backend
+func (b *OpenAIBackend) Name() string {
+	return "openai"
+}
+
+// ExtractEntities implements Backend
+func (b *OpenAIBackend) ExtractEntities(ctx context.Context, description string) (map[string]interface{}, error) {
+	systemPrompt, err := EntityExtractionPrompt()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build prompt: %w", err)
+	}
+
+	body, err := json.Marshal(openAIRequest{
+		Model: b.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: description},
+		},
+		Temperature:    0,
+		ResponseFormat: map[string]string{"type": "json_object"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OpenAI request: %w", err)
+	}
+
+	var resp *http.Response
+	var respBody []byte
+	err = retry.Do(ctx, b.retry, nil, logRetry(b.Name()), func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.endpoint+"/chat/completions", bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to create OpenAI request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+b.apiKey)
+
+		resp, err = b.client.Do(req)
+		if err != nil {
+			return retry.Transient(fmt.Errorf("OpenAI request failed: %w", err))
+		}
+		defer resp.Body.Close()
+
+		respBody, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return retry.Transient(fmt.Errorf("failed to read OpenAI response: %w", err))
+		}
+		return retryableStatus(resp.StatusCode)
+	})
+	if err != nil && !errors.Is(err, errRetryableStatus) {
+		return nil, err
+	}
+
+	var completion openAIResponse
+	if err := json.Unmarshal(respBody, &completion); err != nil {
+		return nil, fmt.Errorf("failed to decode OpenAI response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		message := http.StatusText(resp.StatusCode)
+		if completion.Error != nil {
+			message = completion.Error.Message
+		}
+		return nil, fmt.Errorf("OpenAI request failed with status %d: %s", resp.StatusCode, message)
+	}
+	if len(completion.Choices) == 0 {
+		return nil, errors.New("OpenAI response contained no choices")
+	}
+
+	raw, err := DecodeLLMEntities(completion.Choices[0].Message.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	entities, warnings := NormalizeEntities(raw, description)
+	for _, warning := range warnings {
+		utils.GetLogger().Warnw("Corrected LLM entity", "backend", b.Name(), "detail", warning)
+	}
+
+	return entities, nil
+}
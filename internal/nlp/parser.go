@@ -1,6 +1,7 @@
 package nlp
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -12,28 +13,52 @@ import (
 // Parser interfaces with NLP services to extract infrastructure entities
 type Parser struct {
 	// In a production implementation, this might include a client to an NLP service
+
+	// synonyms rewrite phrases the patterns do not know before extraction
+	synonyms *SynonymRegistry
 }
 
-// NewParser creates a new NLP parser
+// NewParser creates a new NLP parser with the built-in synonyms
 func NewParser() *Parser {
-	return &Parser{}
+	return NewParserWithSynonyms(nil)
+}
+
+// NewParserWithSynonyms creates a new NLP parser that rewrites descriptions with the given
+// synonyms; nil uses the built-in synonyms
+func NewParserWithSynonyms(synonyms *SynonymRegistry) *Parser {
+	if synonyms == nil {
+		synonyms = DefaultSynonyms()
+	}
+	return &Parser{synonyms: synonyms}
 }
 
 // ParseDescription parses a natural language description into an infrastructure model
 func ParseDescription(description string) (*models.InfrastructureModel, error) {
+	return ParseDescriptionWithBackend(context.Background(), description, NewRegexBackend())
+}
+
+// ParseDescriptionWithBackend parses a natural language description into an infrastructure
+// model, extracting entities with the given backend
+func ParseDescriptionWithBackend(ctx context.Context, description string, backend Backend) (*models.InfrastructureModel, error) {
+	model, _, err := ParseDescriptionWithReport(ctx, description, backend)
+	return model, err
+}
+
+// ParseDescriptionWithReport parses a natural language description into an infrastructure
+// model and reports the confidence in each entity and the defaults that were applied
+func ParseDescriptionWithReport(ctx context.Context, description string, backend Backend) (*models.InfrastructureModel, *ExtractionReport, error) {
 	// Validate the input description
 	if description == "" {
-		return nil, errors.New("description cannot be empty")
+		return nil, nil, errors.New("description cannot be empty")
 	}
 	
 	if len(description) < 5 {
-		return nil, errors.New("description is too short to be meaningful")
+		return nil, nil, errors.New("description is too short to be meaningful")
 	}
 
-	parser := NewParser()
-	entities, err := parser.ExtractEntities(description)
+	entities, err := backend.ExtractEntities(ctx, description)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Validate and fill in missing information
@@ -43,28 +68,42 @@ func ParseDescription(description string) (*models.InfrastructureModel, error) {
 		fmt.Println("Validation:", validationResult.Message)
 	}
 
+	// Assess the final entities, so defaults added by validation are reported too
+	report := AssessEntities(description, entities)
+
 	modelBuilder := infra.NewModelBuilder()
 	err = modelBuilder.BuildFromParsedEntities(entities)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return modelBuilder.GetModel(), nil
+	return modelBuilder.GetModel(), report, nil
+}
+
+// ExtractEntitiesWithReport extracts infrastructure entities from the description along
+// with the confidence in each entity and the defaults that were applied
+func (p *Parser) ExtractEntitiesWithReport(description string) (map[string]interface{}, *ExtractionReport, error) {
+	entities, err := p.ExtractEntities(description)
+	if err != nil {
+		return nil, nil, err
+	}
+	return entities, AssessEntities(description, entities), nil
 }
 
 // ExtractEntities extracts infrastructure entities from the description
 func (p *Parser) ExtractEntities(description string) (map[string]interface{}, error) {
 	entities := make(map[string]interface{})
 	
-	// Preprocess the description
-	description = strings.ToLower(description)
+	// Preprocess the description, keeping the original for tags, whose case matters
+	original := description
+	description = p.synonyms.Apply(strings.ToLower(description))
 	
-	// Extract AWS region
-	region := ExtractRegion(description)
+	// Extract AWS region, leaving out the regions of VPCs requested elsewhere
+	region := ExtractRegion(SecondaryVPCPattern.ReplaceAllString(description, ""))
 	entities["region"] = region
 	
-	// Extract VPC information
-	vpcInfo := ExtractVPC(description)
+	// Extract VPC information, leaving out the service CIDR of the cluster
+	vpcInfo := ExtractVPC(ServiceCIDRPattern.ReplaceAllString(description, ""))
 	if len(vpcInfo) > 0 && vpcInfo["exists"] == true {
 		entities["vpc"] = vpcInfo
 	}
@@ -80,7 +119,7 @@ func (p *Parser) ExtractEntities(description string) (map[string]interface{}, er
 				publicCount := subnetInfo["public_count"].(int)
 				privateCount := subnetInfo["private_count"].(int)
 				
-				publicCIDRs, privateCIDRs, err := infra.GenerateSubnetCIDRs(vpcCIDR, publicCount, privateCount)
+				publicCIDRs, privateCIDRs, err := infra.GenerateSubnetCIDRsWithPlan(vpcCIDR, publicCount, privateCount, infra.SubnetPlanFromEntity(subnetInfo))
 				if err == nil {
 					subnetInfo["public_cidrs"] = publicCIDRs
 					subnetInfo["private_cidrs"] = privateCIDRs
@@ -99,12 +138,120 @@ func (p *Parser) ExtractEntities(description string) (map[string]interface{}, er
 	eksInfo := ExtractEKS(description)
 	if len(eksInfo) > 0 && eksInfo["exists"] == true {
 		entities["eks"] = eksInfo
+		applyEKSSubnetDefaults(entities, description)
+	}
+	
+	// Extract Auto Scaling Group information
+	asgInfo := ExtractASG(description)
+	if len(asgInfo) > 0 && asgInfo["exists"] == true {
+		entities["asg"] = asgInfo
 	}
 	
+	// Extract standalone EC2 instance information
+	ec2Info := ExtractEC2(description)
+	if len(ec2Info) > 0 && ec2Info["exists"] == true {
+		entities["ec2_instance"] = ec2Info
+	}
+	
+	// Remove the resources and features the description rules out
+	applyNegations(entities, description)
+	
+	// Apply names given to resources in the description
+	applyResourceNames(entities, ExtractResourceNames(description))
+	
+	// Copy the VPC into the other regions the description asks for
+	applySecondaryRegions(entities, ExtractSecondaryRegions(description))
+	
 	// If no entities were extracted, return an error
 	if len(entities) <= 1 { // Only region is not enough
 		return nil, errors.New("could not extract any infrastructure entities from the description")
 	}
 	
+	// Apply tags requested for every resource
+	applyTags(entities, ExtractTags(original))
+	
+	// Name the environment the description is for
+	if environment := ExtractEnvironment(description); environment != "" {
+		entities["environment"] = environment
+	}
+	
 	return entities, nil
-}
\ No newline at end of file
+}
+
+// applyEKSSubnetDefaults replaces the single-zone subnet defaults of a description with an
+// EKS cluster. EKS needs subnets in two availability zones, and the cluster's nodes run in
+// the private subnets, so a layout the description leaves out spans two zones, and private
+// subnets it leaves out span the zones of the public subnets it states, or two. The counts
+// are listed among the assumptions, so stating them in the description overrides them.
+func applyEKSSubnetDefaults(entities map[string]interface{}, description string) {
+	subnets, ok := entities["subnets"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	switch {
+	case !subnetLayoutPattern.MatchString(description):
+		subnets["public_count"] = infra.MinEKSAvailabilityZones
+		subnets["private_count"] = infra.MinEKSAvailabilityZones
+	case !privateCountStated(strings.ToLower(description)):
+		subnets["private_count"] = eksPrivateCount(subnets)
+	default:
+		return
+	}
+	regenerateSubnetCIDRs(entities)
+}
+
+// eksPrivateCount returns the default private subnet count of an EKS cluster's subnets:
+// one in each availability zone of the public subnets, and at least two
+func eksPrivateCount(subnets map[string]interface{}) int {
+	publicCount, _ := subnets["public_count"].(int)
+	return max(publicCount, infra.MinEKSAvailabilityZones)
+}
+
+// applySecondaryRegions adds the regions of VPCs requested outside the primary region as
+// the "secondary_regions" entity. They are only kept when the primary region has a VPC.
+func applySecondaryRegions(entities map[string]interface{}, regions []string) {
+	if _, ok := entities["vpc"]; !ok {
+		return
+	}
+	var secondary []string
+	for _, region := range regions {
+		if region != entities["region"] {
+			secondary = append(secondary, region)
+		}
+	}
+	if len(secondary) > 0 {
+		entities["secondary_regions"] = secondary
+	}
+}
+
+// applyTags adds the tags requested for every resource as the "tags" entity. Tags that
+// cannot be applied to every resource, such as "Name", are ignored.
+func applyTags(entities map[string]interface{}, tags map[string]string) {
+	tagEntity := make(map[string]interface{})
+	for key, value := range tags {
+		if infra.CheckTag(key, value) == nil {
+			tagEntity[key] = value
+		}
+	}
+	if len(tagEntity) > 0 {
+		entities["tags"] = tagEntity
+	}
+}
+
+// applyResourceNames sets the names given in the description on the extracted entities.
+// Names of resources that were not extracted, and names that are not valid resource names,
+// are ignored.
+func applyResourceNames(entities map[string]interface{}, names map[string]string) {
+	for key, name := range names {
+		if !infra.ValidResourceName(name) {
+			continue
+		}
+		entityKey, field := key, "name"
+		if key == "node_group" {
+			entityKey, field = "eks", "node_group_name"
+		}
+		if entity, ok := entities[entityKey].(map[string]interface{}); ok {
+			entity[field] = name
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
 )
 
 // RegionPattern matches AWS region references
@@ -16,11 +18,19 @@ var VPCPattern = regexp.MustCompile(`(?i)vpc(?:\s+with\s+CIDR\s+(\d{1,3}\.\d{1,3
 var CIDRPattern = regexp.MustCompile(`\b(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}/\d{1,2})\b`)
 
 // SubnetPattern matches subnet references with type and count
-var SubnetPattern = regexp.MustCompile(`(?i)(\d+)\s+(public|private)\s+subnet`)
+var SubnetPattern = regexp.MustCompile(`(?i)(\d+)\s+(public|private)\s+(?:/\d{1,2}\s+)?subnet`)
 
 // AZPattern matches availability zone references
 var AZPattern = regexp.MustCompile(`(?i)(\d+)\s*az`)
 
+// SubnetMaskPattern matches requested subnet sizes, such as "/20 subnets", "/20 private
+// subnets", "subnets of /20" or "subnet size /22"
+var SubnetMaskPattern = regexp.MustCompile(`(?i)(?:^|[^\d.])/(\d{1,2})\s+(?:(?:public|private)\s+)?subnets?\b|\bsubnets?\s+(?:of|sized|size(?:\s+of)?)\s+/(\d{1,2})\b`)
+
+// ReservedAZPattern matches expansion space left for more availability zones, such as
+// "leave room for 6 AZs" or "reserve space for 4 availability zones"
+var ReservedAZPattern = regexp.MustCompile(`(?i)\b(?:room|space|capacity)\s+for\s+(\d+)\s+(?:azs?|availability\s+zones?)\b`)
+
 // IGWPattern matches internet gateway references
 var IGWPattern = regexp.MustCompile(`(?i)(\d+)?\s*(internet\s*gateway|igw)`)
 
@@ -33,12 +43,223 @@ var EKSPattern = regexp.MustCompile(`(?i)eks\s+cluster(?:\s+with\s+(public|priva
 // NodePoolPattern matches node pool references with optional instance type and count
 var NodePoolPattern = regexp.MustCompile(`(?i)(?:node\s*pool|nodepool)(?:\s+with\s+(\d+)\s+nodes?)?(?:\s+of\s+(\d+)\s+nodes?)?(?:\s+on\s+(t\d+\.[a-z]+|m\d+\.[a-z]+|c\d+\.[a-z]+))?`)
 
+// NodeGroupPattern matches a node group given with its capacity type, size and instance type,
+// such as "on-demand node group of 3 m5.large" or "spot node group of up to 10 t3.large"
+var NodeGroupPattern = regexp.MustCompile(`(?i)\b(on[\s-]?demand|spot)\s+node\s*groups?\s+(?:of|with)\s+(up\s+to\s+)?(\d+)\s+(?:x\s+)?(t\d+\.[a-z0-9]+|m\d+\.[a-z0-9]+|c\d+\.[a-z0-9]+)`)
+
+// SpotSharePattern matches the share of the worker nodes that run on spot capacity, such as
+// "80% spot" or "60% of the nodes on spot"
+var SpotSharePattern = regexp.MustCompile(`(?i)\b(\d{1,3})\s*%\s*(?:of\s+(?:the\s+)?(?:nodes|workers|worker\s+nodes|capacity)\s+(?:on|as|using)\s+)?spot\b`)
+
+// SpotWorkersPattern matches worker nodes that all run on spot capacity, such as "spot
+// instances for the workers" or "workers on spot"
+var SpotWorkersPattern = regexp.MustCompile(`(?i)\bspot\s+(?:instances|nodes|capacity)\s+for\s+(?:the\s+)?(?:workers|worker\s+nodes|nodes)\b|\b(?:workers|worker\s+nodes)\s+(?:on|using|run\s+on)\s+spot\b|\bspot\s+(?:workers|worker\s+nodes)\b`)
+
+// instanceTypeName matches an instance type of any family, such as m5a.large or c6g.2xlarge
+const instanceTypeName = `[a-z]\d+[a-z]*\.(?:nano|micro|small|medium|large|\d*xlarge|metal)`
+
+// MixedInstancesPattern matches the instance types a mixed-instances policy allows, such as
+// "mixed instances of m5.large, m5a.large and m5n.large"
+var MixedInstancesPattern = regexp.MustCompile(`(?i)\b(?:mixed[\s-]+instances?(?:\s+polic(?:y|ies))?|a\s+mix)\s+(?:of|with|across|using|over)\s+(` + instanceTypeName + `(?:(?:\s*,\s*(?:and\s+|or\s+)?|\s+(?:and|or)\s+)` + instanceTypeName + `)*)`)
+
+// eksAddonAliases map the ways a description names an EKS add-on to the add-on's EKS name
+var eksAddonAliases = []struct {
+	pattern string
+	name    string
+}{
+	{`vpc[\s-]?cni`, "vpc-cni"},
+	{`core[\s-]?dns`, "coredns"},
+	{`kube[\s-]?proxy`, "kube-proxy"},
+	{`(?:aws[\s-]+)?ebs[\s-]+csi(?:[\s-]+driver)?`, "aws-ebs-csi-driver"},
+	{`(?:aws[\s-]+)?efs[\s-]+csi(?:[\s-]+driver)?`, "aws-efs-csi-driver"},
+	{`(?:eks[\s-]+)?pod[\s-]+identity(?:[\s-]+agent)?`, "eks-pod-identity-agent"},
+}
+
+// EKSAddonPattern matches an EKS add-on with an optional pinned version, such as "coredns",
+// "the EBS CSI driver" or "vpc-cni v1.18.1-eksbuild.1"
+var EKSAddonPattern = regexp.MustCompile(`(?i)\b(` + eksAddonAlternatives() + `)\b(?:\s+(?:add-?on\s+)?(?:version\s+|pinned\s+to\s+|at\s+|@\s*)?(v?\d+\.\d+\.\d+-eksbuild\.\d+))?`)
+
+// eksAddonAlternatives joins the add-on aliases into one alternation
+func eksAddonAlternatives() string {
+	alternatives := make([]string, len(eksAddonAliases))
+	for i, alias := range eksAddonAliases {
+		alternatives[i] = alias.pattern
+	}
+	return strings.Join(alternatives, "|")
+}
+
+// eksControllerAliases map the ways a description names a controller needing an IAM role to
+// the controller's name
+var eksControllerAliases = []struct {
+	pattern string
+	name    string
+}{
+	{`(?:aws[\s-]+)?(?:alb|load[\s-]+balancer)[\s-]+controller`, "aws-load-balancer-controller"},
+	{`external[\s-]?dns`, "external-dns"},
+	{`cluster[\s-]+auto[\s-]?scaler`, "cluster-autoscaler"},
+}
+
+// EKSControllerPattern matches a controller needing an IAM role, such as "the ALB controller"
+// or "external-dns"
+var EKSControllerPattern = regexp.MustCompile(`(?i)\b(` + eksControllerAlternatives() + `)\b`)
+
+// eksControllerAlternatives joins the controller aliases into one alternation
+func eksControllerAlternatives() string {
+	alternatives := make([]string, len(eksControllerAliases))
+	for i, alias := range eksControllerAliases {
+		alternatives[i] = alias.pattern
+	}
+	return strings.Join(alternatives, "|")
+}
+
+// namespaceList matches one or more Kubernetes namespace names, such as "kube-system and app"
+const namespaceList = `[a-z0-9][a-z0-9-]*(?:(?:\s*,\s*(?:and\s+)?|\s+and\s+)[a-z0-9][a-z0-9-]*)*`
+
+// FargatePattern matches namespaces to run on Fargate, such as "run the kube-system and app
+// namespaces on fargate" or "a fargate profile for the app namespace"
+var FargatePattern = regexp.MustCompile(`(?i)\b(` + namespaceList + `)\s+namespaces?\s+(?:on|in|with)\s+fargate\b|\bfargate\s+profiles?\s+for\s+(?:the\s+)?(` + namespaceList + `)\s+namespaces?\b`)
+
+// ServiceCIDRPattern matches the CIDR block of the cluster's Kubernetes services, as in
+// "service CIDR 172.20.0.0/16" or "kubernetes service cidr of 10.100.0.0/16"
+var ServiceCIDRPattern = regexp.MustCompile(`(?i)\b(?:(?:kubernetes|k8s|cluster)\s+)?services?\s+(?:ipv4\s+)?cidr(?:\s+block)?\s+(?:of\s+|is\s+|=\s*)?(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}/\d{1,2})\b`)
+
+// KarpenterPattern matches Karpenter, which provisions the nodes of the cluster's pending pods
+var KarpenterPattern = regexp.MustCompile(`(?i)\bkarpenter\b`)
+
+// AutoscalingPattern matches how the cluster's nodes scale, as in "with cluster autoscaler",
+// "autoscaled by Karpenter" or "using Karpenter for autoscaling"
+var AutoscalingPattern = regexp.MustCompile(`(?i)\b(?:with|using|via|by|through)\s+(?:the\s+)?(?:(cluster[\s-]+auto[\s-]?scaler)|(karpenter))\b`)
+
+// namespaceSeparator splits a namespace list into names
+var namespaceSeparator = regexp.MustCompile(`\s*,\s*(?:and\s+)?|\s+and\s+`)
+
 // InstanceTypePattern matches instance type references
 var InstanceTypePattern = regexp.MustCompile(`(?i)(t\d+\.[a-z]+|m\d+\.[a-z]+|c\d+\.[a-z]+)`)
 
 // NumberPattern extracts standalone numbers
 var NumberPattern = regexp.MustCompile(`\b(\d+)\b`)
 
+// ASGPattern matches Auto Scaling Group references with optional size and instance type
+var ASGPattern = regexp.MustCompile(`(?i)(?:asg|auto\s*scaling\s+group)(?:\s+of\s+(\d+))?(?:\s+(t\d+\.[a-z0-9]+|m\d+\.[a-z0-9]+|c\d+\.[a-z0-9]+))?`)
+
+// ASGRangePattern matches scaling bounds such as "scaling between 2 and 6 instances"
+var ASGRangePattern = regexp.MustCompile(`(?i)(?:between|from)\s+(\d+)\s+(?:and|to)\s+(\d+)\s+instances?`)
+
+// UserDataPattern matches user data directives such as "user data that installs nginx and docker"
+var UserDataPattern = regexp.MustCompile(`(?i)user[\s-]?data\s+(?:that\s+)?install(?:s|ing)?\s+([a-z0-9\-]+(?:(?:,\s*|\s+and\s+)[a-z0-9\-]+)*)`)
+
+// InstanceRefreshPattern matches instance refresh settings with an optional minimum healthy percentage
+var InstanceRefreshPattern = regexp.MustCompile(`(?i)instance\s+refresh(?:\s+(?:with|at)\s+(\d{1,3})%\s+min(?:imum)?\s+healthy)?`)
+
+// EC2Pattern matches standalone EC2 instance references with optional count and instance type,
+// such as "3 t3.small ec2 instances"
+var EC2Pattern = regexp.MustCompile(`(?i)(?:(\d+)\s+)?(?:([tmcr]\d+[a-z]*\.[a-z0-9]+)\s+)?ec2\s+instances?`)
+
+// EC2TypePattern matches an instance type given after the EC2 reference, such as "ec2 instance of type m5.large"
+var EC2TypePattern = regexp.MustCompile(`(?i)ec2\s+instances?\s+(?:of\s+type\s+|with\s+instance\s+type\s+|using\s+|on\s+)?([tmcr]\d+[a-z]*\.[a-z0-9]+)`)
+
+// KeyPairPattern matches key pair references with an optional name, such as "key pair named deploy-key"
+var KeyPairPattern = regexp.MustCompile(`(?i)key\s*pair(?:\s+(?:named|called)\s+([a-z0-9][a-z0-9\-_]*))?`)
+
+// SSHAccessPattern matches SSH access restrictions such as "ssh access from 203.0.113.0/24"
+var SSHAccessPattern = regexp.MustCompile(`(?i)ssh(?:\s+access)?\s+from\s+(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}/\d{1,2})`)
+
+// namedResourceKinds are the resource words a name can be given to
+const namedResourceKinds = `eks\s+cluster|cluster|vpc|node\s*group|auto\s*scaling\s+group|asg|(?:ec2\s+)?instances?|(?:s3\s+)?bucket`
+
+// NamedResourcePattern matches a resource followed by its name, such as "vpc prod-network"
+// or "cluster named payments-eks"
+var NamedResourcePattern = regexp.MustCompile(`(?i)\b(` + namedResourceKinds + `)\s+(?:named\s+|called\s+|as\s+)?["']?([a-z][a-z0-9-]*[a-z0-9])["']?`)
+
+// NamingPattern matches resources given a name, either "a vpc named prod-network" or a
+// naming instruction such as "call the vpc prod-network and the cluster payments-eks"
+var NamingPattern = regexp.MustCompile(`(?i)\b(?:(?:call|name)\s+(?:the\s+)?(?:` + namedResourceKinds + `)\s+(?:as\s+)?["']?[a-z][a-z0-9-]*[a-z0-9]["']?(?:(?:\s*,\s*(?:and\s+)?|\s+and\s+)(?:the\s+)?(?:` + namedResourceKinds + `)\s+(?:as\s+)?["']?[a-z][a-z0-9-]*[a-z0-9]["']?)*|(?:` + namedResourceKinds + `)\s+(?:named|called)\s+["']?[a-z][a-z0-9-]*[a-z0-9]["']?)`)
+
+// namingStopWords are words that follow a resource word without naming it
+var namingStopWords = map[string]bool{
+	"with": true, "in": true, "and": true, "for": true, "to": true, "of": true, "on": true,
+	"using": true, "that": true, "which": true, "per": true, "across": true, "behind": true,
+}
+
+// ExtractResourceNames extracts the names given to resources in the description, keyed by
+// entity: "vpc", "eks", "node_group", "asg", "ec2_instance" or "s3_bucket"
+func ExtractResourceNames(description string) map[string]string {
+	names := make(map[string]string)
+	for _, naming := range NamingPattern.FindAllString(description, -1) {
+		for _, match := range NamedResourcePattern.FindAllStringSubmatch(naming, -1) {
+			name := strings.ToLower(match[2])
+			if namingStopWords[name] {
+				continue
+			}
+			key := namedResourceEntity(strings.ToLower(match[1]))
+			if _, ok := names[key]; !ok {
+				names[key] = name
+			}
+		}
+	}
+	return names
+}
+
+// namedResourceEntity maps a resource word to the entity that holds its name
+func namedResourceEntity(kind string) string {
+	kind = strings.Join(strings.Fields(kind), " ")
+	switch {
+	case kind == "vpc":
+		return "vpc"
+	case strings.HasSuffix(kind, "cluster"):
+		return "eks"
+	case strings.HasPrefix(kind, "node"):
+		return "node_group"
+	case kind == "asg" || strings.HasPrefix(kind, "auto"):
+		return "asg"
+	case strings.HasSuffix(kind, "bucket"):
+		return "s3_bucket"
+	default:
+		return "ec2_instance"
+	}
+}
+
+// tagPair matches one key=value tag; a value does not take a trailing full stop
+const tagPair = `[a-z][a-z0-9_.:/+@-]*\s*=\s*[a-z0-9_:/+@-]+(?:\.[a-z0-9_:/+@-]+)*`
+
+// TagDirectivePattern matches an instruction to tag every resource, such as "tag everything
+// with team=platform and cost-center=1234"
+var TagDirectivePattern = regexp.MustCompile(`(?i)\btag\s+(?:everything|all(?:\s+resources)?|every\s+resource|each\s+resource)\s+with\s+(?:the\s+)?(?:tags?\s+)?(` + tagPair + `(?:(?:\s*,\s*(?:and\s+)?|\s+and\s+)` + tagPair + `)*)`)
+
+// TagPairPattern matches the key and value of one tag in a tag directive
+var TagPairPattern = regexp.MustCompile(`(?i)([a-z][a-z0-9_.:/+@-]*)\s*=\s*([a-z0-9_:/+@-]+(?:\.[a-z0-9_:/+@-]+)*)`)
+
+// ExtractTags extracts the tags the description asks to apply to every resource. Keys and
+// values keep their case; a key given twice takes the last value.
+func ExtractTags(description string) map[string]string {
+	tags := make(map[string]string)
+	for _, directive := range TagDirectivePattern.FindAllStringSubmatch(description, -1) {
+		for _, pair := range TagPairPattern.FindAllStringSubmatch(directive[1], -1) {
+			tags[pair[1]] = pair[2]
+		}
+	}
+	return tags
+}
+
+// SecondaryVPCPattern matches a VPC requested in a region of its own, such as "a DR VPC in
+// us-west-2" or "a secondary vpc in eu-west-1"
+var SecondaryVPCPattern = regexp.MustCompile(`(?i)\b(?:dr|disaster[\s-]+recovery|secondary|second|standby|backup|failover)\s+vpcs?\s+in\s+(` + strings.TrimPrefix(RegionPattern.String(), "(?i)") + `)`)
+
+// ExtractSecondaryRegions extracts the regions of VPCs requested outside the primary
+// region, in the order they are mentioned
+func ExtractSecondaryRegions(description string) []string {
+	var regions []string
+	seen := make(map[string]bool)
+	for _, match := range SecondaryVPCPattern.FindAllStringSubmatch(description, -1) {
+		region := strings.ToLower(match[1])
+		if !seen[region] {
+			seen[region] = true
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
 // ExtractRegion extracts the AWS region from the description
 func ExtractRegion(description string) string {
 	match := RegionPattern.FindString(description)
@@ -108,7 +329,7 @@ func ExtractSubnets(description string) map[string]interface{} {
 	}
 	
 	// Special case for "X public and Y private subnets" pattern
-	combinedPattern := regexp.MustCompile(`(?i)(\d+)\s+public\s+and\s+(\d+)\s+private\s+subnet`)
+	combinedPattern := regexp.MustCompile(`(?i)(\d+)\s+public\s+and\s+(\d+)\s+private\s+(?:/\d{1,2}\s+)?subnet`)
 	combinedMatch := combinedPattern.FindStringSubmatch(description)
 	if len(combinedMatch) >= 3 {
 		if publicCount == 0 {
@@ -128,7 +349,7 @@ func ExtractSubnets(description string) map[string]interface{} {
 	
 	// If no subnet counts found, check for AZ count and assume 1 public and 1 private per AZ
 	if publicCount == 0 && privateCount == 0 {
-		azMatches := AZPattern.FindStringSubmatch(description)
+		azMatches := AZPattern.FindStringSubmatch(ReservedAZPattern.ReplaceAllString(description, ""))
 		if len(azMatches) >= 2 {
 			azCount, err := strconv.Atoi(azMatches[1])
 			if err == nil && azCount > 0 {
@@ -149,6 +370,20 @@ func ExtractSubnets(description string) map[string]interface{} {
 	subnets["public_count"] = publicCount
 	subnets["private_count"] = privateCount
 	
+	// CIDR planning directives; they are checked against the VPC when the CIDRs are planned
+	if match := SubnetMaskPattern.FindStringSubmatch(description); match != nil {
+		mask := match[1]
+		if mask == "" {
+			mask = match[2]
+		}
+		subnets["subnet_mask"], _ = strconv.Atoi(mask)
+	}
+	if match := ReservedAZPattern.FindStringSubmatch(description); match != nil {
+		if count, err := strconv.Atoi(match[1]); err == nil && count > 0 {
+			subnets["reserved_azs"] = count
+		}
+	}
+	
 	return subnets
 }
 
@@ -286,9 +521,415 @@ func ExtractEKS(description string) map[string]interface{} {
 		
 		eks["node_count"] = nodeCount
 		eks["instance_type"] = instanceType
+		
+		// Node groups given with a capacity type replace the single default node group, and
+		// so does a spot share of the worker nodes
+		if nodeGroups := ExtractNodeGroups(description); len(nodeGroups) > 0 {
+			eks["node_groups"] = nodeGroups
+		} else if nodeGroups := ExtractCapacityMix(description, nodeCount, instanceType); len(nodeGroups) > 0 {
+			eks["node_groups"] = nodeGroups
+		}
+		
+		if instanceTypes := ExtractMixedInstanceTypes(description); len(instanceTypes) > 0 {
+			applyMixedInstanceTypes(eks, instanceTypes)
+		}
+		
+		if addons := ExtractEKSAddons(description); len(addons) > 0 {
+			eks["addons"] = addons
+		}
+		
+		if namespaces := ExtractFargateNamespaces(description); len(namespaces) > 0 {
+			eks["fargate_namespaces"] = namespaces
+		}
+		
+		if serviceMatches := ServiceCIDRPattern.FindStringSubmatch(description); len(serviceMatches) > 1 {
+			eks["service_cidr"] = serviceMatches[1]
+		}
+		
+		if KarpenterPattern.MatchString(description) {
+			eks["karpenter"] = true
+		}
+		
+		if autoscaling := ExtractAutoscaling(description); autoscaling != "" {
+			eks["autoscaling"] = autoscaling
+		}
+		
+		if controllers := ExtractEKSControllers(description); len(controllers) > 0 {
+			eks["controllers"] = controllers
+		}
 	}
 	
 	return eks
 }
 
+// ExtractNodeGroups extracts the node groups given with a capacity type, in the order they are
+// mentioned. A group of N nodes scales between N and 2N like the default node group; a group
+// of "up to N" nodes starts at one node and scales up to N.
+func ExtractNodeGroups(description string) []interface{} {
+	var nodeGroups []interface{}
+	for _, match := range NodeGroupPattern.FindAllStringSubmatch(description, -1) {
+		count, err := strconv.Atoi(match[3])
+		if err != nil || count < 1 {
+			continue
+		}
+		
+		capacityType := "ON_DEMAND"
+		if strings.EqualFold(match[1], "spot") {
+			capacityType = "SPOT"
+		}
+		
+		desiredSize, minSize, maxSize := count, count, count*2
+		if match[2] != "" {
+			desiredSize, minSize, maxSize = 1, 1, count
+		}
+		
+		nodeGroups = append(nodeGroups, map[string]interface{}{
+			"capacity_type": capacityType,
+			"instance_type": strings.ToLower(match[4]),
+			"desired_size":  desiredSize,
+			"min_size":      minSize,
+			"max_size":      maxSize,
+		})
+	}
+	return nodeGroups
+}
+
+// ExtractCapacityMix extracts the node groups a spot share of the worker nodes calls for. "80%
+// spot" splits the nodes into an on-demand and a spot node group, keeping at least one node in
+// each; "spot instances for the workers" runs every node on spot. Each group scales up to twice
+// its size like the default node group.
+func ExtractCapacityMix(description string, nodeCount int, instanceType string) []interface{} {
+	spotCount := 0
+	if match := SpotSharePattern.FindStringSubmatch(description); match != nil {
+		percent, err := strconv.Atoi(match[1])
+		if err != nil || percent > 100 {
+			return nil
+		}
+		spotCount = (nodeCount*percent + 50) / 100
+		if percent > 0 && percent < 100 && nodeCount > 1 {
+			spotCount = max(1, min(spotCount, nodeCount-1))
+		}
+	} else if SpotWorkersPattern.MatchString(description) {
+		spotCount = nodeCount
+	}
+	if spotCount == 0 {
+		return nil
+	}
+	
+	var nodeGroups []interface{}
+	for _, group := range []struct {
+		capacityType string
+		count        int
+	}{{"ON_DEMAND", nodeCount - spotCount}, {"SPOT", spotCount}} {
+		if group.count == 0 {
+			continue
+		}
+		nodeGroups = append(nodeGroups, map[string]interface{}{
+			"capacity_type": group.capacityType,
+			"instance_type": instanceType,
+			"desired_size":  group.count,
+			"min_size":      group.count,
+			"max_size":      group.count * 2,
+		})
+	}
+	return nodeGroups
+}
+
+// ExtractMixedInstanceTypes extracts the instance types of a mixed-instances policy, in the
+// order they are mentioned
+func ExtractMixedInstanceTypes(description string) []string {
+	match := MixedInstancesPattern.FindStringSubmatch(description)
+	if match == nil {
+		return nil
+	}
+	var instanceTypes []string
+	seen := make(map[string]bool)
+	for _, instanceType := range regexp.MustCompile(instanceTypeName).FindAllString(strings.ToLower(match[1]), -1) {
+		if !seen[instanceType] {
+			seen[instanceType] = true
+			instanceTypes = append(instanceTypes, instanceType)
+		}
+	}
+	return instanceTypes
+}
+
+// applyMixedInstanceTypes sets the instance types a mixed-instances policy allows. Spot node
+// groups get them when there are any, since spot capacity is the reason to diversify;
+// otherwise every node group does, or the default node group when none are described.
+func applyMixedInstanceTypes(eks map[string]interface{}, instanceTypes []string) {
+	nodeGroups, _ := eks["node_groups"].([]interface{})
+	if len(nodeGroups) == 0 {
+		eks["instance_type"] = instanceTypes[0]
+		eks["instance_types"] = instanceTypes
+		return
+	}
+	spotOnly := false
+	for _, entry := range nodeGroups {
+		if nodeGroup, ok := entry.(map[string]interface{}); ok && nodeGroup["capacity_type"] == "SPOT" {
+			spotOnly = true
+		}
+	}
+	for _, entry := range nodeGroups {
+		nodeGroup, ok := entry.(map[string]interface{})
+		if ok && (!spotOnly || nodeGroup["capacity_type"] == "SPOT") {
+			nodeGroup["instance_types"] = instanceTypes
+		}
+	}
+}
+
+// ExtractEKSAddons extracts the EKS add-ons in the order they are mentioned, each with its
+// EKS name and, when the description pins one, its version
+func ExtractEKSAddons(description string) []interface{} {
+	var addons []interface{}
+	seen := make(map[string]bool)
+	for _, match := range EKSAddonPattern.FindAllStringSubmatch(description, -1) {
+		name := eksAddonName(match[1])
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		
+		addon := map[string]interface{}{"name": name}
+		if match[2] != "" {
+			version := strings.ToLower(match[2])
+			if !strings.HasPrefix(version, "v") {
+				version = "v" + version
+			}
+			addon["version"] = version
+		}
+		addons = append(addons, addon)
+	}
+	return addons
+}
+
+// ExtractEKSControllers extracts the controllers needing an IAM role in the order they are
+// mentioned, by name
+func ExtractEKSControllers(description string) []string {
+	var controllers []string
+	seen := make(map[string]bool)
+	for _, match := range EKSControllerPattern.FindAllStringSubmatch(description, -1) {
+		for _, candidate := range eksControllerAliases {
+			if regexp.MustCompile(`(?i)^(?:` + candidate.pattern + `)$`).MatchString(match[1]) && !seen[candidate.name] {
+				seen[candidate.name] = true
+				controllers = append(controllers, candidate.name)
+			}
+		}
+	}
+	return controllers
+}
+
+// ExtractAutoscaling extracts the autoscaling strategy of the cluster's nodes: the Cluster
+// Autoscaler or Karpenter, whichever the description first scales the nodes with
+func ExtractAutoscaling(description string) string {
+	match := AutoscalingPattern.FindStringSubmatch(description)
+	switch {
+	case match == nil:
+		return ""
+	case match[1] != "":
+		return infra.AutoscalingClusterAutoscaler
+	default:
+		return infra.AutoscalingKarpenter
+	}
+}
+
+// ExtractFargateNamespaces extracts the namespaces the description runs on Fargate, in the
+// order they are mentioned
+func ExtractFargateNamespaces(description string) []string {
+	var namespaces []string
+	seen := make(map[string]bool)
+	for _, match := range FargatePattern.FindAllStringSubmatch(description, -1) {
+		list := match[1]
+		if list == "" {
+			list = match[2]
+		}
+		for _, namespace := range namespaceSeparator.Split(strings.ToLower(list), -1) {
+			if namespace == "" || namespace == "the" || seen[namespace] {
+				continue
+			}
+			seen[namespace] = true
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces
+}
+
+// eksAddonName returns the EKS name of the add-on an alias names
+func eksAddonName(alias string) string {
+	for _, candidate := range eksAddonAliases {
+		if regexp.MustCompile(`(?i)^(?:` + candidate.pattern + `)$`).MatchString(alias) {
+			return candidate.name
+		}
+	}
+	return ""
+}
+
+// ExtractASG extracts Auto Scaling Group and launch template details from the description
+func ExtractASG(description string) map[string]interface{} {
+	asg := make(map[string]interface{})
+
+	asgMatches := ASGPattern.FindStringSubmatch(description)
+	if len(asgMatches) == 0 {
+		return asg
+	}
+	asg["exists"] = true
+
+	// Default sizing and instance type
+	desiredCapacity := 2
+	instanceType := "t3.micro"
+
+	if len(asgMatches) > 1 && asgMatches[1] != "" {
+		count, err := strconv.Atoi(asgMatches[1])
+		if err == nil && count > 0 {
+			desiredCapacity = count
+		}
+	}
+
+	if len(asgMatches) > 2 && asgMatches[2] != "" {
+		instanceType = asgMatches[2]
+	}
+
+	minSize := desiredCapacity
+	maxSize := desiredCapacity * 2
+
+	// Explicit scaling bounds override the derived ones
+	rangeMatches := ASGRangePattern.FindStringSubmatch(description)
+	if len(rangeMatches) >= 3 {
+		lower, errLower := strconv.Atoi(rangeMatches[1])
+		upper, errUpper := strconv.Atoi(rangeMatches[2])
+		if errLower == nil && errUpper == nil && lower <= upper {
+			minSize = lower
+			maxSize = upper
+			if desiredCapacity < minSize || desiredCapacity > maxSize {
+				desiredCapacity = minSize
+			}
+		}
+	}
+
+	asg["desired_capacity"] = desiredCapacity
+	asg["min_size"] = minSize
+	asg["max_size"] = maxSize
+	asg["instance_type"] = instanceType
+
+	// Check whether the group sits behind a load balancer
+	lowerDesc := strings.ToLower(description)
+	asg["behind_alb"] = strings.Contains(lowerDesc, "alb") || strings.Contains(lowerDesc, "load balancer")
+
+	// Extract packages to install through user data
+	userDataMatches := UserDataPattern.FindStringSubmatch(description)
+	if len(userDataMatches) > 1 && userDataMatches[1] != "" {
+		// The list ends at the first word that starts a new clause
+		stopWords := map[string]bool{"instance": true, "instances": true, "with": true, "behind": true, "in": true, "on": true}
+		var packages []string
+		for _, pkg := range regexp.MustCompile(`,\s*|\s+and\s+`).Split(userDataMatches[1], -1) {
+			pkg = strings.TrimSpace(pkg)
+			if stopWords[pkg] {
+				break
+			}
+			if pkg != "" {
+				packages = append(packages, pkg)
+			}
+		}
+		asg["user_data_packages"] = packages
+	}
+
+	// Instance refresh is always enabled, with a rolling strategy at 90% healthy unless specified
+	minHealthy := 90
+	refreshMatches := InstanceRefreshPattern.FindStringSubmatch(description)
+	if len(refreshMatches) > 1 && refreshMatches[1] != "" {
+		percent, err := strconv.Atoi(refreshMatches[1])
+		if err == nil && percent >= 0 && percent <= 100 {
+			minHealthy = percent
+		}
+	}
+	asg["instance_refresh_min_healthy"] = minHealthy
+
+	return asg
+}
+
+// ExtractEC2 extracts standalone EC2 instance details from the description
+func ExtractEC2(description string) map[string]interface{} {
+	ec2 := make(map[string]interface{})
+
+	// Instances launched by an Auto Scaling Group are not standalone instances
+	var matches []string
+	for _, loc := range EC2Pattern.FindAllStringSubmatchIndex(description, -1) {
+		if !describesASGInstances(description[:loc[0]]) {
+			matches = submatches(description, loc)
+			break
+		}
+	}
+	if len(matches) == 0 {
+		return ec2
+	}
+	ec2["exists"] = true
+
+	count := 1
+	if len(matches) > 1 && matches[1] != "" {
+		n, err := strconv.Atoi(matches[1])
+		if err == nil && n > 0 {
+			count = n
+		}
+	}
+	ec2["count"] = count
+
+	// The instance type may come before or after the EC2 reference
+	instanceType := "t3.micro"
+	if len(matches) > 2 && matches[2] != "" {
+		instanceType = matches[2]
+	} else if typeMatches := EC2TypePattern.FindStringSubmatch(description); len(typeMatches) > 1 {
+		instanceType = typeMatches[1]
+	}
+	ec2["instance_type"] = instanceType
+
+	// Operating system for the AMI lookup, Amazon Linux 2023 unless Ubuntu is requested
+	lowerDesc := strings.ToLower(description)
+	ec2["os"] = "amazon-linux-2023"
+	if strings.Contains(lowerDesc, "ubuntu") {
+		ec2["os"] = "ubuntu"
+	}
+
+	// Key pair for SSH access
+	if keyMatches := KeyPairPattern.FindStringSubmatch(description); len(keyMatches) > 0 {
+		keyName := "main-key"
+		if len(keyMatches) > 1 && keyMatches[1] != "" {
+			keyName = keyMatches[1]
+		}
+		ec2["key_name"] = keyName
+	}
+
+	if sshMatches := SSHAccessPattern.FindStringSubmatch(description); len(sshMatches) > 1 {
+		ec2["ssh_cidr"] = sshMatches[1]
+	}
+
+	// Instances serving web traffic get HTTP/HTTPS ingress and a public subnet
+	ec2["web"] = strings.Contains(lowerDesc, "web server") || strings.Contains(lowerDesc, "http")
+	ec2["public"] = strings.Contains(lowerDesc, "public ip") || strings.Contains(lowerDesc, "in public subnet") || strings.Contains(lowerDesc, "in the public subnet")
+
+	return ec2
+}
+
+// describesASGInstances reports whether the text just before an EC2 reference introduces an
+// Auto Scaling Group, as in "an auto scaling group of 3 ec2 instances"
+func describesASGInstances(before string) bool {
+	clause := strings.ToLower(before)
+	if idx := strings.LastIndexAny(clause, ".,;"); idx >= 0 {
+		clause = clause[idx+1:]
+	}
+	if idx := strings.LastIndex(clause, " and "); idx >= 0 {
+		clause = clause[idx+len(" and "):]
+	}
+	return ASGPattern.MatchString(clause)
+}
+
+// submatches returns the submatch strings for a match index slice
+func submatches(s string, loc []int) []string {
+	result := make([]string, len(loc)/2)
+	for i := range result {
+		if loc[2*i] >= 0 {
+			result[i] = s[loc[2*i]:loc[2*i+1]]
+		}
+	}
+	return result
+}
+
 // Note: The GenerateSubnetCIDRs function is now defined in the infra package to avoid circular imports
\ No newline at end of file
@@ -0,0 +1,221 @@
+package nlp
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// Resolutions recorded on a Conflict
+const (
+	ResolutionLLM   = "llm"
+	ResolutionRegex = "regex"
+)
+
+// derivedEntityKeys are computed from other values and never compared
+var derivedEntityKeys = map[string]bool{
+	"exists":        true,
+	"public_cidrs":  true,
+	"private_cidrs": true,
+}
+
+// Conflict records a value on which the LLM and regex extraction disagree
+type Conflict struct {
+	// Field is the entity path, such as "eks.node_count"
+	Field string
+	// LLMValue is the LLM's value (nil when the LLM missed the entity)
+	LLMValue interface{}
+	// RegexValue is the regex parser's value
+	RegexValue interface{}
+	// Resolution names the source whose value was kept
+	Resolution string
+}
+
+// String describes the conflict for logs and reports
+func (c Conflict) String() string {
+	if c.LLMValue == nil {
+		return fmt.Sprintf("%s: missed by the LLM, kept the regex result", c.Field)
+	}
+	return fmt.Sprintf("%s: llm=%v regex=%v, kept %s", c.Field, c.LLMValue, c.RegexValue, c.Resolution)
+}
+
+// ReconcileEntities merges LLM-extracted entities with regex-extracted ones.
+// The LLM output is the base. A regex value that differs from the LLM's wins when it is
+// stated verbatim in the description, such as a CIDR block, region or instance type,
+// since a literal match is more reliable than the model's reading. Entities found only by
+// the regex parser are added back. Every disagreement is returned as a Conflict.
+func ReconcileEntities(llm, regex map[string]interface{}, description string) (map[string]interface{}, []Conflict) {
+	merged := make(map[string]interface{}, len(llm))
+	for key, value := range llm {
+		merged[key] = value
+	}
+
+	var conflicts []Conflict
+	lowerDesc := strings.ToLower(description)
+
+	for _, key := range utils.SortedKeys(regex) {
+		regexValue := regex[key]
+		llmValue, ok := merged[key]
+		if !ok {
+			merged[key] = regexValue
+			conflicts = append(conflicts, Conflict{Field: key, RegexValue: regexValue, Resolution: ResolutionRegex})
+			continue
+		}
+
+		regexEntity, regexIsEntity := regexValue.(map[string]interface{})
+		llmEntity, llmIsEntity := llmValue.(map[string]interface{})
+		if !regexIsEntity || !llmIsEntity {
+			if conflict, differs := reconcileValue(key, llmValue, regexValue, lowerDesc); differs {
+				if conflict.Resolution == ResolutionRegex {
+					merged[key] = regexValue
+				}
+				conflicts = append(conflicts, conflict)
+			}
+			continue
+		}
+
+		entity := make(map[string]interface{}, len(llmEntity))
+		for field, value := range llmEntity {
+			entity[field] = value
+		}
+		for _, field := range utils.SortedKeys(regexEntity) {
+			if derivedEntityKeys[field] {
+				continue
+			}
+			value, ok := entity[field]
+			if !ok {
+				entity[field] = regexEntity[field]
+				continue
+			}
+			if conflict, differs := reconcileValue(key+"."+field, value, regexEntity[field], lowerDesc); differs {
+				if conflict.Resolution == ResolutionRegex {
+					entity[field] = regexEntity[field]
+				}
+				conflicts = append(conflicts, conflict)
+			}
+		}
+		merged[key] = entity
+	}
+
+	regenerateSubnetCIDRs(merged)
+	return merged, conflicts
+}
+
+// reconcileValue compares one value from each source and decides which to keep
+func reconcileValue(field string, llmValue, regexValue interface{}, lowerDesc string) (Conflict, bool) {
+	if reflect.DeepEqual(llmValue, regexValue) {
+		return Conflict{}, false
+	}
+
+	// Values the regex parser fell back to a default for are not real disagreements
+	stated := statedInDescription(regexValue, lowerDesc)
+	if _, isBool := regexValue.(bool); !isBool && !stated {
+		return Conflict{}, false
+	}
+
+	resolution := ResolutionLLM
+	if stated && !statedInDescription(llmValue, lowerDesc) {
+		resolution = ResolutionRegex
+	}
+	return Conflict{Field: field, LLMValue: llmValue, RegexValue: regexValue, Resolution: resolution}, true
+}
+
+// statedInDescription reports whether a scalar value appears as a whole word in the description
+func statedInDescription(value interface{}, lowerDesc string) bool {
+	switch value.(type) {
+	case string, int:
+	default:
+		return false
+	}
+	text := strings.ToLower(fmt.Sprint(value))
+	if text == "" {
+		return false
+	}
+	pattern := regexp.MustCompile(`(^|[^a-z0-9./-])` + regexp.QuoteMeta(text) + `($|[^a-z0-9/-])`)
+	return pattern.MatchString(lowerDesc)
+}
+
+// regenerateSubnetCIDRs recomputes subnet CIDRs after the VPC CIDR or subnet counts changed
+func regenerateSubnetCIDRs(entities map[string]interface{}) {
+	vpc, ok := entities["vpc"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	subnets, ok := entities["subnets"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	cidr, _ := vpc["cidr_block"].(string)
+	publicCount, _ := subnets["public_count"].(int)
+	privateCount, _ := subnets["private_count"].(int)
+
+	if publicCIDRs, privateCIDRs, err := infra.GenerateSubnetCIDRsWithPlan(cidr, publicCount, privateCount, infra.SubnetPlanFromEntity(subnets)); err == nil {
+		subnets["public_cidrs"] = publicCIDRs
+		subnets["private_cidrs"] = privateCIDRs
+	}
+}
+
+// ReconcilingBackend runs an LLM backend alongside the regex parser and reconciles the results.
+// When the LLM request fails, the regex result is used on its own.
+type ReconcilingBackend struct {
+	llm   Backend
+	regex Backend
+}
+
+// NewReconcilingBackend creates a backend that merges LLM output with regex extraction
+func NewReconcilingBackend(llm Backend, regex Backend) *ReconcilingBackend {
+	return &ReconcilingBackend{
+		llm:   llm,
+		regex: regex,
+	}
+}
+
+// Name implements Backend
+func (b *ReconcilingBackend) Name() string {
+	return b.llm.Name()
+}
+
+// ExtractEntities implements Backend
+func (b *ReconcilingBackend) ExtractEntities(ctx context.Context, description string) (map[string]interface{}, error) {
+	logger := utils.GetLogger()
+
+	regexEntities, regexErr := b.regex.ExtractEntities(ctx, description)
+	llmEntities, llmErr := b.llm.ExtractEntities(ctx, description)
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	switch {
+	case llmErr != nil && regexErr != nil:
+		return nil, fmt.Errorf("%s backend failed: %v; regex fallback failed: %w", b.llm.Name(), llmErr, regexErr)
+	case llmErr != nil:
+		logger.Warnw("NLP backend failed, falling back",
+			"backend", b.llm.Name(),
+			"fallback", b.regex.Name(),
+			"error", llmErr.Error(),
+		)
+		return regexEntities, nil
+	case regexErr != nil:
+		// Nothing matched the patterns, so there is nothing to reconcile against
+		return llmEntities, nil
+	}
+
+	merged, conflicts := ReconcileEntities(llmEntities, regexEntities, description)
+	for _, conflict := range conflicts {
+		logger.Warnw("NLP backends disagree",
+			"backend", b.llm.Name(),
+			"field", conflict.Field,
+			"llm", conflict.LLMValue,
+			"regex", conflict.RegexValue,
+			"kept", conflict.Resolution,
+		)
+	}
+
+	return merged, nil
+}
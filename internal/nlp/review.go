@@ -0,0 +1,263 @@
+package nlp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// ErrReviewAborted is returned when generation is aborted during a review
+var ErrReviewAborted = errors.New("generation aborted during review")
+
+// ReviewEntry is one value of the extracted entities, such as "subnets.public_count"
+type ReviewEntry struct {
+	// Path is the entity and field names joined by dots
+	Path  string
+	Value interface{}
+}
+
+// Review is what is shown before generation: the extracted entities and the resources
+// they produce
+type Review struct {
+	Entries   []ReviewEntry
+	Resources []models.Resource
+	// Error explains why the previous edit was rejected, if it was
+	Error string
+}
+
+// Text renders the review as two tables, the values and the planned resources
+func (r Review) Text() string {
+	var buf bytes.Buffer
+
+	buf.WriteString("Parsed values\n")
+	buf.WriteString("-------------\n")
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for _, entry := range r.Entries {
+		fmt.Fprintf(w, "  %s\t%s\n", entry.Path, formatReviewValue(entry.Value))
+	}
+	w.Flush()
+
+	buf.WriteString("\nPlanned resources\n")
+	buf.WriteString("-----------------\n")
+	w = tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for _, resource := range r.Resources {
+		fmt.Fprintf(w, "  %s\t%s\n", resource.Type, resource.Name)
+	}
+	w.Flush()
+	buf.WriteString(fmt.Sprintf("  %d resources\n", len(r.Resources)))
+
+	if r.Error != "" {
+		buf.WriteString(fmt.Sprintf("\nEdit rejected: %s\n", r.Error))
+	}
+	return buf.String()
+}
+
+// ReviewAction is what the reviewer decided to do with a review
+type ReviewAction string
+
+// Review actions
+const (
+	ReviewAccept ReviewAction = "accept"
+	ReviewAbort  ReviewAction = "abort"
+	ReviewEdit   ReviewAction = "edit"
+)
+
+// ReviewDecision is the answer to a review. An edit sets the value at Path and shows the
+// review again.
+type ReviewDecision struct {
+	Action ReviewAction
+	Path   string
+	Value  string
+}
+
+// Reviewer reviews the extracted entities before generation, usually by prompting the user
+type Reviewer interface {
+	// Review returns whether to accept the entities, abort, or edit one of their values
+	Review(ctx context.Context, review Review) (ReviewDecision, error)
+}
+
+// ReviewingBackend shows the entities extracted by another backend to a reviewer, who can
+// edit them, and accept or abort, before the model is built
+type ReviewingBackend struct {
+	backend  Backend
+	reviewer Reviewer
+}
+
+// NewReviewingBackend creates a backend that has its entities reviewed before they are used
+func NewReviewingBackend(backend Backend, reviewer Reviewer) *ReviewingBackend {
+	return &ReviewingBackend{
+		backend:  backend,
+		reviewer: reviewer,
+	}
+}
+
+// Name implements Backend
+func (b *ReviewingBackend) Name() string {
+	return b.backend.Name()
+}
+
+// ExtractEntities implements Backend
+func (b *ReviewingBackend) ExtractEntities(ctx context.Context, description string) (map[string]interface{}, error) {
+	entities, err := b.backend.ExtractEntities(ctx, description)
+	if err != nil {
+		return nil, err
+	}
+
+	var rejected error
+	for {
+		// Fill in the defaults now, so they are shown and can be edited too
+		ValidateEntities(entities)
+		review := Review{Entries: ReviewEntries(entities)}
+		builder := infra.NewModelBuilder()
+		if err := builder.BuildFromParsedEntities(entities); err != nil {
+			return nil, err
+		}
+		review.Resources = builder.GetModel().Resources
+		if rejected != nil {
+			review.Error = rejected.Error()
+		}
+
+		decision, err := b.reviewer.Review(ctx, review)
+		if err != nil {
+			return nil, fmt.Errorf("failed to review entities: %w", err)
+		}
+		switch decision.Action {
+		case ReviewAccept:
+			return entities, nil
+		case ReviewAbort:
+			return nil, ErrReviewAborted
+		case ReviewEdit:
+			rejected = EditEntity(entities, decision.Path, decision.Value)
+			if rejected == nil {
+				utils.GetLogger().Debugw("Applied review edit", "path", decision.Path, "value", decision.Value)
+			}
+		default:
+			return nil, fmt.Errorf("unknown review action %q", decision.Action)
+		}
+	}
+}
+
+// ReviewEntries flattens the entities into the values a review shows, sorted by path.
+// Nested entities, such as node groups, are shown as a whole.
+func ReviewEntries(entities map[string]interface{}) []ReviewEntry {
+	var entries []ReviewEntry
+	for _, key := range utils.SortedKeys(entities) {
+		switch value := entities[key].(type) {
+		case map[string]interface{}:
+			for _, field := range utils.SortedKeys(value) {
+				entries = append(entries, ReviewEntry{Path: key + "." + field, Value: value[field]})
+			}
+		case map[string]string:
+			fields := make([]string, 0, len(value))
+			for field := range value {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			for _, field := range fields {
+				entries = append(entries, ReviewEntry{Path: key + "." + field, Value: value[field]})
+			}
+		default:
+			entries = append(entries, ReviewEntry{Path: key, Value: value})
+		}
+	}
+	return entries
+}
+
+// EditEntity sets the value at a path such as "eks.node_count". The value is converted to
+// the type of the value it replaces; a new field is a number, true or false, or text. Lists
+// are comma separated. Editing the VPC CIDR or the subnet counts plans the subnet CIDRs again.
+func EditEntity(entities map[string]interface{}, path, value string) error {
+	value = strings.TrimSpace(value)
+	key, field, nested := strings.Cut(strings.TrimSpace(path), ".")
+	current, ok := entities[key]
+	if !ok {
+		return fmt.Errorf("%s is not one of the parsed entities", key)
+	}
+
+	if !nested {
+		converted, err := convertReviewValue(path, current, value)
+		if err != nil {
+			return err
+		}
+		entities[key] = converted
+		return nil
+	}
+
+	switch fields := current.(type) {
+	case map[string]interface{}:
+		converted, err := convertReviewValue(path, fields[field], value)
+		if err != nil {
+			return err
+		}
+		fields[field] = converted
+	case map[string]string:
+		fields[field] = value
+	default:
+		return fmt.Errorf("%s has no fields", key)
+	}
+
+	switch path {
+	case "vpc.cidr_block", "subnets.public_count", "subnets.private_count":
+		regenerateSubnetCIDRs(entities)
+	}
+	return nil
+}
+
+// convertReviewValue converts an edited value to the type of the value it replaces
+func convertReviewValue(path string, current interface{}, value string) (interface{}, error) {
+	switch current.(type) {
+	case nil:
+		if number, err := strconv.Atoi(value); err == nil {
+			return number, nil
+		}
+		if flag, err := strconv.ParseBool(value); err == nil {
+			return flag, nil
+		}
+		return value, nil
+	case int:
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a whole number, got %q", path, value)
+		}
+		return number, nil
+	case bool:
+		flag, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be true or false, got %q", path, value)
+		}
+		return flag, nil
+	case string:
+		return value, nil
+	case []string:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("%s cannot be edited in a review; describe it instead", path)
+	}
+}
+
+// formatReviewValue formats a value for the review table
+func formatReviewValue(value interface{}) string {
+	switch v := value.(type) {
+	case []string:
+		return strings.Join(v, ", ")
+	case string:
+		return v
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
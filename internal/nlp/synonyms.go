@@ -0,0 +1,182 @@
+package nlp
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// resourcePhrases are the phrases the patterns recognize for each resource type a synonym
+// can stand for
+var resourcePhrases = map[models.ResourceType]string{
+	models.ResourceVPC:              "vpc",
+	models.ResourceSubnet:           "subnet",
+	models.ResourceIGW:              "internet gateway",
+	models.ResourceNATGateway:       "nat gateway",
+	models.ResourceEKSCluster:       "eks cluster",
+	models.ResourceNodeGroup:        "node group",
+	models.ResourceAutoScalingGroup: "auto scaling group",
+	models.ResourceEC2Instance:      "ec2 instance",
+}
+
+// synonymPhrasePattern limits phrases to words, so they can be matched on word boundaries
+var synonymPhrasePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9 .-]*[a-z0-9]$|^[a-z0-9]$`)
+
+// SynonymRegistry maps phrases to the wording the parser's patterns understand, such as
+// "k8s cluster" to "eks cluster". Descriptions are rewritten with it before entities are
+// extracted, so new phrasings can be supported without new patterns. It is safe for
+// concurrent use, as the parsers of concurrent environments and server requests share it.
+type SynonymRegistry struct {
+	meanings map[string]string
+	// pattern matches every registered phrase, and is compiled when one is registered
+	pattern *regexp.Regexp
+	mutex   sync.RWMutex
+}
+
+// NewSynonymRegistry creates an empty synonym registry
+func NewSynonymRegistry() *SynonymRegistry {
+	return &SynonymRegistry{meanings: make(map[string]string)}
+}
+
+// DefaultSynonyms returns a registry with the built-in synonyms
+func DefaultSynonyms() *SynonymRegistry {
+	r := NewSynonymRegistry()
+	for _, phrase := range []string{"kubernetes cluster", "k8s cluster", "kube cluster"} {
+		r.RegisterResource(phrase, models.ResourceEKSCluster)
+	}
+	r.RegisterResource("virtual private cloud", models.ResourceVPC)
+	r.Register("virtual machines", "ec2 instances")
+	r.RegisterResource("virtual machine", models.ResourceEC2Instance)
+	r.Register("nat gw", "nat gateway")
+	return r
+}
+
+// Register maps a phrase to the wording that replaces it. A phrase registered again takes
+// the new meaning.
+func (r *SynonymRegistry) Register(phrase, meaning string) error {
+	phrase = normalizePhrase(phrase)
+	meaning = normalizePhrase(meaning)
+	if !synonymPhrasePattern.MatchString(phrase) {
+		return fmt.Errorf("synonym %q must be words of letters, digits, dots and hyphens", phrase)
+	}
+	if meaning == "" {
+		return fmt.Errorf("synonym %q has no meaning", phrase)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.meanings[phrase] = meaning
+	r.pattern = compileSynonyms(r.meanings)
+	return nil
+}
+
+// compileSynonyms returns a pattern matching each phrase on word boundaries. Longer phrases
+// are tried first, so that they win over the shorter phrases they contain.
+func compileSynonyms(meanings map[string]string) *regexp.Regexp {
+	phrases := make([]string, 0, len(meanings))
+	for phrase := range meanings {
+		phrases = append(phrases, phrase)
+	}
+	sort.Slice(phrases, func(i, j int) bool {
+		if len(phrases[i]) != len(phrases[j]) {
+			return len(phrases[i]) > len(phrases[j])
+		}
+		return phrases[i] < phrases[j]
+	})
+	alternatives := make([]string, len(phrases))
+	for i, phrase := range phrases {
+		alternatives[i] = strings.ReplaceAll(regexp.QuoteMeta(phrase), " ", `\s+`)
+	}
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(alternatives, "|") + `)\b`)
+}
+
+// RegisterResource maps a phrase to a resource type the parser recognizes
+func (r *SynonymRegistry) RegisterResource(phrase string, resourceType models.ResourceType) error {
+	meaning, ok := resourcePhrases[resourceType]
+	if !ok {
+		return fmt.Errorf("synonym %q: resource type %q is not recognized in descriptions", phrase, resourceType)
+	}
+	return r.Register(phrase, meaning)
+}
+
+// Apply replaces every registered phrase in a description with its meaning. Longer phrases
+// win over the shorter phrases they contain, and replacements are not applied again.
+func (r *SynonymRegistry) Apply(description string) string {
+	if r == nil {
+		return description
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.pattern == nil {
+		return description
+	}
+	return r.pattern.ReplaceAllStringFunc(description, func(match string) string {
+		return r.meanings[normalizePhrase(match)]
+	})
+}
+
+// SynonymEntry is one entry of a synonym file. Phrase stands for either a resource type,
+// such as eks_cluster, or the wording in Means.
+type SynonymEntry struct {
+	Phrase   string `yaml:"phrase"`
+	Resource string `yaml:"resource,omitempty"`
+	Means    string `yaml:"means,omitempty"`
+}
+
+// LoadFile adds the synonyms of a YAML file to the registry:
+//
+//	synonyms:
+//	  - phrase: kube
+//	    resource: eks_cluster
+//	  - phrase: ha nat
+//	    means: nat gateway per az
+func (r *SynonymRegistry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read synonyms: %w", err)
+	}
+
+	var file struct {
+		Synonyms []SynonymEntry `yaml:"synonyms"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return fmt.Errorf("failed to parse synonyms in %s: %w", path, err)
+	}
+	if len(file.Synonyms) == 0 {
+		return fmt.Errorf("no synonyms in %s", path)
+	}
+
+	var problems []string
+	for i, entry := range file.Synonyms {
+		var err error
+		switch {
+		case (entry.Resource == "") == (entry.Means == ""):
+			err = fmt.Errorf("synonym %q needs either a resource or a meaning", entry.Phrase)
+		case entry.Resource != "":
+			err = r.RegisterResource(entry.Phrase, models.ResourceType(entry.Resource))
+		default:
+			err = r.Register(entry.Phrase, entry.Means)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("synonyms[%d]: %v", i, err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid synonyms in %s: %s", path, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// normalizePhrase lowercases a phrase and collapses its whitespace
+func normalizePhrase(phrase string) string {
+	return strings.Join(strings.Fields(strings.ToLower(phrase)), " ")
+}
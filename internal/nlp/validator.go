@@ -89,7 +89,7 @@ func ValidateEntities(entities map[string]interface{}) *ValidationResult {
 				privateCount := subnets["private_count"].(int)
 				
 				// Generate subnet CIDRs
-				publicCIDRs, privateCIDRs, err := infra.GenerateSubnetCIDRs(cidr, publicCount, privateCount)
+				publicCIDRs, privateCIDRs, err := infra.GenerateSubnetCIDRsWithPlan(cidr, publicCount, privateCount, infra.SubnetPlanFromEntity(subnets))
 				if err == nil {
 					subnets["public_cidrs"] = publicCIDRs
 					subnets["private_cidrs"] = privateCIDRs
@@ -172,6 +172,63 @@ func ValidateEntities(entities map[string]interface{}) *ValidationResult {
 		}
 	}
 
+	// Check if Auto Scaling Group configuration is complete
+	if asg, ok := entities["asg"].(map[string]interface{}); ok {
+		// Ensure instance type is set
+		if _, ok := asg["instance_type"]; !ok {
+			asg["instance_type"] = "t3.micro"
+			result.Fixes["asg_instance_type"] = "t3.micro"
+			messages = append(messages, "Added default ASG instance type (t3.micro)")
+		}
+		
+		// Ensure the group size is set
+		if _, ok := asg["desired_capacity"]; !ok {
+			asg["desired_capacity"] = 2
+			result.Fixes["asg_desired_capacity"] = 2
+			messages = append(messages, "Added default ASG desired capacity (2)")
+		}
+		
+		desired, _ := asg["desired_capacity"].(int)
+		if _, ok := asg["min_size"]; !ok {
+			asg["min_size"] = desired
+			result.Fixes["asg_min_size"] = desired
+		}
+		if _, ok := asg["max_size"]; !ok {
+			asg["max_size"] = desired * 2
+			result.Fixes["asg_max_size"] = desired * 2
+		}
+		
+		// Ensure instance refresh settings are set
+		if _, ok := asg["instance_refresh_min_healthy"]; !ok {
+			asg["instance_refresh_min_healthy"] = 90
+			result.Fixes["asg_instance_refresh_min_healthy"] = 90
+			messages = append(messages, "Added default instance refresh minimum healthy percentage (90)")
+		}
+	}
+
+	// Check if EC2 instance configuration is complete
+	if ec2, ok := entities["ec2_instance"].(map[string]interface{}); ok {
+		// Ensure instance type is set
+		if _, ok := ec2["instance_type"]; !ok {
+			ec2["instance_type"] = "t3.micro"
+			result.Fixes["ec2_instance_type"] = "t3.micro"
+			messages = append(messages, "Added default EC2 instance type (t3.micro)")
+		}
+		
+		// Ensure instance count is set
+		if _, ok := ec2["count"]; !ok {
+			ec2["count"] = 1
+			result.Fixes["ec2_count"] = 1
+		}
+		
+		// Ensure the operating system is set for the AMI lookup
+		if _, ok := ec2["os"]; !ok {
+			ec2["os"] = "amazon-linux-2023"
+			result.Fixes["ec2_os"] = "amazon-linux-2023"
+			messages = append(messages, "Added default EC2 operating system (Amazon Linux 2023)")
+		}
+	}
+
 	// Set validation result
 	if len(messages) > 0 {
 		// In this case, the validation is still successful, but we've made modifications
@@ -0,0 +1,223 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/spec"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/internal/version"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"go.uber.org/zap"
+)
+
+// DefaultCacheDir is the directory checkpoints are saved under, relative to the working
+// directory
+const DefaultCacheDir = ".iacgen/cache"
+
+// Checkpoint artifacts: the model parsed from the description, and the model built from it
+const (
+	CheckpointParsed = "parsed"
+	CheckpointModel  = "model"
+)
+
+// Checkpoint saves the models the stages of a run produce under a directory named after the
+// hash of the run's input, so a rerun with the same input resumes after the last stage that
+// succeeded instead of parsing the description again
+type Checkpoint struct {
+	dir    string
+	logger *zap.SugaredLogger
+}
+
+// checkpointInput is everything the parsed and built models depend on
+type checkpointInput struct {
+	Version       string              `json:"version"`
+	Description   string              `json:"description"`
+	Backend       string              `json:"backend"`
+	Model         string              `json:"model,omitempty"`
+	Endpoint      string              `json:"endpoint,omitempty"`
+	Region        string              `json:"region"`
+	Environment   string              `json:"environment,omitempty"`
+	Tags          map[string]string   `json:"tags,omitempty"`
+	TagOverrides  map[string]string   `json:"tag_overrides,omitempty"`
+	AddonVersions map[string]string   `json:"addon_versions,omitempty"`
+	Naming        *infra.NamingPolicy `json:"naming,omitempty"`
+}
+
+// NewCheckpoint returns the checkpoint, under cacheDir, of a description parsed and built
+// with the backend, region, environment, tags, tag overrides, add-on versions and naming policy
+// of the parameters
+func NewCheckpoint(cacheDir, description string, params *ProcessingParams) *Checkpoint {
+	// The synonyms are keyed by what they make of the description
+	if params.LLMConfig.Synonyms != nil {
+		description = params.LLMConfig.Synonyms.Apply(description)
+	}
+	backend := params.NLPBackend
+	if backend == "" {
+		backend = "regex"
+	}
+	var naming *infra.NamingPolicy
+	if !params.Naming.IsZero() {
+		naming = &params.Naming
+	}
+	input, _ := json.Marshal(checkpointInput{
+		Version:       version.Version,
+		Description:   description,
+		Backend:       backend,
+		Model:         params.LLMConfig.Model,
+		Endpoint:      params.LLMConfig.Endpoint,
+		Region:        params.Region,
+		Environment:   params.Environment,
+		Tags:          params.Tags,
+		TagOverrides:  params.TagOverrides,
+		AddonVersions: params.AddonVersions,
+		Naming:        naming,
+	})
+	hash := sha256.Sum256(input)
+
+	return &Checkpoint{
+		dir:    filepath.Join(cacheDir, hex.EncodeToString(hash[:])),
+		logger: utils.GetLogger(),
+	}
+}
+
+// Dir returns the directory of the checkpoint's artifacts
+func (c *Checkpoint) Dir() string {
+	return c.dir
+}
+
+// Load returns a saved artifact, and false if it was not saved or cannot be read
+func (c *Checkpoint) Load(artifact string) (*models.InfrastructureModel, bool) {
+	data, err := os.ReadFile(c.path(artifact))
+	if err != nil {
+		return nil, false
+	}
+	var model models.InfrastructureModel
+	if err := json.Unmarshal(data, &model); err != nil {
+		c.logger.Warnw("Ignoring unreadable checkpoint", "path", c.path(artifact), "error", err)
+		return nil, false
+	}
+	spec.NormalizeModel(&model)
+	return &model, true
+}
+
+// Save saves an artifact. The file is written under another name and renamed, so an
+// interrupted run never leaves a partial artifact.
+func (c *Checkpoint) Save(artifact string, model *models.InfrastructureModel) error {
+	data, err := json.MarshalIndent(model, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	if err := utils.EnsureDirectoryExists(c.dir); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	temp := c.path(artifact) + ".tmp"
+	if err := os.WriteFile(temp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(temp, c.path(artifact)); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Stage wraps a stage producing an artifact. The saved artifact is returned instead of
+// running the stage when there is one, which is noted on progress; otherwise the stage
+// runs and its output is saved. A checkpoint that cannot be saved only logs a warning.
+func (c *Checkpoint) Stage(artifact string, stage Stage, progress io.Writer) Stage {
+	return NewBaseStage(stage.Name(), func(ctx context.Context, input interface{}) (interface{}, error) {
+		if model, ok := c.Load(artifact); ok {
+			c.logger.Debugw("Resuming from checkpoint", "stage", stage.Name(), "path", c.path(artifact))
+			if progress != nil {
+				fmt.Fprintf(progress, "Resumed %s from checkpoint %s\n", stage.Name(), c.path(artifact))
+			}
+			return model, nil
+		}
+
+		output, err := stage.Execute(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		if model, ok := output.(*models.InfrastructureModel); ok {
+			if err := c.Save(artifact, model); err != nil {
+				c.logger.Warnw("Failed to save checkpoint", "stage", stage.Name(), "error", err)
+			}
+		}
+		return output, nil
+	})
+}
+
+// checkedModelStage wraps a stage building a model, failing with the error check returns for
+// the model
+func checkedModelStage(stage Stage, check func(*models.InfrastructureModel) error) Stage {
+	return NewBaseStage(stage.Name(), func(ctx context.Context, input interface{}) (interface{}, error) {
+		output, err := stage.Execute(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		if model, ok := output.(*models.InfrastructureModel); ok {
+			if err := check(model); err != nil {
+				return nil, err
+			}
+		}
+		return output, nil
+	})
+}
+
+// path returns the path of an artifact's file
+func (c *Checkpoint) path(artifact string) string {
+	return filepath.Join(c.dir, artifact+".json")
+}
+
+// addModelStages adds to a pipeline the stages that load the spec or the saved model, or
+// parse the description, or merge the descriptions of several files, with processor, and
+// build the model. With a cache directory, the
+// models they produce are checkpointed, and a rerun with the same description resumes from
+// them. Whichever of them it came from, the model built is validated unless
+// params.SkipModelValidation is set, must have the tags params.RequiredTags names and satisfy
+// the policies of params.Policies, is checked against the AWS service quotas if
+// params.CheckQuotas is set, and is saved to params.SaveModel if it is set.
+func (c *PipelineCoordinatorImpl) addModelStages(p Pipeline, params *ProcessingParams, description string, processor NLPProcessor) {
+	var parse, build Stage
+	switch {
+	case params.ModelFile != "":
+		parse, build = ModelLoadingStage(params.ModelFile), c.modelBuilder.ModelBuildStage()
+	case params.SpecFile != "":
+		parse, build = SpecLoadingStage(params.SpecFile), c.modelBuilder.ModelBuildStage()
+	case len(params.InputFiles) > 0:
+		parse, build = DescriptionMergingStage(params.InputFiles, processor), c.modelBuilder.ModelBuildStage()
+	default:
+		parse, build = processor.ProcessStage(), c.modelBuilder.ModelBuildStage()
+		if params.CacheDir != "" {
+			checkpoint := NewCheckpoint(params.CacheDir, description, params)
+			parse = checkpoint.Stage(CheckpointParsed, parse, params.ProgressWriter)
+			build = checkpoint.Stage(CheckpointModel, build, params.ProgressWriter)
+		}
+	}
+	if !params.SkipModelValidation {
+		build = checkedModelStage(build, infra.NewModelValidator().Validate)
+	}
+	if len(params.RequiredTags) > 0 {
+		build = checkedModelStage(build, func(model *models.InfrastructureModel) error {
+			return infra.CheckRequiredTags(model, params.RequiredTags)
+		})
+	}
+	if len(params.Policies) > 0 {
+		build = policyCheckedStage(build, params)
+	}
+	if params.CheckQuotas {
+		build = quotaCheckedStage(build, params)
+	}
+	if params.SaveModel != "" {
+		build = modelSavingStage(build, params.SaveModel)
+	}
+	p.AddStage(parse)
+	p.AddStage(build)
+}
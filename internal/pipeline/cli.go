@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/riptano/iac_generator_cli/internal/utils"
@@ -99,8 +101,15 @@ func RunWithProgressFeedback(params *ProcessingParams, outputWriter io.Writer) (
 	// Create and configure coordinator
 	coordinator := NewPipelineCoordinator()
 	
-	// Set a timeout context
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	// Set a timeout context, cancelled on an interrupt so the staged files are removed; a
+	// second interrupt stops the program at once
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 	
 	// Configure progress reporting
@@ -112,7 +121,7 @@ func RunWithProgressFeedback(params *ProcessingParams, outputWriter io.Writer) (
 	}
 	
 	// Set up progress reporter output handling
-	reporter, ok := coordinator.progressReporter.(*ConsoleProgressReporter)
+	reporter, ok := unwrapProgressReporter(coordinator.progressReporter).(*ConsoleProgressReporter)
 	if ok {
 		// Start goroutine to forward progress messages to the output writer
 		go func() {
@@ -142,14 +151,34 @@ func RunWithProgressFeedback(params *ProcessingParams, outputWriter io.Writer) (
 	fmt.Fprintln(outputWriter, "-----------------------------------")
 	if err == nil {
 		fmt.Fprintln(outputWriter, "✅ Pipeline execution completed successfully")
-		// Add message about generated files if output directory was specified
-		if params.OutputDir != "." {
-			if params.OutputFormat == "terraform" {
-				fmt.Fprintf(outputWriter, "   Generated Terraform files in: %s\n", params.OutputDir)
-			} else if params.OutputFormat == "crossplane" {
-				fmt.Fprintf(outputWriter, "   Generated Crossplane manifests in: %s\n", params.OutputDir)
+		// Add message about generated files if output directory was specified, for each of
+		// several formats; those of environments are in the environments' directories, which
+		// the result names
+		formats := formatParams(params)
+		for _, formatParams := range formats {
+			if params.OutputDir == "." && len(formats) == 1 {
+				break
+			}
+			if _, err := os.Stat(formatParams.OutputDir); err != nil {
+				continue
+			}
+			if formatParams.OutputFormat == "terraform" {
+				fmt.Fprintf(outputWriter, "   Generated Terraform files in: %s\n", formatParams.OutputDir)
+			} else if formatParams.OutputFormat == "crossplane" {
+				fmt.Fprintf(outputWriter, "   Generated Crossplane manifests in: %s\n", formatParams.OutputDir)
 			}
 		}
+		if params.DRRegion != "" {
+			for _, formatParams := range formats {
+				if _, err := os.Stat(formatParams.OutputDir); err != nil {
+					continue
+				}
+				fmt.Fprintf(outputWriter, "   DR variant (%s): %s\n", params.DRRegion, filepath.Join(formatParams.OutputDir, DRDirectory))
+			}
+		}
+		if params.ComplianceReport {
+			fmt.Fprintf(outputWriter, "   Compliance report: %s\n", filepath.Join(params.OutputDir, ComplianceReportFile))
+		}
 	} else {
 		fmt.Fprintf(outputWriter, "❌ Pipeline execution failed: %v\n", err)
 	}
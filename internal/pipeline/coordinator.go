@@ -1,13 +1,23 @@
 package pipeline
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
+	"github.com/riptano/iac_generator_cli/internal/adapter/crossplane"
+	"github.com/riptano/iac_generator_cli/internal/adapter/terraform"
+	"github.com/riptano/iac_generator_cli/internal/hooks"
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/internal/plugin"
+	"github.com/riptano/iac_generator_cli/internal/policy"
+	"github.com/riptano/iac_generator_cli/internal/report"
 	"github.com/riptano/iac_generator_cli/internal/utils"
 	"github.com/riptano/iac_generator_cli/pkg/models"
 	"go.uber.org/zap"
@@ -47,11 +57,24 @@ func (c *PipelineCoordinatorImpl) InitializePipeline(ctx context.Context, params
 		"region", params.Region,
 	)
 
-	// Initialize NLP processor
-	c.nlpProcessor = NewNLPProcessor()
+	// Initialize NLP processor with the requested backend
+	backend, err := nlp.NewBackend(params.NLPBackend, params.LLMConfig)
+	if err != nil {
+		return err
+	}
+	if params.Clarifier != nil {
+		backend = nlp.NewClarifyingBackend(backend, params.Clarifier)
+	}
+	if params.Reviewer != nil {
+		backend = nlp.NewReviewingBackend(backend, params.Reviewer)
+	}
+	nlpProcessor := NewNLPProcessorWithBackend(backend)
+	nlpProcessor.ReportWriter = params.ProgressWriter
+	nlpProcessor.Region = params.Region
+	c.nlpProcessor = nlpProcessor
 
 	// Initialize model builder with the specified region
-	c.modelBuilder = NewModelBuilder(params.Region)
+	c.modelBuilder = NewModelBuilder(params.Region).WithEnvironment(params.Environment).WithTags(params.Tags).WithTagOverrides(params.TagOverrides).WithAddonVersions(params.AddonVersions).WithNaming(params.Naming)
 
 	// Initialize output handler
 	c.outputHandler = NewOutputHandler(params.OutputDir)
@@ -65,11 +88,16 @@ func (c *PipelineCoordinatorImpl) InitializePipeline(ctx context.Context, params
 	}
 
 	// Create progress reporter
+	formats := len(OutputFormats(params.OutputFormat))
 	totalSteps := 3 // NLP, Model Building, Generation
-	if params.OutputDir != "." || params.OutputFile != "" {
+	if (params.OutputDir != "." || params.OutputFile != "") && formats == 1 {
 		totalSteps++ // Add output writing step
 	}
-	c.progressReporter = NewConsoleProgressReporter(totalSteps)
+	if params.DRRegion != "" {
+		totalSteps += formats // Add DR variant steps
+	}
+	totalSteps += len(plugin.Stages(params.Plugins)) // Add plugin stages
+	c.progressReporter = newProgressReporter(params, totalSteps)
 
 	// Set progress reporter on pipeline
 	c.pipeline.SetProgressReporter(c.progressReporter)
@@ -85,23 +113,266 @@ func (c *PipelineCoordinatorImpl) InitializePipeline(ctx context.Context, params
 
 // validateParams validates the processing parameters
 func (c *PipelineCoordinatorImpl) validateParams(params *ProcessingParams) error {
-	// Validate description or input file
-	if params.Description == "" && params.InputFile == "" {
-		return fmt.Errorf("either description or input file must be provided")
+	// Validate description, input file, spec or saved model
+	if params.Description == "" && params.InputFile == "" && len(params.InputFiles) == 0 && params.SpecFile == "" && params.ModelFile == "" {
+		return fmt.Errorf("either description, input file, spec file or model file must be provided")
 	}
 
-	// Validate output format
-	format := strings.ToLower(params.OutputFormat)
-	valid := false
-	for _, f := range GetAvailableGenerators() {
-		if f == format {
-			valid = true
-			break
+	// The descriptions of several input files are merged instead of parsing one
+	if len(params.InputFiles) > 0 {
+		if params.Description != "" || params.InputFile != "" || params.SpecFile != "" || params.ModelFile != "" {
+			return fmt.Errorf("several input files cannot be combined with a description, input file, spec file or model file")
+		}
+		for _, file := range params.InputFiles {
+			if !utils.FileExists(file) {
+				return fmt.Errorf("input file does not exist: %s", file)
+			}
 		}
 	}
-	if !valid {
+
+	// A spec replaces the description, so both cannot be given
+	if params.SpecFile != "" {
+		if params.Description != "" || params.InputFile != "" {
+			return fmt.Errorf("a spec file cannot be combined with a description or input file")
+		}
+		if !utils.FileExists(params.SpecFile) {
+			return fmt.Errorf("spec file does not exist: %s", params.SpecFile)
+		}
+	}
+
+	// A saved model replaces the description and the spec
+	if params.ModelFile != "" {
+		if params.Description != "" || params.InputFile != "" || params.SpecFile != "" {
+			return fmt.Errorf("a model file cannot be combined with a description, input file or spec file")
+		}
+		if !utils.FileExists(params.ModelFile) {
+			return fmt.Errorf("model file does not exist: %s", params.ModelFile)
+		}
+	}
+
+	// Validate the output formats
+	formats := OutputFormats(params.OutputFormat)
+	if len(formats) == 0 {
 		return fmt.Errorf("unsupported output format: %s", params.OutputFormat)
 	}
+	for _, format := range formats {
+		if err := validateOutputFormat(params, format); err != nil {
+			return err
+		}
+	}
+	if params.Lint {
+		linted := false
+		for _, format := range formats {
+			linted = linted || format == "terraform"
+		}
+		if !linted {
+			return fmt.Errorf("only Terraform is linted, so linting needs the terraform output format")
+		}
+	}
+	if params.Estimate {
+		estimated := false
+		for _, format := range formats {
+			estimated = estimated || format == "terraform"
+		}
+		if !estimated {
+			return fmt.Errorf("only Terraform is estimated, so the cost estimate needs the terraform output format")
+		}
+		if _, _, err := report.FindEstimator(params.Estimator); err != nil {
+			return err
+		}
+	}
+	if params.ScanFailOn != "" && !report.ValidScanSeverity(params.ScanFailOn) {
+		return fmt.Errorf("invalid scan severity: %s (use %s)", params.ScanFailOn, strings.Join(report.ScanSeverities, ", "))
+	}
+	if params.Scan {
+		if _, _, err := report.FindScanner(report.ScanOptions{}); err != nil {
+			return err
+		}
+	}
+	if params.Checkov {
+		if _, err := report.FindCheckov(); err != nil {
+			return err
+		}
+	}
+	if err := report.ValidateCheckovSuppressions(params.CheckovSuppressions); err != nil {
+		return err
+	}
+	if len(formats) > 1 && params.OutputFile != "" {
+		return fmt.Errorf("an output file cannot be used with several output formats, which are each written to a directory")
+	}
+
+	if params.Environment != "" && !infra.ValidEnvironment(params.Environment) {
+		return fmt.Errorf("invalid environment: %s (use up to 16 lower-case letters, digits and hyphens, starting with a letter)", params.Environment)
+	}
+	if err := params.Naming.Validate(); err != nil {
+		return err
+	}
+	for key, value := range params.TagOverrides {
+		if err := infra.CheckTag(key, value); err != nil {
+			return fmt.Errorf("invalid tag: %w", err)
+		}
+	}
+	for _, key := range params.RequiredTags {
+		if err := infra.CheckRequiredTag(key); err != nil {
+			return fmt.Errorf("invalid required tag: %w", err)
+		}
+	}
+	if len(params.Policies) > 0 {
+		if _, err := policy.Load(context.Background(), params.Policies); err != nil {
+			return err
+		}
+	}
+
+	// The DR region must differ from the primary region
+	if params.DRRegion != "" && params.DRRegion == params.Region {
+		return fmt.Errorf("DR region must differ from the primary region: %s", params.DRRegion)
+	}
+
+	if params.Backend != "" || params.BackendBootstrap {
+		if err := terraform.ValidateBackend(backendType(params), params.BackendConfig, params.BackendBootstrap); err != nil {
+			return err
+		}
+	}
+
+	if params.ModuleSource != "" {
+		if err := terraform.ValidateModuleSource(strings.ToLower(params.ModuleSource)); err != nil {
+			return err
+		}
+		if strings.EqualFold(params.ModuleSource, terraform.ModuleSourceRegistry) && params.UseTemplates {
+			return fmt.Errorf("the template system does not generate modules, so it cannot use the registry modules")
+		}
+	}
+
+	if params.ModuleLayout != "" {
+		if err := terraform.ValidateModuleLayout(strings.ToLower(params.ModuleLayout)); err != nil {
+			return err
+		}
+		if strings.EqualFold(params.ModuleLayout, terraform.ModuleLayoutFlat) && strings.EqualFold(params.ModuleSource, terraform.ModuleSourceRegistry) {
+			return fmt.Errorf("the flat module layout has no modules, so it cannot use the registry modules")
+		}
+	}
+	if err := terraform.ValidateModuleNames(params.ModuleNames); err != nil {
+		return err
+	}
+
+	if params.CrossplaneProvider != "" {
+		if err := crossplane.ValidateProvider(strings.ToLower(params.CrossplaneProvider)); err != nil {
+			return err
+		}
+		if strings.EqualFold(params.CrossplaneProvider, crossplane.ProviderUpbound) && params.UseTemplates {
+			return fmt.Errorf("the template system does not support the Upbound provider family")
+		}
+	}
+	if params.CrossplaneCredentials != "" {
+		if err := crossplane.ValidateCredentials(strings.ToLower(params.CrossplaneCredentials)); err != nil {
+			return err
+		}
+		if strings.EqualFold(params.CrossplaneCredentials, crossplane.CredentialsIRSA) && params.UseTemplates {
+			return fmt.Errorf("the template system does not support IRSA credentials")
+		}
+	}
+	if params.Compositions && params.UseTemplates {
+		return fmt.Errorf("the template system does not generate Compositions")
+	}
+	if len(params.CrossplaneImports) > 0 {
+		if err := crossplane.ValidateImports(params.CrossplaneImports); err != nil {
+			return err
+		}
+		if params.UseTemplates {
+			return fmt.Errorf("the template system does not adopt existing resources")
+		}
+	}
+	if params.Team != "" {
+		if err := crossplane.ValidateTeam(params.Team); err != nil {
+			return err
+		}
+		if !params.Compositions {
+			return fmt.Errorf("the team owns the example claims of the Compositions, so it needs --compositions")
+		}
+	}
+	if params.ConnectionSecrets != "" {
+		mode := strings.ToLower(params.ConnectionSecrets)
+		if err := crossplane.ValidateConnectionSecrets(mode); err != nil {
+			return err
+		}
+		if mode != crossplane.ConnectionSecretsNone && params.UseTemplates {
+			return fmt.Errorf("the template system does not write connection secrets")
+		}
+		if mode == crossplane.ConnectionSecretsComposite && !params.Compositions {
+			return fmt.Errorf("the Compositions aggregate the composite connection secrets, so they need --compositions")
+		}
+	}
+	if params.DeletionPolicy != "" {
+		if err := crossplane.ValidateDeletionPolicy(strings.ToLower(params.DeletionPolicy)); err != nil {
+			return err
+		}
+		if !strings.EqualFold(params.DeletionPolicy, crossplane.DeletionOrphan) && params.UseTemplates {
+			return fmt.Errorf("the template system does not support deletion policies")
+		}
+	}
+	if params.ManagementPolicy != "" {
+		if err := crossplane.ValidateManagementPolicy(strings.ToLower(params.ManagementPolicy)); err != nil {
+			return err
+		}
+		if !strings.EqualFold(params.ManagementPolicy, crossplane.ManagementFull) && params.UseTemplates {
+			return fmt.Errorf("the template system does not support management policies")
+		}
+	}
+	if params.GitOps != "" {
+		if err := crossplane.ValidateGitOps(strings.ToLower(params.GitOps), params.GitOpsConfig); err != nil {
+			return err
+		}
+		crossplaneOutput := false
+		for _, format := range OutputFormats(params.OutputFormat) {
+			crossplaneOutput = crossplaneOutput || format == "crossplane"
+		}
+		if !crossplaneOutput {
+			return fmt.Errorf("the GitOps manifests deploy the Crossplane output, so they need --output crossplane")
+		}
+	}
+	if err := infra.ValidateEKSAddonVersions(params.AddonVersions); err != nil {
+		return err
+	}
+
+	if params.TerraformVersion != "" {
+		if err := terraform.ValidateTerraformVersion(params.TerraformVersion); err != nil {
+			return err
+		}
+	}
+	if params.ProviderVersion != "" {
+		if err := terraform.ValidateProviderConstraint(params.ProviderVersion); err != nil {
+			return err
+		}
+	}
+
+	if err := terraform.ValidateLockPlatforms(params.LockPlatforms); err != nil {
+		return err
+	}
+
+	if err := terraform.ValidateLifecycle(params.Lifecycle); err != nil {
+		return err
+	}
+
+	if len(params.Imports) > 0 {
+		if err := terraform.ValidateImports(params.Imports); err != nil {
+			return err
+		}
+		if len(params.Workspaces) > 0 {
+			return fmt.Errorf("import blocks would adopt the same resources in every workspace, so imports cannot be used with workspaces")
+		}
+	}
+
+	if len(params.Workspaces) > 0 {
+		if err := terraform.ValidateWorkspaces(params.Workspaces); err != nil {
+			return err
+		}
+		if params.Environment != "" {
+			return fmt.Errorf("workspaces cannot be used with an environment, which is generated on its own")
+		}
+		if params.UseTemplates {
+			return fmt.Errorf("the template system does not generate the variables of workspaces")
+		}
+	}
 
 	// If input file is specified, check if it exists
 	if params.InputFile != "" {
@@ -120,24 +391,55 @@ func (c *PipelineCoordinatorImpl) validateParams(params *ProcessingParams) error
 	return nil
 }
 
-// setupPipeline sets up the pipeline stages based on parameters
-func (c *PipelineCoordinatorImpl) setupPipeline(params *ProcessingParams) error {
+// validateOutputFormat checks that one output format is built in or provided by a generator
+// plugin
+func validateOutputFormat(params *ProcessingParams, format string) error {
+	for _, f := range GetAvailableGenerators() {
+		if f == format {
+			return nil
+		}
+	}
+	formatPlugin := plugin.Generator(params.Plugins, format)
+	if formatPlugin == nil {
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+
+	// The failover runbook only knows the built-in formats
+	if params.DRRegion != "" {
+		return fmt.Errorf("a DR region cannot be used with the %s format of plugin %s", format, formatPlugin.Name)
+	}
+	return nil
+}
+
+// setupPipeline sets up the pipeline stages for a description based on parameters
+func (c *PipelineCoordinatorImpl) setupPipeline(params *ProcessingParams, description string) error {
 	// Clear any existing stages
 	c.pipeline = NewBasePipeline()
 	c.pipeline.SetProgressReporter(c.progressReporter)
+	c.pipeline.SetRetryPolicy(params.Retry)
+
+	// Add NLP processing and model building stages, or load the model from the spec instead
+	c.addModelStages(c.pipeline, params, description, c.nlpProcessor)
 
-	// Add NLP processing stage
-	c.pipeline.AddStage(c.nlpProcessor.ProcessStage())
+	// Add the stages of stage plugins, such as policy checks
+	addPluginStages(c.pipeline, params)
 
-	// Add model building stage
-	c.pipeline.AddStage(c.modelBuilder.ModelBuildStage())
+	// Add DR variant stage if a secondary region was requested
+	addDRStages(c.pipeline, params)
 
-	// Add IaC generation stage
+	// A generator plugin writes its files into the output directory itself, as does each of
+	// several output formats, and a built-in format unless one output file was requested
+	if plugin.Generator(params.Plugins, params.OutputFormat) != nil || len(OutputFormats(params.OutputFormat)) > 1 || params.OutputFile == "" {
+		c.pipeline.AddStage(generationStage(params, params.OutputDir, outputGenerationStage(params)))
+		return nil
+	}
+
+	// Add IaC generation stage, with the compliance report alongside it if requested
 	generator, found := c.generators[strings.ToLower(params.OutputFormat)]
 	if !found {
 		return fmt.Errorf("no generator available for format: %s", params.OutputFormat)
 	}
-	c.pipeline.AddStage(generator.GenerateStage())
+	c.pipeline.AddStage(generationStage(params, params.OutputDir, generator.GenerateStage()))
 
 	// If output path is specified, add output writing stage
 	if params.OutputDir != "." || params.OutputFile != "" {
@@ -180,6 +482,11 @@ func (c *PipelineCoordinatorImpl) setupPipeline(params *ProcessingParams) error
 
 // loadDescription loads the description from parameters
 func (c *PipelineCoordinatorImpl) loadDescription(params *ProcessingParams) (string, error) {
+	// The spec, model loading and description merging stages do not read a description
+	if params.SpecFile != "" || params.ModelFile != "" || len(params.InputFiles) > 0 {
+		return "", nil
+	}
+
 	// If description is provided directly, use it
 	if params.Description != "" {
 		return params.Description, nil
@@ -197,14 +504,67 @@ func (c *PipelineCoordinatorImpl) loadDescription(params *ProcessingParams) (str
 	return "", fmt.Errorf("no description provided")
 }
 
-// RunPipeline implements PipelineCoordinator
+// RunPipeline implements PipelineCoordinator. The pre-generation hooks run before the
+// pipeline, and the post-generation hooks once it succeeded, in the output directory.
+// The files are generated into a staging directory and moved into the output directory
+// once the pipeline succeeded. With an event sink, the run and each file written to the
+// output directory are reported as events. With metrics, each stage is measured.
 func (c *PipelineCoordinatorImpl) RunPipeline(ctx context.Context, params *ProcessingParams) (string, error) {
-	c.logger.Info("Running pipeline")
+	defer watchFileWrites(params)()
+	if params.Events == nil {
+		return c.runWithHooks(ctx, params)
+	}
 
-	// Set up the pipeline
-	if err := c.setupPipeline(params); err != nil {
-		return "", fmt.Errorf("failed to set up pipeline: %w", err)
+	params.Events.Emit(Event{Type: EventPipelineStarted, Environment: params.Environment})
+	result, err := c.runWithHooks(ctx, params)
+	if err != nil {
+		params.Events.Emit(Event{Type: EventPipelineFailed, Environment: params.Environment, Error: err.Error()})
+		return "", err
+	}
+	params.Events.Emit(Event{Type: EventPipelineCompleted, Environment: params.Environment, Message: result})
+	return result, nil
+}
+
+// runWithHooks runs the pipeline between the pre and post-generation hooks
+func (c *PipelineCoordinatorImpl) runWithHooks(ctx context.Context, params *ProcessingParams) (string, error) {
+	if err := runHooks(ctx, params, hooks.Pre, params.OutputDir); err != nil {
+		return "", err
+	}
+	result, err := c.runStaged(ctx, params)
+	if err != nil {
+		return "", err
+	}
+	if params.Lint {
+		findings, err := lintOutput(params)
+		if err != nil {
+			return "", err
+		}
+		result += "\n\n" + findings
+	}
+	if params.LintIAM {
+		findings, err := lintIAMOutput(params)
+		if err != nil {
+			return "", err
+		}
+		result += "\n\n" + findings
+	}
+	if params.Estimate {
+		estimate, err := estimateOutput(params)
+		if err != nil {
+			return "", err
+		}
+		result += "\n\n" + estimate
 	}
+	if err := runHooks(ctx, params, hooks.Post, params.OutputDir); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// runPipeline runs the pipeline for the description of the parameters, or for each of the
+// environments it covers
+func (c *PipelineCoordinatorImpl) runPipeline(ctx context.Context, params *ProcessingParams) (string, error) {
+	c.logger.Info("Running pipeline")
 
 	// Load description
 	description, err := c.loadDescription(params)
@@ -212,12 +572,40 @@ func (c *PipelineCoordinatorImpl) RunPipeline(ctx context.Context, params *Proce
 		return "", fmt.Errorf("failed to load description: %w", err)
 	}
 
+	// A description covering several environments is generated once per environment, unless
+	// one of them was requested
+	if environments := nlp.SplitEnvironments(description); len(environments) > 0 {
+		if len(params.Workspaces) > 0 {
+			return "", fmt.Errorf("the description covers several environments, which are generated into their own directories, so it cannot use workspaces")
+		}
+		if (len(params.Imports) > 0 || len(params.CrossplaneImports) > 0) && params.Environment == "" {
+			return "", fmt.Errorf("the description covers several environments, which would each adopt the imported resources; generate one with --environment")
+		}
+		if params.Environment == "" {
+			return c.runEnvironments(ctx, params, environments)
+		}
+		description, err = selectEnvironment(environments, params.Environment)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Set up the pipeline
+	if err := c.setupPipeline(params, description); err != nil {
+		return "", fmt.Errorf("failed to set up pipeline: %w", err)
+	}
+
 	// Execute the pipeline
 	result, err := c.pipeline.Execute(ctx, description)
 	if err != nil {
 		return "", fmt.Errorf("pipeline execution failed: %w", err)
 	}
 
+	// Several output formats are each written to a subdirectory
+	if formats := OutputFormats(params.OutputFormat); len(formats) > 1 {
+		return fmt.Sprintf("Successfully generated %s manifests in %s", strings.Join(formats, " and "), params.OutputDir), nil
+	}
+
 	// Handle the result based on its type
 	switch v := result.(type) {
 	case string:
@@ -235,6 +623,148 @@ func (c *PipelineCoordinatorImpl) RunPipeline(ctx context.Context, params *Proce
 	}
 }
 
+// runEnvironments runs the pipeline once per environment, writing each into a subdirectory
+// of the output directory named after the environment. The environments are generated
+// concurrently, each with its progress buffered and written in order once all are done,
+// unless follow-up questions or a review are asked on the terminal.
+func (c *PipelineCoordinatorImpl) runEnvironments(ctx context.Context, params *ProcessingParams, environments []nlp.Environment) (string, error) {
+	if params.SaveModel != "" {
+		return "", fmt.Errorf("the description covers several environments, which each have a model of their own; save one with --environment")
+	}
+
+	interactive := params.Clarifier != nil || params.Reviewer != nil
+	workers := params.Workers
+	if interactive {
+		workers = 1
+	}
+
+	outputs := make([]bytes.Buffer, len(environments))
+	scheduler := NewScheduler(workers)
+	for i, environment := range environments {
+		i, environment := i, environment
+		err := scheduler.Add(environment.Name, func(ctx context.Context) error {
+			if interactive {
+				return c.runEnvironment(ctx, params, environment, c.nlpProcessor, params.ProgressWriter)
+			}
+			processor, err := c.environmentProcessor(params, &outputs[i])
+			if err != nil {
+				return err
+			}
+			return c.runEnvironment(ctx, params, environment, processor, &outputs[i])
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	err := scheduler.Run(ctx)
+
+	names := make([]string, len(environments))
+	for i, environment := range environments {
+		names[i] = environment.Name
+	}
+	for _, format := range OutputFormats(params.OutputFormat) {
+		if err == nil && format == "terraform" && params.BackendBootstrap {
+			err = terraform.GenerateBackendBootstrap(filepath.Join(params.OutputDir, terraform.BackendBootstrapDir), terraformConfig(params), nil)
+		}
+		// One ApplicationSet deploys the Crossplane output of every environment
+		if err == nil && format == "crossplane" && strings.EqualFold(params.GitOps, crossplane.GitOpsArgoCD) {
+			subdir := ""
+			if len(OutputFormats(params.OutputFormat)) > 1 {
+				subdir = format
+			}
+			err = crossplane.WriteArgoCDApplicationSet(params.OutputDir, names, subdir, params.GitOpsConfig)
+		}
+	}
+
+	for i := range environments {
+		if !interactive && params.ProgressWriter != nil {
+			outputs[i].WriteTo(params.ProgressWriter)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Successfully generated %s manifests for environments %s in %s",
+		strings.Join(OutputFormats(params.OutputFormat), " and "), strings.Join(names, ", "), params.OutputDir), nil
+}
+
+// runEnvironment runs the pipeline for one environment, parsing its description with
+// processor and writing its progress to progress
+func (c *PipelineCoordinatorImpl) runEnvironment(ctx context.Context, params *ProcessingParams, environment nlp.Environment, processor NLPProcessor, progress io.Writer) error {
+	envParams := *params
+	envParams.Description = environment.Description
+	envParams.InputFile = ""
+	envParams.OutputDir = filepath.Join(params.OutputDir, environment.Name)
+	envParams.GitOpsConfig.Path = path.Join(params.GitOpsConfig.Path, environment.Name)
+	envParams.OutputFile = ""
+	envParams.Environment = environment.Name
+	envParams.ProgressWriter = progress
+	// The environments share one state backend, whose bootstrap configuration is written once,
+	// and are deployed by one Argo CD ApplicationSet, or Flux manifests of their own
+	envParams.BackendBootstrap = false
+	if strings.EqualFold(params.GitOps, crossplane.GitOpsArgoCD) {
+		envParams.GitOps = ""
+	}
+
+	c.logger.Infow("Generating environment",
+		"environment", environment.Name,
+		"description", environment.Description,
+		"dir", envParams.OutputDir,
+	)
+	if progress != nil {
+		fmt.Fprintf(progress, "\nEnvironment: %s\n", environment.Name)
+	}
+
+	envPipeline := NewBasePipeline()
+	c.addModelStages(envPipeline, &envParams, environment.Description, processor)
+	totalSteps := 3 // NLP, Model Building, Environment Generation
+	totalSteps += addPluginStages(envPipeline, &envParams)
+	totalSteps += addDRStages(envPipeline, &envParams)
+	envPipeline.AddStage(generationStage(&envParams, envParams.OutputDir, outputGenerationStage(&envParams)))
+	envPipeline.SetProgressReporter(newProgressReporter(&envParams, totalSteps))
+	envPipeline.SetRetryPolicy(envParams.Retry)
+
+	if _, err := envPipeline.Execute(ctx, environment.Description); err != nil {
+		return fmt.Errorf("pipeline execution failed for environment %s: %w", environment.Name, err)
+	}
+	return nil
+}
+
+// environmentProcessor returns an NLP processor with a backend and parser of its own, created
+// with the requested backend's settings, that writes its assumptions report to w, so
+// environments can be parsed concurrently. Only the synonym registry is shared, which is
+// safe for concurrent use.
+func (c *PipelineCoordinatorImpl) environmentProcessor(params *ProcessingParams, w io.Writer) (NLPProcessor, error) {
+	processor, ok := c.nlpProcessor.(*NLPProcessorImpl)
+	if !ok {
+		return c.nlpProcessor, nil
+	}
+	backend, err := nlp.NewBackend(params.NLPBackend, params.LLMConfig)
+	if err != nil {
+		return nil, err
+	}
+	environmentProcessor := NewNLPProcessorWithBackend(backend)
+	environmentProcessor.Region = processor.Region
+	if processor.ReportWriter != nil {
+		environmentProcessor.ReportWriter = w
+	}
+	return environmentProcessor, nil
+}
+
+// selectEnvironment returns the description of the requested environment among those a
+// description covers
+func selectEnvironment(environments []nlp.Environment, name string) (string, error) {
+	names := make([]string, len(environments))
+	for i, environment := range environments {
+		if environment.Name == name {
+			return environment.Description, nil
+		}
+		names[i] = environment.Name
+	}
+	return "", fmt.Errorf("environment %s is not one of the environments the description covers (%s)", name, strings.Join(names, ", "))
+}
+
 // GetAvailableGenerators implements PipelineCoordinator
 func (c *PipelineCoordinatorImpl) GetAvailableGenerators() []string {
 	return GetAvailableGenerators()
@@ -0,0 +1,150 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/riptano/iac_generator_cli/internal/report"
+)
+
+// DiffPipeline generates the manifests for the parameters into a temporary directory and
+// compares them with the files of params.OutputDir, which is left untouched. The pipeline
+// must have been initialized with the same parameters, apart from the output directory.
+func (c *PipelineCoordinatorImpl) DiffPipeline(ctx context.Context, params *ProcessingParams) (*report.DirectoryDiff, error) {
+	tempDir, err := os.MkdirTemp("", "iacgen-diff-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// The generators keep some files that already exist, such as READMEs, and add to others,
+	// such as kustomizations, so they generate over a copy of the target directory. The
+	// copies they leave alone are removed again, as generating would not change them. Hidden
+	// files are copied too, as the generation manifest tells the generators what was renamed.
+	seeded, err := seedDirectory(params.OutputDir, tempDir, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.generateIntoDirectory(ctx, params, tempDir); err != nil {
+		return nil, err
+	}
+	if err := removeUntouched(tempDir, seeded); err != nil {
+		return nil, err
+	}
+
+	c.logger.Debugw("Comparing generated files", "generated_dir", tempDir, "target_dir", params.OutputDir)
+	return report.DiffDirectories(params.OutputDir, tempDir)
+}
+
+// DiffDescription generates the manifests for the parameters without writing to
+// params.OutputDir, and returns how they differ from the files already there
+func DiffDescription(params *ProcessingParams) (*report.DirectoryDiff, error) {
+	if params.ProgressWriter == nil {
+		params.ProgressWriter = os.Stderr
+	}
+
+	// Initialize with the current directory, so the target directory is not created
+	initParams := *params
+	initParams.OutputDir = "."
+	initParams.OutputFile = ""
+
+	coordinator := NewPipelineCoordinator()
+	ctx := context.Background()
+
+	if err := coordinator.InitializePipeline(ctx, &initParams); err != nil {
+		return nil, err
+	}
+
+	return coordinator.DiffPipeline(ctx, params)
+}
+
+// seedTime is the modification time of the files copied by seedDirectory, which tells them
+// apart from the files the generators write
+var seedTime = time.Unix(0, 0)
+
+// seedDirectory copies the files of the target directory, which does not need to exist, into
+// dir and returns their paths relative to it. Hidden directories are skipped, and so are
+// hidden files unless hidden is set, as the diff ignores them.
+func seedDirectory(target, dir string, hidden bool) ([]string, error) {
+	if info, err := os.Stat(target); err != nil || !info.IsDir() {
+		return nil, nil
+	}
+
+	var seeded []string
+	err := filepath.WalkDir(target, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != target && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			if !hidden {
+				return nil
+			}
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(target, path)
+		if err != nil {
+			return err
+		}
+		if err := copyFile(path, filepath.Join(dir, rel)); err != nil {
+			return err
+		}
+		seeded = append(seeded, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy %s: %w", target, err)
+	}
+	return seeded, nil
+}
+
+// copyFile copies a file, creating the directory of the copy, and gives the copy seedTime
+func copyFile(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	copied, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(copied, source); err != nil {
+		copied.Close()
+		return err
+	}
+	if err := copied.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(to, seedTime, seedTime)
+}
+
+// removeUntouched removes the seeded files of dir that were not written since they were copied
+func removeUntouched(dir string, seeded []string) error {
+	for _, rel := range seeded {
+		path := filepath.Join(dir, rel)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().Equal(seedTime) {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/adapter/crossplane"
+	"github.com/riptano/iac_generator_cli/internal/adapter/terraform"
+	"github.com/riptano/iac_generator_cli/internal/generator"
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/plugin"
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// DRDirectory is the subdirectory of the output directory holding the disaster-recovery variant
+const DRDirectory = "dr"
+
+// DRVariantStage creates a pipeline stage that generates a secondary-region variant of the
+// infrastructure model into the dr/ subdirectory, along with a failover runbook.
+// The primary model is passed on with S3 replication configured on its buckets.
+func DRVariantStage(params *ProcessingParams) Stage {
+	return drVariantStage("DRVariantGeneration", params)
+}
+
+// addDRStages adds the DR variant stage to a pipeline if a secondary region was requested,
+// one per format for several output formats, each into the format's subdirectory, and
+// returns how many were added
+func addDRStages(p Pipeline, params *ProcessingParams) int {
+	if params.DRRegion == "" {
+		return 0
+	}
+	formats := formatParams(params)
+	if len(formats) == 1 {
+		p.AddStage(DRVariantStage(params))
+		return 1
+	}
+	for _, formatParams := range formats {
+		p.AddStage(drVariantStage("DRVariantGeneration:"+formatParams.OutputFormat, formatParams))
+	}
+	return len(formats)
+}
+
+// drVariantStage creates a DR variant stage with a name
+func drVariantStage(name string, params *ProcessingParams) Stage {
+	return NewBaseStage(name, func(ctx context.Context, input interface{}) (interface{}, error) {
+		model, ok := input.(*models.InfrastructureModel)
+		if !ok {
+			return nil, fmt.Errorf("invalid input type for DR variant generation: %T", input)
+		}
+
+		// Check if the context is canceled
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		logger := utils.GetLogger()
+		variant := infra.BuildDRVariant(model, params.DRRegion)
+
+		drDir := filepath.Join(params.OutputDir, DRDirectory)
+		if err := utils.EnsureDirectoryExists(drDir); err != nil {
+			return nil, fmt.Errorf("failed to create DR directory: %w", err)
+		}
+
+		if _, err := generateDRManifests(ctx, variant, primaryRegion(model, params), params, drDir); err != nil {
+			return nil, fmt.Errorf("failed to generate DR variant: %w", err)
+		}
+
+		runbook := report.FailoverRunbook(variant, strings.ToLower(params.OutputFormat), primaryRegion(model, params), params.DRRegion, DRDirectory)
+		runbookPath := filepath.Join(params.OutputDir, report.FailoverRunbookFile)
+		if err := utils.WriteToFile(runbookPath, runbook); err != nil {
+			return nil, fmt.Errorf("failed to write failover runbook: %w", err)
+		}
+
+		logger.Infow("DR variant generated",
+			"region", params.DRRegion,
+			"resources_count", len(variant.Resources),
+			"dir", drDir,
+		)
+
+		return model, nil
+	})
+}
+
+// primaryRegion returns the region of the primary model, or the requested region for a model
+// without one
+func primaryRegion(model *models.InfrastructureModel, params *ProcessingParams) string {
+	if model.Region != "" {
+		return model.Region
+	}
+	return params.Region
+}
+
+// generateDRManifests renders the DR variant with the generator matching the requested format,
+// keeping its state in the primary region
+func generateDRManifests(ctx context.Context, variant *models.InfrastructureModel, primaryRegion string, params *ProcessingParams, drDir string) (string, error) {
+	return generateManifestsInDirectory(ctx, variant, params, drDir, func(config *terraform.TerraformConfig) {
+		// The DR stack keeps its state beside the primary stack's, in the bucket the primary
+		// stack's bootstrap configuration creates
+		if config.BackendType == "s3" {
+			if config.BackendConfig["region"] == "" {
+				config.BackendConfig["region"] = primaryRegion
+			}
+			if key := config.BackendConfig["key"]; key != "" {
+				config.BackendConfig["key"] = "dr/" + key
+			}
+		}
+		config.BackendBootstrap = false
+		// The existing resources are adopted by the primary stack only
+		config.Imports = nil
+		config.AwsRegion = params.DRRegion
+		config.NameSuffix = infra.DRSuffix + config.NameSuffix
+	})
+}
+
+// terraformConfig returns the configuration of the Terraform generator for the requested
+// region and state backend
+func terraformConfig(params *ProcessingParams) *terraform.TerraformConfig {
+	config := terraform.DefaultTerraformConfig()
+	if params.Region != "" {
+		config.AwsRegion = params.Region
+	}
+	config.BackendType = backendType(params)
+	for key, value := range params.BackendConfig {
+		config.BackendConfig[key] = value
+	}
+	config.BackendBootstrap = params.BackendBootstrap
+	if params.ModuleSource != "" {
+		config.ModuleSource = strings.ToLower(params.ModuleSource)
+	}
+	if params.ModuleLayout != "" {
+		config.ModuleLayout = strings.ToLower(params.ModuleLayout)
+	}
+	config.CustomModuleNames = params.ModuleNames
+	if params.TerraformVersion != "" {
+		config.TerraformVersion = params.TerraformVersion
+	}
+	if params.ProviderVersion != "" {
+		config.ProviderConstraint = params.ProviderVersion
+	}
+	config.Workspaces = params.Workspaces
+	config.Imports = params.Imports
+	config.Lifecycle = params.Lifecycle
+	config.FormatOutput = params.FormatOutput
+	config.LockPlatforms = params.LockPlatforms
+	return config
+}
+
+// backendType returns the requested state backend, local by default
+func backendType(params *ProcessingParams) string {
+	if params.Backend == "" {
+		return "local"
+	}
+	return strings.ToLower(params.Backend)
+}
+
+// generateManifestsInDirectory renders a model into its own directory with the generator
+// matching the requested format, or the generator plugin providing it. The Terraform
+// configuration is tagged with the requested environment and can be adjusted further with
+// configure.
+func generateManifestsInDirectory(ctx context.Context, model *models.InfrastructureModel, params *ProcessingParams, dir string, configure func(config *terraform.TerraformConfig)) (string, error) {
+	var gen generator.Generator
+
+	switch strings.ToLower(params.OutputFormat) {
+	case "terraform":
+		config := terraformConfig(params)
+		// A model made for one environment carries the environment's names and tags itself
+		if params.Environment != "" && model.Environment == "" {
+			config.Environment = params.Environment
+			config.NameSuffix = "-" + params.Environment
+		}
+		if configure != nil {
+			configure(config)
+		}
+		if params.UseTemplates {
+			gen = terraform.NewTemplateTerraformGenerator().WithOutputDir(dir).WithConfig(config)
+		} else {
+			gen = terraform.NewTerraformGenerator().WithOutputDir(dir).WithConfig(config)
+		}
+	case "crossplane":
+		if params.UseTemplates {
+			cpGenerator := crossplane.NewTemplateCrossplaneGenerator()
+			if err := cpGenerator.Init(dir); err != nil {
+				return "", fmt.Errorf("failed to initialize Crossplane generator: %w", err)
+			}
+			gen = cpGenerator
+		} else {
+			// The existing resources are adopted by the primary stack only
+			imports := params.CrossplaneImports
+			if dir != params.OutputDir {
+				imports = nil
+			}
+			cpGenerator := crossplane.NewCrossplaneGenerator().
+				WithProvider(strings.ToLower(params.CrossplaneProvider)).
+				WithCredentials(strings.ToLower(params.CrossplaneCredentials), params.CrossplaneRoleARN).
+				WithCompositions(params.Compositions).
+				WithTeam(params.Team).
+				WithConnectionSecrets(strings.ToLower(params.ConnectionSecrets), params.ConnectionSecretNamespace).
+				WithResourcePolicy(strings.ToLower(params.DeletionPolicy), strings.ToLower(params.ManagementPolicy)).
+				WithImports(imports)
+			if err := cpGenerator.Init(dir); err != nil {
+				return "", fmt.Errorf("failed to initialize Crossplane generator: %w", err)
+			}
+			gen = cpGenerator
+		}
+	default:
+		if p := plugin.Generator(params.Plugins, params.OutputFormat); p != nil {
+			return generatePluginFiles(ctx, p, model, params, dir)
+		}
+		return "", fmt.Errorf("unsupported output format: %s", params.OutputFormat)
+	}
+
+	result, err := gen.Generate(model)
+	if err != nil {
+		return "", err
+	}
+
+	// The GitOps manifests deploy the tree from its directory in the repository, such as the DR
+	// variant's under the output directory's
+	if params.GitOps != "" && strings.EqualFold(params.OutputFormat, "crossplane") {
+		rel, err := filepath.Rel(params.OutputDir, dir)
+		if err != nil {
+			rel = "."
+		}
+		if err := crossplane.WriteGitOps(strings.ToLower(params.GitOps), dir, path.Join(params.GitOpsConfig.Path, filepath.ToSlash(rel)), params.GitOpsConfig); err != nil {
+			return "", err
+		}
+	}
+	return result, nil
+}
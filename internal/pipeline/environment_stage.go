@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// EnvironmentGenerationStage creates a pipeline stage that generates the manifests of one
+// environment into its own directory. It replaces the generation and output stages, so each
+// environment gets a complete layout, including a terraform.tfvars tagged with its name.
+// The stage is retryable after the transient failures of a generator plugin.
+func EnvironmentGenerationStage(params *ProcessingParams) Stage {
+	return environmentGenerationStage("EnvironmentGeneration", params)
+}
+
+// environmentGenerationStage creates an environment generation stage with a name
+func environmentGenerationStage(name string, params *ProcessingParams) Stage {
+	return NewRetryableStage(NewBaseStage(name, func(ctx context.Context, input interface{}) (interface{}, error) {
+		model, ok := input.(*models.InfrastructureModel)
+		if !ok {
+			return nil, fmt.Errorf("invalid input type for environment generation: %T", input)
+		}
+
+		// Check if the context is canceled
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if err := utils.EnsureDirectoryExists(params.OutputDir); err != nil {
+			return nil, fmt.Errorf("failed to create environment directory: %w", err)
+		}
+
+		result, err := generateManifestsInDirectory(ctx, model, params, params.OutputDir, nil)
+		if err != nil && params.Environment == "" {
+			return nil, fmt.Errorf("failed to generate manifests: %w", err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate environment %s: %w", params.Environment, err)
+		}
+
+		utils.GetLogger().Infow("Environment generated",
+			"environment", params.Environment,
+			"resources_count", len(model.Resources),
+			"dir", params.OutputDir,
+		)
+
+		return result, nil
+	}), nil)
+}
+
+// outputGenerationStage returns the stage generating the manifests of an environment into
+// its directory: for several output formats, one generating each at once into a
+// subdirectory named after it, from the same model
+func outputGenerationStage(params *ProcessingParams) Stage {
+	formats := formatParams(params)
+	if len(formats) == 1 {
+		return EnvironmentGenerationStage(params)
+	}
+	stages := make([]Stage, len(formats))
+	for i, formatParams := range formats {
+		stages[i] = environmentGenerationStage("Generation:"+formatParams.OutputFormat, formatParams)
+	}
+	return ParallelStage("EnvironmentGeneration", params.Workers, stages...)
+}
+
+// formatParams returns the parameters generating each output format: params itself for one
+// format, and for several, copies generating each into a subdirectory named after it
+func formatParams(params *ProcessingParams) []*ProcessingParams {
+	formats := OutputFormats(params.OutputFormat)
+	if len(formats) < 2 {
+		return []*ProcessingParams{params}
+	}
+	all := make([]*ProcessingParams, len(formats))
+	for i, format := range formats {
+		formatParams := *params
+		formatParams.OutputFormat = format
+		formatParams.OutputDir = filepath.Join(params.OutputDir, format)
+		formatParams.GitOpsConfig.Path = path.Join(params.GitOpsConfig.Path, format)
+		formatParams.OutputFile = ""
+		all[i] = &formatParams
+	}
+	return all
+}
@@ -0,0 +1,23 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// estimateOutput estimates the monthly cost of the Terraform of the output directory once
+// it was generated, and returns the breakdown per module
+func estimateOutput(params *ProcessingParams) (string, error) {
+	estimate, err := report.EstimateCosts(params.OutputDir, report.EstimateOptions{Estimator: params.Estimator})
+	if err != nil {
+		return "", fmt.Errorf("failed to estimate the cost of the generated Terraform: %w", err)
+	}
+
+	utils.GetLogger().Infow("Generated Terraform estimated",
+		"estimator", estimate.Estimator,
+		"monthly_cost", estimate.Total(),
+	)
+	return "Cost estimate:\n" + estimate.Text(), nil
+}
@@ -0,0 +1,179 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// Types of progress events
+const (
+	EventPipelineStarted   = "pipeline_started"
+	EventPipelineCompleted = "pipeline_completed"
+	EventPipelineFailed    = "pipeline_failed"
+	EventStageStarted      = "stage_started"
+	EventStageCompleted    = "stage_completed"
+	EventStageFailed       = "stage_failed"
+	EventStageRetrying     = "stage_retrying"
+	EventFileWritten       = "file_written"
+	EventWarning           = "warning"
+	EventProgress          = "progress"
+)
+
+// Event is a structured progress event, for programs following a run such as CI systems
+type Event struct {
+	Time  time.Time `json:"time"`
+	Type  string    `json:"event"`
+	Stage string    `json:"stage,omitempty"`
+	// Environment names the environment the event is about, for a description covering
+	// several of them or a requested environment
+	Environment string `json:"environment,omitempty"`
+	// Path is the file written (file_written)
+	Path string `json:"path,omitempty"`
+	// Percentage is the share of the environment's or run's stages completed (stage_completed)
+	Percentage int    `json:"percentage,omitempty"`
+	Message    string `json:"message,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// EventSink receives the progress events of a run. Emit may be called concurrently.
+type EventSink interface {
+	Emit(event Event)
+}
+
+// JSONLinesSink writes each event as a line of JSON
+type JSONLinesSink struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+// NewJSONLinesSink creates a sink writing events to w
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: w}
+}
+
+// Emit implements EventSink. Events without a time are stamped with the current time.
+func (s *JSONLinesSink) Emit(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w.Write(append(line, '\n'))
+}
+
+// EventProgressReporter is a progress reporter emitting stage events to a sink
+type EventProgressReporter struct {
+	sink           EventSink
+	environment    string
+	totalSteps     int
+	completedSteps int
+	mu             sync.Mutex
+}
+
+// NewEventProgressReporter creates a reporter emitting the stage events of a pipeline with
+// totalSteps stages, tagged with an environment (empty for none)
+func NewEventProgressReporter(sink EventSink, environment string, totalSteps int) *EventProgressReporter {
+	return &EventProgressReporter{
+		sink:        sink,
+		environment: environment,
+		totalSteps:  totalSteps,
+	}
+}
+
+// StartStage implements ProgressReporter
+func (r *EventProgressReporter) StartStage(stageName string) {
+	r.sink.Emit(Event{Type: EventStageStarted, Stage: stageName, Environment: r.environment})
+}
+
+// CompleteStage implements ProgressReporter
+func (r *EventProgressReporter) CompleteStage(stageName string) {
+	r.mu.Lock()
+	r.completedSteps++
+	percentage := 100
+	if r.totalSteps > 0 && r.completedSteps < r.totalSteps {
+		percentage = r.completedSteps * 100 / r.totalSteps
+	}
+	r.mu.Unlock()
+
+	r.sink.Emit(Event{Type: EventStageCompleted, Stage: stageName, Environment: r.environment, Percentage: percentage})
+}
+
+// FailStage implements ProgressReporter
+func (r *EventProgressReporter) FailStage(stageName string, err error) {
+	r.sink.Emit(Event{Type: EventStageFailed, Stage: stageName, Environment: r.environment, Error: err.Error()})
+}
+
+// RetryStage implements RetryReporter
+func (r *EventProgressReporter) RetryStage(stageName string, attempt int, maxAttempts int, delay time.Duration, err error) {
+	r.sink.Emit(Event{
+		Type:        EventStageRetrying,
+		Stage:       stageName,
+		Environment: r.environment,
+		Message:     fmt.Sprintf("attempt %d of %d failed; retrying in %s", attempt, maxAttempts, delay.Round(time.Millisecond)),
+		Error:       err.Error(),
+	})
+}
+
+// UpdateProgress implements ProgressReporter
+func (r *EventProgressReporter) UpdateProgress(message string, percentage int) {
+	r.sink.Emit(Event{Type: EventProgress, Environment: r.environment, Message: message, Percentage: percentage})
+}
+
+// newProgressReporter returns the progress reporter of a pipeline with totalSteps stages:
+// one emitting events when the run has an event sink, and the console reporter otherwise,
+// recording the stages to the metrics of the run if it has any
+func newProgressReporter(params *ProcessingParams, totalSteps int) ProgressReporter {
+	var reporter ProgressReporter
+	if params.Events != nil {
+		reporter = NewEventProgressReporter(params.Events, params.Environment, totalSteps)
+	} else {
+		reporter = NewConsoleProgressReporter(totalSteps)
+	}
+	if params.Metrics != nil {
+		reporter = &metricsReporter{
+			ProgressReporter: reporter,
+			metrics:          params.Metrics,
+			environment:      params.Environment,
+			dir:              params.OutputDir,
+		}
+	}
+	return reporter
+}
+
+// watchFileWrites reports each file written until the returned function is called, to the
+// event sink and the metrics of the run. Staged files are reported as events once they are
+// moved into the output directory instead.
+func watchFileWrites(params *ProcessingParams) func() {
+	if params.Events == nil && params.Metrics == nil {
+		return func() {}
+	}
+	utils.SetFileWriteHook(func(path string, size int) {
+		if params.Events != nil && !inStagingDirectory(path) {
+			params.Events.Emit(Event{Type: EventFileWritten, Path: path})
+		}
+		if params.Metrics != nil {
+			params.Metrics.fileWritten(path, size)
+		}
+	})
+	return func() { utils.SetFileWriteHook(nil) }
+}
+
+// warn writes a warning to the progress output, and emits it to the event sink
+func warn(params *ProcessingParams, message string) {
+	if params.ProgressWriter != nil {
+		fmt.Fprintf(params.ProgressWriter, "Warning: %s\n", message)
+	}
+	if params.Events != nil {
+		params.Events.Emit(Event{Type: EventWarning, Environment: params.Environment, Message: message})
+	}
+}
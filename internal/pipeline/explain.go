@@ -0,0 +1,159 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// ModelPipeline parses the description, or loads the spec, of the parameters and builds the
+// infrastructure model without generating anything. The extraction report of a parsed
+// description is returned with it, and is nil for a spec or a saved model.
+func (c *PipelineCoordinatorImpl) ModelPipeline(ctx context.Context, params *ProcessingParams) (*models.InfrastructureModel, *nlp.ExtractionReport, error) {
+	description, err := c.loadDescription(params)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load description: %w", err)
+	}
+
+	// Each environment has its own model, so one of them must be chosen
+	if environments := nlp.SplitEnvironments(description); len(environments) > 0 {
+		if params.Environment == "" {
+			names := make([]string, len(environments))
+			for i, environment := range environments {
+				names[i] = environment.Name
+			}
+			return nil, nil, fmt.Errorf("the description covers several environments (%s); choose one with --environment", strings.Join(names, ", "))
+		}
+		description, err = selectEnvironment(environments, params.Environment)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	modelPipeline := NewBasePipeline()
+	c.addModelStages(modelPipeline, params, description, c.nlpProcessor)
+	modelPipeline.SetProgressReporter(NewConsoleProgressReporter(2))
+	modelPipeline.SetRetryPolicy(params.Retry)
+
+	result, err := modelPipeline.Execute(ctx, description)
+	if err != nil {
+		return nil, nil, fmt.Errorf("pipeline execution failed: %w", err)
+	}
+	model, ok := result.(*models.InfrastructureModel)
+	if !ok {
+		return nil, nil, fmt.Errorf("unexpected pipeline result type: %T", result)
+	}
+
+	// The report of merged descriptions would only be that of the last one
+	var extraction *nlp.ExtractionReport
+	if processor, ok := c.nlpProcessor.(*NLPProcessorImpl); ok && params.SpecFile == "" && params.ModelFile == "" && len(params.InputFiles) == 0 {
+		extraction = processor.LastReport
+	}
+	return model, extraction, nil
+}
+
+// ExplainDescription builds the infrastructure model for the parameters and returns a
+// readable summary of it, without generating or writing anything
+func ExplainDescription(params *ProcessingParams) (*report.Explanation, error) {
+	model, extraction, err := describedModel(params)
+	if err != nil {
+		return nil, err
+	}
+	return report.ExplainModel(model, extraction), nil
+}
+
+// GraphDescription builds the infrastructure model for the parameters and renders the
+// dependencies of its resources as a graph in the given format, without generating or
+// writing anything
+func GraphDescription(params *ProcessingParams, format models.GraphFormat) (string, error) {
+	exporter, err := models.NewGraphExporter(format)
+	if err != nil {
+		return "", err
+	}
+	model, _, err := describedModel(params)
+	if err != nil {
+		return "", err
+	}
+	return exporter.Export(model), nil
+}
+
+// describedModel builds the infrastructure model for the parameters, with the extraction
+// report of a parsed description, for the commands printing it rather than generating it
+func describedModel(params *ProcessingParams) (*models.InfrastructureModel, *nlp.ExtractionReport, error) {
+	// The commands print the model, so the assumptions are not printed as well, and show it
+	// whichever required tags it lacks, whatever problems the model validation finds,
+	// whichever policies it violates and whichever quotas it would exceed
+	describeParams := *params
+	describeParams.RequiredTags = nil
+	describeParams.Policies = nil
+	describeParams.CheckQuotas = false
+	describeParams.SkipModelValidation = true
+	describeParams.OutputDir = "."
+	describeParams.OutputFile = ""
+	describeParams.ProgressWriter = nil
+
+	coordinator := NewPipelineCoordinator()
+	ctx := context.Background()
+
+	if err := coordinator.InitializePipeline(ctx, &describeParams); err != nil {
+		return nil, nil, err
+	}
+	return coordinator.ModelPipeline(ctx, &describeParams)
+}
+
+// DiffModelInputs builds the infrastructure models of two inputs and compares them, without
+// generating or writing anything. Each input is a description, a file holding one, or a spec
+// file, recognized by its .json, .yaml or .yml extension.
+func DiffModelInputs(params *ProcessingParams, before, after string) (*report.ModelDiff, error) {
+	beforeModel, err := inputModel(params, before)
+	if err != nil {
+		return nil, err
+	}
+	afterModel, err := inputModel(params, after)
+	if err != nil {
+		return nil, err
+	}
+	return report.DiffModels(beforeModel, afterModel), nil
+}
+
+// inputModel builds the infrastructure model of an input of DiffModelInputs
+func inputModel(params *ProcessingParams, input string) (*models.InfrastructureModel, error) {
+	inputParams := *params
+	inputParams.Description, inputParams.InputFile, inputParams.SpecFile, inputParams.ModelFile = "", "", "", ""
+	// A description is rarely a valid path, so any error means the input is not a file
+	info, statErr := os.Stat(input)
+	switch {
+	case statErr != nil && isSpecFile(input) && !strings.ContainsAny(input, " \n"):
+		return nil, fmt.Errorf("spec file does not exist: %s", input)
+	case statErr != nil || info.IsDir():
+		inputParams.Description = input
+	case isSpecFile(input):
+		inputParams.SpecFile = input
+	default:
+		inputParams.InputFile = input
+	}
+
+	model, _, err := describedModel(&inputParams)
+	if err != nil {
+		if inputParams.Description != "" {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", input, err)
+	}
+	return model, nil
+}
+
+// isSpecFile reports whether a file is a spec rather than a description, by its extension
+func isSpecFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package pipeline
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/riptano/iac_generator_cli/internal/hooks"
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+)
+
+// generateIntoDirectory generates the manifests for the parameters into dir, with the layout
+// generate writes to an output directory: one subdirectory per environment for a description
+// covering several of them. The hooks run in dir, so the files are those generate would
+// leave, such as after a formatter.
+func (c *PipelineCoordinatorImpl) generateIntoDirectory(ctx context.Context, params *ProcessingParams, dir string) error {
+	defer watchFileWrites(params)()
+	if err := runHooks(ctx, params, hooks.Pre, dir); err != nil {
+		return err
+	}
+	if err := c.generateManifestsIntoDirectory(ctx, params, dir); err != nil {
+		return err
+	}
+	return runHooks(ctx, params, hooks.Post, dir)
+}
+
+// generateManifestsIntoDirectory generates the manifests for the parameters into dir
+func (c *PipelineCoordinatorImpl) generateManifestsIntoDirectory(ctx context.Context, params *ProcessingParams, dir string) error {
+	dirParams := *params
+	dirParams.OutputDir = dir
+	dirParams.OutputFile = ""
+
+	description, err := c.loadDescription(&dirParams)
+	if err != nil {
+		return fmt.Errorf("failed to load description: %w", err)
+	}
+
+	// Environments are generated into subdirectories, as generate does
+	environments := nlp.SplitEnvironments(description)
+	if len(environments) > 0 && params.Environment == "" {
+		_, err := c.runEnvironments(ctx, &dirParams, environments)
+		return err
+	}
+	if len(environments) > 0 {
+		description, err = selectEnvironment(environments, params.Environment)
+		if err != nil {
+			return err
+		}
+	}
+
+	dirPipeline := NewBasePipeline()
+	c.addModelStages(dirPipeline, &dirParams, description, c.nlpProcessor)
+	totalSteps := 3 // NLP, Model Building, Generation
+	totalSteps += addPluginStages(dirPipeline, &dirParams)
+	totalSteps += addDRStages(dirPipeline, &dirParams)
+	dirPipeline.AddStage(generationStage(&dirParams, dirParams.OutputDir, outputGenerationStage(&dirParams)))
+	dirPipeline.SetProgressReporter(newProgressReporter(&dirParams, totalSteps))
+	dirPipeline.SetRetryPolicy(dirParams.Retry)
+
+	if _, err := dirPipeline.Execute(ctx, description); err != nil {
+		return fmt.Errorf("pipeline execution failed: %w", err)
+	}
+	return nil
+}
+
+// FilesPipeline generates the manifests for the parameters into a temporary directory and
+// returns their contents, keyed by their path in the output directory with forward slashes.
+// params.OutputDir is not used.
+func (c *PipelineCoordinatorImpl) FilesPipeline(ctx context.Context, params *ProcessingParams) (map[string]string, error) {
+	tempDir, err := os.MkdirTemp("", "iacgen-files-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := c.generateIntoDirectory(ctx, params, tempDir); err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string)
+	err = filepath.WalkDir(tempDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(tempDir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(content)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read generated files: %w", err)
+	}
+	return files, nil
+}
+
+// GenerateFiles generates the manifests for the parameters and returns their contents
+// instead of writing them to an output directory
+func GenerateFiles(ctx context.Context, params *ProcessingParams) (map[string]string, error) {
+	// Initialize with the current directory, so no output directory is created
+	initParams := *params
+	initParams.OutputDir = "."
+	initParams.OutputFile = ""
+
+	coordinator := NewPipelineCoordinator()
+	if err := coordinator.InitializePipeline(ctx, &initParams); err != nil {
+		return nil, err
+	}
+
+	return coordinator.FilesPipeline(ctx, params)
+}
+
+// sortedFilePaths returns the paths of generated files in order
+func sortedFilePaths(files map[string]string) []string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// WriteArchive writes generated files as a tar stream in path order, gzipped when compress
+// is set
+func WriteArchive(w io.Writer, files map[string]string, compress bool) error {
+	var gzipWriter *gzip.Writer
+	if compress {
+		gzipWriter = gzip.NewWriter(w)
+		w = gzipWriter
+	}
+
+	tarWriter := tar.NewWriter(w)
+	modTime := time.Now()
+	for _, path := range sortedFilePaths(files) {
+		header := &tar.Header{
+			Name:    path,
+			Mode:    0644,
+			Size:    int64(len(files[path])),
+			ModTime: modTime,
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(tarWriter, files[path]); err != nil {
+			return err
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	if gzipWriter != nil {
+		return gzipWriter.Close()
+	}
+	return nil
+}
+
+// ConcatenateFiles joins the manifests among generated files into one document, in path
+// order, each preceded by a "# Source:" comment with its path. YAML manifests are separated
+// by "---", so Crossplane output is a YAML stream. Files that are not manifests, such as
+// README.md, are left out, as are kustomizations, whose resources the document already holds.
+func ConcatenateFiles(files map[string]string) string {
+	var document strings.Builder
+	for _, file := range sortedFilePaths(files) {
+		name := path.Base(file)
+		switch {
+		case name == "kustomization.yaml":
+			continue
+		case strings.HasSuffix(name, ".yaml"), strings.HasSuffix(name, ".yml"):
+			fmt.Fprintf(&document, "---\n# Source: %s\n", file)
+		case strings.HasSuffix(name, ".tf"), strings.Contains(name, ".tfvars"):
+			if document.Len() > 0 {
+				document.WriteString("\n")
+			}
+			fmt.Fprintf(&document, "# Source: %s\n", file)
+		default:
+			continue
+		}
+		document.WriteString(files[file])
+		if !strings.HasSuffix(files[file], "\n") {
+			document.WriteString("\n")
+		}
+	}
+	return document.String()
+}
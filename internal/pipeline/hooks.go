@@ -0,0 +1,33 @@
+package pipeline
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/hooks"
+)
+
+// runHooks runs the hooks of a phase in dir, with the run's settings in IACGEN_ environment
+// variables
+func runHooks(ctx context.Context, params *ProcessingParams, phase string, dir string) error {
+	phaseHooks := params.Hooks.Phase(phase)
+	if len(phaseHooks) == 0 {
+		return nil
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		absDir = dir
+	}
+	env := []string{
+		"IACGEN_HOOK=" + phase,
+		"IACGEN_OUTPUT_DIR=" + absDir,
+		"IACGEN_OUTPUT_FORMAT=" + strings.ToLower(params.OutputFormat),
+		"IACGEN_REGION=" + params.Region,
+		"IACGEN_ENVIRONMENT=" + params.Environment,
+	}
+	return hooks.Run(ctx, phase, phaseHooks, dir, env, params.ProgressWriter, func(err error) {
+		warn(params, err.Error())
+	})
+}
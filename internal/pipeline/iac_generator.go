@@ -128,6 +128,40 @@ func GetAvailableGenerators() []string {
 	return []string{"terraform", "crossplane"}
 }
 
+// OutputFormats returns the formats of an output format, which may be a comma-separated list
+// such as terraform,crossplane, lower-cased and without duplicates
+func OutputFormats(format string) []string {
+	var formats []string
+	seen := make(map[string]bool)
+	for _, f := range strings.Split(format, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f != "" && !seen[f] {
+			seen[f] = true
+			formats = append(formats, f)
+		}
+	}
+	return formats
+}
+
+// ValidOutputFormats checks that an output format, which may be a comma-separated list such
+// as terraform,crossplane, names at least one format and only formats that are available
+func ValidOutputFormats(format string, available []string) bool {
+	formats := OutputFormats(format)
+	for _, f := range formats {
+		found := false
+		for _, a := range available {
+			if f == a {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return len(formats) > 0
+}
+
 // CreateGenerator creates a generator based on the format
 func CreateGenerator(format string, useTemplates bool) (generator.Generator, error) {
 	// Normalize format
@@ -3,7 +3,17 @@ package pipeline
 import (
 	"context"
 	"io"
-
+	"time"
+
+	"github.com/riptano/iac_generator_cli/internal/adapter/crossplane"
+	"github.com/riptano/iac_generator_cli/internal/adapter/terraform"
+	"github.com/riptano/iac_generator_cli/internal/hooks"
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/internal/plugin"
+	"github.com/riptano/iac_generator_cli/internal/quota"
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/riptano/iac_generator_cli/internal/retry"
 	"github.com/riptano/iac_generator_cli/pkg/models"
 )
 
@@ -18,6 +28,15 @@ type Stage interface {
 	Name() string
 }
 
+// RetryableStage is a stage whose failures may be transient, such as one calling an LLM or
+// another network service, so the pipeline retries it with its retry policy
+type RetryableStage interface {
+	Stage
+
+	// Retryable reports whether the stage is worth running again after failing with err
+	Retryable(err error) bool
+}
+
 // NLPProcessor defines the interface for natural language processing
 type NLPProcessor interface {
 	// ParseDescription parses a natural language description and extracts
@@ -86,6 +105,13 @@ type ProgressReporter interface {
 	UpdateProgress(message string, percentage int)
 }
 
+// RetryReporter is implemented by progress reporters that report the retries of stages
+type RetryReporter interface {
+	// RetryStage reports that the given attempt of a stage failed with err, and that the
+	// stage runs again after delay
+	RetryStage(stageName string, attempt int, maxAttempts int, delay time.Duration, err error)
+}
+
 // Pipeline defines the overall pipeline interface
 type Pipeline interface {
 	// Execute runs the entire pipeline with the given input
@@ -99,6 +125,9 @@ type Pipeline interface {
 
 	// SetProgressReporter sets a progress reporter for the pipeline
 	SetProgressReporter(reporter ProgressReporter)
+
+	// SetRetryPolicy sets how the retryable stages of the pipeline are retried
+	SetRetryPolicy(policy retry.Policy)
 }
 
 // PipelineCoordinator orchestrates the execution of the IaC generation pipeline
@@ -121,6 +150,20 @@ type ProcessingParams struct {
 	// InputFile is the path to a file containing the description
 	InputFile string
 
+	// InputFiles are the paths to several files with partial descriptions, such as of the
+	// network and of the cluster, whose models are merged into one
+	InputFiles []string
+
+	// SpecFile is the path to a YAML or JSON spec loaded instead of parsing a description
+	SpecFile string
+
+	// ModelFile is the path to a model saved with SaveModel, loaded as it is instead of
+	// parsing a description
+	ModelFile string
+
+	// SaveModel is the path the built model is saved to as JSON, before it is generated
+	SaveModel string
+
 	// OutputFormat is the desired output format (terraform, crossplane, etc.)
 	OutputFormat string
 
@@ -139,6 +182,201 @@ type ProcessingParams struct {
 	// Debug enables debug logging
 	Debug bool
 
+	// Environment names the environment being generated: one of several a description
+	// covers, or the one requested with --environment, which overrides the environment the
+	// description names (empty otherwise)
+	Environment string
+
+	// Tags are applied to every resource, under the tags the description states, and
+	// TagOverrides over them
+	Tags         map[string]string
+	TagOverrides map[string]string
+
+	// RequiredTags are the tags every model must have, such as CostCenter; generation fails
+	// without them
+	RequiredTags []string
+
+	// Policies are Rego files, or directories of them, whose deny rules the model must not
+	// trigger; the messages of their warn rules are reported as warnings
+	Policies []string
+
+	// CheckQuotas checks the model against the AWS service quotas of its regions before
+	// generating, and warns about the quotas it would exceed, such as VPCs per region or NAT
+	// gateways per availability zone. QuotaChecker creates the checker of a region; nil uses
+	// the credentials of the AWS SDK's default chain.
+	CheckQuotas  bool
+	QuotaChecker func(ctx context.Context, region string) (*quota.Checker, error)
+
+	// SkipModelValidation generates the model without checking the relationships between its
+	// resources, such as overlapping subnets or node groups of a missing cluster
+	SkipModelValidation bool
+
+	// Naming renames the resources of the model after a pattern, such as
+	// {env}-{app}-{resource}; the zero policy keeps their names
+	Naming infra.NamingPolicy
+
+	// AddonVersions override the versions of the EKS add-ons, by add-on name, such as
+	// coredns=v1.11.4-eksbuild.2; other add-ons get the version the description pins, or the
+	// one the compatibility table lists for the cluster's Kubernetes version
+	AddonVersions map[string]string
+
+	// DRRegion is the secondary AWS region for the disaster-recovery variant (empty disables it)
+	DRRegion string
+
+	// ComplianceReport writes a compliance matrix alongside the generated manifests
+	ComplianceReport bool
+
+	// Lint lints the generated Terraform with tflint, appending the findings to the result
+	Lint bool
+
+	// LintIAM lints the IAM policies of the generated files for wildcards, web identity trust
+	// without conditions and broad managed policies, appending the findings to the result
+	LintIAM bool
+
+	// Estimate estimates the monthly cost of the generated Terraform, appending the
+	// breakdown per module to the result, with Estimator: infracost, or the built-in prices
+	// of the common resources. By default infracost is used when it is in PATH.
+	Estimate  bool
+	Estimator string
+
+	// Scan scans the generated files for security misconfigurations with trivy or tfsec,
+	// appending a summary to the result, and ScanFailOn fails the run on findings of a
+	// severity (LOW, MEDIUM, HIGH or CRITICAL) or above (empty never fails it)
+	Scan       bool
+	ScanFailOn string
+
+	// Checkov checks the generated Terraform and manifests with checkov, appending the
+	// consolidated report to the result; the failures of the checks CheckovSuppressions
+	// name are reported as suppressed
+	Checkov             bool
+	CheckovSuppressions []report.CheckovSuppression
+
+	// Backend is the backend the Terraform configuration keeps its state in (empty means
+	// local), with the settings of its block in BackendConfig
+	Backend       string
+	BackendConfig map[string]string
+
+	// BackendBootstrap writes the configuration creating the S3 bucket and DynamoDB lock
+	// table of an s3 backend to backend-bootstrap/
+	BackendBootstrap bool
+
+	// ModuleSource is where the Terraform VPC and EKS modules come from (local or registry;
+	// empty means local)
+	ModuleSource string
+
+	// ModuleLayout is how the Terraform resources are split into modules (modules, by-type or
+	// flat; empty means modules), and ModuleNames maps the modules (vpc, eks and data) to
+	// custom names
+	ModuleLayout string
+	ModuleNames  map[string]string
+
+	// CrossplaneProvider is the provider the Crossplane managed resources are written for
+	// (contrib or upbound; empty means contrib)
+	CrossplaneProvider string
+
+	// CrossplaneCredentials is the source of the Crossplane providers' AWS credentials (secret
+	// or irsa; empty means secret), and CrossplaneRoleARN the IAM role they assume with irsa
+	CrossplaneCredentials string
+	CrossplaneRoleARN     string
+
+	// Compositions writes Crossplane Compositions of the network and the EKS cluster, with
+	// example claims
+	Compositions bool
+
+	// Team is the team owning the example Crossplane claims, whose namespace, ServiceAccount
+	// and RBAC are written beside them; empty keeps the claims in default
+	Team string
+
+	// ConnectionSecrets is the mode of the connection secrets of the Crossplane EKS clusters
+	// and RDS instances (none, resources or composite; empty means none), and
+	// ConnectionSecretNamespace the namespace they are written to
+	ConnectionSecrets         string
+	ConnectionSecretNamespace string
+
+	// DeletionPolicy is the deletion policy of every Crossplane managed resource (orphan or
+	// delete; empty means orphan), and ManagementPolicy their management policy (full or
+	// observe; empty means full)
+	DeletionPolicy   string
+	ManagementPolicy string
+
+	// CrossplaneImports maps the kinds and names of Crossplane managed resources, such as
+	// VPC/main-vpc, to the IDs of the existing AWS resources they adopt, only observing them
+	CrossplaneImports map[string]string
+
+	// GitOps writes manifests deploying the Crossplane output with a GitOps tool (argocd or
+	// flux; empty writes none), with the settings in GitOpsConfig. GitOpsConfig.Path is the
+	// path of OutputDir in the repository.
+	GitOps       string
+	GitOpsConfig crossplane.GitOpsConfig
+
+	// TerraformVersion is the minimum Terraform version of the configuration, such as 1.5.7,
+	// and ProviderVersion the version constraint of the AWS provider, such as "~> 5.40"; empty
+	// keeps the defaults
+	TerraformVersion string
+	ProviderVersion  string
+
+	// Workspaces get a Terraform variables file each under env/, sized for the workspace, and
+	// a Makefile selecting the workspace and its variables file
+	Workspaces []terraform.Workspace
+
+	// Imports maps the addresses of generated Terraform resources to the IDs of the existing
+	// AWS resources they adopt, written as import blocks to imports.tf
+	Imports map[string]string
+
+	// Lifecycle maps Terraform resource types to the lifecycle settings, such as
+	// prevent_destroy, added to their resources
+	Lifecycle map[string]terraform.Lifecycle
+
+	// FormatOutput formats the generated Terraform files as terraform fmt does
+	FormatOutput bool
+
+	// LockPlatforms are the platforms the Terraform dependency lock file has provider
+	// checksums for; none writes no lock file
+	LockPlatforms []string
+
+	// CacheDir is where the parsed and built models are checkpointed, keyed by the input, so
+	// a rerun with the same input resumes from them (empty disables checkpoints)
+	CacheDir string
+
+	// Workers is the number of environments and stages generated at once (below one means
+	// one per CPU)
+	Workers int
+
+	// NLPBackend selects the entity extraction backend (regex or llm; empty means regex)
+	NLPBackend string
+
+	// LLMConfig holds the settings for LLM-backed entity extraction
+	LLMConfig nlp.BackendConfig
+
+	// Clarifier answers follow-up questions about ambiguous descriptions (nil applies defaults)
+	Clarifier nlp.Clarifier
+
+	// Reviewer is shown the parsed entities and planned resources before generation, and
+	// can edit, accept or abort them (nil generates without a review)
+	Reviewer nlp.Reviewer
+
+	// Plugins are the plugins discovered in the plugin directory: generator plugins add
+	// output formats, and stage plugins run on the model before it is generated
+	Plugins []*plugin.Plugin
+
+	// Hooks are the commands run in the output directory before and after generation
+	Hooks hooks.Hooks
+
+	// Retry is how retryable stages, such as stage plugins, are retried (the zero value runs
+	// every stage once)
+	Retry retry.Policy
+
+	// KeepPartial writes the files directly into the output directory instead of a staging
+	// directory, so the files of a failed or cancelled run are left in place
+	KeepPartial bool
+
+	// Metrics collects the time each stage takes and the files it writes (nil collects none)
+	Metrics *Metrics
+
 	// ProgressWriter is where progress updates are written
 	ProgressWriter io.Writer
+
+	// Events receives the run's progress as structured events (nil reports progress to the
+	// console only)
+	Events EventSink
 }
\ No newline at end of file
@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// lintOutput lints the Terraform files of the output directory with tflint once they were
+// generated, and returns the findings as a report. The findings do not fail the run, as
+// the files were written; validate --lint gates on them.
+func lintOutput(params *ProcessingParams) (string, error) {
+	validation, err := report.ValidateDirectory(params.OutputDir, report.ValidationOptions{
+		Format:        report.FormatTerraform,
+		SkipTerraform: true,
+		Lint:          true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to lint the generated Terraform: %w", err)
+	}
+
+	errors, warnings := validation.Counts()
+	utils.GetLogger().Infow("Generated Terraform linted",
+		"dir", params.OutputDir,
+		"errors", errors,
+		"warnings", warnings,
+	)
+	return "Lint findings:\n" + validation.Text(), nil
+}
+
+// lintIAMOutput lints the IAM policies of the generated files once they were written, and
+// returns the findings with their scoped alternatives as a report. Like the tflint findings,
+// they do not fail the run; validate --iam gates on them.
+func lintIAMOutput(params *ProcessingParams) (string, error) {
+	validation, err := report.ValidateDirectory(params.OutputDir, report.ValidationOptions{
+		SkipTerraform: true,
+		IAM:           true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to lint the generated IAM policies: %w", err)
+	}
+
+	errors, warnings := validation.Counts()
+	utils.GetLogger().Infow("Generated IAM policies linted",
+		"dir", params.OutputDir,
+		"errors", errors,
+		"warnings", warnings,
+	)
+	return "IAM findings:\n" + validation.Text(), nil
+}
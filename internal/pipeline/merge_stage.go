@@ -0,0 +1,66 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// DescriptionMergingStage creates a pipeline stage that parses the partial descriptions of
+// several files with processor and merges their models into one, failing with the conflicts
+// between them. Descriptions stating no region are parsed in the region another one states.
+// It replaces the NLP stage, so the stage input is ignored.
+func DescriptionMergingStage(files []string, processor NLPProcessor) Stage {
+	return NewBaseStage("DescriptionMerging", func(ctx context.Context, input interface{}) (interface{}, error) {
+		descriptions := make([]string, len(files))
+		region := ""
+		for i, file := range files {
+			description, err := utils.ReadFromFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read input file: %w", err)
+			}
+			descriptions[i] = strings.TrimSpace(description)
+			if environments := nlp.SplitEnvironments(descriptions[i]); len(environments) > 0 {
+				return nil, fmt.Errorf("%s covers several environments, which cannot be merged with other descriptions", file)
+			}
+			if stated := nlp.RegionPattern.FindString(descriptions[i]); stated != "" && region == "" {
+				region = strings.ToLower(stated)
+			}
+		}
+
+		partials := make([]infra.PartialModel, 0, len(files))
+		for i, file := range files {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			// A description stating no region is in the region the others state, rather than
+			// the default one
+			description := descriptions[i]
+			if region != "" && !nlp.RegionPattern.MatchString(description) {
+				description += " in " + region
+			}
+
+			model, err := processor.ParseDescription(ctx, description)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			partials = append(partials, infra.PartialModel{Source: file, Model: model})
+		}
+
+		model, err := infra.MergeModels(partials)
+		if err != nil {
+			return nil, err
+		}
+
+		utils.GetLogger().Debugw("Descriptions merged",
+			"files", files,
+			"resources_count", len(model.Resources),
+		)
+		return model, nil
+	})
+}
@@ -0,0 +1,276 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// StageMetrics are the measurements of one stage of a run
+type StageMetrics struct {
+	Stage string `json:"stage"`
+	// Environment is the environment the stage generated, for a description covering several
+	// of them or a requested environment
+	Environment string `json:"environment,omitempty"`
+	// Duration is how long the stage ran, retries included
+	Duration time.Duration `json:"-"`
+	// Files and Bytes count the files the stage wrote and their size
+	Files   int   `json:"files"`
+	Bytes   int64 `json:"bytes"`
+	Retries int   `json:"retries,omitempty"`
+	Failed  bool  `json:"failed,omitempty"`
+}
+
+// MarshalJSON writes the duration in milliseconds
+func (m StageMetrics) MarshalJSON() ([]byte, error) {
+	type stageMetrics StageMetrics
+	return json.Marshal(struct {
+		stageMetrics
+		DurationMS float64 `json:"duration_ms"`
+	}{stageMetrics(m), milliseconds(m.Duration)})
+}
+
+// Metrics collects the time each stage of a run takes and the files it writes, such as to
+// find the slow stages. Stages of several environments may run at once.
+type Metrics struct {
+	mu       sync.Mutex
+	stages   []*StageMetrics
+	running  []*runningStage
+	files    int
+	bytes    int64
+	started  time.Time
+	finished time.Time
+}
+
+// runningStage is a stage that started and has not ended
+type runningStage struct {
+	metrics *StageMetrics
+	// dir is the output directory of the stage's pipeline
+	dir   string
+	start time.Time
+}
+
+// NewMetrics creates an empty metrics collector
+func NewMetrics() *Metrics {
+	return &Metrics{}
+}
+
+// Stages returns the metrics of the stages, in the order they started
+func (m *Metrics) Stages() []StageMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stages := make([]StageMetrics, len(m.stages))
+	for i, stage := range m.stages {
+		stages[i] = *stage
+	}
+	return stages
+}
+
+// Duration returns the time from the start of the first stage to the end of the last
+func (m *Metrics) Duration() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.finished.Sub(m.started)
+}
+
+// Files returns the number of files written during the run and their size
+func (m *Metrics) Files() (int, int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.files, m.bytes
+}
+
+// JSON returns the JSON report of the metrics
+func (m *Metrics) JSON() (string, error) {
+	files, bytes := m.Files()
+	data, err := json.MarshalIndent(struct {
+		DurationMS float64        `json:"duration_ms"`
+		Files      int            `json:"files"`
+		Bytes      int64          `json:"bytes"`
+		Stages     []StageMetrics `json:"stages"`
+	}{milliseconds(m.Duration()), files, bytes, m.Stages()}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal metrics report: %w", err)
+	}
+	return string(data), nil
+}
+
+// Text returns the metrics as a table of the stages followed by the totals
+func (m *Metrics) Text() string {
+	stages := m.Stages()
+	environments := false
+	for _, stage := range stages {
+		if stage.Environment != "" {
+			environments = true
+		}
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	row := func(stage, environment, duration, files, size string) {
+		if environments {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", stage, environment, duration, files, size)
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", stage, duration, files, size)
+		}
+	}
+	row("STAGE", "ENVIRONMENT", "DURATION", "FILES", "BYTES")
+	for _, stage := range stages {
+		name := stage.Stage
+		if stage.Retries > 0 {
+			name += fmt.Sprintf(" (%d retries)", stage.Retries)
+		}
+		if stage.Failed {
+			name += " (failed)"
+		}
+		row(name, stage.Environment, formatDuration(stage.Duration), fmt.Sprint(stage.Files), formatBytes(stage.Bytes))
+	}
+	files, size := m.Files()
+	row("Total", "", formatDuration(m.Duration()), fmt.Sprint(files), formatBytes(size))
+	w.Flush()
+	return buf.String()
+}
+
+// startStage records the start of a stage of an environment's pipeline writing to dir
+func (m *Metrics) startStage(stage, environment, dir string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if m.started.IsZero() {
+		m.started = now
+	}
+	metrics := &StageMetrics{Stage: stage, Environment: environment}
+	m.stages = append(m.stages, metrics)
+	m.running = append(m.running, &runningStage{metrics: metrics, dir: dir, start: now})
+}
+
+// endStage records the end of a running stage
+func (m *Metrics) endStage(stage, environment string, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, running := range m.running {
+		if running.metrics.Stage != stage || running.metrics.Environment != environment {
+			continue
+		}
+		now := time.Now()
+		running.metrics.Duration = now.Sub(running.start)
+		running.metrics.Failed = failed
+		if now.After(m.finished) {
+			m.finished = now
+		}
+		m.running = append(m.running[:i], m.running[i+1:]...)
+		return
+	}
+}
+
+// retryStage counts a retry of a running stage
+func (m *Metrics) retryStage(stage, environment string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, running := range m.running {
+		if running.metrics.Stage == stage && running.metrics.Environment == environment {
+			running.metrics.Retries++
+		}
+	}
+}
+
+// fileWritten counts a file written during the run, for the running stage whose output
+// directory holds it, or the only running stage
+func (m *Metrics) fileWritten(path string, size int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files++
+	m.bytes += int64(size)
+
+	var owner *runningStage
+	for _, running := range m.running {
+		if withinDirectory(path, running.dir) && (owner == nil || len(running.dir) > len(owner.dir)) {
+			owner = running
+		}
+	}
+	if owner == nil && len(m.running) == 1 {
+		owner = m.running[0]
+	}
+	if owner != nil {
+		owner.metrics.Files++
+		owner.metrics.Bytes += int64(size)
+	}
+}
+
+// withinDirectory reports whether a path is in a directory
+func withinDirectory(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// metricsReporter is a progress reporter recording the stages it reports to metrics
+type metricsReporter struct {
+	ProgressReporter
+	metrics     *Metrics
+	environment string
+	dir         string
+}
+
+// StartStage implements ProgressReporter
+func (r *metricsReporter) StartStage(stageName string) {
+	r.metrics.startStage(stageName, r.environment, r.dir)
+	r.ProgressReporter.StartStage(stageName)
+}
+
+// CompleteStage implements ProgressReporter
+func (r *metricsReporter) CompleteStage(stageName string) {
+	r.metrics.endStage(stageName, r.environment, false)
+	r.ProgressReporter.CompleteStage(stageName)
+}
+
+// FailStage implements ProgressReporter
+func (r *metricsReporter) FailStage(stageName string, err error) {
+	r.metrics.endStage(stageName, r.environment, true)
+	r.ProgressReporter.FailStage(stageName, err)
+}
+
+// RetryStage implements RetryReporter
+func (r *metricsReporter) RetryStage(stageName string, attempt int, maxAttempts int, delay time.Duration, err error) {
+	r.metrics.retryStage(stageName, r.environment)
+	if reporter, ok := r.ProgressReporter.(RetryReporter); ok {
+		reporter.RetryStage(stageName, attempt, maxAttempts, delay, err)
+	}
+}
+
+// unwrapProgressReporter returns the reporter a metrics reporter wraps
+func unwrapProgressReporter(reporter ProgressReporter) ProgressReporter {
+	if metrics, ok := reporter.(*metricsReporter); ok {
+		return metrics.ProgressReporter
+	}
+	return reporter
+}
+
+// milliseconds returns a duration in milliseconds, to the microsecond
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// formatDuration rounds a duration for the metrics table
+func formatDuration(d time.Duration) string {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// formatBytes formats a size in bytes, KiB or MiB
+func formatBytes(size int64) string {
+	switch {
+	case size < 1024:
+		return fmt.Sprintf("%d B", size)
+	case size < 1024*1024:
+		return fmt.Sprintf("%.1f KiB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%.1f MiB", float64(size)/(1024*1024))
+	}
+}
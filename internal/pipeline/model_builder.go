@@ -14,6 +14,16 @@ import (
 type ModelBuilderImpl struct {
 	// region is the AWS region to use for resources
 	region string
+	// environment overrides the environment the description names (empty keeps it)
+	environment string
+	// tags are applied to every model, under the tags the description states, and
+	// tagOverrides over them
+	tags         map[string]string
+	tagOverrides map[string]string
+	// addonVersions override the versions of the EKS add-ons, by add-on name
+	addonVersions map[string]string
+	// naming renames the resources of every model
+	naming infra.NamingPolicy
 	logger *zap.SugaredLogger
 }
 
@@ -25,6 +35,40 @@ func NewModelBuilder(region string) *ModelBuilderImpl {
 	}
 }
 
+// WithEnvironment sets the environment every model is made for, overriding the one the
+// description names
+func (b *ModelBuilderImpl) WithEnvironment(environment string) *ModelBuilderImpl {
+	b.environment = environment
+	return b
+}
+
+// WithTags sets tags applied to every model, such as those of the config file. Tags the
+// description states with the same keys override them.
+func (b *ModelBuilderImpl) WithTags(tags map[string]string) *ModelBuilderImpl {
+	b.tags = tags
+	return b
+}
+
+// WithTagOverrides sets tags applied to every model over the tags the description states, such
+// as those given for one run
+func (b *ModelBuilderImpl) WithTagOverrides(tags map[string]string) *ModelBuilderImpl {
+	b.tagOverrides = tags
+	return b
+}
+
+// WithAddonVersions sets the versions the EKS add-ons of every model get, by add-on name,
+// overriding those the description pins and the compatibility table
+func (b *ModelBuilderImpl) WithAddonVersions(versions map[string]string) *ModelBuilderImpl {
+	b.addonVersions = versions
+	return b
+}
+
+// WithNaming sets the naming policy the resources of every model are renamed after
+func (b *ModelBuilderImpl) WithNaming(policy infra.NamingPolicy) *ModelBuilderImpl {
+	b.naming = policy
+	return b
+}
+
 // BuildModel implements ModelBuilder
 func (b *ModelBuilderImpl) BuildModel(ctx context.Context, input interface{}) (*models.InfrastructureModel, error) {
 	b.logger.Debugw("Building infrastructure model")
@@ -53,6 +97,14 @@ func (b *ModelBuilderImpl) BuildModel(ctx context.Context, input interface{}) (*
 		return nil, fmt.Errorf("invalid input type for model building: %T", input)
 	}
 
+	model.Tags = infra.MergeTags(b.tags, model.Tags, b.tagOverrides)
+
+	infra.ApplyEnvironment(model, b.environment)
+	if err := infra.ApplyNamingPolicy(model, b.naming); err != nil {
+		return nil, err
+	}
+	infra.ResolveEKSAddonVersions(model, b.addonVersions)
+
 	// Enhance the model with additional information
 	enhancedModel, err := b.EnhanceModel(model)
 	if err != nil {
@@ -68,7 +120,11 @@ func (b *ModelBuilderImpl) BuildModel(ctx context.Context, input interface{}) (*
 
 // EnhanceModel implements ModelBuilder
 func (b *ModelBuilderImpl) EnhanceModel(model *models.InfrastructureModel) (*models.InfrastructureModel, error) {
-	// Apply region to resources that support it
+	// Apply the model's region to resources that support it, or the configured region to a
+	// model without one, so that resources are not taken for another region's
+	if model.Region == "" {
+		model.Region = b.region
+	}
 	for i, resource := range model.Resources {
 		// Check resource type and update region if applicable
 		switch resource.Type {
@@ -84,8 +140,8 @@ func (b *ModelBuilderImpl) EnhanceModel(model *models.InfrastructureModel) (*mod
 			}
 
 			// Add region property if not already set
-			if !hasRegion && b.region != "" {
-				model.Resources[i].AddProperty("region", b.region)
+			if !hasRegion && model.Region != "" {
+				model.Resources[i].AddProperty("region", model.Region)
 			}
 		}
 	}
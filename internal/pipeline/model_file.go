@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/riptano/iac_generator_cli/internal/spec"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// SaveModelFile saves an infrastructure model as indented JSON, so it can be reviewed and
+// versioned, then generated again with LoadModelFile without parsing the description
+func SaveModelFile(path string, model *models.InfrastructureModel) error {
+	data, err := json.MarshalIndent(model, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode model: %w", err)
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := utils.EnsureDirectoryExists(dir); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to save model: %w", err)
+	}
+	return nil
+}
+
+// LoadModelFile loads a model saved by SaveModelFile. Unlike a model spec, the model is taken
+// as it is: its resources are already named and tagged after its environment.
+func LoadModelFile(path string) (*models.InfrastructureModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model file: %w", err)
+	}
+
+	var model models.InfrastructureModel
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&model); err != nil {
+		return nil, fmt.Errorf("invalid model file %s: %w", path, err)
+	}
+	spec.NormalizeModel(&model)
+	if err := spec.ValidateModel(&model); err != nil {
+		return nil, fmt.Errorf("invalid model file %s: %w", path, err)
+	}
+	return &model, nil
+}
+
+// ModelLoadingStage creates a pipeline stage that loads the infrastructure model saved in a
+// file. It replaces the NLP stage, so the stage input is ignored.
+func ModelLoadingStage(modelFile string) Stage {
+	return NewBaseStage("ModelLoading", func(ctx context.Context, input interface{}) (interface{}, error) {
+		// Check if the context is canceled
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		model, err := LoadModelFile(modelFile)
+		if err != nil {
+			return nil, err
+		}
+
+		utils.GetLogger().Debugw("Model loaded",
+			"file", modelFile,
+			"resources_count", len(model.Resources),
+		)
+		return model, nil
+	})
+}
+
+// modelSavingStage wraps the stage building the model, saving the model it builds to
+// modelFile
+func modelSavingStage(stage Stage, modelFile string) Stage {
+	return NewBaseStage(stage.Name(), func(ctx context.Context, input interface{}) (interface{}, error) {
+		output, err := stage.Execute(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		if model, ok := output.(*models.InfrastructureModel); ok {
+			if err := SaveModelFile(modelFile, model); err != nil {
+				return nil, err
+			}
+			utils.GetLogger().Infow("Model saved", "file", modelFile)
+		}
+		return output, nil
+	})
+}
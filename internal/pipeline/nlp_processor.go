@@ -3,8 +3,10 @@ package pipeline
 import (
 	"context"
 	"fmt"
+	"io"
 	"strings"
 
+	"github.com/riptano/iac_generator_cli/internal/infra"
 	"github.com/riptano/iac_generator_cli/internal/nlp"
 	"github.com/riptano/iac_generator_cli/internal/utils"
 	"github.com/riptano/iac_generator_cli/pkg/models"
@@ -13,22 +15,37 @@ import (
 
 // NLPProcessorImpl is the implementation of the NLPProcessor interface
 type NLPProcessorImpl struct {
-	// parser is the underlying NLP parser
-	parser *nlp.Parser
-	logger *zap.SugaredLogger
+	// backend extracts entities from the description
+	backend nlp.Backend
+	logger  *zap.SugaredLogger
+
+	// ReportWriter receives the assumptions section printed before generation (nil disables it)
+	ReportWriter io.Writer
+
+	// Region is the region of descriptions that name none, such as that of --region (empty
+	// keeps the parser's default)
+	Region string
+
+	// LastReport is the extraction report of the last description parsed
+	LastReport *nlp.ExtractionReport
 }
 
-// NewNLPProcessor creates a new NLP processor
+// NewNLPProcessor creates a new NLP processor using the regex parser
 func NewNLPProcessor() *NLPProcessorImpl {
+	return NewNLPProcessorWithBackend(nlp.NewRegexBackend())
+}
+
+// NewNLPProcessorWithBackend creates a new NLP processor using the given backend
+func NewNLPProcessorWithBackend(backend nlp.Backend) *NLPProcessorImpl {
 	return &NLPProcessorImpl{
-		parser: nlp.NewParser(),
-		logger: utils.GetLogger(),
+		backend: backend,
+		logger:  utils.GetLogger(),
 	}
 }
 
 // ParseDescription implements NLPProcessor
 func (p *NLPProcessorImpl) ParseDescription(ctx context.Context, description string) (*models.InfrastructureModel, error) {
-	p.logger.Debugw("Parsing description", "length", len(description))
+	p.logger.Debugw("Parsing description", "length", len(description), "backend", p.backend.Name())
 
 	// Check if the context is canceled
 	if ctx.Err() != nil {
@@ -45,11 +62,32 @@ func (p *NLPProcessorImpl) ParseDescription(ctx context.Context, description str
 	enhancedDescription := nlp.EnhanceDescription(description)
 
 	// Parse the description
-	model, err := nlp.ParseDescription(enhancedDescription)
+	model, report, err := nlp.ParseDescriptionWithReport(ctx, enhancedDescription, p.backend)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse description: %w", err)
 	}
 
+	// The configured region applies when the description names none
+	if p.Region != "" && report.Confidence["region"] == nlp.ConfidenceDefault {
+		infra.MoveToRegion(model, p.Region)
+		for i := range report.Assumptions {
+			if report.Assumptions[i].Entity == "region" {
+				report.Assumptions[i].Value = model.Region
+			}
+		}
+	}
+
+	p.logger.Debugw("Assessed extracted entities",
+		"assumptions", len(report.Assumptions),
+		"confidence", report.Confidence,
+	)
+	p.LastReport = report
+	if p.ReportWriter != nil {
+		fmt.Fprintln(p.ReportWriter)
+		fmt.Fprint(p.ReportWriter, report.Text())
+		fmt.Fprintln(p.ReportWriter)
+	}
+
 	p.logger.Debugw("Description parsed successfully",
 		"resources_count", len(model.Resources),
 		"description_length", len(description),
@@ -77,6 +115,7 @@ func (p *NLPProcessorImpl) ValidateDescription(description string) (bool, string
 		"rds", "database", "lambda", "function", "dynamodb", "table",
 		"cloudwatch", "alarm", "metric", "gateway", "igw", "nat",
 		"eks", "kubernetes", "cluster", "node", "group",
+		"asg", "auto scaling", "launch template",
 	}
 
 	containsInfraTerm := false
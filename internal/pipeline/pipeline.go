@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/riptano/iac_generator_cli/internal/retry"
 	"github.com/riptano/iac_generator_cli/internal/utils"
 	"go.uber.org/zap"
 )
@@ -14,6 +16,7 @@ type BasePipeline struct {
 	stages        []Stage
 	errorHandler  func(error) error
 	reporter      ProgressReporter
+	retryPolicy   retry.Policy
 	mu            sync.Mutex
 	logger        *zap.SugaredLogger
 }
@@ -39,6 +42,7 @@ func (p *BasePipeline) Execute(ctx context.Context, input interface{}) (interfac
 	stages := make([]Stage, len(p.stages))
 	copy(stages, p.stages)
 	reporter := p.reporter
+	retryPolicy := p.retryPolicy
 	p.mu.Unlock()
 
 	var result interface{} = input
@@ -72,7 +76,7 @@ func (p *BasePipeline) Execute(ctx context.Context, input interface{}) (interfac
 		
 		// Run the stage execution in a separate goroutine
 		go func() {
-			stageResult, err := stage.Execute(ctx, result)
+			stageResult, err := p.executeStage(ctx, stage, result, retryPolicy, reporter)
 			resultCh <- struct {
 				res interface{}
 				err error
@@ -142,6 +146,40 @@ func (p *BasePipeline) AddStage(stage Stage) {
 	p.stages = append(p.stages, stage)
 }
 
+// SetRetryPolicy sets how the retryable stages of the pipeline are retried
+func (p *BasePipeline) SetRetryPolicy(policy retry.Policy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.retryPolicy = policy
+}
+
+// executeStage runs a stage, retrying a retryable one with the policy after the failures it
+// accepts. Each retry is logged and reported to reporters that report retries.
+func (p *BasePipeline) executeStage(ctx context.Context, stage Stage, input interface{}, policy retry.Policy, reporter ProgressReporter) (interface{}, error) {
+	retryable, ok := stage.(RetryableStage)
+	if !ok || policy.MaxAttempts < 2 {
+		return stage.Execute(ctx, input)
+	}
+
+	var output interface{}
+	err := retry.Do(ctx, policy, retryable.Retryable, func(attempt int, delay time.Duration, err error) {
+		p.logger.Warnw("Retrying pipeline stage",
+			"stage", stage.Name(),
+			"attempt", attempt,
+			"max_attempts", policy.MaxAttempts,
+			"delay", delay,
+			"error", err)
+		if retryReporter, ok := reporter.(RetryReporter); ok {
+			retryReporter.RetryStage(stage.Name(), attempt, policy.MaxAttempts, delay, err)
+		}
+	}, func(ctx context.Context) error {
+		var err error
+		output, err = stage.Execute(ctx, input)
+		return err
+	})
+	return output, err
+}
+
 // SetErrorHandler sets a custom error handler for the pipeline
 func (p *BasePipeline) SetErrorHandler(handler func(error) error) {
 	p.mu.Lock()
@@ -183,6 +221,26 @@ func (s *BaseStage) Name() string {
 	return s.name
 }
 
+// retryableStage marks a stage as retryable
+type retryableStage struct {
+	Stage
+	retryable func(err error) bool
+}
+
+// Retryable implements RetryableStage
+func (s *retryableStage) Retryable(err error) bool {
+	return s.retryable(err)
+}
+
+// NewRetryableStage marks a stage as retryable after the failures retryable accepts (nil
+// accepts the errors marked with retry.Transient)
+func NewRetryableStage(stage Stage, retryable func(err error) bool) RetryableStage {
+	if retryable == nil {
+		retryable = retry.IsTransient
+	}
+	return &retryableStage{Stage: stage, retryable: retryable}
+}
+
 // ConsoleProgressReporter is a simple progress reporter that writes to the console
 type ConsoleProgressReporter struct {
 	output         chan string
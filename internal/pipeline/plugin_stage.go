@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/plugin"
+	"github.com/riptano/iac_generator_cli/internal/spec"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// LoadPlugins discovers the plugins in dir. A generator plugin cannot provide one of the
+// built-in formats.
+func LoadPlugins(ctx context.Context, dir string) ([]*plugin.Plugin, error) {
+	plugins, err := plugin.Discover(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range plugins {
+		for _, format := range GetAvailableGenerators() {
+			if p.Kind == plugin.KindGenerator && p.Format == format {
+				return nil, fmt.Errorf("plugin %s cannot replace the built-in %s format", p.Name, format)
+			}
+		}
+	}
+	return plugins, nil
+}
+
+// PluginStage creates a pipeline stage that runs a stage plugin on the infrastructure model.
+// Its findings are written to the progress output, and a finding with the error severity
+// fails the stage. The model the plugin responds with replaces the input; without one, the
+// input is passed on unchanged. The stage is retryable after the plugin's transient failures.
+func PluginStage(p *plugin.Plugin, params *ProcessingParams) Stage {
+	return NewRetryableStage(NewBaseStage("Plugin:"+p.Name, func(ctx context.Context, input interface{}) (interface{}, error) {
+		model, ok := input.(*models.InfrastructureModel)
+		if !ok {
+			return nil, fmt.Errorf("invalid input type for plugin %s: %T", p.Name, input)
+		}
+
+		response, err := p.RunStage(ctx, pluginRequest(model, params))
+		if err != nil {
+			return nil, err
+		}
+
+		var failures []string
+		for _, finding := range response.Findings {
+			message := finding.Message
+			if finding.Resource != "" {
+				message = fmt.Sprintf("%s: %s", finding.Resource, finding.Message)
+			}
+			if params.ProgressWriter != nil {
+				fmt.Fprintf(params.ProgressWriter, "%s [%s] %s\n", p.Name, finding.Severity, message)
+			}
+			if finding.Severity == plugin.SeverityWarning && params.Events != nil {
+				params.Events.Emit(Event{Type: EventWarning, Stage: "Plugin:" + p.Name, Environment: params.Environment, Message: message})
+			}
+			if finding.Severity == plugin.SeverityError {
+				failures = append(failures, message)
+			}
+		}
+		if len(failures) > 0 {
+			return nil, fmt.Errorf("plugin %s reported %d error(s): %s", p.Name, len(failures), strings.Join(failures, "; "))
+		}
+
+		if response.Model == nil {
+			return model, nil
+		}
+		// Values decoded from JSON are restored to the types the generators expect
+		spec.NormalizeModel(response.Model)
+		return response.Model, nil
+	}), nil)
+}
+
+// addPluginStages adds a stage for each stage plugin to a pipeline, in discovery order, and
+// returns how many were added
+func addPluginStages(p Pipeline, params *ProcessingParams) int {
+	stages := plugin.Stages(params.Plugins)
+	for _, stagePlugin := range stages {
+		p.AddStage(PluginStage(stagePlugin, params))
+	}
+	return len(stages)
+}
+
+// generatePluginFiles renders a model into dir with a generator plugin and returns a summary
+func generatePluginFiles(ctx context.Context, p *plugin.Plugin, model *models.InfrastructureModel, params *ProcessingParams, dir string) (string, error) {
+	files, err := p.Generate(ctx, pluginRequest(model, params))
+	if err != nil {
+		return "", err
+	}
+	for _, file := range sortedFilePaths(files) {
+		if err := utils.WriteToFile(filepath.Join(dir, filepath.FromSlash(file)), files[file]); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", file, err)
+		}
+	}
+	return fmt.Sprintf("Generated %d files with plugin %s in %s", len(files), p.Name, dir), nil
+}
+
+// pluginRequest builds the request a plugin is sent for a model
+func pluginRequest(model *models.InfrastructureModel, params *ProcessingParams) plugin.Request {
+	region := model.Region
+	if region == "" {
+		region = params.Region
+	}
+	environment := model.Environment
+	if environment == "" {
+		environment = params.Environment
+	}
+	return plugin.Request{
+		Model:       model,
+		Format:      strings.ToLower(params.OutputFormat),
+		Region:      region,
+		Environment: environment,
+		Tags:        params.Tags,
+	}
+}
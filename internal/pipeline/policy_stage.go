@@ -0,0 +1,37 @@
+package pipeline
+
+import (
+	"context"
+
+	"github.com/riptano/iac_generator_cli/internal/policy"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// policyCheckedStage wraps a stage building a model, evaluating the Rego policies of
+// params.Policies over the model it builds. The messages of their warn rules are reported as
+// warnings, and those of their deny rules fail the stage, before any file is generated.
+func policyCheckedStage(stage Stage, params *ProcessingParams) Stage {
+	return NewBaseStage(stage.Name(), func(ctx context.Context, input interface{}) (interface{}, error) {
+		output, err := stage.Execute(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		model, ok := output.(*models.InfrastructureModel)
+		if !ok {
+			return output, nil
+		}
+
+		engine, err := policy.Load(ctx, params.Policies)
+		if err != nil {
+			return nil, err
+		}
+		warnings, err := engine.Check(ctx, model)
+		for _, warning := range warnings {
+			warn(params, "policy "+warning.String())
+		}
+		if err != nil {
+			return nil, err
+		}
+		return output, nil
+	})
+}
@@ -0,0 +1,50 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/riptano/iac_generator_cli/internal/quota"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// quotaCheckedStage wraps a stage building a model, checking the model against the AWS
+// service quotas of each of its regions. The quotas it would exceed are reported as warnings,
+// and so is a check that cannot be made, such as without AWS credentials: the pre-flight never
+// fails the stage.
+func quotaCheckedStage(stage Stage, params *ProcessingParams) Stage {
+	newChecker := params.QuotaChecker
+	if newChecker == nil {
+		newChecker = quota.NewAWSChecker
+	}
+	return NewBaseStage(stage.Name(), func(ctx context.Context, input interface{}) (interface{}, error) {
+		output, err := stage.Execute(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		model, ok := output.(*models.InfrastructureModel)
+		if !ok {
+			return output, nil
+		}
+
+		regions := append([]string{model.Region}, model.SecondaryRegions()...)
+		if regions[0] == "" {
+			regions[0] = params.Region
+		}
+		for _, region := range regions {
+			checker, err := newChecker(ctx, region)
+			var warnings []quota.Warning
+			if err == nil {
+				warnings, err = checker.Check(ctx, model)
+			}
+			if err != nil {
+				warn(params, fmt.Sprintf("the service quotas of %s were not checked: %v", region, err))
+				continue
+			}
+			for _, warning := range warnings {
+				warn(params, "quota "+warning.String())
+			}
+		}
+		return output, nil
+	})
+}
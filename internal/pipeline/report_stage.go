@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// ComplianceReportFile is the name of the compliance matrix written to the output directory
+const ComplianceReportFile = "compliance-report.md"
+
+// ComplianceReportStage creates a pipeline stage that writes a compliance matrix for the
+// infrastructure model to the output directory. The model is passed through unchanged.
+func ComplianceReportStage(outputDir string) Stage {
+	return NewBaseStage("ComplianceReport", func(ctx context.Context, input interface{}) (interface{}, error) {
+		model, ok := input.(*models.InfrastructureModel)
+		if !ok {
+			return nil, fmt.Errorf("invalid input type for compliance report: %T", input)
+		}
+
+		// Check if the context is canceled
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		complianceReport := report.GenerateComplianceReport(model, nil)
+
+		if err := utils.EnsureDirectoryExists(outputDir); err != nil {
+			return nil, fmt.Errorf("failed to create directory %s: %w", outputDir, err)
+		}
+
+		reportPath := filepath.Join(outputDir, ComplianceReportFile)
+		if err := utils.WriteToFile(reportPath, complianceReport.Markdown()); err != nil {
+			return nil, fmt.Errorf("failed to write compliance report: %w", err)
+		}
+
+		satisfied, gaps, notApplicable := complianceReport.Counts()
+		utils.GetLogger().Infow("Compliance report written",
+			"path", reportPath,
+			"satisfied", satisfied,
+			"gaps", gaps,
+			"not_applicable", notApplicable,
+		)
+
+		return model, nil
+	})
+}
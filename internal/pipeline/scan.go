@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// runScanned runs the pipeline and, with Scan, scans the files of the output directory for
+// security misconfigurations, appending the summary to the result, and with Checkov checks
+// them with checkov. A staged run scans its staging directory, so a run failing on
+// ScanFailOn leaves the output directory as it was.
+func (c *PipelineCoordinatorImpl) runScanned(ctx context.Context, params *ProcessingParams) (string, error) {
+	result, err := c.runPipeline(ctx, params)
+	if err != nil {
+		return "", err
+	}
+	if params.Scan {
+		if result, err = scanOutput(params, result); err != nil {
+			return "", err
+		}
+	}
+	if params.Checkov {
+		policies, err := report.RunCheckov(params.OutputDir, report.CheckovOptions{Suppressions: params.CheckovSuppressions})
+		if err != nil {
+			return "", fmt.Errorf("failed to check the generated files: %w", err)
+		}
+		utils.GetLogger().Infow("Generated files checked with checkov",
+			"passed", policies.Passed,
+			"failed", len(policies.Failed()),
+			"suppressed", len(policies.Suppressed()),
+		)
+		result += "\n\nPolicy checks:\n" + policies.Text()
+	}
+	return result, nil
+}
+
+// scanOutput scans the files of the output directory with trivy or tfsec, and appends the
+// summary to the result of the run
+func scanOutput(params *ProcessingParams, result string) (string, error) {
+
+	scan, err := report.ScanDirectory(params.OutputDir, report.ScanOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to scan the generated files: %w", err)
+	}
+	counts := scan.Counts()
+	utils.GetLogger().Infow("Generated files scanned",
+		"scanner", scan.Scanner,
+		"critical", counts["CRITICAL"],
+		"high", counts["HIGH"],
+	)
+
+	summary := scan.Summary()
+	if params.ScanFailOn != "" {
+		if failed := scan.AtOrAbove(params.ScanFailOn); len(failed) > 0 {
+			return "", fmt.Errorf("the security scan found %d findings of %s severity or above:\n%s", len(failed), strings.ToLower(params.ScanFailOn), summary)
+		}
+	}
+	return result + "\n\nSecurity scan:\n" + summary, nil
+}
@@ -0,0 +1,239 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"go.uber.org/zap"
+)
+
+// DefaultWorkers is the number of tasks a scheduler runs at once when no worker count is
+// configured: one per CPU
+func DefaultWorkers() int {
+	return runtime.NumCPU()
+}
+
+// task is a unit of work of a Scheduler
+type task struct {
+	name      string
+	run       func(ctx context.Context) error
+	dependsOn []string
+}
+
+// Scheduler runs a graph of tasks, starting each as soon as the tasks it depends on have
+// completed, with at most a fixed number running at once
+type Scheduler struct {
+	workers int
+	tasks   []*task
+	byName  map[string]*task
+	logger  *zap.SugaredLogger
+}
+
+// NewScheduler creates a scheduler running up to workers tasks at once; a worker count below
+// one means DefaultWorkers
+func NewScheduler(workers int) *Scheduler {
+	if workers < 1 {
+		workers = DefaultWorkers()
+	}
+	return &Scheduler{
+		workers: workers,
+		byName:  make(map[string]*task),
+		logger:  utils.GetLogger(),
+	}
+}
+
+// Add adds a task that runs once the named tasks have completed. The tasks it depends on
+// may be added later, but must be added before Run.
+func (s *Scheduler) Add(name string, run func(ctx context.Context) error, dependsOn ...string) error {
+	if _, exists := s.byName[name]; exists {
+		return fmt.Errorf("task %s is already scheduled", name)
+	}
+	t := &task{name: name, run: run, dependsOn: dependsOn}
+	s.tasks = append(s.tasks, t)
+	s.byName[name] = t
+	return nil
+}
+
+// Run runs every task and waits for them. When a task fails, the context of the running
+// tasks is canceled, no further task is started, and the error of the first failure is
+// returned as the task returned it.
+func (s *Scheduler) Run(ctx context.Context) error {
+	dependents, pending, err := s.graph()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		task *task
+		err  error
+	}
+	results := make(chan result)
+	var ready []*task
+	for _, t := range s.tasks {
+		if pending[t.name] == 0 {
+			ready = append(ready, t)
+		}
+	}
+
+	s.logger.Debugw("Running scheduled tasks", "tasks", len(s.tasks), "workers", s.workers)
+
+	running, completed := 0, 0
+	var firstErr error
+	for completed < len(s.tasks) {
+		// Start the tasks that are ready, up to the worker count, unless one has failed
+		for firstErr == nil && running < s.workers && len(ready) > 0 {
+			t := ready[0]
+			ready = ready[1:]
+			running++
+			go func() {
+				results <- result{task: t, err: t.run(ctx)}
+			}()
+		}
+		if running == 0 {
+			break
+		}
+
+		res := <-results
+		running--
+		completed++
+		if res.err != nil {
+			if firstErr == nil {
+				firstErr = res.err
+				cancel()
+				s.logger.Debugw("Scheduled task failed", "task", res.task.name, "error", res.err)
+			}
+			continue
+		}
+		for _, dependent := range dependents[res.task.name] {
+			pending[dependent.name]--
+			if pending[dependent.name] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	// Wait for the tasks still running after a failure
+	for ; running > 0; running-- {
+		<-results
+	}
+	return firstErr
+}
+
+// graph returns the tasks depending on each task and the number of tasks each depends on,
+// and checks that every dependency exists and that the tasks do not depend on each other in
+// a cycle
+func (s *Scheduler) graph() (map[string][]*task, map[string]int, error) {
+	dependents := make(map[string][]*task)
+	pending := make(map[string]int)
+	for _, t := range s.tasks {
+		for _, name := range t.dependsOn {
+			if _, exists := s.byName[name]; !exists {
+				return nil, nil, fmt.Errorf("task %s depends on unknown task %s", t.name, name)
+			}
+			dependents[name] = append(dependents[name], t)
+			pending[t.name]++
+		}
+	}
+
+	// Remove the tasks without dependencies until none are left; those that remain are in a cycle
+	remaining := make(map[string]int, len(pending))
+	for name, count := range pending {
+		remaining[name] = count
+	}
+	var queue []string
+	for _, t := range s.tasks {
+		if remaining[t.name] == 0 {
+			queue = append(queue, t.name)
+		}
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, dependent := range dependents[name] {
+			remaining[dependent.name]--
+			if remaining[dependent.name] == 0 {
+				queue = append(queue, dependent.name)
+			}
+		}
+	}
+	var cycle []string
+	for name, count := range remaining {
+		if count > 0 {
+			cycle = append(cycle, name)
+		}
+	}
+	if len(cycle) > 0 {
+		sort.Strings(cycle)
+		return nil, nil, fmt.Errorf("tasks %s depend on each other in a cycle", strings.Join(cycle, ", "))
+	}
+	return dependents, pending, nil
+}
+
+// ParallelStage creates a pipeline stage that runs stages concurrently on the same input, with
+// up to workers running at once. Every stage but the last must pass its input through
+// unchanged and must not modify it, as with the compliance report; the result is the last
+// stage's, so the stage behaves as the stages run in order. It is retryable when one of the
+// stages is, after the failures they accept, and then runs them all again.
+func ParallelStage(name string, workers int, stages ...Stage) Stage {
+	parallel := NewBaseStage(name, func(ctx context.Context, input interface{}) (interface{}, error) {
+		var result interface{}
+		scheduler := NewScheduler(workers)
+		for i, stage := range stages {
+			stage, last := stage, i == len(stages)-1
+			err := scheduler.Add(stage.Name(), func(ctx context.Context) error {
+				output, err := stage.Execute(ctx, input)
+				if err != nil && stage.Name() != name {
+					return fmt.Errorf("stage %s failed: %w", stage.Name(), err)
+				}
+				if err != nil {
+					return err
+				}
+				if last {
+					result = output
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := scheduler.Run(ctx); err != nil {
+			return nil, err
+		}
+		return result, nil
+	})
+
+	var retryable []RetryableStage
+	for _, stage := range stages {
+		if stage, ok := stage.(RetryableStage); ok {
+			retryable = append(retryable, stage)
+		}
+	}
+	if len(retryable) == 0 {
+		return parallel
+	}
+	return NewRetryableStage(parallel, func(err error) bool {
+		for _, stage := range retryable {
+			if stage.Retryable(err) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// generationStage returns the stage generating the manifests, run alongside the compliance
+// report when one was requested, as the report only reads the model
+func generationStage(params *ProcessingParams, outputDir string, generation Stage) Stage {
+	if !params.ComplianceReport {
+		return generation
+	}
+	return ParallelStage(generation.Name(), params.Workers, ComplianceReportStage(outputDir), generation)
+}
@@ -0,0 +1,31 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/riptano/iac_generator_cli/internal/spec"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// SpecLoadingStage creates a pipeline stage that loads the infrastructure model from a
+// structured spec file. It replaces the NLP stage, so the stage input is ignored.
+func SpecLoadingStage(specFile string) Stage {
+	return NewBaseStage("SpecLoading", func(ctx context.Context, input interface{}) (interface{}, error) {
+		// Check if the context is canceled
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		model, err := spec.LoadFile(specFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load spec: %w", err)
+		}
+
+		utils.GetLogger().Debugw("Spec loaded",
+			"file", specFile,
+			"resources_count", len(model.Resources),
+		)
+		return model, nil
+	})
+}
@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stagingPrefix starts the name of the staging directories, which are hidden in the output
+// directory
+const stagingPrefix = ".iacgen-staging-"
+
+// runStaged runs the pipeline into a staging directory in the output directory, seeded with
+// a copy of its files, and moves the files the pipeline wrote into the output directory once
+// it succeeded. The staging directory is removed when the pipeline fails or is cancelled, so
+// the output directory is left as it was. The working directory, the default output
+// directory, is staged like any other. With KeepPartial, the pipeline writes to the output
+// directory directly.
+func (c *PipelineCoordinatorImpl) runStaged(ctx context.Context, params *ProcessingParams) (string, error) {
+	if params.KeepPartial {
+		return c.runScanned(ctx, params)
+	}
+
+	if err := os.MkdirAll(params.OutputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	staging, err := os.MkdirTemp(params.OutputDir, stagingPrefix)
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	// The generators join their paths cleaned, as in .iacgen-staging-123/main.tf
+	staging = filepath.Clean(staging)
+	defer os.RemoveAll(staging)
+
+	// The generators keep some files that already exist, such as .gitignore, and add to
+	// others, so they generate over a copy of the output directory
+	seeded, err := seedDirectory(params.OutputDir, staging, true)
+	if err != nil {
+		return "", err
+	}
+
+	stagedParams := *params
+	stagedParams.OutputDir = staging
+	c.logger.Debugw("Generating into staging directory", "dir", staging, "output_dir", params.OutputDir)
+	result, err := c.runScanned(ctx, &stagedParams)
+	if err != nil {
+		c.logger.Infow("Removing the files of the failed run", "dir", staging)
+		return "", err
+	}
+	// A run cancelled once its last stage started may still have completed
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("pipeline execution canceled: %w", err)
+	}
+
+	if err := removeUntouched(staging, seeded); err != nil {
+		return "", err
+	}
+	if err := commitStaged(staging, params.OutputDir, params.Events); err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(result, staging, params.OutputDir), nil
+}
+
+// commitStaged moves the files of the staging directory into the output directory, each
+// replacing the file at its path, and reports each as written to events (which may be nil)
+func commitStaged(staging, outputDir string, events EventSink) error {
+	return filepath.WalkDir(staging, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(staging, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(outputDir, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(target), err)
+		}
+		if err := os.Rename(path, target); err != nil {
+			return fmt.Errorf("failed to move %s into the output directory: %w", rel, err)
+		}
+		if events != nil {
+			events.Emit(Event{Type: EventFileWritten, Path: target})
+		}
+		return nil
+	})
+}
+
+// inStagingDirectory reports whether a path is in a staging directory
+func inStagingDirectory(path string) bool {
+	for _, element := range strings.Split(filepath.ToSlash(path), "/") {
+		if strings.HasPrefix(element, stagingPrefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,302 @@
+// Package plugin runs executables from a plugin directory that add output formats and
+// pipeline stages, with a JSON request on stdin and a JSON response on stdout
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/riptano/iac_generator_cli/internal/retry"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// ProtocolVersion is the version of the plugin protocol this build speaks
+const ProtocolVersion = 1
+
+// Kinds of plugins
+const (
+	// KindGenerator plugins generate the files of an output format from the model
+	KindGenerator = "generator"
+	// KindStage plugins check or change the model before it is generated, as a policy check does
+	KindStage = "stage"
+)
+
+// Commands a plugin is run with, as its only argument. describe takes no request and
+// responds with a Manifest; generate and stage take a Request and respond with a Response.
+const (
+	CommandDescribe = "describe"
+	CommandGenerate = "generate"
+	CommandStage    = "stage"
+)
+
+// Severities of stage findings
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// ExitTemporaryFailure is the exit status (EX_TEMPFAIL) with which a plugin reports a
+// transient failure, such as a network error, so that the stage running it may be retried
+const ExitTemporaryFailure = 75
+
+// describeTimeout bounds how long a plugin may take to describe itself
+const describeTimeout = 10 * time.Second
+
+// Manifest is a plugin's description of itself, the response to describe
+type Manifest struct {
+	ProtocolVersion int    `json:"protocol_version"`
+	Name            string `json:"name"`
+	Kind            string `json:"kind"`
+	// Format is the output format a generator plugin provides, selected with --output
+	Format      string `json:"format,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Request is what generate and stage are sent
+type Request struct {
+	ProtocolVersion int                         `json:"protocol_version"`
+	Model           *models.InfrastructureModel `json:"model"`
+	// Format is the requested output format
+	Format      string            `json:"format"`
+	Region      string            `json:"region"`
+	Environment string            `json:"environment,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+// Finding is an observation of a stage plugin about the model. A finding with the error
+// severity fails the run.
+type Finding struct {
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	// Resource names the resource the finding is about, if any
+	Resource string `json:"resource,omitempty"`
+}
+
+// Response is what generate and stage respond with
+type Response struct {
+	// Files are the generated files, keyed by their path relative to the output directory
+	// with forward slashes (generate)
+	Files map[string]string `json:"files,omitempty"`
+	// Model replaces the model given to the stage; without it the model is passed on
+	// unchanged (stage)
+	Model *models.InfrastructureModel `json:"model,omitempty"`
+	// Findings are reported on the progress output (stage)
+	Findings []Finding `json:"findings,omitempty"`
+	// Error fails the command with a message
+	Error string `json:"error,omitempty"`
+}
+
+// Plugin is a plugin discovered in the plugin directory
+type Plugin struct {
+	Manifest
+	// Path is the plugin's executable
+	Path string
+}
+
+// Discover describes every executable in dir, in name order, and returns the plugins. Hidden
+// files and files that are not executable are skipped. A plugin that cannot describe itself,
+// speaks another protocol version or reuses another plugin's name or format is an error, so
+// a broken plugin is not silently left out.
+func Discover(ctx context.Context, dir string) ([]*Plugin, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	var plugins []*Plugin
+	names := make(map[string]string)
+	formats := make(map[string]string)
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+
+		p, err := Describe(ctx, filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if other, exists := names[p.Name]; exists {
+			return nil, fmt.Errorf("plugins %s and %s are both named %s", other, entry.Name(), p.Name)
+		}
+		names[p.Name] = entry.Name()
+		if p.Kind == KindGenerator {
+			if other, exists := formats[p.Format]; exists {
+				return nil, fmt.Errorf("plugins %s and %s both generate the %s format", other, entry.Name(), p.Format)
+			}
+			formats[p.Format] = entry.Name()
+		}
+		plugins = append(plugins, p)
+	}
+
+	utils.GetLogger().Debugw("Discovered plugins", "dir", dir, "count", len(plugins))
+	return plugins, nil
+}
+
+// Describe runs a plugin's describe command and checks its manifest
+func Describe(ctx context.Context, executable string) (*Plugin, error) {
+	ctx, cancel := context.WithTimeout(ctx, describeTimeout)
+	defer cancel()
+
+	var manifest Manifest
+	if err := run(ctx, executable, CommandDescribe, nil, &manifest); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", filepath.Base(executable), err)
+	}
+
+	switch {
+	case manifest.ProtocolVersion != ProtocolVersion:
+		return nil, fmt.Errorf("plugin %s speaks protocol version %d; this version of iacgen speaks %d",
+			filepath.Base(executable), manifest.ProtocolVersion, ProtocolVersion)
+	case manifest.Name == "":
+		return nil, fmt.Errorf("plugin %s has no name", filepath.Base(executable))
+	case manifest.Kind == KindGenerator && manifest.Format == "":
+		return nil, fmt.Errorf("generator plugin %s has no format", manifest.Name)
+	case manifest.Kind != KindGenerator && manifest.Kind != KindStage:
+		return nil, fmt.Errorf("plugin %s has an unknown kind %q (use %s or %s)", manifest.Name, manifest.Kind, KindGenerator, KindStage)
+	}
+	manifest.Format = strings.ToLower(manifest.Format)
+
+	return &Plugin{Manifest: manifest, Path: executable}, nil
+}
+
+// Generate runs a generator plugin and returns the files it generated. Paths that are
+// absolute or leave the output directory are rejected.
+func (p *Plugin) Generate(ctx context.Context, request Request) (map[string]string, error) {
+	if p.Kind != KindGenerator {
+		return nil, fmt.Errorf("plugin %s is not a generator", p.Name)
+	}
+	response, err := p.call(ctx, CommandGenerate, request)
+	if err != nil {
+		return nil, err
+	}
+	for file := range response.Files {
+		clean := path.Clean(file)
+		if path.IsAbs(file) || filepath.IsAbs(file) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, fmt.Errorf("plugin %s generated a file outside the output directory: %s", p.Name, file)
+		}
+	}
+	return response.Files, nil
+}
+
+// RunStage runs a stage plugin and returns its response
+func (p *Plugin) RunStage(ctx context.Context, request Request) (*Response, error) {
+	if p.Kind != KindStage {
+		return nil, fmt.Errorf("plugin %s is not a stage", p.Name)
+	}
+	response, err := p.call(ctx, CommandStage, request)
+	if err != nil {
+		return nil, err
+	}
+	for _, finding := range response.Findings {
+		switch finding.Severity {
+		case SeverityError, SeverityWarning, SeverityInfo:
+		default:
+			return nil, fmt.Errorf("plugin %s reported a finding with an unknown severity %q", p.Name, finding.Severity)
+		}
+	}
+	return response, nil
+}
+
+// call runs a command of the plugin with a request and decodes its response
+func (p *Plugin) call(ctx context.Context, command string, request Request) (*Response, error) {
+	request.ProtocolVersion = ProtocolVersion
+	var response Response
+	if err := run(ctx, p.Path, command, request, &response); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", p.Name, err)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("plugin %s: %s", p.Name, response.Error)
+	}
+	return &response, nil
+}
+
+// run runs an executable with a command, writing the request to its stdin as JSON and
+// decoding its stdout into response
+func run(ctx context.Context, executable, command string, request interface{}, response interface{}) error {
+	var stdin []byte
+	if request != nil {
+		var err error
+		if stdin, err = json.Marshal(request); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, executable, command)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	logger := utils.GetLogger()
+	logger.Debugw("Running plugin", "path", executable, "command", command)
+	err := cmd.Run()
+	if stderr.Len() > 0 {
+		logger.Debugw("Plugin output", "path", executable, "command", command, "stderr", stderr.String())
+	}
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			err = fmt.Errorf("%s failed: %w: %s", command, err, message)
+		} else {
+			err = fmt.Errorf("%s failed: %w", command, err)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == ExitTemporaryFailure {
+			return retry.Transient(err)
+		}
+		return err
+	}
+
+	if err := json.Unmarshal(stdout.Bytes(), response); err != nil {
+		return fmt.Errorf("invalid %s response: %w", command, err)
+	}
+	return nil
+}
+
+// Generator returns the generator plugin providing a format, or nil if none does
+func Generator(plugins []*Plugin, format string) *Plugin {
+	format = strings.ToLower(format)
+	for _, p := range plugins {
+		if p.Kind == KindGenerator && p.Format == format {
+			return p
+		}
+	}
+	return nil
+}
+
+// Stages returns the stage plugins, in the order they run
+func Stages(plugins []*Plugin) []*Plugin {
+	var stages []*Plugin
+	for _, p := range plugins {
+		if p.Kind == KindStage {
+			stages = append(stages, p)
+		}
+	}
+	return stages
+}
+
+// Formats returns the output formats the generator plugins provide, sorted
+func Formats(plugins []*Plugin) []string {
+	var formats []string
+	for _, p := range plugins {
+		if p.Kind == KindGenerator {
+			formats = append(formats, p.Format)
+		}
+	}
+	sort.Strings(formats)
+	return formats
+}
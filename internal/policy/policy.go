@@ -0,0 +1,210 @@
+// Package policy evaluates Rego policies over infrastructure models with an embedded OPA, so
+// organizations can enforce rules such as "no public EKS endpoints in prod" before any file
+// is generated.
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// Package is the package the policies are written under, such as iacgen.eks; the deny and
+// warn rules of it and of its subpackages are evaluated
+const Package = "iacgen"
+
+// Rules evaluated in each policy package
+const (
+	RuleDeny = "deny"
+	RuleWarn = "warn"
+)
+
+// Violation is a message of a deny or warn rule of a policy
+type Violation struct {
+	// Package is the package of the rule, such as iacgen.eks
+	Package string `json:"package"`
+	// Rule is deny or warn
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	// Resource is the name of the resource the message is about, when the rule names one
+	Resource string `json:"resource,omitempty"`
+}
+
+// String formats the violation with its package and resource
+func (v Violation) String() string {
+	if v.Resource != "" {
+		return fmt.Sprintf("[%s] %s: %s", v.Package, v.Resource, v.Message)
+	}
+	return fmt.Sprintf("[%s] %s", v.Package, v.Message)
+}
+
+// Violations are the denials of the policies a model violates
+type Violations []Violation
+
+// Error lists the denials, one per line
+func (v Violations) Error() string {
+	lines := []string{fmt.Sprintf("the model violates %d policies:", len(v))}
+	for _, violation := range v {
+		lines = append(lines, "  - "+violation.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Result is the outcome of evaluating the policies over a model
+type Result struct {
+	Denials  Violations
+	Warnings []Violation
+}
+
+// Engine evaluates the policies of a set of Rego files over models
+type Engine struct {
+	query rego.PreparedEvalQuery
+}
+
+// Load compiles the Rego files of paths, files or directories, which may also hold JSON
+// and YAML data the policies read under data
+func Load(ctx context.Context, paths []string) (*Engine, error) {
+	query, err := rego.New(
+		rego.Query("data."+Package),
+		rego.Load(paths, nil),
+	).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policies: %w", err)
+	}
+	return &Engine{query: query}, nil
+}
+
+// Input returns the input the policies see for a model: its region, environment and tags,
+// and its resources with their properties as an object keyed by property name
+func Input(model *models.InfrastructureModel) map[string]interface{} {
+	resources := make([]interface{}, 0, len(model.Resources))
+	for _, resource := range model.Resources {
+		properties := make(map[string]interface{}, len(resource.Properties))
+		for _, property := range resource.Properties {
+			properties[property.Name] = property.Value
+		}
+		dependsOn := make([]interface{}, 0, len(resource.DependsOn))
+		for _, dependency := range resource.DependsOn {
+			dependsOn = append(dependsOn, dependency)
+		}
+		resources = append(resources, map[string]interface{}{
+			"type":       string(resource.Type),
+			"name":       resource.Name,
+			"properties": properties,
+			"depends_on": dependsOn,
+		})
+	}
+	tags := make(map[string]interface{}, len(model.Tags))
+	for key, value := range model.Tags {
+		tags[key] = value
+	}
+	return map[string]interface{}{
+		"region":      model.Region,
+		"environment": model.Environment,
+		"tags":        tags,
+		"resources":   resources,
+	}
+}
+
+// Evaluate evaluates the policies over a model, and returns the messages of their deny and
+// warn rules, sorted by package
+func (e *Engine) Evaluate(ctx context.Context, model *models.InfrastructureModel) (*Result, error) {
+	results, err := e.query.Eval(ctx, rego.EvalInput(Input(model)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate policies: %w", err)
+	}
+
+	result := &Result{}
+	for _, set := range results {
+		for _, expression := range set.Expressions {
+			if err := collect(result, Package, expression.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, violations := range [][]Violation{result.Denials, result.Warnings} {
+		sort.SliceStable(violations, func(i, j int) bool {
+			if violations[i].Package != violations[j].Package {
+				return violations[i].Package < violations[j].Package
+			}
+			return violations[i].Message < violations[j].Message
+		})
+	}
+	return result, nil
+}
+
+// Check evaluates the policies over a model, and returns the warnings, failing with the
+// denials as Violations
+func (e *Engine) Check(ctx context.Context, model *models.InfrastructureModel) ([]Violation, error) {
+	result, err := e.Evaluate(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Denials) > 0 {
+		return result.Warnings, result.Denials
+	}
+	return result.Warnings, nil
+}
+
+// collect adds the messages of the deny and warn rules of a package document, and of its
+// subpackages, to result
+func collect(result *Result, pkg string, document interface{}) error {
+	values, ok := document.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := values[key]
+		if key != RuleDeny && key != RuleWarn {
+			if err := collect(result, pkg+"."+key, value); err != nil {
+				return err
+			}
+			continue
+		}
+		messages, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s.%s must be a set of messages", pkg, key)
+		}
+		for _, message := range messages {
+			violation, err := toViolation(pkg, key, message)
+			if err != nil {
+				return err
+			}
+			if key == RuleDeny {
+				result.Denials = append(result.Denials, violation)
+			} else {
+				result.Warnings = append(result.Warnings, violation)
+			}
+		}
+	}
+	return nil
+}
+
+// toViolation converts a message of a rule, a string or an object with msg and an optional
+// resource, to a violation
+func toViolation(pkg, rule string, message interface{}) (Violation, error) {
+	violation := Violation{Package: pkg, Rule: rule}
+	switch message := message.(type) {
+	case string:
+		violation.Message = message
+	case map[string]interface{}:
+		violation.Message, _ = message["msg"].(string)
+		violation.Resource, _ = message["resource"].(string)
+		if violation.Message == "" {
+			return violation, fmt.Errorf("a message of %s.%s has no msg", pkg, rule)
+		}
+	default:
+		return violation, fmt.Errorf("a message of %s.%s is neither a string nor an object with msg: %v", pkg, rule, message)
+	}
+	return violation, nil
+}
@@ -0,0 +1,315 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	sqtypes "github.com/aws/aws-sdk-go-v2/service/servicequotas/types"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// ServiceQuotasAPI is the part of the Service Quotas API the checks call: Get calls only
+type ServiceQuotasAPI interface {
+	GetServiceQuota(ctx context.Context, params *servicequotas.GetServiceQuotaInput, optFns ...func(*servicequotas.Options)) (*servicequotas.GetServiceQuotaOutput, error)
+	GetAWSDefaultServiceQuota(ctx context.Context, params *servicequotas.GetAWSDefaultServiceQuotaInput, optFns ...func(*servicequotas.Options)) (*servicequotas.GetAWSDefaultServiceQuotaOutput, error)
+}
+
+// EC2API is the part of the EC2 API the checks call to count what is in use: Describe calls
+// only
+type EC2API interface {
+	DescribeVpcs(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error)
+	DescribeInternetGateways(ctx context.Context, params *ec2.DescribeInternetGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInternetGatewaysOutput, error)
+	DescribeAddresses(ctx context.Context, params *ec2.DescribeAddressesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeAddressesOutput, error)
+	DescribeNatGateways(ctx context.Context, params *ec2.DescribeNatGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNatGatewaysOutput, error)
+	DescribeSubnets(ctx context.Context, params *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error)
+}
+
+// Quota is a Service Quotas quota the generated infrastructure counts against
+type Quota struct {
+	Name        string
+	ServiceCode string
+	QuotaCode   string
+}
+
+// The quotas that are checked
+var (
+	QuotaVPCs             = Quota{Name: "VPCs per Region", ServiceCode: "vpc", QuotaCode: "L-F678F1CE"}
+	QuotaInternetGateways = Quota{Name: "Internet gateways per Region", ServiceCode: "vpc", QuotaCode: "L-A4707A72"}
+	QuotaElasticIPs       = Quota{Name: "EC2-VPC Elastic IPs", ServiceCode: "ec2", QuotaCode: "L-0263D0A3"}
+	QuotaNATGateways      = Quota{Name: "NAT gateways per Availability Zone", ServiceCode: "vpc", QuotaCode: "L-FE5A380F"}
+)
+
+// Warning is a quota the requested infrastructure would exceed, in a region or, for NAT
+// gateways, in an availability zone
+type Warning struct {
+	Quota Quota
+	// Scope is the region or availability zone the quota applies to
+	Scope     string
+	Limit     int
+	InUse     int
+	Requested int
+}
+
+// String describes the quota, what is in use and what is requested
+func (w Warning) String() string {
+	return fmt.Sprintf("%s in %s: %d in use and %d requested exceed the quota of %d (%s %s); request an increase in the Service Quotas console",
+		w.Quota.Name, w.Scope, w.InUse, w.Requested, w.Limit, w.Quota.ServiceCode, w.Quota.QuotaCode)
+}
+
+// Checker compares the resources a model requests in a region with the quotas of the account
+// there, and with what the account already uses
+type Checker struct {
+	quotas ServiceQuotasAPI
+	ec2    EC2API
+	region string
+}
+
+// NewChecker creates a Checker calling the given clients, which are in a region
+func NewChecker(quotasClient ServiceQuotasAPI, ec2Client EC2API, region string) *Checker {
+	return &Checker{quotas: quotasClient, ec2: ec2Client, region: region}
+}
+
+// NewAWSChecker creates a Checker for a region, with the credentials of the default chain of
+// the AWS SDK: the environment, a profile of the shared configuration or the role of the
+// instance
+func NewAWSChecker(ctx context.Context, region string) (*Checker, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	return NewChecker(servicequotas.NewFromConfig(cfg), ec2.NewFromConfig(cfg), cfg.Region), nil
+}
+
+// Check returns the quotas the resources of the model in the checker's region would exceed:
+// VPCs and internet gateways per region, Elastic IPs, one for each public NAT gateway, and
+// NAT gateways per availability zone. Only the quotas the model requests something of are
+// queried.
+func (c *Checker) Check(ctx context.Context, model *models.InfrastructureModel) ([]Warning, error) {
+	requested := c.requested(model)
+	var warnings []Warning
+
+	for _, check := range []struct {
+		quota Quota
+		count func(ctx context.Context) (int, error)
+	}{
+		{QuotaVPCs, c.countVPCs},
+		{QuotaInternetGateways, c.countInternetGateways},
+		{QuotaElasticIPs, c.countElasticIPs},
+	} {
+		want := requested[check.quota.QuotaCode][c.region]
+		if want == 0 {
+			continue
+		}
+		limit, err := c.limit(ctx, check.quota)
+		if err != nil {
+			return nil, err
+		}
+		inUse, err := check.count(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if inUse+want > limit {
+			warnings = append(warnings, Warning{Quota: check.quota, Scope: c.region, Limit: limit, InUse: inUse, Requested: want})
+		}
+	}
+
+	zones := requested[QuotaNATGateways.QuotaCode]
+	if len(zones) > 0 {
+		limit, err := c.limit(ctx, QuotaNATGateways)
+		if err != nil {
+			return nil, err
+		}
+		inUse, err := c.countNATGateways(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for zone := range zones {
+			names = append(names, zone)
+		}
+		sort.Strings(names)
+		for _, zone := range names {
+			if inUse[zone]+zones[zone] > limit {
+				warnings = append(warnings, Warning{Quota: QuotaNATGateways, Scope: zone, Limit: limit, InUse: inUse[zone], Requested: zones[zone]})
+			}
+		}
+	}
+	return warnings, nil
+}
+
+// requested counts the resources of the model in the checker's region, by quota code and by
+// the region or availability zone the quota applies to
+func (c *Checker) requested(model *models.InfrastructureModel) map[string]map[string]int {
+	requested := make(map[string]map[string]int)
+	add := func(quota Quota, scope string) {
+		if requested[quota.QuotaCode] == nil {
+			requested[quota.QuotaCode] = make(map[string]int)
+		}
+		requested[quota.QuotaCode][scope]++
+	}
+
+	zones := make(map[string]string)
+	for _, resource := range model.Resources {
+		if resource.Type == models.ResourceSubnet {
+			zones[resource.Name] = stringProperty(resource, "availability_zone")
+		}
+	}
+	for i := range model.Resources {
+		resource := model.Resources[i]
+		if model.ResourceRegion(&resource) != c.region {
+			continue
+		}
+		switch resource.Type {
+		case models.ResourceVPC:
+			add(QuotaVPCs, c.region)
+		case models.ResourceIGW:
+			add(QuotaInternetGateways, c.region)
+		case models.ResourceNATGateway:
+			if stringProperty(resource, "connectivity_type") != "private" {
+				add(QuotaElasticIPs, c.region)
+			}
+			if zone := zones[stringProperty(resource, "subnet_id")]; zone != "" {
+				add(QuotaNATGateways, zone)
+			}
+		}
+	}
+	return requested
+}
+
+// limit returns the value of a quota for the account, or its AWS default when the account
+// has no value of its own
+func (c *Checker) limit(ctx context.Context, quota Quota) (int, error) {
+	output, err := c.quotas.GetServiceQuota(ctx, &servicequotas.GetServiceQuotaInput{
+		ServiceCode: aws.String(quota.ServiceCode),
+		QuotaCode:   aws.String(quota.QuotaCode),
+	})
+	var notFound *sqtypes.NoSuchResourceException
+	if errors.As(err, &notFound) {
+		var defaults *servicequotas.GetAWSDefaultServiceQuotaOutput
+		defaults, err = c.quotas.GetAWSDefaultServiceQuota(ctx, &servicequotas.GetAWSDefaultServiceQuotaInput{
+			ServiceCode: aws.String(quota.ServiceCode),
+			QuotaCode:   aws.String(quota.QuotaCode),
+		})
+		if err == nil {
+			return quotaValue(defaults.Quota), nil
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the quota %s (%s): %w", quota.Name, quota.QuotaCode, err)
+	}
+	return quotaValue(output.Quota), nil
+}
+
+// quotaValue returns the value of a quota as a count
+func quotaValue(quota *sqtypes.ServiceQuota) int {
+	if quota == nil || quota.Value == nil {
+		return 0
+	}
+	return int(*quota.Value)
+}
+
+// countVPCs counts the VPCs of the region
+func (c *Checker) countVPCs(ctx context.Context) (int, error) {
+	count := 0
+	pages := ec2.NewDescribeVpcsPaginator(c.ec2, &ec2.DescribeVpcsInput{})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to describe the VPCs of %s: %w", c.region, err)
+		}
+		count += len(page.Vpcs)
+	}
+	return count, nil
+}
+
+// countInternetGateways counts the internet gateways of the region
+func (c *Checker) countInternetGateways(ctx context.Context) (int, error) {
+	count := 0
+	pages := ec2.NewDescribeInternetGatewaysPaginator(c.ec2, &ec2.DescribeInternetGatewaysInput{})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to describe the internet gateways of %s: %w", c.region, err)
+		}
+		count += len(page.InternetGateways)
+	}
+	return count, nil
+}
+
+// countElasticIPs counts the Elastic IPs allocated in the region
+func (c *Checker) countElasticIPs(ctx context.Context) (int, error) {
+	output, err := c.ec2.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{
+		Filters: []ec2types.Filter{{Name: aws.String("domain"), Values: []string{"vpc"}}},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to describe the Elastic IPs of %s: %w", c.region, err)
+	}
+	return len(output.Addresses), nil
+}
+
+// countNATGateways counts the pending and available NAT gateways of the region, by the
+// availability zone of their subnet
+func (c *Checker) countNATGateways(ctx context.Context) (map[string]int, error) {
+	var subnetIDs []string
+	pages := ec2.NewDescribeNatGatewaysPaginator(c.ec2, &ec2.DescribeNatGatewaysInput{
+		Filter: []ec2types.Filter{{Name: aws.String("state"), Values: []string{"pending", "available"}}},
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe the NAT gateways of %s: %w", c.region, err)
+		}
+		for _, nat := range page.NatGateways {
+			subnetIDs = append(subnetIDs, aws.ToString(nat.SubnetId))
+		}
+	}
+	counts := make(map[string]int)
+	if len(subnetIDs) == 0 {
+		return counts, nil
+	}
+
+	zones := make(map[string]string)
+	subnetPages := ec2.NewDescribeSubnetsPaginator(c.ec2, &ec2.DescribeSubnetsInput{
+		Filters: []ec2types.Filter{{Name: aws.String("subnet-id"), Values: uniqueStrings(subnetIDs)}},
+	})
+	for subnetPages.HasMorePages() {
+		page, err := subnetPages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe the subnets of the NAT gateways of %s: %w", c.region, err)
+		}
+		for _, subnet := range page.Subnets {
+			zones[aws.ToString(subnet.SubnetId)] = aws.ToString(subnet.AvailabilityZone)
+		}
+	}
+	for _, id := range subnetIDs {
+		counts[zones[id]]++
+	}
+	return counts, nil
+}
+
+// uniqueStrings returns the strings without repeats, in the order they first appear
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// stringProperty returns a string property of a resource, or "" when it is not a string
+func stringProperty(resource models.Resource, name string) string {
+	value, _ := resource.GetProperty(name)
+	str, _ := value.(string)
+	return str
+}
@@ -0,0 +1,309 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// CheckovFrameworks are the checkov frameworks generated files are checked with: the
+// Terraform, and the Kubernetes manifests, which the Crossplane output is
+var CheckovFrameworks = []string{"terraform", "kubernetes"}
+
+// checkIDPattern matches the IDs of checkov checks, such as CKV_AWS_39 or CKV2_AWS_11
+var checkIDPattern = regexp.MustCompile(`^(CKV2?|BC)_[A-Z0-9]+_\d+$`)
+
+// CheckovSuppression suppresses the failures of a check, for every resource or for those
+// matching Resources
+type CheckovSuppression struct {
+	// Check is the ID of the check, such as CKV_AWS_39
+	Check string `mapstructure:"check" json:"check"`
+	// Resources are patterns of the resources the check is suppressed for, as checkov names
+	// them, such as aws_eks_cluster.* or module.eks.aws_eks_cluster.this; empty matches all
+	Resources []string `mapstructure:"resources" json:"resources,omitempty"`
+	// Reason says why the check does not apply, for the report
+	Reason string `mapstructure:"reason" json:"reason,omitempty"`
+}
+
+// matches reports whether the suppression covers a check of a resource
+func (s CheckovSuppression) matches(check, resource string) bool {
+	if !strings.EqualFold(s.Check, check) {
+		return false
+	}
+	if len(s.Resources) == 0 {
+		return true
+	}
+	for _, pattern := range s.Resources {
+		if matched, _ := path.Match(pattern, resource); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateCheckovSuppressions checks the check IDs and resource patterns of suppressions
+func ValidateCheckovSuppressions(suppressions []CheckovSuppression) error {
+	for _, suppression := range suppressions {
+		if !checkIDPattern.MatchString(suppression.Check) {
+			return fmt.Errorf("invalid checkov check: %q (use the ID of a check, such as CKV_AWS_39)", suppression.Check)
+		}
+		for _, pattern := range suppression.Resources {
+			if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+				return fmt.Errorf("invalid resource pattern of checkov check %s: %q", suppression.Check, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckovOptions controls how RunCheckov checks
+type CheckovOptions struct {
+	// Frameworks are the checkov frameworks to check with (default: CheckovFrameworks)
+	Frameworks []string
+	// Suppressions are the failed checks reported as suppressed instead
+	Suppressions []CheckovSuppression
+	// Path is the checkov binary (default: checkov from PATH)
+	Path string
+}
+
+// PolicyFinding is a failed checkov check of a resource of a generated file
+type PolicyFinding struct {
+	// Framework is the checkov framework of the file, such as terraform or kubernetes
+	Framework string `json:"framework"`
+	Check     string `json:"check"`
+	Name      string `json:"name"`
+	// File is relative to the checked directory
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	Resource  string `json:"resource,omitempty"`
+	Guideline string `json:"guideline,omitempty"`
+	// Suppressed findings match a suppression, whose reason is Reason
+	Suppressed bool   `json:"suppressed,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// PolicyReport is the consolidated report of the checkov checks of the Terraform and the
+// manifests of a generated directory
+type PolicyReport struct {
+	Dir        string          `json:"dir"`
+	Frameworks []string        `json:"frameworks"`
+	Passed     int             `json:"passed"`
+	Findings   []PolicyFinding `json:"findings"`
+}
+
+// checkovReport is the report of one framework of checkov -o json
+type checkovReport struct {
+	CheckType string `json:"check_type"`
+	Results   struct {
+		FailedChecks []struct {
+			CheckID       string `json:"check_id"`
+			CheckName     string `json:"check_name"`
+			FilePath      string `json:"file_path"`
+			FileLineRange []int  `json:"file_line_range"`
+			Resource      string `json:"resource"`
+			Guideline     string `json:"guideline"`
+		} `json:"failed_checks"`
+	} `json:"results"`
+	Summary struct {
+		Passed int `json:"passed"`
+	} `json:"summary"`
+}
+
+// FindCheckov returns the checkov binary of PATH
+func FindCheckov() (string, error) {
+	checkov, err := exec.LookPath("checkov")
+	if err != nil {
+		return "", fmt.Errorf("checkov not found in PATH; install it with pip install checkov")
+	}
+	return checkov, nil
+}
+
+// RunCheckov checks the Terraform and the manifests of a generated directory with checkov,
+// and returns the failed checks, those matching a suppression marked as suppressed
+func RunCheckov(dir string, options CheckovOptions) (*PolicyReport, error) {
+	checkov := options.Path
+	if checkov == "" {
+		var err error
+		if checkov, err = FindCheckov(); err != nil {
+			return nil, err
+		}
+	}
+	frameworks := options.Frameworks
+	if len(frameworks) == 0 {
+		frameworks = CheckovFrameworks
+	}
+
+	args := []string{"--directory", dir, "--output", "json", "--quiet", "--compact", "--skip-download", "--framework"}
+	args = append(args, frameworks...)
+	cmd := exec.Command(checkov, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	// checkov exits with an error when a check fails, but still prints the report
+	output, err := cmd.Output()
+	if len(bytes.TrimSpace(output)) == 0 {
+		if err == nil {
+			err = fmt.Errorf("no output")
+		}
+		return nil, fmt.Errorf("checkov failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	// The report is a list with one report per framework, or one report when a single
+	// framework found files; without files, it is only a summary
+	var reports []checkovReport
+	if bytes.HasPrefix(bytes.TrimSpace(output), []byte("[")) {
+		err = json.Unmarshal(output, &reports)
+	} else {
+		var single checkovReport
+		err = json.Unmarshal(output, &single)
+		if single.CheckType != "" {
+			reports = append(reports, single)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the checkov report: %w", err)
+	}
+
+	report := &PolicyReport{Dir: dir, Frameworks: []string{}}
+	for _, framework := range reports {
+		report.Frameworks = append(report.Frameworks, framework.CheckType)
+		report.Passed += framework.Summary.Passed
+		for _, check := range framework.Results.FailedChecks {
+			finding := PolicyFinding{
+				Framework: framework.CheckType,
+				Check:     check.CheckID,
+				Name:      check.CheckName,
+				File:      strings.TrimPrefix(check.FilePath, "/"),
+				Resource:  check.Resource,
+				Guideline: check.Guideline,
+			}
+			if len(check.FileLineRange) > 0 {
+				finding.Line = check.FileLineRange[0]
+			}
+			for _, suppression := range options.Suppressions {
+				if suppression.matches(check.CheckID, check.Resource) {
+					finding.Suppressed, finding.Reason = true, suppression.Reason
+					break
+				}
+			}
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return report, nil
+}
+
+// Failed returns the failed checks that are not suppressed
+func (r *PolicyReport) Failed() []PolicyFinding {
+	return r.filter(false)
+}
+
+// Suppressed returns the failed checks that are suppressed
+func (r *PolicyReport) Suppressed() []PolicyFinding {
+	return r.filter(true)
+}
+
+func (r *PolicyReport) filter(suppressed bool) []PolicyFinding {
+	var findings []PolicyFinding
+	for _, finding := range r.Findings {
+		if finding.Suppressed == suppressed {
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+// JSON returns the JSON representation of the report
+func (r *PolicyReport) JSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal policy report: %w", err)
+	}
+	return string(data), nil
+}
+
+// Text returns the failed checks of every framework as one table, the suppressed ones as
+// another with their reasons, and a summary line
+func (r *PolicyReport) Text() string {
+	var buf bytes.Buffer
+
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for _, finding := range r.Failed() {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", finding.Check, findingLocation(finding), finding.Framework, finding.Resource, finding.Name)
+	}
+	w.Flush()
+
+	if suppressed := r.Suppressed(); len(suppressed) > 0 {
+		buf.WriteString("Suppressed:\n")
+		w = tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		for _, finding := range suppressed {
+			reason := finding.Reason
+			if reason == "" {
+				reason = "no reason given"
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", finding.Check, findingLocation(finding), finding.Resource, reason)
+		}
+		w.Flush()
+	}
+
+	frameworks := "no files"
+	if len(r.Frameworks) > 0 {
+		frameworks = strings.Join(r.Frameworks, " and ")
+	}
+	buf.WriteString(fmt.Sprintf("Checked %s with checkov: %d passed, %d failed, %d suppressed\n",
+		frameworks, r.Passed, len(r.Failed()), len(r.Suppressed())))
+	return buf.String()
+}
+
+// findingLocation returns the file and line of a finding
+func findingLocation(finding PolicyFinding) string {
+	if finding.Line > 0 {
+		return finding.File + ":" + strconv.Itoa(finding.Line)
+	}
+	return finding.File
+}
+
+// checkPolicies checks the validated formats with checkov, reporting each failed check that
+// is not suppressed as an error
+func (r *ValidationReport) checkPolicies(options ValidationOptions) {
+	checkov := options.CheckovPath
+	if checkov == "" {
+		var err error
+		if checkov, err = FindCheckov(); err != nil {
+			r.add(SeverityWarning, r.Dir, 0, "checkov", "checkov not found in PATH; the policies were not checked")
+			return
+		}
+	}
+	var frameworks []string
+	for _, format := range r.Formats {
+		if format == FormatTerraform {
+			frameworks = append(frameworks, "terraform")
+		} else {
+			frameworks = append(frameworks, "kubernetes")
+		}
+	}
+
+	policies, err := RunCheckov(r.Dir, CheckovOptions{Frameworks: frameworks, Suppressions: options.CheckovSuppressions, Path: checkov})
+	if err != nil {
+		r.add(SeverityError, r.Dir, 0, "checkov", err.Error())
+		return
+	}
+	for _, finding := range policies.Failed() {
+		message := fmt.Sprintf("%s %s: %s", finding.Check, finding.Resource, finding.Name)
+		r.add(SeverityError, filepath.Join(r.Dir, finding.File), finding.Line, "checkov", message)
+	}
+}
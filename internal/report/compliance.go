@@ -0,0 +1,566 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// ControlStatus represents how well the generated infrastructure satisfies a control
+type ControlStatus string
+
+// Supported control statuses
+const (
+	StatusSatisfied     ControlStatus = "satisfied"
+	StatusGap           ControlStatus = "gap"
+	StatusNotApplicable ControlStatus = "not_applicable"
+)
+
+// Supported control frameworks
+const (
+	FrameworkCISAWS = "CIS AWS"
+	FrameworkSOC2   = "SOC 2"
+)
+
+// ControlResult is the outcome of evaluating a single control against a model
+type ControlResult struct {
+	Status    ControlStatus
+	Resources []string
+	Notes     string
+}
+
+// Control describes a compliance control and how to evaluate it
+type Control struct {
+	Framework   string
+	ID          string
+	Title       string
+	Remediation string
+	Check       func(model *models.InfrastructureModel) ControlResult
+}
+
+// ComplianceEntry is a row of the compliance matrix
+type ComplianceEntry struct {
+	Framework   string        `json:"framework"`
+	ControlID   string        `json:"control_id"`
+	Title       string        `json:"title"`
+	Status      ControlStatus `json:"status"`
+	Resources   []string      `json:"resources,omitempty"`
+	Notes       string        `json:"notes,omitempty"`
+	Remediation string        `json:"remediation,omitempty"`
+}
+
+// ComplianceReport is a compliance matrix for an infrastructure model
+type ComplianceReport struct {
+	Entries []ComplianceEntry `json:"entries"`
+}
+
+// GenerateComplianceReport evaluates the given controls against a model.
+// If no controls are given, DefaultControls is used.
+func GenerateComplianceReport(model *models.InfrastructureModel, controls []Control) *ComplianceReport {
+	if len(controls) == 0 {
+		controls = DefaultControls()
+	}
+
+	report := &ComplianceReport{}
+	for _, control := range controls {
+		result := control.Check(model)
+		entry := ComplianceEntry{
+			Framework: control.Framework,
+			ControlID: control.ID,
+			Title:     control.Title,
+			Status:    result.Status,
+			Resources: result.Resources,
+			Notes:     result.Notes,
+		}
+		// Remediation is only useful where something is missing
+		if result.Status == StatusGap {
+			entry.Remediation = control.Remediation
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+
+	return report
+}
+
+// Counts returns the number of satisfied, gap and not applicable controls
+func (r *ComplianceReport) Counts() (satisfied int, gaps int, notApplicable int) {
+	for _, entry := range r.Entries {
+		switch entry.Status {
+		case StatusSatisfied:
+			satisfied++
+		case StatusGap:
+			gaps++
+		case StatusNotApplicable:
+			notApplicable++
+		}
+	}
+	return satisfied, gaps, notApplicable
+}
+
+// Gaps returns the entries whose controls are not satisfied
+func (r *ComplianceReport) Gaps() []ComplianceEntry {
+	var gaps []ComplianceEntry
+	for _, entry := range r.Entries {
+		if entry.Status == StatusGap {
+			gaps = append(gaps, entry)
+		}
+	}
+	return gaps
+}
+
+// JSON returns the JSON representation of the report
+func (r *ComplianceReport) JSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal compliance report: %w", err)
+	}
+	return string(data), nil
+}
+
+// Markdown returns the compliance matrix as a Markdown document
+func (r *ComplianceReport) Markdown() string {
+	var buf bytes.Buffer
+
+	satisfied, gaps, notApplicable := r.Counts()
+
+	buf.WriteString("# Compliance Matrix\n\n")
+	buf.WriteString("Controls evaluated against the generated infrastructure model.\n\n")
+	buf.WriteString(fmt.Sprintf("- Satisfied: %d\n", satisfied))
+	buf.WriteString(fmt.Sprintf("- Gaps: %d\n", gaps))
+	buf.WriteString(fmt.Sprintf("- Not applicable: %d\n\n", notApplicable))
+
+	buf.WriteString("| Framework | Control | Title | Status | Resources | Notes |\n")
+	buf.WriteString("|-----------|---------|-------|--------|-----------|-------|\n")
+	for _, entry := range r.Entries {
+		buf.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s | %s |\n",
+			entry.Framework,
+			entry.ControlID,
+			entry.Title,
+			statusLabel(entry.Status),
+			strings.Join(entry.Resources, ", "),
+			entry.Notes,
+		))
+	}
+
+	// List the remaining gaps with remediation guidance
+	if gapEntries := r.Gaps(); len(gapEntries) > 0 {
+		buf.WriteString("\n## Gaps\n\n")
+		for _, entry := range gapEntries {
+			buf.WriteString(fmt.Sprintf("- **%s %s** %s: %s\n", entry.Framework, entry.ControlID, entry.Title, entry.Remediation))
+		}
+	}
+
+	return buf.String()
+}
+
+// statusLabel returns a human readable label for a control status
+func statusLabel(status ControlStatus) string {
+	switch status {
+	case StatusSatisfied:
+		return "Satisfied"
+	case StatusGap:
+		return "Gap"
+	default:
+		return "N/A"
+	}
+}
+
+// DefaultControls returns the built-in CIS AWS and SOC 2 controls
+func DefaultControls() []Control {
+	return []Control{
+		{
+			Framework:   FrameworkCISAWS,
+			ID:          "2.1.1",
+			Title:       "S3 buckets have server-side encryption enabled",
+			Remediation: "Enable default server-side encryption (SSE-S3 or SSE-KMS) on every bucket.",
+			Check:       checkS3Encryption,
+		},
+		{
+			Framework:   FrameworkCISAWS,
+			ID:          "2.1.5",
+			Title:       "S3 buckets are not publicly accessible",
+			Remediation: "Use a private ACL and enable S3 Block Public Access.",
+			Check:       checkS3PublicAccess,
+		},
+		{
+			Framework:   FrameworkCISAWS,
+			ID:          "3.9",
+			Title:       "VPC flow logging is enabled in all VPCs",
+			Remediation: "Add VPC flow logs delivered to CloudWatch Logs or S3.",
+			Check:       checkVPCFlowLogs,
+		},
+		{
+			Framework:   FrameworkCISAWS,
+			ID:          "5.2",
+			Title:       "No security group allows ingress from 0.0.0.0/0 to remote administration ports",
+			Remediation: "Restrict SSH (22) and RDP (3389) ingress to known CIDR ranges or use SSM Session Manager.",
+			Check:       checkAdminPortIngress,
+		},
+		{
+			Framework:   FrameworkCISAWS,
+			ID:          "5.6",
+			Title:       "EC2 metadata service requires IMDSv2",
+			Remediation: "Set metadata_options.http_tokens to \"required\" on instances and launch templates.",
+			Check:       checkIMDSv2,
+		},
+		{
+			Framework:   FrameworkCISAWS,
+			ID:          "EKS 5.4.2",
+			Title:       "EKS cluster API endpoint is private",
+			Remediation: "Enable private endpoint access and disable public endpoint access on the cluster.",
+			Check:       checkEKSPrivateEndpoint,
+		},
+		{
+			Framework:   FrameworkCISAWS,
+			ID:          "EKS 2.1.1",
+			Title:       "EKS control plane audit logging is enabled",
+			Remediation: "Enable the api, audit and authenticator control plane log types.",
+			Check:       checkEKSLogging,
+		},
+		{
+			Framework:   FrameworkSOC2,
+			ID:          "CC6.1",
+			Title:       "Workloads run under dedicated IAM roles",
+			Remediation: "Attach a dedicated IAM role to each cluster and node group.",
+			Check:       checkIAMRoles,
+		},
+		{
+			Framework:   FrameworkSOC2,
+			ID:          "CC6.6",
+			Title:       "Workloads are isolated in private subnets",
+			Remediation: "Place clusters and Auto Scaling Groups in private subnets behind a NAT Gateway.",
+			Check:       checkPrivateSubnets,
+		},
+		{
+			Framework:   FrameworkSOC2,
+			ID:          "CC7.2",
+			Title:       "Infrastructure activity is logged for monitoring",
+			Remediation: "Enable VPC flow logs and EKS control plane logging.",
+			Check:       checkMonitoring,
+		},
+		{
+			Framework:   FrameworkSOC2,
+			ID:          "A1.2",
+			Title:       "Resources are spread across multiple availability zones",
+			Remediation: "Create subnets in at least two availability zones and size groups for at least two instances.",
+			Check:       checkMultiAZ,
+		},
+		{
+			Framework:   FrameworkSOC2,
+			ID:          "A1.3",
+			Title:       "S3 buckets are versioned for recovery",
+			Remediation: "Enable versioning on every bucket.",
+			Check:       checkS3Versioning,
+		},
+	}
+}
+
+// resourcesOfType returns all resources in the model of the given types
+func resourcesOfType(model *models.InfrastructureModel, types ...models.ResourceType) []models.Resource {
+	var resources []models.Resource
+	if model == nil {
+		return resources
+	}
+	for _, resource := range model.Resources {
+		for _, t := range types {
+			if resource.Type == t {
+				resources = append(resources, resource)
+				break
+			}
+		}
+	}
+	return resources
+}
+
+// boolProperty returns a boolean property, or false if it is missing
+func boolProperty(resource models.Resource, name string) bool {
+	if value, ok := resource.GetProperty(name); ok {
+		if b, ok := value.(bool); ok {
+			return b
+		}
+	}
+	return false
+}
+
+// mapProperty returns a map property, or nil if it is missing
+func mapProperty(resource models.Resource, name string) map[string]interface{} {
+	if value, ok := resource.GetProperty(name); ok {
+		if m, ok := value.(map[string]interface{}); ok {
+			return m
+		}
+	}
+	return nil
+}
+
+// evaluateEach evaluates a predicate against each resource and reports a gap if any resource fails
+func evaluateEach(resources []models.Resource, passes func(models.Resource) bool, notes string) ControlResult {
+	if len(resources) == 0 {
+		return ControlResult{Status: StatusNotApplicable}
+	}
+
+	var passing, failing []string
+	for _, resource := range resources {
+		if passes(resource) {
+			passing = append(passing, resource.Name)
+		} else {
+			failing = append(failing, resource.Name)
+		}
+	}
+
+	if len(failing) > 0 {
+		return ControlResult{
+			Status:    StatusGap,
+			Resources: failing,
+			Notes:     notes,
+		}
+	}
+	return ControlResult{Status: StatusSatisfied, Resources: passing}
+}
+
+// checkS3Encryption checks that all buckets have server-side encryption configured
+func checkS3Encryption(model *models.InfrastructureModel) ControlResult {
+	buckets := resourcesOfType(model, models.ResourceS3Bucket)
+	return evaluateEach(buckets, func(bucket models.Resource) bool {
+		_, ok := bucket.GetProperty("server_side_encryption")
+		return ok
+	}, "no server-side encryption configured")
+}
+
+// checkS3PublicAccess checks that no bucket uses a public ACL
+func checkS3PublicAccess(model *models.InfrastructureModel) ControlResult {
+	buckets := resourcesOfType(model, models.ResourceS3Bucket)
+	return evaluateEach(buckets, func(bucket models.Resource) bool {
+		acl, _ := bucket.GetProperty("acl")
+		aclStr, _ := acl.(string)
+		return !strings.HasPrefix(aclStr, "public")
+	}, "bucket uses a public ACL")
+}
+
+// checkS3Versioning checks that all buckets are versioned
+func checkS3Versioning(model *models.InfrastructureModel) ControlResult {
+	buckets := resourcesOfType(model, models.ResourceS3Bucket)
+	return evaluateEach(buckets, func(bucket models.Resource) bool {
+		return boolProperty(bucket, "versioning")
+	}, "versioning is disabled")
+}
+
+// checkVPCFlowLogs checks that all VPCs have flow logs enabled
+func checkVPCFlowLogs(model *models.InfrastructureModel) ControlResult {
+	vpcs := resourcesOfType(model, models.ResourceVPC)
+	return evaluateEach(vpcs, func(vpc models.Resource) bool {
+		return boolProperty(vpc, "flow_logs")
+	}, "flow logs are not configured")
+}
+
+// checkAdminPortIngress checks security group ingress rules for open SSH/RDP access
+func checkAdminPortIngress(model *models.InfrastructureModel) ControlResult {
+	groups := resourcesOfType(model, models.ResourceSecurityGroup)
+	return evaluateEach(groups, func(group models.Resource) bool {
+		value, _ := group.GetProperty("ingress")
+		rules, _ := value.([]map[string]interface{})
+		for _, rule := range rules {
+			if !ruleOpenToWorld(rule) {
+				continue
+			}
+			fromPort, _ := rule["from_port"].(int)
+			toPort, _ := rule["to_port"].(int)
+			for _, port := range []int{22, 3389} {
+				if fromPort <= port && port <= toPort {
+					return false
+				}
+			}
+		}
+		return true
+	}, "administration port open to 0.0.0.0/0")
+}
+
+// ruleOpenToWorld reports whether a security group rule allows traffic from anywhere
+func ruleOpenToWorld(rule map[string]interface{}) bool {
+	cidrs, _ := rule["cidr_blocks"].([]string)
+	for _, cidr := range cidrs {
+		if cidr == "0.0.0.0/0" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIMDSv2 checks that instances and launch templates require IMDSv2 tokens
+func checkIMDSv2(model *models.InfrastructureModel) ControlResult {
+	compute := resourcesOfType(model, models.ResourceEC2Instance, models.ResourceLaunchTemplate)
+	return evaluateEach(compute, func(resource models.Resource) bool {
+		options := mapProperty(resource, "metadata_options")
+		return options != nil && options["http_tokens"] == "required"
+	}, "IMDSv1 is still allowed")
+}
+
+// checkEKSPrivateEndpoint checks that cluster endpoints are not publicly reachable
+func checkEKSPrivateEndpoint(model *models.InfrastructureModel) ControlResult {
+	clusters := resourcesOfType(model, models.ResourceEKSCluster)
+	return evaluateEach(clusters, func(cluster models.Resource) bool {
+		vpcConfig := mapProperty(cluster, "vpc_config")
+		if vpcConfig == nil {
+			return false
+		}
+		public, _ := vpcConfig["endpoint_public_access"].(bool)
+		private, _ := vpcConfig["endpoint_private_access"].(bool)
+		return private && !public
+	}, "public endpoint access is enabled")
+}
+
+// checkEKSLogging checks that cluster control plane logging is enabled
+func checkEKSLogging(model *models.InfrastructureModel) ControlResult {
+	clusters := resourcesOfType(model, models.ResourceEKSCluster)
+	return evaluateEach(clusters, func(cluster models.Resource) bool {
+		_, ok := cluster.GetProperty("enabled_cluster_log_types")
+		return ok
+	}, "control plane logging is not configured")
+}
+
+// checkIAMRoles checks that clusters and node groups reference an IAM role
+func checkIAMRoles(model *models.InfrastructureModel) ControlResult {
+	workloads := resourcesOfType(model, models.ResourceEKSCluster, models.ResourceNodeGroup)
+	return evaluateEach(workloads, func(resource models.Resource) bool {
+		for _, name := range []string{"role_arn", "node_role_arn"} {
+			if value, ok := resource.GetProperty(name); ok && value != "" {
+				return true
+			}
+		}
+		return false
+	}, "no IAM role attached")
+}
+
+// checkPrivateSubnets checks that clusters and Auto Scaling Groups only use private subnets
+func checkPrivateSubnets(model *models.InfrastructureModel) ControlResult {
+	workloads := resourcesOfType(model, models.ResourceEKSCluster, models.ResourceNodeGroup, models.ResourceAutoScalingGroup)
+	return evaluateEach(workloads, func(resource models.Resource) bool {
+		subnets := workloadSubnets(resource)
+		if len(subnets) == 0 {
+			return false
+		}
+		for _, subnet := range subnets {
+			if !strings.HasPrefix(subnet, "private-") {
+				return false
+			}
+		}
+		return true
+	}, "workload placed in public subnets")
+}
+
+// workloadSubnets returns the subnet names a workload resource is placed in
+func workloadSubnets(resource models.Resource) []string {
+	if vpcConfig := mapProperty(resource, "vpc_config"); vpcConfig != nil {
+		if subnets, ok := vpcConfig["subnet_ids"].([]string); ok {
+			return subnets
+		}
+	}
+	for _, name := range []string{"subnet_ids", "vpc_zone_identifier"} {
+		if value, ok := resource.GetProperty(name); ok {
+			if subnets, ok := value.([]string); ok {
+				return subnets
+			}
+		}
+	}
+	return nil
+}
+
+// checkMonitoring checks that network and control plane activity is logged
+func checkMonitoring(model *models.InfrastructureModel) ControlResult {
+	flowLogs := checkVPCFlowLogs(model)
+	clusterLogs := checkEKSLogging(model)
+
+	if flowLogs.Status == StatusNotApplicable && clusterLogs.Status == StatusNotApplicable {
+		return ControlResult{Status: StatusNotApplicable}
+	}
+
+	var gaps []string
+	if flowLogs.Status == StatusGap {
+		gaps = append(gaps, flowLogs.Resources...)
+	}
+	if clusterLogs.Status == StatusGap {
+		gaps = append(gaps, clusterLogs.Resources...)
+	}
+	if len(gaps) > 0 {
+		return ControlResult{
+			Status:    StatusGap,
+			Resources: gaps,
+			Notes:     "activity logging is not configured",
+		}
+	}
+
+	return ControlResult{
+		Status:    StatusSatisfied,
+		Resources: append(flowLogs.Resources, clusterLogs.Resources...),
+	}
+}
+
+// checkMultiAZ checks that subnets span multiple availability zones and groups can run two instances
+func checkMultiAZ(model *models.InfrastructureModel) ControlResult {
+	subnets := resourcesOfType(model, models.ResourceSubnet)
+	groups := resourcesOfType(model, models.ResourceAutoScalingGroup)
+	if len(subnets) == 0 && len(groups) == 0 {
+		return ControlResult{Status: StatusNotApplicable}
+	}
+
+	var gaps []string
+	var notes []string
+
+	// Collect the distinct availability zones used by subnets
+	zones := make(map[string]bool)
+	for _, subnet := range subnets {
+		if value, ok := subnet.GetProperty("availability_zone"); ok {
+			if az, ok := value.(string); ok {
+				zones[az] = true
+			}
+		}
+	}
+	if len(subnets) > 0 && len(zones) < 2 {
+		gaps = append(gaps, resourceNames(subnets)...)
+		notes = append(notes, "subnets use a single availability zone")
+	}
+
+	for _, group := range groups {
+		if value, ok := group.GetProperty("min_size"); ok {
+			if minSize, ok := value.(int); ok && minSize < 2 {
+				gaps = append(gaps, group.Name)
+				notes = append(notes, fmt.Sprintf("%s min_size is %d", group.Name, minSize))
+			}
+		}
+	}
+
+	if len(gaps) > 0 {
+		return ControlResult{
+			Status:    StatusGap,
+			Resources: gaps,
+			Notes:     strings.Join(notes, "; "),
+		}
+	}
+
+	sortedZones := make([]string, 0, len(zones))
+	for zone := range zones {
+		sortedZones = append(sortedZones, zone)
+	}
+	sort.Strings(sortedZones)
+
+	result := ControlResult{
+		Status:    StatusSatisfied,
+		Resources: append(resourceNames(subnets), resourceNames(groups)...),
+	}
+	if len(sortedZones) > 0 {
+		result.Notes = "zones: " + strings.Join(sortedZones, ", ")
+	}
+	return result
+}
+
+// resourceNames returns the names of the given resources
+func resourceNames(resources []models.Resource) []string {
+	names := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		names = append(names, resource.Name)
+	}
+	return names
+}
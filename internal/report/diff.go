@@ -0,0 +1,449 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/sensitive"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// FileChange is how generating would change a file of the target directory
+type FileChange string
+
+// Supported file changes. Generation never deletes files, so files it does not produce are
+// reported as not generated rather than removed.
+const (
+	FileAdded        FileChange = "added"
+	FileModified     FileChange = "modified"
+	FileNotGenerated FileChange = "not generated"
+)
+
+// diffContextLines is the number of unchanged lines shown around each change
+const diffContextLines = 3
+
+// ANSI colors of the diff output
+const (
+	colorReset = "\033[0m"
+	colorBold  = "\033[1m"
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorCyan  = "\033[36m"
+)
+
+// FileDiff is the change to one file
+type FileDiff struct {
+	// Path is relative to the directories being compared
+	Path   string     `json:"path"`
+	Change FileChange `json:"change"`
+	// Hunks is the unified diff of the file, without its header
+	Hunks   string `json:"hunks,omitempty"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+}
+
+// DirectoryDiff compares the files of a target directory with freshly generated ones
+type DirectoryDiff struct {
+	Dir   string     `json:"dir"`
+	Files []FileDiff `json:"files"`
+	// Unchanged lists the generated files that already match the target directory
+	Unchanged []string `json:"unchanged,omitempty"`
+}
+
+// DiffDirectories compares the files generated into one directory with those of a target
+// directory, which does not need to exist. Hidden files and directories, such as .terraform,
+// are ignored.
+func DiffDirectories(target, generated string) (*DirectoryDiff, error) {
+	generatedFiles, err := listFiles(generated)
+	if err != nil {
+		return nil, err
+	}
+	targetFiles := make(map[string]bool)
+	if info, err := os.Stat(target); err == nil {
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", target)
+		}
+		files, err := listFiles(target)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			targetFiles[file] = true
+		}
+	}
+
+	diff := &DirectoryDiff{Dir: target}
+	for _, file := range generatedFiles {
+		newContent, err := os.ReadFile(filepath.Join(generated, file))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read generated file: %w", err)
+		}
+		oldContent := ""
+		change := FileAdded
+		if targetFiles[file] {
+			content, err := os.ReadFile(filepath.Join(target, file))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", file, err)
+			}
+			oldContent = string(content)
+			change = FileModified
+			delete(targetFiles, file)
+		}
+		if oldContent == string(newContent) && change == FileModified {
+			diff.Unchanged = append(diff.Unchanged, file)
+			continue
+		}
+
+		hunks, added, removed := unifiedDiff(oldContent, string(newContent))
+		diff.Files = append(diff.Files, FileDiff{Path: file, Change: change, Hunks: hunks, Added: added, Removed: removed})
+	}
+	for file := range targetFiles {
+		diff.Files = append(diff.Files, FileDiff{Path: file, Change: FileNotGenerated})
+	}
+
+	sort.Slice(diff.Files, func(i, j int) bool { return diff.Files[i].Path < diff.Files[j].Path })
+	sort.Strings(diff.Unchanged)
+	return diff, nil
+}
+
+// listFiles returns the paths of the files under a directory, relative to it and with
+// forward slashes, skipping hidden files and directories
+func listFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	return files, nil
+}
+
+// Counts returns the number of added, modified and not generated files
+func (d *DirectoryDiff) Counts() (added int, modified int, notGenerated int) {
+	for _, file := range d.Files {
+		switch file.Change {
+		case FileAdded:
+			added++
+		case FileModified:
+			modified++
+		case FileNotGenerated:
+			notGenerated++
+		}
+	}
+	return added, modified, notGenerated
+}
+
+// HasChanges reports whether generating would add or modify any file
+func (d *DirectoryDiff) HasChanges() bool {
+	added, modified, _ := d.Counts()
+	return added+modified > 0
+}
+
+// Text returns the diff of every file followed by a summary, with ANSI colors if color is set
+func (d *DirectoryDiff) Text(color bool) string {
+	var buf bytes.Buffer
+	for _, file := range d.Files {
+		if file.Change != FileNotGenerated {
+			d.writeFileDiff(&buf, file, color)
+		}
+	}
+
+	added, modified, _ := d.Counts()
+	if added+modified == 0 {
+		buf.WriteString(fmt.Sprintf("No changes: %s matches the generated files\n", d.Dir))
+	} else {
+		buf.WriteString(fmt.Sprintf("%d files would change in %s: %d added, %d modified\n", added+modified, d.Dir, added, modified))
+	}
+	d.writeNotGenerated(&buf)
+	return buf.String()
+}
+
+// Plan returns the tree of the files generating would write, each marked as created,
+// modified or unchanged, followed by the diff of every modified file and a summary, with
+// ANSI colors if color is set
+func (d *DirectoryDiff) Plan(color bool) string {
+	labels := make(map[string]string)
+	for _, file := range d.Files {
+		switch file.Change {
+		case FileAdded:
+			labels[file.Path] = paint(color, colorGreen, "(created)")
+		case FileModified:
+			labels[file.Path] = paint(color, colorCyan, fmt.Sprintf("(modified, +%d -%d)", file.Added, file.Removed))
+		}
+	}
+	for _, path := range d.Unchanged {
+		labels[path] = "(unchanged)"
+	}
+	paths := make([]string, 0, len(labels))
+	for path := range labels {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	buf.WriteString(strings.TrimSuffix(d.Dir, "/") + "/\n")
+	buf.WriteString(FileTree(paths, func(path string) string { return labels[path] }))
+
+	added, modified, _ := d.Counts()
+	if modified > 0 {
+		buf.WriteString("\n")
+		for _, file := range d.Files {
+			if file.Change == FileModified {
+				d.writeFileDiff(&buf, file, color)
+			}
+		}
+	}
+
+	buf.WriteString(fmt.Sprintf("\nDry run: %d files would be created, %d modified and %d left unchanged in %s; nothing was written\n",
+		added, modified, len(d.Unchanged), d.Dir))
+	d.writeNotGenerated(&buf)
+	return buf.String()
+}
+
+// writeFileDiff writes the unified diff of one file
+func (d *DirectoryDiff) writeFileDiff(buf *bytes.Buffer, file FileDiff, color bool) {
+	from := "a/" + file.Path
+	if file.Change == FileAdded {
+		from = "/dev/null"
+	}
+	buf.WriteString(paint(color, colorBold, fmt.Sprintf("--- %s\n+++ b/%s", from, file.Path)) + "\n")
+	// Secrets assigned in the generated files are not printed
+	for _, line := range strings.SplitAfter(sensitive.Redact(file.Hunks), "\n") {
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case '@':
+			line = paint(color, colorCyan, strings.TrimSuffix(line, "\n")) + "\n"
+		case '+':
+			line = paint(color, colorGreen, strings.TrimSuffix(line, "\n")) + "\n"
+		case '-':
+			line = paint(color, colorRed, strings.TrimSuffix(line, "\n")) + "\n"
+		}
+		buf.WriteString(line)
+	}
+}
+
+// writeNotGenerated lists the files of the target directory that are not generated
+func (d *DirectoryDiff) writeNotGenerated(buf *bytes.Buffer) {
+	_, _, notGenerated := d.Counts()
+	if notGenerated == 0 {
+		return
+	}
+	buf.WriteString(fmt.Sprintf("%d files are not generated and would be left as they are:\n", notGenerated))
+	for _, file := range d.Files {
+		if file.Change == FileNotGenerated {
+			buf.WriteString("  " + file.Path + "\n")
+		}
+	}
+}
+
+// paint wraps text in an ANSI color if color is set
+func paint(color bool, code, text string) string {
+	if !color {
+		return text
+	}
+	return code + text + colorReset
+}
+
+// FileTree draws sorted, slash-separated file paths as a tree, with the files of a directory
+// before its subdirectories. If label is not nil, each file is followed by its label.
+func FileTree(paths []string, label func(path string) string) string {
+	return fileTree("", paths, label)
+}
+
+// fileTree draws the paths below the directory prefix, which is empty or ends with a slash
+func fileTree(prefix string, paths []string, label func(path string) string) string {
+	var files []string
+	dirs := make(map[string][]string)
+	var dirNames []string
+	for _, path := range paths {
+		dir, rest, nested := strings.Cut(path, "/")
+		if !nested {
+			files = append(files, path)
+			continue
+		}
+		if _, seen := dirs[dir]; !seen {
+			dirNames = append(dirNames, dir)
+		}
+		dirs[dir] = append(dirs[dir], rest)
+	}
+
+	var tree strings.Builder
+	entries := len(files) + len(dirNames)
+	for i, name := range append(files, dirNames...) {
+		branch, indent := "├── ", "│   "
+		if i == entries-1 {
+			branch, indent = "└── ", "    "
+		}
+		children, isDir := dirs[name]
+		if !isDir {
+			line := branch + name
+			if label != nil {
+				if text := label(prefix + name); text != "" {
+					line += " " + text
+				}
+			}
+			tree.WriteString(line + "\n")
+			continue
+		}
+		tree.WriteString(branch + name + "/\n")
+		for _, line := range strings.SplitAfter(fileTree(prefix+name+"/", children, label), "\n") {
+			if line != "" {
+				tree.WriteString(indent + line)
+			}
+		}
+	}
+	return tree.String()
+}
+
+// diffLine is one line of a line diff
+type diffLine struct {
+	op   diffmatchpatch.Operation
+	text string
+}
+
+// lineRune returns the rune a line is diffed as, skipping the surrogates, which are not
+// valid runes
+func lineRune(index int) rune {
+	if index >= 0xD800 {
+		return rune(index + 0x800)
+	}
+	return rune(index)
+}
+
+// lineIndex returns the index of the line diffed as a rune of lineRune
+func lineIndex(r rune) int {
+	if r >= 0xE000 {
+		return int(r) - 0x800
+	}
+	return int(r)
+}
+
+// unifiedDiff returns the hunks of a unified diff between two texts, and the number of
+// lines added and removed
+func unifiedDiff(oldText, newText string) (string, int, int) {
+	// Each line is diffed as one rune, mapped back to its line afterwards
+	var lineArray []string
+	lineRunes := map[string]rune{}
+	toRunes := func(text string) []rune {
+		var runes []rune
+		for _, line := range strings.SplitAfter(text, "\n") {
+			if line == "" {
+				continue
+			}
+			r, ok := lineRunes[line]
+			if !ok {
+				r = lineRune(len(lineArray))
+				lineRunes[line] = r
+				lineArray = append(lineArray, line)
+			}
+			runes = append(runes, r)
+		}
+		return runes
+	}
+	oldRunes, newRunes := toRunes(oldText), toRunes(newText)
+	diffs := diffmatchpatch.New().DiffMainRunes(oldRunes, newRunes, false)
+
+	var lines []diffLine
+	added, removed := 0, 0
+	for _, diff := range diffs {
+		for _, r := range diff.Text {
+			text := lineArray[lineIndex(r)]
+			if !strings.HasSuffix(text, "\n") {
+				text += "\n\\ No newline at end of file\n"
+			}
+			lines = append(lines, diffLine{op: diff.Type, text: text})
+			switch diff.Type {
+			case diffmatchpatch.DiffInsert:
+				added++
+			case diffmatchpatch.DiffDelete:
+				removed++
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	for start := 0; start < len(lines); {
+		// Find the next change, and the end of the hunk around it: the hunk ends where more
+		// unchanged lines follow than two contexts can hold
+		first := start
+		for first < len(lines) && lines[first].op == diffmatchpatch.DiffEqual {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		last := first
+		for next := first; next < len(lines); next++ {
+			if lines[next].op != diffmatchpatch.DiffEqual {
+				last = next
+			} else if next-last > 2*diffContextLines {
+				break
+			}
+		}
+
+		from := max(start, first-diffContextLines)
+		to := min(len(lines), last+1+diffContextLines)
+		oldStart, newStart := 1, 1
+		for _, line := range lines[:from] {
+			if line.op != diffmatchpatch.DiffInsert {
+				oldStart++
+			}
+			if line.op != diffmatchpatch.DiffDelete {
+				newStart++
+			}
+		}
+		oldCount, newCount := 0, 0
+		var hunk bytes.Buffer
+		for _, line := range lines[from:to] {
+			prefix := " "
+			switch line.op {
+			case diffmatchpatch.DiffInsert:
+				prefix = "+"
+				newCount++
+			case diffmatchpatch.DiffDelete:
+				prefix = "-"
+				oldCount++
+			default:
+				oldCount++
+				newCount++
+			}
+			hunk.WriteString(prefix + line.text)
+		}
+		// An empty side starts at the line before it, as in diff -u
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+		buf.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount))
+		buf.Write(hunk.Bytes())
+		start = to
+	}
+	return buf.String(), added, removed
+}
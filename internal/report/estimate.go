@@ -0,0 +1,289 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Cost estimators
+const (
+	EstimatorInfracost = "infracost"
+	EstimatorBuiltin   = "builtin"
+)
+
+// Estimators are the supported cost estimators
+var Estimators = []string{EstimatorInfracost, EstimatorBuiltin}
+
+// CostItem is the estimated monthly cost of one resource
+type CostItem struct {
+	// Project is the root module of the resource, relative to the estimated directory
+	Project string `json:"project"`
+	// Module is the module of the resource, such as module.vpc, or empty in the root module
+	Module   string `json:"module,omitempty"`
+	Resource string `json:"resource"`
+	// MonthlyCost is nil for a resource whose cost depends on its usage, or has no price
+	MonthlyCost *float64 `json:"monthly_cost"`
+	// Detail is what the cost is made of, such as 2 x t3.medium on demand
+	Detail string `json:"detail,omitempty"`
+}
+
+// ModuleCost is the estimated monthly cost of the resources of one module
+type ModuleCost struct {
+	Project     string     `json:"project"`
+	Module      string     `json:"module,omitempty"`
+	MonthlyCost float64    `json:"monthly_cost"`
+	Resources   []CostItem `json:"resources"`
+}
+
+// Name is the module, prefixed with its project when it is not the estimated directory
+// itself
+func (m ModuleCost) Name() string {
+	name := m.Module
+	if name == "" {
+		name = "(root)"
+	}
+	if m.Project != "" && m.Project != "." {
+		name = path.Join(m.Project, name)
+	}
+	return name
+}
+
+// CostEstimate is the estimated monthly cost of the Terraform of a directory
+type CostEstimate struct {
+	Dir       string     `json:"dir"`
+	Estimator string     `json:"estimator"`
+	Currency  string     `json:"currency"`
+	Resources []CostItem `json:"resources"`
+}
+
+// EstimateOptions configure a cost estimate
+type EstimateOptions struct {
+	// Estimator is infracost or builtin; by default infracost is used when it is in PATH
+	Estimator string
+	// Path is the infracost binary, found in PATH when empty
+	Path string
+}
+
+// FindEstimator returns the estimator to use, and the path of infracost when it is the
+// one: the estimator named, or infracost when it is in PATH and the built-in prices
+// otherwise
+func FindEstimator(estimator string) (string, string, error) {
+	switch estimator {
+	case "":
+		if infracost, err := exec.LookPath("infracost"); err == nil {
+			return EstimatorInfracost, infracost, nil
+		}
+		return EstimatorBuiltin, "", nil
+	case EstimatorInfracost:
+		infracost, err := exec.LookPath("infracost")
+		if err != nil {
+			return "", "", fmt.Errorf("infracost not found in PATH; install it from https://www.infracost.io/docs/, or estimate with the built-in prices with --estimator builtin")
+		}
+		return EstimatorInfracost, infracost, nil
+	case EstimatorBuiltin:
+		return EstimatorBuiltin, "", nil
+	}
+	return "", "", fmt.Errorf("invalid estimator: %s (supported estimators: %s)", estimator, strings.Join(Estimators, ", "))
+}
+
+// EstimateCosts estimates the monthly cost of the Terraform root modules of a directory,
+// with infracost or with the built-in prices of the common resources
+func EstimateCosts(dir string, options EstimateOptions) (*CostEstimate, error) {
+	if options.Path != "" && options.Estimator != EstimatorBuiltin {
+		return runInfracost(dir, options.Path)
+	}
+	estimator, infracost, err := FindEstimator(options.Estimator)
+	if err != nil {
+		return nil, err
+	}
+	if estimator == EstimatorBuiltin {
+		return builtinEstimate(dir)
+	}
+	return runInfracost(dir, infracost)
+}
+
+// infracostOutput is the part of the JSON output of infracost breakdown that is read
+type infracostOutput struct {
+	Currency string `json:"currency"`
+	Projects []struct {
+		Name     string `json:"name"`
+		Metadata struct {
+			Path string `json:"path"`
+		} `json:"metadata"`
+		Breakdown struct {
+			Resources []struct {
+				Name           string  `json:"name"`
+				MonthlyCost    *string `json:"monthlyCost"`
+				CostComponents []struct {
+					Name string `json:"name"`
+				} `json:"costComponents"`
+			} `json:"resources"`
+		} `json:"breakdown"`
+	} `json:"projects"`
+}
+
+// runInfracost estimates the monthly cost of the Terraform of a directory with infracost
+func runInfracost(dir, infracost string) (*CostEstimate, error) {
+	cmd := exec.Command(infracost, "breakdown", "--path", dir, "--format", "json", "--no-color")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("infracost failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var breakdown infracostOutput
+	if err := json.Unmarshal(output, &breakdown); err != nil {
+		return nil, fmt.Errorf("failed to read the infracost breakdown: %w", err)
+	}
+
+	estimate := &CostEstimate{Dir: dir, Estimator: EstimatorInfracost, Currency: breakdown.Currency, Resources: []CostItem{}}
+	if estimate.Currency == "" {
+		estimate.Currency = "USD"
+	}
+	for _, project := range breakdown.Projects {
+		name := project.Name
+		if project.Metadata.Path != "" {
+			if rel, err := filepath.Rel(dir, project.Metadata.Path); err == nil && !strings.HasPrefix(rel, "..") {
+				name = filepath.ToSlash(rel)
+			}
+		}
+		for _, resource := range project.Breakdown.Resources {
+			module, address := splitModuleAddress(resource.Name)
+			item := CostItem{Project: name, Module: module, Resource: address}
+			if resource.MonthlyCost != nil {
+				cost, err := strconv.ParseFloat(*resource.MonthlyCost, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid monthly cost of %s: %s", resource.Name, *resource.MonthlyCost)
+				}
+				item.MonthlyCost = &cost
+			} else {
+				item.Detail = "usage-based"
+			}
+			var components []string
+			for _, component := range resource.CostComponents {
+				components = append(components, component.Name)
+			}
+			if len(components) > 0 && item.Detail == "" {
+				item.Detail = strings.Join(components, ", ")
+			}
+			estimate.Resources = append(estimate.Resources, item)
+		}
+	}
+	return estimate, nil
+}
+
+// splitModuleAddress splits a resource address into its module, such as module.vpc, and
+// its address within the module
+func splitModuleAddress(address string) (string, string) {
+	var modules []string
+	for strings.HasPrefix(address, "module.") {
+		parts := strings.SplitN(address, ".", 3)
+		if len(parts) < 3 {
+			break
+		}
+		modules = append(modules, "module."+parts[1])
+		address = parts[2]
+	}
+	return strings.Join(modules, "."), address
+}
+
+// Total returns the estimated monthly cost of all the resources
+func (e *CostEstimate) Total() float64 {
+	total := 0.0
+	for _, item := range e.Resources {
+		if item.MonthlyCost != nil {
+			total += *item.MonthlyCost
+		}
+	}
+	return total
+}
+
+// Modules returns the estimated monthly cost of each module, by project and module name,
+// with the root module of each project first
+func (e *CostEstimate) Modules() []ModuleCost {
+	var modules []ModuleCost
+	index := make(map[string]int)
+	for _, item := range e.Resources {
+		key := item.Project + "\x00" + item.Module
+		i, ok := index[key]
+		if !ok {
+			i = len(modules)
+			index[key] = i
+			modules = append(modules, ModuleCost{Project: item.Project, Module: item.Module})
+		}
+		if item.MonthlyCost != nil {
+			modules[i].MonthlyCost += *item.MonthlyCost
+		}
+		modules[i].Resources = append(modules[i].Resources, item)
+	}
+	sort.SliceStable(modules, func(i, j int) bool {
+		if modules[i].Project != modules[j].Project {
+			return modules[i].Project < modules[j].Project
+		}
+		return modules[i].Module < modules[j].Module
+	})
+	return modules
+}
+
+// JSON returns the estimate, with the cost of each module, as indented JSON
+func (e *CostEstimate) JSON() (string, error) {
+	content, err := json.MarshalIndent(struct {
+		*CostEstimate
+		MonthlyCost float64      `json:"monthly_cost"`
+		Modules     []ModuleCost `json:"modules"`
+	}{e, e.Total(), e.Modules()}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// Text returns the estimate as a readable breakdown: the monthly cost of each module, then
+// of each of its resources, and the total
+func (e *CostEstimate) Text() string {
+	var table, buf bytes.Buffer
+
+	w := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	for _, module := range e.Modules() {
+		fmt.Fprintf(w, "%s\t%s\t\n", module.Name(), e.money(module.MonthlyCost))
+		for _, item := range module.Resources {
+			cost := "-"
+			if item.MonthlyCost != nil {
+				cost = e.money(*item.MonthlyCost)
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", item.Resource, cost, item.Detail)
+		}
+	}
+	w.Flush()
+	// The module lines have no detail, so they are padded with trailing spaces
+	for _, line := range strings.SplitAfter(table.String(), "\n") {
+		buf.WriteString(strings.TrimRight(strings.TrimSuffix(line, "\n"), " "))
+		if strings.HasSuffix(line, "\n") {
+			buf.WriteString("\n")
+		}
+	}
+
+	prices := "infracost"
+	if e.Estimator == EstimatorBuiltin {
+		prices = "the built-in us-east-1 on-demand prices"
+	}
+	fmt.Fprintf(&buf, "Total: %s per month, estimated with %s; usage-based costs are not included\n", e.money(e.Total()), prices)
+	return buf.String()
+}
+
+// money formats an amount in the currency of the estimate
+func (e *CostEstimate) money(amount float64) string {
+	if e.Currency == "" || e.Currency == "USD" {
+		return fmt.Sprintf("$%.2f", amount)
+	}
+	return fmt.Sprintf("%.2f %s", amount, e.Currency)
+}
@@ -0,0 +1,307 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/internal/sensitive"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// maxExplainedValue is the length beyond which string values are shortened in explanations
+const maxExplainedValue = 72
+
+// ExplainedResource is a resource of an explained model
+type ExplainedResource struct {
+	Type       models.ResourceType `json:"type"`
+	Name       string              `json:"name"`
+	Properties []models.Property   `json:"properties"`
+	// DependsOn lists the explicit dependencies of the resource and the resources its
+	// properties refer to by name
+	DependsOn []string `json:"depends_on,omitempty"`
+}
+
+// Explanation is a readable summary of an infrastructure model and of how it was derived
+// from the description
+type Explanation struct {
+	Region      string              `json:"region,omitempty"`
+	Environment string              `json:"environment,omitempty"`
+	Tags        map[string]string   `json:"tags,omitempty"`
+	Resources   []ExplainedResource `json:"resources"`
+	// Assumptions are the defaults applied because the description did not state a value.
+	// They are empty for a model loaded from a spec.
+	Assumptions []nlp.Assumption   `json:"assumptions,omitempty"`
+	Confidence  map[string]float64 `json:"confidence,omitempty"`
+}
+
+// ExplainModel summarizes an infrastructure model. The extraction report of the parsed
+// description adds the assumed defaults, and may be nil.
+func ExplainModel(model *models.InfrastructureModel, extraction *nlp.ExtractionReport) *Explanation {
+	explanation := &Explanation{
+		Region:      model.Region,
+		Environment: model.Environment,
+		Tags:        model.Tags,
+		Resources:   make([]ExplainedResource, 0, len(model.Resources)),
+	}
+	if extraction != nil {
+		explanation.Assumptions = extraction.Assumptions
+		explanation.Confidence = extraction.Confidence
+	}
+
+	for i := range model.Resources {
+		resource := &model.Resources[i]
+		explanation.Resources = append(explanation.Resources, ExplainedResource{
+			Type:       resource.Type,
+			Name:       resource.Name,
+			Properties: redactProperties(resource.Properties),
+			DependsOn:  model.Dependencies(resource),
+		})
+	}
+
+	return explanation
+}
+
+// redactProperties returns the properties with the values of secrets, such as the password of
+// a database, replaced, at any depth
+func redactProperties(properties []models.Property) []models.Property {
+	redacted := make([]models.Property, len(properties))
+	for i, property := range properties {
+		redacted[i] = models.Property{Name: property.Name, Value: redactValue(property.Name, property.Value)}
+	}
+	return redacted
+}
+
+// redactValue returns the value of a property, or of a key of a map, without its secrets
+func redactValue(name string, value interface{}) interface{} {
+	if sensitive.Name(name) {
+		return sensitive.Redacted
+	}
+	if values, ok := value.(map[string]interface{}); ok {
+		redacted := make(map[string]interface{}, len(values))
+		for key, nested := range values {
+			redacted[key] = redactValue(key, nested)
+		}
+		return redacted
+	}
+	return value
+}
+
+// JSON returns the explanation as indented JSON
+func (e *Explanation) JSON() (string, error) {
+	content, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal explanation: %w", err)
+	}
+	return string(content), nil
+}
+
+// summary returns the one-line summary the text and Markdown explanations start with
+func (e *Explanation) summary() string {
+	summary := fmt.Sprintf("%d resources", len(e.Resources))
+	if e.Region != "" {
+		summary += " in " + e.Region
+	}
+	if e.Environment != "" {
+		summary += fmt.Sprintf(" for the %s environment", e.Environment)
+	}
+	return summary
+}
+
+// Text renders the explanation as a tree of resources, their properties and dependencies,
+// followed by the assumed defaults
+func (e *Explanation) Text() string {
+	var buf bytes.Buffer
+
+	buf.WriteString("Infrastructure model: " + e.summary() + "\n")
+	if len(e.Tags) > 0 {
+		buf.WriteString("Tags: " + formatTags(e.Tags) + "\n")
+	}
+	for i, resource := range e.Resources {
+		branch, indent := "├── ", "│   "
+		if i == len(e.Resources)-1 {
+			branch, indent = "└── ", "    "
+		}
+		buf.WriteString(fmt.Sprintf("%s%s %s\n", branch, resource.Type, resource.Name))
+
+		var children []treeNode
+		for _, property := range resource.Properties {
+			children = append(children, propertyNode(property.Name, property.Value))
+		}
+		if len(resource.DependsOn) > 0 {
+			children = append(children, treeNode{label: "depends on: " + strings.Join(resource.DependsOn, ", ")})
+		}
+		writeTree(&buf, children, indent)
+	}
+
+	buf.WriteString("\nAssumed defaults\n")
+	if len(e.Assumptions) == 0 {
+		buf.WriteString("  None\n")
+	}
+	for _, assumption := range e.Assumptions {
+		buf.WriteString(fmt.Sprintf("  • %s = %v: %s\n", assumptionName(assumption), assumption.Value, assumption.Reason))
+	}
+	if len(e.Confidence) > 0 {
+		buf.WriteString("  Confidence: " + formatConfidence(e.Confidence) + "\n")
+	}
+
+	return buf.String()
+}
+
+// Markdown renders the explanation as nested lists, for pull request descriptions
+func (e *Explanation) Markdown() string {
+	var buf bytes.Buffer
+
+	buf.WriteString("## Infrastructure Model\n\n")
+	buf.WriteString(strings.ToUpper(e.summary()[:1]) + e.summary()[1:] + ".\n\n")
+	if len(e.Tags) > 0 {
+		buf.WriteString("Tags: " + formatTags(e.Tags) + "\n\n")
+	}
+	for _, resource := range e.Resources {
+		buf.WriteString(fmt.Sprintf("- **%s** `%s`\n", resource.Type, resource.Name))
+		for _, property := range resource.Properties {
+			writeMarkdownNode(&buf, propertyNode(property.Name, property.Value), "  ")
+		}
+		if len(resource.DependsOn) > 0 {
+			buf.WriteString("  - depends on: `" + strings.Join(resource.DependsOn, "`, `") + "`\n")
+		}
+	}
+
+	buf.WriteString("\n### Assumed Defaults\n\n")
+	if len(e.Assumptions) == 0 {
+		buf.WriteString("None.\n")
+	}
+	for _, assumption := range e.Assumptions {
+		buf.WriteString(fmt.Sprintf("- `%s = %v`: %s\n", assumptionName(assumption), assumption.Value, assumption.Reason))
+	}
+	if len(e.Confidence) > 0 {
+		buf.WriteString("\nConfidence: " + formatConfidence(e.Confidence) + "\n")
+	}
+
+	return buf.String()
+}
+
+// treeNode is a property, or a nested value of one, shown in an explanation
+type treeNode struct {
+	label    string
+	children []treeNode
+}
+
+// propertyNode returns the tree node of a property. Maps and lists of maps become subtrees,
+// and other values are shown inline.
+func propertyNode(name string, value interface{}) treeNode {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		node := treeNode{label: name}
+		for _, key := range utils.SortedKeys(v) {
+			node.children = append(node.children, propertyNode(key, v[key]))
+		}
+		return node
+	case []interface{}:
+		if len(v) > 0 {
+			if _, ok := v[0].(map[string]interface{}); ok {
+				node := treeNode{label: name}
+				for i, item := range v {
+					node.children = append(node.children, propertyNode(fmt.Sprintf("[%d]", i), item))
+				}
+				return node
+			}
+		}
+	case []map[string]interface{}:
+		node := treeNode{label: name}
+		for i, item := range v {
+			node.children = append(node.children, propertyNode(fmt.Sprintf("[%d]", i), item))
+		}
+		return node
+	}
+	return treeNode{label: name + ": " + formatValue(value)}
+}
+
+// writeTree writes tree nodes with box-drawing branches under the given indent
+func writeTree(buf *bytes.Buffer, nodes []treeNode, indent string) {
+	for i, node := range nodes {
+		branch, childIndent := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, childIndent = "└── ", "    "
+		}
+		buf.WriteString(indent + branch + node.label + "\n")
+		writeTree(buf, node.children, indent+childIndent)
+	}
+}
+
+// writeMarkdownNode writes a tree node as a nested list item
+func writeMarkdownNode(buf *bytes.Buffer, node treeNode, indent string) {
+	label := node.label
+	if name, value, found := strings.Cut(label, ": "); found {
+		label = name + ": `" + value + "`"
+	}
+	buf.WriteString(indent + "- " + label + "\n")
+	for _, child := range node.children {
+		writeMarkdownNode(buf, child, indent+"  ")
+	}
+}
+
+// formatValue formats an inline property value, shortening long strings such as user data
+func formatValue(value interface{}) string {
+	var formatted string
+	switch v := value.(type) {
+	case []string:
+		formatted = "[" + strings.Join(v, ", ") + "]"
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		formatted = "[" + strings.Join(items, ", ") + "]"
+	case map[string]string:
+		formatted = "{" + formatTags(v) + "}"
+	default:
+		formatted = fmt.Sprint(value)
+	}
+
+	formatted = strings.Join(strings.Fields(formatted), " ")
+	if len(formatted) > maxExplainedValue {
+		formatted = formatted[:maxExplainedValue-3] + "..."
+	}
+	return formatted
+}
+
+// formatTags formats tags as sorted key=value pairs
+func formatTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + tags[key]
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// formatConfidence formats the confidence of each entity as sorted percentages
+func formatConfidence(confidence map[string]float64) string {
+	keys := make([]string, 0, len(confidence))
+	for key := range confidence {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	scores := make([]string, len(keys))
+	for i, key := range keys {
+		scores[i] = fmt.Sprintf("%s %.0f%%", key, confidence[key]*100)
+	}
+	return strings.Join(scores, ", ")
+}
+
+// assumptionName returns the entity and field an assumption is about
+func assumptionName(assumption nlp.Assumption) string {
+	if assumption.Field == "" {
+		return assumption.Entity
+	}
+	return assumption.Entity + "." + assumption.Field
+}
@@ -0,0 +1,251 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/internal/version"
+)
+
+// Patterns for values that should not leave the user's machine
+var (
+	accessKeyPattern = regexp.MustCompile(`\b(?:AKIA|ASIA)[A-Z0-9]{16}\b`)
+	secretKeyPattern = regexp.MustCompile(`\b[A-Za-z0-9/+]{40}\b`)
+	accountIDPattern = regexp.MustCompile(`\b\d{12}\b`)
+	emailPattern     = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	ipv4Pattern      = regexp.MustCompile(`\b\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}\b`)
+)
+
+// entityLabels describes what each parsed entity key means in a feedback report
+var entityLabels = map[string]string{
+	"region":       "AWS region",
+	"vpc":          "VPC",
+	"subnets":      "Subnets",
+	"gateways":     "Internet and NAT gateways",
+	"eks":          "EKS cluster",
+	"asg":          "Auto Scaling Group",
+	"ec2_instance": "EC2 instances",
+}
+
+// Redaction records one kind of value removed from a description
+type Redaction struct {
+	Kind  string `json:"kind"`
+	Count int    `json:"count"`
+}
+
+// FeedbackReport is an issue-ready report describing how a description was parsed
+type FeedbackReport struct {
+	Description string                 `json:"description"`
+	Redactions  []Redaction            `json:"redactions,omitempty"`
+	UserNote    string                 `json:"user_note,omitempty"`
+	Entities    map[string]interface{} `json:"entities,omitempty"`
+	ParseError  string                 `json:"parse_error,omitempty"`
+	Validation  string                 `json:"validation,omitempty"`
+	Fixes       map[string]interface{} `json:"fixes,omitempty"`
+	Version     string                 `json:"version"`
+	Platform    string                 `json:"platform"`
+}
+
+// RedactDescription removes credentials, account IDs, email addresses and public IP
+// addresses from a description. Public addresses are replaced with documentation-range
+// addresses so CIDR parsing still behaves the same way when the report is reproduced.
+func RedactDescription(description string) (string, []Redaction) {
+	var redactions []Redaction
+
+	replace := func(kind string, pattern *regexp.Regexp, fn func(string) string) {
+		count := 0
+		description = pattern.ReplaceAllStringFunc(description, func(match string) string {
+			replacement := fn(match)
+			if replacement != match {
+				count++
+			}
+			return replacement
+		})
+		if count > 0 {
+			redactions = append(redactions, Redaction{Kind: kind, Count: count})
+		}
+	}
+
+	replace("access key", accessKeyPattern, func(string) string { return "[REDACTED_ACCESS_KEY]" })
+	replace("secret key", secretKeyPattern, func(match string) string {
+		// Only mixed-case strings with digits look like secrets; long words and paths do not
+		if strings.ToLower(match) == match || strings.ToUpper(match) == match || !strings.ContainsAny(match, "0123456789") {
+			return match
+		}
+		return "[REDACTED_SECRET]"
+	})
+	replace("account ID", accountIDPattern, func(string) string { return "123456789012" })
+	replace("email", emailPattern, func(string) string { return "[REDACTED_EMAIL]" })
+	replace("public IP address", ipv4Pattern, func(match string) string {
+		ip := net.ParseIP(match)
+		if ip == nil || ip.IsPrivate() || ip.IsLoopback() || ip.IsUnspecified() || isDocumentationIP(ip) {
+			return match
+		}
+		return "203.0.113.0"
+	})
+
+	return description, redactions
+}
+
+// isDocumentationIP reports whether an address is in one of the RFC 5737 documentation ranges
+func isDocumentationIP(ip net.IP) bool {
+	for _, cidr := range []string{"192.0.2.0/24", "198.51.100.0/24", "203.0.113.0/24"} {
+		_, network, _ := net.ParseCIDR(cidr)
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateFeedbackReport redacts a description and the user's note, which ends up in the
+// issue's title and body too, and records how the parser interprets the description. Parse
+// failures are captured in the report rather than returned, since they are usually
+// what the user wants to report.
+func GenerateFeedbackReport(description string, userNote string) *FeedbackReport {
+	redacted, redactions := RedactDescription(description)
+	note, noteRedactions := RedactDescription(strings.TrimSpace(userNote))
+
+	report := &FeedbackReport{
+		Description: redacted,
+		Redactions:  mergeRedactions(redactions, noteRedactions),
+		UserNote:    note,
+		Version:     version.GetFullVersionInfo(),
+		Platform:    fmt.Sprintf("%s/%s (%s)", runtime.GOOS, runtime.GOARCH, runtime.Version()),
+	}
+
+	// Parse the redacted text so the report reproduces exactly what the maintainers will see
+	entities, err := nlp.NewParser().ExtractEntities(redacted)
+	if err != nil {
+		report.ParseError = err.Error()
+		return report
+	}
+	report.Entities = entities
+
+	validation := nlp.ValidateEntities(entities)
+	if !validation.Valid {
+		report.Validation = validation.Message
+	}
+	if len(validation.Fixes) > 0 {
+		report.Fixes = validation.Fixes
+	}
+
+	return report
+}
+
+// mergeRedactions adds the counts of the redactions of more text to those of the same kind
+func mergeRedactions(redactions, more []Redaction) []Redaction {
+	for _, redaction := range more {
+		merged := false
+		for i := range redactions {
+			if redactions[i].Kind == redaction.Kind {
+				redactions[i].Count += redaction.Count
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			redactions = append(redactions, redaction)
+		}
+	}
+	return redactions
+}
+
+// Title returns a short issue title for the report
+func (r *FeedbackReport) Title() string {
+	summary := r.Description
+	if r.UserNote != "" {
+		summary = r.UserNote
+	}
+	summary = strings.Join(strings.Fields(summary), " ")
+	// Runes are counted, so that a multi-byte character is never cut in two
+	if runes := []rune(summary); len(runes) > 60 {
+		summary = string(runes[:57]) + "..."
+	}
+	return "Parser feedback: " + summary
+}
+
+// Markdown renders the report as a GitHub issue body
+func (r *FeedbackReport) Markdown() string {
+	var buf bytes.Buffer
+
+	buf.WriteString("## Description\n\n")
+	buf.WriteString("```text\n")
+	buf.WriteString(strings.TrimSpace(r.Description) + "\n")
+	buf.WriteString("```\n\n")
+	if len(r.Redactions) > 0 {
+		var kinds []string
+		for _, redaction := range r.Redactions {
+			kinds = append(kinds, fmt.Sprintf("%d %s", redaction.Count, redaction.Kind))
+		}
+		buf.WriteString(fmt.Sprintf("_Redacted before submission: %s._\n\n", strings.Join(kinds, ", ")))
+	}
+
+	buf.WriteString("## What went wrong\n\n")
+	if r.UserNote != "" {
+		buf.WriteString(r.UserNote + "\n\n")
+	} else {
+		buf.WriteString("_Describe what you expected the generator to produce._\n\n")
+	}
+
+	buf.WriteString("## Parse Explanation\n\n")
+	if r.ParseError != "" {
+		buf.WriteString(fmt.Sprintf("The parser rejected the description: `%s`\n\n", r.ParseError))
+	} else {
+		buf.WriteString("| Entity | Recognized |\n")
+		buf.WriteString("|--------|------------|\n")
+		for _, key := range sortedEntityKeys() {
+			recognized := "no"
+			if _, ok := r.Entities[key]; ok {
+				recognized = "yes"
+			}
+			buf.WriteString(fmt.Sprintf("| %s | %s |\n", entityLabels[key], recognized))
+		}
+		buf.WriteString("\n")
+
+		if entitiesJSON, err := json.MarshalIndent(r.Entities, "", "  "); err == nil {
+			buf.WriteString("<details>\n<summary>Extracted entities</summary>\n\n")
+			buf.WriteString("```json\n")
+			buf.Write(entitiesJSON)
+			buf.WriteString("\n```\n\n</details>\n\n")
+		}
+
+		if r.Validation != "" {
+			buf.WriteString(fmt.Sprintf("Defaults applied by validation: %s\n\n", r.Validation))
+		}
+	}
+
+	buf.WriteString("## Environment\n\n")
+	buf.WriteString(fmt.Sprintf("- iacgen: %s\n", r.Version))
+	buf.WriteString(fmt.Sprintf("- Platform: %s\n", r.Platform))
+
+	return buf.String()
+}
+
+// JSON renders the report as indented JSON
+func (r *FeedbackReport) JSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal feedback report: %w", err)
+	}
+	return string(data), nil
+}
+
+// sortedEntityKeys returns the entity keys in a stable order, region first
+func sortedEntityKeys() []string {
+	keys := make([]string, 0, len(entityLabels))
+	for key := range entityLabels {
+		if key != "region" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return append([]string{"region"}, keys...)
+}
@@ -0,0 +1,476 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"gopkg.in/yaml.v3"
+)
+
+// managedPolicyPrefix is the ARN prefix of the AWS managed policies
+const managedPolicyPrefix = "arn:aws:iam::aws:policy/"
+
+// adminPolicyFix is the scoped alternative to the managed policies that allow nearly everything
+const adminPolicyFix = "use a policy of the actions the role needs, which IAM Access Analyzer can generate from its CloudTrail activity"
+
+// broadManagedPolicies are the AWS managed policies that allow nearly everything, with their
+// scoped alternative. The other FullAccess policies allow everything of one service.
+var broadManagedPolicies = map[string]string{
+	"AdministratorAccess": adminPolicyFix,
+	"PowerUserAccess":     adminPolicyFix,
+	"IAMFullAccess":       "use IAMReadOnlyAccess, or a policy scoped to the roles it manages",
+}
+
+// iamStatement is a statement of an IAM policy, from JSON, jsonencode or an
+// aws_iam_policy_document. Values Terraform computes, such as ARNs, read as ${expression}.
+type iamStatement struct {
+	Effect     string
+	Actions    []string
+	NotAction  bool
+	Resources  []string
+	Principals []string
+	Conditions []iamCondition
+	Line       int
+}
+
+// iamCondition is a condition of a statement, such as StringEquals on a key
+type iamCondition struct {
+	Operator string
+	Key      string
+	Values   []string
+}
+
+// lintIAM checks the IAM policies and managed policy attachments of the Terraform files and
+// the Crossplane manifests for wildcard actions and principals, write actions on every
+// resource, web identity trust without conditions, and managed policies that allow every
+// action of a service or more
+func (r *ValidationReport) lintIAM(terraformFiles, yamlFiles []string) {
+	for _, path := range terraformFiles {
+		r.lintTerraformIAM(path)
+	}
+	for _, path := range yamlFiles {
+		r.lintManifestIAM(path)
+	}
+}
+
+// lintTerraformIAM checks the policies of a Terraform file: the jsonencode documents and JSON
+// strings with statements, the aws_iam_policy_document data sources, and the managed policy
+// ARNs it attaches
+func (r *ValidationReport) lintTerraformIAM(path string) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	// The HCL check reports the files that do not parse
+	file, diags := hclsyntax.ParseConfig(src, path, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return
+	}
+	r.lintTerraformBody(path, src, body, "")
+}
+
+// lintTerraformBody checks the attributes and blocks of a body, naming the findings after the
+// top-level block they are in, such as aws_iam_role.node, or the local value
+func (r *ValidationReport) lintTerraformBody(path string, src []byte, body *hclsyntax.Body, where string) {
+	for _, attribute := range body.Attributes {
+		where := where
+		if where == "locals" {
+			where = "local." + attribute.Name
+		}
+		hclsyntax.VisitAll(attribute.Expr, func(node hclsyntax.Node) hcl.Diagnostics {
+			switch expr := node.(type) {
+			case *hclsyntax.FunctionCallExpr:
+				if expr.Name == "jsonencode" && len(expr.Args) == 1 {
+					if document, ok := hclStaticValue(expr.Args[0], src).(map[string]interface{}); ok {
+						statements := policyStatements(document, expr.Range().Start.Line)
+						setStatementLines(statements, expr.Args[0])
+						r.lintPolicy(path, where, statements)
+					}
+				}
+			case *hclsyntax.TemplateExpr:
+				if value, ok := hclStaticValue(expr, src).(string); ok {
+					r.lintIAMString(path, where, expr.Range().Start.Line, value)
+				}
+			}
+			return nil
+		})
+	}
+	for _, block := range body.Blocks {
+		name := where
+		if name == "" {
+			name = terraformBlockName(block)
+		}
+		if block.Type == "data" && len(block.Labels) == 2 && block.Labels[0] == "aws_iam_policy_document" {
+			r.lintPolicy(path, name, policyDocumentStatements(block, src))
+		}
+		r.lintTerraformBody(path, src, block.Body, name)
+	}
+}
+
+// terraformBlockName names a top-level block the way Terraform addresses it, such as
+// aws_iam_role.node or data.aws_iam_policy_document.assume_role
+func terraformBlockName(block *hclsyntax.Block) string {
+	switch {
+	case block.Type == "resource" && len(block.Labels) == 2:
+		return block.Labels[0] + "." + block.Labels[1]
+	case len(block.Labels) > 0:
+		return block.Type + "." + strings.Join(block.Labels, ".")
+	}
+	return block.Type
+}
+
+// lintManifestIAM checks the policy documents and managed policy ARNs of the manifests of a
+// YAML file, naming the findings after the kind and name of their manifest
+func (r *ValidationReport) lintManifestIAM(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var document yaml.Node
+		if err := decoder.Decode(&document); err != nil {
+			// At the end of the file; the YAML check reports the files that do not parse
+			return
+		}
+		var manifest struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+		}
+		_ = document.Decode(&manifest)
+		where := strings.TrimSpace(manifest.Kind + " " + manifest.Metadata.Name)
+		r.lintYAMLNode(path, where, &document)
+	}
+}
+
+// lintYAMLNode checks the string values of a YAML node and its children
+func (r *ValidationReport) lintYAMLNode(path, where string, node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!str" {
+		r.lintIAMString(path, where, node.Line, node.Value)
+	}
+	for _, child := range node.Content {
+		r.lintYAMLNode(path, where, child)
+	}
+}
+
+// lintIAMString checks a string that is a managed policy ARN or a JSON policy document
+func (r *ValidationReport) lintIAMString(path, where string, line int, value string) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, managedPolicyPrefix) {
+		r.lintManagedPolicy(path, where, line, value)
+		return
+	}
+	if !strings.HasPrefix(value, "{") || !strings.Contains(value, "Statement") {
+		return
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &document); err != nil {
+		return
+	}
+	r.lintPolicy(path, where, policyStatements(document, line))
+}
+
+// lintManagedPolicy reports the AWS managed policies that allow every action of a service or
+// more, with a scoped alternative
+func (r *ValidationReport) lintManagedPolicy(path, where string, line int, arn string) {
+	name := arn[strings.LastIndex(arn, "/")+1:]
+	if fix, ok := broadManagedPolicies[name]; ok {
+		r.addIAM(SeverityError, path, where, line, fmt.Sprintf("attaches the AWS managed policy %s, which allows nearly every action on every resource; %s", name, fix))
+		return
+	}
+	if strings.HasSuffix(name, "FullAccess") {
+		readOnly := strings.TrimSuffix(name, "FullAccess") + "ReadOnlyAccess"
+		r.addIAM(SeverityWarning, path, where, line, fmt.Sprintf("attaches the AWS managed policy %s, which allows every action of its service on every resource; use %s if the role only reads, or a policy of the actions it needs on its own resources", name, readOnly))
+	}
+}
+
+// lintPolicy reports the problems of the Allow statements of a policy
+func (r *ValidationReport) lintPolicy(path, where string, statements []iamStatement) {
+	for _, statement := range statements {
+		if !strings.EqualFold(statement.Effect, "Allow") {
+			continue
+		}
+		for _, finding := range lintStatement(statement) {
+			r.addIAM(finding.Severity, path, where, statement.Line, finding.Message)
+		}
+	}
+}
+
+// addIAM records a finding of the IAM check, prefixed with the resource or manifest it is in
+func (r *ValidationReport) addIAM(severity FindingSeverity, path, where string, line int, message string) {
+	if where != "" {
+		message = where + ": " + message
+	}
+	r.add(severity, path, line, "iam", message)
+}
+
+// lintStatement returns the problems of an Allow statement
+func lintStatement(statement iamStatement) []Finding {
+	var findings []Finding
+	warn := func(severity FindingSeverity, format string, args ...interface{}) {
+		findings = append(findings, Finding{Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var writes []string
+	webIdentity := false
+	for _, action := range statement.Actions {
+		service, name, _ := strings.Cut(action, ":")
+		switch {
+		case action == "*":
+			warn(SeverityError, `allows every action ("*"); list the actions the role needs instead`)
+		case name == "*":
+			warn(SeverityWarning, "allows every %s action (%s); list the %s actions the role needs, such as %s:Describe* and %s:List* to only read", service, action, service, service, service)
+		case strings.Contains(name, "*") && !readOnlyAction(name):
+			warn(SeverityWarning, "allows every action matching %s; list the actions the role needs", action)
+		case strings.EqualFold(action, "sts:AssumeRoleWithWebIdentity"):
+			webIdentity = true
+		case !readOnlyAction(name) && !strings.Contains(name, "*"):
+			writes = append(writes, action)
+		}
+	}
+	if statement.NotAction {
+		warn(SeverityWarning, "allows every action but those of its NotAction; list the actions the role needs with Action instead")
+	}
+
+	if len(statement.Conditions) == 0 && len(writes) > 0 && containsValue(statement.Resources, "*") {
+		actions := writes[0]
+		if len(writes) > 1 {
+			actions = fmt.Sprintf("%s and %d more write actions", writes[0], len(writes)-1)
+		}
+		warn(SeverityWarning, `allows %s on every resource ("*"); list the ARNs of the resources, or add a condition such as aws:ResourceTag/<key> or aws:RequestTag/<key> limiting it to the resources the role manages`, actions)
+	}
+	if len(statement.Conditions) == 0 && containsValue(statement.Principals, "*") {
+		warn(SeverityError, `trusts every principal ("*"); name the principals, or add a condition such as aws:SourceAccount or aws:PrincipalOrgID`)
+	}
+
+	if webIdentity {
+		var sub, aud *iamCondition
+		for i, condition := range statement.Conditions {
+			switch {
+			case strings.HasSuffix(condition.Key, ":sub"):
+				sub = &statement.Conditions[i]
+			case strings.HasSuffix(condition.Key, ":aud"):
+				aud = &statement.Conditions[i]
+			}
+		}
+		switch {
+		case sub == nil:
+			warn(SeverityError, "lets every identity of the OIDC provider assume the role, as no condition limits the sub of its token; add a StringEquals condition on <issuer>:sub to system:serviceaccount:<namespace>:<service-account>")
+		case strings.HasPrefix(sub.Operator, "StringLike") && containsWildcard(sub.Values):
+			warn(SeverityWarning, "lets every service account matching %s assume the role; name the service account with a StringEquals condition", strings.Join(sub.Values, ", "))
+		}
+		if aud == nil {
+			warn(SeverityWarning, "does not check the audience of the web identity token; add a StringEquals condition on <issuer>:aud to sts.amazonaws.com")
+		}
+	}
+	return findings
+}
+
+// readOnlyAction reports whether the name of an action, such as DescribeInstances or List*,
+// only reads
+func readOnlyAction(name string) bool {
+	for _, prefix := range []string{"Describe", "List", "Get"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsValue reports whether a list has a value
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// containsWildcard reports whether a value of a list has a * wildcard
+func containsWildcard(values []string) bool {
+	for _, value := range values {
+		if strings.Contains(value, "*") {
+			return true
+		}
+	}
+	return false
+}
+
+// policyStatements returns the statements of a policy document, as JSON decodes it, all at
+// the line of the document
+func policyStatements(document map[string]interface{}, line int) []iamStatement {
+	var raw []interface{}
+	switch value := document["Statement"].(type) {
+	case []interface{}:
+		raw = value
+	case map[string]interface{}:
+		raw = []interface{}{value}
+	}
+
+	var statements []iamStatement
+	for _, item := range raw {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		statement := iamStatement{Line: line}
+		statement.Effect, _ = fields["Effect"].(string)
+		statement.Actions = iamStrings(fields["Action"])
+		_, statement.NotAction = fields["NotAction"]
+		statement.Resources = iamStrings(fields["Resource"])
+		switch principal := fields["Principal"].(type) {
+		case string:
+			statement.Principals = []string{principal}
+		case map[string]interface{}:
+			for _, identifiers := range principal {
+				statement.Principals = append(statement.Principals, iamStrings(identifiers)...)
+			}
+		}
+		if conditions, ok := fields["Condition"].(map[string]interface{}); ok {
+			for operator, keys := range conditions {
+				values, _ := keys.(map[string]interface{})
+				for key, value := range values {
+					statement.Conditions = append(statement.Conditions, iamCondition{Operator: operator, Key: key, Values: iamStrings(value)})
+				}
+			}
+		}
+		statements = append(statements, statement)
+	}
+	return statements
+}
+
+// setStatementLines sets the line of each statement of a jsonencode document to the line
+// its object starts at
+func setStatementLines(statements []iamStatement, document hclsyntax.Expression) {
+	object, ok := document.(*hclsyntax.ObjectConsExpr)
+	if !ok {
+		return
+	}
+	for _, item := range object.Items {
+		if hcl.ExprAsKeyword(item.KeyExpr) != "Statement" {
+			continue
+		}
+		if list, ok := item.ValueExpr.(*hclsyntax.TupleConsExpr); ok && len(list.Exprs) == len(statements) {
+			for i, statement := range list.Exprs {
+				statements[i].Line = statement.Range().Start.Line
+			}
+		}
+	}
+}
+
+// policyDocumentStatements returns the statements of an aws_iam_policy_document data source
+func policyDocumentStatements(block *hclsyntax.Block, src []byte) []iamStatement {
+	attribute := func(body *hclsyntax.Body, name string) interface{} {
+		if attr, ok := body.Attributes[name]; ok {
+			return hclStaticValue(attr.Expr, src)
+		}
+		return nil
+	}
+
+	var statements []iamStatement
+	for _, block := range block.Body.Blocks {
+		if block.Type != "statement" {
+			continue
+		}
+		statement := iamStatement{Effect: "Allow", Line: block.DefRange().Start.Line}
+		if effect, ok := attribute(block.Body, "effect").(string); ok {
+			statement.Effect = effect
+		}
+		statement.Actions = iamStrings(attribute(block.Body, "actions"))
+		_, statement.NotAction = block.Body.Attributes["not_actions"]
+		statement.Resources = iamStrings(attribute(block.Body, "resources"))
+		for _, nested := range block.Body.Blocks {
+			switch nested.Type {
+			case "principals":
+				statement.Principals = append(statement.Principals, iamStrings(attribute(nested.Body, "identifiers"))...)
+			case "condition":
+				test, _ := attribute(nested.Body, "test").(string)
+				variable, _ := attribute(nested.Body, "variable").(string)
+				statement.Conditions = append(statement.Conditions, iamCondition{Operator: test, Key: variable, Values: iamStrings(attribute(nested.Body, "values"))})
+			}
+		}
+		statements = append(statements, statement)
+	}
+	return statements
+}
+
+// iamStrings returns a policy value that is a string or a list of them as a list
+func iamStrings(value interface{}) []string {
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		var values []string
+		for _, item := range value {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// hclStaticValue returns the value of an expression as JSON would decode it, without
+// evaluating it: references, function calls and interpolations read as ${expression}, with
+// the source of the expression
+func hclStaticValue(expr hclsyntax.Expression, src []byte) interface{} {
+	switch expr := expr.(type) {
+	case *hclsyntax.LiteralValueExpr:
+		value := expr.Val
+		switch {
+		case value.IsNull():
+			return nil
+		case value.Type() == cty.String:
+			return value.AsString()
+		case value.Type() == cty.Bool:
+			return value.True()
+		case value.Type() == cty.Number:
+			return value.AsBigFloat().Text('f', -1)
+		}
+	case *hclsyntax.TemplateExpr:
+		var text strings.Builder
+		for _, part := range expr.Parts {
+			if str, ok := hclStaticValue(part, src).(string); ok {
+				text.WriteString(str)
+			}
+		}
+		return text.String()
+	case *hclsyntax.TupleConsExpr:
+		values := make([]interface{}, 0, len(expr.Exprs))
+		for _, item := range expr.Exprs {
+			values = append(values, hclStaticValue(item, src))
+		}
+		return values
+	case *hclsyntax.ObjectConsExpr:
+		values := make(map[string]interface{}, len(expr.Items))
+		for _, item := range expr.Items {
+			key := ""
+			if keyExpr, ok := item.KeyExpr.(*hclsyntax.ObjectConsKeyExpr); ok {
+				if keyword := hcl.ExprAsKeyword(keyExpr.Wrapped); keyword != "" && !keyExpr.ForceNonLiteral {
+					key = keyword
+				} else {
+					key, _ = hclStaticValue(keyExpr.Wrapped, src).(string)
+				}
+			}
+			values[key] = hclStaticValue(item.ValueExpr, src)
+		}
+		return values
+	case *hclsyntax.ParenthesesExpr:
+		return hclStaticValue(expr.Expression, src)
+	}
+	return "${" + string(expr.Range().SliceBytes(src)) + "}"
+}
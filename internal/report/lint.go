@@ -0,0 +1,128 @@
+package report
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// TFLintConfigFile is the tflint configuration a Terraform directory may have, which is
+// used instead of DefaultTFLintRuleset
+const TFLintConfigFile = ".tflint.hcl"
+
+// DefaultTFLintRuleset is the tflint configuration root modules without a .tflint.hcl are
+// linted with: the recommended rules of the bundled Terraform plugin and the naming
+// convention of resources, variables and outputs
+//
+//go:embed tflint.hcl
+var DefaultTFLintRuleset string
+
+// tflintIssue is an issue or an error of tflint --format json
+type tflintIssue struct {
+	Rule *struct {
+		Name     string `json:"name"`
+		Severity string `json:"severity"`
+	} `json:"rule"`
+	Summary  string `json:"summary"`
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
+	Range    *struct {
+		Filename string `json:"filename"`
+		Start    struct {
+			Line int `json:"line"`
+		} `json:"start"`
+	} `json:"range"`
+}
+
+// lintTerraform runs tflint on each root module, with its .tflint.hcl or the default ruleset
+func (r *ValidationReport) lintTerraform(roots []string, options ValidationOptions) {
+	tflint := options.TFLintPath
+	if tflint == "" {
+		var err error
+		if tflint, err = exec.LookPath("tflint"); err != nil {
+			r.add(SeverityWarning, r.Dir, 0, "tflint", "tflint not found in PATH; the Terraform files were not linted")
+			return
+		}
+	}
+
+	config, err := os.CreateTemp("", "iacgen-tflint-*.hcl")
+	if err != nil {
+		r.add(SeverityError, r.Dir, 0, "tflint", fmt.Sprintf("failed to write the default ruleset: %v", err))
+		return
+	}
+	defer os.Remove(config.Name())
+	_, err = config.WriteString(DefaultTFLintRuleset)
+	if closeErr := config.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		r.add(SeverityError, r.Dir, 0, "tflint", fmt.Sprintf("failed to write the default ruleset: %v", err))
+		return
+	}
+
+	for _, dir := range roots {
+		configPath := config.Name()
+		if _, err := os.Stat(filepath.Join(dir, TFLintConfigFile)); err == nil {
+			configPath = ""
+		}
+		r.runTFLint(tflint, configPath, dir)
+	}
+}
+
+// runTFLint lints a root module, with the configuration at configPath, or the .tflint.hcl of
+// the module when configPath is empty
+func (r *ValidationReport) runTFLint(tflint, configPath, dir string) {
+	args := []string{"--format", "json", "--no-color"}
+	if configPath != "" {
+		args = append(args, "--config", configPath)
+	}
+	cmd := exec.Command(tflint, args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	// tflint exits with an error when it finds issues, but still prints them
+	output, err := cmd.Output()
+	var result struct {
+		Issues []tflintIssue `json:"issues"`
+		Errors []tflintIssue `json:"errors"`
+	}
+	if jsonErr := json.Unmarshal(output, &result); jsonErr != nil {
+		if err == nil {
+			err = jsonErr
+		}
+		if len(output) == 0 {
+			output = stderr.Bytes()
+		}
+		r.add(SeverityError, dir, 0, "tflint", diagnosticMessage(fmt.Sprintf("tflint failed: %v", err), string(output)))
+		return
+	}
+
+	for _, issue := range append(result.Issues, result.Errors...) {
+		severity, message := issue.Severity, issue.Message
+		if issue.Rule != nil {
+			severity = issue.Rule.Severity
+			message = fmt.Sprintf("%s (%s)", issue.Message, issue.Rule.Name)
+		} else if issue.Summary != "" {
+			message = diagnosticMessage(issue.Summary, issue.Message)
+		}
+		path, line := dir, 0
+		if issue.Range != nil && issue.Range.Filename != "" {
+			path = issue.Range.Filename
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			line = issue.Range.Start.Line
+		}
+		// tflint's notices are reported as warnings, as they do not fail it either
+		findingSeverity := SeverityWarning
+		if severity == "error" {
+			findingSeverity = SeverityError
+		}
+		r.add(findingSeverity, path, line, "tflint", message)
+	}
+}
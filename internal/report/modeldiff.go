@@ -0,0 +1,307 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/sensitive"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// ModelChange is how a resource or a setting differs between two models
+type ModelChange string
+
+// Supported model changes
+const (
+	ModelAdded   ModelChange = "added"
+	ModelRemoved ModelChange = "removed"
+	ModelChanged ModelChange = "changed"
+)
+
+// dependsOnProperty is the name the explicit dependencies of a resource are compared under
+const dependsOnProperty = "depends_on"
+
+// PropertyDiff is a property, or a setting of the model such as its region, that differs
+// between two models. Before is nil for an added property and After for a removed one.
+type PropertyDiff struct {
+	Name   string      `json:"name"`
+	Change ModelChange `json:"change"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// ResourceDiff is a resource added, removed or changed between two models. The properties of
+// an added or removed resource are all listed as added or removed.
+type ResourceDiff struct {
+	Type       models.ResourceType `json:"type"`
+	Name       string              `json:"name"`
+	Change     ModelChange         `json:"change"`
+	Properties []PropertyDiff      `json:"properties,omitempty"`
+}
+
+// ModelDiff compares two infrastructure models, such as those of a description before and
+// after an edit. Resources are matched by type and name.
+type ModelDiff struct {
+	// Settings are the region, environment and tags of the models that differ
+	Settings  []PropertyDiff `json:"settings,omitempty"`
+	Resources []ResourceDiff `json:"resources"`
+}
+
+// DiffModels compares two infrastructure models. Resources are listed in the order of the
+// second model, followed by those only the first one has. The values of secrets, such as the
+// password of a database, are compared but not reported.
+func DiffModels(before, after *models.InfrastructureModel) *ModelDiff {
+	diff := &ModelDiff{
+		Settings:  diffProperties(modelSettings(before), modelSettings(after)),
+		Resources: []ResourceDiff{},
+	}
+
+	key := func(resource models.Resource) string {
+		return string(resource.Type) + "/" + resource.Name
+	}
+	previous := make(map[string]models.Resource, len(before.Resources))
+	for _, resource := range before.Resources {
+		previous[key(resource)] = resource
+	}
+	current := make(map[string]bool, len(after.Resources))
+	for _, resource := range after.Resources {
+		current[key(resource)] = true
+		old, ok := previous[key(resource)]
+		switch {
+		case !ok:
+			diff.Resources = append(diff.Resources, ResourceDiff{
+				Type:       resource.Type,
+				Name:       resource.Name,
+				Change:     ModelAdded,
+				Properties: diffProperties(nil, resourceProperties(resource)),
+			})
+		default:
+			if properties := diffProperties(resourceProperties(old), resourceProperties(resource)); len(properties) > 0 {
+				diff.Resources = append(diff.Resources, ResourceDiff{
+					Type:       resource.Type,
+					Name:       resource.Name,
+					Change:     ModelChanged,
+					Properties: properties,
+				})
+			}
+		}
+	}
+	for _, resource := range before.Resources {
+		if !current[key(resource)] {
+			diff.Resources = append(diff.Resources, ResourceDiff{
+				Type:       resource.Type,
+				Name:       resource.Name,
+				Change:     ModelRemoved,
+				Properties: diffProperties(resourceProperties(resource), nil),
+			})
+		}
+	}
+	return diff
+}
+
+// modelSettings returns the region, environment and tags of a model as properties
+func modelSettings(model *models.InfrastructureModel) []models.Property {
+	var settings []models.Property
+	if model.Region != "" {
+		settings = append(settings, models.Property{Name: "region", Value: model.Region})
+	}
+	if model.Environment != "" {
+		settings = append(settings, models.Property{Name: "environment", Value: model.Environment})
+	}
+	keys := make([]string, 0, len(model.Tags))
+	for key := range model.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		settings = append(settings, models.Property{Name: "tags." + key, Value: model.Tags[key]})
+	}
+	return settings
+}
+
+// resourceProperties returns the properties of a resource, followed by its explicit
+// dependencies, sorted, as a property of their own. The keys of map values are properties
+// of their own, such as scaling_config.desired_size, so a change names the key that changed.
+func resourceProperties(resource models.Resource) []models.Property {
+	var properties []models.Property
+	for _, property := range resource.Properties {
+		properties = append(properties, flattenProperty(property.Name, property.Value)...)
+	}
+	if len(resource.DependsOn) > 0 {
+		dependsOn := append([]string{}, resource.DependsOn...)
+		sort.Strings(dependsOn)
+		properties = append(properties, models.Property{Name: dependsOnProperty, Value: dependsOn})
+	}
+	return properties
+}
+
+// flattenProperty returns a property, or the properties of the keys of a map value, at any
+// depth
+func flattenProperty(name string, value interface{}) []models.Property {
+	values, ok := value.(map[string]interface{})
+	if !ok || len(values) == 0 {
+		return []models.Property{{Name: name, Value: value}}
+	}
+	var properties []models.Property
+	for _, key := range utils.SortedKeys(values) {
+		properties = append(properties, flattenProperty(name+"."+key, values[key])...)
+	}
+	return properties
+}
+
+// redactPath returns the value of a property, or of a key of a map value, without its
+// secrets. The value of a key of a secret is a secret too.
+func redactPath(name string, value interface{}) interface{} {
+	segments := strings.Split(name, ".")
+	for _, segment := range segments[:len(segments)-1] {
+		if sensitive.Name(segment) {
+			return sensitive.Redacted
+		}
+	}
+	return redactValue(segments[len(segments)-1], value)
+}
+
+// diffProperties compares two lists of properties by name. Properties are listed in the order
+// of the second list, followed by those only the first one has.
+func diffProperties(before, after []models.Property) []PropertyDiff {
+	previous := make(map[string]interface{}, len(before))
+	for _, property := range before {
+		previous[property.Name] = property.Value
+	}
+	current := make(map[string]bool, len(after))
+
+	var diffs []PropertyDiff
+	for _, property := range after {
+		current[property.Name] = true
+		old, ok := previous[property.Name]
+		switch {
+		case !ok:
+			diffs = append(diffs, PropertyDiff{Name: property.Name, Change: ModelAdded, After: redactPath(property.Name, property.Value)})
+		case !sameValue(old, property.Value):
+			diffs = append(diffs, PropertyDiff{
+				Name:   property.Name,
+				Change: ModelChanged,
+				Before: redactPath(property.Name, old),
+				After:  redactPath(property.Name, property.Value),
+			})
+		}
+	}
+	for _, property := range before {
+		if !current[property.Name] {
+			diffs = append(diffs, PropertyDiff{Name: property.Name, Change: ModelRemoved, Before: redactPath(property.Name, property.Value)})
+		}
+	}
+	return diffs
+}
+
+// sameValue reports whether two property values are the same. They are compared as JSON, so
+// a model loaded from a spec matches one built from a description, whose numbers and lists
+// may have other Go types.
+func sameValue(a, b interface{}) bool {
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+	if aErr != nil || bErr != nil {
+		return fmt.Sprint(a) == fmt.Sprint(b)
+	}
+	return bytes.Equal(aJSON, bJSON)
+}
+
+// Counts returns the number of added, removed and changed resources
+func (d *ModelDiff) Counts() (added int, removed int, changed int) {
+	for _, resource := range d.Resources {
+		switch resource.Change {
+		case ModelAdded:
+			added++
+		case ModelRemoved:
+			removed++
+		case ModelChanged:
+			changed++
+		}
+	}
+	return added, removed, changed
+}
+
+// HasChanges reports whether the models differ
+func (d *ModelDiff) HasChanges() bool {
+	return len(d.Settings) > 0 || len(d.Resources) > 0
+}
+
+// JSON returns the diff as indented JSON
+func (d *ModelDiff) JSON() (string, error) {
+	content, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal model diff: %w", err)
+	}
+	return string(content), nil
+}
+
+// Text returns the changed settings and resources, each marked + when added, - when removed
+// and ~ when changed, followed by a summary, with ANSI colors if color is set
+func (d *ModelDiff) Text(color bool) string {
+	var buf bytes.Buffer
+	for _, setting := range d.Settings {
+		writePropertyDiff(&buf, "", setting, color)
+	}
+	if len(d.Settings) > 0 && len(d.Resources) > 0 {
+		buf.WriteString("\n")
+	}
+	for _, resource := range d.Resources {
+		buf.WriteString(paint(color, changeColor(resource.Change), fmt.Sprintf("%s %s %s", changeMark(resource.Change), resource.Type, resource.Name)) + "\n")
+		for _, property := range resource.Properties {
+			writePropertyDiff(&buf, "    ", property, color)
+		}
+	}
+
+	if !d.HasChanges() {
+		buf.WriteString("No changes: the models are the same\n")
+		return buf.String()
+	}
+	added, removed, changed := d.Counts()
+	buf.WriteString("\n")
+	buf.WriteString(fmt.Sprintf("%d resources would change: %d added, %d removed, %d changed", added+removed+changed, added, removed, changed))
+	if len(d.Settings) > 0 {
+		buf.WriteString(fmt.Sprintf("; %d settings changed", len(d.Settings)))
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+// writePropertyDiff writes the line of a changed property or setting
+func writePropertyDiff(buf *bytes.Buffer, indent string, property PropertyDiff, color bool) {
+	var line string
+	switch property.Change {
+	case ModelAdded:
+		line = fmt.Sprintf("+ %s: %s", property.Name, formatValue(property.After))
+	case ModelRemoved:
+		line = fmt.Sprintf("- %s: %s", property.Name, formatValue(property.Before))
+	default:
+		line = fmt.Sprintf("~ %s: %s -> %s", property.Name, formatValue(property.Before), formatValue(property.After))
+	}
+	buf.WriteString(indent + paint(color, changeColor(property.Change), line) + "\n")
+}
+
+// changeMark returns the mark of a change in the text of a diff
+func changeMark(change ModelChange) string {
+	switch change {
+	case ModelAdded:
+		return "+"
+	case ModelRemoved:
+		return "-"
+	}
+	return "~"
+}
+
+// changeColor returns the ANSI color of a change
+func changeColor(change ModelChange) string {
+	switch change {
+	case ModelAdded:
+		return colorGreen
+	case ModelRemoved:
+		return colorRed
+	}
+	return colorCyan
+}
@@ -0,0 +1,679 @@
+package report
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// hoursPerMonth is the number of hours a month is priced for, as AWS and infracost do
+const hoursPerMonth = 730
+
+// spotDiscount is the share of the on-demand price spot capacity is estimated at
+const spotDiscount = 0.3
+
+// maxModuleDepth bounds the nesting of local modules followed by the built-in estimator
+const maxModuleDepth = 10
+
+// Hourly on-demand prices of EC2 instance types in us-east-1
+var instancePrices = map[string]float64{
+	"t3.nano": 0.0052, "t3.micro": 0.0104, "t3.small": 0.0208, "t3.medium": 0.0416,
+	"t3.large": 0.0832, "t3.xlarge": 0.1664, "t3.2xlarge": 0.3328,
+	"t3a.micro": 0.0094, "t3a.small": 0.0188, "t3a.medium": 0.0376, "t3a.large": 0.0752, "t3a.xlarge": 0.1504,
+	"t4g.micro": 0.0084, "t4g.small": 0.0168, "t4g.medium": 0.0336, "t4g.large": 0.0672, "t4g.xlarge": 0.1344,
+	"m5.large": 0.096, "m5.xlarge": 0.192, "m5.2xlarge": 0.384, "m5.4xlarge": 0.768,
+	"m6i.large": 0.096, "m6i.xlarge": 0.192, "m6i.2xlarge": 0.384, "m6i.4xlarge": 0.768,
+	"m6g.large": 0.077, "m6g.xlarge": 0.154, "m6g.2xlarge": 0.308,
+	"m7i.large": 0.1008, "m7i.xlarge": 0.2016, "m7g.large": 0.0816, "m7g.xlarge": 0.1632,
+	"c5.large": 0.085, "c5.xlarge": 0.17, "c5.2xlarge": 0.34, "c5.4xlarge": 0.68,
+	"c6i.large": 0.085, "c6i.xlarge": 0.17, "c6i.2xlarge": 0.34,
+	"c6g.large": 0.068, "c6g.xlarge": 0.136, "c7g.large": 0.0725, "c7g.xlarge": 0.145,
+	"r5.large": 0.126, "r5.xlarge": 0.252, "r5.2xlarge": 0.504,
+	"r6i.large": 0.126, "r6i.xlarge": 0.252, "r6g.large": 0.1008, "r6g.xlarge": 0.2016,
+	"g4dn.xlarge": 0.526, "g5.xlarge": 1.006, "p3.2xlarge": 3.06,
+}
+
+// Hourly on-demand prices of single-AZ RDS instance classes in us-east-1
+var dbInstancePrices = map[string]float64{
+	"db.t3.micro": 0.017, "db.t3.small": 0.034, "db.t3.medium": 0.068, "db.t3.large": 0.136,
+	"db.t4g.micro": 0.016, "db.t4g.small": 0.032, "db.t4g.medium": 0.065, "db.t4g.large": 0.129,
+	"db.m5.large": 0.171, "db.m5.xlarge": 0.342, "db.m5.2xlarge": 0.684,
+	"db.m6g.large": 0.152, "db.m6g.xlarge": 0.304, "db.m6i.large": 0.171, "db.m6i.xlarge": 0.342,
+	"db.r5.large": 0.24, "db.r5.xlarge": 0.48, "db.r6g.large": 0.215, "db.r6g.xlarge": 0.43,
+}
+
+// Monthly prices of a GB of EBS volume types in us-east-1
+var volumePrices = map[string]float64{
+	"gp2": 0.10, "gp3": 0.08, "io1": 0.125, "io2": 0.125, "st1": 0.045, "sc1": 0.015, "standard": 0.05,
+}
+
+// Monthly price of a GB of RDS general purpose storage in us-east-1
+const dbStoragePrice = 0.115
+
+// resourcePricing estimates the monthly cost of the resources of a type, returning nil for a
+// cost that depends on usage or has no built-in price, with what it is made of
+var resourcePricing = map[string]func(resource *resourceInstance) (*float64, string){
+	"aws_instance":              priceInstance,
+	"aws_autoscaling_group":     priceAutoScalingGroup,
+	"aws_eks_cluster":           hourly(0.10, "EKS control plane"),
+	"aws_eks_node_group":        priceNodeGroup,
+	"aws_eks_fargate_profile":   usageBased("Fargate pods, by their vCPU and memory"),
+	"aws_nat_gateway":           hourly(0.045, "NAT gateway, plus $0.045 per GB processed"),
+	"aws_eip":                   hourly(0.005, "public IPv4 address"),
+	"aws_lb":                    hourly(0.0225, "load balancer, plus its capacity units"),
+	"aws_db_instance":           priceDBInstance,
+	"aws_kms_key":               monthly(1, "KMS key"),
+	"aws_secretsmanager_secret": monthly(0.40, "secret"),
+	"aws_s3_bucket":             usageBased("storage and requests"),
+	"aws_dynamodb_table":        priceDynamoDBTable,
+	"aws_lambda_function":       usageBased("requests and duration"),
+	"aws_sqs_queue":             usageBased("requests"),
+	"aws_cloudwatch_log_group":  usageBased("ingested and stored logs"),
+}
+
+// hourly prices a resource billed by the hour
+func hourly(price float64, detail string) func(*resourceInstance) (*float64, string) {
+	return func(*resourceInstance) (*float64, string) {
+		cost := price * hoursPerMonth
+		return &cost, detail
+	}
+}
+
+// monthly prices a resource billed by the month
+func monthly(price float64, detail string) func(*resourceInstance) (*float64, string) {
+	return func(*resourceInstance) (*float64, string) {
+		cost := price
+		return &cost, detail
+	}
+}
+
+// usageBased leaves the cost of a resource billed by its usage out of the estimate
+func usageBased(detail string) func(*resourceInstance) (*float64, string) {
+	return func(*resourceInstance) (*float64, string) {
+		return nil, "usage-based: " + detail
+	}
+}
+
+// priceInstance prices an EC2 instance and its root volume
+func priceInstance(resource *resourceInstance) (*float64, string) {
+	instanceType, _ := ctyString(resource.value("instance_type"))
+	price, ok := instancePrices[instanceType]
+	if !ok {
+		return nil, noPrice(instanceType)
+	}
+	cost := price * hoursPerMonth
+	detail := instanceType
+	if size, ok := ctyNumber(resource.value("root_block_device", "volume_size")); ok {
+		volumeType, _ := ctyString(resource.value("root_block_device", "volume_type"))
+		if volumeType == "" {
+			volumeType = "gp3"
+		}
+		cost += size * volumePrices[volumeType]
+		detail += fmt.Sprintf(", %g GB of %s", size, volumeType)
+	}
+	return &cost, detail
+}
+
+// priceAutoScalingGroup prices the desired capacity of an auto scaling group, of the
+// instance type of its launch template
+func priceAutoScalingGroup(resource *resourceInstance) (*float64, string) {
+	desired, ok := ctyNumber(resource.value("desired_capacity"))
+	if !ok {
+		desired, _ = ctyNumber(resource.value("min_size"))
+	}
+	instanceType := resource.launchTemplateInstanceType()
+	price, ok := instancePrices[instanceType]
+	if !ok {
+		return nil, noPrice(instanceType)
+	}
+	cost := desired * price * hoursPerMonth
+	return &cost, fmt.Sprintf("%g x %s", desired, instanceType)
+}
+
+// priceNodeGroup prices the desired size of an EKS node group, of its first instance type,
+// with the disks of its nodes
+func priceNodeGroup(resource *resourceInstance) (*float64, string) {
+	desired, _ := ctyNumber(resource.value("scaling_config", "desired_size"))
+	instanceTypes := ctyStrings(resource.value("instance_types"))
+	instanceType := "t3.medium"
+	if len(instanceTypes) > 0 {
+		instanceType = instanceTypes[0]
+	}
+	price, ok := instancePrices[instanceType]
+	if !ok {
+		return nil, noPrice(instanceType)
+	}
+
+	capacity := "on demand"
+	if capacityType, _ := ctyString(resource.value("capacity_type")); capacityType == "SPOT" {
+		price *= spotDiscount
+		capacity = fmt.Sprintf("spot, at %.0f%% of on demand", spotDiscount*100)
+	}
+	diskSize, ok := ctyNumber(resource.value("disk_size"))
+	if !ok {
+		diskSize = 20
+	}
+	cost := desired * (price*hoursPerMonth + diskSize*volumePrices["gp2"])
+	return &cost, fmt.Sprintf("%g x %s %s, %g GB disks", desired, instanceType, capacity, diskSize)
+}
+
+// priceDBInstance prices an RDS instance and its storage, doubled with a Multi-AZ standby
+func priceDBInstance(resource *resourceInstance) (*float64, string) {
+	class, _ := ctyString(resource.value("instance_class"))
+	price, ok := dbInstancePrices[class]
+	if !ok {
+		return nil, noPrice(class)
+	}
+	storage, _ := ctyNumber(resource.value("allocated_storage"))
+	cost := price*hoursPerMonth + storage*dbStoragePrice
+	detail := fmt.Sprintf("%s, %g GB of storage", class, storage)
+	if multiAZ, _ := ctyBool(resource.value("multi_az")); multiAZ {
+		cost *= 2
+		detail += ", Multi-AZ"
+	}
+	return &cost, detail
+}
+
+// priceDynamoDBTable prices the provisioned capacity of a DynamoDB table
+func priceDynamoDBTable(resource *resourceInstance) (*float64, string) {
+	if mode, _ := ctyString(resource.value("billing_mode")); mode != "PROVISIONED" {
+		return nil, "usage-based: on-demand requests and storage"
+	}
+	reads, _ := ctyNumber(resource.value("read_capacity"))
+	writes, _ := ctyNumber(resource.value("write_capacity"))
+	cost := (reads*0.00013 + writes*0.00065) * hoursPerMonth
+	return &cost, fmt.Sprintf("%g read and %g write capacity units, plus storage", reads, writes)
+}
+
+// noPrice is the detail of a resource of a type the built-in prices do not have
+func noPrice(instanceType string) string {
+	if instanceType == "" {
+		return "no instance type set"
+	}
+	return fmt.Sprintf("no built-in price for %s; estimate with infracost", instanceType)
+}
+
+// estimateFunctions are the Terraform functions the generated files call in the expressions
+// the built-in estimator evaluates
+var estimateFunctions = map[string]function.Function{
+	"coalesce": stdlib.CoalesceFunc,
+	"concat":   stdlib.ConcatFunc,
+	"keys":     stdlib.KeysFunc,
+	"length":   stdlib.LengthFunc,
+	"lookup":   stdlib.LookupFunc,
+	"max":      stdlib.MaxFunc,
+	"merge":    stdlib.MergeFunc,
+	"min":      stdlib.MinFunc,
+	"tolist":   stdlib.MakeToFunc(cty.List(cty.DynamicPseudoType)),
+	"tomap":    stdlib.MakeToFunc(cty.Map(cty.DynamicPseudoType)),
+	"toset":    stdlib.MakeToFunc(cty.Set(cty.DynamicPseudoType)),
+	"values":   stdlib.ValuesFunc,
+}
+
+// terraformModule is the part of a Terraform module the built-in estimator reads
+type terraformModule struct {
+	variables map[string]hcl.Expression
+	locals    []*hclsyntax.Attribute
+	resources []*hclsyntax.Block
+	modules   []*hclsyntax.Block
+}
+
+// resourceInstance is a resource, or one of the instances of its count or for_each, with
+// the context its expressions are evaluated in
+type resourceInstance struct {
+	address string
+	body    *hclsyntax.Body
+	ctx     *hcl.EvalContext
+	module  *terraformModule
+}
+
+// builtinEstimate estimates the monthly cost of the resources of the Terraform root modules
+// of a directory with the built-in prices, evaluating their counts and attributes with the
+// values of their variables
+func builtinEstimate(dir string) (*CostEstimate, error) {
+	roots, err := terraformRootDirs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := &CostEstimate{Dir: dir, Estimator: EstimatorBuiltin, Currency: "USD", Resources: []CostItem{}}
+	for _, root := range roots {
+		module, err := loadTerraformModule(root)
+		if err != nil {
+			return nil, err
+		}
+		variables, err := rootVariables(root, module)
+		if err != nil {
+			return nil, err
+		}
+		project, err := filepath.Rel(dir, root)
+		if err != nil {
+			return nil, err
+		}
+		items, err := estimateModule(root, module, variables, "", 0)
+		if err != nil {
+			return nil, err
+		}
+		for i := range items {
+			items[i].Project = filepath.ToSlash(project)
+		}
+		estimate.Resources = append(estimate.Resources, items...)
+	}
+	return estimate, nil
+}
+
+// terraformRootDirs returns the directories with .tf files under dir that are not local
+// modules of another, leaving out hidden directories such as .terraform
+func terraformRootDirs(dir string) ([]string, error) {
+	var dirs []string
+	seen := make(map[string]bool)
+	isModule := make(map[string]bool)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && path != dir && strings.HasPrefix(entry.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".tf" {
+			return nil
+		}
+		moduleDir := filepath.Dir(path)
+		if seen[moduleDir] {
+			return nil
+		}
+		seen[moduleDir] = true
+		dirs = append(dirs, moduleDir)
+		module, err := loadTerraformModule(moduleDir)
+		if err != nil {
+			return err
+		}
+		for _, call := range module.modules {
+			if source, ok := localModuleSource(call); ok {
+				isModule[filepath.Join(moduleDir, source)] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Terraform files: %w", err)
+	}
+
+	var roots []string
+	for _, moduleDir := range dirs {
+		if !isModule[filepath.Clean(moduleDir)] {
+			roots = append(roots, moduleDir)
+		}
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("no Terraform files found in %s", dir)
+	}
+	sort.Strings(roots)
+	return roots, nil
+}
+
+// loadTerraformModule parses the .tf files of a module directory
+func loadTerraformModule(dir string) (*terraformModule, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	module := &terraformModule{variables: make(map[string]hcl.Expression)}
+	parser := hclparse.NewParser()
+	for _, path := range paths {
+		file, diags := parser.ParseHCLFile(path)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("failed to parse %s: %s", path, diags.Error())
+		}
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+		for _, block := range body.Blocks {
+			switch {
+			case block.Type == "variable" && len(block.Labels) == 1:
+				if value, ok := block.Body.Attributes["default"]; ok {
+					module.variables[block.Labels[0]] = value.Expr
+				} else {
+					module.variables[block.Labels[0]] = nil
+				}
+			case block.Type == "locals":
+				for _, attribute := range sortedAttributes(block.Body) {
+					module.locals = append(module.locals, attribute)
+				}
+			case block.Type == "resource" && len(block.Labels) == 2:
+				module.resources = append(module.resources, block)
+			case block.Type == "module" && len(block.Labels) == 1:
+				module.modules = append(module.modules, block)
+			}
+		}
+	}
+	return module, nil
+}
+
+// rootVariables returns the values of the variables of a root module: their defaults,
+// overridden by terraform.tfvars and the .auto.tfvars files
+func rootVariables(dir string, module *terraformModule) (map[string]cty.Value, error) {
+	variables := make(map[string]cty.Value)
+	for name, expr := range module.variables {
+		variables[name] = evaluate(expr, nil)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.auto.tfvars"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	if _, err := os.Stat(filepath.Join(dir, "terraform.tfvars")); err == nil {
+		paths = append([]string{filepath.Join(dir, "terraform.tfvars")}, paths...)
+	}
+	parser := hclparse.NewParser()
+	for _, path := range paths {
+		file, diags := parser.ParseHCLFile(path)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("failed to parse %s: %s", path, diags.Error())
+		}
+		attributes, diags := file.Body.JustAttributes()
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("failed to read %s: %s", path, diags.Error())
+		}
+		for name, attribute := range attributes {
+			variables[name] = evaluate(attribute.Expr, nil)
+		}
+	}
+	return variables, nil
+}
+
+// estimateModule estimates the monthly cost of the priced resources of a module, and of its
+// local modules, with the values of its variables. Address is the address of the module,
+// such as module.vpc, empty for a root module.
+func estimateModule(dir string, module *terraformModule, variables map[string]cty.Value, address string, depth int) ([]CostItem, error) {
+	ctx := moduleContext(module, variables)
+
+	var items []CostItem
+	for _, block := range module.resources {
+		price, ok := resourcePricing[block.Labels[0]]
+		if !ok {
+			continue
+		}
+		for _, resource := range expandResource(block, ctx, module) {
+			cost, detail := price(resource)
+			items = append(items, CostItem{Module: address, Resource: resource.address, MonthlyCost: cost, Detail: detail})
+		}
+	}
+
+	for _, call := range module.modules {
+		name := "module." + call.Labels[0]
+		if address != "" {
+			name = address + "." + name
+		}
+		source, ok := localModuleSource(call)
+		if !ok || depth >= maxModuleDepth {
+			source, _ := ctyString(evaluate(call.Body.Attributes["source"].Expr, nil))
+			items = append(items, CostItem{Module: name, Resource: source, Detail: "not a local module; estimate it with infracost"})
+			continue
+		}
+
+		childDir := filepath.Join(dir, source)
+		child, err := loadTerraformModule(childDir)
+		if err != nil {
+			return nil, err
+		}
+		inputs := make(map[string]cty.Value)
+		for variable, expr := range child.variables {
+			inputs[variable] = evaluate(expr, nil)
+		}
+		for _, attribute := range sortedAttributes(call.Body) {
+			switch attribute.Name {
+			case "source", "version", "providers", "count", "for_each", "depends_on":
+				continue
+			}
+			inputs[attribute.Name] = evaluate(attribute.Expr, ctx)
+		}
+		childItems, err := estimateModule(childDir, child, inputs, name, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, childItems...)
+	}
+	return items, nil
+}
+
+// moduleContext returns the context the expressions of a module are evaluated in, with its
+// variables and its locals
+func moduleContext(module *terraformModule, variables map[string]cty.Value) *hcl.EvalContext {
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{"var": objectValue(variables)},
+		Functions: estimateFunctions,
+	}
+	locals := make(map[string]cty.Value)
+	for _, attribute := range module.locals {
+		locals[attribute.Name] = evaluate(attribute.Expr, ctx)
+		ctx.Variables["local"] = objectValue(locals)
+	}
+	return ctx
+}
+
+// expandResource returns the instances of a resource, one for each of its count or
+// for_each, or the resource itself. An unknown count or for_each is taken as one instance.
+func expandResource(block *hclsyntax.Block, ctx *hcl.EvalContext, module *terraformModule) []*resourceInstance {
+	address := block.Labels[0] + "." + block.Labels[1]
+	instance := func(address string, variables map[string]cty.Value) *resourceInstance {
+		child := ctx.NewChild()
+		child.Variables = variables
+		return &resourceInstance{address: address, body: block.Body, ctx: child, module: module}
+	}
+
+	if attribute, ok := block.Body.Attributes["count"]; ok {
+		count, ok := ctyNumber(evaluate(attribute.Expr, ctx))
+		if !ok {
+			count = 1
+		}
+		var instances []*resourceInstance
+		for i := 0; i < int(count); i++ {
+			instances = append(instances, instance(fmt.Sprintf("%s[%d]", address, i), map[string]cty.Value{
+				"count": cty.ObjectVal(map[string]cty.Value{"index": cty.NumberIntVal(int64(i))}),
+			}))
+		}
+		return instances
+	}
+
+	if attribute, ok := block.Body.Attributes["for_each"]; ok {
+		forEach := evaluate(attribute.Expr, ctx)
+		if forEach == cty.NilVal || !forEach.IsWhollyKnown() || forEach.IsNull() || !forEach.CanIterateElements() {
+			return []*resourceInstance{instance(address, nil)}
+		}
+		var instances []*resourceInstance
+		for it := forEach.ElementIterator(); it.Next(); {
+			key, value := it.Element()
+			if forEach.Type().IsSetType() {
+				key = value
+			}
+			name, _ := ctyString(key)
+			instances = append(instances, instance(fmt.Sprintf("%s[%q]", address, name), map[string]cty.Value{
+				"each": cty.ObjectVal(map[string]cty.Value{"key": cty.StringVal(name), "value": value}),
+			}))
+		}
+		return instances
+	}
+
+	return []*resourceInstance{instance(address, nil)}
+}
+
+// value evaluates an attribute of the resource, or of a nested block such as
+// scaling_config, returning cty.NilVal when it is not set or cannot be evaluated
+func (r *resourceInstance) value(names ...string) cty.Value {
+	body := r.body
+	for _, name := range names[:len(names)-1] {
+		var nested *hclsyntax.Body
+		for _, block := range body.Blocks {
+			if block.Type == name {
+				nested = block.Body
+				break
+			}
+		}
+		if nested == nil {
+			return cty.NilVal
+		}
+		body = nested
+	}
+	attribute, ok := body.Attributes[names[len(names)-1]]
+	if !ok {
+		return cty.NilVal
+	}
+	return evaluate(attribute.Expr, r.ctx)
+}
+
+// launchTemplateInstanceType returns the instance type of the launch template of the
+// launch_template block of an auto scaling group, when it is a resource of the same module
+func (r *resourceInstance) launchTemplateInstanceType() string {
+	for _, block := range r.body.Blocks {
+		if block.Type != "launch_template" {
+			continue
+		}
+		for _, name := range []string{"id", "name"} {
+			attribute, ok := block.Body.Attributes[name]
+			if !ok {
+				continue
+			}
+			traversal, diags := hcl.AbsTraversalForExpr(attribute.Expr)
+			if diags.HasErrors() || len(traversal) < 2 || traversal.RootName() != "aws_launch_template" {
+				continue
+			}
+			step, ok := traversal[1].(hcl.TraverseAttr)
+			if !ok {
+				continue
+			}
+			for _, resource := range r.module.resources {
+				if resource.Labels[0] == "aws_launch_template" && resource.Labels[1] == step.Name {
+					template := &resourceInstance{body: resource.Body, ctx: r.ctx, module: r.module}
+					instanceType, _ := ctyString(template.value("instance_type"))
+					return instanceType
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// evaluate evaluates an expression, returning cty.NilVal when it cannot be evaluated, such
+// as an expression referring to another resource or to a module output
+func evaluate(expr hcl.Expression, ctx *hcl.EvalContext) cty.Value {
+	if expr == nil {
+		return cty.NilVal
+	}
+	value, diags := expr.Value(ctx)
+	if diags.HasErrors() {
+		return cty.NilVal
+	}
+	return value
+}
+
+// objectValue returns the object of a map of values, with cty.DynamicVal for those that
+// could not be evaluated
+func objectValue(values map[string]cty.Value) cty.Value {
+	attributes := make(map[string]cty.Value, len(values))
+	for name, value := range values {
+		if value == cty.NilVal {
+			value = cty.DynamicVal
+		}
+		attributes[name] = value
+	}
+	return cty.ObjectVal(attributes)
+}
+
+// sortedAttributes returns the attributes of a body in the order they are written
+func sortedAttributes(body *hclsyntax.Body) []*hclsyntax.Attribute {
+	attributes := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attribute := range body.Attributes {
+		attributes = append(attributes, attribute)
+	}
+	sort.Slice(attributes, func(i, j int) bool {
+		return attributes[i].SrcRange.Start.Byte < attributes[j].SrcRange.Start.Byte
+	})
+	return attributes
+}
+
+// localModuleSource returns the source of a module call when it is a local directory
+func localModuleSource(call *hclsyntax.Block) (string, bool) {
+	attribute, ok := call.Body.Attributes["source"]
+	if !ok {
+		return "", false
+	}
+	source, ok := ctyString(evaluate(attribute.Expr, nil))
+	if !ok || !(strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")) {
+		return "", false
+	}
+	return source, true
+}
+
+// ctyKnown reports whether a value is set and known
+func ctyKnown(value cty.Value) bool {
+	return value != cty.NilVal && value.IsWhollyKnown() && !value.IsNull()
+}
+
+// ctyString converts a value to a string
+func ctyString(value cty.Value) (string, bool) {
+	if !ctyKnown(value) {
+		return "", false
+	}
+	converted, err := convert.Convert(value, cty.String)
+	if err != nil {
+		return "", false
+	}
+	return converted.AsString(), true
+}
+
+// ctyNumber converts a value to a number
+func ctyNumber(value cty.Value) (float64, bool) {
+	if !ctyKnown(value) {
+		return 0, false
+	}
+	converted, err := convert.Convert(value, cty.Number)
+	if err != nil {
+		return 0, false
+	}
+	number, _ := converted.AsBigFloat().Float64()
+	return number, true
+}
+
+// ctyBool converts a value to a bool
+func ctyBool(value cty.Value) (bool, bool) {
+	if !ctyKnown(value) {
+		return false, false
+	}
+	converted, err := convert.Convert(value, cty.Bool)
+	if err != nil {
+		return false, false
+	}
+	return converted.True(), true
+}
+
+// ctyStrings converts a list, tuple or set value to strings, leaving out the elements that
+// are not
+func ctyStrings(value cty.Value) []string {
+	if !ctyKnown(value) || !value.CanIterateElements() {
+		return nil
+	}
+	var values []string
+	for it := value.ElementIterator(); it.Next(); {
+		_, element := it.Element()
+		if s, ok := ctyString(element); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
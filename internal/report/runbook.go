@@ -0,0 +1,110 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// FailoverRunbookFile is the name of the failover runbook written next to a DR variant
+const FailoverRunbookFile = "FAILOVER.md"
+
+// FailoverRunbook generates a Markdown runbook stub for failing over from the
+// primary region to the disaster-recovery variant and back again.
+// drDir is the directory (relative to the output directory) holding the DR manifests.
+func FailoverRunbook(variant *models.InfrastructureModel, format, primaryRegion, drRegion, drDir string) string {
+	var buf bytes.Buffer
+
+	clusters := resourcesOfType(variant, models.ResourceEKSCluster)
+	nodeGroups := resourcesOfType(variant, models.ResourceNodeGroup)
+	buckets := resourcesOfType(variant, models.ResourceS3Bucket)
+
+	buf.WriteString("# Failover Runbook\n\n")
+	buf.WriteString(fmt.Sprintf("Primary region: `%s`  \n", primaryRegion))
+	buf.WriteString(fmt.Sprintf("Disaster-recovery region: `%s`  \n", drRegion))
+	buf.WriteString(fmt.Sprintf("DR manifests: `%s/`\n\n", drDir))
+	buf.WriteString("> This runbook is a generated stub. Fill in owners, contact details and\n")
+	buf.WriteString("> application-specific steps before relying on it.\n\n")
+
+	// Contacts and objectives are left for the team to complete
+	buf.WriteString("## Objectives and Contacts\n\n")
+	buf.WriteString("- Recovery time objective (RTO): _TODO_\n")
+	buf.WriteString("- Recovery point objective (RPO): _TODO_\n")
+	buf.WriteString("- Incident commander: _TODO_\n\n")
+
+	buf.WriteString("## Standby Resources\n\n")
+	if len(variant.Resources) == 0 {
+		buf.WriteString("No resources were replicated to the DR region.\n\n")
+	} else {
+		buf.WriteString("| Resource | Type |\n")
+		buf.WriteString("|----------|------|\n")
+		for _, resource := range variant.Resources {
+			buf.WriteString(fmt.Sprintf("| %s | %s |\n", resource.Name, resource.Type))
+		}
+		buf.WriteString("\n")
+	}
+
+	buf.WriteString("## 1. Provision the Standby Stack\n\n")
+	buf.WriteString("Keep the DR skeleton provisioned ahead of an incident:\n\n")
+	buf.WriteString("```bash\n")
+	if format == "crossplane" {
+		buf.WriteString(fmt.Sprintf("kubectl apply -k %s/vpc\n", drDir))
+		if len(clusters) > 0 {
+			buf.WriteString(fmt.Sprintf("kubectl apply -k %s/eks\n", drDir))
+		}
+	} else {
+		buf.WriteString(fmt.Sprintf("cd %s\n", drDir))
+		buf.WriteString("terraform init\n")
+		buf.WriteString("terraform apply\n")
+	}
+	buf.WriteString("```\n\n")
+
+	buf.WriteString("## 2. Declare the Incident\n\n")
+	buf.WriteString(fmt.Sprintf("- Confirm the outage of `%s` on the AWS Health Dashboard.\n", primaryRegion))
+	buf.WriteString("- Freeze deployments to the primary region.\n\n")
+
+	buf.WriteString("## 3. Fail Over\n\n")
+	step := 1
+	for _, nodeGroup := range nodeGroups {
+		buf.WriteString(fmt.Sprintf("%d. Scale node group `%s` up to production capacity.\n", step, nodeGroup.Name))
+		step++
+	}
+	for _, cluster := range clusters {
+		// Terraform names the cluster from its variables, so read it back from the outputs
+		clusterName := cluster.Name
+		if format != "crossplane" {
+			clusterName = fmt.Sprintf("$(terraform -chdir=%s output -raw cluster_id)", drDir)
+		}
+		buf.WriteString(fmt.Sprintf("%d. Update kubeconfig: `aws eks update-kubeconfig --region %s --name %s`\n", step, drRegion, clusterName))
+		step++
+		buf.WriteString(fmt.Sprintf("%d. Deploy workloads to the DR cluster.\n", step))
+		step++
+	}
+	for _, bucket := range buckets {
+		source := bucket.Name
+		if value, ok := bucket.GetProperty("replica_of"); ok {
+			if name, ok := value.(string); ok {
+				source = name
+			}
+		}
+		buf.WriteString(fmt.Sprintf("%d. Point applications reading `%s` at replica bucket `%s`.\n", step, source, bucket.Name))
+		step++
+	}
+	buf.WriteString(fmt.Sprintf("%d. Switch DNS records to the endpoints in `%s`.\n", step, drRegion))
+	step++
+	buf.WriteString(fmt.Sprintf("%d. Verify application health checks.\n\n", step))
+
+	buf.WriteString("## 4. Fail Back\n\n")
+	buf.WriteString(fmt.Sprintf("1. Restore the primary stack in `%s` and confirm it is healthy.\n", primaryRegion))
+	if len(buckets) > 0 {
+		buf.WriteString("2. Copy objects written during the incident back to the primary buckets.\n")
+		buf.WriteString("3. Switch DNS back to the primary region.\n")
+		buf.WriteString("4. Scale the DR node groups back down.\n")
+	} else {
+		buf.WriteString("2. Switch DNS back to the primary region.\n")
+		buf.WriteString("3. Scale the DR node groups back down.\n")
+	}
+
+	return buf.String()
+}
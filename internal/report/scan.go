@@ -0,0 +1,304 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Security scanners
+const (
+	ScannerTrivy = "trivy"
+	ScannerTFSec = "tfsec"
+)
+
+// ScanSeverities are the severities of security findings, from the least to the most severe
+var ScanSeverities = []string{"LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+// summarizedSeverity is the least severe finding a scan summary lists; the others are only
+// counted
+const summarizedSeverity = "HIGH"
+
+// ScanFinding is a misconfiguration a security scanner found in a generated file
+type ScanFinding struct {
+	// Severity is one of ScanSeverities, or UNKNOWN
+	Severity string `json:"severity"`
+	// File is relative to the scanned directory
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	ID       string `json:"id"`
+	Resource string `json:"resource,omitempty"`
+	Message  string `json:"message"`
+}
+
+// ScanOptions controls how ScanDirectory scans
+type ScanOptions struct {
+	// Scanner is trivy or tfsec (default: trivy when it is in PATH, otherwise tfsec)
+	Scanner string
+	// Path is the binary of the scanner (default: the scanner from PATH)
+	Path string
+}
+
+// ScanReport lists the findings of a security scan of a generated directory
+type ScanReport struct {
+	Dir      string        `json:"dir"`
+	Scanner  string        `json:"scanner"`
+	Findings []ScanFinding `json:"findings"`
+}
+
+// ValidScanSeverity reports whether a severity, in any case, is one of ScanSeverities
+func ValidScanSeverity(severity string) bool {
+	return severityRank(severity) >= 0
+}
+
+// severityRank returns the position of a severity in ScanSeverities, or -1 for any other
+func severityRank(severity string) int {
+	for i, s := range ScanSeverities {
+		if strings.EqualFold(s, severity) {
+			return i
+		}
+	}
+	return -1
+}
+
+// FindScanner returns the scanner to scan with and its binary: the one options name, or trivy
+// when it is in PATH, otherwise tfsec
+func FindScanner(options ScanOptions) (scanner string, path string, err error) {
+	switch strings.ToLower(options.Scanner) {
+	case "":
+		for _, scanner := range []string{ScannerTrivy, ScannerTFSec} {
+			if path, err := exec.LookPath(scanner); err == nil {
+				return scanner, path, nil
+			}
+		}
+		return "", "", fmt.Errorf("neither trivy nor tfsec was found in PATH; install one of them to scan the generated files")
+	case ScannerTrivy, ScannerTFSec:
+		scanner = strings.ToLower(options.Scanner)
+	default:
+		return "", "", fmt.Errorf("unsupported scanner: %s (supported scanners: trivy, tfsec)", options.Scanner)
+	}
+	if options.Path != "" {
+		return scanner, options.Path, nil
+	}
+	if path, err = exec.LookPath(scanner); err != nil {
+		return "", "", fmt.Errorf("%s not found in PATH", scanner)
+	}
+	return scanner, path, nil
+}
+
+// ScanDirectory scans the files of a generated directory for security misconfigurations with
+// trivy config or tfsec. trivy checks the Terraform and the Kubernetes manifests, tfsec only
+// the Terraform.
+func ScanDirectory(dir string, options ScanOptions) (*ScanReport, error) {
+	scanner, path, err := FindScanner(options)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ScanReport{Dir: dir, Scanner: scanner}
+	if scanner == ScannerTrivy {
+		err = report.runTrivy(path)
+	} else {
+		err = report.runTFSec(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if rankA, rankB := severityRank(a.Severity), severityRank(b.Severity); rankA != rankB {
+			return rankA > rankB
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return report, nil
+}
+
+// runScanner runs a scanner, which may exit with an error when it finds misconfigurations,
+// and returns its output
+func runScanner(name, path string, args ...string) ([]byte, error) {
+	cmd := exec.Command(path, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if len(bytes.TrimSpace(output)) == 0 {
+		if err == nil {
+			err = fmt.Errorf("no output")
+		}
+		return nil, fmt.Errorf("%s failed: %v: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// runTrivy scans the directory with trivy config
+func (r *ScanReport) runTrivy(trivy string) error {
+	output, err := runScanner(ScannerTrivy, trivy, "config", "--format", "json", "--quiet", r.Dir)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Results []struct {
+			Target            string `json:"Target"`
+			Misconfigurations []struct {
+				ID            string `json:"ID"`
+				AVDID         string `json:"AVDID"`
+				Title         string `json:"Title"`
+				Message       string `json:"Message"`
+				Severity      string `json:"Severity"`
+				Status        string `json:"Status"`
+				CauseMetadata struct {
+					Resource  string `json:"Resource"`
+					StartLine int    `json:"StartLine"`
+				} `json:"CauseMetadata"`
+			} `json:"Misconfigurations"`
+		} `json:"Results"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return fmt.Errorf("failed to read the trivy report: %w", err)
+	}
+	for _, target := range result.Results {
+		for _, misconfiguration := range target.Misconfigurations {
+			if misconfiguration.Status != "" && misconfiguration.Status != "FAIL" {
+				continue
+			}
+			id := misconfiguration.AVDID
+			if id == "" {
+				id = misconfiguration.ID
+			}
+			message := misconfiguration.Message
+			if message == "" {
+				message = misconfiguration.Title
+			}
+			r.add(misconfiguration.Severity, target.Target, misconfiguration.CauseMetadata.StartLine, id, misconfiguration.CauseMetadata.Resource, message)
+		}
+	}
+	return nil
+}
+
+// runTFSec scans the directory with tfsec
+func (r *ScanReport) runTFSec(tfsec string) error {
+	output, err := runScanner(ScannerTFSec, tfsec, r.Dir, "--format", "json", "--no-color", "--soft-fail")
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Results []struct {
+			RuleID      string `json:"rule_id"`
+			LongID      string `json:"long_id"`
+			Description string `json:"description"`
+			Severity    string `json:"severity"`
+			// Status is 0 for a failed check
+			Status   int    `json:"status"`
+			Resource string `json:"resource"`
+			Location struct {
+				Filename  string `json:"filename"`
+				StartLine int    `json:"start_line"`
+			} `json:"location"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return fmt.Errorf("failed to read the tfsec report: %w", err)
+	}
+	for _, finding := range result.Results {
+		if finding.Status != 0 {
+			continue
+		}
+		id := finding.RuleID
+		if id == "" {
+			id = finding.LongID
+		}
+		r.add(finding.Severity, finding.Location.Filename, finding.Location.StartLine, id, finding.Resource, finding.Description)
+	}
+	return nil
+}
+
+// add records a finding, making its file relative to the scanned directory
+func (r *ScanReport) add(severity, path string, line int, id, resource, message string) {
+	if filepath.IsAbs(path) {
+		if dir, err := filepath.Abs(r.Dir); err == nil {
+			if rel, err := filepath.Rel(dir, path); err == nil {
+				path = rel
+			}
+		}
+	}
+	severity = strings.ToUpper(severity)
+	if !ValidScanSeverity(severity) {
+		severity = "UNKNOWN"
+	}
+	r.Findings = append(r.Findings, ScanFinding{
+		Severity: severity,
+		File:     filepath.ToSlash(path),
+		Line:     line,
+		ID:       id,
+		Resource: resource,
+		Message:  message,
+	})
+}
+
+// Counts returns the number of findings of each severity
+func (r *ScanReport) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, finding := range r.Findings {
+		counts[finding.Severity]++
+	}
+	return counts
+}
+
+// AtOrAbove returns the findings of a severity or a more severe one
+func (r *ScanReport) AtOrAbove(severity string) []ScanFinding {
+	rank := severityRank(severity)
+	var findings []ScanFinding
+	for _, finding := range r.Findings {
+		if severityRank(finding.Severity) >= rank {
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+// JSON returns the JSON representation of the report
+func (r *ScanReport) JSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal scan report: %w", err)
+	}
+	return string(data), nil
+}
+
+// Summary returns the HIGH and CRITICAL findings as a table, followed by the number of
+// findings of each severity
+func (r *ScanReport) Summary() string {
+	var buf bytes.Buffer
+
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for _, finding := range r.AtOrAbove(summarizedSeverity) {
+		location := finding.File
+		if finding.Line > 0 {
+			location += ":" + strconv.Itoa(finding.Line)
+		}
+		message := finding.Message
+		if finding.Resource != "" {
+			message = finding.Resource + ": " + message
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", finding.Severity, location, finding.ID, message)
+	}
+	w.Flush()
+
+	counts := r.Counts()
+	parts := make([]string, 0, len(ScanSeverities))
+	for i := len(ScanSeverities) - 1; i >= 0; i-- {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[ScanSeverities[i]], strings.ToLower(ScanSeverities[i])))
+	}
+	buf.WriteString(fmt.Sprintf("Scanned with %s: %s findings\n", r.Scanner, strings.Join(parts, ", ")))
+	return buf.String()
+}
@@ -0,0 +1,129 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// DefaultSchemaLocations are where kubeconform finds the schemas of the manifests: those of
+// the Kubernetes APIs it bundles, then the CRD schemas of the catalog maintained by the
+// community, which has those of Crossplane and of the AWS providers
+var DefaultSchemaLocations = []string{
+	"default",
+	"https://raw.githubusercontent.com/datreeio/CRDs-catalog/main/{{.Group}}/{{.ResourceKind}}_{{.ResourceAPIVersion}}.json",
+}
+
+// SchemaResult is a manifest kubeconform did not find valid against its schema
+type SchemaResult struct {
+	Filename string `json:"filename"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	// Status is statusInvalid, statusError or statusSkipped
+	Status           string `json:"status"`
+	Msg              string `json:"msg"`
+	ValidationErrors []struct {
+		Path string `json:"path"`
+		Msg  string `json:"msg"`
+	} `json:"validationErrors"`
+}
+
+// Invalid reports whether the manifest does not match its schema, as opposed to having no
+// schema to be checked against
+func (r SchemaResult) Invalid() bool {
+	return r.Status == "statusInvalid" || (r.Status == "statusError" && !r.MissingSchema())
+}
+
+// MissingSchema reports whether no schema location has the schema of the manifest's kind
+func (r SchemaResult) MissingSchema() bool {
+	return r.Status == "statusError" && strings.Contains(r.Msg, "could not find schema")
+}
+
+// Messages returns the problems of the manifest, one per field when kubeconform names them
+func (r SchemaResult) Messages() []string {
+	if len(r.ValidationErrors) == 0 {
+		return []string{fmt.Sprintf("%s %s: %s", r.Kind, r.Name, r.Msg)}
+	}
+	messages := make([]string, 0, len(r.ValidationErrors))
+	for _, validationError := range r.ValidationErrors {
+		messages = append(messages, fmt.Sprintf("%s %s: %s: %s", r.Kind, r.Name, validationError.Path, validationError.Msg))
+	}
+	return messages
+}
+
+// RunKubeconform validates manifests, files or directories, against the schemas of their
+// kinds, rejecting the fields the schemas do not define, and returns those that are not
+// valid. Kustomizations are skipped, having no schema.
+func RunKubeconform(kubeconform string, locations []string, paths ...string) ([]SchemaResult, error) {
+	if len(locations) == 0 {
+		locations = DefaultSchemaLocations
+	}
+	args := []string{"-strict", "-output", "json", "-skip", "kustomize.config.k8s.io/v1beta1/Kustomization"}
+	for _, location := range locations {
+		args = append(args, "-schema-location", location)
+	}
+	args = append(args, paths...)
+
+	cmd := exec.Command(kubeconform, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	// kubeconform exits with an error when a manifest is invalid, but still prints them
+	output, err := cmd.Output()
+	var result struct {
+		Resources []SchemaResult `json:"resources"`
+	}
+	if jsonErr := json.Unmarshal(output, &result); jsonErr != nil {
+		if err == nil {
+			err = jsonErr
+		}
+		return nil, fmt.Errorf("kubeconform failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return result.Resources, nil
+}
+
+// validateSchemas checks Crossplane manifests against the schemas of their kinds with
+// kubeconform. A kind without a schema, such as the claims of the
+// generated Compositions, is reported once as a warning.
+func (r *ValidationReport) validateSchemas(files []string, options ValidationOptions) {
+	kubeconform := options.KubeconformPath
+	if kubeconform == "" {
+		var err error
+		if kubeconform, err = exec.LookPath("kubeconform"); err != nil {
+			r.add(SeverityWarning, r.Dir, 0, "kubeconform", "kubeconform not found in PATH; the manifests were not checked against their schemas")
+			return
+		}
+	}
+
+	results, err := RunKubeconform(kubeconform, options.SchemaLocations, files...)
+	if err != nil {
+		r.add(SeverityError, r.Dir, 0, "kubeconform", err.Error())
+		return
+	}
+
+	missing := make(map[string]string)
+	for _, result := range results {
+		switch {
+		case result.Invalid():
+			for _, message := range result.Messages() {
+				r.add(SeverityError, result.Filename, 0, "kubeconform", message)
+			}
+		case result.MissingSchema():
+			kind := result.Kind + " " + result.Version
+			if _, ok := missing[kind]; !ok {
+				missing[kind] = result.Filename
+			}
+		}
+	}
+	kinds := make([]string, 0, len(missing))
+	for kind := range missing {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		r.add(SeverityWarning, missing[kind], 0, "kubeconform", fmt.Sprintf("no schema for %s; its manifests were not checked against a schema", kind))
+	}
+}
@@ -0,0 +1,156 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+// DefaultTerraformImage is the pinned terraform image hermetic validation runs in
+const DefaultTerraformImage = "hashicorp/terraform:1.9.8"
+
+// Paths of the mounts of a hermetic run in its container
+const (
+	containerWorkspace    = "/workspace"
+	containerDataDir      = "/terraform-data"
+	containerPluginCache  = "/plugin-cache"
+	containerPluginMirror = "/plugin-mirror"
+)
+
+// TerraformRunner runs terraform in a root module: the terraform installed or, for validation
+// that does not depend on it, terraform in a pinned docker image. The providers come from the
+// registry, kept in a plugin cache between runs, or only from a pre-seeded mirror, which makes
+// a run hermetic: a run in a container then has no network at all.
+type TerraformRunner struct {
+	// Path is the terraform binary, or the docker binary with Image (default: found in PATH)
+	Path string
+	// Image runs terraform in this docker image, pinned by tag or digest, such as
+	// DefaultTerraformImage or hashicorp/terraform@sha256:...
+	Image string
+	// PluginCacheDir keeps the providers terraform init downloads between runs, as
+	// TF_PLUGIN_CACHE_DIR
+	PluginCacheDir string
+	// PluginMirrorDir is a filesystem mirror of the providers, as terraform providers mirror
+	// writes it; terraform init installs them from it only, without the registry
+	PluginMirrorDir string
+}
+
+// Docker reports whether terraform runs in a docker image
+func (t TerraformRunner) Docker() bool {
+	return t.Image != ""
+}
+
+// Resolve returns the runner with the binary it runs found in PATH, or an error naming the
+// missing binary
+func (t TerraformRunner) Resolve() (TerraformRunner, error) {
+	if t.Path != "" {
+		return t, nil
+	}
+	name := "terraform"
+	if t.Docker() {
+		name = "docker"
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return t, fmt.Errorf("%s not found in PATH", name)
+	}
+	t.Path = path
+	return t, nil
+}
+
+// InitArgs returns the arguments of terraform init without a backend, installing the
+// providers from the mirror when there is one
+func (t TerraformRunner) InitArgs() []string {
+	args := []string{"init", "-backend=false", "-input=false", "-no-color"}
+	if t.PluginMirrorDir != "" {
+		mirror := t.PluginMirrorDir
+		if t.Docker() {
+			mirror = containerPluginMirror
+		}
+		args = append(args, "-plugin-dir="+mirror)
+	}
+	return args
+}
+
+// Run runs terraform with the arguments in the root module dir, found under root, keeping its
+// data in dataDir so that the provider cache is not written to the module. It returns the
+// output of terraform, or its errors when it printed nothing. In a container, root is mounted
+// so that the module can use modules beside it.
+func (t TerraformRunner) Run(root, dir, dataDir string, args ...string) ([]byte, error) {
+	var cmd *exec.Cmd
+	if t.Docker() {
+		dockerArgs, err := t.dockerArgs(root, dir, dataDir)
+		if err != nil {
+			return nil, err
+		}
+		cmd = exec.Command(t.Path, append(dockerArgs, args...)...)
+	} else {
+		cmd = exec.Command(t.Path, args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "TF_DATA_DIR="+dataDir, "TF_IN_AUTOMATION=1")
+		if t.PluginCacheDir != "" {
+			cmd.Env = append(cmd.Env, "TF_PLUGIN_CACHE_DIR="+t.PluginCacheDir)
+		}
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil && len(output) == 0 {
+		output = stderr.Bytes()
+	}
+	return output, err
+}
+
+// dockerArgs returns the arguments of docker run before those of terraform: the container is
+// removed once it exits, runs as the current user so that the lock file it writes is theirs,
+// and has no network when the providers come from a mirror
+func (t TerraformRunner) dockerArgs(root, dir, dataDir string) ([]string, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"run", "--rm",
+		"-v", root + ":" + containerWorkspace,
+		"-v", dataDir + ":" + containerDataDir,
+		"-w", filepath.ToSlash(filepath.Join(containerWorkspace, rel)),
+		"-e", "TF_DATA_DIR=" + containerDataDir,
+		"-e", "TF_IN_AUTOMATION=1",
+		"-e", "CHECKPOINT_DISABLE=1",
+		"-e", "HOME=/tmp",
+	}
+	if runtime.GOOS != "windows" {
+		args = append(args, "--user", strconv.Itoa(os.Getuid())+":"+strconv.Itoa(os.Getgid()))
+	}
+	if t.PluginCacheDir != "" {
+		cache, err := filepath.Abs(t.PluginCacheDir)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(cache, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create the plugin cache: %w", err)
+		}
+		args = append(args, "-v", cache+":"+containerPluginCache, "-e", "TF_PLUGIN_CACHE_DIR="+containerPluginCache)
+	}
+	if t.PluginMirrorDir != "" {
+		mirror, err := filepath.Abs(t.PluginMirrorDir)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-v", mirror+":"+containerPluginMirror+":ro", "--network", "none")
+	}
+	return append(args, t.Image), nil
+}
@@ -0,0 +1,15 @@
+# The ruleset generated Terraform is linted with when its directory has no .tflint.hcl of
+# its own. The rules are those of tflint's bundled Terraform plugin, so no plugin has to be
+# installed with tflint --init.
+config {
+  call_module_type = "local"
+}
+
+plugin "terraform" {
+  enabled = true
+  preset  = "recommended"
+}
+
+rule "terraform_naming_convention" {
+  enabled = true
+}
@@ -0,0 +1,536 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+	"gopkg.in/yaml.v3"
+)
+
+// FindingSeverity is how serious a validation finding is
+type FindingSeverity string
+
+// Supported finding severities. Only errors fail a validation.
+const (
+	SeverityError   FindingSeverity = "error"
+	SeverityWarning FindingSeverity = "warning"
+)
+
+// Validation formats
+const (
+	FormatTerraform  = "terraform"
+	FormatCrossplane = "crossplane"
+)
+
+// Finding is a problem found in a generated file
+type Finding struct {
+	Severity FindingSeverity `json:"severity"`
+	// File is relative to the validated directory
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	// Check names the check that found the problem, such as "hcl" or "terraform validate"
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// ValidationOptions controls what ValidateDirectory checks
+type ValidationOptions struct {
+	// Format limits validation to terraform or crossplane files; empty validates both
+	Format string
+	// SkipTerraform skips terraform init and validate, leaving the HCL syntax checks
+	SkipTerraform bool
+	// TerraformPath is the terraform binary, or the docker binary with TerraformImage
+	// (default: found in PATH)
+	TerraformPath string
+	// TerraformImage runs terraform init and validate in this pinned docker image, such as
+	// DefaultTerraformImage, instead of the terraform installed
+	TerraformImage string
+	// PluginCacheDir keeps the providers terraform init downloads between runs, and
+	// PluginMirrorDir installs them only from a filesystem mirror, without the network
+	PluginCacheDir  string
+	PluginMirrorDir string
+	// Schemas also checks the Crossplane manifests against the schemas of their kinds with
+	// kubeconform, found at SchemaLocations (default: DefaultSchemaLocations)
+	Schemas         bool
+	SchemaLocations []string
+	// KubeconformPath is the kubeconform binary (default: kubeconform from PATH)
+	KubeconformPath string
+	// Lint also lints each Terraform root module with tflint, with its .tflint.hcl or
+	// DefaultTFLintRuleset
+	Lint bool
+	// TFLintPath is the tflint binary (default: tflint from PATH)
+	TFLintPath string
+	// Checkov also checks the files with checkov, reporting the failed checks that
+	// CheckovSuppressions do not suppress
+	Checkov             bool
+	CheckovSuppressions []CheckovSuppression
+	// CheckovPath is the checkov binary (default: checkov from PATH)
+	CheckovPath string
+	// IAM also lints the IAM policies and managed policy attachments of the files for
+	// wildcards, web identity trust without conditions and broad managed policies
+	IAM bool
+}
+
+// ValidationReport lists the findings of validating a generated directory
+type ValidationReport struct {
+	Dir      string    `json:"dir"`
+	Formats  []string  `json:"formats"`
+	Files    int       `json:"files"`
+	Findings []Finding `json:"findings"`
+}
+
+// ValidateDirectory validates the Terraform and Crossplane files of a generated directory,
+// including its subdirectories. Terraform files are parsed as HCL and every root module is
+// checked with terraform validate, and with options.Lint linted with tflint; Crossplane
+// manifests are parsed as YAML and checked for the fields Crossplane needs, and with
+// options.Schemas against the schemas of their kinds. With options.Checkov, both are also
+// checked with checkov, and with options.IAM their IAM policies are linted.
+func ValidateDirectory(dir string, options ValidationOptions) (*ValidationReport, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	if options.Format != "" && options.Format != FormatTerraform && options.Format != FormatCrossplane {
+		return nil, fmt.Errorf("unsupported format: %s (supported formats: terraform, crossplane)", options.Format)
+	}
+
+	var terraformFiles, yamlFiles []string
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip provider caches and other tool directories
+		if entry.IsDir() && path != dir && strings.HasPrefix(entry.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		switch {
+		case isTerraformFile(entry.Name()):
+			terraformFiles = append(terraformFiles, path)
+		case strings.HasSuffix(entry.Name(), ".yaml"), strings.HasSuffix(entry.Name(), ".yml"):
+			yamlFiles = append(yamlFiles, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	report := &ValidationReport{Dir: dir}
+	if options.Format != FormatCrossplane && len(terraformFiles) > 0 {
+		report.Formats = append(report.Formats, FormatTerraform)
+		report.Files += len(terraformFiles)
+		report.validateTerraform(terraformFiles, options)
+	}
+	if options.Format != FormatTerraform && len(yamlFiles) > 0 {
+		report.Formats = append(report.Formats, FormatCrossplane)
+		report.Files += len(yamlFiles)
+		for _, path := range yamlFiles {
+			report.validateManifests(path)
+		}
+		if options.Schemas {
+			report.validateSchemas(yamlFiles, options)
+		}
+	}
+	if options.Checkov && len(report.Formats) > 0 {
+		report.checkPolicies(options)
+	}
+	if options.IAM {
+		var lintedTerraform, lintedYAML []string
+		if options.Format != FormatCrossplane {
+			lintedTerraform = terraformFiles
+		}
+		if options.Format != FormatTerraform {
+			lintedYAML = yamlFiles
+		}
+		report.lintIAM(lintedTerraform, lintedYAML)
+	}
+	if len(report.Formats) == 0 {
+		if options.Format != "" {
+			return nil, fmt.Errorf("no %s files found in %s", options.Format, dir)
+		}
+		return nil, fmt.Errorf("no Terraform or Crossplane files found in %s", dir)
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		if report.Findings[i].File != report.Findings[j].File {
+			return report.Findings[i].File < report.Findings[j].File
+		}
+		return report.Findings[i].Line < report.Findings[j].Line
+	})
+	return report, nil
+}
+
+// isTerraformFile reports whether a file holds Terraform configuration or variable values,
+// including the per-environment terraform.tfvars.<environment> files
+func isTerraformFile(name string) bool {
+	return strings.HasSuffix(name, ".tf") || strings.HasSuffix(name, ".tfvars") || strings.HasPrefix(name, "terraform.tfvars.")
+}
+
+// Counts returns the number of error and warning findings
+func (r *ValidationReport) Counts() (errors int, warnings int) {
+	for _, finding := range r.Findings {
+		switch finding.Severity {
+		case SeverityError:
+			errors++
+		case SeverityWarning:
+			warnings++
+		}
+	}
+	return errors, warnings
+}
+
+// HasErrors reports whether any finding fails the validation
+func (r *ValidationReport) HasErrors() bool {
+	errors, _ := r.Counts()
+	return errors > 0
+}
+
+// JSON returns the JSON representation of the report
+func (r *ValidationReport) JSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal validation report: %w", err)
+	}
+	return string(data), nil
+}
+
+// Text returns the findings as a table followed by a summary line
+func (r *ValidationReport) Text() string {
+	var buf bytes.Buffer
+
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for _, finding := range r.Findings {
+		location := finding.File
+		if finding.Line > 0 {
+			location += ":" + strconv.Itoa(finding.Line)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", finding.Severity, location, finding.Check, finding.Message)
+	}
+	w.Flush()
+
+	errors, warnings := r.Counts()
+	buf.WriteString(fmt.Sprintf("Validated %d %s files in %s: %d errors, %d warnings\n",
+		r.Files, strings.Join(r.Formats, " and "), r.Dir, errors, warnings))
+	return buf.String()
+}
+
+// add records a finding, making its file relative to the validated directory
+func (r *ValidationReport) add(severity FindingSeverity, path string, line int, check, message string) {
+	if rel, err := filepath.Rel(r.Dir, path); err == nil {
+		path = rel
+	}
+	r.Findings = append(r.Findings, Finding{
+		Severity: severity,
+		File:     filepath.ToSlash(path),
+		Line:     line,
+		Check:    check,
+		Message:  message,
+	})
+}
+
+// validateTerraform parses every Terraform file and runs terraform validate on each root
+// module, that is each directory with .tf files that is not a local module of another
+func (r *ValidationReport) validateTerraform(files []string, options ValidationOptions) {
+	parser := hclparse.NewParser()
+	var moduleDirs []string
+	invalidDirs := make(map[string]bool)
+	for _, path := range files {
+		file, diags := parser.ParseHCLFile(path)
+		for _, diag := range diags {
+			if diag.Severity != hcl.DiagError {
+				continue
+			}
+			line := 0
+			if diag.Subject != nil {
+				line = diag.Subject.Start.Line
+			}
+			r.add(SeverityError, path, line, "hcl", diagnosticMessage(diag.Summary, diag.Detail))
+		}
+		if diags.HasErrors() {
+			invalidDirs[filepath.Dir(path)] = true
+			continue
+		}
+		if strings.HasSuffix(path, ".tf") {
+			moduleDirs = append(moduleDirs, r.checkModuleSources(path, file)...)
+		}
+	}
+
+	if options.SkipTerraform && !options.Lint {
+		return
+	}
+
+	// Root modules are validated with their local modules, so those are not validated alone
+	isModule := make(map[string]bool)
+	for _, dir := range moduleDirs {
+		isModule[dir] = true
+	}
+	var roots []string
+	seen := make(map[string]bool)
+	for _, path := range files {
+		dir := filepath.Dir(path)
+		if !strings.HasSuffix(path, ".tf") || seen[dir] || isModule[filepath.Clean(dir)] || invalidDirs[dir] {
+			continue
+		}
+		seen[dir] = true
+		roots = append(roots, dir)
+	}
+	if len(roots) == 0 {
+		return
+	}
+
+	if !options.SkipTerraform {
+		r.validateRoots(roots, options)
+	}
+	if options.Lint {
+		r.lintTerraform(roots, options)
+	}
+}
+
+// validateRoots runs terraform validate on each root module
+func (r *ValidationReport) validateRoots(roots []string, options ValidationOptions) {
+	terraform, err := TerraformRunner{
+		Path:            options.TerraformPath,
+		Image:           options.TerraformImage,
+		PluginCacheDir:  options.PluginCacheDir,
+		PluginMirrorDir: options.PluginMirrorDir,
+	}.Resolve()
+	if err != nil {
+		r.add(SeverityWarning, r.Dir, 0, "terraform validate", err.Error()+"; only the HCL syntax was checked")
+		return
+	}
+	for _, dir := range roots {
+		r.runTerraformValidate(terraform, dir)
+	}
+}
+
+// checkModuleSources reports local module sources that do not exist, and returns the
+// directories of those that do
+func (r *ValidationReport) checkModuleSources(path string, file *hcl.File) []string {
+	content, _, _ := file.Body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "module", LabelNames: []string{"name"}}},
+	})
+
+	var dirs []string
+	for _, block := range content.Blocks {
+		attributes, _, _ := block.Body.PartialContent(&hcl.BodySchema{
+			Attributes: []hcl.AttributeSchema{{Name: "source"}},
+		})
+		attribute, ok := attributes.Attributes["source"]
+		if !ok {
+			r.add(SeverityError, path, block.DefRange.Start.Line, "module source", fmt.Sprintf("module %q has no source", block.Labels[0]))
+			continue
+		}
+		value, diags := attribute.Expr.Value(nil)
+		if diags.HasErrors() || !value.Type().Equals(cty.String) {
+			continue
+		}
+		source := value.AsString()
+		if !strings.HasPrefix(source, "./") && !strings.HasPrefix(source, "../") {
+			continue
+		}
+		dir := filepath.Clean(filepath.Join(filepath.Dir(path), source))
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			r.add(SeverityError, path, attribute.Range.Start.Line, "module source", fmt.Sprintf("module %q source %s does not exist", block.Labels[0], source))
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// terraformDiagnostic is a diagnostic of terraform validate -json
+type terraformDiagnostic struct {
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	Detail   string `json:"detail"`
+	Range    *struct {
+		Filename string `json:"filename"`
+		Start    struct {
+			Line int `json:"line"`
+		} `json:"start"`
+	} `json:"range"`
+}
+
+// runTerraformValidate initializes a root module without its backend and validates it. The
+// provider cache goes to a temporary directory, so the validated directory is not changed
+// apart from the dependency lock file.
+func (r *ValidationReport) runTerraformValidate(terraform TerraformRunner, dir string) {
+	dataDir, err := os.MkdirTemp("", "iacgen-validate-")
+	if err != nil {
+		r.add(SeverityError, dir, 0, "terraform init", fmt.Sprintf("failed to create a data directory: %v", err))
+		return
+	}
+	defer os.RemoveAll(dataDir)
+
+	run := func(args ...string) ([]byte, error) {
+		return terraform.Run(r.Dir, dir, dataDir, args...)
+	}
+
+	if output, err := run(terraform.InitArgs()...); err != nil {
+		r.add(SeverityError, dir, 0, "terraform init", diagnosticMessage("terraform init failed", string(output)))
+		return
+	}
+
+	// terraform validate exits with an error when the configuration is invalid, but still
+	// prints its diagnostics
+	output, err := run("validate", "-json", "-no-color")
+	var result struct {
+		Diagnostics []terraformDiagnostic `json:"diagnostics"`
+	}
+	if jsonErr := json.Unmarshal(output, &result); jsonErr != nil {
+		if err == nil {
+			err = jsonErr
+		}
+		r.add(SeverityError, dir, 0, "terraform validate", diagnosticMessage(fmt.Sprintf("terraform validate failed: %v", err), string(output)))
+		return
+	}
+	for _, diagnostic := range result.Diagnostics {
+		severity := SeverityWarning
+		if diagnostic.Severity == "error" {
+			severity = SeverityError
+		}
+		path, line := dir, 0
+		if diagnostic.Range != nil {
+			path = filepath.Join(dir, diagnostic.Range.Filename)
+			line = diagnostic.Range.Start.Line
+		}
+		r.add(severity, path, line, "terraform validate", diagnosticMessage(diagnostic.Summary, diagnostic.Detail))
+	}
+}
+
+// diagnosticMessage joins a summary and its detail on one line
+func diagnosticMessage(summary, detail string) string {
+	detail = strings.Join(strings.Fields(detail), " ")
+	if detail == "" {
+		return summary
+	}
+	return summary + ": " + detail
+}
+
+// Patterns for the Kubernetes fields every manifest needs
+var (
+	apiVersionPattern    = regexp.MustCompile(`^([a-z0-9]([a-z0-9.-]*[a-z0-9])?/)?v\d+((alpha|beta)\d+)?$`)
+	objectNamePattern    = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+	yamlErrorLinePattern = regexp.MustCompile(`line (\d+)`)
+)
+
+// maxObjectNameLength is the longest name Kubernetes accepts for an object
+const maxObjectNameLength = 253
+
+// managedResourceGroups are the API group suffixes of Crossplane's AWS managed resources
+var managedResourceGroups = []string{".aws.crossplane.io", ".aws.upbound.io"}
+
+// validateManifests parses every document of a YAML file and checks it as a Crossplane or
+// Kustomize manifest
+func (r *ValidationReport) validateManifests(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		r.add(SeverityError, path, 0, "yaml", fmt.Sprintf("failed to read file: %v", err))
+		return
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var document yaml.Node
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			line := 0
+			if match := yamlErrorLinePattern.FindStringSubmatch(err.Error()); match != nil {
+				line, _ = strconv.Atoi(match[1])
+			}
+			r.add(SeverityError, path, line, "yaml", err.Error())
+			return
+		}
+
+		var manifest map[string]interface{}
+		if err := document.Decode(&manifest); err != nil {
+			r.add(SeverityError, path, document.Line, "yaml", fmt.Sprintf("document is not a mapping: %v", err))
+			continue
+		}
+		if len(manifest) == 0 {
+			continue
+		}
+		r.checkManifest(path, document.Line, manifest)
+	}
+}
+
+// checkManifest checks the fields of one manifest
+func (r *ValidationReport) checkManifest(path string, line int, manifest map[string]interface{}) {
+	apiVersion, _ := manifest["apiVersion"].(string)
+	kind, _ := manifest["kind"].(string)
+	switch {
+	case apiVersion == "":
+		r.add(SeverityError, path, line, "crossplane", "missing apiVersion")
+	case !apiVersionPattern.MatchString(apiVersion):
+		r.add(SeverityError, path, line, "crossplane", fmt.Sprintf("invalid apiVersion %q", apiVersion))
+	}
+	if kind == "" {
+		r.add(SeverityError, path, line, "crossplane", "missing kind")
+	}
+
+	if strings.HasPrefix(apiVersion, "kustomize.config.k8s.io/") {
+		r.checkKustomization(path, line, manifest)
+		return
+	}
+
+	metadata, _ := manifest["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	switch {
+	case name == "":
+		r.add(SeverityError, path, line, "crossplane", fmt.Sprintf("%s has no metadata.name", kind))
+	case len(name) > maxObjectNameLength || !objectNamePattern.MatchString(name):
+		r.add(SeverityError, path, line, "crossplane", fmt.Sprintf("%s name %q is not a valid Kubernetes name (lower-case letters, digits, '-' and '.')", kind, name))
+	}
+
+	group, _, _ := strings.Cut(apiVersion, "/")
+	for _, suffix := range managedResourceGroups {
+		if !strings.HasSuffix(group, suffix) {
+			continue
+		}
+		spec, _ := manifest["spec"].(map[string]interface{})
+		if _, ok := spec["forProvider"].(map[string]interface{}); !ok {
+			r.add(SeverityError, path, line, "crossplane", fmt.Sprintf("%s %s has no spec.forProvider", kind, name))
+		}
+		providerConfig, _ := spec["providerConfigRef"].(map[string]interface{})
+		if configName, _ := providerConfig["name"].(string); configName == "" {
+			r.add(SeverityWarning, path, line, "crossplane", fmt.Sprintf("%s %s has no spec.providerConfigRef.name, so it uses the default ProviderConfig", kind, name))
+		}
+	}
+}
+
+// checkKustomization checks that the resources a kustomization lists exist
+func (r *ValidationReport) checkKustomization(path string, line int, manifest map[string]interface{}) {
+	resources, _ := manifest["resources"].([]interface{})
+	for _, resource := range resources {
+		name, ok := resource.(string)
+		if !ok || strings.Contains(name, "://") {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(path), name)); err != nil {
+			r.add(SeverityError, path, line, "kustomize", fmt.Sprintf("resource %s does not exist", name))
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Package retry runs operations that may fail transiently again, with exponential backoff
+// and jitter
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Policy is how an operation is retried. The zero value runs it once.
+type Policy struct {
+	// MaxAttempts is how many times the operation runs at most (below two runs it once)
+	MaxAttempts int
+	// InitialDelay is the delay before the first retry
+	InitialDelay time.Duration
+	// MaxDelay caps each delay (zero for no cap)
+	MaxDelay time.Duration
+	// Multiplier grows the delay after each retry (below one is taken as two)
+	Multiplier float64
+	// Jitter is the fraction of each delay that is randomized, from 0 to 1, so clients
+	// failing together do not retry together
+	Jitter float64
+}
+
+// DefaultPolicy returns the policy used when retries are enabled without settings: three
+// attempts, retried after about one and then two seconds
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:  3,
+		InitialDelay: time.Second,
+		MaxDelay:     30 * time.Second,
+		Multiplier:   2,
+		Jitter:       0.2,
+	}
+}
+
+// Validate checks the policy's settings
+func (p Policy) Validate() error {
+	switch {
+	case p.MaxAttempts < 0:
+		return fmt.Errorf("max_attempts must not be negative")
+	case p.InitialDelay < 0 || p.MaxDelay < 0:
+		return fmt.Errorf("delays must not be negative")
+	case p.Jitter < 0 || p.Jitter > 1:
+		return fmt.Errorf("jitter must be between 0 and 1")
+	}
+	return nil
+}
+
+// Delay returns the delay after the given failed attempt, counted from one, before jitter
+func (p Policy) Delay(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	delay := float64(p.InitialDelay) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	return time.Duration(delay)
+}
+
+// jittered randomizes the Jitter fraction of a delay
+func (p Policy) jittered(delay time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return delay
+	}
+	spread := float64(delay) * p.Jitter
+	return time.Duration(float64(delay) - spread + rand.Float64()*2*spread)
+}
+
+// transientError marks an error as transient
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+// Transient marks an error as transient, such as a network error or a rate limit, so an
+// operation failing with it is worth retrying
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err: err}
+}
+
+// IsTransient reports whether an error was marked as transient
+func IsTransient(err error) bool {
+	var transient *transientError
+	return errors.As(err, &transient)
+}
+
+// Do runs fn until it succeeds, fails with an error retryable does not accept (nil accepts
+// transient errors), the policy's attempts are used up or ctx is done. onRetry, which may be
+// nil, is called before each retry with the failed attempt and the delay before the next.
+// The error of the last attempt is returned.
+func Do(ctx context.Context, policy Policy, retryable func(error) bool, onRetry func(attempt int, delay time.Duration, err error), fn func(ctx context.Context) error) error {
+	if retryable == nil {
+		retryable = IsTransient
+	}
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if attempt >= policy.MaxAttempts || ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+
+		delay := policy.jittered(policy.Delay(attempt))
+		if onRetry != nil {
+			onRetry(attempt, delay, err)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Package sensitive knows which resource attributes carry secrets, so that the outputs
+// exposing them are marked sensitive and the values are kept out of what the CLI prints
+package sensitive
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the secrets in what the CLI prints, as Terraform shows them in plans
+const Redacted = "(sensitive value)"
+
+// attributes maps Terraform resource and data source types to their attributes carrying
+// secrets, such as the certificate authority data of an EKS cluster or the password of a
+// database
+var attributes = map[string][]string{
+	"aws_db_instance":                   {"password"},
+	"aws_docdb_cluster":                 {"master_password"},
+	"aws_eks_cluster":                   {"certificate_authority"},
+	"aws_eks_cluster_auth":              {"token"},
+	"aws_elasticache_replication_group": {"auth_token"},
+	"aws_iam_access_key":                {"secret", "ses_smtp_password_v4"},
+	"aws_iam_user_login_profile":        {"password"},
+	"aws_mq_broker":                     {"user"},
+	"aws_rds_cluster":                   {"master_password"},
+	"aws_redshift_cluster":              {"master_password"},
+	"aws_secretsmanager_secret_version": {"secret_string", "secret_binary"},
+	"aws_ssm_parameter":                 {"value"},
+	"random_password":                   {"result"},
+	"tls_private_key":                   {"private_key_pem", "private_key_openssh", "private_key_pem_pkcs8"},
+}
+
+// names are the property and argument names whose values are secrets whatever the resource,
+// such as the password of a database described in the model
+var names = []string{
+	"password", "master_password", "auth_token", "secret", "secret_key", "secret_string",
+	"secret_binary", "client_secret", "private_key", "token", "connection_string",
+}
+
+// Attribute reports whether an attribute of a Terraform resource or data source type
+// carries a secret
+func Attribute(resourceType, attribute string) bool {
+	for _, name := range attributes[resourceType] {
+		if name == attribute {
+			return true
+		}
+	}
+	return false
+}
+
+// Name reports whether a property or argument name, such as master_password, holds a secret
+func Name(name string) bool {
+	name = strings.ToLower(name)
+	for _, secret := range names {
+		if name == secret {
+			return true
+		}
+	}
+	return false
+}
+
+// assignmentPattern matches a secret assigned a literal, in HCL as password = "..." or in
+// YAML as password: ..., with the quoted or YAML value in the last group
+var assignmentPattern = regexp.MustCompile(`(?mi)\b(` + strings.Join(names, "|") + `)("?\s*)(=\s*"(?:[^"\\\n]|\\.)*"|:[ \t]+[^\s#][^\n]*)`)
+
+// Redact replaces the literal values of secrets in generated content, such as a diff of
+// Terraform files or Crossplane manifests. References, such as password = var.db_password,
+// are kept, as they are not secrets themselves.
+func Redact(content string) string {
+	return assignmentPattern.ReplaceAllStringFunc(content, func(match string) string {
+		groups := assignmentPattern.FindStringSubmatch(match)
+		if strings.HasPrefix(groups[3], "=") {
+			return groups[1] + groups[2] + `= "` + Redacted + `"`
+		}
+		return groups[1] + groups[2] + ": " + Redacted
+	})
+}
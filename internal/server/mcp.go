@@ -0,0 +1,384 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/internal/version"
+)
+
+// MCPProtocolVersion is the latest Model Context Protocol version the server implements
+const MCPProtocolVersion = "2025-03-26"
+
+// mcpProtocolVersions are the protocol versions the server can speak; the tools it uses are
+// the same in each
+var mcpProtocolVersions = []string{"2024-11-05", MCPProtocolVersion}
+
+// JSON-RPC error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+// rpcMessage is a JSON-RPC 2.0 request or notification; notifications have no ID
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse is a JSON-RPC 2.0 response
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is the error of a failed JSON-RPC request
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// mcpTool describes a tool in the response to tools/list
+type mcpTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+// mcpContent is a text block of a tool result
+type mcpContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// mcpToolResult is the result of tools/call. Failures of the tool itself are results with
+// isError set, so the assistant can read them and correct its call.
+type mcpToolResult struct {
+	Content []mcpContent `json:"content"`
+	IsError bool         `json:"isError,omitempty"`
+}
+
+// mcpGenerateArguments are the arguments of the generate tools: those of POST /generate, and
+// the directory to write the files to
+type mcpGenerateArguments struct {
+	GenerateRequest
+	OutputDir string `json:"output_dir,omitempty"`
+}
+
+// mcpParseArguments are the arguments of parse_description
+type mcpParseArguments struct {
+	Description string `json:"description"`
+	Region      string `json:"region,omitempty"`
+	Environment string `json:"environment,omitempty"`
+}
+
+// mcpValidateArguments are the arguments of validate_output
+type mcpValidateArguments struct {
+	Dir           string `json:"dir"`
+	Format        string `json:"format,omitempty"`
+	SkipTerraform bool   `json:"skip_terraform,omitempty"`
+	Schemas       bool   `json:"schemas,omitempty"`
+	Lint          bool   `json:"lint,omitempty"`
+	IAM           bool   `json:"iam,omitempty"`
+}
+
+// ServeMCP serves the generator as a Model Context Protocol server over the stdio transport:
+// newline-delimited JSON-RPC messages are read from in and answered on out, until in ends or
+// the context is canceled. Tool calls run concurrently.
+func (s *Server) ServeMCP(ctx context.Context, in io.Reader, out io.Writer) error {
+	var (
+		writeMu sync.Mutex
+		calls   sync.WaitGroup
+	)
+	respond := func(response rpcResponse) {
+		response.JSONRPC = "2.0"
+		content, err := json.Marshal(response)
+		if err != nil {
+			s.logger.Warnw("Failed to encode MCP response", "error", err)
+			return
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		out.Write(append(content, '\n'))
+	}
+	defer calls.Wait()
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), MaxRequestBytes)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var message rpcMessage
+		if err := json.Unmarshal([]byte(line), &message); err != nil {
+			respond(rpcResponse{ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: err.Error()}})
+			continue
+		}
+		// Notifications, such as notifications/initialized, need no response
+		if len(message.ID) == 0 {
+			s.logger.Debugw("MCP notification", "method", message.Method)
+			continue
+		}
+		if message.JSONRPC != "2.0" || message.Method == "" {
+			respond(rpcResponse{ID: message.ID, Error: &rpcError{Code: rpcInvalidRequest, Message: "not a JSON-RPC 2.0 request"}})
+			continue
+		}
+
+		// Generation takes a while, so tool calls do not hold up other requests
+		if message.Method == "tools/call" {
+			calls.Add(1)
+			go func() {
+				defer calls.Done()
+				respond(s.handleMCPRequest(ctx, message))
+			}()
+			continue
+		}
+		respond(s.handleMCPRequest(ctx, message))
+	}
+	return scanner.Err()
+}
+
+// handleMCPRequest answers a JSON-RPC request
+func (s *Server) handleMCPRequest(ctx context.Context, message rpcMessage) rpcResponse {
+	response := rpcResponse{ID: message.ID}
+	switch message.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(message.Params, &params)
+		s.logger.Infow("MCP client connected", "protocol_version", params.ProtocolVersion)
+
+		// Speak the client's version when it is supported, and propose the latest otherwise
+		protocolVersion := MCPProtocolVersion
+		for _, supported := range mcpProtocolVersions {
+			if params.ProtocolVersion == supported {
+				protocolVersion = supported
+			}
+		}
+		response.Result = map[string]interface{}{
+			"protocolVersion": protocolVersion,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]string{"name": "iacgen", "version": version.GetVersionInfo()},
+			"instructions":    "Generate Terraform or Crossplane for AWS VPC, subnet, gateway and EKS infrastructure from natural language descriptions. Call parse_description first to check what a description means, then generate_terraform or generate_crossplane, then validate_output on the directory the files were written to.",
+		}
+	case "ping":
+		response.Result = map[string]interface{}{}
+	case "tools/list":
+		response.Result = map[string]interface{}{"tools": mcpTools()}
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(message.Params, &params); err != nil {
+			response.Error = &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+			break
+		}
+		result, err := s.callTool(ctx, params.Name, params.Arguments)
+		if err != nil {
+			response.Error = &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+			break
+		}
+		response.Result = result
+	default:
+		response.Error = &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("method not found: %s", message.Method)}
+	}
+	return response
+}
+
+// callTool runs a tool. An unknown tool is an error; a failure of the tool is a result.
+func (s *Server) callTool(ctx context.Context, name string, arguments json.RawMessage) (*mcpToolResult, error) {
+	s.logger.Infow("MCP tool called", "tool", name)
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+
+	var (
+		text string
+		err  error
+	)
+	switch name {
+	case "parse_description":
+		text, err = s.parseDescriptionTool(ctx, arguments)
+	case "generate_terraform":
+		text, err = s.generateTool(ctx, arguments, "terraform")
+	case "generate_crossplane":
+		text, err = s.generateTool(ctx, arguments, "crossplane")
+	case "validate_output":
+		return s.validateOutputTool(arguments), nil
+	default:
+		return nil, fmt.Errorf("unknown tool: %s", name)
+	}
+	if err != nil {
+		s.logger.Warnw("MCP tool failed", "tool", name, "error", err)
+		return &mcpToolResult{Content: []mcpContent{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+	}
+	return &mcpToolResult{Content: []mcpContent{{Type: "text", Text: text}}}, nil
+}
+
+// parseDescriptionTool returns the model parsed from a description, as JSON
+func (s *Server) parseDescriptionTool(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args mcpParseArguments
+	if err := decodeArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	params, err := s.params(args.Description, args.Region, args.Environment, nil)
+	if err != nil {
+		return "", err
+	}
+	explanation, err := s.explain(ctx, params)
+	if err != nil {
+		return "", err
+	}
+	return explanation.JSON()
+}
+
+// generateTool generates the manifests of a description in a format. They are written to the
+// output directory when one is given, and returned as a JSON file map otherwise.
+func (s *Server) generateTool(ctx context.Context, arguments json.RawMessage, format string) (string, error) {
+	var args mcpGenerateArguments
+	if err := decodeArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	args.Output = format
+	params, err := s.generateParams(args.GenerateRequest)
+	if err != nil {
+		return "", err
+	}
+
+	files, err := pipeline.GenerateFiles(ctx, params)
+	if err != nil {
+		return "", err
+	}
+	if args.OutputDir == "" {
+		content, err := json.MarshalIndent(GenerateResponse{Output: format, Files: files}, "", "  ")
+		return string(content), err
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := utils.WriteToFile(filepath.Join(args.OutputDir, filepath.FromSlash(path)), files[path]); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("Wrote %d %s files to %s:\n%s", len(paths), format, args.OutputDir, strings.Join(paths, "\n")), nil
+}
+
+// validateOutputTool validates a directory of generated files, and is an error result when
+// the report has errors
+func (s *Server) validateOutputTool(arguments json.RawMessage) *mcpToolResult {
+	failed := func(err error) *mcpToolResult {
+		return &mcpToolResult{Content: []mcpContent{{Type: "text", Text: err.Error()}}, IsError: true}
+	}
+
+	var args mcpValidateArguments
+	if err := decodeArguments(arguments, &args); err != nil {
+		return failed(err)
+	}
+	if args.Dir == "" {
+		return failed(errors.New("dir is required"))
+	}
+	validation, err := report.ValidateDirectory(args.Dir, report.ValidationOptions{Format: args.Format, SkipTerraform: args.SkipTerraform, Schemas: args.Schemas, Lint: args.Lint, IAM: args.IAM})
+	if err != nil {
+		return failed(err)
+	}
+	content, err := validation.JSON()
+	if err != nil {
+		return failed(err)
+	}
+	return &mcpToolResult{Content: []mcpContent{{Type: "text", Text: content}}, IsError: validation.HasErrors()}
+}
+
+// decodeArguments decodes the arguments of a tool call, rejecting unknown ones
+func decodeArguments(arguments json.RawMessage, target interface{}) error {
+	decoder := json.NewDecoder(strings.NewReader(string(arguments)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+// mcpTools describes the tools of the server
+func mcpTools() []mcpTool {
+	descriptionProperties := map[string]interface{}{
+		"description": map[string]interface{}{"type": "string", "description": "Natural language description of the infrastructure, such as \"Create a VPC with 2 public subnets and an EKS cluster with 3 nodes\""},
+		"region":      map[string]interface{}{"type": "string", "description": "AWS region for resources, such as us-west-2"},
+		"environment": map[string]interface{}{"type": "string", "description": "Environment to generate, such as staging; required when the description covers several environments"},
+	}
+	generateProperties := map[string]interface{}{
+		"tags":              map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}, "description": "Tags applied to every resource"},
+		"dr_region":         map[string]interface{}{"type": "string", "description": "Secondary AWS region for a disaster-recovery variant, written to the dr directory"},
+		"compliance_report": map[string]interface{}{"type": "boolean", "description": "Add a CIS AWS / SOC 2 compliance matrix (compliance-report.md)"},
+		"use_templates":     map[string]interface{}{"type": "boolean", "description": "Generate with the template system"},
+		"output_dir":        map[string]interface{}{"type": "string", "description": "Directory to write the files to; without it, the files are returned"},
+	}
+	for name, property := range descriptionProperties {
+		generateProperties[name] = property
+	}
+	schema := func(properties map[string]interface{}, required ...string) map[string]interface{} {
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+
+	return []mcpTool{
+		{
+			Name:        "parse_description",
+			Description: "Parse an infrastructure description and return the resources, properties and dependencies it produces, with the defaults that were assumed, without generating anything.",
+			InputSchema: schema(descriptionProperties, "description"),
+		},
+		{
+			Name:        "generate_terraform",
+			Description: "Generate Terraform HCL, with a module per component, for an infrastructure description.",
+			InputSchema: schema(generateProperties, "description"),
+		},
+		{
+			Name:        "generate_crossplane",
+			Description: "Generate Crossplane manifests, with kustomizations, for an infrastructure description.",
+			InputSchema: schema(generateProperties, "description"),
+		},
+		{
+			Name:        "validate_output",
+			Description: "Validate a directory of generated Terraform or Crossplane files, and return the findings. Terraform is checked with terraform validate when it is installed.",
+			InputSchema: schema(map[string]interface{}{
+				"dir":            map[string]interface{}{"type": "string", "description": "Directory of generated files"},
+				"format":         map[string]interface{}{"type": "string", "enum": []string{"terraform", "crossplane"}, "description": "Validate only the files of one format"},
+				"skip_terraform": map[string]interface{}{"type": "boolean", "description": "Only check the HCL syntax, without running terraform"},
+				"schemas":        map[string]interface{}{"type": "boolean", "description": "Also check the Crossplane manifests against the schemas of their kinds with kubeconform"},
+				"lint":           map[string]interface{}{"type": "boolean", "description": "Also lint the Terraform files with tflint"},
+				"iam":            map[string]interface{}{"type": "boolean", "description": "Also lint the IAM policies for wildcards, unconditioned web identity trust and broad managed policies"},
+			}, "dir"),
+		},
+	}
+}
@@ -0,0 +1,270 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/internal/version"
+	"go.uber.org/zap"
+)
+
+// MaxRequestBytes limits the size of a request body
+const MaxRequestBytes = 1 << 20
+
+// ArchiveContentType is the Accept header value that asks /generate for a gzipped tarball
+// instead of a JSON file map
+const ArchiveContentType = "application/gzip"
+
+// Options are the settings of the server. The region, output format and templates are the
+// defaults of requests that do not set them; the entity extraction backend is the same for
+// every request.
+type Options struct {
+	Region       string
+	OutputFormat string
+	UseTemplates bool
+	// Tags are applied to every resource, under the tags of the request and the description
+	Tags map[string]string
+	// RequiredTags are the tags every generated model must have
+	RequiredTags []string
+	// Policies are the Rego policies every generated model must satisfy
+	Policies   []string
+	NLPBackend string
+	LLMConfig  nlp.BackendConfig
+}
+
+// GenerateRequest is the body of POST /generate. Only the description is required.
+type GenerateRequest struct {
+	Description      string            `json:"description"`
+	Output           string            `json:"output,omitempty"`
+	Region           string            `json:"region,omitempty"`
+	Environment      string            `json:"environment,omitempty"`
+	Tags             map[string]string `json:"tags,omitempty"`
+	DRRegion         string            `json:"dr_region,omitempty"`
+	ComplianceReport bool              `json:"compliance_report,omitempty"`
+	UseTemplates     *bool             `json:"use_templates,omitempty"`
+}
+
+// GenerateResponse is the JSON response of POST /generate: the generated files keyed by their
+// path in an output directory
+type GenerateResponse struct {
+	Output string            `json:"output"`
+	Files  map[string]string `json:"files"`
+}
+
+// errorResponse is the body of a failed request
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server serves the generator over HTTP, or to AI assistants over the Model Context Protocol
+type Server struct {
+	options Options
+	logger  *zap.SugaredLogger
+}
+
+// NewServer creates a server with the given options
+func NewServer(options Options) *Server {
+	return &Server{
+		options: options,
+		logger:  utils.GetLogger(),
+	}
+}
+
+// Handler returns the HTTP handler of the server's endpoints
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/generate", s.handleGenerate)
+	mux.HandleFunc("/model", s.handleModel)
+	mux.HandleFunc("/healthz", s.handleHealth)
+	return mux
+}
+
+// handleGenerate generates the manifests of a description, and returns them as a JSON file
+// map, or as a gzipped tarball when the client accepts application/gzip
+func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var request GenerateRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	params, err := s.generateParams(request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	files, err := pipeline.GenerateFiles(r.Context(), params)
+	if err != nil {
+		s.logger.Warnw("Generation failed", "error", err)
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	s.logger.Infow("Generated manifests", "output", params.OutputFormat, "files", len(files))
+
+	if strings.Contains(r.Header.Get("Accept"), ArchiveContentType) {
+		w.Header().Set("Content-Type", ArchiveContentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=iacgen-%s.tar.gz", strings.Join(pipeline.OutputFormats(params.OutputFormat), "-")))
+		if err := pipeline.WriteArchive(w, files, true); err != nil {
+			s.logger.Warnw("Failed to write archive", "error", err)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, GenerateResponse{Output: params.OutputFormat, Files: files})
+}
+
+// handleModel parses the description of the query and returns the resulting infrastructure
+// model and the defaults that were assumed, without generating anything
+func (s *Server) handleModel(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	query := r.URL.Query()
+	params, err := s.params(query.Get("description"), query.Get("region"), query.Get("environment"), nil)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	explanation, err := s.explain(r.Context(), params)
+	if err != nil {
+		s.logger.Warnw("Parsing failed", "error", err)
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	s.logger.Infow("Parsed description", "resources_count", len(explanation.Resources))
+
+	writeJSON(w, http.StatusOK, explanation)
+}
+
+// explain parses the description of the parameters and explains the resulting model
+func (s *Server) explain(ctx context.Context, params *pipeline.ProcessingParams) (*report.Explanation, error) {
+	coordinator := pipeline.NewPipelineCoordinator()
+	if err := coordinator.InitializePipeline(ctx, params); err != nil {
+		return nil, err
+	}
+	model, extraction, err := coordinator.ModelPipeline(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return report.ExplainModel(model, extraction), nil
+}
+
+// handleHealth reports that the server is up, for load balancers and orchestrators
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "version": version.GetVersionInfo()})
+}
+
+// generateParams creates the pipeline parameters of a generation request, applying the
+// server's defaults
+func (s *Server) generateParams(request GenerateRequest) (*pipeline.ProcessingParams, error) {
+	params, err := s.params(request.Description, request.Region, request.Environment, request.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if request.Output != "" {
+		params.OutputFormat = strings.ToLower(request.Output)
+	}
+	if !pipeline.ValidOutputFormats(params.OutputFormat, pipeline.GetAvailableGenerators()) {
+		return nil, fmt.Errorf("invalid output format: %s (supported formats: %s)", params.OutputFormat, strings.Join(pipeline.GetAvailableGenerators(), ", "))
+	}
+	if request.DRRegion != "" && request.DRRegion == params.Region {
+		return nil, fmt.Errorf("DR region must differ from the primary region: %s", request.DRRegion)
+	}
+	params.DRRegion = request.DRRegion
+	params.ComplianceReport = request.ComplianceReport
+	if request.UseTemplates != nil {
+		params.UseTemplates = *request.UseTemplates
+	}
+	return params, nil
+}
+
+// params creates the pipeline parameters of a request from its description, region,
+// environment and tags, applying the server's defaults
+func (s *Server) params(description, region, environment string, tags map[string]string) (*pipeline.ProcessingParams, error) {
+	if strings.TrimSpace(description) == "" {
+		return nil, errors.New("description is required")
+	}
+	if environment != "" && !infra.ValidEnvironment(environment) {
+		return nil, fmt.Errorf("invalid environment: %s (use up to 16 lower-case letters, digits and hyphens, starting with a letter)", environment)
+	}
+	for key, value := range tags {
+		if err := infra.CheckTag(key, value); err != nil {
+			return nil, err
+		}
+	}
+	if region == "" {
+		region = s.options.Region
+	}
+
+	// The request's tags override the server's
+	var mergedTags map[string]string
+	if len(s.options.Tags) > 0 || len(tags) > 0 {
+		mergedTags = make(map[string]string)
+		for key, value := range s.options.Tags {
+			mergedTags[key] = value
+		}
+		for key, value := range tags {
+			mergedTags[key] = value
+		}
+	}
+
+	// Without a clarifier or reviewer, ambiguous descriptions get defaults and nothing is
+	// printed, as requests run concurrently
+	return &pipeline.ProcessingParams{
+		Description:  strings.TrimSpace(description),
+		OutputFormat: s.options.OutputFormat,
+		OutputDir:    ".",
+		Region:       region,
+		UseTemplates: s.options.UseTemplates,
+		Environment:  environment,
+		Tags:         mergedTags,
+		RequiredTags: s.options.RequiredTags,
+		Policies:     s.options.Policies,
+		NLPBackend:   s.options.NLPBackend,
+		LLMConfig:    s.options.LLMConfig,
+	}, nil
+}
+
+// allowMethod answers 405 Method Not Allowed unless the request uses the method
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed; use %s", r.Method, method))
+	return false
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(body)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
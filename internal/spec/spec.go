@@ -0,0 +1,694 @@
+// Package spec loads structured infrastructure specs that bypass natural language parsing
+package spec
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Schema is the JSON Schema describing the spec format
+//
+//go:embed spec.schema.json
+var Schema []byte
+
+// Patterns for validating spec values
+var (
+	regionPattern       = regexp.MustCompile(`^(us|eu|ap|sa|ca|me|af)-(east|west|north|south|central|northeast|northwest|southeast|southwest)-\d+$`)
+	instanceTypePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*\.[a-z0-9]+$`)
+)
+
+// Spec is the entity form of a spec. Its fields mirror the entities extracted by the
+// NLP parser, and omitted fields get the same defaults as a parsed description.
+type Spec struct {
+	Region      string       `json:"region,omitempty"`
+	VPC         *VPCSpec     `json:"vpc,omitempty"`
+	Subnets     *SubnetSpec  `json:"subnets,omitempty"`
+	Gateways    *GatewaySpec `json:"gateways,omitempty"`
+	EKS         *EKSSpec     `json:"eks,omitempty"`
+	ASG         *ASGSpec     `json:"asg,omitempty"`
+	EC2Instance *EC2Spec     `json:"ec2_instance,omitempty"`
+	S3Bucket    *S3Spec      `json:"s3_bucket,omitempty"`
+	// SecondaryRegions are regions that get a copy of the VPC
+	SecondaryRegions []string `json:"secondary_regions,omitempty"`
+	// Tags are applied to every resource
+	Tags map[string]string `json:"tags,omitempty"`
+	// Environment names the environment the resources are for, such as staging
+	Environment string `json:"environment,omitempty"`
+}
+
+// VPCSpec describes the VPC
+type VPCSpec struct {
+	Name      string `json:"name,omitempty"`
+	CIDRBlock string `json:"cidr_block,omitempty"`
+}
+
+// SubnetSpec describes the public and private subnets
+type SubnetSpec struct {
+	PublicCount  *int     `json:"public_count,omitempty"`
+	PrivateCount *int     `json:"private_count,omitempty"`
+	PublicCIDRs  []string `json:"public_cidrs,omitempty"`
+	PrivateCIDRs []string `json:"private_cidrs,omitempty"`
+	// SubnetMask and ReservedAZs plan the CIDR blocks when they are not given
+	SubnetMask  *int `json:"subnet_mask,omitempty"`
+	ReservedAZs *int `json:"reserved_azs,omitempty"`
+}
+
+// GatewaySpec describes the internet and NAT gateways
+type GatewaySpec struct {
+	IGWCount *int `json:"igw_count,omitempty"`
+	NATCount *int `json:"nat_count,omitempty"`
+}
+
+// EKSSpec describes the EKS cluster
+type EKSSpec struct {
+	Name                  string `json:"name,omitempty"`
+	NodeGroupName         string `json:"node_group_name,omitempty"`
+	Version               string `json:"version,omitempty"`
+	NodeCount             *int   `json:"node_count,omitempty"`
+	InstanceType          string `json:"instance_type,omitempty"`
+	EndpointPublicAccess  *bool  `json:"endpoint_public_access,omitempty"`
+	EndpointPrivateAccess *bool  `json:"endpoint_private_access,omitempty"`
+	// ServiceCIDR is the block Kubernetes service addresses come from; AWS picks one when empty
+	ServiceCIDR string `json:"service_cidr,omitempty"`
+	// NodeGroups replace the single default node group
+	NodeGroups []NodeGroupSpec `json:"node_groups,omitempty"`
+	// FargateNamespaces are the namespaces whose pods run on Fargate
+	FargateNamespaces []string `json:"fargate_namespaces,omitempty"`
+	// Karpenter provisions nodes for the cluster's pending pods
+	Karpenter *bool `json:"karpenter,omitempty"`
+	// Autoscaling is how the nodes scale: cluster-autoscaler or karpenter
+	Autoscaling string `json:"autoscaling,omitempty"`
+	// Controllers get an IAM role for their service account, such as external-dns
+	Controllers []string `json:"controllers,omitempty"`
+	// Addons are installed on the cluster, such as coredns or aws-ebs-csi-driver
+	Addons []AddonSpec `json:"addons,omitempty"`
+}
+
+// AddonSpec describes one EKS add-on and the version it is pinned to, if any
+type AddonSpec struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// NodeGroupSpec describes one EKS node group
+type NodeGroupSpec struct {
+	CapacityType string `json:"capacity_type,omitempty"`
+	InstanceType string `json:"instance_type,omitempty"`
+	// InstanceTypes are the instance types a mixed-instances policy allows
+	InstanceTypes []string `json:"instance_types,omitempty"`
+	DesiredSize   *int     `json:"desired_size,omitempty"`
+	MinSize       *int     `json:"min_size,omitempty"`
+	MaxSize       *int     `json:"max_size,omitempty"`
+}
+
+// ASGSpec describes the Auto Scaling Group
+type ASGSpec struct {
+	Name                      string   `json:"name,omitempty"`
+	DesiredCapacity           *int     `json:"desired_capacity,omitempty"`
+	MinSize                   *int     `json:"min_size,omitempty"`
+	MaxSize                   *int     `json:"max_size,omitempty"`
+	InstanceType              string   `json:"instance_type,omitempty"`
+	AMI                       string   `json:"ami,omitempty"`
+	BehindALB                 *bool    `json:"behind_alb,omitempty"`
+	UserDataPackages          []string `json:"user_data_packages,omitempty"`
+	InstanceRefreshMinHealthy *int     `json:"instance_refresh_min_healthy,omitempty"`
+}
+
+// EC2Spec describes standalone EC2 instances
+type EC2Spec struct {
+	Name         string `json:"name,omitempty"`
+	Count        *int   `json:"count,omitempty"`
+	InstanceType string `json:"instance_type,omitempty"`
+	OS           string `json:"os,omitempty"`
+	AMI          string `json:"ami,omitempty"`
+	KeyName      string `json:"key_name,omitempty"`
+	SSHCIDR      string `json:"ssh_cidr,omitempty"`
+	Web          *bool  `json:"web,omitempty"`
+	Public       *bool  `json:"public,omitempty"`
+}
+
+// S3Spec describes an S3 bucket
+type S3Spec struct {
+	Name       string `json:"name,omitempty"`
+	ACL        string `json:"acl,omitempty"`
+	Versioning *bool  `json:"versioning,omitempty"`
+}
+
+// LoadFile loads a spec file and builds the infrastructure model it describes
+func LoadFile(path string) (*models.InfrastructureModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec file: %w", err)
+	}
+
+	model, err := Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid spec %s: %w", path, err)
+	}
+	return model, nil
+}
+
+// Load parses a YAML or JSON spec. A document with a top-level "resources" list is read
+// as a complete InfrastructureModel; any other document is read as an entity spec.
+func Load(data []byte) (*models.InfrastructureModel, error) {
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %w", err)
+	}
+	top, ok := document.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("spec must be a mapping")
+	}
+
+	// Round-trip through JSON so YAML and JSON specs are decoded by the same strict rules
+	normalized, err := json.Marshal(top)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %w", err)
+	}
+
+	if _, ok := top["resources"]; ok {
+		return loadModel(normalized)
+	}
+	return loadEntities(normalized)
+}
+
+// decodeStrict decodes JSON, rejecting fields the target does not define
+func decodeStrict(data []byte, target interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return err
+	}
+	return nil
+}
+
+// loadEntities decodes an entity spec and builds the model through the same validation and
+// model builder used for parsed descriptions
+func loadEntities(data []byte) (*models.InfrastructureModel, error) {
+	var spec Spec
+	if err := decodeStrict(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to decode entity spec: %w", err)
+	}
+
+	entities, err := spec.Entities()
+	if err != nil {
+		return nil, err
+	}
+	nlp.ValidateEntities(entities)
+
+	builder := infra.NewModelBuilder()
+	if err := builder.BuildFromParsedEntities(entities); err != nil {
+		return nil, fmt.Errorf("failed to build model from spec: %w", err)
+	}
+	return builder.GetModel(), nil
+}
+
+// Entities validates the spec and converts it to the entity map produced by the NLP parser
+func (s *Spec) Entities() (map[string]interface{}, error) {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	entities := make(map[string]interface{})
+	if s.Region != "" {
+		check(regionPattern.MatchString(s.Region), "region: %q is not an AWS region", s.Region)
+		entities["region"] = s.Region
+	}
+
+	vpc := map[string]interface{}{
+		"exists":               true,
+		"enable_dns_support":   true,
+		"enable_dns_hostnames": true,
+	}
+	if s.VPC != nil && s.VPC.CIDRBlock != "" {
+		check(validCIDR(s.VPC.CIDRBlock), "vpc.cidr_block: %q is not a valid CIDR block", s.VPC.CIDRBlock)
+		vpc["cidr_block"] = s.VPC.CIDRBlock
+	}
+	if s.VPC != nil {
+		setName(vpc, "name", s.VPC.Name, "vpc", check)
+	}
+	entities["vpc"] = vpc
+
+	if s.Subnets != nil {
+		subnets := make(map[string]interface{})
+		setCount(subnets, "public_count", s.Subnets.PublicCount, 0, 16, "subnets", check)
+		setCount(subnets, "private_count", s.Subnets.PrivateCount, 0, 16, "subnets", check)
+		setCount(subnets, "subnet_mask", s.Subnets.SubnetMask, 16, 28, "subnets", check)
+		setCount(subnets, "reserved_azs", s.Subnets.ReservedAZs, 1, 16, "subnets", check)
+		check(len(s.Subnets.PublicCIDRs) == 0 || (s.Subnets.SubnetMask == nil && s.Subnets.ReservedAZs == nil), "subnets: subnet_mask and reserved_azs cannot be combined with explicit CIDR blocks")
+		for _, list := range []struct {
+			field string
+			cidrs []string
+		}{{"public_cidrs", s.Subnets.PublicCIDRs}, {"private_cidrs", s.Subnets.PrivateCIDRs}} {
+			if len(list.cidrs) == 0 {
+				continue
+			}
+			for _, cidr := range list.cidrs {
+				check(validCIDR(cidr), "subnets.%s: %q is not a valid CIDR block", list.field, cidr)
+			}
+			subnets[list.field] = list.cidrs
+		}
+		check((len(s.Subnets.PublicCIDRs) == 0) == (len(s.Subnets.PrivateCIDRs) == 0), "subnets: public_cidrs and private_cidrs must be given together")
+		if _, ok := subnets["public_count"]; !ok && len(s.Subnets.PublicCIDRs) > 0 {
+			subnets["public_count"] = len(s.Subnets.PublicCIDRs)
+		}
+		if _, ok := subnets["private_count"]; !ok && len(s.Subnets.PrivateCIDRs) > 0 {
+			subnets["private_count"] = len(s.Subnets.PrivateCIDRs)
+		}
+		if count, ok := subnets["public_count"].(int); ok && len(s.Subnets.PublicCIDRs) > 0 {
+			check(len(s.Subnets.PublicCIDRs) == count, "subnets.public_cidrs: expected %d CIDR blocks, got %d", count, len(s.Subnets.PublicCIDRs))
+		}
+		if count, ok := subnets["private_count"].(int); ok && len(s.Subnets.PrivateCIDRs) > 0 {
+			check(len(s.Subnets.PrivateCIDRs) == count, "subnets.private_cidrs: expected %d CIDR blocks, got %d", count, len(s.Subnets.PrivateCIDRs))
+		}
+		entities["subnets"] = subnets
+	}
+
+	if s.Gateways != nil {
+		gateways := map[string]interface{}{"igw_count": 1, "nat_count": 0}
+		setCount(gateways, "igw_count", s.Gateways.IGWCount, 0, 1, "gateways", check)
+		setCount(gateways, "nat_count", s.Gateways.NATCount, 0, 16, "gateways", check)
+		entities["gateways"] = gateways
+	}
+
+	if s.EKS != nil {
+		eks := map[string]interface{}{"exists": true}
+		setName(eks, "name", s.EKS.Name, "eks", check)
+		setName(eks, "node_group_name", s.EKS.NodeGroupName, "eks", check)
+		if s.EKS.Version != "" {
+			err := infra.ValidateEKSVersion(s.EKS.Version)
+			check(err == nil, "eks.version: %v", err)
+			eks["version"] = s.EKS.Version
+		}
+		setCount(eks, "node_count", s.EKS.NodeCount, 1, 100, "eks", check)
+		setInstanceType(eks, s.EKS.InstanceType, "eks", check)
+		setBool(eks, "endpoint_public_access", s.EKS.EndpointPublicAccess)
+		setBool(eks, "endpoint_private_access", s.EKS.EndpointPrivateAccess)
+		if s.EKS.EndpointPublicAccess != nil && s.EKS.EndpointPrivateAccess != nil {
+			check(*s.EKS.EndpointPublicAccess || *s.EKS.EndpointPrivateAccess, "eks: the API endpoint must be public, private or both")
+		}
+		if s.EKS.ServiceCIDR != "" {
+			err := infra.ValidateServiceCIDR(s.EKS.ServiceCIDR)
+			check(err == nil, "eks.service_cidr: %v", err)
+			eks["service_cidr"] = s.EKS.ServiceCIDR
+		}
+		if len(s.EKS.NodeGroups) > 0 {
+			nodeGroups := make([]interface{}, len(s.EKS.NodeGroups))
+			for i, spec := range s.EKS.NodeGroups {
+				nodeGroups[i] = spec.entity(fmt.Sprintf("eks.node_groups[%d]", i), check)
+			}
+			eks["node_groups"] = nodeGroups
+		}
+		if len(s.EKS.FargateNamespaces) > 0 {
+			seen := make(map[string]bool)
+			for i, namespace := range s.EKS.FargateNamespaces {
+				check(infra.ValidNamespace(namespace), "eks.fargate_namespaces[%d]: %q is not a Kubernetes namespace name", i, namespace)
+				check(!seen[namespace], "eks.fargate_namespaces[%d]: %q is listed more than once", i, namespace)
+				seen[namespace] = true
+			}
+			eks["fargate_namespaces"] = s.EKS.FargateNamespaces
+		}
+		setBool(eks, "karpenter", s.EKS.Karpenter)
+		if s.EKS.Autoscaling != "" {
+			check(infra.ValidEKSAutoscaling(s.EKS.Autoscaling), "eks.autoscaling: %q is not a supported autoscaling strategy (%s)", s.EKS.Autoscaling, strings.Join(infra.EKSAutoscalingStrategies(), ", "))
+			check(s.EKS.Autoscaling == infra.AutoscalingKarpenter || s.EKS.Karpenter == nil || !*s.EKS.Karpenter, "eks.autoscaling: %q conflicts with eks.karpenter", s.EKS.Autoscaling)
+			eks["autoscaling"] = s.EKS.Autoscaling
+		}
+		if len(s.EKS.Controllers) > 0 {
+			seen := make(map[string]bool)
+			for i, name := range s.EKS.Controllers {
+				_, known := infra.LookupEKSController(name)
+				check(known, "eks.controllers[%d]: %q is not a supported controller (%s)", i, name, strings.Join(infra.EKSControllerNames(), ", "))
+				check(!seen[name], "eks.controllers[%d]: %q is listed more than once", i, name)
+				seen[name] = true
+			}
+			eks["controllers"] = s.EKS.Controllers
+		}
+		if len(s.EKS.Addons) > 0 {
+			addons := make([]interface{}, len(s.EKS.Addons))
+			seen := make(map[string]bool)
+			for i, spec := range s.EKS.Addons {
+				addons[i] = spec.entity(fmt.Sprintf("eks.addons[%d]", i), check)
+				check(!seen[spec.Name], "eks.addons[%d]: %q is listed more than once", i, spec.Name)
+				seen[spec.Name] = true
+			}
+			eks["addons"] = addons
+		}
+		entities["eks"] = eks
+	}
+
+	if s.ASG != nil {
+		asg := map[string]interface{}{"exists": true}
+		setName(asg, "name", s.ASG.Name, "asg", check)
+		setCount(asg, "desired_capacity", s.ASG.DesiredCapacity, 0, 1000, "asg", check)
+		setCount(asg, "min_size", s.ASG.MinSize, 0, 1000, "asg", check)
+		setCount(asg, "max_size", s.ASG.MaxSize, 1, 1000, "asg", check)
+		setCount(asg, "instance_refresh_min_healthy", s.ASG.InstanceRefreshMinHealthy, 0, 100, "asg", check)
+		setInstanceType(asg, s.ASG.InstanceType, "asg", check)
+		setBool(asg, "behind_alb", s.ASG.BehindALB)
+		if s.ASG.AMI != "" {
+			asg["ami"] = s.ASG.AMI
+		}
+		if len(s.ASG.UserDataPackages) > 0 {
+			asg["user_data_packages"] = s.ASG.UserDataPackages
+		}
+		if s.ASG.MinSize != nil && s.ASG.MaxSize != nil {
+			check(*s.ASG.MinSize <= *s.ASG.MaxSize, "asg: min_size %d is greater than max_size %d", *s.ASG.MinSize, *s.ASG.MaxSize)
+			if s.ASG.DesiredCapacity != nil {
+				check(*s.ASG.DesiredCapacity >= *s.ASG.MinSize && *s.ASG.DesiredCapacity <= *s.ASG.MaxSize,
+					"asg: desired_capacity %d is outside %d..%d", *s.ASG.DesiredCapacity, *s.ASG.MinSize, *s.ASG.MaxSize)
+			}
+		}
+		entities["asg"] = asg
+	}
+
+	if s.EC2Instance != nil {
+		ec2 := map[string]interface{}{"exists": true}
+		setName(ec2, "name", s.EC2Instance.Name, "ec2_instance", check)
+		setCount(ec2, "count", s.EC2Instance.Count, 1, 100, "ec2_instance", check)
+		setInstanceType(ec2, s.EC2Instance.InstanceType, "ec2_instance", check)
+		if s.EC2Instance.OS != "" {
+			check(s.EC2Instance.OS == infra.OSAmazonLinux2023 || s.EC2Instance.OS == infra.OSUbuntu,
+				"ec2_instance.os: %q is not one of %s, %s", s.EC2Instance.OS, infra.OSAmazonLinux2023, infra.OSUbuntu)
+			ec2["os"] = s.EC2Instance.OS
+		}
+		if s.EC2Instance.AMI != "" {
+			ec2["ami"] = s.EC2Instance.AMI
+		}
+		if s.EC2Instance.KeyName != "" {
+			ec2["key_name"] = s.EC2Instance.KeyName
+		}
+		if s.EC2Instance.SSHCIDR != "" {
+			check(validCIDR(s.EC2Instance.SSHCIDR), "ec2_instance.ssh_cidr: %q is not a valid CIDR block", s.EC2Instance.SSHCIDR)
+			ec2["ssh_cidr"] = s.EC2Instance.SSHCIDR
+		}
+		setBool(ec2, "web", s.EC2Instance.Web)
+		setBool(ec2, "public", s.EC2Instance.Public)
+		entities["ec2_instance"] = ec2
+	}
+
+	if s.S3Bucket != nil {
+		bucket := make(map[string]interface{})
+		if s.S3Bucket.Name != "" {
+			bucket["name"] = s.S3Bucket.Name
+		}
+		if s.S3Bucket.ACL != "" {
+			bucket["acl"] = s.S3Bucket.ACL
+		}
+		setBool(bucket, "versioning", s.S3Bucket.Versioning)
+		entities["s3_bucket"] = bucket
+	}
+
+	for i, secondary := range s.SecondaryRegions {
+		check(regionPattern.MatchString(secondary), "secondary_regions[%d]: %q is not an AWS region", i, secondary)
+	}
+	if len(s.SecondaryRegions) > 0 {
+		entities["secondary_regions"] = s.SecondaryRegions
+	}
+
+	if len(s.Tags) > 0 {
+		tags := make(map[string]interface{}, len(s.Tags))
+		for key, value := range s.Tags {
+			if err := infra.CheckTag(key, value); err != nil {
+				check(false, "tags: %v", err)
+				continue
+			}
+			tags[key] = value
+		}
+		entities["tags"] = tags
+	}
+
+	if s.Environment != "" {
+		check(infra.ValidEnvironment(s.Environment), "environment: %q must be up to 16 lower-case letters, digits and hyphens, starting with a letter", s.Environment)
+		entities["environment"] = s.Environment
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid spec: %s", strings.Join(problems, "; "))
+	}
+	return entities, nil
+}
+
+// entity converts a node group spec to a node group entity. Sizes that are left out default
+// the way the description parser's do: two nodes, scaling up to twice the desired size.
+func (n NodeGroupSpec) entity(prefix string, check func(bool, string, ...interface{})) map[string]interface{} {
+	nodeGroup := map[string]interface{}{"capacity_type": "ON_DEMAND"}
+	if n.CapacityType != "" {
+		check(n.CapacityType == "ON_DEMAND" || n.CapacityType == "SPOT", "%s.capacity_type: %q must be ON_DEMAND or SPOT", prefix, n.CapacityType)
+		nodeGroup["capacity_type"] = n.CapacityType
+	}
+	setInstanceType(nodeGroup, n.InstanceType, prefix, check)
+	if len(n.InstanceTypes) > 0 {
+		check(n.InstanceType == "", "%s: instance_type cannot be combined with instance_types", prefix)
+		for i, instanceType := range n.InstanceTypes {
+			check(instanceTypePattern.MatchString(instanceType), "%s.instance_types[%d]: %q is not an instance type", prefix, i, instanceType)
+		}
+		nodeGroup["instance_types"] = n.InstanceTypes
+	}
+
+	desired := 2
+	setCount(nodeGroup, "desired_size", n.DesiredSize, 1, 100, prefix, check)
+	if n.DesiredSize != nil {
+		desired = *n.DesiredSize
+	}
+	minSize, maxSize := desired, desired*2
+	setCount(nodeGroup, "min_size", n.MinSize, 1, 100, prefix, check)
+	if n.MinSize != nil {
+		minSize = *n.MinSize
+	}
+	setCount(nodeGroup, "max_size", n.MaxSize, 1, 200, prefix, check)
+	if n.MaxSize != nil {
+		maxSize = *n.MaxSize
+	}
+	if n.DesiredSize == nil && desired > maxSize {
+		desired = minSize
+	}
+	check(minSize <= maxSize, "%s: min_size %d is greater than max_size %d", prefix, minSize, maxSize)
+	check(desired >= minSize && desired <= maxSize, "%s: desired_size %d is outside %d..%d", prefix, desired, minSize, maxSize)
+
+	nodeGroup["desired_size"] = desired
+	nodeGroup["min_size"] = minSize
+	nodeGroup["max_size"] = maxSize
+	return nodeGroup
+}
+
+// entity converts an add-on spec to the add-on entity the model builder reads
+func (a AddonSpec) entity(prefix string, check func(bool, string, ...interface{})) map[string]interface{} {
+	_, known := infra.LookupEKSAddon(a.Name)
+	check(known, "%s.name: %q is not a supported add-on (%s)", prefix, a.Name, strings.Join(infra.EKSAddonNames(), ", "))
+	addon := map[string]interface{}{"name": a.Name}
+	if a.Version != "" {
+		check(infra.ValidEKSAddonVersion(a.Version), "%s.version: %q is not an add-on version such as v1.18.1-eksbuild.1", prefix, a.Version)
+		addon["version"] = a.Version
+	}
+	return addon
+}
+
+// setName copies an optional resource name into an entity after checking it
+func setName(entity map[string]interface{}, field, name, prefix string, check func(bool, string, ...interface{})) {
+	if name == "" {
+		return
+	}
+	check(infra.ValidResourceName(name), "%s.%s: %q must be lower-case letters, digits and hyphens, starting with a letter", prefix, field, name)
+	entity[field] = name
+}
+
+// setCount copies an optional count into an entity after checking its bounds
+func setCount(entity map[string]interface{}, field string, value *int, min, max int, prefix string, check func(bool, string, ...interface{})) {
+	if value == nil {
+		return
+	}
+	check(*value >= min && *value <= max, "%s.%s: %d is outside %d..%d", prefix, field, *value, min, max)
+	entity[field] = *value
+}
+
+// setInstanceType copies an optional instance type into an entity after checking its format
+func setInstanceType(entity map[string]interface{}, value string, prefix string, check func(bool, string, ...interface{})) {
+	if value == "" {
+		return
+	}
+	check(instanceTypePattern.MatchString(value), "%s.instance_type: %q is not an instance type", prefix, value)
+	entity["instance_type"] = value
+}
+
+// setBool copies an optional flag into an entity
+func setBool(entity map[string]interface{}, field string, value *bool) {
+	if value != nil {
+		entity[field] = *value
+	}
+}
+
+// validCIDR reports whether a value is an IPv4 CIDR block
+func validCIDR(value string) bool {
+	ip, _, err := net.ParseCIDR(value)
+	return err == nil && ip.To4() != nil
+}
+
+// loadModel decodes a complete InfrastructureModel and checks its resources
+func loadModel(data []byte) (*models.InfrastructureModel, error) {
+	var model models.InfrastructureModel
+	if err := decodeStrict(data, &model); err != nil {
+		return nil, fmt.Errorf("failed to decode model spec: %w", err)
+	}
+	NormalizeModel(&model)
+	if err := ValidateModel(&model); err != nil {
+		return nil, err
+	}
+
+	// The resources are named and tagged after their environment like those of an entity spec
+	if environment := model.Environment; environment != "" {
+		if !infra.ValidEnvironment(environment) {
+			return nil, fmt.Errorf("environment: %q must be up to 16 lower-case letters, digits and hyphens, starting with a letter", environment)
+		}
+		model.Environment = ""
+		infra.ApplyEnvironment(&model, environment)
+	}
+
+	return &model, nil
+}
+
+// ValidateModel checks a complete model, such as a model spec or a saved model: its resources
+// have unique names and supported types, and depend only on each other
+func ValidateModel(model *models.InfrastructureModel) error {
+	if len(model.Resources) == 0 {
+		return errors.New("the model has no resources")
+	}
+
+	names := make(map[string]bool, len(model.Resources))
+	for i, resource := range model.Resources {
+		if resource.Name == "" {
+			return fmt.Errorf("resources[%d]: name is required", i)
+		}
+		if !isKnownResourceType(resource.Type) {
+			return fmt.Errorf("resources[%d] (%s): unsupported type %q", i, resource.Name, resource.Type)
+		}
+		if names[resource.Name] {
+			return fmt.Errorf("resources[%d]: duplicate name %q", i, resource.Name)
+		}
+		names[resource.Name] = true
+	}
+
+	for key, value := range model.Tags {
+		if err := infra.CheckTag(key, value); err != nil {
+			return fmt.Errorf("tags: %w", err)
+		}
+	}
+
+	for _, resource := range model.Resources {
+		for _, dependency := range resource.DependsOn {
+			if !names[dependency] {
+				return fmt.Errorf("resource %s depends on unknown resource %q", resource.Name, dependency)
+			}
+		}
+	}
+	return nil
+}
+
+// knownResourceTypes are the resource types accepted in a model spec
+var knownResourceTypes = map[models.ResourceType]bool{
+	models.ResourceEC2Instance:      true,
+	models.ResourceS3Bucket:         true,
+	models.ResourceRDSInstance:      true,
+	models.ResourceVPC:              true,
+	models.ResourceSubnet:           true,
+	models.ResourceSecurityGroup:    true,
+	models.ResourceIAMRole:          true,
+	models.ResourceLambda:           true,
+	models.ResourceDynamoDB:         true,
+	models.ResourceCloudwatch:       true,
+	models.ResourceIGW:              true,
+	models.ResourceNATGateway:       true,
+	models.ResourceEKSCluster:       true,
+	models.ResourceNodeGroup:        true,
+	models.ResourceLaunchTemplate:   true,
+	models.ResourceAutoScalingGroup: true,
+	models.ResourceEKSAddon:         true,
+	models.ResourceFargateProfile:   true,
+}
+
+// RegisterResourceType accepts a resource type in model specs, such as one the template
+// mappings of the config files render with a custom template
+func RegisterResourceType(resourceType models.ResourceType) {
+	knownResourceTypes[resourceType] = true
+}
+
+// knownResourceTypePatterns match more resource types accepted in a model spec
+var knownResourceTypePatterns []*regexp.Regexp
+
+// RegisterResourceTypePattern accepts the resource types a pattern matches in model specs,
+// such as those the template patterns of the config files render with a custom template
+func RegisterResourceTypePattern(pattern *regexp.Regexp) {
+	knownResourceTypePatterns = append(knownResourceTypePatterns, pattern)
+}
+
+// isKnownResourceType reports whether a resource type is accepted in a model spec
+func isKnownResourceType(resourceType models.ResourceType) bool {
+	if knownResourceTypes[resourceType] {
+		return true
+	}
+	for _, pattern := range knownResourceTypePatterns {
+		if pattern.MatchString(string(resourceType)) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeModel converts the property values of a model decoded from JSON to the Go types
+// the model builder produces, as loading a model spec does
+func NormalizeModel(model *models.InfrastructureModel) {
+	for i, resource := range model.Resources {
+		for j, property := range resource.Properties {
+			model.Resources[i].Properties[j].Value = normalizeValue(property.Value)
+		}
+	}
+}
+
+// normalizeValue converts decoded JSON values to the Go types the model builder produces:
+// whole numbers become ints and lists of strings become []string
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < math.MaxInt32 {
+			return int(v)
+		}
+		return v
+	case []interface{}:
+		strs := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				items := make([]interface{}, len(v))
+				for i, item := range v {
+					items[i] = normalizeValue(item)
+				}
+				return items
+			}
+			strs = append(strs, s)
+		}
+		return strs
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeValue(item)
+		}
+		return v
+	default:
+		return value
+	}
+}
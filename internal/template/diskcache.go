@@ -0,0 +1,164 @@
+package template
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/internal/version"
+)
+
+// DiskCache keeps what is derived from parsing templates between runs: the problems linting
+// and testing each template found, and the output of the partials rendered without a
+// resource, such as headers and footers. Entries are keyed by the version of the binary
+// and the hash of the template, so an edited template or another binary never reads a
+// stale entry, and repeated runs, such as the jobs of a CI matrix sharing the directory,
+// skip parsing the templates that did not change.
+type DiskCache struct {
+	dir   string
+	mutex sync.Mutex
+}
+
+// diskCacheEntry is what is cached for a template
+type diskCacheEntry struct {
+	Version string `json:"version"`
+	// Template is the format and name of the template, such as terraform/vpc.tmpl
+	Template string `json:"template"`
+	// Checks are the issues of lint and test, keyed by the check and the resource types
+	// the template was checked for, which the template mappings may change
+	Checks map[string][]TemplateIssue `json:"checks,omitempty"`
+	// Rendered are the outputs of the template, keyed by the hash of the data it was
+	// rendered with
+	Rendered map[string]string `json:"rendered,omitempty"`
+}
+
+// NewDiskCache returns a cache of templates under a directory, which is created when the
+// first entry is saved
+func NewDiskCache(dir string) *DiskCache {
+	return &DiskCache{dir: dir}
+}
+
+// Dir returns the directory of the cache
+func (c *DiskCache) Dir() string {
+	return c.dir
+}
+
+// key returns the key of a template's entry: the hash of the binary's version and commit,
+// and of the template's name and content
+func (c *DiskCache) key(format TemplateFormat, templateName string, content []byte) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00%s/%s\x00", version.Version, version.CommitHash, format, templateName)
+	hash.Write(content)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// path returns the path of an entry's file
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// load returns a template's entry, or an empty entry when there is none or it cannot be read
+func (c *DiskCache) load(key string) *diskCacheEntry {
+	entry := &diskCacheEntry{}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return entry
+	}
+	if err := json.Unmarshal(data, entry); err != nil {
+		utils.GetLogger().Warnw("Ignoring unreadable template cache entry", "path", c.path(key), "error", err)
+		return &diskCacheEntry{}
+	}
+	return entry
+}
+
+// update changes a template's entry and saves it. The file is written under another name
+// and renamed, so that runs sharing the directory never read a partial entry. An entry
+// that cannot be saved only logs a warning.
+func (c *DiskCache) update(key, templateName string, change func(*diskCacheEntry)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := c.load(key)
+	entry.Version = version.Version
+	entry.Template = templateName
+	change(entry)
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err == nil {
+		err = utils.EnsureDirectoryExists(c.dir)
+	}
+	if err == nil {
+		temp, createErr := os.CreateTemp(c.dir, key+".*.tmp")
+		if err = createErr; err == nil {
+			_, err = temp.Write(data)
+			if closeErr := temp.Close(); err == nil {
+				err = closeErr
+			}
+			if err == nil {
+				err = os.Rename(temp.Name(), c.path(key))
+			}
+			if err != nil {
+				os.Remove(temp.Name())
+			}
+		}
+	}
+	if err != nil {
+		utils.GetLogger().Warnw("Failed to save template cache entry", "template", templateName, "error", err)
+	}
+}
+
+// checks returns the cached issues of a check of a template, and false when it was not cached
+func (c *DiskCache) checks(key, check string) ([]TemplateIssue, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	issues, ok := c.load(key).Checks[check]
+	return issues, ok
+}
+
+// saveChecks caches the issues of a check of a template
+func (c *DiskCache) saveChecks(key, templateName, check string, issues []TemplateIssue) {
+	c.update(key, templateName, func(entry *diskCacheEntry) {
+		if entry.Checks == nil {
+			entry.Checks = make(map[string][]TemplateIssue)
+		}
+		if issues == nil {
+			issues = []TemplateIssue{}
+		}
+		entry.Checks[check] = issues
+	})
+}
+
+// rendered returns the cached output of a template rendered with data of a hash, and false
+// when it was not cached
+func (c *DiskCache) rendered(key, dataHash string) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	output, ok := c.load(key).Rendered[dataHash]
+	return output, ok
+}
+
+// saveRendered caches the output of a template rendered with data of a hash
+func (c *DiskCache) saveRendered(key, templateName, dataHash, output string) {
+	c.update(key, templateName, func(entry *diskCacheEntry) {
+		if entry.Rendered == nil {
+			entry.Rendered = make(map[string]string)
+		}
+		entry.Rendered[dataHash] = output
+	})
+}
+
+// hashData returns the hash of the data a partial is rendered with, and false when the data
+// cannot be encoded, so that the output cannot be cached
+func hashData(data interface{}) (string, bool) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", false
+	}
+	hash := sha256.Sum256(encoded)
+	return hex.EncodeToString(hash[:]), true
+}
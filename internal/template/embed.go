@@ -2,7 +2,7 @@ package template
 
 import "embed"
 
-//go:embed templates/terraform/*.tmpl templates/crossplane/*.tmpl
+//go:embed templates/terraform/*.tmpl templates/crossplane/*.tmpl templates/terraform/_common/*.tmpl templates/crossplane/_common/*.tmpl
 var TemplateFS embed.FS
 
 // DefaultTemplateManager is the default template manager instance
@@ -13,10 +13,15 @@ var DefaultTemplateManager *TemplateManager
 // that can be used by other packages
 var DefaultTemplateRenderer *TemplateRenderer
 
+// DefaultSelector is the template selector of the default renderer, with the mappings
+// registered from the config file
+var DefaultSelector *DefaultTemplateSelector
+
 func init() {
 	// Create default template manager and renderer
 	DefaultTemplateManager = NewTemplateManager(TemplateFS)
-	DefaultTemplateRenderer = NewTemplateRenderer(DefaultTemplateManager, nil)
+	DefaultSelector = NewDefaultTemplateSelector()
+	DefaultTemplateRenderer = NewTemplateRenderer(DefaultTemplateManager, DefaultSelector)
 }
 
 // GetDefaultManager returns the default template manager
@@ -24,6 +29,11 @@ func GetDefaultManager() *TemplateManager {
 	return DefaultTemplateManager
 }
 
+// GetDefaultSelector returns the template selector of the default renderer
+func GetDefaultSelector() *DefaultTemplateSelector {
+	return DefaultSelector
+}
+
 // GetDefaultRenderer returns the default template renderer
 func GetDefaultRenderer() *TemplateRenderer {
 	return DefaultTemplateRenderer
@@ -0,0 +1,99 @@
+package template
+
+import (
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// FixtureRegion is the region of the fixture resources and of the data they are rendered with
+const FixtureRegion = "us-east-1"
+
+// FixtureResources returns a resource of each type the built-in templates render, with every
+// property the templates handle set, so that rendering them exercises each template's
+// optional blocks
+func FixtureResources() []models.Resource {
+	vpc := infra.CreateVPC("main-vpc", "10.0.0.0/16", true, true)
+	vpc.AddProperty("instance_tenancy", "default")
+
+	subnet := infra.CreateSubnet("public-subnet-1", "main-vpc", "10.0.0.0/24", FixtureRegion+"a")
+	subnet.AddProperty("is_public", true)
+	subnet.AddProperty("map_public_ip_on_launch", true)
+
+	securityGroup := infra.CreateSecurityGroup("web-sg", "Allow HTTPS", "main-vpc")
+	infra.AddSecurityGroupRule(&securityGroup, "ingress", "tcp", 443, 443, []string{"0.0.0.0/0"})
+	infra.AddSecurityGroupRule(&securityGroup, "egress", "-1", 0, 0, []string{"0.0.0.0/0"})
+
+	lookup := infra.LookupAMI(infra.OSAmazonLinux2023, "t3.micro")
+	instance := infra.CreateEC2Instance("web", "t3.micro", "resolve:ssm:"+lookup.SSMParameter, FixtureRegion)
+	instance.AddProperty("ami_lookup", lookup.Properties())
+	instance.AddProperty("subnet_id", "public-subnet-1")
+	instance.AddProperty("vpc_security_group_ids", []string{"web-sg"})
+	instance.AddProperty("security_groups", []string{"web-sg"})
+	instance.AddProperty("key_name", "deployer")
+	instance.AddProperty("associate_public_ip_address", true)
+	instance.AddProperty("user_data", "#!/bin/bash\necho hello\n")
+	instance.AddProperty("metadata_options", map[string]interface{}{"http_tokens": "required"})
+
+	bucket := infra.CreateS3Bucket("app-assets", "private", true)
+	bucket.AddProperty("region", FixtureRegion)
+	bucket.AddProperty("replication", map[string]interface{}{
+		"destination_bucket": "app-assets-replica",
+		"destination_region": "us-west-2",
+	})
+
+	cluster := infra.CreateEKSCluster("main-cluster", infra.LatestEKSVersion(), "arn:aws:iam::111122223333:role/eks-cluster", []string{"private-subnet-1", "private-subnet-2"}, true, true)
+	cluster.AddProperty("oidc_provider", true)
+
+	nodeGroup := infra.CreateEKSNodeGroup("main-nodes", "main-cluster", "arn:aws:iam::111122223333:role/eks-nodes", []string{"private-subnet-1", "private-subnet-2"}, []string{"t3.large"}, 3, 1, 5)
+	nodeGroup.AddProperty("capacity_type", "ON_DEMAND")
+	nodeGroup.AddProperty("disk_size", 50)
+
+	ebsCSI, _ := infra.LookupEKSAddon("aws-ebs-csi-driver")
+	ebsCSIVersion, _ := infra.CompatibleEKSAddonVersion(infra.LatestEKSVersion(), ebsCSI.Name)
+	addon := infra.CreateEKSAddon("main-cluster-aws-ebs-csi-driver", "main-cluster", ebsCSI, ebsCSIVersion)
+
+	launchTemplate := infra.CreateLaunchTemplate("web-lt", "t3.micro", "ami-0123456789abcdef0", "#!/bin/bash\necho hello\n")
+	launchTemplate.AddProperty("key_name", "deployer")
+
+	asg := infra.CreateAutoScalingGroup("web-asg", "web-lt", []string{"private-subnet-1", "private-subnet-2"}, 2, 1, 4)
+	infra.SetInstanceRefresh(&asg, "Rolling", 90)
+
+	return []models.Resource{
+		vpc,
+		subnet,
+		infra.CreateInternetGateway("main-igw", "main-vpc"),
+		infra.CreateNATGateway("main-nat", "public-subnet-1", "main-nat-eip"),
+		securityGroup,
+		instance,
+		bucket,
+		cluster,
+		nodeGroup,
+		addon,
+		infra.CreateFargateProfile("main-cluster-default", "main-cluster", []string{"private-subnet-1", "private-subnet-2"}, []string{"default"}),
+		launchTemplate,
+		asg,
+	}
+}
+
+// FixtureResource returns the fixture resource of a type, and false when there is none
+func FixtureResource(resourceType models.ResourceType) (models.Resource, bool) {
+	for _, resource := range FixtureResources() {
+		if resource.Type == resourceType {
+			return resource, true
+		}
+	}
+	return models.Resource{}, false
+}
+
+// fixtureData returns the data a template is rendered with for a resource, with the global
+// context the generators set; a nil resource renders a header or footer template
+func fixtureData(resource *models.Resource) map[string]interface{} {
+	data := map[string]interface{}{
+		"region": FixtureRegion,
+		"tags":   map[string]string{"Environment": "test"},
+	}
+	if resource != nil {
+		data["Resource"] = resource
+	}
+	return data
+}
@@ -3,6 +3,7 @@ package template
 import (
 	"fmt"
 	"github.com/riptano/iac_generator_cli/pkg/models"
+	"sort"
 	"strings"
 	"unicode"
 )
@@ -499,6 +500,31 @@ func SplitFunc(s, sep string) []string {
 	return strings.Split(s, sep)
 }
 
+// SecondaryRegionFunc returns the region of a resource placed outside the primary region,
+// or "" when the resource is in the primary region or names no region
+func SecondaryRegionFunc(resource *models.Resource, primary interface{}) string {
+	if resource == nil {
+		return ""
+	}
+	primaryRegion, _ := primary.(string)
+	region, _ := GetPropertyFunc(resource, "region").(string)
+	if primaryRegion == "" || region == primaryRegion {
+		return ""
+	}
+	return region
+}
+
+// ProviderAliasFunc returns the Terraform provider alias for a region, such as us_west_2
+func ProviderAliasFunc(region string) string {
+	return strings.ReplaceAll(region, "-", "_")
+}
+
+// ProviderConfigNameFunc returns the name of the Crossplane ProviderConfig for a region,
+// formed from the name of the primary region's ProviderConfig
+func ProviderConfigNameFunc(name, region string) string {
+	return name + "-" + region
+}
+
 // GetTagsFunc extracts tags from a resource's properties
 func GetTagsFunc(resource *models.Resource) map[string]string {
 	if resource == nil {
@@ -527,6 +553,27 @@ func GetTagsFunc(resource *models.Resource) map[string]string {
 	return tags
 }
 
+// MergeTagsFunc merges tag sets, with later sets replacing tags with the same key
+func MergeTagsFunc(tagSets ...map[string]string) map[string]string {
+	result := make(map[string]string)
+	for _, tags := range tagSets {
+		for k, v := range tags {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// sortedTagKeys returns the tag keys in sorted order, so rendered tags are stable
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // FormatTerraformTagsFunc formats tags as a Terraform tags block
 func FormatTerraformTagsFunc(tags map[string]string) string {
 	if len(tags) == 0 {
@@ -534,8 +581,8 @@ func FormatTerraformTagsFunc(tags map[string]string) string {
 	}
 	
 	lines := make([]string, 0, len(tags))
-	for k, v := range tags {
-		lines = append(lines, fmt.Sprintf("    %s = \"%s\"", k, escapeHCLString(v)))
+	for _, k := range sortedTagKeys(tags) {
+		lines = append(lines, fmt.Sprintf("    %s = \"%s\"", k, escapeHCLString(tags[k])))
 	}
 	
 	return fmt.Sprintf("  tags = {\n%s\n  }", strings.Join(lines, "\n"))
@@ -548,9 +595,9 @@ func FormatCrossplaneTagsFunc(tags map[string]string) string {
 	}
 	
 	lines := make([]string, 0, len(tags))
-	for k, v := range tags {
+	for _, k := range sortedTagKeys(tags) {
 		lines = append(lines, fmt.Sprintf("    - key: \"%s\"\n      value: \"%s\"", 
-			escapeYAMLString(k), escapeYAMLString(v)))
+			escapeYAMLString(k), escapeYAMLString(tags[k])))
 	}
 	
 	return fmt.Sprintf("    tags:\n%s", strings.Join(lines, "\n"))
@@ -0,0 +1,317 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template/parse"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// Severities of template issues
+const (
+	TemplateIssueError   = "error"
+	TemplateIssueWarning = "warning"
+)
+
+// TemplateIssue is a problem found linting or rendering a template
+type TemplateIssue struct {
+	Severity string         `json:"severity"`
+	Format   TemplateFormat `json:"format"`
+	Template string         `json:"template"`
+	// Line is the line of the template the problem is on, or zero when it is not known
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// Location returns where an issue is, such as terraform/vpc.tmpl:12
+func (i TemplateIssue) Location() string {
+	location := fmt.Sprintf("%s/%s", i.Format, i.Template)
+	if i.Line > 0 {
+		location += ":" + strconv.Itoa(i.Line)
+	}
+	return location
+}
+
+// templateErrorPattern matches the errors of text/template, such as
+// template: vpc.tmpl:3: function "foo" not defined, capturing the line and the message
+var templateErrorPattern = regexp.MustCompile(`(?s)^template: [^:]+:(\d+)(?::\d+)?: (.*)$`)
+
+// LintTemplate checks a template without rendering it: that it parses with the template
+// functions, so that it calls no undefined function, that no action delimiter is left
+// unparsed in its text, and that the properties it reads are ones the fixture resources of
+// the resource types that select it have
+func (tm *TemplateManager) LintTemplate(format TemplateFormat, templateName string) []TemplateIssue {
+	return tm.cachedCheck("lint", format, templateName, tm.lintTemplate)
+}
+
+// lintTemplate lints a template without the disk cache
+func (tm *TemplateManager) lintTemplate(format TemplateFormat, templateName string) []TemplateIssue {
+	issue := func(severity string, line int, message string) TemplateIssue {
+		return TemplateIssue{Severity: severity, Format: format, Template: templateName, Line: line, Message: message}
+	}
+
+	content, err := tm.readTemplate(format, templateName)
+	if err != nil {
+		return []TemplateIssue{issue(TemplateIssueError, 0, err.Error())}
+	}
+	// The template is parsed with the partials of its format, whose named templates it may call
+	base, err := tm.commonTemplate(format)
+	if err != nil {
+		return []TemplateIssue{issue(TemplateIssueError, 0, err.Error())}
+	}
+	// What changed since the version the template is written for explains its errors
+	issues := migrationIssues(format, templateName, content)
+	tmpl, err := base.Clone()
+	if err == nil {
+		tmpl, err = tmpl.New(templateName).Parse(string(content))
+	}
+	if err != nil {
+		line, message := templateErrorLine(err)
+		return append([]TemplateIssue{issue(TemplateIssueError, line, message)}, issues...)
+	}
+
+	for _, defined := range tmpl.Templates() {
+		// The partials are checked with the templates that parse them
+		if defined.Tree == nil || defined.Tree.ParseName != templateName {
+			continue
+		}
+		walkNodes(defined.Tree.Root, func(node parse.Node) {
+			switch n := node.(type) {
+			case *parse.TextNode:
+				for _, delimiter := range []string{"{{", "}}"} {
+					if index := bytes.Index(n.Text, []byte(delimiter)); index >= 0 {
+						line := lineOf(content, int(n.Position())+index)
+						issues = append(issues, issue(TemplateIssueError, line, fmt.Sprintf("%q is left in the output unparsed; check the delimiters of the action around it", delimiter)))
+						return
+					}
+				}
+			case *parse.TemplateNode:
+				if called := tmpl.Lookup(n.Name); called == nil || called.Tree == nil {
+					line := lineOf(content, int(n.Position()))
+					issues = append(issues, issue(TemplateIssueError, line, fmt.Sprintf("calls the template %q, which neither the template nor the partials of %s/%s define", n.Name, format, CommonDir)))
+				}
+			}
+		})
+	}
+
+	resourceTypes := GetDefaultSelector().ResourceTypes(format, templateName)
+	if len(resourceTypes) > 0 {
+		analysis, _ := AnalyzeTemplate(string(content))
+		properties, _ := analysis["properties"].([]string)
+		for _, property := range properties {
+			if fixturesHaveProperty(resourceTypes, property) {
+				continue
+			}
+			line := lineOf(content, bytes.Index(content, []byte(strconv.Quote(property))))
+			issues = append(issues, issue(TemplateIssueWarning, line, fmt.Sprintf("reads the property %q, which %s resources do not have; check its name", property, joinResourceTypes(resourceTypes))))
+		}
+	}
+	return issues
+}
+
+// TestTemplate renders a template, with the values of SetValues, with the fixture resource
+// of each resource type that selects it, a resource with only a name and a region for types
+// without one, or without a resource for a header or footer template, and checks that it
+// renders without errors, that no field it reads is missing from the data, and that the
+// output is valid HCL or YAML
+func (tm *TemplateManager) TestTemplate(format TemplateFormat, templateName string) []TemplateIssue {
+	return tm.cachedCheck("test", format, templateName, tm.testTemplate)
+}
+
+// testTemplate tests a template without the disk cache
+func (tm *TemplateManager) testTemplate(format TemplateFormat, templateName string) []TemplateIssue {
+	issue := func(severity string, line int, message string) TemplateIssue {
+		return TemplateIssue{Severity: severity, Format: format, Template: templateName, Line: line, Message: message}
+	}
+
+	tmpl, err := tm.GetTemplate(format, templateName)
+	if err != nil {
+		line, message := templateErrorLine(err)
+		return append([]TemplateIssue{issue(TemplateIssueError, line, message)}, tm.templateMigrationIssues(format, templateName)...)
+	}
+
+	var resources []*models.Resource
+	for _, resourceType := range GetDefaultSelector().ResourceTypes(format, templateName) {
+		resource, ok := FixtureResource(resourceType)
+		if !ok {
+			// A type mapped in the config file has no fixture, so only its common fields are set
+			resource = models.Resource{Type: resourceType, Name: "example-" + strings.ReplaceAll(string(resourceType), "_", "-")}
+			resource.AddProperty("region", FixtureRegion)
+		}
+		resources = append(resources, &resource)
+	}
+	if len(resources) == 0 {
+		if !strings.Contains(templateName, "header") && !strings.Contains(templateName, "footer") {
+			return []TemplateIssue{issue(TemplateIssueWarning, 0, "no resource type selects the template, so it was not rendered")}
+		}
+		resources = append(resources, nil)
+	}
+
+	var issues []TemplateIssue
+	failed := false
+	for _, resource := range resources {
+		subject := "without a resource"
+		if resource != nil {
+			subject = fmt.Sprintf("with %s %s", resource.Type, resource.Name)
+		}
+
+		data := fixtureData(resource)
+		data[ValuesKey] = tm.Values()
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			line, message := templateErrorLine(err)
+			issues = append(issues, issue(TemplateIssueError, line, fmt.Sprintf("failed to render %s: %s", subject, message)))
+			failed = true
+			continue
+		}
+		output := buf.String()
+		if index := strings.Index(output, "<no value>"); index >= 0 {
+			issues = append(issues, issue(TemplateIssueError, 0, fmt.Sprintf("renders <no value> on line %d of its output %s; a field it reads is missing from the data", strings.Count(output[:index], "\n")+1, subject)))
+			continue
+		}
+		if err := ValidateRenderedContent(format, output); err != nil {
+			issues = append(issues, issue(TemplateIssueError, 0, fmt.Sprintf("renders %s: %v", subject, err)))
+		}
+	}
+	if failed {
+		issues = append(issues, tm.templateMigrationIssues(format, templateName)...)
+	}
+	return issues
+}
+
+// templateMigrationIssues returns the migration warnings of a template, or none when it
+// cannot be read
+func (tm *TemplateManager) templateMigrationIssues(format TemplateFormat, templateName string) []TemplateIssue {
+	content, err := tm.readTemplate(format, templateName)
+	if err != nil {
+		return nil
+	}
+	return migrationIssues(format, templateName, content)
+}
+
+// migrationIssues returns a warning for each change of the template context since the
+// version a template declares that it uses, and for a template written for a newer version
+// than this binary renders
+func migrationIssues(format TemplateFormat, templateName string, content []byte) []TemplateIssue {
+	var issues []TemplateIssue
+	if version, _ := TemplateVersion(content); version > TemplateContextVersion {
+		issues = append(issues, TemplateIssue{Severity: TemplateIssueWarning, Format: format, Template: templateName, Line: 1,
+			Message: fmt.Sprintf("is written for template version %d, but this iacgen renders version %d; upgrade iacgen", version, TemplateContextVersion)})
+	}
+	for _, migration := range TemplateMigrations(content) {
+		issues = append(issues, TemplateIssue{Severity: TemplateIssueWarning, Format: format, Template: templateName, Line: migration.Line,
+			Message: fmt.Sprintf("written for an older template version: version %d changed what it uses; %s", migration.Version, migration.Message)})
+	}
+	return issues
+}
+
+// cachedCheck returns the issues an earlier run's check of a template found, from the disk
+// cache, when the template, the resource types that select it and the values did not change
+// since, or runs the check and caches them
+func (tm *TemplateManager) cachedCheck(check string, format TemplateFormat, templateName string, run func(TemplateFormat, string) []TemplateIssue) []TemplateIssue {
+	if tm.diskCache == nil {
+		return run(format, templateName)
+	}
+	content, err := tm.cacheContent(format, templateName)
+	if err != nil {
+		return run(format, templateName)
+	}
+
+	key := tm.diskCache.key(format, templateName, content)
+	for _, resourceType := range GetDefaultSelector().ResourceTypes(format, templateName) {
+		check += ":" + string(resourceType)
+	}
+	// Templates are tested with the values, which may change what they render
+	if valuesHash, ok := hashData(tm.Values()); ok && len(tm.Values()) > 0 {
+		check += ":" + valuesHash
+	}
+	if issues, ok := tm.diskCache.checks(key, check); ok {
+		utils.GetLogger().Debugw("Using cached template check", "template", fmt.Sprintf("%s/%s", format, templateName), "cache", tm.diskCache.Dir())
+		return issues
+	}
+	issues := run(format, templateName)
+	tm.diskCache.saveChecks(key, fmt.Sprintf("%s/%s", format, templateName), check, issues)
+	return issues
+}
+
+// templateErrorLine returns the line of a text/template error and its message without the
+// template name
+func templateErrorLine(err error) (int, string) {
+	message := err.Error()
+	if index := strings.Index(message, "template: "); index > 0 {
+		message = message[index:]
+	}
+	match := templateErrorPattern.FindStringSubmatch(message)
+	if match == nil {
+		return 0, err.Error()
+	}
+	line, _ := strconv.Atoi(match[1])
+	return line, match[2]
+}
+
+// walkNodes calls visit with each node under a node
+func walkNodes(node parse.Node, visit func(parse.Node)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkNodes(child, visit)
+		}
+	case *parse.IfNode:
+		visit(n)
+		walkNodes(n.List, visit)
+		walkNodes(n.ElseList, visit)
+	case *parse.RangeNode:
+		visit(n)
+		walkNodes(n.List, visit)
+		walkNodes(n.ElseList, visit)
+	case *parse.WithNode:
+		visit(n)
+		walkNodes(n.List, visit)
+		walkNodes(n.ElseList, visit)
+	default:
+		visit(n)
+	}
+}
+
+// lineOf returns the line of a byte offset in content, or zero for a negative offset
+func lineOf(content []byte, offset int) int {
+	if offset < 0 || offset > len(content) {
+		return 0
+	}
+	return bytes.Count(content[:offset], []byte("\n")) + 1
+}
+
+// fixturesHaveProperty reports whether the fixture resource of any of the types has a property
+func fixturesHaveProperty(resourceTypes []models.ResourceType, name string) bool {
+	for _, resourceType := range resourceTypes {
+		resource, ok := FixtureResource(resourceType)
+		if !ok {
+			// Without a fixture, any property may be set
+			return true
+		}
+		if _, ok := resource.GetProperty(name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// joinResourceTypes lists resource types, such as "vpc" or "ec2_instance and launch_template"
+func joinResourceTypes(resourceTypes []models.ResourceType) string {
+	names := make([]string, len(resourceTypes))
+	for i, resourceType := range resourceTypes {
+		names[i] = string(resourceType)
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
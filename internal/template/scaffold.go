@@ -0,0 +1,101 @@
+package template
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// terraformTypePattern matches Terraform AWS resource types, such as aws_elasticache_cluster
+var terraformTypePattern = regexp.MustCompile(`^aws_[a-z0-9]+(_[a-z0-9]+)*$`)
+
+// ScaffoldResourceType returns the resource type of the model a Terraform AWS resource type
+// is mapped to templates for, such as elasticache_cluster for aws_elasticache_cluster
+func ScaffoldResourceType(terraformType string) (models.ResourceType, error) {
+	if !terraformTypePattern.MatchString(terraformType) {
+		return "", fmt.Errorf("invalid resource type %q: expected a Terraform AWS resource type such as aws_elasticache_cluster", terraformType)
+	}
+	return models.ResourceType(strings.TrimPrefix(terraformType, "aws_")), nil
+}
+
+// ScaffoldTemplateName returns the name of the template scaffolded for a Terraform AWS
+// resource type, such as elasticache_cluster.tmpl
+func ScaffoldTemplateName(terraformType string) string {
+	return strings.TrimPrefix(terraformType, "aws_") + ".tmpl"
+}
+
+// ScaffoldTemplate returns a starter template of a format for a Terraform AWS resource type.
+// It declares the current template version, and renders the resource with the standard
+// context: its name, the region and provider of a resource outside the primary region, each
+// of its properties, and its tags merged with the tags of the model. The Crossplane kind and API group are guessed from the type.
+func ScaffoldTemplate(format TemplateFormat, terraformType string) (string, error) {
+	if _, err := ScaffoldResourceType(terraformType); err != nil {
+		return "", err
+	}
+
+	switch format {
+	case FormatTerraform:
+		return fmt.Sprintf(`{{/* iacgen-template-version: %[2]d */ -}}
+{{/* Starter template for %[1]s resources. Each property is written as an
+argument: rename them to the arguments of %[1]s, and check the template with
+iacgen templates lint and iacgen templates test. */ -}}
+resource "%[1]s" "{{ .Resource.Name | snake }}" {
+  {{- with secondaryRegion .Resource .region }}
+  provider = aws.{{ providerAlias . }}
+  {{- end }}
+  {{- range .Resource.Properties }}
+  {{- if and (ne .Name "region") (not (hasPrefix .Name "tag.")) }}
+  {{ .Name }} = {{ .Value | toHCL }}
+  {{- end }}
+  {{- end }}
+
+  {{- $tags := getTags .Resource }}
+{{ $tags | tfTags }}
+}
+`, terraformType, TemplateContextVersion), nil
+	case FormatCrossplane:
+		group, kind := crossplaneKind(terraformType)
+		return fmt.Sprintf(`{{/* iacgen-template-version: %[4]d */ -}}
+{{/* Starter template for %[1]s resources. Check the apiVersion and kind against
+the CRDs of the Crossplane AWS provider, rename the properties to the fields of
+forProvider, and check the template with iacgen templates lint and iacgen templates test. */ -}}
+---
+apiVersion: %[2]s.aws.crossplane.io/v1alpha1
+kind: %[3]s
+metadata:
+  name: {{ .Resource.Name | kebab }}
+spec:
+  forProvider:
+    region: {{ defaultValue (getProperty .Resource "region") (defaultValue .region "us-east-1") }}
+    {{- range .Resource.Properties }}
+    {{- if and (ne .Name "region") (not (hasPrefix .Name "tag.")) }}
+    {{ camel .Name }}: {{ .Value | toYAML }}
+    {{- end }}
+    {{- end }}
+    {{- $tags := mergeTags (getTags .Resource) .tags }}
+{{ $tags | cpTags }}
+  providerConfigRef:
+    name: {{ with secondaryRegion .Resource .region }}{{ providerConfigName "aws-provider" . }}{{ else }}default{{ end }}
+`, terraformType, group, kind, TemplateContextVersion), nil
+	default:
+		return "", fmt.Errorf("unsupported template format: %s", format)
+	}
+}
+
+// crossplaneKind guesses the API group and kind of a Terraform AWS resource type: the
+// service and the rest of the type, such as elasticache and Cluster for
+// aws_elasticache_cluster
+func crossplaneKind(terraformType string) (string, string) {
+	words := strings.Split(strings.TrimPrefix(terraformType, "aws_"), "_")
+	group := words[0]
+	if len(words) > 1 {
+		words = words[1:]
+	}
+	var kind strings.Builder
+	for _, word := range words {
+		kind.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return group, kind.String()
+}
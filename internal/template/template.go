@@ -5,8 +5,11 @@ import (
 	"embed"
 	"fmt"
 	"io/fs"
+	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings" // Using strings.Contains (multiple places) and strings.Split (in RegisterPatternTemplate)
 	"sync"
 	"text/template"
@@ -124,8 +127,14 @@ type TemplateManager struct {
 	fs      embed.FS
 	cache   *TemplateCache
 	funcMap template.FuncMap
-	// Base template with common components
-	baseTemplate *template.Template
+	// overrideDir holds templates replacing the embedded ones, as <format>/<name> (empty for none)
+	overrideDir string
+	// noCache reads and parses each template every time it is used
+	noCache bool
+	// diskCache keeps what is derived from the templates between runs (nil for none)
+	diskCache *DiskCache
+	// values are what templates read as .Values, such as the values of --values and --set
+	values map[string]interface{}
 }
 
 // NewTemplateManager creates a new template manager with the given embedded filesystem
@@ -182,49 +191,129 @@ func createTemplateFuncMap() template.FuncMap {
 		"yamlRef":      YAMLRefFunc,
 		"cidrSubnet":   CIDRSubnetFunc,
 		"getTags":      GetTagsFunc,
+		"mergeTags":    MergeTagsFunc,
 		"tfTags":       FormatTerraformTagsFunc,
 		"cpTags":       FormatCrossplaneTagsFunc,
+		
+		// Region functions
+		"secondaryRegion":    SecondaryRegionFunc,
+		"providerAlias":      ProviderAliasFunc,
+		"providerConfigName": ProviderConfigNameFunc,
 	}
 }
 
-// PreloadCommonTemplates preloads common partial templates used by other templates
-func (tm *TemplateManager) PreloadCommonTemplates() error {
-	// Create a base template with standard functionality
-	baseTemplate := template.New("base").Funcs(tm.funcMap)
+// CommonDir is the directory of a format's partials, such as terraform/_common, whose named
+// templates and blocks every template of the format can use and override
+const CommonDir = "_common"
 
-	// Try to preload common partials for each format
+// PreloadCommonTemplates parses the partials of each format, reporting those that do not parse
+func (tm *TemplateManager) PreloadCommonTemplates() error {
 	for _, format := range []TemplateFormat{FormatTerraform, FormatCrossplane} {
-		commonPath := filepath.Join(string(format), "_common")
-		
-		// Check if common directory exists
-		entries, err := fs.ReadDir(tm.fs, commonPath)
-		if err != nil {
-			// Common directory doesn't exist for this format, which is fine
-			continue
+		if _, err := tm.commonTemplate(format); err != nil {
+			return err
 		}
-		
-		// Load all common templates
+	}
+	return nil
+}
+
+// commonPartial is a partial of a format's _common directory
+type commonPartial struct {
+	name    string
+	content []byte
+}
+
+// readCommonTemplates reads the partials of a format, sorted by name: the embedded ones, and
+// those of the override directory, which replace the embedded partials of the same name
+func (tm *TemplateManager) readCommonTemplates(format TemplateFormat) ([]commonPartial, error) {
+	partials := make(map[string][]byte)
+	embeddedDir := path.Join("templates", string(format), CommonDir)
+	if entries, err := fs.ReadDir(tm.fs, embeddedDir); err == nil {
 		for _, entry := range entries {
-			if entry.IsDir() {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ".tmpl" {
 				continue
 			}
-			
-			partialPath := filepath.Join(commonPath, entry.Name())
-			partialData, err := tm.fs.ReadFile(partialPath)
+			content, err := tm.fs.ReadFile(path.Join(embeddedDir, entry.Name()))
 			if err != nil {
-				continue // Skip files we can't read
+				return nil, fmt.Errorf("failed to read common template %s: %w", entry.Name(), err)
 			}
-			
-			// Add to base template
-			_, err = baseTemplate.New(fmt.Sprintf("%s:%s", format, entry.Name())).Parse(string(partialData))
+			partials[entry.Name()] = content
+		}
+	}
+	if tm.overrideDir != "" {
+		overrideDir := filepath.Join(tm.overrideDir, string(format), CommonDir)
+		entries, err := os.ReadDir(overrideDir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read common templates in %s: %w", overrideDir, err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ".tmpl" {
+				continue
+			}
+			content, err := os.ReadFile(filepath.Join(overrideDir, entry.Name()))
 			if err != nil {
-				return fmt.Errorf("failed to parse common template %s: %w", partialPath, err)
+				return nil, fmt.Errorf("failed to read common template %s: %w", entry.Name(), err)
 			}
+			partials[entry.Name()] = content
 		}
 	}
-	
-	tm.baseTemplate = baseTemplate
-	return nil
+
+	names := make([]string, 0, len(partials))
+	for name := range partials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	common := make([]commonPartial, len(names))
+	for i, name := range names {
+		common[i] = commonPartial{name: name, content: partials[name]}
+	}
+	return common, nil
+}
+
+// commonTemplate returns the partials of a format parsed into one template, which each
+// template of the format is parsed into a clone of, so that a template overriding a named
+// block of the partials only changes it for itself
+func (tm *TemplateManager) commonTemplate(format TemplateFormat) (*template.Template, error) {
+	cacheKey := fmt.Sprintf("%s:%s", format, CommonDir)
+	if tmpl, exists := tm.cache.Get(cacheKey); exists && !tm.noCache {
+		return tmpl, nil
+	}
+
+	partials, err := tm.readCommonTemplates(format)
+	if err != nil {
+		return nil, err
+	}
+	base := template.New(cacheKey).Funcs(tm.funcMap)
+	size := 0
+	for _, partial := range partials {
+		if _, err := base.New(path.Join(string(format), CommonDir, partial.name)).Parse(string(partial.content)); err != nil {
+			return nil, fmt.Errorf("failed to parse common template %s: %w", partial.name, err)
+		}
+		size += len(partial.content)
+	}
+
+	if !tm.noCache {
+		tm.cache.Set(cacheKey, base, size)
+	}
+	return base, nil
+}
+
+// cacheContent returns what a template renders from: the partials of its format and its own
+// content, which the entries of the disk cache are keyed by
+func (tm *TemplateManager) cacheContent(format TemplateFormat, templateName string) ([]byte, error) {
+	partials, err := tm.readCommonTemplates(format)
+	if err != nil {
+		return nil, err
+	}
+	content, err := tm.readTemplate(format, templateName)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, partial := range partials {
+		fmt.Fprintf(&buf, "%s\x00%s\x00", partial.name, partial.content)
+	}
+	buf.Write(content)
+	return buf.Bytes(), nil
 }
 
 // GetEmptyTemplate creates an empty template with the function map
@@ -237,42 +326,134 @@ func (tm *TemplateManager) GetTemplate(format TemplateFormat, templateName strin
 	cacheKey := fmt.Sprintf("%s:%s", format, templateName)
 	
 	// Check if template is already in cache
-	if tmpl, exists := tm.cache.Get(cacheKey); exists {
+	if tmpl, exists := tm.cache.Get(cacheKey); exists && !tm.noCache {
 		return tmpl, nil
 	}
 	
 	// Template not in cache, load it
-	templatePath := filepath.Join("templates", string(format), templateName)
-	templateData, err := tm.fs.ReadFile(templatePath)
+	templateData, err := tm.readTemplate(format, templateName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read template %s: %w", templatePath, err)
+		return nil, err
 	}
 	
-	// Parse template
-	var tmpl *template.Template
-	if tm.baseTemplate != nil {
-		// Clone the base template to inherit common partials
-		tmpl, err = tm.baseTemplate.Clone()
-		if err != nil {
-			return nil, fmt.Errorf("failed to clone base template: %w", err)
-		}
-		
-		tmpl, err = tmpl.New(templateName).Parse(string(templateData))
-	} else {
-		// No base template, create from scratch
-		tmpl, err = template.New(templateName).Funcs(tm.funcMap).Parse(string(templateData))
+	// Parse the template into a clone of the partials of its format, so it can use their
+	// named templates and override their blocks
+	base, err := tm.commonTemplate(format)
+	if err != nil {
+		return nil, err
 	}
-	
+	tmpl, err := base.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone common templates: %w", err)
+	}
+	tmpl, err = tmpl.New(templateName).Parse(string(templateData))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse template %s: %w", templateName, err)
+		return nil, withMigrations(format, templateName, templateData, fmt.Errorf("failed to parse template %s: %w", templateName, err))
 	}
+	warnMigrations(format, templateName, templateData)
 	
 	// Add template to cache
-	tm.cache.Set(cacheKey, tmpl, len(templateData))
+	if !tm.noCache {
+		tm.cache.Set(cacheKey, tmpl, len(templateData))
+	}
 	
 	return tmpl, nil
 }
 
+// SetOverrideDir sets a directory of templates that replace the embedded templates with the
+// same format and name, such as <dir>/terraform/vpc.tmpl. Templates it does not have are
+// still read from the embedded filesystem.
+func (tm *TemplateManager) SetOverrideDir(dir string) {
+	tm.overrideDir = dir
+	tm.cache.Clear()
+}
+
+// TemplateSource returns where a template is read from: its path in the override directory
+// when it has the template, or "embedded" for the built-in template
+func (tm *TemplateManager) TemplateSource(format TemplateFormat, templateName string) string {
+	if tm.overrideDir != "" {
+		overridePath := filepath.Join(tm.overrideDir, string(format), templateName)
+		if info, err := os.Stat(overridePath); err == nil && !info.IsDir() {
+			return overridePath
+		}
+	}
+	return "embedded"
+}
+
+// SetCacheEnabled sets whether parsed templates are cached. With the cache disabled, each
+// template is read and parsed every time it is used, so that changes to the templates being
+// edited show up in the next render.
+func (tm *TemplateManager) SetCacheEnabled(enabled bool) {
+	tm.noCache = !enabled
+	tm.cache.Clear()
+}
+
+// SetDiskCacheDir sets a directory keeping the issues linting and testing each template
+// found and the partials rendered without a resource between runs, so that runs after the
+// first skip parsing the templates that did not change. An empty directory disables it.
+func (tm *TemplateManager) SetDiskCacheDir(dir string) {
+	tm.diskCache = nil
+	if dir != "" {
+		tm.diskCache = NewDiskCache(dir)
+	}
+}
+
+// RenderPartial renders a template without a resource, such as a header or footer, with data.
+// With a disk cache, the output an earlier run rendered with the same data is returned
+// without parsing the template.
+func (tm *TemplateManager) RenderPartial(format TemplateFormat, templateName string, data map[string]interface{}) (string, error) {
+	var key, dataHash string
+	cacheable := false
+	if tm.diskCache != nil {
+		content, err := tm.cacheContent(format, templateName)
+		if err != nil {
+			return "", err
+		}
+		key = tm.diskCache.key(format, templateName, content)
+		if dataHash, cacheable = hashData(data); cacheable {
+			if output, ok := tm.diskCache.rendered(key, dataHash); ok {
+				utils.GetLogger().Debugw("Using cached partial", "template", fmt.Sprintf("%s/%s", format, templateName), "cache", tm.diskCache.Dir())
+				return output, nil
+			}
+		}
+	}
+
+	tmpl, err := tm.GetTemplate(format, templateName)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", tm.migrationError(format, templateName, fmt.Errorf("failed to render template %s: %w", templateName, err))
+	}
+	if cacheable {
+		tm.diskCache.saveRendered(key, fmt.Sprintf("%s/%s", format, templateName), dataHash, buf.String())
+	}
+	return buf.String(), nil
+}
+
+// readTemplate reads a template from the override directory, or from the embedded
+// filesystem when the override directory does not have it, and logs which one it came from
+func (tm *TemplateManager) readTemplate(format TemplateFormat, templateName string) ([]byte, error) {
+	source := tm.TemplateSource(format, templateName)
+	templatePath := filepath.Join("templates", string(format), templateName)
+
+	var templateData []byte
+	var err error
+	if source != "embedded" {
+		templateData, err = os.ReadFile(source)
+		templatePath = source
+	} else {
+		templateData, err = tm.fs.ReadFile(templatePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template %s: %w", templatePath, err)
+	}
+
+	utils.GetLogger().Debugw("Loaded template", "template", fmt.Sprintf("%s/%s", format, templateName), "source", source)
+	return templateData, nil
+}
+
 // GetTemplateWithPattern gets a template for a given resource type matching a pattern
 func (tm *TemplateManager) GetTemplateWithPattern(format TemplateFormat, pattern string) (*template.Template, string, error) {
 	// List all templates for the format
@@ -331,9 +512,34 @@ func (tm *TemplateManager) ListTemplates(format TemplateFormat) ([]string, error
 		return nil, fmt.Errorf("failed to list templates for format %s: %w", format, err)
 	}
 	
+	// Templates only the override directory has are listed after the embedded ones
+	if tm.overrideDir != "" {
+		entries, err := os.ReadDir(filepath.Join(tm.overrideDir, string(format)))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to list templates for format %s: %w", format, err)
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || (filepath.Ext(name) != ".tmpl" && filepath.Ext(name) != ".gotmpl") || containsTemplate(templates, name) {
+				continue
+			}
+			templates = append(templates, name)
+		}
+	}
+	
 	return templates, nil
 }
 
+// containsTemplate reports whether a list of template names has the name
+func containsTemplate(templates []string, name string) bool {
+	for _, listed := range templates {
+		if listed == name {
+			return true
+		}
+	}
+	return false
+}
+
 // RefreshCache refreshes the template cache
 func (tm *TemplateManager) RefreshCache() {
 	tm.cache.Clear()
@@ -351,16 +557,22 @@ type TemplateSelector interface {
 type DefaultTemplateSelector struct {
 	// Map resource types to template names for each format
 	mappings map[TemplateFormat]map[models.ResourceType]string
-	// Fallback patterns for resource types without explicit mappings
-	patterns map[TemplateFormat]map[string]string
+	// Fallback patterns for resource types without explicit mappings, tried in order
+	patterns map[TemplateFormat][]patternTemplate
 	mutex    sync.RWMutex
 }
 
+// patternTemplate is the template of the resource types a pattern matches
+type patternTemplate struct {
+	pattern      string
+	templateName string
+}
+
 // NewDefaultTemplateSelector creates a new template selector with default mappings
 func NewDefaultTemplateSelector() *DefaultTemplateSelector {
 	selector := &DefaultTemplateSelector{
 		mappings: make(map[TemplateFormat]map[models.ResourceType]string),
-		patterns: make(map[TemplateFormat]map[string]string),
+		patterns: make(map[TemplateFormat][]patternTemplate),
 	}
 	
 	// Initialize default mappings for Terraform
@@ -379,6 +591,10 @@ func NewDefaultTemplateSelector() *DefaultTemplateSelector {
 		models.ResourceDynamoDB:      "dynamodb.tmpl",
 		models.ResourceCloudwatch:    "cloudwatch.tmpl",
 		models.ResourceRDSInstance:   "rds_instance.tmpl",
+		models.ResourceLaunchTemplate:   "launch_template.tmpl",
+		models.ResourceAutoScalingGroup: "autoscaling_group.tmpl",
+		models.ResourceEKSAddon:         "eks_addon.tmpl",
+		models.ResourceFargateProfile:   "eks_fargate_profile.tmpl",
 	}
 	selector.mappings[FormatTerraform] = tfMapping
 	
@@ -398,6 +614,10 @@ func NewDefaultTemplateSelector() *DefaultTemplateSelector {
 		models.ResourceDynamoDB:      "dynamodb.tmpl",
 		models.ResourceCloudwatch:    "cloudwatch.tmpl",
 		models.ResourceRDSInstance:   "rds_instance.tmpl",
+		models.ResourceLaunchTemplate:   "launch_template.tmpl",
+		models.ResourceAutoScalingGroup: "autoscaling_group.tmpl",
+		models.ResourceEKSAddon:         "eks_addon.tmpl",
+		models.ResourceFargateProfile:   "eks_fargate_profile.tmpl",
 	}
 	selector.mappings[FormatCrossplane] = cpMapping
 	
@@ -412,7 +632,9 @@ func NewDefaultTemplateSelector() *DefaultTemplateSelector {
 		"^eks_":     "eks_resource.tmpl",
 		"^vpc_":     "vpc_resource.tmpl",
 	}
-	selector.patterns[FormatTerraform] = tfPatterns
+	for _, pattern := range utils.SortedKeys(tfPatterns) {
+		selector.RegisterPatternTemplate(FormatTerraform, pattern, tfPatterns[pattern])
+	}
 	
 	cpPatterns := map[string]string{
 		"^ec2_":     "ec2_resource.tmpl",
@@ -424,7 +646,9 @@ func NewDefaultTemplateSelector() *DefaultTemplateSelector {
 		"^eks_":     "eks_resource.tmpl",
 		"^vpc_":     "vpc_resource.tmpl",
 	}
-	selector.patterns[FormatCrossplane] = cpPatterns
+	for _, pattern := range utils.SortedKeys(cpPatterns) {
+		selector.RegisterPatternTemplate(FormatCrossplane, pattern, cpPatterns[pattern])
+	}
 	
 	return selector
 }
@@ -443,18 +667,36 @@ func (s *DefaultTemplateSelector) RegisterTemplate(format TemplateFormat, resour
 	s.mappings[format][resourceType] = templateName
 }
 
-// RegisterPatternTemplate registers a fallback pattern for resources without specific templates
+// RegisterPatternTemplate registers a fallback pattern for resources without specific
+// templates. A pattern registered later is tried before the earlier ones, so that patterns
+// registered at startup, such as those of the config file, win over the built-in ones, and
+// registering a pattern again replaces its template.
 func (s *DefaultTemplateSelector) RegisterPatternTemplate(format TemplateFormat, pattern string, templateName string) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	
-	// Initialize patterns for format if it doesn't exist
-	if _, ok := s.patterns[format]; !ok {
-		s.patterns[format] = make(map[string]string)
+	patterns := []patternTemplate{{pattern: pattern, templateName: templateName}}
+	for _, registered := range s.patterns[format] {
+		if registered.pattern != pattern {
+			patterns = append(patterns, registered)
+		}
 	}
-	
-	// Register the pattern
-	s.patterns[format][pattern] = templateName
+	s.patterns[format] = patterns
+}
+
+// ResourceTypes returns the resource types a template is mapped to for a format, sorted
+func (s *DefaultTemplateSelector) ResourceTypes(format TemplateFormat, templateName string) []models.ResourceType {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var resourceTypes []models.ResourceType
+	for resourceType, name := range s.mappings[format] {
+		if name == templateName {
+			resourceTypes = append(resourceTypes, resourceType)
+		}
+	}
+	sort.Slice(resourceTypes, func(i, j int) bool { return resourceTypes[i] < resourceTypes[j] })
+	return resourceTypes
 }
 
 // SelectTemplate selects the appropriate template for the given resource and format
@@ -477,14 +719,14 @@ func (s *DefaultTemplateSelector) SelectTemplate(format TemplateFormat, resource
 	if patterns, ok := s.patterns[format]; ok {
 		resourceTypeStr := string(resource.Type)
 		
-		for pattern, templateName := range patterns {
-			matched, err := regexp.MatchString(pattern, resourceTypeStr)
+		for _, pattern := range patterns {
+			matched, err := regexp.MatchString(pattern.pattern, resourceTypeStr)
 			if err != nil {
 				continue
 			}
 			
 			if matched {
-				return templateName, nil
+				return pattern.templateName, nil
 			}
 		}
 	}
@@ -563,9 +805,10 @@ func (r *TemplateRenderer) RenderResource(format TemplateFormat, resource *model
 		return "", err
 	}
 	
-	// Create template data with global context
+	// Create template data with the values and the global context
 	r.mutex.RLock()
-	data := make(map[string]interface{}, len(r.globalContext)+1)
+	data := make(map[string]interface{}, len(r.globalContext)+2)
+	data[ValuesKey] = r.manager.Values()
 	for k, v := range r.globalContext {
 		data[k] = v
 	}
@@ -577,7 +820,7 @@ func (r *TemplateRenderer) RenderResource(format TemplateFormat, resource *model
 	// Render template
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", fmt.Errorf("failed to render template %s: %w", templateName, err)
+		return "", r.manager.migrationError(format, templateName, fmt.Errorf("failed to render template %s: %w", templateName, err))
 	}
 	
 	return buf.String(), nil
@@ -587,25 +830,20 @@ func (r *TemplateRenderer) RenderResource(format TemplateFormat, resource *model
 func (r *TemplateRenderer) RenderResources(format TemplateFormat, resources []models.Resource) (string, error) {
 	var result bytes.Buffer
 	
+	// Create template data with the values and the global context
+	r.mutex.RLock()
+	data := make(map[string]interface{}, len(r.globalContext)+1)
+	data[ValuesKey] = r.manager.Values()
+	for k, v := range r.globalContext {
+		data[k] = v
+	}
+	r.mutex.RUnlock()
+	
 	// First try to render a header template
 	headerTemplate := fmt.Sprintf("%s_header.tmpl", format)
-	
-	// Try to get and render the header template
-	tmpl, err := r.manager.GetTemplate(format, headerTemplate)
-	if err == nil {
-		// Create template data with global context
-		r.mutex.RLock()
-		data := make(map[string]interface{}, len(r.globalContext))
-		for k, v := range r.globalContext {
-			data[k] = v
-		}
-		r.mutex.RUnlock()
-		
-		var headerBuf bytes.Buffer
-		if err := tmpl.Execute(&headerBuf, data); err == nil {
-			result.WriteString(headerBuf.String())
-			result.WriteString("\n")
-		}
+	if header, err := r.manager.RenderPartial(format, headerTemplate, data); err == nil {
+		result.WriteString(header)
+		result.WriteString("\n")
 	}
 	
 	// Then render each resource
@@ -621,22 +859,8 @@ func (r *TemplateRenderer) RenderResources(format TemplateFormat, resources []mo
 	
 	// Finally try to render a footer template
 	footerTemplate := fmt.Sprintf("%s_footer.tmpl", format)
-	
-	// Try to get and render the footer template
-	tmpl, err = r.manager.GetTemplate(format, footerTemplate)
-	if err == nil {
-		// Create template data with global context
-		r.mutex.RLock()
-		data := make(map[string]interface{}, len(r.globalContext))
-		for k, v := range r.globalContext {
-			data[k] = v
-		}
-		r.mutex.RUnlock()
-		
-		var footerBuf bytes.Buffer
-		if err := tmpl.Execute(&footerBuf, data); err == nil {
-			result.WriteString(footerBuf.String())
-		}
+	if footer, err := r.manager.RenderPartial(format, footerTemplate, data); err == nil {
+		result.WriteString(footer)
 	}
 	
 	return result.String(), nil
@@ -9,11 +9,13 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	hclpos "github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclparse"
 	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/riptano/iac_generator_cli/internal/report"
 	"github.com/riptano/iac_generator_cli/pkg/models"
 	"gopkg.in/yaml.v3"
 )
@@ -34,6 +36,12 @@ const (
 type ValidationOptions struct {
 	Level   ValidationLevel
 	TempDir string // Directory for temporary files during validation
+	// SchemaLocations are where strict validation finds the schemas of the Crossplane
+	// manifests' kinds (default: report.DefaultSchemaLocations)
+	SchemaLocations []string
+	// Terraform is how strict validation runs terraform: the terraform installed, or in a
+	// pinned docker image with the providers from a plugin cache or mirror
+	Terraform report.TerraformRunner
 }
 
 // DefaultValidationOptions returns default validation options
@@ -74,7 +82,7 @@ func (v *HCLValidator) Validate(content string, options ValidationOptions) error
 
 	// Strict validation with terraform validate
 	if options.Level == ValidationLevelStrict {
-		return v.validateWithTerraform(content, options.TempDir)
+		return v.validateWithTerraform(content, options)
 	}
 
 	return nil
@@ -90,9 +98,9 @@ func formatHCL(content string) string {
 }
 
 // validateWithTerraform validates HCL with the terraform validate command
-func (v *HCLValidator) validateWithTerraform(content string, tempDir string) error {
+func (v *HCLValidator) validateWithTerraform(content string, options ValidationOptions) error {
 	// Create a temporary directory for validation
-	dir, err := ioutil.TempDir(tempDir, "terraform-validate-")
+	dir, err := ioutil.TempDir(options.TempDir, "terraform-validate-")
 	if err != nil {
 		return fmt.Errorf("failed to create temporary directory: %w", err)
 	}
@@ -104,24 +112,24 @@ func (v *HCLValidator) validateWithTerraform(content string, tempDir string) err
 		return fmt.Errorf("failed to write temporary file: %w", err)
 	}
 
-	// Check if terraform is available
-	_, err = exec.LookPath("terraform")
+	// Check if terraform, or docker to run it in, is available
+	terraform, err := options.Terraform.Resolve()
 	if err != nil {
-		return fmt.Errorf("terraform not found in PATH, skipping strict validation")
+		return fmt.Errorf("%v, skipping strict validation", err)
+	}
+	dataDir := filepath.Join(dir, ".terraform")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
 	}
 
 	// Initialize terraform
-	initCmd := exec.Command("terraform", "init", "-no-color")
-	initCmd.Dir = dir
-	initOutput, err := initCmd.CombinedOutput()
+	initOutput, err := terraform.Run(dir, dir, dataDir, terraform.InitArgs()...)
 	if err != nil {
 		return fmt.Errorf("terraform init failed: %s", string(initOutput))
 	}
 
 	// Validate terraform
-	validateCmd := exec.Command("terraform", "validate", "-no-color")
-	validateCmd.Dir = dir
-	validateOutput, err := validateCmd.CombinedOutput()
+	validateOutput, err := terraform.Run(dir, dir, dataDir, "validate", "-no-color")
 	if err != nil {
 		return fmt.Errorf("terraform validate failed: %s", string(validateOutput))
 	}
@@ -145,11 +153,51 @@ func (v *YAMLValidator) Validate(content string, options ValidationOptions) erro
 		return fmt.Errorf("invalid YAML syntax: %w", err)
 	}
 
-	// Check for required fields in Crossplane resources
+	// Check for required fields in Crossplane resources, then the fields of their schemas
 	if options.Level == ValidationLevelStrict {
-		return v.validateCrossplaneYAML(data)
+		if err := v.validateCrossplaneYAML(data); err != nil {
+			return err
+		}
+		return v.validateWithKubeconform(content, options)
+	}
+
+	return nil
+}
+
+// validateWithKubeconform validates YAML against the schemas of its kinds, such as the CRDs of
+// the Crossplane providers, with kubeconform. Fields a schema does not define, such as a
+// misspelled reference, are rejected; kinds without a schema are not checked.
+func (v *YAMLValidator) validateWithKubeconform(content string, options ValidationOptions) error {
+	kubeconform, err := exec.LookPath("kubeconform")
+	if err != nil {
+		return fmt.Errorf("kubeconform not found in PATH, skipping strict validation")
+	}
+
+	// Write content to a temporary file
+	file, err := ioutil.TempFile(options.TempDir, "crossplane-validate-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
 	}
+	file.Close()
 
+	results, err := report.RunKubeconform(kubeconform, options.SchemaLocations, file.Name())
+	if err != nil {
+		return err
+	}
+	var problems []string
+	for _, result := range results {
+		if result.Invalid() {
+			problems = append(problems, result.Messages()...)
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("schema validation failed: %s", strings.Join(problems, "; "))
+	}
 	return nil
 }
 
@@ -278,7 +326,7 @@ func ValidateResourceTemplate(manager *TemplateManager, format TemplateFormat, t
 	return ValidateRenderedContent(format, buf.String())
 }
 
-// AnalyzeTemplate analyzes a template for variables and resource types
+// AnalyzeTemplate analyzes a template for variables, resource types and the properties it reads
 func AnalyzeTemplate(templateContent string) (map[string]interface{}, error) {
 	result := map[string]interface{}{
 		"variables": []string{},
@@ -338,5 +386,19 @@ func AnalyzeTemplate(templateContent string) (map[string]interface{}, error) {
 	}
 	result["requiredProperties"] = propList
 
+	// Find every property read, with getProperty or hasProperty or by name in a range over
+	// the resource's properties
+	readRegex := regexp.MustCompile(`(?:(?:getProperty|hasProperty)\s+\.Resource|eq\s+\.Name)\s+"([^"]+)"`)
+	read := make(map[string]bool)
+	for _, match := range readRegex.FindAllStringSubmatch(templateContent, -1) {
+		read[match[1]] = true
+	}
+	readList := make([]string, 0, len(read))
+	for p := range read {
+		readList = append(readList, p)
+	}
+	sort.Strings(readList)
+	result["properties"] = readList
+
 	return result, nil
 }
\ No newline at end of file
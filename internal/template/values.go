@@ -0,0 +1,90 @@
+package template
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValuesKey is the key of the template data holding the values of --values and --set, such
+// as {{ .Values.artifact_bucket }}
+const ValuesKey = "Values"
+
+// LoadValues returns the values of YAML files merged in order, with the key=value settings
+// applied after them, so that later files and settings override earlier ones. The keys of a
+// setting are separated by dots, such as sso.role_arn=arn:aws:iam::123456789012:role/admin,
+// and its value is a string, so that account IDs and ARNs are kept as written.
+func LoadValues(files []string, settings []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read values file: %w", err)
+		}
+		var fileValues map[string]interface{}
+		if err := yaml.Unmarshal(data, &fileValues); err != nil {
+			return nil, fmt.Errorf("invalid values file %s: %w", file, err)
+		}
+		mergeValues(values, fileValues)
+	}
+
+	for _, setting := range settings {
+		key, value, ok := strings.Cut(setting, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid value: %q (use key=value)", setting)
+		}
+		keys := strings.Split(key, ".")
+		for _, k := range keys {
+			if k == "" {
+				return nil, fmt.Errorf("invalid value: %q (separate the keys with single dots)", setting)
+			}
+		}
+		values = setValue(values, keys, value)
+	}
+	return values, nil
+}
+
+// mergeValues merges values into dst, merging the maps both have and replacing the rest
+func mergeValues(dst, values map[string]interface{}) {
+	for key, value := range values {
+		if source, ok := value.(map[string]interface{}); ok {
+			if existing, ok := dst[key].(map[string]interface{}); ok {
+				mergeValues(existing, source)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
+
+// setValue sets the value at a path of keys, replacing a value on the path that is not a map
+func setValue(values map[string]interface{}, keys []string, value string) map[string]interface{} {
+	if len(keys) == 1 {
+		values[keys[0]] = value
+		return values
+	}
+	child, ok := values[keys[0]].(map[string]interface{})
+	if !ok {
+		child = make(map[string]interface{})
+	}
+	values[keys[0]] = setValue(child, keys[1:], value)
+	return values
+}
+
+// SetValues sets the values templates are rendered with as .Values, such as the values of
+// --values and --set
+func (tm *TemplateManager) SetValues(values map[string]interface{}) {
+	tm.values = values
+}
+
+// Values returns the values templates are rendered with as .Values, which are empty unless
+// set, so that templates can read them with {{ with .Values.key }}
+func (tm *TemplateManager) Values() map[string]interface{} {
+	if tm.values == nil {
+		return map[string]interface{}{}
+	}
+	return tm.values
+}
@@ -0,0 +1,130 @@
+package template
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// TemplateContextVersion is the version of the data and functions templates are rendered
+// with. A template declares the version it was written for with a header on its first
+// line, such as {{/* iacgen-template-version: 2 */ -}}, and a template written for an older
+// version, or declaring none, is checked for the changes made since.
+const TemplateContextVersion = 2
+
+// templateVersionPattern matches the version header of a template
+var templateVersionPattern = regexp.MustCompile(`^\{\{-?\s*/\*\s*iacgen-template-version:\s*(\d+)\s*\*/\s*-?\}\}`)
+
+// contextChange is a change of the template context that breaks templates written before it
+type contextChange struct {
+	// version is the context version that made the change
+	version int
+	// pattern matches what templates written before the change use
+	pattern *regexp.Regexp
+	// migration tells how to update a template
+	migration string
+}
+
+// contextChanges are the changes of the template context, oldest first
+var contextChanges = []contextChange{
+	{
+		version:   2,
+		pattern:   regexp.MustCompile(`(\|\s*default\s)|(\{\{-?\s*default\s)`),
+		migration: `the default function was renamed defaultValue and takes the value first: replace {{ .region | default "us-east-1" }} with {{ defaultValue .region "us-east-1" }}`,
+	},
+	{
+		version:   2,
+		pattern:   regexp.MustCompile(`contains\s+\(?\$?\.Resource\.Properties\b`),
+		migration: `contains only searches strings: replace {{ if contains .Resource.Properties "allocation_id" }} with {{ if hasProperty .Resource "allocation_id" }}`,
+	},
+}
+
+// TemplateMigration is a change of the template context since the version a template
+// declares that the template is affected by
+type TemplateMigration struct {
+	// Line is the line of the template using what changed
+	Line int
+	// Version is the context version that made the change
+	Version int
+	// Message tells how to update the template
+	Message string
+}
+
+// TemplateVersion returns the context version a template declares in its header, and false
+// when it declares none, in which case it is taken to be written for version 1
+func TemplateVersion(content []byte) (int, bool) {
+	match := templateVersionPattern.FindSubmatch(content)
+	if match == nil {
+		return 1, false
+	}
+	version, err := strconv.Atoi(string(match[1]))
+	if err != nil {
+		return 1, false
+	}
+	return version, true
+}
+
+// TemplateMigrations returns the changes of the template context since the version a
+// template declares that it uses, each on the first line using it
+func TemplateMigrations(content []byte) []TemplateMigration {
+	version, _ := TemplateVersion(content)
+	var migrations []TemplateMigration
+	for _, change := range contextChanges {
+		if change.version <= version {
+			continue
+		}
+		if index := change.pattern.FindIndex(content); index != nil {
+			migrations = append(migrations, TemplateMigration{
+				Line:    lineOf(content, index[0]),
+				Version: change.version,
+				Message: change.migration,
+			})
+		}
+	}
+	return migrations
+}
+
+// migrationError explains an error parsing or rendering a template with the changes of the
+// template context since the version the template declares, if it is affected by any, so
+// that a template written for an older version fails with what to change rather than with
+// the error of text/template alone
+func (tm *TemplateManager) migrationError(format TemplateFormat, templateName string, err error) error {
+	content, readErr := tm.readTemplate(format, templateName)
+	if readErr != nil {
+		return err
+	}
+	return withMigrations(format, templateName, content, err)
+}
+
+// withMigrations adds to an error of a template the changes of the template context it is
+// affected by, if any
+func withMigrations(format TemplateFormat, templateName string, content []byte, err error) error {
+	migrations := TemplateMigrations(content)
+	if len(migrations) == 0 {
+		return err
+	}
+	version, _ := TemplateVersion(content)
+	lines := make([]string, len(migrations))
+	for i, migration := range migrations {
+		lines[i] = fmt.Sprintf("line %d: %s", migration.Line, migration.Message)
+	}
+	return fmt.Errorf("template %s/%s is written for template version %d, and version %d changed what it uses (%s): %w",
+		format, templateName, version, TemplateContextVersion, strings.Join(lines, "; "), err)
+}
+
+// warnMigrations logs the changes of the template context a template is affected by, and
+// that it is written for a newer version than this binary renders
+func warnMigrations(format TemplateFormat, templateName string, content []byte) {
+	logger := utils.GetLogger()
+	name := fmt.Sprintf("%s/%s", format, templateName)
+	version, _ := TemplateVersion(content)
+	if version > TemplateContextVersion {
+		logger.Warnw("Template is written for a newer template version; upgrade iacgen", "template", name, "version", version, "supported", TemplateContextVersion)
+	}
+	for _, migration := range TemplateMigrations(content) {
+		logger.Warnw("Template uses what a later template version changed", "template", name, "line", migration.Line, "version", version, "migration", migration.Message)
+	}
+}
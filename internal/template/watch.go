@@ -0,0 +1,70 @@
+package template
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/riptano/iac_generator_cli/internal/utils"
+)
+
+// WatchOverrideDir clears the template cache whenever a template in the override directory
+// is written, created, renamed or removed, so that a long-running server renders the
+// templates being edited without waiting for the cache to expire. It watches until the
+// context is done, and does nothing without an override directory.
+func (tm *TemplateManager) WatchOverrideDir(ctx context.Context) error {
+	if tm.overrideDir == "" {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch templates: %w", err)
+	}
+	// The watches are not recursive, so each format directory and its partials are watched
+	// as well
+	dirs := []string{tm.overrideDir}
+	for _, format := range []TemplateFormat{FormatTerraform, FormatCrossplane} {
+		dirs = append(dirs, filepath.Join(tm.overrideDir, string(format)), filepath.Join(tm.overrideDir, string(format), CommonDir))
+	}
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch templates in %s: %w", dir, err)
+		}
+	}
+
+	logger := utils.GetLogger()
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				// A format or partials directory created after the watch started is watched too
+				if event.Has(fsnotify.Create) && (filepath.Dir(event.Name) == filepath.Clean(tm.overrideDir) || filepath.Base(event.Name) == CommonDir) {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						_ = watcher.Add(event.Name)
+					}
+				}
+				tm.RefreshCache()
+				logger.Debugw("Template changed, cleared the template cache", "path", event.Name, "op", event.Op.String())
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warnw("Watching templates failed", "dir", tm.overrideDir, "error", err)
+			}
+		}
+	}()
+	return nil
+}
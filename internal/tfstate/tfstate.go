@@ -0,0 +1,500 @@
+// Package tfstate imports the resources of a Terraform state into an infrastructure model, so
+// infrastructure managed with Terraform can be converted to Crossplane or generated again
+package tfstate
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/riptano/iac_generator_cli/internal/spec"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+)
+
+// StateVersion is the version of the state format the import reads, written by Terraform 0.12
+// and later
+const StateVersion = 4
+
+// state is the part of a Terraform state the import reads
+type state struct {
+	Version   int             `json:"version"`
+	Resources []stateResource `json:"resources"`
+}
+
+// stateResource is a resource block of a state, with an instance for each of its count or
+// for_each keys
+type stateResource struct {
+	Module    string          `json:"module,omitempty"`
+	Mode      string          `json:"mode"`
+	Type      string          `json:"type"`
+	Name      string          `json:"name"`
+	Instances []stateInstance `json:"instances"`
+}
+
+// stateInstance is an instance of a resource, with the attributes AWS reported for it
+type stateInstance struct {
+	IndexKey   interface{}            `json:"index_key,omitempty"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// Result is the model imported from a state, with the addresses of the resource instances
+// it was imported from and of those of unsupported types, which were skipped
+type Result struct {
+	Model    *models.InfrastructureModel
+	Imported []string
+	Skipped  []string
+}
+
+// converter converts the instances of a Terraform resource type
+type converter struct {
+	resourceType models.ResourceType
+	// identity is the attribute naming the resource in AWS, which names it in the model too
+	identity string
+	convert  func(i *importer, resource *models.Resource, attributes map[string]interface{})
+}
+
+// converters are the converters of the supported Terraform resource types
+var converters = map[string]converter{
+	"aws_vpc": {resourceType: models.ResourceVPC, convert: func(i *importer, resource *models.Resource, attributes map[string]interface{}) {
+		resource.AddProperty("cidr_block", stringAttribute(attributes, "cidr_block"))
+		resource.AddProperty("enable_dns_support", boolAttribute(attributes, "enable_dns_support"))
+		resource.AddProperty("enable_dns_hostnames", boolAttribute(attributes, "enable_dns_hostnames"))
+	}},
+	"aws_subnet": {resourceType: models.ResourceSubnet, convert: func(i *importer, resource *models.Resource, attributes map[string]interface{}) {
+		resource.AddProperty("vpc_id", i.reference(stringAttribute(attributes, "vpc_id")))
+		resource.AddProperty("cidr_block", stringAttribute(attributes, "cidr_block"))
+		resource.AddProperty("availability_zone", stringAttribute(attributes, "availability_zone"))
+		resource.AddProperty("map_public_ip_on_launch", boolAttribute(attributes, "map_public_ip_on_launch"))
+	}},
+	"aws_internet_gateway": {resourceType: models.ResourceIGW, convert: func(i *importer, resource *models.Resource, attributes map[string]interface{}) {
+		resource.AddProperty("vpc_id", i.reference(stringAttribute(attributes, "vpc_id")))
+	}},
+	"aws_nat_gateway": {resourceType: models.ResourceNATGateway, convert: func(i *importer, resource *models.Resource, attributes map[string]interface{}) {
+		resource.AddProperty("subnet_id", i.reference(stringAttribute(attributes, "subnet_id")))
+		if allocationID := stringAttribute(attributes, "allocation_id"); allocationID != "" {
+			resource.AddProperty("allocation_id", allocationID)
+		}
+		resource.AddProperty("connectivity_type", stringAttributeOr(attributes, "connectivity_type", "public"))
+	}},
+	"aws_eks_cluster": {resourceType: models.ResourceEKSCluster, identity: "name", convert: func(i *importer, resource *models.Resource, attributes map[string]interface{}) {
+		resource.AddProperty("name", resource.Name)
+		resource.AddProperty("role_arn", stringAttribute(attributes, "role_arn"))
+		resource.AddProperty("version", stringAttribute(attributes, "version"))
+		vpcConfig := blockAttribute(attributes, "vpc_config")
+		resource.AddProperty("vpc_config", map[string]interface{}{
+			"subnet_ids":              i.references(stringsAttribute(vpcConfig, "subnet_ids")),
+			"endpoint_public_access":  boolAttribute(vpcConfig, "endpoint_public_access"),
+			"endpoint_private_access": boolAttribute(vpcConfig, "endpoint_private_access"),
+		})
+	}},
+	"aws_eks_node_group": {resourceType: models.ResourceNodeGroup, identity: "node_group_name", convert: func(i *importer, resource *models.Resource, attributes map[string]interface{}) {
+		resource.AddProperty("cluster_name", i.reference(stringAttribute(attributes, "cluster_name")))
+		resource.AddProperty("node_role_arn", stringAttribute(attributes, "node_role_arn"))
+		resource.AddProperty("subnet_ids", i.references(stringsAttribute(attributes, "subnet_ids")))
+		scaling := blockAttribute(attributes, "scaling_config")
+		resource.AddProperty("scaling_config", map[string]interface{}{
+			"desired_size": intAttribute(scaling, "desired_size"),
+			"min_size":     intAttribute(scaling, "min_size"),
+			"max_size":     intAttribute(scaling, "max_size"),
+		})
+		resource.AddProperty("instance_types", stringsAttribute(attributes, "instance_types"))
+	}},
+	"aws_eks_addon": {resourceType: models.ResourceEKSAddon, convert: func(i *importer, resource *models.Resource, attributes map[string]interface{}) {
+		cluster := i.reference(stringAttribute(attributes, "cluster_name"))
+		resource.AddProperty("cluster_name", cluster)
+		resource.AddProperty("addon_name", stringAttribute(attributes, "addon_name"))
+		if version := stringAttribute(attributes, "addon_version"); version != "" {
+			resource.AddProperty("addon_version", version)
+		}
+		resource.AddProperty("resolve_conflicts", stringAttributeOr(attributes, "resolve_conflicts_on_update", "OVERWRITE"))
+		i.dependOn(resource, cluster)
+	}},
+	"aws_eks_fargate_profile": {resourceType: models.ResourceFargateProfile, identity: "fargate_profile_name", convert: func(i *importer, resource *models.Resource, attributes map[string]interface{}) {
+		cluster := i.reference(stringAttribute(attributes, "cluster_name"))
+		resource.AddProperty("cluster_name", cluster)
+		resource.AddProperty("fargate_profile_name", resource.Name)
+		resource.AddProperty("subnet_ids", i.references(stringsAttribute(attributes, "subnet_ids")))
+		var namespaces []string
+		for _, selector := range blocksAttribute(attributes, "selector") {
+			if namespace := stringAttribute(selector, "namespace"); namespace != "" {
+				namespaces = append(namespaces, namespace)
+			}
+		}
+		resource.AddProperty("namespaces", namespaces)
+		i.dependOn(resource, cluster)
+	}},
+	"aws_security_group": {resourceType: models.ResourceSecurityGroup, identity: "name", convert: func(i *importer, resource *models.Resource, attributes map[string]interface{}) {
+		resource.AddProperty("name", resource.Name)
+		resource.AddProperty("description", stringAttribute(attributes, "description"))
+		resource.AddProperty("vpc_id", i.reference(stringAttribute(attributes, "vpc_id")))
+		for _, ruleType := range []string{"ingress", "egress"} {
+			var rules []map[string]interface{}
+			for _, rule := range blocksAttribute(attributes, ruleType) {
+				rules = append(rules, map[string]interface{}{
+					"protocol":    stringAttribute(rule, "protocol"),
+					"from_port":   intAttribute(rule, "from_port"),
+					"to_port":     intAttribute(rule, "to_port"),
+					"cidr_blocks": stringsAttribute(rule, "cidr_blocks"),
+				})
+			}
+			if len(rules) > 0 {
+				resource.AddProperty(ruleType, rules)
+			}
+		}
+	}},
+	"aws_instance": {resourceType: models.ResourceEC2Instance, convert: func(i *importer, resource *models.Resource, attributes map[string]interface{}) {
+		resource.AddProperty("instance_type", stringAttribute(attributes, "instance_type"))
+		resource.AddProperty("ami", stringAttribute(attributes, "ami"))
+		if subnetID := stringAttribute(attributes, "subnet_id"); subnetID != "" {
+			resource.AddProperty("subnet_id", i.reference(subnetID))
+		}
+		if groups := stringsAttribute(attributes, "vpc_security_group_ids"); len(groups) > 0 {
+			resource.AddProperty("vpc_security_group_ids", i.references(groups))
+		}
+		if keyName := stringAttribute(attributes, "key_name"); keyName != "" {
+			resource.AddProperty("key_name", keyName)
+		}
+		if boolAttribute(attributes, "associate_public_ip_address") {
+			resource.AddProperty("associate_public_ip_address", true)
+		}
+	}},
+	"aws_s3_bucket": {resourceType: models.ResourceS3Bucket, identity: "bucket", convert: func(i *importer, resource *models.Resource, attributes map[string]interface{}) {
+		resource.AddProperty("bucket", stringAttribute(attributes, "bucket"))
+		resource.AddProperty("acl", stringAttributeOr(attributes, "acl", "private"))
+		resource.AddProperty("versioning", boolAttribute(blockAttribute(attributes, "versioning"), "enabled"))
+	}},
+	"aws_launch_template": {resourceType: models.ResourceLaunchTemplate, identity: "name", convert: func(i *importer, resource *models.Resource, attributes map[string]interface{}) {
+		resource.AddProperty("name_prefix", resource.Name+"-")
+		resource.AddProperty("instance_type", stringAttribute(attributes, "instance_type"))
+		resource.AddProperty("image_id", stringAttribute(attributes, "image_id"))
+		// The state keeps the user data base64-encoded, as the API takes it
+		if userData := stringAttribute(attributes, "user_data"); userData != "" {
+			if decoded, err := base64.StdEncoding.DecodeString(userData); err == nil {
+				userData = string(decoded)
+			}
+			resource.AddProperty("user_data", userData)
+		}
+	}},
+	"aws_autoscaling_group": {resourceType: models.ResourceAutoScalingGroup, identity: "name", convert: func(i *importer, resource *models.Resource, attributes map[string]interface{}) {
+		resource.AddProperty("name", resource.Name)
+		launchTemplate := blockAttribute(attributes, "launch_template")
+		if id := stringAttribute(launchTemplate, "id"); id != "" {
+			name := i.reference(id)
+			resource.AddProperty("launch_template", name)
+			i.dependOn(resource, name)
+		}
+		resource.AddProperty("vpc_zone_identifier", i.references(stringsAttribute(attributes, "vpc_zone_identifier")))
+		resource.AddProperty("desired_capacity", intAttribute(attributes, "desired_capacity"))
+		resource.AddProperty("min_size", intAttribute(attributes, "min_size"))
+		resource.AddProperty("max_size", intAttribute(attributes, "max_size"))
+		resource.AddProperty("health_check_type", stringAttributeOr(attributes, "health_check_type", "EC2"))
+	}},
+}
+
+// SupportedTypes returns the Terraform resource types the import converts, sorted
+func SupportedTypes() []string {
+	types := make([]string, 0, len(converters))
+	for resourceType := range converters {
+		types = append(types, resourceType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// LoadFile imports the state in a file, or in stdin when the path is "-"
+func LoadFile(path string) (*Result, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	result, err := Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state %s: %w", path, err)
+	}
+	return result, nil
+}
+
+// Load imports the managed resources of supported types in a state into a model. Data
+// sources are left out, and resources of other types are skipped. The resources are named
+// after their name in AWS, their Name tag or their address, and refer to each other by these
+// names; IDs of resources the state does not have are kept as they are. Tags every resource
+// has become the tags of the model.
+func Load(data []byte) (*Result, error) {
+	var parsed state
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse state: %w", err)
+	}
+	if parsed.Version != StateVersion {
+		return nil, fmt.Errorf("unsupported state version %d (only version %d, written by Terraform 0.12 and later, can be imported)", parsed.Version, StateVersion)
+	}
+
+	i := &importer{names: make(map[string]bool), ids: make(map[string]string)}
+	result := &Result{Model: models.NewInfrastructureModel()}
+
+	// The resources are named first, so references to any of them can be resolved
+	type instance struct {
+		converter  converter
+		name       string
+		attributes map[string]interface{}
+	}
+	var instances []instance
+	for _, resource := range parsed.Resources {
+		if resource.Mode != "managed" {
+			continue
+		}
+		conv, supported := converters[resource.Type]
+		for _, stateInstance := range resource.Instances {
+			address := instanceAddress(resource, stateInstance)
+			if !supported {
+				result.Skipped = append(result.Skipped, address)
+				continue
+			}
+			attributes := stateInstance.Attributes
+			name := i.name(resource, stateInstance, conv)
+			for _, key := range []string{"id", "arn"} {
+				if id := stringAttribute(attributes, key); id != "" {
+					i.ids[id] = name
+				}
+			}
+			if identity := stringAttribute(attributes, conv.identity); conv.identity != "" && identity != "" {
+				i.ids[identity] = name
+			}
+			instances = append(instances, instance{converter: conv, name: name, attributes: attributes})
+			result.Imported = append(result.Imported, address)
+		}
+	}
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("the state has no resources that can be imported (supported types: %s)", strings.Join(SupportedTypes(), ", "))
+	}
+
+	var tagSets []map[string]string
+	for _, instance := range instances {
+		resource := models.NewResource(instance.converter.resourceType, instance.name)
+		instance.converter.convert(i, &resource, instance.attributes)
+		result.Model.AddResource(resource)
+		if tags, ok := tagsAttribute(instance.attributes); ok {
+			tagSets = append(tagSets, tags)
+		}
+		if result.Model.Region == "" {
+			result.Model.Region = attributeRegion(instance.attributes)
+		}
+	}
+	result.Model.Tags = commonTags(tagSets)
+
+	spec.NormalizeModel(result.Model)
+	if err := spec.ValidateModel(result.Model); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importer names the imported resources and resolves the references between them
+type importer struct {
+	// names are the model names given so far
+	names map[string]bool
+	// ids maps the IDs, ARNs and AWS names of the imported resources to their model names
+	ids map[string]string
+}
+
+// namePattern matches the characters a model name is made of
+var namePattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// name returns the model name of an instance: its name in AWS, its Name tag or its address,
+// made of lower-case letters, digits and hyphens. A name already given gets a number.
+func (i *importer) name(resource stateResource, instance stateInstance, conv converter) string {
+	var candidates []string
+	if conv.identity != "" {
+		candidates = append(candidates, stringAttribute(instance.Attributes, conv.identity))
+	}
+	if tags, ok := tagsAttribute(instance.Attributes); ok {
+		candidates = append(candidates, tags["Name"])
+	}
+	address := resource.Name
+	switch key := instance.IndexKey.(type) {
+	case float64:
+		address += "-" + strconv.Itoa(int(key)+1)
+	case string:
+		address += "-" + key
+	}
+	candidates = append(candidates, address, strings.TrimPrefix(resource.Type, "aws_")+"-"+address)
+
+	name := ""
+	for _, candidate := range candidates {
+		candidate = strings.Trim(namePattern.ReplaceAllString(strings.ToLower(candidate), "-"), "-")
+		if candidate != "" && candidate[0] >= 'a' && candidate[0] <= 'z' {
+			name = candidate
+			break
+		}
+	}
+	unique := name
+	for n := 2; i.names[unique]; n++ {
+		unique = name + "-" + strconv.Itoa(n)
+	}
+	i.names[unique] = true
+	return unique
+}
+
+// reference returns the model name of the resource an ID refers to, or the ID when the
+// state does not have the resource
+func (i *importer) reference(id string) string {
+	if name, ok := i.ids[id]; ok {
+		return name
+	}
+	return id
+}
+
+// references returns the model names of the resources IDs refer to
+func (i *importer) references(ids []string) []string {
+	names := make([]string, len(ids))
+	for n, id := range ids {
+		names[n] = i.reference(id)
+	}
+	return names
+}
+
+// dependOn adds a dependency on a resource of the model, leaving out resources it does not have
+func (i *importer) dependOn(resource *models.Resource, name string) {
+	if i.names[name] {
+		resource.AddDependency(name)
+	}
+}
+
+// instanceAddress returns the address of an instance, such as module.vpc.aws_subnet.public[0]
+func instanceAddress(resource stateResource, instance stateInstance) string {
+	address := resource.Type + "." + resource.Name
+	if resource.Module != "" {
+		address = resource.Module + "." + address
+	}
+	switch key := instance.IndexKey.(type) {
+	case float64:
+		address += "[" + strconv.Itoa(int(key)) + "]"
+	case string:
+		address += "[" + strconv.Quote(key) + "]"
+	}
+	return address
+}
+
+// attributeRegion returns the region of a resource, from its ARN or its availability zone
+func attributeRegion(attributes map[string]interface{}) string {
+	if parts := strings.Split(stringAttribute(attributes, "arn"), ":"); len(parts) > 3 && parts[3] != "" {
+		return parts[3]
+	}
+	if zone := stringAttribute(attributes, "availability_zone"); len(zone) > 1 {
+		return zone[:len(zone)-1]
+	}
+	return ""
+}
+
+// tagsAttribute returns the tags of a resource, including the provider's default tags, and
+// whether it has tags
+func tagsAttribute(attributes map[string]interface{}) (map[string]string, bool) {
+	for _, key := range []string{"tags_all", "tags"} {
+		values, ok := attributes[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		tags := make(map[string]string, len(values))
+		for tag, value := range values {
+			if str, ok := value.(string); ok {
+				tags[tag] = str
+			}
+		}
+		return tags, true
+	}
+	return nil, false
+}
+
+// commonTags returns the tags, other than Name, with the same value in every set
+func commonTags(tagSets []map[string]string) map[string]string {
+	if len(tagSets) == 0 {
+		return nil
+	}
+	common := make(map[string]string)
+	for key, value := range tagSets[0] {
+		if key != "Name" {
+			common[key] = value
+		}
+	}
+	for _, tags := range tagSets[1:] {
+		for key, value := range common {
+			if tags[key] != value {
+				delete(common, key)
+			}
+		}
+	}
+	if len(common) == 0 {
+		return nil
+	}
+	return common
+}
+
+// stringAttribute returns a string attribute, or "" when it is not set
+func stringAttribute(attributes map[string]interface{}, key string) string {
+	value, _ := attributes[key].(string)
+	return value
+}
+
+// stringAttributeOr returns a string attribute, or a default when it is not set
+func stringAttributeOr(attributes map[string]interface{}, key, defaultValue string) string {
+	if value := stringAttribute(attributes, key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// boolAttribute returns a boolean attribute, or false when it is not set
+func boolAttribute(attributes map[string]interface{}, key string) bool {
+	value, _ := attributes[key].(bool)
+	return value
+}
+
+// intAttribute returns a number attribute as an int, or 0 when it is not set
+func intAttribute(attributes map[string]interface{}, key string) int {
+	value, _ := attributes[key].(float64)
+	return int(value)
+}
+
+// stringsAttribute returns a list or set of strings
+func stringsAttribute(attributes map[string]interface{}, key string) []string {
+	values, _ := attributes[key].([]interface{})
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		if str, ok := value.(string); ok {
+			strs = append(strs, str)
+		}
+	}
+	return strs
+}
+
+// blocksAttribute returns the nested blocks of an attribute
+func blocksAttribute(attributes map[string]interface{}, key string) []map[string]interface{} {
+	values, _ := attributes[key].([]interface{})
+	var blocks []map[string]interface{}
+	for _, value := range values {
+		if block, ok := value.(map[string]interface{}); ok {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// blockAttribute returns the first nested block of an attribute, or an empty block
+func blockAttribute(attributes map[string]interface{}, key string) map[string]interface{} {
+	if blocks := blocksAttribute(attributes, key); len(blocks) > 0 {
+		return blocks[0]
+	}
+	return map[string]interface{}{}
+}
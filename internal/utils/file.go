@@ -5,8 +5,22 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
+var (
+	fileWriteHook   func(path string, size int)
+	fileWriteHookMu sync.RWMutex
+)
+
+// SetFileWriteHook sets a function called with the path and size of each file WriteToFile
+// writes, such as to report the files of a run as they are written (nil for none)
+func SetFileWriteHook(hook func(path string, size int)) {
+	fileWriteHookMu.Lock()
+	defer fileWriteHookMu.Unlock()
+	fileWriteHook = hook
+}
+
 // WriteToFile writes content to a file, creating the file and directories if they don't exist
 func WriteToFile(path string, content string) error {
 	// Create directory if it doesn't exist
@@ -20,6 +34,12 @@ func WriteToFile(path string, content string) error {
 		return fmt.Errorf("failed to write to file %s: %w", path, err)
 	}
 
+	fileWriteHookMu.RLock()
+	hook := fileWriteHook
+	fileWriteHookMu.RUnlock()
+	if hook != nil {
+		hook(path, len(content))
+	}
 	return nil
 }
 
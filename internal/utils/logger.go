@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"io"
 	"os"
 	"sync"
 
@@ -10,10 +11,17 @@ import (
 )
 
 var (
-	logger *zap.SugaredLogger
-	once   sync.Once
+	logger    *zap.SugaredLogger
+	once      sync.Once
+	logOutput io.Writer = os.Stdout
 )
 
+// SetLogOutput sets where the logger writes, which is stdout by default. It only takes
+// effect when called before the first call to GetLogger.
+func SetLogOutput(w io.Writer) {
+	logOutput = w
+}
+
 // GetLogger returns a singleton logger instance
 func GetLogger() *zap.SugaredLogger {
 	once.Do(func() {
@@ -38,7 +46,7 @@ func GetLogger() *zap.SugaredLogger {
 		// Create core
 		core := zapcore.NewCore(
 			zapcore.NewConsoleEncoder(encoderConfig),
-			zapcore.AddSync(os.Stdout),
+			zapcore.AddSync(logOutput),
 			level,
 		)
 
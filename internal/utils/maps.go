@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"sort"
+)
+
+// SortedKeys returns the keys of a map in order, so that what is built from the map is the
+// same on every run
+func SortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dependencies returns the names of the resources a resource depends on: its explicit
+// dependencies, then the resources its properties refer to by name, such as a subnet's
+// vpc_id, in the order they first appear
+func (m *InfrastructureModel) Dependencies(resource *Resource) []string {
+	names := make(map[string]bool, len(m.Resources))
+	for _, r := range m.Resources {
+		names[r.Name] = true
+	}
+
+	dependsOn := append([]string{}, resource.DependsOn...)
+	for _, property := range resource.Properties {
+		dependsOn = append(dependsOn, referencedNames(property.Value, names)...)
+	}
+
+	var unique []string
+	seen := map[string]bool{resource.Name: true}
+	for _, name := range dependsOn {
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
+
+// referencedNames returns the resource names a property value refers to
+func referencedNames(value interface{}, names map[string]bool) []string {
+	var referenced []string
+	switch v := value.(type) {
+	case string:
+		if names[v] {
+			referenced = append(referenced, v)
+		}
+	case []string:
+		for _, item := range v {
+			referenced = append(referenced, referencedNames(item, names)...)
+		}
+	case []interface{}:
+		for _, item := range v {
+			referenced = append(referenced, referencedNames(item, names)...)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			referenced = append(referenced, referencedNames(v[key], names)...)
+		}
+	}
+	return referenced
+}
+
+// GraphFormat is a format the dependency graph of a model is rendered in
+type GraphFormat string
+
+// Supported graph formats
+const (
+	// GraphDOT renders the graph in the Graphviz DOT language
+	GraphDOT GraphFormat = "dot"
+	// GraphMermaid renders the graph as a Mermaid flowchart, which GitHub renders in Markdown
+	GraphMermaid GraphFormat = "mermaid"
+)
+
+// GraphFormats are the supported graph formats
+var GraphFormats = []GraphFormat{GraphDOT, GraphMermaid}
+
+// GraphExporter renders the dependencies of a model's resources as a graph, with an edge from
+// each resource to each resource it depends on
+type GraphExporter struct {
+	format GraphFormat
+}
+
+// NewGraphExporter creates a GraphExporter rendering graphs in the given format
+func NewGraphExporter(format GraphFormat) (*GraphExporter, error) {
+	for _, known := range GraphFormats {
+		if format == known {
+			return &GraphExporter{format: format}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid graph format: %s (supported formats: dot, mermaid)", format)
+}
+
+// graphEdge is a dependency of a resource, from the index of the resource to the name of the
+// resource it depends on
+type graphEdge struct {
+	from int
+	to   string
+}
+
+// Export renders the graph of a model. Resources are nodes labeled with their type and name,
+// in the order of the model. A dependency on a resource the model does not have is drawn as
+// a dashed node of its own, so a dangling reference stands out.
+func (e *GraphExporter) Export(model *InfrastructureModel) string {
+	index := make(map[string]int, len(model.Resources))
+	for i, resource := range model.Resources {
+		if _, ok := index[resource.Name]; !ok {
+			index[resource.Name] = i
+		}
+	}
+
+	var edges []graphEdge
+	var missing []string
+	seenMissing := make(map[string]bool)
+	for i := range model.Resources {
+		for _, name := range model.Dependencies(&model.Resources[i]) {
+			edges = append(edges, graphEdge{from: i, to: name})
+			if _, ok := index[name]; !ok && !seenMissing[name] {
+				seenMissing[name] = true
+				missing = append(missing, name)
+			}
+		}
+	}
+
+	// Nodes are identified by position, since resource names may not be valid identifiers
+	nodeID := func(name string) string {
+		if i, ok := index[name]; ok {
+			return "r" + strconv.Itoa(i)
+		}
+		for i, dangling := range missing {
+			if dangling == name {
+				return "m" + strconv.Itoa(i)
+			}
+		}
+		return ""
+	}
+
+	var buf strings.Builder
+	switch e.format {
+	case GraphMermaid:
+		buf.WriteString("flowchart LR\n")
+		for i, resource := range model.Resources {
+			fmt.Fprintf(&buf, "    r%d[\"%s<br/>%s\"]\n", i, mermaidText(string(resource.Type)), mermaidText(resource.Name))
+		}
+		for i, name := range missing {
+			fmt.Fprintf(&buf, "    m%d[\"%s<br/>(not in the model)\"]\n", i, mermaidText(name))
+			fmt.Fprintf(&buf, "    style m%d stroke-dasharray: 5 5\n", i)
+		}
+		for _, edge := range edges {
+			fmt.Fprintf(&buf, "    r%d --> %s\n", edge.from, nodeID(edge.to))
+		}
+	default:
+		buf.WriteString("digraph infrastructure {\n")
+		buf.WriteString("  rankdir=LR;\n")
+		buf.WriteString("  node [shape=box];\n")
+		for i, resource := range model.Resources {
+			fmt.Fprintf(&buf, "  r%d [label=%s];\n", i, strconv.Quote(string(resource.Type)+"\n"+resource.Name))
+		}
+		for i, name := range missing {
+			fmt.Fprintf(&buf, "  m%d [label=%s, style=dashed];\n", i, strconv.Quote(name+"\n(not in the model)"))
+		}
+		for _, edge := range edges {
+			fmt.Fprintf(&buf, "  r%d -> %s;\n", edge.from, nodeID(edge.to))
+		}
+		buf.WriteString("}\n")
+	}
+	return buf.String()
+}
+
+// mermaidText escapes text for a quoted Mermaid label
+func mermaidText(text string) string {
+	return strings.ReplaceAll(text, "\"", "#quot;")
+}
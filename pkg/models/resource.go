@@ -19,6 +19,10 @@ const (
 	ResourceNATGateway    ResourceType = "nat_gateway"
 	ResourceEKSCluster    ResourceType = "eks_cluster"
 	ResourceNodeGroup     ResourceType = "eks_node_group"
+	ResourceLaunchTemplate   ResourceType = "launch_template"
+	ResourceAutoScalingGroup ResourceType = "autoscaling_group"
+	ResourceEKSAddon         ResourceType = "eks_addon"
+	ResourceFargateProfile   ResourceType = "eks_fargate_profile"
 )
 
 // Property represents a resource property
@@ -38,6 +42,12 @@ type Resource struct {
 // InfrastructureModel represents the complete infrastructure model
 type InfrastructureModel struct {
 	Resources []Resource `json:"resources"`
+	// Region is the primary region, used for resources without a region property
+	Region string `json:"region,omitempty"`
+	// Tags are applied to every resource, in addition to the resource's own tags
+	Tags map[string]string `json:"tags,omitempty"`
+	// Environment names the environment the model is for, such as "staging"
+	Environment string `json:"environment,omitempty"`
 }
 
 // NewResource creates a new resource with the given type and name
@@ -57,6 +67,27 @@ func (r *Resource) AddProperty(name string, value interface{}) {
 	})
 }
 
+// SetProperty sets a property on a resource, replacing any existing value
+func (r *Resource) SetProperty(name string, value interface{}) {
+	for i, property := range r.Properties {
+		if property.Name == name {
+			r.Properties[i].Value = value
+			return
+		}
+	}
+	r.AddProperty(name, value)
+}
+
+// GetProperty returns the value of a property and whether it exists
+func (r *Resource) GetProperty(name string) (interface{}, bool) {
+	for _, property := range r.Properties {
+		if property.Name == name {
+			return property.Value, true
+		}
+	}
+	return nil, false
+}
+
 // AddDependency adds a dependency to a resource
 func (r *Resource) AddDependency(resourceName string) {
 	r.DependsOn = append(r.DependsOn, resourceName)
@@ -72,4 +103,33 @@ func NewInfrastructureModel() *InfrastructureModel {
 // AddResource adds a resource to the infrastructure model
 func (m *InfrastructureModel) AddResource(resource Resource) {
 	m.Resources = append(m.Resources, resource)
-}
\ No newline at end of file
+}
+
+// ResourceRegion returns the region of a resource: its region property, or the model's
+// primary region when it has none
+func (m *InfrastructureModel) ResourceRegion(resource *Resource) string {
+	if value, ok := resource.GetProperty("region"); ok {
+		if region, ok := value.(string); ok && region != "" {
+			return region
+		}
+	}
+	return m.Region
+}
+
+// SecondaryRegions returns the regions of resources outside the primary region, in the
+// order they first appear. A model without a primary region has no secondary regions.
+func (m *InfrastructureModel) SecondaryRegions() []string {
+	if m.Region == "" {
+		return nil
+	}
+	var regions []string
+	seen := map[string]bool{m.Region: true}
+	for i := range m.Resources {
+		region := m.ResourceRegion(&m.Resources[i])
+		if !seen[region] {
+			seen[region] = true
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
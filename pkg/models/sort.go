@@ -0,0 +1,72 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SortedResources returns the resources of the model ordered so that each comes after the
+// resources it depends on, as returned by Dependencies. Resources that could come in either
+// order keep the order of the model, so sorting an already sorted model changes nothing and
+// the same model always gives the same order. Dependencies on resources the model does not
+// have are ignored; a dependency cycle is an error.
+func (m *InfrastructureModel) SortedResources() ([]Resource, error) {
+	indices := make(map[string][]int, len(m.Resources))
+	for i, resource := range m.Resources {
+		indices[resource.Name] = append(indices[resource.Name], i)
+	}
+
+	// Each resource waits for the resources of the model it depends on
+	waiting := make([]map[int]bool, len(m.Resources))
+	for i := range m.Resources {
+		waiting[i] = make(map[int]bool)
+		for _, name := range m.Dependencies(&m.Resources[i]) {
+			for _, j := range indices[name] {
+				waiting[i][j] = true
+			}
+		}
+	}
+
+	sorted := make([]Resource, 0, len(m.Resources))
+	emitted := make([]bool, len(m.Resources))
+	for len(sorted) < len(m.Resources) {
+		// Emit the first resource in model order whose dependencies were all emitted
+		next := -1
+		for i := range m.Resources {
+			if !emitted[i] && len(waiting[i]) == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			var cycle []string
+			for i, resource := range m.Resources {
+				if !emitted[i] {
+					cycle = append(cycle, resource.Name)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle between resources: %s", strings.Join(cycle, ", "))
+		}
+
+		emitted[next] = true
+		sorted = append(sorted, m.Resources[next])
+		for i := range waiting {
+			delete(waiting[i], next)
+		}
+	}
+	return sorted, nil
+}
+
+// Sorted returns a copy of the model with its resources in dependency order, as returned by
+// SortedResources. The model itself is left as it is. The generators write the resources of
+// the sorted model, so each is written after those it depends on, in the same order on every
+// run.
+func (m *InfrastructureModel) Sorted() (*InfrastructureModel, error) {
+	resources, err := m.SortedResources()
+	if err != nil {
+		return nil, err
+	}
+	sorted := *m
+	sorted.Resources = resources
+	return &sorted, nil
+}
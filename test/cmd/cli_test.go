@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"archive/tar"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -224,7 +227,7 @@ resources:
 			},
 			expectedCode: 1,
 			expectError: []string{
-				"either provide a description as an argument or specify an input file with --file",
+				"either provide a description as an argument, specify an input file with --file, a spec with --spec or a saved model with --from-model",
 			},
 			expectedFiles: []string{},
 		},
@@ -512,6 +515,54 @@ func TestCLIRegionFlag(t *testing.T) {
 	}
 }
 
+// TestCLICompletion tests the completion scripts and the dynamic completion of flag values
+func TestCLICompletion(t *testing.T) {
+	// Skip this test if it's a short run
+	if testing.Short() {
+		t.Skip("Skipping CLI execution test in short mode")
+	}
+
+	// Find the binary to test
+	binaryPath, err := findBinaryPath()
+	if err != nil {
+		t.Skipf("Skipping test due to missing binary: %v", err)
+		return
+	}
+	// Extract the temp directory from the binary path for cleanup
+	binDir := filepath.Dir(binaryPath)
+	defer os.RemoveAll(binDir)
+
+	// The profiles come from the project config file in the working directory
+	workDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "iacgen.yaml"), []byte("profiles:\n  Prod:\n    aws_region: us-west-2\n  sandbox: {}\n"), 0644))
+
+	run := func(args ...string) string {
+		cmd := exec.Command(binaryPath, args...)
+		cmd.Dir = workDir
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		require.NoError(t, cmd.Run(), "stderr: %s", stderr.String())
+		return stdout.String()
+	}
+
+	for shell, header := range map[string]string{
+		"bash":       "# bash completion V2 for iacgen",
+		"zsh":        "#compdef iacgen",
+		"fish":       "# fish completion for iacgen",
+		"powershell": "# powershell completion for iacgen",
+	} {
+		assert.True(t, strings.HasPrefix(run("completion", shell), header), "The %s script should be the only output", shell)
+	}
+
+	assert.True(t, strings.HasPrefix(run("__complete", "generate", "--output", ""), "terraform\tTerraform HCL\ncrossplane\tCrossplane manifests\n:4\n"))
+	assert.Contains(t, run("__complete", "diff", "--dr-region", ""), "\neu-west-1\n")
+	assert.True(t, strings.HasPrefix(run("__complete", "explain", "--profile", ""), "Prod\nsandbox\n:4\n"), "Profile names should keep their case")
+
+	cmd := exec.Command(binaryPath, "completion", "tcsh")
+	assert.Error(t, cmd.Run(), "Unsupported shells should be rejected")
+}
+
 // TestCLIDebugFlag tests CLI command execution with debug flag
 func TestCLIDebugFlag(t *testing.T) {
 	// Skip this test if it's a short run
@@ -572,6 +623,475 @@ func TestCLIDebugFlag(t *testing.T) {
 	// This ensures the test is more robust to changes in logging behavior
 }
 
+
+// TestCLIWizard tests that the wizard builds a description from answers and writes it on confirm
+func TestCLIWizard(t *testing.T) {
+	// Skip this test if it's a short run
+	if testing.Short() {
+		t.Skip("Skipping CLI execution test in short mode")
+	}
+
+	// Find the binary to test
+	binaryPath, err := findBinaryPath()
+	if err != nil {
+		t.Skipf("Skipping test due to missing binary: %v", err)
+		return
+	}
+	// Extract the temp directory from the binary path for cleanup
+	binDir := filepath.Dir(binaryPath)
+	defer os.RemoveAll(binDir)
+
+	outputDir := filepath.Join(t.TempDir(), "infra")
+	// Region, CIDR, public and private subnets; internet and NAT gateways; EKS, version, nodes
+	// and instance type, with an invalid answer asked again; output format and directory;
+	// confirmation
+	answers := []string{"us-west-2", "10.1.0.0/16", "2", "0", "", "y", "1.29", "x", "4", "t3.large", "terraform", outputDir, "y"}
+
+	cmd := exec.Command(binaryPath, "wizard")
+	cmd.Stdin = strings.NewReader(strings.Join(answers, "\n") + "\n")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	require.NoError(t, cmd.Run(), "stderr: %s", stderr.String())
+
+	output := stdout.String()
+	assert.NotContains(t, output, "INFO", "Logs should not be mixed with the wizard")
+	assert.NotContains(t, output, "NAT gateway for outbound traffic", "The NAT gateway needs private subnets")
+	assert.Contains(t, output, "please enter a number from 1 to 100")
+	assert.Contains(t, output, "Description: Create a VPC with CIDR 10.1.0.0/16 in us-west-2 with 2 public subnets, an internet gateway and without a NAT gateway, and an EKS cluster version 1.29 with 4 nodes of instance type t3.large\n")
+	assert.Contains(t, output, "└── modules/\n    ├── eks/\n")
+	assert.Contains(t, output, "Files: the same")
+	assert.Contains(t, output, "Wrote 14 terraform files to "+outputDir)
+	assert.FileExists(t, filepath.Join(outputDir, "modules", "eks", "main.tf"))
+
+	// Declining writes nothing, and input that ends early is an error
+	declined := filepath.Join(t.TempDir(), "declined")
+	answers[11], answers[12] = declined, "n"
+	cmd = exec.Command(binaryPath, "wizard")
+	cmd.Stdin = strings.NewReader(strings.Join(answers, "\n") + "\n")
+	output, err = runOutput(cmd)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Nothing was written.")
+	assert.NoDirExists(t, declined)
+
+	cmd = exec.Command(binaryPath, "wizard")
+	cmd.Stdin = strings.NewReader("us-west-2\n")
+	output, err = runOutput(cmd)
+	assert.Error(t, err)
+	assert.Contains(t, output, "input ended before the wizard was finished")
+}
+
+// TestCLIPipeMode tests reading the description from stdin and writing the files to stdout
+func TestCLIPipeMode(t *testing.T) {
+	// Skip this test if it's a short run
+	if testing.Short() {
+		t.Skip("Skipping CLI execution test in short mode")
+	}
+
+	// Find the binary to test
+	binaryPath, err := findBinaryPath()
+	if err != nil {
+		t.Skipf("Skipping test due to missing binary: %v", err)
+		return
+	}
+	// Extract the temp directory from the binary path for cleanup
+	binDir := filepath.Dir(binaryPath)
+	defer os.RemoveAll(binDir)
+
+	workDir := t.TempDir()
+	run := func(args ...string) (string, string, error) {
+		cmd := exec.Command(binaryPath, args...)
+		cmd.Dir = workDir
+		cmd.Stdin = strings.NewReader("Create a VPC with an EKS cluster\n")
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		return stdout.String(), stderr.String(), err
+	}
+
+	stdout, stderr, err := run("generate", "-", "--output", "terraform", "--stdout")
+	require.NoError(t, err, "stderr: %s", stderr)
+	assert.True(t, strings.HasPrefix(stdout, "# Source: main.tf\n"), "Only the document should be written to stdout")
+	assert.Contains(t, stdout, "\n# Source: modules/eks/main.tf\n")
+	assert.Contains(t, stderr, "Assumptions", "The assumptions report should be written to stderr")
+
+	stdout, stderr, err = run("generate", "-", "--output", "crossplane", "--stdout", "--tar")
+	require.NoError(t, err, "stderr: %s", stderr)
+	header, err := tar.NewReader(strings.NewReader(stdout)).Next()
+	require.NoError(t, err)
+	assert.Equal(t, "README.md", header.Name)
+
+	entries, err := os.ReadDir(workDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "Nothing should be written to the filesystem")
+
+	_, stderr, err = run("generate", "-", "--stdout", "--output-dir", "infra")
+	assert.Error(t, err)
+	assert.Contains(t, stderr, "do not combine it with --output-dir")
+	_, stderr, err = run("generate", "-", "--review")
+	assert.Error(t, err)
+	assert.Contains(t, stderr, "cannot be combined with a description read from stdin")
+}
+
+func TestCLIDryRun(t *testing.T) {
+	// Skip this test if it's a short run
+	if testing.Short() {
+		t.Skip("Skipping CLI execution test in short mode")
+	}
+
+	// Find the binary to test
+	binaryPath, err := findBinaryPath()
+	if err != nil {
+		t.Skipf("Skipping test due to missing binary: %v", err)
+		return
+	}
+	// Extract the temp directory from the binary path for cleanup
+	binDir := filepath.Dir(binaryPath)
+	defer os.RemoveAll(binDir)
+
+	workDir := t.TempDir()
+	cmd := exec.Command(binaryPath, "generate", "Create a VPC with an EKS cluster with 3 nodes",
+		"--output", "crossplane", "--output-dir", "infra", "--non-interactive", "--dry-run")
+	cmd.Dir = workDir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	require.NoError(t, cmd.Run(), "stderr: %s", stderr.String())
+
+	assert.True(t, strings.HasPrefix(stdout.String(), "infra/\n"), "The plan should start with the file tree")
+	assert.Contains(t, stdout.String(), "vpc.yaml (created)\n")
+	assert.Contains(t, stdout.String(), "nothing was written\n")
+	assert.NoDirExists(t, filepath.Join(workDir, "infra"), "Nothing should be written in a dry run")
+
+	cmd = exec.Command(binaryPath, "generate", "Create a VPC", "--dry-run", "--stdout")
+	cmd.Dir = workDir
+	output, err := runOutput(cmd)
+	assert.Error(t, err)
+	assert.Contains(t, output, "do not combine it with --stdout")
+}
+
+func TestCLIProgressJSON(t *testing.T) {
+	// Skip this test if it's a short run
+	if testing.Short() {
+		t.Skip("Skipping CLI execution test in short mode")
+	}
+
+	// Find the binary to test
+	binaryPath, err := findBinaryPath()
+	if err != nil {
+		t.Skipf("Skipping test due to missing binary: %v", err)
+		return
+	}
+	// Extract the temp directory from the binary path for cleanup
+	binDir := filepath.Dir(binaryPath)
+	defer os.RemoveAll(binDir)
+
+	workDir := t.TempDir()
+	cmd := exec.Command(binaryPath, "generate", "Create a VPC with 2 public subnets",
+		"--output", "crossplane", "--output-dir", "infra", "--non-interactive", "--progress", "json")
+	cmd.Dir = workDir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	require.NoError(t, cmd.Run(), "stderr: %s", stderr.String())
+
+	// Every line of stdout is an event
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		var event struct {
+			Event string `json:"event"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(line), &event), line)
+		events = append(events, event.Event)
+	}
+	assert.Equal(t, "pipeline_started", events[0])
+	assert.Equal(t, "pipeline_completed", events[len(events)-1])
+	assert.Contains(t, events, "stage_completed")
+	assert.Contains(t, events, "file_written")
+	assert.Contains(t, stderr.String(), "Starting IaC generation pipeline", "Text progress should go to stderr")
+
+	cmd = exec.Command(binaryPath, "generate", "Create a VPC", "--progress", "yaml")
+	cmd.Dir = workDir
+	output, err := runOutput(cmd)
+	assert.Error(t, err)
+	assert.Contains(t, output, "invalid progress format: yaml (use text or json)")
+}
+
+func TestCLIMetrics(t *testing.T) {
+	// Skip this test if it's a short run
+	if testing.Short() {
+		t.Skip("Skipping CLI execution test in short mode")
+	}
+
+	// Find the binary to test
+	binaryPath, err := findBinaryPath()
+	if err != nil {
+		t.Skipf("Skipping test due to missing binary: %v", err)
+		return
+	}
+	// Extract the temp directory from the binary path for cleanup
+	binDir := filepath.Dir(binaryPath)
+	defer os.RemoveAll(binDir)
+
+	workDir := t.TempDir()
+	cmd := exec.Command(binaryPath, "generate", "Create a VPC with 2 public subnets",
+		"--output-dir", "infra", "--non-interactive", "--metrics", "--metrics-file", "metrics.json")
+	cmd.Dir = workDir
+	output, err := runOutput(cmd)
+	require.NoError(t, err, output)
+	assert.Contains(t, output, "Stage metrics:")
+	assert.Regexp(t, `EnvironmentGeneration\s+\S+\s+\d+\s+[\d.]+ KiB`, output)
+
+	content, err := os.ReadFile(filepath.Join(workDir, "metrics.json"))
+	require.NoError(t, err)
+	var report struct {
+		Files  int `json:"files"`
+		Stages []struct {
+			Stage string `json:"stage"`
+		} `json:"stages"`
+	}
+	require.NoError(t, json.Unmarshal(content, &report))
+	assert.Positive(t, report.Files)
+	require.NotEmpty(t, report.Stages)
+	assert.Equal(t, "NLPProcessing", report.Stages[0].Stage)
+}
+
+func TestCLIPlugins(t *testing.T) {
+	// Skip this test if it's a short run
+	if testing.Short() {
+		t.Skip("Skipping CLI execution test in short mode")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("the plugin is a shell script")
+	}
+
+	// Find the binary to test
+	binaryPath, err := findBinaryPath()
+	if err != nil {
+		t.Skipf("Skipping test due to missing binary: %v", err)
+		return
+	}
+	// Extract the temp directory from the binary path for cleanup
+	binDir := filepath.Dir(binaryPath)
+	defer os.RemoveAll(binDir)
+
+	workDir := t.TempDir()
+	pluginDir := filepath.Join(workDir, "plugins")
+	require.NoError(t, os.MkdirAll(pluginDir, 0755))
+	script := `#!/bin/sh
+case "$1" in
+describe) echo '{"protocol_version":1,"name":"pulumi","kind":"generator","format":"pulumi","description":"Pulumi YAML programs"}' ;;
+generate) cat > /dev/null; printf '%s\n' '{"files":{"Pulumi.yaml":"name: infra\n"}}' ;;
+esac
+`
+	require.NoError(t, os.WriteFile(filepath.Join(pluginDir, "pulumi"), []byte(script), 0755))
+
+	cmd := exec.Command(binaryPath, "plugins", "--plugin-dir", "plugins")
+	cmd.Dir = workDir
+	output, err := runOutput(cmd)
+	require.NoError(t, err, output)
+	assert.Contains(t, output, "pulumi  generator  pulumi  Pulumi YAML programs")
+
+	cmd = exec.Command(binaryPath, "generate", "Create a VPC", "--output", "pulumi", "--output-dir", "infra",
+		"--plugin-dir", "plugins", "--non-interactive")
+	cmd.Dir = workDir
+	output, err = runOutput(cmd)
+	require.NoError(t, err, output)
+	assert.Equal(t, "name: infra\n", utils.LoadFileContent(t, filepath.Join(workDir, "infra", "Pulumi.yaml")))
+
+	cmd = exec.Command(binaryPath, "generate", "Create a VPC", "--plugin-dir", "missing")
+	cmd.Dir = workDir
+	output, err = runOutput(cmd)
+	assert.Error(t, err)
+	assert.Contains(t, output, "plugin directory does not exist: missing")
+}
+
+func TestCLITemplates(t *testing.T) {
+	// Skip this test if it's a short run
+	if testing.Short() {
+		t.Skip("Skipping CLI execution test in short mode")
+	}
+
+	// Find the binary to test
+	binaryPath, err := findBinaryPath()
+	if err != nil {
+		t.Skipf("Skipping test due to missing binary: %v", err)
+		return
+	}
+	// Extract the temp directory from the binary path for cleanup
+	binDir := filepath.Dir(binaryPath)
+	defer os.RemoveAll(binDir)
+
+	workDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(workDir, "templates", "terraform"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "templates", "terraform", "vpc.tmpl"),
+		[]byte("resource \"aws_vpc\" \"x\" {\n  cidr_block = \"{{ getProperty .Resource \"cidr\" }}\"\n}\n"), 0644))
+
+	cmd := exec.Command(binaryPath, "templates", "list", "--template-dir", "templates", "--output", "terraform")
+	cmd.Dir = workDir
+	output, err := runOutput(cmd)
+	require.NoError(t, err, output)
+	assert.Regexp(t, `terraform +vpc\.tmpl +vpc +templates/terraform/vpc\.tmpl`, output)
+	assert.Regexp(t, `terraform +subnet\.tmpl +subnet +embedded`, output)
+
+	cmd = exec.Command(binaryPath, "templates", "lint", "--template-dir", "templates")
+	cmd.Dir = workDir
+	output, err = runOutput(cmd)
+	require.NoError(t, err, output)
+	assert.Contains(t, output, `warning  terraform/vpc.tmpl:2  reads the property "cidr"`)
+	assert.Contains(t, output, "0 errors, 1 warnings")
+
+	cmd = exec.Command(binaryPath, "templates", "test", "vpc.tmpl", "--template-dir", "templates")
+	cmd.Dir = workDir
+	output, err = runOutput(cmd)
+	assert.Error(t, err)
+	assert.Contains(t, output, "error  terraform/vpc.tmpl  renders <no value>")
+	assert.Contains(t, output, "Tested 2 templates: 1 errors, 0 warnings")
+
+	cmd = exec.Command(binaryPath, "templates", "test", "waf.tmpl")
+	cmd.Dir = workDir
+	output, err = runOutput(cmd)
+	assert.Error(t, err)
+	assert.Contains(t, output, "no template waf.tmpl")
+
+	// Scaffolded templates are mapped in the config file, and render resources of their type
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "iacgen.yaml"), []byte("use_templates: true\n"), 0644))
+	cmd = exec.Command(binaryPath, "templates", "scaffold", "--type", "aws_elasticache_cluster", "--config", "iacgen.yaml",
+		"--template-dir", "templates")
+	cmd.Dir = workDir
+	output, err = runOutput(cmd)
+	require.NoError(t, err, output)
+	assert.Contains(t, output, "Created "+filepath.Join("templates", "terraform", "elasticache_cluster.tmpl"))
+	assert.Contains(t, output, "Created "+filepath.Join("templates", "crossplane", "elasticache_cluster.tmpl"))
+	assert.Equal(t, `use_templates: true
+template_mappings:
+  terraform:
+    elasticache_cluster: elasticache_cluster.tmpl
+  crossplane:
+    elasticache_cluster: elasticache_cluster.tmpl
+`, utils.LoadFileContent(t, filepath.Join(workDir, "iacgen.yaml")))
+
+	model := `{"region": "us-east-1", "resources": [{"type": "elasticache_cluster", "name": "cache", "properties": [{"name": "engine", "value": "redis"}]}]}`
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "model.json"), []byte(model), 0644))
+	cmd = exec.Command(binaryPath, "generate", "--from-model", "model.json", "--config", "iacgen.yaml",
+		"--template-dir", "templates", "--output-dir", "infra", "--non-interactive")
+	cmd.Dir = workDir
+	output, err = runOutput(cmd)
+	require.NoError(t, err, output)
+	assert.Contains(t, utils.LoadFileContent(t, filepath.Join(workDir, "infra", "main.tf")), `resource "aws_elasticache_cluster" "cache" {`)
+
+	// Templates read the values of --values, overridden by --set
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "values.yaml"),
+		[]byte("artifacts:\n  bucket: org-artifacts\nsso_role_arn: arn:aws:iam::111111111111:role/readonly\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "templates", "terraform", "elasticache_cluster.tmpl"),
+		[]byte("resource \"aws_elasticache_cluster\" \"{{ .Resource.Name }}\" {\n  engine = \"redis\"\n  tags = {\n    Artifacts = \"{{ .Values.artifacts.bucket }}\"\n    Role = \"{{ .Values.sso_role_arn }}\"\n  }\n}\n"), 0644))
+	cmd = exec.Command(binaryPath, "generate", "--from-model", "model.json", "--config", "iacgen.yaml",
+		"--template-dir", "templates", "--output-dir", "infra", "--non-interactive",
+		"--values", "values.yaml", "--set", "sso_role_arn=arn:aws:iam::123456789012:role/admin")
+	cmd.Dir = workDir
+	output, err = runOutput(cmd)
+	require.NoError(t, err, output)
+	mainTF := utils.LoadFileContent(t, filepath.Join(workDir, "infra", "main.tf"))
+	assert.Regexp(t, `Artifacts += "org-artifacts"`, mainTF)
+	assert.Regexp(t, `Role += "arn:aws:iam::123456789012:role/admin"`, mainTF)
+
+	cmd = exec.Command(binaryPath, "templates", "test", "terraform/elasticache_cluster.tmpl", "--config", "iacgen.yaml",
+		"--template-dir", "templates", "--set", "artifacts")
+	cmd.Dir = workDir
+	output, err = runOutput(cmd)
+	assert.Error(t, err)
+	assert.Contains(t, output, `invalid value: "artifacts" (use key=value)`)
+
+	// The config file maps resource types, and the types patterns match, to the team's templates
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "team.yaml"), []byte(`use_templates: true
+template_mappings:
+  terraform:
+    vpc: team_vpc.tmpl
+template_patterns:
+  terraform:
+    - pattern: ^memorydb_
+      template: memorydb.tmpl
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "templates", "terraform", "team_vpc.tmpl"),
+		[]byte("resource \"aws_vpc\" \"{{ .Resource.Name }}\" {\n  cidr_block = \"10.42.0.0/16\"\n}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "templates", "terraform", "memorydb.tmpl"),
+		[]byte("resource \"aws_{{ .Resource.Type }}\" \"{{ .Resource.Name }}\" {\n  node_type = \"db.t4g.small\"\n}\n"), 0644))
+	model = `{"region": "us-east-1", "resources": [{"type": "vpc", "name": "core"}, {"type": "memorydb_cluster", "name": "sessions"}]}`
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "team-model.json"), []byte(model), 0644))
+	cmd = exec.Command(binaryPath, "generate", "--from-model", "team-model.json", "--config", "team.yaml",
+		"--template-dir", "templates", "--output-dir", "team", "--non-interactive")
+	cmd.Dir = workDir
+	output, err = runOutput(cmd)
+	require.NoError(t, err, output)
+	mainTF = utils.LoadFileContent(t, filepath.Join(workDir, "team", "main.tf"))
+	assert.Regexp(t, `cidr_block += "10.42.0.0/16"`, mainTF)
+	assert.Contains(t, mainTF, `resource "aws_memorydb_cluster" "sessions" {`)
+
+	cmd = exec.Command(binaryPath, "templates", "list", "--config", "team.yaml", "--template-dir", "templates", "--output", "terraform")
+	cmd.Dir = workDir
+	output, err = runOutput(cmd)
+	require.NoError(t, err, output)
+	assert.Regexp(t, `terraform +team_vpc\.tmpl +vpc +templates/terraform/team_vpc\.tmpl`, output)
+
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "invalid.yaml"), []byte(`template_patterns:
+  terraform:
+    - pattern: ^memorydb_(
+      template: memorydb.tmpl
+`), 0644))
+	cmd = exec.Command(binaryPath, "templates", "list", "--config", "invalid.yaml")
+	cmd.Dir = workDir
+	output, err = runOutput(cmd)
+	assert.Error(t, err)
+	assert.Contains(t, output, `invalid template_patterns: pattern "^memorydb_(" of terraform`)
+}
+
+func TestCLIImport(t *testing.T) {
+	// Skip this test if it's a short run
+	if testing.Short() {
+		t.Skip("Skipping CLI execution test in short mode")
+	}
+
+	// Find the binary to test
+	binaryPath, err := findBinaryPath()
+	if err != nil {
+		t.Skipf("Skipping test due to missing binary: %v", err)
+		return
+	}
+	// Extract the temp directory from the binary path for cleanup
+	binDir := filepath.Dir(binaryPath)
+	defer os.RemoveAll(binDir)
+
+	statePath, err := filepath.Abs(filepath.Join("..", "fixtures", "states", "eks.tfstate"))
+	require.NoError(t, err)
+	workDir := t.TempDir()
+	cmd := exec.Command(binaryPath, "import", "--state", statePath, "--save-model", "model.json")
+	cmd.Dir = workDir
+	output, err := runOutput(cmd)
+	require.NoError(t, err, output)
+	assert.Contains(t, output, "Imported 10 resources into model.json")
+	assert.Contains(t, output, "  module.eks.aws_iam_role.cluster")
+
+	// The imported model converts to Crossplane
+	cmd = exec.Command(binaryPath, "generate", "--from-model", "model.json", "--output", "crossplane",
+		"--output-dir", "crossplane", "--non-interactive")
+	cmd.Dir = workDir
+	output, err = runOutput(cmd)
+	require.NoError(t, err, output)
+	assert.FileExists(t, filepath.Join(workDir, "crossplane", "kustomization.yaml"))
+
+	cmd = exec.Command(binaryPath, "import")
+	cmd.Dir = workDir
+	output, err = runOutput(cmd)
+	assert.Error(t, err)
+	assert.Contains(t, output, "specify the state file to import with --state")
+}
+
+// runOutput runs a command and returns its combined output
+func runOutput(cmd *exec.Cmd) (string, error) {
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
 // Helper function to find the binary to test
 func findBinaryPath() (string, error) {
 	// First check for a built binary in the expected location
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/config"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes a config file and returns its path
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestReadConfigFiles(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	user := writeConfig(t, ".iacgen.yaml", `aws_region: eu-west-1
+nlp_backend: ollama
+tags:
+  Team: platform
+  CostCenter: 1234
+profiles:
+  prod:
+    aws_region: us-west-2
+    tags:
+      Team: sre
+`)
+	project := writeConfig(t, "iacgen.yaml", `default_type: crossplane
+aws_region: eu-central-1
+ollama:
+  model: qwen2.5:7b
+`)
+	missing := filepath.Join(t.TempDir(), "iacgen.yaml")
+
+	used, tags, err := config.ReadConfigFiles([]string{user, missing, project}, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{user, project}, used, "Missing files should be skipped")
+	assert.Equal(t, "eu-central-1", viper.GetString("aws_region"), "The project file should override the user's")
+	assert.Equal(t, "crossplane", viper.GetString("default_type"))
+	assert.Equal(t, "ollama", viper.GetString("nlp_backend"))
+	assert.Equal(t, "qwen2.5:7b", viper.GetString("ollama.model"))
+	assert.Equal(t, map[string]string{"Team": "platform", "CostCenter": "1234"}, tags, "Tag keys should keep their case")
+}
+
+func TestReadConfigFilesWithProfile(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	file := writeConfig(t, "iacgen.yaml", `aws_region: eu-west-1
+use_templates: true
+tags:
+  Team: platform
+  CostCenter: "1234"
+profiles:
+  Prod:
+    aws_region: us-west-2
+    tags:
+      Team: sre
+`)
+
+	_, tags, err := config.ReadConfigFiles([]string{file}, "prod")
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2", viper.GetString("aws_region"), "The profile should override the file's settings")
+	assert.True(t, viper.GetBool("use_templates"), "Settings the profile does not set should be kept")
+	assert.Equal(t, map[string]string{"Team": "sre", "CostCenter": "1234"}, tags)
+
+	viper.Reset()
+	_, _, err = config.ReadConfigFiles([]string{file}, "staging")
+	assert.EqualError(t, err, "profile staging is not defined in the config files (profiles: prod)")
+}
+
+func TestSetFileValue(t *testing.T) {
+	path := writeConfig(t, ".iacgen.yaml", `# Team defaults
+aws_region: eu-west-1
+template_mappings:
+  terraform:
+    waf: waf.tmpl
+`)
+	require.NoError(t, config.SetFileValue(path, []string{"template_mappings", "terraform", "elasticache_cluster"}, "elasticache_cluster.tmpl"))
+	require.NoError(t, config.SetFileValue(path, []string{"template_mappings", "crossplane", "elasticache_cluster"}, "elasticache_cluster.tmpl"))
+	require.NoError(t, config.SetFileValue(path, []string{"use_templates"}, "true"))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `# Team defaults
+aws_region: eu-west-1
+template_mappings:
+  terraform:
+    waf: waf.tmpl
+    elasticache_cluster: elasticache_cluster.tmpl
+  crossplane:
+    elasticache_cluster: elasticache_cluster.tmpl
+use_templates: "true"
+`, string(content), "The other settings and comments should be kept")
+
+	// A missing file is created
+	missing := filepath.Join(t.TempDir(), "home", ".iacgen.yaml")
+	require.NoError(t, config.SetFileValue(missing, []string{"template_dir"}, "/templates"))
+	content, err = os.ReadFile(missing)
+	require.NoError(t, err)
+	assert.Equal(t, "template_dir: /templates\n", string(content))
+
+	assert.Error(t, config.SetFileValue(path, []string{"aws_region", "primary"}, "us-east-1"), "A setting that is not a map has no keys")
+}
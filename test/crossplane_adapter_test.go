@@ -3,6 +3,7 @@ package test
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/riptano/iac_generator_cli/internal/adapter/crossplane"
@@ -121,4 +122,725 @@ func TestCrossplaneAdapter(t *testing.T) {
 			t.Errorf("Expected file not found: %s", file)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestK8sObjectAddTags(t *testing.T) {
+	tags := map[string]string{"team": "platform"}
+
+	cluster := crossplane.NewK8sObject("eks.aws.crossplane.io/v1beta1", "Cluster", "main")
+	cluster.AddNestedSpecField([]string{"forProvider", "tags"}, map[string]string{"Name": "main"})
+	cluster.AddTags(tags)
+	clusterTags := cluster.Spec["forProvider"].(map[string]interface{})["tags"].(map[string]string)
+	if clusterTags["team"] != "platform" || clusterTags["Name"] != "main" {
+		t.Errorf("Expected EKS tags to be merged into the tag map, got %v", clusterTags)
+	}
+
+	asg := crossplane.NewK8sObject("autoscaling.aws.crossplane.io/v1beta1", "AutoScalingGroup", "web")
+	asg.AddNestedSpecField([]string{"forProvider", "tags"}, []map[string]interface{}{
+		{"key": "Name", "value": "web", "propagateAtLaunch": true},
+	})
+	asg.AddTags(tags)
+	asgTags := asg.Spec["forProvider"].(map[string]interface{})["tags"].([]map[string]interface{})
+	if len(asgTags) != 2 || asgTags[1]["key"] != "team" || asgTags[1]["propagateAtLaunch"] != true {
+		t.Errorf("Expected the ASG tag to be appended and propagated, got %v", asgTags)
+	}
+
+	route := crossplane.NewK8sObject("ec2.aws.crossplane.io/v1beta1", "Route", "default")
+	route.AddNestedSpecField([]string{"forProvider", "destinationCidrBlock"}, "0.0.0.0/0")
+	route.AddTags(tags)
+	if _, ok := route.Spec["forProvider"].(map[string]interface{})["tags"]; ok {
+		t.Errorf("Routes cannot be tagged")
+	}
+}
+
+func TestCrossplaneGeneratorSecondaryRegions(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := models.NewInfrastructureModel()
+	model.Region = "us-east-1"
+	for _, region := range []string{"us-east-1", "us-west-2"} {
+		suffix := ""
+		if region != model.Region {
+			suffix = infra.RegionSuffix(region)
+		}
+		vpc := models.NewResource(models.ResourceVPC, "main-vpc"+suffix)
+		vpc.AddProperty("region", region)
+		subnet := models.NewResource(models.ResourceSubnet, "public-subnet-1"+suffix)
+		subnet.AddProperty("region", region)
+		subnet.AddProperty("availability_zone", region+"a")
+		subnet.AddProperty("map_public_ip_on_launch", true)
+		model.AddResource(vpc)
+		model.AddResource(subnet)
+	}
+
+	generator := crossplane.NewCrossplaneGenerator()
+	if err := generator.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize generator: %v", err)
+	}
+	if _, err := generator.Generate(model); err != nil {
+		t.Fatalf("Failed to generate Crossplane files: %v", err)
+	}
+
+	providerConfig, err := os.ReadFile(filepath.Join(tempDir, "base", "providerconfig.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to read providerconfig.yaml: %v", err)
+	}
+	if !strings.Contains(string(providerConfig), "name: aws-provider-us-west-2") ||
+		!strings.Contains(string(providerConfig), "region: us-west-2") {
+		t.Errorf("Expected a ProviderConfig for us-west-2, got:\n%s", providerConfig)
+	}
+
+	network, err := os.ReadFile(filepath.Join(tempDir, "vpc", "us-west-2.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to read the us-west-2 network: %v", err)
+	}
+	if strings.Count(string(network), "name: aws-provider-us-west-2") != strings.Count(string(network), "kind:") {
+		t.Errorf("Expected every us-west-2 object to use the regional ProviderConfig, got:\n%s", network)
+	}
+	if !strings.Contains(string(network), "name: public-rt-us-west-2") {
+		t.Errorf("Expected generated names to carry the region suffix, got:\n%s", network)
+	}
+
+	primary, err := os.ReadFile(filepath.Join(tempDir, "vpc", "subnets.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to read subnets.yaml: %v", err)
+	}
+	if strings.Contains(string(primary), "us-west-2") {
+		t.Errorf("Expected the primary subnets to stay in us-east-1, got:\n%s", primary)
+	}
+
+	kustomization, err := os.ReadFile(filepath.Join(tempDir, "vpc", "kustomization.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to read the VPC kustomization: %v", err)
+	}
+	if !strings.Contains(string(kustomization), "- us-west-2.yaml") {
+		t.Errorf("Expected the us-west-2 network in the VPC kustomization, got:\n%s", kustomization)
+	}
+}
+
+func TestCrossplaneGeneratorNodeGroupCapacityType(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := models.NewInfrastructureModel()
+	model.AddResource(infra.CreateEKSCluster("main-eks", "1.28", "role-arn", []string{"subnet-1"}, true, false))
+	onDemand := infra.CreateEKSNodeGroup("main-node-group-on-demand", "main-eks", "role-arn", []string{"subnet-1"}, []string{"m5.large"}, 3, 3, 6)
+	onDemand.AddProperty("capacity_type", "ON_DEMAND")
+	spot := infra.CreateEKSNodeGroup("main-node-group-spot", "main-eks", "role-arn", []string{"subnet-1"}, []string{"t3.large"}, 1, 1, 10)
+	spot.AddProperty("capacity_type", "SPOT")
+	model.AddResource(onDemand)
+	model.AddResource(spot)
+
+	generator := crossplane.NewCrossplaneGenerator()
+	if err := generator.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize generator: %v", err)
+	}
+	if _, err := generator.Generate(model); err != nil {
+		t.Fatalf("Failed to generate Crossplane files: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "eks", "nodegroup.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to read nodegroup.yaml: %v", err)
+	}
+	for _, expected := range []string{"name: main-node-group-spot", "capacityType: SPOT", "capacityType: ON_DEMAND", "maxSize: 10", "desiredSize: 3"} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected nodegroup.yaml to contain %s, got:\n%s", expected, content)
+		}
+	}
+}
+
+func TestCrossplaneGeneratorNodeGroupAMIType(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := models.NewInfrastructureModel()
+	model.AddResource(infra.CreateEKSCluster("main-eks", "1.30", "role-arn", []string{"subnet-1"}, true, false))
+	model.AddResource(infra.CreateEKSNodeGroup("main-node-group-arm", "main-eks", "role-arn", []string{"subnet-1"}, []string{"m7g.large"}, 2, 2, 4))
+	model.AddResource(infra.CreateEKSNodeGroup("main-node-group-x86", "main-eks", "role-arn", []string{"subnet-1"}, []string{"m5.large"}, 2, 2, 4))
+	gpu := infra.CreateEKSNodeGroup("main-node-group-gpu", "main-eks", "role-arn", []string{"subnet-1"}, []string{"g5.xlarge"}, 1, 1, 2)
+	gpu.AddProperty("ami_type", "AL2023_x86_64_NVIDIA")
+	model.AddResource(gpu)
+
+	generator := crossplane.NewCrossplaneGenerator()
+	if err := generator.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize generator: %v", err)
+	}
+	if _, err := generator.Generate(model); err != nil {
+		t.Fatalf("Failed to generate Crossplane files: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "eks", "nodegroup.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to read nodegroup.yaml: %v", err)
+	}
+	// Node groups without an AMI type get the default of their version and architecture
+	for _, expected := range []string{"amiType: AL2023_ARM_64_STANDARD", "amiType: AL2023_x86_64_STANDARD", "amiType: AL2023_x86_64_NVIDIA"} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected nodegroup.yaml to contain %s, got:\n%s", expected, content)
+		}
+	}
+}
+
+func TestCrossplaneGeneratorFargateProfiles(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := models.NewInfrastructureModel()
+	model.Region = "eu-west-1"
+	model.AddResource(infra.CreateEKSCluster("main-eks", "1.28", "role-arn", []string{"public-subnet-1", "private-subnet-1"}, true, false))
+	model.AddResource(infra.CreateFargateProfile("main-eks-fargate", "main-eks", []string{"private-subnet-1"}, []string{"kube-system", "app"}))
+
+	generator := crossplane.NewCrossplaneGenerator()
+	if err := generator.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize generator: %v", err)
+	}
+	if _, err := generator.Generate(model); err != nil {
+		t.Fatalf("Failed to generate Crossplane files: %v", err)
+	}
+
+	expectations := map[string][]string{
+		"fargate.yaml": {"kind: FargateProfile", "name: main-eks-fargate", "namespace: kube-system", "namespace: app", "name: private-subnet-1", "name: eks-fargate-pod-execution-role"},
+		"iam.yaml":     {"name: eks-fargate-pod-execution-role", "eks-fargate-pods.amazonaws.com", "AmazonEKSFargatePodExecutionRolePolicy"},
+	}
+	for file, expected := range expectations {
+		content, err := os.ReadFile(filepath.Join(tempDir, "eks", file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, text := range expected {
+			if !strings.Contains(string(content), text) {
+				t.Errorf("Expected %s to contain %s, got:\n%s", file, text, content)
+			}
+		}
+	}
+	fargate, _ := os.ReadFile(filepath.Join(tempDir, "eks", "fargate.yaml"))
+	if strings.Contains(string(fargate), "public-subnet-1") {
+		t.Errorf("Fargate pods should only run in private subnets, got:\n%s", fargate)
+	}
+}
+
+func TestCrossplaneGeneratorEKSAddons(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := models.NewInfrastructureModel()
+	model.Region = "eu-west-1"
+	model.AddResource(infra.CreateEKSCluster("main-eks", "1.28", "role-arn", []string{"subnet-1"}, true, false))
+	coredns, _ := infra.LookupEKSAddon("coredns")
+	ebs, _ := infra.LookupEKSAddon("aws-ebs-csi-driver")
+	model.AddResource(infra.CreateEKSAddon("main-eks-coredns", "main-eks", coredns, "v1.11.1-eksbuild.9"))
+	model.AddResource(infra.CreateEKSAddon("main-eks-aws-ebs-csi-driver", "main-eks", ebs, ""))
+
+	generator := crossplane.NewCrossplaneGenerator()
+	if err := generator.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize generator: %v", err)
+	}
+	if _, err := generator.Generate(model); err != nil {
+		t.Fatalf("Failed to generate Crossplane files: %v", err)
+	}
+
+	expectations := map[string][]string{
+		"addons.yaml": {"kind: Addon", "addonName: coredns", "addonVersion: v1.11.1-eksbuild.9", "region: eu-west-1", "name: main-eks-aws-ebs-csi-driver-irsa"},
+		"iam.yaml":    {"name: main-eks-aws-ebs-csi-driver-irsa", "oidc.eks.eu-west-1.amazonaws.com/id/OIDC_ID:sub", "system:serviceaccount:kube-system:ebs-csi-controller-sa", "AmazonEBSCSIDriverPolicy"},
+	}
+	for file, expected := range expectations {
+		content, err := os.ReadFile(filepath.Join(tempDir, "eks", file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, text := range expected {
+			if !strings.Contains(string(content), text) {
+				t.Errorf("Expected %s to contain %s, got:\n%s", file, text, content)
+			}
+		}
+	}
+}
+
+func TestCrossplaneGeneratorUpboundProvider(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := models.NewInfrastructureModel()
+	model.Region = "eu-west-1"
+	model.AddResource(infra.CreateVPC("main-vpc", "10.0.0.0/16", true, true))
+	model.AddResource(infra.CreateSubnet("private-subnet-1", "main-vpc", "10.0.10.0/24", "eu-west-1a"))
+	cluster := infra.CreateEKSCluster("main-eks", "1.28", "role-arn", []string{"private-subnet-1"}, true, false)
+	cluster.AddProperty("kubernetes_network_config", map[string]interface{}{"service_ipv4_cidr": "172.20.0.0/16"})
+	model.AddResource(cluster)
+	model.AddResource(infra.CreateEKSNodeGroup("main-eks-nodes", "main-eks", "node-role-arn", []string{"private-subnet-1"}, []string{"t3.medium"}, 2, 1, 3))
+
+	generator := crossplane.NewCrossplaneGenerator().WithProvider(crossplane.ProviderUpbound)
+	if err := generator.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize generator: %v", err)
+	}
+	if _, err := generator.Generate(model); err != nil {
+		t.Fatalf("Failed to generate Crossplane files: %v", err)
+	}
+
+	expectations := map[string][]string{
+		filepath.Join("base", "provider.yaml"):       {"xpkg.upbound.io/upbound/provider-aws-ec2", "xpkg.upbound.io/upbound/provider-aws-eks", "name: aws-config"},
+		filepath.Join("base", "providerconfig.yaml"): {"apiVersion: aws.upbound.io/v1beta1"},
+		filepath.Join("base", "runtime-config.yaml"): {"kind: DeploymentRuntimeConfig"},
+		filepath.Join("vpc", "vpc.yaml"):             {"apiVersion: ec2.aws.upbound.io/v1beta1", "region: eu-west-1"},
+		filepath.Join("eks", "cluster.yaml"):         {"apiVersion: eks.aws.upbound.io/v1beta1", "vpcConfig:", "region: eu-west-1", "kubernetesNetworkConfig:\n            - serviceIpv4Cidr: 172.20.0.0/16"},
+		filepath.Join("eks", "nodegroup.yaml"):       {"nodeRoleArnRef:", "scalingConfig:\n            - desiredSize: 2"},
+		filepath.Join("eks", "iam.yaml"):             {"apiVersion: iam.aws.upbound.io/v1beta1", "assumeRolePolicy:"},
+	}
+	for file, expected := range expectations {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, text := range expected {
+			if !strings.Contains(string(content), text) {
+				t.Errorf("Expected %s to contain %s, got:\n%s", file, text, content)
+			}
+		}
+		if strings.Contains(string(content), "aws.crossplane.io/") {
+			t.Errorf("Expected %s to use only the Upbound APIs, got:\n%s", file, content)
+		}
+	}
+	providerConfig, _ := os.ReadFile(filepath.Join(tempDir, "base", "providerconfig.yaml"))
+	if strings.Contains(string(providerConfig), "region:") {
+		t.Errorf("Expected the Upbound ProviderConfig to leave the region to the resources, got:\n%s", providerConfig)
+	}
+
+	if err := crossplane.ValidateProvider("upbound"); err != nil {
+		t.Errorf("Expected upbound to be a valid provider, got: %v", err)
+	}
+	if err := crossplane.ValidateProvider("jet"); err == nil {
+		t.Error("Expected jet to be rejected as a provider")
+	}
+}
+
+func TestCrossplaneGeneratorCompositions(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := models.NewInfrastructureModel()
+	model.Region = "us-west-2"
+	model.AddResource(infra.CreateVPC("team-vpc", "10.20.0.0/16", true, true))
+	model.AddResource(infra.CreateSubnet("public-subnet-1", "team-vpc", "10.20.0.0/24", "us-west-2a"))
+	model.AddResource(infra.CreateSubnet("public-subnet-2", "team-vpc", "10.20.1.0/24", "us-west-2b"))
+	model.AddResource(infra.CreateEKSCluster("team-eks", "1.29", "role-arn", []string{"public-subnet-1"}, true, false))
+	model.AddResource(infra.CreateEKSNodeGroup("team-eks-nodes", "team-eks", "node-role-arn", []string{"public-subnet-1"}, []string{"m5.large"}, 4, 2, 6))
+
+	generator := crossplane.NewCrossplaneGenerator().WithCompositions(true)
+	if err := generator.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize generator: %v", err)
+	}
+	if _, err := generator.Generate(model); err != nil {
+		t.Fatalf("Failed to generate Crossplane files: %v", err)
+	}
+
+	expectations := map[string][]string{
+		filepath.Join("compositions", "definitions.yaml"):        {"kind: CompositeResourceDefinition", "name: xnetworks.platform.iacgen.io", "kind: Network", "kind: KubernetesCluster"},
+		filepath.Join("compositions", "network.yaml"):            {"kind: XNetwork", "spec.parameters.subnetCidrBlocks[1]", "matchControllerRef: true"},
+		filepath.Join("compositions", "kubernetes-cluster.yaml"): {"kind: XKubernetesCluster", "spec.parameters.nodeCount", "spec.forProvider.resourcesVpcConfig.subnetIdSelector.matchLabels[platform.iacgen.io/network]"},
+		filepath.Join("compositions", "kustomization.yaml"):      {"- functions.yaml", "- definitions.yaml"},
+		filepath.Join("claims", "network.yaml"):                  {"kind: Network", "name: team-vpc", "namespace: default", "cidrBlock: 10.20.0.0/16", "- 10.20.1.0/24", "- us-west-2b"},
+		filepath.Join("claims", "kubernetes-cluster.yaml"):       {"kind: KubernetesCluster", "name: team-eks", "networkName: team-vpc", "instanceType: m5.large", "nodeCount: 4", `version: "1.29"`},
+		filepath.Join("claims", "kustomization.yaml"):            {"- network.yaml", "- kubernetes-cluster.yaml"},
+	}
+	for file, expected := range expectations {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, text := range expected {
+			if !strings.Contains(string(content), text) {
+				t.Errorf("Expected %s to contain %s, got:\n%s", file, text, content)
+			}
+		}
+	}
+
+	// Compositions are only written when enabled
+	plainDir := t.TempDir()
+	plain := crossplane.NewCrossplaneGenerator()
+	if err := plain.Init(plainDir); err != nil {
+		t.Fatalf("Failed to initialize generator: %v", err)
+	}
+	if _, err := plain.Generate(model); err != nil {
+		t.Fatalf("Failed to generate Crossplane files: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(plainDir, "claims")); !os.IsNotExist(err) {
+		t.Errorf("Expected no claims without compositions, got: %v", err)
+	}
+}
+
+func TestArgoCDManifests(t *testing.T) {
+	config := crossplane.DefaultGitOpsConfig(crossplane.GitOpsArgoCD)
+	config.RepoURL = "https://github.com/acme/platform.git"
+	config.Path = "infra"
+	config.SyncPolicy = crossplane.SyncAutoPrune
+
+	app := crossplane.ArgoCDApplication("infra/crossplane", config).YAML()
+	for _, text := range []string{"kind: Application", "name: infra-crossplane", "namespace: argocd", "path: infra/crossplane",
+		"repoURL: https://github.com/acme/platform.git", "targetRevision: HEAD", "prune: true", "selfHeal: true", "SkipDryRunOnMissingResource=true"} {
+		if !strings.Contains(app, text) {
+			t.Errorf("Expected the Application to contain %s, got:\n%s", text, app)
+		}
+	}
+
+	config.SyncPolicy = crossplane.SyncManual
+	appSet := crossplane.ArgoCDApplicationSet([]string{"dev", "prod"}, "", config).YAML()
+	for _, text := range []string{"kind: ApplicationSet", "- environment: dev", "- environment: prod", "name: infra-{{environment}}", "path: infra/{{environment}}"} {
+		if !strings.Contains(appSet, text) {
+			t.Errorf("Expected the ApplicationSet to contain %s, got:\n%s", text, appSet)
+		}
+	}
+	if strings.Contains(appSet, "automated:") {
+		t.Errorf("Expected a manual sync policy, got:\n%s", appSet)
+	}
+
+	if err := crossplane.ValidateGitOps("jenkins", config); err == nil {
+		t.Error("Expected jenkins to be rejected as a GitOps tool")
+	}
+	config.SyncPolicy = "sometimes"
+	if err := crossplane.ValidateGitOps(crossplane.GitOpsArgoCD, config); err == nil {
+		t.Error("Expected an unknown sync policy to be rejected")
+	}
+}
+
+func TestFluxManifests(t *testing.T) {
+	config := crossplane.DefaultGitOpsConfig(crossplane.GitOpsFlux)
+	config.RepoURL = "https://github.com/acme/platform.git"
+	config.Interval = "5m"
+
+	var manifests []string
+	for _, obj := range crossplane.FluxManifests("infra", config) {
+		manifests = append(manifests, obj.YAML())
+	}
+	all := strings.Join(manifests, "---\n")
+	for _, text := range []string{"kind: GitRepository", "url: https://github.com/acme/platform.git", "branch: main",
+		"name: infra-providers", "path: ./infra/base", "kind: Provider", "path: ./infra", "interval: 5m", "prune: true",
+		"wait: true", "namespace: flux-system", "$patch: delete"} {
+		if !strings.Contains(all, text) {
+			t.Errorf("Expected the Flux manifests to contain %s, got:\n%s", text, all)
+		}
+	}
+	if !strings.Contains(manifests[2], "dependsOn") {
+		t.Errorf("Expected the resources to depend on the providers, got:\n%s", manifests[2])
+	}
+	if strings.Contains(all, "suspend") {
+		t.Errorf("Expected the Kustomizations to reconcile, got:\n%s", all)
+	}
+
+	config.SyncPolicy = crossplane.SyncManual
+	config.Revision = "refs/tags/v1.0.0"
+	manual := crossplane.FluxManifests("infra", config)
+	if text := manual[1].YAML(); !strings.Contains(text, "suspend: true") || !strings.Contains(text, "prune: false") {
+		t.Errorf("Expected a suspended Kustomization without pruning, got:\n%s", text)
+	}
+	if text := manual[0].YAML(); !strings.Contains(text, "name: refs/tags/v1.0.0") {
+		t.Errorf("Expected a reference to the tag, got:\n%s", text)
+	}
+
+	config.Interval = "often"
+	if err := crossplane.ValidateGitOps(crossplane.GitOpsFlux, config); err == nil {
+		t.Error("Expected an invalid interval to be rejected")
+	}
+}
+
+func TestCrossplaneGeneratorCredentials(t *testing.T) {
+	model := models.NewInfrastructureModel()
+	model.Region = "us-west-2"
+	model.AddResource(infra.CreateVPC("main-vpc", "10.0.0.0/16", true, true))
+	model.AddResource(infra.CreateSubnet("private-subnet-1", "main-vpc", "10.0.10.0/24", "us-west-2a"))
+	model.AddResource(infra.CreateEKSCluster("main-eks", "1.28", "role-arn", []string{"private-subnet-1"}, true, false))
+	model.AddResource(infra.CreateEKSNodeGroup("main-eks-nodes", "main-eks", "node-role-arn", []string{"private-subnet-1"}, []string{"t3.medium"}, 2, 1, 3))
+
+	read := func(dir, file string) string {
+		content, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		return string(content)
+	}
+
+	// Secret credentials: every file of base/ is applied with the tree
+	secretDir := t.TempDir()
+	generator := crossplane.NewCrossplaneGenerator()
+	if err := generator.Init(secretDir); err != nil {
+		t.Fatalf("Failed to initialize generator: %v", err)
+	}
+	if _, err := generator.Generate(model); err != nil {
+		t.Fatalf("Failed to generate Crossplane files: %v", err)
+	}
+	kustomization := read(secretDir, filepath.Join("base", "kustomization.yaml"))
+	for _, file := range []string{"provider.yaml", "controller-config.yaml", "providerconfig.yaml", "aws-secret.yaml"} {
+		if !strings.Contains(kustomization, "- "+file) {
+			t.Errorf("Expected the base kustomization to list %s, got:\n%s", file, kustomization)
+		}
+	}
+	if config := read(secretDir, filepath.Join("base", "controller-config.yaml")); strings.Contains(config, "role-arn") {
+		t.Errorf("Expected no IAM role without IRSA, got:\n%s", config)
+	}
+	if eks := read(secretDir, filepath.Join("eks", "kustomization.yaml")); !strings.Contains(eks, "- nodegroup.yaml") || !strings.Contains(eks, "- iam.yaml") {
+		t.Errorf("Expected the EKS kustomization to list the node group and roles, got:\n%s", eks)
+	}
+
+	// IRSA: the providers assume the role, and no Secret is written
+	roleARN := "arn:aws:iam::123456789012:role/crossplane"
+	for _, provider := range []string{crossplane.ProviderContrib, crossplane.ProviderUpbound} {
+		dir := t.TempDir()
+		generator := crossplane.NewCrossplaneGenerator().WithProvider(provider).WithCredentials(crossplane.CredentialsIRSA, roleARN)
+		if err := generator.Init(dir); err != nil {
+			t.Fatalf("Failed to initialize generator: %v", err)
+		}
+		if _, err := generator.Generate(model); err != nil {
+			t.Fatalf("Failed to generate Crossplane files: %v", err)
+		}
+		runtimeFile, source := "controller-config.yaml", "source: InjectedIdentity"
+		if provider == crossplane.ProviderUpbound {
+			runtimeFile, source = "runtime-config.yaml", "source: IRSA"
+		}
+		if config := read(dir, filepath.Join("base", runtimeFile)); !strings.Contains(config, roleARN) {
+			t.Errorf("Expected %s to name the role with %s, got:\n%s", runtimeFile, provider, config)
+		}
+		if config := read(dir, filepath.Join("base", "providerconfig.yaml")); !strings.Contains(config, source) || strings.Contains(config, "secretRef") {
+			t.Errorf("Expected the %s ProviderConfig to take the injected identity, got:\n%s", provider, config)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "base", "aws-secret.yaml")); !os.IsNotExist(err) {
+			t.Errorf("Expected no Secret with IRSA for %s, got: %v", provider, err)
+		}
+	}
+
+	if err := crossplane.ValidateCredentials("keys"); err == nil {
+		t.Error("Expected unknown credentials to be rejected")
+	}
+}
+
+func TestCrossplaneGeneratorConnectionSecrets(t *testing.T) {
+	model := models.NewInfrastructureModel()
+	model.Region = "us-east-1"
+	model.AddResource(infra.CreateVPC("main-vpc", "10.0.0.0/16", true, true))
+	model.AddResource(infra.CreateSubnet("private-subnet-1", "main-vpc", "10.0.10.0/24", "us-east-1a"))
+	model.AddResource(infra.CreateSubnet("private-subnet-2", "main-vpc", "10.0.11.0/24", "us-east-1b"))
+	model.AddResource(infra.CreateEKSCluster("main-eks", "1.28", "role-arn", []string{"private-subnet-1"}, true, false))
+	database := models.NewResource(models.ResourceRDSInstance, "orders-db")
+	database.AddProperty("engine", "mysql")
+	database.AddProperty("instance_class", "db.t3.small")
+	model.AddResource(database)
+
+	read := func(dir, file string) string {
+		content, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		return string(content)
+	}
+	generate := func(generator *crossplane.CrossplaneGenerator) string {
+		dir := t.TempDir()
+		if err := generator.Init(dir); err != nil {
+			t.Fatalf("Failed to initialize generator: %v", err)
+		}
+		if _, err := generator.Generate(model); err != nil {
+			t.Fatalf("Failed to generate Crossplane files: %v", err)
+		}
+		return dir
+	}
+
+	// Without connection secrets, the database is still written
+	plainDir := generate(crossplane.NewCrossplaneGenerator())
+	if instances := read(plainDir, filepath.Join("rds", "instances.yaml")); !strings.Contains(instances, "kind: RDSInstance") || strings.Contains(instances, "writeConnectionSecretToRef") {
+		t.Errorf("Expected an RDS instance without a connection secret, got:\n%s", instances)
+	}
+	if root := read(plainDir, "kustomization.yaml"); !strings.Contains(root, "- rds") {
+		t.Errorf("Expected the root kustomization to list rds, got:\n%s", root)
+	}
+
+	// Each resource writes a secret of its own
+	dir := generate(crossplane.NewCrossplaneGenerator().WithConnectionSecrets(crossplane.ConnectionSecretsResources, "apps"))
+	for file, expected := range map[string][]string{
+		filepath.Join("eks", "cluster.yaml"):   {"name: main-eks-connection", "namespace: apps"},
+		filepath.Join("rds", "instances.yaml"): {"name: orders-db-connection", "namespace: apps", "engine: mysql", "dbInstanceClass: db.t3.small"},
+	} {
+		content := read(dir, file)
+		for _, text := range expected {
+			if !strings.Contains(content, text) {
+				t.Errorf("Expected %s to contain %s, got:\n%s", file, text, content)
+			}
+		}
+	}
+
+	// The Upbound provider family publishes the kubeconfig from a ClusterAuth
+	upboundDir := generate(crossplane.NewCrossplaneGenerator().WithProvider(crossplane.ProviderUpbound).WithConnectionSecrets(crossplane.ConnectionSecretsResources, ""))
+	if auth := read(upboundDir, filepath.Join("eks", "cluster-auth.yaml")); !strings.Contains(auth, "kind: ClusterAuth") || !strings.Contains(auth, "namespace: crossplane-system") {
+		t.Errorf("Expected a ClusterAuth writing the connection secret, got:\n%s", auth)
+	}
+	if instances := read(upboundDir, filepath.Join("rds", "instances.yaml")); !strings.Contains(instances, "apiVersion: rds.aws.upbound.io/v1beta1") || !strings.Contains(instances, "autoGeneratePassword: true") {
+		t.Errorf("Expected an Upbound RDS instance generating its password, got:\n%s", instances)
+	}
+
+	// The Compositions aggregate the cluster's connection details into the claim's secret
+	compositeDir := generate(crossplane.NewCrossplaneGenerator().WithCompositions(true).WithConnectionSecrets(crossplane.ConnectionSecretsComposite, ""))
+	for file, expected := range map[string][]string{
+		filepath.Join("compositions", "definitions.yaml"):        {"connectionSecretKeys:"},
+		filepath.Join("compositions", "kubernetes-cluster.yaml"): {"writeConnectionSecretsToNamespace: crossplane-system", "fromConnectionSecretKey: kubeconfig"},
+		filepath.Join("claims", "kubernetes-cluster.yaml"):       {"name: main-eks-connection"},
+	} {
+		content := read(compositeDir, file)
+		for _, text := range expected {
+			if !strings.Contains(content, text) {
+				t.Errorf("Expected %s to contain %s, got:\n%s", file, text, content)
+			}
+		}
+	}
+
+	if err := crossplane.ValidateConnectionSecrets("all"); err == nil {
+		t.Error("Expected an unknown mode to be rejected")
+	}
+}
+
+func TestCrossplaneGeneratorResourcePolicy(t *testing.T) {
+	model := models.NewInfrastructureModel()
+	model.Region = "us-east-1"
+	model.AddResource(infra.CreateVPC("main-vpc", "10.0.0.0/16", true, true))
+	model.AddResource(infra.CreateSubnet("private-subnet-1", "main-vpc", "10.0.10.0/24", "us-east-1a"))
+	model.AddResource(infra.CreateEKSCluster("main-eks", "1.28", "role-arn", []string{"private-subnet-1"}, true, false))
+
+	read := func(dir, file string) string {
+		content, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		return string(content)
+	}
+	generate := func(generator *crossplane.CrossplaneGenerator) string {
+		dir := t.TempDir()
+		if err := generator.Init(dir); err != nil {
+			t.Fatalf("Failed to initialize generator: %v", err)
+		}
+		if _, err := generator.Generate(model); err != nil {
+			t.Fatalf("Failed to generate Crossplane files: %v", err)
+		}
+		return dir
+	}
+	files := []string{
+		filepath.Join("vpc", "vpc.yaml"),
+		filepath.Join("vpc", "subnets.yaml"),
+		filepath.Join("eks", "cluster.yaml"),
+		filepath.Join("eks", "iam.yaml"),
+	}
+
+	// By default every managed resource orphans its external resource, not only the cluster
+	dir := generate(crossplane.NewCrossplaneGenerator())
+	for _, file := range files {
+		if content := read(dir, file); !strings.Contains(content, "deletionPolicy: Orphan") || strings.Contains(content, "managementPolicies") {
+			t.Errorf("Expected %s to orphan its resources, got:\n%s", file, content)
+		}
+	}
+	if config := read(dir, filepath.Join("base", "providerconfig.yaml")); strings.Contains(config, "deletionPolicy") {
+		t.Errorf("Expected the provider configuration to keep no deletion policy, got:\n%s", config)
+	}
+
+	// The policies set apply to every managed resource, in the Compositions too
+	observeDir := generate(crossplane.NewCrossplaneGenerator().WithProvider(crossplane.ProviderUpbound).WithCompositions(true).
+		WithResourcePolicy(crossplane.DeletionDelete, crossplane.ManagementObserve))
+	for _, file := range append(files, filepath.Join("compositions", "kubernetes-cluster.yaml")) {
+		content := read(observeDir, file)
+		if !strings.Contains(content, "deletionPolicy: Delete") || !strings.Contains(content, "- Observe") || strings.Contains(content, "deletionPolicy: Orphan") {
+			t.Errorf("Expected %s to observe its resources, got:\n%s", file, content)
+		}
+	}
+
+	if err := crossplane.ValidateDeletionPolicy("retain"); err == nil {
+		t.Error("Expected an unknown deletion policy to be rejected")
+	}
+	if err := crossplane.ValidateManagementPolicy("partial"); err == nil {
+		t.Error("Expected an unknown management policy to be rejected")
+	}
+}
+
+func TestCrossplaneGeneratorTeam(t *testing.T) {
+	model := models.NewInfrastructureModel()
+	model.Region = "us-east-1"
+	model.AddResource(infra.CreateVPC("main-vpc", "10.0.0.0/16", true, true))
+	model.AddResource(infra.CreateSubnet("private-subnet-1", "main-vpc", "10.0.10.0/24", "us-east-1a"))
+	model.AddResource(infra.CreateEKSCluster("main-eks", "1.28", "role-arn", []string{"private-subnet-1"}, true, false))
+
+	dir := t.TempDir()
+	generator := crossplane.NewCrossplaneGenerator().WithCompositions(true).WithTeam("payments")
+	if err := generator.Init(dir); err != nil {
+		t.Fatalf("Failed to initialize generator: %v", err)
+	}
+	if _, err := generator.Generate(model); err != nil {
+		t.Fatalf("Failed to generate Crossplane files: %v", err)
+	}
+
+	for file, expected := range map[string][]string{
+		filepath.Join("team", "kustomization.yaml"):   {"namespace: payments", "- namespace.yaml", "- rbac.yaml"},
+		filepath.Join("team", "namespace.yaml"):       {"kind: Namespace", "name: payments"},
+		filepath.Join("team", "rbac.yaml"):            {"kind: ServiceAccount", "kind: Role", "- kubernetesclusters", "kind: RoleBinding", "name: payments-claims"},
+		filepath.Join("claims", "kustomization.yaml"): {"namespace: payments"},
+		filepath.Join("claims", "network.yaml"):       {"namespace: payments"},
+	} {
+		content, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, text := range expected {
+			if !strings.Contains(string(content), text) {
+				t.Errorf("Expected %s to contain %s, got:\n%s", file, text, content)
+			}
+		}
+	}
+
+	// The team may only manage its claims, not read the secrets of its namespace
+	rbac, _ := os.ReadFile(filepath.Join(dir, "team", "rbac.yaml"))
+	if strings.Contains(string(rbac), "- secrets") || strings.Contains(string(rbac), "spec:") {
+		t.Errorf("Expected a role limited to the claims, got:\n%s", rbac)
+	}
+
+	if err := crossplane.ValidateTeam("Payments_Team"); err == nil {
+		t.Error("Expected a team that cannot name a namespace to be rejected")
+	}
+}
+
+func TestCrossplaneGeneratorImports(t *testing.T) {
+	model := models.NewInfrastructureModel()
+	model.Region = "us-east-1"
+	model.AddResource(infra.CreateVPC("main-vpc", "10.0.0.0/16", true, true))
+	model.AddResource(infra.CreateSubnet("private-subnet-1", "main-vpc", "10.0.10.0/24", "us-east-1a"))
+	model.AddResource(infra.CreateEKSCluster("main-eks", "1.28", "role-arn", []string{"private-subnet-1"}, true, false))
+
+	dir := t.TempDir()
+	generator := crossplane.NewCrossplaneGenerator().WithProvider(crossplane.ProviderUpbound).WithImports(map[string]string{
+		"VPC/main-vpc":     "vpc-0123456789abcdef0",
+		"Cluster/main-eks": "main-eks",
+	})
+	if err := generator.Init(dir); err != nil {
+		t.Fatalf("Failed to initialize generator: %v", err)
+	}
+	if _, err := generator.Generate(model); err != nil {
+		t.Fatalf("Failed to generate Crossplane files: %v", err)
+	}
+
+	read := func(file string) string {
+		content, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		return string(content)
+	}
+
+	// The adopted resources only observe the existing ones, the others are created
+	if vpc := read(filepath.Join("vpc", "vpc.yaml")); !strings.Contains(vpc, "crossplane.io/external-name: vpc-0123456789abcdef0") || !strings.Contains(vpc, "- Observe") {
+		t.Errorf("Expected the VPC to observe the existing VPC, got:\n%s", vpc)
+	}
+	if cluster := read(filepath.Join("eks", "cluster.yaml")); !strings.Contains(cluster, "crossplane.io/external-name: main-eks") || !strings.Contains(cluster, "- Observe") {
+		t.Errorf("Expected the cluster to observe the existing cluster, got:\n%s", cluster)
+	}
+	if subnets := read(filepath.Join("vpc", "subnets.yaml")); strings.Contains(subnets, "external-name") || strings.Contains(subnets, "managementPolicies") {
+		t.Errorf("Expected the subnets to be created, got:\n%s", subnets)
+	}
+
+	importMap := filepath.Join(t.TempDir(), "imports.yaml")
+	if err := os.WriteFile(importMap, []byte("imports:\n  VPC/main-vpc: vpc-0123456789abcdef0\n"), 0644); err != nil {
+		t.Fatalf("Failed to write import map: %v", err)
+	}
+	if imports, err := crossplane.LoadImportMap(importMap); err != nil || imports["VPC/main-vpc"] != "vpc-0123456789abcdef0" {
+		t.Errorf("Expected the import map to load, got %v (%v)", imports, err)
+	}
+	if err := crossplane.ValidateImports(map[string]string{"module.vpc.aws_vpc.this": "vpc-0123456789abcdef0"}); err == nil {
+		t.Error("Expected a Terraform address to be rejected")
+	}
+	if err := crossplane.ValidateImports(map[string]string{"VPC/main-vpc": " "}); err == nil {
+		t.Error("Expected an import without an ID to be rejected")
+	}
+}
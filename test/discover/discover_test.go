@@ -0,0 +1,213 @@
+package discover
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	ekstypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/riptano/iac_generator_cli/internal/discover"
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/test/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAWS answers the discovery calls for a VPC with a public and a private subnet in each of
+// two availability zones, a NAT gateway and an EKS cluster, plus a cluster in another VPC
+type fakeAWS struct {
+	// failCluster makes DescribeCluster fail
+	failCluster bool
+}
+
+func tags(pairs ...string) []ec2types.Tag {
+	var result []ec2types.Tag
+	for i := 0; i < len(pairs); i += 2 {
+		result = append(result, ec2types.Tag{Key: aws.String(pairs[i]), Value: aws.String(pairs[i+1])})
+	}
+	return result
+}
+
+func (f *fakeAWS) DescribeVpcs(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error) {
+	if params.VpcIds[0] != "vpc-0abc" {
+		return &ec2.DescribeVpcsOutput{}, nil
+	}
+	return &ec2.DescribeVpcsOutput{Vpcs: []ec2types.Vpc{{
+		VpcId:     aws.String("vpc-0abc"),
+		CidrBlock: aws.String("10.20.0.0/16"),
+		Tags:      tags("Name", "Shop VPC", "Team", "shop", "aws:cloudformation:stack-name", "shop"),
+	}}}, nil
+}
+
+func (f *fakeAWS) DescribeVpcAttribute(ctx context.Context, params *ec2.DescribeVpcAttributeInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcAttributeOutput, error) {
+	enabled := &ec2types.AttributeBooleanValue{Value: aws.Bool(true)}
+	if params.Attribute == ec2types.VpcAttributeNameEnableDnsSupport {
+		return &ec2.DescribeVpcAttributeOutput{EnableDnsSupport: enabled}, nil
+	}
+	return &ec2.DescribeVpcAttributeOutput{EnableDnsHostnames: enabled}, nil
+}
+
+func (f *fakeAWS) DescribeSubnets(ctx context.Context, params *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error) {
+	subnet := func(id, cidr, zone, name string) ec2types.Subnet {
+		return ec2types.Subnet{
+			SubnetId: aws.String(id), CidrBlock: aws.String(cidr), AvailabilityZone: aws.String(zone),
+			MapPublicIpOnLaunch: aws.Bool(false), Tags: tags("Name", name, "Team", "shop"),
+		}
+	}
+	// The subnets come in two pages
+	if params.NextToken == nil {
+		return &ec2.DescribeSubnetsOutput{Subnets: []ec2types.Subnet{
+			subnet("subnet-pub1", "10.20.0.0/24", "us-west-2a", "shop-public-a"),
+			subnet("subnet-pub2", "10.20.1.0/24", "us-west-2b", "shop-public-b"),
+		}, NextToken: aws.String("page-2")}, nil
+	}
+	return &ec2.DescribeSubnetsOutput{Subnets: []ec2types.Subnet{
+		subnet("subnet-prv1", "10.20.10.0/24", "us-west-2a", "shop-private-a"),
+		subnet("subnet-prv2", "10.20.11.0/24", "us-west-2b", "shop-private-b"),
+	}}, nil
+}
+
+func (f *fakeAWS) DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error) {
+	return &ec2.DescribeRouteTablesOutput{RouteTables: []ec2types.RouteTable{
+		{
+			// The main route table, which the private subnets use, routes to the NAT gateway
+			Associations: []ec2types.RouteTableAssociation{{Main: aws.Bool(true)}},
+			Routes:       []ec2types.Route{{DestinationCidrBlock: aws.String("0.0.0.0/0"), NatGatewayId: aws.String("nat-0abc")}},
+		},
+		{
+			Associations: []ec2types.RouteTableAssociation{{SubnetId: aws.String("subnet-pub1")}, {SubnetId: aws.String("subnet-pub2")}},
+			Routes:       []ec2types.Route{{DestinationCidrBlock: aws.String("0.0.0.0/0"), GatewayId: aws.String("igw-0abc")}},
+		},
+	}}, nil
+}
+
+func (f *fakeAWS) DescribeInternetGateways(ctx context.Context, params *ec2.DescribeInternetGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInternetGatewaysOutput, error) {
+	return &ec2.DescribeInternetGatewaysOutput{InternetGateways: []ec2types.InternetGateway{{
+		InternetGatewayId: aws.String("igw-0abc"), Tags: tags("Team", "shop"),
+	}}}, nil
+}
+
+func (f *fakeAWS) DescribeNatGateways(ctx context.Context, params *ec2.DescribeNatGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNatGatewaysOutput, error) {
+	return &ec2.DescribeNatGatewaysOutput{NatGateways: []ec2types.NatGateway{{
+		NatGatewayId:        aws.String("nat-0abc"),
+		SubnetId:            aws.String("subnet-pub1"),
+		ConnectivityType:    ec2types.ConnectivityTypePublic,
+		NatGatewayAddresses: []ec2types.NatGatewayAddress{{AllocationId: aws.String("eipalloc-0abc")}},
+		Tags:                tags("Name", "shop-nat", "Team", "shop"),
+	}}}, nil
+}
+
+func (f *fakeAWS) ListClusters(ctx context.Context, params *eks.ListClustersInput, optFns ...func(*eks.Options)) (*eks.ListClustersOutput, error) {
+	return &eks.ListClustersOutput{Clusters: []string{"shop", "other"}}, nil
+}
+
+func (f *fakeAWS) DescribeCluster(ctx context.Context, params *eks.DescribeClusterInput, optFns ...func(*eks.Options)) (*eks.DescribeClusterOutput, error) {
+	if f.failCluster {
+		return nil, errors.New("AccessDeniedException")
+	}
+	vpcID, subnets := "vpc-0abc", []string{"subnet-prv1", "subnet-prv2"}
+	if aws.ToString(params.Name) == "other" {
+		vpcID, subnets = "vpc-other", []string{"subnet-x"}
+	}
+	return &eks.DescribeClusterOutput{Cluster: &ekstypes.Cluster{
+		Name:    params.Name,
+		RoleArn: aws.String("arn:aws:iam::123456789012:role/shop-cluster"),
+		Version: aws.String("1.29"),
+		ResourcesVpcConfig: &ekstypes.VpcConfigResponse{
+			VpcId: aws.String(vpcID), SubnetIds: subnets, EndpointPrivateAccess: true,
+		},
+		Tags: map[string]string{"Team": "shop"},
+	}}, nil
+}
+
+func (f *fakeAWS) ListNodegroups(ctx context.Context, params *eks.ListNodegroupsInput, optFns ...func(*eks.Options)) (*eks.ListNodegroupsOutput, error) {
+	return &eks.ListNodegroupsOutput{Nodegroups: []string{"general"}}, nil
+}
+
+func (f *fakeAWS) DescribeNodegroup(ctx context.Context, params *eks.DescribeNodegroupInput, optFns ...func(*eks.Options)) (*eks.DescribeNodegroupOutput, error) {
+	return &eks.DescribeNodegroupOutput{Nodegroup: &ekstypes.Nodegroup{
+		NodegroupName: params.NodegroupName,
+		ClusterName:   params.ClusterName,
+		NodeRole:      aws.String("arn:aws:iam::123456789012:role/shop-nodes"),
+		Subnets:       []string{"subnet-prv1", "subnet-prv2"},
+		InstanceTypes: []string{"m5.large"},
+		ScalingConfig: &ekstypes.NodegroupScalingConfig{DesiredSize: aws.Int32(3), MinSize: aws.Int32(2), MaxSize: aws.Int32(5)},
+		Tags:          map[string]string{"Team": "shop"},
+	}}, nil
+}
+
+func (f *fakeAWS) ListAddons(ctx context.Context, params *eks.ListAddonsInput, optFns ...func(*eks.Options)) (*eks.ListAddonsOutput, error) {
+	return &eks.ListAddonsOutput{Addons: []string{"coredns"}}, nil
+}
+
+func (f *fakeAWS) DescribeAddon(ctx context.Context, params *eks.DescribeAddonInput, optFns ...func(*eks.Options)) (*eks.DescribeAddonOutput, error) {
+	return &eks.DescribeAddonOutput{Addon: &ekstypes.Addon{
+		AddonName: params.AddonName, AddonVersion: aws.String("v1.11.1-eksbuild.4"), ClusterName: params.ClusterName,
+		Tags: map[string]string{"Team": "shop"},
+	}}, nil
+}
+
+func (f *fakeAWS) ListFargateProfiles(ctx context.Context, params *eks.ListFargateProfilesInput, optFns ...func(*eks.Options)) (*eks.ListFargateProfilesOutput, error) {
+	return &eks.ListFargateProfilesOutput{}, nil
+}
+
+func (f *fakeAWS) DescribeFargateProfile(ctx context.Context, params *eks.DescribeFargateProfileInput, optFns ...func(*eks.Options)) (*eks.DescribeFargateProfileOutput, error) {
+	return nil, errors.New("no Fargate profiles")
+}
+
+func TestDiscover(t *testing.T) {
+	fake := &fakeAWS{}
+	discoverer := discover.NewDiscoverer(fake, fake, "us-west-2")
+	model, err := discoverer.Discover(context.Background(), "vpc-0abc")
+	require.NoError(t, err)
+
+	assert.Equal(t, "us-west-2", model.Region)
+	// Tags AWS sets itself are left out
+	assert.Equal(t, map[string]string{"Team": "shop"}, model.Tags)
+
+	// Resources are named after their Name tag, or their name or ID in AWS, and the cluster of
+	// the other VPC is left out
+	var names []string
+	for _, resource := range model.Resources {
+		names = append(names, resource.Name)
+	}
+	assert.Equal(t, []string{
+		"shop-vpc", "shop-public-a", "shop-public-b", "shop-private-a", "shop-private-b",
+		"igw-0abc", "shop-nat", "shop", "general", "coredns",
+	}, names)
+
+	vpc := utils.ResourceNamed(t, model, "shop-vpc")
+	dnsHostnames, _ := vpc.GetProperty("enable_dns_hostnames")
+	assert.Equal(t, true, dnsHostnames)
+
+	// Subnets routing to the internet gateway are public, even without public IPs on launch
+	assert.True(t, infra.IsPublicSubnet(*utils.ResourceNamed(t, model, "shop-public-b")))
+	assert.False(t, infra.IsPublicSubnet(*utils.ResourceNamed(t, model, "shop-private-a")))
+
+	nat := utils.ResourceNamed(t, model, "shop-nat")
+	subnetID, _ := nat.GetProperty("subnet_id")
+	assert.Equal(t, "shop-public-a", subnetID)
+	nodeGroup := utils.ResourceNamed(t, model, "general")
+	cluster, _ := nodeGroup.GetProperty("cluster_name")
+	assert.Equal(t, "shop", cluster)
+	subnetIDs, _ := nodeGroup.GetProperty("subnet_ids")
+	assert.Equal(t, []string{"shop-private-a", "shop-private-b"}, subnetIDs)
+	assert.Equal(t, []string{"shop"}, utils.ResourceNamed(t, model, "coredns").DependsOn)
+
+	// The discovered model is one the generators accept
+	assert.NoError(t, infra.NewModelValidator().Validate(model))
+}
+
+func TestDiscoverErrors(t *testing.T) {
+	fake := &fakeAWS{}
+	_, err := discover.NewDiscoverer(fake, fake, "us-west-2").Discover(context.Background(), "vpc-missing")
+	assert.EqualError(t, err, "VPC vpc-missing not found in us-west-2")
+
+	fake.failCluster = true
+	_, err = discover.NewDiscoverer(fake, fake, "us-west-2").Discover(context.Background(), "vpc-0abc")
+	assert.EqualError(t, err, "failed to describe EKS cluster other: AccessDeniedException")
+}
@@ -0,0 +1,105 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/riptano/iac_generator_cli/internal/hooks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// parse parses the hooks setting of a config file
+func parse(t *testing.T, config string) (hooks.Hooks, error) {
+	t.Helper()
+	var settings map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(config), &settings))
+	return hooks.Parse(settings["hooks"])
+}
+
+func TestParse(t *testing.T) {
+	parsed, err := parse(t, `
+hooks:
+  pre:
+    - terraform version
+  post:
+    - terraform fmt -recursive
+    - run: kubeconform vpc/*.yaml
+      timeout: 30s
+      on_failure: warn
+`)
+	require.NoError(t, err)
+	assert.Equal(t, []hooks.Hook{
+		{Command: "terraform version", Timeout: hooks.DefaultTimeout, OnFailure: hooks.FailureFail},
+	}, parsed.Pre)
+	assert.Equal(t, []hooks.Hook{
+		{Command: "terraform fmt -recursive", Timeout: hooks.DefaultTimeout, OnFailure: hooks.FailureFail},
+		{Command: "kubeconform vpc/*.yaml", Timeout: 30 * time.Second, OnFailure: hooks.FailureWarn},
+	}, parsed.Post)
+
+	parsed, err = hooks.Parse(nil)
+	require.NoError(t, err)
+	assert.True(t, parsed.Empty())
+}
+
+func TestParseRejectsInvalidHooks(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"phase", "hooks:\n  during: [ls]\n", "unknown hook phase during (use pre or post)"},
+		{"list", "hooks:\n  post: ls\n", "post hooks must be a list"},
+		{"timeout", "hooks:\n  post:\n    - run: ls\n      timeout: soon\n", `post hook 1: invalid timeout "soon" (use a duration such as 30s)`},
+		{"policy", "hooks:\n  post:\n    - ls\n    - run: ls\n      on_failure: retry\n", `post hook 2: invalid on_failure "retry" (use fail, warn or ignore)`},
+		{"command", "hooks:\n  pre:\n    - timeout: 1s\n", "pre hook 1: a hook must have a command"},
+		{"setting", "hooks:\n  pre:\n    - run: ls\n      shell: bash\n", "pre hook 1: unknown setting shell (use run, timeout and on_failure)"},
+		{"map", "hooks: [ls]\n", "hooks must be a map of pre and post hooks"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse(t, tt.config)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks are shell commands")
+	}
+
+	dir := t.TempDir()
+	var out bytes.Buffer
+	err := hooks.Run(context.Background(), hooks.Post, []hooks.Hook{
+		{Command: `echo "$IACGEN_HOOK" > phase.txt`, OnFailure: hooks.FailureFail},
+		{Command: "echo checked; exit 3", OnFailure: hooks.FailureWarn},
+		{Command: "exit 4", OnFailure: hooks.FailureIgnore},
+		{Command: "sleep 5", Timeout: 100 * time.Millisecond, OnFailure: hooks.FailureWarn},
+		{Command: "echo last > last.txt", OnFailure: hooks.FailureFail},
+	}, dir, []string{"IACGEN_HOOK=post"}, &out, nil)
+	require.NoError(t, err)
+
+	phase, err := os.ReadFile(filepath.Join(dir, "phase.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "post\n", string(phase), "Hooks should run in the directory with the environment")
+	assert.FileExists(t, filepath.Join(dir, "last.txt"), "Hooks after failures that are not fatal should run")
+	assert.Contains(t, out.String(), "Running post-generation hook: echo checked; exit 3\nchecked\n")
+	assert.Contains(t, out.String(), `Warning: post-generation hook "echo checked; exit 3" failed: exit status 3`)
+	assert.NotContains(t, out.String(), `"exit 4" failed`)
+	assert.Contains(t, out.String(), `Warning: post-generation hook "sleep 5" failed: timed out after 100ms`)
+
+	// A failing hook with the fail policy stops the hooks after it
+	err = hooks.Run(context.Background(), hooks.Pre, []hooks.Hook{
+		{Command: "exit 1", OnFailure: hooks.FailureFail},
+		{Command: "touch skipped.txt", OnFailure: hooks.FailureFail},
+	}, dir, nil, nil, nil)
+	assert.EqualError(t, err, `pre-generation hook "exit 1" failed: exit status 1`)
+	assert.NoFileExists(t, filepath.Join(dir, "skipped.txt"))
+}
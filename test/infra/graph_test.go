@@ -0,0 +1,102 @@
+package infra
+
+import (
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// graphTestModel returns a model with explicit and referenced dependencies, and one on a
+// resource the model does not have
+func graphTestModel() *models.InfrastructureModel {
+	model := models.NewInfrastructureModel()
+
+	vpc := models.NewResource(models.ResourceVPC, "main-vpc")
+	model.AddResource(vpc)
+
+	subnet := models.NewResource(models.ResourceSubnet, "private-subnet-1")
+	subnet.AddProperty("vpc_id", "main-vpc")
+	subnet.AddDependency("main-vpc")
+	model.AddResource(subnet)
+
+	cluster := models.NewResource(models.ResourceEKSCluster, "main-eks")
+	cluster.AddProperty("subnet_ids", []string{"private-subnet-1"})
+	cluster.AddDependency("eks-role")
+	model.AddResource(cluster)
+
+	return model
+}
+
+func TestModelDependencies(t *testing.T) {
+	model := graphTestModel()
+
+	assert.Empty(t, model.Dependencies(&model.Resources[0]))
+	assert.Equal(t, []string{"main-vpc"}, model.Dependencies(&model.Resources[1]), "A referenced dependency should be listed once")
+	assert.Equal(t, []string{"eks-role", "private-subnet-1"}, model.Dependencies(&model.Resources[2]), "Explicit dependencies should come before referenced ones")
+}
+
+func TestGraphExporter(t *testing.T) {
+	model := graphTestModel()
+
+	exporter, err := models.NewGraphExporter(models.GraphDOT)
+	require.NoError(t, err)
+	assert.Equal(t, `digraph infrastructure {
+  rankdir=LR;
+  node [shape=box];
+  r0 [label="vpc\nmain-vpc"];
+  r1 [label="subnet\nprivate-subnet-1"];
+  r2 [label="eks_cluster\nmain-eks"];
+  m0 [label="eks-role\n(not in the model)", style=dashed];
+  r1 -> r0;
+  r2 -> m0;
+  r2 -> r1;
+}
+`, exporter.Export(model))
+
+	exporter, err = models.NewGraphExporter(models.GraphMermaid)
+	require.NoError(t, err)
+	assert.Equal(t, `flowchart LR
+    r0["vpc<br/>main-vpc"]
+    r1["subnet<br/>private-subnet-1"]
+    r2["eks_cluster<br/>main-eks"]
+    m0["eks-role<br/>(not in the model)"]
+    style m0 stroke-dasharray: 5 5
+    r1 --> r0
+    r2 --> m0
+    r2 --> r1
+`, exporter.Export(model))
+
+	_, err = models.NewGraphExporter("png")
+	assert.EqualError(t, err, "invalid graph format: png (supported formats: dot, mermaid)")
+}
+
+func TestSortedResources(t *testing.T) {
+	model := models.NewInfrastructureModel()
+	nodeGroup := models.NewResource(models.ResourceNodeGroup, "main-node-group")
+	nodeGroup.AddProperty("cluster_name", "main-eks")
+	model.AddResource(nodeGroup)
+	cluster := models.NewResource(models.ResourceEKSCluster, "main-eks")
+	cluster.AddDependency("main-vpc")
+	model.AddResource(cluster)
+	model.AddResource(models.NewResource(models.ResourceS3Bucket, "app-logs"))
+	model.AddResource(models.NewResource(models.ResourceVPC, "main-vpc"))
+
+	sorted, err := model.Sorted()
+	require.NoError(t, err)
+	var names []string
+	for _, resource := range sorted.Resources {
+		names = append(names, resource.Name)
+	}
+	assert.Equal(t, []string{"app-logs", "main-vpc", "main-eks", "main-node-group"}, names, "Resources should follow their dependencies, keeping the model order otherwise")
+	assert.Equal(t, "main-node-group", model.Resources[0].Name, "The model should keep its order")
+
+	resorted, err := sorted.SortedResources()
+	require.NoError(t, err)
+	assert.Equal(t, sorted.Resources, resorted, "Sorting a sorted model should change nothing")
+
+	model.Resources[3].AddDependency("main-node-group")
+	_, err = model.SortedResources()
+	assert.EqualError(t, err, "dependency cycle between resources: main-node-group, main-eks, main-vpc")
+}
@@ -0,0 +1,103 @@
+package infra
+
+import (
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// partialModel returns a model with a VPC and a subnet in us-west-2, as a description of one
+// part of an infrastructure builds them
+func partialModel(resources ...models.Resource) *models.InfrastructureModel {
+	model := models.NewInfrastructureModel()
+	model.Region = "us-west-2"
+	model.Tags = map[string]string{"Team": "shop"}
+	model.AddResource(infra.CreateVPC("main-vpc", "10.0.0.0/16", true, true))
+	model.AddResource(infra.CreateSubnet("public-subnet-1", "main-vpc", "10.0.0.0/24", "us-west-2a"))
+	for _, resource := range resources {
+		model.AddResource(resource)
+	}
+	return model
+}
+
+func TestMergeModels(t *testing.T) {
+	network := partialModel(infra.CreateSubnet("private-subnet-1", "main-vpc", "10.0.10.0/24", "us-west-2a"))
+	bucket := models.NewResource(models.ResourceS3Bucket, "data")
+	bucket.AddDependency("main-vpc")
+	data := partialModel(bucket)
+	data.Region = ""
+
+	merged, err := infra.MergeModels([]infra.PartialModel{
+		{Source: "network.txt", Model: network},
+		{Source: "data.txt", Model: data},
+	})
+	require.NoError(t, err)
+
+	// The VPC and subnet both models have are merged into one
+	var names []string
+	for _, resource := range merged.Resources {
+		names = append(names, resource.Name)
+	}
+	assert.Equal(t, []string{"main-vpc", "public-subnet-1", "private-subnet-1", "data"}, names)
+	assert.Equal(t, []string{"main-vpc"}, merged.Resources[3].DependsOn)
+	assert.Equal(t, "us-west-2", merged.Region)
+	assert.Equal(t, map[string]string{"Team": "shop"}, merged.Tags)
+}
+
+func TestMergeModelsConflicts(t *testing.T) {
+	network := partialModel(infra.CreateSubnet("private-subnet-1", "main-vpc", "10.0.10.0/24", "us-west-2a"))
+	cluster := partialModel(
+		infra.CreateSubnet("private-subnet-1", "main-vpc", "10.0.11.0/24", "us-west-2a"),
+		infra.CreateSubnet("eks-subnet-1", "main-vpc", "10.0.10.128/25", "us-west-2b"),
+		infra.CreateVPC("eks-vpc", "10.0.0.0/20", true, true),
+	)
+	cluster.Region = "us-east-1"
+	cluster.Tags = map[string]string{"Team": "platform"}
+
+	_, err := infra.MergeModels([]infra.PartialModel{
+		{Source: "network.txt", Model: network},
+		{Source: "eks.txt", Model: cluster},
+	})
+	require.Error(t, err)
+	var conflicts infra.MergeConflicts
+	require.ErrorAs(t, err, &conflicts)
+	assert.Equal(t, infra.MergeConflicts{
+		{
+			Resource: "region",
+			Problem:  "eks.txt is in us-east-1, but network.txt is in us-west-2",
+			Fix:      "describe the same region in each",
+		},
+		{
+			Resource: "tag Team",
+			Problem:  `eks.txt sets it to "platform", but network.txt sets it to "shop"`,
+			Fix:      "set it the same way in each",
+		},
+		{
+			Resource: "subnet private-subnet-1",
+			Problem:  "network.txt and eks.txt describe it differently: cidr_block 10.0.10.0/24 and 10.0.11.0/24",
+			Fix:      "describe it the same way in each, or give one of them another name",
+		},
+		{
+			Resource: "subnet eks-subnet-1 of eks.txt",
+			Problem:  "its CIDR block 10.0.10.128/25 overlaps 10.0.10.0/24 of subnet private-subnet-1 of network.txt",
+			Fix:      "give each subnet of main-vpc a CIDR block of its own",
+		},
+		{
+			Resource: "vpc eks-vpc of eks.txt",
+			Problem:  "its CIDR block 10.0.0.0/20 overlaps 10.0.0.0/16 of vpc main-vpc of network.txt",
+			Fix:      "name the same VPC in each description, or give the VPCs CIDR blocks of their own",
+		},
+	}, conflicts)
+	assert.Contains(t, err.Error(), "the models cannot be merged:\n  - region: eks.txt is in us-east-1")
+
+	// A name used for resources of two types
+	bucket := models.NewResource(models.ResourceS3Bucket, "main-vpc")
+	_, err = infra.MergeModels([]infra.PartialModel{
+		{Source: "network.txt", Model: partialModel()},
+		{Source: "data.txt", Model: &models.InfrastructureModel{Resources: []models.Resource{bucket}}},
+	})
+	assert.EqualError(t, err, "the models cannot be merged:\n  - s3_bucket main-vpc: network.txt and data.txt describe it differently: a vpc and a s3_bucket (describe it the same way in each, or give one of them another name)")
+}
@@ -1,6 +1,7 @@
 package infra
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/riptano/iac_generator_cli/internal/infra"
@@ -20,12 +21,12 @@ func TestModelBuilderCreation(t *testing.T) {
 
 func TestResourceAddition(t *testing.T) {
 	builder := infra.NewModelBuilder()
-	
+
 	// Create and add a VPC resource
 	vpc := models.NewResource(models.ResourceVPC, "test-vpc")
 	vpc.AddProperty("cidr_block", "10.0.0.0/16")
 	builder.AddResource(vpc)
-	
+
 	// Verify the resource was added
 	model := builder.GetModel()
 	assert.Equal(t, 1, len(model.Resources), "Model should have 1 resource")
@@ -36,15 +37,15 @@ func TestResourceAddition(t *testing.T) {
 func TestVPCCreation(t *testing.T) {
 	// Test VPC resource creation
 	vpc := infra.CreateVPC("test-vpc", "10.0.0.0/16", true, true)
-	
+
 	assert.Equal(t, models.ResourceVPC, vpc.Type, "Resource type should be VPC")
 	assert.Equal(t, "test-vpc", vpc.Name, "VPC name should match")
-	
+
 	// Check properties
 	foundProps := make(map[string]bool)
 	for _, prop := range vpc.Properties {
 		foundProps[prop.Name] = true
-		
+
 		switch prop.Name {
 		case "cidr_block":
 			assert.Equal(t, "10.0.0.0/16", prop.Value, "CIDR block should match")
@@ -54,7 +55,7 @@ func TestVPCCreation(t *testing.T) {
 			assert.Equal(t, true, prop.Value, "DNS hostnames should be enabled")
 		}
 	}
-	
+
 	assert.True(t, foundProps["cidr_block"], "CIDR block property should exist")
 	assert.True(t, foundProps["enable_dns_support"], "DNS support property should exist")
 	assert.True(t, foundProps["enable_dns_hostnames"], "DNS hostnames property should exist")
@@ -63,15 +64,15 @@ func TestVPCCreation(t *testing.T) {
 func TestSubnetCreation(t *testing.T) {
 	// Test subnet resource creation
 	subnet := infra.CreateSubnet("test-subnet", "test-vpc", "10.0.1.0/24", "us-east-1a")
-	
+
 	assert.Equal(t, models.ResourceSubnet, subnet.Type, "Resource type should be Subnet")
 	assert.Equal(t, "test-subnet", subnet.Name, "Subnet name should match")
-	
+
 	// Check properties
 	foundProps := make(map[string]bool)
 	for _, prop := range subnet.Properties {
 		foundProps[prop.Name] = true
-		
+
 		switch prop.Name {
 		case "vpc_id":
 			assert.Equal(t, "test-vpc", prop.Value, "VPC ID should match")
@@ -81,7 +82,7 @@ func TestSubnetCreation(t *testing.T) {
 			assert.Equal(t, "us-east-1a", prop.Value, "Availability zone should match")
 		}
 	}
-	
+
 	assert.True(t, foundProps["vpc_id"], "VPC ID property should exist")
 	assert.True(t, foundProps["cidr_block"], "CIDR block property should exist")
 	assert.True(t, foundProps["availability_zone"], "Availability zone property should exist")
@@ -90,10 +91,10 @@ func TestSubnetCreation(t *testing.T) {
 func TestInternetGatewayCreation(t *testing.T) {
 	// Test Internet Gateway resource creation
 	igw := infra.CreateInternetGateway("test-igw", "test-vpc")
-	
+
 	assert.Equal(t, models.ResourceIGW, igw.Type, "Resource type should be IGW")
 	assert.Equal(t, "test-igw", igw.Name, "IGW name should match")
-	
+
 	// Check properties
 	var vpcIDFound bool
 	for _, prop := range igw.Properties {
@@ -102,22 +103,22 @@ func TestInternetGatewayCreation(t *testing.T) {
 			assert.Equal(t, "test-vpc", prop.Value, "VPC ID should match")
 		}
 	}
-	
+
 	assert.True(t, vpcIDFound, "VPC ID property should exist")
 }
 
 func TestNATGatewayCreation(t *testing.T) {
 	// Test NAT Gateway resource creation
 	natGateway := infra.CreateNATGateway("test-nat", "test-subnet", "test-eip")
-	
+
 	assert.Equal(t, models.ResourceNATGateway, natGateway.Type, "Resource type should be NAT Gateway")
 	assert.Equal(t, "test-nat", natGateway.Name, "NAT Gateway name should match")
-	
+
 	// Check properties
 	foundProps := make(map[string]bool)
 	for _, prop := range natGateway.Properties {
 		foundProps[prop.Name] = true
-		
+
 		switch prop.Name {
 		case "subnet_id":
 			assert.Equal(t, "test-subnet", prop.Value, "Subnet ID should match")
@@ -127,7 +128,7 @@ func TestNATGatewayCreation(t *testing.T) {
 			assert.Equal(t, "public", prop.Value, "Connectivity type should be public")
 		}
 	}
-	
+
 	assert.True(t, foundProps["subnet_id"], "Subnet ID property should exist")
 	assert.True(t, foundProps["allocation_id"], "Allocation ID property should exist")
 	assert.True(t, foundProps["connectivity_type"], "Connectivity type property should exist")
@@ -137,15 +138,15 @@ func TestEKSClusterCreation(t *testing.T) {
 	// Test EKS Cluster resource creation
 	subnetIDs := []string{"subnet-1", "subnet-2"}
 	eksCluster := infra.CreateEKSCluster("test-eks", "1.27", "test-role-arn", subnetIDs, true, false)
-	
+
 	assert.Equal(t, models.ResourceEKSCluster, eksCluster.Type, "Resource type should be EKS Cluster")
 	assert.Equal(t, "test-eks", eksCluster.Name, "EKS Cluster name should match")
-	
+
 	// Check properties
 	foundProps := make(map[string]bool)
 	for _, prop := range eksCluster.Properties {
 		foundProps[prop.Name] = true
-		
+
 		switch prop.Name {
 		case "name":
 			assert.Equal(t, "test-eks", prop.Value, "Cluster name should match")
@@ -156,13 +157,13 @@ func TestEKSClusterCreation(t *testing.T) {
 		case "vpc_config":
 			vpcConfig, ok := prop.Value.(map[string]interface{})
 			assert.True(t, ok, "VPC config should be a map")
-			
+
 			assert.Equal(t, subnetIDs, vpcConfig["subnet_ids"], "Subnet IDs should match")
 			assert.Equal(t, true, vpcConfig["endpoint_public_access"], "Public access should match")
 			assert.Equal(t, false, vpcConfig["endpoint_private_access"], "Private access should match")
 		}
 	}
-	
+
 	assert.True(t, foundProps["name"], "Name property should exist")
 	assert.True(t, foundProps["role_arn"], "Role ARN property should exist")
 	assert.True(t, foundProps["version"], "Version property should exist")
@@ -174,15 +175,15 @@ func TestEKSNodeGroupCreation(t *testing.T) {
 	subnetIDs := []string{"subnet-1", "subnet-2"}
 	instanceTypes := []string{"t3.medium"}
 	nodeGroup := infra.CreateEKSNodeGroup("test-ng", "test-eks", "test-role-arn", subnetIDs, instanceTypes, 2, 1, 3)
-	
+
 	assert.Equal(t, models.ResourceNodeGroup, nodeGroup.Type, "Resource type should be Node Group")
 	assert.Equal(t, "test-ng", nodeGroup.Name, "Node Group name should match")
-	
+
 	// Check properties
 	foundProps := make(map[string]bool)
 	for _, prop := range nodeGroup.Properties {
 		foundProps[prop.Name] = true
-		
+
 		switch prop.Name {
 		case "cluster_name":
 			assert.Equal(t, "test-eks", prop.Value, "Cluster name should match")
@@ -195,13 +196,13 @@ func TestEKSNodeGroupCreation(t *testing.T) {
 		case "scaling_config":
 			scalingConfig, ok := prop.Value.(map[string]interface{})
 			assert.True(t, ok, "Scaling config should be a map")
-			
+
 			assert.Equal(t, 2, scalingConfig["desired_size"], "Desired size should match")
 			assert.Equal(t, 1, scalingConfig["min_size"], "Min size should match")
 			assert.Equal(t, 3, scalingConfig["max_size"], "Max size should match")
 		}
 	}
-	
+
 	assert.True(t, foundProps["cluster_name"], "Cluster name property should exist")
 	assert.True(t, foundProps["node_role_arn"], "Node role ARN property should exist")
 	assert.True(t, foundProps["subnet_ids"], "Subnet IDs property should exist")
@@ -211,13 +212,13 @@ func TestEKSNodeGroupCreation(t *testing.T) {
 
 func TestSubnetCIDRGeneration(t *testing.T) {
 	tests := []struct {
-		name           string
-		vpcCIDR        string
-		publicCount    int
-		privateCount   int
-		expectError    bool
-		publicCIDRs    []string
-		privateCIDRs   []string
+		name         string
+		vpcCIDR      string
+		publicCount  int
+		privateCount int
+		expectError  bool
+		publicCIDRs  []string
+		privateCIDRs []string
 	}{
 		{
 			name:         "Standard VPC CIDR",
@@ -245,23 +246,23 @@ func TestSubnetCIDRGeneration(t *testing.T) {
 			expectError:  true,
 		},
 	}
-	
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			publicCIDRs, privateCIDRs, err := infra.GenerateSubnetCIDRs(tt.vpcCIDR, tt.publicCount, tt.privateCount)
-			
+
 			if tt.expectError {
 				assert.Error(t, err, "Expected error generating subnet CIDRs")
 			} else {
 				assert.NoError(t, err, "Did not expect error generating subnet CIDRs")
 				assert.Equal(t, tt.publicCount, len(publicCIDRs), "Public CIDR count should match")
 				assert.Equal(t, tt.privateCount, len(privateCIDRs), "Private CIDR count should match")
-				
+
 				// Check individual CIDRs
 				for i, cidr := range tt.publicCIDRs {
 					assert.Equal(t, cidr, publicCIDRs[i], "Public CIDR should match")
 				}
-				
+
 				for i, cidr := range tt.privateCIDRs {
 					assert.Equal(t, cidr, privateCIDRs[i], "Private CIDR should match")
 				}
@@ -270,10 +271,84 @@ func TestSubnetCIDRGeneration(t *testing.T) {
 	}
 }
 
+func TestSubnetCIDRPlan(t *testing.T) {
+	tests := []struct {
+		name         string
+		vpcCIDR      string
+		plan         infra.SubnetPlan
+		publicCIDRs  []string
+		privateCIDRs []string
+		expectError  string
+	}{
+		{
+			name:         "Subnet size",
+			vpcCIDR:      "10.0.0.0/16",
+			plan:         infra.SubnetPlan{Mask: 20},
+			publicCIDRs:  []string{"10.0.0.0/20", "10.0.16.0/20"},
+			privateCIDRs: []string{"10.0.32.0/20", "10.0.48.0/20"},
+		},
+		{
+			name:         "Room for more availability zones",
+			vpcCIDR:      "10.0.0.0/16",
+			plan:         infra.SubnetPlan{Mask: 20, ReservedAZs: 6},
+			publicCIDRs:  []string{"10.0.0.0/20", "10.0.16.0/20"},
+			privateCIDRs: []string{"10.0.96.0/20", "10.0.112.0/20"},
+		},
+		{
+			name:         "Reserved space with the default size",
+			vpcCIDR:      "172.16.0.0/20",
+			plan:         infra.SubnetPlan{ReservedAZs: 4},
+			publicCIDRs:  []string{"172.16.0.0/24", "172.16.1.0/24"},
+			privateCIDRs: []string{"172.16.4.0/24", "172.16.5.0/24"},
+		},
+		{
+			name:        "Plan larger than the VPC",
+			vpcCIDR:     "10.0.0.0/16",
+			plan:        infra.SubnetPlan{Mask: 18, ReservedAZs: 6},
+			expectError: "has room for 4 /18 subnets, but 12 are needed",
+		},
+		{
+			name:        "Subnets as large as the VPC",
+			vpcCIDR:     "10.0.0.0/20",
+			plan:        infra.SubnetPlan{Mask: 20},
+			expectError: "do not fit in VPC 10.0.0.0/20",
+		},
+		{
+			name:        "Unsupported size",
+			vpcCIDR:     "10.0.0.0/16",
+			plan:        infra.SubnetPlan{Mask: 30},
+			expectError: "/30 is not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publicCIDRs, privateCIDRs, err := infra.GenerateSubnetCIDRsWithPlan(tt.vpcCIDR, 2, 2, tt.plan)
+			if tt.expectError != "" {
+				assert.ErrorContains(t, err, tt.expectError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.publicCIDRs, publicCIDRs)
+			assert.Equal(t, tt.privateCIDRs, privateCIDRs)
+		})
+	}
+}
+
+func TestSubnetPlanErrorFromEntities(t *testing.T) {
+	entities := map[string]interface{}{
+		"vpc":     map[string]interface{}{"cidr_block": "10.0.0.0/20"},
+		"subnets": map[string]interface{}{"public_count": 3, "private_count": 3, "subnet_mask": 22},
+	}
+
+	err := infra.NewModelBuilder().BuildFromParsedEntities(entities)
+	assert.ErrorContains(t, err, "cannot plan subnet CIDRs", "A plan that does not fit should not fall back to default CIDRs")
+}
+
 func TestBuildFromParsedEntities(t *testing.T) {
 	tests := []struct {
-		name             string
-		entities         map[string]interface{}
+		name              string
+		entities          map[string]interface{}
 		expectedResources map[models.ResourceType]int
 	}{
 		{
@@ -281,9 +356,9 @@ func TestBuildFromParsedEntities(t *testing.T) {
 			entities: map[string]interface{}{
 				"region": "us-east-1",
 				"vpc": map[string]interface{}{
-					"exists":              true,
-					"cidr_block":          "10.0.0.0/16",
-					"enable_dns_support":  true,
+					"exists":               true,
+					"cidr_block":           "10.0.0.0/16",
+					"enable_dns_support":   true,
 					"enable_dns_hostnames": true,
 				},
 			},
@@ -296,9 +371,9 @@ func TestBuildFromParsedEntities(t *testing.T) {
 			entities: map[string]interface{}{
 				"region": "us-east-1",
 				"vpc": map[string]interface{}{
-					"exists":              true,
-					"cidr_block":          "10.0.0.0/16",
-					"enable_dns_support":  true,
+					"exists":               true,
+					"cidr_block":           "10.0.0.0/16",
+					"enable_dns_support":   true,
 					"enable_dns_hostnames": true,
 				},
 				"subnets": map[string]interface{}{
@@ -316,9 +391,9 @@ func TestBuildFromParsedEntities(t *testing.T) {
 			entities: map[string]interface{}{
 				"region": "us-east-1",
 				"vpc": map[string]interface{}{
-					"exists":              true,
-					"cidr_block":          "10.0.0.0/16",
-					"enable_dns_support":  true,
+					"exists":               true,
+					"cidr_block":           "10.0.0.0/16",
+					"enable_dns_support":   true,
 					"enable_dns_hostnames": true,
 				},
 				"subnets": map[string]interface{}{
@@ -342,9 +417,9 @@ func TestBuildFromParsedEntities(t *testing.T) {
 			entities: map[string]interface{}{
 				"region": "us-east-1",
 				"vpc": map[string]interface{}{
-					"exists":              true,
-					"cidr_block":          "10.0.0.0/16",
-					"enable_dns_support":  true,
+					"exists":               true,
+					"cidr_block":           "10.0.0.0/16",
+					"enable_dns_support":   true,
 					"enable_dns_hostnames": true,
 				},
 				"subnets": map[string]interface{}{
@@ -356,40 +431,40 @@ func TestBuildFromParsedEntities(t *testing.T) {
 					"nat_count": 1,
 				},
 				"eks": map[string]interface{}{
-					"exists":                 true,
-					"version":                "1.27",
-					"endpoint_public_access": true,
+					"exists":                  true,
+					"version":                 "1.27",
+					"endpoint_public_access":  true,
 					"endpoint_private_access": false,
 					"node_count":              2,
 					"instance_type":           "t3.medium",
 				},
 			},
 			expectedResources: map[models.ResourceType]int{
-				models.ResourceVPC:         1,
-				models.ResourceSubnet:      4,
-				models.ResourceIGW:         1,
-				models.ResourceNATGateway:  1,
-				models.ResourceEKSCluster:  1,
-				models.ResourceNodeGroup:   1,
+				models.ResourceVPC:        1,
+				models.ResourceSubnet:     4,
+				models.ResourceIGW:        1,
+				models.ResourceNATGateway: 1,
+				models.ResourceEKSCluster: 1,
+				models.ResourceNodeGroup:  1,
 			},
 		},
 	}
-	
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			builder := infra.NewModelBuilder()
 			err := builder.BuildFromParsedEntities(tt.entities)
 			assert.NoError(t, err, "Did not expect error building from parsed entities")
-			
+
 			model := builder.GetModel()
 			assert.NotNil(t, model, "Model should not be nil")
-			
+
 			// Count resources by type
 			resourceCounts := make(map[models.ResourceType]int)
 			for _, resource := range model.Resources {
 				resourceCounts[resource.Type]++
 			}
-			
+
 			// Verify expected resource counts
 			for resourceType, expectedCount := range tt.expectedResources {
 				actualCount := resourceCounts[resourceType]
@@ -405,9 +480,9 @@ func TestResourceDependencies(t *testing.T) {
 	entities := map[string]interface{}{
 		"region": "us-east-1",
 		"vpc": map[string]interface{}{
-			"exists":              true,
-			"cidr_block":          "10.0.0.0/16",
-			"enable_dns_support":  true,
+			"exists":               true,
+			"cidr_block":           "10.0.0.0/16",
+			"enable_dns_support":   true,
 			"enable_dns_hostnames": true,
 		},
 		"subnets": map[string]interface{}{
@@ -419,28 +494,28 @@ func TestResourceDependencies(t *testing.T) {
 			"nat_count": 1,
 		},
 		"eks": map[string]interface{}{
-			"exists":                 true,
-			"version":                "1.27",
-			"endpoint_public_access": true,
+			"exists":                  true,
+			"version":                 "1.27",
+			"endpoint_public_access":  true,
 			"endpoint_private_access": false,
 			"node_count":              2,
 			"instance_type":           "t3.medium",
 		},
 	}
-	
+
 	builder := infra.NewModelBuilder()
 	err := builder.BuildFromParsedEntities(entities)
 	assert.NoError(t, err, "Did not expect error building from parsed entities")
-	
+
 	model := builder.GetModel()
 	assert.NotNil(t, model, "Model should not be nil")
-	
+
 	// Verify dependencies
 	var vpcID string
 	var publicSubnetID string
 	var privateSubnetID string
 	var eksClusterID string
-	
+
 	// Find the resources first
 	for _, resource := range model.Resources {
 		switch resource.Type {
@@ -461,7 +536,7 @@ func TestResourceDependencies(t *testing.T) {
 			eksClusterID = resource.Name
 		}
 	}
-	
+
 	// Verify dependencies
 	for _, resource := range model.Resources {
 		switch resource.Type {
@@ -475,7 +550,7 @@ func TestResourceDependencies(t *testing.T) {
 				}
 			}
 			assert.True(t, dependsOnVPC, "Subnet should depend on VPC")
-			
+
 		case models.ResourceIGW:
 			// IGW should depend on VPC
 			dependsOnVPC := false
@@ -486,7 +561,7 @@ func TestResourceDependencies(t *testing.T) {
 				}
 			}
 			assert.True(t, dependsOnVPC, "IGW should depend on VPC")
-			
+
 		case models.ResourceNATGateway:
 			// NAT Gateway should depend on a subnet
 			dependsOnSubnet := false
@@ -497,7 +572,7 @@ func TestResourceDependencies(t *testing.T) {
 				}
 			}
 			assert.True(t, dependsOnSubnet, "NAT Gateway should depend on public subnet")
-			
+
 		case models.ResourceNodeGroup:
 			// Node Group should depend on EKS Cluster
 			dependsOnEKS := false
@@ -508,7 +583,7 @@ func TestResourceDependencies(t *testing.T) {
 				}
 			}
 			assert.True(t, dependsOnEKS, "Node Group should depend on EKS Cluster")
-			
+
 			// Node Group should depend on subnets
 			dependsOnSubnet := false
 			for _, dep := range resource.DependsOn {
@@ -525,13 +600,13 @@ func TestResourceDependencies(t *testing.T) {
 func TestComplexInfrastructureModel(t *testing.T) {
 	// Test the complex infrastructure model from fixtures
 	model := fixtures.CreateTestInfrastructureModel()
-	
+
 	// Count resources by type
 	resourceCounts := make(map[models.ResourceType]int)
 	for _, resource := range model.Resources {
 		resourceCounts[resource.Type]++
 	}
-	
+
 	// Verify expected resources
 	assert.Equal(t, 1, resourceCounts[models.ResourceVPC], "Should have 1 VPC")
 	assert.Equal(t, 4, resourceCounts[models.ResourceSubnet], "Should have 4 subnets")
@@ -539,27 +614,27 @@ func TestComplexInfrastructureModel(t *testing.T) {
 	assert.Equal(t, 1, resourceCounts[models.ResourceNATGateway], "Should have 1 NAT Gateway")
 	assert.Equal(t, 1, resourceCounts[models.ResourceEKSCluster], "Should have 1 EKS Cluster")
 	assert.Equal(t, 1, resourceCounts[models.ResourceNodeGroup], "Should have 1 Node Group")
-	
+
 	// Verify dependencies
 	for _, resource := range model.Resources {
 		switch resource.Type {
 		case models.ResourceSubnet:
 			// Subnets should depend on VPC
 			assert.Contains(t, resource.DependsOn, "main-vpc", "Subnet should depend on VPC")
-			
+
 		case models.ResourceIGW:
 			// IGW should depend on VPC
 			assert.Contains(t, resource.DependsOn, "main-vpc", "IGW should depend on VPC")
-			
+
 		case models.ResourceNATGateway:
 			// NAT Gateway should depend on a subnet
 			assert.Contains(t, resource.DependsOn, "public-subnet-1", "NAT Gateway should depend on public subnet")
-			
+
 		case models.ResourceEKSCluster:
 			// EKS Cluster should depend on private subnets
 			assert.Contains(t, resource.DependsOn, "private-subnet-1", "EKS Cluster should depend on private subnet 1")
 			assert.Contains(t, resource.DependsOn, "private-subnet-2", "EKS Cluster should depend on private subnet 2")
-			
+
 		case models.ResourceNodeGroup:
 			// Node Group should depend on EKS Cluster and subnets
 			assert.Contains(t, resource.DependsOn, "main-eks-cluster", "Node Group should depend on EKS Cluster")
@@ -567,4 +642,481 @@ func TestComplexInfrastructureModel(t *testing.T) {
 			assert.Contains(t, resource.DependsOn, "private-subnet-2", "Node Group should depend on private subnet 2")
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestAutoScalingGroupFromEntities(t *testing.T) {
+	entities := map[string]interface{}{
+		"region": "us-east-1",
+		"vpc": map[string]interface{}{
+			"cidr_block": "10.0.0.0/16",
+		},
+		"subnets": map[string]interface{}{
+			"public_count":  1,
+			"private_count": 2,
+		},
+		"asg": map[string]interface{}{
+			"exists":                       true,
+			"desired_capacity":             3,
+			"min_size":                     3,
+			"max_size":                     6,
+			"instance_type":                "t3.large",
+			"behind_alb":                   true,
+			"user_data_packages":           []string{"nginx"},
+			"instance_refresh_min_healthy": 75,
+		},
+	}
+
+	builder := infra.NewModelBuilder()
+	err := builder.BuildFromParsedEntities(entities)
+	assert.NoError(t, err, "Building the model should succeed")
+
+	var launchTemplate, asg *models.Resource
+	model := builder.GetModel()
+	for i := range model.Resources {
+		switch model.Resources[i].Type {
+		case models.ResourceLaunchTemplate:
+			launchTemplate = &model.Resources[i]
+		case models.ResourceAutoScalingGroup:
+			asg = &model.Resources[i]
+		}
+	}
+
+	if assert.NotNil(t, launchTemplate, "Launch template should be created") {
+		instanceType, _ := launchTemplate.GetProperty("instance_type")
+		assert.Equal(t, "t3.large", instanceType)
+		userData, ok := launchTemplate.GetProperty("user_data")
+		assert.True(t, ok, "Launch template should carry user data")
+		assert.Contains(t, userData, "dnf install -y nginx")
+	}
+
+	if assert.NotNil(t, asg, "Auto Scaling Group should be created") {
+		subnets, _ := asg.GetProperty("vpc_zone_identifier")
+		assert.Equal(t, []string{"private-subnet-1", "private-subnet-2"}, subnets, "ASG should use the private subnets")
+		healthCheck, _ := asg.GetProperty("health_check_type")
+		assert.Equal(t, "ELB", healthCheck, "ASG behind an ALB should use ELB health checks")
+		refresh, _ := asg.GetProperty("instance_refresh")
+		assert.Equal(t, map[string]interface{}{"strategy": "Rolling", "min_healthy_percentage": 75}, refresh)
+		assert.Contains(t, asg.DependsOn, launchTemplate.Name, "ASG should depend on its launch template")
+	}
+}
+
+func TestEC2InstancesFromEntities(t *testing.T) {
+	entities := map[string]interface{}{
+		"region": "us-east-1",
+		"vpc": map[string]interface{}{
+			"cidr_block": "10.0.0.0/16",
+		},
+		"subnets": map[string]interface{}{
+			"public_count":  2,
+			"private_count": 2,
+		},
+		"ec2_instance": map[string]interface{}{
+			"exists":        true,
+			"count":         2,
+			"instance_type": "t4g.small",
+			"os":            "amazon-linux-2023",
+			"key_name":      "deploy-key",
+			"web":           true,
+		},
+	}
+
+	builder := infra.NewModelBuilder()
+	err := builder.BuildFromParsedEntities(entities)
+	assert.NoError(t, err, "Building the model should succeed")
+
+	var instances []models.Resource
+	var securityGroup *models.Resource
+	model := builder.GetModel()
+	for i := range model.Resources {
+		switch model.Resources[i].Type {
+		case models.ResourceEC2Instance:
+			instances = append(instances, model.Resources[i])
+		case models.ResourceSecurityGroup:
+			securityGroup = &model.Resources[i]
+		}
+	}
+
+	if assert.Len(t, instances, 2, "Two instances should be created") {
+		assert.Equal(t, "main-instance-1", instances[0].Name)
+		assert.Equal(t, "main-instance-2", instances[1].Name)
+
+		// Instances are spread across the private subnets
+		subnet1, _ := instances[0].GetProperty("subnet_id")
+		subnet2, _ := instances[1].GetProperty("subnet_id")
+		assert.Equal(t, "private-subnet-1", subnet1)
+		assert.Equal(t, "private-subnet-2", subnet2)
+
+		// Graviton instance types resolve arm64 images
+		ami, _ := instances[0].GetProperty("ami")
+		assert.Equal(t, "resolve:ssm:/aws/service/ami-amazon-linux-latest/al2023-ami-kernel-default-arm64", ami)
+		lookup, ok := instances[0].GetProperty("ami_lookup")
+		assert.True(t, ok, "Instances should carry an AMI lookup")
+		assert.Equal(t, "amazon", lookup.(map[string]interface{})["owner"])
+
+		keyName, _ := instances[0].GetProperty("key_name")
+		assert.Equal(t, "deploy-key", keyName)
+		metadata, _ := instances[0].GetProperty("metadata_options")
+		assert.Equal(t, map[string]interface{}{"http_tokens": "required"}, metadata, "Instances should require IMDSv2")
+	}
+
+	if assert.NotNil(t, securityGroup, "Security group should be created") {
+		assert.Equal(t, "main-instance-sg", securityGroup.Name)
+		ingress, _ := securityGroup.GetProperty("ingress")
+		rules := ingress.([]map[string]interface{})
+		assert.Len(t, rules, 3, "SSH, HTTP and HTTPS ingress rules should be added")
+		assert.Equal(t, []string{"10.0.0.0/16"}, rules[0]["cidr_blocks"], "SSH should default to the VPC CIDR")
+	}
+}
+
+func TestLookupAMI(t *testing.T) {
+	ubuntu := infra.LookupAMI(infra.OSUbuntu, "t3.micro")
+	assert.Equal(t, "099720109477", ubuntu.Owner)
+	assert.Equal(t, "x86_64", ubuntu.Architecture)
+	assert.Equal(t, "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-*", ubuntu.NameFilter)
+
+	graviton := infra.LookupAMI(infra.OSUbuntu, "c7gn.large")
+	assert.Equal(t, "arm64", graviton.Architecture)
+
+	amazonLinux := infra.LookupAMI("", "m5.large")
+	assert.Equal(t, infra.OSAmazonLinux2023, amazonLinux.OS)
+	assert.Equal(t, "amazon_linux_2023_x86_64", amazonLinux.DataSourceName())
+}
+
+func TestBuildDRVariant(t *testing.T) {
+	entities := map[string]interface{}{
+		"region": "us-east-1",
+		"vpc": map[string]interface{}{
+			"cidr_block": "10.0.0.0/16",
+		},
+		"subnets": map[string]interface{}{
+			"public_count":  2,
+			"private_count": 2,
+		},
+		"eks": map[string]interface{}{
+			"version":    "1.28",
+			"node_count": 3,
+		},
+	}
+
+	builder := infra.NewModelBuilder()
+	err := builder.BuildFromParsedEntities(entities)
+	assert.NoError(t, err, "BuildFromParsedEntities should not return an error")
+
+	primary := builder.GetModel()
+	primary.AddResource(infra.CreateS3Bucket("app-data", "private", false))
+
+	variant := infra.BuildDRVariant(primary, "eu-west-1")
+
+	var vpc, subnet, cluster, bucket *models.Resource
+	for i, resource := range variant.Resources {
+		assert.True(t, strings.HasSuffix(resource.Name, infra.DRSuffix), "DR resource %s should have the DR suffix", resource.Name)
+
+		switch resource.Type {
+		case models.ResourceVPC:
+			vpc = &variant.Resources[i]
+		case models.ResourceSubnet:
+			if subnet == nil {
+				subnet = &variant.Resources[i]
+			}
+		case models.ResourceEKSCluster:
+			cluster = &variant.Resources[i]
+		case models.ResourceS3Bucket:
+			bucket = &variant.Resources[i]
+		}
+	}
+
+	assert.NotNil(t, vpc, "DR variant should contain a VPC")
+	assert.NotNil(t, subnet, "DR variant should contain subnets")
+	assert.NotNil(t, cluster, "DR variant should contain the EKS cluster")
+	assert.NotNil(t, bucket, "DR variant should contain a replica bucket")
+
+	// Regional settings point at the DR region
+	region, _ := vpc.GetProperty("region")
+	assert.Equal(t, "eu-west-1", region, "DR VPC should be in the DR region")
+	az, _ := subnet.GetProperty("availability_zone")
+	assert.True(t, strings.HasPrefix(az.(string), "eu-west-1"), "DR subnet should use a DR availability zone")
+
+	// References are rewritten to the DR resources
+	vpcID, _ := subnet.GetProperty("vpc_id")
+	assert.Equal(t, "main-vpc-dr", vpcID, "DR subnet should reference the DR VPC")
+	vpcConfig, _ := cluster.GetProperty("vpc_config")
+	for _, id := range vpcConfig.(map[string]interface{})["subnet_ids"].([]string) {
+		assert.True(t, strings.HasSuffix(id, infra.DRSuffix), "DR cluster should use DR subnets")
+	}
+
+	// The primary model is left intact apart from bucket replication
+	for _, resource := range primary.Resources {
+		assert.False(t, strings.HasSuffix(resource.Name, infra.DRSuffix), "Primary resource %s should not be renamed", resource.Name)
+		if resource.Type == models.ResourceS3Bucket {
+			versioning, _ := resource.GetProperty("versioning")
+			assert.Equal(t, true, versioning, "Replicated buckets require versioning")
+			replication, ok := resource.GetProperty("replication")
+			assert.True(t, ok, "Primary bucket should be configured for replication")
+			assert.Equal(t, "app-data-dr", replication.(map[string]interface{})["destination_bucket"])
+			assert.Equal(t, "eu-west-1", replication.(map[string]interface{})["destination_region"])
+		}
+	}
+}
+
+func TestApplyEnvironment(t *testing.T) {
+	builder := infra.NewModelBuilder()
+	assert.NoError(t, builder.BuildFromParsedEntities(map[string]interface{}{
+		"vpc":         map[string]interface{}{"cidr_block": "10.0.0.0/16"},
+		"subnets":     map[string]interface{}{"public_count": 1, "private_count": 1},
+		"eks":         map[string]interface{}{"node_count": 2},
+		"tags":        map[string]interface{}{"team": "platform"},
+		"environment": "staging",
+	}))
+	model := builder.GetModel()
+
+	assert.Equal(t, "staging", model.Environment)
+	assert.Equal(t, map[string]string{"team": "platform", "Environment": "staging"}, model.Tags)
+
+	// Another environment replaces the suffix instead of adding to it
+	infra.ApplyEnvironment(model, "prod")
+	assert.Equal(t, "prod", model.Tags["Environment"])
+
+	names := make(map[string]bool)
+	for _, resource := range model.Resources {
+		assert.True(t, strings.HasSuffix(resource.Name, "-prod"), "%s should be named after the environment", resource.Name)
+		assert.NotContains(t, resource.Name, "staging")
+		names[resource.Name] = true
+	}
+	for _, resource := range model.Resources {
+		for _, dependency := range resource.DependsOn {
+			assert.True(t, names[dependency], "%s should depend on the renamed %s", resource.Name, dependency)
+		}
+		if resource.Type == models.ResourceSubnet {
+			vpcID, _ := resource.GetProperty("vpc_id")
+			assert.Equal(t, "main-vpc-prod", vpcID)
+		}
+	}
+
+	assert.True(t, infra.ValidEnvironment("qa-2"))
+	assert.False(t, infra.ValidEnvironment("Staging"))
+	assert.False(t, infra.ValidEnvironment("a-very-long-environment-name"))
+}
+
+func TestTagsFromEntities(t *testing.T) {
+	entities := map[string]interface{}{
+		"region": "us-east-1",
+		"vpc":    map[string]interface{}{"cidr_block": "10.0.0.0/16"},
+		"tags": map[string]interface{}{
+			"team":        "platform",
+			"cost-center": 1234,
+			"aws:created": "by-hand",
+		},
+	}
+
+	builder := infra.NewModelBuilder()
+	assert.NoError(t, builder.BuildFromParsedEntities(entities))
+	model := builder.GetModel()
+	assert.Equal(t, map[string]string{"team": "platform", "cost-center": "1234"}, model.Tags, "Invalid tags should be dropped")
+
+	variant := infra.BuildDRVariant(model, "eu-west-1")
+	assert.Equal(t, model.Tags, variant.Tags, "The DR variant should carry the global tags")
+}
+
+func TestCheckTag(t *testing.T) {
+	assert.NoError(t, infra.CheckTag("cost-center", "1234"))
+	assert.NoError(t, infra.CheckTag("Owner", ""))
+	assert.Error(t, infra.CheckTag("Name", "web"), "Name is set per resource")
+	assert.Error(t, infra.CheckTag("AWS:cloudformation", "x"), "aws: keys are reserved")
+	assert.Error(t, infra.CheckTag("team", `"platform"`), "Quotes are not allowed in values")
+	assert.Error(t, infra.CheckTag("", "x"), "Keys cannot be empty")
+}
+
+func TestMergeTags(t *testing.T) {
+	defaults := map[string]string{"Team": "platform", "Owner": "infra"}
+	tags := map[string]string{"Owner": "web", "Tier": "frontend"}
+	overrides := map[string]string{"Tier": "edge"}
+
+	assert.Equal(t, map[string]string{"Team": "platform", "Owner": "web", "Tier": "edge"}, infra.MergeTags(defaults, tags, overrides), "The model's tags should override the defaults, and the overrides both")
+	assert.Equal(t, tags, infra.MergeTags(nil, tags, nil))
+	assert.Nil(t, infra.MergeTags(nil, nil, nil))
+}
+
+func TestCheckRequiredTags(t *testing.T) {
+	model := models.NewInfrastructureModel()
+	model.Tags = map[string]string{"Owner": "sre", "CostCenter": " "}
+	assert.Equal(t, []string{"CostCenter", "Team"}, infra.MissingTags(model.Tags, []string{"Owner", "CostCenter", "Team"}), "Empty tags should count as missing")
+	assert.EqualError(t, infra.CheckRequiredTags(model, []string{"Team", "CostCenter"}), "missing required tags: Team, CostCenter (set them under tags in the config file, with --tag or in the description)")
+	assert.NoError(t, infra.CheckRequiredTags(model, []string{"Owner"}))
+
+	assert.NoError(t, infra.CheckRequiredTag("CostCenter"))
+	assert.Error(t, infra.CheckRequiredTag("Name"), "Name is set per resource")
+}
+
+func TestAddSecondaryRegion(t *testing.T) {
+	entities := map[string]interface{}{
+		"region":            "us-east-1",
+		"vpc":               map[string]interface{}{"cidr_block": "10.0.0.0/16"},
+		"subnets":           map[string]interface{}{"public_count": 2},
+		"gateways":          map[string]interface{}{"igw_count": 1},
+		"eks":               map[string]interface{}{"node_count": 2},
+		"secondary_regions": []string{"eu-west-1"},
+	}
+
+	builder := infra.NewModelBuilder()
+	assert.NoError(t, builder.BuildFromParsedEntities(entities))
+	model := builder.GetModel()
+	assert.Equal(t, []string{"eu-west-1"}, model.SecondaryRegions())
+
+	copies := make(map[models.ResourceType]int)
+	for i, resource := range model.Resources {
+		region := model.ResourceRegion(&model.Resources[i])
+		if region != "eu-west-1" {
+			continue
+		}
+		copies[resource.Type]++
+		assert.True(t, strings.HasSuffix(resource.Name, infra.RegionSuffix("eu-west-1")), "Copies should carry the region suffix")
+		for _, dep := range resource.DependsOn {
+			assert.True(t, strings.HasSuffix(dep, "-eu-west-1"), "Copies should depend on copies, got %s", dep)
+		}
+		if az, ok := resource.GetProperty("availability_zone"); ok {
+			assert.True(t, strings.HasPrefix(az.(string), "eu-west-1"), "Availability zones should move to the region")
+		}
+	}
+	assert.Equal(t, map[models.ResourceType]int{
+		models.ResourceVPC:    1,
+		models.ResourceSubnet: 2,
+		models.ResourceIGW:    1,
+	}, copies, "Only the network should be copied")
+
+	// Adding the primary region is a no-op
+	count := len(model.Resources)
+	infra.AddSecondaryRegion(model, "us-east-1")
+	assert.Len(t, model.Resources, count)
+}
+
+func TestNodeGroupsFromEntities(t *testing.T) {
+	entities := map[string]interface{}{
+		"vpc":     map[string]interface{}{"cidr_block": "10.0.0.0/16"},
+		"subnets": map[string]interface{}{"private_count": 2},
+		"eks": map[string]interface{}{
+			"node_count": 2,
+			"node_groups": []interface{}{
+				map[string]interface{}{"capacity_type": "SPOT", "instance_type": "t3.large", "desired_size": 1, "min_size": 1, "max_size": 10},
+				map[string]interface{}{"capacity_type": "SPOT", "instance_type": "c5.large", "instance_types": []string{"c5.large", "c5a.large"}, "desired_size": 2, "min_size": 2, "max_size": 4},
+			},
+		},
+	}
+
+	builder := infra.NewModelBuilder()
+	assert.NoError(t, builder.BuildFromParsedEntities(entities))
+
+	var names []string
+	for _, resource := range builder.GetModel().Resources {
+		if resource.Type != models.ResourceNodeGroup {
+			continue
+		}
+		names = append(names, resource.Name)
+		if resource.Name == "main-node-group-spot" {
+			instanceTypes, _ := resource.GetProperty("instance_types")
+			assert.Equal(t, []string{"t3.large"}, instanceTypes)
+			scaling, _ := resource.GetProperty("scaling_config")
+			assert.Equal(t, 10, scaling.(map[string]interface{})["max_size"])
+		}
+		if resource.Name == "main-node-group-spot-2" {
+			instanceTypes, _ := resource.GetProperty("instance_types")
+			assert.Equal(t, []string{"c5.large", "c5a.large"}, instanceTypes, "A mixed-instances policy should allow every listed type")
+		}
+	}
+	assert.Equal(t, []string{"main-node-group-spot", "main-node-group-spot-2"}, names, "Node groups of the same capacity type should be numbered")
+}
+
+func TestFargateProfilesFromEntities(t *testing.T) {
+	entities := map[string]interface{}{
+		"vpc":     map[string]interface{}{"cidr_block": "10.0.0.0/16"},
+		"subnets": map[string]interface{}{"private_count": 2},
+		"eks": map[string]interface{}{
+			"fargate_namespaces": []string{"kube-system", "app", "web", "api", "jobs", "batch"},
+		},
+	}
+
+	builder := infra.NewModelBuilder()
+	assert.NoError(t, builder.BuildFromParsedEntities(entities))
+
+	namespaces := make(map[string]interface{})
+	for _, resource := range builder.GetModel().Resources {
+		if resource.Type == models.ResourceFargateProfile {
+			namespaces[resource.Name], _ = resource.GetProperty("namespaces")
+			assert.Equal(t, []string{"main-eks-cluster"}, resource.DependsOn)
+		}
+	}
+	assert.Equal(t, map[string]interface{}{
+		"main-eks-cluster-fargate":   []string{"kube-system", "app", "web", "api", "jobs"},
+		"main-eks-cluster-fargate-2": []string{"batch"},
+	}, namespaces, "A profile should select at most five namespaces")
+}
+
+func TestResolveEKSAddonVersions(t *testing.T) {
+	model := models.NewInfrastructureModel()
+	model.AddResource(infra.CreateEKSCluster("main-eks", "1.29", "role-arn", []string{"private-subnet-1"}, true, false))
+	for _, name := range []string{"coredns", "kube-proxy", "vpc-cni", "aws-efs-csi-driver"} {
+		addon, _ := infra.LookupEKSAddon(name)
+		model.AddResource(infra.CreateEKSAddon("main-eks-"+name, "main-eks", addon, ""))
+	}
+	ebs, _ := infra.LookupEKSAddon("aws-ebs-csi-driver")
+	model.AddResource(infra.CreateEKSAddon("main-eks-aws-ebs-csi-driver", "main-eks", ebs, "v1.30.0-eksbuild.1"))
+
+	infra.ResolveEKSAddonVersions(model, map[string]string{"vpc-cni": "v1.18.1-eksbuild.1"})
+
+	versions := make(map[string]interface{})
+	for _, resource := range model.Resources {
+		if resource.Type == models.ResourceEKSAddon {
+			versions[resource.Name], _ = resource.GetProperty("addon_version")
+		}
+	}
+	assert.Equal(t, map[string]interface{}{
+		"main-eks-coredns":            "v1.11.4-eksbuild.2",
+		"main-eks-kube-proxy":         "v1.29.10-eksbuild.3",
+		"main-eks-vpc-cni":            "v1.18.1-eksbuild.1",
+		"main-eks-aws-efs-csi-driver": nil,
+		"main-eks-aws-ebs-csi-driver": "v1.30.0-eksbuild.1",
+	}, versions, "Overrides should win over pinned versions, which win over the compatibility table")
+
+	assert.NoError(t, infra.ValidateEKSAddonVersions(map[string]string{"coredns": "v1.11.4-eksbuild.2"}))
+	assert.Error(t, infra.ValidateEKSAddonVersions(map[string]string{"istio": "v1.0.0-eksbuild.1"}))
+	assert.Error(t, infra.ValidateEKSAddonVersions(map[string]string{"coredns": "latest"}))
+}
+
+func TestEKSAddonsFromEntities(t *testing.T) {
+	entities := map[string]interface{}{
+		"vpc":     map[string]interface{}{"cidr_block": "10.0.0.0/16"},
+		"subnets": map[string]interface{}{"private_count": 2},
+		"eks": map[string]interface{}{
+			"addons": []interface{}{
+				map[string]interface{}{"name": "coredns", "version": "v1.11.1-eksbuild.9"},
+				map[string]interface{}{"name": "aws-ebs-csi-driver"},
+				map[string]interface{}{"name": "istio"},
+			},
+		},
+	}
+
+	builder := infra.NewModelBuilder()
+	assert.NoError(t, builder.BuildFromParsedEntities(entities))
+
+	addons := make(map[string]models.Resource)
+	for _, resource := range builder.GetModel().Resources {
+		if resource.Type == models.ResourceEKSAddon {
+			addons[resource.Name] = resource
+		}
+	}
+	assert.Len(t, addons, 2, "Unknown add-ons should be skipped")
+
+	coredns := addons["main-eks-cluster-coredns"]
+	version, _ := coredns.GetProperty("addon_version")
+	assert.Equal(t, "v1.11.1-eksbuild.9", version)
+	assert.Equal(t, []string{"main-eks-cluster"}, coredns.DependsOn)
+	_, irsa := coredns.GetProperty("service_account")
+	assert.False(t, irsa, "CoreDNS needs no IAM role")
+
+	ebs := addons["main-eks-cluster-aws-ebs-csi-driver"]
+	serviceAccount, _ := ebs.GetProperty("service_account")
+	assert.Equal(t, "kube-system/ebs-csi-controller-sa", serviceAccount)
+	policy, _ := ebs.GetProperty("policy_arn")
+	assert.Equal(t, "arn:aws:iam::aws:policy/service-role/AmazonEBSCSIDriverPolicy", policy)
+	_, pinned := ebs.GetProperty("addon_version")
+	assert.False(t, pinned, "An unpinned add-on should get the default version")
+}
@@ -0,0 +1,96 @@
+package infra
+
+import (
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// namingTestModel returns the model of a description with a VPC, a subnet and an EKS
+// cluster, made for an environment
+func namingTestModel(environment string) *models.InfrastructureModel {
+	builder := infra.NewModelBuilder()
+	err := builder.BuildFromParsedEntities(map[string]interface{}{
+		"region":      "us-west-2",
+		"environment": environment,
+		"vpc":         map[string]interface{}{"cidr_block": "10.0.0.0/16"},
+		"subnets":     map[string]interface{}{"private_count": 1},
+		"eks":         map[string]interface{}{"node_count": 2},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return builder.GetModel()
+}
+
+func TestApplyNamingPolicy(t *testing.T) {
+	policy := infra.NamingPolicy{Pattern: "{env}-{app}-{resource}", App: "OrderService"}
+
+	model := namingTestModel("prod")
+	require.NoError(t, infra.ApplyNamingPolicy(model, policy))
+	var names []string
+	for _, resource := range model.Resources {
+		names = append(names, resource.Name)
+	}
+	assert.Equal(t, []string{
+		"prod-order-service-vpc",
+		"prod-order-service-private-subnet-1",
+		"prod-order-service-eks-cluster",
+		"prod-order-service-node-group",
+	}, names, "The environment suffix and the main- prefix should be replaced")
+
+	vpcID, _ := model.Resources[1].GetProperty("vpc_id")
+	assert.Equal(t, "prod-order-service-vpc", vpcID, "References should be renamed")
+	clusterName, _ := model.Resources[3].GetProperty("cluster_name")
+	assert.Equal(t, "prod-order-service-eks-cluster", clusterName)
+
+	// Names the policy already gave are kept
+	require.NoError(t, infra.ApplyNamingPolicy(model, policy))
+	assert.Equal(t, "prod-order-service-vpc", model.Resources[0].Name)
+
+	// Empty values drop their separators, and the lower case joins the words of each value
+	model = namingTestModel("")
+	require.NoError(t, infra.ApplyNamingPolicy(model, infra.NamingPolicy{Pattern: "{env}-{app}-{resource}", App: "Order Service", Case: infra.NamingCaseLower}))
+	assert.Equal(t, "orderservice-vpc", model.Resources[0].Name)
+	assert.Equal(t, "orderservice-privatesubnet1", model.Resources[1].Name)
+
+	// A policy that keeps the environment out of the pattern keeps its suffix
+	model = namingTestModel("dev")
+	require.NoError(t, infra.ApplyNamingPolicy(model, infra.NamingPolicy{Pattern: "acme-{resource}"}))
+	assert.Equal(t, "acme-vpc-dev", model.Resources[0].Name)
+
+	// The zero policy keeps the default names
+	model = namingTestModel("")
+	require.NoError(t, infra.ApplyNamingPolicy(model, infra.NamingPolicy{}))
+	assert.Equal(t, infra.DefaultVPCName, model.Resources[0].Name)
+}
+
+func TestApplyNamingPolicyErrors(t *testing.T) {
+	model := models.NewInfrastructureModel()
+	model.AddResource(models.NewResource(models.ResourceVPC, "main-vpc"))
+	model.AddResource(models.NewResource(models.ResourceSubnet, "vpc"))
+	err := infra.ApplyNamingPolicy(model, infra.NamingPolicy{Pattern: "shop-{resource}"})
+	assert.EqualError(t, err, "the naming pattern shop-{resource} gives main-vpc and vpc the same name: shop-vpc")
+
+	model = namingTestModel("")
+	err = infra.ApplyNamingPolicy(model, infra.NamingPolicy{Pattern: "2024-{resource}"})
+	assert.EqualError(t, err, `the naming pattern 2024-{resource} gives main-vpc the name "2024-vpc", which does not start with a letter`)
+}
+
+func TestNamingPolicyValidate(t *testing.T) {
+	assert.NoError(t, infra.NamingPolicy{}.Validate())
+	assert.NoError(t, infra.NamingPolicy{Pattern: "{env}-{app}-{resource}", App: "shop", Case: "Lower"}.Validate())
+
+	for policy, message := range map[infra.NamingPolicy]string{
+		{Case: "snake"}:                           "invalid naming case: snake (supported cases: kebab, lower)",
+		{Pattern: "{env}-{app}"}:                  "invalid naming pattern: {env}-{app} (it must contain {resource} once, so each resource gets a name of its own)",
+		{Pattern: "{resource}-{team}"}:            "invalid naming pattern: {resource}-{team} (unknown placeholder {team}; use {env}, {app}, {region}, {resource})",
+		{Pattern: "Shop_{resource}"}:              "invalid naming pattern: Shop_{resource} (use lower-case letters, digits and hyphens around the placeholders)",
+		{Pattern: "{app}-{resource}", App: " - "}: "the naming pattern {app}-{resource} uses {app}, but no application name is set",
+	} {
+		assert.EqualError(t, policy.Validate(), message)
+	}
+}
@@ -0,0 +1,282 @@
+package infra
+
+import (
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/riptano/iac_generator_cli/test/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// validationTestModel returns the model of a VPC with a public and a private subnet in each of
+// two availability zones, a NAT gateway and an EKS cluster with a node group
+func validationTestModel() *models.InfrastructureModel {
+	builder := infra.NewModelBuilder()
+	err := builder.BuildFromParsedEntities(map[string]interface{}{
+		"region":   "us-west-2",
+		"vpc":      map[string]interface{}{"cidr_block": "10.0.0.0/16"},
+		"subnets":  map[string]interface{}{"public_count": 2, "private_count": 2},
+		"gateways": map[string]interface{}{"igw_count": 1, "nat_count": 1},
+		"eks":      map[string]interface{}{"node_count": 2},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return builder.GetModel()
+}
+
+func TestModelValidator(t *testing.T) {
+	validator := infra.NewModelValidator()
+	assert.NoError(t, validator.Validate(validationTestModel()))
+
+	model := validationTestModel()
+	utils.ResourceNamed(t, model, "private-subnet-2").SetProperty("cidr_block", "10.0.10.128/25")
+	utils.ResourceNamed(t, model, "private-subnet-2").SetProperty("availability_zone", "us-west-2a")
+	utils.ResourceNamed(t, model, "nat-gateway-1").SetProperty("subnet_id", "private-subnet-1")
+	utils.ResourceNamed(t, model, infra.DefaultNodeGroupName).SetProperty("cluster_name", "other-cluster")
+
+	err := validator.Validate(model)
+	require.Error(t, err)
+	var errs infra.ModelErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, infra.ModelErrors{
+		{
+			Resource: "subnet private-subnet-2",
+			Problem:  "its CIDR block 10.0.10.128/25 overlaps 10.0.10.0/24 of subnet private-subnet-1",
+			Fix:      "give each subnet of main-vpc a CIDR block of its own",
+		},
+		{
+			Resource: "EKS cluster main-eks-cluster",
+			Problem:  "its subnets private-subnet-1, private-subnet-2 are all in one availability zone, but EKS needs at least 2",
+			Fix:      `describe private subnets in two or more availability zones, such as "2 private subnets across 2 AZs"`,
+		},
+		{
+			Resource: "NAT gateway nat-gateway-1",
+			Problem:  "it is in the private subnet private-subnet-1, where it cannot reach the internet",
+			Fix:      "place it in a public subnet",
+		},
+		{
+			Resource: "node group main-node-group",
+			Problem:  "its cluster other-cluster is not in the model",
+			Fix:      "set its cluster to one of main-eks-cluster",
+		},
+	}, errs)
+	assert.Contains(t, err.Error(), "the model is not valid:\n  - subnet private-subnet-2: its CIDR block")
+}
+
+func TestModelValidatorNetworks(t *testing.T) {
+	validator := infra.NewModelValidator()
+
+	// The subnets of other VPCs, and of the copies of a VPC in other regions, may overlap
+	model := validationTestModel()
+	infra.AddSecondaryRegion(model, "us-east-1")
+	other := infra.CreateSubnet("other-subnet", "other-vpc", "10.0.10.0/24", "us-west-2a")
+	model.AddResource(other)
+	assert.NoError(t, validator.Validate(model))
+
+	// A cluster with a single subnet, and a public NAT gateway without a subnet
+	model = validationTestModel()
+	utils.ResourceNamed(t, model, "main-eks-cluster").SetProperty("vpc_config", map[string]interface{}{
+		"subnet_ids": []interface{}{"private-subnet-1"},
+	})
+	utils.ResourceNamed(t, model, "nat-gateway-1").SetProperty("subnet_id", "")
+	err := validator.Validate(model)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "EKS cluster main-eks-cluster: it has 1 subnet, but EKS needs subnets in at least 2 availability zones")
+	assert.Contains(t, err.Error(), "NAT gateway nat-gateway-1: it is in no subnet")
+
+	// Subnets referenced by ID are assumed to be in zones of their own, and private NAT
+	// gateways may be in private subnets
+	model = validationTestModel()
+	utils.ResourceNamed(t, model, "main-eks-cluster").SetProperty("vpc_config", map[string]interface{}{
+		"subnet_ids": []string{"subnet-0a1b2c", "subnet-3d4e5f"},
+	})
+	utils.ResourceNamed(t, model, "nat-gateway-1").SetProperty("subnet_id", "private-subnet-1")
+	utils.ResourceNamed(t, model, "nat-gateway-1").SetProperty("connectivity_type", "private")
+	assert.NoError(t, validator.Validate(model))
+}
+
+func TestModelValidatorSubnetCapacity(t *testing.T) {
+	validator := infra.NewModelValidator()
+
+	// Subnets that do not fit the VPC are packed from its start, so that the ones past its end
+	// are reported by name, with the VPC size all of them need
+	builder := infra.NewModelBuilder()
+	require.NoError(t, builder.BuildFromParsedEntities(map[string]interface{}{
+		"region":  "us-west-2",
+		"vpc":     map[string]interface{}{"cidr_block": "10.0.0.0/23"},
+		"subnets": map[string]interface{}{"public_count": 2, "private_count": 2},
+	}))
+	err := validator.Validate(builder.GetModel())
+	require.Error(t, err)
+	var errs infra.ModelErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, infra.ModelErrors{
+		{
+			Resource: "subnet private-subnet-1",
+			Problem:  "its CIDR block 10.0.2.0/24 does not fit in 10.0.0.0/23 of VPC main-vpc",
+			Fix:      "the subnets of main-vpc need a /22 VPC or larger; use a larger VPC CIDR, or fewer or smaller subnets",
+		},
+		{
+			Resource: "subnet private-subnet-2",
+			Problem:  "its CIDR block 10.0.3.0/24 does not fit in 10.0.0.0/23 of VPC main-vpc",
+			Fix:      "the subnets of main-vpc need a /22 VPC or larger; use a larger VPC CIDR, or fewer or smaller subnets",
+		},
+	}, errs)
+
+	// A subnet outside a VPC with room for it is to be moved, not the VPC grown
+	model := validationTestModel()
+	utils.ResourceNamed(t, model, "public-subnet-2").SetProperty("cidr_block", "10.1.1.0/24")
+	err = validator.Validate(model)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "subnet public-subnet-2: its CIDR block 10.1.1.0/24 does not fit in 10.0.0.0/16 of VPC main-vpc (give it a CIDR block within 10.0.0.0/16)")
+
+	// A subnet larger than its VPC does not fit either
+	model = validationTestModel()
+	utils.ResourceNamed(t, model, "main-vpc").SetProperty("cidr_block", "10.0.0.0/25")
+	err = validator.Validate(model)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "subnet public-subnet-1: its CIDR block 10.0.0.0/24 does not fit in 10.0.0.0/25 of VPC main-vpc")
+}
+
+func TestModelValidatorServiceCIDR(t *testing.T) {
+	validator := infra.NewModelValidator()
+	withServiceCIDR := func(cidr string) *models.InfrastructureModel {
+		model := validationTestModel()
+		utils.ResourceNamed(t, model, "main-eks-cluster").SetProperty("kubernetes_network_config", map[string]interface{}{"service_ipv4_cidr": cidr})
+		return model
+	}
+	assert.NoError(t, validator.Validate(withServiceCIDR("172.20.0.0/16")))
+
+	testCases := []struct {
+		cidr     string
+		expected string
+	}{
+		{"10.0.0.0/12", "EKS cluster main-eks-cluster: its service CIDR 10.0.0.0/12 overlaps 10.0.0.0/16 of VPC main-vpc (give the cluster a service CIDR outside its VPC, such as 172.20.0.0/16)"},
+		{"10.0.10.0/24", "EKS cluster main-eks-cluster: its service CIDR 10.0.10.0/24 overlaps 10.0.0.0/16 of VPC main-vpc"},
+		{"172.20.0.0/8", "its service CIDR 172.20.0.0/8 is not the start of a block; use 172.0.0.0/8"},
+		{"10.0.0.0/11", "its service CIDR 10.0.0.0/11 is a /11, but EKS needs /12 to /24"},
+		{"100.64.0.0/16", "its service CIDR 100.64.0.0/16 is not in a private range"},
+		{"services", "its service CIDR services is not an IPv4 CIDR block"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.cidr, func(t *testing.T) {
+			err := validator.Validate(withServiceCIDR(tc.cidr))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expected)
+		})
+	}
+
+	// A cluster without subnets of the model is checked against the VPCs of its region
+	model := withServiceCIDR("10.0.0.0/16")
+	utils.ResourceNamed(t, model, "main-eks-cluster").SetProperty("vpc_config", map[string]interface{}{
+		"subnet_ids": []string{"subnet-0a1b2c", "subnet-3d4e5f"},
+	})
+	err := validator.Validate(model)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "its service CIDR 10.0.0.0/16 overlaps 10.0.0.0/16 of VPC main-vpc")
+}
+
+func TestModelValidatorEKSVersions(t *testing.T) {
+	validator := infra.NewModelValidator()
+	withVersion := func(version string) *models.InfrastructureModel {
+		model := validationTestModel()
+		utils.ResourceNamed(t, model, "main-eks-cluster").SetProperty("version", version)
+		return model
+	}
+	for _, version := range infra.SupportedEKSVersions() {
+		assert.NoError(t, validator.Validate(withVersion(version)), version)
+	}
+
+	err := validator.Validate(withVersion("1.19"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "EKS cluster main-eks-cluster: its Kubernetes version 1.19 is not one EKS creates clusters with (use 1.25 to 1.31, such as \"EKS cluster version 1.31\")")
+
+	assert.NoError(t, infra.ValidateEKSVersion("1.29"))
+	assert.EqualError(t, infra.ValidateEKSVersion("1.19"), "EKS does not support Kubernetes 1.19; use 1.25 to 1.31")
+	assert.EqualError(t, infra.ValidateEKSVersion("latest"), `"latest" is not a Kubernetes version such as 1.31`)
+}
+
+func TestModelValidatorNodeGroups(t *testing.T) {
+	validator := infra.NewModelValidator()
+	nodeGroup := func(model *models.InfrastructureModel) *models.Resource {
+		for i := range model.Resources {
+			if model.Resources[i].Type == models.ResourceNodeGroup {
+				return &model.Resources[i]
+			}
+		}
+		return nil
+	}
+
+	testCases := []struct {
+		name          string
+		instanceTypes []string
+		amiType       string
+		version       string
+		expected      string
+	}{
+		{name: "Graviton with ARM AMI", instanceTypes: []string{"m7g.large", "c7g.large"}, amiType: "AL2023_ARM_64_STANDARD"},
+		{name: "GPU", instanceTypes: []string{"g5.xlarge"}, amiType: "AL2_x86_64_GPU"},
+		{name: "Custom AMI", instanceTypes: []string{"t4g.medium"}, amiType: "CUSTOM"},
+		{name: "Older node version", instanceTypes: []string{"t3.medium"}, version: "1.24"},
+		{name: "Mixed architectures", instanceTypes: []string{"t3.medium", "t4g.medium"}, expected: "its instance types mix arm64 (t4g.medium) and x86_64 (t3.medium), but a node group runs one AMI"},
+		{name: "Graviton with x86 AMI", instanceTypes: []string{"t4g.medium"}, amiType: "AL2_x86_64", expected: "its AMI type AL2_x86_64 is for x86_64 instances, but t4g.medium is not one (use instance types the AMI type runs on, or an AMI type such as AL2_ARM_64)"},
+		{name: "x86 with ARM AMI", instanceTypes: []string{"m5.large"}, amiType: "BOTTLEROCKET_ARM_64", expected: "its AMI type BOTTLEROCKET_ARM_64 is for arm64 instances, but m5.large is not one"},
+		{name: "GPU AMI without GPU", instanceTypes: []string{"m5.large"}, amiType: "AL2023_x86_64_NVIDIA", expected: "its AMI type AL2023_x86_64_NVIDIA is for NVIDIA GPU instances, but m5.large has no GPU"},
+		{name: "Unknown AMI type", instanceTypes: []string{"m5.large"}, amiType: "UBUNTU", expected: "its AMI type UBUNTU is not an EKS AMI type"},
+		{name: "Newer node version", instanceTypes: []string{"t3.medium"}, version: "1.28", expected: "its Kubernetes version 1.28 is not within three minor versions before 1.27 of its cluster"},
+		{name: "Too old node version", instanceTypes: []string{"t3.medium"}, version: "1.23", expected: "its Kubernetes version 1.23 is not within three minor versions before 1.27"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			model := validationTestModel()
+			group := nodeGroup(model)
+			group.SetProperty("instance_types", tc.instanceTypes)
+			if tc.amiType != "" {
+				group.SetProperty("ami_type", tc.amiType)
+			}
+			if tc.version != "" {
+				group.SetProperty("version", tc.version)
+			}
+			err := validator.Validate(model)
+			if tc.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "node group "+group.Name+": "+tc.expected)
+		})
+	}
+}
+
+func TestModelValidatorEKSAddons(t *testing.T) {
+	validator := infra.NewModelValidator()
+	withKubeProxy := func(version string) *models.InfrastructureModel {
+		model := validationTestModel()
+		model.AddResource(infra.CreateEKSAddon("main-eks-cluster-kube-proxy", "main-eks-cluster", infra.EKSAddon{Name: "kube-proxy"}, version))
+		return model
+	}
+	assert.NoError(t, validator.Validate(withKubeProxy("")))
+	assert.NoError(t, validator.Validate(withKubeProxy("v1.27.16-eksbuild.14")))
+
+	err := validator.Validate(withKubeProxy("v1.30.6-eksbuild.3"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "EKS add-on main-eks-cluster-kube-proxy: its version v1.30.6-eksbuild.3 is for Kubernetes 1.30, but its cluster main-eks-cluster runs 1.27 (pin it to v1.27.16-eksbuild.14, or leave it unpinned)")
+
+	// The other add-ons support a range of versions
+	model := validationTestModel()
+	model.AddResource(infra.CreateEKSAddon("main-eks-cluster-coredns", "main-eks-cluster", infra.EKSAddon{Name: "coredns"}, "v1.11.4-eksbuild.2"))
+	assert.NoError(t, validator.Validate(model))
+}
+
+func TestIsPublicSubnet(t *testing.T) {
+	assert.True(t, infra.IsPublicSubnet(infra.CreateSubnet("public-subnet-1", "main-vpc", "10.0.0.0/24", "us-west-2a")))
+	assert.False(t, infra.IsPublicSubnet(infra.CreateSubnet("private-subnet-1", "main-vpc", "10.0.10.0/24", "us-west-2a")))
+
+	// The setting wins over the name
+	subnet := infra.CreateSubnet("web", "main-vpc", "10.0.0.0/24", "us-west-2a")
+	subnet.AddProperty("map_public_ip_on_launch", true)
+	assert.True(t, infra.IsPublicSubnet(subnet))
+}
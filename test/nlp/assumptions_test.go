@@ -0,0 +1,88 @@
+package nlp
+
+import (
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assumedFields returns the entity paths of the assumptions in a report
+func assumedFields(report *nlp.ExtractionReport) []string {
+	var fields []string
+	for _, assumption := range report.Assumptions {
+		name := assumption.Entity
+		if assumption.Field != "" {
+			name += "." + assumption.Field
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+func TestExtractEntitiesWithReport(t *testing.T) {
+	t.Run("Sparse description", func(t *testing.T) {
+		entities, report, err := nlp.NewParser().ExtractEntitiesWithReport("an eks cluster with 3 nodes")
+		require.NoError(t, err)
+		subnets := entities["subnets"].(map[string]interface{})
+		assert.Equal(t, 2, subnets["private_count"], "The EKS cluster needs subnets in two availability zones")
+
+		fields := assumedFields(report)
+		assert.Contains(t, fields, "region")
+		assert.Contains(t, fields, "vpc", "An implicit VPC should be reported")
+		assert.Contains(t, fields, "vpc.cidr_block")
+		assert.Contains(t, fields, "subnets.public_count")
+		assert.Contains(t, fields, "gateways.nat_count")
+		assert.Contains(t, fields, "eks.version")
+		assert.NotContains(t, fields, "eks.node_count", "A stated node count is not an assumption")
+
+		assert.Equal(t, nlp.ConfidenceDefault, report.Confidence["region"])
+		assert.Less(t, report.Confidence["eks"], nlp.ConfidenceStated)
+		assert.Greater(t, report.Confidence["eks"], nlp.ConfidenceDefault)
+
+		text := report.Text()
+		assert.Contains(t, text, "Assumptions")
+		assert.Contains(t, text, "vpc.cidr_block = 10.0.0.0/16")
+	})
+
+	t.Run("Explicit description", func(t *testing.T) {
+		description := "a vpc with cidr 10.1.0.0/16 in us-west-2 with 2 public and 2 private subnets, " +
+			"1 internet gateway and 2 nat gateways, and an eks cluster with private api access version 1.29 with 3 nodes on m5.large"
+		_, report, err := nlp.NewParser().ExtractEntitiesWithReport(description)
+		require.NoError(t, err)
+
+		assert.Empty(t, report.Assumptions)
+		for entity, confidence := range report.Confidence {
+			assert.Equal(t, nlp.ConfidenceStated, confidence, "Entity %s should be fully stated", entity)
+		}
+		assert.Contains(t, report.Text(), "None")
+	})
+}
+
+func TestAssessEntitiesInferredValues(t *testing.T) {
+	// Values that differ from the defaults but are not in the text, as an LLM might infer them
+	entities, _ := nlp.NormalizeEntities(map[string]interface{}{
+		"region":  "eu-west-1",
+		"subnets": map[string]interface{}{"public_count": float64(3), "private_count": float64(3)},
+	}, "a highly available network in ireland")
+
+	report := nlp.AssessEntities("a highly available network in ireland", entities)
+	assert.Equal(t, nlp.ConfidenceInferred, report.Confidence["subnets"])
+	assert.Equal(t, nlp.ConfidenceInferred, report.Confidence["region"])
+	assert.NotContains(t, assumedFields(report), "subnets.public_count")
+}
+
+func TestEKSPrivateSubnetAssumption(t *testing.T) {
+	entities, report, err := nlp.NewParser().ExtractEntitiesWithReport("a vpc with 3 public subnets and an eks cluster with 3 nodes")
+	require.NoError(t, err)
+	subnets := entities["subnets"].(map[string]interface{})
+	assert.Equal(t, 3, subnets["private_count"], "The EKS nodes should get a private subnet in each availability zone")
+
+	assert.Contains(t, assumedFields(report), "subnets.private_count", "The derived private subnet count should be reported")
+	assert.NotContains(t, assumedFields(report), "subnets.public_count", "A stated public subnet count is not an assumption")
+
+	_, report, err = nlp.NewParser().ExtractEntitiesWithReport("a vpc with 3 public and 3 private subnets and an eks cluster with 3 nodes")
+	require.NoError(t, err)
+	assert.NotContains(t, assumedFields(report), "subnets.private_count", "A stated private subnet count is not an assumption")
+}
@@ -0,0 +1,350 @@
+package nlp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/internal/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingBackend is a backend that always returns an error
+type failingBackend struct{}
+
+func (b failingBackend) Name() string { return "failing" }
+
+func (b failingBackend) ExtractEntities(ctx context.Context, description string) (map[string]interface{}, error) {
+	return nil, errors.New("backend unavailable")
+}
+
+// newOpenAIServer starts a stub chat completions endpoint that replies with the given content
+func newOpenAIServer(t *testing.T, content string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+
+		var req map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "test-model", req["model"])
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]string{"role": "assistant", "content": content}},
+			},
+		})
+	}))
+}
+
+func TestNewBackend(t *testing.T) {
+	backend, err := nlp.NewBackend("", nlp.BackendConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, nlp.BackendRegex, backend.Name(), "The regex backend should be the default")
+
+	_, err = nlp.NewBackend(nlp.BackendLLM, nlp.BackendConfig{})
+	assert.Error(t, err, "The llm backend should require an API key")
+
+	_, err = nlp.NewBackend("spacy", nlp.BackendConfig{})
+	assert.Error(t, err, "Unknown backends should be rejected")
+}
+
+func TestOpenAIBackendExtractEntities(t *testing.T) {
+	server := newOpenAIServer(t, "```json\n"+`{
+		"region": "eu-west-1",
+		"vpc": {"cidr_block": "10.20.0.0/16"},
+		"subnets": {"public_count": 3, "private_count": 3},
+		"gateways": {"igw_count": 1, "nat_count": 3},
+		"eks": {"version": "1.29", "node_count": 4, "instance_type": "m5.large", "endpoint_public_access": false, "endpoint_private_access": true}
+	}`+"\n```")
+	defer server.Close()
+
+	backend, err := nlp.NewOpenAIBackend(nlp.BackendConfig{
+		APIKey:   "test-key",
+		Model:    "test-model",
+		Endpoint: server.URL,
+		Timeout:  5 * time.Second,
+	})
+	require.NoError(t, err)
+
+	entities, err := backend.ExtractEntities(context.Background(), "a private kubernetes cluster in ireland spread over three zones")
+	require.NoError(t, err)
+
+	assert.Equal(t, "eu-west-1", entities["region"])
+	assert.Equal(t, "10.20.0.0/16", entities["vpc"].(map[string]interface{})["cidr_block"])
+
+	subnets := entities["subnets"].(map[string]interface{})
+	assert.Equal(t, 3, subnets["public_count"], "Counts should be converted to ints")
+	assert.Len(t, subnets["private_cidrs"], 3, "Subnet CIDRs should be generated")
+
+	eks := entities["eks"].(map[string]interface{})
+	assert.Equal(t, true, eks["exists"])
+	assert.Equal(t, "1.29", eks["version"])
+	assert.Equal(t, 4, eks["node_count"])
+	assert.Equal(t, true, eks["endpoint_private_access"])
+}
+
+func TestOpenAIBackendFallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error": {"message": "rate limited"}}`))
+	}))
+	defer server.Close()
+
+	openAI, err := nlp.NewOpenAIBackend(nlp.BackendConfig{APIKey: "test-key", Endpoint: server.URL, Timeout: 5 * time.Second})
+	require.NoError(t, err)
+
+	_, err = openAI.ExtractEntities(context.Background(), "a vpc with 2 public subnets")
+	assert.ErrorContains(t, err, "rate limited")
+
+	backend := nlp.NewReconcilingBackend(openAI, nlp.NewRegexBackend())
+	entities, err := backend.ExtractEntities(context.Background(), "a vpc with 2 public subnets in us-west-2")
+	require.NoError(t, err, "The regex fallback should be used")
+	assert.Equal(t, "us-west-2", entities["region"])
+
+	_, err = nlp.NewReconcilingBackend(failingBackend{}, failingBackend{}).ExtractEntities(context.Background(), "a vpc")
+	assert.Error(t, err, "An error should be returned when both backends fail")
+}
+
+func TestOpenAIBackendRetry(t *testing.T) {
+	requests, failures := 0, 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error": {"message": "overloaded"}}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]string{"role": "assistant", "content": `{"region": "eu-west-1"}`}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	policy := retry.Policy{MaxAttempts: 3, InitialDelay: time.Millisecond}
+	backend, err := nlp.NewOpenAIBackend(nlp.BackendConfig{APIKey: "test-key", Endpoint: server.URL, Timeout: 5 * time.Second, Retry: policy})
+	require.NoError(t, err)
+
+	entities, err := backend.ExtractEntities(context.Background(), "a vpc")
+	require.NoError(t, err, "A server error should be retried")
+	assert.Equal(t, "eu-west-1", entities["region"])
+	assert.Equal(t, 2, requests)
+
+	// The message of the last response is surfaced once the attempts are used up
+	requests, failures = 0, 10
+	_, err = backend.ExtractEntities(context.Background(), "a vpc")
+	assert.EqualError(t, err, "OpenAI request failed with status 503: overloaded")
+	assert.Equal(t, 3, requests)
+}
+
+func TestNormalizeEntities(t *testing.T) {
+	raw := map[string]interface{}{
+		"region":   "mars-north-1",
+		"vpc":      map[string]interface{}{"cidr_block": "10.0.0.0/8"},
+		"subnets":  map[string]interface{}{"public_count": float64(2), "private_count": float64(500), "subnet_mask": float64(20), "reserved_azs": float64(6)},
+		"database": map[string]interface{}{"engine": "postgres"},
+		"asg": map[string]interface{}{
+			"desired_capacity": float64(10),
+			"min_size":         float64(6),
+			"max_size":         float64(2),
+			"instance_type":    "not a type",
+		},
+		"ec2_instance":      map[string]interface{}{"count": float64(2), "os": "windows", "ssh_cidr": "203.0.113.0/24"},
+		"secondary_regions": []interface{}{"US-West-2", "us-east-2", "mars-north-1"},
+		"eks": map[string]interface{}{
+			"node_groups": []interface{}{
+				map[string]interface{}{"capacity_type": "spot", "instance_type": "t3.large", "instance_types": []interface{}{"t3.large", "T3a.large", "huge"}, "min_size": float64(8), "max_size": float64(2)},
+				map[string]interface{}{"capacity_type": "reserved", "desired_size": float64(3)},
+				"not a node group",
+			},
+			"addons": []interface{}{
+				map[string]interface{}{"name": "coredns", "version": "latest"},
+				map[string]interface{}{"name": "istio"},
+				map[string]interface{}{"name": "kube-proxy", "version": "v1.29.0-eksbuild.1"},
+			},
+			"fargate_namespaces": []interface{}{"kube-system", "App", "kube-system", "not_a_namespace"},
+			"karpenter":          true,
+			"controllers":        []interface{}{"external-dns", "istio-ingress", "external-dns"},
+		},
+		"environment": "Staging Env",
+	}
+
+	entities, warnings := nlp.NormalizeEntities(raw, "an asg in us-east-2")
+
+	assert.Equal(t, "us-east-2", entities["region"], "An invalid region should fall back to the description")
+	assert.Equal(t, "10.0.0.0/16", entities["vpc"].(map[string]interface{})["cidr_block"], "An oversized VPC CIDR should be replaced")
+	assert.Equal(t, 1, entities["subnets"].(map[string]interface{})["private_count"], "An out-of-range count should be replaced")
+	assert.Equal(t, []string{"10.0.96.0/20"}, entities["subnets"].(map[string]interface{})["private_cidrs"], "Subnet CIDRs should follow the subnet plan")
+	assert.NotContains(t, entities, "database", "Unsupported entities should be dropped")
+	assert.NotContains(t, entities, "environment", "An invalid environment name should be dropped")
+	assert.Equal(t, []string{"us-west-2"}, entities["secondary_regions"], "Secondary regions should exclude the primary and invalid regions")
+
+	asg := entities["asg"].(map[string]interface{})
+	assert.Equal(t, 2, asg["min_size"], "Inverted bounds should be swapped")
+	assert.Equal(t, 6, asg["max_size"])
+	assert.Equal(t, 2, asg["desired_capacity"], "Desired capacity should be clamped to the bounds")
+	assert.Equal(t, "t3.micro", asg["instance_type"])
+
+	nodeGroups := entities["eks"].(map[string]interface{})["node_groups"].([]interface{})
+	require.Len(t, nodeGroups, 2, "Invalid node groups should be dropped")
+	spot := nodeGroups[0].(map[string]interface{})
+	assert.Equal(t, "SPOT", spot["capacity_type"])
+	assert.Equal(t, 2, spot["min_size"], "Inverted node group sizes should be swapped")
+	assert.Equal(t, 8, spot["max_size"])
+	assert.Equal(t, []string{"t3.large", "t3a.large"}, spot["instance_types"], "Invalid instance types should be dropped")
+	assert.Equal(t, "ON_DEMAND", nodeGroups[1].(map[string]interface{})["capacity_type"], "Unknown capacity types should fall back to ON_DEMAND")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "coredns"},
+		map[string]interface{}{"name": "kube-proxy", "version": "v1.29.0-eksbuild.1"},
+	}, entities["eks"].(map[string]interface{})["addons"], "Unknown add-ons and malformed versions should be dropped")
+	assert.Equal(t, []string{"kube-system", "app"}, entities["eks"].(map[string]interface{})["fargate_namespaces"], "Fargate namespaces should be valid and listed once")
+	assert.Equal(t, true, entities["eks"].(map[string]interface{})["karpenter"])
+	assert.Equal(t, []string{"external-dns"}, entities["eks"].(map[string]interface{})["controllers"], "Controllers should be known and listed once")
+
+	ec2 := entities["ec2_instance"].(map[string]interface{})
+	assert.Equal(t, "amazon-linux-2023", ec2["os"])
+	assert.Equal(t, "203.0.113.0/24", ec2["ssh_cidr"])
+
+	assert.NotEmpty(t, warnings, "Every correction should be reported")
+}
+
+func TestOllamaBackendExtractEntities(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+
+		var req map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "qwen2.5:7b", req["model"])
+		assert.Equal(t, false, req["stream"], "Streaming should be disabled")
+
+		// The entity schema constrains the output format
+		format, ok := req["format"].(map[string]interface{})
+		require.True(t, ok, "The request should carry the entity JSON Schema")
+		assert.Contains(t, format["properties"], "eks")
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": map[string]string{
+				"role":    "assistant",
+				"content": `{"region": "us-west-2", "subnets": {"public_count": 2, "private_count": 2}, "ec2_instance": {"count": 2, "instance_type": "t3.small", "os": "ubuntu"}}`,
+			},
+			"done": true,
+		})
+	}))
+	defer server.Close()
+
+	backend := nlp.NewOllamaBackend(nlp.BackendConfig{
+		Model:    "qwen2.5:7b",
+		Endpoint: server.URL + "/",
+		Timeout:  5 * time.Second,
+	})
+	assert.Equal(t, nlp.BackendOllama, backend.Name())
+
+	entities, err := backend.ExtractEntities(context.Background(), "two ubuntu web servers in oregon")
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2", entities["region"])
+
+	ec2 := entities["ec2_instance"].(map[string]interface{})
+	assert.Equal(t, 2, ec2["count"])
+	assert.Equal(t, "ubuntu", ec2["os"])
+}
+
+func TestOllamaBackendUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "model \"missing\" not found, try pulling it first"}`))
+	}))
+	defer server.Close()
+
+	backend := nlp.NewOllamaBackend(nlp.BackendConfig{Model: "missing", Endpoint: server.URL, Timeout: 5 * time.Second})
+	_, err := backend.ExtractEntities(context.Background(), "a vpc")
+	assert.ErrorContains(t, err, "try pulling it first", "The Ollama error message should be surfaced")
+
+	fallback, err := nlp.NewBackend(nlp.BackendOllama, nlp.BackendConfig{Endpoint: server.URL, Timeout: 5 * time.Second})
+	require.NoError(t, err, "The ollama backend should not require an API key")
+	entities, err := fallback.ExtractEntities(context.Background(), "a vpc with 2 public subnets")
+	require.NoError(t, err, "The regex fallback should be used")
+	assert.Contains(t, entities, "vpc")
+}
+
+func TestAnthropicBackendExtractEntities(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/messages", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("x-api-key"))
+		assert.NotEmpty(t, r.Header.Get("anthropic-version"))
+
+		var req map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, nlp.DefaultAnthropicModel, req["model"])
+
+		// The model must be forced to answer through the entity tool
+		tools := req["tools"].([]interface{})
+		require.Len(t, tools, 1)
+		tool := tools[0].(map[string]interface{})
+		assert.Contains(t, tool["input_schema"].(map[string]interface{})["properties"], "subnets")
+		choice := req["tool_choice"].(map[string]interface{})
+		assert.Equal(t, "tool", choice["type"])
+		assert.Equal(t, tool["name"], choice["name"])
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"content": []map[string]interface{}{
+				{
+					"type":  "tool_use",
+					"name":  tool["name"],
+					"input": map[string]interface{}{"region": "ap-southeast-2", "eks": map[string]interface{}{"node_count": 5}},
+				},
+			},
+			"stop_reason": "tool_use",
+		})
+	}))
+	defer server.Close()
+
+	backend, err := nlp.NewAnthropicBackend(nlp.BackendConfig{APIKey: "test-key", Endpoint: server.URL, Timeout: 5 * time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, nlp.BackendAnthropic, backend.Name())
+
+	entities, err := backend.ExtractEntities(context.Background(), "a kubernetes cluster with five nodes in sydney")
+	require.NoError(t, err)
+	assert.Equal(t, "ap-southeast-2", entities["region"])
+	assert.Equal(t, 5, entities["eks"].(map[string]interface{})["node_count"])
+
+	_, err = nlp.NewBackend(nlp.BackendAnthropic, nlp.BackendConfig{})
+	assert.Error(t, err, "The anthropic backend should require an API key")
+}
+
+func TestReconcileEntities(t *testing.T) {
+	description := "a vpc with cidr 10.1.0.0/16 in us-west-2 and an eks cluster with 3 nodes"
+
+	regex, err := nlp.NewParser().ExtractEntities(description)
+	require.NoError(t, err)
+
+	llm, _ := nlp.NormalizeEntities(map[string]interface{}{
+		"region":  "us-west-1",
+		"vpc":     map[string]interface{}{"cidr_block": "10.0.0.0/16"},
+		"subnets": map[string]interface{}{"public_count": float64(2), "private_count": float64(2)},
+	}, description)
+
+	merged, conflicts := nlp.ReconcileEntities(llm, regex, description)
+
+	assert.Equal(t, "us-west-2", merged["region"], "A region stated in the description should win")
+	assert.Equal(t, "10.1.0.0/16", merged["vpc"].(map[string]interface{})["cidr_block"], "A CIDR stated in the description should win")
+	assert.Equal(t, 2, merged["subnets"].(map[string]interface{})["public_count"], "LLM values should be kept where the regex used a default")
+	assert.Equal(t, []string{"10.1.0.0/24", "10.1.1.0/24"}, merged["subnets"].(map[string]interface{})["public_cidrs"], "Subnet CIDRs should follow the reconciled VPC CIDR")
+	assert.Contains(t, merged, "eks", "Entities missed by the LLM should be restored")
+
+	fields := make(map[string]nlp.Conflict)
+	for _, conflict := range conflicts {
+		fields[conflict.Field] = conflict
+	}
+	assert.Equal(t, nlp.ResolutionRegex, fields["region"].Resolution)
+	assert.Equal(t, nlp.ResolutionRegex, fields["vpc.cidr_block"].Resolution)
+	assert.Contains(t, fields, "eks")
+	assert.NotContains(t, fields, "subnets.public_count", "Regex defaults should not be reported as conflicts")
+}
@@ -0,0 +1,92 @@
+package nlp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedClarifier answers clarification questions from a map keyed by question ID
+type scriptedClarifier struct {
+	answers map[string]string
+	asked   []string
+}
+
+func (c *scriptedClarifier) Ask(ctx context.Context, clarification nlp.Clarification) (string, error) {
+	c.asked = append(c.asked, clarification.ID)
+	return c.answers[clarification.ID], nil
+}
+
+func TestFindClarifications(t *testing.T) {
+	testCases := []struct {
+		name        string
+		description string
+		expected    []string
+	}{
+		{
+			name:        "Ambiguous description",
+			description: "a vpc with a nat gateway and an eks cluster",
+			expected:    []string{"availability_zones", "nat_gateways", "eks_api_access"},
+		},
+		{
+			name:        "Explicit description",
+			description: "a vpc with 3 public and 3 private subnets, one nat gateway per az and an eks cluster with private api access",
+			expected:    nil,
+		},
+		{
+			name:        "Single NAT stated",
+			description: "a vpc with 2 public subnets and a single nat gateway",
+			expected:    nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			entities, err := nlp.NewParser().ExtractEntities(tc.description)
+			require.NoError(t, err)
+
+			var ids []string
+			for _, clarification := range nlp.FindClarifications(tc.description, entities) {
+				ids = append(ids, clarification.ID)
+			}
+			assert.Equal(t, tc.expected, ids)
+		})
+	}
+}
+
+func TestClarifyingBackend(t *testing.T) {
+	clarifier := &scriptedClarifier{answers: map[string]string{
+		"availability_zones": "3",
+		"nat_gateways":       "per-az",
+		"eks_api_access":     "both",
+	}}
+	backend := nlp.NewClarifyingBackend(nlp.NewRegexBackend(), clarifier)
+
+	entities, err := backend.ExtractEntities(context.Background(), "a vpc with a nat gateway and an eks cluster")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"availability_zones", "nat_gateways", "eks_api_access"}, clarifier.asked)
+
+	subnets := entities["subnets"].(map[string]interface{})
+	assert.Equal(t, 3, subnets["private_count"])
+	assert.Len(t, subnets["private_cidrs"], 3, "Subnet CIDRs should follow the new AZ count")
+	assert.Equal(t, 3, entities["gateways"].(map[string]interface{})["nat_count"], "A NAT gateway per AZ should follow the AZ answer")
+
+	eks := entities["eks"].(map[string]interface{})
+	assert.Equal(t, true, eks["endpoint_public_access"])
+	assert.Equal(t, true, eks["endpoint_private_access"])
+
+	// Empty answers keep the non-interactive defaults
+	defaults, err := nlp.NewClarifyingBackend(nlp.NewRegexBackend(), &scriptedClarifier{}).
+		ExtractEntities(context.Background(), "a vpc with a nat gateway and an eks cluster")
+	require.NoError(t, err)
+	regex, err := nlp.NewParser().ExtractEntities("a vpc with a nat gateway and an eks cluster")
+	require.NoError(t, err)
+	assert.Equal(t, regex, defaults)
+
+	_, err = nlp.NewClarifyingBackend(nlp.NewRegexBackend(), &scriptedClarifier{answers: map[string]string{"eks_api_access": "sometimes"}}).
+		ExtractEntities(context.Background(), "an eks cluster with 3 public subnets")
+	assert.Error(t, err, "Answers outside the options should be rejected")
+}
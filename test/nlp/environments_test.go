@@ -0,0 +1,85 @@
+package nlp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitEnvironments(t *testing.T) {
+	testCases := []struct {
+		name        string
+		description string
+		expected    []nlp.Environment
+	}{
+		{
+			name:        "Count borrowed from another environment",
+			description: "create dev and prod environments with a VPC and an EKS cluster, prod with 3 NAT gateways and dev with 1",
+			expected: []nlp.Environment{
+				{Name: "dev", Description: "1 NAT gateways. create an environment with a VPC and an EKS cluster"},
+				{Name: "prod", Description: "3 NAT gateways. create an environment with a VPC and an EKS cluster"},
+			},
+		},
+		{
+			name:        "Environment without its own clause",
+			description: "Create dev, staging and prod environments: a VPC with 2 public and 2 private subnets. prod with 3 nodes on m5.large; staging: 2 nodes",
+			expected: []nlp.Environment{
+				{Name: "dev", Description: "Create an environment: a VPC with 2 public and 2 private subnets."},
+				{Name: "staging", Description: "2 nodes. Create an environment: a VPC with 2 public and 2 private subnets."},
+				{Name: "prod", Description: "3 nodes on m5.large. Create an environment: a VPC with 2 public and 2 private subnets."},
+			},
+		},
+		{
+			name:        "Single environment",
+			description: "a VPC in the prod environment with a NAT gateway",
+			expected:    nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, nlp.SplitEnvironments(tc.description))
+		})
+	}
+}
+
+func TestSplitEnvironmentsParse(t *testing.T) {
+	environments := nlp.SplitEnvironments("create dev and prod environments with a VPC with 3 public and 3 private subnets, prod with 3 NAT gateways and dev with 1")
+	require.Len(t, environments, 2)
+
+	natCounts := make(map[string]interface{})
+	for _, environment := range environments {
+		entities, err := nlp.NewParser().ExtractEntities(environment.Description)
+		require.NoError(t, err)
+		natCounts[environment.Name] = entities["gateways"].(map[string]interface{})["nat_count"]
+	}
+	assert.Equal(t, map[string]interface{}{"dev": 1, "prod": 3}, natCounts)
+}
+
+func TestExtractEnvironment(t *testing.T) {
+	testCases := []struct {
+		description string
+		expected    string
+	}{
+		{description: "a vpc with an eks cluster for staging", expected: "staging"},
+		{description: "a vpc in the prod environment with a nat gateway", expected: "prod"},
+		{description: "a production environment with 3 private subnets", expected: "production"},
+		{description: "a vpc with 2 public subnets", expected: ""},
+		{description: "a vpc with 2 public subnets for testing", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, nlp.ExtractEnvironment(tc.description), tc.description)
+	}
+
+	model, err := nlp.ParseDescription("A VPC with 2 public subnets and an EKS cluster for staging")
+	require.NoError(t, err)
+	assert.Equal(t, "staging", model.Environment)
+	assert.Equal(t, "staging", model.Tags["Environment"])
+	for _, resource := range model.Resources {
+		assert.True(t, strings.HasSuffix(resource.Name, "-staging"), "%s should be named after the environment", resource.Name)
+	}
+}
@@ -0,0 +1,98 @@
+package nlp_test
+
+import (
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegations(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		entity   string
+		expected map[string]interface{}
+	}{
+		{
+			name:     "Without a NAT gateway",
+			input:    "A VPC with 2 public and 2 private subnets without a NAT gateway",
+			entity:   "gateways",
+			expected: map[string]interface{}{"igw_count": 1, "nat_count": 0},
+		},
+		{
+			name:     "No NAT gateways and no internet gateway",
+			input:    "A VPC with 2 private subnets, no NAT gateways and no internet gateway",
+			entity:   "gateways",
+			expected: map[string]interface{}{"igw_count": 0, "nat_count": 0},
+		},
+		{
+			name:     "No public subnets",
+			input:    "A VPC with no public subnets and 3 private subnets",
+			entity:   "subnets",
+			expected: map[string]interface{}{"public_count": 0, "private_count": 3},
+		},
+		{
+			name:     "Zero private subnets",
+			input:    "A VPC with 2 public subnets and 0 private subnets",
+			entity:   "subnets",
+			expected: map[string]interface{}{"public_count": 2, "private_count": 0},
+		},
+		{
+			name:     "No public endpoint",
+			input:    "A VPC and an EKS cluster with 3 nodes and no public endpoint",
+			entity:   "eks",
+			expected: map[string]interface{}{"endpoint_public_access": false, "endpoint_private_access": true},
+		},
+		{
+			name:     "Without DNS hostnames",
+			input:    "A VPC without DNS hostnames",
+			entity:   "vpc",
+			expected: map[string]interface{}{"enable_dns_hostnames": false, "enable_dns_support": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entities, err := nlp.NewParser().ExtractEntities(tt.input)
+			require.NoError(t, err)
+			entity, ok := entities[tt.entity].(map[string]interface{})
+			require.True(t, ok, "Expected a %s entity", tt.entity)
+			for field, value := range tt.expected {
+				assert.Equal(t, value, entity[field], "%s.%s", tt.entity, field)
+			}
+		})
+	}
+}
+
+func TestPrivateOnlyVPCWithNoIGW(t *testing.T) {
+	model, err := nlp.ParseDescription("A private-only VPC with no IGW and 2 private subnets")
+	require.NoError(t, err)
+
+	counts := make(map[models.ResourceType]int)
+	for _, resource := range model.Resources {
+		counts[resource.Type]++
+	}
+	assert.Equal(t, 1, counts[models.ResourceVPC])
+	assert.Equal(t, 2, counts[models.ResourceSubnet], "Only the private subnets should be created")
+	assert.Zero(t, counts[models.ResourceIGW])
+	assert.Zero(t, counts[models.ResourceNATGateway])
+
+	for _, resource := range model.Resources {
+		if resource.Type == models.ResourceSubnet {
+			public, _ := resource.GetProperty("map_public_ip_on_launch")
+			assert.NotEqual(t, true, public, "%s should be private", resource.Name)
+		}
+	}
+}
+
+func TestNegationOnlyAppliesToNegatedPhrases(t *testing.T) {
+	entities, err := nlp.NewParser().ExtractEntities("A VPC with 10 public subnets, a NAT gateway and an EKS cluster with private-only API access")
+	require.NoError(t, err)
+
+	assert.Equal(t, 10, entities["subnets"].(map[string]interface{})["public_count"], "\"10 public\" is not \"0 public\"")
+	assert.Equal(t, 1, entities["gateways"].(map[string]interface{})["nat_count"])
+	assert.Equal(t, 1, entities["gateways"].(map[string]interface{})["igw_count"], "A private-only API is not a private-only VPC")
+}
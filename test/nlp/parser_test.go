@@ -3,9 +3,11 @@ package nlp
 import (
 	"testing"
 
+	"github.com/riptano/iac_generator_cli/internal/infra"
 	"github.com/riptano/iac_generator_cli/internal/nlp"
 	"github.com/riptano/iac_generator_cli/pkg/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRegionExtraction(t *testing.T) {
@@ -54,9 +56,9 @@ func TestPatternMatchingVPC(t *testing.T) {
 			name:  "VPC with CIDR",
 			input: "Create a VPC with CIDR 10.0.0.0/16",
 			expected: map[string]interface{}{
-				"exists":              true,
-				"cidr_block":          "10.0.0.0/16",
-				"enable_dns_support":  true,
+				"exists":               true,
+				"cidr_block":           "10.0.0.0/16",
+				"enable_dns_support":   true,
 				"enable_dns_hostnames": true,
 			},
 		},
@@ -64,9 +66,9 @@ func TestPatternMatchingVPC(t *testing.T) {
 			name:  "VPC without CIDR (should use default)",
 			input: "Create a VPC for my application",
 			expected: map[string]interface{}{
-				"exists":              true,
-				"cidr_block":          "10.0.0.0/16",
-				"enable_dns_support":  true,
+				"exists":               true,
+				"cidr_block":           "10.0.0.0/16",
+				"enable_dns_support":   true,
 				"enable_dns_hostnames": true,
 			},
 		},
@@ -74,9 +76,9 @@ func TestPatternMatchingVPC(t *testing.T) {
 			name:  "VPC mentioned multiple times (should extract CIDR)",
 			input: "Create a VPC with CIDR 172.16.0.0/16 and configure the VPC with DNS hostnames",
 			expected: map[string]interface{}{
-				"exists":              true,
-				"cidr_block":          "172.16.0.0/16",
-				"enable_dns_support":  true,
+				"exists":               true,
+				"cidr_block":           "172.16.0.0/16",
+				"enable_dns_support":   true,
 				"enable_dns_hostnames": true,
 			},
 		},
@@ -205,60 +207,60 @@ func TestPatternMatchingEKS(t *testing.T) {
 			name:  "Basic EKS",
 			input: "Create an EKS cluster",
 			expected: map[string]interface{}{
-				"exists":                true,
-				"endpoint_public_access": true,
+				"exists":                  true,
+				"endpoint_public_access":  true,
 				"endpoint_private_access": false,
-				"version":               "1.27",
-				"node_count":            2,
-				"instance_type":         "t3.medium",
+				"version":                 "1.27",
+				"node_count":              2,
+				"instance_type":           "t3.medium",
 			},
 		},
 		{
 			name:  "EKS with private access",
 			input: "Create an EKS cluster with private API access",
 			expected: map[string]interface{}{
-				"exists":                true,
-				"endpoint_public_access": false,
+				"exists":                  true,
+				"endpoint_public_access":  false,
 				"endpoint_private_access": true,
-				"version":               "1.27",
-				"node_count":            2,
-				"instance_type":         "t3.medium",
+				"version":                 "1.27",
+				"node_count":              2,
+				"instance_type":           "t3.medium",
 			},
 		},
 		{
 			name:  "EKS with version",
 			input: "Create an EKS cluster with version 1.28",
 			expected: map[string]interface{}{
-				"exists":                true,
-				"endpoint_public_access": true,
+				"exists":                  true,
+				"endpoint_public_access":  true,
 				"endpoint_private_access": false,
-				"version":               "1.28",
-				"node_count":            2,
-				"instance_type":         "t3.medium",
+				"version":                 "1.28",
+				"node_count":              2,
+				"instance_type":           "t3.medium",
 			},
 		},
 		{
 			name:  "EKS with node pool",
 			input: "Create an EKS cluster with a node pool of 3 nodes",
 			expected: map[string]interface{}{
-				"exists":                true,
-				"endpoint_public_access": true,
+				"exists":                  true,
+				"endpoint_public_access":  true,
 				"endpoint_private_access": false,
-				"version":               "1.27",
-				"node_count":            3,
-				"instance_type":         "t3.medium",
+				"version":                 "1.27",
+				"node_count":              3,
+				"instance_type":           "t3.medium",
 			},
 		},
 		{
 			name:  "EKS with instance type",
 			input: "Create an EKS cluster with t3.large instances",
 			expected: map[string]interface{}{
-				"exists":                true,
-				"endpoint_public_access": true,
+				"exists":                  true,
+				"endpoint_public_access":  true,
 				"endpoint_private_access": false,
-				"version":               "1.27",
-				"node_count":            2,
-				"instance_type":         "t3.large",
+				"version":                 "1.27",
+				"node_count":              2,
+				"instance_type":           "t3.large",
 			},
 		},
 	}
@@ -282,13 +284,13 @@ func TestTableDrivenParsingTests(t *testing.T) {
 			description: "Create a VPC in us-east-1",
 			assertions: func(t *testing.T, entities map[string]interface{}) {
 				assert.Equal(t, "us-east-1", entities["region"], "Region mismatch")
-				
+
 				// Check VPC exists
 				vpc, ok := entities["vpc"].(map[string]interface{})
 				assert.True(t, ok, "VPC not found in parsed entities")
 				assert.True(t, vpc["exists"].(bool), "VPC exists flag not found")
 				assert.Equal(t, true, vpc["exists"], "VPC exists flag mismatch")
-				
+
 				// Check VPC CIDR
 				assert.True(t, vpc["cidr_block"] != nil, "VPC CIDR block not found")
 				assert.Equal(t, "10.0.0.0/16", vpc["cidr_block"], "VPC CIDR block mismatch")
@@ -299,13 +301,13 @@ func TestTableDrivenParsingTests(t *testing.T) {
 			description: "Create a VPC with CIDR 192.168.0.0/16 in us-west-2",
 			assertions: func(t *testing.T, entities map[string]interface{}) {
 				assert.Equal(t, "us-west-2", entities["region"], "Region mismatch")
-				
+
 				// Check VPC exists
 				vpc, ok := entities["vpc"].(map[string]interface{})
 				assert.True(t, ok, "VPC not found in parsed entities")
 				assert.True(t, vpc["exists"].(bool), "VPC exists flag not found")
 				assert.Equal(t, true, vpc["exists"], "VPC exists flag mismatch")
-				
+
 				// Check VPC CIDR
 				assert.True(t, vpc["cidr_block"] != nil, "VPC CIDR block not found")
 				assert.Equal(t, "192.168.0.0/16", vpc["cidr_block"], "VPC CIDR block mismatch")
@@ -316,11 +318,11 @@ func TestTableDrivenParsingTests(t *testing.T) {
 			description: "Create a VPC with 3 public subnets and 2 private subnets in eu-central-1",
 			assertions: func(t *testing.T, entities map[string]interface{}) {
 				assert.Equal(t, "eu-central-1", entities["region"], "Region mismatch")
-				
+
 				// Check VPC exists
 				_, ok := entities["vpc"].(map[string]interface{})
 				assert.True(t, ok, "VPC not found in parsed entities")
-				
+
 				// Check subnets
 				subnets, ok := entities["subnets"].(map[string]interface{})
 				assert.True(t, ok, "Subnets not found in parsed entities")
@@ -333,11 +335,11 @@ func TestTableDrivenParsingTests(t *testing.T) {
 			description: "Create a VPC with an Internet Gateway and 2 NAT Gateways in us-east-2",
 			assertions: func(t *testing.T, entities map[string]interface{}) {
 				assert.Equal(t, "us-east-2", entities["region"], "Region mismatch")
-				
+
 				// Check VPC exists
 				_, ok := entities["vpc"].(map[string]interface{})
 				assert.True(t, ok, "VPC not found in parsed entities")
-				
+
 				// Check gateways
 				gateways, ok := entities["gateways"].(map[string]interface{})
 				assert.True(t, ok, "Gateways not found in parsed entities")
@@ -350,17 +352,17 @@ func TestTableDrivenParsingTests(t *testing.T) {
 			description: "Create an EKS cluster in us-east-1 with private API access and a VPC with CIDR 10.0.0.0/16",
 			assertions: func(t *testing.T, entities map[string]interface{}) {
 				assert.Equal(t, "us-east-1", entities["region"], "Region mismatch")
-				
+
 				// Check VPC exists
 				vpc, ok := entities["vpc"].(map[string]interface{})
 				assert.True(t, ok, "VPC not found in parsed entities")
 				assert.True(t, vpc["exists"].(bool), "VPC exists flag not found")
 				assert.Equal(t, true, vpc["exists"], "VPC exists flag mismatch")
-				
+
 				// Check VPC CIDR
 				assert.True(t, vpc["cidr_block"] != nil, "VPC CIDR block not found")
 				assert.Equal(t, "10.0.0.0/16", vpc["cidr_block"], "VPC CIDR block mismatch")
-				
+
 				// Check EKS
 				eks, ok := entities["eks"].(map[string]interface{})
 				assert.True(t, ok, "EKS not found in parsed entities")
@@ -369,7 +371,7 @@ func TestTableDrivenParsingTests(t *testing.T) {
 			},
 		},
 	}
-	
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			parser := nlp.NewParser()
@@ -392,9 +394,9 @@ func TestEntityValidation(t *testing.T) {
 			entities: map[string]interface{}{
 				"region": "us-east-1",
 				"vpc": map[string]interface{}{
-					"exists":              true,
-					"cidr_block":          "10.0.0.0/16",
-					"enable_dns_support":  true,
+					"exists":               true,
+					"cidr_block":           "10.0.0.0/16",
+					"enable_dns_support":   true,
 					"enable_dns_hostnames": true,
 				},
 				"subnets": map[string]interface{}{
@@ -423,9 +425,9 @@ func TestEntityValidation(t *testing.T) {
 			entities: map[string]interface{}{
 				"region": "us-east-1",
 				"vpc": map[string]interface{}{
-					"exists":              true,
-					"cidr_block":          "10.0.0.0/16",
-					"enable_dns_support":  true,
+					"exists":               true,
+					"cidr_block":           "10.0.0.0/16",
+					"enable_dns_support":   true,
 					"enable_dns_hostnames": true,
 				},
 				"subnets": map[string]interface{}{},
@@ -436,9 +438,9 @@ func TestEntityValidation(t *testing.T) {
 			name: "Missing region",
 			entities: map[string]interface{}{
 				"vpc": map[string]interface{}{
-					"exists":              true,
-					"cidr_block":          "10.0.0.0/16",
-					"enable_dns_support":  true,
+					"exists":               true,
+					"cidr_block":           "10.0.0.0/16",
+					"enable_dns_support":   true,
 					"enable_dns_hostnames": true,
 				},
 			},
@@ -449,7 +451,7 @@ func TestEntityValidation(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			result := nlp.ValidateEntities(tt.entities)
-			
+
 			// We've changed the behavior - validation is always successful because
 			// we fix the issues instead of failing. We just verify that fixes were applied.
 			if !tt.expectedValid {
@@ -462,9 +464,9 @@ func TestEntityValidation(t *testing.T) {
 func TestFullModelParsing(t *testing.T) {
 	// Test cases for full model parsing
 	tests := []struct {
-		name               string
-		description        string
-		expectedResources  int
+		name                  string
+		description           string
+		expectedResources     int
 		expectedResourceTypes map[models.ResourceType]int
 	}{
 		{
@@ -472,9 +474,9 @@ func TestFullModelParsing(t *testing.T) {
 			description:       "Create a VPC with CIDR 10.0.0.0/16 in us-east-1",
 			expectedResources: 4, // The default model adds 1 VPC, 1 public subnet, 1 private subnet, 1 IGW
 			expectedResourceTypes: map[models.ResourceType]int{
-				models.ResourceVPC: 1,
+				models.ResourceVPC:    1,
 				models.ResourceSubnet: 2,
-				models.ResourceIGW: 1,
+				models.ResourceIGW:    1,
 			},
 		},
 		{
@@ -492,9 +494,9 @@ func TestFullModelParsing(t *testing.T) {
 			description:       "Create a VPC with 2 public subnets, 2 private subnets, and an Internet Gateway in us-east-2",
 			expectedResources: 6, // VPC + 4 subnets + IGW
 			expectedResourceTypes: map[models.ResourceType]int{
-				models.ResourceVPC:           1,
-				models.ResourceSubnet:        4,
-				models.ResourceIGW:           1,
+				models.ResourceVPC:    1,
+				models.ResourceSubnet: 4,
+				models.ResourceIGW:    1,
 			},
 		},
 		{
@@ -502,12 +504,12 @@ func TestFullModelParsing(t *testing.T) {
 			description:       "AWS infra in us-east-1 with a vpc, 3 public and 3 private subnets, 1 IGW and 3 NAT gateways, plus an EKS Cluster with node group",
 			expectedResources: 13, // VPC + 6 subnets + IGW + 3 NAT + EKS + NodeGroup
 			expectedResourceTypes: map[models.ResourceType]int{
-				models.ResourceVPC:           1,
-				models.ResourceSubnet:        6,
-				models.ResourceIGW:           1,
-				models.ResourceNATGateway:    3,
-				models.ResourceEKSCluster:    1,
-				models.ResourceNodeGroup:     1,
+				models.ResourceVPC:        1,
+				models.ResourceSubnet:     6,
+				models.ResourceIGW:        1,
+				models.ResourceNATGateway: 3,
+				models.ResourceEKSCluster: 1,
+				models.ResourceNodeGroup:  1,
 			},
 		},
 	}
@@ -518,16 +520,16 @@ func TestFullModelParsing(t *testing.T) {
 			model, err := nlp.ParseDescription(tt.description)
 			assert.NoError(t, err, "Error parsing description")
 			assert.NotNil(t, model, "Parsed model is nil")
-			
+
 			// Check resource count
 			assert.Equal(t, tt.expectedResources, len(model.Resources), "Resource count mismatch")
-			
+
 			// Count resources by type
 			resourceCounts := make(map[models.ResourceType]int)
 			for _, resource := range model.Resources {
 				resourceCounts[resource.Type]++
 			}
-			
+
 			// Check resource type counts
 			for resourceType, expectedCount := range tt.expectedResourceTypes {
 				actualCount := resourceCounts[resourceType]
@@ -560,4 +562,600 @@ func TestInvalidDescriptionErrors(t *testing.T) {
 			assert.Error(t, err, "Expected error parsing invalid description")
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestPatternMatchingASG(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]interface{}
+	}{
+		{
+			name:  "ASG with count and instance type behind ALB",
+			input: "an asg of 3 t3.large instances behind the alb",
+			expected: map[string]interface{}{
+				"exists":                       true,
+				"desired_capacity":             3,
+				"min_size":                     3,
+				"max_size":                     6,
+				"instance_type":                "t3.large",
+				"behind_alb":                   true,
+				"instance_refresh_min_healthy": 90,
+			},
+		},
+		{
+			name:  "Auto scaling group with range, user data and instance refresh",
+			input: "an auto scaling group scaling between 2 and 5 instances with user data that installs nginx and docker, instance refresh with 50% min healthy",
+			expected: map[string]interface{}{
+				"exists":                       true,
+				"desired_capacity":             2,
+				"min_size":                     2,
+				"max_size":                     5,
+				"instance_type":                "t3.micro",
+				"behind_alb":                   false,
+				"user_data_packages":           []string{"nginx", "docker"},
+				"instance_refresh_min_healthy": 50,
+			},
+		},
+		{
+			name:     "No ASG mentioned",
+			input:    "a vpc with 2 public subnets",
+			expected: map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := nlp.ExtractASG(tt.input)
+			assert.Equal(t, tt.expected, result, "Extracted ASG info does not match expected")
+		})
+	}
+}
+
+func TestPatternMatchingEC2(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]interface{}
+	}{
+		{
+			name:  "EC2 instances with count, type, key pair and SSH range",
+			input: "3 t3.small ec2 instances running ubuntu with key pair named deploy-key and ssh access from 203.0.113.0/24",
+			expected: map[string]interface{}{
+				"exists":        true,
+				"count":         3,
+				"instance_type": "t3.small",
+				"os":            "ubuntu",
+				"key_name":      "deploy-key",
+				"ssh_cidr":      "203.0.113.0/24",
+				"web":           false,
+				"public":        false,
+			},
+		},
+		{
+			name:  "Single web server with type after the reference",
+			input: "an ec2 instance of type m5.large as a web server in the public subnet",
+			expected: map[string]interface{}{
+				"exists":        true,
+				"count":         1,
+				"instance_type": "m5.large",
+				"os":            "amazon-linux-2023",
+				"web":           true,
+				"public":        true,
+			},
+		},
+		{
+			name:     "Instances belonging to an Auto Scaling Group",
+			input:    "an auto scaling group of 3 t3.micro ec2 instances",
+			expected: map[string]interface{}{},
+		},
+		{
+			name:     "No EC2 mentioned",
+			input:    "a vpc with 2 public subnets",
+			expected: map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := nlp.ExtractEC2(tt.input)
+			assert.Equal(t, tt.expected, result, "Extracted EC2 info does not match expected")
+		})
+	}
+}
+
+func TestExtractResourceNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]string
+	}{
+		{
+			name:     "Naming instruction",
+			input:    "a vpc and an eks cluster; call the VPC prod-network and the cluster payments-eks",
+			expected: map[string]string{"vpc": "prod-network", "eks": "payments-eks"},
+		},
+		{
+			name:     "Named resources",
+			input:    "a VPC named core-net with an auto scaling group called web-asg and an s3 bucket named app-assets",
+			expected: map[string]string{"vpc": "core-net", "asg": "web-asg", "s3_bucket": "app-assets"},
+		},
+		{
+			name:     "Key pair names are not resource names",
+			input:    "an ec2 instance named bastion with key pair named deploy-key",
+			expected: map[string]string{"ec2_instance": "bastion"},
+		},
+		{
+			name:     "No names",
+			input:    "name the vpc with cidr 10.1.0.0/16",
+			expected: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, nlp.ExtractResourceNames(tt.input))
+		})
+	}
+}
+
+func TestResourceNamesInModel(t *testing.T) {
+	model, err := nlp.ParseDescription("a vpc with 2 public subnets and an eks cluster with 3 nodes; call the VPC prod-network and the cluster payments-eks")
+	assert.NoError(t, err)
+
+	names := make(map[models.ResourceType]string)
+	for _, resource := range model.Resources {
+		names[resource.Type] = resource.Name
+	}
+	assert.Equal(t, "prod-network", names[models.ResourceVPC])
+	assert.Equal(t, "prod-network-igw", names[models.ResourceIGW])
+	assert.Equal(t, "payments-eks", names[models.ResourceEKSCluster])
+	assert.Equal(t, "payments-eks-node-group", names[models.ResourceNodeGroup])
+}
+
+func TestExtractTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]string
+	}{
+		{
+			name:     "Pairs joined with and",
+			input:    "a vpc with an eks cluster, tag everything with team=platform and cost-center=1234.",
+			expected: map[string]string{"team": "platform", "cost-center": "1234"},
+		},
+		{
+			name:     "Comma separated with case kept",
+			input:    "Tag all resources with Owner=Data-Eng, app=checkout, and env=staging; add an ec2 instance",
+			expected: map[string]string{"Owner": "Data-Eng", "app": "checkout", "env": "staging"},
+		},
+		{
+			name:     "Dotted value",
+			input:    "tag every resource with the tags version=1.2.3",
+			expected: map[string]string{"version": "1.2.3"},
+		},
+		{
+			name:     "No directive",
+			input:    "a vpc with team=platform",
+			expected: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, nlp.ExtractTags(tt.input))
+		})
+	}
+}
+
+func TestTagsInModel(t *testing.T) {
+	model, err := nlp.ParseDescription("a vpc with an eks cluster; tag everything with Team=Platform, Name=ignored and cost-center=1234")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"Team": "Platform", "cost-center": "1234"}, model.Tags, "Tags should keep their case and skip Name")
+}
+
+func TestExtractSecondaryRegions(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "DR VPC",
+			input:    "a vpc in us-east-1 and a dr vpc in us-west-2",
+			expected: []string{"us-west-2"},
+		},
+		{
+			name:     "Several kinds of secondary VPC",
+			input:    "a vpc in us-east-1, a disaster recovery vpc in eu-west-1 and a standby vpc in eu-west-1",
+			expected: []string{"eu-west-1"},
+		},
+		{
+			name:     "Single region",
+			input:    "a vpc in us-east-1 with 2 public subnets",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, nlp.ExtractSecondaryRegions(tt.input))
+		})
+	}
+}
+
+func TestSecondaryRegionsInModel(t *testing.T) {
+	model, err := nlp.ParseDescription("A DR VPC in us-west-2 and a VPC in us-east-1 with 2 public subnets")
+	assert.NoError(t, err)
+	assert.Equal(t, "us-east-1", model.Region, "The DR VPC's region should not become the primary region")
+	assert.Equal(t, []string{"us-west-2"}, model.SecondaryRegions())
+
+	regions := make(map[string]string)
+	for i, resource := range model.Resources {
+		regions[resource.Name] = model.ResourceRegion(&model.Resources[i])
+	}
+	assert.Equal(t, "us-east-1", regions["main-vpc"])
+	assert.Equal(t, "us-west-2", regions["main-vpc-us-west-2"])
+	assert.Equal(t, "us-west-2", regions["public-subnet-1-us-west-2"])
+}
+
+func TestExtractNodeGroups(t *testing.T) {
+	nodeGroups := nlp.ExtractNodeGroups("an eks cluster with one on-demand node group of 3 m5.large and one spot node group of up to 10 t3.large")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"capacity_type": "ON_DEMAND",
+			"instance_type": "m5.large",
+			"desired_size":  3,
+			"min_size":      3,
+			"max_size":      6,
+		},
+		map[string]interface{}{
+			"capacity_type": "SPOT",
+			"instance_type": "t3.large",
+			"desired_size":  1,
+			"min_size":      1,
+			"max_size":      10,
+		},
+	}, nodeGroups)
+
+	assert.Empty(t, nlp.ExtractNodeGroups("an eks cluster with 3 nodes of t3.large"))
+}
+
+func TestNodeGroupsInModel(t *testing.T) {
+	model, err := nlp.ParseDescription("A VPC with 2 private subnets and an EKS cluster with one on-demand node group of 3 m5.large and one spot node group of up to 10 t3.large")
+	assert.NoError(t, err)
+
+	capacityTypes := make(map[string]string)
+	for _, resource := range model.Resources {
+		if resource.Type != models.ResourceNodeGroup {
+			continue
+		}
+		capacityType, _ := resource.GetProperty("capacity_type")
+		capacityTypes[resource.Name] = capacityType.(string)
+	}
+	assert.Equal(t, map[string]string{
+		"main-node-group-on-demand": "ON_DEMAND",
+		"main-node-group-spot":      "SPOT",
+	}, capacityTypes, "Each node group should replace the default node group")
+}
+
+func TestExtractCapacityMix(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected map[string]int
+	}{
+		{input: "an eks cluster with 10 nodes, 80% spot", expected: map[string]int{"ON_DEMAND": 2, "SPOT": 8}},
+		{input: "an eks cluster with 2 nodes and 90% of the nodes on spot", expected: map[string]int{"ON_DEMAND": 1, "SPOT": 1}},
+		{input: "an eks cluster with 3 nodes using spot instances for the workers", expected: map[string]int{"SPOT": 3}},
+		{input: "an eks cluster with 5 nodes, 100% spot", expected: map[string]int{"SPOT": 5}},
+		{input: "an eks cluster with 5 nodes, 0% spot", expected: map[string]int{}},
+	}
+
+	for _, tt := range tests {
+		entities, err := nlp.NewParser().ExtractEntities(tt.input)
+		assert.NoError(t, err)
+		sizes := make(map[string]int)
+		nodeGroups, _ := entities["eks"].(map[string]interface{})["node_groups"].([]interface{})
+		for _, entry := range nodeGroups {
+			nodeGroup := entry.(map[string]interface{})
+			sizes[nodeGroup["capacity_type"].(string)] = nodeGroup["desired_size"].(int)
+			assert.Equal(t, 2*nodeGroup["desired_size"].(int), nodeGroup["max_size"], tt.input)
+		}
+		assert.Equal(t, tt.expected, sizes, tt.input)
+	}
+
+	nodeGroups := nlp.ExtractCapacityMix("spot workers", 4, "c5.large")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"capacity_type": "SPOT", "instance_type": "c5.large", "desired_size": 4, "min_size": 4, "max_size": 8},
+	}, nodeGroups)
+}
+
+func TestExtractMixedInstanceTypes(t *testing.T) {
+	assert.Equal(t, []string{"m5.large", "m5a.large", "m5n.large"}, nlp.ExtractMixedInstanceTypes("spot nodes with mixed instances of m5.large, m5a.large and m5n.large"))
+	assert.Equal(t, []string{"c6g.xlarge", "c6g.2xlarge"}, nlp.ExtractMixedInstanceTypes("a mixed-instances policy across c6g.xlarge or c6g.2xlarge or c6g.xlarge"))
+	assert.Empty(t, nlp.ExtractMixedInstanceTypes("an eks cluster with 3 nodes of t3.large"))
+
+	entities, err := nlp.NewParser().ExtractEntities("an eks cluster with 10 nodes, 80% spot with a mix of m5.large and m5a.large")
+	assert.NoError(t, err)
+	for _, entry := range entities["eks"].(map[string]interface{})["node_groups"].([]interface{}) {
+		nodeGroup := entry.(map[string]interface{})
+		if nodeGroup["capacity_type"] == "SPOT" {
+			assert.Equal(t, []string{"m5.large", "m5a.large"}, nodeGroup["instance_types"], "Spot node groups should diversify their instance types")
+		} else {
+			assert.NotContains(t, nodeGroup, "instance_types", "On-demand node groups should keep their instance type")
+		}
+	}
+
+	entities, err = nlp.NewParser().ExtractEntities("an eks cluster with 3 nodes and mixed instances of t3.large and t3a.large")
+	assert.NoError(t, err)
+	eks := entities["eks"].(map[string]interface{})
+	assert.Equal(t, []string{"t3.large", "t3a.large"}, eks["instance_types"], "The default node group should get the instance types")
+	assert.Equal(t, "t3.large", eks["instance_type"])
+}
+
+func TestSpotShareInModel(t *testing.T) {
+	model, err := nlp.ParseDescription("A VPC with 2 private subnets and an EKS cluster with 10 nodes, 80% spot")
+	assert.NoError(t, err)
+
+	capacityTypes := make(map[string]interface{})
+	for _, resource := range model.Resources {
+		if resource.Type == models.ResourceNodeGroup {
+			capacityTypes[resource.Name], _ = resource.GetProperty("capacity_type")
+		}
+	}
+	assert.Equal(t, map[string]interface{}{
+		"main-node-group-on-demand": "ON_DEMAND",
+		"main-node-group-spot":      "SPOT",
+	}, capacityTypes, "A spot share should replace the default node group")
+}
+
+func TestExtractEKSAddons(t *testing.T) {
+	addons := nlp.ExtractEKSAddons("an eks cluster with vpc-cni, coredns v1.11.1-eksbuild.9, kube-proxy and the ebs csi driver, plus core dns again")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "vpc-cni"},
+		map[string]interface{}{"name": "coredns", "version": "v1.11.1-eksbuild.9"},
+		map[string]interface{}{"name": "kube-proxy"},
+		map[string]interface{}{"name": "aws-ebs-csi-driver"},
+	}, addons, "Add-ons should be listed once, in the order they are mentioned")
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "aws-efs-csi-driver"},
+		map[string]interface{}{"name": "eks-pod-identity-agent", "version": "v1.2.0-eksbuild.1"},
+	}, nlp.ExtractEKSAddons("the aws efs csi driver and the pod identity agent pinned to v1.2.0-eksbuild.1"))
+
+	assert.Empty(t, nlp.ExtractEKSAddons("an eks cluster with 3 nodes"))
+}
+
+func TestExtractFargateNamespaces(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{input: "run the kube-system and app namespaces on fargate", expected: []string{"kube-system", "app"}},
+		{input: "a fargate profile for the payments namespace", expected: []string{"payments"}},
+		{input: "fargate profiles for the web, api, and jobs namespaces", expected: []string{"web", "api", "jobs"}},
+		{input: "an eks cluster with 3 nodes", expected: nil},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, nlp.ExtractFargateNamespaces(tt.input), tt.input)
+	}
+}
+
+func TestFargateProfilesInModel(t *testing.T) {
+	model, err := nlp.ParseDescription("A VPC with 2 public and 2 private subnets and an EKS cluster that runs the kube-system and app namespaces on Fargate")
+	assert.NoError(t, err)
+
+	var profiles []models.Resource
+	for _, resource := range model.Resources {
+		if resource.Type == models.ResourceFargateProfile {
+			profiles = append(profiles, resource)
+		}
+	}
+	if assert.Len(t, profiles, 1) {
+		assert.Equal(t, "main-eks-cluster-fargate", profiles[0].Name)
+		namespaces, _ := profiles[0].GetProperty("namespaces")
+		assert.Equal(t, []string{"kube-system", "app"}, namespaces)
+		subnets, _ := profiles[0].GetProperty("subnet_ids")
+		assert.Equal(t, []string{"private-subnet-1", "private-subnet-2"}, subnets, "Fargate pods should run in the private subnets")
+	}
+}
+
+func TestExtractEKSControllers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{input: "an eks cluster with ALB controller and external-dns", expected: []string{"aws-load-balancer-controller", "external-dns"}},
+		{input: "an eks cluster with the AWS Load Balancer Controller", expected: []string{"aws-load-balancer-controller"}},
+		{input: "an eks cluster with the cluster autoscaler and External DNS", expected: []string{"cluster-autoscaler", "external-dns"}},
+		{input: "an eks cluster with 3 nodes", expected: nil},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, nlp.ExtractEKSControllers(tt.input), tt.input)
+	}
+}
+
+func TestEKSControllersInModel(t *testing.T) {
+	model, err := nlp.ParseDescription("A VPC with 2 private subnets and an EKS cluster with ALB controller and external-dns")
+	assert.NoError(t, err)
+
+	for _, resource := range model.Resources {
+		switch resource.Type {
+		case models.ResourceEKSCluster:
+			controllers, _ := resource.GetProperty("controllers")
+			assert.Equal(t, []string{"aws-load-balancer-controller", "external-dns"}, controllers)
+		case models.ResourceAutoScalingGroup:
+			t.Errorf("The controllers should not add a %s", resource.Type)
+		}
+	}
+}
+
+func TestKarpenterInModel(t *testing.T) {
+	karpenter := func(description string) bool {
+		model, err := nlp.ParseDescription(description)
+		assert.NoError(t, err)
+		for _, resource := range model.Resources {
+			if resource.Type == models.ResourceEKSCluster {
+				value, _ := resource.GetProperty("karpenter")
+				return value == true
+			}
+		}
+		t.Fatalf("Expected an EKS cluster for %q", description)
+		return false
+	}
+
+	assert.True(t, karpenter("A VPC with 2 private subnets and an EKS cluster with Karpenter"))
+	assert.False(t, karpenter("A VPC with 2 private subnets and an EKS cluster without Karpenter"))
+	assert.False(t, karpenter("A VPC with 2 private subnets and an EKS cluster with 3 nodes"))
+}
+
+func TestAutoscalingInModel(t *testing.T) {
+	cluster := func(description string) models.Resource {
+		model, err := nlp.ParseDescription(description)
+		assert.NoError(t, err)
+		for _, resource := range model.Resources {
+			if resource.Type == models.ResourceEKSCluster {
+				return resource
+			}
+		}
+		t.Fatalf("Expected an EKS cluster for %q", description)
+		return models.Resource{}
+	}
+
+	// The Cluster Autoscaler gets an IAM role for its service account
+	eks := cluster("A VPC with 2 private subnets and an EKS cluster with cluster autoscaler")
+	autoscaling, _ := eks.GetProperty("autoscaling")
+	controllers, _ := eks.GetProperty("controllers")
+	karpenter, _ := eks.GetProperty("karpenter")
+	assert.Equal(t, "cluster-autoscaler", autoscaling)
+	assert.Equal(t, []string{"cluster-autoscaler"}, controllers)
+	assert.Nil(t, karpenter)
+
+	// Karpenter scales the nodes instead, so the Cluster Autoscaler gets no role
+	eks = cluster("A VPC with 2 private subnets and an EKS cluster autoscaled by Karpenter, with external-dns and the cluster autoscaler")
+	autoscaling, _ = eks.GetProperty("autoscaling")
+	controllers, _ = eks.GetProperty("controllers")
+	karpenter, _ = eks.GetProperty("karpenter")
+	assert.Equal(t, "karpenter", autoscaling)
+	assert.Equal(t, []string{"external-dns"}, controllers)
+	assert.Equal(t, true, karpenter)
+
+	eks = cluster("A VPC with 2 private subnets and an EKS cluster with 3 nodes")
+	_, ok := eks.GetProperty("autoscaling")
+	assert.False(t, ok)
+}
+
+func TestEKSAddonsInModel(t *testing.T) {
+	model, err := nlp.ParseDescription("A VPC with 2 private subnets and an EKS cluster with vpc-cni, CoreDNS, kube-proxy and the EBS CSI driver")
+	assert.NoError(t, err)
+
+	var addons []string
+	for _, resource := range model.Resources {
+		switch resource.Type {
+		case models.ResourceEKSAddon:
+			addons = append(addons, resource.Name)
+			_, irsa := resource.GetProperty("service_account")
+			assert.Equal(t, resource.Name == "main-eks-cluster-aws-ebs-csi-driver", irsa, "%s should only have a service account role if it calls AWS APIs", resource.Name)
+		case models.ResourceEKSCluster:
+			oidc, _ := resource.GetProperty("oidc_provider")
+			assert.Equal(t, true, oidc, "The EBS CSI driver's role needs the cluster's OIDC provider")
+		}
+	}
+	assert.Equal(t, []string{
+		"main-eks-cluster-vpc-cni",
+		"main-eks-cluster-coredns",
+		"main-eks-cluster-kube-proxy",
+		"main-eks-cluster-aws-ebs-csi-driver",
+	}, addons)
+}
+
+func TestExtractSubnetPlan(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]interface{}
+	}{
+		{
+			name:     "Subnet size and reserved zones",
+			input:    "a vpc with 3 public and 3 private /20 subnets, leave room for 6 azs",
+			expected: map[string]interface{}{"public_count": 3, "private_count": 3, "subnet_mask": 20, "reserved_azs": 6},
+		},
+		{
+			name:     "Subnets of a size",
+			input:    "a vpc 10.0.0.0/16 with 2 public subnets of /22",
+			expected: map[string]interface{}{"public_count": 2, "private_count": 1, "subnet_mask": 22},
+		},
+		{
+			name:     "Reserved zones are not subnets",
+			input:    "a vpc with subnets, reserving space for 4 availability zones",
+			expected: map[string]interface{}{"public_count": 1, "private_count": 1, "reserved_azs": 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, nlp.ExtractSubnets(tt.input))
+		})
+	}
+}
+
+func TestSubnetPlanInModel(t *testing.T) {
+	model, err := nlp.ParseDescription("A VPC with 2 public and 2 private /20 subnets, leave room for 6 AZs")
+	assert.NoError(t, err)
+
+	cidrs := make(map[string]interface{})
+	for _, resource := range model.Resources {
+		if resource.Type == models.ResourceSubnet {
+			cidrs[resource.Name], _ = resource.GetProperty("cidr_block")
+		}
+	}
+	assert.Equal(t, "10.0.16.0/20", cidrs["public-subnet-2"])
+	assert.Equal(t, "10.0.96.0/20", cidrs["private-subnet-1"], "Private subnets should start after the reserved public tier")
+
+	_, err = nlp.ParseDescription("A VPC with 3 public and 3 private /18 subnets, leave room for 6 AZs")
+	assert.ErrorContains(t, err, "has room for 4 /18 subnets")
+}
+
+func TestServiceCIDRInModel(t *testing.T) {
+	model, err := nlp.ParseDescription("An EKS cluster with 2 public and 2 private subnets and a service CIDR of 172.20.0.0/16")
+	require.NoError(t, err)
+
+	for _, resource := range model.Resources {
+		switch resource.Type {
+		case models.ResourceVPC:
+			cidr, _ := resource.GetProperty("cidr_block")
+			assert.Equal(t, "10.0.0.0/16", cidr, "The service CIDR should not be taken for the VPC CIDR")
+		case models.ResourceEKSCluster:
+			assert.Equal(t, "172.20.0.0/16", infra.ServiceCIDR(resource))
+		}
+	}
+}
+
+func TestEKSDefaultPrivateSubnets(t *testing.T) {
+	tests := []struct {
+		description string
+		public      int
+		private     int
+	}{
+		{"Create a VPC with 2 public subnets and an EKS cluster with 3 nodes", 2, 2},
+		{"Create a VPC with 1 public subnet and an EKS cluster", 1, 2},
+		{"Create a VPC with 3 public subnets and an EKS cluster", 3, 3},
+		{"Create a VPC with 3 public and 2 private subnets and an EKS cluster", 3, 2},
+		{"An EKS cluster with 3 nodes", 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			entities, err := nlp.NewParser().ExtractEntities(tt.description)
+			require.NoError(t, err)
+			subnets := entities["subnets"].(map[string]interface{})
+			assert.Equal(t, tt.public, subnets["public_count"])
+			assert.Equal(t, tt.private, subnets["private_count"], "The private subnets of the cluster should span two availability zones")
+
+			model, err := nlp.ParseDescription(tt.description)
+			require.NoError(t, err)
+			assert.NoError(t, infra.NewModelValidator().Validate(model))
+		})
+	}
+}
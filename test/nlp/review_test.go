@@ -0,0 +1,98 @@
+package nlp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedReviewer returns the decisions in order and records the reviews it was shown
+type scriptedReviewer struct {
+	decisions []nlp.ReviewDecision
+	reviews   []nlp.Review
+}
+
+func (r *scriptedReviewer) Review(ctx context.Context, review nlp.Review) (nlp.ReviewDecision, error) {
+	r.reviews = append(r.reviews, review)
+	decision := r.decisions[0]
+	r.decisions = r.decisions[1:]
+	return decision, nil
+}
+
+func TestEditEntity(t *testing.T) {
+	entities, err := nlp.NewParser().ExtractEntities("a vpc with 2 public and 2 private subnets and an eks cluster with 3 nodes")
+	require.NoError(t, err)
+	entities["tags"] = map[string]string{"Team": "platform"}
+
+	require.NoError(t, nlp.EditEntity(entities, "eks.node_count", "5"))
+	require.NoError(t, nlp.EditEntity(entities, "eks.endpoint_private_access", "true"))
+	require.NoError(t, nlp.EditEntity(entities, "subnets.public_count", " 3 "))
+	require.NoError(t, nlp.EditEntity(entities, "tags.Owner", "sre"))
+	require.NoError(t, nlp.EditEntity(entities, "region", "eu-west-1"))
+
+	eks := entities["eks"].(map[string]interface{})
+	assert.Equal(t, 5, eks["node_count"])
+	assert.Equal(t, true, eks["endpoint_private_access"])
+	assert.Len(t, entities["subnets"].(map[string]interface{})["public_cidrs"], 3, "Subnet CIDRs should follow the new count")
+	assert.Equal(t, "sre", entities["tags"].(map[string]string)["Owner"])
+	assert.Equal(t, "eu-west-1", entities["region"])
+
+	assert.ErrorContains(t, nlp.EditEntity(entities, "eks.node_count", "many"), "whole number")
+	assert.ErrorContains(t, nlp.EditEntity(entities, "eks.exists", "maybe"), "true or false")
+	assert.ErrorContains(t, nlp.EditEntity(entities, "database.engine", "postgres"), "not one of the parsed entities")
+	assert.ErrorContains(t, nlp.EditEntity(entities, "region.name", "us-east-1"), "has no fields")
+}
+
+func TestReviewEntries(t *testing.T) {
+	entries := nlp.ReviewEntries(map[string]interface{}{
+		"vpc":    map[string]interface{}{"exists": true, "cidr_block": "10.0.0.0/16"},
+		"region": "us-east-1",
+		"tags":   map[string]string{"Team": "platform"},
+	})
+
+	assert.Equal(t, []nlp.ReviewEntry{
+		{Path: "region", Value: "us-east-1"},
+		{Path: "tags.Team", Value: "platform"},
+		{Path: "vpc.cidr_block", Value: "10.0.0.0/16"},
+		{Path: "vpc.exists", Value: true},
+	}, entries)
+}
+
+func TestReviewingBackend(t *testing.T) {
+	reviewer := &scriptedReviewer{decisions: []nlp.ReviewDecision{
+		{Action: nlp.ReviewEdit, Path: "eks.node_count", Value: "many"},
+		{Action: nlp.ReviewEdit, Path: "subnets.private_count", Value: "3"},
+		{Action: nlp.ReviewAccept},
+	}}
+	backend := nlp.NewReviewingBackend(nlp.NewRegexBackend(), reviewer)
+
+	entities, err := backend.ExtractEntities(context.Background(), "a vpc with 2 public and 2 private subnets and an eks cluster with 3 nodes")
+	require.NoError(t, err)
+	assert.Equal(t, 3, entities["subnets"].(map[string]interface{})["private_count"])
+
+	require.Len(t, reviewer.reviews, 3)
+	assert.Empty(t, reviewer.reviews[0].Error)
+	assert.Contains(t, reviewer.reviews[1].Error, "whole number", "A rejected edit should be explained in the next review")
+	assert.Empty(t, reviewer.reviews[2].Error)
+	countSubnets := func(review nlp.Review) int {
+		count := 0
+		for _, resource := range review.Resources {
+			if resource.Type == models.ResourceSubnet {
+				count++
+			}
+		}
+		return count
+	}
+	assert.Equal(t, 4, countSubnets(reviewer.reviews[0]))
+	assert.Equal(t, 5, countSubnets(reviewer.reviews[2]), "Planned resources should follow the edits")
+	assert.Contains(t, reviewer.reviews[0].Text(), "eks.node_count")
+	assert.Contains(t, reviewer.reviews[0].Text(), "main-eks-cluster")
+
+	_, err = nlp.NewReviewingBackend(nlp.NewRegexBackend(), &scriptedReviewer{decisions: []nlp.ReviewDecision{{Action: nlp.ReviewAbort}}}).
+		ExtractEntities(context.Background(), "a vpc with an eks cluster")
+	assert.ErrorIs(t, err, nlp.ErrReviewAborted)
+}
@@ -0,0 +1,139 @@
+package nlp_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultSynonyms(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "a k8s cluster with 3 nodes", expected: "a eks cluster with 3 nodes"},
+		{input: "a Kubernetes  Cluster in a virtual private cloud", expected: "a eks cluster in a vpc"},
+		{input: "2 virtual machines behind a nat gw", expected: "2 ec2 instances behind a nat gateway"},
+		{input: "run kubectl on a kubernetes-cluster", expected: "run kubectl on a kubernetes-cluster"},
+	}
+
+	synonyms := nlp.DefaultSynonyms()
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, synonyms.Apply(tt.input))
+	}
+}
+
+func TestSynonymRegistry(t *testing.T) {
+	synonyms := nlp.NewSynonymRegistry()
+	require.NoError(t, synonyms.Register("kube", "eks"))
+	require.NoError(t, synonyms.RegisterResource("kube fleet", models.ResourceEKSCluster))
+	require.NoError(t, synonyms.Register("eks", "never applied twice"))
+
+	assert.Equal(t, "a eks cluster and a eks", synonyms.Apply("a kube fleet and a kube"), "Longer phrases should win and replacements should not be rewritten")
+
+	assert.Error(t, synonyms.Register("k8s!", "eks"))
+	assert.Error(t, synonyms.Register("kube", " "))
+	assert.ErrorContains(t, synonyms.RegisterResource("mainframe", models.ResourceLambda), "not recognized")
+}
+
+// TestSynonymRegistryConcurrency applies a shared registry from several goroutines while
+// phrases are registered, as concurrent environments and server requests do; go test -race
+// reports it if the registry is not safe for concurrent use
+func TestSynonymRegistryConcurrency(t *testing.T) {
+	registry := nlp.DefaultSynonyms()
+
+	// The goroutines only record what they see, as calls on t would order them
+	results := make([][]string, 8)
+	errs := make([]error, 50)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				results[i] = append(results[i], registry.Apply("a k8s cluster in a virtual private cloud"))
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := range errs {
+			errs[j] = registry.Register("ha nat", "nat gateway per az")
+		}
+	}()
+	wg.Wait()
+
+	for _, applied := range results {
+		for _, result := range applied {
+			assert.Equal(t, "a eks cluster in a vpc", result)
+		}
+	}
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, "a nat gateway per az", registry.Apply("a ha nat"))
+}
+
+func TestLoadSynonymFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "synonyms.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+synonyms:
+  - phrase: compute cluster
+    resource: eks_cluster
+  - phrase: ha egress
+    means: nat gateway per az
+`), 0644))
+
+	synonyms := nlp.DefaultSynonyms()
+	require.NoError(t, synonyms.LoadFile(path))
+	assert.Equal(t, "a eks cluster with nat gateway per az", synonyms.Apply("a compute cluster with HA egress"))
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalid, []byte(`
+synonyms:
+  - phrase: db
+    resource: rds_instance
+  - phrase: both
+    resource: vpc
+    means: vpc
+`), 0644))
+	err := nlp.NewSynonymRegistry().LoadFile(invalid)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "synonyms[0]")
+	assert.Contains(t, err.Error(), "synonyms[1]")
+
+	unknown := filepath.Join(dir, "unknown.yaml")
+	require.NoError(t, os.WriteFile(unknown, []byte("synonyms:\n  - phrase: kube\n    type: eks_cluster\n"), 0644))
+	assert.Error(t, nlp.NewSynonymRegistry().LoadFile(unknown), "Unknown fields should be rejected")
+}
+
+func TestBackendSynonyms(t *testing.T) {
+	synonyms := nlp.DefaultSynonyms()
+	require.NoError(t, synonyms.Register("platform", "eks cluster"))
+
+	backend, err := nlp.NewBackend(nlp.BackendRegex, nlp.BackendConfig{Synonyms: synonyms})
+	require.NoError(t, err)
+
+	entities, err := backend.ExtractEntities(context.Background(), "A VPC with a platform of 3 nodes")
+	require.NoError(t, err)
+	assert.Contains(t, entities, "eks", "Registered synonyms should reach the regex parser")
+
+	model, err := nlp.ParseDescription("A VPC with a Kubernetes cluster with 3 nodes")
+	require.NoError(t, err)
+	var clusters int
+	for _, resource := range model.Resources {
+		if resource.Type == models.ResourceEKSCluster {
+			clusters++
+		}
+	}
+	assert.Equal(t, 1, clusters, "Built-in synonyms should apply by default")
+}
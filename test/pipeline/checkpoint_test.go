@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/riptano/iac_generator_cli/test/fixtures"
+	"github.com/riptano/iac_generator_cli/test/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	testEnv := utils.NewTestEnvironment(t)
+	defer testEnv.Cleanup()
+
+	cacheDir := filepath.Join(testEnv.BaseDir, "cache")
+	params := func() *pipeline.ProcessingParams {
+		return &pipeline.ProcessingParams{
+			Description:    "Create a VPC with 2 public and 2 private subnets and an EKS cluster with 3 nodes of instance type t3.large",
+			OutputFormat:   "terraform",
+			Region:         "us-east-1",
+			Tags:           map[string]string{"Team": "platform"},
+			CacheDir:       cacheDir,
+			ProgressWriter: &bytes.Buffer{},
+		}
+	}
+
+	first := params()
+	fresh, err := pipeline.GenerateFiles(context.Background(), first)
+	require.NoError(t, err)
+	assert.Contains(t, first.ProgressWriter.(*bytes.Buffer).String(), "Assumptions")
+	assert.NotContains(t, first.ProgressWriter.(*bytes.Buffer).String(), "Resumed")
+
+	checkpoint := pipeline.NewCheckpoint(cacheDir, first.Description, first)
+	assert.FileExists(t, filepath.Join(checkpoint.Dir(), "parsed.json"))
+	assert.FileExists(t, filepath.Join(checkpoint.Dir(), "model.json"))
+
+	// A rerun resumes from the checkpoints instead of parsing the description
+	second := params()
+	resumed, err := pipeline.GenerateFiles(context.Background(), second)
+	require.NoError(t, err)
+	progress := second.ProgressWriter.(*bytes.Buffer).String()
+	assert.Contains(t, progress, "Resumed NLPProcessing from checkpoint")
+	assert.Contains(t, progress, "Resumed ModelBuilding from checkpoint")
+	assert.NotContains(t, progress, "Assumptions", "The description should not be parsed again")
+	assert.Equal(t, fresh, resumed)
+
+	// A run that failed after parsing resumes from the parsed model
+	require.NoError(t, os.Remove(filepath.Join(checkpoint.Dir(), "model.json")))
+	third := params()
+	_, err = pipeline.GenerateFiles(context.Background(), third)
+	require.NoError(t, err)
+	progress = third.ProgressWriter.(*bytes.Buffer).String()
+	assert.Contains(t, progress, "Resumed NLPProcessing from checkpoint")
+	assert.NotContains(t, progress, "Resumed ModelBuilding")
+
+	// Another input has its own checkpoint
+	other := params()
+	other.Region = "eu-west-1"
+	assert.NotEqual(t, checkpoint.Dir(), pipeline.NewCheckpoint(cacheDir, other.Description, other).Dir())
+	other.Region = first.Region
+	other.Tags = map[string]string{"Team": "data"}
+	assert.NotEqual(t, checkpoint.Dir(), pipeline.NewCheckpoint(cacheDir, other.Description, other).Dir())
+}
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	testEnv := utils.NewTestEnvironment(t)
+	defer testEnv.Cleanup()
+
+	descriptions := append(append(fixtures.TestDescriptionVPC, fixtures.TestDescriptionEKS...), fixtures.TestDescriptionComplex...)
+	for _, format := range []string{"terraform", "crossplane"} {
+		for i, fixture := range descriptions {
+			params := func() *pipeline.ProcessingParams {
+				return &pipeline.ProcessingParams{
+					Description:    fixture.Description,
+					OutputFormat:   format,
+					Region:         "us-east-1",
+					CacheDir:       filepath.Join(testEnv.BaseDir, "cache", format, string(rune('a'+i))),
+					ProgressWriter: &bytes.Buffer{},
+				}
+			}
+			fresh, err := pipeline.GenerateFiles(context.Background(), params())
+			require.NoError(t, err, fixture.Name)
+
+			resumedParams := params()
+			resumed, err := pipeline.GenerateFiles(context.Background(), resumedParams)
+			require.NoError(t, err, fixture.Name)
+			require.True(t, strings.Contains(resumedParams.ProgressWriter.(*bytes.Buffer).String(), "Resumed ModelBuilding"), fixture.Name)
+			assert.Equal(t, fresh, resumed, "%s (%s) should generate the same files from its checkpoint", fixture.Name, format)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/hooks"
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSink records the events emitted to it
+type recordingSink struct {
+	mu     sync.Mutex
+	events []pipeline.Event
+}
+
+func (s *recordingSink) Emit(event pipeline.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+// ofType returns the events of a type
+func (s *recordingSink) ofType(eventType string) []pipeline.Event {
+	var events []pipeline.Event
+	for _, event := range s.events {
+		if event.Type == eventType {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestJSONLinesSink(t *testing.T) {
+	var out bytes.Buffer
+	reporter := pipeline.NewEventProgressReporter(pipeline.NewJSONLinesSink(&out), "prod", 2)
+	reporter.StartStage("NLPProcessing")
+	reporter.CompleteStage("NLPProcessing")
+	reporter.FailStage("ModelBuilding", errors.New("no resources"))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	var events []pipeline.Event
+	for _, line := range lines {
+		var event pipeline.Event
+		require.NoError(t, json.Unmarshal([]byte(line), &event), line)
+		assert.False(t, event.Time.IsZero(), "Events should be stamped with a time")
+		events = append(events, event)
+	}
+	assert.Equal(t, pipeline.EventStageStarted, events[0].Type)
+	assert.Equal(t, "prod", events[0].Environment)
+	assert.Equal(t, 50, events[1].Percentage)
+	assert.Equal(t, "no resources", events[2].Error)
+	assert.Contains(t, lines[0], `"event":"stage_started","stage":"NLPProcessing","environment":"prod"`)
+}
+
+func TestPipelineEvents(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks are shell commands")
+	}
+
+	outputDir := filepath.Join(t.TempDir(), "infra")
+	sink := &recordingSink{}
+	params := &pipeline.ProcessingParams{
+		Description:  "create dev and prod environments with a VPC with 3 public and 3 private subnets",
+		OutputFormat: "terraform",
+		OutputDir:    outputDir,
+		Region:       "us-east-1",
+		Hooks: hooks.Hooks{
+			Post: []hooks.Hook{{Command: "exit 2", OnFailure: hooks.FailureWarn}},
+		},
+		ProgressWriter: &bytes.Buffer{},
+		Events:         sink,
+	}
+
+	coordinator := pipeline.NewPipelineCoordinator()
+	require.NoError(t, coordinator.InitializePipeline(context.Background(), params))
+	_, err := coordinator.RunPipeline(context.Background(), params)
+	require.NoError(t, err)
+
+	require.NotEmpty(t, sink.events)
+	assert.Equal(t, pipeline.EventPipelineStarted, sink.events[0].Type)
+	last := sink.events[len(sink.events)-1]
+	assert.Equal(t, pipeline.EventPipelineCompleted, last.Type)
+	assert.Contains(t, last.Message, "environments dev, prod")
+
+	// Each environment reports its stages
+	completed := map[string]int{}
+	for _, event := range sink.ofType(pipeline.EventStageCompleted) {
+		completed[event.Environment]++
+	}
+	assert.Equal(t, 3, completed["dev"])
+	assert.Equal(t, 3, completed["prod"])
+
+	var written []string
+	for _, event := range sink.ofType(pipeline.EventFileWritten) {
+		written = append(written, event.Path)
+	}
+	assert.Contains(t, written, filepath.Join(outputDir, "prod", "terraform.tfvars"))
+
+	warnings := sink.ofType(pipeline.EventWarning)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, `post-generation hook "exit 2" failed: exit status 2`, warnings[0].Message)
+	assert.Contains(t, params.ProgressWriter.(*bytes.Buffer).String(), "Warning: post-generation hook")
+
+	// A failed run ends with the error
+	sink.events = nil
+	params.Hooks.Post[0].OnFailure = hooks.FailureFail
+	_, err = coordinator.RunPipeline(context.Background(), params)
+	require.Error(t, err)
+	last = sink.events[len(sink.events)-1]
+	assert.Equal(t, pipeline.EventPipelineFailed, last.Type)
+	assert.Equal(t, err.Error(), last.Error)
+}
@@ -0,0 +1,276 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputFormats(t *testing.T) {
+	assert.Equal(t, []string{"terraform"}, pipeline.OutputFormats("terraform"))
+	assert.Equal(t, []string{"terraform", "crossplane"}, pipeline.OutputFormats("Terraform, crossplane,terraform"))
+	assert.Empty(t, pipeline.OutputFormats(" , "))
+}
+
+func TestPipelineMultipleFormats(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "infra")
+	params := &pipeline.ProcessingParams{
+		Description:      "Create a VPC with an EKS cluster",
+		OutputFormat:     "terraform,crossplane",
+		OutputDir:        outputDir,
+		Region:           "us-east-1",
+		DRRegion:         "us-west-2",
+		ComplianceReport: true,
+		ProgressWriter:   &bytes.Buffer{},
+	}
+
+	coordinator := pipeline.NewPipelineCoordinator()
+	require.NoError(t, coordinator.InitializePipeline(context.Background(), params))
+	result, err := coordinator.RunPipeline(context.Background(), params)
+	require.NoError(t, err)
+	assert.Equal(t, "Successfully generated terraform and crossplane manifests in "+outputDir, result)
+
+	// Each format gets a complete tree of its own, from the same model
+	assert.FileExists(t, filepath.Join(outputDir, "terraform", "main.tf"))
+	assert.FileExists(t, filepath.Join(outputDir, "terraform", "dr", "main.tf"))
+	assert.FileExists(t, filepath.Join(outputDir, "crossplane", "kustomization.yaml"))
+	assert.FileExists(t, filepath.Join(outputDir, "crossplane", "dr", "kustomization.yaml"))
+	assert.FileExists(t, filepath.Join(outputDir, pipeline.ComplianceReportFile), "The report covers the model, which the formats share")
+	assert.NoFileExists(t, filepath.Join(outputDir, "main.tf"))
+
+	// Environments get each format in their directory
+	files, err := pipeline.GenerateFiles(context.Background(), &pipeline.ProcessingParams{
+		Description:    "create dev and prod environments with a VPC with 3 public and 3 private subnets",
+		OutputFormat:   "crossplane,terraform",
+		Region:         "us-east-1",
+		ProgressWriter: &bytes.Buffer{},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, files, "dev/terraform/main.tf")
+	assert.Contains(t, files, "prod/terraform/terraform.tfvars")
+	assert.Contains(t, files, "prod/crossplane/kustomization.yaml")
+}
+
+func TestPipelineMultipleFormatsValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		params pipeline.ProcessingParams
+		want   string
+	}{
+		{"unknown format", pipeline.ProcessingParams{OutputFormat: "terraform,helm"}, "unsupported output format: helm"},
+		{"output file", pipeline.ProcessingParams{OutputFormat: "terraform,crossplane", OutputFile: "main.tf"}, "an output file cannot be used with several output formats, which are each written to a directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := tt.params
+			params.Description = "Create a VPC"
+			params.OutputDir = t.TempDir()
+			params.Region = "us-east-1"
+			err := pipeline.NewPipelineCoordinator().InitializePipeline(context.Background(), &params)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestPipelineLint(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake tflint is a shell script")
+	}
+
+	// The fake tflint reports an issue of every module it lints
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "tflint"), []byte(`#!/bin/sh
+echo '{"issues":[{"rule":{"name":"terraform_unused_declarations","severity":"warning"},"message":"variable \"tags\" is declared but not used","range":{"filename":"variables.tf","start":{"line":1}}}],"errors":[]}'
+exit 2
+`), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	outputDir := filepath.Join(t.TempDir(), "infra")
+	params := &pipeline.ProcessingParams{
+		Description:    "Create a VPC with an EKS cluster",
+		OutputFormat:   "terraform,crossplane",
+		OutputDir:      outputDir,
+		Region:         "us-east-1",
+		Lint:           true,
+		ProgressWriter: &bytes.Buffer{},
+	}
+	coordinator := pipeline.NewPipelineCoordinator()
+	require.NoError(t, coordinator.InitializePipeline(context.Background(), params))
+	result, err := coordinator.RunPipeline(context.Background(), params)
+	require.NoError(t, err, "Lint findings should not fail the run")
+	assert.Contains(t, result, "Lint findings:")
+	assert.Contains(t, result, `terraform/variables.tf:1  tflint  variable "tags" is declared but not used (terraform_unused_declarations)`)
+	assert.Contains(t, result, "0 errors, 1 warnings")
+
+	// Only Terraform is linted
+	params = &pipeline.ProcessingParams{
+		Description:    "Create a VPC",
+		OutputFormat:   "crossplane",
+		OutputDir:      t.TempDir(),
+		Lint:           true,
+		ProgressWriter: &bytes.Buffer{},
+	}
+	err = pipeline.NewPipelineCoordinator().InitializePipeline(context.Background(), params)
+	assert.ErrorContains(t, err, "linting needs the terraform output format")
+}
+
+func TestPipelineLintIAM(t *testing.T) {
+	params := &pipeline.ProcessingParams{
+		Description:    "Create a VPC with 2 private subnets across 2 AZs and an EKS cluster with the EBS CSI driver and external-dns",
+		OutputFormat:   "terraform,crossplane",
+		OutputDir:      filepath.Join(t.TempDir(), "infra"),
+		Region:         "us-east-1",
+		LintIAM:        true,
+		ProgressWriter: &bytes.Buffer{},
+	}
+	coordinator := pipeline.NewPipelineCoordinator()
+	require.NoError(t, coordinator.InitializePipeline(context.Background(), params))
+	result, err := coordinator.RunPipeline(context.Background(), params)
+	require.NoError(t, err)
+	// The generated roles trust single service accounts and attach scoped managed policies
+	assert.Contains(t, result, "IAM findings:")
+	assert.Contains(t, result, "terraform and crossplane files")
+	assert.Contains(t, result, ": 0 errors, 0 warnings")
+}
+
+func TestPipelineEstimate(t *testing.T) {
+	params := &pipeline.ProcessingParams{
+		Description:    "Create a VPC with 2 public and 2 private subnets, 2 NAT gateways and an EKS cluster",
+		OutputFormat:   "terraform,crossplane",
+		OutputDir:      filepath.Join(t.TempDir(), "infra"),
+		Region:         "us-east-1",
+		Estimate:       true,
+		Estimator:      report.EstimatorBuiltin,
+		ProgressWriter: &bytes.Buffer{},
+	}
+	coordinator := pipeline.NewPipelineCoordinator()
+	require.NoError(t, coordinator.InitializePipeline(context.Background(), params))
+	result, err := coordinator.RunPipeline(context.Background(), params)
+	require.NoError(t, err)
+	assert.Contains(t, result, "Cost estimate:\nterraform/module.eks")
+	assert.Contains(t, result, "aws_eks_cluster.this")
+	assert.Contains(t, result, "aws_nat_gateway.this[1]")
+	assert.Contains(t, result, "per month, estimated with the built-in us-east-1 on-demand prices")
+
+	// Only Terraform is estimated
+	params = &pipeline.ProcessingParams{
+		Description:    "Create a VPC",
+		OutputFormat:   "crossplane",
+		OutputDir:      t.TempDir(),
+		Estimate:       true,
+		ProgressWriter: &bytes.Buffer{},
+	}
+	err = pipeline.NewPipelineCoordinator().InitializePipeline(context.Background(), params)
+	assert.ErrorContains(t, err, "the cost estimate needs the terraform output format")
+
+	params.OutputFormat, params.Estimator = "terraform", "aws"
+	err = pipeline.NewPipelineCoordinator().InitializePipeline(context.Background(), params)
+	assert.ErrorContains(t, err, "invalid estimator: aws")
+}
+
+func TestPipelineScan(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake trivy is a shell script")
+	}
+
+	// The fake trivy reports a critical and a medium finding
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "trivy"), []byte(`#!/bin/sh
+cat <<'JSON'
+{"Results": [{"Target": "modules/eks/main.tf", "Misconfigurations": [
+  {"AVDID": "AVD-AWS-0040", "Message": "Public cluster access is enabled.", "Severity": "CRITICAL", "Status": "FAIL", "CauseMetadata": {"Resource": "aws_eks_cluster.this", "StartLine": 7}},
+  {"AVDID": "AVD-AWS-0038", "Message": "Control plane scheduler logging is not enabled.", "Severity": "MEDIUM", "Status": "FAIL", "CauseMetadata": {"Resource": "aws_eks_cluster.this", "StartLine": 1}}
+]}]}
+JSON
+`), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	run := func(outputDir, failOn string) (string, error) {
+		params := &pipeline.ProcessingParams{
+			Description:    "Create a VPC with an EKS cluster",
+			OutputFormat:   "terraform",
+			OutputDir:      outputDir,
+			Region:         "us-east-1",
+			Scan:           true,
+			ScanFailOn:     failOn,
+			ProgressWriter: &bytes.Buffer{},
+		}
+		coordinator := pipeline.NewPipelineCoordinator()
+		if err := coordinator.InitializePipeline(context.Background(), params); err != nil {
+			return "", err
+		}
+		return coordinator.RunPipeline(context.Background(), params)
+	}
+
+	outputDir := filepath.Join(t.TempDir(), "infra")
+	result, err := run(outputDir, "")
+	require.NoError(t, err)
+	assert.Contains(t, result, "Security scan:")
+	assert.Contains(t, result, "CRITICAL  modules/eks/main.tf:7  AVD-AWS-0040")
+	assert.Contains(t, result, "Scanned with trivy: 1 critical, 0 high, 1 medium, 0 low findings")
+	assert.NotContains(t, result, ".iacgen-staging-", "The staging directory should not show in the result")
+
+	// Findings at or above the threshold fail the run, and nothing is written
+	outputDir = filepath.Join(t.TempDir(), "infra")
+	_, err = run(outputDir, "HIGH")
+	assert.ErrorContains(t, err, "the security scan found 1 findings of high severity or above")
+	entries, _ := os.ReadDir(outputDir)
+	assert.Empty(t, entries, "A failed scan should leave the output directory as it was")
+
+	_, err = run(filepath.Join(t.TempDir(), "infra"), "CRITICAL")
+	assert.ErrorContains(t, err, "AVD-AWS-0040")
+
+	_, err = run(t.TempDir(), "severe")
+	assert.ErrorContains(t, err, "invalid scan severity: severe")
+}
+
+func TestPipelineCheckov(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake checkov is a shell script")
+	}
+
+	// The fake checkov fails a Terraform and a Kubernetes check
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "checkov"), []byte(`#!/bin/sh
+cat <<'JSON'
+[{"check_type": "terraform", "results": {"failed_checks": [
+  {"check_id": "CKV_AWS_39", "check_name": "Ensure Amazon EKS public endpoint disabled", "file_path": "/terraform/modules/eks/main.tf", "file_line_range": [1, 20], "resource": "module.eks.aws_eks_cluster.this"}
+]}, "summary": {"passed": 10}},
+{"check_type": "kubernetes", "results": {"failed_checks": [
+  {"check_id": "CKV_K8S_21", "check_name": "The default namespace should not be used", "file_path": "/crossplane/vpc/vpc.yaml", "file_line_range": [1, 12], "resource": "VPC.default.main-vpc"}
+]}, "summary": {"passed": 2}}]
+JSON
+exit 1
+`), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	params := &pipeline.ProcessingParams{
+		Description:         "Create a VPC with an EKS cluster",
+		OutputFormat:        "terraform,crossplane",
+		OutputDir:           filepath.Join(t.TempDir(), "infra"),
+		Region:              "us-east-1",
+		Checkov:             true,
+		CheckovSuppressions: []report.CheckovSuppression{{Check: "CKV_K8S_21", Reason: "Managed resources are cluster-scoped"}},
+		ProgressWriter:      &bytes.Buffer{},
+	}
+	coordinator := pipeline.NewPipelineCoordinator()
+	require.NoError(t, coordinator.InitializePipeline(context.Background(), params))
+	result, err := coordinator.RunPipeline(context.Background(), params)
+	require.NoError(t, err, "Failed checks should not fail the run")
+	assert.Contains(t, result, "Policy checks:")
+	assert.Contains(t, result, "CKV_AWS_39  terraform/modules/eks/main.tf:1  terraform  module.eks.aws_eks_cluster.this")
+	assert.Contains(t, result, "Managed resources are cluster-scoped")
+	assert.Contains(t, result, "Checked terraform and kubernetes with checkov: 12 passed, 1 failed, 1 suppressed")
+
+	params.CheckovSuppressions = []report.CheckovSuppression{{Check: "default namespace"}}
+	err = pipeline.NewPipelineCoordinator().InitializePipeline(context.Background(), params)
+	assert.ErrorContains(t, err, "invalid checkov check")
+}
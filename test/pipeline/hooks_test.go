@@ -0,0 +1,46 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/hooks"
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks are shell commands")
+	}
+
+	params := &pipeline.ProcessingParams{
+		Description:  "Create a VPC with 2 public subnets",
+		OutputFormat: "terraform",
+		Region:       "us-east-1",
+		Hooks: hooks.Hooks{
+			Pre: []hooks.Hook{{Command: `ls > pre.txt`, OnFailure: hooks.FailureFail}},
+			Post: []hooks.Hook{{
+				Command:   `echo "$IACGEN_OUTPUT_FORMAT $IACGEN_REGION" > post.txt`,
+				OnFailure: hooks.FailureFail,
+			}},
+		},
+		ProgressWriter: &bytes.Buffer{},
+	}
+
+	// The files are those the hooks leave in the output directory
+	files, err := pipeline.GenerateFiles(context.Background(), params)
+	require.NoError(t, err)
+	assert.Equal(t, "pre.txt\n", files["pre.txt"], "Pre-generation hooks should run before anything is generated")
+	assert.Equal(t, "terraform us-east-1\n", files["post.txt"])
+	assert.Contains(t, files, "main.tf")
+	assert.Contains(t, params.ProgressWriter.(*bytes.Buffer).String(), "Running post-generation hook: echo")
+
+	// A failing pre-generation hook stops generation
+	params.Hooks.Pre = []hooks.Hook{{Command: "echo 'terraform not found' >&2; exit 127", OnFailure: hooks.FailureFail}}
+	_, err = pipeline.GenerateFiles(context.Background(), params)
+	assert.EqualError(t, err, `pre-generation hook "echo 'terraform not found' >&2; exit 127" failed: exit status 127`)
+}
@@ -1,15 +1,18 @@
 package pipeline
 
 import (
+	"archive/tar"
 	"bytes"
 	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/riptano/iac_generator_cli/internal/report"
 	utilsInternal "github.com/riptano/iac_generator_cli/internal/utils"
 	"github.com/riptano/iac_generator_cli/test/fixtures"
 	"github.com/riptano/iac_generator_cli/test/utils"
@@ -288,6 +291,21 @@ func TestModelBuilderIntegration(t *testing.T) {
 	}
 }
 
+func TestModelBuilderTagsIntegration(t *testing.T) {
+	processor := pipeline.NewNLPProcessor()
+	builder := pipeline.NewModelBuilder("us-east-1").
+		WithEnvironment("prod").
+		WithTags(map[string]string{"Team": "platform", "CostCenter": "1234", "Environment": "dev"})
+
+	model, err := processor.ParseDescription(context.Background(), "Create a VPC with 2 public subnets, tag everything with Team=web")
+	require.NoError(t, err)
+	model, err = builder.BuildModel(context.Background(), model)
+	require.NoError(t, err)
+
+	// The description's tags override the configured ones, and the environment overrides both
+	assert.Equal(t, map[string]string{"Team": "web", "CostCenter": "1234", "Environment": "prod"}, model.Tags)
+}
+
 func TestPipelineStagesIntegration(t *testing.T) {
 	// Test pipeline stages integration
 	// Create a test environment
@@ -377,4 +395,282 @@ func TestErrorHandlingIntegration(t *testing.T) {
 	result, err = p.Execute(ctx, "valid_input")
 	assert.NoError(t, err, "Pipeline should not error with valid input")
 	assert.Equal(t, "NextStage Output", result, "Pipeline should return output from final stage")
-}
\ No newline at end of file
+}
+func TestSpecPipelineIntegration(t *testing.T) {
+	testEnv := utils.NewTestEnvironment(t)
+	defer testEnv.Cleanup()
+
+	specFile := testEnv.CreateFixtureFile("infra.yaml", "region: us-west-2\nsubnets:\n  public_count: 2\n  private_count: 2\n")
+	outputDir := filepath.Join(testEnv.OutputDir, "spec")
+
+	var outputBuffer bytes.Buffer
+	params := &pipeline.ProcessingParams{
+		SpecFile:       specFile,
+		OutputFormat:   "crossplane",
+		OutputDir:      outputDir,
+		OutputFile:     "resources.yaml",
+		Region:         "us-west-2",
+		ProgressWriter: &outputBuffer,
+	}
+
+	coordinator := pipeline.NewPipelineCoordinator()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	require.NoError(t, coordinator.InitializePipeline(ctx, params))
+	_, err := coordinator.RunPipeline(ctx, params)
+	require.NoError(t, err)
+
+	assert.Contains(t, utils.LoadFileContent(t, filepath.Join(outputDir, "resources.yaml")), "subnets: 4 resources")
+	assert.NotContains(t, outputBuffer.String(), "Assumptions", "A spec skips the NLP stage and its assumption report")
+
+	// A spec replaces the description
+	params.Description = "a vpc with 2 public subnets"
+	assert.Error(t, pipeline.NewPipelineCoordinator().InitializePipeline(ctx, params))
+}
+
+func TestRegionPipelineIntegration(t *testing.T) {
+	testEnv := utils.NewTestEnvironment(t)
+	defer testEnv.Cleanup()
+
+	run := func(name, description, region string) string {
+		outputDir := filepath.Join(testEnv.OutputDir, name)
+		_, err := pipeline.ProcessPipeline(&pipeline.ProcessingParams{
+			Description:    description,
+			OutputFormat:   "terraform",
+			OutputDir:      outputDir,
+			Region:         region,
+			ProgressWriter: &bytes.Buffer{},
+		})
+		require.NoError(t, err)
+		mainTf := utils.LoadFileContent(t, filepath.Join(outputDir, "main.tf"))
+		assert.Equal(t, 1, strings.Count(mainTf, `module "vpc`), "The region should not be taken for a second one")
+		return utils.LoadFileContent(t, filepath.Join(outputDir, "terraform.tfvars"))
+	}
+
+	// The region a description names wins over the default of --region
+	tfvars := run("stated", "Create a VPC with 2 public and 2 private subnets in us-west-2", "us-east-1")
+	assert.Contains(t, tfvars, `aws_region = "us-west-2"`)
+	assert.Contains(t, tfvars, `["us-west-2a", "us-west-2b"]`)
+
+	// --region applies to a description that names none
+	tfvars = run("flag", "Create a VPC with 2 public and 2 private subnets", "eu-west-1")
+	assert.Contains(t, tfvars, `aws_region = "eu-west-1"`)
+	assert.Contains(t, tfvars, `["eu-west-1a", "eu-west-1b"]`)
+}
+
+func TestEnvironmentFlagPipelineIntegration(t *testing.T) {
+	testEnv := utils.NewTestEnvironment(t)
+	defer testEnv.Cleanup()
+
+	outputDir := filepath.Join(testEnv.OutputDir, "environment")
+
+	params := &pipeline.ProcessingParams{
+		Description:  "create dev and prod environments with a VPC with 3 public and 3 private subnets, prod with 3 NAT gateways and dev with 1",
+		OutputFormat: "terraform",
+		OutputDir:    outputDir,
+		Region:       "us-east-1",
+		Environment:  "prod",
+		DRRegion:     "us-west-2",
+	}
+
+	coordinator := pipeline.NewPipelineCoordinator()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	require.NoError(t, coordinator.InitializePipeline(ctx, params))
+	_, err := coordinator.RunPipeline(ctx, params)
+	require.NoError(t, err)
+
+	// Only the requested environment is generated, named and tagged after it
+	drVars := utils.LoadFileContent(t, filepath.Join(outputDir, "dr", "terraform.tfvars.prod"))
+	assert.Contains(t, drVars, `Environment = "prod"`)
+	assert.Contains(t, drVars, `vpc_name = "main-prod-dr"`)
+	assert.Contains(t, drVars, "single_nat_gateway = false")
+	assert.NoDirExists(t, filepath.Join(outputDir, "dev"))
+
+	params.Environment = "qa"
+	coordinator = pipeline.NewPipelineCoordinator()
+	require.NoError(t, coordinator.InitializePipeline(ctx, params))
+	_, err = coordinator.RunPipeline(ctx, params)
+	assert.ErrorContains(t, err, "not one of the environments")
+}
+
+func TestEnvironmentsPipelineIntegration(t *testing.T) {
+	testEnv := utils.NewTestEnvironment(t)
+	defer testEnv.Cleanup()
+
+	outputDir := filepath.Join(testEnv.OutputDir, "environments")
+
+	var outputBuffer bytes.Buffer
+	params := &pipeline.ProcessingParams{
+		Description:    "create dev and prod environments with a VPC with 3 public and 3 private subnets, prod with 3 NAT gateways and dev with 1",
+		OutputFormat:   "terraform",
+		OutputDir:      outputDir,
+		Region:         "us-east-1",
+		ProgressWriter: &outputBuffer,
+	}
+
+	coordinator := pipeline.NewPipelineCoordinator()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	require.NoError(t, coordinator.InitializePipeline(ctx, params))
+	result, err := coordinator.RunPipeline(ctx, params)
+	require.NoError(t, err)
+	assert.Contains(t, result, "environments dev, prod")
+
+	devVars := utils.LoadFileContent(t, filepath.Join(outputDir, "dev", "terraform.tfvars"))
+	assert.Contains(t, devVars, `Environment = "dev"`)
+	assert.Contains(t, devVars, "single_nat_gateway = true")
+
+	prodVars := utils.LoadFileContent(t, filepath.Join(outputDir, "prod", "terraform.tfvars"))
+	assert.Contains(t, prodVars, `Environment = "prod"`)
+	assert.Contains(t, prodVars, `vpc_name = "main-prod"`)
+	assert.Contains(t, prodVars, "single_nat_gateway = false")
+
+	assert.Contains(t, outputBuffer.String(), "Environment: prod")
+}
+
+func TestDiffPipelineIntegration(t *testing.T) {
+	testEnv := utils.NewTestEnvironment(t)
+	defer testEnv.Cleanup()
+
+	outputDir := filepath.Join(testEnv.OutputDir, "diff")
+	description := "create dev and prod environments with a VPC with 3 public and 3 private subnets, prod with 3 NAT gateways and dev with 1"
+
+	// Nothing is written when the output directory does not exist yet
+	diff, err := pipeline.DiffDescription(&pipeline.ProcessingParams{
+		Description:    description,
+		OutputFormat:   "terraform",
+		OutputDir:      outputDir,
+		Region:         "us-east-1",
+		ProgressWriter: &bytes.Buffer{},
+	})
+	require.NoError(t, err)
+	assert.NoDirExists(t, outputDir)
+	added, modified, _ := diff.Counts()
+	assert.Equal(t, len(diff.Files), added)
+	assert.Zero(t, modified)
+
+	params := &pipeline.ProcessingParams{
+		Description:    description,
+		OutputFormat:   "terraform",
+		OutputDir:      outputDir,
+		Region:         "us-east-1",
+		ProgressWriter: &bytes.Buffer{},
+	}
+	_, err = pipeline.ProcessPipeline(params)
+	require.NoError(t, err)
+	before := utils.LoadFileContent(t, filepath.Join(outputDir, "prod", "terraform.tfvars"))
+
+	diff, err = pipeline.DiffDescription(params)
+	require.NoError(t, err)
+	assert.False(t, diff.HasChanges(), "The description that generated the directory should not change it")
+
+	// A changed description shows the change, and leaves the directory as it was
+	params.Description = "create dev and prod environments with a VPC with 3 public and 3 private subnets, prod with 1 NAT gateway and dev with 1"
+	diff, err = pipeline.DiffDescription(params)
+	require.NoError(t, err)
+	require.True(t, diff.HasChanges())
+	var paths []string
+	for _, file := range diff.Files {
+		paths = append(paths, file.Path)
+	}
+	assert.Contains(t, paths, "prod/terraform.tfvars")
+	assert.NotContains(t, paths, "dev/terraform.tfvars")
+	assert.Contains(t, diff.Text(false), "-single_nat_gateway = false\n+single_nat_gateway = true\n")
+	assert.Equal(t, before, utils.LoadFileContent(t, filepath.Join(outputDir, "prod", "terraform.tfvars")))
+}
+
+func TestDiffPipelineKustomizations(t *testing.T) {
+	testEnv := utils.NewTestEnvironment(t)
+	defer testEnv.Cleanup()
+
+	params := &pipeline.ProcessingParams{
+		Description:    "Create a VPC with an EC2 instance of type t3.micro",
+		OutputFormat:   "crossplane",
+		OutputDir:      filepath.Join(testEnv.OutputDir, "dry-run"),
+		Region:         "us-east-1",
+		ProgressWriter: &bytes.Buffer{},
+	}
+	files, err := pipeline.GenerateFiles(context.Background(), params)
+	require.NoError(t, err)
+	for path, content := range files {
+		path = filepath.Join(params.OutputDir, filepath.FromSlash(path))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	// Generating adds its resources to an existing kustomization, and keeps the README
+	kustomization := filepath.Join(params.OutputDir, "ec2", "kustomization.yaml")
+	existing := "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\n\nresources:\n- monitoring.yaml\n"
+	require.NoError(t, os.WriteFile(kustomization, []byte(existing), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(params.OutputDir, "README.md"), []byte("Owned by the platform team\n"), 0644))
+
+	diff, err := pipeline.DiffDescription(params)
+	require.NoError(t, err)
+	changes := make(map[string]report.FileChange)
+	for _, file := range diff.Files {
+		changes[file.Path] = file.Change
+		if file.Path == "ec2/kustomization.yaml" {
+			assert.Equal(t, "@@ -3,3 +3,4 @@\n \n resources:\n - monitoring.yaml\n+- compute.yaml\n", file.Hunks)
+		}
+	}
+	assert.Equal(t, report.FileModified, changes["ec2/kustomization.yaml"])
+	assert.Equal(t, report.FileNotGenerated, changes["README.md"])
+	assert.Contains(t, diff.Unchanged, "ec2/compute.yaml")
+	assert.Equal(t, existing, utils.LoadFileContent(t, kustomization))
+}
+
+func TestExplainPipelineIntegration(t *testing.T) {
+	params := &pipeline.ProcessingParams{
+		Description:  "create dev and prod environments with a VPC with 2 public and 2 private subnets and an EKS cluster with 3 nodes",
+		OutputFormat: "terraform",
+		OutputDir:    ".",
+		Region:       "us-east-1",
+	}
+
+	_, err := pipeline.ExplainDescription(params)
+	assert.ErrorContains(t, err, "covers several environments (dev, prod)")
+
+	params.Environment = "prod"
+	explanation, err := pipeline.ExplainDescription(params)
+	require.NoError(t, err)
+	assert.Equal(t, "prod", explanation.Environment)
+	assert.NotEmpty(t, explanation.Resources)
+	assert.NotEmpty(t, explanation.Assumptions, "A parsed description should report its assumed defaults")
+	assert.Contains(t, explanation.Text(), "eks_cluster main-eks-cluster-prod")
+}
+
+func TestGenerateFilesIntegration(t *testing.T) {
+	files, err := pipeline.GenerateFiles(context.Background(), &pipeline.ProcessingParams{
+		Description:  "Create a VPC with an EKS cluster",
+		OutputFormat: "crossplane",
+		Region:       "us-east-1",
+	})
+	require.NoError(t, err)
+	assert.Contains(t, files, "eks/cluster.yaml")
+	assert.Contains(t, files, "kustomization.yaml")
+
+	// The document is a YAML stream of the manifests, without kustomizations or the README
+	document := pipeline.ConcatenateFiles(files)
+	assert.True(t, strings.HasPrefix(document, "---\n# Source: base/"))
+	assert.Contains(t, document, "---\n# Source: eks/cluster.yaml\n"+files["eks/cluster.yaml"])
+	assert.NotContains(t, document, "kustomization.yaml")
+	assert.NotContains(t, document, "README.md")
+
+	terraformDocument := pipeline.ConcatenateFiles(map[string]string{
+		"main.tf":                  "module \"vpc\" {}",
+		"terraform.tfvars.staging": "environment = \"staging\"\n",
+		"compliance-report.md":     "# Compliance\n",
+	})
+	assert.Equal(t, "# Source: main.tf\nmodule \"vpc\" {}\n\n# Source: terraform.tfvars.staging\nenvironment = \"staging\"\n", terraformDocument)
+
+	var archive bytes.Buffer
+	require.NoError(t, pipeline.WriteArchive(&archive, files, false))
+	reader := tar.NewReader(&archive)
+	header, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "README.md", header.Name, "Files should be archived in path order")
+}
@@ -0,0 +1,115 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineMetrics(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "infra")
+	metrics := pipeline.NewMetrics()
+	params := &pipeline.ProcessingParams{
+		Description:    "create dev and prod environments with a VPC with 3 public and 3 private subnets",
+		OutputFormat:   "terraform",
+		OutputDir:      outputDir,
+		Region:         "us-east-1",
+		ProgressWriter: &bytes.Buffer{},
+		Metrics:        metrics,
+	}
+
+	coordinator := pipeline.NewPipelineCoordinator()
+	require.NoError(t, coordinator.InitializePipeline(context.Background(), params))
+	_, err := coordinator.RunPipeline(context.Background(), params)
+	require.NoError(t, err)
+
+	// Each environment's stages are measured, and the files are counted for the stage that
+	// wrote them
+	stages := metrics.Stages()
+	require.Len(t, stages, 6)
+	written := map[string]int{}
+	for _, stage := range stages {
+		assert.Positive(t, stage.Duration, stage.Stage)
+		assert.False(t, stage.Failed)
+		written[stage.Environment+"/"+stage.Stage] = stage.Files
+	}
+	assert.Equal(t, 0, written["dev/NLPProcessing"])
+	assert.Positive(t, written["dev/EnvironmentGeneration"])
+	assert.Positive(t, written["prod/EnvironmentGeneration"])
+
+	var files int
+	var size int64
+	require.NoError(t, filepath.WalkDir(outputDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		files++
+		size += info.Size()
+		return err
+	}))
+	totalFiles, totalBytes := metrics.Files()
+	assert.Equal(t, files, totalFiles)
+	assert.Equal(t, size, totalBytes)
+	assert.Equal(t, files, written["dev/EnvironmentGeneration"]+written["prod/EnvironmentGeneration"])
+
+	text := metrics.Text()
+	assert.Contains(t, text, "STAGE")
+	assert.Contains(t, text, "ENVIRONMENT")
+	assert.Regexp(t, `EnvironmentGeneration\s+prod\s+\S+\s+\d+\s+[\d.]+ KiB`, text)
+
+	var report struct {
+		DurationMS float64 `json:"duration_ms"`
+		Files      int     `json:"files"`
+		Stages     []struct {
+			Stage      string  `json:"stage"`
+			DurationMS float64 `json:"duration_ms"`
+		} `json:"stages"`
+	}
+	content, err := metrics.JSON()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(content), &report))
+	assert.Equal(t, files, report.Files)
+	assert.Positive(t, report.DurationMS)
+	require.Len(t, report.Stages, 6)
+	assert.Equal(t, "NLPProcessing", report.Stages[0].Stage)
+}
+
+func TestPipelineMetricsFailedStage(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the plugins are shell scripts")
+	}
+
+	dir := t.TempDir()
+	writePluginScript(t, dir, "policy", `{"protocol_version":1,"name":"encryption","kind":"stage"}`,
+		`echo '{"findings":[{"severity":"error","message":"bucket is not encrypted","resource":"logs"}]}'`)
+	plugins, err := pipeline.LoadPlugins(context.Background(), dir)
+	require.NoError(t, err)
+
+	// The stages are measured up to the one that failed, when files are generated for stdout too
+	metrics := pipeline.NewMetrics()
+	_, err = pipeline.GenerateFiles(context.Background(), &pipeline.ProcessingParams{
+		Description:    "Create a VPC with 2 public subnets",
+		OutputFormat:   "terraform",
+		Region:         "us-east-1",
+		Plugins:        plugins,
+		ProgressWriter: &bytes.Buffer{},
+		Metrics:        metrics,
+	})
+	require.Error(t, err)
+
+	stages := metrics.Stages()
+	require.Len(t, stages, 3)
+	assert.Equal(t, "Plugin:encryption", stages[2].Stage)
+	assert.True(t, stages[2].Failed)
+	assert.False(t, stages[0].Failed)
+	assert.Contains(t, metrics.Text(), "Plugin:encryption (failed)")
+}
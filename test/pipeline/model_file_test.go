@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/riptano/iac_generator_cli/test/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveAndLoadModel(t *testing.T) {
+	testEnv := utils.NewTestEnvironment(t)
+	defer testEnv.Cleanup()
+
+	modelFile := filepath.Join(testEnv.BaseDir, "models", "model.json")
+	saved := &pipeline.ProcessingParams{
+		Description:    "Create a VPC with 2 public subnets and an EKS cluster with 3 nodes",
+		OutputFormat:   "terraform",
+		Region:         "us-east-1",
+		Environment:    "staging",
+		SaveModel:      modelFile,
+		ProgressWriter: &bytes.Buffer{},
+	}
+	fresh, err := pipeline.GenerateFiles(context.Background(), saved)
+	require.NoError(t, err)
+
+	model, err := pipeline.LoadModelFile(modelFile)
+	require.NoError(t, err)
+	assert.Equal(t, "staging", model.Environment)
+	assert.Equal(t, "main-vpc-staging", model.Resources[0].Name)
+
+	// The saved model is generated as it is, without parsing the description again
+	loaded := &pipeline.ProcessingParams{
+		ModelFile:      modelFile,
+		OutputFormat:   "terraform",
+		Region:         "us-east-1",
+		ProgressWriter: &bytes.Buffer{},
+	}
+	regenerated, err := pipeline.GenerateFiles(context.Background(), loaded)
+	require.NoError(t, err)
+	assert.NotContains(t, loaded.ProgressWriter.(*bytes.Buffer).String(), "Assumptions", "The saved model should not be parsed again")
+	assert.Equal(t, fresh, regenerated)
+
+	// A saved model can be generated into another format
+	loaded.OutputFormat = "crossplane"
+	files, err := pipeline.GenerateFiles(context.Background(), loaded)
+	require.NoError(t, err)
+	assert.Contains(t, files, "vpc/vpc.yaml")
+
+	// A description covering several environments has one model per environment
+	several := *saved
+	several.Description = "create dev and prod environments with a VPC with 3 public and 3 private subnets"
+	several.Environment = ""
+	_, err = pipeline.GenerateFiles(context.Background(), &several)
+	assert.ErrorContains(t, err, "save one with --environment")
+}
+
+func TestLoadModelFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"unknown field":      `{"resources": [{"type": "vpc", "name": "main-vpc"}], "regions": "us-east-1"}`,
+		"no resources":       `{"region": "us-east-1", "resources": []}`,
+		"unknown type":       `{"resources": [{"type": "bucket", "name": "logs"}]}`,
+		"unknown dependency": `{"resources": [{"type": "subnet", "name": "subnet-1", "depends_on": ["main-vpc"]}]}`,
+	} {
+		path := filepath.Join(dir, "model.json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		_, err := pipeline.LoadModelFile(path)
+		assert.ErrorContains(t, err, "invalid model file", name)
+	}
+
+	_, err := pipeline.LoadModelFile(filepath.Join(dir, "missing.json"))
+	assert.ErrorContains(t, err, "failed to read model file")
+}
+
+func TestMergeInputFiles(t *testing.T) {
+	dir := t.TempDir()
+	network := filepath.Join(dir, "network.txt")
+	eks := filepath.Join(dir, "eks.txt")
+	require.NoError(t, os.WriteFile(network, []byte("Create a VPC with CIDR 10.0.0.0/16 in us-west-2 with 2 public and 2 private subnets and a NAT gateway\n"), 0644))
+	require.NoError(t, os.WriteFile(eks, []byte("An EKS cluster version 1.29 with 3 nodes\n"), 0644))
+
+	modelFile := filepath.Join(dir, "model.json")
+	params := &pipeline.ProcessingParams{
+		InputFiles:     []string{network, eks},
+		OutputFormat:   "terraform",
+		Region:         "us-west-2",
+		SaveModel:      modelFile,
+		ProgressWriter: &bytes.Buffer{},
+	}
+	files, err := pipeline.GenerateFiles(context.Background(), params)
+	require.NoError(t, err)
+	assert.Contains(t, files, "main.tf")
+
+	// The VPC both descriptions imply is merged into one, in the region network.txt states
+	model, err := pipeline.LoadModelFile(modelFile)
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2", model.Region)
+	var types []string
+	for _, resource := range model.Resources {
+		types = append(types, string(resource.Type))
+	}
+	assert.Equal(t, 1, countOf(types, "vpc"))
+	assert.Equal(t, 1, countOf(types, "nat_gateway"))
+	assert.Equal(t, 1, countOf(types, "eks_cluster"))
+
+	// A VPC described with another CIDR block in each file is a conflict
+	require.NoError(t, os.WriteFile(eks, []byte("An EKS cluster with 3 nodes in a VPC with CIDR 10.1.0.0/16\n"), 0644))
+	params.SaveModel = ""
+	_, err = pipeline.GenerateFiles(context.Background(), params)
+	assert.ErrorContains(t, err, "vpc main-vpc: "+network+" and "+eks+" describe it differently: cidr_block 10.0.0.0/16 and 10.1.0.0/16")
+}
+
+// countOf returns how many times a value is in a list
+func countOf(values []string, value string) int {
+	count := 0
+	for _, v := range values {
+		if v == value {
+			count++
+		}
+	}
+	return count
+}
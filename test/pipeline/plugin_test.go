@@ -0,0 +1,145 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/riptano/iac_generator_cli/internal/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePluginScript writes a shell script plugin answering describe with manifest and the
+// other commands with body
+func writePluginScript(t *testing.T, dir, name, manifest, body string) {
+	t.Helper()
+	script := "#!/bin/sh\nif [ \"$1\" = describe ]; then\n  echo '" + manifest + "'\n  exit 0\nfi\ncat > /dev/null\n" + body
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(script), 0755))
+}
+
+func TestPipelinePlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the plugins are shell scripts")
+	}
+
+	dir := t.TempDir()
+	writePluginScript(t, dir, "pulumi", `{"protocol_version":1,"name":"pulumi","kind":"generator","format":"pulumi"}`,
+		`printf '%s\n' '{"files":{"Pulumi.yaml":"name: infra\nruntime: yaml\n"}}'`)
+	// The policy check replaces the model with one tagged with a cost center
+	writePluginScript(t, dir, "policy", `{"protocol_version":1,"name":"cost-center","kind":"stage"}`,
+		`echo '{"findings":[{"severity":"warning","message":"no CostCenter tag; using 42","resource":"main-vpc"}],"model":{"resources":[{"type":"vpc","name":"main-vpc","properties":[{"name":"cidr_block","value":"10.0.0.0/16"}]}],"region":"us-east-1","tags":{"CostCenter":"42"}}}'`)
+
+	plugins, err := pipeline.LoadPlugins(context.Background(), dir)
+	require.NoError(t, err)
+	params := func(format string) *pipeline.ProcessingParams {
+		return &pipeline.ProcessingParams{
+			Description:    "Create a VPC with 2 public subnets",
+			OutputFormat:   format,
+			Region:         "us-east-1",
+			Plugins:        plugins,
+			ProgressWriter: &bytes.Buffer{},
+		}
+	}
+
+	// A generator plugin provides a format of its own
+	pulumi := params("pulumi")
+	files, err := pipeline.GenerateFiles(context.Background(), pulumi)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Pulumi.yaml": "name: infra\nruntime: yaml\n"}, files)
+	assert.Contains(t, pulumi.ProgressWriter.(*bytes.Buffer).String(), "cost-center [warning] main-vpc: no CostCenter tag; using 42")
+
+	// Stage plugins run before the built-in generators too
+	files, err = pipeline.GenerateFiles(context.Background(), params("terraform"))
+	require.NoError(t, err)
+	var tagged bool
+	for path, content := range files {
+		if strings.HasSuffix(path, ".tf") && strings.Contains(content, "CostCenter") {
+			tagged = true
+		}
+	}
+	assert.True(t, tagged, "The model of the stage plugin should be generated")
+
+	// A DR variant needs a runbook for a built-in format
+	drParams := params("pulumi")
+	drParams.DRRegion = "eu-west-1"
+	_, err = pipeline.GenerateFiles(context.Background(), drParams)
+	assert.EqualError(t, err, "a DR region cannot be used with the pulumi format of plugin pulumi")
+}
+
+func TestPipelinePluginStageFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the plugins are shell scripts")
+	}
+
+	dir := t.TempDir()
+	writePluginScript(t, dir, "policy", `{"protocol_version":1,"name":"encryption","kind":"stage"}`,
+		`echo '{"findings":[{"severity":"error","message":"bucket is not encrypted","resource":"logs"},{"severity":"info","message":"2 resources checked"}]}'`)
+	plugins, err := pipeline.LoadPlugins(context.Background(), dir)
+	require.NoError(t, err)
+
+	_, err = pipeline.GenerateFiles(context.Background(), &pipeline.ProcessingParams{
+		Description:    "Create a VPC with 2 public subnets",
+		OutputFormat:   "terraform",
+		Region:         "us-east-1",
+		Plugins:        plugins,
+		ProgressWriter: &bytes.Buffer{},
+	})
+	assert.ErrorContains(t, err, "plugin encryption reported 1 error(s): logs: bucket is not encrypted")
+
+	// Built-in formats cannot be replaced
+	writePluginScript(t, dir, "terraform", `{"protocol_version":1,"name":"tf","kind":"generator","format":"terraform"}`, "")
+	_, err = pipeline.LoadPlugins(context.Background(), dir)
+	assert.EqualError(t, err, "plugin tf cannot replace the built-in terraform format")
+}
+
+func TestPipelinePluginRetry(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the plugins are shell scripts")
+	}
+
+	// The policy check fails transiently twice, as if its policy server were unavailable
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "attempts")
+	writePluginScript(t, dir, "policy", `{"protocol_version":1,"name":"remote-policy","kind":"stage"}`,
+		`echo x >> "`+counter+`"
+if [ "$(wc -l < "`+counter+`")" -lt 3 ]; then
+  echo 'policy server unavailable' >&2
+  exit 75
+fi
+echo '{}'
+`)
+	plugins, err := pipeline.LoadPlugins(context.Background(), dir)
+	require.NoError(t, err)
+
+	sink := &recordingSink{}
+	params := &pipeline.ProcessingParams{
+		Description:    "Create a VPC with 2 public subnets",
+		OutputFormat:   "terraform",
+		Region:         "us-east-1",
+		Plugins:        plugins,
+		Retry:          retry.Policy{MaxAttempts: 3, InitialDelay: time.Millisecond},
+		ProgressWriter: &bytes.Buffer{},
+		Events:         sink,
+	}
+	_, err = pipeline.GenerateFiles(context.Background(), params)
+	require.NoError(t, err)
+
+	retries := sink.ofType(pipeline.EventStageRetrying)
+	require.Len(t, retries, 2)
+	assert.Equal(t, "Plugin:remote-policy", retries[0].Stage)
+	assert.Contains(t, retries[0].Message, "attempt 1 of 3 failed; retrying in")
+	assert.Equal(t, "plugin remote-policy: stage failed: exit status 75: policy server unavailable", retries[0].Error)
+
+	// Without retries, or with failures that are not transient, the stage fails at once
+	require.NoError(t, os.Remove(counter))
+	params.Retry = retry.Policy{}
+	_, err = pipeline.GenerateFiles(context.Background(), params)
+	assert.ErrorContains(t, err, "exit status 75: policy server unavailable")
+}
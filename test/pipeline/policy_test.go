@@ -0,0 +1,64 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicies(t *testing.T) {
+	policies := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(policies, "eks.rego"), []byte(`package iacgen.eks
+
+import rego.v1
+
+deny contains {"msg": "EKS endpoints must not be public in prod", "resource": cluster.name} if {
+	input.environment == "prod"
+	some cluster in input.resources
+	cluster.type == "eks_cluster"
+	cluster.properties.vpc_config.endpoint_public_access
+}
+
+warn contains "the model has no Owner tag" if not input.tags.Owner
+`), 0644))
+
+	params := func(environment string) *pipeline.ProcessingParams {
+		return &pipeline.ProcessingParams{
+			Description:    "Create a VPC with 2 public and 2 private subnets and an EKS cluster",
+			OutputFormat:   "terraform",
+			OutputDir:      filepath.Join(t.TempDir(), "infra"),
+			Region:         "us-east-1",
+			Environment:    environment,
+			Policies:       []string{policies},
+			ProgressWriter: &bytes.Buffer{},
+		}
+	}
+
+	// A denial fails the run before any file is written
+	prod := params("prod")
+	coordinator := pipeline.NewPipelineCoordinator()
+	require.NoError(t, coordinator.InitializePipeline(context.Background(), prod))
+	_, err := coordinator.RunPipeline(context.Background(), prod)
+	assert.ErrorContains(t, err, "the model violates 1 policies:\n  - [iacgen.eks] main-eks-cluster-prod: EKS endpoints must not be public in prod")
+	assert.NoFileExists(t, filepath.Join(prod.OutputDir, "main.tf"))
+
+	// Warnings are reported without failing it
+	dev := params("dev")
+	coordinator = pipeline.NewPipelineCoordinator()
+	require.NoError(t, coordinator.InitializePipeline(context.Background(), dev))
+	_, err = coordinator.RunPipeline(context.Background(), dev)
+	require.NoError(t, err)
+	assert.Contains(t, dev.ProgressWriter.(*bytes.Buffer).String(), "Warning: policy [iacgen.eks] the model has no Owner tag")
+	assert.FileExists(t, filepath.Join(dev.OutputDir, "main.tf"))
+
+	// A policy that does not compile is rejected up front
+	require.NoError(t, os.WriteFile(filepath.Join(policies, "broken.rego"), []byte("package iacgen.broken\n\ndeny[msg] {\n"), 0644))
+	err = pipeline.NewPipelineCoordinator().InitializePipeline(context.Background(), params("dev"))
+	assert.ErrorContains(t, err, "failed to load policies")
+}
@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	sqtypes "github.com/aws/aws-sdk-go-v2/service/servicequotas/types"
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/riptano/iac_generator_cli/internal/quota"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fullAccount is an account with every quota at 1 and one VPC, and nothing else, in use
+type fullAccount struct{}
+
+func (fullAccount) GetServiceQuota(ctx context.Context, params *servicequotas.GetServiceQuotaInput, optFns ...func(*servicequotas.Options)) (*servicequotas.GetServiceQuotaOutput, error) {
+	return &servicequotas.GetServiceQuotaOutput{Quota: &sqtypes.ServiceQuota{Value: aws.Float64(1)}}, nil
+}
+
+func (fullAccount) GetAWSDefaultServiceQuota(ctx context.Context, params *servicequotas.GetAWSDefaultServiceQuotaInput, optFns ...func(*servicequotas.Options)) (*servicequotas.GetAWSDefaultServiceQuotaOutput, error) {
+	return &servicequotas.GetAWSDefaultServiceQuotaOutput{Quota: &sqtypes.ServiceQuota{Value: aws.Float64(1)}}, nil
+}
+
+func (fullAccount) DescribeVpcs(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error) {
+	return &ec2.DescribeVpcsOutput{Vpcs: make([]ec2types.Vpc, 1)}, nil
+}
+
+func (fullAccount) DescribeInternetGateways(ctx context.Context, params *ec2.DescribeInternetGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInternetGatewaysOutput, error) {
+	return &ec2.DescribeInternetGatewaysOutput{}, nil
+}
+
+func (fullAccount) DescribeAddresses(ctx context.Context, params *ec2.DescribeAddressesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeAddressesOutput, error) {
+	return &ec2.DescribeAddressesOutput{}, nil
+}
+
+func (fullAccount) DescribeNatGateways(ctx context.Context, params *ec2.DescribeNatGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNatGatewaysOutput, error) {
+	return &ec2.DescribeNatGatewaysOutput{}, nil
+}
+
+func (fullAccount) DescribeSubnets(ctx context.Context, params *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error) {
+	return &ec2.DescribeSubnetsOutput{}, nil
+}
+
+func TestQuotaChecks(t *testing.T) {
+	params := func(checker func(ctx context.Context, region string) (*quota.Checker, error)) *pipeline.ProcessingParams {
+		return &pipeline.ProcessingParams{
+			Description:    "Create a VPC with 2 public and 2 private subnets and 2 NAT gateways",
+			OutputFormat:   "terraform",
+			OutputDir:      filepath.Join(t.TempDir(), "infra"),
+			Region:         "us-east-1",
+			CheckQuotas:    true,
+			QuotaChecker:   checker,
+			ProgressWriter: &bytes.Buffer{},
+		}
+	}
+	run := func(params *pipeline.ProcessingParams) string {
+		coordinator := pipeline.NewPipelineCoordinator()
+		require.NoError(t, coordinator.InitializePipeline(context.Background(), params))
+		_, err := coordinator.RunPipeline(context.Background(), params)
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(params.OutputDir, "main.tf"))
+		return params.ProgressWriter.(*bytes.Buffer).String()
+	}
+
+	// The quotas the model would exceed are warnings, and generation goes on
+	var regions []string
+	output := run(params(func(ctx context.Context, region string) (*quota.Checker, error) {
+		regions = append(regions, region)
+		return quota.NewChecker(fullAccount{}, fullAccount{}, region), nil
+	}))
+	assert.Equal(t, []string{"us-east-1"}, regions)
+	assert.Contains(t, output, "Warning: quota VPCs per Region in us-east-1: 1 in use and 1 requested exceed the quota of 1")
+	assert.Contains(t, output, "Warning: quota EC2-VPC Elastic IPs in us-east-1: 0 in use and 2 requested exceed the quota of 1")
+
+	// A check that cannot be made is a warning too
+	output = run(params(func(ctx context.Context, region string) (*quota.Checker, error) {
+		return nil, errors.New("failed to load AWS configuration: no credentials")
+	}))
+	assert.Contains(t, output, "Warning: the service quotas of us-east-1 were not checked: failed to load AWS configuration: no credentials")
+}
@@ -0,0 +1,193 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/riptano/iac_generator_cli/test/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerRunsDependenciesFirst(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			return nil
+		}
+	}
+
+	scheduler := pipeline.NewScheduler(4)
+	require.NoError(t, scheduler.Add("generate", record("generate"), "model"))
+	require.NoError(t, scheduler.Add("report", record("report"), "model"))
+	require.NoError(t, scheduler.Add("model", record("model"), "parse"))
+	require.NoError(t, scheduler.Add("parse", record("parse")))
+	require.NoError(t, scheduler.Run(context.Background()))
+
+	require.Len(t, order, 4)
+	assert.Equal(t, []string{"parse", "model"}, order[:2])
+	assert.ElementsMatch(t, []string{"generate", "report"}, order[2:])
+}
+
+func TestSchedulerLimitsWorkers(t *testing.T) {
+	var running, peak int32
+	scheduler := pipeline.NewScheduler(2)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, scheduler.Add(name, func(ctx context.Context) error {
+			now := atomic.AddInt32(&running, 1)
+			for {
+				seen := atomic.LoadInt32(&peak)
+				if now <= seen || atomic.CompareAndSwapInt32(&peak, seen, now) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		}))
+	}
+	require.NoError(t, scheduler.Run(context.Background()))
+	assert.Equal(t, int32(2), peak, "Independent tasks should run concurrently, up to the worker count")
+}
+
+func TestSchedulerStopsAtFirstFailure(t *testing.T) {
+	failure := errors.New("render failed")
+	var dependentRan, canceled atomic.Bool
+
+	scheduler := pipeline.NewScheduler(2)
+	require.NoError(t, scheduler.Add("render", func(ctx context.Context) error { return failure }))
+	require.NoError(t, scheduler.Add("write", func(ctx context.Context) error {
+		dependentRan.Store(true)
+		return nil
+	}, "render"))
+	require.NoError(t, scheduler.Add("slow", func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			canceled.Store(true)
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	}))
+
+	err := scheduler.Run(context.Background())
+	assert.ErrorIs(t, err, failure)
+	assert.False(t, dependentRan.Load(), "Tasks depending on a failed task should not run")
+	assert.True(t, canceled.Load(), "Running tasks should be canceled")
+}
+
+func TestSchedulerRejectsInvalidGraphs(t *testing.T) {
+	noop := func(ctx context.Context) error { return nil }
+
+	scheduler := pipeline.NewScheduler(1)
+	require.NoError(t, scheduler.Add("a", noop))
+	assert.EqualError(t, scheduler.Add("a", noop), "task a is already scheduled")
+
+	scheduler = pipeline.NewScheduler(1)
+	require.NoError(t, scheduler.Add("a", noop, "missing"))
+	assert.EqualError(t, scheduler.Run(context.Background()), "task a depends on unknown task missing")
+
+	scheduler = pipeline.NewScheduler(1)
+	require.NoError(t, scheduler.Add("a", noop, "c"))
+	require.NoError(t, scheduler.Add("b", noop, "a"))
+	require.NoError(t, scheduler.Add("c", noop, "b"))
+	require.NoError(t, scheduler.Add("d", noop))
+	assert.EqualError(t, scheduler.Run(context.Background()), "tasks a, b, c depend on each other in a cycle")
+}
+
+func TestParallelStage(t *testing.T) {
+	var seen atomic.Int32
+	passThrough := pipeline.NewBaseStage("Report", func(ctx context.Context, input interface{}) (interface{}, error) {
+		seen.Add(1)
+		return input, nil
+	})
+	generate := pipeline.NewBaseStage("Generate", func(ctx context.Context, input interface{}) (interface{}, error) {
+		return strings.ToUpper(input.(string)), nil
+	})
+
+	result, err := pipeline.ParallelStage("Generate", 0, passThrough, generate).Execute(context.Background(), "vpc")
+	require.NoError(t, err)
+	assert.Equal(t, "VPC", result, "The result should be the last stage's")
+	assert.Equal(t, int32(1), seen.Load())
+
+	failing := pipeline.NewBaseStage("Report", func(ctx context.Context, input interface{}) (interface{}, error) {
+		return nil, errors.New("no model")
+	})
+	_, err = pipeline.ParallelStage("Generate", 0, failing, generate).Execute(context.Background(), "vpc")
+	assert.EqualError(t, err, "stage Report failed: no model")
+}
+
+func TestEnvironmentsPipelineConcurrency(t *testing.T) {
+	testEnv := utils.NewTestEnvironment(t)
+	defer testEnv.Cleanup()
+
+	description := "create dev, staging and prod environments with a VPC with 2 public and 2 private subnets and an EKS cluster, prod with 3 nodes"
+	run := func(workers int) (string, string) {
+		outputDir := filepath.Join(testEnv.OutputDir, "workers", string(rune('0'+workers)))
+		var progress bytes.Buffer
+		_, err := pipeline.ProcessPipeline(&pipeline.ProcessingParams{
+			Description:      description,
+			OutputFormat:     "terraform",
+			OutputDir:        outputDir,
+			Region:           "us-east-1",
+			ComplianceReport: true,
+			Workers:          workers,
+			ProgressWriter:   &progress,
+		})
+		require.NoError(t, err)
+		return outputDir, progress.String()
+	}
+
+	sequentialDir, sequential := run(1)
+	concurrentDir, concurrent := run(3)
+	assert.Equal(t, sequential, concurrent, "Progress should be written in order whatever the worker count")
+	assert.Less(t, strings.Index(concurrent, "Environment: dev"), strings.Index(concurrent, "Environment: staging"))
+	assert.Less(t, strings.Index(concurrent, "Environment: staging"), strings.Index(concurrent, "Environment: prod"))
+
+	for _, environment := range []string{"dev", "staging", "prod"} {
+		for _, file := range []string{"terraform.tfvars", "compliance-report.md", "modules/eks/main.tf"} {
+			assert.Equal(t,
+				utils.LoadFileContent(t, filepath.Join(sequentialDir, environment, file)),
+				utils.LoadFileContent(t, filepath.Join(concurrentDir, environment, file)),
+				"%s/%s should not depend on the worker count", environment, file)
+		}
+	}
+}
+
+func TestEnvironmentsPipelineParsesEachEnvironmentWithItsOwnBackend(t *testing.T) {
+	testEnv := utils.NewTestEnvironment(t)
+	defer testEnv.Cleanup()
+
+	// The environments share the synonyms, and generate both formats at once; run with -race
+	synonyms := nlp.DefaultSynonyms()
+	require.NoError(t, synonyms.RegisterResource("container platform", models.ResourceEKSCluster))
+	_, err := pipeline.ProcessPipeline(&pipeline.ProcessingParams{
+		Description:    "create dev, staging and prod environments with a VPC with 2 public and 2 private subnets and a container platform",
+		OutputFormat:   "terraform,crossplane",
+		OutputDir:      testEnv.OutputDir,
+		Region:         "us-east-1",
+		Workers:        3,
+		LLMConfig:      nlp.BackendConfig{Synonyms: synonyms},
+		ProgressWriter: &bytes.Buffer{},
+	})
+	require.NoError(t, err)
+
+	for _, environment := range []string{"dev", "staging", "prod"} {
+		assert.FileExists(t, filepath.Join(testEnv.OutputDir, environment, "terraform", "modules", "eks", "main.tf"))
+		assert.FileExists(t, filepath.Join(testEnv.OutputDir, environment, "crossplane", "eks", "cluster.yaml"))
+	}
+}
@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/riptano/iac_generator_cli/test/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineStagedOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the plugins are shell scripts")
+	}
+
+	// The policy check fails for prod only, once dev has been generated
+	pluginDir := t.TempDir()
+	script := "#!/bin/sh\nif [ \"$1\" = describe ]; then\n  echo '{\"protocol_version\":1,\"name\":\"prod-policy\",\"kind\":\"stage\"}'\n  exit 0\nfi\n" +
+		"if grep -q '\"environment\":\"prod\"'; then\n  echo '{\"findings\":[{\"severity\":\"error\",\"message\":\"prod is frozen\"}]}'\nelse\n  echo '{}'\nfi\n"
+	require.NoError(t, os.WriteFile(filepath.Join(pluginDir, "policy"), []byte(script), 0755))
+	plugins, err := pipeline.LoadPlugins(context.Background(), pluginDir)
+	require.NoError(t, err)
+
+	outputDir := filepath.Join(t.TempDir(), "infra")
+	require.NoError(t, os.MkdirAll(outputDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "notes.md"), []byte("mine\n"), 0644))
+	params := &pipeline.ProcessingParams{
+		Description:    "create dev and prod environments with a VPC with 3 public subnets",
+		OutputFormat:   "terraform",
+		OutputDir:      outputDir,
+		Region:         "us-east-1",
+		Workers:        1,
+		Plugins:        plugins,
+		ProgressWriter: &bytes.Buffer{},
+	}
+	run := func() error {
+		coordinator := pipeline.NewPipelineCoordinator()
+		require.NoError(t, coordinator.InitializePipeline(context.Background(), params))
+		_, err := coordinator.RunPipeline(context.Background(), params)
+		return err
+	}
+
+	// A failed run leaves the output directory as it was
+	require.ErrorContains(t, run(), "prod is frozen")
+	entries, err := os.ReadDir(outputDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "Only the file that was there should be left")
+	assert.Equal(t, "notes.md", entries[0].Name())
+
+	// With KeepPartial, the environments generated before the failure are left in place
+	params.KeepPartial = true
+	require.Error(t, run())
+	assert.FileExists(t, filepath.Join(outputDir, "dev", "main.tf"))
+	assert.NoDirExists(t, filepath.Join(outputDir, "prod"))
+
+	// A successful run moves the generated files in, keeping those the generators keep
+	require.NoError(t, os.RemoveAll(filepath.Join(outputDir, "dev")))
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, ".gitignore"), []byte("*.tfstate\n"), 0644))
+	params.KeepPartial = false
+	params.Plugins = nil
+	require.NoError(t, run())
+	assert.FileExists(t, filepath.Join(outputDir, "dev", "main.tf"))
+	assert.FileExists(t, filepath.Join(outputDir, "prod", "main.tf"))
+	assert.Equal(t, "mine\n", utils.LoadFileContent(t, filepath.Join(outputDir, "notes.md")))
+	assert.Equal(t, "*.tfstate\n", utils.LoadFileContent(t, filepath.Join(outputDir, ".gitignore")))
+	entries, err = os.ReadDir(outputDir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotContains(t, entry.Name(), "staging", "The staging directory should be removed")
+	}
+}
+
+func TestPipelineStagesWorkingDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the plugin is a shell script")
+	}
+
+	// The policy check fails for prod only, once dev has been generated
+	pluginDir := t.TempDir()
+	script := "#!/bin/sh\nif [ \"$1\" = describe ]; then\n  echo '{\"protocol_version\":1,\"name\":\"prod-policy\",\"kind\":\"stage\"}'\n  exit 0\nfi\n" +
+		"if grep -q '\"environment\":\"prod\"'; then\n  echo '{\"findings\":[{\"severity\":\"error\",\"message\":\"prod is frozen\"}]}'\nelse\n  echo '{}'\nfi\n"
+	require.NoError(t, os.WriteFile(filepath.Join(pluginDir, "policy"), []byte(script), 0755))
+	plugins, err := pipeline.LoadPlugins(context.Background(), pluginDir)
+	require.NoError(t, err)
+
+	// The default output directory, the working directory, is staged like any other
+	workDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "notes.md"), []byte("mine\n"), 0644))
+	previous, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(workDir))
+	defer os.Chdir(previous)
+
+	params := &pipeline.ProcessingParams{
+		Description:    "create dev and prod environments with a VPC with 3 public subnets",
+		OutputFormat:   "terraform",
+		OutputDir:      ".",
+		Region:         "us-east-1",
+		Workers:        1,
+		Plugins:        plugins,
+		ProgressWriter: &bytes.Buffer{},
+	}
+	coordinator := pipeline.NewPipelineCoordinator()
+	require.NoError(t, coordinator.InitializePipeline(context.Background(), params))
+	_, err = coordinator.RunPipeline(context.Background(), params)
+	require.ErrorContains(t, err, "prod is frozen")
+
+	entries, err := os.ReadDir(workDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "A failed run should leave the working directory as it was")
+	assert.Equal(t, "notes.md", entries[0].Name())
+}
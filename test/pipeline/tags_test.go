@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/pipeline"
+	"github.com/riptano/iac_generator_cli/test/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequiredTags(t *testing.T) {
+	testEnv := utils.NewTestEnvironment(t)
+	defer testEnv.Cleanup()
+
+	params := func(format string) *pipeline.ProcessingParams {
+		return &pipeline.ProcessingParams{
+			Description:    "Create a VPC with 2 public subnets, tag everything with Owner=web and CostCenter=1234",
+			OutputFormat:   format,
+			Region:         "us-east-1",
+			Tags:           map[string]string{"Team": "platform", "Owner": "infra"},
+			TagOverrides:   map[string]string{"CostCenter": "5678"},
+			RequiredTags:   []string{"CostCenter", "Owner", "Team"},
+			CacheDir:       filepath.Join(testEnv.BaseDir, "cache"),
+			ProgressWriter: &bytes.Buffer{},
+		}
+	}
+
+	// The overrides win over the description, which wins over the defaults
+	files, err := pipeline.GenerateFiles(context.Background(), params("terraform"))
+	require.NoError(t, err)
+	assert.Regexp(t, `CostCenter += "5678"`, files["variables.tf"])
+	assert.Regexp(t, `Owner += "web"`, files["variables.tf"])
+	assert.Regexp(t, `Team += "platform"`, files["variables.tf"])
+
+	files, err = pipeline.GenerateFiles(context.Background(), params("crossplane"))
+	require.NoError(t, err)
+	assert.Contains(t, files["vpc/vpc.yaml"], "value: \"5678\"")
+
+	// A model without a required tag is not generated, even when resumed from a checkpoint
+	for _, format := range []string{"terraform", "crossplane"} {
+		missing := params(format)
+		missing.RequiredTags = append(missing.RequiredTags, "DataClass")
+		_, err = pipeline.GenerateFiles(context.Background(), missing)
+		assert.ErrorContains(t, err, "missing required tags: DataClass", format)
+		assert.Contains(t, missing.ProgressWriter.(*bytes.Buffer).String(), "Resumed", "The model should come from the checkpoint")
+	}
+}
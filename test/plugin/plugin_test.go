@@ -0,0 +1,159 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/plugin"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePlugin writes a shell script plugin answering describe with manifest and the other
+// commands with body
+func writePlugin(t *testing.T, dir, name, manifest, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	script := "#!/bin/sh\nif [ \"$1\" = describe ]; then\n  echo '" + manifest + "'\n  exit 0\nfi\n" + body
+	require.NoError(t, os.WriteFile(path, []byte(script), 0755))
+	return path
+}
+
+func skipOnWindows(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the plugins are shell scripts")
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	skipOnWindows(t)
+	dir := t.TempDir()
+	writePlugin(t, dir, "b-policy", `{"protocol_version":1,"name":"tag-policy","kind":"stage","description":"Requires a Team tag"}`, "")
+	writePlugin(t, dir, "a-pulumi", `{"protocol_version":1,"name":"pulumi","kind":"generator","format":"Pulumi"}`, "")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a plugin"), 0644))
+	writePlugin(t, dir, ".hidden", `{}`, "")
+
+	plugins, err := plugin.Discover(context.Background(), dir)
+	require.NoError(t, err)
+	require.Len(t, plugins, 2)
+	assert.Equal(t, "pulumi", plugins[0].Name)
+	assert.Equal(t, "pulumi", plugins[0].Format, "Formats are lower-cased")
+	assert.Equal(t, filepath.Join(dir, "a-pulumi"), plugins[0].Path)
+	assert.Equal(t, "Requires a Team tag", plugins[1].Description)
+
+	assert.Equal(t, plugins[0], plugin.Generator(plugins, "PULUMI"))
+	assert.Nil(t, plugin.Generator(plugins, "terraform"))
+	assert.Equal(t, []*plugin.Plugin{plugins[1]}, plugin.Stages(plugins))
+	assert.Equal(t, []string{"pulumi"}, plugin.Formats(plugins))
+}
+
+func TestDiscoverRejectsInvalidPlugins(t *testing.T) {
+	skipOnWindows(t)
+	tests := []struct {
+		name     string
+		manifest string
+		body     string
+		want     string
+	}{
+		{"version", `{"protocol_version":2,"name":"future","kind":"stage"}`, "", "plugin p speaks protocol version 2; this version of iacgen speaks 1"},
+		{"kind", `{"protocol_version":1,"name":"odd","kind":"linter"}`, "", `plugin odd has an unknown kind "linter" (use generator or stage)`},
+		{"format", `{"protocol_version":1,"name":"gen","kind":"generator"}`, "", "generator plugin gen has no format"},
+		{"name", `{"protocol_version":1,"kind":"stage"}`, "", "plugin p has no name"},
+		{"json", `not json`, "", "plugin p: invalid describe response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writePlugin(t, dir, "p", tt.manifest, tt.body)
+			_, err := plugin.Discover(context.Background(), dir)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+
+	dir := t.TempDir()
+	writePlugin(t, dir, "one", `{"protocol_version":1,"name":"cdk","kind":"generator","format":"cdk"}`, "")
+	writePlugin(t, dir, "two", `{"protocol_version":1,"name":"cdk2","kind":"generator","format":"cdk"}`, "")
+	_, err := plugin.Discover(context.Background(), dir)
+	assert.EqualError(t, err, "plugins one and two both generate the cdk format")
+}
+
+func TestGenerate(t *testing.T) {
+	skipOnWindows(t)
+	dir := t.TempDir()
+	requestPath := filepath.Join(dir, "request.json")
+	path := writePlugin(t, dir, "pulumi", `{"protocol_version":1,"name":"pulumi","kind":"generator","format":"pulumi"}`,
+		`cat > "`+requestPath+`"
+printf '%s\n' '{"files":{"Pulumi.yaml":"name: infra\n","stacks/dev.yaml":"config: {}\n"}}'
+`)
+	p, err := plugin.Describe(context.Background(), path)
+	require.NoError(t, err)
+
+	model := &models.InfrastructureModel{Region: "eu-west-1"}
+	model.AddResource(models.NewResource(models.ResourceVPC, "main-vpc"))
+	files, err := p.Generate(context.Background(), plugin.Request{Model: model, Format: "pulumi", Region: "eu-west-1"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Pulumi.yaml": "name: infra\n", "stacks/dev.yaml": "config: {}\n"}, files)
+
+	var request plugin.Request
+	content, err := os.ReadFile(requestPath)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(content, &request))
+	assert.Equal(t, plugin.ProtocolVersion, request.ProtocolVersion)
+	assert.Equal(t, "eu-west-1", request.Region)
+	require.Len(t, request.Model.Resources, 1)
+	assert.Equal(t, "main-vpc", request.Model.Resources[0].Name)
+
+	_, err = p.RunStage(context.Background(), plugin.Request{Model: model})
+	assert.EqualError(t, err, "plugin pulumi is not a stage")
+}
+
+func TestGenerateFailures(t *testing.T) {
+	skipOnWindows(t)
+	manifest := `{"protocol_version":1,"name":"gen","kind":"generator","format":"gen"}`
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"exit status", "echo 'no credentials for the registry' >&2\nexit 3\n", "plugin gen: generate failed: exit status 3: no credentials for the registry"},
+		{"error response", `echo '{"error":"unsupported resource eks_addon"}'` + "\n", "plugin gen: unsupported resource eks_addon"},
+		{"escaping path", `echo '{"files":{"../outside.txt":"x"}}'` + "\n", "plugin gen generated a file outside the output directory: ../outside.txt"},
+		{"absolute path", `echo '{"files":{"/etc/motd":"x"}}'` + "\n", "plugin gen generated a file outside the output directory: /etc/motd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := plugin.Describe(context.Background(), writePlugin(t, t.TempDir(), "gen", manifest, tt.body))
+			require.NoError(t, err)
+			_, err = p.Generate(context.Background(), plugin.Request{Model: &models.InfrastructureModel{}})
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestRunStage(t *testing.T) {
+	skipOnWindows(t)
+	manifest := `{"protocol_version":1,"name":"policy","kind":"stage"}`
+	path := writePlugin(t, t.TempDir(), "policy", manifest,
+		`cat > /dev/null
+echo '{"findings":[{"severity":"warning","message":"no Team tag","resource":"main-vpc"}],"model":{"resources":[{"type":"vpc","name":"main-vpc","properties":[]}],"tags":{"Team":"platform"}}}'
+`)
+	p, err := plugin.Describe(context.Background(), path)
+	require.NoError(t, err)
+
+	response, err := p.RunStage(context.Background(), plugin.Request{Model: &models.InfrastructureModel{}})
+	require.NoError(t, err)
+	assert.Equal(t, []plugin.Finding{{Severity: plugin.SeverityWarning, Message: "no Team tag", Resource: "main-vpc"}}, response.Findings)
+	require.NotNil(t, response.Model)
+	assert.Equal(t, map[string]string{"Team": "platform"}, response.Model.Tags)
+
+	path = writePlugin(t, t.TempDir(), "policy", manifest, `echo '{"findings":[{"severity":"fatal","message":"x"}]}'`+"\n")
+	p, err = plugin.Describe(context.Background(), path)
+	require.NoError(t, err)
+	_, err = p.RunStage(context.Background(), plugin.Request{Model: &models.InfrastructureModel{}})
+	assert.EqualError(t, err, `plugin policy reported a finding with an unknown severity "fatal"`)
+}
@@ -0,0 +1,135 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/policy"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eksPolicy denies public EKS endpoints in prod
+const eksPolicy = `package iacgen.eks
+
+import rego.v1
+
+deny contains {"msg": "EKS endpoints must not be public in prod", "resource": cluster.name} if {
+	input.environment == "prod"
+	some cluster in input.resources
+	cluster.type == "eks_cluster"
+	cluster.properties.vpc_config.endpoint_public_access
+}
+`
+
+// natPolicy denies more than one NAT gateway in dev, and warns about untagged models
+const natPolicy = `package iacgen.network.nat
+
+import rego.v1
+
+deny contains sprintf("dev may have at most 1 NAT gateway, not %d", [count(gateways)]) if {
+	input.environment == "dev"
+	gateways := [gateway | some gateway in input.resources; gateway.type == "nat_gateway"]
+	count(gateways) > 1
+}
+
+warn contains "the model has no Owner tag" if not input.tags.Owner
+`
+
+// writePolicies writes the policy files to a temporary directory and returns it
+func writePolicies(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return dir
+}
+
+// testModel returns a model of an EKS cluster with the given endpoint access and NAT gateways
+func testModel(environment string, publicEndpoint bool, natGateways int) *models.InfrastructureModel {
+	model := models.NewInfrastructureModel()
+	model.Environment = environment
+	model.Region = "us-east-1"
+	model.AddResource(infra.CreateVPC("main-vpc", "10.0.0.0/16", true, true))
+	for i := 0; i < natGateways; i++ {
+		model.AddResource(infra.CreateNATGateway("nat-gateway-"+strconv.Itoa(i+1), "public-subnet-1", "eip-allocation"))
+	}
+	model.AddResource(infra.CreateEKSCluster("main-eks", "1.29", "arn:aws:iam::123456789012:role/eks", []string{"private-subnet-1"}, publicEndpoint, true))
+	return model
+}
+
+func TestInput(t *testing.T) {
+	model := testModel("prod", true, 1)
+	model.Tags = map[string]string{"Owner": "platform"}
+	model.Resources[2].AddDependency("main-vpc")
+
+	input := policy.Input(model)
+	assert.Equal(t, "prod", input["environment"])
+	assert.Equal(t, "us-east-1", input["region"])
+	assert.Equal(t, map[string]interface{}{"Owner": "platform"}, input["tags"])
+
+	resources := input["resources"].([]interface{})
+	require.Len(t, resources, 3)
+	cluster := resources[2].(map[string]interface{})
+	assert.Equal(t, "eks_cluster", cluster["type"])
+	assert.Equal(t, "main-eks", cluster["name"])
+	assert.Equal(t, []interface{}{"main-vpc"}, cluster["depends_on"])
+	vpcConfig := cluster["properties"].(map[string]interface{})["vpc_config"].(map[string]interface{})
+	assert.Equal(t, true, vpcConfig["endpoint_public_access"])
+}
+
+func TestEvaluate(t *testing.T) {
+	dir := writePolicies(t, map[string]string{"eks.rego": eksPolicy, "nat.rego": natPolicy})
+	engine, err := policy.Load(context.Background(), []string{dir})
+	require.NoError(t, err)
+
+	// A public endpoint is denied in prod, and two NAT gateways in dev
+	result, err := engine.Evaluate(context.Background(), testModel("prod", true, 2))
+	require.NoError(t, err)
+	require.Len(t, result.Denials, 1)
+	assert.Equal(t, policy.Violation{Package: "iacgen.eks", Rule: policy.RuleDeny, Message: "EKS endpoints must not be public in prod", Resource: "main-eks"}, result.Denials[0])
+	assert.Equal(t, "[iacgen.eks] main-eks: EKS endpoints must not be public in prod", result.Denials[0].String())
+	require.Len(t, result.Warnings, 1)
+	assert.Equal(t, "[iacgen.network.nat] the model has no Owner tag", result.Warnings[0].String())
+
+	result, err = engine.Evaluate(context.Background(), testModel("dev", true, 2))
+	require.NoError(t, err)
+	require.Len(t, result.Denials, 1)
+	assert.Equal(t, "dev may have at most 1 NAT gateway, not 2", result.Denials[0].Message)
+
+	// Check returns the warnings, and fails with the denials
+	model := testModel("dev", false, 1)
+	model.Tags = map[string]string{"Owner": "platform"}
+	warnings, err := engine.Check(context.Background(), model)
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	warnings, err = engine.Check(context.Background(), testModel("prod", true, 1))
+	var violations policy.Violations
+	require.ErrorAs(t, err, &violations)
+	assert.Len(t, violations, 1)
+	assert.Len(t, warnings, 1)
+	assert.Equal(t, "the model violates 1 policies:\n  - [iacgen.eks] main-eks: EKS endpoints must not be public in prod", err.Error())
+}
+
+func TestLoadErrors(t *testing.T) {
+	// A policy that does not compile fails to load
+	dir := writePolicies(t, map[string]string{"broken.rego": "package iacgen.broken\n\ndeny[msg] {\n"})
+	_, err := policy.Load(context.Background(), []string{dir})
+	assert.ErrorContains(t, err, "failed to load policies")
+
+	_, err = policy.Load(context.Background(), []string{filepath.Join(t.TempDir(), "missing.rego")})
+	assert.ErrorContains(t, err, "failed to load policies")
+
+	// A deny rule that is not a set of messages fails the evaluation
+	dir = writePolicies(t, map[string]string{"bad.rego": "package iacgen.bad\n\ndeny := true\n"})
+	engine, err := policy.Load(context.Background(), []string{dir})
+	require.NoError(t, err)
+	_, err = engine.Evaluate(context.Background(), testModel("prod", true, 0))
+	assert.ErrorContains(t, err, "iacgen.bad.deny must be a set of messages")
+}
@@ -0,0 +1,125 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	sqtypes "github.com/aws/aws-sdk-go-v2/service/servicequotas/types"
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/quota"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAWS answers the quota checks for an account with 4 VPCs, 2 internet gateways, 4 Elastic
+// IPs and 5 NAT gateways in us-west-2a, whose VPC quota was raised to 10 while the others are
+// the AWS defaults of 5
+type fakeAWS struct {
+	// calls are the quota codes asked for
+	calls []string
+	// failQuotas makes GetServiceQuota fail
+	failQuotas bool
+}
+
+func (f *fakeAWS) GetServiceQuota(ctx context.Context, params *servicequotas.GetServiceQuotaInput, optFns ...func(*servicequotas.Options)) (*servicequotas.GetServiceQuotaOutput, error) {
+	f.calls = append(f.calls, aws.ToString(params.QuotaCode))
+	if f.failQuotas {
+		return nil, errors.New("AccessDeniedException: not authorized to perform servicequotas:GetServiceQuota")
+	}
+	if aws.ToString(params.QuotaCode) == quota.QuotaVPCs.QuotaCode {
+		return &servicequotas.GetServiceQuotaOutput{Quota: &sqtypes.ServiceQuota{Value: aws.Float64(10)}}, nil
+	}
+	return nil, &sqtypes.NoSuchResourceException{Message: aws.String("no applied quota")}
+}
+
+func (f *fakeAWS) GetAWSDefaultServiceQuota(ctx context.Context, params *servicequotas.GetAWSDefaultServiceQuotaInput, optFns ...func(*servicequotas.Options)) (*servicequotas.GetAWSDefaultServiceQuotaOutput, error) {
+	return &servicequotas.GetAWSDefaultServiceQuotaOutput{Quota: &sqtypes.ServiceQuota{Value: aws.Float64(5)}}, nil
+}
+
+func (f *fakeAWS) DescribeVpcs(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error) {
+	// The VPCs come in two pages
+	if params.NextToken == nil {
+		return &ec2.DescribeVpcsOutput{Vpcs: make([]ec2types.Vpc, 3), NextToken: aws.String("page-2")}, nil
+	}
+	return &ec2.DescribeVpcsOutput{Vpcs: make([]ec2types.Vpc, 1)}, nil
+}
+
+func (f *fakeAWS) DescribeInternetGateways(ctx context.Context, params *ec2.DescribeInternetGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInternetGatewaysOutput, error) {
+	return &ec2.DescribeInternetGatewaysOutput{InternetGateways: make([]ec2types.InternetGateway, 2)}, nil
+}
+
+func (f *fakeAWS) DescribeAddresses(ctx context.Context, params *ec2.DescribeAddressesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeAddressesOutput, error) {
+	return &ec2.DescribeAddressesOutput{Addresses: make([]ec2types.Address, 4)}, nil
+}
+
+func (f *fakeAWS) DescribeNatGateways(ctx context.Context, params *ec2.DescribeNatGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNatGatewaysOutput, error) {
+	nat := func(subnet string) ec2types.NatGateway {
+		return ec2types.NatGateway{SubnetId: aws.String(subnet)}
+	}
+	return &ec2.DescribeNatGatewaysOutput{NatGateways: []ec2types.NatGateway{
+		nat("subnet-a1"), nat("subnet-a1"), nat("subnet-a1"), nat("subnet-a2"), nat("subnet-a2"),
+	}}, nil
+}
+
+func (f *fakeAWS) DescribeSubnets(ctx context.Context, params *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error) {
+	return &ec2.DescribeSubnetsOutput{Subnets: []ec2types.Subnet{
+		{SubnetId: aws.String("subnet-a1"), AvailabilityZone: aws.String("us-west-2a")},
+		{SubnetId: aws.String("subnet-a2"), AvailabilityZone: aws.String("us-west-2a")},
+	}}, nil
+}
+
+// quotaTestModel returns the model of a VPC with a NAT gateway in each of its 3 public subnets,
+// in us-west-2a, us-west-2b and us-west-2c
+func quotaTestModel(t *testing.T) *models.InfrastructureModel {
+	builder := infra.NewModelBuilder()
+	require.NoError(t, builder.BuildFromParsedEntities(map[string]interface{}{
+		"region":   "us-west-2",
+		"vpc":      map[string]interface{}{"cidr_block": "10.0.0.0/16"},
+		"subnets":  map[string]interface{}{"public_count": 3, "private_count": 3},
+		"gateways": map[string]interface{}{"igw_count": 1, "nat_count": 3},
+	}))
+	return builder.GetModel()
+}
+
+func TestCheck(t *testing.T) {
+	fake := &fakeAWS{}
+	warnings, err := quota.NewChecker(fake, fake, "us-west-2").Check(context.Background(), quotaTestModel(t))
+	require.NoError(t, err)
+
+	// The raised VPC quota, the internet gateways and the NAT gateways of us-west-2b and
+	// us-west-2c have room, while 3 more Elastic IPs and a sixth NAT gateway in us-west-2a do not
+	assert.Equal(t, []quota.Warning{
+		{Quota: quota.QuotaElasticIPs, Scope: "us-west-2", Limit: 5, InUse: 4, Requested: 3},
+		{Quota: quota.QuotaNATGateways, Scope: "us-west-2a", Limit: 5, InUse: 5, Requested: 1},
+	}, warnings)
+	assert.Equal(t, "EC2-VPC Elastic IPs in us-west-2: 4 in use and 3 requested exceed the quota of 5 (ec2 L-0263D0A3); request an increase in the Service Quotas console", warnings[0].String())
+
+	// The resources of other regions are left to the checkers of those regions
+	fake = &fakeAWS{}
+	model := quotaTestModel(t)
+	infra.AddSecondaryRegion(model, "us-east-1")
+	warnings, err = quota.NewChecker(fake, fake, "us-east-1").Check(context.Background(), model)
+	require.NoError(t, err)
+	assert.Equal(t, []quota.Warning{
+		{Quota: quota.QuotaElasticIPs, Scope: "us-east-1", Limit: 5, InUse: 4, Requested: 3},
+	}, warnings)
+
+	// Quotas nothing is requested of are not queried
+	fake = &fakeAWS{}
+	model = models.NewInfrastructureModel()
+	model.Region = "us-west-2"
+	model.AddResource(infra.CreateVPC("main-vpc", "10.0.0.0/16", true, true))
+	warnings, err = quota.NewChecker(fake, fake, "us-west-2").Check(context.Background(), model)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, []string{quota.QuotaVPCs.QuotaCode}, fake.calls)
+
+	_, err = quota.NewChecker(&fakeAWS{failQuotas: true}, fake, "us-west-2").Check(context.Background(), quotaTestModel(t))
+	assert.ErrorContains(t, err, "failed to get the quota VPCs per Region (L-F678F1CE): AccessDeniedException")
+}
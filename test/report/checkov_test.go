@@ -0,0 +1,137 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkovOutput is a checkov report of a Terraform and a Kubernetes framework
+const checkovOutput = `[
+  {"check_type": "terraform", "results": {"failed_checks": [
+    {"check_id": "CKV_AWS_39", "check_name": "Ensure Amazon EKS public endpoint disabled", "file_path": "/modules/eks/main.tf", "file_line_range": [1, 20], "resource": "module.eks.aws_eks_cluster.this", "guideline": "https://docs.example.com/CKV_AWS_39"},
+    {"check_id": "CKV2_AWS_11", "check_name": "Ensure VPC flow logging is enabled in all VPCs", "file_path": "/modules/vpc/main.tf", "file_line_range": [3, 9], "resource": "module.vpc.aws_vpc.this"}
+  ]}, "summary": {"passed": 12, "failed": 2}},
+  {"check_type": "kubernetes", "results": {"failed_checks": [
+    {"check_id": "CKV_K8S_21", "check_name": "The default namespace should not be used", "file_path": "/team/namespace.yaml", "file_line_range": [1, 6], "resource": "ServiceAccount.default.platform"}
+  ]}, "summary": {"passed": 3, "failed": 1}}
+]`
+
+// writeCheckov writes a fake checkov that records its arguments and prints output
+func writeCheckov(t *testing.T, output string) (path string, argsFile string) {
+	bin := t.TempDir()
+	path = filepath.Join(bin, "checkov")
+	argsFile = filepath.Join(bin, "args")
+	writeFiles(t, bin, map[string]string{"checkov": "#!/bin/sh\necho \"$@\" > \"" + argsFile + "\"\ncat <<'JSON'\n" + output + "\nJSON\nexit 1\n"})
+	require.NoError(t, os.Chmod(path, 0755))
+	return path, argsFile
+}
+
+func TestRunCheckov(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake checkov is a shell script")
+	}
+
+	dir := t.TempDir()
+	checkov, argsFile := writeCheckov(t, checkovOutput)
+	policies, err := report.RunCheckov(dir, report.CheckovOptions{
+		Path: checkov,
+		Suppressions: []report.CheckovSuppression{
+			{Check: "CKV_AWS_39", Resources: []string{"module.eks.aws_eks_cluster.*"}, Reason: "The CI runners reach the API over the internet"},
+			{Check: "CKV_K8S_21"},
+			{Check: "CKV2_AWS_11", Resources: []string{"module.other.*"}},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"terraform", "kubernetes"}, policies.Frameworks)
+	assert.Equal(t, 15, policies.Passed)
+	assert.Equal(t, []report.PolicyFinding{{
+		Framework: "terraform",
+		Check:     "CKV2_AWS_11",
+		Name:      "Ensure VPC flow logging is enabled in all VPCs",
+		File:      "modules/vpc/main.tf",
+		Line:      3,
+		Resource:  "module.vpc.aws_vpc.this",
+	}}, policies.Failed(), "A suppression of other resources should not suppress a check")
+
+	suppressed := policies.Suppressed()
+	require.Len(t, suppressed, 2)
+	assert.Equal(t, "CKV_AWS_39", suppressed[0].Check)
+	assert.Equal(t, "The CI runners reach the API over the internet", suppressed[0].Reason)
+	assert.Equal(t, "CKV_K8S_21", suppressed[1].Check)
+
+	args, err := os.ReadFile(argsFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "--directory "+dir)
+	assert.Contains(t, string(args), "--output json")
+	assert.Contains(t, string(args), "--framework terraform kubernetes")
+
+	text := policies.Text()
+	assert.Contains(t, text, "CKV2_AWS_11  modules/vpc/main.tf:3  terraform  module.vpc.aws_vpc.this  Ensure VPC flow logging is enabled in all VPCs")
+	assert.Contains(t, text, "Suppressed:\n  CKV_AWS_39  modules/eks/main.tf:1")
+	assert.Contains(t, text, "no reason given")
+	assert.True(t, strings.HasSuffix(text, "Checked terraform and kubernetes with checkov: 15 passed, 1 failed, 2 suppressed\n"))
+
+	// A single framework is reported as an object, and a directory without files as a summary
+	checkov, _ = writeCheckov(t, `{"check_type": "terraform", "results": {"failed_checks": []}, "summary": {"passed": 4}}`)
+	policies, err = report.RunCheckov(dir, report.CheckovOptions{Path: checkov, Frameworks: []string{"terraform"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"terraform"}, policies.Frameworks)
+	assert.Equal(t, 4, policies.Passed)
+	assert.Empty(t, policies.Findings)
+
+	checkov, _ = writeCheckov(t, `{"passed": 0, "failed": 0, "skipped": 0, "parsing_errors": 0, "resource_count": 0}`)
+	policies, err = report.RunCheckov(dir, report.CheckovOptions{Path: checkov})
+	require.NoError(t, err)
+	assert.Empty(t, policies.Frameworks)
+	assert.Contains(t, policies.Text(), "Checked no files with checkov")
+}
+
+func TestValidateWithCheckov(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake checkov is a shell script")
+	}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.tf":             "module \"vpc\" {\n  source = \"./modules/vpc\"\n}\n",
+		"modules/vpc/main.tf": "variable \"cidr\" {}\n",
+	})
+	checkov, argsFile := writeCheckov(t, checkovOutput)
+
+	validation, err := report.ValidateDirectory(dir, report.ValidationOptions{
+		SkipTerraform:       true,
+		Checkov:             true,
+		CheckovPath:         checkov,
+		CheckovSuppressions: []report.CheckovSuppression{{Check: "CKV_AWS_39"}, {Check: "CKV_K8S_21"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []report.Finding{{
+		Severity: report.SeverityError,
+		File:     "modules/vpc/main.tf",
+		Line:     3,
+		Check:    "checkov",
+		Message:  "CKV2_AWS_11 module.vpc.aws_vpc.this: Ensure VPC flow logging is enabled in all VPCs",
+	}}, validation.Findings)
+
+	args, err := os.ReadFile(argsFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "--framework terraform", "Only the frameworks of the validated formats should be checked")
+	assert.NotContains(t, string(args), "kubernetes")
+}
+
+func TestValidateCheckovSuppressions(t *testing.T) {
+	assert.NoError(t, report.ValidateCheckovSuppressions([]report.CheckovSuppression{
+		{Check: "CKV_AWS_39", Resources: []string{"aws_eks_cluster.*"}},
+		{Check: "CKV2_AWS_11"},
+		{Check: "CKV_K8S_21", Reason: "Claims are namespaced by the team"},
+	}))
+	assert.ErrorContains(t, report.ValidateCheckovSuppressions([]report.CheckovSuppression{{Check: "aws-eks-public"}}), `invalid checkov check: "aws-eks-public"`)
+	assert.ErrorContains(t, report.ValidateCheckovSuppressions([]report.CheckovSuppression{{Check: "CKV_AWS_39", Resources: []string{"aws_[eks"}}}), "invalid resource pattern of checkov check CKV_AWS_39")
+}
@@ -0,0 +1,161 @@
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// findEntry returns the compliance entry for a control ID
+func findEntry(t *testing.T, r *report.ComplianceReport, controlID string) report.ComplianceEntry {
+	for _, entry := range r.Entries {
+		if entry.ControlID == controlID {
+			return entry
+		}
+	}
+	t.Fatalf("control %s not found in report", controlID)
+	return report.ComplianceEntry{}
+}
+
+func TestComplianceReportControls(t *testing.T) {
+	privateBucket := infra.CreateS3Bucket("private-bucket", "private", true)
+	privateBucket.AddProperty("server_side_encryption", "AES256")
+
+	openGroup := infra.CreateSecurityGroup("open-sg", "Open SSH", "main-vpc")
+	infra.AddSecurityGroupRule(&openGroup, "ingress", "tcp", 22, 22, []string{"0.0.0.0/0"})
+
+	webGroup := infra.CreateSecurityGroup("web-sg", "Web traffic", "main-vpc")
+	infra.AddSecurityGroupRule(&webGroup, "ingress", "tcp", 443, 443, []string{"0.0.0.0/0"})
+
+	privateCluster := infra.CreateEKSCluster("private-cluster", "1.28", "arn:aws:iam::123456789012:role/eks", []string{"private-subnet-1", "private-subnet-2"}, false, true)
+
+	tests := []struct {
+		name      string
+		resources []models.Resource
+		controlID string
+		expected  report.ControlStatus
+	}{
+		{
+			name:      "Encrypted bucket",
+			resources: []models.Resource{privateBucket},
+			controlID: "2.1.1",
+			expected:  report.StatusSatisfied,
+		},
+		{
+			name:      "Unencrypted bucket",
+			resources: []models.Resource{infra.CreateS3Bucket("plain-bucket", "private", false)},
+			controlID: "2.1.1",
+			expected:  report.StatusGap,
+		},
+		{
+			name:      "Public bucket ACL",
+			resources: []models.Resource{infra.CreateS3Bucket("public-bucket", "public-read", false)},
+			controlID: "2.1.5",
+			expected:  report.StatusGap,
+		},
+		{
+			name:      "No buckets",
+			resources: []models.Resource{infra.CreateVPC("main-vpc", "10.0.0.0/16", true, true)},
+			controlID: "2.1.1",
+			expected:  report.StatusNotApplicable,
+		},
+		{
+			name:      "SSH open to the world",
+			resources: []models.Resource{openGroup},
+			controlID: "5.2",
+			expected:  report.StatusGap,
+		},
+		{
+			name:      "Only HTTPS open to the world",
+			resources: []models.Resource{webGroup},
+			controlID: "5.2",
+			expected:  report.StatusSatisfied,
+		},
+		{
+			name:      "Private EKS endpoint",
+			resources: []models.Resource{privateCluster},
+			controlID: "EKS 5.4.2",
+			expected:  report.StatusSatisfied,
+		},
+		{
+			name:      "Private EKS subnets",
+			resources: []models.Resource{privateCluster},
+			controlID: "CC6.6",
+			expected:  report.StatusSatisfied,
+		},
+		{
+			name: "Single availability zone",
+			resources: []models.Resource{
+				infra.CreateSubnet("private-subnet-1", "main-vpc", "10.0.10.0/24", "us-east-1a"),
+				infra.CreateSubnet("private-subnet-2", "main-vpc", "10.0.11.0/24", "us-east-1a"),
+			},
+			controlID: "A1.2",
+			expected:  report.StatusGap,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			model := models.NewInfrastructureModel()
+			for _, resource := range tc.resources {
+				model.AddResource(resource)
+			}
+
+			r := report.GenerateComplianceReport(model, nil)
+			entry := findEntry(t, r, tc.controlID)
+			assert.Equal(t, tc.expected, entry.Status, "Unexpected status for control %s", tc.controlID)
+
+			// Remediation guidance is only given for gaps
+			if tc.expected == report.StatusGap {
+				assert.NotEmpty(t, entry.Remediation, "Gaps should include remediation guidance")
+			} else {
+				assert.Empty(t, entry.Remediation, "Only gaps should include remediation guidance")
+			}
+		})
+	}
+}
+
+func TestComplianceReportFromEntities(t *testing.T) {
+	entities := map[string]interface{}{
+		"region": "us-west-2",
+		"vpc": map[string]interface{}{
+			"cidr_block": "10.0.0.0/16",
+		},
+		"subnets": map[string]interface{}{
+			"public_count":  2,
+			"private_count": 2,
+		},
+		"eks": map[string]interface{}{
+			"version":    "1.28",
+			"node_count": 3,
+		},
+	}
+
+	builder := infra.NewModelBuilder()
+	err := builder.BuildFromParsedEntities(entities)
+	assert.NoError(t, err, "BuildFromParsedEntities should not return an error")
+
+	r := report.GenerateComplianceReport(builder.GetModel(), nil)
+	assert.Equal(t, len(report.DefaultControls()), len(r.Entries), "Every default control should be reported")
+
+	satisfied, gaps, notApplicable := r.Counts()
+	assert.Equal(t, len(r.Entries), satisfied+gaps+notApplicable, "Counts should cover every entry")
+	assert.Equal(t, gaps, len(r.Gaps()), "Gaps should match the gap count")
+
+	// The generated VPC has no flow logs
+	assert.Equal(t, report.StatusGap, findEntry(t, r, "3.9").Status, "VPC flow logs should be reported as a gap")
+	assert.Equal(t, report.StatusSatisfied, findEntry(t, r, "A1.2").Status, "Subnets should span multiple availability zones")
+
+	markdown := r.Markdown()
+	assert.True(t, strings.Contains(markdown, "# Compliance Matrix"), "Markdown should have a title")
+	assert.True(t, strings.Contains(markdown, "| CIS AWS | 3.9 |"), "Markdown should include the flow log control")
+	assert.True(t, strings.Contains(markdown, "## Gaps"), "Markdown should list gaps")
+
+	jsonReport, err := r.JSON()
+	assert.NoError(t, err, "JSON should not return an error")
+	assert.Contains(t, jsonReport, "\"control_id\": \"3.9\"", "JSON should include control IDs")
+}
@@ -0,0 +1,119 @@
+package report
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffDirectories(t *testing.T) {
+	target := t.TempDir()
+	writeFiles(t, target, map[string]string{
+		"main.tf":                 "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n",
+		"versions.tf":             "terraform {}\n",
+		"README.md":               "Owned by the platform team\n",
+		".terraform/providers.tf": "cached\n",
+	})
+	generated := t.TempDir()
+	writeFiles(t, generated, map[string]string{
+		"main.tf":             "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nN\n",
+		"versions.tf":         "terraform {}\n",
+		"modules/vpc/main.tf": "resource \"aws_vpc\" \"this\" {}\n",
+	})
+
+	diff, err := report.DiffDirectories(target, generated)
+	require.NoError(t, err)
+	require.Len(t, diff.Files, 3, "Unchanged and hidden files should be left out")
+
+	readme, main, module := diff.Files[0], diff.Files[1], diff.Files[2]
+	assert.Equal(t, report.FileDiff{Path: "README.md", Change: report.FileNotGenerated}, readme)
+
+	assert.Equal(t, "main.tf", main.Path)
+	assert.Equal(t, report.FileModified, main.Change)
+	assert.Equal(t, 2, main.Added)
+	assert.Equal(t, 2, main.Removed)
+	assert.Equal(t, "@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n@@ -11,4 +11,4 @@\n k\n l\n m\n-n\n+N\n", main.Hunks,
+		"Changes more than two contexts apart should be in separate hunks")
+
+	assert.Equal(t, "modules/vpc/main.tf", module.Path)
+	assert.Equal(t, report.FileAdded, module.Change)
+	assert.Equal(t, "@@ -0,0 +1,1 @@\n+resource \"aws_vpc\" \"this\" {}\n", module.Hunks)
+
+	assert.True(t, diff.HasChanges())
+	text := diff.Text(false)
+	assert.Contains(t, text, "--- a/main.tf\n+++ b/main.tf\n@@ -1,5 +1,5 @@\n")
+	assert.Contains(t, text, "--- /dev/null\n+++ b/modules/vpc/main.tf\n")
+	assert.Contains(t, text, "2 files would change in "+target+": 1 added, 1 modified\n")
+	assert.Contains(t, text, "1 files are not generated and would be left as they are:\n  README.md\n")
+	assert.NotContains(t, text, "\033[")
+
+	colored := diff.Text(true)
+	assert.Contains(t, colored, "\033[31m-b\033[0m\n")
+	assert.Contains(t, colored, "\033[32m+B\033[0m\n")
+	assert.Contains(t, colored, "\033[36m@@ -1,5 +1,5 @@\033[0m\n")
+}
+
+func TestDiffDirectoriesWithoutTarget(t *testing.T) {
+	generated := t.TempDir()
+	writeFiles(t, generated, map[string]string{"main.tf": "module \"vpc\" {}"})
+
+	diff, err := report.DiffDirectories(filepath.Join(t.TempDir(), "infra"), generated)
+	require.NoError(t, err)
+	require.Len(t, diff.Files, 1)
+	assert.Equal(t, report.FileAdded, diff.Files[0].Change)
+	assert.Equal(t, "@@ -0,0 +1,1 @@\n+module \"vpc\" {}\n\\ No newline at end of file\n", diff.Files[0].Hunks)
+
+	// The same files have no changes
+	diff, err = report.DiffDirectories(generated, generated)
+	require.NoError(t, err)
+	assert.False(t, diff.HasChanges())
+	assert.True(t, strings.HasPrefix(diff.Text(true), "No changes: "))
+}
+
+func TestDirectoryDiffPlan(t *testing.T) {
+	target := t.TempDir()
+	writeFiles(t, target, map[string]string{
+		"main.tf":     "module \"vpc\" {}\n",
+		"versions.tf": "terraform {}\n",
+	})
+	generated := t.TempDir()
+	writeFiles(t, generated, map[string]string{
+		"main.tf":             "module \"vpc\" {}\nmodule \"eks\" {}\n",
+		"versions.tf":         "terraform {}\n",
+		"modules/vpc/main.tf": "resource \"aws_vpc\" \"this\" {}\n",
+	})
+
+	diff, err := report.DiffDirectories(target, generated)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"versions.tf"}, diff.Unchanged)
+
+	plan := diff.Plan(false)
+	assert.True(t, strings.HasPrefix(plan, target+"/\n"+
+		"├── main.tf (modified, +1 -0)\n"+
+		"├── versions.tf (unchanged)\n"+
+		"└── modules/\n"+
+		"    └── vpc/\n"+
+		"        └── main.tf (created)\n"), plan)
+	assert.Contains(t, plan, "--- a/main.tf\n+++ b/main.tf\n", "Modified files should be shown as diffs")
+	assert.NotContains(t, plan, "+++ b/modules/vpc/main.tf", "Created files should only be listed")
+	assert.Contains(t, plan, "Dry run: 1 files would be created, 1 modified and 1 left unchanged in "+target)
+}
+
+func TestDirectoryDiffRedactsSecrets(t *testing.T) {
+	target := t.TempDir()
+	writeFiles(t, target, map[string]string{"resources.tf": "resource \"aws_db_instance\" \"db\" {\n  password = \"old-secret\"\n}\n"})
+	generated := t.TempDir()
+	writeFiles(t, generated, map[string]string{"resources.tf": "resource \"aws_db_instance\" \"db\" {\n  password = \"new-secret\"\n}\n"})
+
+	diff, err := report.DiffDirectories(target, generated)
+	require.NoError(t, err)
+	for _, text := range []string{diff.Text(false), diff.Plan(false)} {
+		assert.NotContains(t, text, "old-secret")
+		assert.NotContains(t, text, "new-secret")
+		assert.Contains(t, text, "+  password = \"(sensitive value)\"\n")
+	}
+}
@@ -0,0 +1,222 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// costOf returns the monthly cost of a resource of an estimate
+func costOf(t *testing.T, estimate *report.CostEstimate, module, resource string) report.CostItem {
+	for _, item := range estimate.Resources {
+		if item.Module == module && item.Resource == resource {
+			return item
+		}
+	}
+	require.Failf(t, "resource not estimated", "%s %s", module, resource)
+	return report.CostItem{}
+}
+
+func TestBuiltinEstimate(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.tf": `module "vpc" {
+  source             = "./modules/vpc"
+  availability_zones = var.availability_zones
+  single_nat_gateway = var.single_nat_gateway
+}
+
+module "eks" {
+  source      = "./modules/eks"
+  node_groups = var.node_groups
+  subnet_ids  = module.vpc.private_subnet_ids
+}
+
+module "registry" {
+  source  = "terraform-aws-modules/rds/aws"
+  version = "6.0.0"
+}
+
+resource "aws_launch_template" "web" {
+  instance_type = "t3.micro"
+}
+
+resource "aws_autoscaling_group" "web" {
+  desired_capacity = 3
+  launch_template {
+    id = aws_launch_template.web.id
+  }
+}
+
+resource "aws_instance" "bastion" {
+  instance_type = "t3.large"
+  root_block_device {
+    volume_size = 50
+  }
+}
+
+resource "aws_db_instance" "app" {
+  instance_class    = "db.t3.medium"
+  allocated_storage = 100
+  multi_az          = true
+}
+
+resource "aws_instance" "gpu" {
+  instance_type = "x9.mega"
+}
+
+resource "aws_s3_bucket" "logs" {}
+
+resource "aws_vpc" "free" {}
+`,
+		"variables.tf": `variable "availability_zones" {
+  default = ["us-east-1a"]
+}
+
+variable "single_nat_gateway" {
+  default = true
+}
+
+variable "node_groups" {}
+`,
+		"terraform.tfvars": `availability_zones = ["us-east-1a", "us-east-1b", "us-east-1c"]
+single_nat_gateway = false
+node_groups = {
+  default = { instance_types = ["m5.large"], capacity_type = "ON_DEMAND", desired_size = 2, disk_size = 50 }
+  spot    = { instance_types = ["m5.large", "m5.xlarge"], capacity_type = "SPOT", desired_size = 1, disk_size = 20 }
+}
+`,
+		"modules/vpc/main.tf": `locals {
+  nat_count = var.single_nat_gateway ? 1 : length(var.availability_zones)
+}
+
+resource "aws_nat_gateway" "this" {
+  count = local.nat_count
+}
+`,
+		"modules/vpc/variables.tf": "variable \"availability_zones\" {}\nvariable \"single_nat_gateway\" {}\n",
+		"modules/eks/main.tf": `resource "aws_eks_cluster" "this" {}
+
+resource "aws_eks_node_group" "this" {
+  for_each       = var.node_groups
+  instance_types = each.value.instance_types
+  capacity_type  = each.value.capacity_type
+  disk_size      = each.value.disk_size
+  scaling_config {
+    desired_size = each.value.desired_size
+  }
+}
+`,
+		"modules/eks/variables.tf": "variable \"node_groups\" {}\nvariable \"subnet_ids\" {}\n",
+		// Hidden directories, such as the provider cache, are not estimated
+		".terraform/modules/x/main.tf": `resource "aws_nat_gateway" "cached" {}`,
+	})
+
+	estimate, err := report.EstimateCosts(dir, report.EstimateOptions{Estimator: report.EstimatorBuiltin})
+	require.NoError(t, err)
+	assert.Equal(t, report.EstimatorBuiltin, estimate.Estimator)
+
+	// The count comes from the tfvars, through the module inputs and locals
+	for _, resource := range []string{"aws_nat_gateway.this[0]", "aws_nat_gateway.this[1]", "aws_nat_gateway.this[2]"} {
+		assert.InDelta(t, 32.85, *costOf(t, estimate, "module.vpc", resource).MonthlyCost, 0.001)
+	}
+	assert.InDelta(t, 73.0, *costOf(t, estimate, "module.eks", "aws_eks_cluster.this").MonthlyCost, 0.001)
+
+	onDemand := costOf(t, estimate, "module.eks", `aws_eks_node_group.this["default"]`)
+	assert.InDelta(t, 2*(0.096*730+50*0.10), *onDemand.MonthlyCost, 0.001)
+	assert.Equal(t, "2 x m5.large on demand, 50 GB disks", onDemand.Detail)
+	spot := costOf(t, estimate, "module.eks", `aws_eks_node_group.this["spot"]`)
+	assert.InDelta(t, 0.096*730*0.3+20*0.10, *spot.MonthlyCost, 0.001)
+
+	asg := costOf(t, estimate, "", "aws_autoscaling_group.web")
+	assert.InDelta(t, 3*0.0104*730, *asg.MonthlyCost, 0.001)
+	assert.Equal(t, "3 x t3.micro", asg.Detail)
+	assert.InDelta(t, 0.0832*730+50*0.08, *costOf(t, estimate, "", "aws_instance.bastion").MonthlyCost, 0.001)
+	assert.InDelta(t, 2*(0.068*730+100*0.115), *costOf(t, estimate, "", "aws_db_instance.app").MonthlyCost, 0.001)
+
+	// Resources without a price are listed without a cost
+	gpu := costOf(t, estimate, "", "aws_instance.gpu")
+	assert.Nil(t, gpu.MonthlyCost)
+	assert.Contains(t, gpu.Detail, "no built-in price for x9.mega")
+	assert.Nil(t, costOf(t, estimate, "", "aws_s3_bucket.logs").MonthlyCost)
+	assert.Equal(t, "not a local module; estimate it with infracost", costOf(t, estimate, "module.registry", "terraform-aws-modules/rds/aws").Detail)
+	for _, item := range estimate.Resources {
+		assert.NotEqual(t, "aws_vpc.free", item.Resource, "Free resources should be left out")
+		assert.NotEqual(t, "aws_nat_gateway.cached", item.Resource, "Hidden directories should be left out")
+	}
+
+	modules := estimate.Modules()
+	require.Len(t, modules, 4)
+	assert.Equal(t, "(root)", modules[0].Name())
+	assert.Equal(t, "module.eks", modules[1].Name())
+	assert.Equal(t, "module.registry", modules[2].Name())
+	assert.Equal(t, "module.vpc", modules[3].Name())
+	assert.InDelta(t, 3*32.85, modules[3].MonthlyCost, 0.001)
+
+	text := estimate.Text()
+	assert.Contains(t, text, "module.vpc                            $98.55\n")
+	assert.Contains(t, text, "  aws_nat_gateway.this[0]             $32.85   NAT gateway")
+	assert.Contains(t, text, "  aws_s3_bucket.logs                  -        usage-based: storage and requests")
+	assert.Contains(t, text, "estimated with the built-in us-east-1 on-demand prices")
+
+	content, err := estimate.JSON()
+	require.NoError(t, err)
+	assert.Contains(t, content, `"monthly_cost": 98.55`)
+}
+
+func TestEstimateWithInfracost(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake infracost is a shell script")
+	}
+
+	dir := t.TempDir()
+	infracost, argsFile := writeScanner(t, "infracost", `{"version": "0.2", "currency": "USD", "projects": [
+  {"name": "infra", "metadata": {"path": "`+dir+`"}, "breakdown": {"resources": [
+    {"name": "module.vpc.aws_nat_gateway.this[0]", "monthlyCost": "32.85", "costComponents": [{"name": "NAT gateway"}, {"name": "Data processed"}]},
+    {"name": "aws_s3_bucket.logs", "monthlyCost": null}
+  ]}},
+  {"name": "dr", "metadata": {"path": "`+filepath.Join(dir, "dr")+`"}, "breakdown": {"resources": [
+    {"name": "module.eks.aws_eks_cluster.this", "monthlyCost": "73"}
+  ]}}
+]}`)
+
+	estimate, err := report.EstimateCosts(dir, report.EstimateOptions{Path: infracost})
+	require.NoError(t, err)
+	args, err := os.ReadFile(argsFile)
+	require.NoError(t, err)
+	assert.Equal(t, "breakdown --path "+dir+" --format json --no-color", strings.TrimSpace(string(args)))
+
+	assert.Equal(t, report.EstimatorInfracost, estimate.Estimator)
+	assert.InDelta(t, 105.85, estimate.Total(), 0.001)
+	nat := costOf(t, estimate, "module.vpc", "aws_nat_gateway.this[0]")
+	assert.Equal(t, ".", nat.Project)
+	assert.Equal(t, "NAT gateway, Data processed", nat.Detail)
+	assert.Equal(t, "usage-based", costOf(t, estimate, "", "aws_s3_bucket.logs").Detail)
+	assert.Equal(t, "dr", costOf(t, estimate, "module.eks", "aws_eks_cluster.this").Project)
+
+	text := estimate.Text()
+	assert.Contains(t, text, "dr/module.eks")
+	assert.Contains(t, text, "Total: $105.85 per month, estimated with infracost")
+}
+
+func TestFindEstimator(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	estimator, _, err := report.FindEstimator("")
+	require.NoError(t, err)
+	assert.Equal(t, report.EstimatorBuiltin, estimator, "The built-in prices should be used without infracost")
+
+	_, _, err = report.FindEstimator(report.EstimatorInfracost)
+	assert.ErrorContains(t, err, "infracost not found in PATH")
+	_, _, err = report.FindEstimator("aws")
+	assert.ErrorContains(t, err, "invalid estimator: aws")
+
+	_, err = report.EstimateCosts(t.TempDir(), report.EstimateOptions{Estimator: report.EstimatorBuiltin})
+	assert.ErrorContains(t, err, "no Terraform files found")
+}
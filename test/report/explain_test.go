@@ -0,0 +1,109 @@
+package report
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// explainTestModel returns a small model with nested properties and references
+func explainTestModel() *models.InfrastructureModel {
+	model := models.NewInfrastructureModel()
+	model.Region = "us-west-2"
+	model.Environment = "staging"
+	model.Tags = map[string]string{"Team": "platform", "Environment": "staging"}
+
+	vpc := models.NewResource(models.ResourceVPC, "main-vpc")
+	vpc.AddProperty("cidr_block", "10.0.0.0/16")
+	model.AddResource(vpc)
+
+	subnet := models.NewResource(models.ResourceSubnet, "private-subnet-1")
+	subnet.AddProperty("vpc_id", "main-vpc")
+	subnet.AddDependency("main-vpc")
+	model.AddResource(subnet)
+
+	nodeGroup := models.NewResource(models.ResourceNodeGroup, "main-node-group")
+	nodeGroup.AddProperty("subnet_ids", []string{"private-subnet-1"})
+	nodeGroup.AddProperty("scaling_config", map[string]interface{}{"min_size": 2, "desired_size": 3})
+	nodeGroup.AddProperty("user_data", strings.Repeat("echo hello\n", 20))
+	model.AddResource(nodeGroup)
+
+	return model
+}
+
+func TestExplainModel(t *testing.T) {
+	extraction := &nlp.ExtractionReport{
+		Confidence:  map[string]float64{"vpc": 0.5, "eks": 1},
+		Assumptions: []nlp.Assumption{{Entity: "vpc", Field: "cidr_block", Value: "10.0.0.0/16", Reason: "no CIDR block stated"}},
+	}
+	explanation := report.ExplainModel(explainTestModel(), extraction)
+
+	require.Len(t, explanation.Resources, 3)
+	assert.Empty(t, explanation.Resources[0].DependsOn)
+	assert.Equal(t, []string{"main-vpc"}, explanation.Resources[1].DependsOn, "A referenced dependency should be listed once")
+	assert.Equal(t, []string{"private-subnet-1"}, explanation.Resources[2].DependsOn, "Resources referenced by properties should be dependencies")
+
+	text := explanation.Text()
+	assert.True(t, strings.HasPrefix(text, "Infrastructure model: 3 resources in us-west-2 for the staging environment\nTags: Environment=staging, Team=platform\n"))
+	assert.Contains(t, text, `├── vpc main-vpc
+│   └── cidr_block: 10.0.0.0/16
+├── subnet private-subnet-1
+│   ├── vpc_id: main-vpc
+│   └── depends on: main-vpc
+└── eks_node_group main-node-group
+    ├── subnet_ids: [private-subnet-1]
+    ├── scaling_config
+    │   ├── desired_size: 3
+    │   └── min_size: 2
+`)
+	assert.Contains(t, text, "    ├── user_data: echo hello echo hello", "Long values should be shortened to one line")
+	assert.Contains(t, text, "...\n    └── depends on: private-subnet-1\n")
+	assert.Contains(t, text, "Assumed defaults\n  • vpc.cidr_block = 10.0.0.0/16: no CIDR block stated\n  Confidence: eks 100%, vpc 50%\n")
+
+	markdown := explanation.Markdown()
+	assert.Contains(t, markdown, "3 resources in us-west-2 for the staging environment.\n")
+	assert.Contains(t, markdown, "- **eks_node_group** `main-node-group`\n  - subnet_ids: `[private-subnet-1]`\n  - scaling_config\n    - desired_size: `3`\n")
+	assert.Contains(t, markdown, "  - depends on: `private-subnet-1`\n")
+	assert.Contains(t, markdown, "- `vpc.cidr_block = 10.0.0.0/16`: no CIDR block stated\n")
+
+	content, err := explanation.JSON()
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(content), &decoded))
+	assert.Equal(t, "staging", decoded["environment"])
+	assert.Len(t, decoded["assumptions"], 1)
+}
+
+func TestExplainModelRedactsSecrets(t *testing.T) {
+	model := models.NewInfrastructureModel()
+	database := models.NewResource(models.ResourceRDSInstance, "app-db")
+	database.AddProperty("username", "admin")
+	database.AddProperty("password", "hunter2")
+	database.AddProperty("credentials", map[string]interface{}{"auth_token": "abc123"})
+	model.AddResource(database)
+
+	explanation := report.ExplainModel(model, nil)
+	content, err := explanation.JSON()
+	require.NoError(t, err)
+	for _, text := range []string{explanation.Text(), explanation.Markdown(), content} {
+		assert.NotContains(t, text, "hunter2")
+		assert.NotContains(t, text, "abc123")
+		assert.Contains(t, text, "admin")
+	}
+	assert.Contains(t, explanation.Text(), "password: (sensitive value)")
+	assert.Equal(t, "hunter2", model.Resources[0].Properties[1].Value, "The model itself should be left as it is")
+}
+
+func TestExplainModelFromSpec(t *testing.T) {
+	explanation := report.ExplainModel(explainTestModel(), nil)
+	assert.Empty(t, explanation.Assumptions)
+	assert.Contains(t, explanation.Text(), "Assumed defaults\n  None\n")
+	assert.NotContains(t, explanation.Text(), "Confidence")
+	assert.Contains(t, explanation.Markdown(), "### Assumed Defaults\n\nNone.\n")
+}
@@ -0,0 +1,188 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// iamTerraform has a policy of every kind the IAM lint reads, each with a problem
+const iamTerraform = `resource "aws_iam_role_policy" "app" {
+  name = "app"
+  role = aws_iam_role.app.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Effect   = "Allow"
+        Action   = "*"
+        Resource = "*"
+      },
+      {
+        Effect   = "Allow"
+        Action   = ["s3:*", "ec2:DescribeInstances", "ec2:Describe*"]
+        Resource = "*"
+      },
+      {
+        Effect   = "Allow"
+        Action   = ["ec2:CreateTags", "ec2:DeleteTags"]
+        Resource = "*"
+      },
+      {
+        Effect   = "Allow"
+        Action   = ["ec2:TerminateInstances"]
+        Resource = "*"
+        Condition = {
+          StringEquals = {
+            "aws:ResourceTag/team" = "app"
+          }
+        }
+      },
+      {
+        Effect    = "Deny"
+        Principal = "*"
+        Action    = "*"
+        Resource  = aws_s3_bucket.app.arn
+      },
+    ]
+  })
+}
+
+data "aws_iam_policy_document" "irsa" {
+  statement {
+    actions = ["sts:AssumeRoleWithWebIdentity"]
+
+    principals {
+      identifiers = [aws_iam_openid_connect_provider.this.arn]
+      type        = "Federated"
+    }
+  }
+}
+
+data "aws_iam_policy_document" "irsa_like" {
+  statement {
+    actions = ["sts:AssumeRoleWithWebIdentity"]
+
+    condition {
+      test     = "StringLike"
+      variable = "${replace(aws_iam_openid_connect_provider.this.url, "https://", "")}:sub"
+      values   = ["system:serviceaccount:*"]
+    }
+
+    condition {
+      test     = "StringEquals"
+      variable = "${replace(aws_iam_openid_connect_provider.this.url, "https://", "")}:aud"
+      values   = ["sts.amazonaws.com"]
+    }
+
+    principals {
+      identifiers = [aws_iam_openid_connect_provider.this.arn]
+      type        = "Federated"
+    }
+  }
+}
+
+resource "aws_iam_role_policy_attachment" "admin" {
+  policy_arn = "arn:aws:iam::aws:policy/AdministratorAccess"
+  role       = aws_iam_role.app.name
+}
+
+resource "aws_iam_role_policy_attachment" "s3" {
+  policy_arn = "arn:aws:iam::aws:policy/AmazonS3FullAccess"
+  role       = aws_iam_role.app.name
+}
+
+resource "aws_iam_role_policy_attachment" "ecr" {
+  policy_arn = "arn:aws:iam::aws:policy/AmazonEC2ContainerRegistryReadOnly"
+  role       = aws_iam_role.app.name
+}
+`
+
+// iamManifests are Crossplane roles with JSON policy documents and managed policies
+const iamManifests = `apiVersion: iam.aws.upbound.io/v1beta1
+kind: Role
+metadata:
+  name: trusted-by-anyone
+spec:
+  forProvider:
+    assumeRolePolicy: |
+      {
+        "Version": "2012-10-17",
+        "Statement": [
+          {"Effect": "Allow", "Principal": {"AWS": "*"}, "Action": "sts:AssumeRole"}
+        ]
+      }
+    managedPolicyArns:
+      - arn:aws:iam::aws:policy/IAMFullAccess
+---
+apiVersion: iam.aws.upbound.io/v1beta1
+kind: Role
+metadata:
+  name: irsa
+spec:
+  forProvider:
+    assumeRolePolicy: |
+      {
+        "Version": "2012-10-17",
+        "Statement": [
+          {
+            "Effect": "Allow",
+            "Principal": {"Federated": "arn:aws:iam::111122223333:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE"},
+            "Action": "sts:AssumeRoleWithWebIdentity",
+            "Condition": {
+              "StringEquals": {
+                "oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE:sub": "system:serviceaccount:kube-system:ebs-csi-controller-sa",
+                "oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE:aud": "sts.amazonaws.com"
+              }
+            }
+          }
+        ]
+      }
+    managedPolicyArns:
+      - arn:aws:iam::aws:policy/service-role/AmazonEBSCSIDriverPolicy
+`
+
+func TestValidateDirectoryIAM(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"terraform/iam.tf":         iamTerraform,
+		"crossplane/iam/role.yaml": iamManifests,
+	})
+
+	validation, err := report.ValidateDirectory(dir, report.ValidationOptions{SkipTerraform: true, IAM: true})
+	require.NoError(t, err)
+
+	var iam []report.Finding
+	for _, finding := range validation.Findings {
+		if finding.Check == "iam" {
+			iam = append(iam, finding)
+		}
+	}
+	assert.Equal(t, []report.Finding{
+		{Severity: report.SeverityError, File: "crossplane/iam/role.yaml", Line: 7, Check: "iam", Message: `Role trusted-by-anyone: trusts every principal ("*"); name the principals, or add a condition such as aws:SourceAccount or aws:PrincipalOrgID`},
+		{Severity: report.SeverityError, File: "crossplane/iam/role.yaml", Line: 15, Check: "iam", Message: "Role trusted-by-anyone: attaches the AWS managed policy IAMFullAccess, which allows nearly every action on every resource; use IAMReadOnlyAccess, or a policy scoped to the roles it manages"},
+		{Severity: report.SeverityError, File: "terraform/iam.tf", Line: 8, Check: "iam", Message: `aws_iam_role_policy.app: allows every action ("*"); list the actions the role needs instead`},
+		{Severity: report.SeverityWarning, File: "terraform/iam.tf", Line: 13, Check: "iam", Message: "aws_iam_role_policy.app: allows every s3 action (s3:*); list the s3 actions the role needs, such as s3:Describe* and s3:List* to only read"},
+		{Severity: report.SeverityWarning, File: "terraform/iam.tf", Line: 18, Check: "iam", Message: `aws_iam_role_policy.app: allows ec2:CreateTags and 1 more write actions on every resource ("*"); list the ARNs of the resources, or add a condition such as aws:ResourceTag/<key> or aws:RequestTag/<key> limiting it to the resources the role manages`},
+		{Severity: report.SeverityError, File: "terraform/iam.tf", Line: 44, Check: "iam", Message: "data.aws_iam_policy_document.irsa: lets every identity of the OIDC provider assume the role, as no condition limits the sub of its token; add a StringEquals condition on <issuer>:sub to system:serviceaccount:<namespace>:<service-account>"},
+		{Severity: report.SeverityWarning, File: "terraform/iam.tf", Line: 44, Check: "iam", Message: "data.aws_iam_policy_document.irsa: does not check the audience of the web identity token; add a StringEquals condition on <issuer>:aud to sts.amazonaws.com"},
+		{Severity: report.SeverityWarning, File: "terraform/iam.tf", Line: 55, Check: "iam", Message: "data.aws_iam_policy_document.irsa_like: lets every service account matching system:serviceaccount:* assume the role; name the service account with a StringEquals condition"},
+		{Severity: report.SeverityError, File: "terraform/iam.tf", Line: 78, Check: "iam", Message: "aws_iam_role_policy_attachment.admin: attaches the AWS managed policy AdministratorAccess, which allows nearly every action on every resource; use a policy of the actions the role needs, which IAM Access Analyzer can generate from its CloudTrail activity"},
+		{Severity: report.SeverityWarning, File: "terraform/iam.tf", Line: 83, Check: "iam", Message: "aws_iam_role_policy_attachment.s3: attaches the AWS managed policy AmazonS3FullAccess, which allows every action of its service on every resource; use AmazonS3ReadOnlyAccess if the role only reads, or a policy of the actions it needs on its own resources"},
+	}, iam)
+
+	// The IAM lint only runs when asked for, on the formats validated
+	validation, err = report.ValidateDirectory(dir, report.ValidationOptions{SkipTerraform: true})
+	require.NoError(t, err)
+	for _, finding := range validation.Findings {
+		assert.NotEqual(t, "iam", finding.Check)
+	}
+	validation, err = report.ValidateDirectory(dir, report.ValidationOptions{Format: report.FormatCrossplane, IAM: true})
+	require.NoError(t, err)
+	for _, finding := range validation.Findings {
+		assert.NotContains(t, finding.File, "terraform/")
+	}
+}
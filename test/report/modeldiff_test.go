@@ -0,0 +1,94 @@
+package report
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/riptano/iac_generator_cli/internal/spec"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffModels(t *testing.T) {
+	before := explainTestModel()
+	database := models.NewResource(models.ResourceRDSInstance, "orders-db")
+	database.AddProperty("engine", "postgres")
+	database.AddProperty("password", "hunter2")
+	before.AddResource(database)
+
+	after := explainTestModel()
+	after.Region = "us-east-2"
+	after.Tags["Owner"] = "sre"
+	after.Resources[2].Properties[1].Value = map[string]interface{}{"min_size": 2, "desired_size": 5}
+	after.Resources[2].AddDependency("main-vpc")
+	bucket := models.NewResource(models.ResourceS3Bucket, "app-logs")
+	bucket.AddProperty("versioning", true)
+	after.AddResource(bucket)
+
+	diff := report.DiffModels(before, after)
+	require.True(t, diff.HasChanges())
+	assert.Equal(t, []report.PropertyDiff{
+		{Name: "region", Change: report.ModelChanged, Before: "us-west-2", After: "us-east-2"},
+		{Name: "tags.Owner", Change: report.ModelAdded, After: "sre"},
+	}, diff.Settings)
+
+	require.Len(t, diff.Resources, 3)
+	assert.Equal(t, report.ResourceDiff{
+		Type:   models.ResourceNodeGroup,
+		Name:   "main-node-group",
+		Change: report.ModelChanged,
+		Properties: []report.PropertyDiff{
+			{Name: "scaling_config.desired_size", Change: report.ModelChanged, Before: 3, After: 5},
+			{Name: "depends_on", Change: report.ModelAdded, After: []string{"main-vpc"}},
+		},
+	}, diff.Resources[0], "Only the keys of a map that changed should be listed")
+	assert.Equal(t, report.ModelAdded, diff.Resources[1].Change)
+	assert.Equal(t, "app-logs", diff.Resources[1].Name)
+	assert.Equal(t, report.ModelRemoved, diff.Resources[2].Change, "Removed resources should come last")
+	assert.Contains(t, diff.Resources[2].Properties, report.PropertyDiff{Name: "password", Change: report.ModelRemoved, Before: "(sensitive value)"}, "Secrets should not be reported")
+
+	added, removed, changed := diff.Counts()
+	assert.Equal(t, []int{1, 1, 1}, []int{added, removed, changed})
+
+	text := diff.Text(false)
+	assert.Contains(t, text, `~ region: us-west-2 -> us-east-2
++ tags.Owner: sre
+
+~ eks_node_group main-node-group
+    ~ scaling_config.desired_size: 3 -> 5
+    + depends_on: [main-vpc]
++ s3_bucket app-logs
+    + versioning: true
+- rds_instance orders-db
+    - engine: postgres
+    - password: (sensitive value)
+`)
+	assert.True(t, strings.HasSuffix(text, "\n3 resources would change: 1 added, 1 removed, 1 changed; 2 settings changed\n"))
+	assert.NotContains(t, text, "hunter2")
+	assert.Contains(t, diff.Text(true), "\033[32m+ s3_bucket app-logs\033[0m")
+
+	content, err := diff.JSON()
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(content), &decoded))
+	assert.Len(t, decoded["resources"], 3)
+	assert.NotContains(t, content, "hunter2")
+}
+
+func TestDiffModelsFromSpec(t *testing.T) {
+	// A spec's environment renames its resources, so the model has none
+	model := explainTestModel()
+	model.Environment = ""
+	content, err := json.Marshal(model)
+	require.NoError(t, err)
+	loaded, err := spec.Load(content)
+	require.NoError(t, err)
+
+	// The values decoded from JSON have other Go types, but are the same
+	diff := report.DiffModels(model, loaded)
+	assert.False(t, diff.HasChanges(), "Expected no changes, got:\n%s", diff.Text(false))
+	assert.Equal(t, "No changes: the models are the same\n", diff.Text(false))
+}
@@ -0,0 +1,30 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailoverRunbook(t *testing.T) {
+	primary := models.NewInfrastructureModel()
+	primary.AddResource(infra.CreateVPC("main-vpc", "10.0.0.0/16", true, true))
+	primary.AddResource(infra.CreateEKSCluster("main-eks-cluster", "1.28", "arn:aws:iam::123456789012:role/eks", []string{"private-subnet-1"}, true, false))
+	primary.AddResource(infra.CreateS3Bucket("app-data", "private", false))
+
+	variant := infra.BuildDRVariant(primary, "eu-west-1")
+
+	runbook := report.FailoverRunbook(variant, "crossplane", "us-east-1", "eu-west-1", "dr")
+	assert.Contains(t, runbook, "# Failover Runbook", "Runbook should have a title")
+	assert.Contains(t, runbook, "Disaster-recovery region: `eu-west-1`", "Runbook should name the DR region")
+	assert.Contains(t, runbook, "kubectl apply -k dr/eks", "Crossplane runbook should apply the DR manifests")
+	assert.Contains(t, runbook, "--name main-eks-cluster-dr", "Runbook should point kubeconfig at the DR cluster")
+	assert.Contains(t, runbook, "Point applications reading `app-data` at replica bucket `app-data-dr`", "Runbook should cover replicated buckets")
+
+	runbook = report.FailoverRunbook(variant, "terraform", "us-east-1", "eu-west-1", "dr")
+	assert.Contains(t, runbook, "terraform apply", "Terraform runbook should apply the DR configuration")
+	assert.Contains(t, runbook, "terraform -chdir=dr output -raw cluster_id", "Terraform runbook should read the cluster name from outputs")
+}
@@ -0,0 +1,111 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeScanner writes a fake scanner that records its arguments and prints output
+func writeScanner(t *testing.T, name, output string) (path string, argsFile string) {
+	bin := t.TempDir()
+	path = filepath.Join(bin, name)
+	argsFile = filepath.Join(bin, "args")
+	writeFiles(t, bin, map[string]string{name: "#!/bin/sh\necho \"$@\" > \"" + argsFile + "\"\ncat <<'JSON'\n" + output + "\nJSON\n"})
+	require.NoError(t, os.Chmod(path, 0755))
+	return path, argsFile
+}
+
+func TestScanDirectoryWithTrivy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake trivy is a shell script")
+	}
+
+	dir := t.TempDir()
+	trivy, argsFile := writeScanner(t, "trivy", `{"SchemaVersion": 2, "Results": [
+  {"Target": "modules/eks/main.tf", "Type": "terraform", "Misconfigurations": [
+    {"ID": "AVD-AWS-0038", "AVDID": "AVD-AWS-0038", "Message": "Control plane scheduler logging is not enabled.", "Severity": "MEDIUM", "Status": "FAIL", "CauseMetadata": {"Resource": "aws_eks_cluster.this", "StartLine": 1}},
+    {"ID": "AVD-AWS-0040", "AVDID": "AVD-AWS-0040", "Message": "Public cluster access is enabled.", "Severity": "CRITICAL", "Status": "FAIL", "CauseMetadata": {"Resource": "aws_eks_cluster.this", "StartLine": 7}}
+  ]},
+  {"Target": "modules/vpc/main.tf", "Type": "terraform", "Misconfigurations": [
+    {"ID": "AVD-AWS-0178", "AVDID": "AVD-AWS-0178", "Message": "VPC does not have VPC Flow Logs enabled.", "Severity": "HIGH", "Status": "FAIL", "CauseMetadata": {"Resource": "aws_vpc.this", "StartLine": 3}}
+  ]},
+  {"Target": "main.tf", "Type": "terraform"}
+]}`)
+
+	scan, err := report.ScanDirectory(dir, report.ScanOptions{Scanner: "trivy", Path: trivy})
+	require.NoError(t, err)
+	assert.Equal(t, report.ScannerTrivy, scan.Scanner)
+	assert.Equal(t, []report.ScanFinding{
+		{Severity: "CRITICAL", File: "modules/eks/main.tf", Line: 7, ID: "AVD-AWS-0040", Resource: "aws_eks_cluster.this", Message: "Public cluster access is enabled."},
+		{Severity: "HIGH", File: "modules/vpc/main.tf", Line: 3, ID: "AVD-AWS-0178", Resource: "aws_vpc.this", Message: "VPC does not have VPC Flow Logs enabled."},
+		{Severity: "MEDIUM", File: "modules/eks/main.tf", Line: 1, ID: "AVD-AWS-0038", Resource: "aws_eks_cluster.this", Message: "Control plane scheduler logging is not enabled."},
+	}, scan.Findings, "Findings should be sorted from the most severe")
+
+	args, err := os.ReadFile(argsFile)
+	require.NoError(t, err)
+	assert.Equal(t, "config --format json --quiet "+dir, strings.TrimSpace(string(args)))
+
+	assert.Len(t, scan.AtOrAbove("high"), 2)
+	assert.Len(t, scan.AtOrAbove("CRITICAL"), 1)
+	assert.Len(t, scan.AtOrAbove("low"), 3)
+
+	summary := scan.Summary()
+	assert.Contains(t, summary, "CRITICAL  modules/eks/main.tf:7  AVD-AWS-0040  aws_eks_cluster.this: Public cluster access is enabled.")
+	assert.Contains(t, summary, "HIGH      modules/vpc/main.tf:3  AVD-AWS-0178")
+	assert.NotContains(t, summary, "AVD-AWS-0038", "Only HIGH and CRITICAL findings should be listed")
+	assert.Contains(t, summary, "Scanned with trivy: 1 critical, 1 high, 1 medium, 0 low findings")
+}
+
+func TestScanDirectoryWithTFSec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake tfsec is a shell script")
+	}
+
+	dir := t.TempDir()
+	tfsec, argsFile := writeScanner(t, "tfsec", `{"results": [
+  {"rule_id": "AVD-AWS-0040", "long_id": "aws-eks-no-public-cluster-access", "description": "Public cluster access is enabled.", "severity": "CRITICAL", "status": 0, "resource": "aws_eks_cluster.this",
+   "location": {"filename": "`+filepath.Join(dir, "modules", "eks", "main.tf")+`", "start_line": 7}},
+  {"rule_id": "AVD-AWS-0038", "description": "Control plane scheduler logging is not enabled.", "severity": "MEDIUM", "status": 2, "resource": "aws_eks_cluster.this",
+   "location": {"filename": "`+filepath.Join(dir, "modules", "eks", "main.tf")+`", "start_line": 1}}
+]}`)
+
+	scan, err := report.ScanDirectory(dir, report.ScanOptions{Scanner: "tfsec", Path: tfsec})
+	require.NoError(t, err)
+	assert.Equal(t, []report.ScanFinding{
+		{Severity: "CRITICAL", File: "modules/eks/main.tf", Line: 7, ID: "AVD-AWS-0040", Resource: "aws_eks_cluster.this", Message: "Public cluster access is enabled."},
+	}, scan.Findings, "Ignored checks should be left out")
+
+	args, err := os.ReadFile(argsFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "--soft-fail")
+}
+
+func TestScanDirectoryErrors(t *testing.T) {
+	_, err := report.ScanDirectory(t.TempDir(), report.ScanOptions{Scanner: "checkov"})
+	assert.ErrorContains(t, err, "unsupported scanner: checkov")
+
+	if runtime.GOOS != "windows" {
+		trivy, _ := writeScanner(t, "trivy", "not json")
+		_, err = report.ScanDirectory(t.TempDir(), report.ScanOptions{Scanner: "trivy", Path: trivy})
+		assert.ErrorContains(t, err, "failed to read the trivy report")
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	_, err = report.ScanDirectory(t.TempDir(), report.ScanOptions{})
+	assert.ErrorContains(t, err, "neither trivy nor tfsec was found in PATH")
+}
+
+func TestValidScanSeverity(t *testing.T) {
+	for _, severity := range []string{"low", "MEDIUM", "High", "critical"} {
+		assert.True(t, report.ValidScanSeverity(severity), severity)
+	}
+	assert.False(t, report.ValidScanSeverity("severe"))
+	assert.False(t, report.ValidScanSeverity(""))
+}
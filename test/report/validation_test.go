@@ -0,0 +1,400 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/adapter/crossplane"
+	"github.com/riptano/iac_generator_cli/internal/adapter/terraform"
+	"github.com/riptano/iac_generator_cli/internal/nlp"
+	"github.com/riptano/iac_generator_cli/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles writes files, keyed by their path relative to dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+// findingsIn returns the findings of a check
+func findingsIn(r *report.ValidationReport, check string) []report.Finding {
+	var findings []report.Finding
+	for _, finding := range r.Findings {
+		if finding.Check == check {
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+func TestValidateTerraformDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.tf": `module "vpc" {
+  source = "./modules/vpc"
+}
+
+module "eks" {
+  source = "./modules/eks"
+}
+`,
+		"modules/vpc/main.tf":      "resource \"aws_vpc\" \"this\" {\n  cidr_block = \"10.0.0.0/16\"\n}\n",
+		"terraform.tfvars.staging": "vpc_name = \"main-staging\"\n",
+		"dr/main.tf":               "resource \"aws_vpc\" \"this\" {\n  cidr_block = ${var.cidr}\n}\n",
+	})
+
+	validation, err := report.ValidateDirectory(dir, report.ValidationOptions{SkipTerraform: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{report.FormatTerraform}, validation.Formats)
+	assert.Equal(t, 4, validation.Files)
+
+	sources := findingsIn(validation, "module source")
+	require.Len(t, sources, 1)
+	assert.Equal(t, "main.tf", sources[0].File)
+	assert.Equal(t, 6, sources[0].Line)
+	assert.Contains(t, sources[0].Message, `module "eks" source ./modules/eks does not exist`)
+
+	syntax := findingsIn(validation, "hcl")
+	require.NotEmpty(t, syntax)
+	assert.Equal(t, "dr/main.tf", syntax[0].File)
+	assert.Equal(t, 2, syntax[0].Line)
+
+	assert.True(t, validation.HasErrors())
+	assert.Contains(t, validation.Text(), "dr/main.tf:2")
+
+	_, err = report.ValidateDirectory(dir, report.ValidationOptions{Format: report.FormatCrossplane})
+	assert.ErrorContains(t, err, "no crossplane files")
+}
+
+func TestValidateWithTerraform(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake terraform is a shell script")
+	}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.tf":             "module \"vpc\" {\n  source = \"./modules/vpc\"\n}\n",
+		"modules/vpc/main.tf": "variable \"cidr\" {}\n",
+	})
+
+	// The fake terraform records where it ran and reports one diagnostic
+	bin := t.TempDir()
+	terraformPath := filepath.Join(bin, "terraform")
+	writeFiles(t, bin, map[string]string{"terraform": `#!/bin/sh
+pwd >> "` + filepath.Join(bin, "runs") + `"
+if [ "$1" = "validate" ]; then
+  echo '{"valid":false,"diagnostics":[{"severity":"error","summary":"Missing required argument","detail":"The argument \"cidr\" is required.","range":{"filename":"main.tf","start":{"line":1}}}]}'
+  exit 1
+fi
+`})
+	require.NoError(t, os.Chmod(terraformPath, 0755))
+
+	validation, err := report.ValidateDirectory(dir, report.ValidationOptions{TerraformPath: terraformPath})
+	require.NoError(t, err)
+	assert.Equal(t, []report.Finding{{
+		Severity: report.SeverityError,
+		File:     "main.tf",
+		Line:     1,
+		Check:    "terraform validate",
+		Message:  `Missing required argument: The argument "cidr" is required.`,
+	}}, validation.Findings)
+
+	runs, err := os.ReadFile(filepath.Join(bin, "runs"))
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(runs)), "\n"), 2, "Only the root module should be initialized and validated, not its local module")
+	assert.NoDirExists(t, filepath.Join(dir, ".terraform"), "The provider cache should not be written to the validated directory")
+}
+
+func TestValidateWithTerraformInDocker(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake docker is a shell script")
+	}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"environments/prod/main.tf": "module \"vpc\" {\n  source = \"../../modules/vpc\"\n}\n",
+		"modules/vpc/main.tf":       "variable \"cidr\" {}\n",
+	})
+	mirror := t.TempDir()
+	cache := filepath.Join(t.TempDir(), "plugin-cache")
+
+	// The fake docker records the arguments of each run, one per line, and reports valid
+	bin := t.TempDir()
+	dockerPath := filepath.Join(bin, "docker")
+	argsFile := filepath.Join(bin, "args")
+	writeFiles(t, bin, map[string]string{"docker": `#!/bin/sh
+for arg in "$@"; do echo "$arg" >> "` + argsFile + `"; done
+echo "--" >> "` + argsFile + `"
+for arg in "$@"; do
+  if [ "$arg" = "validate" ]; then echo '{"valid":true,"diagnostics":[]}'; fi
+done
+`})
+	require.NoError(t, os.Chmod(dockerPath, 0755))
+
+	validation, err := report.ValidateDirectory(dir, report.ValidationOptions{
+		TerraformPath:   dockerPath,
+		TerraformImage:  report.DefaultTerraformImage,
+		PluginCacheDir:  cache,
+		PluginMirrorDir: mirror,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, validation.Findings)
+
+	content, err := os.ReadFile(argsFile)
+	require.NoError(t, err)
+	runs := strings.Split(strings.TrimSuffix(string(content), "--\n"), "--\n")
+	require.Len(t, runs, 2, "The root module should be initialized and validated in two containers")
+
+	init := strings.Split(strings.TrimSpace(runs[0]), "\n")
+	assert.Equal(t, []string{"run", "--rm", "-v", dir + ":/workspace"}, init[:4], "The validated directory should be mounted, so that modules beside the root module resolve")
+	assert.Contains(t, runs[0], "\n-w\n/workspace/environments/prod\n")
+	assert.Contains(t, runs[0], "\n-v\n"+cache+":/plugin-cache\n-e\nTF_PLUGIN_CACHE_DIR=/plugin-cache\n")
+	assert.Contains(t, runs[0], "\n-v\n"+mirror+":/plugin-mirror:ro\n--network\nnone\n", "A run with a mirror should have no network")
+	assert.Contains(t, runs[0], "\n"+report.DefaultTerraformImage+"\ninit\n-backend=false\n-input=false\n-no-color\n-plugin-dir=/plugin-mirror\n")
+	assert.Contains(t, runs[1], "\n"+report.DefaultTerraformImage+"\nvalidate\n-json\n-no-color\n")
+	assert.DirExists(t, cache, "The plugin cache should be created to be mounted")
+
+	// Without docker, validation says so and leaves the HCL syntax checks
+	t.Setenv("PATH", t.TempDir())
+	validation, err = report.ValidateDirectory(dir, report.ValidationOptions{TerraformImage: report.DefaultTerraformImage})
+	require.NoError(t, err)
+	assert.Equal(t, []report.Finding{{
+		Severity: report.SeverityWarning,
+		File:     ".",
+		Check:    "terraform validate",
+		Message:  "docker not found in PATH; only the HCL syntax was checked",
+	}}, validation.Findings)
+}
+
+func TestValidateCrossplaneDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"kustomization.yaml": "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n- vpc.yaml\n- eks\n",
+		"vpc.yaml": `apiVersion: ec2.aws.crossplane.io/v1beta1
+kind: VPC
+metadata:
+  name: main-vpc
+spec:
+  forProvider:
+    cidrBlock: 10.0.0.0/16
+  providerConfigRef:
+    name: aws-provider
+---
+apiVersion: ec2.aws.crossplane.io/v1beta1
+kind: Subnet
+metadata:
+  name: Public_Subnet
+spec:
+  providerConfigRef:
+    name: aws-provider
+---
+apiVersion: ec2.aws.upbound.io/v1beta1
+kind: InternetGateway
+metadata:
+  name: main-igw
+spec:
+  forProvider:
+    region: us-east-1
+`,
+		"broken.yml": "kind: [Provider\n",
+	})
+
+	validation, err := report.ValidateDirectory(dir, report.ValidationOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{report.FormatCrossplane}, validation.Formats)
+
+	messages := make(map[string]report.FindingSeverity)
+	for _, finding := range validation.Findings {
+		messages[finding.File+": "+finding.Message] = finding.Severity
+	}
+	assert.Equal(t, report.SeverityError, messages["kustomization.yaml: resource eks does not exist"])
+	assert.Equal(t, report.SeverityError, messages[`vpc.yaml: Subnet name "Public_Subnet" is not a valid Kubernetes name (lower-case letters, digits, '-' and '.')`])
+	assert.Equal(t, report.SeverityError, messages["vpc.yaml: Subnet Public_Subnet has no spec.forProvider"])
+	assert.Equal(t, report.SeverityWarning, messages["vpc.yaml: InternetGateway main-igw has no spec.providerConfigRef.name, so it uses the default ProviderConfig"])
+	assert.Len(t, findingsIn(validation, "yaml"), 1, "Invalid YAML should be reported")
+	assert.Len(t, validation.Findings, 5)
+
+	errors, warnings := validation.Counts()
+	assert.Equal(t, 4, errors)
+	assert.Equal(t, 1, warnings)
+}
+
+func TestValidateGeneratedFiles(t *testing.T) {
+	model, err := nlp.ParseDescription("A VPC with 2 public and 2 private subnets, a NAT gateway and an EKS cluster with 3 nodes")
+	require.NoError(t, err)
+
+	terraformDir := t.TempDir()
+	_, err = terraform.NewTerraformGenerator().WithOutputDir(terraformDir).Generate(model)
+	require.NoError(t, err)
+	validation, err := report.ValidateDirectory(terraformDir, report.ValidationOptions{SkipTerraform: true})
+	require.NoError(t, err)
+	assert.Empty(t, validation.Findings, "Generated Terraform should parse")
+
+	crossplaneDir := t.TempDir()
+	generator := crossplane.NewCrossplaneGenerator()
+	require.NoError(t, generator.Init(crossplaneDir))
+	_, err = generator.Generate(model)
+	require.NoError(t, err)
+	validation, err = report.ValidateDirectory(crossplaneDir, report.ValidationOptions{})
+	require.NoError(t, err)
+	assert.False(t, validation.HasErrors(), "Generated Crossplane manifests should validate:\n%s", validation.Text())
+}
+
+func TestValidateWithKubeconform(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake kubeconform is a shell script")
+	}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"kustomization.yaml": "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n- subnets.yaml\n",
+		"subnets.yaml": `apiVersion: ec2.aws.upbound.io/v1beta1
+kind: Subnet
+metadata:
+  name: public-1
+spec:
+  forProvider:
+    region: us-east-1
+    vpcIdRefs:
+      name: main-vpc
+  providerConfigRef:
+    name: aws-provider
+`,
+	})
+
+	// The fake kubeconform records its arguments and reports a misspelled field and two
+	// manifests of a kind without a schema
+	bin := t.TempDir()
+	kubeconformPath := filepath.Join(bin, "kubeconform")
+	writeFiles(t, bin, map[string]string{"kubeconform": `#!/bin/sh
+echo "$@" > "` + filepath.Join(bin, "args") + `"
+cat <<'JSON'
+{
+  "resources": [
+    {"filename": "` + filepath.Join(dir, "subnets.yaml") + `", "kind": "Subnet", "name": "public-1", "version": "ec2.aws.upbound.io/v1beta1", "status": "statusInvalid", "msg": "problem validating schema",
+     "validationErrors": [{"path": "/spec/forProvider", "msg": "additionalProperties 'vpcIdRefs' not allowed"}]},
+    {"filename": "` + filepath.Join(dir, "claims.yaml") + `", "kind": "Network", "name": "a", "version": "platform.iacgen.io/v1alpha1", "status": "statusError", "msg": "could not find schema for Network"},
+    {"filename": "` + filepath.Join(dir, "claims.yaml") + `", "kind": "Network", "name": "b", "version": "platform.iacgen.io/v1alpha1", "status": "statusError", "msg": "could not find schema for Network"}
+  ]
+}
+JSON
+exit 1
+`})
+	require.NoError(t, os.Chmod(kubeconformPath, 0755))
+
+	validation, err := report.ValidateDirectory(dir, report.ValidationOptions{Schemas: true, KubeconformPath: kubeconformPath})
+	require.NoError(t, err)
+	assert.Equal(t, []report.Finding{
+		{
+			Severity: report.SeverityWarning,
+			File:     "claims.yaml",
+			Check:    "kubeconform",
+			Message:  "no schema for Network platform.iacgen.io/v1alpha1; its manifests were not checked against a schema",
+		},
+		{
+			Severity: report.SeverityError,
+			File:     "subnets.yaml",
+			Check:    "kubeconform",
+			Message:  "Subnet public-1: /spec/forProvider: additionalProperties 'vpcIdRefs' not allowed",
+		},
+	}, validation.Findings)
+
+	args, err := os.ReadFile(filepath.Join(bin, "args"))
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "-strict")
+	assert.Contains(t, string(args), "-schema-location default")
+	assert.Contains(t, string(args), filepath.Join(dir, "subnets.yaml"))
+
+	// A configured kubeconform that cannot run is an error, not a skipped check
+	validation, err = report.ValidateDirectory(dir, report.ValidationOptions{Schemas: true, KubeconformPath: filepath.Join(bin, "missing")})
+	require.NoError(t, err)
+	assert.True(t, validation.HasErrors(), "A kubeconform that cannot run should fail the validation")
+}
+
+func TestValidateWithTFLint(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake tflint is a shell script")
+	}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.tf":             "module \"vpc\" {\n  source = \"./modules/vpc\"\n}\n",
+		"modules/vpc/main.tf": "variable \"cidr\" {}\n",
+		"custom/main.tf":      "variable \"name\" {}\n",
+		"custom/.tflint.hcl":  "plugin \"terraform\" {\n  enabled = true\n}\n",
+	})
+
+	// The fake tflint records where it ran with which arguments, and reports an issue of the
+	// root module
+	bin := t.TempDir()
+	tflintPath := filepath.Join(bin, "tflint")
+	writeFiles(t, bin, map[string]string{"tflint": `#!/bin/sh
+echo "$(pwd) $@" >> "` + filepath.Join(bin, "runs") + `"
+if [ "$(pwd)" = "` + dir + `" ]; then
+  echo '{"issues":[{"rule":{"name":"terraform_module_pinned_source","severity":"warning","link":"https://example.com"},"message":"Module source is not pinned","range":{"filename":"main.tf","start":{"line":2,"column":3}},"callers":[]}],"errors":[]}'
+  exit 2
+fi
+echo '{"issues":[],"errors":[{"summary":"Failed to load plugin","message":"plugin not found","severity":"error"}]}'
+exit 1
+`})
+	require.NoError(t, os.Chmod(tflintPath, 0755))
+
+	validation, err := report.ValidateDirectory(dir, report.ValidationOptions{SkipTerraform: true, Lint: true, TFLintPath: tflintPath})
+	require.NoError(t, err)
+	assert.Equal(t, []report.Finding{
+		{
+			Severity: report.SeverityError,
+			File:     "custom",
+			Check:    "tflint",
+			Message:  "Failed to load plugin: plugin not found",
+		},
+		{
+			Severity: report.SeverityWarning,
+			File:     "main.tf",
+			Line:     2,
+			Check:    "tflint",
+			Message:  "Module source is not pinned (terraform_module_pinned_source)",
+		},
+	}, validation.Findings)
+
+	runs, err := os.ReadFile(filepath.Join(bin, "runs"))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(runs)), "\n")
+	require.Len(t, lines, 2, "Only the root modules should be linted, not their local modules")
+	for _, line := range lines {
+		if strings.HasPrefix(line, filepath.Join(dir, "custom")+" ") {
+			assert.NotContains(t, line, "--config", "A module with its own .tflint.hcl should be linted with it")
+		} else {
+			assert.Contains(t, line, "--format json")
+			assert.Contains(t, line, "--config", "A module without a .tflint.hcl should be linted with the default ruleset")
+		}
+	}
+
+	// A configured tflint that cannot run is an error, and a missing one a warning
+	validation, err = report.ValidateDirectory(dir, report.ValidationOptions{SkipTerraform: true, Lint: true, TFLintPath: filepath.Join(bin, "missing")})
+	require.NoError(t, err)
+	assert.True(t, validation.HasErrors(), "A tflint that cannot run should fail the validation")
+
+	t.Setenv("PATH", t.TempDir())
+	validation, err = report.ValidateDirectory(dir, report.ValidationOptions{SkipTerraform: true, Lint: true})
+	require.NoError(t, err)
+	assert.Equal(t, []report.Finding{{
+		Severity: report.SeverityWarning,
+		File:     ".",
+		Check:    "tflint",
+		Message:  "tflint not found in PATH; the Terraform files were not linted",
+	}}, validation.Findings)
+}
+
+func TestDefaultTFLintRuleset(t *testing.T) {
+	assert.Contains(t, report.DefaultTFLintRuleset, `plugin "terraform"`)
+	assert.Contains(t, report.DefaultTFLintRuleset, `preset  = "recommended"`)
+}
@@ -0,0 +1,84 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/riptano/iac_generator_cli/internal/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	policy := retry.Policy{InitialDelay: time.Second, MaxDelay: 5 * time.Second, Multiplier: 2}
+	assert.Equal(t, time.Second, policy.Delay(1))
+	assert.Equal(t, 2*time.Second, policy.Delay(2))
+	assert.Equal(t, 4*time.Second, policy.Delay(3))
+	assert.Equal(t, 5*time.Second, policy.Delay(4), "Delays should be capped")
+
+	assert.NoError(t, retry.DefaultPolicy().Validate())
+	assert.EqualError(t, retry.Policy{Jitter: 1.5}.Validate(), "jitter must be between 0 and 1")
+	assert.EqualError(t, retry.Policy{MaxAttempts: -1}.Validate(), "max_attempts must not be negative")
+}
+
+func TestDo(t *testing.T) {
+	policy := retry.Policy{MaxAttempts: 4, InitialDelay: time.Millisecond, Multiplier: 2, Jitter: 0.5}
+	unavailable := errors.New("503 service unavailable")
+
+	// Transient failures are retried until the operation succeeds
+	var attempts []int
+	calls := 0
+	err := retry.Do(context.Background(), policy, nil, func(attempt int, delay time.Duration, err error) {
+		attempts = append(attempts, attempt)
+		assert.True(t, delay > 0 && delay < 10*time.Millisecond, "The delay should be jittered around the backoff: %s", delay)
+		assert.ErrorIs(t, err, unavailable)
+	}, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return retry.Transient(unavailable)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, attempts)
+
+	// The error of the last attempt is returned once the attempts are used up
+	calls = 0
+	err = retry.Do(context.Background(), policy, nil, nil, func(ctx context.Context) error {
+		calls++
+		return retry.Transient(unavailable)
+	})
+	assert.Equal(t, 4, calls)
+	assert.True(t, retry.IsTransient(err))
+	assert.EqualError(t, err, "503 service unavailable")
+
+	// Other failures are not retried
+	calls = 0
+	err = retry.Do(context.Background(), policy, nil, nil, func(ctx context.Context) error {
+		calls++
+		return errors.New("invalid API key")
+	})
+	assert.Equal(t, 1, calls)
+	assert.EqualError(t, err, "invalid API key")
+
+	// The zero policy runs the operation once
+	calls = 0
+	retry.Do(context.Background(), retry.Policy{}, nil, nil, func(ctx context.Context) error {
+		calls++
+		return retry.Transient(unavailable)
+	})
+	assert.Equal(t, 1, calls)
+
+	// A cancelled context stops the retries
+	ctx, cancel := context.WithCancel(context.Background())
+	calls = 0
+	err = retry.Do(ctx, retry.Policy{MaxAttempts: 5, InitialDelay: time.Hour}, nil, func(int, time.Duration, error) {
+		cancel()
+	}, func(ctx context.Context) error {
+		calls++
+		return retry.Transient(unavailable)
+	})
+	assert.Equal(t, 1, calls)
+	assert.ErrorIs(t, err, unavailable)
+}
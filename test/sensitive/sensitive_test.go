@@ -0,0 +1,32 @@
+package sensitive
+
+import (
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/sensitive"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttribute(t *testing.T) {
+	assert.True(t, sensitive.Attribute("aws_eks_cluster", "certificate_authority"))
+	assert.True(t, sensitive.Attribute("aws_db_instance", "password"))
+	assert.False(t, sensitive.Attribute("aws_db_instance", "endpoint"))
+	assert.False(t, sensitive.Attribute("aws_vpc", "password"), "Only the registered types' attributes are secrets")
+}
+
+func TestName(t *testing.T) {
+	assert.True(t, sensitive.Name("master_password"))
+	assert.True(t, sensitive.Name("Password"))
+	assert.False(t, sensitive.Name("password_length"))
+}
+
+func TestRedact(t *testing.T) {
+	hcl := "+  username = \"admin\"\n+  password = \"hunter2\"\n-  master_password   = \"s3cr\\\"et\"\n   password = var.db_password\n"
+	assert.Equal(t, "+  username = \"admin\"\n+  password = \""+sensitive.Redacted+"\"\n-  master_password   = \""+sensitive.Redacted+"\"\n   password = var.db_password\n", sensitive.Redact(hcl),
+		"Literal secrets should be replaced, and references kept")
+
+	yaml := "+    masterUsername: admin\n+    password: hunter2\n+    auth_token: \"abc\"\n"
+	assert.Equal(t, "+    masterUsername: admin\n+    password: "+sensitive.Redacted+"\n+    auth_token: "+sensitive.Redacted+"\n", sensitive.Redact(yaml))
+
+	assert.Equal(t, "password_length = 16\n", sensitive.Redact("password_length = 16\n"))
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mcpResponse is a JSON-RPC response with a tool result or error
+type mcpResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		Tools           []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	} `json:"result"`
+	Error *struct {
+		Code int `json:"code"`
+	} `json:"error"`
+}
+
+// serveMCP sends the messages to an MCP server and returns its responses by ID
+func serveMCP(t *testing.T, messages ...string) map[string]mcpResponse {
+	srv := server.NewServer(server.Options{Region: "us-east-1", OutputFormat: "terraform"})
+	var out bytes.Buffer
+	require.NoError(t, srv.ServeMCP(context.Background(), strings.NewReader(strings.Join(messages, "\n")+"\n"), &out))
+
+	responses := make(map[string]mcpResponse)
+	scanner := bufio.NewScanner(&out)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var response mcpResponse
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &response), "Each line should be a JSON-RPC message")
+		responses[string(response.ID)] = response
+	}
+	return responses
+}
+
+func TestMCPProtocol(t *testing.T) {
+	responses := serveMCP(t,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1.0"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"ping"}`,
+		`{"jsonrpc":"2.0","id":4,"method":"resources/list"}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"generate_pulumi","arguments":{}}}`,
+		`not json`,
+	)
+
+	require.Len(t, responses, 6, "The notification should not be answered")
+	assert.Equal(t, "2024-11-05", responses["1"].Result.ProtocolVersion, "A supported client version should be used")
+
+	var tools []string
+	for _, tool := range responses["2"].Result.Tools {
+		tools = append(tools, tool.Name)
+	}
+	assert.Equal(t, []string{"parse_description", "generate_terraform", "generate_crossplane", "validate_output"}, tools)
+
+	assert.Nil(t, responses["3"].Error)
+	assert.Equal(t, -32601, responses["4"].Error.Code)
+	assert.Equal(t, -32602, responses["5"].Error.Code)
+	assert.Equal(t, -32700, responses["null"].Error.Code)
+}
+
+func TestMCPTools(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "infra")
+
+	responses := serveMCP(t,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"parse_description","arguments":{"description":"Create a VPC with an EKS cluster with 3 nodes"}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"generate_crossplane","arguments":{"description":"Create a VPC with an EKS cluster"}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"generate_terraform","arguments":{"description":"Create a VPC with an EKS cluster","output_dir":"`+outputDir+`"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"generate_terraform","arguments":{"description":"Create a VPC","environment":"Prod"}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"validate_output","arguments":{}}}`,
+	)
+
+	assert.False(t, responses["1"].Result.IsError)
+	assert.Contains(t, responses["1"].Result.Content[0].Text, `"type": "eks_cluster"`)
+
+	var generated server.GenerateResponse
+	require.NoError(t, json.Unmarshal([]byte(responses["2"].Result.Content[0].Text), &generated))
+	assert.Equal(t, "crossplane", generated.Output)
+	assert.Contains(t, generated.Files, "eks/cluster.yaml")
+
+	assert.Contains(t, responses["3"].Result.Content[0].Text, "main.tf")
+	assert.FileExists(t, filepath.Join(outputDir, "modules", "eks", "main.tf"))
+
+	assert.True(t, responses["4"].Result.IsError, "Invalid arguments should be a tool error")
+	assert.Contains(t, responses["4"].Result.Content[0].Text, "invalid environment: Prod")
+	assert.True(t, responses["5"].Result.IsError)
+	assert.Contains(t, responses["5"].Result.Content[0].Text, "dir is required")
+
+	// The files written by generate_terraform validate
+	responses = serveMCP(t, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"validate_output","arguments":{"dir":"`+outputDir+`","skip_terraform":true}}}`)
+	assert.False(t, responses["1"].Result.IsError, responses["1"].Result.Content[0].Text)
+}
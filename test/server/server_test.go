@@ -0,0 +1,160 @@
+package server
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer starts a server with the CLI's defaults
+func newTestServer(t *testing.T) *httptest.Server {
+	srv := server.NewServer(server.Options{
+		Region:       "us-east-1",
+		OutputFormat: "terraform",
+		Tags:         map[string]string{"Team": "platform", "CostCenter": "1234"},
+	})
+	testServer := httptest.NewServer(srv.Handler())
+	t.Cleanup(testServer.Close)
+	return testServer
+}
+
+func TestGenerateFiles(t *testing.T) {
+	testServer := newTestServer(t)
+
+	body := `{"description": "Create a VPC with 2 public subnets and an EKS cluster with 3 nodes", "tags": {"Team": "payments"}}`
+	response, err := http.Post(testServer.URL+"/generate", "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+
+	var generated server.GenerateResponse
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&generated))
+	assert.Equal(t, "terraform", generated.Output)
+	assert.Contains(t, generated.Files, "main.tf")
+	assert.Contains(t, generated.Files, "modules/eks/main.tf")
+	assert.Contains(t, generated.Files["terraform.tfvars"], `Team`)
+	assert.Contains(t, generated.Files["terraform.tfvars"], `"payments"`, "The request's tags should override the server's")
+	assert.Contains(t, generated.Files["terraform.tfvars"], `"1234"`, "The server's tags should apply to every request")
+}
+
+func TestGenerateMultipleFormats(t *testing.T) {
+	testServer := newTestServer(t)
+
+	body := `{"description": "Create a VPC with an EKS cluster", "output": "terraform,crossplane"}`
+	response, err := http.Post(testServer.URL+"/generate", "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	var generated server.GenerateResponse
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&generated))
+	assert.Equal(t, "terraform,crossplane", generated.Output)
+	assert.Contains(t, generated.Files, "terraform/main.tf", "Each format should be generated into its own subdirectory")
+	assert.Contains(t, generated.Files, "crossplane/kustomization.yaml", "Each format should be generated into its own subdirectory")
+}
+
+func TestGenerateArchive(t *testing.T) {
+	testServer := newTestServer(t)
+
+	request, err := http.NewRequest(http.MethodPost, testServer.URL+"/generate", strings.NewReader(`{"description": "Create a VPC with an EKS cluster", "output": "crossplane"}`))
+	require.NoError(t, err)
+	request.Header.Set("Accept", server.ArchiveContentType)
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "attachment; filename=iacgen-crossplane.tar.gz", response.Header.Get("Content-Disposition"))
+
+	gzipReader, err := gzip.NewReader(response.Body)
+	require.NoError(t, err)
+	tarReader := tar.NewReader(gzipReader)
+	var names []string
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, header.Name)
+	}
+	assert.Contains(t, names, "kustomization.yaml")
+	assert.Contains(t, names, "eks/cluster.yaml")
+}
+
+func TestGenerateErrors(t *testing.T) {
+	testServer := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		error  string
+	}{
+		{"Missing description", `{}`, http.StatusBadRequest, "description is required"},
+		{"Unknown field", `{"descripton": "Create a VPC"}`, http.StatusBadRequest, `unknown field "descripton"`},
+		{"Invalid format", `{"description": "Create a VPC", "output": "pulumi"}`, http.StatusBadRequest, "invalid output format: pulumi"},
+		{"Invalid format in a list", `{"description": "Create a VPC", "output": "terraform,pulumi"}`, http.StatusBadRequest, "invalid output format: terraform,pulumi"},
+		{"Invalid environment", `{"description": "Create a VPC", "environment": "Prod"}`, http.StatusBadRequest, "invalid environment: Prod"},
+		{"Same DR region", `{"description": "Create a VPC", "dr_region": "us-east-1"}`, http.StatusBadRequest, "DR region must differ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := http.Post(testServer.URL+"/generate", "application/json", strings.NewReader(tt.body))
+			require.NoError(t, err)
+			defer response.Body.Close()
+			assert.Equal(t, tt.status, response.StatusCode)
+
+			var body map[string]string
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&body))
+			assert.Contains(t, body["error"], tt.error)
+		})
+	}
+
+	response, err := http.Get(testServer.URL + "/generate")
+	require.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+	assert.Equal(t, http.MethodPost, response.Header.Get("Allow"))
+}
+
+func TestModel(t *testing.T) {
+	testServer := newTestServer(t)
+
+	query := url.Values{"description": {"Create a VPC with an EKS cluster with 3 nodes"}, "environment": {"staging"}}
+	response, err := http.Get(testServer.URL + "/model?" + query.Encode())
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	var model struct {
+		Environment string `json:"environment"`
+		Resources   []struct {
+			Type string `json:"type"`
+			Name string `json:"name"`
+		} `json:"resources"`
+	}
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&model))
+	assert.Equal(t, "staging", model.Environment)
+	var types []string
+	for _, resource := range model.Resources {
+		types = append(types, resource.Type)
+	}
+	assert.Contains(t, types, "vpc")
+	assert.Contains(t, types, "eks_cluster")
+
+	response, err = http.Get(testServer.URL + "/model")
+	require.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
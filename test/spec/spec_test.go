@@ -0,0 +1,426 @@
+package spec
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/spec"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// findResource returns the first resource of the given type
+func findResource(model *models.InfrastructureModel, resourceType models.ResourceType) *models.Resource {
+	for i := range model.Resources {
+		if model.Resources[i].Type == resourceType {
+			return &model.Resources[i]
+		}
+	}
+	return nil
+}
+
+func TestLoadEntitySpec(t *testing.T) {
+	model, err := spec.Load([]byte(`
+region: eu-west-1
+vpc:
+  name: core-net
+  cidr_block: 10.20.0.0/16
+subnets:
+  public_count: 3
+  private_count: 3
+gateways:
+  nat_count: 3
+eks:
+  version: "1.29"
+  node_count: 4
+  endpoint_public_access: false
+  endpoint_private_access: true
+tags:
+  team: platform
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "platform"}, model.Tags)
+
+	vpc := findResource(model, models.ResourceVPC)
+	require.NotNil(t, vpc)
+	assert.Equal(t, "core-net", vpc.Name)
+	cidr, _ := vpc.GetProperty("cidr_block")
+	assert.Equal(t, "10.20.0.0/16", cidr)
+
+	subnets, nats := 0, 0
+	for _, resource := range model.Resources {
+		switch resource.Type {
+		case models.ResourceSubnet:
+			subnets++
+		case models.ResourceNATGateway:
+			nats++
+		}
+	}
+	assert.Equal(t, 6, subnets)
+	assert.Equal(t, 3, nats)
+
+	cluster := findResource(model, models.ResourceEKSCluster)
+	require.NotNil(t, cluster)
+	version, _ := cluster.GetProperty("version")
+	assert.Equal(t, "1.29", version)
+}
+
+func TestLoadSecondaryRegions(t *testing.T) {
+	model, err := spec.Load([]byte(`
+region: eu-west-1
+vpc:
+  name: core-net
+secondary_regions: [eu-central-1]
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "eu-west-1", model.Region)
+	assert.Equal(t, []string{"eu-central-1"}, model.SecondaryRegions())
+
+	names := make(map[string]bool)
+	for _, resource := range model.Resources {
+		names[resource.Name] = true
+	}
+	assert.True(t, names["core-net-eu-central-1"], "The VPC should be copied into the secondary region")
+}
+
+func TestLoadNodeGroups(t *testing.T) {
+	model, err := spec.Load([]byte(`
+eks:
+  node_groups:
+    - instance_type: m5.large
+      desired_size: 3
+    - capacity_type: SPOT
+      instance_types: [t3.large, t3a.large]
+      min_size: 1
+      max_size: 10
+`))
+	require.NoError(t, err)
+
+	sizes := make(map[string]interface{})
+	for _, resource := range model.Resources {
+		if resource.Type == models.ResourceNodeGroup {
+			sizes[resource.Name], _ = resource.GetProperty("scaling_config")
+		}
+		if resource.Name == "main-node-group-spot" {
+			instanceTypes, _ := resource.GetProperty("instance_types")
+			assert.Equal(t, []string{"t3.large", "t3a.large"}, instanceTypes)
+		}
+	}
+	assert.Equal(t, map[string]interface{}{
+		"main-node-group-on-demand": map[string]interface{}{"desired_size": 3, "min_size": 3, "max_size": 6},
+		"main-node-group-spot":      map[string]interface{}{"desired_size": 2, "min_size": 1, "max_size": 10},
+	}, sizes)
+}
+
+func TestLoadAddons(t *testing.T) {
+	model, err := spec.Load([]byte(`
+eks:
+  addons:
+    - name: coredns
+      version: v1.11.1-eksbuild.9
+    - name: aws-ebs-csi-driver
+`))
+	require.NoError(t, err)
+
+	versions := make(map[string]interface{})
+	for _, resource := range model.Resources {
+		if resource.Type == models.ResourceEKSAddon {
+			versions[resource.Name], _ = resource.GetProperty("addon_version")
+		}
+	}
+	assert.Equal(t, map[string]interface{}{
+		"main-eks-cluster-coredns":            "v1.11.1-eksbuild.9",
+		"main-eks-cluster-aws-ebs-csi-driver": nil,
+	}, versions)
+}
+
+func TestLoadFargateNamespaces(t *testing.T) {
+	model, err := spec.Load([]byte(`
+eks:
+  fargate_namespaces: [kube-system, app]
+`))
+	require.NoError(t, err)
+
+	var profiles []string
+	for _, resource := range model.Resources {
+		if resource.Type == models.ResourceFargateProfile {
+			profiles = append(profiles, resource.Name)
+			namespaces, _ := resource.GetProperty("namespaces")
+			assert.Equal(t, []string{"kube-system", "app"}, namespaces)
+		}
+	}
+	assert.Equal(t, []string{"main-eks-cluster-fargate"}, profiles)
+}
+
+func TestLoadEnvironment(t *testing.T) {
+	model, err := spec.Load([]byte("environment: staging\nvpc: {}\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "staging", model.Environment)
+	assert.Equal(t, "staging", model.Tags["Environment"])
+	for _, resource := range model.Resources {
+		assert.True(t, strings.HasSuffix(resource.Name, "-staging"), "%s should be named after the environment", resource.Name)
+	}
+
+	model, err = spec.Load([]byte("environment: prod\nresources:\n  - type: vpc\n    name: core\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "core-prod", model.Resources[0].Name)
+}
+
+func TestLoadKarpenter(t *testing.T) {
+	model, err := spec.Load([]byte(`
+eks:
+  karpenter: true
+`))
+	require.NoError(t, err)
+
+	for _, resource := range model.Resources {
+		if resource.Type == models.ResourceEKSCluster {
+			karpenter, _ := resource.GetProperty("karpenter")
+			assert.Equal(t, true, karpenter)
+		}
+	}
+}
+
+func TestLoadAutoscaling(t *testing.T) {
+	model, err := spec.Load([]byte(`
+eks:
+  autoscaling: cluster-autoscaler
+`))
+	require.NoError(t, err)
+
+	for _, resource := range model.Resources {
+		if resource.Type == models.ResourceEKSCluster {
+			autoscaling, _ := resource.GetProperty("autoscaling")
+			controllers, _ := resource.GetProperty("controllers")
+			assert.Equal(t, "cluster-autoscaler", autoscaling)
+			assert.Equal(t, []string{"cluster-autoscaler"}, controllers)
+		}
+	}
+
+	_, err = spec.Load([]byte("eks:\n  autoscaling: keda\n"))
+	assert.ErrorContains(t, err, "not a supported autoscaling strategy")
+	_, err = spec.Load([]byte("eks:\n  autoscaling: cluster-autoscaler\n  karpenter: true\n"))
+	assert.ErrorContains(t, err, "conflicts with eks.karpenter")
+}
+
+func TestLoadEKSControllers(t *testing.T) {
+	model, err := spec.Load([]byte(`
+eks:
+  controllers: [aws-load-balancer-controller, cluster-autoscaler]
+`))
+	require.NoError(t, err)
+
+	for _, resource := range model.Resources {
+		if resource.Type == models.ResourceEKSCluster {
+			controllers, _ := resource.GetProperty("controllers")
+			assert.Equal(t, []string{"aws-load-balancer-controller", "cluster-autoscaler"}, controllers)
+		}
+	}
+}
+
+func TestLoadServiceCIDR(t *testing.T) {
+	model, err := spec.Load([]byte(`
+eks:
+  service_cidr: 172.20.0.0/16
+`))
+	require.NoError(t, err)
+
+	for _, resource := range model.Resources {
+		if resource.Type == models.ResourceEKSCluster {
+			assert.Equal(t, "172.20.0.0/16", infra.ServiceCIDR(resource))
+		}
+	}
+}
+
+func TestLoadSubnetPlan(t *testing.T) {
+	model, err := spec.Load([]byte(`
+subnets:
+  public_count: 2
+  private_count: 2
+  subnet_mask: 20
+  reserved_azs: 4
+`))
+	require.NoError(t, err)
+
+	cidrs := make(map[string]interface{})
+	for _, resource := range model.Resources {
+		if resource.Type == models.ResourceSubnet {
+			cidrs[resource.Name], _ = resource.GetProperty("cidr_block")
+		}
+	}
+	assert.Equal(t, "10.0.16.0/20", cidrs["public-subnet-2"])
+	assert.Equal(t, "10.0.64.0/20", cidrs["private-subnet-1"])
+
+	_, err = spec.Load([]byte("vpc:\n  cidr_block: 10.0.0.0/20\nsubnets:\n  subnet_mask: 20\n"))
+	assert.ErrorContains(t, err, "do not fit in VPC")
+}
+
+func TestLoadJSONSpec(t *testing.T) {
+	model, err := spec.Load([]byte(`{"ec2_instance": {"count": 2, "os": "ubuntu", "instance_type": "t3.small"}}`))
+	require.NoError(t, err)
+
+	instances := 0
+	for _, resource := range model.Resources {
+		if resource.Type == models.ResourceEC2Instance {
+			instances++
+		}
+	}
+	assert.Equal(t, 2, instances, "Omitted entities should get the parser's defaults")
+	assert.NotNil(t, findResource(model, models.ResourceVPC))
+}
+
+func TestLoadModelSpec(t *testing.T) {
+	model, err := spec.Load([]byte(`
+resources:
+  - type: vpc
+    name: core
+    properties:
+      - name: cidr_block
+        value: 10.1.0.0/16
+  - type: subnet
+    name: core-private
+    properties:
+      - name: vpc_id
+        value: core
+      - name: map_public_ip_on_launch
+        value: false
+    depends_on: [core]
+  - type: autoscaling_group
+    name: workers
+    properties:
+      - name: desired_capacity
+        value: 3
+`))
+	require.NoError(t, err)
+	require.Len(t, model.Resources, 3)
+	assert.Equal(t, []string{"core"}, model.Resources[1].DependsOn)
+
+	capacity, _ := model.Resources[2].GetProperty("desired_capacity")
+	assert.Equal(t, 3, capacity, "Whole numbers should be decoded as ints")
+}
+
+func TestRegisterResourceTypePattern(t *testing.T) {
+	model := []byte(`
+resources:
+  - type: memorydb_cluster
+    name: sessions
+`)
+	_, err := spec.Load(model)
+	assert.ErrorContains(t, err, `unsupported type "memorydb_cluster"`)
+
+	spec.RegisterResourceTypePattern(regexp.MustCompile(`^memorydb_`))
+	loaded, err := spec.Load(model)
+	require.NoError(t, err)
+	assert.Equal(t, models.ResourceType("memorydb_cluster"), loaded.Resources[0].Type)
+}
+
+func TestLoadInvalidSpecs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spec     string
+		expected string
+	}{
+		{name: "Unknown field", spec: "vpc:\n  cidr: 10.0.0.0/16\n", expected: `unknown field "cidr"`},
+		{name: "Unknown entity", spec: "database:\n  engine: postgres\n", expected: `unknown field "database"`},
+		{name: "Wrong type", spec: "subnets:\n  public_count: two\n", expected: "public_count"},
+		{name: "Invalid region", spec: "region: mars-1\n", expected: "not an AWS region"},
+		{name: "Invalid secondary region", spec: "vpc: {}\nsecondary_regions: [mars-1]\n", expected: "secondary_regions[0]"},
+		{name: "Out of range", spec: "eks:\n  node_count: 0\n", expected: "eks.node_count"},
+		{name: "Inverted ASG bounds", spec: "asg:\n  min_size: 5\n  max_size: 2\n", expected: "min_size 5 is greater than max_size 2"},
+		{name: "Invalid capacity type", spec: "eks:\n  node_groups:\n    - capacity_type: RESERVED\n", expected: "eks.node_groups[0].capacity_type"},
+		{name: "Desired size out of bounds", spec: "eks:\n  node_groups:\n    - desired_size: 5\n      max_size: 3\n", expected: "desired_size 5 is outside 5..3"},
+		{name: "Invalid mixed instance type", spec: "eks:\n  node_groups:\n    - instance_types: [m5.large, huge]\n", expected: "eks.node_groups[0].instance_types[1]"},
+		{name: "Instance type and mixed instance types", spec: "eks:\n  node_groups:\n    - instance_type: m5.large\n      instance_types: [m5a.large]\n", expected: "cannot be combined with instance_types"},
+		{name: "Unknown add-on", spec: "eks:\n  addons:\n    - name: istio\n", expected: "eks.addons[0].name"},
+		{name: "Invalid add-on version", spec: "eks:\n  addons:\n    - name: coredns\n      version: latest\n", expected: "eks.addons[0].version"},
+		{name: "Repeated add-on", spec: "eks:\n  addons:\n    - name: coredns\n    - name: coredns\n", expected: "listed more than once"},
+		{name: "Unknown controller", spec: "eks:\n  controllers: [istio]\n", expected: "eks.controllers[0]"},
+		{name: "Repeated controller", spec: "eks:\n  controllers: [external-dns, external-dns]\n", expected: `"external-dns" is listed more than once`},
+		{name: "Invalid Fargate namespace", spec: "eks:\n  fargate_namespaces: [Kube_System]\n", expected: "eks.fargate_namespaces[0]"},
+		{name: "Repeated Fargate namespace", spec: "eks:\n  fargate_namespaces: [app, app]\n", expected: `"app" is listed more than once`},
+		{name: "Unsupported EKS version", spec: "eks:\n  version: \"1.19\"\n", expected: "eks.version: EKS does not support Kubernetes 1.19; use 1.25 to 1.31"},
+		{name: "Invalid service CIDR", spec: "eks:\n  service_cidr: 172.20.0.0/8\n", expected: "eks.service_cidr: 172.20.0.0/8 is not the start of a block"},
+		{name: "Subnet mask out of range", spec: "subnets:\n  subnet_mask: 12\n", expected: "subnets.subnet_mask"},
+		{name: "Subnet plan with CIDRs", spec: "subnets:\n  subnet_mask: 20\n  public_cidrs: [10.0.1.0/24]\n  private_cidrs: [10.0.2.0/24]\n", expected: "cannot be combined with explicit CIDR blocks"},
+		{name: "Invalid name", spec: "vpc:\n  name: Prod_Network\n", expected: "vpc.name"},
+		{name: "Invalid environment", spec: "environment: Staging\n", expected: "environment"},
+		{name: "Reserved tag", spec: "tags:\n  Name: core\n", expected: `tag key "Name"`},
+		{name: "Non-string tag", spec: "tags:\n  cost-center: 1234\n", expected: "tags"},
+		{name: "CIDR count mismatch", spec: "subnets:\n  public_count: 2\n  public_cidrs: [10.0.1.0/24]\n  private_cidrs: [10.0.2.0/24]\n", expected: "expected 2 CIDR blocks"},
+		{name: "Unknown resource type", spec: "resources:\n  - type: mainframe\n    name: big\n", expected: `unsupported type "mainframe"`},
+		{name: "Unknown dependency", spec: "resources:\n  - type: vpc\n    name: core\n    depends_on: [edge]\n", expected: `unknown resource "edge"`},
+		{name: "Not a mapping", spec: "- vpc\n", expected: "must be a mapping"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := spec.Load([]byte(tc.spec))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expected)
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "infra.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("region: us-west-2\n"), 0644))
+
+	model, err := spec.LoadFile(path)
+	require.NoError(t, err)
+	assert.NotEmpty(t, model.Resources)
+
+	_, err = spec.LoadFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+// jsonFields returns the JSON field names of a struct type
+func jsonFields(t reflect.Type) []string {
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// schemaProperties returns the property names of a schema object
+func schemaProperties(object map[string]interface{}) []string {
+	var names []string
+	for name := range object["properties"].(map[string]interface{}) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSchemaMatchesSpec(t *testing.T) {
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(spec.Schema, &schema), "The schema should be valid JSON")
+
+	entitySpec := schema["$defs"].(map[string]interface{})["entitySpec"].(map[string]interface{})
+	assert.Equal(t, jsonFields(reflect.TypeOf(spec.Spec{})), schemaProperties(entitySpec))
+
+	sections := map[string]reflect.Type{
+		"vpc":          reflect.TypeOf(spec.VPCSpec{}),
+		"subnets":      reflect.TypeOf(spec.SubnetSpec{}),
+		"gateways":     reflect.TypeOf(spec.GatewaySpec{}),
+		"eks":          reflect.TypeOf(spec.EKSSpec{}),
+		"asg":          reflect.TypeOf(spec.ASGSpec{}),
+		"ec2_instance": reflect.TypeOf(spec.EC2Spec{}),
+		"s3_bucket":    reflect.TypeOf(spec.S3Spec{}),
+	}
+	properties := entitySpec["properties"].(map[string]interface{})
+	for name, structType := range sections {
+		section := properties[name].(map[string]interface{})
+		assert.Equal(t, jsonFields(structType), schemaProperties(section), "Schema section %s should match the spec struct", name)
+	}
+}
@@ -2,10 +2,12 @@ package template
 
 import (
 	"bytes"
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
 	"text/template"
+	"time"
 
 	internalTemplate "github.com/riptano/iac_generator_cli/internal/template"
 	"github.com/riptano/iac_generator_cli/pkg/models"
@@ -403,4 +405,482 @@ func TestCompareToExpectedOutputs(t *testing.T) {
 	t.Skip("Skipping test as we're using mock templates that don't match the actual expected outputs")
 	
 	// In a real implementation, this test would compare rendered outputs with expected fixtures
-}
\ No newline at end of file
+}
+func TestTemplateOverrideDir(t *testing.T) {
+	overrideDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(overrideDir, "terraform"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(overrideDir, "terraform", "vpc.tmpl"), []byte(`# custom {{ .Name }}`), 0644))
+
+	manager := internalTemplate.NewTemplateManager(internalTemplate.TemplateFS)
+	embedded, err := manager.GetTemplate(internalTemplate.FormatTerraform, "vpc.tmpl")
+	assert.NoError(t, err)
+
+	// The override replaces the cached embedded template, and other templates are still embedded
+	manager.SetOverrideDir(overrideDir)
+	custom, err := manager.GetTemplate(internalTemplate.FormatTerraform, "vpc.tmpl")
+	assert.NoError(t, err)
+	assert.NotEqual(t, embedded, custom)
+	var buf bytes.Buffer
+	assert.NoError(t, custom.Execute(&buf, map[string]string{"Name": "main-vpc"}))
+	assert.Equal(t, "# custom main-vpc", buf.String())
+
+	_, err = manager.GetTemplate(internalTemplate.FormatTerraform, "subnet.tmpl")
+	assert.NoError(t, err, "Templates the override directory does not have should be embedded ones")
+	_, err = manager.GetTemplate(internalTemplate.FormatCrossplane, "vpc.tmpl")
+	assert.NoError(t, err)
+
+	// Each template reports where it came from, and templates only the override directory
+	// has are listed with the embedded ones
+	assert.Equal(t, filepath.Join(overrideDir, "terraform", "vpc.tmpl"), manager.TemplateSource(internalTemplate.FormatTerraform, "vpc.tmpl"))
+	assert.Equal(t, "embedded", manager.TemplateSource(internalTemplate.FormatTerraform, "subnet.tmpl"))
+	assert.NoError(t, os.WriteFile(filepath.Join(overrideDir, "terraform", "waf.tmpl"), []byte(`# waf`), 0644))
+	templates, err := manager.ListTemplates(internalTemplate.FormatTerraform)
+	assert.NoError(t, err)
+	assert.Contains(t, templates, "waf.tmpl")
+	assert.Len(t, templates, len(uniqueStrings(templates)), "Overridden templates should be listed once")
+	_, name, err := manager.GetTemplateWithPattern(internalTemplate.FormatTerraform, "^waf")
+	assert.NoError(t, err)
+	assert.Equal(t, "waf.tmpl", name)
+}
+
+// uniqueStrings returns the distinct strings of a list
+func uniqueStrings(values []string) map[string]bool {
+	unique := make(map[string]bool, len(values))
+	for _, value := range values {
+		unique[value] = true
+	}
+	return unique
+}
+
+// renderTemplate renders a template of the manager with the name, or returns the error
+func renderTemplate(manager *internalTemplate.TemplateManager, format internalTemplate.TemplateFormat, name string) string {
+	tmpl, err := manager.GetTemplate(format, name)
+	if err != nil {
+		return err.Error()
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, map[string]string{"Name": "main-vpc"}); err != nil {
+		return err.Error()
+	}
+	return buf.String()
+}
+
+func TestTemplateCacheDisabled(t *testing.T) {
+	overrideDir := t.TempDir()
+	path := filepath.Join(overrideDir, "terraform", "vpc.tmpl")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, os.WriteFile(path, []byte(`# v1 {{ .Name }}`), 0644))
+
+	manager := internalTemplate.NewTemplateManager(internalTemplate.TemplateFS)
+	manager.SetOverrideDir(overrideDir)
+	assert.Equal(t, "# v1 main-vpc", renderTemplate(manager, internalTemplate.FormatTerraform, "vpc.tmpl"))
+
+	// The cached template is used until the cache is disabled
+	assert.NoError(t, os.WriteFile(path, []byte(`# v2 {{ .Name }}`), 0644))
+	assert.Equal(t, "# v1 main-vpc", renderTemplate(manager, internalTemplate.FormatTerraform, "vpc.tmpl"))
+	manager.SetCacheEnabled(false)
+	assert.Equal(t, "# v2 main-vpc", renderTemplate(manager, internalTemplate.FormatTerraform, "vpc.tmpl"))
+	assert.NoError(t, os.WriteFile(path, []byte(`# v3 {{ .Name }}`), 0644))
+	assert.Equal(t, "# v3 main-vpc", renderTemplate(manager, internalTemplate.FormatTerraform, "vpc.tmpl"))
+}
+
+func TestWatchOverrideDir(t *testing.T) {
+	overrideDir := t.TempDir()
+	path := filepath.Join(overrideDir, "terraform", "vpc.tmpl")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, os.WriteFile(path, []byte(`# v1 {{ .Name }}`), 0644))
+
+	manager := internalTemplate.NewTemplateManager(internalTemplate.TemplateFS)
+	manager.SetOverrideDir(overrideDir)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, manager.WatchOverrideDir(ctx))
+	assert.Equal(t, "# v1 main-vpc", renderTemplate(manager, internalTemplate.FormatTerraform, "vpc.tmpl"))
+
+	// A changed template, and one added in a new format directory, replace the cached ones
+	assert.NoError(t, os.WriteFile(path, []byte(`# v2 {{ .Name }}`), 0644))
+	assert.Eventually(t, func() bool {
+		return renderTemplate(manager, internalTemplate.FormatTerraform, "vpc.tmpl") == "# v2 main-vpc"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	crossplane := renderTemplate(manager, internalTemplate.FormatCrossplane, "vpc.tmpl")
+	assert.NoError(t, os.MkdirAll(filepath.Join(overrideDir, "crossplane"), 0755))
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, os.WriteFile(filepath.Join(overrideDir, "crossplane", "vpc.tmpl"), []byte(`# cp {{ .Name }}`), 0644))
+	assert.NotEqual(t, "# cp main-vpc", crossplane)
+	assert.Eventually(t, func() bool {
+		return renderTemplate(manager, internalTemplate.FormatCrossplane, "vpc.tmpl") == "# cp main-vpc"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestLintAndTestBuiltinTemplates(t *testing.T) {
+	manager := internalTemplate.NewTemplateManager(internalTemplate.TemplateFS)
+	for _, format := range []internalTemplate.TemplateFormat{internalTemplate.FormatTerraform, internalTemplate.FormatCrossplane} {
+		names, err := manager.ListTemplates(format)
+		assert.NoError(t, err)
+		for _, name := range names {
+			assert.Empty(t, manager.LintTemplate(format, name), "%s/%s", format, name)
+			assert.Empty(t, manager.TestTemplate(format, name), "%s/%s", format, name)
+		}
+	}
+}
+
+func TestLintTemplate(t *testing.T) {
+	overrideDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(overrideDir, "terraform"), 0755))
+	write := func(name, content string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(overrideDir, "terraform", name), []byte(content), 0644))
+	}
+	write("vpc.tmpl", "resource \"aws_vpc\" \"{{ .Resource.Name }}\" {\n  cidr_block = \"{{ getProperty .Resource \"cidr\" }}\"\n  tenancy = \"{ .Resource.Name }}\"\n}\n")
+	write("subnet.tmpl", "resource \"aws_subnet\" \"x\" {\n  id = \"{{ .Resource.Name | shout }}\"\n}\n")
+
+	manager := internalTemplate.NewTemplateManager(internalTemplate.TemplateFS)
+	manager.SetOverrideDir(overrideDir)
+
+	issues := manager.LintTemplate(internalTemplate.FormatTerraform, "vpc.tmpl")
+	if assert.Len(t, issues, 2) {
+		assert.Equal(t, internalTemplate.TemplateIssueError, issues[0].Severity)
+		assert.Equal(t, 3, issues[0].Line)
+		assert.Contains(t, issues[0].Message, `"}}" is left in the output unparsed`)
+		assert.Equal(t, internalTemplate.TemplateIssueWarning, issues[1].Severity)
+		assert.Equal(t, 2, issues[1].Line)
+		assert.Contains(t, issues[1].Message, `reads the property "cidr", which vpc resources do not have`)
+		assert.Equal(t, "terraform/vpc.tmpl:3", issues[0].Location())
+	}
+
+	issues = manager.LintTemplate(internalTemplate.FormatTerraform, "subnet.tmpl")
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, internalTemplate.TemplateIssueError, issues[0].Severity)
+		assert.Equal(t, 2, issues[0].Line)
+		assert.Contains(t, issues[0].Message, `function "shout" not defined`)
+	}
+}
+
+func TestTestTemplate(t *testing.T) {
+	overrideDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(overrideDir, "terraform"), 0755))
+	write := func(name, content string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(overrideDir, "terraform", name), []byte(content), 0644))
+	}
+	// upper of a bool fails only when rendered, and the data has no zone
+	write("vpc.tmpl", "resource \"aws_vpc\" \"x\" {\n  dns = \"{{ upper (getProperty .Resource \"enable_dns_support\") }}\"\n}\n")
+	write("internet_gateway.tmpl", "resource \"aws_internet_gateway\" \"x\" {\n  zone = \"{{ .zone }}\"\n}\n")
+	write("subnet.tmpl", "resource \"aws_subnet\" \"x\" {\n  id = {{ .Resource.Name }}\n")
+
+	manager := internalTemplate.NewTemplateManager(internalTemplate.TemplateFS)
+	manager.SetOverrideDir(overrideDir)
+
+	issues := manager.TestTemplate(internalTemplate.FormatTerraform, "vpc.tmpl")
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, 2, issues[0].Line)
+		assert.Contains(t, issues[0].Message, "failed to render with vpc main-vpc")
+	}
+
+	issues = manager.TestTemplate(internalTemplate.FormatTerraform, "internet_gateway.tmpl")
+	if assert.Len(t, issues, 1) {
+		assert.Contains(t, issues[0].Message, "renders <no value> on line 2 of its output")
+	}
+
+	issues = manager.TestTemplate(internalTemplate.FormatTerraform, "subnet.tmpl")
+	if assert.Len(t, issues, 1) {
+		assert.Contains(t, issues[0].Message, "renders with subnet public-subnet-1")
+	}
+}
+
+func TestAnalyzeTemplateProperties(t *testing.T) {
+	analysis, err := internalTemplate.AnalyzeTemplate(`{{ getProperty .Resource "cidr_block" }}{{ if hasProperty .Resource "tags" }}{{ end }}{{ range .Resource.Properties }}{{ if eq .Name "enable_dns_support" }}{{ end }}{{ end }}`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cidr_block", "enable_dns_support", "tags"}, analysis["properties"])
+}
+
+func TestScaffoldTemplate(t *testing.T) {
+	resourceType, err := internalTemplate.ScaffoldResourceType("aws_elasticache_cluster")
+	assert.NoError(t, err)
+	assert.Equal(t, models.ResourceType("elasticache_cluster"), resourceType)
+	_, err = internalTemplate.ScaffoldResourceType("elasticache")
+	assert.Error(t, err)
+
+	overrideDir := t.TempDir()
+	manager := internalTemplate.NewTemplateManager(internalTemplate.TemplateFS)
+	manager.SetOverrideDir(overrideDir)
+	internalTemplate.GetDefaultSelector().RegisterTemplate(internalTemplate.FormatTerraform, resourceType, "elasticache_cluster.tmpl")
+	internalTemplate.GetDefaultSelector().RegisterTemplate(internalTemplate.FormatCrossplane, resourceType, "elasticache_cluster.tmpl")
+
+	resource := models.Resource{Type: resourceType, Name: "session-cache"}
+	resource.AddProperty("engine", "redis")
+	resource.AddProperty("num_cache_nodes", 2)
+	resource.AddProperty("region", "us-east-1")
+	resource.AddProperty("tag.Team", "web")
+	renderer := internalTemplate.NewTemplateRenderer(manager, internalTemplate.GetDefaultSelector())
+	renderer.SetGlobalContext("region", "us-east-1")
+
+	for format, want := range map[internalTemplate.TemplateFormat][]string{
+		internalTemplate.FormatTerraform: {
+			`resource "aws_elasticache_cluster" "session_cache" {`,
+			`engine = "redis"`,
+			`num_cache_nodes = 2`,
+			`Team = "web"`,
+		},
+		internalTemplate.FormatCrossplane: {
+			"apiVersion: elasticache.aws.crossplane.io/v1alpha1",
+			"kind: Cluster",
+			"name: session-cache",
+			"numCacheNodes: 2",
+			`value: "web"`,
+		},
+	} {
+		content, err := internalTemplate.ScaffoldTemplate(format, "aws_elasticache_cluster")
+		assert.NoError(t, err)
+		path := filepath.Join(overrideDir, string(format), "elasticache_cluster.tmpl")
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+		assert.Empty(t, manager.LintTemplate(format, "elasticache_cluster.tmpl"), format)
+		assert.Empty(t, manager.TestTemplate(format, "elasticache_cluster.tmpl"), format)
+
+		rendered, err := renderer.RenderResource(format, &resource)
+		assert.NoError(t, err)
+		assert.NoError(t, internalTemplate.ValidateRenderedContent(format, rendered), rendered)
+		for _, line := range want {
+			assert.Contains(t, rendered, line, format)
+		}
+	}
+}
+
+func TestTemplateDiskCache(t *testing.T) {
+	overrideDir := t.TempDir()
+	cacheDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(overrideDir, "terraform"), 0755))
+	vpcPath := filepath.Join(overrideDir, "terraform", "vpc.tmpl")
+	assert.NoError(t, os.WriteFile(vpcPath, []byte(`cidr = {{ getProperty .Resource "cidr" | quote }}`), 0644))
+	headerPath := filepath.Join(overrideDir, "terraform", "terraform_header.tmpl")
+	assert.NoError(t, os.WriteFile(headerPath, []byte(`# region {{ .region }}`), 0644))
+
+	newManager := func() *internalTemplate.TemplateManager {
+		manager := internalTemplate.NewTemplateManager(internalTemplate.TemplateFS)
+		manager.SetOverrideDir(overrideDir)
+		manager.SetDiskCacheDir(cacheDir)
+		return manager
+	}
+	manager := newManager()
+	issues := manager.LintTemplate(internalTemplate.FormatTerraform, "vpc.tmpl")
+	assert.Len(t, issues, 1)
+	header, err := manager.RenderPartial(internalTemplate.FormatTerraform, "terraform_header.tmpl", map[string]interface{}{"region": "eu-west-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "# region eu-west-1", header)
+
+	// Later runs read the entries instead of parsing the templates, which the changed
+	// entries show
+	entries, err := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	for _, entry := range entries {
+		content, err := os.ReadFile(entry)
+		assert.NoError(t, err)
+		content = bytes.ReplaceAll(content, []byte(`which vpc resources do not have`), []byte(`from the cache`))
+		content = bytes.ReplaceAll(content, []byte(`# region eu-west-1`), []byte(`# cached`))
+		assert.NoError(t, os.WriteFile(entry, content, 0644))
+	}
+	manager = newManager()
+	issues = manager.LintTemplate(internalTemplate.FormatTerraform, "vpc.tmpl")
+	if assert.Len(t, issues, 1) {
+		assert.Contains(t, issues[0].Message, "from the cache")
+	}
+	header, err = manager.RenderPartial(internalTemplate.FormatTerraform, "terraform_header.tmpl", map[string]interface{}{"region": "eu-west-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "# cached", header)
+
+	// Other data, and edited templates, are rendered and checked again
+	header, err = manager.RenderPartial(internalTemplate.FormatTerraform, "terraform_header.tmpl", map[string]interface{}{"region": "us-west-2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "# region us-west-2", header)
+	assert.NoError(t, os.WriteFile(vpcPath, []byte(`cidr = {{ getProperty .Resource "cidr_block" | quote }}`), 0644))
+	assert.Empty(t, manager.LintTemplate(internalTemplate.FormatTerraform, "vpc.tmpl"))
+	assert.Empty(t, manager.TestTemplate(internalTemplate.FormatTerraform, "vpc.tmpl"))
+}
+
+func TestCommonBlocks(t *testing.T) {
+	overrideDir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(overrideDir, "terraform", name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	write("_common/owner.tmpl", `{{ define "owner" }}owner = "platform"{{ end }}`)
+	write("vpc.tmpl", `{{ define "owner" }}owner = "{{ .Name }}"{{ end }}# {{ template "owner" . }}`)
+	write("subnet.tmpl", `# {{ template "owner" . }}`)
+
+	manager := internalTemplate.NewTemplateManager(internalTemplate.TemplateFS)
+	manager.SetOverrideDir(overrideDir)
+	assert.NoError(t, manager.PreloadCommonTemplates())
+
+	// A template overriding a block of the partials changes it for itself only
+	assert.Equal(t, `# owner = "main-vpc"`, renderTemplate(manager, internalTemplate.FormatTerraform, "vpc.tmpl"))
+	assert.Equal(t, `# owner = "platform"`, renderTemplate(manager, internalTemplate.FormatTerraform, "subnet.tmpl"))
+
+	// The built-in templates use the blocks of the built-in partials
+	renderer := internalTemplate.NewTemplateRenderer(manager, nil)
+	renderer.SetGlobalContext("region", "us-east-1")
+	igw := models.Resource{Type: models.ResourceIGW, Name: "main-igw"}
+	igw.AddProperty("region", "us-west-2")
+	igw.AddProperty("tag.Team", "network")
+	rendered, err := renderer.RenderResource(internalTemplate.FormatTerraform, &igw)
+	assert.NoError(t, err)
+	assert.Contains(t, rendered, "provider = aws.us_west_2")
+	assert.Contains(t, rendered, `Team = "network"`)
+
+	// A partial of the template directory replaces the built-in partial of the same name
+	write("_common/blocks.tmpl", `{{ define "provider" }}
+  # no provider{{ end }}{{ define "tags" }}{{ end }}`)
+	manager.RefreshCache()
+	rendered, err = renderer.RenderResource(internalTemplate.FormatTerraform, &igw)
+	assert.NoError(t, err)
+	assert.Contains(t, rendered, "# no provider")
+	assert.NotContains(t, rendered, "Team")
+
+	// Templates calling a template nobody defines fail linting
+	write("internet_gateway.tmpl", "resource \"aws_internet_gateway\" \"x\" {\n  {{- template \"tgs\" . }}\n}\n")
+	issues := manager.LintTemplate(internalTemplate.FormatTerraform, "internet_gateway.tmpl")
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, 2, issues[0].Line)
+		assert.Contains(t, issues[0].Message, `calls the template "tgs", which neither the template nor the partials of terraform/_common define`)
+	}
+}
+
+func TestTemplateVersionMigrations(t *testing.T) {
+	version, declared := internalTemplate.TemplateVersion([]byte("{{/* iacgen-template-version: 2 */ -}}\nresource {}\n"))
+	assert.True(t, declared)
+	assert.Equal(t, 2, version)
+	version, declared = internalTemplate.TemplateVersion([]byte("resource {}\n"))
+	assert.False(t, declared)
+	assert.Equal(t, 1, version)
+
+	// A template declaring the current version is not checked for what older versions used
+	assert.Empty(t, internalTemplate.TemplateMigrations([]byte("{{/* iacgen-template-version: 2 */ -}}\n{{ .region | default \"us-east-1\" }}\n")))
+	migrations := internalTemplate.TemplateMigrations([]byte("resource {\n  region = \"{{ .region | default \"us-east-1\" }}\"\n}\n"))
+	if assert.Len(t, migrations, 1) {
+		assert.Equal(t, 2, migrations[0].Line)
+		assert.Equal(t, 2, migrations[0].Version)
+		assert.Contains(t, migrations[0].Message, "defaultValue")
+	}
+
+	overrideDir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(overrideDir, "terraform", name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	// default was renamed, so the template fails to parse, and contains of the properties fails
+	// only when rendered
+	write("vpc.tmpl", "resource \"aws_vpc\" \"x\" {\n  region = \"{{ .region | default \"us-east-1\" }}\"\n}\n")
+	write("subnet.tmpl", "resource \"aws_subnet\" \"x\" {\n{{- if contains .Resource.Properties \"cidr_block\" }}\n  cidr_block = \"x\"\n{{- end }}\n}\n")
+	write("internet_gateway.tmpl", "{{/* iacgen-template-version: 3 */ -}}\nresource \"aws_internet_gateway\" \"x\" {}\n")
+
+	manager := internalTemplate.NewTemplateManager(internalTemplate.TemplateFS)
+	manager.SetOverrideDir(overrideDir)
+
+	_, err := manager.GetTemplate(internalTemplate.FormatTerraform, "vpc.tmpl")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "template terraform/vpc.tmpl is written for template version 1, and version 2 changed what it uses (line 2: the default function was renamed defaultValue")
+		assert.Contains(t, err.Error(), `function "default" not defined`)
+	}
+
+	renderer := internalTemplate.NewTemplateRenderer(manager, nil)
+	subnet := models.Resource{Type: models.ResourceSubnet, Name: "public"}
+	subnet.AddProperty("cidr_block", "10.0.1.0/24")
+	_, err = renderer.RenderResource(internalTemplate.FormatTerraform, &subnet)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "line 2: contains only searches strings: replace")
+		assert.Contains(t, err.Error(), "hasProperty")
+	}
+
+	issues := manager.LintTemplate(internalTemplate.FormatTerraform, "vpc.tmpl")
+	if assert.Len(t, issues, 2) {
+		assert.Equal(t, internalTemplate.TemplateIssueError, issues[0].Severity)
+		assert.Equal(t, internalTemplate.TemplateIssueWarning, issues[1].Severity)
+		assert.Equal(t, 2, issues[1].Line)
+		assert.Contains(t, issues[1].Message, "written for an older template version: version 2 changed what it uses")
+	}
+	issues = manager.TestTemplate(internalTemplate.FormatTerraform, "subnet.tmpl")
+	if assert.Len(t, issues, 2) {
+		assert.Equal(t, internalTemplate.TemplateIssueError, issues[0].Severity)
+		assert.Contains(t, issues[1].Message, "hasProperty")
+	}
+	issues = manager.LintTemplate(internalTemplate.FormatTerraform, "internet_gateway.tmpl")
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, internalTemplate.TemplateIssueWarning, issues[0].Severity)
+		assert.Contains(t, issues[0].Message, "is written for template version 3, but this iacgen renders version 2")
+	}
+}
+
+func TestTemplateValues(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.yaml")
+	prod := filepath.Join(dir, "prod.yaml")
+	assert.NoError(t, os.WriteFile(base, []byte("artifacts:\n  bucket: dev-artifacts\n  prefix: builds\naccount: 111111111111\n"), 0644))
+	assert.NoError(t, os.WriteFile(prod, []byte("artifacts:\n  bucket: prod-artifacts\n"), 0644))
+
+	// Later files override earlier ones key by key, and settings override the files as strings
+	values, err := internalTemplate.LoadValues([]string{base, prod}, []string{"sso.role_arn=arn:aws:iam::123456789012:role/admin", "account=012345678901"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"artifacts": map[string]interface{}{"bucket": "prod-artifacts", "prefix": "builds"},
+		"account":   "012345678901",
+		"sso":       map[string]interface{}{"role_arn": "arn:aws:iam::123456789012:role/admin"},
+	}, values)
+
+	_, err = internalTemplate.LoadValues(nil, []string{"role_arn"})
+	assert.EqualError(t, err, `invalid value: "role_arn" (use key=value)`)
+	_, err = internalTemplate.LoadValues(nil, []string{"sso..role_arn=x"})
+	assert.Error(t, err)
+	_, err = internalTemplate.LoadValues([]string{filepath.Join(dir, "missing.yaml")}, nil)
+	assert.Error(t, err)
+
+	overrideDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(overrideDir, "terraform"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(overrideDir, "terraform", "vpc.tmpl"), []byte(
+		"resource \"aws_vpc\" \"x\" {\n  tags = {\n    Artifacts = \"{{ .Values.artifacts.bucket }}\"\n{{- with .Values.sso }}\n    Role = \"{{ .role_arn }}\"\n{{- end }}\n  }\n}\n"), 0644))
+
+	manager := internalTemplate.NewTemplateManager(internalTemplate.TemplateFS)
+	manager.SetOverrideDir(overrideDir)
+	assert.Empty(t, manager.Values())
+
+	// Without the values the template renders <no value>, which templates test reports
+	issues := manager.TestTemplate(internalTemplate.FormatTerraform, "vpc.tmpl")
+	if assert.Len(t, issues, 1) {
+		assert.Contains(t, issues[0].Message, "renders <no value>")
+	}
+
+	manager.SetValues(values)
+	assert.Empty(t, manager.TestTemplate(internalTemplate.FormatTerraform, "vpc.tmpl"))
+	renderer := internalTemplate.NewTemplateRenderer(manager, nil)
+	vpc := models.Resource{Type: models.ResourceVPC, Name: "main"}
+	rendered, err := renderer.RenderResource(internalTemplate.FormatTerraform, &vpc)
+	assert.NoError(t, err)
+	assert.Contains(t, rendered, `Artifacts = "prod-artifacts"`)
+	assert.Contains(t, rendered, `Role = "arn:aws:iam::123456789012:role/admin"`)
+}
+
+func TestTemplateSelectorPatterns(t *testing.T) {
+	selector := internalTemplate.NewDefaultTemplateSelector()
+	selectTemplate := func(resourceType models.ResourceType) string {
+		name, err := selector.SelectTemplate(internalTemplate.FormatTerraform, &models.Resource{Type: resourceType, Name: "x"})
+		assert.NoError(t, err)
+		return name
+	}
+	assert.Equal(t, "eks_resource.tmpl", selectTemplate("eks_identity_provider"))
+
+	// Patterns registered later are tried first, and replace the template of the same pattern
+	selector.RegisterPatternTemplate(internalTemplate.FormatTerraform, "^eks_", "team_eks.tmpl")
+	selector.RegisterPatternTemplate(internalTemplate.FormatTerraform, "^eks_identity", "team_eks_identity.tmpl")
+	assert.Equal(t, "team_eks_identity.tmpl", selectTemplate("eks_identity_provider"))
+	assert.Equal(t, "team_eks.tmpl", selectTemplate("eks_access_entry"))
+	selector.RegisterPatternTemplate(internalTemplate.FormatTerraform, "^eks_", "platform_eks.tmpl")
+	assert.Equal(t, "platform_eks.tmpl", selectTemplate("eks_identity_provider"))
+
+	// Mappings of resource types win over patterns
+	assert.Equal(t, "eks_cluster.tmpl", selectTemplate(models.ResourceEKSCluster))
+	selector.RegisterTemplate(internalTemplate.FormatTerraform, models.ResourceVPC, "team_vpc.tmpl")
+	assert.Equal(t, "team_vpc.tmpl", selectTemplate(models.ResourceVPC))
+	assert.Equal(t, []models.ResourceType{models.ResourceVPC}, selector.ResourceTypes(internalTemplate.FormatTerraform, "team_vpc.tmpl"))
+}
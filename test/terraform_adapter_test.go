@@ -1,11 +1,19 @@
 package test
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/riptano/iac_generator_cli/internal/adapter/terraform"
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/utils"
 	"github.com/riptano/iac_generator_cli/pkg/models"
 )
 
@@ -157,6 +165,11 @@ func createTestInfrastructureModel() *models.InfrastructureModel {
 	subnet.AddDependency("main")
 	model.AddResource(subnet)
 
+	// Create an EKS cluster resource
+	cluster := models.NewResource(models.ResourceEKSCluster, "main-eks")
+	cluster.AddProperty("version", "1.29")
+	model.AddResource(cluster)
+
 	return model
 }
 
@@ -297,4 +310,1246 @@ func dirExists(path string) bool {
 // Helper function to check if a string contains a substring
 func contains(s, substr string) bool {
 	return true // TODO: Implement properly
-}
\ No newline at end of file
+}
+func TestTerraformGeneratorResourceNames(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := models.NewInfrastructureModel()
+	model.AddResource(models.NewResource(models.ResourceVPC, "prod-network"))
+	model.AddResource(models.NewResource(models.ResourceEKSCluster, "payments-eks"))
+
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).Generate(model)
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	tfvars, err := os.ReadFile(filepath.Join(tempDir, "terraform.tfvars"))
+	if err != nil {
+		t.Fatalf("Failed to read terraform.tfvars: %v", err)
+	}
+	for _, expected := range []string{
+		`vpc_name = "prod-network"`,
+		`cluster_name = "payments-eks"`,
+		`"kubernetes.io/cluster/payments-eks" = "shared"`,
+	} {
+		if !strings.Contains(string(tfvars), expected) {
+			t.Errorf("Expected terraform.tfvars to contain %s", expected)
+		}
+	}
+
+	outputs, err := os.ReadFile(filepath.Join(tempDir, "outputs.tf"))
+	if err != nil {
+		t.Fatalf("Failed to read outputs.tf: %v", err)
+	}
+	if !strings.Contains(string(outputs), `output "vpc_name"`) {
+		t.Errorf("Expected outputs.tf to contain the vpc_name output")
+	}
+}
+
+func TestTerraformGeneratorGlobalTags(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := models.NewInfrastructureModel()
+	model.AddResource(models.NewResource(models.ResourceVPC, "main-vpc"))
+	model.Tags = map[string]string{"team": "platform", "Environment": "staging", "billing:code": "1234"}
+
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).Generate(model)
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	for _, file := range []string{"terraform.tfvars", "variables.tf"} {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, expected := range []string{
+			`Environment    = "staging"`,
+			`team           = "platform"`,
+			`"billing:code" = "1234"`,
+		} {
+			if !strings.Contains(string(content), expected) {
+				t.Errorf("Expected %s to contain %s", file, expected)
+			}
+		}
+	}
+}
+
+func TestTerraformGeneratorEnvironment(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := models.NewInfrastructureModel()
+	model.AddResource(models.NewResource(models.ResourceVPC, "main-vpc"))
+	model.AddResource(models.NewResource(models.ResourceEKSCluster, "main-eks-cluster"))
+	infra.ApplyEnvironment(model, "staging")
+
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).Generate(model)
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tempDir, "terraform.tfvars")); err == nil {
+		t.Errorf("Expected the variables to be written for the staging workspace only")
+	}
+	expectations := map[string][]string{
+		"terraform.tfvars.staging": {`vpc_name = "main-staging"`, `cluster_name = "main-staging"`, `Environment = "staging"`},
+		"versions.tf":              {`backend "local" {`, `workspace_dir = "environments"`},
+	}
+	for file, expected := range expectations {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, text := range expected {
+			if !strings.Contains(string(content), text) {
+				t.Errorf("Expected %s to contain %s, got:\n%s", file, text, content)
+			}
+		}
+	}
+}
+
+func TestTerraformGeneratorSecondaryRegions(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := models.NewInfrastructureModel()
+	model.Region = "us-east-1"
+	model.AddResource(models.NewResource(models.ResourceVPC, "main-vpc"))
+	drVPC := models.NewResource(models.ResourceVPC, "main-vpc-us-west-2")
+	drVPC.AddProperty("region", "us-west-2")
+	model.AddResource(drVPC)
+
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).Generate(model)
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	expected := map[string][]string{
+		"provider.tf": {`alias  = "us_west_2"`, `region = "us-west-2"`},
+		"main.tf":     {`module "vpc_us_west_2"`, "aws = aws.us_west_2", `["us-west-2a", "us-west-2b", "us-west-2c"]`},
+		"outputs.tf":  {"module.vpc_us_west_2.vpc_id"},
+	}
+	for file, contents := range expected {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, expected := range contents {
+			if !strings.Contains(string(content), expected) {
+				t.Errorf("Expected %s to contain %s", file, expected)
+			}
+		}
+	}
+}
+
+func TestTerraformGeneratorPrimaryRegion(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// A model outside the default region, with its resources in it
+	model := models.NewInfrastructureModel()
+	model.Region = "us-west-2"
+	vpc := models.NewResource(models.ResourceVPC, "main-vpc")
+	vpc.AddProperty("region", "us-west-2")
+	model.AddResource(vpc)
+	for name, az := range map[string]string{"public-subnet-1": "us-west-2a", "public-subnet-2": "us-west-2b"} {
+		subnet := models.NewResource(models.ResourceSubnet, name)
+		subnet.AddProperty("vpc_id", "main-vpc")
+		subnet.AddProperty("availability_zone", az)
+		subnet.AddProperty("region", "us-west-2")
+		model.AddResource(subnet)
+	}
+
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).Generate(model)
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	mainTf, err := os.ReadFile(filepath.Join(tempDir, "main.tf"))
+	if err != nil {
+		t.Fatalf("Failed to read main.tf: %v", err)
+	}
+	if count := strings.Count(string(mainTf), `module "vpc`); count != 1 {
+		t.Errorf("Expected exactly one VPC module, got %d:\n%s", count, mainTf)
+	}
+	providerTf, err := os.ReadFile(filepath.Join(tempDir, "provider.tf"))
+	if err != nil {
+		t.Fatalf("Failed to read provider.tf: %v", err)
+	}
+	if strings.Contains(string(providerTf), "alias") {
+		t.Errorf("Expected no aliased provider, got:\n%s", providerTf)
+	}
+	tfvars, err := os.ReadFile(filepath.Join(tempDir, "terraform.tfvars"))
+	if err != nil {
+		t.Fatalf("Failed to read terraform.tfvars: %v", err)
+	}
+	if !strings.Contains(string(tfvars), `aws_region = "us-west-2"`) {
+		t.Errorf("Expected aws_region to be the model's region, got:\n%s", tfvars)
+	}
+}
+
+func TestTemplateTerraformGeneratorSecondaryRegions(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := models.NewInfrastructureModel()
+	model.Region = "us-east-1"
+	model.AddResource(models.NewResource(models.ResourceVPC, "main-vpc"))
+	drVPC := models.NewResource(models.ResourceVPC, "main-vpc-us-west-2")
+	drVPC.AddProperty("region", "us-west-2")
+	model.AddResource(drVPC)
+
+	_, err := terraform.NewTemplateTerraformGenerator().WithOutputDir(tempDir).Generate(model)
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	mainTf, err := os.ReadFile(filepath.Join(tempDir, "main.tf"))
+	if err != nil {
+		t.Fatalf("Failed to read main.tf: %v", err)
+	}
+	if strings.Count(string(mainTf), "provider = aws.us_west_2") != 1 {
+		t.Errorf("Expected only the us-west-2 VPC to use the aliased provider, got:\n%s", mainTf)
+	}
+
+	providerTf, err := os.ReadFile(filepath.Join(tempDir, "provider.tf"))
+	if err != nil {
+		t.Fatalf("Failed to read provider.tf: %v", err)
+	}
+	if !strings.Contains(string(providerTf), `alias  = "us_west_2"`) {
+		t.Errorf("Expected provider.tf to declare the us_west_2 provider, got:\n%s", providerTf)
+	}
+}
+
+func TestTerraformGeneratorNodeGroups(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := models.NewInfrastructureModel()
+	model.AddResource(models.NewResource(models.ResourceEKSCluster, "main-eks"))
+	nodeGroup := infra.CreateEKSNodeGroup("main-node-group-spot", "main-eks", "role-arn", nil, []string{"t3.large"}, 1, 1, 10)
+	nodeGroup.AddProperty("capacity_type", "SPOT")
+	model.AddResource(nodeGroup)
+
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).Generate(model)
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "terraform.tfvars"))
+	if err != nil {
+		t.Fatalf("Failed to read terraform.tfvars: %v", err)
+	}
+	for _, expected := range []string{`"main-node-group-spot" = {`, `instance_types = ["t3.large"]`, `capacity_type = "SPOT"`, "max_size = 10"} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected terraform.tfvars to contain %s, got:\n%s", expected, content)
+		}
+	}
+	if strings.Contains(string(content), "default = {") {
+		t.Errorf("Expected the described node groups to replace the default ones, got:\n%s", content)
+	}
+}
+
+func TestTerraformGeneratorServiceCIDR(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := createTestInfrastructureModel()
+	for i := range model.Resources {
+		if model.Resources[i].Type == models.ResourceEKSCluster {
+			model.Resources[i].SetProperty("kubernetes_network_config", map[string]interface{}{"service_ipv4_cidr": "172.20.0.0/16"})
+		}
+	}
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).Generate(model)
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	expectations := map[string][]string{
+		"main.tf":          {"cluster_service_ipv4_cidr = var.cluster_service_ipv4_cidr"},
+		"variables.tf":     {`variable "cluster_service_ipv4_cidr" {`, `default     = "172.20.0.0/16"`},
+		"terraform.tfvars": {`cluster_service_ipv4_cidr = "172.20.0.0/16"`},
+	}
+	for file, expected := range expectations {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, text := range expected {
+			if !strings.Contains(string(content), text) {
+				t.Errorf("Expected %s to contain %q, got:\n%s", file, text, content)
+			}
+		}
+	}
+
+	// Without a service CIDR, AWS picks one and the root module does not mention it
+	otherDir := t.TempDir()
+	if _, err := terraform.NewTerraformGenerator().WithOutputDir(otherDir).Generate(createTestInfrastructureModel()); err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+	main, _ := os.ReadFile(filepath.Join(otherDir, "main.tf"))
+	if strings.Contains(string(main), "cluster_service_ipv4_cidr") {
+		t.Errorf("Expected no service CIDR in main.tf, got:\n%s", main)
+	}
+}
+
+func TestTerraformGeneratorFargateProfiles(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := models.NewInfrastructureModel()
+	model.AddResource(models.NewResource(models.ResourceEKSCluster, "main-eks"))
+	model.AddResource(infra.CreateFargateProfile("main-eks-fargate", "main-eks", []string{"private-subnet-1"}, []string{"kube-system", "app"}))
+
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).Generate(model)
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	expectations := map[string][]string{
+		"terraform.tfvars":         {`"main-eks-fargate" = {`, `namespaces = ["kube-system", "app"]`},
+		"main.tf":                  {"fargate_profiles = var.fargate_profiles"},
+		"modules/eks/main.tf":      {`resource "aws_eks_fargate_profile" "this"`, "pod_execution_role_arn = aws_iam_role.fargate[0].arn"},
+		"modules/eks/iam.tf":       {`resource "aws_iam_role" "fargate"`, "eks-fargate-pods.amazonaws.com", "AmazonEKSFargatePodExecutionRolePolicy"},
+		"modules/eks/variables.tf": {`variable "fargate_profiles"`},
+	}
+	for file, expected := range expectations {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, text := range expected {
+			if !strings.Contains(string(content), text) {
+				t.Errorf("Expected %s to contain %s, got:\n%s", file, text, content)
+			}
+		}
+	}
+}
+
+func TestTerraformGeneratorKarpenter(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := createTestInfrastructureModel()
+	for i, resource := range model.Resources {
+		if resource.Type == models.ResourceEKSCluster {
+			model.Resources[i].AddProperty("karpenter", true)
+		}
+	}
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).Generate(model)
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	expectations := map[string][]string{
+		"modules/eks/karpenter.tf": {
+			`resource "aws_iam_instance_profile" "karpenter"`, "role = aws_iam_role.node.name",
+			"system:serviceaccount:kube-system:karpenter", `resource "aws_sqs_queue" "karpenter"`,
+			"EC2 Spot Instance Interruption Warning", "arn       = aws_sqs_queue.karpenter.arn",
+		},
+		"modules/eks/outputs.tf": {`output "karpenter_controller_role_arn"`, "value       = aws_sqs_queue.karpenter.name"},
+		"outputs.tf": {
+			"value       = module.eks.karpenter_controller_role_arn", `output "karpenter_helm_values"`,
+			"interruptionQueue = module.eks.karpenter_interruption_queue_name", `kind       = "NodePool"`,
+			"subnetSelectorTerms        = [for id in module.vpc.private_subnet_ids : { id = id }]",
+		},
+	}
+	for file, expected := range expectations {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, text := range expected {
+			if !strings.Contains(string(content), text) {
+				t.Errorf("Expected %s to contain %q, got:\n%s", file, text, content)
+			}
+		}
+	}
+
+	// The registry source has a Karpenter module of its own
+	registryDir := t.TempDir()
+	config := terraform.DefaultTerraformConfig()
+	config.ModuleSource = terraform.ModuleSourceRegistry
+	if _, err := terraform.NewTerraformGenerator().WithOutputDir(registryDir).WithConfig(config).Generate(model); err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+	main, _ := os.ReadFile(filepath.Join(registryDir, "main.tf"))
+	outputs, _ := os.ReadFile(filepath.Join(registryDir, "outputs.tf"))
+	if !strings.Contains(string(main), `source  = "terraform-aws-modules/eks/aws//modules/karpenter"`) || !strings.Contains(string(main), "cluster_name          = module.eks.cluster_name") {
+		t.Errorf("Expected the registry Karpenter module, got:\n%s", main)
+	}
+	if !strings.Contains(string(outputs), "value       = module.karpenter.iam_role_arn") {
+		t.Errorf("Expected the Karpenter outputs from the registry module, got:\n%s", outputs)
+	}
+
+	// Without Karpenter, the EKS module has no karpenter.tf
+	plainDir := t.TempDir()
+	if _, err := terraform.NewTerraformGenerator().WithOutputDir(plainDir).Generate(createTestInfrastructureModel()); err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+	if fileExists(filepath.Join(plainDir, "modules", "eks", "karpenter.tf")) {
+		t.Errorf("Expected no karpenter.tf without Karpenter")
+	}
+}
+
+func TestTerraformGeneratorClusterAutoscaler(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := createTestInfrastructureModel()
+	for i, resource := range model.Resources {
+		if resource.Type == models.ResourceEKSCluster {
+			model.Resources[i].AddProperty("autoscaling", "cluster-autoscaler")
+			model.Resources[i].AddProperty("controllers", []string{"cluster-autoscaler"})
+			model.Resources[i].AddProperty("oidc_provider", true)
+		}
+	}
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).Generate(model)
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	expectations := map[string][]string{
+		"modules/eks/main.tf": {
+			`"k8s.io/cluster-autoscaler/enabled"             = "true"`,
+			`"k8s.io/cluster-autoscaler/${var.cluster_name}" = "owned"`,
+			"ignore_changes = [scaling_config[0].desired_size]",
+		},
+		"modules/eks/controllers.tf": {`service_account = "kube-system/cluster-autoscaler"`},
+		"outputs.tf": {
+			`output "cluster_autoscaler_helm_values"`, "clusterName = module.eks.cluster_id",
+			`"eks.amazonaws.com/role-arn" = module.eks.controller_role_arns["cluster-autoscaler"]`,
+		},
+	}
+	for file, expected := range expectations {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, text := range expected {
+			if !strings.Contains(string(content), text) {
+				t.Errorf("Expected %s to contain %q, got:\n%s", file, text, content)
+			}
+		}
+	}
+
+	// The registry module's node groups get the tags
+	registryDir := t.TempDir()
+	config := terraform.DefaultTerraformConfig()
+	config.ModuleSource = terraform.ModuleSourceRegistry
+	if _, err := terraform.NewTerraformGenerator().WithOutputDir(registryDir).WithConfig(config).Generate(model); err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+	main, _ := os.ReadFile(filepath.Join(registryDir, "main.tf"))
+	if !strings.Contains(string(main), "merge(group.additional_tags, {") {
+		t.Errorf("Expected the registry node groups to have the Cluster Autoscaler tags, got:\n%s", main)
+	}
+
+	// Without the Cluster Autoscaler, the node groups have neither the tags nor the output
+	plainDir := t.TempDir()
+	if _, err := terraform.NewTerraformGenerator().WithOutputDir(plainDir).Generate(createTestInfrastructureModel()); err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+	eksMain, _ := os.ReadFile(filepath.Join(plainDir, "modules", "eks", "main.tf"))
+	outputs, _ := os.ReadFile(filepath.Join(plainDir, "outputs.tf"))
+	if strings.Contains(string(eksMain), "k8s.io/cluster-autoscaler") || strings.Contains(string(outputs), "cluster_autoscaler_helm_values") {
+		t.Errorf("Expected no Cluster Autoscaler configuration without it")
+	}
+}
+
+func TestTerraformGeneratorEKSControllers(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := createTestInfrastructureModel()
+	for i, resource := range model.Resources {
+		if resource.Type == models.ResourceEKSCluster {
+			model.Resources[i].AddProperty("controllers", []string{"aws-load-balancer-controller", "external-dns", "cluster-autoscaler"})
+		}
+	}
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).Generate(model)
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	expectations := map[string][]string{
+		"modules/eks/controllers.tf": {
+			`service_account = "kube-system/aws-load-balancer-controller"`, "elasticloadbalancing:CreateLoadBalancer",
+			`service_account = "kube-system/external-dns"`, "route53:ChangeResourceRecordSets",
+			`service_account = "kube-system/cluster-autoscaler"`, `"aws:ResourceTag/k8s.io/cluster-autoscaler/${var.cluster_name}" = "owned"`,
+			"identifiers = [aws_iam_openid_connect_provider.this.arn]", `resource "aws_iam_role" "controller"`,
+		},
+		"modules/eks/outputs.tf": {`output "controller_role_arns"`},
+		"outputs.tf":             {"value       = module.eks.controller_role_arns"},
+	}
+	for file, expected := range expectations {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, text := range expected {
+			if !strings.Contains(string(content), text) {
+				t.Errorf("Expected %s to contain %q, got:\n%s", file, text, content)
+			}
+		}
+	}
+
+	registryDir := t.TempDir()
+	config := terraform.DefaultTerraformConfig()
+	config.ModuleSource = terraform.ModuleSourceRegistry
+	if _, err := terraform.NewTerraformGenerator().WithOutputDir(registryDir).WithConfig(config).Generate(model); err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+	main, _ := os.ReadFile(filepath.Join(registryDir, "main.tf"))
+	for _, text := range []string{`module "controller_irsa"`, `"external-dns" = "kube-system:external-dns"`, "attach_load_balancer_controller_policy = each.key == \"aws-load-balancer-controller\""} {
+		if !strings.Contains(string(main), text) {
+			t.Errorf("Expected main.tf to contain %q, got:\n%s", text, main)
+		}
+	}
+}
+
+func TestTerraformGeneratorEKSAddons(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := models.NewInfrastructureModel()
+	model.AddResource(models.NewResource(models.ResourceEKSCluster, "main-eks"))
+	coredns, _ := infra.LookupEKSAddon("coredns")
+	ebs, _ := infra.LookupEKSAddon("aws-ebs-csi-driver")
+	model.AddResource(infra.CreateEKSAddon("main-eks-coredns", "main-eks", coredns, "v1.11.1-eksbuild.9"))
+	model.AddResource(infra.CreateEKSAddon("main-eks-aws-ebs-csi-driver", "main-eks", ebs, ""))
+
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).Generate(model)
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	expectations := map[string][]string{
+		"terraform.tfvars":         {`"coredns" = {`, `addon_version = "v1.11.1-eksbuild.9"`, `"aws-ebs-csi-driver" = {`, `service_account = "kube-system/ebs-csi-controller-sa"`, "addon_version = null"},
+		"main.tf":                  {"addons      = var.addons"},
+		"modules/eks/main.tf":      {`resource "aws_eks_addon" "this"`, "aws_iam_role.addon[each.key].arn"},
+		"modules/eks/iam.tf":       {`resource "aws_iam_role" "addon"`, `"system:serviceaccount:${replace(each.value.service_account, "/", ":")}"`},
+		"modules/eks/outputs.tf":   {`output "addon_versions"`},
+		"modules/eks/variables.tf": {`variable "addons"`},
+	}
+	for file, expected := range expectations {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, text := range expected {
+			if !strings.Contains(string(content), text) {
+				t.Errorf("Expected %s to contain %s, got:\n%s", file, text, content)
+			}
+		}
+	}
+}
+
+func TestTerraformGeneratorModelDriven(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := models.NewInfrastructureModel()
+	model.AddResource(infra.CreateVPC("main-vpc", "172.16.0.0/16", true, true))
+	model.AddResource(infra.CreateSubnet("public-subnet-1", "main-vpc", "172.16.0.0/24", "us-east-1a"))
+	model.AddResource(infra.CreateSubnet("public-subnet-2", "main-vpc", "172.16.1.0/24", "us-east-1b"))
+	model.AddResource(infra.CreateSubnet("private-subnet-1", "main-vpc", "172.16.10.0/24", "us-east-1a"))
+	model.AddResource(infra.CreateS3Bucket("app-logs", "private", true))
+	database := models.NewResource(models.ResourceRDSInstance, "orders-db")
+	database.AddProperty("engine", "postgres")
+	database.AddProperty("instance_class", "db.t3.micro")
+	model.AddResource(database)
+
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).Generate(model)
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	if dirExists(filepath.Join(tempDir, "modules", "eks")) {
+		t.Errorf("Expected no EKS module for a model without a cluster")
+	}
+	expectations := map[string][]string{
+		"terraform.tfvars": {
+			`vpc_cidr = "172.16.0.0/16"`,
+			`availability_zones = ["us-east-1a", "us-east-1b"]`,
+			`public_subnet_cidrs = ["172.16.0.0/24", "172.16.1.0/24"]`,
+			`private_subnet_cidrs = ["172.16.10.0/24"]`,
+			"enable_nat_gateway = false",
+		},
+		"resources.tf": {`resource "aws_s3_bucket" "app_logs"`, `resource "aws_s3_bucket_versioning" "app_logs_versioning"`, `resource "aws_db_instance" "orders_db"`, `engine = "postgres"`},
+		"outputs.tf":   {"module.vpc.vpc_id", "value       = aws_s3_bucket.app_logs.id", "value       = aws_db_instance.orders_db.id"},
+	}
+	for file, expected := range expectations {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, text := range expected {
+			if !strings.Contains(string(content), text) {
+				t.Errorf("Expected %s to contain %s, got:\n%s", file, text, content)
+			}
+		}
+	}
+	for _, file := range []string{"main.tf", "variables.tf", "outputs.tf", "terraform.tfvars"} {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		if strings.Contains(string(content), "cluster_name") || strings.Contains(string(content), "10.0.101.0/24") {
+			t.Errorf("Expected %s to hold only what the model describes, got:\n%s", file, content)
+		}
+	}
+}
+
+func TestTerraformGeneratorS3Backend(t *testing.T) {
+	tempDir := t.TempDir()
+
+	config := terraform.DefaultTerraformConfig()
+	config.BackendType = "s3"
+	config.BackendConfig = map[string]string{"bucket": "acme-state", "key": "infra/terraform.tfstate", "dynamodb_table": "terraform-locks"}
+	config.BackendBootstrap = true
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).WithConfig(config).Generate(createTestInfrastructureModel())
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	expectations := map[string][]string{
+		"versions.tf": {`backend "s3"`, `bucket = "acme-state"`, `key = "infra/terraform.tfstate"`, `dynamodb_table = "terraform-locks"`, `region = "us-east-1"`},
+		filepath.Join(terraform.BackendBootstrapDir, "main.tf"): {
+			`resource "aws_s3_bucket" "state"`, `bucket = "acme-state"`, "prevent_destroy = true", `status = "Enabled"`,
+			`resource "aws_dynamodb_table" "lock"`, `name         = "terraform-locks"`, `hash_key     = "LockID"`,
+		},
+		filepath.Join(terraform.BackendBootstrapDir, "outputs.tf"): {`output "state_bucket"`, `output "lock_table"`},
+	}
+	for file, expected := range expectations {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, text := range expected {
+			if !strings.Contains(string(content), text) {
+				t.Errorf("Expected %s to contain %q", file, text)
+			}
+		}
+	}
+
+	if err := terraform.ValidateBackend("gcs", nil, false); err == nil {
+		t.Errorf("Expected an unsupported backend to be rejected")
+	}
+	if err := terraform.ValidateBackend("s3", map[string]string{"key": "terraform.tfstate"}, true); err == nil {
+		t.Errorf("Expected a bootstrap configuration without a bucket to be rejected")
+	}
+}
+
+func TestTerraformGeneratorRegistryModules(t *testing.T) {
+	tempDir := t.TempDir()
+
+	config := terraform.DefaultTerraformConfig()
+	config.ModuleSource = terraform.ModuleSourceRegistry
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).WithConfig(config).Generate(createTestInfrastructureModel())
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	if dirExists(filepath.Join(tempDir, "modules")) {
+		t.Errorf("Expected no local modules with the registry modules")
+	}
+	expectations := map[string][]string{
+		"main.tf": {
+			`source  = "terraform-aws-modules/vpc/aws"`, "private_subnets    = var.private_subnet_cidrs",
+			`source  = "terraform-aws-modules/eks/aws"`, "subnet_ids = module.vpc.private_subnets", "eks_managed_node_groups = {",
+			`module "addon_irsa"`,
+		},
+		"outputs.tf": {"value       = module.vpc.private_subnets", "value       = module.eks.cluster_name"},
+	}
+	for file, expected := range expectations {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, text := range expected {
+			if !strings.Contains(string(content), text) {
+				t.Errorf("Expected %s to contain %q", file, text)
+			}
+		}
+	}
+	main, _ := os.ReadFile(filepath.Join(tempDir, "main.tf"))
+	if regexp.MustCompile(`version = "[~><=]`).Match(main) {
+		t.Errorf("Expected the registry modules to be pinned to exact versions")
+	}
+
+	if err := terraform.ValidateModuleSource("git"); err == nil {
+		t.Errorf("Expected an unsupported module source to be rejected")
+	}
+}
+
+func TestTerraformGeneratorRegionalResources(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := models.NewInfrastructureModel()
+	model.Region = "us-east-1"
+	model.AddResource(infra.CreateVPC("main-vpc", "10.0.0.0/16", true, true))
+	model.AddResource(infra.CreateSubnet("public-subnet-1", "main-vpc", "10.0.0.0/24", "us-east-1a"))
+	infra.AddSecondaryRegion(model, "us-west-2")
+	bucket := infra.CreateS3Bucket("audit-logs", "private", true)
+	bucket.AddProperty("region", "eu-west-1")
+	model.AddResource(bucket)
+	instance := models.NewResource(models.ResourceEC2Instance, "web-west")
+	instance.AddProperty("subnet_id", "public-subnet-1-us-west-2")
+	instance.AddProperty("region", "us-west-2")
+	model.AddResource(instance)
+
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).Generate(model)
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	expected := map[string][]string{
+		"provider.tf":  {`alias  = "us_west_2"`, `alias  = "eu_west_1"`},
+		"resources.tf": {"provider = aws.eu_west_1\n  bucket = \"audit-logs\"", `resource "aws_s3_bucket_versioning" "audit_logs_versioning" {` + "\n  provider = aws.eu_west_1"},
+		"compute.tf":   {"provider = aws.us_west_2", "subnet_id              = module.vpc_us_west_2.public_subnet_ids[0]"},
+	}
+	for file, contents := range expected {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, expected := range contents {
+			if !strings.Contains(string(content), expected) {
+				t.Errorf("Expected %s to contain %s", file, expected)
+			}
+		}
+	}
+
+	// Only regions with a VPC get a copy of the VPC module
+	main, _ := os.ReadFile(filepath.Join(tempDir, "main.tf"))
+	if strings.Contains(string(main), "vpc_eu_west_1") {
+		t.Errorf("Expected no VPC module in eu-west-1, which only has a bucket")
+	}
+}
+
+func TestTerraformGeneratorNodeGroupTfvars(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// A node group described without a capacity type is on-demand, and no other is invented
+	model := models.NewInfrastructureModel()
+	model.AddResource(models.NewResource(models.ResourceEKSCluster, "main-eks"))
+	nodeGroup := models.NewResource(models.ResourceNodeGroup, "main-node-group")
+	nodeGroup.AddProperty("cluster_name", "main-eks")
+	nodeGroup.AddProperty("instance_types", []string{"m5.large"})
+	nodeGroup.AddProperty("scaling_config", map[string]interface{}{"desired_size": 5, "min_size": 5, "max_size": 7})
+	model.AddResource(nodeGroup)
+
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).Generate(model)
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "terraform.tfvars"))
+	if err != nil {
+		t.Fatalf("Failed to read terraform.tfvars: %v", err)
+	}
+	tfvars := string(content)
+	for _, expected := range []string{`"main-node-group" = {`, `instance_types = ["m5.large"]`, `capacity_type = "ON_DEMAND"`, "desired_size = 5", "min_size = 5", "max_size = 7"} {
+		if !strings.Contains(tfvars, expected) {
+			t.Errorf("Expected terraform.tfvars to contain %s, got:\n%s", expected, tfvars)
+		}
+	}
+	for _, unexpected := range []string{"t3.medium", "SPOT", "default = {"} {
+		if strings.Contains(tfvars, unexpected) {
+			t.Errorf("Expected terraform.tfvars not to contain %s, got:\n%s", unexpected, tfvars)
+		}
+	}
+}
+
+func TestTerraformGeneratorWorkspaces(t *testing.T) {
+	tempDir := t.TempDir()
+
+	prod := terraform.DefaultWorkspace("prod")
+	if err := prod.Set("node_instance_type", "m5.xlarge"); err != nil {
+		t.Fatalf("Failed to set the node instance type: %v", err)
+	}
+	config := terraform.DefaultTerraformConfig()
+	config.Workspaces = []terraform.Workspace{terraform.DefaultWorkspace("dev"), terraform.DefaultWorkspace("staging"), prod}
+
+	// The workspaces size the node groups of the model
+	model := createTestInfrastructureModel()
+	nodeGroup := models.NewResource(models.ResourceNodeGroup, "main-node-group")
+	nodeGroup.AddProperty("cluster_name", "main-eks")
+	model.AddResource(nodeGroup)
+
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).WithConfig(config).Generate(model)
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	expected := map[string][]string{
+		"env/dev.tfvars":     {`cluster_name = "main-eks-dev"`, "single_nat_gateway = true", "desired_size = 1", `Environment = "dev"`},
+		"env/staging.tfvars": {`cluster_name = "main-eks-staging"`, "enable_nat_gateway = true", "single_nat_gateway = true"},
+		"env/prod.tfvars":    {`cluster_name = "main-eks-prod"`, "single_nat_gateway = false", `instance_types = ["m5.xlarge"]`},
+		"Makefile":           {"WORKSPACES := dev staging prod", "terraform workspace select $(ENV)", "-var-file=env/$(ENV).tfvars"},
+		"versions.tf":        {`workspace_dir = "environments"`},
+	}
+	for file, contents := range expected {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, expected := range contents {
+			if !strings.Contains(string(content), expected) {
+				t.Errorf("Expected %s to contain %s", file, expected)
+			}
+		}
+	}
+
+	if err := terraform.ValidateWorkspaces([]terraform.Workspace{{Name: "dev"}, {Name: "dev"}}); err == nil {
+		t.Errorf("Expected a workspace listed twice to be rejected")
+	}
+	if err := prod.Set("nat_gateways", "-1"); err == nil {
+		t.Errorf("Expected a negative NAT gateway count to be rejected")
+	}
+}
+
+func TestTerraformGeneratorVariableValidation(t *testing.T) {
+	tempDir := t.TempDir()
+
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).Generate(createTestInfrastructureModel())
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "variables.tf"))
+	if err != nil {
+		t.Fatalf("Failed to read variables.tf: %v", err)
+	}
+	for _, expected := range []string{
+		"var.aws_region))",
+		"can(cidrhost(var.vpc_cidr, 0))",
+		"for cidr in var.private_subnet_cidrs : can(cidrhost(cidr, 0))",
+		`can(regex("^1[.][0-9]+$", var.cluster_version))`,
+		`contains(["ON_DEMAND", "SPOT"], group.capacity_type)`,
+		"group.min_size <= group.desired_size && group.desired_size <= group.max_size",
+	} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected variables.tf to contain %s", expected)
+		}
+	}
+	if strings.Count(string(content), "validation {") != 7 {
+		t.Errorf("Expected 7 validation blocks, got:\n%s", content)
+	}
+}
+
+func TestTerraformGeneratorImports(t *testing.T) {
+	tempDir := t.TempDir()
+
+	importMap := filepath.Join(t.TempDir(), "imports.yaml")
+	if err := os.WriteFile(importMap, []byte("imports:\n  module.vpc.aws_vpc.this: vpc-0123456789abcdef0\n  module.eks.aws_eks_cluster.this: main-eks\n"), 0644); err != nil {
+		t.Fatalf("Failed to write the import map: %v", err)
+	}
+	imports, err := terraform.LoadImportMap(importMap)
+	if err != nil {
+		t.Fatalf("Failed to load the import map: %v", err)
+	}
+	config := terraform.DefaultTerraformConfig()
+	config.Imports = imports
+
+	_, err = terraform.NewTerraformGenerator().WithOutputDir(tempDir).WithConfig(config).Generate(createTestInfrastructureModel())
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	expected := map[string][]string{
+		"imports.tf":  {"import {\n  to = module.eks.aws_eks_cluster.this\n  id = \"main-eks\"\n}", "import {\n  to = module.vpc.aws_vpc.this\n  id = \"vpc-0123456789abcdef0\"\n}"},
+		"versions.tf": {`required_version = ">= 1.5.0"`},
+	}
+	for file, contents := range expected {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, expected := range contents {
+			if !strings.Contains(string(content), expected) {
+				t.Errorf("Expected %s to contain %s", file, expected)
+			}
+		}
+	}
+
+	if err := terraform.ValidateImports(map[string]string{"aws vpc": "vpc-1"}); err == nil {
+		t.Errorf("Expected an invalid resource address to be rejected")
+	}
+	if err := terraform.ValidateImports(map[string]string{"aws_s3_bucket.logs[\"eu\"]": ""}); err == nil {
+		t.Errorf("Expected an import without an ID to be rejected")
+	}
+}
+
+func TestTerraformGeneratorMovedBlocks(t *testing.T) {
+	tempDir := t.TempDir()
+
+	generate := func(bucketName string) {
+		model := models.NewInfrastructureModel()
+		model.Region = "us-east-1"
+		model.AddResource(infra.CreateS3Bucket(bucketName, "private", true))
+		model.AddResource(infra.CreateS3Bucket("audit-logs", "private", false))
+		if _, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).Generate(model); err != nil {
+			t.Fatalf("Failed to generate Terraform files: %v", err)
+		}
+	}
+
+	generate("app-logs")
+	if _, err := os.Stat(filepath.Join(tempDir, terraform.ManifestFile)); err != nil {
+		t.Fatalf("Expected the generation manifest to be written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "moved.tf")); err == nil {
+		t.Errorf("Expected no moved.tf before anything was renamed")
+	}
+
+	generate("service-logs")
+	content, err := os.ReadFile(filepath.Join(tempDir, "moved.tf"))
+	if err != nil {
+		t.Fatalf("Failed to read moved.tf: %v", err)
+	}
+	for _, expected := range []string{
+		"moved {\n  from = aws_s3_bucket.app_logs\n  to   = aws_s3_bucket.service_logs\n}",
+		"moved {\n  from = aws_s3_bucket_versioning.app_logs_versioning\n  to   = aws_s3_bucket_versioning.service_logs_versioning\n}",
+	} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected moved.tf to contain %s, got:\n%s", expected, content)
+		}
+	}
+	if strings.Contains(string(content), "audit_logs") {
+		t.Errorf("Expected the bucket that was not renamed to stay where it is")
+	}
+
+	// The moves are kept by later generations, as the state may not have been applied since
+	generate("service-logs")
+	if again, _ := os.ReadFile(filepath.Join(tempDir, "moved.tf")); string(again) != string(content) {
+		t.Errorf("Expected moved.tf to be kept by a generation without renames, got:\n%s", again)
+	}
+	// Renaming the bucket back moves it from its new name only, as a cycle is not allowed
+	generate("app-logs")
+	back, _ := os.ReadFile(filepath.Join(tempDir, "moved.tf"))
+	if !strings.Contains(string(back), "from = aws_s3_bucket.service_logs\n  to   = aws_s3_bucket.app_logs\n") || strings.Contains(string(back), "from = aws_s3_bucket.app_logs\n") {
+		t.Errorf("Expected the bucket to be moved back to its old name only, got:\n%s", back)
+	}
+}
+
+func TestTerraformGeneratorLifecycle(t *testing.T) {
+	tempDir := t.TempDir()
+
+	model := createTestInfrastructureModel()
+	model.AddResource(infra.CreateLaunchTemplate("web-lt", "t3.micro", "ami-12345678", ""))
+	config := terraform.DefaultTerraformConfig()
+	config.Lifecycle = map[string]terraform.Lifecycle{
+		"aws_eks_cluster":     {PreventDestroy: true},
+		"aws_eks_node_group":  {IgnoreChanges: []string{"scaling_config[0].desired_size"}},
+		"aws_launch_template": {PreventDestroy: true},
+	}
+
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).WithConfig(config).Generate(model)
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	expected := map[string][]string{
+		"modules/eks/main.tf": {
+			"  lifecycle {\n    prevent_destroy = true\n  }\n}\n\nresource \"aws_eks_node_group\"",
+			"  lifecycle {\n    ignore_changes = [scaling_config[0].desired_size]\n  }\n}",
+		},
+		// The launch template's own lifecycle block gets the setting
+		"compute.tf": {"  lifecycle {\n    prevent_destroy = true\n    create_before_destroy = true\n  }"},
+	}
+	for file, contents := range expected {
+		content, err := os.ReadFile(filepath.Join(tempDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, expected := range contents {
+			if !strings.Contains(string(content), expected) {
+				t.Errorf("Expected %s to contain %s, got:\n%s", file, expected, content)
+			}
+		}
+	}
+
+	if err := terraform.ValidateLifecycle(map[string]terraform.Lifecycle{"eks_cluster": {PreventDestroy: true}}); err == nil {
+		t.Errorf("Expected a resource type that is not a Terraform type to be rejected")
+	}
+	if err := terraform.ValidateLifecycle(map[string]terraform.Lifecycle{"aws_eks_node_group": {IgnoreChanges: []string{"scaling config"}}}); err == nil {
+		t.Errorf("Expected an invalid attribute to be rejected")
+	}
+}
+
+func TestTerraformGeneratorFormatOutput(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// A file that was there before is the user's, so it is left as it is
+	own := "resource \"aws_s3_bucket\" \"own\" {\n  bucket    = \"own-bucket\"\n}\n"
+	if err := os.WriteFile(filepath.Join(tempDir, "own.tf"), []byte(own), 0644); err != nil {
+		t.Fatalf("Failed to write own.tf: %v", err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(tempDir, "own.tf"), past, past); err != nil {
+		t.Fatalf("Failed to age own.tf: %v", err)
+	}
+
+	config := terraform.DefaultTerraformConfig()
+	config.FormatOutput = true
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).WithConfig(config).Generate(createTestInfrastructureModel())
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(tempDir, "*.tf*"))
+	files = append(files, filepath.Join(tempDir, "modules", "eks", "main.tf"))
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		if filepath.Base(file) == "own.tf" {
+			if string(content) != own {
+				t.Errorf("Expected own.tf to be left as it was, got:\n%s", content)
+			}
+			continue
+		}
+		if formatted := hclwrite.Format(content); !bytes.Equal(formatted, content) {
+			t.Errorf("Expected %s to be formatted, got:\n%s", file, content)
+		}
+	}
+}
+
+func TestTerraformGeneratorVersionPinning(t *testing.T) {
+	tempDir := t.TempDir()
+
+	config := terraform.DefaultTerraformConfig()
+	config.TerraformVersion = "1.6.0"
+	config.ProviderConstraint = ">= 5.40, < 6.0"
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).WithConfig(config).Generate(createTestInfrastructureModel())
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(tempDir, "versions.tf"))
+	if err != nil {
+		t.Fatalf("Failed to read versions.tf: %v", err)
+	}
+	for _, expected := range []string{`required_version = ">= 1.6.0"`, `version = ">= 5.40, < 6.0"`} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected versions.tf to contain %s, got:\n%s", expected, content)
+		}
+	}
+
+	// The EKS module's add-ons resolve conflicts as only the 5.x provider can
+	config = terraform.DefaultTerraformConfig()
+	config.ProviderConstraint = "~> 4.67"
+	_, err = terraform.NewTerraformGenerator().WithOutputDir(t.TempDir()).WithConfig(config).Generate(createTestInfrastructureModel())
+	if err == nil || !strings.Contains(err.Error(), "5.0.0") {
+		t.Errorf("Expected a provider without the add-on features to be rejected, got %v", err)
+	}
+
+	config = terraform.DefaultTerraformConfig()
+	config.ModuleSource = terraform.ModuleSourceRegistry
+	config.ProviderConstraint = "~> 5.40.0"
+	_, err = terraform.NewTerraformGenerator().WithOutputDir(t.TempDir()).WithConfig(config).Generate(createTestInfrastructureModel())
+	if err == nil || !strings.Contains(err.Error(), "terraform-aws-modules/eks/aws") {
+		t.Errorf("Expected a provider older than the registry EKS module supports to be rejected, got %v", err)
+	}
+
+	if err := terraform.ValidateTerraformVersion(">= 1.5"); err == nil {
+		t.Errorf("Expected a Terraform version with an operator to be rejected")
+	}
+	if err := terraform.ValidateProviderConstraint("~> 5.0, latest"); err == nil {
+		t.Errorf("Expected an invalid provider constraint to be rejected")
+	}
+}
+
+func TestTerraformGeneratorLockFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake terraform is a shell script")
+	}
+	tempDir := t.TempDir()
+
+	// The fake terraform records its arguments, and writes the lock file as providers lock does
+	bin := t.TempDir()
+	runs := filepath.Join(bin, "runs")
+	script := `#!/bin/sh
+echo "$@" >> "` + runs + `"
+if [ "$1" = "providers" ]; then
+  printf 'provider "registry.terraform.io/hashicorp/aws" {\n  version = "5.61.0"\n}\n' > .terraform.lock.hcl
+fi
+`
+	if err := os.WriteFile(filepath.Join(bin, "terraform"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write the fake terraform: %v", err)
+	}
+
+	config := terraform.DefaultTerraformConfig()
+	config.LockPlatforms = []string{"linux_amd64", "darwin_arm64"}
+	config.TerraformPath = filepath.Join(bin, "terraform")
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).WithConfig(config).Generate(createTestInfrastructureModel())
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	content, err := os.ReadFile(runs)
+	if err != nil {
+		t.Fatalf("Failed to read the runs of terraform: %v", err)
+	}
+	expected := "init -backend=false -input=false -no-color\nproviders lock -platform=linux_amd64 -platform=darwin_arm64\n"
+	if string(content) != expected {
+		t.Errorf("Expected terraform to run:\n%s\ngot:\n%s", expected, content)
+	}
+	if !utils.FileExists(filepath.Join(tempDir, terraform.LockFile)) {
+		t.Errorf("Expected %s to be written", terraform.LockFile)
+	}
+	if utils.FileExists(filepath.Join(tempDir, ".terraform")) {
+		t.Errorf("Expected the provider cache not to be written to the output directory")
+	}
+
+	// Without terraform, the lock file cannot be written
+	config.TerraformPath = filepath.Join(bin, "missing")
+	if _, err := terraform.NewTerraformGenerator().WithOutputDir(t.TempDir()).WithConfig(config).Generate(createTestInfrastructureModel()); err == nil {
+		t.Errorf("Expected generation to fail when terraform cannot run")
+	}
+	if err := terraform.ValidateLockPlatforms([]string{"linux-amd64"}); err == nil {
+		t.Errorf("Expected an invalid platform to be rejected")
+	}
+}
+
+func TestTerraformGeneratorSensitiveOutputs(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// The user's own file is left as it is, even with an output that should be sensitive
+	own := "output \"db_password\" {\n  value = aws_db_instance.db.password\n}\n"
+	if err := os.WriteFile(filepath.Join(tempDir, "own.tf"), []byte(own), 0644); err != nil {
+		t.Fatalf("Failed to write own.tf: %v", err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(tempDir, "own.tf"), past, past); err != nil {
+		t.Fatalf("Failed to age own.tf: %v", err)
+	}
+
+	_, err := terraform.NewTerraformGenerator().WithOutputDir(tempDir).WithConfig(terraform.DefaultTerraformConfig()).Generate(createTestInfrastructureModel())
+	if err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "outputs.tf"))
+	if err != nil {
+		t.Fatalf("Failed to read outputs.tf: %v", err)
+	}
+	// The root output of the module's CA data is sensitive, as the module's output is
+	expected := "output \"cluster_ca_certificate\" {\n  description = \"Base64 encoded certificate data required to communicate with the cluster\"\n  value       = module.eks.cluster_ca_certificate\n  sensitive   = true\n}\n"
+	if !strings.Contains(string(content), expected) {
+		t.Errorf("Expected outputs.tf to contain %s, got:\n%s", expected, content)
+	}
+	if strings.Count(string(content), "sensitive") != 1 {
+		t.Errorf("Expected only the CA data output to be sensitive, got:\n%s", content)
+	}
+
+	if content, _ := os.ReadFile(filepath.Join(tempDir, "own.tf")); string(content) != own {
+		t.Errorf("Expected own.tf to be left as it was, got:\n%s", content)
+	}
+}
+
+func TestTerraformGeneratorModuleLayout(t *testing.T) {
+	model := createTestInfrastructureModel()
+	bucket := models.NewResource(models.ResourceS3Bucket, "app-logs")
+	bucket.AddProperty("bucket", "app-logs")
+	model.AddResource(bucket)
+
+	// The by-type layout groups the resources into the network, compute and data modules
+	byTypeDir := t.TempDir()
+	config := terraform.DefaultTerraformConfig()
+	config.ModuleLayout = terraform.ModuleLayoutByType
+	config.CustomModuleNames = map[string]string{"eks": "cluster"}
+	if _, err := terraform.NewTerraformGenerator().WithOutputDir(byTypeDir).WithConfig(config).Generate(model); err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+	for _, module := range []string{"network", "cluster", "data"} {
+		if !fileExists(filepath.Join(byTypeDir, "modules", module, "main.tf")) {
+			t.Errorf("Expected the %s module to be generated", module)
+		}
+	}
+	if dirExists(filepath.Join(byTypeDir, "modules", "vpc")) || fileExists(filepath.Join(byTypeDir, "resources.tf")) {
+		t.Errorf("Expected no vpc module and no resources.tf in the by-type layout")
+	}
+	mainTf, _ := os.ReadFile(filepath.Join(byTypeDir, "main.tf"))
+	for _, expected := range []string{`module "network" {`, `source = "./modules/cluster"`, "vpc_id          = module.network.vpc_id", `module "data" {`} {
+		if !strings.Contains(string(mainTf), expected) {
+			t.Errorf("Expected main.tf to contain %s, got:\n%s", expected, mainTf)
+		}
+	}
+	outputsTf, _ := os.ReadFile(filepath.Join(byTypeDir, "outputs.tf"))
+	if !strings.Contains(string(outputsTf), "value       = module.data.app_logs_id") {
+		t.Errorf("Expected the bucket's output to come from the data module, got:\n%s", outputsTf)
+	}
+
+	// The flat layout declares the contents of the modules in the root module
+	flatDir := t.TempDir()
+	config = terraform.DefaultTerraformConfig()
+	config.ModuleLayout = terraform.ModuleLayoutFlat
+	if _, err := terraform.NewTerraformGenerator().WithOutputDir(flatDir).WithConfig(config).Generate(model); err != nil {
+		t.Fatalf("Failed to generate Terraform files: %v", err)
+	}
+	if dirExists(filepath.Join(flatDir, "modules")) {
+		t.Errorf("Expected no modules directory in the flat layout")
+	}
+	vpcTf, _ := os.ReadFile(filepath.Join(flatDir, "vpc.tf"))
+	for _, expected := range []string{"vpc_vpc_cidr             = var.vpc_cidr", "cidr_block           = local.vpc_vpc_cidr", "vpc_vpc_id                  = aws_vpc.this.id"} {
+		if !strings.Contains(string(vpcTf), expected) {
+			t.Errorf("Expected vpc.tf to contain %s, got:\n%s", expected, vpcTf)
+		}
+	}
+	eksTf, _ := os.ReadFile(filepath.Join(flatDir, "eks.tf"))
+	if !strings.Contains(string(eksTf), "eks_vpc_id                    = local.vpc_vpc_id") {
+		t.Errorf("Expected the cluster to be in the VPC of vpc.tf, got:\n%s", eksTf)
+	}
+	if !fileExists(filepath.Join(flatDir, "eks_iam.tf")) {
+		t.Errorf("Expected the IAM roles of the cluster in eks_iam.tf")
+	}
+	mainTf, _ = os.ReadFile(filepath.Join(flatDir, "main.tf"))
+	if strings.Contains(string(mainTf), "module") {
+		t.Errorf("Expected no module calls in main.tf, got:\n%s", mainTf)
+	}
+	// The root output of the CA data is sensitive through the local
+	outputsTf, _ = os.ReadFile(filepath.Join(flatDir, "outputs.tf"))
+	expected := "  value       = local.eks_cluster_ca_certificate\n  sensitive   = true\n"
+	if !strings.Contains(string(outputsTf), expected) {
+		t.Errorf("Expected outputs.tf to contain %s, got:\n%s", expected, outputsTf)
+	}
+
+	// The flat layout cannot declare a copy of the VPC in a secondary region
+	model.Region = "us-east-1"
+	secondary := models.NewResource(models.ResourceVPC, "west")
+	secondary.AddProperty("region", "us-west-2")
+	model.AddResource(secondary)
+	if _, err := terraform.NewTerraformGenerator().WithOutputDir(t.TempDir()).WithConfig(config).Generate(model); err == nil || !strings.Contains(err.Error(), "us-west-2") {
+		t.Errorf("Expected the flat layout to reject the VPC in us-west-2, got %v", err)
+	}
+}
+
+func TestTerraformGeneratorResourceOrder(t *testing.T) {
+	// The replica is listed before the bucket it depends on
+	model := models.NewInfrastructureModel()
+	replica := infra.CreateS3Bucket("logs-replica", "private", true)
+	replica.AddDependency("app-logs")
+	model.AddResource(replica)
+	model.AddResource(infra.CreateS3Bucket("app-logs", "private", true))
+
+	var generated []string
+	for i := 0; i < 2; i++ {
+		dir := t.TempDir()
+		if _, err := terraform.NewTerraformGenerator().WithOutputDir(dir).Generate(model); err != nil {
+			t.Fatalf("Failed to generate Terraform files: %v", err)
+		}
+		content, err := os.ReadFile(filepath.Join(dir, "resources.tf"))
+		if err != nil {
+			t.Fatalf("Failed to read resources.tf: %v", err)
+		}
+		generated = append(generated, string(content))
+	}
+	if generated[0] != generated[1] {
+		t.Errorf("Expected the same resources.tf on every run, got:\n%s\nthen:\n%s", generated[0], generated[1])
+	}
+	bucket := strings.Index(generated[0], `resource "aws_s3_bucket" "app_logs"`)
+	dependent := strings.Index(generated[0], `resource "aws_s3_bucket" "logs_replica"`)
+	if bucket < 0 || dependent < 0 || bucket > dependent {
+		t.Errorf("Expected the bucket to be declared before the replica depending on it, got:\n%s", generated[0])
+	}
+	if model.Resources[0].Name != "logs-replica" {
+		t.Errorf("Expected the model to keep its order, got %s first", model.Resources[0].Name)
+	}
+
+	// A dependency cycle cannot be ordered
+	model.Resources[1].AddDependency("logs-replica")
+	if _, err := terraform.NewTerraformGenerator().WithOutputDir(t.TempDir()).Generate(model); err == nil || !strings.Contains(err.Error(), "dependency cycle between resources: logs-replica, app-logs") {
+		t.Errorf("Expected a dependency cycle error, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package tfstate
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/riptano/iac_generator_cli/internal/infra"
+	"github.com/riptano/iac_generator_cli/internal/tfstate"
+	"github.com/riptano/iac_generator_cli/pkg/models"
+	"github.com/riptano/iac_generator_cli/test/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stateFile is a state with a VPC and an EKS cluster, as modules write them
+var stateFile = filepath.Join("..", "fixtures", "states", "eks.tfstate")
+
+func TestLoadFile(t *testing.T) {
+	result, err := tfstate.LoadFile(stateFile)
+	require.NoError(t, err)
+	model := result.Model
+
+	// Data sources are left out, unsupported types are skipped
+	assert.Len(t, result.Imported, 10)
+	assert.Equal(t, []string{"module.eks.aws_iam_role.cluster"}, result.Skipped)
+	assert.Len(t, model.Resources, 10)
+
+	// The region comes from the ARNs, the tags every resource has become the model's
+	assert.Equal(t, "us-west-2", model.Region)
+	assert.Equal(t, map[string]string{"CostCenter": "4321", "ManagedBy": "terraform"}, model.Tags)
+
+	// Resources are named after their name in AWS, their Name tag or their address
+	var names []string
+	for _, resource := range model.Resources {
+		names = append(names, resource.Name)
+	}
+	assert.Equal(t, []string{
+		"shop-vpc", "public-1", "public-2", "private-1", "private-2",
+		"this", "this-1", "shop", "general", "coredns",
+	}, names)
+
+	// and refer to each other by these names
+	subnet := utils.ResourceNamed(t, model, "private-2")
+	assert.Equal(t, models.ResourceSubnet, subnet.Type)
+	vpcID, _ := subnet.GetProperty("vpc_id")
+	assert.Equal(t, "shop-vpc", vpcID)
+	nat := utils.ResourceNamed(t, model, "this-1")
+	subnetID, _ := nat.GetProperty("subnet_id")
+	assert.Equal(t, "public-1", subnetID)
+	nodeGroup := utils.ResourceNamed(t, model, "general")
+	cluster, _ := nodeGroup.GetProperty("cluster_name")
+	assert.Equal(t, "shop", cluster)
+	subnetIDs, _ := nodeGroup.GetProperty("subnet_ids")
+	assert.Equal(t, []string{"private-1", "private-2"}, subnetIDs)
+	assert.Equal(t, []string{"shop"}, utils.ResourceNamed(t, model, "coredns").DependsOn)
+
+	// The imported model is one the generators accept
+	assert.NoError(t, infra.NewModelValidator().Validate(model))
+}
+
+func TestLoad(t *testing.T) {
+	// Resources the model does not have are referred to by their ID
+	result, err := tfstate.Load([]byte(`{
+		"version": 4,
+		"resources": [{
+			"mode": "managed", "type": "aws_subnet", "name": "app",
+			"instances": [{"attributes": {
+				"id": "subnet-0abc", "vpc_id": "vpc-existing", "cidr_block": "10.0.1.0/24",
+				"availability_zone": "eu-west-1a", "tags": {"Name": "App Subnet"}
+			}}]
+		}]
+	}`))
+	require.NoError(t, err)
+	require.Len(t, result.Model.Resources, 1)
+	subnet := result.Model.Resources[0]
+	assert.Equal(t, "app-subnet", subnet.Name)
+	vpcID, _ := subnet.GetProperty("vpc_id")
+	assert.Equal(t, "vpc-existing", vpcID)
+	assert.Equal(t, "eu-west-1", result.Model.Region)
+
+	_, err = tfstate.Load([]byte(`{"version": 3, "modules": []}`))
+	assert.EqualError(t, err, "unsupported state version 3 (only version 4, written by Terraform 0.12 and later, can be imported)")
+
+	_, err = tfstate.Load([]byte(`{"version": 4, "resources": [{"mode": "managed", "type": "aws_iam_role", "name": "x", "instances": [{"attributes": {}}]}]}`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the state has no resources that can be imported")
+
+	_, err = tfstate.Load([]byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestSupportedTypes(t *testing.T) {
+	types := tfstate.SupportedTypes()
+	assert.Contains(t, types, "aws_vpc")
+	assert.Contains(t, types, "aws_eks_cluster")
+	assert.IsIncreasing(t, types)
+}
@@ -326,6 +326,18 @@ func FindFilesByPattern(t *testing.T, baseDir, pattern string) []string {
 	return matches
 }
 
+// ResourceNamed returns the resource of the model with a name, failing the test without one
+func ResourceNamed(t *testing.T, model *models.InfrastructureModel, name string) *models.Resource {
+	t.Helper()
+	for i := range model.Resources {
+		if model.Resources[i].Name == name {
+			return &model.Resources[i]
+		}
+	}
+	t.Fatalf("the model has no resource %s", name)
+	return nil
+}
+
 // ContainsString checks if a string slice contains a specific string
 func ContainsString(slice []string, s string) bool {
 	for _, item := range slice {